@@ -428,6 +428,7 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 	f.features = (&fs.Features{
 		CaseInsensitive:         true,
 		CanHaveEmptyDirectories: true,
+		Trash:                   true,
 	}).Fill(ctx, f)
 	f.srv.SetErrorHandler(errorHandler)
 
@@ -0,0 +1,713 @@
+// Package combine implements a backend to combine multiple remotes in a directory tree
+package combine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/config/configstruct"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// Register with Fs
+func init() {
+	fsi := &fs.RegInfo{
+		Name:        "combine",
+		Description: "Combine several remotes into one",
+		NewFs:       NewFs,
+		Options: []fs.Option{{
+			Name: "upstreams",
+			Help: `Upstreams for combining
+
+These should be in the form
+
+    dir=remote:path dir2=remote2:path
+
+Where before the = is specified the root directory and after is the remote to
+put there.
+
+Embedded spaces can be added using quotes
+
+    "dir=remote:path with space" "dir2=remote2:path with space"
+
+`,
+			Required: true,
+			Default:  fs.SpaceSepList(nil),
+		}},
+	}
+	fs.Register(fsi)
+}
+
+// Options defines the configuration for this backend
+type Options struct {
+	Upstreams fs.SpaceSepList `config:"upstreams"`
+}
+
+// Fs represents a combine of upstreams
+type Fs struct {
+	name      string               // name of this remote
+	root      string               // the path we are working on, relative to the combine root
+	features  *fs.Features         // optional features
+	opt       Options              // options for this Fs
+	hashSet   hash.Set             // common hashes
+	when      time.Time            // directory times
+	upstreams map[string]*upstream // map of upstreams
+}
+
+// upstream is a remote mounted on a top level directory of the combine
+type upstream struct {
+	f      fs.Fs  // the upstream Fs
+	parent *Fs    // the combine Fs this is part of
+	dir    string // directory the upstream is mounted on in the combine
+	remote string // the remote string used to make the upstream
+}
+
+// parseUpstream parses an upstream in the form "dir=remote:path"
+func parseUpstream(upstream string) (dir, remote string, err error) {
+	equal := strings.IndexRune(upstream, '=')
+	if equal < 0 {
+		return "", "", fmt.Errorf("no \"=\" in upstream %q", upstream)
+	}
+	dir, remote = strings.Trim(upstream[:equal], "/"), upstream[equal+1:]
+	if dir == "" {
+		return "", "", fmt.Errorf("empty dir in upstream %q", upstream)
+	}
+	if strings.Contains(dir, "/") {
+		return "", "", fmt.Errorf("dir in upstream %q must be a single directory name", upstream)
+	}
+	if remote == "" {
+		return "", "", fmt.Errorf("empty remote in upstream %q", upstream)
+	}
+	return dir, remote, nil
+}
+
+// NewFs constructs an Fs from the path.
+//
+// The returned Fs is the actual Fs, referenced by remote in the config
+func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, error) {
+	// Parse config into Options struct
+	opt := new(Options)
+	err := configstruct.Set(m, opt)
+	if err != nil {
+		return nil, err
+	}
+	if len(opt.Upstreams) == 0 {
+		return nil, errors.New("combine can't point to an empty upstream - check the value of the upstreams setting")
+	}
+	root = strings.Trim(root, "/")
+	f := &Fs{
+		name:      name,
+		root:      root,
+		opt:       *opt,
+		when:      time.Now(),
+		upstreams: make(map[string]*upstream, len(opt.Upstreams)),
+	}
+	for _, s := range opt.Upstreams {
+		dir, remote, err := parseUpstream(s)
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(remote, name+":") {
+			return nil, errors.New("can't point combine remote at itself - check the value of the upstreams setting")
+		}
+		if _, found := f.upstreams[dir]; found {
+			return nil, fmt.Errorf("duplicate directory name %q in upstreams", dir)
+		}
+		f.upstreams[dir] = &upstream{
+			parent: f,
+			dir:    dir,
+			remote: remote,
+		}
+	}
+
+	// The root must be in an upstream if it isn't the combine root
+	if root != "" {
+		_, _, err := f.findUpstream(root)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Make the upstreams in parallel
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, u := range f.upstreams {
+		wg.Add(1)
+		go func(u *upstream) {
+			defer wg.Done()
+			uFs, err := cache.Get(ctx, u.remote)
+			if err == fs.ErrorIsFile {
+				err = fmt.Errorf("upstream %q for %q must be a directory not a file", u.remote, u.dir)
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				return
+			}
+			u.f = uFs
+		}(u)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	f.features = (&fs.Features{
+		CaseInsensitive:         true,
+		DuplicateFiles:          false,
+		ReadMimeType:            true,
+		WriteMimeType:           true,
+		CanHaveEmptyDirectories: true,
+		SetTier:                 true,
+		GetTier:                 true,
+		Trash:                   true,
+	}).Fill(ctx, f)
+
+	// Mask the features with those of the upstreams and find the
+	// common hashes
+	first := true
+	for _, u := range f.upstreams {
+		f.features = f.features.Mask(ctx, u.f)
+		if first {
+			f.hashSet = u.f.Hashes()
+			first = false
+		} else {
+			f.hashSet = f.hashSet.Overlap(u.f.Hashes())
+		}
+	}
+
+	// Check to see if the root points to a file in an upstream
+	if u, uRemote, err := f.findUpstream(root); err == nil && uRemote != "" {
+		_, err := u.f.NewObject(ctx, uRemote)
+		if err == nil {
+			f.root = path.Dir(root)
+			if f.root == "." {
+				f.root = ""
+			}
+			// return an error with an fs which points to the parent
+			return f, fs.ErrorIsFile
+		}
+	}
+	return f, nil
+}
+
+// fullPath returns remote relative to the root of the combine
+func (f *Fs) fullPath(remote string) string {
+	return path.Join(f.root, remote)
+}
+
+// relativePath returns the remote relative to the combine root as a
+// path relative to f.root
+func (f *Fs) relativePath(remote string) string {
+	if f.root == "" {
+		return remote
+	}
+	if remote == f.root {
+		return ""
+	}
+	return strings.TrimPrefix(remote, f.root+"/")
+}
+
+// errNotInUpstream is returned when an operation needs a path inside
+// an upstream but was given the root or a path outside the upstreams
+var errNotInUpstream = errors.New("can't do this outside an upstream directory - use one of the top level directories")
+
+// findUpstream finds the upstream for the remote given, relative to
+// the combine root, returning the upstream and the path relative to
+// it.
+//
+// If the remote isn't in an upstream it returns fs.ErrorDirNotFound.
+func (f *Fs) findUpstream(remote string) (u *upstream, uRemote string, err error) {
+	remote = strings.Trim(remote, "/")
+	dir, uRemote := remote, ""
+	if i := strings.IndexRune(remote, '/'); i >= 0 {
+		dir, uRemote = remote[:i], remote[i+1:]
+	}
+	u = f.upstreams[dir]
+	if u == nil {
+		return nil, "", fs.ErrorDirNotFound
+	}
+	return u, uRemote, nil
+}
+
+// findUpstreamObject finds the upstream for the object at remote,
+// relative to f.root, which must be inside an upstream directory.
+func (f *Fs) findUpstreamObject(remote string) (u *upstream, uRemote string, err error) {
+	u, uRemote, err = f.findUpstream(f.fullPath(remote))
+	if err != nil {
+		return nil, "", errNotInUpstream
+	}
+	if uRemote == "" {
+		return nil, "", fs.ErrorIsDir
+	}
+	return u, uRemote, nil
+}
+
+// Name of the remote (as passed into NewFs)
+func (f *Fs) Name() string {
+	return f.name
+}
+
+// Root of the remote (as passed into NewFs)
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// String converts this Fs to a string
+func (f *Fs) String() string {
+	return fmt.Sprintf("combine root '%s:%s'", f.name, f.root)
+}
+
+// Features returns the optional features of this Fs
+func (f *Fs) Features() *fs.Features {
+	return f.features
+}
+
+// Precision is the greatest precision of all the upstreams
+func (f *Fs) Precision() time.Duration {
+	var greatestPrecision time.Duration
+	for _, u := range f.upstreams {
+		if p := u.f.Precision(); p > greatestPrecision {
+			greatestPrecision = p
+		}
+	}
+	return greatestPrecision
+}
+
+// Hashes returns the hash types supported by all the upstreams
+func (f *Fs) Hashes() hash.Set {
+	return f.hashSet
+}
+
+// dirNames returns the upstream directory names in sorted order
+func (f *Fs) dirNames() []string {
+	dirs := make([]string, 0, len(f.upstreams))
+	for dir := range f.upstreams {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// List the objects and directories in dir into entries.  The
+// entries can be returned in any order but should be for a
+// complete directory.
+//
+// dir should be "" to list the root, and should not have
+// trailing slashes.
+//
+// This should return ErrDirNotFound if the directory isn't
+// found.
+func (f *Fs) List(ctx context.Context, dir string) (entries fs.DirEntries, err error) {
+	dir = f.fullPath(dir)
+	if dir == "" {
+		entries = make(fs.DirEntries, 0, len(f.upstreams))
+		for _, dir := range f.dirNames() {
+			entries = append(entries, fs.NewDir(dir, f.when))
+		}
+		return entries, nil
+	}
+	u, uRemote, err := f.findUpstream(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err = u.f.List(ctx, uRemote)
+	if err != nil {
+		return nil, err
+	}
+	return u.wrapEntries(ctx, entries)
+}
+
+// NewObject creates a new remote combine file object
+func (f *Fs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	u, uRemote, err := f.findUpstream(f.fullPath(remote))
+	if err != nil {
+		return nil, fs.ErrorObjectNotFound
+	}
+	if uRemote == "" {
+		return nil, fs.ErrorIsDir
+	}
+	o, err := u.f.NewObject(ctx, uRemote)
+	if err != nil {
+		return nil, err
+	}
+	return u.newObject(o), nil
+}
+
+// Mkdir makes the directory (container, bucket)
+//
+// Shouldn't return an error if it already exists
+func (f *Fs) Mkdir(ctx context.Context, dir string) error {
+	dir = f.fullPath(dir)
+	if dir == "" {
+		return nil
+	}
+	u, uRemote, err := f.findUpstream(dir)
+	if err != nil {
+		return errNotInUpstream
+	}
+	return u.f.Mkdir(ctx, uRemote)
+}
+
+// Rmdir removes the directory (container, bucket) if empty
+//
+// Return an error if it doesn't exist or isn't empty
+func (f *Fs) Rmdir(ctx context.Context, dir string) error {
+	dir = f.fullPath(dir)
+	if dir == "" {
+		return fs.ErrorDirectoryNotEmpty
+	}
+	u, uRemote, err := f.findUpstream(dir)
+	if err != nil {
+		return err
+	}
+	if uRemote == "" {
+		return errors.New("can't remove an upstream directory")
+	}
+	return u.f.Rmdir(ctx, uRemote)
+}
+
+// Put in to the remote path with the modTime given of the given size
+//
+// May create the object even if it returns an error - if so
+// will return the object and the error, otherwise will return
+// nil and the error
+func (f *Fs) Put(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	u, uRemote, err := f.findUpstreamObject(src.Remote())
+	if err != nil {
+		return nil, err
+	}
+	o, err := u.f.Put(ctx, in, operations.NewOverrideRemote(src, uRemote), options...)
+	if err != nil {
+		return nil, err
+	}
+	return u.newObject(o), nil
+}
+
+// PutStream uploads to the remote path with the modTime given of indeterminate size
+//
+// May create the object even if it returns an error - if so
+// will return the object and the error, otherwise will return
+// nil and the error
+func (f *Fs) PutStream(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (fs.Object, error) {
+	u, uRemote, err := f.findUpstreamObject(src.Remote())
+	if err != nil {
+		return nil, err
+	}
+	do := u.f.Features().PutStream
+	if do == nil {
+		return nil, errors.New("can't PutStream to this upstream")
+	}
+	o, err := do(ctx, in, operations.NewOverrideRemote(src, uRemote), options...)
+	if err != nil {
+		return nil, err
+	}
+	return u.newObject(o), nil
+}
+
+// Purge all files in the directory
+//
+// Implement this if you have a way of deleting all the files
+// quicker than just running Remove() on the result of List()
+//
+// Return an error if it doesn't exist
+func (f *Fs) Purge(ctx context.Context, dir string) error {
+	u, uRemote, err := f.findUpstream(f.fullPath(dir))
+	if err != nil {
+		return err
+	}
+	if uRemote == "" {
+		return errors.New("can't purge an upstream directory")
+	}
+	do := u.f.Features().Purge
+	if do == nil {
+		return fs.ErrorCantPurge
+	}
+	return do(ctx, uRemote)
+}
+
+// Copy src to this remote using server-side copy operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantCopy
+func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok {
+		fs.Debugf(src, "Can't copy - not same remote type")
+		return nil, fs.ErrorCantCopy
+	}
+	u, uRemote, err := f.findUpstreamObject(remote)
+	if err != nil {
+		return nil, err
+	}
+	do := u.f.Features().Copy
+	if do == nil || srcObj.u.f != u.f {
+		fs.Debugf(src, "Can't copy - not in the same upstream")
+		return nil, fs.ErrorCantCopy
+	}
+	o, err := do(ctx, srcObj.Object, uRemote)
+	if err != nil {
+		return nil, err
+	}
+	return u.newObject(o), nil
+}
+
+// Move src to this remote using server-side move operations.
+//
+// This is stored with the remote path given
+//
+// It returns the destination Object and a possible error
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantMove
+func (f *Fs) Move(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+	srcObj, ok := src.(*Object)
+	if !ok {
+		fs.Debugf(src, "Can't move - not same remote type")
+		return nil, fs.ErrorCantMove
+	}
+	u, uRemote, err := f.findUpstreamObject(remote)
+	if err != nil {
+		return nil, err
+	}
+	do := u.f.Features().Move
+	if do == nil || srcObj.u.f != u.f {
+		fs.Debugf(src, "Can't move - not in the same upstream")
+		return nil, fs.ErrorCantMove
+	}
+	o, err := do(ctx, srcObj.Object, uRemote)
+	if err != nil {
+		return nil, err
+	}
+	return u.newObject(o), nil
+}
+
+// DirMove moves src, srcRemote to this remote at dstRemote
+// using server-side move operations.
+//
+// Will only be called if src.Fs().Name() == f.Name()
+//
+// If it isn't possible then return fs.ErrorCantDirMove
+//
+// If destination exists then return fs.ErrorDirExists
+func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string) error {
+	srcFs, ok := src.(*Fs)
+	if !ok {
+		fs.Debugf(src, "Can't move directory - not same remote type")
+		return fs.ErrorCantDirMove
+	}
+	srcU, srcURemote, err := srcFs.findUpstream(srcFs.fullPath(srcRemote))
+	if err != nil {
+		return err
+	}
+	dstU, dstURemote, err := f.findUpstream(f.fullPath(dstRemote))
+	if err != nil {
+		return errNotInUpstream
+	}
+	if srcURemote == "" || dstURemote == "" {
+		return errors.New("can't move an upstream directory")
+	}
+	do := dstU.f.Features().DirMove
+	if do == nil || srcU.f != dstU.f {
+		fs.Debugf(src, "Can't move directory - not in the same upstream")
+		return fs.ErrorCantDirMove
+	}
+	return do(ctx, srcU.f, srcURemote, dstURemote)
+}
+
+// About gets quota information from the Fs
+//
+// The values are totalled across the upstreams. If any upstream
+// doesn't know a value then it is left out.
+func (f *Fs) About(ctx context.Context) (*fs.Usage, error) {
+	usage := &fs.Usage{
+		Total:   new(int64),
+		Used:    new(int64),
+		Trashed: new(int64),
+		Other:   new(int64),
+		Free:    new(int64),
+		Objects: new(int64),
+	}
+	add := func(total **int64, value *int64) {
+		if value != nil && *total != nil {
+			**total += *value
+		} else {
+			*total = nil
+		}
+	}
+	for _, u := range f.upstreams {
+		do := u.f.Features().About
+		if do == nil {
+			return nil, errors.New("not all upstreams support About")
+		}
+		usg, err := do(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("about failed for %q: %w", u.dir, err)
+		}
+		add(&usage.Total, usg.Total)
+		add(&usage.Used, usg.Used)
+		add(&usage.Trashed, usg.Trashed)
+		add(&usage.Other, usg.Other)
+		add(&usage.Free, usg.Free)
+		add(&usage.Objects, usg.Objects)
+	}
+	return usage, nil
+}
+
+// DirCacheFlush resets the directory cache - used in testing
+// as an optional interface
+func (f *Fs) DirCacheFlush() {
+	for _, u := range f.upstreams {
+		if do := u.f.Features().DirCacheFlush; do != nil {
+			do()
+		}
+	}
+}
+
+// Shutdown the backend, closing any background tasks and any
+// cached connections.
+func (f *Fs) Shutdown(ctx context.Context) (err error) {
+	for _, u := range f.upstreams {
+		if do := u.f.Features().Shutdown; do != nil {
+			if uErr := do(ctx); uErr != nil {
+				fs.Errorf(f, "Shutdown of %q failed: %v", u.dir, uErr)
+				err = uErr
+			}
+		}
+	}
+	return err
+}
+
+// wrapEntries makes the entries from the upstream appear in the
+// combine by prefixing them with the upstream's directory
+func (u *upstream) wrapEntries(ctx context.Context, entries fs.DirEntries) (fs.DirEntries, error) {
+	for i, entry := range entries {
+		switch x := entry.(type) {
+		case fs.Object:
+			entries[i] = u.newObject(x)
+		case fs.Directory:
+			entries[i] = fs.NewDirCopy(ctx, x).SetRemote(u.parent.relativePath(path.Join(u.dir, x.Remote())))
+		default:
+			return nil, fmt.Errorf("unknown entry type %T", entry)
+		}
+	}
+	return entries, nil
+}
+
+// Object describes a wrapped Object
+//
+// This is a wrapped Object which knows its path prefix
+type Object struct {
+	fs.Object
+	u *upstream
+}
+
+// newObject wraps o as an object in the combine
+func (u *upstream) newObject(o fs.Object) *Object {
+	return &Object{
+		Object: o,
+		u:      u,
+	}
+}
+
+// Fs returns read only access to the Fs that this object is part of
+func (o *Object) Fs() fs.Info {
+	return o.u.parent
+}
+
+// Remote returns the remote path
+func (o *Object) Remote() string {
+	return o.u.parent.relativePath(path.Join(o.u.dir, o.Object.Remote()))
+}
+
+// String returns a description of the Object
+func (o *Object) String() string {
+	if o == nil {
+		return "<nil>"
+	}
+	return o.Remote()
+}
+
+// Update in to the object with the modTime given of the given size
+//
+// When called from outside an Fs by rclone, src.Size() will always be >= 0.
+// But for unknown-sized objects (indicated by src.Size() == -1), Upload should either
+// return an error or update the object properly (rather than e.g. calling panic).
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	return o.Object.Update(ctx, in, operations.NewOverrideRemote(src, o.Object.Remote()), options...)
+}
+
+// MimeType returns the content type of the Object if known
+func (o *Object) MimeType(ctx context.Context) (mimeType string) {
+	if do, ok := o.Object.(fs.MimeTyper); ok {
+		mimeType = do.MimeType(ctx)
+	}
+	return mimeType
+}
+
+// ID returns the ID of the Object if known, or "" if not
+func (o *Object) ID() string {
+	if do, ok := o.Object.(fs.IDer); ok {
+		return do.ID()
+	}
+	return ""
+}
+
+// SetTier performs changing storage tier of the Object if
+// multiple storage classes supported
+func (o *Object) SetTier(tier string) error {
+	do, ok := o.Object.(fs.SetTierer)
+	if !ok {
+		return errors.New("can't SetTier on this upstream")
+	}
+	return do.SetTier(tier)
+}
+
+// GetTier returns storage tier or class of the Object
+func (o *Object) GetTier() string {
+	if do, ok := o.Object.(fs.GetTierer); ok {
+		return do.GetTier()
+	}
+	return ""
+}
+
+// UnWrap returns the Object that this Object is wrapping or nil if it
+// isn't wrapping anything
+func (o *Object) UnWrap() fs.Object {
+	return o.Object
+}
+
+// Check the interfaces are satisfied
+var (
+	_ fs.Fs              = (*Fs)(nil)
+	_ fs.Purger          = (*Fs)(nil)
+	_ fs.PutStreamer     = (*Fs)(nil)
+	_ fs.Copier          = (*Fs)(nil)
+	_ fs.Mover           = (*Fs)(nil)
+	_ fs.DirMover        = (*Fs)(nil)
+	_ fs.DirCacheFlusher = (*Fs)(nil)
+	_ fs.Abouter         = (*Fs)(nil)
+	_ fs.Shutdowner      = (*Fs)(nil)
+	_ fs.FullObject      = (*Object)(nil)
+)
@@ -0,0 +1,182 @@
+package combine
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUpstream(t *testing.T) {
+	for _, test := range []struct {
+		in     string
+		dir    string
+		remote string
+		err    string
+	}{
+		{in: "dir=remote:path", dir: "dir", remote: "remote:path"},
+		{in: "/dir/=/local/path", dir: "dir", remote: "/local/path"},
+		{in: "dir=remote:a=b", dir: "dir", remote: "remote:a=b"},
+		{in: "remote:path", err: `no "=" in upstream "remote:path"`},
+		{in: "=remote:", err: `empty dir in upstream "=remote:"`},
+		{in: "a/b=remote:", err: `dir in upstream "a/b=remote:" must be a single directory name`},
+		{in: "dir=", err: `empty remote in upstream "dir="`},
+	} {
+		dir, remote, err := parseUpstream(test.in)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.dir, dir, test.in)
+		assert.Equal(t, test.remote, remote, test.in)
+	}
+}
+
+// newTestFs makes a combine of two local directories
+func newTestFs(t *testing.T, root string) fs.Fs {
+	upstreams := fs.SpaceSepList{"one=" + t.TempDir(), "two=" + t.TempDir()}
+	f, err := NewFs(context.Background(), "TestCombine", root, configmap.Simple{
+		"upstreams": upstreams.String(),
+	})
+	require.NoError(t, err)
+	return f
+}
+
+func put(ctx context.Context, t *testing.T, f fs.Fs, remote, contents string) fs.Object {
+	src := object.NewStaticObjectInfo(remote, time.Now(), int64(len(contents)), true, nil, f)
+	o, err := f.Put(ctx, bytes.NewBufferString(contents), src)
+	require.NoError(t, err)
+	return o
+}
+
+func TestCombine(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFs(t, "")
+	assert.Equal(t, "", f.Root())
+
+	// The root lists the upstreams
+	entries, err := f.List(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "one", entries[0].Remote())
+	assert.Equal(t, "two", entries[1].Remote())
+
+	// Files go into the upstreams
+	o := put(ctx, t, f, "one/dir/file.txt", "hello")
+	assert.Equal(t, "one/dir/file.txt", o.Remote())
+	assert.Equal(t, f, o.Fs())
+
+	entries, err = f.List(ctx, "one")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "one/dir", entries[0].Remote())
+
+	entries, err = f.List(ctx, "one/dir")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "one/dir/file.txt", entries[0].Remote())
+
+	o, err = f.NewObject(ctx, "one/dir/file.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), o.Size())
+
+	// Update keeps the path in the upstream
+	src := object.NewStaticObjectInfo(o.Remote(), time.Now(), 7, true, nil, f)
+	require.NoError(t, o.Update(ctx, bytes.NewBufferString("goodbye"), src))
+	assert.Equal(t, "one/dir/file.txt", o.Remote())
+	assert.Equal(t, int64(7), o.Size())
+
+	// Not in an upstream
+	_, err = f.NewObject(ctx, "three/file.txt")
+	assert.Equal(t, fs.ErrorObjectNotFound, err)
+	_, err = f.NewObject(ctx, "one")
+	assert.Equal(t, fs.ErrorIsDir, err)
+	_, err = f.List(ctx, "three")
+	assert.Equal(t, fs.ErrorDirNotFound, err)
+	src = object.NewStaticObjectInfo("file.txt", time.Now(), 1, true, nil, f)
+	_, err = f.Put(ctx, bytes.NewBufferString("x"), src)
+	assert.Equal(t, errNotInUpstream, err)
+	assert.Equal(t, errNotInUpstream, f.Mkdir(ctx, "three"))
+	assert.NoError(t, f.Mkdir(ctx, ""))
+	assert.Equal(t, fs.ErrorDirectoryNotEmpty, f.Rmdir(ctx, ""))
+	assert.Error(t, f.Rmdir(ctx, "one"))
+
+	// Moves within an upstream are server-side
+	moved, err := operations.Move(ctx, f, nil, "one/moved.txt", o)
+	require.NoError(t, err)
+	assert.Equal(t, "one/moved.txt", moved.Remote())
+
+	// Moves between upstreams aren't
+	_, err = f.Features().Move(ctx, moved, "two/moved.txt")
+	assert.Equal(t, fs.ErrorCantMove, err)
+	moved, err = operations.Move(ctx, f, nil, "two/moved.txt", moved)
+	require.NoError(t, err)
+	assert.Equal(t, "two/moved.txt", moved.Remote())
+	_, err = f.NewObject(ctx, "one/moved.txt")
+	assert.Equal(t, fs.ErrorObjectNotFound, err)
+}
+
+func TestCombineRoot(t *testing.T) {
+	ctx := context.Background()
+	upstreams := "one=" + t.TempDir() + " two=" + t.TempDir()
+	newFs := func(root string) (fs.Fs, error) {
+		return NewFs(ctx, "TestCombine", root, configmap.Simple{"upstreams": upstreams})
+	}
+	f, err := newFs("two/dir")
+	require.NoError(t, err)
+	assert.Equal(t, "TestCombine", f.Name())
+	assert.Equal(t, "two/dir", f.Root())
+
+	// Paths are relative to the root
+	o := put(ctx, t, f, "sub/file.txt", "hello")
+	assert.Equal(t, "sub/file.txt", o.Remote())
+	entries, err := f.List(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "sub", entries[0].Remote())
+	entries, err = f.List(ctx, "sub")
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "sub/file.txt", entries[0].Remote())
+
+	root, err := newFs("")
+	require.NoError(t, err)
+	_, err = root.NewObject(ctx, "two/dir/sub/file.txt")
+	require.NoError(t, err)
+
+	// A root pointing to a file returns the parent
+	f, err = newFs("two/dir/sub/file.txt")
+	assert.Equal(t, fs.ErrorIsFile, err)
+	assert.Equal(t, "two/dir/sub", f.Root())
+	_, err = f.NewObject(ctx, "file.txt")
+	require.NoError(t, err)
+
+	_, err = NewFs(ctx, "TestCombine", "three", configmap.Simple{
+		"upstreams": "one=" + t.TempDir(),
+	})
+	assert.Equal(t, fs.ErrorDirNotFound, err)
+}
+
+func TestCombineErrors(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		upstreams string
+		err       string
+	}{
+		{"", "combine can't point to an empty upstream - check the value of the upstreams setting"},
+		{"a=TestCombine:b", "can't point combine remote at itself - check the value of the upstreams setting"},
+		{"a=/tmp b=/tmp a=/tmp", `duplicate directory name "a" in upstreams`},
+	} {
+		_, err := NewFs(ctx, "TestCombine", "", configmap.Simple{"upstreams": test.upstreams})
+		assert.EqualError(t, err, test.err, test.upstreams)
+	}
+}
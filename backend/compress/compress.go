@@ -21,6 +21,7 @@ import (
 
 	"github.com/buengese/sgzip"
 	"github.com/gabriel-vasile/mimetype"
+	"github.com/klauspost/compress/zstd"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
@@ -43,6 +44,7 @@ const (
 	minCompressionRatio = 1.1
 
 	gzFileExt           = ".gz"
+	zstdFileExt         = ".zst"
 	metaFileExt         = ".json"
 	uncompressedFileExt = ".bin"
 )
@@ -51,6 +53,7 @@ const (
 const (
 	Uncompressed = 0
 	Gzip         = 2
+	Zstd         = 3
 )
 
 var nameRegexp = regexp.MustCompile("^(.+?)\\.([A-Za-z0-9-_]{11})$")
@@ -62,6 +65,9 @@ func init() {
 		{ // Default compression mode options {
 			Value: "gzip",
 			Help:  "Standard gzip compression with fastest parameters.",
+		}, {
+			Value: "zstd",
+			Help:  "Zstandard compression, faster than gzip for a similar ratio.\nReading from an offset decompresses from the start of the file.",
 		},
 	}
 
@@ -84,6 +90,8 @@ func init() {
 			Help: `GZIP compression level (-2 to 9).
 
 Generally -1 (default, equivalent to 5) is recommended.
+For zstd, levels 1 to 9 are mapped onto the zstd levels and -1 is
+the zstd default.
 Levels 1 to 9 increase compression at the cost of speed. Going past 6 
 generally offers very little return.
 
@@ -180,9 +188,13 @@ func NewFs(ctx context.Context, name, rpath string, m configmap.Mapper) (fs.Fs,
 		SetTier:                 true,
 		BucketBased:             true,
 		CanHaveEmptyDirectories: true,
+		Trash:                   true,
 	}).Fill(ctx, f).Mask(ctx, wrappedFs).WrapsFs(f, wrappedFs)
 	// We support reading MIME types no matter the wrapped fs
 	f.features.ReadMimeType = true
+	// We compress uploads with a Content-Encoding header no matter
+	// the wrapped fs
+	f.features.CompressUploads = true
 	// We can only support putstream if we have serverside copy or move
 	if !operations.CanServerSideMove(wrappedFs) {
 		f.features.Disable("PutStream")
@@ -195,6 +207,8 @@ func compressionModeFromName(name string) int {
 	switch name {
 	case "gzip":
 		return Gzip
+	case "zstd":
+		return Zstd
 	default:
 		return Uncompressed
 	}
@@ -237,7 +251,10 @@ func processFileName(compressedFileName string) (origFileName string, extension
 	if err != nil {
 		return "", "", 0, errors.New("Could not decode size")
 	}
-	return match[1], gzFileExt, size, nil
+	if extension != zstdFileExt {
+		extension = gzFileExt
+	}
+	return match[1], extension, size, nil
 }
 
 // Generates the file name for a metadata file
@@ -252,10 +269,13 @@ func isMetadataFile(filename string) bool {
 
 // makeDataName generates the file name for a data file with specified compression mode
 func makeDataName(remote string, size int64, mode int) (newRemote string) {
-	if mode != Uncompressed {
-		newRemote = remote + "." + int64ToBase64(size) + gzFileExt
-	} else {
+	switch mode {
+	case Uncompressed:
 		newRemote = remote + uncompressedFileExt
+	case Zstd:
+		newRemote = remote + "." + int64ToBase64(size) + zstdFileExt
+	default:
+		newRemote = remote + "." + int64ToBase64(size) + gzFileExt
 	}
 	return newRemote
 }
@@ -434,6 +454,69 @@ type compressionResult struct {
 	meta sgzip.GzipMetadata
 }
 
+// zstdLevel converts the gzip style compression level in the config
+// into a zstd encoder level
+func zstdLevel(level int) zstd.EncoderLevel {
+	if level < 0 {
+		return zstd.SpeedDefault
+	}
+	return zstd.EncoderLevelFromZstd(level)
+}
+
+// compressTo compresses in into w with mode, returning the metadata
+// needed to read it back. Only the size is recorded for zstd.
+func (f *Fs) compressTo(w io.Writer, in io.Reader, mode int) (meta sgzip.GzipMetadata, err error) {
+	if mode == Zstd {
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstdLevel(f.opt.CompressionLevel)))
+		if err != nil {
+			return meta, err
+		}
+		meta.Size, err = io.Copy(zw, in)
+		zErr := zw.Close()
+		if zErr != nil {
+			fs.Errorf(nil, "Failed to close compress: %v", zErr)
+			if err == nil {
+				err = zErr
+			}
+		}
+		return meta, err
+	}
+	gz, err := sgzip.NewWriterLevel(w, f.opt.CompressionLevel)
+	if err != nil {
+		return meta, err
+	}
+	_, err = io.Copy(gz, in)
+	gzErr := gz.Close()
+	if gzErr != nil {
+		fs.Errorf(nil, "Failed to close compress: %v", gzErr)
+		if err == nil {
+			err = gzErr
+		}
+	}
+	return gz.MetaData(), err
+}
+
+// uploadMode returns the compression mode to use for an upload with
+// options and the options to pass on to the wrapped remote.
+//
+// A Content-Encoding upload header naming a supported compression,
+// e.g. from --header-upload or --vfs-write-back-compress, overrides
+// the configured mode for this upload. It isn't passed on as the
+// metadata file is uploaded with the same options.
+func (f *Fs) uploadMode(options []fs.OpenOption) (mode int, newOptions []fs.OpenOption) {
+	mode = f.mode
+	for _, option := range options {
+		if x, ok := option.(*fs.HTTPOption); ok && strings.EqualFold(x.Key, "Content-Encoding") {
+			if m := compressionModeFromName(strings.ToLower(x.Value)); m != Uncompressed {
+				mode = m
+				continue
+			}
+		}
+		newOptions = append(newOptions, option)
+	}
+	return mode, newOptions
+}
+
 // replicating some of operations.Rcat functionality because we want to support remotes without streaming
 // support and of course cannot know the size of a compressed file before compressing it.
 func (f *Fs) rcat(ctx context.Context, dstFileName string, in io.ReadCloser, modTime time.Time, options []fs.OpenOption) (o fs.Object, err error) {
@@ -482,7 +565,7 @@ func (f *Fs) rcat(ctx context.Context, dstFileName string, in io.ReadCloser, mod
 }
 
 // Put a compressed version of a file. Returns a wrappable object and metadata.
-func (f *Fs) putCompress(ctx context.Context, in io.Reader, src fs.ObjectInfo, options []fs.OpenOption, mimeType string) (fs.Object, *ObjectMetadata, error) {
+func (f *Fs) putCompress(ctx context.Context, in io.Reader, src fs.ObjectInfo, options []fs.OpenOption, mode int, mimeType string) (fs.Object, *ObjectMetadata, error) {
 	// Unwrap reader accounting
 	in, wrap := accounting.UnWrap(in)
 
@@ -494,19 +577,7 @@ func (f *Fs) putCompress(ctx context.Context, in io.Reader, src fs.ObjectInfo, o
 	pipeReader, pipeWriter := io.Pipe()
 	results := make(chan compressionResult)
 	go func() {
-		gz, err := sgzip.NewWriterLevel(pipeWriter, f.opt.CompressionLevel)
-		if err != nil {
-			results <- compressionResult{err: err, meta: sgzip.GzipMetadata{}}
-			return
-		}
-		_, err = io.Copy(gz, in)
-		gzErr := gz.Close()
-		if gzErr != nil {
-			fs.Errorf(nil, "Failed to close compress: %v", gzErr)
-			if err == nil {
-				err = gzErr
-			}
-		}
+		meta, err := f.compressTo(pipeWriter, in, mode)
 		closeErr := pipeWriter.Close()
 		if closeErr != nil {
 			fs.Errorf(nil, "Failed to close pipe: %v", closeErr)
@@ -514,7 +585,7 @@ func (f *Fs) putCompress(ctx context.Context, in io.Reader, src fs.ObjectInfo, o
 				err = closeErr
 			}
 		}
-		results <- compressionResult{err: err, meta: gz.MetaData()}
+		results <- compressionResult{err: err, meta: meta}
 	}()
 	wrappedIn := wrap(bufio.NewReaderSize(pipeReader, bufferSize)) // Probably no longer needed as sgzip has it's own buffering
 
@@ -536,7 +607,7 @@ func (f *Fs) putCompress(ctx context.Context, in io.Reader, src fs.ObjectInfo, o
 	}
 
 	// Transfer the data
-	o, err := f.rcat(ctx, makeDataName(src.Remote(), src.Size(), f.mode), ioutil.NopCloser(wrappedIn), src.ModTime(ctx), options)
+	o, err := f.rcat(ctx, makeDataName(src.Remote(), src.Size(), mode), ioutil.NopCloser(wrappedIn), src.ModTime(ctx), options)
 	//o, err := operations.Rcat(ctx, f.Fs, makeDataName(src.Remote(), src.Size(), f.mode), ioutil.NopCloser(wrappedIn), src.ModTime(ctx))
 	if err != nil {
 		if o != nil {
@@ -561,7 +632,7 @@ func (f *Fs) putCompress(ctx context.Context, in io.Reader, src fs.ObjectInfo, o
 	}
 
 	// Generate metadata
-	meta := newMetadata(result.meta.Size, f.mode, result.meta, hex.EncodeToString(metaHasher.Sum(nil)), mimeType)
+	meta := newMetadata(result.meta.Size, mode, result.meta, hex.EncodeToString(metaHasher.Sum(nil)), mimeType)
 
 	// Check the hashes of the compressed data if we were comparing them
 	if ht != hash.None && hasher != nil {
@@ -652,8 +723,9 @@ func (f *Fs) putWithCustomFunctions(ctx context.Context, in io.Reader, src fs.Ob
 	var dataObject fs.Object
 	var meta *ObjectMetadata
 	var err error
+	mode, options := f.uploadMode(options)
 	if compressible {
-		dataObject, meta, err = f.putCompress(ctx, in, src, options, mimeType)
+		dataObject, meta, err = f.putCompress(ctx, in, src, options, mode, mimeType)
 	} else {
 		dataObject, meta, err = f.putUncompress(ctx, in, src, putData, options, mimeType)
 	}
@@ -1280,6 +1352,9 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (rc io.Read
 	}
 	// Get a chunkedreader for the wrapped object
 	chunkedReader := chunkedreader.New(ctx, o.Object, initialChunkSize, maxChunkSize)
+	if o.meta.Mode == Zstd {
+		return openZstd(chunkedReader, offset, limit)
+	}
 	// Get file handle
 	var file io.Reader
 	if offset != 0 {
@@ -1301,6 +1376,43 @@ func (o *Object) Open(ctx context.Context, options ...fs.OpenOption) (rc io.Read
 	return ReadCloserWrapper{Reader: fileReader, Closer: chunkedReader}, nil
 }
 
+// zstdReadCloser closes the zstd decoder and the data it reads from
+type zstdReadCloser struct {
+	io.Reader
+	zr *zstd.Decoder
+	in io.Closer
+}
+
+// Close the decoder and the underlying reader
+func (z zstdReadCloser) Close() error {
+	z.zr.Close()
+	return z.in.Close()
+}
+
+// openZstd decompresses in from offset for limit bytes
+//
+// zstd files have no index so data before offset is decompressed
+// and discarded.
+func openZstd(in io.ReadCloser, offset, limit int64) (io.ReadCloser, error) {
+	zr, err := zstd.NewReader(in)
+	if err != nil {
+		_ = in.Close()
+		return nil, err
+	}
+	rc := zstdReadCloser{Reader: zr, zr: zr, in: in}
+	if offset != 0 {
+		_, err = io.CopyN(ioutil.Discard, zr, offset)
+		if err != nil && err != io.EOF {
+			_ = rc.Close()
+			return nil, err
+		}
+	}
+	if limit != -1 {
+		rc.Reader = io.LimitReader(zr, limit)
+	}
+	return rc, nil
+}
+
 // ObjectInfo describes a wrapped fs.ObjectInfo for being the source
 type ObjectInfo struct {
 	src    fs.ObjectInfo
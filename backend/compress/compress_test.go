@@ -4,14 +4,18 @@ package compress
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	_ "github.com/rclone/rclone/backend/drive"
 	_ "github.com/rclone/rclone/backend/local"
 	_ "github.com/rclone/rclone/backend/s3"
 	_ "github.com/rclone/rclone/backend/swift"
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fstest"
 	"github.com/rclone/rclone/fstest/fstests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestIntegration runs integration tests against the remote
@@ -63,3 +67,66 @@ func TestRemoteGzip(t *testing.T) {
 		},
 	})
 }
+
+// TestRemoteZstd tests zstd compression
+func TestRemoteZstd(t *testing.T) {
+	if *fstest.RemoteName != "" {
+		t.Skip("Skipping as -remote set")
+	}
+	tempdir := filepath.Join(os.TempDir(), "rclone-compress-test-zstd")
+	name := "TestCompressZstd"
+	fstests.Run(t, &fstests.Opt{
+		RemoteName: name + ":",
+		NilObject:  (*Object)(nil),
+		UnimplementableFsMethods: []string{
+			"OpenWriterAt",
+			"MergeDirs",
+			"DirCacheFlush",
+			"PutUnchecked",
+			"PutStream",
+			"UserInfo",
+			"Disconnect",
+		},
+		UnimplementableObjectMethods: []string{
+			"GetTier",
+			"SetTier",
+		},
+		ExtraConfig: []fstests.ExtraConfigItem{
+			{Name: name, Key: "type", Value: "compress"},
+			{Name: name, Key: "remote", Value: tempdir},
+			{Name: name, Key: "mode", Value: "zstd"},
+		},
+	})
+}
+
+func TestUploadMode(t *testing.T) {
+	f := &Fs{mode: Gzip}
+	other := &fs.HTTPOption{Key: "Cache-Control", Value: "no-cache"}
+
+	mode, options := f.uploadMode([]fs.OpenOption{other})
+	assert.Equal(t, Gzip, mode)
+	assert.Equal(t, []fs.OpenOption{other}, options)
+
+	mode, options = f.uploadMode([]fs.OpenOption{other, &fs.HTTPOption{Key: "content-encoding", Value: "ZSTD"}})
+	assert.Equal(t, Zstd, mode)
+	assert.Equal(t, []fs.OpenOption{other}, options)
+
+	unknown := &fs.HTTPOption{Key: "Content-Encoding", Value: "br"}
+	mode, options = f.uploadMode([]fs.OpenOption{unknown})
+	assert.Equal(t, Gzip, mode)
+	assert.Equal(t, []fs.OpenOption{unknown}, options)
+}
+
+func TestDataNameZstd(t *testing.T) {
+	name := makeDataName("dir/file.txt", 12345, Zstd)
+	assert.True(t, strings.HasSuffix(name, zstdFileExt))
+	origName, ext, size, err := processFileName(name)
+	require.NoError(t, err)
+	assert.Equal(t, "dir/file.txt", origName)
+	assert.Equal(t, zstdFileExt, ext)
+	assert.Equal(t, int64(12345), size)
+
+	_, ext, _, err = processFileName(makeDataName("file.txt", 1, Gzip))
+	require.NoError(t, err)
+	assert.Equal(t, gzFileExt, ext)
+}
@@ -1169,6 +1169,7 @@ func newFs(ctx context.Context, name, path string, m configmap.Mapper) (*Fs, err
 		WriteMimeType:           true,
 		CanHaveEmptyDirectories: true,
 		ServerSideAcrossConfigs: opt.ServerSideAcrossConfigs,
+		Trash:                   opt.UseTrash,
 	}).Fill(ctx, f)
 
 	// Create a new authorized Drive client.
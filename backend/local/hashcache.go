@@ -0,0 +1,144 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/lib/kv"
+)
+
+// hashCacheFacility is the name of the database the hashes of local
+// files are cached in with --local-hash-cache
+const hashCacheFacility = "localhash"
+
+// hashCacheRecord is the cached hashes of a local file
+//
+// They are only used while the file has the same size, modification
+// time and inode as when they were read.
+type hashCacheRecord struct {
+	Size    int64
+	ModTime time.Time
+	Inode   uint64
+	Hashes  map[string]string
+}
+
+// newHashCacheRecord makes an empty record for the file described by fi
+func newHashCacheRecord(fi os.FileInfo) *hashCacheRecord {
+	return &hashCacheRecord{
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+		Inode:   readInode(fi),
+		Hashes:  map[string]string{},
+	}
+}
+
+// sameFile returns true if r is for the same version of the file as other
+func (r *hashCacheRecord) sameFile(other *hashCacheRecord) bool {
+	return r.Size == other.Size && r.ModTime.Equal(other.ModTime) && r.Inode == other.Inode
+}
+
+// decode the record from data
+func (r *hashCacheRecord) decode(data []byte) error {
+	return gob.NewDecoder(bytes.NewBuffer(data)).Decode(r)
+}
+
+// encode the record
+func (r *hashCacheRecord) encode() ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(r)
+	return buf.Bytes(), err
+}
+
+// hashCacheGet reads the cached hash of a file
+type hashCacheGet struct {
+	key  string
+	want *hashCacheRecord // the file as it is now
+	hash string           // name of the hash wanted
+	val  string           // the hash value if found
+}
+
+// Do the get
+func (op *hashCacheGet) Do(ctx context.Context, b kv.Bucket) error {
+	data := b.Get([]byte(op.key))
+	if len(data) == 0 {
+		return nil
+	}
+	var r hashCacheRecord
+	if err := r.decode(data); err != nil {
+		return fmt.Errorf("invalid record: %w", err)
+	}
+	if r.sameFile(op.want) {
+		op.val = r.Hashes[op.hash]
+	}
+	return nil
+}
+
+// hashCachePut adds hashes of a file to the cache
+type hashCachePut struct {
+	key    string
+	record *hashCacheRecord
+}
+
+// Do the put
+func (op *hashCachePut) Do(ctx context.Context, b kv.Bucket) error {
+	r := op.record
+	// Keep the other hashes of the same version of the file
+	if data := b.Get([]byte(op.key)); len(data) > 0 {
+		var old hashCacheRecord
+		if old.decode(data) == nil && old.sameFile(r) {
+			for name, value := range old.Hashes {
+				if _, ok := r.Hashes[name]; !ok {
+					r.Hashes[name] = value
+				}
+			}
+		}
+	}
+	data, err := r.encode()
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+	return b.Put([]byte(op.key), data)
+}
+
+// cachedHash returns the hash of type ht for o from the hash cache
+// and the file info it is valid for, or "" if it isn't there.
+func (o *Object) cachedHash(ht hash.Type) (string, os.FileInfo) {
+	fi, err := os.Lstat(o.path)
+	if err != nil {
+		return "", nil
+	}
+	op := &hashCacheGet{
+		key:  o.path,
+		want: newHashCacheRecord(fi),
+		hash: ht.String(),
+	}
+	err = o.fs.hashCache.Do(false, op)
+	if err != nil {
+		fs.Debugf(o, "Failed to read hash cache: %v", err)
+		return "", fi
+	}
+	return op.val, fi
+}
+
+// putCachedHash stores the hash of type ht for o which was read
+// while the file was as described by fi
+func (o *Object) putCachedHash(ht hash.Type, value string, fi os.FileInfo) {
+	if fi == nil {
+		return
+	}
+	record := newHashCacheRecord(fi)
+	record.Hashes[ht.String()] = value
+	err := o.fs.hashCache.Do(true, &hashCachePut{
+		key:    o.path,
+		record: record,
+	})
+	if err != nil {
+		fs.Debugf(o, "Failed to write hash cache: %v", err)
+	}
+}
@@ -0,0 +1,21 @@
+// Inode reading functions
+
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package local
+
+import (
+	"os"
+	"syscall"
+)
+
+// readInode turns a valid os.FileInfo into an inode number,
+// returning 0 if it fails.
+func readInode(fi os.FileInfo) uint64 {
+	statT, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+	return uint64(statT.Ino) // nolint: unconvert
+}
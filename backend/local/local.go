@@ -24,8 +24,10 @@ import (
 	"github.com/rclone/rclone/fs/config/configstruct"
 	"github.com/rclone/rclone/fs/fserrors"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/lib/delta"
 	"github.com/rclone/rclone/lib/encoder"
 	"github.com/rclone/rclone/lib/file"
+	"github.com/rclone/rclone/lib/kv"
 	"github.com/rclone/rclone/lib/readers"
 	"golang.org/x/text/unicode/norm"
 )
@@ -42,6 +44,10 @@ func init() {
 		Description: "Local Disk",
 		NewFs:       NewFs,
 		CommandHelp: commandHelp,
+		MetadataInfo: &fs.MetadataInfo{
+			System: systemMetadataInfo,
+			Help:   "Depending on which OS is in use the local backend may return only some of the system metadata. Setting system metadata is supported on all OSes but setting user metadata is not supported.",
+		},
 		Options: []fs.Option{{
 			Name:     "nounc",
 			Help:     "Disable UNC (long path names) conversion on Windows.",
@@ -192,6 +198,23 @@ when copying to a CIFS mount owned by another user. If this option is
 enabled, rclone will no longer update the modtime after copying a file.`,
 			Default:  false,
 			Advanced: true,
+		}, {
+			Name: "hash_cache",
+			Help: `Cache the hashes of files to avoid reading them again.
+
+Normally rclone reads the whole of a local file each time it needs its
+hash, e.g. for each --checksum sync or cryptcheck, which can take
+a long time on large trees.
+
+With this flag rclone stores the hashes it reads in a database in the
+cache directory and uses them again while the file has the same size,
+modification time and inode number (inode numbers are not available
+on Windows).
+
+A file which is changed without changing any of those, which is
+unusual, won't be hashed again so will have the wrong hash.`,
+			Default:  false,
+			Advanced: true,
 		}, {
 			Name:     config.ConfigEncoding,
 			Help:     config.ConfigEncodingHelp,
@@ -216,6 +239,7 @@ type Options struct {
 	NoPreAllocate     bool                 `config:"no_preallocate"`
 	NoSparse          bool                 `config:"no_sparse"`
 	NoSetModTime      bool                 `config:"no_set_modtime"`
+	HashCache         bool                 `config:"hash_cache"`
 	Enc               encoder.MultiEncoder `config:"encoding"`
 }
 
@@ -226,6 +250,7 @@ type Fs struct {
 	opt         Options             // parsed config options
 	features    *fs.Features        // optional features
 	dev         uint64              // device number of root node
+	hashCache   *kv.DB              // cache of hashes if --local-hash-cache is set
 	precisionOk sync.Once           // Whether we need to read the precision
 	precision   time.Duration       // precision of local filesystem
 	warnedMu    sync.Mutex          // used for locking access to 'warned'.
@@ -279,10 +304,18 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 		CanHaveEmptyDirectories: true,
 		IsLocal:                 true,
 		SlowHash:                true,
+		ReadMetadata:            true,
+		WriteMetadata:           true,
 	}).Fill(ctx, f)
 	if opt.FollowSymlinks {
 		f.lstat = os.Stat
 	}
+	if opt.HashCache {
+		f.hashCache, err = kv.Start(ctx, hashCacheFacility, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open hash cache: %w", err)
+		}
+	}
 
 	// Check to see if this points to a file
 	fi, err := f.lstat(f.root)
@@ -787,6 +820,14 @@ func (f *Fs) DirMove(ctx context.Context, src fs.Fs, srcRemote, dstRemote string
 	return nil
 }
 
+// Shutdown the backend, closing the hash cache if open
+func (f *Fs) Shutdown(ctx context.Context) error {
+	if f.hashCache == nil {
+		return nil
+	}
+	return f.hashCache.Stop(false)
+}
+
 // Hashes returns the supported hash sets.
 func (f *Fs) Hashes() hash.Set {
 	return hash.Supported()
@@ -884,6 +925,20 @@ func (o *Object) Hash(ctx context.Context, r hash.Type) (string, error) {
 	o.fs.objectMetaMu.RUnlock()
 
 	if changed || !hashFound {
+		var cacheInfo os.FileInfo
+		if o.fs.hashCache != nil && !o.translatedLink && !o.fs.opt.NoCheckUpdated {
+			hashValue, cacheInfo = o.cachedHash(r)
+			if hashValue != "" {
+				o.fs.objectMetaMu.Lock()
+				if o.hashes == nil {
+					o.hashes = map[hash.Type]string{}
+				}
+				o.hashes[r] = hashValue
+				o.fs.objectMetaMu.Unlock()
+				return hashValue, nil
+			}
+		}
+
 		var in io.ReadCloser
 
 		if !o.translatedLink {
@@ -919,6 +974,9 @@ func (o *Object) Hash(ctx context.Context, r hash.Type) (string, error) {
 			o.hashes[r] = hashValue
 		}
 		o.fs.objectMetaMu.Unlock()
+		if cacheInfo != nil {
+			o.putCachedHash(r, hashValue, cacheInfo)
+		}
 	}
 	return hashValue, nil
 }
@@ -1222,6 +1280,116 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		return err
 	}
 
+	// Set the metadata if passed in
+	if metadata := fs.GetMetadataOptions(options); metadata != nil {
+		err = o.writeMetadata(ctx, metadata)
+		if err != nil {
+			return err
+		}
+	}
+
+	// ReRead info now that we have finished
+	return o.lstat()
+}
+
+// Signature returns the checksums of the blocks of blockSize bytes
+// of the file for delta transfers
+func (o *Object) Signature(ctx context.Context, blockSize int) (sig *delta.Signature, err error) {
+	if o.translatedLink {
+		return nil, fs.ErrorNotImplemented
+	}
+	in, err := file.Open(o.path)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.CheckClose(in, &err)
+	return delta.Sign(in, blockSize)
+}
+
+// Patch updates the file by applying the delta read from in to its
+// current contents.
+//
+// The new contents are written to a temporary file alongside which is
+// renamed over the file when complete.
+func (o *Object) Patch(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) (err error) {
+	if o.translatedLink {
+		return fs.ErrorNotImplemented
+	}
+	var hasher *hash.MultiHasher
+	for _, option := range options {
+		switch x := option.(type) {
+		case *fs.HashesOption:
+			if x.Hashes.Count() > 0 {
+				hasher, err = hash.NewMultiHasherTypes(x.Hashes)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	base, err := file.Open(o.path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = base.Close()
+	}()
+	info, err := base.Stat()
+	if err != nil {
+		return err
+	}
+	tmpPath := o.path + ".rclone-delta"
+	f, err := file.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if !o.fs.opt.NoPreAllocate {
+		err = file.PreAllocate(src.Size(), f)
+		if err != nil {
+			fs.Debugf(o, "Failed to pre-allocate: %v", err)
+			if err == file.ErrDiskFull {
+				_ = f.Close()
+				_ = os.Remove(tmpPath)
+				return err
+			}
+		}
+	}
+	var out io.Writer = f
+	if hasher != nil {
+		out = io.MultiWriter(f, hasher)
+	}
+	_, err = delta.Apply(out, base, in)
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+	// Close the old file before renaming over it for Windows
+	_ = base.Close()
+	if err == nil {
+		o.clearHashCache()
+		err = os.Rename(tmpPath, o.path)
+	}
+	if err != nil {
+		if removeErr := os.Remove(tmpPath); removeErr != nil && !os.IsNotExist(removeErr) {
+			fs.Errorf(o, "Failed to remove partially written file: %v", removeErr)
+		}
+		return err
+	}
+
+	// All successful so update the hashes
+	if hasher != nil {
+		o.fs.objectMetaMu.Lock()
+		o.hashes = hasher.Sums()
+		o.fs.objectMetaMu.Unlock()
+	}
+
+	// Set the mtime
+	err = o.SetModTime(ctx, src.ModTime(ctx))
+	if err != nil {
+		return err
+	}
+
 	// ReRead info now that we have finished
 	return o.lstat()
 }
@@ -1358,5 +1526,9 @@ var (
 	_ fs.DirMover       = &Fs{}
 	_ fs.Commander      = &Fs{}
 	_ fs.OpenWriterAter = &Fs{}
+	_ fs.Shutdowner     = &Fs{}
 	_ fs.Object         = &Object{}
+	_ fs.Deltaer        = &Object{}
+	_ fs.Metadataer     = &Object{}
+	_ fs.SetMetadataer  = &Object{}
 )
@@ -7,6 +7,8 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"testing"
 	"time"
 
@@ -200,6 +202,45 @@ func TestHashOnUpdate(t *testing.T) {
 	assert.Equal(t, "45685e95985e20822fb2538a522a5ccf", md5)
 }
 
+// Test the hashes are cached with --local-hash-cache
+func TestHashCache(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	const filePath = "file.txt"
+	when := time.Now()
+	r.WriteFile(filePath, "content", when)
+	f, err := NewFs(ctx, "local", r.LocalName, configmap.Simple{"hash_cache": "true"})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, f.Features().Shutdown(ctx))
+	}()
+
+	getHash := func() string {
+		o, err := f.NewObject(ctx, filePath)
+		require.NoError(t, err)
+		md5, err := o.Hash(ctx, hash.MD5)
+		require.NoError(t, err)
+		return md5
+	}
+	assert.Equal(t, "9a0364b9e99bb480dd25e1f0284c8555", getHash())
+
+	// Change the contents in place keeping the size and modtime -
+	// the cached hash is used so is stale
+	localPath := filepath.Join(r.LocalName, filePath)
+	fd, err := os.OpenFile(localPath, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = fd.Write([]byte("CONTENT"))
+	require.NoError(t, err)
+	require.NoError(t, fd.Close())
+	require.NoError(t, os.Chtimes(localPath, when, when))
+	assert.Equal(t, "9a0364b9e99bb480dd25e1f0284c8555", getHash())
+
+	// Changing the modtime reads the file again
+	require.NoError(t, os.Chtimes(localPath, when, when.Add(time.Second)))
+	assert.Equal(t, "45685e95985e20822fb2538a522a5ccf", getHash())
+}
+
 // Test hashes on deleting an object
 func TestHashOnDelete(t *testing.T) {
 	ctx := context.Background()
@@ -229,3 +270,68 @@ func TestHashOnDelete(t *testing.T) {
 	_, err = o.Hash(ctx, hash.MD5)
 	require.Error(t, err)
 }
+
+func TestMetadata(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	const filePath = "file.txt"
+	when := time.Date(2022, 5, 10, 11, 3, 1, 0, time.UTC)
+	r.WriteFile(filePath, "content", when)
+	f := r.Flocal.(*Fs)
+	assert.True(t, f.Features().ReadMetadata)
+	assert.True(t, f.Features().WriteMetadata)
+
+	// Get the object
+	obj, err := f.NewObject(ctx, filePath)
+	require.NoError(t, err)
+	o := obj.(*Object)
+
+	metadata, err := o.Metadata(ctx)
+	require.NoError(t, err)
+	mtime, err := time.Parse(metadataTimeFormat, metadata["mtime"])
+	require.NoError(t, err)
+	assert.True(t, when.Equal(mtime))
+
+	// Reupload it setting some of the metadata
+	atime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	var b = bytes.NewBufferString("CONTENT")
+	src := object.NewStaticObjectInfo(filePath, when, int64(b.Len()), true, nil, f)
+	err = o.Update(ctx, b, src, fs.MetadataOption{
+		"mode":  "0100600",
+		"atime": atime.Format(metadataTimeFormat),
+	})
+	require.NoError(t, err)
+
+	fi, err := os.Stat(o.path)
+	require.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	}
+	assert.True(t, when.Equal(fi.ModTime()))
+
+	if runtime.GOOS == "linux" {
+		metadata, err = o.Metadata(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "0100600", metadata["mode"])
+		gotAtime, err := time.Parse(metadataTimeFormat, metadata["atime"])
+		require.NoError(t, err)
+		assert.True(t, atime.Equal(gotAtime))
+		assert.Equal(t, strconv.Itoa(os.Getuid()), metadata["uid"])
+	}
+
+	// Set the metadata on the existing object
+	when2 := time.Date(2023, 6, 7, 8, 9, 10, 0, time.UTC)
+	err = o.SetMetadata(ctx, fs.Metadata{
+		"mode":  "0100640",
+		"mtime": when2.Format(metadataTimeFormat),
+	})
+	require.NoError(t, err)
+	assert.True(t, when2.Equal(o.ModTime(ctx)))
+	assert.Equal(t, int64(len("CONTENT")), o.Size())
+	if runtime.GOOS != "windows" {
+		fi, err = os.Stat(o.path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+	}
+}
@@ -0,0 +1,136 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+const metadataTimeFormat = time.RFC3339Nano
+
+// system metadata keys which this backend uses
+var systemMetadataInfo = map[string]fs.MetadataHelp{
+	"mode": {
+		Help:    "File type and mode",
+		Type:    "octal, unix style",
+		Example: "0100664",
+	},
+	"uid": {
+		Help:    "User ID of owner",
+		Type:    "decimal number",
+		Example: "500",
+	},
+	"gid": {
+		Help:    "Group ID of owner",
+		Type:    "decimal number",
+		Example: "500",
+	},
+	"atime": {
+		Help:    "Time of last access",
+		Type:    "RFC 3339",
+		Example: "2006-01-02T15:04:05.999999999Z07:00",
+	},
+	"mtime": {
+		Help:    "Time of last modification",
+		Type:    "RFC 3339",
+		Example: "2006-01-02T15:04:05.999999999Z07:00",
+	},
+}
+
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (metadata fs.Metadata, err error) {
+	metadata, err = o.readMetadataFromFile()
+	if err != nil {
+		return nil, err
+	}
+	metadata.Set("mtime", o.ModTime(ctx).Format(metadataTimeFormat))
+	return metadata, nil
+}
+
+// SetMetadata sets metadata on an existing object
+//
+// Only the keys in metadata are changed.
+func (o *Object) SetMetadata(ctx context.Context, metadata fs.Metadata) error {
+	if v, ok := metadata["mtime"]; ok {
+		mtime, err := time.Parse(metadataTimeFormat, v)
+		if err != nil {
+			fs.Errorf(o, "Ignoring bad mtime %q in metadata: %v", v, err)
+		} else {
+			err = o.SetModTime(ctx, mtime)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err := o.writeMetadata(ctx, metadata)
+	if err != nil {
+		return err
+	}
+	return o.lstat()
+}
+
+// writeMetadata sets the system metadata in metadata on the file.
+//
+// The mtime is set separately by SetModTime so this should be called
+// afterwards. Changing the owner usually needs root so failing to do
+// that isn't an error.
+func (o *Object) writeMetadata(ctx context.Context, metadata fs.Metadata) (err error) {
+	if v, ok := metadata["mode"]; ok && !o.translatedLink {
+		mode, err := strconv.ParseUint(v, 8, 32)
+		if err != nil {
+			fs.Errorf(o, "Ignoring bad mode %q in metadata: %v", v, err)
+		} else {
+			err = os.Chmod(o.path, os.FileMode(mode)&os.ModePerm)
+			if err != nil {
+				return fmt.Errorf("failed to set permissions from metadata: %w", err)
+			}
+		}
+	}
+	uid, gid := -1, -1
+	if v, ok := metadata["uid"]; ok {
+		uid = parseMetadataID(o, "uid", v)
+	}
+	if v, ok := metadata["gid"]; ok {
+		gid = parseMetadataID(o, "gid", v)
+	}
+	if uid >= 0 || gid >= 0 {
+		err = os.Lchown(o.path, uid, gid)
+		if err != nil {
+			fs.Debugf(o, "Failed to set owner from metadata: %v", err)
+		}
+	}
+	if v, ok := metadata["atime"]; ok && !o.fs.opt.NoSetModTime {
+		atime, err := time.Parse(metadataTimeFormat, v)
+		if err != nil {
+			fs.Errorf(o, "Ignoring bad atime %q in metadata: %v", v, err)
+		} else {
+			mtime := o.ModTime(ctx)
+			if o.translatedLink {
+				err = lChtimes(o.path, atime, mtime)
+			} else {
+				err = os.Chtimes(o.path, atime, mtime)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to set atime from metadata: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseMetadataID parses the uid or gid in v returning -1 if it is
+// invalid
+func parseMetadataID(o *Object, key, v string) int {
+	id, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		fs.Errorf(o, "Ignoring bad %s %q in metadata: %v", key, v, err)
+		return -1
+	}
+	return int(id)
+}
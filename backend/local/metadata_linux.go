@@ -0,0 +1,33 @@
+//go:build linux
+// +build linux
+
+package local
+
+import (
+	"fmt"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// Read the metadata from the file into metadata where possible
+func (o *Object) readMetadataFromFile() (metadata fs.Metadata, err error) {
+	info, err := o.fs.lstat(o.path)
+	if err != nil {
+		return nil, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		fs.Debugf(o, "didn't return Stat_t as expected")
+		return nil, nil
+	}
+	metadata = fs.Metadata{
+		"mode":  fmt.Sprintf("0%o", stat.Mode),
+		"uid":   strconv.FormatUint(uint64(stat.Uid), 10),
+		"gid":   strconv.FormatUint(uint64(stat.Gid), 10),
+		"atime": time.Unix(stat.Atim.Unix()).Format(metadataTimeFormat),
+	}
+	return metadata, nil
+}
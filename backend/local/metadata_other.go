@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package local
+
+import (
+	"github.com/rclone/rclone/fs"
+)
+
+// Read the metadata from the file into metadata where possible
+//
+// Only the mtime is supported on this OS so there is nothing to read
+func (o *Object) readMetadataFromFile() (metadata fs.Metadata, err error) {
+	return nil, nil
+}
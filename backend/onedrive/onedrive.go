@@ -858,6 +858,7 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 		ReadMimeType:            true,
 		CanHaveEmptyDirectories: true,
 		ServerSideAcrossConfigs: opt.ServerSideAcrossConfigs,
+		Trash:                   true,
 	}).Fill(ctx, f)
 	f.srv.SetErrorHandler(errorHandler)
 
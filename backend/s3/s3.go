@@ -8,6 +8,7 @@ import (
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -61,6 +62,10 @@ func init() {
 		Description: "Amazon S3 Compliant Storage Providers including AWS, Alibaba, Ceph, China Mobile, Digital Ocean, Dreamhost, IBM COS, Lyve Cloud, Minio, Netease, RackCorp, Scaleway, SeaweedFS, StackPath, Storj, Tencent COS and Wasabi",
 		NewFs:       NewFs,
 		CommandHelp: commandHelp,
+		MetadataInfo: &fs.MetadataInfo{
+			System: systemMetadataInfo,
+			Help:   `User metadata is stored as x-amz-meta- keys. S3 metadata keys are case insensitive and are always returned in lower case.`,
+		},
 		Options: []fs.Option{{
 			Name: fs.ConfigProvider,
 			Help: "Choose your S3 provider.",
@@ -1788,6 +1793,26 @@ This should be true, false or left unset to use the default for the provider.
 		}})
 }
 
+// system metadata keys which this backend uses
+var systemMetadataInfo = map[string]fs.MetadataHelp{
+	"content-type": {
+		Help:    "A standard MIME type describing the format of the object data.",
+		Type:    "string",
+		Example: "text/plain",
+	},
+	"mtime": {
+		Help:    "Time of last modification, read from rclone metadata.",
+		Type:    "RFC 3339",
+		Example: "2006-01-02T15:04:05.999999999Z07:00",
+	},
+	"tier": {
+		Help:     "Tier of the object.",
+		Type:     "string",
+		Example:  "GLACIER",
+		ReadOnly: true,
+	},
+}
+
 // Constants
 const (
 	metaMtime   = "Mtime"     // the meta key to store mtime in - e.g. X-Amz-Meta-Mtime
@@ -2335,6 +2360,9 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 		SetTier:           true,
 		GetTier:           true,
 		SlowModTime:       true,
+		ReadMetadata:      true,
+		WriteMetadata:     true,
+		UserMetadata:      true,
 	}).Fill(ctx, f)
 	if f.rootBucket != "" && f.rootDirectory != "" && !opt.NoHeadObject && !strings.HasSuffix(root, "/") {
 		// Check to see if the (bucket,directory) is actually an existing file
@@ -3057,6 +3085,24 @@ func (f *Fs) Copy(ctx context.Context, src fs.Object, remote string) (fs.Object,
 	return f.NewObject(ctx, remote)
 }
 
+// AccountID returns an identifier for the credentials and endpoint
+// in use so that remotes sharing them can server-side copy between
+// each other.
+//
+// It returns "" for anonymous access as the credentials aren't known.
+func (f *Fs) AccountID() string {
+	var credentials string
+	switch {
+	case f.opt.AccessKeyID != "":
+		credentials = "key:" + f.opt.AccessKeyID
+	case f.opt.EnvAuth:
+		credentials = "env:" + f.opt.SharedCredentialsFile + ":" + f.opt.Profile
+	default:
+		return ""
+	}
+	return strings.Join([]string{f.opt.Provider, f.opt.Region, f.opt.Endpoint, credentials}, "\x00")
+}
+
 // Hashes returns the supported hash sets.
 func (f *Fs) Hashes() hash.Set {
 	return hash.Set(hash.MD5)
@@ -3576,12 +3622,16 @@ func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
 	if o.storageClass == "GLACIER" || o.storageClass == "DEEP_ARCHIVE" {
 		return fs.ErrorCantSetModTime
 	}
+	return o.replaceMetadata(ctx, o.meta, fs.MimeType(ctx, o)) // Guess the content type
+}
 
-	// Copy the object to itself to update the metadata
+// replaceMetadata copies the object to itself to replace its metadata
+// with meta
+func (o *Object) replaceMetadata(ctx context.Context, meta map[string]*string, contentType string) error {
 	bucket, bucketPath := o.split()
 	req := s3.CopyObjectInput{
-		ContentType:       aws.String(fs.MimeType(ctx, o)), // Guess the content type
-		Metadata:          o.meta,
+		ContentType:       aws.String(contentType),
+		Metadata:          meta,
 		MetadataDirective: aws.String(s3.MetadataDirectiveReplace), // replace metadata with that passed in
 	}
 	if o.fs.opt.RequesterPays {
@@ -3590,6 +3640,51 @@ func (o *Object) SetModTime(ctx context.Context, modTime time.Time) error {
 	return o.fs.copy(ctx, &req, bucket, bucketPath, bucket, bucketPath, o)
 }
 
+// SetMetadata sets metadata on an existing object
+//
+// Only the keys in metadata are changed. The object is copied to
+// itself to do this, the same as SetModTime.
+func (o *Object) SetMetadata(ctx context.Context, metadata fs.Metadata) error {
+	err := o.readMetaData(ctx)
+	if err != nil {
+		return err
+	}
+	if o.storageClass == "GLACIER" || o.storageClass == "DEEP_ARCHIVE" {
+		return fs.ErrorNotImplemented
+	}
+	contentType := o.mimeType
+	if contentType == "" {
+		contentType = fs.MimeType(ctx, o)
+	}
+	req := s3.PutObjectInput{Metadata: map[string]*string{}}
+	o.applyMetadata(&req, metadata)
+	if req.ContentType != nil {
+		contentType = *req.ContentType
+	}
+
+	// Make the new metadata replacing the old keys, which may not
+	// be in the same case, with the new ones
+	meta := make(map[string]*string, len(o.meta)+len(req.Metadata))
+	for k, v := range o.meta {
+		meta[k] = v
+	}
+	for k, v := range req.Metadata {
+		for oldK := range meta {
+			if strings.EqualFold(oldK, k) {
+				delete(meta, oldK)
+			}
+		}
+		meta[k] = v
+	}
+	err = o.replaceMetadata(ctx, meta, contentType)
+	if err != nil {
+		return err
+	}
+	o.meta = meta
+	o.mimeType = contentType
+	return nil
+}
+
 // Storable raturns a boolean indicating if this object is storable
 func (o *Object) Storable() bool {
 	return true
@@ -3928,17 +4023,261 @@ func (o *Object) uploadMultipart(ctx context.Context, req *s3.PutObjectInput, si
 	return etag, nil
 }
 
-// Update the Object from in with modTime and size
-func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
-	bucket, bucketPath := o.split()
-	err := o.fs.makeBucket(ctx, bucket)
+// s3ChunkWriter uploads the chunks written to it as the parts of a
+// multipart upload
+type s3ChunkWriter struct {
+	f         *Fs
+	o         *Object
+	req       *s3.PutObjectInput
+	uploadID  *string
+	chunkSize int64
+	partsMu   sync.Mutex // to protect parts
+	parts     []*s3.CompletedPart
+}
+
+// OpenChunkWriter returns the chunk size and a ChunkWriter
+//
+// Pass in the remote and the src object being uploaded. All the
+// chunks written must be chunkSize bytes except the last.
+func (f *Fs) OpenChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (chunkSize int64, writer fs.ChunkWriter, err error) {
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	bucket, _ := o.split()
+	err = f.makeBucket(ctx, bucket)
 	if err != nil {
-		return err
+		return -1, nil, err
 	}
-	modTime := src.ModTime(ctx)
+	req, _ := o.prepareUpload(ctx, src, options, true)
+
+	uploadParts := f.opt.MaxUploadParts
+	if uploadParts < 1 {
+		uploadParts = 1
+	} else if uploadParts > maxUploadParts {
+		uploadParts = maxUploadParts
+	}
+	// Adjust the chunk size until the number of parts is small enough
+	chunkSize = int64(f.opt.ChunkSize)
+	if size := src.Size(); size > 0 && size/chunkSize >= uploadParts {
+		// Calculate partition size rounded up to the nearest MiB
+		chunkSize = (((size / uploadParts) >> 20) + 1) << 20
+	}
+
+	var mReq s3.CreateMultipartUploadInput
+	structs.SetFrom(&mReq, req)
+	var cout *s3.CreateMultipartUploadOutput
+	err = f.pacer.Call(func() (bool, error) {
+		var err error
+		cout, err = f.c.CreateMultipartUploadWithContext(ctx, &mReq)
+		return f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return -1, nil, fmt.Errorf("multipart upload failed to initialise: %w", err)
+	}
+	return chunkSize, &s3ChunkWriter{
+		f:         f,
+		o:         o,
+		req:       req,
+		uploadID:  cout.UploadId,
+		chunkSize: chunkSize,
+	}, nil
+}
+
+// s3ResumeID is the ResumeID of an s3ChunkWriter
+type s3ResumeID struct {
+	UploadID  string `json:"uploadId"`
+	ChunkSize int64  `json:"chunkSize"`
+}
+
+// ResumeID returns the ID to resume the multipart upload with
+func (w *s3ChunkWriter) ResumeID() string {
+	buf, _ := json.Marshal(s3ResumeID{
+		UploadID:  aws.StringValue(w.uploadID),
+		ChunkSize: w.chunkSize,
+	})
+	return string(buf)
+}
+
+// ResumeChunkWriter reopens the multipart upload started by
+// OpenChunkWriter with the ResumeID given
+//
+// It returns the chunk size, a ChunkWriter and the chunk numbers of
+// the parts which have already been uploaded.
+func (f *Fs) ResumeChunkWriter(ctx context.Context, remote string, src fs.ObjectInfo, resumeID string, options ...fs.OpenOption) (chunkSize int64, writer fs.ChunkWriter, written []int, err error) {
+	var id s3ResumeID
+	err = json.Unmarshal([]byte(resumeID), &id)
+	if err != nil || id.UploadID == "" || id.ChunkSize <= 0 {
+		return -1, nil, nil, fmt.Errorf("invalid resume ID %q", resumeID)
+	}
+	o := &Object{
+		fs:     f,
+		remote: remote,
+	}
+	req, _ := o.prepareUpload(ctx, src, options, true)
+	w := &s3ChunkWriter{
+		f:         f,
+		o:         o,
+		req:       req,
+		uploadID:  aws.String(id.UploadID),
+		chunkSize: id.ChunkSize,
+	}
+
+	// Find the parts which have been uploaded
 	size := src.Size()
+	var marker *int64
+	for {
+		var resp *s3.ListPartsOutput
+		err = f.pacer.Call(func() (bool, error) {
+			var err error
+			resp, err = f.c.ListPartsWithContext(ctx, &s3.ListPartsInput{
+				Bucket:               req.Bucket,
+				Key:                  req.Key,
+				UploadId:             w.uploadID,
+				PartNumberMarker:     marker,
+				RequestPayer:         req.RequestPayer,
+				SSECustomerAlgorithm: req.SSECustomerAlgorithm,
+				SSECustomerKey:       req.SSECustomerKey,
+				SSECustomerKeyMD5:    req.SSECustomerKeyMD5,
+			})
+			return f.shouldRetry(ctx, err)
+		})
+		if err != nil {
+			return -1, nil, nil, fmt.Errorf("failed to list parts of multipart upload: %w", err)
+		}
+		for _, part := range resp.Parts {
+			if part.PartNumber == nil || part.ETag == nil || part.Size == nil {
+				continue
+			}
+			chunk := int(*part.PartNumber - 1)
+			// Only use parts which are complete
+			wantSize := size - int64(chunk)*id.ChunkSize
+			if wantSize > id.ChunkSize {
+				wantSize = id.ChunkSize
+			}
+			if wantSize <= 0 || *part.Size != wantSize {
+				continue
+			}
+			w.parts = append(w.parts, &s3.CompletedPart{
+				PartNumber: part.PartNumber,
+				ETag:       part.ETag,
+			})
+			written = append(written, chunk)
+		}
+		if !aws.BoolValue(resp.IsTruncated) || resp.NextPartNumberMarker == nil {
+			break
+		}
+		marker = resp.NextPartNumberMarker
+	}
+	return id.ChunkSize, w, written, nil
+}
 
-	multipart := size < 0 || size >= int64(o.fs.opt.UploadCutoff)
+// WriteChunk uploads chunk number chunkNumber read from reader as a
+// part of the multipart upload
+func (w *s3ChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (bytesWritten int64, err error) {
+	if chunkNumber < 0 {
+		return -1, fmt.Errorf("invalid chunk number %d", chunkNumber)
+	}
+	// part numbers start at 1
+	partNum := int64(chunkNumber) + 1
+
+	// create checksum of the chunk for integrity checking
+	md5hash := md5.New()
+	bytesWritten, err = io.Copy(md5hash, reader)
+	if err != nil {
+		return -1, fmt.Errorf("multipart upload failed to read chunk: %w", err)
+	}
+	md5sum := base64.StdEncoding.EncodeToString(md5hash.Sum(nil))
+
+	var uout *s3.UploadPartOutput
+	err = w.f.pacer.Call(func() (bool, error) {
+		// rewind the reader for each try
+		if _, err := reader.Seek(0, io.SeekStart); err != nil {
+			return false, err
+		}
+		var err error
+		uout, err = w.f.c.UploadPartWithContext(ctx, &s3.UploadPartInput{
+			Body:                 reader,
+			Bucket:               w.req.Bucket,
+			Key:                  w.req.Key,
+			PartNumber:           &partNum,
+			UploadId:             w.uploadID,
+			ContentMD5:           &md5sum,
+			ContentLength:        &bytesWritten,
+			RequestPayer:         w.req.RequestPayer,
+			SSECustomerAlgorithm: w.req.SSECustomerAlgorithm,
+			SSECustomerKey:       w.req.SSECustomerKey,
+			SSECustomerKeyMD5:    w.req.SSECustomerKeyMD5,
+		})
+		return w.f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return -1, fmt.Errorf("multipart upload failed to upload part: %w", err)
+	}
+	w.partsMu.Lock()
+	w.parts = append(w.parts, &s3.CompletedPart{
+		PartNumber: &partNum,
+		ETag:       uout.ETag,
+	})
+	w.partsMu.Unlock()
+	fs.Debugf(w.o, "multipart upload wrote chunk %d with %v bytes", partNum, bytesWritten)
+	return bytesWritten, nil
+}
+
+// Abort the multipart upload
+func (w *s3ChunkWriter) Abort(ctx context.Context) error {
+	if w.f.opt.LeavePartsOnError {
+		return nil
+	}
+	fs.Debugf(w.o, "Cancelling multipart upload")
+	err := w.f.pacer.Call(func() (bool, error) {
+		_, err := w.f.c.AbortMultipartUploadWithContext(context.Background(), &s3.AbortMultipartUploadInput{
+			Bucket:       w.req.Bucket,
+			Key:          w.req.Key,
+			UploadId:     w.uploadID,
+			RequestPayer: w.req.RequestPayer,
+		})
+		return w.f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to cancel multipart upload: %w", err)
+	}
+	return nil
+}
+
+// Close finishes the multipart upload
+func (w *s3ChunkWriter) Close(ctx context.Context) error {
+	w.partsMu.Lock()
+	defer w.partsMu.Unlock()
+	// sort the completed parts by part number
+	sort.Slice(w.parts, func(i, j int) bool {
+		return *w.parts[i].PartNumber < *w.parts[j].PartNumber
+	})
+	err := w.f.pacer.Call(func() (bool, error) {
+		_, err := w.f.c.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket: w.req.Bucket,
+			Key:    w.req.Key,
+			MultipartUpload: &s3.CompletedMultipartUpload{
+				Parts: w.parts,
+			},
+			RequestPayer: w.req.RequestPayer,
+			UploadId:     w.uploadID,
+		})
+		return w.f.shouldRetry(ctx, err)
+	})
+	if err != nil {
+		return fmt.Errorf("multipart upload failed to finalise: %w", err)
+	}
+	return nil
+}
+
+// prepareUpload makes the PutObjectInput for uploading src to o and
+// reads the MD5 of src if it is needed
+//
+// multipart should be set if this is to be a multipart upload.
+func (o *Object) prepareUpload(ctx context.Context, src fs.ObjectInfo, options []fs.OpenOption, multipart bool) (req *s3.PutObjectInput, md5sumHex string) {
+	bucket, bucketPath := o.split()
+	modTime := src.ModTime(ctx)
 
 	// Set the mtime in the meta data
 	metadata := map[string]*string{
@@ -3952,8 +4291,8 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	// - for multipart provided checksums aren't disabled
 	//    - so we can add the md5sum in the metadata as metaMD5Hash
 	var md5sumBase64 string
-	var md5sumHex string
 	if !multipart || !o.fs.opt.DisableChecksum {
+		var err error
 		md5sumHex, err = src.Hash(ctx, hash.MD5)
 		if err == nil && matchMd5.MatchString(md5sumHex) {
 			hashBytes, err := hex.DecodeString(md5sumHex)
@@ -3972,7 +4311,7 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 
 	// Guess the content type
 	mimeType := fs.MimeType(ctx, src)
-	req := s3.PutObjectInput{
+	req = &s3.PutObjectInput{
 		Bucket:      &bucket,
 		ACL:         &o.fs.opt.ACL,
 		Key:         &bucketPath,
@@ -4003,6 +4342,10 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 	if o.fs.opt.StorageClass != "" {
 		req.StorageClass = &o.fs.opt.StorageClass
 	}
+	// Set the metadata if passed in
+	if meta := fs.GetMetadataOptions(options); meta != nil {
+		o.applyMetadata(req, meta)
+	}
 	// Apply upload options
 	for _, option := range options {
 		key, value := option.Header()
@@ -4033,17 +4376,59 @@ func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, op
 		}
 	}
 
+	return req, md5sumHex
+}
+
+// applyMetadata sets the metadata passed in with --metadata on req
+//
+// The system metadata is mapped onto the request and everything else
+// is stored as user metadata.
+func (o *Object) applyMetadata(req *s3.PutObjectInput, meta fs.Metadata) {
+	for k, v := range meta {
+		switch k {
+		case "content-type":
+			req.ContentType = aws.String(v)
+		case "mtime":
+			modTime, err := time.Parse(time.RFC3339Nano, v)
+			if err != nil {
+				fs.Errorf(o, "Ignoring bad mtime %q in metadata: %v", v, err)
+			} else {
+				req.Metadata[metaMtime] = aws.String(swift.TimeToFloatString(modTime))
+			}
+		case "tier":
+			// read only so ignore
+		default:
+			if strings.EqualFold(k, metaMD5Hash) {
+				fs.Debugf(o, "Ignoring reserved key %q in metadata", k)
+				continue
+			}
+			req.Metadata[k] = aws.String(v)
+		}
+	}
+}
+
+// Update the Object from in with modTime and size
+func (o *Object) Update(ctx context.Context, in io.Reader, src fs.ObjectInfo, options ...fs.OpenOption) error {
+	bucket, bucketPath := o.split()
+	err := o.fs.makeBucket(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	size := src.Size()
+	multipart := size < 0 || size >= int64(o.fs.opt.UploadCutoff)
+	req, md5sumHex := o.prepareUpload(ctx, src, options, multipart)
+
 	var resp *http.Response // response from PUT
 	var wantETag string     // Multipart upload Etag to check
 	if multipart {
-		wantETag, err = o.uploadMultipart(ctx, &req, size, in)
+		wantETag, err = o.uploadMultipart(ctx, req, size, in)
 		if err != nil {
 			return err
 		}
 	} else {
 
 		// Create the request
-		putObj, _ := o.fs.c.PutObjectRequest(&req)
+		putObj, _ := o.fs.c.PutObjectRequest(req)
 
 		// Sign it so we can upload using a presigned request.
 		//
@@ -4158,6 +4543,29 @@ func (o *Object) MimeType(ctx context.Context) string {
 	return o.mimeType
 }
 
+// Metadata returns metadata for an object
+//
+// It should return nil if there is no Metadata
+func (o *Object) Metadata(ctx context.Context) (metadata fs.Metadata, err error) {
+	err = o.readMetaData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	metadata = make(fs.Metadata, len(o.meta)+3)
+	for k, v := range o.meta {
+		if strings.EqualFold(k, metaMtime) || strings.EqualFold(k, metaMD5Hash) {
+			continue
+		}
+		metadata[strings.ToLower(k)] = aws.StringValue(v)
+	}
+	metadata["mtime"] = o.ModTime(ctx).Format(time.RFC3339Nano)
+	if o.mimeType != "" {
+		metadata["content-type"] = o.mimeType
+	}
+	metadata["tier"] = o.GetTier()
+	return metadata, nil
+}
+
 // SetTier performs changing storage class
 func (o *Object) SetTier(tier string) (err error) {
 	ctx := context.TODO()
@@ -4185,14 +4593,20 @@ func (o *Object) GetTier() string {
 
 // Check the interfaces are satisfied
 var (
-	_ fs.Fs          = &Fs{}
-	_ fs.Copier      = &Fs{}
-	_ fs.PutStreamer = &Fs{}
-	_ fs.ListRer     = &Fs{}
-	_ fs.Commander   = &Fs{}
-	_ fs.CleanUpper  = &Fs{}
-	_ fs.Object      = &Object{}
-	_ fs.MimeTyper   = &Object{}
-	_ fs.GetTierer   = &Object{}
-	_ fs.SetTierer   = &Object{}
+	_ fs.Fs                   = &Fs{}
+	_ fs.Copier               = &Fs{}
+	_ fs.PutStreamer          = &Fs{}
+	_ fs.ListRer              = &Fs{}
+	_ fs.Commander            = &Fs{}
+	_ fs.CleanUpper           = &Fs{}
+	_ fs.AccountIDer          = &Fs{}
+	_ fs.OpenChunkWriter      = &Fs{}
+	_ fs.ChunkWriterResumer   = &Fs{}
+	_ fs.Object               = &Object{}
+	_ fs.MimeTyper            = &Object{}
+	_ fs.GetTierer            = &Object{}
+	_ fs.SetTierer            = &Object{}
+	_ fs.Metadataer           = &Object{}
+	_ fs.SetMetadataer        = &Object{}
+	_ fs.ResumableChunkWriter = &s3ChunkWriter{}
 )
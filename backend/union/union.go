@@ -877,6 +877,7 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 		BucketBased:             true,
 		SetTier:                 true,
 		GetTier:                 true,
+		Trash:                   true,
 	}).Fill(ctx, f)
 	canMove := true
 	for _, f := range upstreams {
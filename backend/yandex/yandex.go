@@ -286,6 +286,7 @@ func NewFs(ctx context.Context, name, root string, m configmap.Mapper) (fs.Fs, e
 		ReadMimeType:            true,
 		WriteMimeType:           false, // Yandex ignores the mime type we send
 		CanHaveEmptyDirectories: true,
+		Trash:                   !opt.HardDelete,
 	}).Fill(ctx, f)
 	f.srv.SetErrorHandler(errorHandler)
 
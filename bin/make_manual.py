@@ -36,6 +36,7 @@ docs = [
     "cache.md",
     "chunker.md",
     "sharefile.md",
+    "combine.md",
     "crypt.md",
     "compress.md",
     "dropbox.md",
@@ -0,0 +1,161 @@
+// Package apply provides the apply command.
+package apply
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/rc/jobs"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	// Register the rc calls the jobs run
+	_ "github.com/rclone/rclone/fs/sync"
+)
+
+var (
+	checkOnly = false
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.BoolVarP(cmdFlags, &checkOnly, "check", "", checkOnly, "Only check the job spec is valid, don't run any jobs")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "apply jobs.yaml",
+	Short: `Run the sync jobs described in a job spec file.`,
+	// Note: "|" will be replaced by backticks below
+	Long: strings.ReplaceAll(`
+Read a list of jobs from a YAML or JSON job spec file, or standard
+input if it is |-|, check them and run them. This lets a pipeline of
+syncs between several remotes be kept in a file under version control
+rather than in a shell script.
+
+    jobs:
+      - name: photos
+        command: sync
+        source: /home/user/photos
+        destination: remote:photos
+        filter:
+          ExcludeRule: ["*.tmp", ".cache/**"]
+        config:
+          Transfers: 8
+      - name: offsite
+        command: copy
+        source: remote:photos
+        destination: offsite:photos
+        params:
+          createEmptySrcDirs: true
+        schedule: "0 3 * * *"
+
+Each job has
+
+- |name| - a unique name for the job
+- |command| - one of |sync|, |copy| or |move|
+- |source| - the remote to read from
+- |destination| - the remote to write to
+- |filter| - filter options as for the |_filter| parameter of rc calls (optional)
+- |config| - config options as for the |_config| parameter of rc calls (optional)
+- |params| - extra parameters for the rc call, e.g. |createEmptySrcDirs| (optional)
+- |schedule| - a cron expression for when to run the job (optional)
+
+The |filter| and |config| options use the names shown by |rclone rc
+options/get|, so |--max-age 1d| is |MaxAge: 1d| under |filter| and
+|--transfers 8| is |Transfers: 8| under |config|. These override the
+flags given on the command line for that job only.
+
+The jobs without a |schedule| are run one after the other in the
+order they are in the file. If one fails the ones after it aren't run.
+
+If there are any jobs with a |schedule| then rclone keeps running after
+that and runs each of them whenever its cron expression matches until
+it is stopped. The cron expression has the five fields "minute hour
+day-of-month month day-of-week", or can be one of |@hourly|,
+|@daily|, |@weekly|, |@monthly| or |@yearly|.
+
+Use |--check| to check the job spec file without running anything.
+The whole file is checked before any jobs are run.
+
+The same job spec can be run on a remote control server with the
+|job/apply| rc call.
+`, "|", "`"),
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		spec, err := readSpec(args[0])
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if checkOnly {
+			fmt.Printf("%d jobs OK\n", len(spec.Jobs))
+			return
+		}
+		scheduled := 0
+		cmd.Run(false, true, command, func() error {
+			results, err := jobs.Apply(context.Background(), spec)
+			for _, result := range results {
+				if result["status"] == "scheduled" {
+					scheduled++
+				}
+				if errMsg, ok := result["error"]; ok {
+					fmt.Printf("%s: %s: %s\n", result["name"], result["status"], errMsg)
+				} else {
+					fmt.Printf("%s: %s\n", result["name"], result["status"])
+				}
+			}
+			return err
+		})
+		if scheduled > 0 {
+			fs.Logf(nil, "Waiting to run %d scheduled jobs - interrupt to stop", scheduled)
+			waitForSignal()
+		}
+	},
+}
+
+// readSpec reads and validates the job spec at path
+func readSpec(path string) (spec *jobs.Spec, err error) {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		var fd *os.File
+		fd, err = os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open job spec: %w", err)
+		}
+		defer fs.CheckClose(fd, &err)
+		in = fd
+	}
+	buf, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read job spec: %w", err)
+	}
+	// YAML is a superset of JSON so this reads both
+	params := rc.Params{}
+	err = yaml.Unmarshal(buf, &params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse job spec: %w", err)
+	}
+	spec, err = jobs.ParseSpec(params)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// waitForSignal waits until rclone is interrupted
+func waitForSignal() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	<-sigs
+}
@@ -0,0 +1,422 @@
+// Package archive provides the archive command.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+	"github.com/spf13/cobra"
+)
+
+var (
+	extract = false
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.BoolVarP(cmdFlags, &extract, "extract", "x", extract, "Extract the archive source:path/file into dest:path")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "archive source:path dest:path/file",
+	Short: `Create an archive of a remote, or extract one into a remote.`,
+	// Note: "|" will be replaced by backticks below
+	Long: strings.ReplaceAll(`
+Stream the files in source:path into an archive file written to
+dest:path/file, without storing them locally first. The type of the
+archive is chosen from the extension of the file name and can be one
+of
+
+- |.tar|
+- |.tar.gz| or |.tgz|
+- |.zip|
+
+For example to export a snapshot of a bucket to a local archive
+
+    rclone archive remote:bucket /tmp/bucket.tar.gz
+
+Or to make the archive on another remote
+
+    rclone archive remote:bucket backup:archives/bucket.zip
+
+Use the |--extract|/|-x| flag to do the reverse and unpack the archive
+source:path/file into the directory dest:path, for example
+
+    rclone archive -x /tmp/bucket.tar.gz remote:bucket
+
+The archive is streamed, so it can't be retried if the transfer fails
+part way through. Zip archives are read from the source with range
+requests so the source must support them when extracting.
+
+Archives only contain files and directories, not the metadata of the
+objects other than their modification times. Entries with names which
+would extract outside the destination are skipped.
+
+**Note**: Use the |-P|/|--progress| flag to view real-time transfer statistics.
+`, "|", "`"),
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		if extract {
+			fsrc, srcFileName := cmd.NewFsFile(args[0])
+			fdst := cmd.NewFsDir(args[1:])
+			cmd.Run(false, true, command, func() error {
+				if srcFileName == "" {
+					return errors.New("can't extract - source must be an archive file")
+				}
+				return Extract(context.Background(), fdst, fsrc, srcFileName)
+			})
+			return
+		}
+		fsrc := cmd.NewFsSrc(args)
+		fdst, dstFileName := cmd.NewFsDstFile(args[1:])
+		cmd.Run(false, true, command, func() error {
+			return Create(context.Background(), fdst, dstFileName, fsrc)
+		})
+	},
+}
+
+// Format is the type of an archive
+type Format byte
+
+// Archive formats
+const (
+	FormatTar Format = iota
+	FormatTarGz
+	FormatZip
+)
+
+// FormatFromName returns the archive Format to use for the file name
+func FormatFromName(name string) (Format, error) {
+	lowerName := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lowerName, ".tar"):
+		return FormatTar, nil
+	case strings.HasSuffix(lowerName, ".tar.gz"), strings.HasSuffix(lowerName, ".tgz"):
+		return FormatTarGz, nil
+	case strings.HasSuffix(lowerName, ".zip"):
+		return FormatZip, nil
+	}
+	return 0, fmt.Errorf("unknown archive type for %q: expecting .tar, .tar.gz, .tgz or .zip", name)
+}
+
+// Create streams the contents of fsrc into an archive written to
+// dstFileName on fdst
+func Create(ctx context.Context, fdst fs.Fs, dstFileName string, fsrc fs.Fs) error {
+	format, err := FormatFromName(dstFileName)
+	if err != nil {
+		return err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(writeArchive(ctx, pw, format, fsrc))
+	}()
+	_, err = operations.Rcat(ctx, fdst, dstFileName, pr, time.Now())
+	_ = pr.CloseWithError(err)
+	if err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// archiveWriter adds entries to an archive
+type archiveWriter interface {
+	// addDir adds the directory dir
+	addDir(dir fs.Directory) error
+	// addFile adds the contents of in as o
+	addFile(o fs.Object, in io.Reader) error
+	// Close finishes the archive
+	Close() error
+}
+
+// writeArchive writes the contents of fsrc as an archive in format to out
+func writeArchive(ctx context.Context, out io.Writer, format Format, fsrc fs.Fs) (err error) {
+	var w archiveWriter
+	switch format {
+	case FormatTar:
+		w = &tarWriter{tw: tar.NewWriter(out)}
+	case FormatTarGz:
+		gz := gzip.NewWriter(out)
+		w = &tarWriter{tw: tar.NewWriter(gz), gz: gz}
+	case FormatZip:
+		w = &zipWriter{zw: zip.NewWriter(out)}
+	}
+	ci := fs.GetConfig(ctx)
+	err = walk.Walk(ctx, fsrc, "", false, ci.MaxDepth, func(dirPath string, entries fs.DirEntries, err error) error {
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			switch x := entry.(type) {
+			case fs.Directory:
+				err = w.addDir(x)
+			case fs.Object:
+				err = addObject(ctx, w, x)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// addObject adds the contents of o to w
+func addObject(ctx context.Context, w archiveWriter, o fs.Object) (err error) {
+	if o.Size() < 0 {
+		return fmt.Errorf("can't archive %q as its size is unknown", o.Remote())
+	}
+	in, err := operations.NewReOpen(ctx, o, fs.GetConfig(ctx).LowLevelRetries)
+	if err != nil {
+		return fmt.Errorf("failed to open %q: %w", o.Remote(), err)
+	}
+	defer fs.CheckClose(in, &err)
+	err = w.addFile(o, in)
+	if err != nil {
+		return fmt.Errorf("failed to archive %q: %w", o.Remote(), err)
+	}
+	fs.Debugf(o, "Added to archive")
+	return nil
+}
+
+// tarWriter writes tar archives, optionally gzipped
+type tarWriter struct {
+	tw *tar.Writer
+	gz *gzip.Writer // may be nil
+}
+
+func (w *tarWriter) addDir(dir fs.Directory) error {
+	return w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     dir.Remote() + "/",
+		Mode:     0755,
+		ModTime:  dir.ModTime(context.Background()),
+	})
+}
+
+func (w *tarWriter) addFile(o fs.Object, in io.Reader) error {
+	err := w.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     o.Remote(),
+		Mode:     0644,
+		Size:     o.Size(),
+		ModTime:  o.ModTime(context.Background()),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w.tw, in)
+	return err
+}
+
+func (w *tarWriter) Close() error {
+	err := w.tw.Close()
+	if err != nil || w.gz == nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+// zipWriter writes zip archives
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipWriter) addDir(dir fs.Directory) error {
+	_, err := w.zw.CreateHeader(&zip.FileHeader{
+		Name:     dir.Remote() + "/",
+		Modified: dir.ModTime(context.Background()),
+	})
+	return err
+}
+
+func (w *zipWriter) addFile(o fs.Object, in io.Reader) error {
+	out, err := w.zw.CreateHeader(&zip.FileHeader{
+		Name:     o.Remote(),
+		Method:   zip.Deflate,
+		Modified: o.ModTime(context.Background()),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	return err
+}
+
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}
+
+// cleanName returns the path to extract the archive entry name to or
+// "" if it should be skipped because it would be outside the
+// destination
+func cleanName(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return ""
+		}
+	}
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+// Extract reads the archive srcFileName from fsrc and writes its
+// contents into fdst
+func Extract(ctx context.Context, fdst fs.Fs, fsrc fs.Fs, srcFileName string) (err error) {
+	format, err := FormatFromName(srcFileName)
+	if err != nil {
+		return err
+	}
+	src, err := fsrc.NewObject(ctx, srcFileName)
+	if err != nil {
+		return fmt.Errorf("failed to find archive: %w", err)
+	}
+	if format == FormatZip {
+		return extractZip(ctx, fdst, src)
+	}
+	in, err := operations.NewReOpen(ctx, src, fs.GetConfig(ctx).LowLevelRetries)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer fs.CheckClose(in, &err)
+	var r io.Reader = in
+	if format == FormatTarGz {
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(in)
+		if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		defer fs.CheckClose(gz, &err)
+		r = gz
+	}
+	return extractTar(ctx, fdst, r)
+}
+
+// extractTar extracts the tar archive read from in into fdst
+func extractTar(ctx context.Context, fdst fs.Fs, in io.Reader) error {
+	tr := tar.NewReader(in)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read archive: %w", err)
+		}
+		name := cleanName(hdr.Name)
+		if name == "" {
+			fs.Errorf(hdr.Name, "Skipping archive entry with unsafe name")
+			continue
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = operations.Mkdir(ctx, fdst, name)
+		case tar.TypeReg:
+			_, err = operations.RcatSize(ctx, fdst, name, ioutil.NopCloser(tr), hdr.Size, hdr.ModTime)
+		default:
+			fs.Logf(name, "Skipping archive entry which isn't a file or directory")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// objectReaderAt reads from an Object at offsets with range requests
+type objectReaderAt struct {
+	ctx context.Context
+	o   fs.Object
+}
+
+// ReadAt reads len(p) bytes from the Object at off
+func (r *objectReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	in, err := r.o.Open(r.ctx, &fs.RangeOption{Start: off, End: off + int64(len(p)) - 1})
+	if err != nil {
+		return 0, err
+	}
+	defer fs.CheckClose(in, &err)
+	n, err = io.ReadFull(in, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// extractZip extracts the zip archive src into fdst
+//
+// The directory is read with range requests and each file is read
+// with a single range request for its compressed data.
+func extractZip(ctx context.Context, fdst fs.Fs, src fs.Object) error {
+	zr, err := zip.NewReader(&objectReaderAt{ctx: ctx, o: src}, src.Size())
+	if err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+	for _, f := range zr.File {
+		name := cleanName(f.Name)
+		if name == "" {
+			fs.Errorf(f.Name, "Skipping archive entry with unsafe name")
+			continue
+		}
+		if f.FileInfo().IsDir() {
+			err = operations.Mkdir(ctx, fdst, name)
+		} else {
+			err = extractZipFile(ctx, fdst, name, src, f)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractZipFile extracts f from the zip archive src to name in fdst
+func extractZipFile(ctx context.Context, fdst fs.Fs, name string, src fs.Object, f *zip.File) (err error) {
+	offset, err := f.DataOffset()
+	if err != nil {
+		return fmt.Errorf("failed to read archive entry %q: %w", f.Name, err)
+	}
+	var in io.ReadCloser
+	if f.CompressedSize64 == 0 {
+		in = ioutil.NopCloser(strings.NewReader(""))
+	} else {
+		in, err = src.Open(ctx, &fs.RangeOption{Start: offset, End: offset + int64(f.CompressedSize64) - 1})
+		if err != nil {
+			return fmt.Errorf("failed to open archive entry %q: %w", f.Name, err)
+		}
+	}
+	defer fs.CheckClose(in, &err)
+	var r io.Reader
+	switch f.Method {
+	case zip.Store:
+		r = in
+	case zip.Deflate:
+		fr := flate.NewReader(in)
+		defer fs.CheckClose(fr, &err)
+		r = fr
+	default:
+		return fmt.Errorf("archive entry %q: %w", f.Name, zip.ErrAlgorithm)
+	}
+	_, err = operations.RcatSize(ctx, fdst, name, ioutil.NopCloser(r), int64(f.UncompressedSize64), f.Modified)
+	return err
+}
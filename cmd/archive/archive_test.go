@@ -0,0 +1,86 @@
+package archive
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain drives the tests
+func TestMain(m *testing.M) {
+	fstest.TestMain(m)
+}
+
+func TestFormatFromName(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want Format
+		err  bool
+	}{
+		{name: "file.tar", want: FormatTar},
+		{name: "dir/file.TAR", want: FormatTar},
+		{name: "file.tar.gz", want: FormatTarGz},
+		{name: "file.tgz", want: FormatTarGz},
+		{name: "file.zip", want: FormatZip},
+		{name: "file.gz", err: true},
+		{name: "file", err: true},
+	} {
+		got, err := FormatFromName(test.name)
+		if test.err {
+			assert.Error(t, err, test.name)
+		} else {
+			require.NoError(t, err, test.name)
+			assert.Equal(t, test.want, got, test.name)
+		}
+	}
+}
+
+func TestCleanName(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{in: "file", want: "file"},
+		{in: "dir/file", want: "dir/file"},
+		{in: "dir/", want: "dir"},
+		{in: "/abs/file", want: "abs/file"},
+		{in: "./dir//file", want: "dir/file"},
+		{in: `dir\file`, want: "dir/file"},
+		{in: "../file", want: ""},
+		{in: "dir/../../file", want: ""},
+		{in: `..\file`, want: ""},
+		{in: "/", want: ""},
+	} {
+		assert.Equal(t, test.want, cleanName(test.in), test.in)
+	}
+}
+
+func TestCreateExtract(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteObject(ctx, "file1", "hello world", fstest.Time("2001-02-03T04:05:06Z"))
+	file2 := r.WriteObject(ctx, "dir/file2", "potato", fstest.Time("2011-12-13T14:15:16Z"))
+	file3 := r.WriteObject(ctx, "dir/empty", "", fstest.Time("2021-02-03T04:05:06Z"))
+	r.CheckRemoteItems(t, file1, file2, file3)
+
+	for _, name := range []string{"archive.tar", "archive.tar.gz", "archive.zip"} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, Create(ctx, r.Flocal, name, r.Fremote))
+			_, err := r.Flocal.NewObject(ctx, name)
+			require.NoError(t, err)
+
+			fextract, err := fs.NewFs(ctx, filepath.Join(r.LocalName, "extract-"+name))
+			require.NoError(t, err)
+			require.NoError(t, Extract(ctx, fextract, r.Flocal, name))
+			fstest.CheckListingWithPrecision(t, fextract, []fstest.Item{file1, file2, file3}, []string{"dir"}, 2*time.Second)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Package backup provides the backup command.
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/fs/sync"
+	"github.com/spf13/cobra"
+)
+
+// SnapshotFormat is the time format of the names of the snapshot
+// directories
+const SnapshotFormat = "2006-01-02T150405Z"
+
+var (
+	createEmptySrcDirs = false
+	backupPruneOpt     = PruneOptions{Format: SnapshotFormat}
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.BoolVarP(cmdFlags, &createEmptySrcDirs, "create-empty-src-dirs", "", createEmptySrcDirs, "Create empty source dirs in the snapshot")
+	AddPruneFlags(cmdFlags, &backupPruneOpt)
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "backup source:path dest:path",
+	Short: `Make a point in time snapshot of source in a new directory of dest.`,
+	// Note: "|" will be replaced by backticks below
+	Long: strings.ReplaceAll(`
+Copy the source into a new directory of the destination named after
+the current time in UTC, like |2022-05-10T110301Z|, so each run makes
+a complete snapshot of the source which can be restored on its own
+with |rclone copy|.
+
+Files which are unchanged since the newest snapshot already in the
+destination are server-side copied from it rather than uploaded again,
+exactly as if it was given with |--copy-dest|. On backends where a
+server-side copy shares the data, like most object stores with
+deduplicating storage, unchanged files take up no extra space.
+
+For example
+
+    rclone backup /home/user remote:backups
+
+run on two days would make
+
+    remote:backups/2022-05-10T110301Z/...
+    remote:backups/2022-05-11T110245Z/...
+
+If the destination doesn't support server-side copy then each snapshot
+is a full copy of the source.
+
+Directories in dest:path which aren't named like snapshots are
+ignored. Old snapshots can be removed with |rclone purge|, or
+automatically after making each snapshot by giving a retention policy
+with the |--keep-*| flags which work as for |rclone backup-prune|, e.g.
+
+    rclone backup /home/user remote:backups --keep-daily 7 --keep-monthly 12
+
+**Note**: Use the |-P|/|--progress| flag to view real-time transfer statistics.
+
+**Note**: Use the |--dry-run| or the |--interactive|/|-i| flag to test without copying anything.
+`, "|", "`"),
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		fsrc, fdst := cmd.NewFsSrcDst(args)
+		cmd.Run(true, true, command, func() error {
+			ctx := context.Background()
+			now := time.Now()
+			err := Backup(ctx, fdst, fsrc, args[1], now)
+			if err != nil || !backupPruneOpt.HasPolicy() {
+				return err
+			}
+			_, _, err = Prune(ctx, fdst, &backupPruneOpt, now)
+			return err
+		})
+	},
+}
+
+// LatestSnapshot returns the name of the newest snapshot directory in
+// entries or "" if there isn't one
+func LatestSnapshot(entries fs.DirEntries) (latest string) {
+	var latestTime time.Time
+	for _, entry := range entries {
+		if _, ok := entry.(fs.Directory); !ok {
+			continue
+		}
+		name := path.Base(entry.Remote())
+		t, err := time.Parse(SnapshotFormat, name)
+		if err == nil && t.After(latestTime) {
+			latest, latestTime = name, t
+		}
+	}
+	return latest
+}
+
+// Backup copies fsrc into a new snapshot directory named after now in
+// fdst, which is the root of dstRemote, server-side copying the files
+// which are unchanged since the newest snapshot.
+func Backup(ctx context.Context, fdst, fsrc fs.Fs, dstRemote string, now time.Time) error {
+	entries, err := fdst.List(ctx, "")
+	if err != nil && !errors.Is(err, fs.ErrorDirNotFound) {
+		return fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	previous := LatestSnapshot(entries)
+	name := now.UTC().Format(SnapshotFormat)
+	if name <= previous {
+		return fmt.Errorf("snapshot %q is not newer than the latest snapshot %q", name, previous)
+	}
+
+	ctx, ci := fs.AddConfig(ctx)
+	if previous == "" {
+		fs.Infof(fdst, "No previous snapshot found - copying all files")
+	} else if fdst.Features().Copy == nil {
+		fs.Logf(fdst, "Copying all files as the destination doesn't support server-side copy")
+	} else {
+		fs.Infof(fdst, "Copying unchanged files server-side from snapshot %q", previous)
+		ci.CopyDest = append([]string{fspath.JoinRootPath(dstRemote, previous)}, ci.CopyDest...)
+	}
+
+	fsnap, err := cache.Get(ctx, fspath.JoinRootPath(dstRemote, name))
+	if err != nil {
+		return fmt.Errorf("failed to make snapshot %q: %w", name, err)
+	}
+	fs.Logf(fsnap, "Making snapshot")
+	return sync.CopyDir(ctx, fsnap, fsrc, createEmptySrcDirs)
+}
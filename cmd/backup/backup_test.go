@@ -0,0 +1,25 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockdir"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatestSnapshot(t *testing.T) {
+	assert.Equal(t, "", LatestSnapshot(nil))
+	assert.Equal(t, "", LatestSnapshot(fs.DirEntries{
+		mockdir.New("potato"),
+		mockobject.New("2022-05-10T110301Z"),
+	}))
+	assert.Equal(t, "2022-05-11T090000Z", LatestSnapshot(fs.DirEntries{
+		mockdir.New("2022-05-10T110301Z"),
+		mockdir.New("2022-05-11T090000Z"),
+		mockdir.New("2022-05-09T235959Z"),
+		mockdir.New("potato"),
+		mockobject.New("2022-05-12T000000Z"),
+	}))
+}
@@ -0,0 +1,234 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// PruneOptions describes which generations to keep when pruning
+//
+// A generation is kept if any of the options select it.
+type PruneOptions struct {
+	KeepLast    int         // keep the newest this many generations
+	KeepWithin  fs.Duration // keep generations newer than this
+	KeepDaily   int         // keep the newest generation of this many days
+	KeepWeekly  int         // keep the newest generation of this many weeks
+	KeepMonthly int         // keep the newest generation of this many months
+	KeepYearly  int         // keep the newest generation of this many years
+	Format      string      // time format of the generation names or "" to guess
+}
+
+// HasPolicy returns true if any of the keep options are set
+func (opt *PruneOptions) HasPolicy() bool {
+	return opt.KeepLast > 0 || opt.KeepWithin > 0 || opt.KeepDaily > 0 || opt.KeepWeekly > 0 || opt.KeepMonthly > 0 || opt.KeepYearly > 0
+}
+
+// AddPruneFlags adds the flags for opt to flagSet
+func AddPruneFlags(flagSet *pflag.FlagSet, opt *PruneOptions) {
+	flags.IntVarP(flagSet, &opt.KeepLast, "keep-last", "", opt.KeepLast, "Keep the newest N generations")
+	flags.FVarP(flagSet, &opt.KeepWithin, "keep-within", "", "Keep generations newer than this, e.g. 30d")
+	flags.IntVarP(flagSet, &opt.KeepDaily, "keep-daily", "", opt.KeepDaily, "Keep the newest generation of each of the last N days with one")
+	flags.IntVarP(flagSet, &opt.KeepWeekly, "keep-weekly", "", opt.KeepWeekly, "Keep the newest generation of each of the last N weeks with one")
+	flags.IntVarP(flagSet, &opt.KeepMonthly, "keep-monthly", "", opt.KeepMonthly, "Keep the newest generation of each of the last N months with one")
+	flags.IntVarP(flagSet, &opt.KeepYearly, "keep-yearly", "", opt.KeepYearly, "Keep the newest generation of each of the last N years with one")
+}
+
+var pruneOpt = PruneOptions{}
+
+func init() {
+	cmd.Root.AddCommand(pruneDefinition)
+	cmdFlags := pruneDefinition.Flags()
+	AddPruneFlags(cmdFlags, &pruneOpt)
+	flags.StringVarP(cmdFlags, &pruneOpt.Format, "format", "", pruneOpt.Format, "Go time format of the generation directory names if not guessed")
+}
+
+var pruneDefinition = &cobra.Command{
+	Use:   "backup-prune remote:path",
+	Short: `Remove old generations of backups according to a retention policy.`,
+	// Note: "|" will be replaced by backticks below
+	Long: strings.ReplaceAll(`
+Remove the old generations of backups in remote:path which aren't
+selected by any of the |--keep-*| flags.
+
+A generation is a directory in remote:path named after the time it
+was made, like those made by |rclone backup| or by running |rclone sync|
+with |--backup-dir remote:path/2022-05-10| each day. Names like
+|2022-05-10T110301Z|, |2022-05-10T11:03:01Z|, |2022-05-10-110301|,
+|20220510110301| and |2022-05-10| are understood, or give the Go time
+format of the names with |--format|. Times without a time zone are
+taken as UTC. Directories and files with other names are left alone.
+
+The policies work like those of restic and borg:
+
+- |--keep-last N| keeps the newest N generations
+- |--keep-within 30d| keeps the generations made in the last 30 days
+- |--keep-daily N| keeps the newest generation of each of the last N
+  days which have a generation, and likewise |--keep-weekly|,
+  |--keep-monthly| and |--keep-yearly|
+
+A generation is kept if any policy selects it, so
+
+    rclone backup-prune remote:backups --keep-daily 7 --keep-weekly 4 --keep-monthly 12
+
+keeps a week of dailies, a month of weeklies and a year of monthlies.
+The newest generation is always kept. At least one policy must be
+given.
+
+The same flags can be given to |rclone backup| to prune after making
+each snapshot.
+
+**Note**: Use the |--dry-run| or the |--interactive|/|-i| flag to test without deleting anything.
+`, "|", "`"),
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsDir(args)
+		cmd.Run(true, false, command, func() error {
+			_, _, err := Prune(context.Background(), f, &pruneOpt, time.Now())
+			return err
+		})
+	},
+}
+
+// errNoPolicy is returned by Prune if none of the keep options are set
+var errNoPolicy = errors.New("no retention policy given - use at least one of the --keep-* flags")
+
+// generationFormats are the time formats of generation names which
+// are tried in turn if a format isn't given
+var generationFormats = []string{
+	SnapshotFormat,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02-150405",
+	"2006-01-02_15-04-05",
+	"20060102T150405",
+	"20060102150405",
+	"2006-01-02",
+	"20060102",
+}
+
+// Generation is a directory of a backup named after the time it was
+// made
+type Generation struct {
+	Name string
+	Time time.Time
+}
+
+// ParseGenerations returns the directories in entries which are
+// generations named with format, or any of the known formats if it is
+// "", newest first.
+func ParseGenerations(entries fs.DirEntries, format string) (gens []Generation) {
+	formats := generationFormats
+	if format != "" {
+		formats = []string{format}
+	}
+	for _, entry := range entries {
+		if _, ok := entry.(fs.Directory); !ok {
+			continue
+		}
+		name := path.Base(entry.Remote())
+		for _, format := range formats {
+			t, err := time.Parse(format, name)
+			if err == nil {
+				gens = append(gens, Generation{Name: name, Time: t})
+				break
+			}
+		}
+	}
+	sort.SliceStable(gens, func(i, j int) bool {
+		return gens[i].Time.After(gens[j].Time)
+	})
+	return gens
+}
+
+// Keep returns the names of the generations in gens, which must be
+// newest first, that opt selects at time now, with the reasons they
+// were selected.
+func (opt *PruneOptions) Keep(gens []Generation, now time.Time) map[string][]string {
+	keep := make(map[string][]string)
+	if len(gens) == 0 {
+		return keep
+	}
+	keep[gens[0].Name] = append(keep[gens[0].Name], "newest")
+	for i, gen := range gens {
+		if i < opt.KeepLast {
+			keep[gen.Name] = append(keep[gen.Name], "last")
+		}
+		if opt.KeepWithin > 0 && gen.Time.After(now.Add(-time.Duration(opt.KeepWithin))) {
+			keep[gen.Name] = append(keep[gen.Name], "within")
+		}
+	}
+	for _, period := range []struct {
+		n      int
+		reason string
+		key    func(t time.Time) string
+	}{
+		{opt.KeepDaily, "daily", func(t time.Time) string { return t.Format("2006-01-02") }},
+		{opt.KeepWeekly, "weekly", func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-%02d", year, week)
+		}},
+		{opt.KeepMonthly, "monthly", func(t time.Time) string { return t.Format("2006-01") }},
+		{opt.KeepYearly, "yearly", func(t time.Time) string { return t.Format("2006") }},
+	} {
+		// The first generation in each period is the newest
+		n, last := 0, ""
+		for _, gen := range gens {
+			if n >= period.n {
+				break
+			}
+			key := period.key(gen.Time.UTC())
+			if key != last {
+				keep[gen.Name] = append(keep[gen.Name], period.reason)
+				last = key
+				n++
+			}
+		}
+	}
+	return keep
+}
+
+// Prune removes the generations in f which opt doesn't keep at time
+// now, returning the names of the generations kept and removed.
+func Prune(ctx context.Context, f fs.Fs, opt *PruneOptions, now time.Time) (kept, removed []string, err error) {
+	if !opt.HasPolicy() {
+		return nil, nil, errNoPolicy
+	}
+	entries, err := f.List(ctx, "")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list generations: %w", err)
+	}
+	gens := ParseGenerations(entries, opt.Format)
+	if len(gens) == 0 {
+		fs.Logf(f, "No generations found to prune")
+		return nil, nil, nil
+	}
+	keep := opt.Keep(gens, now)
+	for _, gen := range gens {
+		if reasons, ok := keep[gen.Name]; ok {
+			fs.Infof(f, "Keeping generation %q (%s)", gen.Name, strings.Join(reasons, ", "))
+			kept = append(kept, gen.Name)
+			continue
+		}
+		fs.Infof(f, "Removing generation %q", gen.Name)
+		purgeErr := operations.Purge(ctx, f, gen.Name)
+		if purgeErr != nil {
+			fs.Errorf(f, "Failed to remove generation %q: %v", gen.Name, purgeErr)
+			err = purgeErr
+			continue
+		}
+		removed = append(removed, gen.Name)
+	}
+	return kept, removed, err
+}
@@ -0,0 +1,76 @@
+package backup
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockdir"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGenerations(t *testing.T) {
+	entries := fs.DirEntries{
+		mockdir.New("2022-05-10T110301Z"),
+		mockdir.New("2022-05-11"),
+		mockdir.New("20220509120000"),
+		mockdir.New("potato"),
+		mockobject.New("2022-05-12"),
+	}
+	gens := ParseGenerations(entries, "")
+	var names []string
+	for _, gen := range gens {
+		names = append(names, gen.Name)
+	}
+	assert.Equal(t, []string{"2022-05-11", "2022-05-10T110301Z", "20220509120000"}, names)
+	assert.Equal(t, time.Date(2022, 5, 10, 11, 3, 1, 0, time.UTC), gens[1].Time)
+
+	gens = ParseGenerations(entries, "2006-01-02")
+	assert.Equal(t, []Generation{{Name: "2022-05-11", Time: time.Date(2022, 5, 11, 0, 0, 0, 0, time.UTC)}}, gens)
+}
+
+func TestPruneKeep(t *testing.T) {
+	// A generation every day at noon for 400 days
+	now := time.Date(2022, 5, 10, 13, 0, 0, 0, time.UTC)
+	var gens []Generation
+	for i := 0; i < 400; i++ {
+		t := now.Add(-time.Hour).AddDate(0, 0, -i)
+		gens = append(gens, Generation{Name: t.Format(SnapshotFormat), Time: t})
+	}
+	day := func(year int, month time.Month, day int) string {
+		return time.Date(year, month, day, 12, 0, 0, 0, time.UTC).Format(SnapshotFormat)
+	}
+	for _, test := range []struct {
+		name string
+		opt  PruneOptions
+		want []string
+	}{
+		{"None", PruneOptions{}, []string{day(2022, 5, 10)}},
+		{"Last", PruneOptions{KeepLast: 2}, []string{day(2022, 5, 10), day(2022, 5, 9)}},
+		{"Within", PruneOptions{KeepWithin: fs.Duration(50 * time.Hour)}, []string{day(2022, 5, 10), day(2022, 5, 9), day(2022, 5, 8)}},
+		{"Daily", PruneOptions{KeepDaily: 2}, []string{day(2022, 5, 10), day(2022, 5, 9)}},
+		// 2022-05-10 is a Tuesday so the weeks end on the Sundays before
+		{"Weekly", PruneOptions{KeepWeekly: 3}, []string{day(2022, 5, 10), day(2022, 5, 8), day(2022, 5, 1)}},
+		{"Monthly", PruneOptions{KeepMonthly: 3}, []string{day(2022, 5, 10), day(2022, 4, 30), day(2022, 3, 31)}},
+		{"Yearly", PruneOptions{KeepYearly: 5}, []string{day(2022, 5, 10), day(2021, 12, 31)}},
+		{"Combined", PruneOptions{KeepDaily: 2, KeepMonthly: 2}, []string{day(2022, 5, 10), day(2022, 5, 9), day(2022, 4, 30)}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			keep := test.opt.Keep(gens, now)
+			var got []string
+			for _, gen := range gens {
+				if _, ok := keep[gen.Name]; ok {
+					got = append(got, gen.Name)
+				}
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestPruneNoPolicy(t *testing.T) {
+	_, _, err := Prune(context.Background(), nil, &PruneOptions{}, time.Now())
+	assert.Equal(t, errNoPolicy, err)
+}
@@ -0,0 +1,89 @@
+package backup
+
+import (
+	"context"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "backup/prune",
+		AuthRequired: true,
+		Fn:           rcPrune,
+		Title:        "Remove old generations of backups according to a retention policy",
+		Help: `This takes the following parameters:
+
+- fs - a remote name string e.g. "drive:backups" containing the generations
+- keepLast - keep the newest N generations
+- keepWithin - keep generations newer than this, e.g. "30d"
+- keepDaily - keep the newest generation of each of the last N days with one
+- keepWeekly - keep the newest generation of each of the last N weeks with one
+- keepMonthly - keep the newest generation of each of the last N months with one
+- keepYearly - keep the newest generation of each of the last N years with one
+- format - Go time format of the generation directory names if not guessed
+
+At least one of the keep parameters must be given.
+
+Returns:
+
+- kept - names of the generations kept
+- removed - names of the generations removed
+
+See the [backup-prune](/commands/rclone_backup-prune/) command for more information on the above.
+`,
+	})
+}
+
+// Prune generations over rc
+func rcPrune(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	f, err := rc.GetFs(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	opt := &PruneOptions{}
+	for _, param := range []struct {
+		name string
+		p    *int
+	}{
+		{"keepLast", &opt.KeepLast},
+		{"keepDaily", &opt.KeepDaily},
+		{"keepWeekly", &opt.KeepWeekly},
+		{"keepMonthly", &opt.KeepMonthly},
+		{"keepYearly", &opt.KeepYearly},
+	} {
+		n, err := in.GetInt64(param.name)
+		if rc.NotErrParamNotFound(err) {
+			return nil, err
+		}
+		*param.p = int(n)
+	}
+	keepWithin, err := in.GetDuration("keepWithin")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	opt.KeepWithin = fs.Duration(keepWithin)
+	opt.Format, err = in.GetString("format")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if !opt.HasPolicy() {
+		return nil, rc.NewErrParamInvalid(errNoPolicy)
+	}
+	kept, removed, err := Prune(ctx, f, opt, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if kept == nil {
+		kept = []string{}
+	}
+	if removed == nil {
+		removed = []string{}
+	}
+	return rc.Params{
+		"kept":    kept,
+		"removed": removed,
+	}, nil
+}
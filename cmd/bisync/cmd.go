@@ -32,6 +32,7 @@ type Options struct {
 	CheckAccess     bool
 	CheckFilename   string
 	CheckSync       CheckSyncMode
+	OnConflict      ConflictMode
 	RemoveEmptyDirs bool
 	MaxDelete       int // percentage from 0 to 100
 	Force           bool
@@ -93,6 +94,48 @@ func (x *CheckSyncMode) Type() string {
 	return "string"
 }
 
+// ConflictMode controls how files new or changed in both paths are resolved
+type ConflictMode int
+
+// Conflict modes
+const (
+	ConflictKeepBoth ConflictMode = iota // Keep both versions renamed with ..path1 and ..path2 suffixes (default)
+	ConflictNewer                        // Keep the version with the newer modification time
+	ConflictFail                         // Abort without changing anything
+)
+
+func (x ConflictMode) String() string {
+	switch x {
+	case ConflictKeepBoth:
+		return "keep-both"
+	case ConflictNewer:
+		return "newer"
+	case ConflictFail:
+		return "fail"
+	}
+	return "unknown"
+}
+
+// Set a Conflict mode from a string
+func (x *ConflictMode) Set(s string) error {
+	switch strings.ToLower(s) {
+	case "keep-both":
+		*x = ConflictKeepBoth
+	case "newer":
+		*x = ConflictNewer
+	case "fail":
+		*x = ConflictFail
+	default:
+		return fmt.Errorf("unknown on-conflict mode for bisync: %q", s)
+	}
+	return nil
+}
+
+// Type of the Conflict value
+func (x *ConflictMode) Type() string {
+	return "string"
+}
+
 // Opt keeps command line options
 var Opt Options
 
@@ -104,6 +147,7 @@ func init() {
 	flags.StringVarP(cmdFlags, &Opt.CheckFilename, "check-filename", "", Opt.CheckFilename, makeHelp("Filename for --check-access (default: {CHECKFILE})"))
 	flags.BoolVarP(cmdFlags, &Opt.Force, "force", "", Opt.Force, "Bypass --max-delete safety check and run the sync. Consider using with --verbose")
 	flags.FVarP(cmdFlags, &Opt.CheckSync, "check-sync", "", "Controls comparison of final listings: true|false|only (default: true)")
+	flags.FVarP(cmdFlags, &Opt.OnConflict, "on-conflict", "", "How to resolve files new or changed in both paths: keep-both|newer|fail (default: keep-both)")
 	flags.BoolVarP(cmdFlags, &Opt.RemoveEmptyDirs, "remove-empty-dirs", "", Opt.RemoveEmptyDirs, "Remove empty directories at the final cleanup step.")
 	flags.StringVarP(cmdFlags, &Opt.FiltersFile, "filters-file", "", Opt.FiltersFile, "Read filtering patterns from a file")
 	flags.StringVarP(cmdFlags, &Opt.Workdir, "workdir", "", Opt.Workdir, makeHelp("Use custom working dir - useful for testing. (default: {WORKDIR})"))
@@ -158,6 +202,8 @@ func (opt *Options) applyContext(ctx context.Context) {
 	ci := fs.GetConfig(ctx)
 	if ci.MaxDelete >= 0 {
 		maxDelete = int(ci.MaxDelete)
+	} else if ci.MaxDeletePercent >= 0 {
+		maxDelete = ci.MaxDeletePercent
 	}
 	if maxDelete < 0 {
 		maxDelete = 0
@@ -168,6 +214,7 @@ func (opt *Options) applyContext(ctx context.Context) {
 	opt.MaxDelete = maxDelete
 	// reset MaxDelete for fs/operations, bisync handles this parameter specially
 	ci.MaxDelete = -1
+	ci.MaxDeletePercent = -1
 	opt.DryRun = ci.DryRun
 }
 
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"sort"
+	"time"
 
 	"github.com/rclone/rclone/cmd/bisync/bilib"
 	"github.com/rclone/rclone/fs"
@@ -45,6 +46,7 @@ type deltaSet struct {
 	deleted    int    // number of deleted files (for "excess deletes" check)
 	foundSame  bool   // true if found at least one unchanged file
 	checkFiles bilib.Names
+	times      map[string]time.Time // current modification times of the new or changed files
 }
 
 func (ds *deltaSet) empty() bool {
@@ -119,6 +121,7 @@ func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, oldListing, newLis
 		oldCount:   len(old.list),
 		opt:        b.opt,
 		checkFiles: bilib.Names{},
+		times:      map[string]time.Time{},
 	}
 
 	for _, file := range old.list {
@@ -142,6 +145,7 @@ func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, oldListing, newLis
 
 		if d.is(deltaModified) {
 			ds.deltas[file] = d
+			ds.times[file] = now.getTime(file)
 		} else {
 			// Once we've found at least one unchanged file,
 			// we know that not everything has changed,
@@ -154,6 +158,7 @@ func (b *bisyncRun) findDeltas(fctx context.Context, f fs.Fs, oldListing, newLis
 		if !old.has(file) {
 			b.indent(msg, file, "File is new")
 			ds.deltas[file] = deltaNew
+			ds.times[file] = now.getTime(file)
 		}
 	}
 
@@ -198,6 +203,23 @@ func (b *bisyncRun) applyDeltas(ctx context.Context, ds1, ds2 *deltaSet) (change
 				copy1to2.Add(file)
 				handled.Add(file)
 			} else if d2.is(deltaOther) {
+				if b.opt.OnConflict == ConflictNewer {
+					t1, t2 := ds1.times[file], ds2.times[file]
+					if t1.After(t2) {
+						b.indent("!WARNING", file, "New or changed in both paths - Path1 is newer")
+						b.indent("Path1", p2, "Queue copy to Path2")
+						copy1to2.Add(file)
+						handled.Add(file)
+						continue
+					} else if t2.After(t1) {
+						b.indent("!WARNING", file, "New or changed in both paths - Path2 is newer")
+						b.indent("Path2", p1, "Queue copy to Path1")
+						copy2to1.Add(file)
+						handled.Add(file)
+						continue
+					}
+					b.indent("!WARNING", file, "New or changed in both paths at the same time - keeping both")
+				}
 				b.indent("!WARNING", file, "New or changed in both paths")
 				b.indent("!Path1", p1+"..path1", "Renaming Path1 copy")
 				if err = operations.MoveFile(ctxMove, b.fs1, b.fs1, file+"..path1", file); err != nil {
@@ -290,6 +312,17 @@ func (b *bisyncRun) applyDeltas(ctx context.Context, ds1, ds2 *deltaSet) (change
 	return
 }
 
+// conflicts returns the files which are new or changed in both ds1 and ds2
+func conflicts(ds1, ds2 *deltaSet) (files []string) {
+	for _, file := range ds1.sort() {
+		d2, in2 := ds2.deltas[file]
+		if ds1.deltas[file].is(deltaOther) && in2 && d2.is(deltaOther) {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
 // exccessDeletes checks whether number of deletes is within allowed range
 func (ds *deltaSet) excessDeletes() bool {
 	maxDelete := ds.opt.MaxDelete
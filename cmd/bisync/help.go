@@ -30,6 +30,8 @@ var rcHelp = makeHelp(`This takes the following parameters
 - force - maxDelete safety check and run the sync
 - checkSync - |true| by default, |false| disables comparison of final listings,
               |only| will skip sync, only compare listings from the last run
+- onConflict - how to resolve files new or changed in both paths:
+               |keep-both| by default, |newer| or |fail|
 - removeEmptyDirs - remove empty directories at the final cleanup step
 - filtersFile - read filtering patterns from a file
 - workdir - server directory for history files (default: {WORKDIR})
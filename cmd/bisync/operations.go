@@ -245,6 +245,17 @@ func (b *bisyncRun) runLocked(octx context.Context, listing1, listing2 string) (
 		}
 	}
 
+	// Check for files changed in both paths if they mustn't be resolved
+	if opt.OnConflict == ConflictFail {
+		if files := conflicts(ds1, ds2); len(files) > 0 {
+			for _, file := range files {
+				fs.Errorf(file, "New or changed in both paths")
+			}
+			b.abort = true
+			return fmt.Errorf("%d files new or changed in both paths", len(files))
+		}
+	}
+
 	// Determine and apply changes to Path1 and Path2
 	noChanges := ds1.empty() && ds2.empty()
 	changes1 := false
@@ -73,6 +73,16 @@ func rcBisync(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 		return nil, err
 	}
 
+	onConflict, err := in.GetString("onConflict")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if onConflict != "" {
+		if err := opt.OnConflict.Set(onConflict); err != nil {
+			return nil, rc.NewErrParamInvalid(err)
+		}
+	}
+
 	fs1, err := rc.GetFsNamed(octx, in, "path1")
 	if err != nil {
 		return nil, err
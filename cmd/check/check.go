@@ -26,6 +26,7 @@ var (
 	match             = ""
 	differ            = ""
 	errFile           = ""
+	outputFormat      = "text"
 	checkFileHashType = ""
 )
 
@@ -46,6 +47,7 @@ func AddFlags(cmdFlags *pflag.FlagSet) {
 	flags.StringVarP(cmdFlags, &match, "match", "", match, "Report all matching files to this file")
 	flags.StringVarP(cmdFlags, &differ, "differ", "", differ, "Report all non-matching files to this file")
 	flags.StringVarP(cmdFlags, &errFile, "error", "", errFile, "Report all files with errors (hashing or reading) to this file")
+	flags.StringVarP(cmdFlags, &outputFormat, "output-format", "", outputFormat, "Format of the --combined report text|json")
 }
 
 // FlagsHelp describes the flags for the help
@@ -71,6 +73,15 @@ you what happened to it. These are reminiscent of diff files.
 - |+ path| means path was missing on the destination, so only in the source
 - |* path| means path was present in source and destination but different.
 - |! path| means there was an error reading or hashing the source or dest.
+
+If you supply |--output-format json| then the |--combined| report is
+written as one JSON object per line instead, to stdout if |--combined|
+isn't set, which is easier for scripts and CI pipelines to read, e.g.
+
+    {"path":"file.txt","status":"match"}
+
+The |status| is one of |match|, |differ|, |missingOnSrc|,
+|missingOnDst| or |error|.
 `, "|", "`")
 
 // GetCheckOpt gets the options corresponding to the check flags
@@ -100,7 +111,19 @@ func GetCheckOpt(fsrc, fdst fs.Fs) (opt *operations.CheckOpt, close func(), err
 		return nil
 	}
 
-	if err = open(combined, &opt.Combined); err != nil {
+	combinedName := combined
+	switch strings.ToLower(outputFormat) {
+	case "", "text":
+	case "json":
+		opt.CombinedJSON = true
+		if combinedName == "" {
+			combinedName = "-"
+		}
+	default:
+		return nil, nil, fmt.Errorf("unknown --output-format %q: must be text or json", outputFormat)
+	}
+
+	if err = open(combinedName, &opt.Combined); err != nil {
 		return nil, nil, err
 	}
 	if err = open(missingOnSrc, &opt.MissingOnSrc); err != nil {
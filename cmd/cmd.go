@@ -245,7 +245,9 @@ func Run(Retry bool, showStats bool, cmd *cobra.Command, f func() error) {
 	if !showStats && ShowStats() {
 		showStats = true
 	}
-	if ci.Progress {
+	if ci.ProgressJSON || ci.ProgressJSONAddr != "" {
+		stopStats = startProgressJSON(ci.ProgressJSONAddr)
+	} else if ci.Progress {
 		stopStats = startProgress()
 	} else if showStats {
 		stopStats = StartStats()
@@ -487,6 +489,8 @@ func resolveExitCode(err error) {
 		os.Exit(exitcode.UncategorizedError)
 	case errors.Is(err, accounting.ErrorMaxTransferLimitReached):
 		os.Exit(exitcode.TransferExceeded)
+	case errors.Is(err, fs.ErrorMaxDeleteReached):
+		os.Exit(exitcode.MaxDeleteReached)
 	case fserrors.ShouldRetry(err):
 		os.Exit(exitcode.RetryError)
 	case fserrors.IsNoRetryError(err):
@@ -170,6 +170,10 @@ func (fsys *FS) stat(node vfs.Node, stat *fuse.Stat_t) (errc int) {
 	stat.Size = int64(Size)
 	t := fuse.NewTimespec(modTime)
 	stat.Atim = t
+	if mNode, ok := node.(vfs.MetadataNode); ok {
+		stat.Uid, stat.Gid = mNode.Owner()
+		stat.Atim = fuse.NewTimespec(mNode.AccessTime())
+	}
 	stat.Mtim = t
 	stat.Ctim = t
 	stat.Blksize = 512
@@ -304,6 +308,8 @@ func (fsys *FS) OpenEx(path string, fi *fuse.FileInfo_t) (errc int) {
 	if err != nil {
 		return translateError(err)
 	}
+	_, _, pid := fuse.Getcontext()
+	fsys.VFS.SetHandlePID(handle, pid)
 
 	// If size unknown then use direct io to read
 	if entry := handle.Node().DirEntry(); entry != nil && entry.Size() < 0 {
@@ -341,6 +347,8 @@ func (fsys *FS) CreateEx(filePath string, mode uint32, fi *fuse.FileInfo_t) (err
 	if err != nil {
 		return translateError(err)
 	}
+	_, _, pid := fuse.Getcontext()
+	fsys.VFS.SetHandlePID(handle, pid)
 	fi.Fh = fsys.openHandle(handle)
 	return 0
 }
@@ -476,6 +484,12 @@ func (fsys *FS) Utimens(path string, tmsp []fuse.Timespec) (errc int) {
 		fs.Debugf(path, "Utimens: Not setting time as timespec isn't complete: %v", tmsp)
 		return 0
 	}
+	if mNode, ok := node.(vfs.MetadataNode); ok && !tmsp[0].Time().Before(invalidDateCutoff) {
+		err := mNode.SetAccessTime(tmsp[0].Time())
+		if err != nil {
+			return translateError(err)
+		}
+	}
 	t := tmsp[1].Time()
 	if t.Before(invalidDateCutoff) {
 		fs.Debugf(path, "Utimens: Not setting out of range time: %v", t)
@@ -517,17 +531,43 @@ func (fsys *FS) Readlink(path string) (errc int, linkPath string) {
 }
 
 // Chmod changes the permission bits of a file.
+//
+// This is only stored with --vfs-metadata
 func (fsys *FS) Chmod(path string, mode uint32) (errc int) {
 	defer log.Trace(path, "mode=0%o", mode)("errc=%d", &errc)
-	// This is a no-op for rclone
-	return 0
+	node, errc := fsys.lookupNode(path)
+	if errc != 0 {
+		return errc
+	}
+	mNode, ok := node.(vfs.MetadataNode)
+	if !ok {
+		return 0
+	}
+	return translateError(mNode.Chmod(os.FileMode(mode).Perm()))
 }
 
 // Chown changes the owner and group of a file.
+//
+// This is only stored with --vfs-metadata
 func (fsys *FS) Chown(path string, uid uint32, gid uint32) (errc int) {
 	defer log.Trace(path, "uid=%d, gid=%d", uid, gid)("errc=%d", &errc)
-	// This is a no-op for rclone
-	return 0
+	node, errc := fsys.lookupNode(path)
+	if errc != 0 {
+		return errc
+	}
+	mNode, ok := node.(vfs.MetadataNode)
+	if !ok {
+		return 0
+	}
+	// ^uint32(0) means leave it unchanged
+	newUID, newGID := -1, -1
+	if uid != ^uint32(0) {
+		newUID = int(uid)
+	}
+	if gid != ^uint32(0) {
+		newGID = int(gid)
+	}
+	return translateError(mNode.Chown(newUID, newGID))
 }
 
 // Access checks file access permissions.
@@ -593,6 +633,8 @@ func translateError(err error) (errc int) {
 		return -fuse.ENOSYS
 	case vfs.EINVAL:
 		return -fuse.EINVAL
+	case vfs.ENOSPC:
+		return -fuse.ENOSPC
 	}
 	fs.Errorf(nil, "IO error: %v", err)
 	return -fuse.EIO
@@ -200,6 +200,15 @@ in with each invocation of |--continue|.
 At the end of the non interactive process, rclone will return a result
 with |State| as empty string.
 
+If |--oauth-url| is passed then instead of asking |config_is_local|
+and waiting for a web browser on this machine to do OAuth, rclone
+returns the URL to authorize at as |OAuthURL| and asks the question
+|config_oauth_redirect|. Open the URL in a browser anywhere, log in,
+then return the URL the browser is redirected to (which may fail to
+load) as the |--result|. rclone checks its state against the |--state|
+and exchanges the code in it for a token. This is useful for setting
+up remotes from a web UI with the |config/create| rc command.
+
 If |--all| is passed then rclone will ask all the config questions,
 not just the post config questions. Any parameters are used as
 defaults for questions as usual.
@@ -267,6 +276,7 @@ func init() {
 		flags.BoolVarP(cmdFlags, &updateRemoteOpt.All, "all", "", false, "Ask the full set of config questions")
 		flags.StringVarP(cmdFlags, &updateRemoteOpt.State, "state", "", "", "State - use with --continue")
 		flags.StringVarP(cmdFlags, &updateRemoteOpt.Result, "result", "", "", "Result - use with --continue")
+		flags.BoolVarP(cmdFlags, &updateRemoteOpt.OAuthURL, "oauth-url", "", false, "Return the URL to do OAuth at and ask for the redirect")
 	}
 }
 
@@ -2,8 +2,10 @@ package copyurl
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/rclone/rclone/cmd"
@@ -18,6 +20,9 @@ var (
 	printFilename = false
 	stdout        = false
 	noClobber     = false
+	urlsFrom      = ""
+	results       = ""
+	urlRetries    = 3
 )
 
 func init() {
@@ -27,6 +32,9 @@ func init() {
 	flags.BoolVarP(cmdFlags, &printFilename, "print-filename", "p", printFilename, "Print the resulting name from --auto-filename")
 	flags.BoolVarP(cmdFlags, &noClobber, "no-clobber", "", noClobber, "Prevent overwriting file with same name")
 	flags.BoolVarP(cmdFlags, &stdout, "stdout", "", stdout, "Write the output to stdout rather than a file")
+	flags.StringVarP(cmdFlags, &urlsFrom, "urls-from", "", urlsFrom, "Read a list of URLs and destinations to copy from file (use - to read from stdin)")
+	flags.StringVarP(cmdFlags, &results, "results", "", results, "Write a JSON report of the results of --urls-from to this file (use - for stdout)")
+	flags.IntVarP(cmdFlags, &urlRetries, "url-retries", "", urlRetries, "Number of times to try each URL with --urls-from")
 }
 
 var commandDefinition = &cobra.Command{
@@ -46,8 +54,56 @@ destination if there is one with the same name.
 
 Setting ` + "`--stdout`" + ` or making the output file name ` + "`-`" + `
 will cause the output to be written to standard output.
+
+### Copying many URLs
+
+Use ` + "`--urls-from file`" + ` to copy a list of URLs into the
+destination directory, using ` + "`-`" + ` to read the list from standard
+input. There is one URL per line, optionally followed by white space and
+the path to store it at relative to the destination. If the path is
+left out then the file name is taken from the URL as with
+` + "`--auto-filename`" + `. Blank lines and lines starting with ` + "`#`" + `
+or ` + "`;`" + ` are ignored.
+
+    # URL                              destination
+    https://example.com/data/file1.csv
+    https://example.com/data/file2.csv 2022/file2.csv
+
+    rclone copyurl --urls-from urls.txt remote:downloads
+
+The URLs are downloaded ` + "`--transfers`" + ` at a time and each one is
+tried up to ` + "`--url-retries`" + ` times, waiting longer between each
+attempt. Errors which won't be fixed by trying again, such as a 404 Not
+Found, aren't retried. ` + "`--no-clobber`" + ` can be used with
+` + "`--urls-from`" + ` but ` + "`--auto-filename`" + ` and ` + "`--stdout`" + ` can't.
+
+Use ` + "`--results file`" + ` to write a JSON report of what happened
+to each URL, or ` + "`--results -`" + ` to write it to standard output.
+It is a list of objects like this, in the same order as the input.
+
+` + "```" + `
+[
+  {
+    "url": "https://example.com/data/file2.csv",
+    "remote": "2022/file2.csv",
+    "size": 1234,
+    "tries": 1,
+    "success": true
+  }
+]
+` + "```" + `
+
+Failed downloads have ` + "`\"success\": false`" + ` and an ` + "`error`" + `
+with the last error. rclone exits with an error if any URL couldn't be
+copied but still copies all the others and writes the report.
 `,
 	RunE: func(command *cobra.Command, args []string) (err error) {
+		if urlsFrom != "" {
+			return copyURLs(command, args)
+		}
+		if results != "" {
+			return errors.New("--results can only be used with --urls-from")
+		}
 		cmd.CheckArgs(1, 2, command, args)
 
 		var dstFileName string
@@ -79,3 +135,67 @@ will cause the output to be written to standard output.
 		return nil
 	},
 }
+
+// copyURLs copies the URLs read from --urls-from
+func copyURLs(command *cobra.Command, args []string) error {
+	if autoFilename || stdout {
+		return errors.New("can't use --auto-filename or --stdout with --urls-from")
+	}
+	cmd.CheckArgs(1, 1, command, args)
+	fdst := cmd.NewFsDir(args)
+	cmd.Run(false, true, command, func() error {
+		items, err := readCopyURLItems()
+		if err != nil {
+			return err
+		}
+		copyResults, copyErr := operations.CopyURLs(context.Background(), fdst, items, noClobber, urlRetries)
+		if results != "" {
+			err = writeResults(copyResults)
+			if err != nil {
+				return err
+			}
+		}
+		return copyErr
+	})
+	return nil
+}
+
+// readCopyURLItems reads the items to copy from --urls-from
+func readCopyURLItems() (items []operations.CopyURLItem, err error) {
+	var in io.Reader = os.Stdin
+	if urlsFrom != "-" {
+		var fd *os.File
+		fd, err = os.Open(urlsFrom)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open --urls-from: %w", err)
+		}
+		defer fs.CheckClose(fd, &err)
+		in = fd
+	}
+	items, err = operations.ReadCopyURLItems(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --urls-from: %w", err)
+	}
+	return items, nil
+}
+
+// writeResults writes the JSON report to --results
+func writeResults(copyResults []operations.CopyURLResult) (err error) {
+	var out io.Writer = os.Stdout
+	if results != "-" {
+		var fd *os.File
+		fd, err = os.Create(results)
+		if err != nil {
+			return fmt.Errorf("failed to create --results: %w", err)
+		}
+		defer fs.CheckClose(fd, &err)
+		out = fd
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(copyResults)
+	if err != nil {
+		return fmt.Errorf("failed to write --results: %w", err)
+	}
+	return nil
+}
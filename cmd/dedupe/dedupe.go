@@ -19,7 +19,7 @@ var (
 func init() {
 	cmd.Root.AddCommand(commandDefinition)
 	cmdFlag := commandDefinition.Flags()
-	flags.FVarP(cmdFlag, &dedupeMode, "dedupe-mode", "", "Dedupe mode interactive|skip|first|newest|oldest|largest|smallest|rename")
+	flags.FVarP(cmdFlag, &dedupeMode, "dedupe-mode", "", "Dedupe mode interactive|skip|first|newest|oldest|largest|smallest|rename|copy")
 	flags.BoolVarP(cmdFlag, &byHash, "by-hash", "", false, "Find identical hashes rather than names")
 }
 
@@ -125,6 +125,18 @@ Dedupe can be run non interactively using the ` + "`" + `--dedupe-mode` + "`" +
   * ` + "`" + `--dedupe-mode smallest` + "`" + ` - removes identical files then keeps the smallest one.
   * ` + "`" + `--dedupe-mode rename` + "`" + ` - removes identical files then renames the rest to be different.
   * ` + "`" + `--dedupe-mode list` + "`" + ` - lists duplicate dirs and files only and changes nothing.
+  * ` + "`" + `--dedupe-mode copy` + "`" + ` - with --by-hash only, replaces the duplicates with server-side copies of the oldest one.
+
+When deduping by hash the duplicates can be anywhere in the remote, and
+the modes which keep one file, such as ` + "`newest`" + ` and ` + "`oldest`" + `,
+delete all the others wherever they are. Use ` + "`list`" + ` to report
+them first.
+
+The ` + "`copy`" + ` mode keeps all the file names but replaces the
+duplicates with server-side copies of the oldest one. This only saves
+space on backends where a server-side copy shares the data with the
+original rather than duplicating it, such as some deduplicating object
+stores, and needs the backend to support server-side copy.
 
 For example, to rename all the identically named photos in your Google Photos directory, do
 
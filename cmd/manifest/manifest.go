@@ -0,0 +1,189 @@
+// Package manifest provides the manifest command.
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	hashType = hash.SHA256
+	download = false
+	keyFile  = ""
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	commandDefinition.AddCommand(createCommand)
+	commandDefinition.AddCommand(verifyCommand)
+	createFlags := createCommand.Flags()
+	flags.FVarP(createFlags, &hashType, "hash", "", "Hash type to use in the manifest")
+	addFlags(createFlags)
+	addFlags(verifyCommand.Flags())
+}
+
+// addFlags adds the flags common to create and verify
+func addFlags(cmdFlags *pflag.FlagSet) {
+	flags.BoolVarP(cmdFlags, &download, "download", "", download, "Download the files and hash them locally even if the remote supports the hash")
+	flags.StringVarP(cmdFlags, &keyFile, "key-file", "", keyFile, "File containing the secret key to sign or check the manifest with")
+}
+
+// readKey reads the key from --key-file if set
+func readKey() (key []byte, err error) {
+	if keyFile == "" {
+		return nil, nil
+	}
+	key, err = ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read --key-file: %w", err)
+	}
+	key = bytes.TrimRight(key, "\r\n")
+	if len(key) == 0 {
+		return nil, errors.New("--key-file is empty")
+	}
+	return key, nil
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "manifest",
+	Short: `Create or verify an integrity manifest of a remote.`,
+	// Note: "|" will be replaced by backticks below
+	Long: strings.ReplaceAll(`
+A manifest is a JSON file listing the path, size and hash of each
+file in a remote. Make one with |rclone manifest create| and check the
+remote against it later with |rclone manifest verify| to find any
+files which have been changed, corrupted, added or removed.
+
+The manifest records the hashes with a single hash type, SHA-256 by
+default. If a remote can't supply that hash then its files are
+downloaded and hashed locally. This means that a manifest made on one
+remote can be used to verify a copy of the files on any other remote,
+even if the two remotes don't share a hash type.
+
+If |--key-file| is given, the manifest is signed with the secret key
+in that file (using HMAC-SHA256) so that changes to the manifest
+itself can be detected when it is verified with the same key. Keep
+the key somewhere other than the remote being checked.
+`, "|", "`"),
+}
+
+var createCommand = &cobra.Command{
+	Use:   "create remote:path manifest.json",
+	Short: `Create an integrity manifest of remote:path.`,
+	// Note: "|" will be replaced by backticks below
+	Long: strings.ReplaceAll(`
+Create a manifest of the files in remote:path, writing it to the local
+file manifest.json, or to standard output if it is |-|.
+
+    rclone manifest create --key-file secret.key remote:path manifest.json
+
+Use |--hash| to choose the hash type. Choosing a hash which the remote
+supports, e.g. |--hash md5| for S3, avoids downloading the files. Use
+|--download| to hash the data of the files even if the remote can
+supply the hash.
+
+`, "|", "`") + hash.HelpString(0),
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		f := cmd.NewFsSrc(args)
+		output := args[1]
+		cmd.Run(false, output != "-", command, func() (err error) {
+			key, err := readKey()
+			if err != nil {
+				return err
+			}
+			m, err := operations.MakeManifest(context.Background(), f, hashType, download)
+			if err != nil {
+				return err
+			}
+			if key != nil {
+				err = m.Sign(key)
+				if err != nil {
+					return err
+				}
+			}
+			var out io.Writer = os.Stdout
+			if output != "-" {
+				var fd *os.File
+				fd, err = os.Create(output)
+				if err != nil {
+					return fmt.Errorf("failed to create manifest: %w", err)
+				}
+				defer fs.CheckClose(fd, &err)
+				out = fd
+			}
+			return m.Write(out)
+		})
+	},
+}
+
+var verifyCommand = &cobra.Command{
+	Use:   "verify remote:path manifest.json",
+	Short: `Verify remote:path against an integrity manifest.`,
+	// Note: "|" will be replaced by backticks below
+	Long: strings.ReplaceAll(`
+Check the files in remote:path against the manifest in the local file
+manifest.json, or standard input if it is |-|. An error is logged for
+each file which isn't in the manifest, is missing from the remote, or
+has a different size or hash. It exits with an error if any were
+found.
+
+    rclone manifest verify --key-file secret.key remote:path manifest.json
+
+If |--key-file| is given, the signature of the manifest is checked
+with the key first, and nothing is verified if it doesn't match. If the
+manifest is signed but no |--key-file| is given then the signature
+isn't checked.
+
+Use |--download| to hash the data of the files even if the remote can
+supply the hash.
+`, "|", "`"),
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(2, 2, command, args)
+		f := cmd.NewFsSrc(args)
+		input := args[1]
+		cmd.Run(false, true, command, func() (err error) {
+			key, err := readKey()
+			if err != nil {
+				return err
+			}
+			var in io.Reader = os.Stdin
+			if input != "-" {
+				var fd *os.File
+				fd, err = os.Open(input)
+				if err != nil {
+					return fmt.Errorf("failed to open manifest: %w", err)
+				}
+				defer fs.CheckClose(fd, &err)
+				in = fd
+			}
+			m, err := operations.ReadManifest(in)
+			if err != nil {
+				return err
+			}
+			if key != nil {
+				err = m.CheckSignature(key)
+				if err != nil {
+					return err
+				}
+			} else if m.Signature != "" {
+				fs.Logf(nil, "Not checking the manifest signature as no --key-file was given")
+			}
+			return operations.VerifyManifest(context.Background(), f, m, download)
+		})
+	},
+}
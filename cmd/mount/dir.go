@@ -149,6 +149,7 @@ func (d *Dir) Create(ctx context.Context, req *fuse.CreateRequest, resp *fuse.Cr
 	if err != nil {
 		return nil, nil, translateError(err)
 	}
+	file.VFS().SetHandlePID(fh, int(req.Pid))
 	node = &File{file, d.fsys}
 	file.SetSys(node) // cache the FUSE node for later
 	return node, &FileHandle{fh}, err
@@ -29,11 +29,10 @@ func (f *File) Attr(ctx context.Context, a *fuse.Attr) (err error) {
 	modTime := f.File.ModTime()
 	Size := uint64(f.File.Size())
 	Blocks := (Size + 511) / 512
-	a.Gid = f.VFS().Opt.GID
-	a.Uid = f.VFS().Opt.UID
-	a.Mode = f.VFS().Opt.FilePerms
+	a.Uid, a.Gid = f.File.Owner()
+	a.Mode = f.File.Mode().Perm()
 	a.Size = Size
-	a.Atime = modTime
+	a.Atime = f.File.AccessTime()
 	a.Mtime = modTime
 	a.Ctime = modTime
 	a.Crtime = modTime
@@ -44,7 +43,8 @@ func (f *File) Attr(ctx context.Context, a *fuse.Attr) (err error) {
 // Check interface satisfied
 var _ fusefs.NodeSetattrer = (*File)(nil)
 
-// Setattr handles attribute changes from FUSE. Currently supports ModTime and Size only
+// Setattr handles attribute changes from FUSE. Currently supports
+// ModTime and Size, and Mode, Uid, Gid and Atime with --vfs-metadata
 func (f *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) (err error) {
 	defer log.Trace(f, "a=%+v", req)("err=%v", &err)
 	if !f.VFS().Opt.NoModTime {
@@ -54,6 +54,27 @@ func (f *File) Setattr(ctx context.Context, req *fuse.SetattrRequest, resp *fuse
 			err = f.File.SetModTime(time.Now())
 		}
 	}
+	if err == nil && req.Valid.Atime() {
+		err = f.File.SetAccessTime(req.Atime)
+	} else if err == nil && req.Valid.AtimeNow() {
+		err = f.File.SetAccessTime(time.Now())
+	}
+	if err == nil && req.Valid.Mode() {
+		err = f.File.Chmod(req.Mode)
+	}
+	if err == nil && (req.Valid.Uid() || req.Valid.Gid()) {
+		uid, gid := -1, -1
+		if req.Valid.Uid() {
+			uid = int(req.Uid)
+		}
+		if req.Valid.Gid() {
+			gid = int(req.Gid)
+		}
+		err = f.File.Chown(uid, gid)
+	}
+	if err != nil {
+		return translateError(err)
+	}
 	if req.Valid.Size() {
 		err = f.File.Truncate(int64(req.Size))
 	}
@@ -73,6 +94,7 @@ func (f *File) Open(ctx context.Context, req *fuse.OpenRequest, resp *fuse.OpenR
 	if err != nil {
 		return nil, translateError(err)
 	}
+	f.File.VFS().SetHandlePID(handle, int(req.Pid))
 
 	// If size unknown then use direct io to read
 	if entry := handle.Node().DirEntry(); entry != nil && entry.Size() < 0 {
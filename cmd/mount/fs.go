@@ -101,6 +101,8 @@ func translateError(err error) error {
 		return fuse.ENOSYS
 	case vfs.EINVAL:
 		return fuse.Errno(syscall.EINVAL)
+	case vfs.ENOSPC:
+		return fuse.Errno(syscall.ENOSPC)
 	}
 	return err
 }
@@ -146,6 +146,10 @@ func (f *FileHandle) Setattr(ctx context.Context, in *fuse.SetAttrIn, out *fuse.
 		out.Attr.Mtime = uint64(mtime.Unix())
 		out.Attr.Mtimensec = uint32(mtime.Nanosecond())
 	}
+	err = setAttrMetadata(f.h.Node(), in, out)
+	if err != nil {
+		return translateError(err)
+	}
 	return 0
 }
 
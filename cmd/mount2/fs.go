@@ -67,9 +67,15 @@ func setAttr(node vfs.Node, attr *fuse.Attr) {
 	Blocks := (Size + BlockSize - 1) / BlockSize
 	modTime := node.ModTime()
 	// set attributes
-	vfs := node.VFS()
-	attr.Owner.Gid = vfs.Opt.GID
-	attr.Owner.Uid = vfs.Opt.UID
+	atime := modTime
+	if mNode, ok := node.(vfs.MetadataNode); ok {
+		attr.Owner.Uid, attr.Owner.Gid = mNode.Owner()
+		atime = mNode.AccessTime()
+	} else {
+		VFS := node.VFS()
+		attr.Owner.Gid = VFS.Opt.GID
+		attr.Owner.Uid = VFS.Opt.UID
+	}
 	attr.Mode = getMode(node)
 	attr.Size = Size
 	attr.Nlink = 1
@@ -77,8 +83,8 @@ func setAttr(node vfs.Node, attr *fuse.Attr) {
 	// attr.Blksize = BlockSize // not supported in freebsd/darwin, defaults to 4k if not set
 	s := uint64(modTime.Unix())
 	ns := uint32(modTime.Nanosecond())
-	attr.Atime = s
-	attr.Atimensec = ns
+	attr.Atime = uint64(atime.Unix())
+	attr.Atimensec = uint32(atime.Nanosecond())
 	attr.Mtime = s
 	attr.Mtimensec = ns
 	attr.Ctime = s
@@ -99,6 +105,51 @@ func (f *FS) setEntryOut(node vfs.Node, out *fuse.EntryOut) {
 	out.SetAttrTimeout(f.opt.AttrTimeout)
 }
 
+// set the access time, permissions and owner from in on node and
+// fill them in on out
+//
+// Nodes which don't have these ignore them
+func setAttrMetadata(vNode vfs.Node, in *fuse.SetAttrIn, out *fuse.AttrOut) (err error) {
+	node, ok := vNode.(vfs.MetadataNode)
+	if !ok {
+		return nil
+	}
+	atime, ok := in.GetATime()
+	if ok {
+		err = node.SetAccessTime(atime)
+		if err != nil {
+			return err
+		}
+		out.Attr.Atime = uint64(atime.Unix())
+		out.Attr.Atimensec = uint32(atime.Nanosecond())
+	}
+	mode, ok := in.GetMode()
+	if ok {
+		err = node.Chmod(os.FileMode(mode).Perm())
+		if err != nil {
+			return err
+		}
+		out.Attr.Mode = getMode(node)
+	}
+	uid, uidOK := in.GetUID()
+	gid, gidOK := in.GetGID()
+	if uidOK || gidOK {
+		newUID, newGID := -1, -1
+		if uidOK {
+			newUID = int(uid)
+		}
+		if gidOK {
+			newGID = int(gid)
+		}
+		err = node.Chown(newUID, newGID)
+		if err != nil {
+			return err
+		}
+		out.Attr.Owner.Uid, out.Attr.Owner.Gid = node.Owner()
+	}
+	return nil
+}
+
 // Translate errors from mountlib into Syscall error numbers
 func translateError(err error) syscall.Errno {
 	if err == nil {
@@ -128,6 +179,8 @@ func translateError(err error) syscall.Errno {
 		return syscall.ENOSYS
 	case vfs.EINVAL:
 		return syscall.EINVAL
+	case vfs.ENOSPC:
+		return syscall.ENOSPC
 	}
 	fs.Errorf(nil, "IO error: %v", err)
 	return syscall.EIO
@@ -140,6 +140,10 @@ func (n *Node) Setattr(ctx context.Context, f fusefs.FileHandle, in *fuse.SetAtt
 		out.Attr.Mtime = uint64(mtime.Unix())
 		out.Attr.Mtimensec = uint32(mtime.Nanosecond())
 	}
+	err = setAttrMetadata(n.node, in, out)
+	if err != nil {
+		return translateError(err)
+	}
 	return 0
 }
 
@@ -155,6 +159,9 @@ func (n *Node) Open(ctx context.Context, flags uint32) (fh fusefs.FileHandle, fu
 	if err != nil {
 		return nil, 0, translateError(err)
 	}
+	if caller, ok := fuse.FromContext(ctx); ok {
+		n.node.VFS().SetHandlePID(handle, int(caller.Pid))
+	}
 	// If size unknown then use direct io to read
 	if entry := n.node.DirEntry(); entry != nil && entry.Size() < 0 {
 		fuseFlags |= fuse.FOPEN_DIRECT_IO
@@ -334,6 +341,9 @@ func (n *Node) Create(ctx context.Context, name string, flags uint32, mode uint3
 	if err != nil {
 		return nil, nil, 0, translateError(err)
 	}
+	if caller, ok := fuse.FromContext(ctx); ok {
+		file.VFS().SetHandlePID(handle, int(caller.Pid))
+	}
 	fh = newFileHandle(handle, n.fsys)
 	// FIXME
 	// fh = &fusefs.WithFlags{
@@ -240,6 +240,15 @@ Hubic) do not support the concept of empty directories, so empty
 directories will have a tendency to disappear once they fall out of
 the directory cache.
 
+Modification times set with |touch| or |utimes| are written through to
+the remote if it supports setting them (see |--no-modtime|). Changes
+of permissions and ownership with |chmod| and |chown|, and access
+times, are only stored if |--vfs-metadata| is set and the remote can
+write metadata. Otherwise they are accepted but not stored, and all
+files and directories show the permissions and owner set with
+|--file-perms|, |--dir-perms|, |--uid| and |--gid|. See the
+[VFS Metadata](#vfs-metadata) section for more info.
+
 When |rclone mount| is invoked on Unix with |--daemon| flag, the main rclone
 program will wait for the background mount to become ready or until the timeout
 specified by the |--daemon-wait| flag. On Linux it can check mount status using
@@ -15,6 +15,7 @@ import (
 	"github.com/rclone/rclone/fs/config"
 	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/rc/events"
 	"github.com/rclone/rclone/lib/atexit"
 	"github.com/rclone/rclone/lib/daemonize"
 	"github.com/rclone/rclone/vfs"
@@ -258,9 +259,19 @@ func (m *MountPoint) Mount() (daemon *os.Process, err error) {
 		return nil, fmt.Errorf("failed to mount FUSE fs: %w", err)
 	}
 	m.MountedOn = time.Now()
+	m.publish("mounted")
 	return nil, nil
 }
 
+// publish an event saying the mount has changed to status
+func (m *MountPoint) publish(status string) {
+	events.Publish(events.TypeMount, rc.Params{
+		"mountPoint": m.MountPoint,
+		"fs":         fs.ConfigString(m.Fs),
+		"status":     status,
+	})
+}
+
 // Wait for mount end
 func (m *MountPoint) Wait() error {
 	// Unmount on exit
@@ -320,5 +331,9 @@ func (m *MountPoint) Wait() error {
 
 // Unmount the specified mountpoint
 func (m *MountPoint) Unmount() (err error) {
-	return m.UnmountFn()
+	err = m.UnmountFn()
+	if err == nil {
+		m.publish("unmounted")
+	}
+	return err
 }
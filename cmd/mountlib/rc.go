@@ -153,7 +153,7 @@ Example:
 }
 
 // unMountRc allows the umount command to be run from rc
-func unMountRc(_ context.Context, in rc.Params) (out rc.Params, err error) {
+func unMountRc(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	mountPoint, err := in.GetString("mountPoint")
 	if err != nil {
 		return nil, err
@@ -161,7 +161,7 @@ func unMountRc(_ context.Context, in rc.Params) (out rc.Params, err error) {
 	mountMu.Lock()
 	defer mountMu.Unlock()
 	mountInfo, found := liveMounts[mountPoint]
-	if !found {
+	if !found || !rc.TenantOwns(rc.GetTenant(ctx), mountInfo.Fs.Name()) {
 		return nil, errors.New("mount not found")
 	}
 	if err = mountInfo.Unmount(); err != nil {
@@ -234,12 +234,15 @@ type MountInfo struct {
 }
 
 // listMountsRc returns a list of current mounts sorted by mount path
-func listMountsRc(_ context.Context, in rc.Params) (out rc.Params, err error) {
+func listMountsRc(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	tenant := rc.GetTenant(ctx)
 	mountMu.Lock()
 	defer mountMu.Unlock()
 	var keys []string
-	for key := range liveMounts {
-		keys = append(keys, key)
+	for key, m := range liveMounts {
+		if rc.TenantOwns(tenant, m.Fs.Name()) {
+			keys = append(keys, key)
+		}
 	}
 	sort.Strings(keys)
 	mountPoints := []MountInfo{}
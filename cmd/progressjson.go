@@ -0,0 +1,104 @@
+// Show the progress as JSON for machines to read
+
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+)
+
+// progressRecord is a single line of --progress-json output
+type progressRecord struct {
+	Time  time.Time              `json:"time"`
+	Final bool                   `json:"final"` // set on the last record
+	Stats map[string]interface{} `json:"stats"` // as returned by core/stats
+}
+
+// openProgressJSON returns where to write the JSON progress to -
+// stdout or a connection to the socket at addr.
+//
+// addr may be "unix:/path/to/socket" or "host:port" for TCP.
+func openProgressJSON(addr string) (io.WriteCloser, error) {
+	if addr == "" {
+		return nopCloser{os.Stdout}, nil
+	}
+	network := "tcp"
+	if strings.HasPrefix(addr, "unix:") {
+		network, addr = "unix", strings.TrimPrefix(addr, "unix:")
+	}
+	return net.Dial(network, addr)
+}
+
+// nopCloser stops stdout being closed
+type nopCloser struct {
+	io.Writer
+}
+
+// Close does nothing
+func (nopCloser) Close() error {
+	return nil
+}
+
+// startProgressJSON starts writing the progress as a JSON record per
+// line every stats interval.
+//
+// It returns a func which should be called to stop the progress which
+// writes a final record.
+func startProgressJSON(addr string) func() {
+	out, err := openProgressJSON(addr)
+	if err != nil {
+		log.Fatalf("Failed to open --progress-json-addr: %v", err)
+	}
+	enc := json.NewEncoder(out)
+	write := func(final bool) {
+		stats, err := accounting.GlobalStats().RemoteStats()
+		if err == nil {
+			err = enc.Encode(progressRecord{
+				Time:  time.Now(),
+				Final: final,
+				Stats: stats,
+			})
+		}
+		if err != nil {
+			fs.Errorf(nil, "Failed to write JSON progress: %v", err)
+		}
+	}
+
+	stopStats := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		progressInterval := defaultProgressInterval
+		if ShowStats() && *statsInterval > 0 {
+			progressInterval = *statsInterval
+		}
+		ticker := time.NewTicker(progressInterval)
+		for {
+			select {
+			case <-ticker.C:
+				write(false)
+			case <-stopStats:
+				ticker.Stop()
+				write(true)
+				if err := out.Close(); err != nil {
+					fs.Errorf(nil, "Failed to close --progress-json-addr: %v", err)
+				}
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stopStats)
+		wg.Wait()
+	}
+}
@@ -7,11 +7,13 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"strings"
 
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/serve/httplib"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/fs/fshttp"
@@ -28,8 +30,11 @@ var (
 	authUser  = ""
 	authPass  = ""
 	loopback  = false
+	openAPI   = false
 	options   []string
 	arguments []string
+	// set if url is a unix socket or named pipe
+	socketDialer func(ctx context.Context, network, address string) (net.Conn, error)
 )
 
 func init() {
@@ -41,6 +46,7 @@ func init() {
 	flags.StringVarP(cmdFlags, &authUser, "user", "", "", "Username to use to rclone remote control")
 	flags.StringVarP(cmdFlags, &authPass, "pass", "", "", "Password to use to connect to rclone remote control")
 	flags.BoolVarP(cmdFlags, &loopback, "loopback", "", false, "If set connect to this rclone instance not via HTTP")
+	flags.BoolVarP(cmdFlags, &openAPI, "openapi", "", false, "If set output an OpenAPI 3 description of the rc API")
 	flags.StringArrayVarP(cmdFlags, &options, "opt", "o", options, "Option in the form name=value or name placed in the \"opt\" array")
 	flags.StringArrayVarP(cmdFlags, &arguments, "arg", "a", arguments, "Argument placed in the \"arg\" array")
 }
@@ -53,7 +59,9 @@ var commandDefinition = &cobra.Command{
 This runs a command against a running rclone.  Use the --url flag to
 specify an non default URL to connect on.  This can be either a
 ":port" which is taken to mean "http://localhost:port" or a
-"host:port" which is taken to mean "http://host:port"
+"host:port" which is taken to mean "http://host:port". It can also be
+a unix socket like "unix:///run/rclone.sock" or on Windows a named
+pipe like "npipe:////./pipe/rclone" if rclone rcd is listening on one.
 
 A username and password can be passed in with --user and --pass.
 
@@ -97,12 +105,24 @@ rclone rc server, e.g.:
 
     rclone rc --loopback operations/about fs=/
 
+Use --openapi to output an OpenAPI 3 document describing all the
+commands, which can be used to generate clients for the API. This is
+the same as calling "core/openapi".
+
+    rclone rc --loopback --openapi > rclone-rc.json
+
 Use "rclone rc" to see a list of all possible commands.`,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(0, 1e9, command, args)
 		cmd.Run(false, false, command, func() error {
 			ctx := context.Background()
 			parseFlags()
+			if openAPI {
+				if len(args) != 0 {
+					return errors.New("can't use --openapi with a command")
+				}
+				return run(ctx, []string{"core/openapi"})
+			}
 			if len(args) == 0 {
 				return list(ctx)
 			}
@@ -117,6 +137,11 @@ func parseFlags() {
 	setAlternateFlag("rc-addr", &url)
 	setAlternateFlag("rc-user", &authUser)
 	setAlternateFlag("rc-pass", &authPass)
+	// If url is a unix socket or named pipe then connect to that
+	if socketDialer = httplib.SocketDialer(url); socketDialer != nil {
+		url = "http://localhost/"
+		return
+	}
 	// If url is just :port then fix it up
 	if strings.HasPrefix(url, ":") {
 		url = "localhost" + url
@@ -179,6 +204,13 @@ func doCall(ctx context.Context, path string, in rc.Params) (out rc.Params, err
 
 	// Do HTTP request
 	client := fshttp.NewClient(ctx)
+	if socketDialer != nil {
+		client = &http.Client{
+			Transport: &http.Transport{
+				DialContext: socketDialer,
+			},
+		}
+	}
 	url += path
 	data, err := json.Marshal(in)
 	if err != nil {
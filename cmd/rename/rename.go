@@ -0,0 +1,81 @@
+// Package rename provides the rename command.
+package rename
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/spf13/cobra"
+)
+
+var (
+	fromRegex = ""
+	to        = ""
+)
+
+func init() {
+	cmd.Root.AddCommand(commandDefinition)
+	cmdFlags := commandDefinition.Flags()
+	flags.StringVarP(cmdFlags, &fromRegex, "from-regex", "", fromRegex, "Regular expression matching the paths of the files to rename")
+	flags.StringVarP(cmdFlags, &to, "to", "", to, "Replacement for the matches, may use $1 or ${name} for submatches")
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "rename remote:path --from-regex REGEX --to REPLACEMENT",
+	Short: `Rename the files in remote:path matching a regular expression.`,
+	// Note: "|" will be replaced by backticks below
+	Long: strings.ReplaceAll(`
+Rename all the files in remote:path whose path matches the regular
+expression given with |--from-regex|. The new path of each file is
+made by replacing the matches in its path with |--to|, which may refer
+to the submatches of the regular expression as |$1| or |${name}|. The
+paths are relative to remote:path and use |/| as the separator.
+
+For example to change the extension of all the |.jpeg| files to |.jpg|
+
+    rclone rename remote:photos --from-regex '\.jpeg$' --to '.jpg'
+
+Or to move files named like |IMG_20220510_123456.jpg| into a
+directory for each year and month
+
+    rclone rename remote:photos --from-regex '^IMG_(\d{4})(\d{2})' --to '$1/$2/IMG_$1$2'
+
+The files are renamed with server-side moves where the remote supports
+them, so this is much quicker than calling |rclone moveto| for each
+file. Files are never renamed over existing files or onto the same new
+name as each other - these are reported as errors and left alone.
+
+Use |--dry-run| to see what would be renamed without renaming anything.
+
+The syntax of the regular expressions is the same as for the
+|{{regexp}}| form of filters - see the [filtering docs](/filtering/).
+`, "|", "`"),
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsDir(args)
+		cmd.Run(true, false, command, func() error {
+			if fromRegex == "" {
+				return errors.New("need --from-regex")
+			}
+			from, err := regexp.Compile(fromRegex)
+			if err != nil {
+				return fmt.Errorf("bad --from-regex: %w", err)
+			}
+			ctx := context.Background()
+			renames, err := operations.Rename(ctx, f, from, to)
+			if fs.GetConfig(ctx).DryRun {
+				for _, rename := range renames {
+					fmt.Printf("%s -> %s\n", rename.From, rename.To)
+				}
+			}
+			return err
+		})
+	},
+}
@@ -0,0 +1,442 @@
+// Package accesslog writes access logs and keeps Prometheus metrics
+// of the requests made to the serve commands
+package accesslog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/spf13/pflag"
+)
+
+// Help contains text describing the access log and metrics
+var Help = strings.Replace(`
+### Access logs and metrics
+
+Use |--access-log| to write a line to a file for every request made to
+the server, or |--access-log -| to write them to standard output. By
+default the lines are in the Common Log Format used by most web
+servers
+
+    127.0.0.1 - user [02/Jan/2006:15:04:05 -0700] "GET /file.txt HTTP/1.1" 200 1234
+
+so the usual tools can be used to analyse them. Use
+|--access-log-format json| to write a JSON object per line instead
+with the fields time, server, remote, user, method, path,
+destination (for renames and copies), protocol, status, bytes_sent,
+bytes_received and duration (in seconds).
+
+The servers which don't use HTTP log each file operation with the
+name of the operation as the method, e.g. |RETR| or |STOR| for FTP and
+|Get| or |Put| for SFTP. The status is 200 if the operation worked or
+an HTTP style status code describing the error if not, e.g. 404 if
+the file wasn't found.
+
+Use |--metrics-addr| to serve Prometheus metrics on a separate
+address, e.g. |--metrics-addr localhost:9090|, at the path |/metrics|.
+These include the number of requests, the time taken to answer them
+and the bytes sent and received, labelled by the server and the
+method. The rclone transfer metrics are served there too.
+`, "|", "`", -1)
+
+// Options for the access log and metrics
+type Options struct {
+	AccessLog       string // file to write the access log to, "-" for stdout
+	AccessLogFormat string // "common" or "json"
+	MetricsAddr     string // address to serve the Prometheus metrics on if set
+}
+
+// DefaultOpt is the default values used for Options
+var DefaultOpt = Options{
+	AccessLogFormat: "common",
+}
+
+// Opt is options set by command line flags
+var Opt = DefaultOpt
+
+// AddFlags adds the flags for the access log and metrics to the command
+func AddFlags(flagSet *pflag.FlagSet) {
+	flags.StringVarP(flagSet, &Opt.AccessLog, "access-log", "", Opt.AccessLog, "File to write an access log line to for each request, - for stdout")
+	flags.StringVarP(flagSet, &Opt.AccessLogFormat, "access-log-format", "", Opt.AccessLogFormat, "Format of the access log: common or json")
+	flags.StringVarP(flagSet, &Opt.MetricsAddr, "metrics-addr", "", Opt.MetricsAddr, "IPaddress:Port or :Port to serve Prometheus metrics on")
+}
+
+// Entry is the record of one request in the access log
+type Entry struct {
+	Time          time.Time `json:"time"`
+	Server        string    `json:"server"` // name of the server, e.g. "webdav"
+	Remote        string    `json:"remote"` // address of the client
+	User          string    `json:"user,omitempty"`
+	Method        string    `json:"method"` // HTTP method or name of the operation
+	Path          string    `json:"path"`
+	Destination   string    `json:"destination,omitempty"` // new path for copies and renames
+	Protocol      string    `json:"protocol"`
+	Status        int       `json:"status"`
+	BytesSent     int64     `json:"bytes_sent"`
+	BytesReceived int64     `json:"bytes_received"`
+	Duration      float64   `json:"duration"` // seconds taken to answer
+}
+
+// metrics are the Prometheus metrics for the servers
+type metrics struct {
+	requests      *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	bytesSent     *prometheus.CounterVec
+	bytesReceived *prometheus.CounterVec
+}
+
+// newMetrics makes the metrics for the servers
+func newMetrics(namespace string) *metrics {
+	return &metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "serve",
+			Name:      "requests_total",
+			Help:      "Number of requests by server, method and status code",
+		}, []string{"server", "method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "serve",
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to answer requests by server and method",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		}, []string{"server", "method"}),
+		bytesSent: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "serve",
+			Name:      "sent_bytes_total",
+			Help:      "Bytes sent to clients by server and method",
+		}, []string{"server", "method"}),
+		bytesReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "serve",
+			Name:      "received_bytes_total",
+			Help:      "Bytes received from clients by server and method",
+		}, []string{"server", "method"}),
+	}
+}
+
+// Collectors returns the metrics as collectors for registration
+func (m *metrics) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.requests,
+		m.duration,
+		m.bytesSent,
+		m.bytesReceived,
+	}
+}
+
+// observe records entry in the metrics labelled with method
+func (m *metrics) observe(e *Entry, method string) {
+	m.requests.WithLabelValues(e.Server, method, fmt.Sprint(e.Status)).Inc()
+	m.duration.WithLabelValues(e.Server, method).Observe(e.Duration)
+	m.bytesSent.WithLabelValues(e.Server, method).Add(float64(e.BytesSent))
+	m.bytesReceived.WithLabelValues(e.Server, method).Add(float64(e.BytesReceived))
+}
+
+var serveMetrics *metrics
+
+func init() {
+	serveMetrics = newMetrics("rclone")
+	for _, c := range serveMetrics.Collectors() {
+		prometheus.MustRegister(c)
+	}
+}
+
+// Logger writes the access log and records the metrics for a server
+type Logger struct {
+	server  string // name of the server used in the entries
+	json    bool   // write JSON rather than common log format
+	mu      sync.Mutex
+	out     io.Writer    // where to write the log, nil for none
+	file    *os.File     // the log file if we opened one
+	metrics *http.Server // the metrics server if running
+}
+
+// New makes a Logger for the server called server from opt and starts
+// the metrics server if required.
+func New(server string, opt *Options) (*Logger, error) {
+	l := &Logger{
+		server: server,
+	}
+	switch opt.AccessLogFormat {
+	case "common", "":
+	case "json":
+		l.json = true
+	default:
+		return nil, fmt.Errorf("unknown --access-log-format %q - must be common or json", opt.AccessLogFormat)
+	}
+	switch opt.AccessLog {
+	case "":
+	case "-":
+		l.out = os.Stdout
+	default:
+		f, err := os.OpenFile(opt.AccessLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open access log: %w", err)
+		}
+		l.out, l.file = f, f
+	}
+	if opt.MetricsAddr != "" {
+		listener, err := net.Listen("tcp", opt.MetricsAddr)
+		if err != nil {
+			_ = l.Close()
+			return nil, fmt.Errorf("failed to serve metrics: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		srv := &http.Server{Handler: mux}
+		l.metrics = srv
+		fs.Logf(nil, "Serving Prometheus metrics on http://%s/metrics", listener.Addr())
+		go func() {
+			err := srv.Serve(listener)
+			if err != nil && err != http.ErrServerClosed {
+				fs.Errorf(nil, "Metrics server failed: %v", err)
+			}
+		}()
+	}
+	return l, nil
+}
+
+// Close closes the access log and stops the metrics server
+func (l *Logger) Close() (err error) {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.metrics != nil {
+		err = l.metrics.Close()
+		l.metrics = nil
+	}
+	if l.file != nil {
+		if closeErr := l.file.Close(); err == nil {
+			err = closeErr
+		}
+		l.file = nil
+	}
+	l.out = nil
+	return err
+}
+
+// commonTimeFormat is the time format used in the common log format
+const commonTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// orDash returns s or "-" if it is empty as the common log format
+// needs
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// commonLine formats e in the common log format
+func commonLine(e *Entry) string {
+	remote := e.Remote
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	bytes := "-"
+	if e.BytesSent > 0 {
+		bytes = fmt.Sprint(e.BytesSent)
+	}
+	return fmt.Sprintf("%s - %s [%s] %q %d %s\n",
+		orDash(remote),
+		orDash(strings.Replace(e.User, " ", "%20", -1)),
+		e.Time.Format(commonTimeFormat),
+		e.Method+" "+e.Path+" "+e.Protocol,
+		e.Status,
+		bytes,
+	)
+}
+
+// Log records e in the metrics and writes it to the access log
+func (l *Logger) Log(e *Entry) {
+	l.log(e, e.Method)
+}
+
+// log records e in the metrics labelled with method and writes it to
+// the access log
+func (l *Logger) log(e *Entry, method string) {
+	if l == nil {
+		return
+	}
+	e.Server = l.server
+	serveMetrics.observe(e, method)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.out == nil {
+		return
+	}
+	var line []byte
+	if l.json {
+		var err error
+		line, err = json.Marshal(e)
+		if err != nil {
+			fs.Errorf(nil, "Failed to marshal access log entry: %v", err)
+			return
+		}
+		line = append(line, '\n')
+	} else {
+		line = []byte(commonLine(e))
+	}
+	_, err := l.out.Write(line)
+	if err != nil {
+		fs.Errorf(nil, "Failed to write access log: %v", err)
+	}
+}
+
+// Operation starts logging an operation on path by user from remote
+// for a server which doesn't use HTTP. Call the returned function
+// when it has finished with the bytes transferred and the error.
+func (l *Logger) Operation(remote, user, method, path, protocol string) func(sent, received int64, err error) {
+	return l.Rename(remote, user, method, path, "", protocol)
+}
+
+// Rename is like Operation but for operations which make a new path
+// such as renames
+func (l *Logger) Rename(remote, user, method, path, destination, protocol string) func(sent, received int64, err error) {
+	start := time.Now()
+	return func(sent, received int64, err error) {
+		l.Log(&Entry{
+			Time:          start,
+			Remote:        remote,
+			User:          user,
+			Method:        method,
+			Path:          path,
+			Destination:   destination,
+			Protocol:      protocol,
+			Status:        StatusFromError(err),
+			BytesSent:     sent,
+			BytesReceived: received,
+			Duration:      time.Since(start).Seconds(),
+		})
+	}
+}
+
+// StatusFromError returns an HTTP style status code for the result of
+// an operation which returned err
+func StatusFromError(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, os.ErrNotExist):
+		return http.StatusNotFound
+	case errors.Is(err, os.ErrPermission):
+		return http.StatusForbidden
+	case errors.Is(err, os.ErrExist):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// knownMethods are the HTTP methods recorded in the metrics - others
+// are recorded as "OTHER" so clients can't make an unlimited number
+// of labels
+var knownMethods = map[string]struct{}{
+	"GET": {}, "HEAD": {}, "POST": {}, "PUT": {}, "DELETE": {}, "OPTIONS": {}, "PATCH": {},
+	"PROPFIND": {}, "PROPPATCH": {}, "MKCOL": {}, "COPY": {}, "MOVE": {}, "LOCK": {}, "UNLOCK": {},
+	"SUBSCRIBE": {}, "UNSUBSCRIBE": {},
+}
+
+// responseWriter records the status and the bytes written to an
+// http.ResponseWriter
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status and passes it on
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write counts the bytes and passes them on
+func (w *responseWriter) Write(p []byte) (n int, err error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err = w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush passes on the Flush if the ResponseWriter supports it
+func (w *responseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// countingReader counts the bytes read from the request body
+type countingReader struct {
+	io.ReadCloser
+	bytes int64
+}
+
+// Read counts the bytes and passes them on
+func (r *countingReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Handler returns middleware which logs each request to next
+func (l *Logger) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := &responseWriter{ResponseWriter: w}
+		var body *countingReader
+		if r.Body != nil {
+			body = &countingReader{ReadCloser: r.Body}
+			r.Body = body
+		}
+		// Read these now in case the handler changes the request
+		path, method, destination := r.URL.RequestURI(), r.Method, r.Header.Get("Destination")
+		user, _, _ := r.BasicAuth()
+		defer func() {
+			e := &Entry{
+				Time:        start,
+				Remote:      r.RemoteAddr,
+				User:        user,
+				Method:      method,
+				Path:        path,
+				Destination: destination,
+				Protocol:    r.Proto,
+				Status:      rw.status,
+				BytesSent:   rw.bytes,
+				Duration:    time.Since(start).Seconds(),
+			}
+			if e.Status == 0 {
+				e.Status = http.StatusOK
+			}
+			if body != nil {
+				e.BytesReceived = body.bytes
+			}
+			metricMethod := e.Method
+			if _, ok := knownMethods[metricMethod]; !ok {
+				metricMethod = "OTHER"
+			}
+			l.log(e, metricMethod)
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
@@ -0,0 +1,170 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommonLine(t *testing.T) {
+	e := &Entry{
+		Time:      time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60)),
+		Remote:    "127.0.0.1:1234",
+		User:      "frank",
+		Method:    "GET",
+		Path:      "/apache_pb.gif",
+		Protocol:  "HTTP/1.0",
+		Status:    200,
+		BytesSent: 2326,
+	}
+	assert.Equal(t, `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326`+"\n", commonLine(e))
+
+	e.Remote, e.User, e.BytesSent = "", "", 0
+	assert.Equal(t, `- - - [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 -`+"\n", commonLine(e))
+}
+
+func TestStatusFromError(t *testing.T) {
+	assert.Equal(t, http.StatusOK, StatusFromError(nil))
+	assert.Equal(t, http.StatusNotFound, StatusFromError(fmt.Errorf("open: %w", os.ErrNotExist)))
+	assert.Equal(t, http.StatusForbidden, StatusFromError(os.ErrPermission))
+	assert.Equal(t, http.StatusConflict, StatusFromError(os.ErrExist))
+	assert.Equal(t, http.StatusInternalServerError, StatusFromError(errors.New("potato")))
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := New("test", &Options{AccessLogFormat: "potato"})
+	assert.EqualError(t, err, `unknown --access-log-format "potato" - must be common or json`)
+	_, err = New("test", &Options{AccessLog: filepath.Join(t.TempDir(), "missing", "access.log")})
+	assert.Error(t, err)
+}
+
+func TestHandler(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "access.log")
+	l, err := New("testhandler", &Options{AccessLog: logPath, AccessLogFormat: "json"})
+	require.NoError(t, err)
+
+	handler := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method == "PUT" {
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		_, _ = w.Write([]byte("hello " + string(body)))
+	}))
+
+	req := httptest.NewRequest("GET", "/dir/file.txt?x=1", nil)
+	req.SetBasicAuth("user", "pass")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, "hello ", w.Body.String())
+
+	req = httptest.NewRequest("PUT", "/upload.txt", strings.NewReader("contents"))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("MOVE", "/a.txt", nil)
+	req.Header.Set("Destination", "/b.txt")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest("BREW", "/pot", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.NoError(t, l.Close())
+
+	buf, err := ioutil.ReadFile(logPath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	require.Len(t, lines, 4)
+
+	var e Entry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &e))
+	assert.Equal(t, "testhandler", e.Server)
+	assert.Equal(t, "user", e.User)
+	assert.Equal(t, "GET", e.Method)
+	assert.Equal(t, "/dir/file.txt?x=1", e.Path)
+	assert.Equal(t, "HTTP/1.1", e.Protocol)
+	assert.Equal(t, 200, e.Status)
+	assert.Equal(t, int64(6), e.BytesSent)
+	assert.Equal(t, int64(0), e.BytesReceived)
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &e))
+	assert.Equal(t, "PUT", e.Method)
+	assert.Equal(t, 201, e.Status)
+	assert.Equal(t, int64(0), e.BytesSent)
+	assert.Equal(t, int64(8), e.BytesReceived)
+
+	e = Entry{}
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &e))
+	assert.Equal(t, "MOVE", e.Method)
+	assert.Equal(t, "/a.txt", e.Path)
+	assert.Equal(t, "/b.txt", e.Destination)
+
+	// Unknown methods are logged but not used as metric labels
+	require.NoError(t, json.Unmarshal([]byte(lines[3]), &e))
+	assert.Equal(t, "BREW", e.Method)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(serveMetrics.requests.WithLabelValues("testhandler", "GET", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(serveMetrics.requests.WithLabelValues("testhandler", "PUT", "201")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(serveMetrics.requests.WithLabelValues("testhandler", "OTHER", "200")))
+	assert.Equal(t, 8.0, testutil.ToFloat64(serveMetrics.bytesReceived.WithLabelValues("testhandler", "PUT")))
+	assert.Equal(t, 6.0, testutil.ToFloat64(serveMetrics.bytesSent.WithLabelValues("testhandler", "GET")))
+}
+
+func TestOperation(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "access.log")
+	l, err := New("testop", &Options{AccessLog: logPath, AccessLogFormat: "common"})
+	require.NoError(t, err)
+
+	l.Operation("10.0.0.1:2121", "bob", "RETR", "/file.txt", "FTP")(100, 0, nil)
+	l.Operation("10.0.0.1:2121", "bob", "DELE", "/missing.txt", "FTP")(0, 0, os.ErrNotExist)
+	require.NoError(t, l.Close())
+
+	// Logging after closing just records the metrics
+	l.Operation("10.0.0.1:2121", "bob", "RETR", "/file.txt", "FTP")(100, 0, nil)
+
+	buf, err := ioutil.ReadFile(logPath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	require.Len(t, lines, 2)
+	assert.Regexp(t, `^10\.0\.0\.1 - bob \[.*\] "RETR /file.txt FTP" 200 100$`, lines[0])
+	assert.Regexp(t, `^10\.0\.0\.1 - bob \[.*\] "DELE /missing.txt FTP" 404 -$`, lines[1])
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(serveMetrics.requests.WithLabelValues("testop", "RETR", "200")))
+	assert.Equal(t, 200.0, testutil.ToFloat64(serveMetrics.bytesSent.WithLabelValues("testop", "RETR")))
+}
+
+func TestMetricsServer(t *testing.T) {
+	l, err := New("testmetrics", &Options{MetricsAddr: "localhost:0"})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, l.Close())
+	}()
+	l.Operation("", "", "Get", "/file.txt", "SFTP")(0, 0, nil)
+
+	srv := httptest.NewServer(l.metrics.Handler)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/metrics")
+	require.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `rclone_serve_requests_total{code="200",method="Get",server="testmetrics"} 1`)
+
+	// A nil Logger does nothing
+	var nilLogger *Logger
+	nilLogger.Log(&Entry{})
+	assert.NoError(t, nilLogger.Close())
+}
@@ -90,6 +90,20 @@ func (cds *contentDirectoryService) cdsObjectToUpnpavObject(cdsObject object, fi
 		Size: uint64(fileInfo.Size()),
 	})
 
+	// Offer a transcoded version after the original so renderers
+	// which can play the original prefer it.
+	if cds.transcoder.wanted(fileInfo.Name()) {
+		item.Res = append(item.Res, upnpav.Resource{
+			URL: (&url.URL{
+				Scheme:   "http",
+				Host:     host,
+				Path:     path.Join(resPath, cdsObject.Path),
+				RawQuery: transcodeQuery + "=1",
+			}).String(),
+			ProtocolInfo: cds.transcoder.protocolInfo(),
+		})
+	}
+
 	for _, resource := range resources {
 		subtitleURL := (&url.URL{
 			Scheme: "http",
@@ -2,6 +2,7 @@ package dlna
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/anacrolix/dms/upnp"
 )
@@ -17,10 +18,20 @@ func (cms *connectionManagerService) Handle(action string, argsXML []byte, r *ht
 	switch action {
 	case "GetProtocolInfo":
 		return map[string]string{
-			"Source": defaultProtocolInfo,
+			"Source": cms.sourceProtocolInfo(),
 			"Sink":   "",
 		}, nil
 	default:
 		return nil, upnp.InvalidActionError
 	}
 }
+
+// Returns the protocols the server can send, including the output of
+// the transcoder if it isn't one of the defaults.
+func (cms *connectionManagerService) sourceProtocolInfo() string {
+	t := cms.transcoder
+	if t == nil || strings.Contains(defaultProtocolInfo, ":"+t.mime+":") {
+		return defaultProtocolInfo
+	}
+	return defaultProtocolInfo + ",http-get:*:" + t.mime + ":*"
+}
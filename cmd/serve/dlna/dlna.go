@@ -17,6 +17,7 @@ import (
 	"github.com/anacrolix/dms/ssdp"
 	"github.com/anacrolix/dms/upnp"
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/serve/accesslog"
 	"github.com/rclone/rclone/cmd/serve/dlna/data"
 	"github.com/rclone/rclone/cmd/serve/dlna/dlnaflags"
 	"github.com/rclone/rclone/fs"
@@ -28,6 +29,7 @@ import (
 func init() {
 	dlnaflags.AddFlags(Command.Flags())
 	vfsflags.AddFlags(Command.Flags())
+	accesslog.AddFlags(Command.Flags())
 }
 
 // Command definition for cobra.
@@ -40,16 +42,20 @@ and play audio/video from it. VLC is also supported. Service discovery uses UDP
 packets (SSDP) and will thus only work on LANs.
 
 Rclone will list all files present in the remote, without filtering based on media formats or
-file extensions. Additionally, there is no media transcoding support. This means that some
-players might show files that they are not able to play back correctly.
+file extensions. Media is served as it is unless transcoding is set up as described
+below. This means that some players might show files that they are not able to play back
+correctly.
 
-` + dlnaflags.Help + vfs.Help,
+` + dlnaflags.Help + accesslog.Help + vfs.Help,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(1, 1, command, args)
 		f := cmd.NewFsSrc(args)
 
 		cmd.Run(false, false, command, func() error {
-			s := newServer(f, &dlnaflags.Opt)
+			s, err := newServer(f, &dlnaflags.Opt)
+			if err != nil {
+				return err
+			}
 			if err := s.Serve(); err != nil {
 				return err
 			}
@@ -86,11 +92,26 @@ type server struct {
 	// Time interval between SSPD announces
 	AnnounceInterval time.Duration
 
+	// Converts media the renderer can't play, nil if not configured
+	transcoder *transcoder
+
+	// Writes the access log and records the metrics
+	accessLog *accesslog.Logger
+
 	f   fs.Fs
 	vfs *vfs.VFS
 }
 
-func newServer(f fs.Fs, opt *dlnaflags.Options) *server {
+func newServer(f fs.Fs, opt *dlnaflags.Options) (*server, error) {
+	tc, err := newTranscoder(opt)
+	if err != nil {
+		return nil, err
+	}
+	accessLog, err := accesslog.New("dlna", &accesslog.Opt)
+	if err != nil {
+		return nil, err
+	}
+
 	friendlyName := opt.FriendlyName
 	if friendlyName == "" {
 		friendlyName = makeDefaultFriendlyName()
@@ -104,6 +125,9 @@ func newServer(f fs.Fs, opt *dlnaflags.Options) *server {
 
 		httpListenAddr: opt.ListenAddr,
 
+		transcoder: tc,
+		accessLog:  accessLog,
+
 		f:   f,
 		vfs: vfs.New(f, &vfsflags.Opt),
 	}
@@ -134,9 +158,9 @@ func newServer(f fs.Fs, opt *dlnaflags.Options) *server {
 	r.Handle("/static/", http.StripPrefix("/static/",
 		withHeader("Cache-Control", "public, max-age=86400",
 			http.FileServer(data.Assets))))
-	s.handler = logging(withHeader("Server", serverField, r))
+	s.handler = accessLog.Handler(logging(withHeader("Server", serverField, r)))
 
-	return s
+	return s, nil
 }
 
 // UPnPService is the interface for the SOAP service.
@@ -232,6 +256,16 @@ func (s *server) resourceHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if r.URL.Query().Get(transcodeQuery) != "" {
+		file, ok := node.(*vfs.File)
+		if !ok || !s.transcoder.wanted(node.Name()) {
+			http.NotFound(w, r)
+			return
+		}
+		s.transcodeHandler(w, r, file)
+		return
+	}
+
 	w.Header().Set("Content-Length", strconv.FormatInt(node.Size(), 10))
 
 	// add some DLNA specific headers
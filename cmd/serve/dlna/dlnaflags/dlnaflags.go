@@ -1,6 +1,7 @@
 package dlnaflags
 
 import (
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/fs/rc"
 	"github.com/spf13/pflag"
@@ -19,20 +20,59 @@ default "rclone (hostname)".
 
 Use ` + "`--log-trace` in conjunction with `-vv`" + ` to enable additional debug
 logging of all UPNP traffic.
+
+### Transcoding
+
+Many renderers, TVs in particular, can only play a few media formats.
+Use ` + "`--transcode-ext`" + ` to give a comma separated list of file
+extensions, e.g. ` + "`--transcode-ext mkv,avi,webm`" + `, which rclone
+should offer to convert into a format the renderer can play.
+
+Items with those extensions are advertised with an extra transcoded
+resource alongside the original file. Renderers which can't play the
+original will then pick the transcoded one, which rclone makes by
+running the command given with ` + "`--transcode-cmd`" + `. The file is
+fed to the command on its standard input and whatever the command
+writes to its standard output is streamed to the renderer. The
+arguments are separated by spaces and can be quoted with double quotes.
+
+The default command uses ` + "`ffmpeg`" + ` to remux the audio and video
+into an MPEG transport stream without re-encoding them. This is quick
+and works for files which only need a different container, such as
+most MKV files. Files which the renderer can't decode at all need a
+command which re-encodes them, for example
+
+    --transcode-cmd "ffmpeg -loglevel error -i pipe:0 -c:v libx264 -c:a aac -f mpegts pipe:1"
+
+Use ` + "`--transcode-mime`" + ` to set the MIME type of the output of the
+command and ` + "`--transcode-profile`" + ` to give the DLNA profile name
+(DLNA.ORG_PN) to advertise it with, if the renderer needs one.
+
+Transcoded streams can't be seeked and their length isn't known in
+advance. Formats which need to read the end of the file first, like
+MP4 files which aren't arranged for streaming, can't be transcoded
+from standard input.
 `
 
 // Options is the type for DLNA serving options.
 type Options struct {
-	ListenAddr   string
-	FriendlyName string
-	LogTrace     bool
+	ListenAddr       string
+	FriendlyName     string
+	LogTrace         bool
+	TranscodeExt     fs.CommaSepList
+	TranscodeCmd     fs.SpaceSepList
+	TranscodeMime    string
+	TranscodeProfile string
 }
 
 // DefaultOpt contains the defaults options for DLNA serving.
 var DefaultOpt = Options{
-	ListenAddr:   ":7879",
-	FriendlyName: "",
-	LogTrace:     false,
+	ListenAddr:       ":7879",
+	FriendlyName:     "",
+	LogTrace:         false,
+	TranscodeCmd:     fs.SpaceSepList{"ffmpeg", "-loglevel", "error", "-i", "pipe:0", "-map", "0:v?", "-map", "0:a?", "-c", "copy", "-f", "mpegts", "pipe:1"},
+	TranscodeMime:    "video/mpeg",
+	TranscodeProfile: "",
 }
 
 // Opt contains the options for DLNA serving.
@@ -45,6 +85,10 @@ func addFlagsPrefix(flagSet *pflag.FlagSet, prefix string, Opt *Options) {
 	flags.StringVarP(flagSet, &Opt.ListenAddr, prefix+"addr", "", Opt.ListenAddr, "The ip:port or :port to bind the DLNA http server to")
 	flags.StringVarP(flagSet, &Opt.FriendlyName, prefix+"name", "", Opt.FriendlyName, "Name of DLNA server")
 	flags.BoolVarP(flagSet, &Opt.LogTrace, prefix+"log-trace", "", Opt.LogTrace, "Enable trace logging of SOAP traffic")
+	flags.FVarP(flagSet, &Opt.TranscodeExt, prefix+"transcode-ext", "", "Comma separated list of file extensions to offer transcoded")
+	flags.FVarP(flagSet, &Opt.TranscodeCmd, prefix+"transcode-cmd", "", "Command to transcode media from stdin to stdout")
+	flags.StringVarP(flagSet, &Opt.TranscodeMime, prefix+"transcode-mime", "", Opt.TranscodeMime, "MIME type of the output of --transcode-cmd")
+	flags.StringVarP(flagSet, &Opt.TranscodeProfile, prefix+"transcode-profile", "", Opt.TranscodeProfile, "DLNA profile name of the output of --transcode-cmd")
 }
 
 // AddFlags add the command line flags for DLNA serving.
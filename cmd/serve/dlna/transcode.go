@@ -0,0 +1,157 @@
+package dlna
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	dms_dlna "github.com/anacrolix/dms/dlna"
+	"github.com/rclone/rclone/cmd/serve/dlna/dlnaflags"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+)
+
+// Query parameter on resource URLs asking for the transcoded stream.
+const transcodeQuery = "transcode"
+
+// Limit on how much of the transcoder's stderr is kept for logging.
+const maxTranscodeStderr = 4096
+
+// transcoder runs an external command to convert media which
+// renderers can't play into a format which they can.
+type transcoder struct {
+	exts    map[string]struct{} // lower case extensions without the "."
+	cmd     []string
+	mime    string
+	profile string
+}
+
+// Make a transcoder from the options, or nil if transcoding is off.
+func newTranscoder(opt *dlnaflags.Options) (*transcoder, error) {
+	if len(opt.TranscodeExt) == 0 {
+		return nil, nil
+	}
+	if len(opt.TranscodeCmd) == 0 || opt.TranscodeCmd[0] == "" {
+		return nil, errors.New("need --transcode-cmd to use --transcode-ext")
+	}
+	if opt.TranscodeMime == "" {
+		return nil, errors.New("need --transcode-mime to use --transcode-ext")
+	}
+	t := &transcoder{
+		exts:    make(map[string]struct{}, len(opt.TranscodeExt)),
+		cmd:     opt.TranscodeCmd,
+		mime:    opt.TranscodeMime,
+		profile: opt.TranscodeProfile,
+	}
+	for _, ext := range opt.TranscodeExt {
+		ext = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(ext)), ".")
+		if ext != "" {
+			t.exts[ext] = struct{}{}
+		}
+	}
+	return t, nil
+}
+
+// Returns whether the file with this name should be offered transcoded.
+func (t *transcoder) wanted(name string) bool {
+	if t == nil {
+		return false
+	}
+	_, ext := splitExt(strings.ToLower(name))
+	_, found := t.exts[strings.TrimPrefix(ext, ".")]
+	return found
+}
+
+// The contentFeatures.dlna.org of the transcoded stream. It can't be
+// seeked as its length isn't known in advance.
+func (t *transcoder) contentFeatures() string {
+	return dms_dlna.ContentFeatures{
+		ProfileName: t.profile,
+		Transcoded:  true,
+	}.String()
+}
+
+// The protocolInfo advertising the transcoded stream.
+func (t *transcoder) protocolInfo() string {
+	return fmt.Sprintf("http-get:*:%s:%s", t.mime, t.contentFeatures())
+}
+
+// Serves the media file transcoded by running the command.
+func (s *server) transcodeHandler(w http.ResponseWriter, r *http.Request, file *vfs.File) {
+	t := s.transcoder
+	w.Header().Set("Content-Type", t.mime)
+	if r.Header.Get("getContentFeatures.dlna.org") != "" {
+		w.Header().Set("contentFeatures.dlna.org", t.contentFeatures())
+	}
+	w.Header().Set("transferMode.dlna.org", "Streaming")
+	if r.Method == "HEAD" {
+		return
+	}
+
+	in, err := file.Open(os.O_RDONLY)
+	if err != nil {
+		serveError(file, w, "Could not open resource", err)
+		return
+	}
+	defer fs.CheckClose(in, &err)
+
+	// The command is killed if the renderer goes away
+	stderr := &limitedBuffer{limit: maxTranscodeStderr}
+	cmd := exec.CommandContext(r.Context(), t.cmd[0], t.cmd[1:]...)
+	out := &countingWriter{w: w}
+	cmd.Stdin = in
+	cmd.Stdout = out
+	cmd.Stderr = stderr
+	fs.Debugf(file, "Transcoding with %q", t.cmd)
+	err = cmd.Run()
+	if err != nil {
+		if r.Context().Err() != nil {
+			fs.Debugf(file, "Transcoding stopped: renderer disconnected")
+			return
+		}
+		err = fmt.Errorf("%w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+		if out.n == 0 {
+			serveError(file, w, "Transcoding failed", err)
+		} else {
+			// Too late to tell the renderer
+			fs.Errorf(file, "Transcoding failed: %v", err)
+		}
+	}
+}
+
+// countingWriter is an io.Writer which counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write implements io.Writer
+func (c *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// limitedBuffer is an io.Writer which keeps the first limit bytes
+// written to it and discards the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+// Write implements io.Writer
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); room > 0 {
+		if len(p) > room {
+			_, _ = b.Buffer.Write(p[:room])
+		} else {
+			_, _ = b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
@@ -0,0 +1,126 @@
+package dlna
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/rclone/rclone/cmd/serve/dlna/dlnaflags"
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTranscoder(t *testing.T) {
+	opt := dlnaflags.DefaultOpt
+	tr, err := newTranscoder(&opt)
+	require.NoError(t, err)
+	assert.Nil(t, tr)
+	assert.False(t, tr.wanted("video.mkv"))
+
+	opt.TranscodeExt = fs.CommaSepList{"mkv", " .AVI"}
+	tr, err = newTranscoder(&opt)
+	require.NoError(t, err)
+	assert.True(t, tr.wanted("video.mkv"))
+	assert.True(t, tr.wanted("Video.MKV"))
+	assert.True(t, tr.wanted("video.avi"))
+	assert.False(t, tr.wanted("video.mp4"))
+	assert.False(t, tr.wanted("mkv"))
+	assert.Contains(t, tr.protocolInfo(), "http-get:*:video/mpeg:")
+	assert.Contains(t, tr.protocolInfo(), "DLNA.ORG_CI=1")
+
+	opt.TranscodeProfile = "MPEG_TS_SD_EU_ISO"
+	tr, err = newTranscoder(&opt)
+	require.NoError(t, err)
+	assert.Contains(t, tr.protocolInfo(), "DLNA.ORG_PN=MPEG_TS_SD_EU_ISO")
+
+	opt.TranscodeCmd = nil
+	_, err = newTranscoder(&opt)
+	assert.EqualError(t, err, "need --transcode-cmd to use --transcode-ext")
+
+	opt.TranscodeCmd = fs.SpaceSepList{"cat"}
+	opt.TranscodeMime = ""
+	_, err = newTranscoder(&opt)
+	assert.EqualError(t, err, "need --transcode-mime to use --transcode-ext")
+}
+
+// Start a server which transcodes .mp4 files with the command
+func startTranscodeServer(t *testing.T, command ...string) *httptest.Server {
+	f, err := fs.NewFs(context.Background(), "testdata/files")
+	require.NoError(t, err)
+	opt := dlnaflags.DefaultOpt
+	opt.TranscodeExt = fs.CommaSepList{"mp4"}
+	opt.TranscodeCmd = command
+	s, err := newServer(f, &opt)
+	require.NoError(t, err)
+	ts := httptest.NewServer(s.handler)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestTranscode(t *testing.T) {
+	if _, err := exec.LookPath("cat"); err != nil {
+		t.Skip("need cat to test transcoding")
+	}
+	ts := startTranscodeServer(t, "cat")
+
+	// The transcoded resource is advertised after the original
+	req, err := http.NewRequest("POST", ts.URL+serviceControlURL, strings.NewReader(`
+<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"
+            s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+    <s:Body>
+        <u:Browse xmlns:u="urn:schemas-upnp-org:service:ContentDirectory:1">
+            <ObjectID>0</ObjectID>
+            <BrowseFlag>BrowseDirectChildren</BrowseFlag>
+            <Filter>*</Filter>
+            <StartingIndex>0</StartingIndex>
+            <RequestedCount>0</RequestedCount>
+            <SortCriteria></SortCriteria>
+        </u:Browse>
+    </s:Body>
+</s:Envelope>`))
+	require.NoError(t, err)
+	req.Header.Set("SOAPACTION", `"urn:schemas-upnp-org:service:ContentDirectory:1#Browse"`)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	original := strings.Index(string(body), "/r/video.mp4&lt;")
+	transcoded := strings.Index(string(body), "/r/video.mp4?"+transcodeQuery+"=1")
+	assert.True(t, original >= 0 && transcoded > original, string(body))
+	assert.Contains(t, string(body), "video/mpeg:DLNA.ORG_OP=00;DLNA.ORG_CI=1")
+	assert.NotContains(t, string(body), "/r/small_jpeg.jpg?")
+
+	resp, err = http.Get(ts.URL + resPath + "video.mp4?" + transcodeQuery + "=1")
+	require.NoError(t, err)
+	body, err = ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "video/mpeg", resp.Header.Get("Content-Type"))
+	golden, err := ioutil.ReadFile("testdata/files/video.mp4")
+	require.NoError(t, err)
+	assert.Equal(t, golden, body)
+
+	// Only files with the extensions are transcoded
+	resp, err = http.Get(ts.URL + resPath + "small_jpeg.jpg?" + transcodeQuery + "=1")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestTranscodeFailed(t *testing.T) {
+	ts := startTranscodeServer(t, "rclone-transcoder-which-does-not-exist")
+
+	resp, err := http.Get(ts.URL + resPath + "video.mp4?" + transcodeQuery + "=1")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+}
@@ -250,6 +250,10 @@ func getVFSOption(vfsOpt *vfscommon.Options, opt rc.Params, key string) (ok bool
 		err = getFVarP(&vfsOpt.ReadAhead, opt, key)
 	case "vfs-used-is-size":
 		vfsOpt.UsedIsSize, err = opt.GetBool(key)
+	case "vfs-bwlimit":
+		err = getFVarP(&vfsOpt.BwLimit, opt, key)
+	case "vfs-quota":
+		err = getFVarP(&vfsOpt.Quota, opt, key)
 
 	// unprefixed vfs options
 	case "no-modtime":
@@ -80,11 +80,40 @@ func newVolume(ctx context.Context, name string, volOpt VolOpts, drv *Driver) (*
 // getInfo returns short digest about volume
 func (vol *Volume) getInfo() *VolInfo {
 	vol.prepareState()
+	status := rc.Params{"Mounts": vol.Mounts}
+	vol.addVFSStatus(status)
 	return &VolInfo{
 		Name:       vol.Name,
 		CreatedAt:  vol.CreatedAt.Format(time.RFC3339),
 		Mountpoint: vol.MountPoint,
-		Status:     rc.Params{"Mounts": vol.Mounts},
+		Status:     status,
+	}
+}
+
+// addVFSStatus adds the state of the VFS to status if the volume is
+// mounted so it shows up in `docker volume inspect`
+func (vol *Volume) addVFSStatus(status rc.Params) {
+	VFS := vol.mnt.VFS
+	if VFS == nil {
+		return
+	}
+	if cache := VFS.Stats()["diskCache"]; cache != nil {
+		stats := cache.(rc.Params)
+		status["CacheBytesUsed"] = stats["bytesUsed"]
+		status["CacheFiles"] = stats["files"]
+		status["DirtyBytes"] = stats["bytesDirty"]
+		status["DirtyFiles"] = stats["dirtyFiles"]
+		status["UploadsInProgress"] = stats["uploadsInProgress"]
+		status["UploadsQueued"] = stats["uploadsQueued"]
+	}
+	if VFS.Opt.BwLimit > 0 {
+		status["BwLimit"] = VFS.Opt.BwLimit.String()
+	}
+	if VFS.Opt.Quota >= 0 {
+		total, used, free := VFS.Statfs()
+		status["Quota"] = total
+		status["QuotaUsed"] = used
+		status["QuotaFree"] = free
 	}
 }
 
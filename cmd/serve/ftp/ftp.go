@@ -7,6 +7,7 @@ package ftp
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -14,10 +15,12 @@ import (
 	"os"
 	"os/user"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/serve/accesslog"
 	"github.com/rclone/rclone/cmd/serve/proxy"
 	"github.com/rclone/rclone/cmd/serve/proxy/proxyflags"
 	"github.com/rclone/rclone/fs"
@@ -26,6 +29,7 @@ import (
 	"github.com/rclone/rclone/fs/log"
 	"github.com/rclone/rclone/fs/rc"
 	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
 	"github.com/rclone/rclone/vfs/vfsflags"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -42,6 +46,8 @@ type Options struct {
 	BasicPass    string // password for BasicUser
 	TLSCert      string // TLS PEM key (concatenation of certificate and CA certificate)
 	TLSKey       string // TLS PEM Private key
+	ExplicitTLS  bool   // use explicit FTPS (AUTH TLS) rather than implicit TLS
+	ForceTLS     bool   // refuse clients which don't use TLS
 }
 
 // DefaultOpt is the default values used for Options
@@ -66,11 +72,14 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.StringVarP(flagSet, &Opt.BasicPass, "pass", "", Opt.BasicPass, "Password for authentication (empty value allow every password)")
 	flags.StringVarP(flagSet, &Opt.TLSCert, "cert", "", Opt.TLSCert, "TLS PEM key (concatenation of certificate and CA certificate)")
 	flags.StringVarP(flagSet, &Opt.TLSKey, "key", "", Opt.TLSKey, "TLS PEM Private key")
+	flags.BoolVarP(flagSet, &Opt.ExplicitTLS, "explicit-tls", "", Opt.ExplicitTLS, "Use explicit FTPS (AUTH TLS) instead of implicit TLS")
+	flags.BoolVarP(flagSet, &Opt.ForceTLS, "force-tls", "", Opt.ForceTLS, "Refuse clients which don't upgrade to TLS with explicit FTPS")
 }
 
 func init() {
 	vfsflags.AddFlags(Command.Flags())
 	proxyflags.AddFlags(Command.Flags())
+	accesslog.AddFlags(Command.Flags())
 	AddFlags(Command.Flags())
 }
 
@@ -98,7 +107,34 @@ then using Authentication is advised - see the next section for info.
 By default this will serve files without needing a login.
 
 You can set a single username and password with the --user and --pass flags.
-` + vfs.Help + proxy.Help,
+
+#### TLS
+
+Many FTP clients refuse to send passwords over an unencrypted
+connection, so it is recommended to use TLS (FTPS) by supplying a
+certificate and private key with --cert and --key.
+
+By default the server uses implicit TLS, where clients must start TLS
+as soon as they connect. This is normally done on port 990. Use
+--explicit-tls to use explicit FTPS instead, where clients connect
+in plain text on the normal FTP port then upgrade the connection with
+the AUTH TLS command. Most modern clients use explicit FTPS.
+
+With explicit FTPS clients may choose not to upgrade the connection.
+Use --force-tls to refuse to log in clients which don't.
+
+#### Passive mode and NAT
+
+Data is transferred over separate connections which the client opens
+to a port chosen from the range given by --passive-port, which
+defaults to 30000-32000. These ports must be open in any firewall in
+front of the server.
+
+If the server is behind NAT, the address it tells clients to connect
+to for data connections will be its private address. Use --public-ip
+to give the public IPv4 address of the server instead and forward the
+passive port range to the server.
+` + accesslog.Help + vfs.Help + proxy.Help + proxy.UsersHelp,
 	Run: func(command *cobra.Command, args []string) {
 		var f fs.Fs
 		if proxyflags.Opt.AuthProxy == "" {
@@ -108,7 +144,7 @@ You can set a single username and password with the --user and --pass flags.
 			cmd.CheckArgs(0, 0, command, args)
 		}
 		cmd.Run(false, false, command, func() error {
-			s, err := newServer(context.Background(), f, &Opt)
+			s, err := newServer(context.Background(), f, &Opt, &vfsflags.Opt)
 			if err != nil {
 				return err
 			}
@@ -126,10 +162,11 @@ type server struct {
 	vfs    *vfs.VFS
 	proxy  *proxy.Proxy
 	useTLS bool
+	log    *accesslog.Logger
 }
 
 // Make a new FTP to serve the remote
-func newServer(ctx context.Context, f fs.Fs, opt *Options) (*server, error) {
+func newServer(ctx context.Context, f fs.Fs, opt *Options, vfsOpt *vfscommon.Options) (*server, error) {
 	host, port, err := net.SplitHostPort(opt.ListenAddr)
 	if err != nil {
 		return nil, errors.New("Failed to parse host:port")
@@ -139,17 +176,49 @@ func newServer(ctx context.Context, f fs.Fs, opt *Options) (*server, error) {
 		return nil, errors.New("Failed to parse host:port")
 	}
 
+	if (opt.TLSCert == "") != (opt.TLSKey == "") {
+		return nil, errors.New("need both --cert and --key to use TLS")
+	}
+	useTLS := opt.TLSKey != ""
+	if useTLS {
+		// Check the certificate now rather than on the first connection
+		_, err = tls.LoadX509KeyPair(opt.TLSCert, opt.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+	} else if opt.ExplicitTLS || opt.ForceTLS {
+		return nil, errors.New("need --cert and --key to use --explicit-tls or --force-tls")
+	}
+	err = checkPassivePorts(opt.PassivePorts)
+	if err != nil {
+		return nil, err
+	}
+	if opt.PublicIP != "" {
+		if ip := net.ParseIP(opt.PublicIP); ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("--public-ip %q must be an IPv4 address", opt.PublicIP)
+		}
+	}
+
 	s := &server{
-		f:   f,
-		ctx: ctx,
-		opt: *opt,
+		f:      f,
+		ctx:    ctx,
+		opt:    *opt,
+		useTLS: useTLS,
 	}
-	if proxyflags.Opt.AuthProxy != "" {
+	s.log, err = accesslog.New("ftp", &accesslog.Opt)
+	if err != nil {
+		return nil, err
+	}
+	if proxyflags.Opt.UsersFile != "" {
+		s.proxy, err = proxy.NewUsers(ctx, &proxyflags.Opt, f)
+		if err != nil {
+			return nil, err
+		}
+	} else if proxyflags.Opt.AuthProxy != "" {
 		s.proxy = proxy.New(ctx, &proxyflags.Opt)
 	} else {
-		s.vfs = vfs.New(f, &vfsflags.Opt)
+		s.vfs = vfs.New(f, vfsOpt)
 	}
-	s.useTLS = s.opt.TLSKey != ""
 
 	ftpopt := &ftp.ServerOpts{
 		Name:           "Rclone FTP Server",
@@ -164,12 +233,29 @@ func newServer(ctx context.Context, f fs.Fs, opt *Options) (*server, error) {
 		TLS:            s.useTLS,
 		CertFile:       s.opt.TLSCert,
 		KeyFile:        s.opt.TLSKey,
+		ExplicitFTPS:   s.opt.ExplicitTLS,
+		ForceTLS:       s.opt.ForceTLS,
 		//TODO implement a maximum of https://godoc.org/goftp.io/server#ServerOpts
 	}
 	s.srv = ftp.NewServer(ftpopt)
 	return s, nil
 }
 
+// checkPassivePorts checks the passive port range is in the form
+// "low-high" or is a single port
+func checkPassivePorts(ports string) error {
+	lowPort, highPort := ports, ports
+	if i := strings.IndexByte(ports, '-'); i >= 0 {
+		lowPort, highPort = ports[:i], ports[i+1:]
+	}
+	low, lowErr := strconv.Atoi(lowPort)
+	high, highErr := strconv.Atoi(highPort)
+	if lowErr != nil || highErr != nil || low <= 0 || high > 65535 || low > high {
+		return fmt.Errorf("--passive-port %q must be a port range like 30000-32000", ports)
+	}
+	return nil
+}
+
 // serve runs the ftp server
 func (s *server) serve() error {
 	fs.Logf(s.f, "Serving FTP on %s", s.srv.Hostname+":"+strconv.Itoa(s.srv.Port))
@@ -232,9 +318,18 @@ func (s *server) NewDriver() (ftp.Driver, error) {
 type Driver struct {
 	s    *server
 	vfs  *vfs.VFS
+	user string // the user which logged in
 	lock sync.Mutex
 }
 
+// logCommand starts logging the FTP command on path to the access log
+//
+// The FTP library doesn't tell the driver the address of the client
+// so it is left out.
+func (d *Driver) logCommand(command, path string) func(sent, received int64, err error) {
+	return d.s.log.Operation("", d.user, command, path, "FTP")
+}
+
 // CheckPasswd handle auth based on configuration
 func (d *Driver) CheckPasswd(user, pass string) (ok bool, err error) {
 	s := d.s
@@ -253,6 +348,7 @@ func (d *Driver) CheckPasswd(user, pass string) (ok bool, err error) {
 			return false, nil
 		}
 	}
+	d.user = user
 	return true, nil
 }
 
@@ -286,6 +382,8 @@ func (d *Driver) ListDir(path string, callback func(ftp.FileInfo) error) (err er
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	defer log.Trace(path, "")("err = %v", &err)
+	done := d.logCommand("LIST", path)
+	defer func() { done(0, 0, err) }()
 	node, err := d.vfs.Stat(path)
 	if err == vfs.ENOENT {
 		return errors.New("Directory not found")
@@ -322,6 +420,8 @@ func (d *Driver) DeleteDir(path string) (err error) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	defer log.Trace(path, "")("err = %v", &err)
+	done := d.logCommand("RMD", path)
+	defer func() { done(0, 0, err) }()
 	node, err := d.vfs.Stat(path)
 	if err != nil {
 		return err
@@ -341,6 +441,8 @@ func (d *Driver) DeleteFile(path string) (err error) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	defer log.Trace(path, "")("err = %v", &err)
+	done := d.logCommand("DELE", path)
+	defer func() { done(0, 0, err) }()
 	node, err := d.vfs.Stat(path)
 	if err != nil {
 		return err
@@ -360,6 +462,8 @@ func (d *Driver) Rename(oldName, newName string) (err error) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	defer log.Trace(oldName, "newName=%q", newName)("err = %v", &err)
+	done := d.s.log.Rename("", d.user, "RNTO", oldName, newName, "FTP")
+	defer func() { done(0, 0, err) }()
 	return d.vfs.Rename(oldName, newName)
 }
 
@@ -368,6 +472,8 @@ func (d *Driver) MakeDir(path string) (err error) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	defer log.Trace(path, "")("err = %v", &err)
+	done := d.logCommand("MKD", path)
+	defer func() { done(0, 0, err) }()
 	dir, leaf, err := d.vfs.StatParent(path)
 	if err != nil {
 		return err
@@ -381,6 +487,12 @@ func (d *Driver) GetFile(path string, offset int64) (size int64, fr io.ReadClose
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	defer log.Trace(path, "offset=%v", offset)("err = %v", &err)
+	done := d.logCommand("RETR", path)
+	defer func() {
+		if err != nil {
+			done(0, 0, err)
+		}
+	}()
 	node, err := d.vfs.Stat(path)
 	if err == vfs.ENOENT {
 		fs.Infof(path, "File not found")
@@ -405,7 +517,37 @@ func (d *Driver) GetFile(path string, offset int64) (size int64, fr io.ReadClose
 	tr := accounting.GlobalStats().NewTransferRemoteSize(path, node.Size())
 	defer tr.Done(d.s.ctx, nil)
 
-	return node.Size(), handle, nil
+	return node.Size(), &loggedReader{ReadCloser: handle, done: done}, nil
+}
+
+// loggedReader counts the bytes read from a file being downloaded
+// and logs them to the access log when it is closed
+type loggedReader struct {
+	io.ReadCloser
+	done  func(sent, received int64, err error)
+	bytes int64
+	err   error // first read error which isn't io.EOF
+}
+
+// Read counts the bytes read
+func (r *loggedReader) Read(p []byte) (n int, err error) {
+	n, err = r.ReadCloser.Read(p)
+	r.bytes += int64(n)
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
+
+// Close closes the file and logs the download
+func (r *loggedReader) Close() error {
+	err := r.ReadCloser.Close()
+	logErr := r.err
+	if logErr == nil {
+		logErr = err
+	}
+	r.done(r.bytes, 0, logErr)
+	return err
 }
 
 //PutFile upload a file
@@ -413,6 +555,12 @@ func (d *Driver) PutFile(path string, data io.Reader, appendData bool) (n int64,
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	defer log.Trace(path, "append=%v", appendData)("err = %v", &err)
+	command := "STOR"
+	if appendData {
+		command = "APPE"
+	}
+	done := d.logCommand(command, path)
+	defer func() { done(0, n, err) }()
 	var isExist bool
 	node, err := d.vfs.Stat(path)
 	if err == nil {
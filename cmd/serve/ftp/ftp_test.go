@@ -17,7 +17,9 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/configmap"
 	"github.com/rclone/rclone/fs/config/obscure"
+	"github.com/rclone/rclone/vfs/vfsflags"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	ftp "goftp.io/server/core"
 )
 
@@ -40,7 +42,7 @@ func TestFTP(t *testing.T) {
 		opt.BasicUser = testUSER
 		opt.BasicPass = testPASS
 
-		w, err := newServer(context.Background(), f, &opt)
+		w, err := newServer(context.Background(), f, &opt, &vfsflags.Opt)
 		assert.NoError(t, err)
 
 		quit := make(chan struct{})
@@ -70,3 +72,36 @@ func TestFTP(t *testing.T) {
 
 	servetest.Run(t, "ftp", start)
 }
+
+func TestNewServerOptions(t *testing.T) {
+	f, err := fs.NewFs(context.Background(), t.TempDir())
+	require.NoError(t, err)
+	for _, test := range []struct {
+		name    string
+		modify  func(opt *Options)
+		wantErr string
+	}{
+		{"Default", func(opt *Options) {}, ""},
+		{"SinglePassivePort", func(opt *Options) { opt.PassivePorts = "30000" }, ""},
+		{"BadPassivePorts", func(opt *Options) { opt.PassivePorts = "32000-30000" }, "--passive-port"},
+		{"BadPassivePortNumber", func(opt *Options) { opt.PassivePorts = "1-70000" }, "--passive-port"},
+		{"PublicIP", func(opt *Options) { opt.PublicIP = "203.0.113.1" }, ""},
+		{"BadPublicIP", func(opt *Options) { opt.PublicIP = "2001:db8::1" }, "--public-ip"},
+		{"CertWithoutKey", func(opt *Options) { opt.TLSCert = "cert.pem" }, "need both --cert and --key"},
+		{"MissingCert", func(opt *Options) { opt.TLSCert, opt.TLSKey = "notfound.pem", "notfound.key" }, "failed to load TLS certificate"},
+		{"ExplicitWithoutCert", func(opt *Options) { opt.ExplicitTLS = true }, "need --cert and --key"},
+		{"ForceWithoutCert", func(opt *Options) { opt.ForceTLS = true }, "need --cert and --key"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opt := DefaultOpt
+			test.modify(&opt)
+			_, err := newServer(context.Background(), f, &opt, &vfsflags.Opt)
+			if test.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), test.wantErr)
+			}
+		})
+	}
+}
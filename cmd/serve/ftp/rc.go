@@ -0,0 +1,90 @@
+//go:build !plan9
+// +build !plan9
+
+package ftp
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/rclone/rclone/cmd/serve/servelib"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/vfs/vfsflags"
+	ftp "goftp.io/server/core"
+)
+
+func init() {
+	servelib.AddRc("ftp", startRc)
+}
+
+// rcServer is an FTP server started by serve/start
+type rcServer struct {
+	*server
+	addr string        // address the server is listening on
+	done chan struct{} // closed when the server has stopped
+}
+
+// startRc starts an FTP server for serve/start
+func startRc(ctx context.Context, f fs.Fs, in rc.Params) (servelib.Server, error) {
+	opt := Opt
+	err := in.GetStructMissingOK("opt", &opt)
+	if err != nil {
+		return nil, err
+	}
+	vfsOpt := vfsflags.Opt
+	err = in.GetStructMissingOK("vfsOpt", &vfsOpt)
+	if err != nil {
+		return nil, err
+	}
+
+	// The FTP library opens the listener itself so check the
+	// address can be used now and find a free port if asked for
+	// port 0 so it can be returned.
+	ln, err := net.Listen("tcp", opt.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %q: %w", opt.ListenAddr, err)
+	}
+	opt.ListenAddr = ln.Addr().String()
+	err = ln.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := newServer(ctx, f, &opt, &vfsOpt)
+	if err != nil {
+		return nil, err
+	}
+	r := &rcServer{
+		server: s,
+		addr:   opt.ListenAddr,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(r.done)
+		err := s.serve()
+		if err != nil && err != ftp.ErrServerClosed {
+			fs.Errorf(f, "Error serving FTP on %s: %v", r.addr, err)
+		}
+	}()
+	return r, nil
+}
+
+// Addr returns the address the server is listening on
+func (r *rcServer) Addr() string {
+	return r.addr
+}
+
+// Shutdown stops the server
+func (r *rcServer) Shutdown() error {
+	err := r.close()
+	<-r.done
+	if r.vfs != nil {
+		r.vfs.Shutdown()
+	}
+	if logErr := r.log.Close(); err == nil {
+		err = logErr
+	}
+	return err
+}
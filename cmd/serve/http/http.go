@@ -14,13 +14,17 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/serve/accesslog"
 	"github.com/rclone/rclone/cmd/serve/http/data"
+	"github.com/rclone/rclone/cmd/serve/multiremote"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
 	httplib "github.com/rclone/rclone/lib/http"
 	"github.com/rclone/rclone/lib/http/auth"
+	"github.com/rclone/rclone/lib/http/oidc"
 	"github.com/rclone/rclone/lib/http/serve"
 	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
 	"github.com/rclone/rclone/vfs/vfsflags"
 	"github.com/spf13/cobra"
 )
@@ -41,11 +45,13 @@ func init() {
 	httplib.AddFlags(Command.Flags())
 	auth.AddFlags(Command.Flags())
 	vfsflags.AddFlags(Command.Flags())
+	multiremote.AddFlags(Command.Flags())
+	accesslog.AddFlags(Command.Flags())
 }
 
 // Command definition for cobra
 var Command = &cobra.Command{
-	Use:   "http remote:path",
+	Use:   "http remote:path [remote:path...]",
 	Short: `Serve the remote over HTTP.`,
 	Long: `rclone serve http implements a basic web server to serve the remote
 over HTTP.  This can be viewed in a web browser or you can make a
@@ -58,12 +64,31 @@ The server will log errors.  Use -v to see access logs.
 
 --bwlimit will be respected for file transfers.  Use --stats to
 control the stats printing.
-` + httplib.Help + data.Help + auth.Help + vfs.Help,
+
+Files are served with ETag and Last-Modified headers so clients can
+use conditional requests (If-None-Match, If-Modified-Since, If-Match,
+If-Unmodified-Since and If-Range) to avoid downloading files they
+already have. The ETag is made from the size and modification time of
+the file.
+
+Directory listings are served as HTML by default. Add ?format=json to
+the URL or send an "Accept: application/json" header to get a JSON
+listing instead, for example
+
+    curl 'http://localhost:8080/dir/?format=json'
+
+which returns the Path of the directory and a list of Entries, each
+with the Name, URL, IsDir, Size and ModTime of the entry.
+` + httplib.Help + data.Help + auth.Help + oidc.Help + accesslog.Help + multiremote.Help + vfs.Help,
 	Run: func(command *cobra.Command, args []string) {
-		cmd.CheckArgs(1, 1, command, args)
-		f := cmd.NewFsSrc(args)
+		f := multiremote.NewFs(command, args)
 		cmd.Run(false, true, command, func() error {
-			s := newServer(f, Opt.Template)
+			s := newServer(f, Opt.Template, &vfsflags.Opt)
+			var err error
+			s.accessLog, err = accesslog.New("http", &accesslog.Opt)
+			if err != nil {
+				return err
+			}
 			router, err := httplib.Router()
 			if err != nil {
 				return err
@@ -80,23 +105,29 @@ type server struct {
 	f            fs.Fs
 	vfs          *vfs.VFS
 	HTMLTemplate *template.Template // HTML template for web interface
+	accessLog    *accesslog.Logger  // access log and metrics if set
+	authOpt      auth.Options       // authentication to use
 }
 
-func newServer(f fs.Fs, templatePath string) *server {
+func newServer(f fs.Fs, templatePath string, vfsOpt *vfscommon.Options) *server {
 	htmlTemplate, templateErr := data.GetTemplate(templatePath)
 	if templateErr != nil {
 		log.Fatalf(templateErr.Error())
 	}
 	s := &server{
 		f:            f,
-		vfs:          vfs.New(f, &vfsflags.Opt),
+		vfs:          vfs.New(f, vfsOpt),
 		HTMLTemplate: htmlTemplate,
+		authOpt:      auth.Opt,
 	}
 	return s
 }
 
 func (s *server) Bind(router chi.Router) {
-	if m := auth.Auth(auth.Opt); m != nil {
+	if s.accessLog != nil {
+		router.Use(s.accessLog.Handler)
+	}
+	if m := auth.Auth(s.authOpt); m != nil {
 		router.Use(m)
 	}
 	router.Use(
@@ -143,7 +174,7 @@ func (s *server) serveDir(w http.ResponseWriter, r *http.Request, dirRemote stri
 	// Make the entries for display
 	directory := serve.NewDirectory(dirRemote, s.HTMLTemplate)
 	for _, node := range dirEntries {
-		if vfsflags.Opt.NoModTime {
+		if s.vfs.Opt.NoModTime {
 			directory.AddHTMLEntry(node.Path(), node.IsDir(), node.Size(), time.Time{})
 		} else {
 			directory.AddHTMLEntry(node.Path(), node.IsDir(), node.Size(), node.ModTime().UTC())
@@ -182,6 +213,16 @@ func (s *server) serveFile(w http.ResponseWriter, r *http.Request, remote string
 	}
 	obj := entry.(fs.Object)
 	file := node.(*vfs.File)
+	modTime := file.ModTime()
+	etag := serve.ETag(node.Size(), modTime)
+
+	// Set the validators and check them against any conditional
+	// headers for both GET and HEAD
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	if serve.CheckPreconditions(w, r, etag, modTime) {
+		return
+	}
 
 	// Set content length if we know how long the object is
 	knownSize := obj.Size() >= 0
@@ -197,9 +238,6 @@ func (s *server) serveFile(w http.ResponseWriter, r *http.Request, remote string
 		w.Header().Set("Content-Type", mimeType)
 	}
 
-	// Set the Last-Modified header to the timestamp
-	w.Header().Set("Last-Modified", file.ModTime().UTC().Format(http.TimeFormat))
-
 	// If HEAD no need to read the object since we have set the headers
 	if r.Method == "HEAD" {
 		return
@@ -225,10 +263,11 @@ func (s *server) serveFile(w http.ResponseWriter, r *http.Request, remote string
 
 	// Serve the file
 	if knownSize {
-		http.ServeContent(w, r, remote, node.ModTime(), in)
+		// This deals with Range and If-Range using the ETag set above
+		http.ServeContent(w, r, remote, modTime, in)
 	} else {
 		// http.ServeContent can't serve unknown length files
-		if rangeRequest := r.Header.Get("Range"); rangeRequest != "" {
+		if rangeRequest := r.Header.Get("Range"); rangeRequest != "" && serve.RangeApplies(r, etag, modTime) {
 			http.Error(w, "Can't use Range: on files of unknown length", http.StatusRequestedRangeNotSatisfiable)
 			return
 		}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"encoding/json"
 	"flag"
 	"io/ioutil"
 	"net/http"
@@ -14,6 +15,7 @@ import (
 	"github.com/rclone/rclone/fs/config/configfile"
 	"github.com/rclone/rclone/fs/filter"
 	httplib "github.com/rclone/rclone/lib/http"
+	"github.com/rclone/rclone/vfs/vfsflags"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -32,7 +34,7 @@ const (
 func startServer(t *testing.T, f fs.Fs) {
 	opt := httplib.DefaultOpt
 	opt.ListenAddr = testBindAddress
-	httpServer = newServer(f, testTemplate)
+	httpServer = newServer(f, testTemplate, &vfsflags.Opt)
 	router, err := httplib.Router()
 	if err != nil {
 		t.Fatal(err.Error())
@@ -229,6 +231,69 @@ func TestGET(t *testing.T) {
 	}
 }
 
+func TestConditional(t *testing.T) {
+	do := func(method, url string, headers ...string) *http.Response {
+		req, err := http.NewRequest(method, testURL+url, nil)
+		require.NoError(t, err)
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_, err = ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp
+	}
+
+	resp := do("GET", datedObject)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get("ETag")
+	assert.NotEqual(t, "", etag)
+	lastModified := expectedTime.Format(http.TimeFormat)
+
+	for _, method := range []string{"GET", "HEAD"} {
+		resp = do(method, datedObject, "If-None-Match", etag)
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode, method)
+		assert.Equal(t, etag, resp.Header.Get("ETag"), method)
+		resp = do(method, datedObject, "If-Modified-Since", lastModified)
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode, method)
+		resp = do(method, datedObject, "If-Match", `"potato"`)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode, method)
+		resp = do(method, datedObject, "If-None-Match", `"potato"`)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, method)
+	}
+
+	// Ranges only apply if If-Range matches
+	resp = do("GET", datedObject, "Range", "bytes=2-5", "If-Range", etag)
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	resp = do("GET", datedObject, "Range", "bytes=2-5", "If-Range", `"potato"`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestDirJSON(t *testing.T) {
+	resp, err := http.Get(testURL + "three/?format=json")
+	require.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+	var listing struct {
+		Path    string
+		Entries []struct {
+			Name  string
+			IsDir bool
+			Size  int64
+		}
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&listing))
+	assert.Equal(t, "/three", listing.Path)
+	require.Len(t, listing.Entries, 2)
+	assert.Equal(t, "a.txt", listing.Entries[0].Name)
+	assert.False(t, listing.Entries[0].IsDir)
+}
+
 func TestFinalise(t *testing.T) {
 	_ = httplib.Shutdown()
 }
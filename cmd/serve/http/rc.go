@@ -0,0 +1,73 @@
+package http
+
+import (
+	"context"
+
+	"github.com/rclone/rclone/cmd/serve/accesslog"
+	"github.com/rclone/rclone/cmd/serve/servelib"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	httplib "github.com/rclone/rclone/lib/http"
+	"github.com/rclone/rclone/lib/http/auth"
+	"github.com/rclone/rclone/vfs/vfsflags"
+)
+
+func init() {
+	servelib.AddRc("http", startRc)
+}
+
+// rcServer is an http server started by serve/start
+type rcServer struct {
+	*server
+	srv httplib.Server
+}
+
+// startRc starts an http server for serve/start
+func startRc(ctx context.Context, f fs.Fs, in rc.Params) (servelib.Server, error) {
+	opt := httplib.GetOptions()
+	err := in.GetStructMissingOK("opt", &opt)
+	if err != nil {
+		return nil, err
+	}
+	authOpt := auth.Opt
+	err = in.GetStructMissingOK("authOpt", &authOpt)
+	if err != nil {
+		return nil, err
+	}
+	vfsOpt := vfsflags.Opt
+	err = in.GetStructMissingOK("vfsOpt", &vfsOpt)
+	if err != nil {
+		return nil, err
+	}
+	s := newServer(f, Opt.Template, &vfsOpt)
+	s.authOpt = authOpt
+	s.accessLog, err = accesslog.New("http", &accesslog.Opt)
+	if err != nil {
+		s.vfs.Shutdown()
+		return nil, err
+	}
+	srv, err := httplib.Listen(opt)
+	if err != nil {
+		s.vfs.Shutdown()
+		_ = s.accessLog.Close()
+		return nil, err
+	}
+	s.Bind(srv.Router())
+	srv.Serve()
+	return &rcServer{server: s, srv: srv}, nil
+}
+
+// Addr returns the address the server is listening on
+func (r *rcServer) Addr() string {
+	return r.srv.Addr().String()
+}
+
+// Shutdown stops the server
+func (r *rcServer) Shutdown() error {
+	err := r.srv.Shutdown()
+	r.vfs.Shutdown()
+	if logErr := r.accessLog.Close(); err == nil {
+		err = logErr
+	}
+	return err
+}
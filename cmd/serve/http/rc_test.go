@@ -0,0 +1,59 @@
+package http
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRc(t *testing.T) {
+	ctx := context.Background()
+	start := rc.Calls.Get("serve/start")
+	require.NotNil(t, start)
+	stop := rc.Calls.Get("serve/stop")
+	require.NotNil(t, stop)
+
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello"), 0600))
+
+	out, err := start.Fn(ctx, rc.Params{
+		"type":    "http",
+		"fs":      dir,
+		"opt":     rc.Params{"ListenAddr": "localhost:0"},
+		"authOpt": rc.Params{"BasicUser": "user", "BasicPass": "pass"},
+	})
+	require.NoError(t, err)
+	id, err := out.GetString("id")
+	require.NoError(t, err)
+	addr, err := out.GetString("addr")
+	require.NoError(t, err)
+	url := "http://" + addr + "/file.txt"
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, err := http.NewRequest("GET", url, nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("user", "pass")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", string(body))
+
+	_, err = stop.Fn(ctx, rc.Params{"id": id})
+	require.NoError(t, err)
+	_, err = http.Get(url)
+	assert.Error(t, err)
+}
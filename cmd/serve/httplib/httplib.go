@@ -20,6 +20,10 @@ import (
 	auth "github.com/abbot/go-http-auth"
 	"github.com/rclone/rclone/cmd/serve/http/data"
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/lib/http/certs"
+	"github.com/rclone/rclone/lib/http/oidc"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 // Globals
@@ -38,6 +42,13 @@ IPs.  By default it only listens on localhost.  You can use port
 If you set --addr to listen on a public or LAN accessible IP address
 then using Authentication is advised - see the next section for info.
 
+--addr may also be a unix domain socket, e.g. --addr
+unix:///run/rclone.sock, or on Windows a named pipe, e.g. --addr
+npipe:////./pipe/rclone, so local programs can connect without a TCP
+port being opened. The socket is made so only the user running rclone
+can connect to it. Named pipes allow the user, administrators and the
+system to connect.
+
 --server-read-timeout and --server-write-timeout can be used to
 control the timeouts on the server.  Note that this is the total time
 for a transfer.
@@ -108,7 +119,14 @@ supply --client-ca also.
 of that with the CA certificate.  --key should be the PEM encoded
 private key and --client-ca should be the PEM encoded client
 certificate authority certificate.
-`
+
+When --client-ca is in use every client must present a certificate
+signed by it, so the certificate is used to identify the user. The
+user name is the common name (CN) of the certificate, or the first
+DNS name, email address or URI in its subject alternative names
+(SAN) if it doesn't have one. If --user or --htpasswd are also set
+then clients must supply a password as well.
+` + certs.Help
 
 // Options contains options for the http Server
 type Options struct {
@@ -125,6 +143,9 @@ type Options struct {
 	BasicUser          string        // single username for basic auth if not using Htpasswd
 	BasicPass          string        // password for BasicUser
 	Auth               AuthFn        `json:"-"` // custom Auth (not set by command line flags)
+	TokenAuth          TokenAuthFn   `json:"-"` // Bearer token auth used as well as the above (not set by command line flags)
+	OIDC               oidc.Options  // OpenID Connect authentication used instead of user and password if Issuer is set
+	Certs              certs.Options // reloading of SslCert and SslKey or getting certificates with ACME
 	Template           string        // User specified template
 }
 
@@ -134,6 +155,13 @@ type Options struct {
 // If a non nil value is returned then it is added to the context under the key
 type AuthFn func(user, pass string) (value interface{}, err error)
 
+// TokenAuthFn if used will be used to authenticate requests with an
+// "Authorization: Bearer token" header instead of basic auth. If an
+// error is returned then the request is not authenticated.
+//
+// If a non nil value is returned then it is added to the context under ContextAuthKey
+type TokenAuthFn func(token string) (value interface{}, err error)
+
 // DefaultOpt is the default values used for Options
 var DefaultOpt = Options{
 	ListenAddr:         "localhost:8080",
@@ -141,6 +169,8 @@ var DefaultOpt = Options{
 	ServerReadTimeout:  1 * time.Hour,
 	ServerWriteTimeout: 1 * time.Hour,
 	MaxHeaderBytes:     4096,
+	OIDC:               oidc.DefaultOpt,
+	Certs:              certs.DefaultOpt,
 }
 
 // Server contains info about the running http server
@@ -151,9 +181,12 @@ type Server struct {
 	waitChan        chan struct{} // for waiting on the listener to close
 	httpServer      *http.Server
 	basicPassHashed string
-	useSSL          bool               // if server is configured for SSL/TLS
-	usingAuth       bool               // set if authentication is configured
-	HTMLTemplate    *template.Template // HTML template for web interface
+	authenticator   *auth.BasicAuth     // checks user and password if set
+	oidc            *oidc.Authenticator // checks OpenID Connect users if set
+	certs           *certs.Certs        // supplies the certificates if using SSL/TLS
+	useSSL          bool                // if server is configured for SSL/TLS
+	usingAuth       bool                // set if authentication is configured
+	HTMLTemplate    *template.Template  // HTML template for web interface
 }
 
 type contextUserType struct{}
@@ -195,6 +228,36 @@ func parseAuthorization(r *http.Request) (user, pass string, ok bool) {
 	return
 }
 
+// parseBearer parses a bearer token from the Authorization header
+// it returns a boolean as to whether one was found
+func parseBearer(r *http.Request) (token string, ok bool) {
+	s := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(s) == 2 && s[0] == "Bearer" && s[1] != "" {
+		return s[1], true
+	}
+	return "", false
+}
+
+// certUser returns the user name from the verified client
+// certificate of r or "" if there isn't one
+func certUser(r *http.Request) string {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	switch {
+	case cert.Subject.CommonName != "":
+		return cert.Subject.CommonName
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	}
+	return ""
+}
+
 // NewServer creates an http server.  The opt can be nil in which case
 // the default options will be used.
 func NewServer(handler http.Handler, opt *Options) *Server {
@@ -211,7 +274,6 @@ func NewServer(handler http.Handler, opt *Options) *Server {
 
 	// Use htpasswd if required on everything
 	if s.Opt.HtPasswd != "" || s.Opt.BasicUser != "" || s.Opt.Auth != nil {
-		var authenticator *auth.BasicAuth
 		if s.Opt.Auth == nil {
 			var secretProvider auth.SecretProvider
 			if s.Opt.HtPasswd != "" {
@@ -222,7 +284,7 @@ func NewServer(handler http.Handler, opt *Options) *Server {
 				s.basicPassHashed = string(auth.MD5Crypt([]byte(s.Opt.BasicPass), []byte("dlPL2MqE"), []byte("$1$")))
 				secretProvider = s.singleUserProvider
 			}
-			authenticator = auth.NewBasicAuthenticator(s.Opt.Realm, secretProvider)
+			s.authenticator = auth.NewBasicAuthenticator(s.Opt.Realm, secretProvider)
 		}
 		oldHandler := handler
 		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -231,33 +293,57 @@ func NewServer(handler http.Handler, opt *Options) *Server {
 				oldHandler.ServeHTTP(w, r)
 				return
 			}
-			unauthorized := func() {
+			r, ok := s.CheckAuth(r)
+			if !ok {
 				w.Header().Set("Content-Type", "text/plain")
 				w.Header().Set("WWW-Authenticate", `Basic realm="`+s.Opt.Realm+`"`)
 				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			oldHandler.ServeHTTP(w, r)
+		})
+		s.usingAuth = true
+	}
+
+	// Use OpenID Connect instead of user and password if required
+	if s.Opt.OIDC.Issuer != "" {
+		if s.Opt.HtPasswd != "" || s.Opt.BasicUser != "" || s.Opt.Auth != nil {
+			log.Fatalf("Can't use --oidc-issuer with --user, --htpasswd, --auth-proxy or --users-file")
+		}
+		var err error
+		s.oidc, err = oidc.New(&s.Opt.OIDC)
+		if err != nil {
+			log.Fatalf("Failed to set up OpenID Connect: %v", err)
+		}
+		oldHandler := handler
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// No auth wanted for OPTIONS method
+			if r.Method == "OPTIONS" {
+				oldHandler.ServeHTTP(w, r)
+				return
 			}
-			user, pass, authValid := parseAuthorization(r)
-			if !authValid {
-				unauthorized()
+			if s.oidc.HandleCallback(w, r, s.Opt.BaseURL) {
 				return
 			}
-			if s.Opt.Auth == nil {
-				if username := authenticator.CheckAuth(r); username == "" {
-					fs.Infof(r.URL.Path, "%s: Unauthorized request from %s", r.RemoteAddr, user)
-					unauthorized()
-					return
-				}
-			} else {
-				// Custom Auth
-				value, err := s.Opt.Auth(user, pass)
-				if err != nil {
-					fs.Infof(r.URL.Path, "%s: Auth failed from %s: %v", r.RemoteAddr, user, err)
-					unauthorized()
-					return
-				}
-				if value != nil {
-					r = r.WithContext(context.WithValue(r.Context(), ContextAuthKey, value))
-				}
+			r, ok := s.CheckAuth(r)
+			if !ok {
+				s.oidc.RequireAuth(w, r, s.Opt.BaseURL)
+				return
+			}
+			oldHandler.ServeHTTP(w, r)
+		})
+		s.usingAuth = true
+	}
+
+	// Use the client certificate to identify the user if required
+	if s.Opt.ClientCA != "" {
+		oldHandler := handler
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := certUser(r)
+			if user == "" && r.Method != "OPTIONS" {
+				fs.Infof(r.URL.Path, "%s: Unauthorized request with no usable client certificate", r.RemoteAddr)
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
 			}
 			r = r.WithContext(context.WithValue(r.Context(), ContextUserKey, user))
 			oldHandler.ServeHTTP(w, r)
@@ -265,10 +351,10 @@ func NewServer(handler http.Handler, opt *Options) *Server {
 		s.usingAuth = true
 	}
 
-	s.useSSL = s.Opt.SslKey != ""
-	if (s.Opt.SslCert != "") != s.useSSL {
+	if (s.Opt.SslCert != "") != (s.Opt.SslKey != "") {
 		log.Fatalf("Need both -cert and -key to use SSL")
 	}
+	s.useSSL = s.Opt.SslKey != "" || len(s.Opt.Certs.ACMEDomains) > 0
 
 	// If a Base URL is set then serve from there
 	s.Opt.BaseURL = strings.Trim(s.Opt.BaseURL, "/")
@@ -290,6 +376,15 @@ func NewServer(handler http.Handler, opt *Options) *Server {
 		},
 	}
 
+	if s.useSSL {
+		var err error
+		s.certs, err = certs.New(&s.Opt.Certs, s.Opt.SslCert, s.Opt.SslKey)
+		if err != nil {
+			log.Fatalf("Failed to set up SSL certificates: %v", err)
+		}
+		s.certs.Configure(s.httpServer.TLSConfig)
+	}
+
 	if s.Opt.ClientCA != "" {
 		if !s.useSSL {
 			log.Fatalf("Can't use --client-ca without --cert and --key")
@@ -315,11 +410,33 @@ func NewServer(handler http.Handler, opt *Options) *Server {
 	return s
 }
 
+// ServeH2C makes the server accept unencrypted HTTP/2 (h2c) as well
+// as HTTP/1.x if it isn't using SSL. Servers using SSL negotiate
+// HTTP/2 anyway.
+//
+// Call before Serve.
+func (s *Server) ServeH2C() {
+	if s.useSSL {
+		return
+	}
+	s.httpServer.Handler = h2c.NewHandler(s.httpServer.Handler, &http2.Server{
+		IdleTimeout: s.httpServer.IdleTimeout,
+	})
+}
+
+// Wrap wraps the handler of the server, including the authentication,
+// in middleware, e.g. to log every request.
+//
+// Call before Serve.
+func (s *Server) Wrap(middleware func(http.Handler) http.Handler) {
+	s.httpServer.Handler = middleware(s.httpServer.Handler)
+}
+
 // Serve runs the server - returns an error only if
 // the listener was not started; does not block, so
 // use s.Wait() to block on the listener indefinitely.
 func (s *Server) Serve() error {
-	ln, err := net.Listen("tcp", s.httpServer.Addr)
+	ln, err := listen(s.httpServer.Addr)
 	if err != nil {
 		return fmt.Errorf("start server failed: %w", err)
 	}
@@ -336,16 +453,12 @@ func (s *Server) Serve() error {
 			srvIface := interface{}(s.httpServer)
 			if tlsSrv, ok := srvIface.(tlsServer); ok {
 				// yay -- we get easy TLS support with HTTP/2
-				err = tlsSrv.ServeTLS(s.listener, s.Opt.SslCert, s.Opt.SslKey)
+				//
+				// The certificates come from s.certs so they can be reloaded
+				err = tlsSrv.ServeTLS(s.listener, "", "")
 			} else {
 				// oh well -- we can still do TLS but might not have HTTP/2
-				tlsConfig := new(tls.Config)
-				tlsConfig.Certificates = make([]tls.Certificate, 1)
-				tlsConfig.Certificates[0], err = tls.LoadX509KeyPair(s.Opt.SslCert, s.Opt.SslKey)
-				if err != nil {
-					log.Printf("Error loading key pair: %v", err)
-				}
-				tlsLn := tls.NewListener(s.listener, tlsConfig)
+				tlsLn := tls.NewListener(s.listener, s.httpServer.TLSConfig)
 				err = s.httpServer.Serve(tlsLn)
 			}
 		} else {
@@ -365,7 +478,11 @@ func (s *Server) Wait() {
 
 // Close shuts the running server down
 func (s *Server) Close() {
-	err := s.httpServer.Close()
+	err := s.certs.Close()
+	if err != nil {
+		log.Printf("Error on closing ACME challenge server: %v", err)
+	}
+	err = s.httpServer.Close()
 	if err != nil {
 		log.Printf("Error on closing HTTP server: %v", err)
 		return
@@ -373,6 +490,11 @@ func (s *Server) Close() {
 	close(s.waitChan)
 }
 
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
 // URL returns the serving address of this server
 func (s *Server) URL() string {
 	proto := "http"
@@ -380,6 +502,9 @@ func (s *Server) URL() string {
 		proto = "https"
 	}
 	addr := s.Opt.ListenAddr
+	if isSocket(addr) {
+		return fmt.Sprintf("%s%s/", addr, s.Opt.BaseURL)
+	}
 	// prefer actual listener address if using ":port" or "addr:0"
 	useActualAddress := addr == "" || addr[0] == ':' || addr[len(addr)-1] == ':' || strings.HasSuffix(addr, ":0")
 	if s.listener != nil && useActualAddress {
@@ -390,6 +515,63 @@ func (s *Server) URL() string {
 	return fmt.Sprintf("%s://%s%s/", proto, addr, s.Opt.BaseURL)
 }
 
+// CheckAuth checks the bearer token or user and password in the
+// Authorization header of r. It returns r with the user and any value
+// returned by the Auth or TokenAuth functions added to its context,
+// or false if the credentials aren't valid.
+//
+// It returns r unchanged and true if no user and password
+// authentication is configured.
+func (s *Server) CheckAuth(r *http.Request) (*http.Request, bool) {
+	if s.authenticator == nil && s.Opt.Auth == nil && s.oidc == nil {
+		return r, true
+	}
+	if token, ok := parseBearer(r); ok && s.Opt.TokenAuth != nil {
+		value, err := s.Opt.TokenAuth(token)
+		// If it isn't one of our tokens it may be an OpenID Connect one
+		if err != nil && s.oidc == nil {
+			fs.Infof(r.URL.Path, "%s: Token auth failed: %v", r.RemoteAddr, err)
+			return r, false
+		}
+		if err == nil {
+			if value != nil {
+				r = r.WithContext(context.WithValue(r.Context(), ContextAuthKey, value))
+			}
+			return r, true
+		}
+	}
+	if s.oidc != nil {
+		user, err := s.oidc.Check(r, s.Opt.BaseURL)
+		if err != nil {
+			return r, false
+		}
+		r = r.WithContext(context.WithValue(r.Context(), ContextUserKey, user.Name))
+		return r, true
+	}
+	user, pass, authValid := parseAuthorization(r)
+	if !authValid {
+		return r, false
+	}
+	if s.Opt.Auth == nil {
+		if username := s.authenticator.CheckAuth(r); username == "" {
+			fs.Infof(r.URL.Path, "%s: Unauthorized request from %s", r.RemoteAddr, user)
+			return r, false
+		}
+	} else {
+		// Custom Auth
+		value, err := s.Opt.Auth(user, pass)
+		if err != nil {
+			fs.Infof(r.URL.Path, "%s: Auth failed from %s: %v", r.RemoteAddr, user, err)
+			return r, false
+		}
+		if value != nil {
+			r = r.WithContext(context.WithValue(r.Context(), ContextAuthKey, value))
+		}
+	}
+	r = r.WithContext(context.WithValue(r.Context(), ContextUserKey, user))
+	return r, true
+}
+
 // UsingAuth returns true if authentication is required
 func (s *Server) UsingAuth() bool {
 	return s.usingAuth
@@ -0,0 +1,97 @@
+package httplib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Prefixes for ListenAddr to listen on a unix domain socket or a
+// windows named pipe instead of a TCP port
+const (
+	unixPrefix  = "unix://"
+	npipePrefix = "npipe://"
+)
+
+// isSocket returns true if addr is a unix socket or named pipe address
+func isSocket(addr string) bool {
+	return strings.HasPrefix(addr, unixPrefix) || strings.HasPrefix(addr, npipePrefix)
+}
+
+// npipePath converts a named pipe address like npipe:////./pipe/name
+// into a path like \\.\pipe\name
+func npipePath(addr string) string {
+	return strings.Replace(strings.TrimPrefix(addr, npipePrefix), "/", `\`, -1)
+}
+
+// listen makes the listener for addr which may be a TCP address, or
+// "unix:///path/to/socket" or "npipe:////./pipe/name".
+//
+// Unix sockets are made so only the user running rclone can connect.
+// Named pipes get the default security which only allows the owner,
+// administrators and the system to connect to them.
+func listen(addr string) (net.Listener, error) {
+	switch {
+	case strings.HasPrefix(addr, unixPrefix):
+		return listenUnix(strings.TrimPrefix(addr, unixPrefix))
+	case strings.HasPrefix(addr, npipePrefix):
+		return listenNpipe(npipePath(addr))
+	}
+	return net.Listen("tcp", addr)
+}
+
+// listenUnix listens on the unix socket at path, removing it first if
+// it was left behind by a previous run
+func listenUnix(path string) (net.Listener, error) {
+	if path == "" {
+		return nil, errors.New("unix socket path is empty")
+	}
+	if fi, err := os.Stat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%q exists and isn't a socket", path)
+		}
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("unix socket %q is in use", path)
+		}
+		err = os.Remove(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove stale unix socket: %w", err)
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("failed to set permissions on unix socket: %w", err)
+	}
+	return ln, nil
+}
+
+// SocketDialer returns a function to dial addr if it is a unix socket
+// or named pipe address suitable for use in http.Transport.DialContext,
+// or nil if it is a TCP address.
+func SocketDialer(addr string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	switch {
+	case strings.HasPrefix(addr, unixPrefix):
+		path := strings.TrimPrefix(addr, unixPrefix)
+		return func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+	case strings.HasPrefix(addr, npipePrefix):
+		path := npipePath(addr)
+		return func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialNpipe(ctx, path)
+		}
+	}
+	return nil
+}
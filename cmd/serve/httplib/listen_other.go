@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package httplib
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+var errNpipeNotSupported = errors.New("named pipes are only supported on Windows")
+
+// listenNpipe listens on the named pipe at path
+func listenNpipe(path string) (net.Listener, error) {
+	return nil, errNpipeNotSupported
+}
+
+// dialNpipe connects to the named pipe at path
+func dialNpipe(ctx context.Context, path string) (net.Conn, error) {
+	return nil, errNpipeNotSupported
+}
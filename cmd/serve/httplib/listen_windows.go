@@ -0,0 +1,21 @@
+//go:build windows
+// +build windows
+
+package httplib
+
+import (
+	"context"
+	"net"
+
+	"github.com/Microsoft/go-winio"
+)
+
+// listenNpipe listens on the named pipe at path
+func listenNpipe(path string) (net.Listener, error) {
+	return winio.ListenPipe(path, nil)
+}
+
+// dialNpipe connects to the named pipe at path
+func dialNpipe(ctx context.Context, path string) (net.Conn, error) {
+	return winio.DialPipeContext(ctx, path)
+}
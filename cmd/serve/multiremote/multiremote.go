@@ -0,0 +1,171 @@
+// Package multiremote serves several remotes as the top level
+// directories of a single virtual file system
+package multiremote
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+
+	_ "github.com/rclone/rclone/backend/combine" // used to serve several remotes
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Help contains text describing how to serve several remotes
+var Help = strings.Replace(`
+### Serving several remotes
+
+More than one remote can be served at once by giving several
+|remote:path| arguments. Each one appears as a top level directory of
+the server. The directory is named after the last element of the
+path, or after the remote if there is no path, so
+
+    rclone serve webdav drive: s3:bucket/photos /home/user
+
+serves the directories |drive|, |photos| and |user|. To choose the
+name of the directory yourself use |dir=remote:path|, for example
+
+    rclone serve webdav docs=drive:Documents pics=s3:bucket/photos
+
+Use |--all-remotes| instead of any arguments to serve every remote in
+the config file, each in a directory named after the remote. Any
+remotes which can't be used are logged and left out.
+
+Files can be moved and copied server-side within a directory but
+moving them between directories copies and deletes them. Files can't
+be created in the root and the top level directories can't be
+renamed or removed.
+
+See [the combine backend](/combine/) for more details.
+`, "|", "`", -1)
+
+// Options for serving several remotes
+type Options struct {
+	AllRemotes bool
+}
+
+// Opt is options set by command line flags
+var Opt Options
+
+// AddFlags adds the flags to serve several remotes to the command
+func AddFlags(flagSet *pflag.FlagSet) {
+	flags.BoolVarP(flagSet, &Opt.AllRemotes, "all-remotes", "", Opt.AllRemotes, "Serve all the remotes in the config file as top level directories")
+}
+
+// splitNamed splits arg in the form "dir=remote:path" returning ok
+// false if it isn't in that form
+func splitNamed(arg string) (dir, remote string, ok bool) {
+	i := strings.IndexRune(arg, '=')
+	if i <= 0 || strings.ContainsAny(arg[:i], `/\:,`) {
+		return "", "", false
+	}
+	return arg[:i], arg[i+1:], true
+}
+
+// upstreamDir returns the directory name arg should be served as
+// and the remote to serve there.
+//
+// arg is either "dir=remote:path" or "remote:path" in which case the
+// directory is named after the last element of the path or the remote.
+func upstreamDir(arg string) (dir, remote string, err error) {
+	if dir, remote, ok := splitNamed(arg); ok {
+		return dir, remote, nil
+	}
+	parsed, err := fspath.Parse(arg)
+	if err != nil {
+		return "", "", err
+	}
+	p := parsed.Path
+	if parsed.Name == "" {
+		p, err = filepath.Abs(p)
+		if err != nil {
+			return "", "", err
+		}
+		p = filepath.ToSlash(p)
+	}
+	dir = path.Base(strings.TrimRight(p, "/"))
+	if dir == "." || dir == "/" {
+		dir = strings.TrimPrefix(parsed.Name, ":")
+	}
+	if dir == "" {
+		return "", "", fmt.Errorf("can't make a directory name for %q - use dir=%s", arg, arg)
+	}
+	return dir, arg, nil
+}
+
+// allRemotes returns the upstreams for all the remotes in the config
+// file which can be used
+func allRemotes(ctx context.Context) (upstreams fs.SpaceSepList) {
+	for _, name := range config.FileSections() {
+		remote := name + ":"
+		_, err := cache.Get(ctx, remote)
+		if err != nil {
+			fs.Errorf(nil, "Not serving %q: %v", remote, err)
+			continue
+		}
+		upstreams = append(upstreams, name+"="+remote)
+	}
+	return upstreams
+}
+
+// upstreams returns the upstreams to serve the remotes in args
+func upstreams(args []string) (upstreams fs.SpaceSepList, err error) {
+	seen := make(map[string]string, len(args))
+	for _, arg := range args {
+		dir, remote, err := upstreamDir(arg)
+		if err != nil {
+			return nil, err
+		}
+		if previous, found := seen[dir]; found {
+			return nil, fmt.Errorf("%q and %q would both be served as %q - use dir=remote:path to name them", previous, arg, dir)
+		}
+		seen[dir] = arg
+		upstreams = append(upstreams, dir+"="+remote)
+	}
+	return upstreams, nil
+}
+
+// connectionString returns a connection string for a combine backend
+// of upstreams
+func connectionString(upstreams fs.SpaceSepList) string {
+	return ":combine,upstreams='" + strings.Replace(upstreams.String(), "'", "''", -1) + "':"
+}
+
+// NewFs checks the arguments of command and creates the Fs to serve.
+//
+// This is a single remote if one is given, otherwise a combine
+// backend with the remotes as its top level directories.
+func NewFs(command *cobra.Command, args []string) fs.Fs {
+	var (
+		ups fs.SpaceSepList
+		err error
+	)
+	if Opt.AllRemotes {
+		cmd.CheckArgs(0, 0, command, args)
+		ups = allRemotes(context.Background())
+		if len(ups) == 0 {
+			log.Fatalf("--all-remotes: no remotes in the config file can be served")
+		}
+	} else {
+		cmd.CheckArgs(1, 1e6, command, args)
+		if _, _, named := splitNamed(args[0]); len(args) == 1 && !named {
+			return cmd.NewFsSrc(args)
+		}
+		ups, err = upstreams(args)
+		if err != nil {
+			err = fs.CountError(err)
+			log.Fatalf("Failed to serve several remotes: %v", err)
+		}
+	}
+	return cmd.NewFsDir([]string{connectionString(ups)})
+}
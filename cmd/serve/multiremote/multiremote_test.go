@@ -0,0 +1,62 @@
+package multiremote
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstreamDir(t *testing.T) {
+	cwd, err := filepath.Abs(".")
+	require.NoError(t, err)
+	for _, test := range []struct {
+		in     string
+		dir    string
+		remote string
+		err    bool
+	}{
+		{in: "drive:", dir: "drive", remote: "drive:"},
+		{in: "s3:bucket/photos/", dir: "photos", remote: "s3:bucket/photos/"},
+		{in: "/home/user", dir: "user", remote: "/home/user"},
+		{in: ".", dir: filepath.Base(cwd), remote: "."},
+		{in: ":s3,provider=AWS:", dir: "s3", remote: ":s3,provider=AWS:"},
+		{in: "drive,shared_with_me=true:", dir: "drive", remote: "drive,shared_with_me=true:"},
+		{in: "docs=drive:Documents", dir: "docs", remote: "drive:Documents"},
+		{in: "my docs=/a=b", dir: "my docs", remote: "/a=b"},
+		{in: "/", err: true},
+	} {
+		dir, remote, err := upstreamDir(test.in)
+		if test.err {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.dir, dir, test.in)
+		assert.Equal(t, test.remote, remote, test.in)
+	}
+}
+
+func TestUpstreams(t *testing.T) {
+	ups, err := upstreams([]string{"drive:", "s3:bucket/photos", "docs=drive:Documents"})
+	require.NoError(t, err)
+	assert.Equal(t, fs.SpaceSepList{"drive=drive:", "photos=s3:bucket/photos", "docs=drive:Documents"}, ups)
+
+	_, err = upstreams([]string{"drive:photos", "s3:photos"})
+	assert.EqualError(t, err, `"drive:photos" and "s3:photos" would both be served as "photos" - use dir=remote:path to name them`)
+}
+
+func TestConnectionString(t *testing.T) {
+	ups := fs.SpaceSepList{"a=remote:path with space", "it's=/tmp/it's"}
+	parsed, err := fspath.Parse(connectionString(ups))
+	require.NoError(t, err)
+	assert.Equal(t, ":combine", parsed.Name)
+	assert.Equal(t, "", parsed.Path)
+
+	var got fs.SpaceSepList
+	require.NoError(t, got.Set(parsed.Config["upstreams"]))
+	assert.Equal(t, ups, got)
+}
@@ -0,0 +1,165 @@
+package nfs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/vfs"
+)
+
+// nfsStat is an NFSv3 status code
+type nfsStat uint32
+
+// NFSv3 status codes (RFC 1813)
+const (
+	nfs3OK           nfsStat = 0
+	nfs3ErrPerm      nfsStat = 1
+	nfs3ErrNoEnt     nfsStat = 2
+	nfs3ErrIO        nfsStat = 5
+	nfs3ErrAcces     nfsStat = 13
+	nfs3ErrExist     nfsStat = 17
+	nfs3ErrNotDir    nfsStat = 20
+	nfs3ErrIsDir     nfsStat = 21
+	nfs3ErrInval     nfsStat = 22
+	nfs3ErrNoSpc     nfsStat = 28
+	nfs3ErrROFS      nfsStat = 30
+	nfs3ErrNameLong  nfsStat = 63
+	nfs3ErrNotEmpty  nfsStat = 66
+	nfs3ErrStale     nfsStat = 70
+	nfs3ErrBadHandle nfsStat = 10001
+	nfs3ErrNotSupp   nfsStat = 10004
+	nfs3ErrTooSmall  nfsStat = 10005
+)
+
+// NFSv3 file types
+const (
+	nf3Reg = 1
+	nf3Dir = 2
+)
+
+// Permission bits as used by the mode and the ACCESS procedure
+const (
+	permRead  = 4
+	permWrite = 2
+	permExec  = 1
+)
+
+// toStat converts an error from the VFS into an NFS status
+func toStat(err error) nfsStat {
+	if err == nil {
+		return nfs3OK
+	}
+	_, uErr := fserrors.Cause(err)
+	switch uErr {
+	case vfs.OK:
+		return nfs3OK
+	case vfs.ENOENT, fs.ErrorDirNotFound, fs.ErrorObjectNotFound:
+		return nfs3ErrNoEnt
+	case vfs.EEXIST, fs.ErrorDirExists:
+		return nfs3ErrExist
+	case vfs.EPERM, fs.ErrorPermissionDenied:
+		return nfs3ErrPerm
+	case vfs.ENOTEMPTY:
+		return nfs3ErrNotEmpty
+	case vfs.EROFS:
+		return nfs3ErrROFS
+	case vfs.ENOSPC:
+		return nfs3ErrNoSpc
+	case vfs.ENOSYS, fs.ErrorNotImplemented:
+		return nfs3ErrNotSupp
+	case vfs.EINVAL:
+		return nfs3ErrInval
+	}
+	if errors.Is(err, fs.ErrorDirNotFound) || errors.Is(err, fs.ErrorObjectNotFound) {
+		return nfs3ErrNoEnt
+	}
+	fs.Errorf(nil, "NFS error: %v", err)
+	return nfs3ErrIO
+}
+
+// writeTime writes an nfstime3
+func writeTime(w *xdrWriter, t time.Time) {
+	secs := t.Unix()
+	if secs < 0 {
+		secs = 0
+	}
+	w.writeUint32(uint32(secs))
+	w.writeUint32(uint32(t.Nanosecond()))
+}
+
+// writeAttr writes the fattr3 for node
+func (s *server) writeAttr(w *xdrWriter, node vfs.Node) {
+	if node.IsDir() {
+		w.writeUint32(nf3Dir)
+	} else {
+		w.writeUint32(nf3Reg)
+	}
+	w.writeUint32(uint32(node.Mode().Perm()))
+	if node.IsDir() {
+		w.writeUint32(2) // nlink
+	} else {
+		w.writeUint32(1)
+	}
+	w.writeUint32(s.vfs.Opt.UID)
+	w.writeUint32(s.vfs.Opt.GID)
+	size := node.Size()
+	if size < 0 {
+		size = 0
+	}
+	w.writeUint64(uint64(size)) // size
+	w.writeUint64(uint64(size)) // used
+	w.writeUint32(0)            // rdev
+	w.writeUint32(0)
+	w.writeUint64(s.fsid)
+	w.writeUint64(s.handles.id(node.Path()))
+	modTime := node.ModTime()
+	writeTime(w, modTime) // atime
+	writeTime(w, modTime) // mtime
+	writeTime(w, modTime) // ctime
+}
+
+// writePostOpAttr writes a post_op_attr which is empty if node is nil
+func (s *server) writePostOpAttr(w *xdrWriter, node vfs.Node) {
+	if node == nil {
+		w.writeBool(false)
+		return
+	}
+	w.writeBool(true)
+	s.writeAttr(w, node)
+}
+
+// writeWcc writes a wcc_data with no pre operation attributes
+func (s *server) writeWcc(w *xdrWriter, node vfs.Node) {
+	w.writeBool(false)
+	s.writePostOpAttr(w, node)
+}
+
+// allowed returns the permission bits out of want the caller has on
+// node
+func (req *request) allowed(node vfs.Node, want uint32) uint32 {
+	if req.creds.uid == 0 {
+		return want
+	}
+	perm := uint32(node.Mode().Perm())
+	opt := req.s.vfs.Opt
+	switch {
+	case req.creds.uid == opt.UID:
+		perm >>= 6
+	case req.creds.inGroup(opt.GID):
+		perm >>= 3
+	}
+	return perm & want
+}
+
+// checkWrite returns the status for an attempt to modify node
+func (req *request) checkWrite(node vfs.Node) nfsStat {
+	if req.readOnly || req.s.vfs.Opt.ReadOnly {
+		return nfs3ErrROFS
+	}
+	if req.allowed(node, permWrite) == 0 {
+		return nfs3ErrAcces
+	}
+	return nfs3OK
+}
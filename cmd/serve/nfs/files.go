@@ -0,0 +1,223 @@
+package nfs
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// NFSv3 has no open or close so VFS handles are kept open between
+// READs and WRITEs and closed when they haven't been used for
+// openFileTimeout, on COMMIT, or before the file is removed or
+// renamed.
+const openFileTimeout = 10 * time.Second
+
+// openFile is a VFS handle kept open between calls
+type openFile struct {
+	mu     sync.Mutex // held while the handle is in use
+	h      vfs.Handle
+	write  bool // open for writing
+	read   bool // open for reading
+	used   time.Time
+	closed bool
+}
+
+// openFiles is the cache of open handles by path
+type openFiles struct {
+	vfs   *vfs.VFS
+	mu    sync.Mutex
+	files map[string]*openFile
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// newOpenFiles makes the cache and starts the goroutine closing idle
+// handles
+func newOpenFiles(VFS *vfs.VFS) *openFiles {
+	o := &openFiles{
+		vfs:   VFS,
+		files: make(map[string]*openFile),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go o.closeIdle()
+	return o
+}
+
+// get returns an open handle for path which can write if write is
+// set, opening it if necessary.
+func (o *openFiles) get(path string, write bool) (*openFile, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	of := o.files[path]
+	if of != nil && (of.write || !write) && (of.read || write) {
+		return of, nil
+	}
+	if of != nil {
+		// Close the existing handle so a reader sees the data
+		// written or so a reader can be replaced by a writer
+		delete(o.files, path)
+		if err := of.close(); err != nil {
+			return nil, err
+		}
+	}
+	flags := os.O_RDONLY
+	read := true
+	if write {
+		if o.vfs.Opt.CacheMode >= vfscommon.CacheModeWrites {
+			flags = os.O_RDWR
+		} else {
+			flags = os.O_WRONLY
+			read = false
+		}
+	}
+	h, err := o.vfs.OpenFile(path, flags, 0)
+	if err != nil {
+		return nil, err
+	}
+	of = &openFile{
+		h:     h,
+		write: write,
+		read:  read,
+		used:  time.Now(),
+	}
+	o.files[path] = of
+	return of, nil
+}
+
+// create creates the file at path, truncating it if it exists, and
+// keeps the handle open for writing
+func (o *openFiles) create(path string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if of := o.files[path]; of != nil {
+		delete(o.files, path)
+		if err := of.close(); err != nil {
+			return err
+		}
+	}
+	flags := os.O_CREATE | os.O_TRUNC | os.O_WRONLY
+	if o.vfs.Opt.CacheMode >= vfscommon.CacheModeWrites {
+		flags = os.O_CREATE | os.O_TRUNC | os.O_RDWR
+	}
+	h, err := o.vfs.OpenFile(path, flags, o.vfs.Opt.FilePerms)
+	if err != nil {
+		return err
+	}
+	o.files[path] = &openFile{
+		h:     h,
+		write: true,
+		read:  flags&os.O_RDWR != 0,
+		used:  time.Now(),
+	}
+	return nil
+}
+
+// do calls fn with an open handle for path
+func (o *openFiles) do(path string, write bool, fn func(h vfs.Handle) error) error {
+	for {
+		of, err := o.get(path, write)
+		if err != nil {
+			return err
+		}
+		of.mu.Lock()
+		if of.closed {
+			// closed after we found it so try again
+			of.mu.Unlock()
+			continue
+		}
+		err = fn(of.h)
+		of.used = time.Now()
+		of.mu.Unlock()
+		return err
+	}
+}
+
+// close closes the handle waiting for it to be unused. Any error
+// from writing the file is returned.
+func (of *openFile) close() error {
+	of.mu.Lock()
+	defer of.mu.Unlock()
+	if of.closed {
+		return nil
+	}
+	of.closed = true
+	return of.h.Close()
+}
+
+// close closes any open handle for path
+func (o *openFiles) close(path string) error {
+	o.mu.Lock()
+	of := o.files[path]
+	delete(o.files, path)
+	o.mu.Unlock()
+	if of == nil {
+		return nil
+	}
+	return of.close()
+}
+
+// closeDir closes any open handles at or below dir
+func (o *openFiles) closeDir(dir string) (err error) {
+	o.mu.Lock()
+	var toClose []*openFile
+	for path, of := range o.files {
+		if dir == "" || path == dir || strings.HasPrefix(path, dir+"/") {
+			toClose = append(toClose, of)
+			delete(o.files, path)
+		}
+	}
+	o.mu.Unlock()
+	for _, of := range toClose {
+		if closeErr := of.close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// closeIdle closes handles which haven't been used recently until
+// shutdown is called
+func (o *openFiles) closeIdle() {
+	defer close(o.done)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-o.stop:
+			return
+		case now := <-ticker.C:
+			o.mu.Lock()
+			var toClose = map[string]*openFile{}
+			for path, of := range o.files {
+				if of.mu.TryLock() {
+					if now.Sub(of.used) >= openFileTimeout {
+						toClose[path] = of
+						delete(o.files, path)
+					}
+					of.mu.Unlock()
+				}
+			}
+			o.mu.Unlock()
+			for path, of := range toClose {
+				if err := of.close(); err != nil {
+					fs.Errorf(path, "NFS failed to close file: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// shutdown stops the idle closer and closes all the handles
+func (o *openFiles) shutdown() {
+	close(o.stop)
+	<-o.done
+	if err := o.closeDir(""); err != nil {
+		fs.Errorf(nil, "NFS failed to close file: %v", err)
+	}
+}
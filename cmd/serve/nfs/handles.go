@@ -0,0 +1,107 @@
+package nfs
+
+import (
+	"encoding/binary"
+	"strings"
+	"sync"
+)
+
+// handleSize is the size of the file handles we issue
+const handleSize = 16
+
+// handles maps NFS file handles to paths in the VFS.
+//
+// A handle is made of a prefix which is random for each run of the
+// server, so handles from a previous run are stale, followed by an
+// id which is also used as the file id. Ids are never reused and
+// entries are only removed when the file or directory is, so the
+// table grows with the number of paths the clients look at.
+type handles struct {
+	mu     sync.Mutex
+	prefix [8]byte
+	next   uint64
+	byID   map[uint64]string
+	byPath map[string]uint64
+}
+
+// newHandles makes a handle table with the root at id 1
+func newHandles(prefix [8]byte) *handles {
+	h := &handles{
+		prefix: prefix,
+		next:   2,
+		byID:   map[uint64]string{1: ""},
+		byPath: map[string]uint64{"": 1},
+	}
+	return h
+}
+
+// id returns the id for path, allocating one if needed
+func (h *handles) id(path string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	id, ok := h.byPath[path]
+	if !ok {
+		id = h.next
+		h.next++
+		h.byPath[path] = id
+		h.byID[id] = path
+	}
+	return id
+}
+
+// handle returns the file handle for path
+func (h *handles) handle(path string) []byte {
+	fh := make([]byte, handleSize)
+	copy(fh, h.prefix[:])
+	binary.BigEndian.PutUint64(fh[8:], h.id(path))
+	return fh
+}
+
+// path returns the path for the file handle fh or false if it isn't
+// known
+func (h *handles) path(fh []byte) (string, bool) {
+	if len(fh) != handleSize || string(fh[:8]) != string(h.prefix[:]) {
+		return "", false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	path, ok := h.byID[binary.BigEndian.Uint64(fh[8:])]
+	return path, ok
+}
+
+// rename moves the ids of oldPath and everything below it to
+// newPath so the handles stay valid
+func (h *handles) rename(oldPath, newPath string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removePath(newPath)
+	for path, id := range h.byPath {
+		var to string
+		switch {
+		case path == oldPath:
+			to = newPath
+		case strings.HasPrefix(path, oldPath+"/"):
+			to = newPath + path[len(oldPath):]
+		default:
+			continue
+		}
+		delete(h.byPath, path)
+		h.byPath[to] = id
+		h.byID[id] = to
+	}
+}
+
+// remove forgets path so its handle becomes stale
+func (h *handles) remove(path string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removePath(path)
+}
+
+// removePath forgets path - call with the lock held
+func (h *handles) removePath(path string) {
+	if id, ok := h.byPath[path]; ok && path != "" {
+		delete(h.byPath, path)
+		delete(h.byID, id)
+	}
+}
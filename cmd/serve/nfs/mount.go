@@ -0,0 +1,92 @@
+package nfs
+
+// MOUNT version 3 (RFC 1813 appendix I)
+
+import "strings"
+
+// MOUNT constants
+const (
+	progMount    = 100005
+	mountVersion = 3
+
+	maxMountPath = 1024
+
+	mnt3OK       = 0
+	mnt3ErrNoEnt = 2
+	mnt3ErrIO    = 5
+	mnt3ErrNoDir = 20
+)
+
+// mountProgram returns the MOUNT procedures in procedure number order
+func mountProgram() program {
+	return program{
+		name: "MOUNT",
+		vers: mountVersion,
+		procs: []procedure{
+			(*request).null,
+			(*request).mnt,
+			(*request).dump,
+			(*request).umnt,
+			(*request).umntall,
+			(*request).export,
+		},
+	}
+}
+
+// mnt returns the file handle for a directory so the client can
+// mount it. Any directory in the remote can be mounted.
+func (req *request) mnt() error {
+	dirPath := req.args.readString(maxMountPath)
+	if req.args.err != nil {
+		return req.args.err
+	}
+	node, err := req.s.vfs.Stat(strings.Trim(dirPath, "/"))
+	switch {
+	case err == nil && !node.IsDir():
+		req.res.writeUint32(mnt3ErrNoDir)
+	case err == nil:
+		req.res.writeUint32(mnt3OK)
+		req.res.writeOpaque(req.s.handles.handle(node.Path()))
+		req.res.writeUint32(1) // auth flavors
+		req.res.writeUint32(authUnix)
+	case toStat(err) == nfs3ErrNoEnt:
+		req.res.writeUint32(mnt3ErrNoEnt)
+	default:
+		req.res.writeUint32(mnt3ErrIO)
+	}
+	return nil
+}
+
+// dump would list the mounts but we don't keep track of them so
+// returns an empty list
+func (req *request) dump() error {
+	req.res.writeBool(false)
+	return nil
+}
+
+// umnt is called when a client unmounts
+func (req *request) umnt() error {
+	_ = req.args.readString(maxMountPath)
+	return req.args.err
+}
+
+// umntall is called when a client unmounts everything
+func (req *request) umntall() error {
+	return nil
+}
+
+// export lists the single export "/" with the networks allowed to
+// mount it, or no networks if anyone can
+func (req *request) export() error {
+	req.res.writeBool(true)
+	req.res.writeString("/")
+	for _, networks := range [][]string{req.s.opt.Allow, req.s.opt.AllowRO} {
+		for _, network := range networks {
+			req.res.writeBool(true)
+			req.res.writeString(network)
+		}
+	}
+	req.res.writeBool(false) // end of groups
+	req.res.writeBool(false) // end of exports
+	return nil
+}
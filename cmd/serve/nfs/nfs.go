@@ -0,0 +1,149 @@
+// Package nfs implements a server to serve a VFS remote over NFSv3
+package nfs
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfsflags"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Options contains options for the NFS Server
+type Options struct {
+	ListenAddr string   // Port to listen on
+	Allow      []string // IPs and networks allowed read-write access
+	AllowRO    []string // IPs and networks allowed read-only access
+	RootSquash bool     // map uid 0 to the anonymous user
+	AllSquash  bool     // map all users to the anonymous user
+	AnonUID    uint32   // uid of the anonymous user
+	AnonGID    uint32   // gid of the anonymous user
+}
+
+// DefaultOpt is the default values used for Options
+var DefaultOpt = Options{
+	ListenAddr: "localhost:2049",
+	RootSquash: true,
+	AnonUID:    65534,
+	AnonGID:    65534,
+}
+
+// Opt is options set by command line flags
+var Opt = DefaultOpt
+
+// AddFlags adds flags for the nfs server
+func AddFlags(flagSet *pflag.FlagSet, Opt *Options) {
+	rc.AddOption("nfs", &Opt)
+	flags.StringVarP(flagSet, &Opt.ListenAddr, "addr", "", Opt.ListenAddr, "IPaddress:Port or :Port to bind server to")
+	flags.StringArrayVarP(flagSet, &Opt.Allow, "allow", "", Opt.Allow, "IP address or CIDR network allowed read-write access (can be repeated)")
+	flags.StringArrayVarP(flagSet, &Opt.AllowRO, "allow-ro", "", Opt.AllowRO, "IP address or CIDR network allowed read-only access (can be repeated)")
+	flags.BoolVarP(flagSet, &Opt.RootSquash, "root-squash", "", Opt.RootSquash, "Map requests from uid/gid 0 to the anonymous uid/gid")
+	flags.BoolVarP(flagSet, &Opt.AllSquash, "all-squash", "", Opt.AllSquash, "Map requests from all users to the anonymous uid/gid")
+	flags.Uint32VarP(flagSet, &Opt.AnonUID, "anon-uid", "", Opt.AnonUID, "The uid requests are mapped to by squashing")
+	flags.Uint32VarP(flagSet, &Opt.AnonGID, "anon-gid", "", Opt.AnonGID, "The gid requests are mapped to by squashing")
+}
+
+func init() {
+	vfsflags.AddFlags(Command.Flags())
+	AddFlags(Command.Flags(), &Opt)
+}
+
+const longHelp = `rclone serve nfs implements a user space NFS version 3 server to
+serve the remote over NFS. This lets machines which can't run rclone
+mount, such as ESXi hosts, NAS appliances or systems without FUSE,
+mount the remote with their own NFS client.
+
+Only NFS version 3 over TCP is supported. There is no portmapper, lock
+manager or NFSv4 support, so clients must be told the port to use for
+both NFS and MOUNT, and to do locking locally, for example on Linux
+
+    mount -t nfs -o vers=3,tcp,port=2049,mountport=2049,nolock server:/ /mnt/remote
+
+and on macOS
+
+    mount -t nfs -o vers=3,tcp,port=2049,mountport=2049,locallocks server:/ /mnt/remote
+
+Any directory of the remote can be mounted, e.g. |server:/photos|.
+
+### Server options
+
+Use --addr to specify which IP address and port the server should
+listen on, e.g. --addr 1.2.3.4:2049 or --addr :2049 to listen to all
+IPs. By default it only listens on localhost.
+
+Note that NFS has no authentication of its own - clients say which
+user they are and the server believes them. If you set --addr to
+listen on a LAN accessible address you should restrict which clients
+may connect.
+
+### Export ACLs
+
+Use --allow to give an IP address or CIDR network read-write access
+and --allow-ro to give read-only access, e.g.
+
+    --allow 192.168.1.10 --allow-ro 192.168.1.0/24
+
+Both flags can be repeated. If neither is given any client which can
+reach the server has read-write access. Connections from other
+addresses are refused.
+
+### Users and permissions
+
+Files and directories are reported as owned by --uid and --gid with
+the permissions from --file-perms and --dir-perms (see the VFS
+options below), and requests are checked against these as a local
+file system would.
+
+By default requests from root (uid 0) are mapped to the anonymous
+user given with --anon-uid and --anon-gid (65534, "nobody", by
+default). Use --root-squash=false to let root do anything, or
+--all-squash to map every user to the anonymous user.
+
+Changes to the owner and permissions of files are accepted but
+ignored as rclone can't store them.
+
+### Limitations
+
+Symbolic links, hard links and special files aren't supported.
+
+NFS has no open or close so rclone keeps files open between reads and
+writes. A file written by a client is uploaded once it hasn't been
+used for 10 seconds, or sooner if the client sends a COMMIT for it.
+
+Clients write the parts of a file out of order and may read it back
+while writing, so |--vfs-cache-mode writes| or |full| should be
+used if clients will be writing.
+
+File handles are kept in memory so clients will get "stale file
+handle" errors after the server is restarted and will need to
+remount.
+
+`
+
+// Command definition for cobra
+var Command = &cobra.Command{
+	Use:   "nfs remote:path",
+	Short: `Serve the remote as an NFS mount.`,
+	Long:  strings.ReplaceAll(longHelp, "|", "`") + vfs.Help,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsSrc(args)
+		cmd.Run(false, true, command, func() error {
+			s, err := newServer(context.Background(), vfs.New(f, &vfsflags.Opt), &Opt)
+			if err != nil {
+				return err
+			}
+			err = s.Serve()
+			if err != nil {
+				return err
+			}
+			s.Wait()
+			return nil
+		})
+	},
+}
@@ -0,0 +1,908 @@
+package nfs
+
+// NFS version 3 (RFC 1813)
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+)
+
+// NFSv3 constants
+const (
+	progNFS    = 100003
+	nfsVersion = 3
+
+	maxHandle = 64      // largest file handle accepted
+	maxName   = 255     // longest file name
+	maxData   = 1 << 20 // largest READ or WRITE
+
+	stableFileSync = 2
+
+	createUnchecked = 0
+	createGuarded   = 1
+	createExclusive = 2
+
+	timeServer = 1
+	timeClient = 2
+
+	access3Read    = 0x01
+	access3Lookup  = 0x02
+	access3Modify  = 0x04
+	access3Extend  = 0x08
+	access3Delete  = 0x10
+	access3Execute = 0x20
+
+	fsf3Homogeneous = 0x08
+	fsf3CanSetTime  = 0x10
+
+	nfs3ErrNotSync   nfsStat = 10002
+	nfs3ErrBadCookie nfsStat = 10003
+)
+
+// request is an RPC call being processed
+type request struct {
+	ctx      context.Context
+	s        *server
+	creds    creds
+	readOnly bool // if set the client may only read
+	args     *xdrReader
+	res      *xdrWriter
+}
+
+// nfsProgram returns the NFSv3 procedures in procedure number order
+func nfsProgram() program {
+	return program{
+		name: "NFS",
+		vers: nfsVersion,
+		procs: []procedure{
+			(*request).null,
+			(*request).getattr,
+			(*request).setattr,
+			(*request).lookup,
+			(*request).access,
+			(*request).readlink,
+			(*request).read,
+			(*request).write,
+			(*request).create,
+			(*request).mkdir,
+			(*request).symlink,
+			(*request).mknod,
+			(*request).remove,
+			(*request).rmdir,
+			(*request).rename,
+			(*request).link,
+			(*request).readdir,
+			(*request).readdirplus,
+			(*request).fsstat,
+			(*request).fsinfo,
+			(*request).pathconf,
+			(*request).commit,
+		},
+	}
+}
+
+// null does nothing - it is used by clients to ping the server
+func (req *request) null() error {
+	return nil
+}
+
+// readHandle reads a file handle from the arguments
+func (req *request) readHandle() []byte {
+	return req.args.readOpaque(maxHandle)
+}
+
+// readDirOp reads a diropargs3 from the arguments
+func (req *request) readDirOp() (fh []byte, name string) {
+	fh = req.readHandle()
+	name = req.args.readString(1024)
+	return fh, name
+}
+
+// node finds the node for the file handle fh
+func (s *server) node(fh []byte) (vfs.Node, nfsStat) {
+	p, ok := s.handles.path(fh)
+	if !ok {
+		if len(fh) != handleSize {
+			return nil, nfs3ErrBadHandle
+		}
+		return nil, nfs3ErrStale
+	}
+	node, err := s.vfs.Stat(p)
+	if err != nil {
+		stat := toStat(err)
+		if stat == nfs3ErrNoEnt {
+			s.handles.remove(p)
+			stat = nfs3ErrStale
+		}
+		return nil, stat
+	}
+	return node, nfs3OK
+}
+
+// dir finds the directory for the file handle fh
+func (s *server) dir(fh []byte) (*vfs.Dir, nfsStat) {
+	node, stat := s.node(fh)
+	if stat != nfs3OK {
+		return nil, stat
+	}
+	dir, ok := node.(*vfs.Dir)
+	if !ok {
+		return nil, nfs3ErrNotDir
+	}
+	return dir, nfs3OK
+}
+
+// checkName checks name is usable as a new directory entry
+func checkName(name string) nfsStat {
+	switch {
+	case len(name) > maxName:
+		return nfs3ErrNameLong
+	case name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\x00"):
+		return nfs3ErrInval
+	}
+	return nfs3OK
+}
+
+// checkDirWrite returns the status for an attempt to change the
+// entries in dir
+func (req *request) checkDirWrite(dir *vfs.Dir) nfsStat {
+	if stat := req.checkWrite(dir); stat != nfs3OK {
+		return stat
+	}
+	if req.allowed(dir, permExec) == 0 {
+		return nfs3ErrAcces
+	}
+	return nfs3OK
+}
+
+// joinPath joins name onto the path of dir
+func joinPath(dir vfs.Node, name string) string {
+	return path.Join(dir.Path(), name)
+}
+
+// sattr is a decoded sattr3. Changes to the mode, owner and access
+// time are accepted but ignored as the VFS can't store them.
+type sattr struct {
+	setSize  bool
+	size     uint64
+	setMtime bool
+	mtime    time.Time
+}
+
+// readTime reads an nfstime3
+func (req *request) readTime() time.Time {
+	secs := req.args.readUint32()
+	nsecs := req.args.readUint32()
+	return time.Unix(int64(secs), int64(nsecs))
+}
+
+// readSattr reads a sattr3 from the arguments
+func (req *request) readSattr() (a sattr) {
+	for i := 0; i < 3; i++ { // mode, uid, gid
+		if req.args.readBool() {
+			_ = req.args.readUint32()
+		}
+	}
+	if a.setSize = req.args.readBool(); a.setSize {
+		a.size = req.args.readUint64()
+	}
+	if req.args.readUint32() == timeClient { // atime
+		_ = req.readTime()
+	}
+	switch req.args.readUint32() {
+	case timeServer:
+		a.setMtime = true
+		a.mtime = time.Now()
+	case timeClient:
+		a.setMtime = true
+		a.mtime = req.readTime()
+	}
+	return a
+}
+
+// applySattr applies the changes in a to node
+func (req *request) applySattr(node vfs.Node, a sattr) error {
+	if a.setSize {
+		if node.IsDir() {
+			return vfs.EINVAL
+		}
+		err := node.Truncate(int64(a.size))
+		if err != nil {
+			return err
+		}
+	}
+	if a.setMtime {
+		err := node.SetModTime(a.mtime)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getattr returns the attributes of a file or directory
+func (req *request) getattr() error {
+	fh := req.readHandle()
+	if req.args.err != nil {
+		return req.args.err
+	}
+	node, stat := req.s.node(fh)
+	req.res.writeUint32(uint32(stat))
+	if stat == nfs3OK {
+		req.s.writeAttr(req.res, node)
+	}
+	return nil
+}
+
+// setattr changes the size and modification time
+func (req *request) setattr() error {
+	fh := req.readHandle()
+	a := req.readSattr()
+	var guard time.Time
+	check := req.args.readBool()
+	if check {
+		guard = req.readTime()
+	}
+	if req.args.err != nil {
+		return req.args.err
+	}
+	node, stat := req.s.node(fh)
+	if stat == nfs3OK && check {
+		// ctime is reported as the modification time
+		modTime := node.ModTime()
+		if modTime.Unix() != guard.Unix() || modTime.Nanosecond() != guard.Nanosecond() {
+			stat = nfs3ErrNotSync
+		}
+	}
+	if stat == nfs3OK && (a.setSize || a.setMtime) {
+		stat = req.checkWrite(node)
+		if stat == nfs3OK {
+			stat = toStat(req.applySattr(node, a))
+		}
+	}
+	req.res.writeUint32(uint32(stat))
+	req.s.writeWcc(req.res, node)
+	return nil
+}
+
+// lookup finds a name in a directory
+func (req *request) lookup() error {
+	fh, name := req.readDirOp()
+	if req.args.err != nil {
+		return req.args.err
+	}
+	dir, stat := req.s.dir(fh)
+	var node vfs.Node
+	if stat == nfs3OK && req.allowed(dir, permExec) == 0 {
+		stat = nfs3ErrAcces
+	}
+	if stat == nfs3OK {
+		switch {
+		case name == ".":
+			node = dir
+		case name == "..":
+			node, stat = req.s.node(req.s.handles.handle(parentPath(dir.Path())))
+		case len(name) > maxName:
+			stat = nfs3ErrNameLong
+		default:
+			var err error
+			node, err = dir.Stat(name)
+			stat = toStat(err)
+		}
+	}
+	req.res.writeUint32(uint32(stat))
+	if stat != nfs3OK {
+		req.s.writePostOpAttr(req.res, nodeOrNil(dir))
+		return nil
+	}
+	req.res.writeOpaque(req.s.handles.handle(node.Path()))
+	req.s.writePostOpAttr(req.res, node)
+	req.s.writePostOpAttr(req.res, dir)
+	return nil
+}
+
+// parentPath returns the path of the parent of p, the root being its
+// own parent
+func parentPath(p string) string {
+	parent := path.Dir(p)
+	if parent == "." || parent == "/" {
+		return ""
+	}
+	return parent
+}
+
+// nodeOrNil returns dir as a vfs.Node or nil if it is nil so it can
+// be passed to writePostOpAttr
+func nodeOrNil(dir *vfs.Dir) vfs.Node {
+	if dir == nil {
+		return nil
+	}
+	return dir
+}
+
+// access returns which of the requested accesses are allowed
+func (req *request) access() error {
+	fh := req.readHandle()
+	want := req.args.readUint32()
+	if req.args.err != nil {
+		return req.args.err
+	}
+	node, stat := req.s.node(fh)
+	req.res.writeUint32(uint32(stat))
+	req.s.writePostOpAttr(req.res, node)
+	if stat != nfs3OK {
+		return nil
+	}
+	perm := req.allowed(node, permRead|permWrite|permExec)
+	if req.readOnly || req.s.vfs.Opt.ReadOnly {
+		perm &^= permWrite
+	}
+	var got uint32
+	if perm&permRead != 0 {
+		got |= access3Read
+	}
+	if perm&permWrite != 0 {
+		got |= access3Modify | access3Extend | access3Delete
+	}
+	if perm&permExec != 0 {
+		if node.IsDir() {
+			got |= access3Lookup
+		} else {
+			got |= access3Execute
+		}
+	}
+	req.res.writeUint32(got & want)
+	return nil
+}
+
+// readlink fails as there are no symlinks
+func (req *request) readlink() error {
+	fh := req.readHandle()
+	if req.args.err != nil {
+		return req.args.err
+	}
+	node, stat := req.s.node(fh)
+	if stat == nfs3OK {
+		stat = nfs3ErrInval
+	}
+	req.res.writeUint32(uint32(stat))
+	req.s.writePostOpAttr(req.res, node)
+	return nil
+}
+
+// read reads data from a file
+func (req *request) read() error {
+	fh := req.readHandle()
+	offset := req.args.readUint64()
+	count := req.args.readUint32()
+	if req.args.err != nil {
+		return req.args.err
+	}
+	node, stat := req.s.node(fh)
+	if stat == nfs3OK && node.IsDir() {
+		stat = nfs3ErrIsDir
+	}
+	if stat == nfs3OK && req.allowed(node, permRead|permExec) == 0 {
+		stat = nfs3ErrAcces
+	}
+	var (
+		buf []byte
+		eof bool
+	)
+	if stat == nfs3OK {
+		if count > maxData {
+			count = maxData
+		}
+		buf = make([]byte, count)
+		var n int
+		err := req.s.files.do(node.Path(), false, func(h vfs.Handle) (err error) {
+			n, err = h.ReadAt(buf, int64(offset))
+			return err
+		})
+		if err == io.EOF {
+			eof, err = true, nil
+		}
+		stat = toStat(err)
+		buf = buf[:n]
+		if int64(offset)+int64(n) >= node.Size() {
+			eof = true
+		}
+	}
+	req.res.writeUint32(uint32(stat))
+	req.s.writePostOpAttr(req.res, node)
+	if stat != nfs3OK {
+		return nil
+	}
+	req.res.writeUint32(uint32(len(buf)))
+	req.res.writeBool(eof)
+	req.res.writeOpaque(buf)
+	return nil
+}
+
+// write writes data to a file.
+//
+// The data is written to a VFS handle which is kept open, so it is
+// in the VFS cache, or being streamed to the remote, when we reply.
+// It is only uploaded when the handle is closed so COMMIT closes it.
+func (req *request) write() error {
+	fh := req.readHandle()
+	offset := req.args.readUint64()
+	_ = req.args.readUint32() // count
+	_ = req.args.readUint32() // stable
+	data := req.args.readOpaque(maxData)
+	if req.args.err != nil {
+		return req.args.err
+	}
+	node, stat := req.s.node(fh)
+	if stat == nfs3OK && node.IsDir() {
+		stat = nfs3ErrIsDir
+	}
+	if stat == nfs3OK {
+		stat = req.checkWrite(node)
+	}
+	var n int
+	if stat == nfs3OK {
+		err := req.s.files.do(node.Path(), true, func(h vfs.Handle) (err error) {
+			n, err = h.WriteAt(data, int64(offset))
+			return err
+		})
+		stat = toStat(err)
+	}
+	req.res.writeUint32(uint32(stat))
+	req.s.writeWcc(req.res, node)
+	if stat != nfs3OK {
+		return nil
+	}
+	req.res.writeUint32(uint32(n))
+	req.res.writeUint32(stableFileSync)
+	req.res.writeFixed(req.s.verifier[:])
+	return nil
+}
+
+// writeNewNode writes the reply for a successful CREATE or MKDIR
+func (req *request) writeNewNode(node vfs.Node, dir *vfs.Dir) {
+	req.res.writeUint32(uint32(nfs3OK))
+	req.res.writeBool(true)
+	req.res.writeOpaque(req.s.handles.handle(node.Path()))
+	req.s.writePostOpAttr(req.res, node)
+	req.s.writeWcc(req.res, dir)
+}
+
+// create makes a new file.
+//
+// EXCLUSIVE creates are treated like GUARDED ones as there is nowhere
+// to store the verifier, so a retransmitted EXCLUSIVE create will
+// fail with EXIST.
+func (req *request) create() error {
+	fh, name := req.readDirOp()
+	how := req.args.readUint32()
+	var a sattr
+	switch how {
+	case createUnchecked, createGuarded:
+		a = req.readSattr()
+	case createExclusive:
+		_ = req.args.readFixed(8)
+	default:
+		return errGarbageArgs
+	}
+	if req.args.err != nil {
+		return req.args.err
+	}
+	dir, stat := req.s.dir(fh)
+	if stat == nfs3OK {
+		stat = checkName(name)
+	}
+	if stat == nfs3OK {
+		stat = req.checkDirWrite(dir)
+	}
+	var node vfs.Node
+	if stat == nfs3OK {
+		node, stat = req.createFile(dir, name, how, a)
+	}
+	if stat != nfs3OK {
+		req.res.writeUint32(uint32(stat))
+		req.s.writeWcc(req.res, nodeOrNil(dir))
+		return nil
+	}
+	req.writeNewNode(node, dir)
+	return nil
+}
+
+// createFile creates name in dir as described by how and a
+func (req *request) createFile(dir *vfs.Dir, name string, how uint32, a sattr) (vfs.Node, nfsStat) {
+	p := joinPath(dir, name)
+	node, err := dir.Stat(name)
+	switch {
+	case err == nil && how != createUnchecked:
+		return nil, nfs3ErrExist
+	case err == nil && node.IsDir():
+		return nil, nfs3ErrIsDir
+	case err == nil:
+		if stat := req.checkWrite(node); stat != nfs3OK {
+			return nil, stat
+		}
+	case toStat(err) != nfs3ErrNoEnt:
+		return nil, toStat(err)
+	default:
+		err = req.s.files.create(p)
+		if err != nil {
+			return nil, toStat(err)
+		}
+		node, err = dir.Stat(name)
+		if err != nil {
+			return nil, toStat(err)
+		}
+		if a.setSize && a.size == 0 {
+			a.setSize = false
+		}
+	}
+	err = req.applySattr(node, a)
+	if err != nil {
+		return nil, toStat(err)
+	}
+	return node, nfs3OK
+}
+
+// mkdir makes a new directory
+func (req *request) mkdir() error {
+	fh, name := req.readDirOp()
+	a := req.readSattr()
+	if req.args.err != nil {
+		return req.args.err
+	}
+	dir, stat := req.s.dir(fh)
+	if stat == nfs3OK {
+		stat = checkName(name)
+	}
+	if stat == nfs3OK {
+		stat = req.checkDirWrite(dir)
+	}
+	var node vfs.Node
+	if stat == nfs3OK {
+		var err error
+		node, err = dir.Mkdir(name)
+		stat = toStat(err)
+	}
+	if stat == nfs3OK && a.setMtime {
+		if err := node.SetModTime(a.mtime); err != nil {
+			fs.Debugf(node, "NFS failed to set modification time: %v", err)
+		}
+	}
+	if stat != nfs3OK {
+		req.res.writeUint32(uint32(stat))
+		req.s.writeWcc(req.res, nodeOrNil(dir))
+		return nil
+	}
+	req.writeNewNode(node, dir)
+	return nil
+}
+
+// symlink fails as symlinks aren't supported
+func (req *request) symlink() error {
+	req.res.writeUint32(uint32(nfs3ErrNotSupp))
+	req.s.writeWcc(req.res, nil)
+	return nil
+}
+
+// mknod fails as special files aren't supported
+func (req *request) mknod() error {
+	req.res.writeUint32(uint32(nfs3ErrNotSupp))
+	req.s.writeWcc(req.res, nil)
+	return nil
+}
+
+// link fails as hard links aren't supported
+func (req *request) link() error {
+	req.res.writeUint32(uint32(nfs3ErrNotSupp))
+	req.s.writePostOpAttr(req.res, nil)
+	req.s.writeWcc(req.res, nil)
+	return nil
+}
+
+// removeNode removes name from the directory. If isDir is set it
+// must be a directory, otherwise a file.
+func (req *request) removeNode(isDir bool) error {
+	fh, name := req.readDirOp()
+	if req.args.err != nil {
+		return req.args.err
+	}
+	dir, stat := req.s.dir(fh)
+	if stat == nfs3OK {
+		stat = req.checkDirWrite(dir)
+	}
+	var node vfs.Node
+	if stat == nfs3OK {
+		var err error
+		node, err = dir.Stat(name)
+		stat = toStat(err)
+	}
+	if stat == nfs3OK && node.IsDir() != isDir {
+		if isDir {
+			stat = nfs3ErrNotDir
+		} else {
+			stat = nfs3ErrIsDir
+		}
+	}
+	if stat == nfs3OK {
+		p := node.Path()
+		if err := req.s.files.close(p); err != nil {
+			fs.Errorf(p, "NFS failed to close file before removing it: %v", err)
+		}
+		stat = toStat(node.Remove())
+		if stat == nfs3OK {
+			req.s.handles.remove(p)
+		}
+	}
+	req.res.writeUint32(uint32(stat))
+	req.s.writeWcc(req.res, nodeOrNil(dir))
+	return nil
+}
+
+// remove removes a file
+func (req *request) remove() error {
+	return req.removeNode(false)
+}
+
+// rmdir removes an empty directory
+func (req *request) rmdir() error {
+	return req.removeNode(true)
+}
+
+// rename renames a file or directory
+func (req *request) rename() error {
+	fromFh, fromName := req.readDirOp()
+	toFh, toName := req.readDirOp()
+	if req.args.err != nil {
+		return req.args.err
+	}
+	fromDir, stat := req.s.dir(fromFh)
+	var toDir *vfs.Dir
+	if stat == nfs3OK {
+		toDir, stat = req.s.dir(toFh)
+	}
+	if stat == nfs3OK {
+		stat = checkName(toName)
+	}
+	if stat == nfs3OK && checkName(fromName) != nfs3OK {
+		stat = nfs3ErrInval
+	}
+	if stat == nfs3OK {
+		stat = req.checkDirWrite(fromDir)
+	}
+	if stat == nfs3OK {
+		stat = req.checkDirWrite(toDir)
+	}
+	if stat == nfs3OK {
+		fromPath, toPath := joinPath(fromDir, fromName), joinPath(toDir, toName)
+		for _, p := range []string{fromPath, toPath} {
+			if err := req.s.files.closeDir(p); err != nil {
+				fs.Errorf(p, "NFS failed to close file before renaming it: %v", err)
+			}
+		}
+		stat = toStat(fromDir.Rename(fromName, toName, toDir))
+		if stat == nfs3OK {
+			req.s.handles.rename(fromPath, toPath)
+		}
+	}
+	req.res.writeUint32(uint32(stat))
+	req.s.writeWcc(req.res, nodeOrNil(fromDir))
+	req.s.writeWcc(req.res, nodeOrNil(toDir))
+	return nil
+}
+
+// dirEntry is an entry returned by READDIR or READDIRPLUS
+type dirEntry struct {
+	name string
+	node vfs.Node
+}
+
+// dirEntries returns the entries of dir including "." and ".."
+func (req *request) dirEntries(dir *vfs.Dir) ([]dirEntry, nfsStat) {
+	items, err := dir.ReadDirAll()
+	if err != nil {
+		return nil, toStat(err)
+	}
+	parent, stat := req.s.node(req.s.handles.handle(parentPath(dir.Path())))
+	if stat != nfs3OK {
+		parent = dir
+	}
+	entries := make([]dirEntry, 0, len(items)+2)
+	entries = append(entries, dirEntry{".", dir}, dirEntry{"..", parent})
+	for _, item := range items {
+		entries = append(entries, dirEntry{item.Name(), item})
+	}
+	return entries, nfs3OK
+}
+
+// readdir lists a directory. If plus is set it returns the attributes
+// and file handles too.
+//
+// The cookie of each entry is its index plus one, so listings are
+// only stable if the directory doesn't change between calls.
+func (req *request) readDir(plus bool) error {
+	fh := req.readHandle()
+	cookie := req.args.readUint64()
+	_ = req.args.readFixed(8) // cookieverf
+	dirCount := req.args.readUint32()
+	maxCount := dirCount
+	if plus {
+		maxCount = req.args.readUint32()
+	}
+	if req.args.err != nil {
+		return req.args.err
+	}
+	dir, stat := req.s.dir(fh)
+	if stat == nfs3OK && req.allowed(dir, permRead) == 0 {
+		stat = nfs3ErrAcces
+	}
+	var entries []dirEntry
+	if stat == nfs3OK {
+		entries, stat = req.dirEntries(dir)
+	}
+	if stat == nfs3OK && cookie > uint64(len(entries)) {
+		stat = nfs3ErrBadCookie
+	}
+	req.res.writeUint32(uint32(stat))
+	req.s.writePostOpAttr(req.res, nodeOrNil(dir))
+	if stat != nfs3OK {
+		return nil
+	}
+	req.res.writeFixed(make([]byte, 8)) // cookieverf
+
+	// The fixed parts of the reply use about this many bytes
+	const overhead = 128
+	var (
+		list    = &xdrWriter{}
+		dirSize uint32
+		i       = int(cookie)
+	)
+	for ; i < len(entries); i++ {
+		e := &xdrWriter{}
+		e.writeBool(true)
+		e.writeUint64(req.s.handles.id(entries[i].node.Path()))
+		e.writeString(entries[i].name)
+		e.writeUint64(uint64(i + 1))
+		size := uint32(len(e.buf))
+		if plus {
+			req.s.writePostOpAttr(e, entries[i].node)
+			e.writeBool(true)
+			e.writeOpaque(req.s.handles.handle(entries[i].node.Path()))
+		}
+		if dirSize+size > dirCount || uint32(len(list.buf)+len(e.buf))+overhead > maxCount {
+			break
+		}
+		dirSize += size
+		list.buf = append(list.buf, e.buf...)
+	}
+	if i == int(cookie) && i < len(entries) {
+		// Roll back the reply so far and report the buffer was too small
+		req.res.buf = req.res.buf[:0]
+		req.res.writeUint32(uint32(nfs3ErrTooSmall))
+		req.s.writePostOpAttr(req.res, dir)
+		return nil
+	}
+	req.res.buf = append(req.res.buf, list.buf...)
+	req.res.writeBool(false)
+	req.res.writeBool(i == len(entries))
+	return nil
+}
+
+// readdir lists a directory
+func (req *request) readdir() error {
+	return req.readDir(false)
+}
+
+// readdirplus lists a directory with attributes and file handles
+func (req *request) readdirplus() error {
+	return req.readDir(true)
+}
+
+// fsstat returns the space used and free
+func (req *request) fsstat() error {
+	fh := req.readHandle()
+	if req.args.err != nil {
+		return req.args.err
+	}
+	node, stat := req.s.node(fh)
+	req.res.writeUint32(uint32(stat))
+	req.s.writePostOpAttr(req.res, node)
+	if stat != nfs3OK {
+		return nil
+	}
+	total, _, free := req.s.vfs.Statfs()
+	if total < 0 {
+		total = 1 << 50
+	}
+	if free < 0 || free > total {
+		free = total
+	}
+	const files = 1 << 30
+	req.res.writeUint64(uint64(total))
+	req.res.writeUint64(uint64(free)) // free
+	req.res.writeUint64(uint64(free)) // available to the user
+	req.res.writeUint64(files)
+	req.res.writeUint64(files)
+	req.res.writeUint64(files)
+	req.res.writeUint32(0) // invarsec
+	return nil
+}
+
+// fsinfo returns the limits of the server
+func (req *request) fsinfo() error {
+	fh := req.readHandle()
+	if req.args.err != nil {
+		return req.args.err
+	}
+	node, stat := req.s.node(fh)
+	req.res.writeUint32(uint32(stat))
+	req.s.writePostOpAttr(req.res, node)
+	if stat != nfs3OK {
+		return nil
+	}
+	req.res.writeUint32(maxData) // rtmax
+	req.res.writeUint32(maxData) // rtpref
+	req.res.writeUint32(4096)    // rtmult
+	req.res.writeUint32(maxData) // wtmax
+	req.res.writeUint32(maxData) // wtpref
+	req.res.writeUint32(4096)    // wtmult
+	req.res.writeUint32(64 * 1024)
+	req.res.writeUint64(1<<63 - 1) // maxfilesize
+	precision := req.s.vfs.Fs().Precision()
+	if precision <= 0 || precision > time.Hour {
+		precision = time.Second
+	}
+	req.res.writeUint32(uint32(precision / time.Second))
+	req.res.writeUint32(uint32(precision % time.Second))
+	req.res.writeUint32(fsf3Homogeneous | fsf3CanSetTime)
+	return nil
+}
+
+// pathconf returns the limits on names
+func (req *request) pathconf() error {
+	fh := req.readHandle()
+	if req.args.err != nil {
+		return req.args.err
+	}
+	node, stat := req.s.node(fh)
+	req.res.writeUint32(uint32(stat))
+	req.s.writePostOpAttr(req.res, node)
+	if stat != nfs3OK {
+		return nil
+	}
+	req.res.writeUint32(1) // linkmax
+	req.res.writeUint32(maxName)
+	req.res.writeBool(true) // no_trunc
+	req.res.writeBool(true) // chown_restricted
+	req.res.writeBool(req.s.vfs.Opt.CaseInsensitive)
+	req.res.writeBool(true) // case_preserving
+	return nil
+}
+
+// commit closes the open handle of a file so it is uploaded
+func (req *request) commit() error {
+	fh := req.readHandle()
+	_ = req.args.readUint64() // offset
+	_ = req.args.readUint32() // count
+	if req.args.err != nil {
+		return req.args.err
+	}
+	node, stat := req.s.node(fh)
+	if stat == nfs3OK && !node.IsDir() {
+		if err := req.s.files.close(node.Path()); err != nil {
+			fs.Errorf(node, "NFS failed to commit file: %v", err)
+			stat = nfs3ErrIO
+		}
+	}
+	req.res.writeUint32(uint32(stat))
+	req.s.writeWcc(req.res, node)
+	if stat == nfs3OK {
+		req.res.writeFixed(req.s.verifier[:])
+	}
+	return nil
+}
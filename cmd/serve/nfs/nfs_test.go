@@ -0,0 +1,492 @@
+package nfs
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer starts an NFS server on a temporary directory
+// returning the server and the directory
+func newTestServer(t *testing.T, opt Options, vfsOpt vfscommon.Options) (*server, string) {
+	require.NoError(t, config.SetCacheDir(t.TempDir()))
+	dir := t.TempDir()
+	f, err := fs.NewFs(context.Background(), dir)
+	require.NoError(t, err)
+	VFS := vfs.New(f, &vfsOpt)
+	t.Cleanup(VFS.Shutdown)
+	opt.ListenAddr = "127.0.0.1:0"
+	s, err := newServer(context.Background(), VFS, &opt)
+	require.NoError(t, err)
+	require.NoError(t, s.Serve())
+	t.Cleanup(func() {
+		assert.NoError(t, s.Close())
+	})
+	return s, dir
+}
+
+// testClient is a minimal NFS client
+type testClient struct {
+	t   *testing.T
+	c   net.Conn
+	xid uint32
+	uid uint32
+	gid uint32
+}
+
+// newTestClient connects to s as uid/gid
+func newTestClient(t *testing.T, s *server, uid, gid uint32) *testClient {
+	c, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return &testClient{t: t, c: c, uid: uid, gid: gid}
+}
+
+// call makes an RPC call returning the accept status and a reader
+// for the results
+func (tc *testClient) call(prog, vers, proc uint32, args *xdrWriter) (uint32, *xdrReader) {
+	tc.xid++
+	w := &xdrWriter{}
+	w.writeUint32(tc.xid)
+	w.writeUint32(msgCall)
+	w.writeUint32(rpcVersion)
+	w.writeUint32(prog)
+	w.writeUint32(vers)
+	w.writeUint32(proc)
+	cred := &xdrWriter{}
+	cred.writeUint32(0)
+	cred.writeString("test")
+	cred.writeUint32(tc.uid)
+	cred.writeUint32(tc.gid)
+	cred.writeUint32(0)
+	w.writeUint32(authUnix)
+	w.writeOpaque(cred.buf)
+	w.writeUint32(authNone)
+	w.writeOpaque(nil)
+	if args != nil {
+		w.buf = append(w.buf, args.buf...)
+	}
+	record := make([]byte, 4)
+	binary.BigEndian.PutUint32(record, lastFragment|uint32(len(w.buf)))
+	_, err := tc.c.Write(append(record, w.buf...))
+	require.NoError(tc.t, err)
+
+	reply, err := readRecord(tc.c)
+	require.NoError(tc.t, err)
+	r := newXDRReader(reply)
+	require.Equal(tc.t, tc.xid, r.readUint32())
+	require.Equal(tc.t, uint32(msgReply), r.readUint32())
+	require.Equal(tc.t, uint32(replyAccepted), r.readUint32())
+	_ = r.readUint32()
+	_ = r.readOpaque(maxAuthBytes)
+	return r.readUint32(), r
+}
+
+// nfs makes an NFS call returning the status and a reader for the
+// rest of the results
+func (tc *testClient) nfs(proc uint32, args *xdrWriter) (nfsStat, *xdrReader) {
+	accept, r := tc.call(progNFS, nfsVersion, proc, args)
+	require.Equal(tc.t, uint32(acceptSuccess), accept)
+	return nfsStat(r.readUint32()), r
+}
+
+// mount mounts p returning the file handle
+func (tc *testClient) mount(p string) []byte {
+	args := &xdrWriter{}
+	args.writeString(p)
+	accept, r := tc.call(progMount, mountVersion, 1, args)
+	require.Equal(tc.t, uint32(acceptSuccess), accept)
+	require.Equal(tc.t, uint32(mnt3OK), r.readUint32())
+	fh := r.readOpaque(maxHandle)
+	require.NoError(tc.t, r.err)
+	return fh
+}
+
+// skipAttr skips a post_op_attr returning the size if present
+func skipAttr(r *xdrReader) (size uint64) {
+	if !r.readBool() {
+		return 0
+	}
+	_ = r.readFixed(5 * 4)
+	size = r.readUint64()
+	_ = r.readFixed(8 + 8 + 8 + 8 + 3*8)
+	return size
+}
+
+// handleArgs makes the arguments with a single file handle
+func handleArgs(fh []byte) *xdrWriter {
+	w := &xdrWriter{}
+	w.writeOpaque(fh)
+	return w
+}
+
+// dirOpArgs makes the arguments for a directory and name
+func dirOpArgs(fh []byte, name string) *xdrWriter {
+	w := handleArgs(fh)
+	w.writeString(name)
+	return w
+}
+
+// emptySattr writes a sattr3 which changes nothing
+func emptySattr(w *xdrWriter) {
+	for i := 0; i < 6; i++ {
+		w.writeUint32(0)
+	}
+}
+
+// lookup looks up name in dir
+func (tc *testClient) lookup(dir []byte, name string) (nfsStat, []byte) {
+	stat, r := tc.nfs(3, dirOpArgs(dir, name))
+	if stat != nfs3OK {
+		return stat, nil
+	}
+	return stat, r.readOpaque(maxHandle)
+}
+
+// create creates name in dir
+func (tc *testClient) create(dir []byte, name string, how uint32) (nfsStat, []byte) {
+	args := dirOpArgs(dir, name)
+	args.writeUint32(how)
+	if how == createExclusive {
+		args.writeFixed(make([]byte, 8))
+	} else {
+		emptySattr(args)
+	}
+	stat, r := tc.nfs(8, args)
+	if stat != nfs3OK {
+		return stat, nil
+	}
+	require.True(tc.t, r.readBool())
+	return stat, r.readOpaque(maxHandle)
+}
+
+// write writes data at offset
+func (tc *testClient) write(fh []byte, offset uint64, data string) nfsStat {
+	args := handleArgs(fh)
+	args.writeUint64(offset)
+	args.writeUint32(uint32(len(data)))
+	args.writeUint32(0)
+	args.writeOpaque([]byte(data))
+	stat, r := tc.nfs(7, args)
+	if stat == nfs3OK {
+		_ = r.readBool()
+		skipAttr(r)
+		assert.Equal(tc.t, uint32(len(data)), r.readUint32())
+	}
+	return stat
+}
+
+// read reads count bytes at offset
+func (tc *testClient) read(fh []byte, offset uint64, count uint32) (nfsStat, string, bool) {
+	args := handleArgs(fh)
+	args.writeUint64(offset)
+	args.writeUint32(count)
+	stat, r := tc.nfs(6, args)
+	skipAttr(r)
+	if stat != nfs3OK {
+		return stat, "", false
+	}
+	_ = r.readUint32()
+	eof := r.readBool()
+	data := r.readOpaque(maxData)
+	require.NoError(tc.t, r.err)
+	return stat, string(data), eof
+}
+
+// readdirplus lists dir returning the names found
+func (tc *testClient) readdirplus(dir []byte) (names []string) {
+	var cookie uint64
+	for {
+		args := handleArgs(dir)
+		args.writeUint64(cookie)
+		args.writeFixed(make([]byte, 8))
+		args.writeUint32(100)
+		args.writeUint32(1000)
+		stat, r := tc.nfs(17, args)
+		require.Equal(tc.t, nfs3OK, stat)
+		skipAttr(r)
+		_ = r.readFixed(8)
+		for r.readBool() {
+			_ = r.readUint64()
+			names = append(names, r.readString(maxName))
+			cookie = r.readUint64()
+			skipAttr(r)
+			require.True(tc.t, r.readBool())
+			_ = r.readOpaque(maxHandle)
+		}
+		eof := r.readBool()
+		require.NoError(tc.t, r.err)
+		if eof {
+			return names
+		}
+	}
+}
+
+func TestXDR(t *testing.T) {
+	w := &xdrWriter{}
+	w.writeUint32(1)
+	w.writeUint64(1 << 40)
+	w.writeBool(true)
+	w.writeString("hello")
+	w.writeOpaque([]byte{1, 2, 3, 4})
+	assert.Equal(t, 4+8+4+4+8+4+4, len(w.buf))
+
+	r := newXDRReader(w.buf)
+	assert.Equal(t, uint32(1), r.readUint32())
+	assert.Equal(t, uint64(1<<40), r.readUint64())
+	assert.True(t, r.readBool())
+	assert.Equal(t, "hello", r.readString(10))
+	assert.Equal(t, []byte{1, 2, 3, 4}, r.readOpaque(10))
+	require.NoError(t, r.err)
+
+	// Reading past the end or too large an opaque is an error
+	_ = r.readUint32()
+	assert.Equal(t, errGarbageArgs, r.err)
+	r = newXDRReader(w.buf[16:])
+	_ = r.readString(4)
+	assert.Equal(t, errGarbageArgs, r.err)
+}
+
+func TestHandles(t *testing.T) {
+	h := newHandles([8]byte{1})
+	root := h.handle("")
+	p, ok := h.path(root)
+	assert.True(t, ok)
+	assert.Equal(t, "", p)
+
+	a := h.handle("dir/a")
+	assert.Equal(t, a, h.handle("dir/a"))
+	dir := h.handle("dir")
+	h.rename("dir", "new")
+	p, _ = h.path(a)
+	assert.Equal(t, "new/a", p)
+	p, _ = h.path(dir)
+	assert.Equal(t, "new", p)
+
+	h.remove("new/a")
+	_, ok = h.path(a)
+	assert.False(t, ok)
+
+	// Handles from another server are unknown
+	_, ok = newHandles([8]byte{2}).path(dir)
+	assert.False(t, ok)
+}
+
+func TestServer(t *testing.T) {
+	vfsOpt := vfscommon.DefaultOpt
+	vfsOpt.CacheMode = vfscommon.CacheModeWrites
+	vfsOpt.WriteBack = 0
+	vfsOpt.UID, vfsOpt.GID = 1000, 1000
+	s, dir := newTestServer(t, DefaultOpt, vfsOpt)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "existing.txt"), []byte("potato"), 0666))
+	tc := newTestClient(t, s, 1000, 1000)
+
+	// The NULL procedure works for both programs
+	accept, _ := tc.call(progNFS, nfsVersion, 0, nil)
+	assert.Equal(t, uint32(acceptSuccess), accept)
+	accept, _ = tc.call(progMount, mountVersion, 0, nil)
+	assert.Equal(t, uint32(acceptSuccess), accept)
+
+	// Unknown programs, versions and procedures are rejected
+	accept, _ = tc.call(100227, 3, 0, nil)
+	assert.Equal(t, uint32(acceptProgUnavail), accept)
+	accept, _ = tc.call(progNFS, 4, 0, nil)
+	assert.Equal(t, uint32(acceptProgMismatch), accept)
+	accept, _ = tc.call(progNFS, nfsVersion, 22, nil)
+	assert.Equal(t, uint32(acceptProcUnavail), accept)
+	accept, _ = tc.call(progNFS, nfsVersion, 1, nil)
+	assert.Equal(t, uint32(acceptGarbageArgs), accept)
+
+	root := tc.mount("/")
+
+	// Read an existing file
+	stat, fh := tc.lookup(root, "existing.txt")
+	require.Equal(t, nfs3OK, stat)
+	stat, data, eof := tc.read(fh, 0, 100)
+	require.Equal(t, nfs3OK, stat)
+	assert.Equal(t, "potato", data)
+	assert.True(t, eof)
+	stat, data, eof = tc.read(fh, 2, 2)
+	require.Equal(t, nfs3OK, stat)
+	assert.Equal(t, "ta", data)
+	assert.False(t, eof)
+	stat, _ = tc.lookup(root, "missing.txt")
+	assert.Equal(t, nfs3ErrNoEnt, stat)
+
+	// Create a directory and a file in it, writing out of order
+	args := dirOpArgs(root, "dir")
+	emptySattr(args)
+	stat, r := tc.nfs(9, args)
+	require.Equal(t, nfs3OK, stat)
+	require.True(t, r.readBool())
+	dirFh := r.readOpaque(maxHandle)
+	stat, fh = tc.create(dirFh, "new.txt", createGuarded)
+	require.Equal(t, nfs3OK, stat)
+	stat, _ = tc.create(dirFh, "new.txt", createGuarded)
+	assert.Equal(t, nfs3ErrExist, stat)
+	require.Equal(t, nfs3OK, tc.write(fh, 6, " world"))
+	require.Equal(t, nfs3OK, tc.write(fh, 0, "hello,"))
+	stat, data, _ = tc.read(fh, 0, 100)
+	require.Equal(t, nfs3OK, stat)
+	assert.Equal(t, "hello, world", data)
+
+	// COMMIT closes the file so it is uploaded
+	args = handleArgs(fh)
+	args.writeUint64(0)
+	args.writeUint32(0)
+	stat, _ = tc.nfs(21, args)
+	require.Equal(t, nfs3OK, stat)
+	assert.Eventually(t, func() bool {
+		got, err := os.ReadFile(filepath.Join(dir, "dir", "new.txt"))
+		return err == nil && string(got) == "hello, world"
+	}, 10*time.Second, 10*time.Millisecond)
+
+	// GETATTR returns the size
+	stat, r = tc.nfs(1, handleArgs(fh))
+	require.Equal(t, nfs3OK, stat)
+	_ = r.readFixed(5 * 4)
+	assert.Equal(t, uint64(12), r.readUint64())
+
+	// List the directories
+	assert.Equal(t, []string{".", "..", "new.txt"}, tc.readdirplus(dirFh))
+	names := tc.readdirplus(root)
+	sort.Strings(names)
+	assert.Equal(t, []string{".", "..", "dir", "existing.txt"}, names)
+
+	// Rename the directory - the handles follow it
+	args = dirOpArgs(root, "dir")
+	args.writeOpaque(root)
+	args.writeString("renamed")
+	stat, _ = tc.nfs(14, args)
+	require.Equal(t, nfs3OK, stat)
+	stat, data, _ = tc.read(fh, 0, 100)
+	require.Equal(t, nfs3OK, stat)
+	assert.Equal(t, "hello, world", data)
+	_, err := os.Stat(filepath.Join(dir, "renamed", "new.txt"))
+	require.NoError(t, err)
+
+	// The directory isn't empty so can't be removed
+	stat, _ = tc.nfs(13, dirOpArgs(root, "renamed"))
+	assert.Equal(t, nfs3ErrNotEmpty, stat)
+
+	// Remove the file and directory
+	stat, _ = tc.nfs(12, dirOpArgs(dirFh, "new.txt"))
+	require.Equal(t, nfs3OK, stat)
+	stat, _ = tc.nfs(1, handleArgs(fh))
+	assert.Equal(t, nfs3ErrStale, stat)
+	stat, _ = tc.nfs(13, dirOpArgs(root, "renamed"))
+	require.Equal(t, nfs3OK, stat)
+	_, err = os.Stat(filepath.Join(dir, "renamed"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Symlinks aren't supported
+	stat, _ = tc.nfs(10, nil)
+	assert.Equal(t, nfs3ErrNotSupp, stat)
+}
+
+func TestServerPermissions(t *testing.T) {
+	vfsOpt := vfscommon.DefaultOpt
+	vfsOpt.CacheMode = vfscommon.CacheModeWrites
+	vfsOpt.UID, vfsOpt.GID = 1000, 1000
+	vfsOpt.FilePerms = 0644
+	vfsOpt.DirPerms = 0755
+	s, dir := newTestServer(t, DefaultOpt, vfsOpt)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte("potato"), 0666))
+
+	// The owner can write
+	owner := newTestClient(t, s, 1000, 1000)
+	root := owner.mount("/")
+	_, fh := owner.lookup(root, "file.txt")
+	assert.Equal(t, nfs3OK, owner.write(fh, 0, "P"))
+
+	// Another user can read but not write
+	other := newTestClient(t, s, 1001, 1001)
+	stat, data, _ := other.read(fh, 0, 100)
+	require.Equal(t, nfs3OK, stat)
+	assert.Equal(t, "Potato", data)
+	assert.Equal(t, nfs3ErrAcces, other.write(fh, 0, "p"))
+	stat, _ = other.create(root, "new.txt", createUnchecked)
+	assert.Equal(t, nfs3ErrAcces, stat)
+
+	// root is squashed to nobody so can't write either
+	rootUser := newTestClient(t, s, 0, 0)
+	assert.Equal(t, nfs3ErrAcces, rootUser.write(fh, 0, "p"))
+
+	// ACCESS reports the same
+	args := handleArgs(fh)
+	args.writeUint32(access3Read | access3Modify)
+	stat, r := other.nfs(4, args)
+	require.Equal(t, nfs3OK, stat)
+	skipAttr(r)
+	assert.Equal(t, uint32(access3Read), r.readUint32())
+}
+
+func TestServerRootNoSquash(t *testing.T) {
+	opt := DefaultOpt
+	opt.RootSquash = false
+	vfsOpt := vfscommon.DefaultOpt
+	vfsOpt.UID, vfsOpt.GID = 1000, 1000
+	vfsOpt.FilePerms = 0600
+	vfsOpt.DirPerms = 0700
+	s, _ := newTestServer(t, opt, vfsOpt)
+	tc := newTestClient(t, s, 0, 0)
+	root := tc.mount("/")
+	stat, _ := tc.create(root, "new.txt", createExclusive)
+	assert.Equal(t, nfs3OK, stat)
+}
+
+func TestServerAllow(t *testing.T) {
+	opt := DefaultOpt
+	opt.Allow = []string{"10.0.0.0/8"}
+	s, _ := newTestServer(t, opt, vfscommon.DefaultOpt)
+
+	// Connections from addresses not allowed are closed
+	c, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+	require.NoError(t, c.SetReadDeadline(time.Now().Add(10*time.Second)))
+	_, err = c.Read(make([]byte, 1))
+	assert.Error(t, err)
+
+	// Read only clients can read but not write
+	opt.AllowRO = []string{"127.0.0.1"}
+	s, dir := newTestServer(t, opt, vfscommon.DefaultOpt)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file.txt"), []byte("potato"), 0666))
+	tc := newTestClient(t, s, 1000, 1000)
+	root := tc.mount("/")
+	stat, fh := tc.lookup(root, "file.txt")
+	require.Equal(t, nfs3OK, stat)
+	stat, data, _ := tc.read(fh, 0, 100)
+	require.Equal(t, nfs3OK, stat)
+	assert.Equal(t, "potato", data)
+	assert.Equal(t, nfs3ErrROFS, tc.write(fh, 0, "P"))
+	stat, _ = tc.create(root, "new.txt", createUnchecked)
+	assert.Equal(t, nfs3ErrROFS, stat)
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := parseNetworks([]string{"192.168.1.10", "10.0.0.0/8", "::1"})
+	require.NoError(t, err)
+	require.Len(t, networks, 3)
+	assert.Equal(t, "192.168.1.10/32", networks[0].String())
+	assert.Equal(t, "10.0.0.0/8", networks[1].String())
+	assert.Equal(t, "::1/128", networks[2].String())
+
+	_, err = parseNetworks([]string{"potato"})
+	assert.Error(t, err)
+	_, err = parseNetworks([]string{"10.0.0.0/99"})
+	assert.Error(t, err)
+}
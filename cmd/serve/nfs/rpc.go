@@ -0,0 +1,293 @@
+package nfs
+
+// ONC RPC version 2 (RFC 5531) over TCP
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// RPC constants
+const (
+	rpcVersion = 2
+
+	msgCall  = 0
+	msgReply = 1
+
+	replyAccepted = 0
+	replyDenied   = 1
+
+	acceptSuccess      = 0
+	acceptProgUnavail  = 1
+	acceptProgMismatch = 2
+	acceptProcUnavail  = 3
+	acceptGarbageArgs  = 4
+
+	rejectRPCMismatch = 0
+	rejectAuthError   = 1
+
+	authNone = 0
+	authUnix = 1
+
+	authBadCred      = 1
+	lastFragment     = 1 << 31
+	maxAuthBytes     = 400
+	maxMachineName   = 255
+	maxGroups        = 16
+	maxRecordSize    = maxData + 64*1024
+	maxCallsInFlight = 16
+)
+
+// errRecordTooBig is returned if a client sends an RPC record which
+// is larger than we are prepared to read
+var errRecordTooBig = errors.New("RPC record too big")
+
+// rpcCall is the header of an RPC call
+type rpcCall struct {
+	xid        uint32
+	prog       uint32
+	vers       uint32
+	proc       uint32
+	credType   uint32
+	credBody   []byte
+	badVersion bool // set if the RPC version isn't supported
+}
+
+// readCall reads the header of an RPC call, returning an error if
+// it isn't a call
+func readCall(r *xdrReader) (call rpcCall, err error) {
+	call.xid = r.readUint32()
+	if r.readUint32() != msgCall {
+		return call, errors.New("RPC message is not a call")
+	}
+	call.badVersion = r.readUint32() != rpcVersion
+	call.prog = r.readUint32()
+	call.vers = r.readUint32()
+	call.proc = r.readUint32()
+	call.credType = r.readUint32()
+	call.credBody = r.readOpaque(maxAuthBytes)
+	_ = r.readUint32() // verifier flavor
+	_ = r.readOpaque(maxAuthBytes)
+	return call, r.err
+}
+
+// creds are the credentials of the caller after squashing
+type creds struct {
+	uid  uint32
+	gid  uint32
+	gids []uint32
+}
+
+// inGroup returns true if the caller is a member of gid
+func (c *creds) inGroup(gid uint32) bool {
+	if c.gid == gid {
+		return true
+	}
+	for _, g := range c.gids {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCreds reads the credentials out of the call and applies the
+// squash options
+func (s *server) parseCreds(call *rpcCall) (c creds, ok bool) {
+	anon := creds{uid: s.opt.AnonUID, gid: s.opt.AnonGID}
+	switch call.credType {
+	case authNone:
+		return anon, true
+	case authUnix:
+		r := newXDRReader(call.credBody)
+		_ = r.readUint32() // stamp
+		_ = r.readString(maxMachineName)
+		c.uid = r.readUint32()
+		c.gid = r.readUint32()
+		n := r.readUint32()
+		if n > maxGroups {
+			return c, false
+		}
+		for i := uint32(0); i < n; i++ {
+			c.gids = append(c.gids, r.readUint32())
+		}
+		if r.err != nil {
+			return c, false
+		}
+	default:
+		return c, false
+	}
+	if s.opt.AllSquash || (s.opt.RootSquash && c.uid == 0) {
+		return anon, true
+	}
+	if s.opt.RootSquash && c.gid == 0 {
+		c.gid = s.opt.AnonGID
+	}
+	return c, true
+}
+
+// procedure is the implementation of an RPC procedure. It reads its
+// arguments from req.args and writes its results to req.res.
+//
+// It should return errGarbageArgs if the arguments couldn't be
+// decoded.
+type procedure func(req *request) error
+
+// program is an RPC program
+type program struct {
+	name  string
+	vers  uint32
+	procs []procedure
+}
+
+// conn is a connection from a client
+type conn struct {
+	s        *server
+	c        net.Conn
+	readOnly bool // if set the client may only read
+	mu       sync.Mutex
+	wg       sync.WaitGroup
+}
+
+// serve reads calls from the connection until it is closed, running
+// them concurrently
+func (c *conn) serve(ctx context.Context) {
+	defer c.wg.Wait()
+	in := bufio.NewReader(c.c)
+	inFlight := make(chan struct{}, maxCallsInFlight)
+	for {
+		record, err := readRecord(in)
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				fs.Debugf(c.c.RemoteAddr(), "NFS connection closed: %v", err)
+			}
+			return
+		}
+		inFlight <- struct{}{}
+		c.wg.Add(1)
+		go func() {
+			defer func() {
+				<-inFlight
+				c.wg.Done()
+			}()
+			reply := c.handle(ctx, record)
+			if reply == nil {
+				return
+			}
+			err := c.writeRecord(reply)
+			if err != nil {
+				fs.Debugf(c.c.RemoteAddr(), "NFS failed to write reply: %v", err)
+				_ = c.c.Close()
+			}
+		}()
+	}
+}
+
+// handle runs the call in record returning the reply or nil if no
+// reply should be sent
+func (c *conn) handle(ctx context.Context, record []byte) []byte {
+	r := newXDRReader(record)
+	call, err := readCall(r)
+	if err != nil {
+		fs.Debugf(c.c.RemoteAddr(), "NFS dropping bad RPC call: %v", err)
+		return nil
+	}
+	w := &xdrWriter{}
+	w.writeUint32(call.xid)
+	w.writeUint32(msgReply)
+	if call.badVersion {
+		w.writeUint32(replyDenied)
+		w.writeUint32(rejectRPCMismatch)
+		w.writeUint32(rpcVersion)
+		w.writeUint32(rpcVersion)
+		return w.buf
+	}
+	cr, ok := c.s.parseCreds(&call)
+	if !ok {
+		w.writeUint32(replyDenied)
+		w.writeUint32(rejectAuthError)
+		w.writeUint32(authBadCred)
+		return w.buf
+	}
+	w.writeUint32(replyAccepted)
+	w.writeUint32(authNone) // verifier
+	w.writeUint32(0)
+	prog, ok := c.s.programs[call.prog]
+	switch {
+	case !ok:
+		w.writeUint32(acceptProgUnavail)
+		return w.buf
+	case call.vers != prog.vers:
+		w.writeUint32(acceptProgMismatch)
+		w.writeUint32(prog.vers)
+		w.writeUint32(prog.vers)
+		return w.buf
+	case call.proc >= uint32(len(prog.procs)) || prog.procs[call.proc] == nil:
+		w.writeUint32(acceptProcUnavail)
+		return w.buf
+	}
+	req := &request{
+		ctx:      ctx,
+		s:        c.s,
+		creds:    cr,
+		readOnly: c.readOnly,
+		args:     r,
+		res:      &xdrWriter{},
+	}
+	err = prog.procs[call.proc](req)
+	if err != nil {
+		fs.Debugf(c.c.RemoteAddr(), "%s procedure %d: %v", prog.name, call.proc, err)
+		w.writeUint32(acceptGarbageArgs)
+		return w.buf
+	}
+	w.writeUint32(acceptSuccess)
+	w.buf = append(w.buf, req.res.buf...)
+	return w.buf
+}
+
+// writeRecord writes data to the connection as a single fragment
+func (c *conn) writeRecord(data []byte) error {
+	buf := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(buf, lastFragment|uint32(len(data)))
+	buf = append(buf, data...)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.c.Write(buf)
+	return err
+}
+
+// readRecord reads a record made of one or more fragments
+func readRecord(in io.Reader) (record []byte, err error) {
+	var header [4]byte
+	for {
+		_, err = io.ReadFull(in, header[:])
+		if err != nil {
+			return nil, err
+		}
+		h := binary.BigEndian.Uint32(header[:])
+		n := int(h &^ lastFragment)
+		if len(record)+n > maxRecordSize {
+			return nil, errRecordTooBig
+		}
+		start := len(record)
+		record = append(record, make([]byte, n)...)
+		_, err = io.ReadFull(in, record[start:])
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("failed to read RPC record: %w", err)
+		}
+		if h&lastFragment != 0 {
+			return record, nil
+		}
+	}
+}
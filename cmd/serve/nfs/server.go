@@ -0,0 +1,190 @@
+package nfs
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+)
+
+// server contains everything to run the server
+type server struct {
+	ctx      context.Context
+	opt      Options
+	vfs      *vfs.VFS
+	allow    []*net.IPNet // networks allowed read-write access
+	allowRO  []*net.IPNet // networks allowed read-only access
+	programs map[uint32]program
+	handles  *handles
+	files    *openFiles
+	fsid     uint64
+	verifier [8]byte // write verifier which changes each run
+	listener net.Listener
+	waitChan chan struct{} // for waiting on the listener to close
+	mu       sync.Mutex
+	conns    map[*conn]struct{}
+}
+
+func newServer(ctx context.Context, VFS *vfs.VFS, opt *Options) (*server, error) {
+	s := &server{
+		ctx:      ctx,
+		opt:      *opt,
+		vfs:      VFS,
+		waitChan: make(chan struct{}),
+		conns:    make(map[*conn]struct{}),
+	}
+	var err error
+	s.allow, err = parseNetworks(opt.Allow)
+	if err != nil {
+		return nil, err
+	}
+	s.allowRO, err = parseNetworks(opt.AllowRO)
+	if err != nil {
+		return nil, err
+	}
+	var prefix [8]byte
+	_, err = rand.Read(prefix[:])
+	if err != nil {
+		return nil, err
+	}
+	_, err = rand.Read(s.verifier[:])
+	if err != nil {
+		return nil, err
+	}
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(fs.ConfigString(VFS.Fs())))
+	s.fsid = hash.Sum64()
+	s.handles = newHandles(prefix)
+	s.programs = map[uint32]program{
+		progMount: mountProgram(),
+		progNFS:   nfsProgram(),
+	}
+	return s, nil
+}
+
+// parseNetworks parses IP addresses and CIDR networks
+func parseNetworks(in []string) (out []*net.IPNet, err error) {
+	for _, s := range in {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %q", s)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network: %w", err)
+		}
+		out = append(out, network)
+	}
+	return out, nil
+}
+
+// access returns whether addr may connect and if so whether it
+// may only read
+func (s *server) access(addr net.Addr) (ok bool, readOnly bool) {
+	if len(s.allow) == 0 && len(s.allowRO) == 0 {
+		return true, false
+	}
+	tcpAddr, isTCP := addr.(*net.TCPAddr)
+	if !isTCP {
+		return false, false
+	}
+	for _, network := range s.allow {
+		if network.Contains(tcpAddr.IP) {
+			return true, false
+		}
+	}
+	for _, network := range s.allowRO {
+		if network.Contains(tcpAddr.IP) {
+			return true, true
+		}
+	}
+	return false, false
+}
+
+// Serve starts the server listening
+func (s *server) Serve() (err error) {
+	s.listener, err = net.Listen("tcp", s.opt.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for NFS connections: %w", err)
+	}
+	s.files = newOpenFiles(s.vfs)
+	fs.Logf(nil, "NFS Server listening on %v\n", s.listener.Addr())
+	go s.acceptConnections()
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (s *server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// acceptConnections accepts connections until the listener is closed
+func (s *server) acceptConnections() {
+	defer close(s.waitChan)
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		s.files.shutdown()
+	}()
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				fs.Errorf(nil, "Failed to accept NFS connection: %v", err)
+			}
+			return
+		}
+		ok, readOnly := s.access(c.RemoteAddr())
+		if !ok {
+			fs.Infof(c.RemoteAddr(), "NFS connection refused as not in --allow or --allow-ro")
+			_ = c.Close()
+			continue
+		}
+		fs.Debugf(c.RemoteAddr(), "NFS connection accepted (read only %v)", readOnly)
+		nc := &conn{s: s, c: c, readOnly: readOnly}
+		s.mu.Lock()
+		s.conns[nc] = struct{}{}
+		s.mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			nc.serve(s.ctx)
+			_ = nc.c.Close()
+			s.mu.Lock()
+			delete(s.conns, nc)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Wait blocks while the listener is open
+func (s *server) Wait() {
+	<-s.waitChan
+}
+
+// Close the listener and the connections, then close any open files
+func (s *server) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for nc := range s.conns {
+		_ = nc.c.Close()
+	}
+	s.mu.Unlock()
+	s.Wait()
+	return err
+}
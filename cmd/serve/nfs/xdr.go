@@ -0,0 +1,131 @@
+package nfs
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// errGarbageArgs is returned when the arguments to a call can't be
+// decoded
+var errGarbageArgs = errors.New("can't decode arguments")
+
+// xdrReader decodes XDR (RFC 4506) from a buffer.
+//
+// Any error is sticky so the values can be read one after another
+// and the error checked at the end.
+type xdrReader struct {
+	buf []byte
+	err error
+}
+
+// newXDRReader makes a reader for buf
+func newXDRReader(buf []byte) *xdrReader {
+	return &xdrReader{buf: buf}
+}
+
+// next returns the next n bytes or nil if there aren't enough
+func (r *xdrReader) next(n int) []byte {
+	if r.err != nil || n < 0 || n > len(r.buf) {
+		r.err = errGarbageArgs
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+// readUint32 reads an unsigned int
+func (r *xdrReader) readUint32() uint32 {
+	b := r.next(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+// readUint64 reads an unsigned hyper
+func (r *xdrReader) readUint64() uint64 {
+	b := r.next(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// readBool reads a bool
+func (r *xdrReader) readBool() bool {
+	return r.readUint32() != 0
+}
+
+// readFixed reads fixed length opaque data of n bytes
+func (r *xdrReader) readFixed(n int) []byte {
+	b := r.next(n)
+	r.next(pad(n))
+	return b
+}
+
+// readOpaque reads variable length opaque data of at most max bytes
+func (r *xdrReader) readOpaque(max int) []byte {
+	n := r.readUint32()
+	if r.err == nil && n > uint32(max) {
+		r.err = errGarbageArgs
+		return nil
+	}
+	return r.readFixed(int(n))
+}
+
+// readString reads a string of at most max bytes
+func (r *xdrReader) readString(max int) string {
+	return string(r.readOpaque(max))
+}
+
+// pad returns the number of bytes needed to pad n bytes to a
+// multiple of 4
+func pad(n int) int {
+	return (4 - n%4) % 4
+}
+
+// xdrWriter encodes XDR into a buffer
+type xdrWriter struct {
+	buf []byte
+}
+
+// writeUint32 writes an unsigned int
+func (w *xdrWriter) writeUint32(v uint32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], v)
+	w.buf = append(w.buf, b[:]...)
+}
+
+// writeUint64 writes an unsigned hyper
+func (w *xdrWriter) writeUint64(v uint64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	w.buf = append(w.buf, b[:]...)
+}
+
+// writeBool writes a bool
+func (w *xdrWriter) writeBool(v bool) {
+	if v {
+		w.writeUint32(1)
+	} else {
+		w.writeUint32(0)
+	}
+}
+
+// writeFixed writes fixed length opaque data
+func (w *xdrWriter) writeFixed(b []byte) {
+	w.buf = append(w.buf, b...)
+	w.buf = append(w.buf, make([]byte, pad(len(b)))...)
+}
+
+// writeOpaque writes variable length opaque data
+func (w *xdrWriter) writeOpaque(b []byte) {
+	w.writeUint32(uint32(len(b)))
+	w.writeFixed(b)
+}
+
+// writeString writes a string
+func (w *xdrWriter) writeString(s string) {
+	w.writeOpaque([]byte(s))
+}
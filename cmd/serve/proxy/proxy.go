@@ -109,11 +109,13 @@ backend that rclone supports.
 // Options is options for creating the proxy
 type Options struct {
 	AuthProxy string
+	UsersFile string
 }
 
 // DefaultOpt is the default values uses for Opt
 var DefaultOpt = Options{
 	AuthProxy: "",
+	UsersFile: "",
 }
 
 // Proxy represents a proxy to turn auth requests into a VFS
@@ -122,6 +124,8 @@ type Proxy struct {
 	vfsCache *libcache.Cache
 	ctx      context.Context // for global config
 	Opt      Options
+	users    *usersFile // set if using a users file instead of a program
+	f        fs.Fs      // the Fs the users file users are confined within
 }
 
 // cacheEntry is what is stored in the vfsCache
@@ -183,6 +187,9 @@ func (p *Proxy) run(in map[string]string) (config configmap.Simple, err error) {
 
 // call runs the auth proxy and returns a cacheEntry and an error
 func (p *Proxy) call(user, auth string, isPublicKey bool) (value interface{}, err error) {
+	if p.users != nil {
+		return p.callUsers(user, auth, isPublicKey)
+	}
 	var config configmap.Simple
 	// Contact the proxy
 	if isPublicKey {
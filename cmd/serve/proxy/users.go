@@ -0,0 +1,237 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	auth "github.com/abbot/go-http-auth"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/fspath"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfsflags"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UsersHelp contains text describing how to use the users file
+var UsersHelp = strings.Replace(`
+### Users file
+
+If you supply the parameter |--users-file /path/to/file| then rclone
+will authenticate users against that file and confine each of them to
+their own directory of the remote. This lets a single server share
+different parts of a remote with different users.
+
+The file is in the format of an htpasswd file with an optional extra
+field giving the path, relative to the root of the remote being
+served, which the user is confined to.
+
+    # user:password hash:path
+    alice:$2y$05$ih3C91zUBSTFcAh2mQnZYuob0UOZVEf16wl/ukgjDhjvj.xgM1WwS:home/alice
+    bob:{SHA}qvTGHdzF6KLavt4PO0gs2a6pQ00=:home/bob
+    admin:$apr1$a0j62R97$mYqFkloXH0/UOaUnAiV2b0
+
+Users without a path can see the whole remote. Paths can't escape the
+root of the remote and should exist before the user logs in.
+
+The password hashes can be made with |htpasswd| from the Apache tools,
+for example |htpasswd -nbB alice password| to make a bcrypt hash.
+Bcrypt, MD5 (|$apr1$|) and SHA1 (|{SHA}|) hashes are supported.
+
+Only password authentication is supported, so users of |serve sftp|
+can't log in with public keys when using a users file.
+
+The file is read again when it changes, but logged in users keep
+using the old password for up to 5 minutes as for |--auth-proxy|.
+
+|--users-file| and |--auth-proxy| can't be used together.
+`, "|", "`", -1)
+
+// userEntry is a single line of the users file
+type userEntry struct {
+	hash string // the htpasswd style password hash
+	root string // path within the remote the user is confined to
+}
+
+// usersFile is the parsed users file, reloaded if it changes
+type usersFile struct {
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	users   map[string]userEntry
+}
+
+// parseUsers parses the contents of a users file
+func parseUsers(in *bufio.Scanner) (users map[string]userEntry, err error) {
+	users = make(map[string]userEntry)
+	lineNumber := 0
+	for in.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(in.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) < 2 || fields[0] == "" {
+			return nil, fmt.Errorf("line %d: need user:password hash[:path]", lineNumber)
+		}
+		user, hash := fields[0], fields[1]
+		if !supportedHash(hash) {
+			return nil, fmt.Errorf("line %d: unsupported password hash for user %q", lineNumber, user)
+		}
+		if _, found := users[user]; found {
+			return nil, fmt.Errorf("line %d: duplicate user %q", lineNumber, user)
+		}
+		root := ""
+		if len(fields) == 3 {
+			// Clean the path so it can't escape the root
+			root = strings.TrimPrefix(path.Clean("/"+fields[2]), "/")
+		}
+		users[user] = userEntry{
+			hash: hash,
+			root: root,
+		}
+	}
+	if err := in.Err(); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// load reads the users file if it has changed since it was last read
+//
+// Call with the mutex held
+func (u *usersFile) load() error {
+	fi, err := os.Stat(u.path)
+	if err != nil {
+		return fmt.Errorf("failed to read users file: %w", err)
+	}
+	if u.users != nil && fi.ModTime().Equal(u.modTime) {
+		return nil
+	}
+	in, err := os.Open(u.path)
+	if err != nil {
+		return fmt.Errorf("failed to read users file: %w", err)
+	}
+	defer fs.CheckClose(in, &err)
+	users, err := parseUsers(bufio.NewScanner(in))
+	if err != nil {
+		return fmt.Errorf("failed to parse users file %q: %w", u.path, err)
+	}
+	if u.users != nil {
+		fs.Infof(nil, "Reloaded %d users from %q", len(users), u.path)
+	}
+	u.users = users
+	u.modTime = fi.ModTime()
+	return nil
+}
+
+// check checks the user and password against the users file,
+// returning the root the user is confined to
+func (u *usersFile) check(user, pass string) (root string, err error) {
+	u.mu.Lock()
+	err = u.load()
+	if err != nil {
+		// carry on with the old users if the file is broken
+		fs.Errorf(nil, "%v", err)
+	}
+	entry, found := u.users[user]
+	u.mu.Unlock()
+	if !found || !checkPassword(entry.hash, pass) {
+		return "", errors.New("users: incorrect user or password")
+	}
+	return entry.root, nil
+}
+
+// supportedHash returns whether we can check passwords against hash
+func supportedHash(hash string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$2"):
+		_, err := bcrypt.Cost([]byte(hash))
+		return err == nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		return true
+	case strings.HasPrefix(hash, "$apr1$"), strings.HasPrefix(hash, "$1$"):
+		return auth.NewMD5Entry(hash) != nil
+	}
+	return false
+}
+
+// checkPassword returns whether pass matches the htpasswd style hash
+func checkPassword(hash, pass string) bool {
+	switch {
+	case strings.HasPrefix(hash, "$2"):
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) == nil
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(pass))
+		want := "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+		return subtle.ConstantTimeCompare([]byte(hash), []byte(want)) == 1
+	case strings.HasPrefix(hash, "$apr1$"), strings.HasPrefix(hash, "$1$"):
+		entry := auth.NewMD5Entry(hash)
+		if entry == nil {
+			return false
+		}
+		want := auth.MD5Crypt([]byte(pass), entry.Salt, entry.Magic)
+		return subtle.ConstantTimeCompare([]byte(hash), want) == 1
+	}
+	return false
+}
+
+// NewUsers creates a new proxy which authenticates users with the
+// users file in opt and serves each of them the part of f they are
+// confined to.
+func NewUsers(ctx context.Context, opt *Options, f fs.Fs) (*Proxy, error) {
+	if opt.AuthProxy != "" {
+		return nil, errors.New("--auth-proxy and --users-file can't be used together")
+	}
+	p := New(ctx, opt)
+	p.f = f
+	p.users = &usersFile{path: opt.UsersFile}
+	p.users.mu.Lock()
+	err := p.users.load()
+	p.users.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	fs.Infof(nil, "Loaded %d users from %q", len(p.users.users), opt.UsersFile)
+	return p, nil
+}
+
+// callUsers checks the user against the users file and returns a
+// cacheEntry for the user's part of the remote
+func (p *Proxy) callUsers(user, pass string, isPublicKey bool) (value interface{}, err error) {
+	if isPublicKey {
+		return nil, errors.New("users: public key authentication isn't supported with --users-file")
+	}
+	root, err := p.users.check(user, pass)
+	if err != nil {
+		return nil, err
+	}
+	return p.vfsCache.Get(user, func(key string) (value interface{}, ok bool, err error) {
+		f := p.f
+		if root != "" {
+			f, err = cache.Get(p.ctx, fspath.JoinRootPath(fs.ConfigString(p.f), root))
+			if err == fs.ErrorIsFile {
+				return nil, false, fmt.Errorf("users: path %q for user %q is a file", root, user)
+			} else if err != nil {
+				return nil, false, fmt.Errorf("users: failed to create backend for %q: %w", user, err)
+			}
+		}
+		entry := cacheEntry{
+			vfs:    vfs.New(f, &vfsflags.Opt),
+			pwHash: sha256.Sum256([]byte(pass)),
+		}
+		return entry, true, nil
+	})
+}
@@ -0,0 +1,121 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Hashes of "hello" from the go-http-auth tests
+const (
+	testBcrypt = "$2y$05$ih3C91zUBSTFcAh2mQnZYuob0UOZVEf16wl/ukgjDhjvj.xgM1WwS" // hello3
+	testSHA    = "{SHA}qvTGHdzF6KLavt4PO0gs2a6pQ00="                            // hello
+	testMD5    = "$apr1$a0j62R97$mYqFkloXH0/UOaUnAiV2b0"                        // hello2
+)
+
+func TestCheckPassword(t *testing.T) {
+	for _, test := range []struct {
+		hash string
+		pass string
+		want bool
+	}{
+		{testBcrypt, "hello3", true},
+		{testBcrypt, "hello", false},
+		{testSHA, "hello", true},
+		{testSHA, "hello2", false},
+		{testMD5, "hello2", true},
+		{testMD5, "hello", false},
+		{"hello", "hello", false},
+		{"", "", false},
+	} {
+		assert.True(t, supportedHash(test.hash) || !test.want, test.hash)
+		assert.Equal(t, test.want, checkPassword(test.hash, test.pass), test.hash)
+	}
+}
+
+func TestParseUsers(t *testing.T) {
+	parse := func(in string) (map[string]userEntry, error) {
+		return parseUsers(bufio.NewScanner(strings.NewReader(in)))
+	}
+
+	users, err := parse(`# comment
+alice:` + testBcrypt + `:home/alice
+
+bob:` + testSHA + `:../../etc/
+  carol:` + testMD5 + `
+dave:` + testSHA + `:dir:with:colons
+`)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]userEntry{
+		"alice": {hash: testBcrypt, root: "home/alice"},
+		"bob":   {hash: testSHA, root: "etc"},
+		"carol": {hash: testMD5, root: ""},
+		"dave":  {hash: testSHA, root: "dir:with:colons"},
+	}, users)
+
+	for _, in := range []string{
+		"alice",
+		":" + testSHA,
+		"alice:plaintext",
+		"alice:" + testSHA + "\nalice:" + testMD5,
+	} {
+		_, err := parse(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "home", "alice"), 0777))
+	usersPath := filepath.Join(dir, "users")
+	require.NoError(t, ioutil.WriteFile(usersPath, []byte(
+		"alice:"+testSHA+":home/alice\n"+
+			"bob:"+testMD5+"\n"), 0600))
+	f, err := fs.NewFs(ctx, dir)
+	require.NoError(t, err)
+
+	opt := DefaultOpt
+	opt.UsersFile = usersPath
+	opt.AuthProxy = "potato"
+	_, err = NewUsers(ctx, &opt, f)
+	assert.Error(t, err)
+
+	opt.AuthProxy = ""
+	p, err := NewUsers(ctx, &opt, f)
+	require.NoError(t, err)
+
+	VFS, vfsKey, err := p.Call("alice", "hello", false)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", vfsKey)
+	assert.Equal(t, VFS, p.Get("alice"))
+	assert.Equal(t, filepath.ToSlash(filepath.Join(dir, "home", "alice")), VFS.Fs().Root())
+
+	VFS, _, err = p.Call("bob", "hello2", false)
+	require.NoError(t, err)
+	assert.Equal(t, f, VFS.Fs())
+
+	_, _, err = p.Call("alice", "wrong", false)
+	assert.Error(t, err)
+	_, _, err = p.Call("carol", "hello", false)
+	assert.Error(t, err)
+	_, _, err = p.Call("carol", "AAAAB3NzaC1yc2E", true)
+	assert.Error(t, err)
+
+	// Check the file is reloaded when it changes
+	require.NoError(t, ioutil.WriteFile(usersPath, []byte("carol:"+testSHA+"\n"), 0600))
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(usersPath, future, future))
+	_, _, err = p.Call("carol", "hello", false)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,118 @@
+//go:build go1.17
+// +build go1.17
+
+package restic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// errQuotaExceeded is returned when an upload would take a
+// repository over --max-size
+var errQuotaExceeded = errors.New("repository size limit exceeded")
+
+// quota keeps track of the space used by each repository
+type quota struct {
+	mu   sync.Mutex       // protects used
+	f    fs.Fs            // the remote being served
+	max  int64            // maximum size of a repository
+	used map[string]int64 // bytes used by each repository root
+}
+
+// create a new quota for repositories of at most max bytes
+func newQuota(f fs.Fs, max int64) *quota {
+	return &quota{
+		f:    f,
+		max:  max,
+		used: map[string]int64{},
+	}
+}
+
+// usage returns the bytes used by the repository at repo, measuring
+// it the first time it is seen
+//
+// Call with the mutex held
+func (q *quota) usage(ctx context.Context, repo string) (int64, error) {
+	used, found := q.used[repo]
+	if found {
+		return used, nil
+	}
+	err := walk.ListR(ctx, q.f, repo, true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		for _, entry := range entries {
+			if o, ok := entry.(fs.Object); ok && o.Size() > 0 {
+				used += o.Size()
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrorDirNotFound) {
+		return 0, fmt.Errorf("failed to measure repository size: %w", err)
+	}
+	fs.Debugf(repo, "Repository uses %v", fs.SizeSuffix(used))
+	q.used[repo] = used
+	return used, nil
+}
+
+// reserve size bytes in the repository at repo, returning
+// errQuotaExceeded if there isn't room. size may be negative if an
+// object is being replaced by a smaller one.
+func (q *quota) reserve(ctx context.Context, repo string, size int64) error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	used, err := q.usage(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if size > 0 && used+size > q.max {
+		return errQuotaExceeded
+	}
+	q.used[repo] = used + size
+	return nil
+}
+
+// release size bytes used in the repository at repo
+func (q *quota) release(repo string, size int64) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if used, found := q.used[repo]; found {
+		used -= size
+		if used < 0 {
+			used = 0
+		}
+		q.used[repo] = used
+	}
+}
+
+// repoRoot returns the root of the repository containing the remote
+// which must have come from makeRemote.
+func repoRoot(remote string) string {
+	parts := strings.Split(remote, "/")
+	n := len(parts)
+	switch {
+	case parts[n-1] == "config":
+		// repo/config
+		parts = parts[:n-1]
+	case n >= 3 && parts[n-3] == "data" && len(parts[n-2]) == 2 && strings.HasPrefix(parts[n-1], parts[n-2]):
+		// repo/data/21/2159dd48
+		parts = parts[:n-3]
+	case n >= 2:
+		// repo/type/name
+		parts = parts[:n-2]
+	default:
+		parts = nil
+	}
+	return strings.Join(parts, "/")
+}
@@ -35,6 +35,8 @@ var (
 	appendOnly   bool
 	privateRepos bool
 	cacheObjects bool
+	pruneUser    string
+	maxSize      = fs.SizeSuffix(-1)
 )
 
 func init() {
@@ -44,6 +46,8 @@ func init() {
 	flags.BoolVarP(flagSet, &appendOnly, "append-only", "", false, "Disallow deletion of repository data")
 	flags.BoolVarP(flagSet, &privateRepos, "private-repos", "", false, "Users can only access their private repo")
 	flags.BoolVarP(flagSet, &cacheObjects, "cache-objects", "", true, "Cache listed objects")
+	flags.StringVarP(flagSet, &pruneUser, "prune-user", "", "", "User allowed to delete and overwrite repository data in append-only mode")
+	flags.FVarP(flagSet, &maxSize, "max-size", "", "Maximum size of each repository")
 }
 
 // Command definition for cobra
@@ -131,11 +135,47 @@ these **must** end with /.  Eg
 
 The "--private-repos" flag can be used to limit users to repositories starting
 with a path of ` + "`/<username>/`" + `.
+
+#### Append only mode ####
+
+The "--append-only" flag stops clients deleting or overwriting data in
+the repositories, apart from removing their own locks. This protects
+the backups from a client which has been compromised, but it also
+means that "restic forget --prune" can't be run through the server.
+
+To allow pruning, use "--prune-user" to name a user who may delete and
+overwrite repository data. The other users can only add data. This
+needs authentication to be set up with "--htpasswd" or "--user" and
+"--pass", for example
+
+    rclone serve restic --append-only --htpasswd /path/to/htpasswd --prune-user admin remote:backup
+
+Run the prune from a trusted machine using the credentials of the
+prune user.
+
+#### Repository size limit ####
+
+Use "--max-size" to limit the size of each repository, for example
+"--max-size 100G". Uploads which would take a repository over the
+limit are refused with "413 Request Entity Too Large". Run a prune to
+free up space.
+
+The size of a repository is measured when it is first written to and
+then kept up to date by rclone, so changes made to the remote by other
+programs aren't noticed until rclone is restarted.
 ` + httplib.Help,
 	Run: func(command *cobra.Command, args []string) {
 		cmd.CheckArgs(1, 1, command, args)
 		f := cmd.NewFsSrc(args)
 		cmd.Run(false, true, command, func() error {
+			if pruneUser != "" {
+				if !appendOnly {
+					return errors.New("--prune-user only makes sense with --append-only")
+				}
+				if httpflags.Opt.HtPasswd == "" && httpflags.Opt.BasicUser == "" {
+					return errors.New("--prune-user needs authentication with --htpasswd or --user")
+				}
+			}
 			s := NewServer(f, &httpflags.Opt)
 			if stdio {
 				if terminal.IsTerminal(int(os.Stdout.Fd())) {
@@ -173,6 +213,7 @@ type Server struct {
 	*httplib.Server
 	f     fs.Fs
 	cache *cache
+	quota *quota // nil if the size of repositories isn't limited
 }
 
 // NewServer returns an HTTP server that speaks the rest protocol
@@ -183,6 +224,9 @@ func NewServer(f fs.Fs, opt *httplib.Options) *Server {
 		f:      f,
 		cache:  newCache(),
 	}
+	if maxSize >= 0 {
+		s.quota = newQuota(f, int64(maxSize))
+	}
 	mux.HandleFunc(s.Opt.BaseURL+"/", s.ServeHTTP)
 	return s
 }
@@ -286,12 +330,23 @@ func (s *Server) serveObject(w http.ResponseWriter, r *http.Request, remote stri
 	serve.Object(w, r, o)
 }
 
+// canModify returns whether the request may delete or overwrite
+// repository data. In append-only mode only the prune user may.
+func canModify(r *http.Request) bool {
+	if !appendOnly {
+		return true
+	}
+	user, _ := r.Context().Value(httplib.ContextUserKey).(string)
+	return pruneUser != "" && user == pruneUser
+}
+
 // postObject posts an object to the repository
 func (s *Server) postObject(w http.ResponseWriter, r *http.Request, remote string) {
-	if appendOnly {
-		// make sure the file does not exist yet
-		_, err := s.newObject(r.Context(), remote)
-		if err == nil {
+	var existing fs.Object
+	if appendOnly || s.quota != nil {
+		// find out if the file exists already
+		existing, _ = s.newObject(r.Context(), remote)
+		if existing != nil && !canModify(r) {
 			fs.Errorf(remote, "Post request: file already exists, refusing to overwrite in append-only mode")
 			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
 
@@ -299,8 +354,31 @@ func (s *Server) postObject(w http.ResponseWriter, r *http.Request, remote strin
 		}
 	}
 
+	// the size the repository grows by
+	repo, grow := repoRoot(remote), r.ContentLength
+	if existing != nil {
+		grow -= existing.Size()
+	}
+	if s.quota != nil {
+		if r.ContentLength < 0 {
+			http.Error(w, http.StatusText(http.StatusLengthRequired), http.StatusLengthRequired)
+			return
+		}
+		err := s.quota.reserve(r.Context(), repo, grow)
+		if err == errQuotaExceeded {
+			fs.Errorf(remote, "Post request: %v", err)
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			fs.Errorf(remote, "Post request: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	o, err := operations.RcatSize(r.Context(), s.f, remote, r.Body, r.ContentLength, time.Now())
 	if err != nil {
+		s.quota.release(repo, grow)
 		err = accounting.Stats(r.Context()).Error(err)
 		fs.Errorf(remote, "Post request rcat error: %v", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
@@ -314,7 +392,7 @@ func (s *Server) postObject(w http.ResponseWriter, r *http.Request, remote strin
 
 // delete the remote
 func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request, remote string) {
-	if appendOnly {
+	if !canModify(r) {
 		parts := strings.Split(r.URL.Path, "/")
 
 		// if path doesn't end in "/locks/:name", disallow the operation
@@ -341,6 +419,8 @@ func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request, remote str
 		return
 	}
 
+	s.quota.release(repoRoot(remote), o.Size())
+
 	// remove object from cache
 	s.cache.remove(remote)
 }
@@ -4,6 +4,7 @@
 package restic
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"io"
@@ -12,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/serve/httplib"
 	"github.com/rclone/rclone/cmd/serve/httplib/httpflags"
 	"github.com/rclone/rclone/fs/config/configfile"
 	"github.com/stretchr/testify/require"
@@ -138,3 +140,40 @@ func TestResticHandler(t *testing.T) {
 		})
 	}
 }
+
+// newUserRequest returns a new HTTP request made by user
+func newUserRequest(t testing.TB, user, method, path string, body io.Reader) *http.Request {
+	req := newRequest(t, method, path, body)
+	return req.WithContext(context.WithValue(req.Context(), httplib.ContextUserKey, user))
+}
+
+// TestResticPruneUser checks that only the prune user can delete and
+// overwrite data in append-only mode.
+func TestResticPruneUser(t *testing.T) {
+	// globally set append-only mode and the prune user
+	prevAppendOnly, prevPruneUser := appendOnly, pruneUser
+	appendOnly, pruneUser = true, "admin"
+	defer func() {
+		appendOnly, pruneUser = prevAppendOnly, prevPruneUser
+	}()
+
+	f := cmd.NewFsSrc([]string{t.TempDir()})
+	srv := NewServer(f, &httpflags.Opt)
+
+	for _, test := range []struct {
+		req  *http.Request
+		want int
+	}{
+		{newUserRequest(t, "user", "POST", "/?create=true", nil), http.StatusOK},
+		{newUserRequest(t, "user", "POST", "/data/0123", strings.NewReader("data")), http.StatusOK},
+		{newUserRequest(t, "user", "POST", "/data/0123", strings.NewReader("other data")), http.StatusForbidden},
+		{newUserRequest(t, "user", "DELETE", "/data/0123", nil), http.StatusForbidden},
+		{newRequest(t, "DELETE", "/data/0123", nil), http.StatusForbidden},
+		{newUserRequest(t, "admin", "POST", "/data/0123", strings.NewReader("other data")), http.StatusOK},
+		{newUserRequest(t, "admin", "DELETE", "/data/0123", nil), http.StatusOK},
+		{newUserRequest(t, "user", "GET", "/data/0123", nil), http.StatusNotFound},
+	} {
+		t.Logf("%v %v by %v", test.req.Method, test.req.URL.Path, test.req.Context().Value(httplib.ContextUserKey))
+		checkRequest(t, srv.ServeHTTP, test.req, []wantFunc{wantCode(test.want)})
+	}
+}
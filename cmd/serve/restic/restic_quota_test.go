@@ -0,0 +1,79 @@
+//go:build go1.17
+// +build go1.17
+
+package restic
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/serve/httplib/httpflags"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepoRoot(t *testing.T) {
+	for _, test := range []struct {
+		remote string
+		want   string
+	}{
+		{"config", ""},
+		{"keys/0123", ""},
+		{"data/01/0123", ""},
+		{"repo/config", "repo"},
+		{"repo/locks/0123", "repo"},
+		{"user/repo/data/01/0123", "user/repo"},
+		{"data/config", "data"},
+		{"data/index/0123", "data"},
+	} {
+		assert.Equal(t, test.want, repoRoot(test.remote), test.remote)
+	}
+}
+
+// TestResticMaxSize checks the size of each repository is limited
+func TestResticMaxSize(t *testing.T) {
+	prev := maxSize
+	maxSize = 20
+	defer func() {
+		maxSize = prev
+	}()
+
+	f := cmd.NewFsSrc([]string{t.TempDir()})
+	srv := NewServer(f, &httpflags.Opt)
+
+	chunked := newRequest(t, "POST", "/repo/data/0123", strings.NewReader("data"))
+	chunked.ContentLength = -1
+
+	for _, test := range []struct {
+		req  *http.Request
+		want int
+	}{
+		{newRequest(t, "POST", "/repo/?create=true", nil), http.StatusOK},
+		{newRequest(t, "POST", "/repo/config", strings.NewReader("0123456789")), http.StatusOK},
+		{newRequest(t, "POST", "/repo/data/0123", strings.NewReader("01234567890")), http.StatusRequestEntityTooLarge},
+		{newRequest(t, "GET", "/repo/data/0123", nil), http.StatusNotFound},
+		{chunked, http.StatusLengthRequired},
+		{newRequest(t, "POST", "/repo/data/0123", strings.NewReader("0123456789")), http.StatusOK},
+		// Each repository has its own limit
+		{newRequest(t, "POST", "/other/config", strings.NewReader("0123456789")), http.StatusOK},
+		// Overwriting and deleting frees up space
+		{newRequest(t, "POST", "/repo/config", strings.NewReader("01234")), http.StatusOK},
+		{newRequest(t, "POST", "/repo/keys/4567", strings.NewReader("01234")), http.StatusOK},
+		{newRequest(t, "POST", "/repo/keys/89ab", strings.NewReader("0")), http.StatusRequestEntityTooLarge},
+		{newRequest(t, "DELETE", "/repo/data/0123", nil), http.StatusOK},
+		{newRequest(t, "POST", "/repo/keys/89ab", strings.NewReader("0")), http.StatusOK},
+	} {
+		t.Logf("%v %v", test.req.Method, test.req.URL.Path)
+		checkRequest(t, srv.ServeHTTP, test.req, []wantFunc{wantCode(test.want)})
+	}
+
+	// A new server measures the existing repositories
+	srv = NewServer(f, &httpflags.Opt)
+	checkRequest(t, srv.ServeHTTP,
+		newRequest(t, "POST", "/repo/keys/cdef", strings.NewReader("0123456789")),
+		[]wantFunc{wantCode(http.StatusRequestEntityTooLarge)})
+	checkRequest(t, srv.ServeHTTP,
+		newRequest(t, "POST", "/repo/keys/cdef", strings.NewReader("012345")),
+		[]wantFunc{wantCode(http.StatusOK)})
+}
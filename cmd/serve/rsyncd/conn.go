@@ -0,0 +1,243 @@
+package rsyncd
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+	"golang.org/x/crypto/md4"
+)
+
+// conn is a connection from a client
+type conn struct {
+	s    *server
+	c    net.Conn
+	vfs  *vfs.VFS
+	in   *countingReader
+	out  *countingWriter
+	r    *reader
+	w    *writer
+	opt  *options
+	seed int32 // checksum seed sent to the client
+}
+
+// newConn makes a conn for c
+func newConn(s *server, c net.Conn) *conn {
+	dc := &conn{
+		s:   s,
+		c:   c,
+		vfs: s.vfs,
+		in:  &countingReader{r: c},
+		out: &countingWriter{w: c},
+	}
+	dc.r = newReader(dc.in)
+	dc.w = newWriter(dc.out)
+	return dc
+}
+
+// serve runs the connection until the transfer is finished
+func (c *conn) serve() {
+	err := c.run()
+	if err == nil {
+		fs.Debugf(c.c.RemoteAddr(), "rsync connection finished")
+	} else if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+		fs.Debugf(c.c.RemoteAddr(), "rsync connection closed: %v", err)
+	} else {
+		fs.Errorf(c.c.RemoteAddr(), "rsync connection failed: %v", err)
+	}
+}
+
+// run the protocol
+func (c *conn) run() error {
+	c.w.writeString(fmt.Sprintf("@RSYNCD: %d.0\n", protocolVersion))
+	err := c.w.flush()
+	if err != nil {
+		return err
+	}
+	greeting := c.r.readLine()
+	if c.r.err != nil {
+		return c.r.err
+	}
+	var version int
+	if _, err := fmt.Sscanf(greeting, "@RSYNCD: %d", &version); err != nil {
+		return c.refuse("protocol startup error")
+	}
+	if version < protocolVersion {
+		return c.refuse(fmt.Sprintf("protocol version %d isn't supported - rsync 2.6.0 or later is needed", version))
+	}
+
+	module := c.r.readLine()
+	if c.r.err != nil {
+		return c.r.err
+	}
+	if module == "" || module == "#list" {
+		c.w.writeString(fmt.Sprintf("%-15s\t%s\n", c.s.opt.Module, c.s.opt.Comment))
+		c.w.writeString("@RSYNCD: EXIT\n")
+		return c.w.flush()
+	}
+	if module != c.s.opt.Module {
+		return c.refuse(fmt.Sprintf("Unknown module '%s'", module))
+	}
+	if c.s.opt.User != "" {
+		err = c.authenticate()
+		if err != nil {
+			return err
+		}
+	}
+	c.w.writeString("@RSYNCD: OK\n")
+	err = c.w.flush()
+	if err != nil {
+		return err
+	}
+
+	var args []string
+	for {
+		arg := c.r.readDelimited("\n\x00")
+		if c.r.err != nil {
+			return c.r.err
+		}
+		if arg == "" {
+			break
+		}
+		if len(args) >= maxArgs {
+			return errors.New("too many arguments")
+		}
+		args = append(args, arg)
+	}
+	fs.Debugf(c.c.RemoteAddr(), "rsync arguments %q", args)
+	c.opt, err = parseOptions(args)
+	if err == nil && !c.opt.sender && c.vfs.Opt.ReadOnly {
+		err = errors.New("module is read only")
+	}
+
+	// Once the arguments are read the protocol is set up by
+	// sending the checksum seed, then the output is multiplexed so
+	// any errors can be shown to the client.
+	c.seed = 0
+	if err == nil {
+		c.seed = c.opt.checksumSeed
+	}
+	if c.seed == 0 {
+		var buf [4]byte
+		_, _ = rand.Read(buf[:])
+		c.seed = int32(binary.LittleEndian.Uint32(buf[:]) >> 1)
+	}
+	c.w.writeInt(c.seed)
+	c.w.startMux()
+	if err != nil {
+		c.w.writeMessage(msgError, fmt.Sprintf("rclone: %v\n", err))
+		_ = c.w.flush()
+		return err
+	}
+	err = c.w.flush()
+	if err != nil {
+		return err
+	}
+	if c.opt.sender {
+		return c.send()
+	}
+	return c.receive()
+}
+
+// refuse sends an error to the client before the transfer has
+// started and returns it
+func (c *conn) refuse(msg string) error {
+	c.w.writeString("@ERROR: " + msg + "\n")
+	_ = c.w.flush()
+	return errors.New(msg)
+}
+
+// authResponse returns the response to challenge the client should
+// give for pass.
+//
+// This is the base64 encoded MD4 sum of the password and the
+// challenge, which for protocols before 30 has a zero checksum seed
+// in front.
+func authResponse(pass, challenge string) string {
+	hash := md4.New()
+	_, _ = hash.Write([]byte{0, 0, 0, 0})
+	_, _ = hash.Write([]byte(pass))
+	_, _ = hash.Write([]byte(challenge))
+	return base64.RawStdEncoding.EncodeToString(hash.Sum(nil))
+}
+
+// authenticate challenges the client for the user and password
+func (c *conn) authenticate() error {
+	var buf [16]byte
+	_, err := rand.Read(buf[:])
+	if err != nil {
+		return err
+	}
+	challenge := base64.RawStdEncoding.EncodeToString(buf[:])
+	c.w.writeString("@RSYNCD: AUTHREQD " + challenge + "\n")
+	err = c.w.flush()
+	if err != nil {
+		return err
+	}
+	line := c.r.readLine()
+	if c.r.err != nil {
+		return c.r.err
+	}
+	user, response, _ := strings.Cut(line, " ")
+	want := authResponse(c.s.opt.Pass, challenge)
+	if user != c.s.opt.User || subtle.ConstantTimeCompare([]byte(response), []byte(want)) != 1 {
+		fs.Infof(c.c.RemoteAddr(), "rsync authentication failed for user %q", user)
+		return c.refuse("auth failed on module " + c.s.opt.Module)
+	}
+	return nil
+}
+
+// errorf logs an error which doesn't stop the transfer and shows it
+// to the client
+func (c *conn) errorf(format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	fs.Errorf(c.c.RemoteAddr(), "rsync: %s", msg)
+	c.w.writeMessage(msgError, "rclone: "+msg+"\n")
+}
+
+// infof shows a message to the client if it asked for verbose output
+func (c *conn) infof(format string, a ...interface{}) {
+	if c.opt.verbose > 0 {
+		c.w.writeMessage(msgInfo, fmt.Sprintf(format, a...)+"\n")
+	}
+}
+
+// modulePath converts a path sent by the client, which starts with the
+// module name, into a path in the VFS. It also returns whether the
+// path refers to the contents of a directory rather than the
+// directory itself, which rsync shows with a trailing /.
+func (c *conn) modulePath(p string) (string, bool) {
+	module := c.s.opt.Module
+	if p == module || strings.HasPrefix(p, module+"/") {
+		p = p[len(module):]
+	}
+	contents := p == "" || p == "." || strings.HasSuffix(p, "/") || strings.HasSuffix(p, "/.")
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	return p, contents
+}
+
+// safeName returns whether a name from the client's file list stays
+// inside the directory it is being received into
+func safeName(name string) bool {
+	if name == "." {
+		return true
+	}
+	if name == "" || strings.HasPrefix(name, "/") {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
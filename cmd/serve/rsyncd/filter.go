@@ -0,0 +1,86 @@
+package rsyncd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rclone/rclone/fs/filter"
+)
+
+// filterRule is an include or exclude rule sent by the client
+type filterRule struct {
+	include bool
+	dirOnly bool // only matches directories
+	re      *regexp.Regexp
+}
+
+// filterRules are the rules sent by the client. The first rule
+// which matches a name decides whether it is excluded.
+type filterRules []filterRule
+
+// add parses rule and adds it to the rules.
+//
+// Clients using protocol 27 send a rule as the pattern with an
+// optional "+ " or "- " prefix, or "!" to clear the rules.
+func (rules filterRules) add(rule string) (filterRules, error) {
+	if rule == "!" {
+		return nil, nil
+	}
+	r := filterRule{}
+	pattern := rule
+	if strings.HasPrefix(pattern, "+ ") {
+		r.include = true
+		pattern = pattern[2:]
+	} else {
+		pattern = strings.TrimPrefix(pattern, "- ")
+	}
+	if len(pattern) > 1 && strings.HasSuffix(pattern, "/") {
+		r.dirOnly = true
+		pattern = pattern[:len(pattern)-1]
+	}
+	var err error
+	r.re, err = filter.GlobToRegexp(pattern, false)
+	if err != nil {
+		return nil, fmt.Errorf("bad filter rule %q: %w", rule, err)
+	}
+	return append(rules, r), nil
+}
+
+// excluded returns whether name, which is relative to the top of
+// the transfer, is excluded by the rules
+func (rules filterRules) excluded(name string, isDir bool) bool {
+	if name == "." {
+		return false
+	}
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.re.MatchString(name) {
+			return !r.include
+		}
+	}
+	return false
+}
+
+// readFilterRules reads the filter rules sent by the client
+func readFilterRules(r *reader) (rules filterRules, err error) {
+	for {
+		n := r.readInt()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if n == 0 {
+			return rules, nil
+		}
+		rule := r.readBuf(int(n))
+		if r.err != nil {
+			return nil, r.err
+		}
+		rules, err = rules.add(string(rule))
+		if err != nil {
+			return nil, err
+		}
+	}
+}
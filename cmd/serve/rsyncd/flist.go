@@ -0,0 +1,290 @@
+package rsyncd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+	"golang.org/x/crypto/md4"
+)
+
+// File types in the mode of a file list entry, which are the Linux
+// values whatever the platform
+const (
+	modeTypeMask = 0170000
+	modeSocket   = 0140000
+	modeSymlink  = 0120000
+	modeRegular  = 0100000
+	modeBlock    = 0060000
+	modeDir      = 0040000
+	modeChar     = 0020000
+	modeFIFO     = 0010000
+)
+
+// Flags of a file list entry in protocol 27 saying which fields are
+// the same as the previous entry and so aren't sent
+const (
+	xmitTopDir   = 1 << 0
+	xmitSameMode = 1 << 1
+	xmitSameRdev = 1 << 2
+	xmitSameUID  = 1 << 3
+	xmitSameGID  = 1 << 4
+	xmitSameName = 1 << 5
+	xmitLongName = 1 << 6
+	xmitSameTime = 1 << 7
+)
+
+// errBadFileList is returned if the file list from the client can't
+// be decoded
+var errBadFileList = errors.New("bad file list entry")
+
+// fileEntry is an entry in the file list
+type fileEntry struct {
+	name     string // path relative to the top of the transfer, "." for the top itself
+	size     int64
+	modTime  int32 // seconds since the epoch
+	mode     uint32
+	uid      uint32
+	gid      uint32
+	topDir   bool     // a directory given as an argument
+	checksum []byte   // MD4 of the contents if --checksum is in use
+	node     vfs.Node // node being sent if we are the sender
+}
+
+// fileType returns the type bits of the mode
+func (e *fileEntry) fileType() uint32 {
+	return e.mode & modeTypeMask
+}
+
+// isDir returns whether the entry is a directory
+func (e *fileEntry) isDir() bool {
+	return e.fileType() == modeDir
+}
+
+// isRegular returns whether the entry is a regular file
+func (e *fileEntry) isRegular() bool {
+	return e.fileType() == modeRegular
+}
+
+// hasRdev returns whether the entry is a device or special file and
+// so has a device number on the wire if devices are preserved
+func (e *fileEntry) hasRdev() bool {
+	switch e.fileType() {
+	case modeChar, modeBlock, modeFIFO, modeSocket:
+		return true
+	}
+	return false
+}
+
+// wireMode converts an os.FileMode into the mode sent on the wire
+func wireMode(mode os.FileMode) uint32 {
+	if mode.IsDir() {
+		return modeDir | uint32(mode.Perm())
+	}
+	return modeRegular | uint32(mode.Perm())
+}
+
+// fileList is the list of files in a transfer.
+//
+// Both ends sort the list the same way so they can refer to the
+// files by their index.
+type fileList []*fileEntry
+
+// sort sorts the list in the order rsync uses for protocol 27 which
+// is by the bytes of the name
+func (list fileList) sort() {
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].name < list[j].name
+	})
+}
+
+// writeFileList sends the file list followed by the empty user and
+// group name lists and the I/O error flag
+func writeFileList(w *writer, opt *options, list fileList, ioError int32) {
+	var last fileEntry
+	for _, e := range list {
+		var flags byte
+		if e.topDir && e.isDir() {
+			flags |= xmitTopDir
+		}
+		if e.mode == last.mode {
+			flags |= xmitSameMode
+		}
+		if opt.preserveUID && e.uid == last.uid {
+			flags |= xmitSameUID
+		}
+		if opt.preserveGID && e.gid == last.gid {
+			flags |= xmitSameGID
+		}
+		if e.modTime == last.modTime {
+			flags |= xmitSameTime
+		}
+		l1 := 0
+		for l1 < len(e.name) && l1 < len(last.name) && l1 < 255 && e.name[l1] == last.name[l1] {
+			l1++
+		}
+		if l1 > 0 {
+			flags |= xmitSameName
+		}
+		l2 := len(e.name) - l1
+		if l2 > 255 {
+			flags |= xmitLongName
+		}
+		if flags == 0 {
+			// Zero flags end the list so set a flag which
+			// makes no difference
+			if e.isDir() {
+				flags |= xmitLongName
+			} else {
+				flags |= xmitTopDir
+			}
+		}
+		w.writeByte(flags)
+		if flags&xmitSameName != 0 {
+			w.writeByte(byte(l1))
+		}
+		if flags&xmitLongName != 0 {
+			w.writeInt(int32(l2))
+		} else {
+			w.writeByte(byte(l2))
+		}
+		w.writeString(e.name[l1:])
+		w.writeLongint(e.size)
+		if flags&xmitSameTime == 0 {
+			w.writeInt(e.modTime)
+		}
+		if flags&xmitSameMode == 0 {
+			w.writeInt(int32(e.mode))
+		}
+		if opt.preserveUID && flags&xmitSameUID == 0 {
+			w.writeInt(int32(e.uid))
+		}
+		if opt.preserveGID && flags&xmitSameGID == 0 {
+			w.writeInt(int32(e.gid))
+		}
+		if opt.alwaysChecksum {
+			// Protocol 27 sends a checksum for every entry
+			sum := e.checksum
+			if len(sum) != sumLength {
+				sum = make([]byte, sumLength)
+			}
+			w.writeBytes(sum)
+		}
+		last = *e
+	}
+	w.writeByte(0)
+	if !opt.numericIDs {
+		// We don't send any names for the ids
+		if opt.preserveUID {
+			w.writeInt(0)
+		}
+		if opt.preserveGID {
+			w.writeInt(0)
+		}
+	}
+	w.writeInt(ioError)
+}
+
+// readFileList reads the file list sent by the client followed by
+// the user and group name lists and the I/O error flag.
+//
+// The list isn't sorted.
+func readFileList(r *reader, opt *options) (list fileList, ioError int32, err error) {
+	var last fileEntry
+	for {
+		flags := r.readByte()
+		if r.err != nil {
+			return nil, 0, r.err
+		}
+		if flags == 0 {
+			break
+		}
+		l1 := 0
+		if flags&xmitSameName != 0 {
+			l1 = int(r.readByte())
+		}
+		var l2 int
+		if flags&xmitLongName != 0 {
+			l2 = int(r.readInt())
+		} else {
+			l2 = int(r.readByte())
+		}
+		if l1 > len(last.name) {
+			return nil, 0, errBadFileList
+		}
+		e := &fileEntry{
+			name:    last.name[:l1] + string(r.readBuf(l2)),
+			modTime: last.modTime,
+			mode:    last.mode,
+			uid:     last.uid,
+			gid:     last.gid,
+		}
+		e.size = r.readLongint()
+		if flags&xmitSameTime == 0 {
+			e.modTime = r.readInt()
+		}
+		if flags&xmitSameMode == 0 {
+			e.mode = uint32(r.readInt())
+		}
+		if opt.preserveUID && flags&xmitSameUID == 0 {
+			e.uid = uint32(r.readInt())
+		}
+		if opt.preserveGID && flags&xmitSameGID == 0 {
+			e.gid = uint32(r.readInt())
+		}
+		if opt.preserveDevices && e.hasRdev() && flags&xmitSameRdev == 0 {
+			_ = r.readInt()
+		}
+		if opt.preserveLinks && e.fileType() == modeSymlink {
+			_ = r.readBuf(int(r.readInt()))
+		}
+		if opt.alwaysChecksum {
+			e.checksum = r.readBuf(sumLength)
+		}
+		e.topDir = flags&xmitTopDir != 0 && e.isDir()
+		if r.err != nil {
+			return nil, 0, r.err
+		}
+		list = append(list, e)
+		last = *e
+	}
+	if !opt.numericIDs {
+		if opt.preserveUID {
+			readIDList(r)
+		}
+		if opt.preserveGID {
+			readIDList(r)
+		}
+	}
+	ioError = r.readInt()
+	return list, ioError, r.err
+}
+
+// readIDList reads and discards a list of user or group names
+func readIDList(r *reader) {
+	for r.err == nil {
+		if r.readInt() == 0 {
+			return
+		}
+		_ = r.readBuf(int(r.readByte()))
+	}
+}
+
+// fileChecksum returns the MD4 checksum of the contents of node
+// which is what rsync compares for --checksum
+func fileChecksum(node vfs.Node) (sum []byte, err error) {
+	h, err := node.Open(os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.CheckClose(h, &err)
+	hash := md4.New()
+	_, err = io.Copy(hash, h)
+	if err != nil {
+		return nil, err
+	}
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,278 @@
+package rsyncd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// Protocol constants
+const (
+	protocolVersion = 27        // version of the rsync protocol we speak
+	chunkSize       = 32 * 1024 // largest block of literal data sent in one go
+	maxLineLen      = 4096      // longest line, argument or file name accepted
+	maxArgs         = 1024      // most arguments accepted
+	sumLength       = 16        // length of an MD4 checksum
+
+	// Tags of the multiplexed messages sent to the client
+	mplexBase = 7
+	msgData   = 0
+	msgError  = 1 // MSG_ERROR_XFER which clients before 3.0 know as MSG_ERROR
+	msgInfo   = 2
+)
+
+// errTooLong is returned if the client sends a line or name which is
+// longer than we are prepared to read
+var errTooLong = errors.New("line too long")
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader
+func (cr *countingReader) Read(p []byte) (n int, err error) {
+	n, err = cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write implements io.Writer
+func (cw *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// reader decodes the values sent by the client, which are little
+// endian.
+//
+// Any error is sticky so the values can be read one after another
+// and the error checked at the end.
+type reader struct {
+	r   *bufio.Reader
+	err error
+}
+
+// newReader makes a reader for in
+func newReader(in io.Reader) *reader {
+	return &reader{r: bufio.NewReaderSize(in, 64*1024)}
+}
+
+// readFull reads len(p) bytes into p
+func (r *reader) readFull(p []byte) {
+	if r.err != nil {
+		return
+	}
+	_, r.err = io.ReadFull(r.r, p)
+}
+
+// readBuf reads n bytes
+func (r *reader) readBuf(n int) []byte {
+	if r.err == nil && (n < 0 || n > maxLineLen) {
+		r.err = errTooLong
+	}
+	if r.err != nil {
+		return nil
+	}
+	buf := make([]byte, n)
+	r.readFull(buf)
+	return buf
+}
+
+// discard skips n bytes
+func (r *reader) discard(n int64) {
+	if r.err != nil {
+		return
+	}
+	_, r.err = io.CopyN(ioutil.Discard, r.r, n)
+}
+
+// readByte reads a single byte
+func (r *reader) readByte() byte {
+	var buf [1]byte
+	r.readFull(buf[:])
+	return buf[0]
+}
+
+// readInt reads a 32 bit int
+func (r *reader) readInt() int32 {
+	var buf [4]byte
+	r.readFull(buf[:])
+	return int32(binary.LittleEndian.Uint32(buf[:]))
+}
+
+// readLongint reads a 64 bit int which is sent as a 32 bit int if
+// it fits
+func (r *reader) readLongint() int64 {
+	x := r.readInt()
+	if x != -1 {
+		return int64(x)
+	}
+	var buf [8]byte
+	r.readFull(buf[:])
+	return int64(binary.LittleEndian.Uint64(buf[:]))
+}
+
+// readDelimited reads up to and not including the first delimiter
+// byte in delims
+func (r *reader) readDelimited(delims string) string {
+	var line []byte
+	for r.err == nil {
+		b, err := r.r.ReadByte()
+		if err != nil {
+			r.err = err
+			break
+		}
+		for i := 0; i < len(delims); i++ {
+			if b == delims[i] {
+				return string(line)
+			}
+		}
+		if len(line) >= maxLineLen {
+			r.err = errTooLong
+			break
+		}
+		line = append(line, b)
+	}
+	return ""
+}
+
+// readLine reads a line of text without the line ending
+func (r *reader) readLine() string {
+	line := r.readDelimited("\n")
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line
+}
+
+// writer encodes the values sent to the client.
+//
+// Once multiplexing is started everything written is sent as data
+// messages which text messages for the client to show can be mixed
+// with. It may be used from several goroutines at once.
+//
+// Like reader any error is sticky.
+type writer struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	mux bool   // set if the output is multiplexed
+	buf []byte // data waiting to be sent in a message
+	err error
+}
+
+// newWriter makes a writer for out
+func newWriter(out io.Writer) *writer {
+	return &writer{w: bufio.NewWriterSize(out, 64*1024)}
+}
+
+// startMux starts multiplexing the output
+func (w *writer) startMux() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.mux = true
+}
+
+// writeHeader writes a message header. Call with the mutex held.
+func (w *writer) writeHeader(tag byte, n int) {
+	var header [4]byte
+	binary.LittleEndian.PutUint32(header[:], uint32(mplexBase+tag)<<24|uint32(n))
+	w.write(header[:])
+}
+
+// write writes to the connection. Call with the mutex held.
+func (w *writer) write(p []byte) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = w.w.Write(p)
+}
+
+// flushData sends any data waiting in a message. Call with the mutex
+// held.
+func (w *writer) flushData() {
+	if len(w.buf) == 0 {
+		return
+	}
+	w.writeHeader(msgData, len(w.buf))
+	w.write(w.buf)
+	w.buf = w.buf[:0]
+}
+
+// writeBytes writes p
+func (w *writer) writeBytes(p []byte) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.mux {
+		w.write(p)
+		return
+	}
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= chunkSize {
+		w.flushData()
+	}
+}
+
+// writeString writes s
+func (w *writer) writeString(s string) {
+	w.writeBytes([]byte(s))
+}
+
+// writeByte writes a single byte
+func (w *writer) writeByte(b byte) {
+	w.writeBytes([]byte{b})
+}
+
+// writeInt writes a 32 bit int
+func (w *writer) writeInt(x int32) {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(x))
+	w.writeBytes(buf[:])
+}
+
+// writeLongint writes a 64 bit int, as a 32 bit int if it fits
+func (w *writer) writeLongint(x int64) {
+	if x >= 0 && x <= 0x7FFFFFFF {
+		w.writeInt(int32(x))
+		return
+	}
+	var buf [12]byte
+	binary.LittleEndian.PutUint32(buf[:4], 0xFFFFFFFF)
+	binary.LittleEndian.PutUint64(buf[4:], uint64(x))
+	w.writeBytes(buf[:])
+}
+
+// writeMessage sends text for the client to show. This is only
+// possible once multiplexing has started.
+func (w *writer) writeMessage(tag byte, text string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.mux {
+		return
+	}
+	w.flushData()
+	w.writeHeader(tag, len(text))
+	w.write([]byte(text))
+}
+
+// flush sends everything written so far returning any error
+func (w *writer) flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushData()
+	if w.err == nil {
+		w.err = w.w.Flush()
+	}
+	return w.err
+}
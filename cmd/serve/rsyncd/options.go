@@ -0,0 +1,228 @@
+package rsyncd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// options are the rsync options the client sends as arguments
+type options struct {
+	sender          bool // the server is sending the files
+	verbose         int
+	recursive       bool
+	dirs            bool // transfer directories without recursing
+	preserveLinks   bool
+	preserveUID     bool
+	preserveGID     bool
+	preserveDevices bool // devices and special files
+	preserveTimes   bool
+	alwaysChecksum  bool
+	ignoreTimes     bool
+	sizeOnly        bool
+	update          bool
+	existing        bool
+	ignoreExisting  bool
+	dryRun          bool
+	numericIDs      bool
+	deleteMode      bool
+	deleteExcluded  bool
+	pruneEmptyDirs  bool
+	maxDelete       int // -1 for no limit
+	modifyWindow    time.Duration
+	checksumSeed    int32    // 0 to choose one
+	paths           []string // the paths after the options
+}
+
+// unsupportedShort describes the short options which change the
+// protocol in ways we don't implement
+var unsupportedShort = map[byte]string{
+	'z': "compression (-z)",
+	'R': "relative paths (-R)",
+	'H': "hard links (-H)",
+	'A': "ACLs (-A)",
+	'X': "extended attributes (-X)",
+	'b': "backups (-b)",
+	's': "--protect-args (-s)",
+}
+
+// ignoredShort are the short options which only affect the client or
+// which make no difference to a remote
+const ignoredShort = "qpLkKxESWhOJCyPi8"
+
+// ignoredLong are the long options which only affect the client or
+// which make no difference to a remote
+var ignoredLong = map[string]bool{
+	"server":            true,
+	"ignore-errors":     true,
+	"force":             true,
+	"partial":           true,
+	"inplace":           true,
+	"delay-updates":     true,
+	"safe-links":        true,
+	"copy-unsafe-links": true,
+	"no-implied-dirs":   true,
+	"omit-dir-times":    true,
+	"omit-link-times":   true,
+	"list-only":         true,
+	"super":             true,
+	"no-super":          true,
+	"fake-super":        true,
+	"whole-file":        true,
+	"no-whole-file":     true,
+}
+
+// longWithValue are the long options which take a value
+var longWithValue = map[string]bool{
+	"modify-window": true,
+	"max-delete":    true,
+	"checksum-seed": true,
+	"partial-dir":   true,
+	"temp-dir":      true,
+	"block-size":    true,
+	"timeout":       true,
+	"contimeout":    true,
+	"bwlimit":       true,
+	"log-format":    true,
+	"out-format":    true,
+}
+
+// parseOptions parses the arguments sent by the client
+func parseOptions(args []string) (*options, error) {
+	o := &options{maxDelete: -1}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			o.paths = append(o.paths, args[i+1:]...)
+			i = len(args)
+		case strings.HasPrefix(arg, "--"):
+			name, value, hasValue := strings.Cut(arg[2:], "=")
+			if longWithValue[name] && !hasValue {
+				i++
+				if i >= len(args) {
+					return nil, fmt.Errorf("--%s needs a value", name)
+				}
+				value = args[i]
+			}
+			err := o.parseLong(name, value)
+			if err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			takesNext, err := o.parseShort(arg[1:])
+			if err != nil {
+				return nil, err
+			}
+			if takesNext {
+				i++
+			}
+		default:
+			o.paths = append(o.paths, arg)
+		}
+	}
+	// The first path is the directory to change into which isn't
+	// used by a daemon
+	if len(o.paths) == 0 {
+		return nil, errors.New("no paths given")
+	}
+	o.paths = o.paths[1:]
+	if !o.sender && o.pruneEmptyDirs {
+		return nil, errors.New("pruning empty directories (-m) isn't supported when sending to rclone serve rsyncd")
+	}
+	return o, nil
+}
+
+// parseShort parses a group of short options, returning whether
+// the option value is the next argument
+func (o *options) parseShort(group string) (takesNext bool, err error) {
+	for i := 0; i < len(group); i++ {
+		c := group[i]
+		switch c {
+		case 'v':
+			o.verbose++
+		case 'r':
+			o.recursive = true
+		case 'd':
+			o.dirs = true
+		case 'l':
+			o.preserveLinks = true
+		case 'o':
+			o.preserveUID = true
+		case 'g':
+			o.preserveGID = true
+		case 'D':
+			o.preserveDevices = true
+		case 't':
+			o.preserveTimes = true
+		case 'c':
+			o.alwaysChecksum = true
+		case 'I':
+			o.ignoreTimes = true
+		case 'u':
+			o.update = true
+		case 'n':
+			o.dryRun = true
+		case 'm':
+			o.pruneEmptyDirs = true
+		case 'e', 'B', 'T':
+			// These take the rest of the group as their value
+			// or the next argument. Clients send their
+			// capabilities as the value of -e.
+			return i == len(group)-1, nil
+		default:
+			if what, found := unsupportedShort[c]; found {
+				return false, fmt.Errorf("%s isn't supported by rclone serve rsyncd", what)
+			}
+			if strings.IndexByte(ignoredShort, c) < 0 {
+				return false, fmt.Errorf("-%c isn't supported by rclone serve rsyncd", c)
+			}
+		}
+	}
+	return false, nil
+}
+
+// parseLong parses a long option
+func (o *options) parseLong(name, value string) (err error) {
+	switch name {
+	case "sender":
+		o.sender = true
+	case "delete", "del", "delete-before", "delete-during", "delete-after", "delete-delay":
+		o.deleteMode = true
+	case "delete-excluded":
+		o.deleteMode = true
+		o.deleteExcluded = true
+	case "numeric-ids":
+		o.numericIDs = true
+	case "size-only":
+		o.sizeOnly = true
+	case "ignore-times":
+		o.ignoreTimes = true
+	case "existing", "ignore-non-existing":
+		o.existing = true
+	case "ignore-existing":
+		o.ignoreExisting = true
+	case "modify-window":
+		var n int
+		n, err = strconv.Atoi(value)
+		if n > 0 {
+			o.modifyWindow = time.Duration(n) * time.Second
+		}
+	case "max-delete":
+		o.maxDelete, err = strconv.Atoi(value)
+	case "checksum-seed":
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 32)
+		o.checksumSeed = int32(n)
+	default:
+		if !ignoredLong[name] && !longWithValue[name] {
+			return fmt.Errorf("--%s isn't supported by rclone serve rsyncd", name)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("bad value for --%s: %w", name, err)
+	}
+	return nil
+}
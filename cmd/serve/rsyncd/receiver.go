@@ -0,0 +1,485 @@
+package rsyncd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+)
+
+// receiver receives the files the client sends.
+//
+// Like rsync it runs a generator, which tells the client which files
+// it wants, alongside the receiver proper, which writes the files the
+// client sends. The client sends the files in two phases, the second
+// being for the files which failed verification in the first.
+type receiver struct {
+	c          *conn
+	list       fileList
+	dest       string        // destination directory, or file if file is set
+	file       bool          // set if a single file is written to dest
+	window     time.Duration // modification times closer than this are the same
+	phase0Once sync.Once
+	phase0Done chan struct{} // closed when the first phase is finished
+	redo       []int32       // files to ask for again - only read once phase0Done is closed
+}
+
+// receive receives files from the client
+func (c *conn) receive() error {
+	var rules filterRules
+	var err error
+	// The client only sends the filter rules if we need them
+	if c.opt.pruneEmptyDirs || (c.opt.deleteMode && !c.opt.deleteExcluded) {
+		rules, err = readFilterRules(c.r)
+		if err != nil {
+			return err
+		}
+	}
+	list, ioError, err := readFileList(c.r, c.opt)
+	if err != nil {
+		return err
+	}
+	list.sort()
+	fs.Debugf(c.c.RemoteAddr(), "rsync received file list of %d entries", len(list))
+
+	rv := &receiver{
+		c:          c,
+		list:       list,
+		window:     fs.GetModifyWindow(c.s.ctx, c.vfs.Fs()),
+		phase0Done: make(chan struct{}),
+	}
+	if rv.window != fs.ModTimeNotSupported && c.opt.modifyWindow > rv.window {
+		rv.window = c.opt.modifyWindow
+	}
+	err = rv.setDestination()
+	if err != nil {
+		c.errorf("%v", err)
+		_ = c.w.flush()
+		return err
+	}
+
+	genErr := make(chan error, 1)
+	go func() {
+		genErr <- rv.generate()
+	}()
+	err = rv.receiveFiles()
+	rv.finishPhase0()
+	if err != nil {
+		// Make sure the generator isn't stuck writing
+		_ = c.c.Close()
+		<-genErr
+		return err
+	}
+	err = <-genErr
+	if err != nil {
+		return err
+	}
+
+	if c.opt.deleteMode {
+		if ioError != 0 {
+			c.errorf("IO error encountered - skipping file deletion")
+		} else {
+			rv.deleteExtraneous(rules)
+		}
+	}
+	c.w.writeInt(-1)
+	return c.w.flush()
+}
+
+// finishPhase0 lets the generator know the first phase is finished
+func (rv *receiver) finishPhase0() {
+	rv.phase0Once.Do(func() {
+		close(rv.phase0Done)
+	})
+}
+
+// setDestination works out where the files are written.
+//
+// Like rsync, if a single file is sent and the destination isn't a
+// directory the file is written as the destination, otherwise the
+// destination is a directory which is made if needed.
+func (rv *receiver) setDestination() error {
+	c := rv.c
+	arg := c.s.opt.Module
+	if len(c.opt.paths) > 0 {
+		arg = c.opt.paths[0]
+	}
+	dest, contents := c.modulePath(arg)
+	rv.dest = dest
+	single := len(rv.list) == 1 && !rv.list[0].isDir()
+	node, err := c.vfs.Stat(dest)
+	if err == nil {
+		if node.IsDir() {
+			return nil
+		}
+		if single {
+			rv.file = true
+			return nil
+		}
+		return fmt.Errorf("destination %q is not a directory", arg)
+	}
+	if !errors.Is(err, vfs.ENOENT) {
+		return fmt.Errorf("failed to stat destination %q: %w", arg, err)
+	}
+	if single && !contents {
+		rv.file = true
+		return nil
+	}
+	if c.opt.dryRun {
+		return nil
+	}
+	return rv.mkdirAll(dest)
+}
+
+// mkdirAll makes the directory dir and any parents it needs
+func (rv *receiver) mkdirAll(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	node, err := rv.c.vfs.Stat(dir)
+	if err == nil {
+		if !node.IsDir() {
+			return fmt.Errorf("can't make directory %q as a file is in the way", dir)
+		}
+		return nil
+	}
+	if !errors.Is(err, vfs.ENOENT) {
+		return err
+	}
+	parent := path.Dir(dir)
+	if parent == "." {
+		parent = ""
+	}
+	err = rv.mkdirAll(parent)
+	if err != nil {
+		return err
+	}
+	err = rv.c.vfs.Mkdir(dir, 0777)
+	if err != nil {
+		return fmt.Errorf("failed to make directory %q: %w", dir, err)
+	}
+	return nil
+}
+
+// localPath returns the path in the VFS that e is written to
+func (rv *receiver) localPath(e *fileEntry) string {
+	if rv.file || e.name == "." {
+		return rv.dest
+	}
+	return path.Join(rv.dest, e.name)
+}
+
+// generate makes the directories in the file list and asks the client
+// for the files which need transferring
+func (rv *receiver) generate() error {
+	c := rv.c
+	for i, e := range rv.list {
+		if i > 0 && rv.list[i-1].name == e.name {
+			continue
+		}
+		if !safeName(e.name) {
+			c.errorf("skipping unsafe file name %q", e.name)
+			continue
+		}
+		switch {
+		case e.isDir():
+			rv.makeDir(e)
+		case e.isRegular():
+			if c.opt.dryRun || !rv.wanted(e) {
+				continue
+			}
+			c.w.writeInt(int32(i))
+			sumHead{}.write(c.w)
+			err := c.w.flush()
+			if err != nil {
+				return err
+			}
+		default:
+			c.infof("skipping non-regular file %q", e.name)
+		}
+	}
+	c.w.writeInt(-1)
+	err := c.w.flush()
+	if err != nil {
+		return err
+	}
+
+	// Ask again for any files which failed verification
+	<-rv.phase0Done
+	for _, ndx := range rv.redo {
+		c.w.writeInt(ndx)
+		sumHead{}.write(c.w)
+	}
+	c.w.writeInt(-1)
+	return c.w.flush()
+}
+
+// makeDir makes the directory for e if it doesn't exist
+func (rv *receiver) makeDir(e *fileEntry) {
+	c := rv.c
+	if rv.file || e.name == "." || c.opt.dryRun {
+		return
+	}
+	local := rv.localPath(e)
+	node, err := c.vfs.Stat(local)
+	if err == nil {
+		if !node.IsDir() {
+			c.errorf("can't make directory %q as a file is in the way", e.name)
+		}
+		return
+	}
+	if !errors.Is(err, vfs.ENOENT) {
+		c.errorf("failed to stat %q: %v", e.name, err)
+		return
+	}
+	err = c.vfs.Mkdir(local, 0777)
+	if err != nil {
+		c.errorf("failed to make directory %q: %v", e.name, err)
+	}
+}
+
+// wanted returns whether the file e needs transferring
+func (rv *receiver) wanted(e *fileEntry) bool {
+	c := rv.c
+	node, err := c.vfs.Stat(rv.localPath(e))
+	if errors.Is(err, vfs.ENOENT) {
+		return !c.opt.existing
+	}
+	if err != nil {
+		c.errorf("failed to stat %q: %v", e.name, err)
+		return false
+	}
+	if node.IsDir() {
+		c.errorf("can't replace directory %q with a file", e.name)
+		return false
+	}
+	if c.opt.ignoreExisting {
+		return false
+	}
+	// The client only sends whole seconds so compare those
+	dt := time.Duration(node.ModTime().Unix()-int64(e.modTime)) * time.Second
+	if c.opt.update && dt > 0 && dt > rv.window {
+		return false
+	}
+	if c.opt.ignoreTimes || node.Size() != e.size {
+		return true
+	}
+	if c.opt.sizeOnly {
+		return false
+	}
+	if c.opt.alwaysChecksum {
+		sum, err := fileChecksum(node)
+		if err != nil {
+			c.errorf("failed to checksum %q: %v", e.name, err)
+			return true
+		}
+		return !bytes.Equal(sum, e.checksum)
+	}
+	if rv.window == fs.ModTimeNotSupported {
+		return false
+	}
+	if dt < 0 {
+		dt = -dt
+	}
+	return dt > rv.window
+}
+
+// receiveFiles receives the files the client sends until it says
+// both phases are finished
+func (rv *receiver) receiveFiles() error {
+	c := rv.c
+	phase := 0
+	for {
+		ndx := c.r.readInt()
+		if c.r.err != nil {
+			return c.r.err
+		}
+		if ndx == -1 {
+			phase++
+			if phase > 1 {
+				return nil
+			}
+			rv.finishPhase0()
+			continue
+		}
+		if ndx < 0 || int(ndx) >= len(rv.list) || !rv.list[ndx].isRegular() {
+			return fmt.Errorf("invalid file index %d", ndx)
+		}
+		err := rv.receiveFile(ndx, phase)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// receiveFile receives the file at index ndx and writes it to the VFS.
+//
+// Only errors reading from the client are returned, any others are
+// reported and the transfer carries on.
+func (rv *receiver) receiveFile(ndx int32, phase int) error {
+	c := rv.c
+	e := rv.list[ndx]
+	local := rv.localPath(e)
+	_, err := readSumHead(c.r)
+	if err != nil {
+		return err
+	}
+	out, err := c.vfs.OpenFile(local, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		c.errorf("failed to open %q for writing: %v", e.name, err)
+		out = nil
+	}
+	abort := func() {
+		if out != nil {
+			_ = out.Close()
+			rv.remove(local)
+		}
+	}
+
+	// We never send block checksums so all the data is literal
+	hash := newFileHash(c.seed)
+	buf := make([]byte, chunkSize)
+	var writeErr error
+	for {
+		n := c.r.readInt()
+		if c.r.err != nil {
+			abort()
+			return c.r.err
+		}
+		if n == 0 {
+			break
+		}
+		if n < 0 {
+			abort()
+			return fmt.Errorf("unexpected block match receiving %q", e.name)
+		}
+		for n > 0 {
+			chunk := buf
+			if int(n) < len(chunk) {
+				chunk = chunk[:n]
+			}
+			c.r.readFull(chunk)
+			if c.r.err != nil {
+				abort()
+				return c.r.err
+			}
+			_, _ = hash.Write(chunk)
+			if out != nil && writeErr == nil {
+				_, writeErr = out.Write(chunk)
+			}
+			n -= int32(len(chunk))
+		}
+	}
+	sum := make([]byte, sumLength)
+	c.r.readFull(sum)
+	if c.r.err != nil {
+		abort()
+		return c.r.err
+	}
+	if out == nil {
+		return nil
+	}
+	closeErr := out.Close()
+	if writeErr == nil {
+		writeErr = closeErr
+	}
+
+	switch {
+	case writeErr != nil:
+		c.errorf("failed to write %q: %v", e.name, writeErr)
+		rv.remove(local)
+	case !bytes.Equal(sum, hash.Sum(nil)):
+		rv.remove(local)
+		if phase == 0 {
+			fs.Debugf(local, "rsync checksum mismatch - will try again")
+			rv.redo = append(rv.redo, ndx)
+		} else {
+			c.errorf("%q failed verification -- update discarded", e.name)
+		}
+	default:
+		if c.opt.preserveTimes {
+			modTime := time.Unix(int64(e.modTime), 0)
+			err = c.vfs.Chtimes(local, modTime, modTime)
+			if err != nil {
+				c.errorf("failed to set modification time of %q: %v", e.name, err)
+			}
+		}
+		fs.Debugf(local, "Received with rsync")
+	}
+	return nil
+}
+
+// remove removes a file which wasn't received properly
+func (rv *receiver) remove(local string) {
+	err := rv.c.vfs.Remove(local)
+	if err != nil && !errors.Is(err, vfs.ENOENT) {
+		fs.Errorf(local, "rsync failed to remove partial file: %v", err)
+	}
+}
+
+// deleteExtraneous deletes the files in the directories the client
+// sent which aren't in the file list.
+//
+// Files excluded by the filter rules are kept unless
+// --delete-excluded is in use, in which case there are no rules.
+func (rv *receiver) deleteExtraneous(rules filterRules) {
+	c := rv.c
+	if rv.file {
+		return
+	}
+	names := make(map[string]bool, len(rv.list))
+	for _, e := range rv.list {
+		names[e.name] = true
+	}
+	deleted := 0
+	for _, e := range rv.list {
+		if !e.isDir() || !safeName(e.name) || (!c.opt.recursive && !e.topDir) {
+			continue
+		}
+		node, err := c.vfs.Stat(rv.localPath(e))
+		if err != nil || !node.IsDir() {
+			continue
+		}
+		items, err := node.(*vfs.Dir).ReadDirAll()
+		if err != nil {
+			c.errorf("failed to read directory %q: %v", e.name, err)
+			continue
+		}
+		for _, item := range items {
+			name := item.Name()
+			if e.name != "." {
+				name = path.Join(e.name, name)
+			}
+			if names[name] || rules.excluded(name, item.IsDir()) {
+				continue
+			}
+			if c.opt.maxDelete >= 0 && deleted >= c.opt.maxDelete {
+				c.errorf("deletions stopped due to --max-delete limit")
+				return
+			}
+			deleted++
+			display := name
+			if item.IsDir() {
+				display += "/"
+			}
+			c.infof("deleting %s", display)
+			if c.opt.dryRun {
+				continue
+			}
+			if item.IsDir() {
+				err = item.RemoveAll()
+			} else {
+				err = item.Remove()
+			}
+			if err != nil {
+				c.errorf("failed to delete %q: %v", name, err)
+			}
+		}
+	}
+}
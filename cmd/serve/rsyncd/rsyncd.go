@@ -0,0 +1,150 @@
+// Package rsyncd implements a server to serve a VFS remote with the
+// rsync daemon protocol
+package rsyncd
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfsflags"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Options contains options for the rsync daemon
+type Options struct {
+	ListenAddr string // Port to listen on
+	Module     string // name of the module
+	Comment    string // description of the module shown in the module list
+	User       string // single username for authentication
+	Pass       string // password for authentication
+}
+
+// DefaultOpt is the default values used for Options
+var DefaultOpt = Options{
+	ListenAddr: "localhost:873",
+	Module:     "rclone",
+}
+
+// Opt is options set by command line flags
+var Opt = DefaultOpt
+
+// AddFlags adds flags for the rsync daemon
+func AddFlags(flagSet *pflag.FlagSet, Opt *Options) {
+	rc.AddOption("rsyncd", &Opt)
+	flags.StringVarP(flagSet, &Opt.ListenAddr, "addr", "", Opt.ListenAddr, "IPaddress:Port or :Port to bind server to")
+	flags.StringVarP(flagSet, &Opt.Module, "module", "", Opt.Module, "Name of the module to serve the remote as")
+	flags.StringVarP(flagSet, &Opt.Comment, "comment", "", Opt.Comment, "Description of the module shown when clients list the modules")
+	flags.StringVarP(flagSet, &Opt.User, "user", "", Opt.User, "User name for authentication")
+	flags.StringVarP(flagSet, &Opt.Pass, "pass", "", Opt.Pass, "Password for authentication")
+}
+
+func init() {
+	vfsflags.AddFlags(Command.Flags())
+	AddFlags(Command.Flags(), &Opt)
+}
+
+const longHelp = `rclone serve rsyncd implements an rsync daemon to serve the remote
+to rsync clients. This lets existing scripts which copy files to and
+from an rsync server use any remote without changing them.
+
+Clients refer to the remote with an rsync URL like
+
+    rsync -av rsync://server/rclone/path/ /local/dir/
+    rsync -av /local/dir/ rsync://server/rclone/path/
+
+or the equivalent |server::rclone/path| syntax, where |rclone| is the
+name of the module which can be changed with --module. Use
+
+    rsync rsync://server/
+
+to list the module.
+
+### Server options
+
+Use --addr to specify which IP address and port the server should
+listen on, e.g. --addr 1.2.3.4:873 or --addr :873 to listen to all
+IPs. By default it only listens on localhost.
+
+rsync clients connect to port 873 by default, which needs root
+privileges to listen on under Unix. Other ports can be given in the
+URL, e.g. |rsync://server:8873/rclone/|.
+
+### Authentication
+
+Use --user and --pass to set the user name and password clients must
+give. The client can be told the password with the |RSYNC_PASSWORD|
+environment variable or the |--password-file| flag, e.g.
+
+    RSYNC_PASSWORD=secret rsync -av rsync://user@server/rclone/ /local/dir/
+
+The password is checked with the rsync challenge and response so it
+is never sent over the network, but the files are not encrypted, so
+the server should only be used on a trusted network.
+
+Use --read-only (see the VFS options below) to stop clients uploading
+or deleting files.
+
+### Supported features
+
+rclone speaks version 27 of the rsync protocol which all rsync
+clients since 2.6.0 support. The rsync options which make sense for a
+remote are supported, including
+
+- |-r|, |-d|, |-t|, |-v| and |-n|
+- |-c|, |-I|, |-u|, |--size-only|, |--existing| and |--ignore-existing|
+  to choose which files are sent to the server
+- |--delete| and |--delete-excluded| with |--max-delete|
+- |--exclude|, |--include| and |--filter| with |+| and |-| rules
+
+The modes and owners of files are sent to clients from --file-perms,
+--dir-perms, --uid and --gid. Those sent to the server are ignored as
+rclone can't store them.
+
+### Limitations
+
+Compression (|-z|) isn't supported, so rsync must be run without it.
+Relative paths (|-R|), hard links (|-H|), ACLs (|-A|), extended
+attributes (|-X|), backups (|-b|) and |--protect-args| (|-s|) aren't
+supported either. rsync will stop with an error if any of these are
+used.
+
+Symbolic links, devices and special files sent to the server are
+skipped.
+
+Files are always sent in full rather than as the changes to the copy
+the other end has, so only files which have changed are transferred
+but each of those is sent completely.
+
+Files sent to the server are written to their final name as they
+arrive, so a file is incomplete while it is being transferred, and
+directory modification times aren't set.
+
+`
+
+// Command definition for cobra
+var Command = &cobra.Command{
+	Use:   "rsyncd remote:path",
+	Short: `Serve the remote with the rsync daemon protocol.`,
+	Long:  strings.ReplaceAll(longHelp, "|", "`") + vfs.Help,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsSrc(args)
+		cmd.Run(false, true, command, func() error {
+			s, err := newServer(context.Background(), vfs.New(f, &vfsflags.Opt), &Opt)
+			if err != nil {
+				return err
+			}
+			err = s.Serve()
+			if err != nil {
+				return err
+			}
+			s.Wait()
+			return nil
+		})
+	},
+}
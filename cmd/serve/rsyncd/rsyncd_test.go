@@ -0,0 +1,595 @@
+package rsyncd
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOptions(t *testing.T) {
+	opt, err := parseOptions([]string{"--server", "--sender", "-vlogDtpre.iLsfxC", "--delete", "--max-delete=3", "--modify-window", "2", ".", "rclone/dir/"})
+	require.NoError(t, err)
+	assert.True(t, opt.sender)
+	assert.Equal(t, 1, opt.verbose)
+	assert.True(t, opt.recursive)
+	assert.True(t, opt.preserveLinks)
+	assert.True(t, opt.preserveUID)
+	assert.True(t, opt.preserveGID)
+	assert.True(t, opt.preserveDevices)
+	assert.True(t, opt.preserveTimes)
+	assert.True(t, opt.deleteMode)
+	assert.False(t, opt.deleteExcluded)
+	assert.Equal(t, 3, opt.maxDelete)
+	assert.Equal(t, 2*time.Second, opt.modifyWindow)
+	assert.Equal(t, []string{"rclone/dir/"}, opt.paths)
+
+	opt, err = parseOptions([]string{"--server", "-B", "1024", "-nce.iLsf", "--delete-excluded", ".", "a", "b"})
+	require.NoError(t, err)
+	assert.False(t, opt.sender)
+	assert.True(t, opt.dryRun)
+	assert.True(t, opt.alwaysChecksum)
+	assert.True(t, opt.deleteExcluded)
+	assert.Equal(t, -1, opt.maxDelete)
+	assert.Equal(t, []string{"a", "b"}, opt.paths)
+
+	for _, test := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--server", "-vz", ".", "rclone/"}, "compression (-z) isn't supported"},
+		{[]string{"--server", "-vQ", ".", "rclone/"}, "-Q isn't supported"},
+		{[]string{"--server", "--backup-dir=x", ".", "rclone/"}, "--backup-dir isn't supported"},
+		{[]string{"--server", "--max-delete=x", ".", "rclone/"}, "bad value for --max-delete"},
+		{[]string{"--server", "--max-delete"}, "--max-delete needs a value"},
+		{[]string{"--server", "-m", ".", "rclone/"}, "pruning empty directories"},
+		{[]string{"--server", "-r"}, "no paths given"},
+	} {
+		_, err := parseOptions(test.args)
+		require.Error(t, err, test.args)
+		assert.Contains(t, err.Error(), test.want, test.args)
+	}
+}
+
+func TestFilterRules(t *testing.T) {
+	var rules filterRules
+	var err error
+	for _, rule := range []string{"- *.log", "!", "+ keep.tmp", "- *.tmp", "- cache/", "/top.txt"} {
+		rules, err = rules.add(rule)
+		require.NoError(t, err)
+	}
+	for _, test := range []struct {
+		name  string
+		isDir bool
+		want  bool
+	}{
+		{".", true, false},
+		{"file.log", false, false},
+		{"file.tmp", false, true},
+		{"dir/file.tmp", false, true},
+		{"keep.tmp", false, false},
+		{"cache", true, true},
+		{"cache", false, false},
+		{"dir/cache", true, true},
+		{"top.txt", false, true},
+		{"dir/top.txt", false, false},
+	} {
+		assert.Equal(t, test.want, rules.excluded(test.name, test.isDir), test.name)
+	}
+}
+
+func TestSafeName(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		want bool
+	}{
+		{".", true},
+		{"file", true},
+		{"dir/file", true},
+		{"", false},
+		{"/etc/passwd", false},
+		{"..", false},
+		{"dir/../../file", false},
+		{"dir//file", false},
+		{"dir/./file", false},
+	} {
+		assert.Equal(t, test.want, safeName(test.name), test.name)
+	}
+}
+
+func TestModulePath(t *testing.T) {
+	c := &conn{s: &server{opt: Options{Module: "rclone"}}}
+	for _, test := range []struct {
+		in       string
+		want     string
+		contents bool
+	}{
+		{"rclone", "", true},
+		{"rclone/", "", true},
+		{"rclone/dir", "dir", false},
+		{"rclone/dir/", "dir", true},
+		{"rclone/dir/.", "dir", true},
+		{"rclone/../../etc", "etc", false},
+		{"rclonex/dir", "rclonex/dir", false},
+	} {
+		got, contents := c.modulePath(test.in)
+		assert.Equal(t, test.want, got, test.in)
+		assert.Equal(t, test.contents, contents, test.in)
+	}
+}
+
+func TestFileListRoundTrip(t *testing.T) {
+	opt := &options{preserveUID: true, preserveGID: true, alwaysChecksum: true}
+	sum := bytes.Repeat([]byte{1}, sumLength)
+	list := fileList{
+		{name: ".", mode: modeDir | 0755, modTime: 1000, topDir: true, checksum: make([]byte, sumLength)},
+		{name: "a.txt", mode: modeRegular | 0644, size: 5, modTime: 1000, uid: 1, gid: 2, checksum: sum},
+		{name: "a.txt.bak", mode: modeRegular | 0644, size: 1 << 40, modTime: 2000, uid: 1, gid: 2, checksum: sum},
+		{name: "dir", mode: modeDir | 0755, modTime: 2000, uid: 1, gid: 2, checksum: make([]byte, sumLength)},
+		{name: "dir/" + strings.Repeat("x", 300), mode: modeRegular | 0600, size: 0, modTime: 2000, uid: 3, gid: 2, checksum: sum},
+	}
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	writeFileList(w, opt, list, 1)
+	require.NoError(t, w.flush())
+
+	got, ioError, err := readFileList(newReader(&buf), opt)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), ioError)
+	assert.Equal(t, list, got)
+	assert.Equal(t, 0, buf.Len())
+}
+
+// demuxReader reads the data from the multiplexed output of the
+// server, collecting the messages
+type demuxReader struct {
+	r        io.Reader
+	left     int
+	messages []string
+}
+
+// Read implements io.Reader
+func (d *demuxReader) Read(p []byte) (int, error) {
+	for d.left == 0 {
+		var header [4]byte
+		if _, err := io.ReadFull(d.r, header[:]); err != nil {
+			return 0, err
+		}
+		h := binary.LittleEndian.Uint32(header[:])
+		n := int(h & 0xFFFFFF)
+		if byte(h>>24)-mplexBase == msgData {
+			d.left = n
+			continue
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(d.r, msg); err != nil {
+			return 0, err
+		}
+		d.messages = append(d.messages, string(msg))
+	}
+	if len(p) > d.left {
+		p = p[:d.left]
+	}
+	n, err := d.r.Read(p)
+	d.left -= n
+	return n, err
+}
+
+// testClient speaks enough of the client side of the protocol to
+// test the server
+type testClient struct {
+	t     *testing.T
+	raw   *reader // unmultiplexed output of the server
+	w     *writer
+	demux *demuxReader
+	r     *reader // data from the multiplexed output of the server
+	opt   *options
+	seed  int32
+}
+
+func newTestClient(t *testing.T, s *server) *testClient {
+	c, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	tc := &testClient{t: t, raw: newReader(c), w: newWriter(c)}
+	assert.Equal(t, "@RSYNCD: 27.0", tc.raw.readLine())
+	tc.w.writeString("@RSYNCD: 30.0\n")
+	return tc
+}
+
+// connect asks for the module, giving the user and password if asked
+// for them, and returns the reply
+func (tc *testClient) connect(module, user, pass string) string {
+	tc.w.writeString(module + "\n")
+	require.NoError(tc.t, tc.w.flush())
+	line := tc.raw.readLine()
+	if strings.HasPrefix(line, "@RSYNCD: AUTHREQD ") {
+		challenge := strings.TrimPrefix(line, "@RSYNCD: AUTHREQD ")
+		tc.w.writeString(user + " " + authResponse(pass, challenge) + "\n")
+		require.NoError(tc.t, tc.w.flush())
+		line = tc.raw.readLine()
+	}
+	require.NoError(tc.t, tc.raw.err)
+	return line
+}
+
+// start sends the arguments and reads the checksum seed
+func (tc *testClient) start(args ...string) {
+	for _, arg := range args {
+		tc.w.writeString(arg + "\n")
+	}
+	tc.w.writeString("\n")
+	require.NoError(tc.t, tc.w.flush())
+	tc.seed = tc.raw.readInt()
+	require.NoError(tc.t, tc.raw.err)
+	tc.demux = &demuxReader{r: tc.raw.r}
+	tc.r = newReader(tc.demux)
+	tc.opt, _ = parseOptions(args)
+}
+
+// writeRules sends the filter rules
+func (tc *testClient) writeRules(rules ...string) {
+	for _, rule := range rules {
+		tc.w.writeInt(int32(len(rule)))
+		tc.w.writeString(rule)
+	}
+	tc.w.writeInt(0)
+}
+
+// pull receives the files from the server returning the file list and
+// the contents of the files
+func (tc *testClient) pull(rules ...string) (fileList, map[string]string) {
+	t := tc.t
+	tc.writeRules(rules...)
+	require.NoError(t, tc.w.flush())
+	list, ioError, err := readFileList(tc.r, tc.opt)
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), ioError)
+	list.sort()
+	for i, e := range list {
+		if e.isRegular() {
+			tc.w.writeInt(int32(i))
+			sumHead{}.write(tc.w)
+		}
+	}
+	tc.w.writeInt(-1)
+	require.NoError(t, tc.w.flush())
+
+	files := make(map[string]string)
+	for {
+		ndx := tc.r.readInt()
+		require.NoError(t, tc.r.err)
+		if ndx == -1 {
+			break
+		}
+		_, err := readSumHead(tc.r)
+		require.NoError(t, err)
+		hash := newFileHash(tc.seed)
+		var data []byte
+		for {
+			n := tc.r.readInt()
+			if n == 0 {
+				break
+			}
+			chunk := make([]byte, n)
+			tc.r.readFull(chunk)
+			require.NoError(t, tc.r.err)
+			data = append(data, chunk...)
+		}
+		_, _ = hash.Write(data)
+		sum := make([]byte, sumLength)
+		tc.r.readFull(sum)
+		require.NoError(t, tc.r.err)
+		assert.Equal(t, hash.Sum(nil), sum)
+		files[list[ndx].name] = string(data)
+	}
+
+	// End the second phase, read the statistics and say goodbye
+	tc.w.writeInt(-1)
+	require.NoError(t, tc.w.flush())
+	assert.Equal(t, int32(-1), tc.r.readInt())
+	for i := 0; i < 3; i++ {
+		_ = tc.r.readLongint()
+	}
+	tc.w.writeInt(-1)
+	require.NoError(t, tc.w.flush())
+	require.NoError(t, tc.r.err)
+	return list, files
+}
+
+// testFile is a file the test client pushes
+type testFile struct {
+	name    string
+	data    string
+	dir     bool
+	modTime int32
+}
+
+// push sends files to the server, sending a bad checksum the first
+// time corrupt is sent, and returns the names of the files the server
+// asked for
+func (tc *testClient) push(files []testFile, corrupt string, rules ...string) (requested []string) {
+	t := tc.t
+	if tc.opt.deleteMode && !tc.opt.deleteExcluded {
+		tc.writeRules(rules...)
+	}
+	var list fileList
+	contents := make(map[string]string)
+	for _, f := range files {
+		e := &fileEntry{name: f.name, modTime: f.modTime, mode: modeRegular | 0644, size: int64(len(f.data))}
+		if f.dir {
+			e.mode = modeDir | 0755
+			e.size = 0
+			e.topDir = f.name == "."
+		}
+		contents[f.name] = f.data
+		list = append(list, e)
+	}
+	list.sort()
+	writeFileList(tc.w, tc.opt, list, 0)
+	require.NoError(t, tc.w.flush())
+
+	phase := 0
+	for {
+		ndx := tc.r.readInt()
+		require.NoError(t, tc.r.err)
+		if ndx == -1 {
+			phase++
+			if phase > 1 {
+				break
+			}
+			tc.w.writeInt(-1)
+			require.NoError(t, tc.w.flush())
+			continue
+		}
+		head, err := readSumHead(tc.r)
+		require.NoError(t, err)
+		name := list[ndx].name
+		requested = append(requested, name)
+		data := []byte(contents[name])
+		tc.w.writeInt(ndx)
+		head.write(tc.w)
+		for rest := data; len(rest) > 0; {
+			n := len(rest)
+			if n > chunkSize {
+				n = chunkSize
+			}
+			tc.w.writeInt(int32(n))
+			tc.w.writeBytes(rest[:n])
+			rest = rest[n:]
+		}
+		tc.w.writeInt(0)
+		hash := newFileHash(tc.seed)
+		_, _ = hash.Write(data)
+		sum := hash.Sum(nil)
+		if name == corrupt && phase == 0 {
+			sum[0] ^= 0xFF
+		}
+		tc.w.writeBytes(sum)
+		require.NoError(t, tc.w.flush())
+	}
+	tc.w.writeInt(-1)
+	require.NoError(t, tc.w.flush())
+	assert.Equal(t, int32(-1), tc.r.readInt())
+	require.NoError(t, tc.r.err)
+	return requested
+}
+
+func newTestServer(t *testing.T, opt *Options) (*server, string) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	f, err := fs.NewFs(ctx, dir)
+	require.NoError(t, err)
+	vfsOpt := vfscommon.DefaultOpt
+	vfsOpt.CacheMode = vfscommon.CacheModeWrites
+	vfsOpt.WriteBack = 0
+	VFS := vfs.New(f, &vfsOpt)
+	t.Cleanup(VFS.Shutdown)
+	opt.ListenAddr = "127.0.0.1:0"
+	s, err := newServer(ctx, VFS, opt)
+	require.NoError(t, err)
+	require.NoError(t, s.Serve())
+	t.Cleanup(func() { _ = s.Close() })
+	return s, dir
+}
+
+func TestServerModules(t *testing.T) {
+	s, _ := newTestServer(t, &Options{Module: "backup", Comment: "Backups"})
+
+	tc := newTestClient(t, s)
+	assert.Equal(t, "backup         \tBackups", tc.connect("#list", "", ""))
+	assert.Equal(t, "@RSYNCD: EXIT", tc.raw.readLine())
+
+	tc = newTestClient(t, s)
+	assert.Equal(t, "@ERROR: Unknown module 'rclone'", tc.connect("rclone", "", ""))
+
+	tc = newTestClient(t, s)
+	assert.Equal(t, "@RSYNCD: OK", tc.connect("backup", "", ""))
+}
+
+func TestServerAuth(t *testing.T) {
+	s, _ := newTestServer(t, &Options{User: "user", Pass: "secret"})
+
+	tc := newTestClient(t, s)
+	assert.Equal(t, "@RSYNCD: OK", tc.connect("rclone", "user", "secret"))
+
+	for _, user := range []string{"user", "someone"} {
+		tc = newTestClient(t, s)
+		assert.Equal(t, "@ERROR: auth failed on module rclone", tc.connect("rclone", user, "wrong"), user)
+	}
+}
+
+func TestServerUnsupported(t *testing.T) {
+	s, _ := newTestServer(t, &Options{})
+	tc := newTestClient(t, s)
+	require.Equal(t, "@RSYNCD: OK", tc.connect("rclone", "", ""))
+	tc.start("--server", "-vlogDtprz", ".", "rclone/")
+	_ = tc.r.readInt()
+	assert.Equal(t, io.EOF, tc.r.err)
+	assert.Equal(t, []string{"rclone: compression (-z) isn't supported by rclone serve rsyncd\n"}, tc.demux.messages)
+}
+
+func TestServerPull(t *testing.T) {
+	s, dir := newTestServer(t, &Options{})
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	big := strings.Repeat("0123456789", 10000)
+	for name, data := range map[string]string{
+		"top.txt":          "top",
+		"dir/one.txt":      "one",
+		"dir/debug.log":    "log",
+		"dir/sub/big.bin":  big,
+		"other/other.txt":  "other",
+		"dir/sub/empty.md": "",
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0777))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0666))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+
+	// Recursive copy of a directory's contents with a filter
+	tc := newTestClient(t, s)
+	require.Equal(t, "@RSYNCD: OK", tc.connect("rclone", "", ""))
+	tc.start("--server", "--sender", "-vrte.iLsfxC", ".", "rclone/dir/")
+	list, files := tc.pull("- *.log")
+	var names []string
+	for _, e := range list {
+		names = append(names, e.name)
+		if e.isRegular() {
+			assert.Equal(t, int32(modTime.Unix()), e.modTime, e.name)
+		}
+	}
+	assert.Equal(t, []string{".", "one.txt", "sub", "sub/big.bin", "sub/empty.md"}, names)
+	assert.True(t, list[0].topDir)
+	assert.Equal(t, map[string]string{
+		"one.txt":      "one",
+		"sub/big.bin":  big,
+		"sub/empty.md": "",
+	}, files)
+
+	// A single file and a directory without recursion
+	tc = newTestClient(t, s)
+	require.Equal(t, "@RSYNCD: OK", tc.connect("rclone", "", ""))
+	tc.start("--server", "--sender", "-v", ".", "rclone/top.txt", "rclone/other")
+	list, files = tc.pull()
+	require.Len(t, list, 1)
+	assert.Equal(t, "top.txt", list[0].name)
+	assert.Equal(t, map[string]string{"top.txt": "top"}, files)
+	assert.Equal(t, []string{"skipping directory other\n"}, tc.demux.messages)
+
+	// A missing file is reported
+	tc = newTestClient(t, s)
+	require.Equal(t, "@RSYNCD: OK", tc.connect("rclone", "", ""))
+	tc.start("--server", "--sender", "-r", ".", "rclone/missing")
+	tc.writeRules()
+	require.NoError(t, tc.w.flush())
+	list, ioError, err := readFileList(tc.r, tc.opt)
+	require.NoError(t, err)
+	assert.Len(t, list, 0)
+	assert.Equal(t, int32(1), ioError)
+	require.Len(t, tc.demux.messages, 1)
+	assert.Contains(t, tc.demux.messages[0], `failed to stat "rclone/missing"`)
+}
+
+func TestServerPush(t *testing.T) {
+	s, dir := newTestServer(t, &Options{})
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	mt := int32(modTime.Unix())
+	big := strings.Repeat("abcdefghij", 10000)
+	files := []testFile{
+		{name: ".", dir: true, modTime: mt},
+		{name: "one.txt", data: "one", modTime: mt},
+		{name: "sub", dir: true, modTime: mt},
+		{name: "sub/big.bin", data: big, modTime: mt},
+		{name: "../escape.txt", data: "bad", modTime: mt},
+	}
+
+	// Push into a new directory with one file failing verification
+	// the first time
+	tc := newTestClient(t, s)
+	require.Equal(t, "@RSYNCD: OK", tc.connect("rclone", "", ""))
+	tc.start("--server", "-rte.iLsfxC", ".", "rclone/backup/")
+	requested := tc.push(files, "one.txt")
+	sort.Strings(requested)
+	assert.Equal(t, []string{"one.txt", "one.txt", "sub/big.bin"}, requested)
+	require.Len(t, tc.demux.messages, 1)
+	assert.Contains(t, tc.demux.messages[0], `skipping unsafe file name "../escape.txt"`)
+
+	for name, want := range map[string]string{
+		"backup/one.txt":     "one",
+		"backup/sub/big.bin": big,
+	} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		got, err := os.ReadFile(path)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, string(got), name)
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, modTime.Unix(), fi.ModTime().Unix(), name)
+	}
+	_, err := os.Stat(filepath.Join(dir, "escape.txt"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Pushing again transfers nothing and deletes the extra files
+	// apart from the excluded ones
+	require.NoError(t, s.vfs.Mkdir("backup/sub/old", 0777))
+	for _, name := range []string{"old.txt", "keep.tmp", "sub/old/old.txt"} {
+		h, err := s.vfs.OpenFile("backup/"+name, os.O_WRONLY|os.O_CREATE, 0666)
+		require.NoError(t, err)
+		_, err = h.Write([]byte("old"))
+		require.NoError(t, err)
+		require.NoError(t, h.Close())
+	}
+	tc = newTestClient(t, s)
+	require.Equal(t, "@RSYNCD: OK", tc.connect("rclone", "", ""))
+	tc.start("--server", "-vrt", "--delete", ".", "rclone/backup/")
+	requested = tc.push(files[:4], "", "- *.tmp")
+	assert.Len(t, requested, 0)
+	sort.Strings(tc.demux.messages)
+	assert.Equal(t, []string{"deleting old.txt\n", "deleting sub/old/\n"}, tc.demux.messages)
+	for name, exists := range map[string]bool{
+		"old.txt":  false,
+		"keep.tmp": true,
+		"sub/old":  false,
+		"one.txt":  true,
+	} {
+		_, err := os.Stat(filepath.Join(dir, "backup", filepath.FromSlash(name)))
+		assert.Equal(t, exists, err == nil, name)
+	}
+
+	// A single file is written to the path given if it isn't a
+	// directory
+	tc = newTestClient(t, s)
+	require.Equal(t, "@RSYNCD: OK", tc.connect("rclone", "", ""))
+	tc.start("--server", "-t", ".", "rclone/backup/renamed.txt")
+	requested = tc.push([]testFile{{name: "one.txt", data: "renamed", modTime: mt}}, "")
+	assert.Equal(t, []string{"one.txt"}, requested)
+	got, err := os.ReadFile(filepath.Join(dir, "backup", "renamed.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", string(got))
+}
+
+func TestServerReadOnly(t *testing.T) {
+	s, _ := newTestServer(t, &Options{})
+	s.vfs.Opt.ReadOnly = true
+	tc := newTestClient(t, s)
+	require.Equal(t, "@RSYNCD: OK", tc.connect("rclone", "", ""))
+	tc.start("--server", "-r", ".", "rclone/")
+	_ = tc.r.readInt()
+	assert.Equal(t, io.EOF, tc.r.err)
+	assert.Equal(t, []string{"rclone: module is read only\n"}, tc.demux.messages)
+}
@@ -0,0 +1,280 @@
+package rsyncd
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+	"golang.org/x/crypto/md4"
+)
+
+// sumHead describes the block checksums of a file which the side
+// receiving it sends so only the differences need to be sent
+type sumHead struct {
+	count     int32 // number of blocks
+	blength   int32 // block length
+	s2length  int32 // length of the strong checksum of each block
+	remainder int32 // length of the last block
+}
+
+// readSumHead reads and checks a sumHead
+func readSumHead(r *reader) (h sumHead, err error) {
+	h.count = r.readInt()
+	h.blength = r.readInt()
+	h.s2length = r.readInt()
+	h.remainder = r.readInt()
+	if r.err != nil {
+		return h, r.err
+	}
+	if h.count < 0 || h.blength < 0 || h.s2length < 0 || h.s2length > sumLength || h.remainder < 0 || h.remainder > h.blength {
+		return h, fmt.Errorf("invalid checksum header %+v", h)
+	}
+	return h, nil
+}
+
+// write sends the sumHead
+func (h sumHead) write(w *writer) {
+	w.writeInt(h.count)
+	w.writeInt(h.blength)
+	w.writeInt(h.s2length)
+	w.writeInt(h.remainder)
+}
+
+// newFileHash returns the hash used to check a file was transferred
+// correctly, which is MD4 with the checksum seed in front
+func newFileHash(seed int32) hash.Hash {
+	hash := md4.New()
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], uint32(seed))
+	_, _ = hash.Write(buf[:])
+	return hash
+}
+
+// send sends the files the client asks for
+func (c *conn) send() error {
+	rules, err := readFilterRules(c.r)
+	if err != nil {
+		return err
+	}
+	list, ioError := c.buildFileList(rules)
+	writeFileList(c.w, c.opt, list, ioError)
+	err = c.w.flush()
+	if err != nil {
+		return err
+	}
+	fs.Debugf(c.c.RemoteAddr(), "rsync sent file list of %d entries", len(list))
+
+	// The client asks for files until it sends -1, then asks for
+	// any which failed verification again until it sends -1 again.
+	phase := 0
+	for {
+		ndx := c.r.readInt()
+		if c.r.err != nil {
+			return c.r.err
+		}
+		if ndx == -1 {
+			phase++
+			if phase > 1 {
+				break
+			}
+			c.w.writeInt(-1)
+			err = c.w.flush()
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if ndx < 0 || int(ndx) >= len(list) || !list[ndx].isRegular() {
+			return fmt.Errorf("invalid file index %d", ndx)
+		}
+		if c.opt.dryRun {
+			c.w.writeInt(ndx)
+			continue
+		}
+		head, err := readSumHead(c.r)
+		if err != nil {
+			return err
+		}
+		// We always send the whole file so don't need the
+		// block checksums
+		c.r.discard(int64(head.count) * int64(4+head.s2length))
+		if c.r.err != nil {
+			return c.r.err
+		}
+		err = c.sendFile(ndx, list[ndx], head)
+		if err != nil {
+			return err
+		}
+	}
+	c.w.writeInt(-1)
+
+	// Send the statistics then wait for the client to say goodbye
+	var totalSize int64
+	for _, e := range list {
+		if e.isRegular() {
+			totalSize += e.size
+		}
+	}
+	c.w.writeLongint(c.in.n)
+	c.w.writeLongint(c.out.n)
+	c.w.writeLongint(totalSize)
+	err = c.w.flush()
+	if err != nil {
+		return err
+	}
+	goodbye := c.r.readInt()
+	if c.r.err != nil {
+		return c.r.err
+	}
+	if goodbye != -1 {
+		return fmt.Errorf("invalid packet at end of run (%d)", goodbye)
+	}
+	return nil
+}
+
+// sendFile sends the contents of the file at index ndx as literal
+// data followed by its checksum
+func (c *conn) sendFile(ndx int32, e *fileEntry, head sumHead) error {
+	in, err := e.node.Open(os.O_RDONLY)
+	if err != nil {
+		c.errorf("failed to open %q: %v", e.name, err)
+		return nil
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	c.w.writeInt(ndx)
+	head.write(c.w)
+	hash := newFileHash(c.seed)
+	buf := make([]byte, chunkSize)
+	var readErr error
+	for {
+		n, err := io.ReadFull(in, buf)
+		if n > 0 {
+			c.w.writeInt(int32(n))
+			c.w.writeBytes(buf[:n])
+			_, _ = hash.Write(buf[:n])
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			readErr = err
+			break
+		}
+	}
+	c.w.writeInt(0)
+	sum := hash.Sum(nil)
+	if readErr != nil {
+		// Send a bad checksum so the client discards the file
+		// and asks for it again
+		c.errorf("failed to read %q: %v", e.name, readErr)
+		sum = make([]byte, sumLength)
+	} else {
+		fs.Debugf(e.node.Path(), "Sent with rsync")
+	}
+	c.w.writeBytes(sum)
+	return c.w.flush()
+}
+
+// buildFileList makes the sorted list of files to send from the paths
+// the client asked for, returning it with the I/O error flag which
+// is set if any couldn't be read
+func (c *conn) buildFileList(rules filterRules) (list fileList, ioError int32) {
+	paths := c.opt.paths
+	if len(paths) == 0 {
+		paths = []string{c.s.opt.Module}
+	}
+	seen := make(map[string]bool)
+	add := func(e *fileEntry) {
+		if !seen[e.name] {
+			seen[e.name] = true
+			list = append(list, e)
+		}
+	}
+	for _, p := range paths {
+		remote, contents := c.modulePath(p)
+		node, err := c.vfs.Stat(remote)
+		if err != nil {
+			c.errorf("failed to stat %q: %v", p, err)
+			ioError = 1
+			continue
+		}
+		if !node.IsDir() {
+			add(c.newEntry(node.Name(), node))
+			continue
+		}
+		name := node.Name()
+		if contents {
+			name = "."
+		}
+		if !c.opt.recursive && !c.opt.dirs {
+			c.infof("skipping directory %s", name)
+			continue
+		}
+		top := c.newEntry(name, node)
+		top.topDir = true
+		add(top)
+		if contents || c.opt.recursive {
+			ioError |= c.addDir(node.(*vfs.Dir), name, rules, add)
+		}
+	}
+	list.sort()
+	return list, ioError
+}
+
+// addDir adds the contents of dir, whose name in the file list is
+// prefix, with add, recursing into directories if required. It
+// returns 1 if there were any errors reading directories.
+func (c *conn) addDir(dir *vfs.Dir, prefix string, rules filterRules, add func(*fileEntry)) (ioError int32) {
+	items, err := dir.ReadDirAll()
+	if err != nil {
+		c.errorf("failed to read directory %q: %v", prefix, err)
+		return 1
+	}
+	for _, item := range items {
+		name := item.Name()
+		if prefix != "." {
+			name = path.Join(prefix, name)
+		}
+		if rules.excluded(name, item.IsDir()) {
+			continue
+		}
+		add(c.newEntry(name, item))
+		if subDir, ok := item.(*vfs.Dir); ok && c.opt.recursive {
+			ioError |= c.addDir(subDir, name, rules, add)
+		}
+	}
+	return ioError
+}
+
+// newEntry makes a file list entry for node
+func (c *conn) newEntry(name string, node vfs.Node) *fileEntry {
+	e := &fileEntry{
+		name:    name,
+		modTime: int32(node.ModTime().Unix()),
+		mode:    wireMode(node.Mode()),
+		uid:     c.vfs.Opt.UID,
+		gid:     c.vfs.Opt.GID,
+		node:    node,
+	}
+	if node.IsDir() {
+		return e
+	}
+	e.size = node.Size()
+	if e.size < 0 {
+		e.size = 0
+	}
+	if c.opt.alwaysChecksum {
+		sum, err := fileChecksum(node)
+		if err != nil {
+			c.errorf("failed to checksum %q: %v", name, err)
+		}
+		e.checksum = sum
+	}
+	return e
+}
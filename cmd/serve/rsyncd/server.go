@@ -0,0 +1,103 @@
+package rsyncd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+)
+
+// server contains everything to run the server
+type server struct {
+	ctx      context.Context
+	opt      Options
+	vfs      *vfs.VFS
+	listener net.Listener
+	waitChan chan struct{} // for waiting on the listener to close
+	mu       sync.Mutex
+	conns    map[*conn]struct{}
+}
+
+func newServer(ctx context.Context, VFS *vfs.VFS, opt *Options) (*server, error) {
+	if opt.User == "" && opt.Pass != "" {
+		return nil, errors.New("need --user with --pass")
+	}
+	s := &server{
+		ctx:      ctx,
+		opt:      *opt,
+		vfs:      VFS,
+		waitChan: make(chan struct{}),
+		conns:    make(map[*conn]struct{}),
+	}
+	if s.opt.Module == "" {
+		s.opt.Module = DefaultOpt.Module
+	}
+	return s, nil
+}
+
+// Serve starts the server listening
+func (s *server) Serve() (err error) {
+	s.listener, err = net.Listen("tcp", s.opt.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for rsync connections: %w", err)
+	}
+	fs.Logf(nil, "rsync daemon listening on %v serving module %q\n", s.listener.Addr(), s.opt.Module)
+	go s.acceptConnections()
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (s *server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// acceptConnections accepts connections until the listener is closed
+func (s *server) acceptConnections() {
+	defer close(s.waitChan)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				fs.Errorf(nil, "Failed to accept rsync connection: %v", err)
+			}
+			return
+		}
+		fs.Debugf(c.RemoteAddr(), "rsync connection accepted")
+		dc := newConn(s, c)
+		s.mu.Lock()
+		s.conns[dc] = struct{}{}
+		s.mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dc.serve()
+			_ = dc.c.Close()
+			s.mu.Lock()
+			delete(s.conns, dc)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Wait blocks while the listener is open
+func (s *server) Wait() {
+	<-s.waitChan
+}
+
+// Close the listener and the connections
+func (s *server) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for dc := range s.conns {
+		_ = dc.c.Close()
+	}
+	s.mu.Unlock()
+	s.Wait()
+	return err
+}
@@ -0,0 +1,302 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Constants for AWS signature version 4
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat  = "20060102T150405Z"
+	sigV4DateFormat  = "20060102"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+	streamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	maxClockSkew     = 15 * time.Minute
+	maxPresignExpiry = 7 * 24 * time.Hour
+)
+
+// Errors returned when authenticating requests
+var (
+	errAccessDenied          = &apiError{http.StatusForbidden, "AccessDenied", "Access Denied"}
+	errInvalidAccessKeyID    = &apiError{http.StatusForbidden, "InvalidAccessKeyId", "The access key ID you provided does not exist in our records"}
+	errSignatureDoesNotMatch = &apiError{http.StatusForbidden, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided"}
+	errRequestTimeTooSkewed  = &apiError{http.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large"}
+	errExpiredPresignRequest = &apiError{http.StatusForbidden, "AccessDenied", "Request has expired"}
+	errAuthorizationMalform  = &apiError{http.StatusBadRequest, "AuthorizationHeaderMalformed", "The authorization header or query is malformed"}
+)
+
+// authRequest is the parsed authentication of a request signed with
+// AWS signature version 4 either in the Authorization header or the
+// query for presigned URLs
+type authRequest struct {
+	accessKey     string
+	date          string // YYYYMMDD from the credential scope
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+	amzDate       time.Time
+	expires       time.Duration // only set if presigned
+	presigned     bool
+	key           []byte // signing key, set once the signature is checked
+}
+
+// scope returns the credential scope
+func (a *authRequest) scope() string {
+	return strings.Join([]string{a.date, a.region, a.service, "aws4_request"}, "/")
+}
+
+// parseCredential parses accessKey/date/region/service/aws4_request
+func (a *authRequest) parseCredential(credential string) error {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[4] != "aws4_request" {
+		return errAuthorizationMalform
+	}
+	a.accessKey, a.date, a.region, a.service = parts[0], parts[1], parts[2], parts[3]
+	if a.service != "s3" {
+		return errAuthorizationMalform
+	}
+	return nil
+}
+
+// parseAuthHeader parses an Authorization header like
+//
+//	AWS4-HMAC-SHA256 Credential=AKID/20130524/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-date, Signature=abcd
+func parseAuthHeader(r *http.Request) (*authRequest, error) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, sigV4Algorithm+" ") {
+		return nil, errAuthorizationMalform
+	}
+	a := &authRequest{}
+	for _, field := range strings.Split(header[len(sigV4Algorithm)+1:], ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return nil, errAuthorizationMalform
+		}
+		switch key {
+		case "Credential":
+			if err := a.parseCredential(value); err != nil {
+				return nil, err
+			}
+		case "SignedHeaders":
+			a.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			a.signature = value
+		}
+	}
+	if a.accessKey == "" || len(a.signedHeaders) == 0 || a.signature == "" {
+		return nil, errAuthorizationMalform
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		amzDate = r.Header.Get("Date")
+	}
+	var err error
+	a.amzDate, err = time.Parse(sigV4TimeFormat, amzDate)
+	if err != nil {
+		a.amzDate, err = http.ParseTime(amzDate)
+		if err != nil {
+			return nil, errAuthorizationMalform
+		}
+	}
+	return a, nil
+}
+
+// parseAuthQuery parses the X-Amz-* query parameters of a presigned URL
+func parseAuthQuery(query url.Values) (*authRequest, error) {
+	if query.Get("X-Amz-Algorithm") != sigV4Algorithm {
+		return nil, errAuthorizationMalform
+	}
+	a := &authRequest{presigned: true}
+	if err := a.parseCredential(query.Get("X-Amz-Credential")); err != nil {
+		return nil, err
+	}
+	a.signedHeaders = strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+	a.signature = query.Get("X-Amz-Signature")
+	var err error
+	a.amzDate, err = time.Parse(sigV4TimeFormat, query.Get("X-Amz-Date"))
+	if err != nil {
+		return nil, errAuthorizationMalform
+	}
+	seconds, err := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+	if err != nil || seconds < 0 {
+		return nil, errAuthorizationMalform
+	}
+	a.expires = time.Duration(seconds) * time.Second
+	if a.expires > maxPresignExpiry {
+		return nil, errAuthorizationMalform
+	}
+	if a.signature == "" || len(a.signedHeaders) == 0 {
+		return nil, errAuthorizationMalform
+	}
+	return a, nil
+}
+
+// checkAuth checks the signature of r against the secret for its
+// access key in keys. It returns the parsed authentication, which is
+// needed to check the signatures of a streamingPayload, and the
+// payload hash the client signed which is either a hex SHA-256,
+// unsignedPayload or streamingPayload.
+func checkAuth(r *http.Request, keys map[string]string, now time.Time) (a *authRequest, payloadHash string, err error) {
+	if r.Header.Get("Authorization") != "" {
+		a, err = parseAuthHeader(r)
+	} else if r.URL.Query().Get("X-Amz-Algorithm") != "" {
+		a, err = parseAuthQuery(r.URL.Query())
+	} else {
+		return nil, "", errAccessDenied
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	secret, ok := keys[a.accessKey]
+	if !ok {
+		return nil, "", errInvalidAccessKeyID
+	}
+	if a.amzDate.UTC().Format(sigV4DateFormat) != a.date {
+		return nil, "", errAuthorizationMalform
+	}
+	if a.presigned {
+		if now.Before(a.amzDate.Add(-maxClockSkew)) {
+			return nil, "", errRequestTimeTooSkewed
+		}
+		if now.After(a.amzDate.Add(a.expires)) {
+			return nil, "", errExpiredPresignRequest
+		}
+		payloadHash = unsignedPayload
+	} else {
+		if skew := now.Sub(a.amzDate); skew > maxClockSkew || skew < -maxClockSkew {
+			return nil, "", errRequestTimeTooSkewed
+		}
+		payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+		if payloadHash == "" {
+			payloadHash = unsignedPayload
+		}
+	}
+	a.key = signingKey(secret, a)
+	if !a.checkSignature(stringToSign(r, a, payloadHash), a.signature) {
+		return nil, "", errSignatureDoesNotMatch
+	}
+	return a, payloadHash, nil
+}
+
+// checkSignature returns true if signature is the signature of
+// toSign made with the signing key
+func (a *authRequest) checkSignature(toSign, signature string) bool {
+	want := hex.EncodeToString(hmacSHA256(a.key, []byte(toSign)))
+	return subtle.ConstantTimeCompare([]byte(want), []byte(signature)) == 1
+}
+
+// stringToSign makes the string which is signed for the request
+func stringToSign(r *http.Request, a *authRequest, payloadHash string) string {
+	return strings.Join([]string{
+		sigV4Algorithm,
+		a.amzDate.UTC().Format(sigV4TimeFormat),
+		a.scope(),
+		hexSHA256([]byte(canonicalRequest(r, a, payloadHash))),
+	}, "\n")
+}
+
+// canonicalRequest makes the canonical request which is signed
+func canonicalRequest(r *http.Request, a *authRequest, payloadHash string) string {
+	// Canonical query with the signature removed if presigned
+	query := r.URL.Query()
+	query.Del("X-Amz-Signature")
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var params []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			params = append(params, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+
+	// Canonical headers
+	var headers strings.Builder
+	for _, name := range a.signedHeaders {
+		var value string
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = strconv.FormatInt(r.ContentLength, 10)
+		default:
+			var values []string
+			for _, v := range r.Header.Values(name) {
+				values = append(values, strings.Join(strings.Fields(v), " "))
+			}
+			value = strings.Join(values, ",")
+		}
+		headers.WriteString(name)
+		headers.WriteString(":")
+		headers.WriteString(value)
+		headers.WriteString("\n")
+	}
+
+	path := r.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(path, false),
+		strings.Join(params, "&"),
+		headers.String(),
+		strings.Join(a.signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+// signingKey derives the signing key from the secret
+func signingKey(secret string, a *authRequest) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), []byte(a.date))
+	key = hmacSHA256(key, []byte(a.region))
+	key = hmacSHA256(key, []byte(a.service))
+	return hmacSHA256(key, []byte("aws4_request"))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write(data)
+	return h.Sum(nil)
+}
+
+// hexSHA256 returns the hex SHA-256 of data
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// uriEncode encodes s as AWS requires, leaving "/" alone unless
+// encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			out.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			out.WriteByte(c)
+		default:
+			fmt.Fprintf(&out, "%%%02X", c)
+		}
+	}
+	return out.String()
+}
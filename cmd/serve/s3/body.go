@@ -0,0 +1,174 @@
+package s3
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Limits on the request bodies
+const (
+	maxXMLSize   = 2 * 1024 * 1024  // largest XML request read
+	maxChunkSize = 16 * 1024 * 1024 // largest chunk of an aws-chunked body
+)
+
+// Errors returned when reading request bodies
+var (
+	errIncompleteBody = &apiError{http.StatusBadRequest, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header"}
+	errInvalidDigest  = &apiError{http.StatusBadRequest, "InvalidDigest", "The Content-MD5 you specified is not valid"}
+	errEntityTooLarge = &apiError{http.StatusBadRequest, "EntityTooLarge", "Your proposed upload exceeds the maximum allowed size"}
+)
+
+// s3Body returns the body of the request and its size, or -1 if it
+// isn't known. The body is decoded if it was sent with aws-chunked
+// encoding and returns an error at EOF if its SHA-256 or MD5 don't
+// match the ones the client sent.
+func s3Body(req *request) (in io.Reader, size int64, err error) {
+	in, size = req.r.Body, req.r.ContentLength
+	if req.payloadHash == streamingPayload {
+		size = -1
+		if decoded := req.r.Header.Get("X-Amz-Decoded-Content-Length"); decoded != "" {
+			size, err = strconv.ParseInt(decoded, 10, 64)
+			if err != nil || size < 0 {
+				return nil, 0, errInvalidArgument
+			}
+		}
+		in = newChunkedReader(in, req.auth)
+	} else if sum, err := hex.DecodeString(req.payloadHash); err == nil && len(sum) == sha256.Size {
+		in = &checkReader{in: in, h: sha256.New(), want: sum, err: errContentSHA256}
+	}
+	if contentMD5 := req.r.Header.Get("Content-MD5"); contentMD5 != "" {
+		sum, err := base64.StdEncoding.DecodeString(contentMD5)
+		if err != nil || len(sum) != md5.Size {
+			return nil, 0, errInvalidDigest
+		}
+		in = &checkReader{in: in, h: md5.New(), want: sum, err: errBadDigest}
+	}
+	return in, size, nil
+}
+
+// readAllLimit reads all of in returning an error if it is larger
+// than limit
+func readAllLimit(in io.Reader, limit int64) ([]byte, error) {
+	buf, err := ioutil.ReadAll(io.LimitReader(in, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buf)) > limit {
+		return nil, errEntityTooLarge
+	}
+	return buf, nil
+}
+
+// checkReader checks the hash of the data read from in, returning
+// err instead of io.EOF if it isn't want
+type checkReader struct {
+	in   io.Reader
+	h    hash.Hash
+	want []byte
+	err  error
+}
+
+// Read data checking the hash at EOF
+func (c *checkReader) Read(p []byte) (n int, err error) {
+	n, err = c.in.Read(p)
+	_, _ = c.h.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(c.h.Sum(nil), c.want) {
+		err = c.err
+	}
+	return n, err
+}
+
+// chunkedReader decodes a body sent with aws-chunked content
+// encoding, checking the signature of each chunk if authenticating
+type chunkedReader struct {
+	in      *bufio.Reader
+	a       *authRequest // nil if not checking signatures
+	prevSig string       // signature of the previous chunk
+	chunk   []byte       // unread data in the current chunk
+	buf     []byte
+	err     error // error to return once chunk is empty
+}
+
+// newChunkedReader makes a reader to decode in
+func newChunkedReader(in io.Reader, a *authRequest) *chunkedReader {
+	c := &chunkedReader{
+		in: bufio.NewReader(in),
+		a:  a,
+	}
+	if a != nil {
+		c.prevSig = a.signature
+	}
+	return c
+}
+
+// Read decoded data
+func (c *chunkedReader) Read(p []byte) (n int, err error) {
+	for len(c.chunk) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		c.err = c.readChunk()
+	}
+	n = copy(p, c.chunk)
+	c.chunk = c.chunk[n:]
+	return n, nil
+}
+
+// readChunk reads the next chunk which looks like
+//
+//	size-in-hex;chunk-signature=signature\r\n
+//	data\r\n
+//
+// returning io.EOF once the last chunk, which has no data, is read
+func (c *chunkedReader) readChunk() error {
+	line, err := c.in.ReadString('\n')
+	if err != nil {
+		return errIncompleteBody
+	}
+	sizeHex, params, _ := strings.Cut(strings.TrimRight(line, "\r\n"), ";")
+	size, err := strconv.ParseInt(sizeHex, 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return errIncompleteBody
+	}
+	if int64(cap(c.buf)) < size {
+		c.buf = make([]byte, size)
+	}
+	data := c.buf[:size]
+	var crlf [2]byte
+	if _, err = io.ReadFull(c.in, data); err != nil {
+		return errIncompleteBody
+	}
+	if _, err = io.ReadFull(c.in, crlf[:]); err != nil || string(crlf[:]) != "\r\n" {
+		return errIncompleteBody
+	}
+	if c.a != nil {
+		signature := strings.TrimPrefix(params, "chunk-signature=")
+		toSign := strings.Join([]string{
+			sigV4Algorithm + "-PAYLOAD",
+			c.a.amzDate.UTC().Format(sigV4TimeFormat),
+			c.a.scope(),
+			c.prevSig,
+			hexSHA256(nil),
+			hexSHA256(data),
+		}, "\n")
+		if !c.a.checkSignature(toSign, signature) {
+			return errSignatureDoesNotMatch
+		}
+		c.prevSig = signature
+	}
+	if size == 0 {
+		return io.EOF
+	}
+	c.chunk = data
+	return nil
+}
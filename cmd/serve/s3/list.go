@@ -0,0 +1,214 @@
+package s3
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// maxKeys is the most keys returned by a listing
+const maxKeys = 1000
+
+// listBucketResult is the response to ListObjects and ListObjectsV2
+type listBucketResult struct {
+	XMLName        xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name           string         `xml:"Name"`
+	Prefix         string         `xml:"Prefix"`
+	Delimiter      string         `xml:"Delimiter,omitempty"`
+	MaxKeys        int            `xml:"MaxKeys"`
+	IsTruncated    bool           `xml:"IsTruncated"`
+	Contents       []objectResult `xml:"Contents"`
+	CommonPrefixes []prefixResult `xml:"CommonPrefixes"`
+
+	// ListObjects only
+	Marker     *string `xml:"Marker"`
+	NextMarker string  `xml:"NextMarker,omitempty"`
+
+	// ListObjectsV2 only
+	KeyCount              *int   `xml:"KeyCount"`
+	ContinuationToken     string `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string `xml:"NextContinuationToken,omitempty"`
+	StartAfter            string `xml:"StartAfter,omitempty"`
+}
+
+// objectResult describes an object in listBucketResult
+type objectResult struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+	Owner        *owner `xml:"Owner,omitempty"`
+}
+
+// prefixResult describes a common prefix in listBucketResult
+type prefixResult struct {
+	Prefix string `xml:"Prefix"`
+}
+
+// listEntry is an object or a common prefix found by listBucket
+type listEntry struct {
+	key string
+	o   fs.Object // nil if this is a common prefix
+}
+
+// listBucket returns the objects in bucket with keys starting with
+// prefix sorted by key. If delimiter is set then the keys with
+// delimiter after the prefix are returned as common prefixes instead.
+func (s *Server) listBucket(ctx context.Context, bucket, prefix, delimiter string) (entries []listEntry, err error) {
+	// Only list the directory the prefix is in
+	dir := ""
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = prefix[:i]
+	}
+	root := path.Join(bucket, dir)
+	trimKey := func(remote string) string {
+		return strings.TrimPrefix(remote, bucket+"/")
+	}
+	if delimiter == "/" {
+		// Use a directory listing as the directories are the
+		// common prefixes
+		var dirEntries fs.DirEntries
+		dirEntries, err = s.f.List(ctx, root)
+		for _, entry := range dirEntries {
+			key := trimKey(entry.Remote())
+			if !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			switch x := entry.(type) {
+			case fs.Object:
+				entries = append(entries, listEntry{key: key, o: x})
+			case fs.Directory:
+				entries = append(entries, listEntry{key: key + "/"})
+			}
+		}
+	} else {
+		prefixes := map[string]struct{}{}
+		err = walk.ListR(ctx, s.f, root, true, -1, walk.ListObjects, func(dirEntries fs.DirEntries) error {
+			for _, entry := range dirEntries {
+				o, ok := entry.(fs.Object)
+				if !ok {
+					continue
+				}
+				key := trimKey(o.Remote())
+				if !strings.HasPrefix(key, prefix) {
+					continue
+				}
+				if delimiter != "" {
+					rest := key[len(prefix):]
+					if i := strings.Index(rest, delimiter); i >= 0 {
+						prefixes[prefix+rest[:i+len(delimiter)]] = struct{}{}
+						continue
+					}
+				}
+				entries = append(entries, listEntry{key: key, o: o})
+			}
+			return nil
+		})
+		for commonPrefix := range prefixes {
+			entries = append(entries, listEntry{key: commonPrefix})
+		}
+	}
+	if errors.Is(err, fs.ErrorDirNotFound) {
+		// The listing is empty if the bucket exists
+		return nil, s.bucketExists(ctx, bucket)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+	return entries, nil
+}
+
+// listObjects lists the objects in a bucket with ListObjects or
+// ListObjectsV2 if the list-type is 2
+func (s *Server) listObjects(req *request) error {
+	v2 := req.query.Get("list-type") == "2"
+	result := listBucketResult{
+		Name:      req.bucket,
+		Prefix:    req.query.Get("prefix"),
+		Delimiter: req.query.Get("delimiter"),
+		MaxKeys:   maxKeys,
+		Contents:  []objectResult{},
+	}
+	if maxKeysParam := req.query.Get("max-keys"); maxKeysParam != "" {
+		n, err := strconv.Atoi(maxKeysParam)
+		if err != nil || n < 0 {
+			return errInvalidArgument
+		}
+		if n < result.MaxKeys {
+			result.MaxKeys = n
+		}
+	}
+
+	// Find the key to list after
+	var after string
+	if v2 {
+		result.StartAfter = req.query.Get("start-after")
+		result.ContinuationToken = req.query.Get("continuation-token")
+		after = result.StartAfter
+		if result.ContinuationToken != "" {
+			token, err := base64.URLEncoding.DecodeString(result.ContinuationToken)
+			if err != nil {
+				return errInvalidArgument
+			}
+			after = string(token)
+		}
+	} else {
+		marker := req.query.Get("marker")
+		result.Marker = &marker
+		after = marker
+	}
+
+	entries, err := s.listBucket(req.ctx, req.bucket, result.Prefix, result.Delimiter)
+	if err != nil {
+		return err
+	}
+	entries = entries[sort.Search(len(entries), func(i int) bool {
+		return entries[i].key > after
+	}):]
+	if len(entries) > result.MaxKeys {
+		entries = entries[:result.MaxKeys]
+		result.IsTruncated = true
+		last := entries[len(entries)-1].key
+		if v2 {
+			result.NextContinuationToken = base64.URLEncoding.EncodeToString([]byte(last))
+		} else {
+			result.NextMarker = last
+		}
+	}
+	for _, entry := range entries {
+		if entry.o == nil {
+			result.CommonPrefixes = append(result.CommonPrefixes, prefixResult{Prefix: entry.key})
+			continue
+		}
+		object := objectResult{
+			Key:          entry.key,
+			LastModified: formatTime(entry.o.ModTime(req.ctx)),
+			ETag:         etag(req.ctx, entry.o),
+			Size:         entry.o.Size(),
+			StorageClass: "STANDARD",
+		}
+		if !v2 || req.query.Get("fetch-owner") == "true" {
+			object.Owner = &theOwner
+		}
+		result.Contents = append(result.Contents, object)
+	}
+	if v2 {
+		keyCount := len(entries)
+		result.KeyCount = &keyCount
+	}
+	writeXML(req.w, http.StatusOK, result)
+	return nil
+}
@@ -0,0 +1,335 @@
+package s3
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// maxPartNumber is the largest part number of a multipart upload
+const maxPartNumber = 10000
+
+// multipartUpload is a multipart upload in progress. The parts are
+// stored in a temporary directory until the upload is completed.
+type multipartUpload struct {
+	bucket  string
+	key     string
+	modTime time.Time
+	dir     string // temporary directory holding the parts
+	mu      sync.Mutex
+	parts   map[int]string // hex MD5 of each part uploaded
+	done    bool           // set once completed or aborted
+}
+
+// partPath returns the path of the file for part n
+func (up *multipartUpload) partPath(n int) string {
+	return filepath.Join(up.dir, strconv.Itoa(n))
+}
+
+// multipartUploads are the multipart uploads in progress
+type multipartUploads struct {
+	mu      sync.Mutex
+	uploads map[string]*multipartUpload // by upload ID
+}
+
+// newMultipartUploads makes an empty multipartUploads
+func newMultipartUploads() *multipartUploads {
+	return &multipartUploads{
+		uploads: make(map[string]*multipartUpload),
+	}
+}
+
+// add starts a new upload returning its ID
+func (u *multipartUploads) add(bucket, key string, modTime time.Time) (id string, err error) {
+	var buf [16]byte
+	_, err = rand.Read(buf[:])
+	if err != nil {
+		return "", err
+	}
+	id = hex.EncodeToString(buf[:])
+	dir, err := os.MkdirTemp("", "rclone-serve-s3-")
+	if err != nil {
+		return "", fmt.Errorf("failed to make directory for multipart upload: %w", err)
+	}
+	u.mu.Lock()
+	u.uploads[id] = &multipartUpload{
+		bucket:  bucket,
+		key:     key,
+		modTime: modTime,
+		dir:     dir,
+		parts:   make(map[int]string),
+	}
+	u.mu.Unlock()
+	return id, nil
+}
+
+// get finds the upload the request is for
+func (u *multipartUploads) get(req *request) (*multipartUpload, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	up, ok := u.uploads[req.query.Get("uploadId")]
+	if !ok || up.bucket != req.bucket || up.key != req.key {
+		return nil, errNoSuchUpload
+	}
+	return up, nil
+}
+
+// remove the upload with id and its parts. up.mu must be held.
+func (u *multipartUploads) remove(id string, up *multipartUpload) {
+	u.mu.Lock()
+	delete(u.uploads, id)
+	u.mu.Unlock()
+	up.done = true
+	err := os.RemoveAll(up.dir)
+	if err != nil {
+		fs.Errorf(nil, "Failed to remove multipart upload parts: %v", err)
+	}
+}
+
+// abortAll removes all the uploads in progress
+func (u *multipartUploads) abortAll() {
+	u.mu.Lock()
+	uploads := u.uploads
+	u.uploads = make(map[string]*multipartUpload)
+	u.mu.Unlock()
+	for id, up := range uploads {
+		up.mu.Lock()
+		u.remove(id, up)
+		up.mu.Unlock()
+	}
+}
+
+// initiateMultipartUploadResult is the response to CreateMultipartUpload
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+// createMultipartUpload starts a multipart upload
+func (s *Server) createMultipartUpload(req *request) error {
+	if strings.HasSuffix(req.key, "/") {
+		return errInvalidKey
+	}
+	err := s.bucketExists(req.ctx, req.bucket)
+	if err != nil {
+		return err
+	}
+	id, err := s.uploads.add(req.bucket, req.key, requestModTime(req.r))
+	if err != nil {
+		return err
+	}
+	writeXML(req.w, http.StatusOK, initiateMultipartUploadResult{
+		Bucket:   req.bucket,
+		Key:      req.key,
+		UploadID: id,
+	})
+	return nil
+}
+
+// uploadPart stores a part of a multipart upload
+func (s *Server) uploadPart(req *request) (err error) {
+	n, err := strconv.Atoi(req.query.Get("partNumber"))
+	if err != nil || n < 1 || n > maxPartNumber {
+		return errInvalidArgument
+	}
+	up, err := s.uploads.get(req)
+	if err != nil {
+		return err
+	}
+	in, _, err := s3Body(req)
+	if err != nil {
+		return err
+	}
+
+	// Write the part to a temporary file so it only replaces a part
+	// with the same number once it is complete
+	out, err := os.CreateTemp(up.dir, "part-")
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errNoSuchUpload
+		}
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(out.Name())
+		}
+	}()
+	md5Hash := md5.New()
+	_, err = io.Copy(io.MultiWriter(out, md5Hash), in)
+	closeErr := out.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	sum := hex.EncodeToString(md5Hash.Sum(nil))
+
+	up.mu.Lock()
+	defer up.mu.Unlock()
+	if up.done {
+		return errNoSuchUpload
+	}
+	err = os.Rename(out.Name(), up.partPath(n))
+	if err != nil {
+		return err
+	}
+	up.parts[n] = sum
+	req.w.Header().Set("ETag", `"`+sum+`"`)
+	req.w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// completeMultipartUpload is the request body of CompleteMultipartUpload
+type completeMultipartUpload struct {
+	XMLName xml.Name `xml:"CompleteMultipartUpload"`
+	Parts   []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	} `xml:"Part"`
+}
+
+// completeMultipartUploadResult is the response to CompleteMultipartUpload
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// completeMultipartUpload uploads the parts listed in the request
+// body as the object
+func (s *Server) completeMultipartUpload(req *request) error {
+	up, err := s.uploads.get(req)
+	if err != nil {
+		return err
+	}
+	var body completeMultipartUpload
+	err = readXML(req, &body)
+	if err != nil {
+		return err
+	}
+	if len(body.Parts) == 0 {
+		return errMalformedXML
+	}
+
+	up.mu.Lock()
+	defer up.mu.Unlock()
+	if up.done {
+		return errNoSuchUpload
+	}
+	var (
+		paths  = make([]string, 0, len(body.Parts))
+		md5s   = make([]byte, 0, len(body.Parts)*md5.Size)
+		size   int64
+		prevN  = 0
+		partIn = &partsReader{}
+	)
+	for _, part := range body.Parts {
+		if part.PartNumber <= prevN {
+			return errInvalidPartOrder
+		}
+		prevN = part.PartNumber
+		sum, ok := up.parts[part.PartNumber]
+		if !ok || strings.Trim(part.ETag, `"`) != sum {
+			return errInvalidPart
+		}
+		fi, err := os.Stat(up.partPath(part.PartNumber))
+		if err != nil {
+			return err
+		}
+		size += fi.Size()
+		paths = append(paths, up.partPath(part.PartNumber))
+		binarySum, _ := hex.DecodeString(sum)
+		md5s = append(md5s, binarySum...)
+	}
+	partIn.paths = paths
+	_, err = operations.RcatSize(req.ctx, s.f, req.remote(), partIn, size, up.modTime)
+	_ = partIn.Close()
+	if err != nil {
+		return err
+	}
+	s.uploads.remove(req.query.Get("uploadId"), up)
+	writeXML(req.w, http.StatusOK, completeMultipartUploadResult{
+		Location: "/" + req.bucket + "/" + req.key,
+		Bucket:   req.bucket,
+		Key:      req.key,
+		ETag:     fmt.Sprintf(`"%x-%d"`, md5.Sum(md5s), len(body.Parts)),
+	})
+	return nil
+}
+
+// abortMultipartUpload removes the upload and its parts
+func (s *Server) abortMultipartUpload(req *request) error {
+	up, err := s.uploads.get(req)
+	if err != nil {
+		return err
+	}
+	up.mu.Lock()
+	defer up.mu.Unlock()
+	if !up.done {
+		s.uploads.remove(req.query.Get("uploadId"), up)
+	}
+	req.w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// partsReader reads the files in paths one after another, only
+// opening one at a time
+type partsReader struct {
+	paths []string
+	cur   *os.File
+}
+
+// Read data from the parts
+func (p *partsReader) Read(b []byte) (n int, err error) {
+	for {
+		if p.cur == nil {
+			if len(p.paths) == 0 {
+				return 0, io.EOF
+			}
+			p.cur, err = os.Open(p.paths[0])
+			if err != nil {
+				return 0, err
+			}
+			p.paths = p.paths[1:]
+		}
+		n, err = p.cur.Read(b)
+		if err == io.EOF {
+			_ = p.cur.Close()
+			p.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+// Close the part being read
+func (p *partsReader) Close() error {
+	if p.cur == nil {
+		return nil
+	}
+	err := p.cur.Close()
+	p.cur = nil
+	return err
+}
@@ -0,0 +1,291 @@
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/lib/http/serve"
+)
+
+// metaMtime is the metadata header the modification time is kept in,
+// the same as rclone's s3 backend uses
+const metaMtime = "X-Amz-Meta-Mtime"
+
+// emptyETag is the ETag of an object with no data
+const emptyETag = `"d41d8cd98f00b204e9800998ecf8427e"`
+
+// etag returns the ETag for o. This is the MD5 of o if the backend
+// can read it cheaply, otherwise it is made from the size and
+// modification time of o with a "-1" suffix so clients don't mistake
+// it for the MD5.
+func etag(ctx context.Context, o fs.Object) string {
+	if !o.Fs().Features().SlowHash && o.Fs().Hashes().Contains(hash.MD5) {
+		if sum, err := o.Hash(ctx, hash.MD5); err == nil && sum != "" {
+			return `"` + sum + `"`
+		}
+	}
+	sum := md5.Sum([]byte(fmt.Sprintf("%s\x00%d\x00%d", o.Remote(), o.Size(), o.ModTime(ctx).UnixNano())))
+	return fmt.Sprintf(`"%x-1"`, sum)
+}
+
+// formatMtime formats t for the metaMtime header as seconds since the
+// epoch with a fractional part
+func formatMtime(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+// parseMtime parses the metaMtime header
+func parseMtime(s string) (t time.Time, err error) {
+	secs, frac, _ := strings.Cut(s, ".")
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return t, err
+	}
+	var nsec int64
+	if frac != "" {
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		nsec, err = strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64)
+		if err != nil {
+			return t, err
+		}
+	}
+	return time.Unix(sec, nsec), nil
+}
+
+// requestModTime returns the modification time the client set in the
+// metaMtime header, or the current time if it didn't set one
+func requestModTime(r *http.Request) time.Time {
+	if mtime := r.Header.Get(metaMtime); mtime != "" {
+		t, err := parseMtime(mtime)
+		if err == nil {
+			return t
+		}
+		fs.Debugf(r.URL.Path, "Ignoring invalid %s %q: %v", metaMtime, mtime, err)
+	}
+	return time.Now()
+}
+
+// newObject finds the object being requested
+func (s *Server) newObject(ctx context.Context, bucket, key string) (fs.Object, error) {
+	if strings.HasSuffix(key, "/") {
+		return nil, errNoSuchKey
+	}
+	o, err := s.f.NewObject(ctx, bucket+"/"+key)
+	if err != nil {
+		if errors.Is(err, fs.ErrorObjectNotFound) || errors.Is(err, fs.ErrorDirNotFound) {
+			if bucketErr := s.bucketExists(ctx, bucket); bucketErr != nil {
+				return nil, bucketErr
+			}
+			return nil, errNoSuchKey
+		}
+		return nil, err
+	}
+	return o, nil
+}
+
+// getObject serves the object with GET or HEAD
+func (s *Server) getObject(req *request) error {
+	o, err := s.newObject(req.ctx, req.bucket, req.key)
+	if err != nil {
+		return err
+	}
+	modTime := o.ModTime(req.ctx)
+	h := req.w.Header()
+	h.Set("ETag", etag(req.ctx, o))
+	h.Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	h.Set(metaMtime, formatMtime(modTime))
+	serve.Object(req.w, req.r, o)
+	return nil
+}
+
+// putObject uploads the object, or makes a directory if the key ends
+// in "/"
+func (s *Server) putObject(req *request) error {
+	in, size, err := s3Body(req)
+	if err != nil {
+		return err
+	}
+	err = s.bucketExists(req.ctx, req.bucket)
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(req.key, "/") {
+		if size != 0 {
+			return errInvalidKey
+		}
+		err = s.f.Mkdir(req.ctx, strings.TrimSuffix(req.remote(), "/"))
+		if err != nil {
+			return err
+		}
+		req.w.Header().Set("ETag", emptyETag)
+		req.w.WriteHeader(http.StatusOK)
+		return nil
+	}
+	md5Hash := md5.New()
+	in = io.TeeReader(in, md5Hash)
+	_, err = operations.RcatSize(req.ctx, s.f, req.remote(), ioutil.NopCloser(in), size, requestModTime(req.r))
+	if err != nil {
+		return err
+	}
+	req.w.Header().Set("ETag", `"`+hex.EncodeToString(md5Hash.Sum(nil))+`"`)
+	req.w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// copyObjectResult is the response to CopyObject
+type copyObjectResult struct {
+	XMLName      xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CopyObjectResult"`
+	LastModified string   `xml:"LastModified"`
+	ETag         string   `xml:"ETag"`
+}
+
+// copyObject copies the object in the X-Amz-Copy-Source header to the
+// object being requested
+func (s *Server) copyObject(req *request) error {
+	source, _, _ := strings.Cut(req.r.Header.Get("X-Amz-Copy-Source"), "?")
+	source, err := url.PathUnescape(source)
+	if err != nil {
+		return errInvalidArgument
+	}
+	srcBucket, srcKey, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	if srcBucket == "" || !validBucket(srcBucket) || srcKey == "" || !validKey(srcKey) {
+		return errInvalidArgument
+	}
+	src, err := s.newObject(req.ctx, srcBucket, srcKey)
+	if err != nil {
+		return err
+	}
+	err = s.bucketExists(req.ctx, req.bucket)
+	if err != nil {
+		return err
+	}
+	dst := src
+	if src.Remote() != req.remote() {
+		dst, err = operations.Copy(req.ctx, s.f, nil, req.remote(), src)
+		if err != nil {
+			return err
+		}
+	}
+	if dst == nil {
+		// --dry-run
+		dst = src
+	} else if req.r.Header.Get("X-Amz-Metadata-Directive") == "REPLACE" && req.r.Header.Get(metaMtime) != "" {
+		err = dst.SetModTime(req.ctx, requestModTime(req.r))
+		if err != nil {
+			fs.Debugf(dst, "Failed to set modification time on copy: %v", err)
+		}
+	}
+	writeXML(req.w, http.StatusOK, copyObjectResult{
+		LastModified: formatTime(dst.ModTime(req.ctx)),
+		ETag:         etag(req.ctx, dst),
+	})
+	return nil
+}
+
+// deleteKey deletes the object with key in bucket, or the directory
+// if key ends in "/". It isn't an error if the object doesn't exist.
+func (s *Server) deleteKey(ctx context.Context, bucket, key string) error {
+	if strings.HasSuffix(key, "/") {
+		err := s.f.Rmdir(ctx, bucket+"/"+strings.TrimSuffix(key, "/"))
+		if err != nil {
+			fs.Debugf(bucket+"/"+key, "Not removing directory: %v", err)
+		}
+		return nil
+	}
+	o, err := s.f.NewObject(ctx, bucket+"/"+key)
+	if err == nil {
+		err = operations.DeleteFile(ctx, o)
+	}
+	if err != nil && !errors.Is(err, fs.ErrorObjectNotFound) && !errors.Is(err, fs.ErrorDirNotFound) {
+		return err
+	}
+	return nil
+}
+
+// deleteObject deletes the object being requested
+func (s *Server) deleteObject(req *request) error {
+	err := s.deleteKey(req.ctx, req.bucket, req.key)
+	if err != nil {
+		return err
+	}
+	req.w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// deleteRequest is the request body of DeleteObjects
+type deleteRequest struct {
+	XMLName xml.Name `xml:"Delete"`
+	Quiet   bool     `xml:"Quiet"`
+	Objects []struct {
+		Key string `xml:"Key"`
+	} `xml:"Object"`
+}
+
+// deleteResult is the response to DeleteObjects
+type deleteResult struct {
+	XMLName xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ DeleteResult"`
+	Deleted []deletedResult `xml:"Deleted"`
+	Errors  []deleteError   `xml:"Error"`
+}
+
+// deletedResult is an object deleted by DeleteObjects
+type deletedResult struct {
+	Key string `xml:"Key"`
+}
+
+// deleteError is an object DeleteObjects failed to delete
+type deleteError struct {
+	Key     string `xml:"Key"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// deleteObjects deletes the objects listed in the request body
+func (s *Server) deleteObjects(req *request) error {
+	var body deleteRequest
+	err := readXML(req, &body)
+	if err != nil {
+		return err
+	}
+	if len(body.Objects) > maxKeys {
+		return errMalformedXML
+	}
+	var result deleteResult
+	for _, object := range body.Objects {
+		err = errInvalidKey
+		if validKey(object.Key) {
+			err = s.deleteKey(req.ctx, req.bucket, object.Key)
+		}
+		if err != nil {
+			apiErr := errInternalServerError
+			if !errors.As(err, &apiErr) {
+				fs.Errorf(req.bucket+"/"+object.Key, "Failed to delete: %v", err)
+			}
+			result.Errors = append(result.Errors, deleteError{
+				Key:     object.Key,
+				Code:    apiErr.code,
+				Message: apiErr.message,
+			})
+		} else if !body.Quiet {
+			result.Deleted = append(result.Deleted, deletedResult{Key: object.Key})
+		}
+	}
+	writeXML(req.w, http.StatusOK, result)
+	return nil
+}
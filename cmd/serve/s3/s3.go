@@ -0,0 +1,527 @@
+// Package s3 serves a remote over the Amazon S3 protocol
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/serve/httplib"
+	"github.com/rclone/rclone/cmd/serve/httplib/httpflags"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/spf13/cobra"
+)
+
+var (
+	authKeys []string
+)
+
+func init() {
+	httpflags.AddFlags(Command.Flags())
+	flagSet := Command.Flags()
+	flags.StringArrayVarP(flagSet, &authKeys, "auth-key", "", authKeys, "Set key pair for S3 authentication (repeat for more), e.g. ACCESS_KEY_ID,SECRET_ACCESS_KEY")
+}
+
+// Command definition for cobra
+var Command = &cobra.Command{
+	Use:   "s3 remote:path",
+	Short: `Serve remote:path over the S3 protocol.`,
+	// Note: "|" will be replaced by backticks below
+	Long: strings.ReplaceAll(`rclone serve s3 implements a basic S3 server that serves a remote
+via S3. This lets any program which can talk to S3 read and write
+files on any remote rclone supports.
+
+Each directory at the top level of |remote:path| is served as a
+bucket and the files inside it as its objects, so
+
+    rclone serve s3 --auth-key ACCESS_KEY_ID,SECRET_ACCESS_KEY remote:path
+
+serves the file |remote:path/bucket/dir/file.txt| as the object
+|dir/file.txt| in the bucket |bucket|.
+
+The server supports
+
+- listing buckets, and listing objects with both versions of
+  ListObjects including prefixes and delimiters
+- creating and deleting buckets
+- getting, putting, copying and deleting objects, including deleting
+  many objects at once
+- multipart uploads
+- presigned URLs
+
+Only path style addressing is supported, so S3 clients must be set up
+to use it, e.g. |force_path_style = true| for rclone's own s3 backend
+or |--addressing-style path| for |aws s3|.
+
+The modification time of an object is read from and stored in the
+|X-Amz-Meta-Mtime| metadata as rclone's s3 backend does. Other
+metadata, storage classes, versioning, ACLs and bucket policies aren't
+supported.
+
+The parts of multipart uploads are kept in the temporary directory
+until the upload is completed or aborted, so make sure it has enough
+space.
+
+#### Authentication
+
+Use |--auth-key ACCESS_KEY_ID,SECRET_ACCESS_KEY| to set the keys
+clients must use. It can be repeated to allow several pairs of keys.
+Requests must be signed with AWS signature version 4, either in the
+|Authorization| header or the query of a presigned URL.
+
+If no |--auth-key| is given then the server allows anonymous access to
+anyone who can connect to it and doesn't check the signatures of
+requests.
+
+The |--user|, |--pass| and |--htpasswd| flags can't be used with this
+server as S3 clients don't support basic authentication.
+`, "|", "`") + httplib.Help,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsSrc(args)
+		cmd.Run(false, false, command, func() error {
+			keys, err := parseAuthKeys(authKeys)
+			if err != nil {
+				return err
+			}
+			opt := httpflags.Opt
+			if opt.HtPasswd != "" || opt.BasicUser != "" {
+				return errors.New("--user, --pass and --htpasswd can't be used with serve s3 - use --auth-key instead")
+			}
+			s := NewServer(f, &opt, keys)
+			err = s.Serve()
+			if err != nil {
+				return err
+			}
+			s.Wait()
+			return nil
+		})
+	},
+}
+
+// parseAuthKeys parses the --auth-key flags into secret keys indexed
+// by access key
+func parseAuthKeys(in []string) (map[string]string, error) {
+	keys := make(map[string]string, len(in))
+	for _, pair := range in {
+		accessKey, secretKey, ok := strings.Cut(pair, ",")
+		if !ok || accessKey == "" || secretKey == "" {
+			return nil, fmt.Errorf("--auth-key %q should be ACCESS_KEY_ID,SECRET_ACCESS_KEY", pair)
+		}
+		keys[accessKey] = secretKey
+	}
+	return keys, nil
+}
+
+// Server contains everything to run the Server
+type Server struct {
+	*httplib.Server
+	f       fs.Fs
+	keys    map[string]string // secret keys by access key - no auth if empty
+	uploads *multipartUploads
+}
+
+// NewServer returns an HTTP server that speaks the S3 protocol
+func NewServer(f fs.Fs, opt *httplib.Options, keys map[string]string) *Server {
+	mux := http.NewServeMux()
+	s := &Server{
+		Server:  httplib.NewServer(mux, opt),
+		f:       f,
+		keys:    keys,
+		uploads: newMultipartUploads(),
+	}
+	mux.HandleFunc(s.Opt.BaseURL+"/", s.ServeHTTP)
+	return s
+}
+
+// Serve runs the http server in the background.
+//
+// Use s.Close() and s.Wait() to shutdown server
+func (s *Server) Serve() error {
+	err := s.Server.Serve()
+	if err != nil {
+		return err
+	}
+	if len(s.keys) == 0 {
+		fs.Logf(s.f, "No --auth-key set - serving S3 without authentication")
+	}
+	fs.Logf(s.f, "Serving S3 on %s", s.URL())
+	return nil
+}
+
+// Close shuts the running server down and removes the parts of any
+// unfinished multipart uploads
+func (s *Server) Close() {
+	s.Server.Close()
+	s.uploads.abortAll()
+}
+
+// apiError is an error returned to the client as an S3 error
+// response
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+// Error satisfies the error interface
+func (e *apiError) Error() string {
+	return e.code + ": " + e.message
+}
+
+// Errors returned by the server
+var (
+	errNoSuchBucket        = &apiError{http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist"}
+	errNoSuchKey           = &apiError{http.StatusNotFound, "NoSuchKey", "The specified key does not exist"}
+	errNoSuchUpload        = &apiError{http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist"}
+	errBucketNotEmpty      = &apiError{http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty"}
+	errInvalidBucketName   = &apiError{http.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid"}
+	errInvalidKey          = &apiError{http.StatusBadRequest, "InvalidArgument", "The specified key is not valid"}
+	errInvalidArgument     = &apiError{http.StatusBadRequest, "InvalidArgument", "Invalid Argument"}
+	errInvalidPart         = &apiError{http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found"}
+	errInvalidPartOrder    = &apiError{http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order"}
+	errMalformedXML        = &apiError{http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema"}
+	errBadDigest           = &apiError{http.StatusBadRequest, "BadDigest", "The Content-MD5 you specified did not match what we received"}
+	errContentSHA256       = &apiError{http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed"}
+	errMethodNotAllowed    = &apiError{http.StatusMethodNotAllowed, "MethodNotAllowed", "The specified method is not allowed against this resource"}
+	errNotImplemented      = &apiError{http.StatusNotImplemented, "NotImplemented", "A header or query you provided implies functionality that is not implemented"}
+	errInternalServerError = &apiError{http.StatusInternalServerError, "InternalError", "We encountered an internal error. Please try again."}
+)
+
+// errorResponse is the body of an S3 error response
+type errorResponse struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+// writeXML writes v as an XML response with status
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	err := xml.NewEncoder(w).Encode(v)
+	if err != nil {
+		fs.Errorf(nil, "Failed to write S3 response: %v", err)
+	}
+}
+
+// writeError writes err as an S3 error response
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *apiError
+	switch {
+	case errors.As(err, &apiErr):
+	case errors.Is(err, fs.ErrorObjectNotFound), errors.Is(err, fs.ErrorIsDir), errors.Is(err, fs.ErrorNotAFile):
+		apiErr = errNoSuchKey
+	case errors.Is(err, fs.ErrorDirNotFound):
+		apiErr = errNoSuchBucket
+	default:
+		fs.Errorf(r.URL.Path, "%s request error: %v", r.Method, err)
+		apiErr = errInternalServerError
+	}
+	fs.Debugf(r.URL.Path, "%s request failed: %v", r.Method, apiErr)
+	writeXML(w, apiErr.status, errorResponse{
+		Code:     apiErr.code,
+		Message:  apiErr.message,
+		Resource: r.URL.Path,
+	})
+}
+
+// ServeHTTP reads incoming requests and dispatches them
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Server", "rclone/"+fs.Version)
+
+	urlPath, ok := s.Path(w, r)
+	if !ok {
+		return
+	}
+	fs.Debugf(s.f, "%s %s", r.Method, urlPath)
+
+	var a *authRequest
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if len(s.keys) > 0 {
+		var err error
+		a, payloadHash, err = checkAuth(r, s.keys, time.Now())
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+	}
+	if strings.HasPrefix(payloadHash, "STREAMING-") && payloadHash != streamingPayload {
+		writeError(w, r, errNotImplemented)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(urlPath, "/"), "/")
+	if bucket != "" && !validBucket(bucket) {
+		writeError(w, r, errInvalidBucketName)
+		return
+	}
+	if key != "" && !validKey(key) {
+		writeError(w, r, errInvalidKey)
+		return
+	}
+	req := &request{
+		w:           w,
+		r:           r,
+		ctx:         r.Context(),
+		query:       r.URL.Query(),
+		bucket:      bucket,
+		key:         key,
+		auth:        a,
+		payloadHash: payloadHash,
+	}
+
+	// Dispatch on path then method then query
+	var err error
+	switch {
+	case bucket == "":
+		switch r.Method {
+		case "GET":
+			err = s.listBuckets(req)
+		default:
+			err = errMethodNotAllowed
+		}
+	case key == "":
+		switch r.Method {
+		case "GET":
+			if req.has("uploads") || req.has("versions") || req.has("acl") || req.has("policy") {
+				err = errNotImplemented
+			} else if req.has("location") {
+				err = s.getBucketLocation(req)
+			} else {
+				err = s.listObjects(req)
+			}
+		case "HEAD":
+			err = s.headBucket(req)
+		case "PUT":
+			err = s.createBucket(req)
+		case "DELETE":
+			err = s.deleteBucket(req)
+		case "POST":
+			if req.has("delete") {
+				err = s.deleteObjects(req)
+			} else {
+				err = errNotImplemented
+			}
+		default:
+			err = errMethodNotAllowed
+		}
+	default:
+		switch r.Method {
+		case "GET", "HEAD":
+			if req.has("uploadId") || req.has("acl") || req.has("tagging") {
+				err = errNotImplemented
+			} else {
+				err = s.getObject(req)
+			}
+		case "PUT":
+			if req.has("uploadId") {
+				err = s.uploadPart(req)
+			} else if r.Header.Get("X-Amz-Copy-Source") != "" {
+				err = s.copyObject(req)
+			} else {
+				err = s.putObject(req)
+			}
+		case "POST":
+			if req.has("uploads") {
+				err = s.createMultipartUpload(req)
+			} else if req.has("uploadId") {
+				err = s.completeMultipartUpload(req)
+			} else {
+				err = errNotImplemented
+			}
+		case "DELETE":
+			if req.has("uploadId") {
+				err = s.abortMultipartUpload(req)
+			} else {
+				err = s.deleteObject(req)
+			}
+		default:
+			err = errMethodNotAllowed
+		}
+	}
+	if err != nil {
+		writeError(w, r, err)
+	}
+}
+
+// request is an S3 request being served
+type request struct {
+	w           http.ResponseWriter
+	r           *http.Request
+	ctx         context.Context
+	query       url.Values
+	bucket      string
+	key         string
+	auth        *authRequest // nil if not authenticating
+	payloadHash string       // the x-amz-content-sha256 of the request
+}
+
+// has returns true if the query has the parameter name
+func (req *request) has(name string) bool {
+	_, ok := req.query[name]
+	return ok
+}
+
+// remote returns the remote for the object being requested
+func (req *request) remote() string {
+	return req.bucket + "/" + req.key
+}
+
+// validBucket returns true if bucket can be used as a bucket name
+func validBucket(bucket string) bool {
+	return bucket != "." && bucket != ".." && !strings.ContainsAny(bucket, "\\")
+}
+
+// validKey returns true if key can be used as an object key. Keys
+// may not have empty, "." or ".." path segments so they can't refer
+// to anything outside their bucket, though they may end in "/" to
+// refer to a directory.
+func validKey(key string) bool {
+	for _, segment := range strings.Split(strings.TrimSuffix(key, "/"), "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// bucketExists returns an error if bucket doesn't exist
+func (s *Server) bucketExists(ctx context.Context, bucket string) error {
+	entries, err := s.f.List(ctx, "")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, ok := entry.(fs.Directory); ok && entry.Remote() == bucket {
+			return nil
+		}
+	}
+	return errNoSuchBucket
+}
+
+// listAllMyBucketsResult is the response to ListBuckets
+type listAllMyBucketsResult struct {
+	XMLName xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+	Owner   owner          `xml:"Owner"`
+	Buckets []bucketResult `xml:"Buckets>Bucket"`
+}
+
+// owner is the owner of all the buckets and objects
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+// bucketResult describes a bucket in listAllMyBucketsResult
+type bucketResult struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+// theOwner is the owner returned in listings
+var theOwner = owner{ID: "rclone", DisplayName: "rclone"}
+
+// formatTime formats t as S3 does in XML responses
+func formatTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// listBuckets lists the directories in the root as buckets
+func (s *Server) listBuckets(req *request) error {
+	entries, err := s.f.List(req.ctx, "")
+	if err != nil {
+		return err
+	}
+	result := listAllMyBucketsResult{Owner: theOwner, Buckets: []bucketResult{}}
+	for _, entry := range entries {
+		if dir, ok := entry.(fs.Directory); ok {
+			result.Buckets = append(result.Buckets, bucketResult{
+				Name:         dir.Remote(),
+				CreationDate: formatTime(dir.ModTime(req.ctx)),
+			})
+		}
+	}
+	writeXML(req.w, http.StatusOK, result)
+	return nil
+}
+
+// headBucket checks the bucket exists
+func (s *Server) headBucket(req *request) error {
+	err := s.bucketExists(req.ctx, req.bucket)
+	if err != nil {
+		return err
+	}
+	req.w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// locationConstraint is the response to GetBucketLocation
+type locationConstraint struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+	Location string   `xml:",chardata"`
+}
+
+// getBucketLocation returns the default location for the bucket
+func (s *Server) getBucketLocation(req *request) error {
+	err := s.bucketExists(req.ctx, req.bucket)
+	if err != nil {
+		return err
+	}
+	writeXML(req.w, http.StatusOK, locationConstraint{})
+	return nil
+}
+
+// createBucket makes the directory for the bucket. It succeeds if the
+// bucket exists already.
+func (s *Server) createBucket(req *request) error {
+	err := s.f.Mkdir(req.ctx, req.bucket)
+	if err != nil {
+		return err
+	}
+	req.w.Header().Set("Location", "/"+req.bucket)
+	req.w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// deleteBucket removes the directory for the bucket if it is empty
+func (s *Server) deleteBucket(req *request) error {
+	err := s.bucketExists(req.ctx, req.bucket)
+	if err != nil {
+		return err
+	}
+	err = s.f.Rmdir(req.ctx, req.bucket)
+	if err != nil {
+		fs.Debugf(req.bucket, "Failed to remove bucket: %v", err)
+		return errBucketNotEmpty
+	}
+	req.w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// readXML reads the XML request body into v
+func readXML(req *request, v interface{}) error {
+	in, _, err := s3Body(req)
+	if err != nil {
+		return err
+	}
+	buf, err := readAllLimit(in, maxXMLSize)
+	if err != nil {
+		return err
+	}
+	err = xml.Unmarshal(buf, v)
+	if err != nil {
+		fs.Debugf(req.r.URL.Path, "Failed to parse XML: %v", err)
+		return errMalformedXML
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package s3
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/cmd/serve/httplib"
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testAccessKey = "access"
+	testSecretKey = "secret"
+)
+
+// testServer is an S3 server on a local directory
+type testServer struct {
+	t   *testing.T
+	s   *Server
+	dir string
+}
+
+// newTestServer makes an S3 server on a temporary directory with one
+// set of keys
+func newTestServer(t *testing.T) *testServer {
+	dir := t.TempDir()
+	f, err := fs.NewFs(context.Background(), dir)
+	require.NoError(t, err)
+	s := NewServer(f, &httplib.DefaultOpt, map[string]string{testAccessKey: testSecretKey})
+	t.Cleanup(s.uploads.abortAll)
+	return &testServer{t: t, s: s, dir: dir}
+}
+
+// do makes a signed request returning the response
+func (ts *testServer) do(method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "http://localhost:8080"+target, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	signRequest(r, hexSHA256([]byte(body)), testAccessKey, testSecretKey, time.Now())
+	w := httptest.NewRecorder()
+	ts.s.ServeHTTP(w, r)
+	return w
+}
+
+// check makes a request checking the status of the response
+func (ts *testServer) check(wantStatus int, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	w := ts.do(method, target, body, headers...)
+	require.Equal(ts.t, wantStatus, w.Code, "%s %s: %s", method, target, w.Body.String())
+	return w
+}
+
+// checkError makes a request checking it fails with code
+func (ts *testServer) checkError(wantStatus int, wantCode string, method, target, body string, headers ...string) {
+	w := ts.check(wantStatus, method, target, body, headers...)
+	var resp errorResponse
+	require.NoError(ts.t, xml.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(ts.t, wantCode, resp.Code, "%s %s", method, target)
+}
+
+// list lists the bucket returning the keys and common prefixes
+func (ts *testServer) list(query string) (result listBucketResult, keys, prefixes []string) {
+	w := ts.check(http.StatusOK, "GET", "/bucket?"+query, "")
+	require.NoError(ts.t, xml.Unmarshal(w.Body.Bytes(), &result))
+	for _, object := range result.Contents {
+		keys = append(keys, object.Key)
+	}
+	for _, prefix := range result.CommonPrefixes {
+		prefixes = append(prefixes, prefix.Prefix)
+	}
+	return result, keys, prefixes
+}
+
+func TestServerAuth(t *testing.T) {
+	ts := newTestServer(t)
+
+	r := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+	w := httptest.NewRecorder()
+	ts.s.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>AccessDenied</Code>")
+
+	r = httptest.NewRequest("GET", "http://localhost:8080/", nil)
+	signRequest(r, hexSHA256(nil), testAccessKey, "potato", time.Now())
+	w = httptest.NewRecorder()
+	ts.s.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>SignatureDoesNotMatch</Code>")
+
+	// Body doesn't match the signed SHA-256
+	ts.check(http.StatusOK, "PUT", "/bucket", "")
+	r = httptest.NewRequest("PUT", "http://localhost:8080/bucket/file", strings.NewReader("potato"))
+	signRequest(r, hexSHA256([]byte("sausage")), testAccessKey, testSecretKey, time.Now())
+	w = httptest.NewRecorder()
+	ts.s.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	ts.checkError(http.StatusNotFound, "NoSuchKey", "GET", "/bucket/file", "")
+}
+
+func TestServerBuckets(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.checkError(http.StatusNotFound, "NoSuchBucket", "HEAD", "/bucket", "")
+	ts.check(http.StatusOK, "PUT", "/bucket", "")
+	ts.check(http.StatusOK, "PUT", "/bucket", "")
+	ts.check(http.StatusOK, "HEAD", "/bucket", "")
+	require.NoError(t, os.Mkdir(filepath.Join(ts.dir, "other"), 0777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(ts.dir, "notabucket"), []byte("x"), 0666))
+
+	w := ts.check(http.StatusOK, "GET", "/", "")
+	var result listAllMyBucketsResult
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &result))
+	require.Equal(t, 2, len(result.Buckets))
+	assert.Equal(t, "bucket", result.Buckets[0].Name)
+	assert.Equal(t, "other", result.Buckets[1].Name)
+
+	ts.check(http.StatusOK, "PUT", "/bucket/file", "contents")
+	ts.checkError(http.StatusConflict, "BucketNotEmpty", "DELETE", "/bucket", "")
+	ts.check(http.StatusNoContent, "DELETE", "/bucket/file", "")
+	ts.check(http.StatusNoContent, "DELETE", "/bucket", "")
+	ts.checkError(http.StatusNotFound, "NoSuchBucket", "DELETE", "/bucket", "")
+	ts.checkError(http.StatusNotFound, "NoSuchBucket", "GET", "/bucket", "")
+	ts.checkError(http.StatusNotFound, "NoSuchBucket", "PUT", "/bucket/file", "contents")
+}
+
+func TestServerObjects(t *testing.T) {
+	ts := newTestServer(t)
+	ts.check(http.StatusOK, "PUT", "/bucket", "")
+
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 7000, time.UTC)
+	w := ts.check(http.StatusOK, "PUT", "/bucket/dir/file.txt", "hello world", metaMtime, formatMtime(mtime))
+	assert.Equal(t, `"5eb63bbbe01eeed093cb22bb8f5acdc3"`, w.Header().Get("ETag"))
+	fi, err := os.Stat(filepath.Join(ts.dir, "bucket", "dir", "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), fi.Size())
+	assert.True(t, mtime.Equal(fi.ModTime()), fi.ModTime())
+
+	w = ts.check(http.StatusOK, "GET", "/bucket/dir/file.txt", "")
+	assert.Equal(t, "hello world", w.Body.String())
+	assert.Equal(t, formatMtime(mtime), w.Header().Get(metaMtime))
+	assert.NotEqual(t, "", w.Header().Get("ETag"))
+
+	w = ts.check(http.StatusPartialContent, "GET", "/bucket/dir/file.txt", "", "Range", "bytes=6-")
+	assert.Equal(t, "world", w.Body.String())
+
+	w = ts.check(http.StatusOK, "HEAD", "/bucket/dir/file.txt", "")
+	assert.Equal(t, "11", w.Header().Get("Content-Length"))
+
+	ts.checkError(http.StatusNotFound, "NoSuchKey", "GET", "/bucket/dir/potato", "")
+	ts.checkError(http.StatusNotFound, "NoSuchKey", "GET", "/bucket/dir", "")
+	ts.checkError(http.StatusBadRequest, "InvalidArgument", "GET", "/bucket/dir/../../secret", "")
+	ts.checkError(http.StatusBadRequest, "BadDigest", "PUT", "/bucket/bad", "hello", "Content-MD5", "XrY7u+Ae7tCTyyK7j1rNww==")
+
+	// Copy
+	w = ts.check(http.StatusOK, "PUT", "/bucket/copy.txt", "", "X-Amz-Copy-Source", "/bucket/dir/file.txt")
+	assert.Contains(t, w.Body.String(), "<CopyObjectResult")
+	w = ts.check(http.StatusOK, "GET", "/bucket/copy.txt", "")
+	assert.Equal(t, "hello world", w.Body.String())
+	ts.checkError(http.StatusNotFound, "NoSuchKey", "PUT", "/bucket/copy2.txt", "", "X-Amz-Copy-Source", "bucket/potato")
+
+	// Directory
+	ts.check(http.StatusOK, "PUT", "/bucket/empty/", "")
+	fi, err = os.Stat(filepath.Join(ts.dir, "bucket", "empty"))
+	require.NoError(t, err)
+	assert.True(t, fi.IsDir())
+
+	// Delete
+	ts.check(http.StatusNoContent, "DELETE", "/bucket/copy.txt", "")
+	ts.check(http.StatusNoContent, "DELETE", "/bucket/copy.txt", "")
+	ts.checkError(http.StatusNotFound, "NoSuchKey", "GET", "/bucket/copy.txt", "")
+	ts.check(http.StatusNoContent, "DELETE", "/bucket/empty/", "")
+	_, err = os.Stat(filepath.Join(ts.dir, "bucket", "empty"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Delete several
+	ts.check(http.StatusOK, "PUT", "/bucket/a", "a")
+	ts.check(http.StatusOK, "PUT", "/bucket/b", "b")
+	w = ts.check(http.StatusOK, "POST", "/bucket?delete", `<Delete><Object><Key>a</Key></Object><Object><Key>b</Key></Object><Object><Key>../x</Key></Object></Delete>`)
+	var result deleteResult
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, []deletedResult{{Key: "a"}, {Key: "b"}}, result.Deleted)
+	require.Equal(t, 1, len(result.Errors))
+	assert.Equal(t, "../x", result.Errors[0].Key)
+	ts.checkError(http.StatusNotFound, "NoSuchKey", "GET", "/bucket/a", "")
+}
+
+func TestServerList(t *testing.T) {
+	ts := newTestServer(t)
+	ts.check(http.StatusOK, "PUT", "/bucket", "")
+	for _, key := range []string{"a", "b/c", "b/d/e", "b/f", "g/h", "gh"} {
+		ts.check(http.StatusOK, "PUT", "/bucket/"+key, key)
+	}
+
+	_, keys, prefixes := ts.list("list-type=2")
+	assert.Equal(t, []string{"a", "b/c", "b/d/e", "b/f", "g/h", "gh"}, keys)
+	assert.Nil(t, prefixes)
+
+	_, keys, prefixes = ts.list("list-type=2&delimiter=/")
+	assert.Equal(t, []string{"a", "gh"}, keys)
+	assert.Equal(t, []string{"b/", "g/"}, prefixes)
+
+	_, keys, prefixes = ts.list("list-type=2&delimiter=/&prefix=b/")
+	assert.Equal(t, []string{"b/c", "b/f"}, keys)
+	assert.Equal(t, []string{"b/d/"}, prefixes)
+
+	_, keys, prefixes = ts.list("list-type=2&prefix=g")
+	assert.Equal(t, []string{"g/h", "gh"}, keys)
+	assert.Nil(t, prefixes)
+
+	_, keys, prefixes = ts.list("delimiter=d&prefix=b/")
+	assert.Equal(t, []string{"b/c", "b/f"}, keys)
+	assert.Equal(t, []string{"b/d"}, prefixes)
+
+	_, keys, _ = ts.list("list-type=2&prefix=potato/")
+	assert.Nil(t, keys)
+
+	// Pages with ListObjectsV2
+	var all []string
+	token := ""
+	for i := 0; ; i++ {
+		require.Less(t, i, 10)
+		result, keys, _ := ts.list("list-type=2&max-keys=4&continuation-token=" + token)
+		all = append(all, keys...)
+		require.Equal(t, len(keys), *result.KeyCount)
+		if !result.IsTruncated {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	assert.Equal(t, []string{"a", "b/c", "b/d/e", "b/f", "g/h", "gh"}, all)
+
+	// Pages with ListObjects
+	result, keys, _ := ts.list("max-keys=2&marker=b/c")
+	assert.Equal(t, []string{"b/d/e", "b/f"}, keys)
+	assert.True(t, result.IsTruncated)
+	assert.Equal(t, "b/f", result.NextMarker)
+
+	_, keys, _ = ts.list("list-type=2&start-after=b/f")
+	assert.Equal(t, []string{"g/h", "gh"}, keys)
+}
+
+func TestServerMultipart(t *testing.T) {
+	ts := newTestServer(t)
+	ts.check(http.StatusOK, "PUT", "/bucket", "")
+
+	start := func() string {
+		w := ts.check(http.StatusOK, "POST", "/bucket/big?uploads", "")
+		var result initiateMultipartUploadResult
+		require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &result))
+		require.NotEqual(t, "", result.UploadID)
+		return result.UploadID
+	}
+	upload := func(id string, n int, data string) string {
+		w := ts.check(http.StatusOK, "PUT", fmt.Sprintf("/bucket/big?partNumber=%d&uploadId=%s", n, id), data)
+		return w.Header().Get("ETag")
+	}
+	complete := func(id string, etags ...string) *httptest.ResponseRecorder {
+		var body strings.Builder
+		body.WriteString("<CompleteMultipartUpload>")
+		for i, etag := range etags {
+			fmt.Fprintf(&body, "<Part><PartNumber>%d</PartNumber><ETag>%s</ETag></Part>", i+1, etag)
+		}
+		body.WriteString("</CompleteMultipartUpload>")
+		return ts.do("POST", "/bucket/big?uploadId="+id, body.String())
+	}
+
+	id := start()
+	etag2 := upload(id, 2, "two")
+	etag1 := upload(id, 1, "potato")
+	etag1 = upload(id, 1, "one-") // replaces the first upload of part 1
+	w := complete(id, etag1, `"potato"`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "InvalidPart")
+	w = complete(id, etag1, etag2)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result completeMultipartUploadResult
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &result))
+	assert.True(t, strings.HasSuffix(result.ETag, `-2"`), result.ETag)
+	w = ts.check(http.StatusOK, "GET", "/bucket/big", "")
+	assert.Equal(t, "one-two", w.Body.String())
+	assert.Equal(t, 0, len(ts.s.uploads.uploads))
+
+	// Completed uploads can't be used again
+	ts.checkError(http.StatusNotFound, "NoSuchUpload", "PUT", "/bucket/big?partNumber=1&uploadId="+id, "x")
+
+	// Abort removes the parts
+	id = start()
+	upload(id, 1, "one")
+	up := ts.s.uploads.uploads[id]
+	require.NotNil(t, up)
+	ts.check(http.StatusNoContent, "DELETE", "/bucket/big?uploadId="+id, "")
+	_, err := os.Stat(up.dir)
+	assert.True(t, os.IsNotExist(err))
+	ts.checkError(http.StatusNotFound, "NoSuchUpload", "POST", "/bucket/big?uploadId="+id, "<CompleteMultipartUpload/>")
+	ts.checkError(http.StatusNotFound, "NoSuchUpload", "PUT", "/bucket/other?partNumber=1&uploadId="+id, "x")
+}
+
+func TestParseMtime(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want time.Time
+	}{
+		{"1234567890", time.Unix(1234567890, 0)},
+		{"1234567890.5", time.Unix(1234567890, 500000000)},
+		{"1234567890.000000001", time.Unix(1234567890, 1)},
+		{"1234567890.0000000019", time.Unix(1234567890, 1)},
+	} {
+		got, err := parseMtime(test.in)
+		require.NoError(t, err, test.in)
+		assert.True(t, test.want.Equal(got), test.in)
+	}
+	_, err := parseMtime("potato")
+	assert.Error(t, err)
+	now := time.Now()
+	got, err := parseMtime(formatMtime(now))
+	require.NoError(t, err)
+	assert.True(t, now.Equal(got))
+}
+
+func TestValidKey(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want bool
+	}{
+		{"file", true},
+		{"dir/file", true},
+		{"dir/", true},
+		{"dir//file", false},
+		{"/file", false},
+		{"./file", false},
+		{"dir/../file", false},
+		{"..", false},
+	} {
+		assert.Equal(t, test.want, validKey(test.in), test.in)
+	}
+}
@@ -8,8 +8,12 @@ import (
 	"github.com/rclone/rclone/cmd/serve/docker"
 	"github.com/rclone/rclone/cmd/serve/ftp"
 	"github.com/rclone/rclone/cmd/serve/http"
+	"github.com/rclone/rclone/cmd/serve/nfs"
 	"github.com/rclone/rclone/cmd/serve/restic"
+	"github.com/rclone/rclone/cmd/serve/rsyncd"
+	"github.com/rclone/rclone/cmd/serve/s3"
 	"github.com/rclone/rclone/cmd/serve/sftp"
+	"github.com/rclone/rclone/cmd/serve/smb"
 	"github.com/rclone/rclone/cmd/serve/webdav"
 	"github.com/spf13/cobra"
 )
@@ -22,6 +26,9 @@ func init() {
 	if restic.Command != nil {
 		Command.AddCommand(restic.Command)
 	}
+	if s3.Command != nil {
+		Command.AddCommand(s3.Command)
+	}
 	if dlna.Command != nil {
 		Command.AddCommand(dlna.Command)
 	}
@@ -31,6 +38,15 @@ func init() {
 	if sftp.Command != nil {
 		Command.AddCommand(sftp.Command)
 	}
+	if nfs.Command != nil {
+		Command.AddCommand(nfs.Command)
+	}
+	if smb.Command != nil {
+		Command.AddCommand(smb.Command)
+	}
+	if rsyncd.Command != nil {
+		Command.AddCommand(rsyncd.Command)
+	}
 	if docker.Command != nil {
 		Command.AddCommand(docker.Command)
 	}
@@ -0,0 +1,262 @@
+// Package servelib lets the servers be started and stopped with the
+// remote control
+package servelib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// Server is a server started by serve/start
+type Server interface {
+	// Addr returns the address the server is listening on
+	Addr() string
+	// Shutdown stops the server
+	Shutdown() error
+}
+
+// StartFn starts a server serving f with the options in the "opt"
+// and "vfsOpt" parameters of in. The server must be serving when it
+// returns.
+//
+// ctx isn't cancelled when the rc call returns so the server can
+// keep it.
+type StartFn func(ctx context.Context, f fs.Fs, in rc.Params) (Server, error)
+
+var (
+	// mutex to protect all the variables in this block
+	serveMu sync.Mutex
+	// Start functions available indexed by type
+	startFns = map[string]StartFn{}
+	// Map of ID => running server
+	liveServers = map[string]*liveServer{}
+	// Number of servers started so far, used to make the IDs
+	serversStarted = 0
+)
+
+// liveServer is a server started by serve/start
+type liveServer struct {
+	ID        string
+	Type      string
+	Fs        fs.Fs
+	Server    Server
+	StartedOn time.Time
+}
+
+// AddRc adds serveType to the servers which can be started with
+// serve/start
+func AddRc(serveType string, startFn StartFn) {
+	serveMu.Lock()
+	defer serveMu.Unlock()
+	startFns[serveType] = startFn
+}
+
+// types returns the sorted types of server which can be started
+//
+// Call with the mutex held.
+func types() []string {
+	serveTypes := make([]string, 0, len(startFns))
+	for serveType := range startFns {
+		serveTypes = append(serveTypes, serveType)
+	}
+	sort.Strings(serveTypes)
+	return serveTypes
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "serve/start",
+		AuthRequired: true,
+		Fn:           startRc,
+		Title:        "Start a server serving a remote",
+		Help: `This starts a server serving a remote in the same way as
+rclone serve, which keeps running until it is stopped with serve/stop.
+
+This takes the following parameters:
+
+- type - the type of server, e.g. http, webdav, sftp or ftp (required)
+- fs - the remote path to serve (required)
+- opt - a JSON object with the options of the server in
+- vfsOpt - a JSON object with VFS options in
+
+and returns
+
+- id - the ID of the server to use with serve/stop
+- addr - the address the server is listening on
+
+The options default to the flags given to rclone. Use "ListenAddr" in
+opt to set the address to listen on, which can be ":0" to let the OS
+choose a port - the port chosen is returned in addr. The options for
+each type of server are
+
+- http - server options such as ListenAddr, SslCert and SslKey, with
+  the authentication options such as BasicUser, BasicPass and HtPasswd
+  in a separate authOpt parameter
+- webdav - the "http" section of options/get
+- sftp - the "sftp" section of options/get
+- ftp - the "ftp" section of options/get
+
+Example:
+
+    rclone rc serve/start type=webdav fs=mydrive: opt='{"ListenAddr": ":8080", "BasicUser": "user", "BasicPass": "pass"}'
+    rclone rc serve/start type=sftp fs=/home/user opt='{"ListenAddr": "localhost:0"}' vfsOpt='{"CacheMode": 2}'
+
+Servers started like this use the access log, metrics, auth proxy and
+server specific flags given to rclone.
+`,
+	})
+}
+
+// startRc starts a server from the rc
+func startRc(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	serveType, err := in.GetString("type")
+	if err != nil {
+		return nil, err
+	}
+
+	serveMu.Lock()
+	startFn, serveTypes := startFns[serveType], types()
+	serveMu.Unlock()
+	if startFn == nil {
+		return nil, rc.NewErrParamInvalid(fmt.Errorf("can't start server of type %q - must be one of %s", serveType, strings.Join(serveTypes, ", ")))
+	}
+
+	f, err := rc.GetFs(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	// The server outlives the rc call so don't give it the context
+	// of the call, only its config
+	s, err := startFn(fs.CopyConfig(context.Background(), ctx), f, in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s server: %w", serveType, err)
+	}
+
+	serveMu.Lock()
+	defer serveMu.Unlock()
+	serversStarted++
+	id := fmt.Sprintf("%s-%d", serveType, serversStarted)
+	liveServers[id] = &liveServer{
+		ID:        id,
+		Type:      serveType,
+		Fs:        f,
+		Server:    s,
+		StartedOn: time.Now(),
+	}
+
+	fs.Logf(f, "Started %s server %s on %s", serveType, id, s.Addr())
+	return rc.Params{
+		"id":   id,
+		"addr": s.Addr(),
+	}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "serve/stop",
+		AuthRequired: true,
+		Fn:           stopRc,
+		Title:        "Stop a server started with serve/start",
+		Help: `This stops a server started with serve/start.
+
+This takes the following parameters:
+
+- id - the ID of the server returned by serve/start (required)
+
+Example:
+
+    rclone rc serve/stop id=webdav-1
+`,
+	})
+}
+
+// stopRc stops a server started from the rc
+func stopRc(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	id, err := in.GetString("id")
+	if err != nil {
+		return nil, err
+	}
+	serveMu.Lock()
+	defer serveMu.Unlock()
+	s, found := liveServers[id]
+	if !found || !rc.TenantOwns(rc.GetTenant(ctx), s.Fs.Name()) {
+		return nil, errors.New("server not found")
+	}
+	delete(liveServers, id)
+	err = s.Server.Shutdown()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stop server %s: %w", id, err)
+	}
+	fs.Logf(s.Fs, "Stopped %s server %s", s.Type, id)
+	return nil, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "serve/list",
+		AuthRequired: true,
+		Fn:           listRc,
+		Title:        "Show the servers started with serve/start",
+		Help: `This shows the running servers started with serve/start.
+
+This takes no parameters and returns
+
+- list: list of running servers, each with
+    - id - the ID to stop the server with
+    - type - the type of server
+    - fs - the remote being served
+    - addr - the address the server is listening on
+    - startedOn - when the server was started
+- types: list of the types of server which can be started
+
+Example:
+
+    rclone rc serve/list
+`,
+	})
+}
+
+// ServerInfo describes a running server for serve/list
+type ServerInfo struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	Fs        string    `json:"fs"`
+	Addr      string    `json:"addr"`
+	StartedOn time.Time `json:"startedOn"`
+}
+
+// listRc returns the running servers in the order they were started
+func listRc(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	tenant := rc.GetTenant(ctx)
+	serveMu.Lock()
+	defer serveMu.Unlock()
+	list := []ServerInfo{}
+	for _, s := range liveServers {
+		if !rc.TenantOwns(tenant, s.Fs.Name()) {
+			continue
+		}
+		list = append(list, ServerInfo{
+			ID:        s.ID,
+			Type:      s.Type,
+			Fs:        fs.ConfigString(s.Fs),
+			Addr:      s.Server.Addr(),
+			StartedOn: s.StartedOn,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].StartedOn.Before(list[j].StartedOn) || (list[i].StartedOn.Equal(list[j].StartedOn) && list[i].ID < list[j].ID)
+	})
+	return rc.Params{
+		"list":  list,
+		"types": types(),
+	}, nil
+}
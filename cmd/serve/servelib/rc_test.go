@@ -0,0 +1,119 @@
+package servelib
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer is a Server which records whether it is running
+type testServer struct {
+	addr    string
+	running bool
+}
+
+func (s *testServer) Addr() string { return s.addr }
+
+func (s *testServer) Shutdown() error {
+	if !s.running {
+		return errors.New("not running")
+	}
+	s.running = false
+	return nil
+}
+
+func TestRc(t *testing.T) {
+	ctx := context.Background()
+	var started []*testServer
+	AddRc("test", func(ctx context.Context, f fs.Fs, in rc.Params) (Server, error) {
+		var opt struct{ ListenAddr string }
+		err := in.GetStructMissingOK("opt", &opt)
+		if err != nil {
+			return nil, err
+		}
+		if opt.ListenAddr == "bad" {
+			return nil, errors.New("bad address")
+		}
+		s := &testServer{addr: opt.ListenAddr, running: true}
+		started = append(started, s)
+		return s, nil
+	})
+	defer func() {
+		serveMu.Lock()
+		delete(startFns, "test")
+		serveMu.Unlock()
+	}()
+	start := rc.Calls.Get("serve/start")
+	require.NotNil(t, start)
+	stop := rc.Calls.Get("serve/stop")
+	require.NotNil(t, stop)
+	list := rc.Calls.Get("serve/list")
+	require.NotNil(t, list)
+	dir := t.TempDir()
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := start.Fn(ctx, rc.Params{"fs": dir})
+		assert.Error(t, err)
+		_, err = start.Fn(ctx, rc.Params{"type": "potato", "fs": dir})
+		assert.True(t, rc.IsErrParamInvalid(err), err)
+		_, err = start.Fn(ctx, rc.Params{"type": "test"})
+		assert.Error(t, err)
+		_, err = start.Fn(ctx, rc.Params{"type": "test", "fs": dir, "opt": rc.Params{"ListenAddr": "bad"}})
+		assert.EqualError(t, err, "failed to start test server: bad address")
+		_, err = stop.Fn(ctx, rc.Params{"id": "test-999"})
+		assert.EqualError(t, err, "server not found")
+		_, err = stop.Fn(ctx, rc.Params{})
+		assert.Error(t, err)
+	})
+
+	out, err := start.Fn(ctx, rc.Params{"type": "test", "fs": dir, "opt": rc.Params{"ListenAddr": "localhost:1234"}})
+	require.NoError(t, err)
+	id1, err := out.GetString("id")
+	require.NoError(t, err)
+	addr, err := out.GetString("addr")
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:1234", addr)
+
+	out, err = start.Fn(ctx, rc.Params{"type": "test", "fs": dir, "opt": `{"ListenAddr": "localhost:5678"}`})
+	require.NoError(t, err)
+	id2, err := out.GetString("id")
+	require.NoError(t, err)
+	assert.NotEqual(t, id1, id2)
+	require.Len(t, started, 2)
+
+	out, err = list.Fn(ctx, nil)
+	require.NoError(t, err)
+	var servers []ServerInfo
+	require.NoError(t, out.GetStruct("list", &servers))
+	require.Len(t, servers, 2)
+	assert.Equal(t, id1, servers[0].ID)
+	assert.Equal(t, "test", servers[0].Type)
+	assert.Equal(t, "localhost:1234", servers[0].Addr)
+	assert.Equal(t, id2, servers[1].ID)
+	var serveTypes []string
+	require.NoError(t, out.GetStruct("types", &serveTypes))
+	assert.Contains(t, serveTypes, "test")
+
+	_, err = stop.Fn(ctx, rc.Params{"id": id1})
+	require.NoError(t, err)
+	assert.False(t, started[0].running)
+	assert.True(t, started[1].running)
+	_, err = stop.Fn(ctx, rc.Params{"id": id1})
+	assert.EqualError(t, err, "server not found")
+
+	out, err = list.Fn(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, out.GetStruct("list", &servers))
+	require.Len(t, servers, 1)
+	assert.Equal(t, id2, servers[0].ID)
+
+	_, err = stop.Fn(ctx, rc.Params{"id": id2})
+	require.NoError(t, err)
+	assert.False(t, started[1].running)
+}
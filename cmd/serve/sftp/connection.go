@@ -14,6 +14,7 @@ import (
 	"strings"
 
 	"github.com/pkg/sftp"
+	"github.com/rclone/rclone/cmd/serve/accesslog"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/lib/terminal"
@@ -274,7 +275,7 @@ func serveChannel(rwc io.ReadWriteCloser, h sftp.Handlers, what string) error {
 	return nil
 }
 
-func serveStdio(f fs.Fs) error {
+func serveStdio(f fs.Fs) (err error) {
 	if terminal.IsTerminal(int(os.Stdout.Fd())) {
 		return errors.New("refusing to run SFTP server directly on a terminal. Please let sshd start rclone, by connecting with sftp or sshfs")
 	}
@@ -282,7 +283,15 @@ func serveStdio(f fs.Fs) error {
 		stdin:  os.Stdin,
 		stdout: os.Stdout,
 	}
-	handlers := newVFSHandler(vfs.New(f, &vfsflags.Opt))
+	if accesslog.Opt.AccessLog == "-" {
+		return errors.New("can't write the access log to stdout with --stdio")
+	}
+	accessLog, err := accesslog.New("sftp", &accesslog.Opt)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(accessLog, &err)
+	handlers := newVFSHandler(vfs.New(f, &vfsflags.Opt), accessLog, "", "")
 	return serveChannel(sshChannel, handlers, "stdio")
 }
 
@@ -6,10 +6,12 @@ package sftp
 import (
 	"io"
 	"os"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/pkg/sftp"
+	"github.com/rclone/rclone/cmd/serve/accesslog"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/vfs"
 )
@@ -17,11 +19,21 @@ import (
 // vfsHandler converts the VFS to be served by SFTP
 type vfsHandler struct {
 	*vfs.VFS
+	log    *accesslog.Logger // access log, may be nil
+	remote string            // address of the client
+	user   string            // user the client logged in as
 }
 
 // vfsHandler returns a Handlers object with the test handlers.
-func newVFSHandler(vfs *vfs.VFS) sftp.Handlers {
-	v := vfsHandler{VFS: vfs}
+//
+// The requests are logged to log as coming from user at remote.
+func newVFSHandler(vfs *vfs.VFS, log *accesslog.Logger, remote, user string) sftp.Handlers {
+	v := vfsHandler{
+		VFS:    vfs,
+		log:    log,
+		remote: remote,
+		user:   user,
+	}
 	return sftp.Handlers{
 		FileGet:  v,
 		FilePut:  v,
@@ -30,23 +42,82 @@ func newVFSHandler(vfs *vfs.VFS) sftp.Handlers {
 	}
 }
 
+// logRequest starts logging r to the access log
+func (v vfsHandler) logRequest(r *sftp.Request) func(sent, received int64, err error) {
+	return v.log.Rename(v.remote, v.user, r.Method, r.Filepath, r.Target, "SFTP")
+}
+
 func (v vfsHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	done := v.logRequest(r)
 	file, err := v.OpenFile(r.Filepath, os.O_RDONLY, 0777)
 	if err != nil {
+		done(0, 0, err)
 		return nil, err
 	}
-	return file, nil
+	return &loggedFile{Handle: file, done: done}, nil
 }
 
 func (v vfsHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	done := v.logRequest(r)
 	file, err := v.OpenFile(r.Filepath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
 	if err != nil {
+		done(0, 0, err)
 		return nil, err
 	}
-	return file, nil
+	return &loggedFile{Handle: file, done: done}, nil
+}
+
+// loggedFile counts the bytes read and written to a file and logs
+// them to the access log when it is closed
+type loggedFile struct {
+	vfs.Handle
+	done     func(sent, received int64, err error)
+	mu       sync.Mutex
+	sent     int64
+	received int64
+	err      error // first error which isn't io.EOF
+}
+
+// count records n bytes and err from a read or write
+func (f *loggedFile) count(bytes *int64, n int, err error) {
+	f.mu.Lock()
+	*bytes += int64(n)
+	if err != nil && err != io.EOF && f.err == nil {
+		f.err = err
+	}
+	f.mu.Unlock()
+}
+
+// ReadAt counts the bytes read
+func (f *loggedFile) ReadAt(p []byte, off int64) (n int, err error) {
+	n, err = f.Handle.ReadAt(p, off)
+	f.count(&f.sent, n, err)
+	return n, err
+}
+
+// WriteAt counts the bytes written
+func (f *loggedFile) WriteAt(p []byte, off int64) (n int, err error) {
+	n, err = f.Handle.WriteAt(p, off)
+	f.count(&f.received, n, err)
+	return n, err
+}
+
+// Close closes the file and logs the transfer
+func (f *loggedFile) Close() error {
+	err := f.Handle.Close()
+	f.mu.Lock()
+	logErr := f.err
+	f.mu.Unlock()
+	if logErr == nil {
+		logErr = err
+	}
+	f.done(f.sent, f.received, logErr)
+	return err
 }
 
-func (v vfsHandler) Filecmd(r *sftp.Request) error {
+func (v vfsHandler) Filecmd(r *sftp.Request) (err error) {
+	done := v.logRequest(r)
+	defer func() { done(0, 0, err) }()
 	switch r.Method {
 	case "Setstat":
 		attr := r.Attributes()
@@ -107,6 +178,8 @@ func (v vfsHandler) Filelist(r *sftp.Request) (l sftp.ListerAt, err error) {
 	var handle vfs.Handle
 	switch r.Method {
 	case "List":
+		done := v.logRequest(r)
+		defer func() { done(0, 0, err) }()
 		node, err = v.Stat(r.Filepath)
 		if err != nil {
 			return nil, err
@@ -0,0 +1,53 @@
+//go:build !plan9
+// +build !plan9
+
+package sftp
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rclone/rclone/cmd/serve/servelib"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/vfs/vfsflags"
+)
+
+func init() {
+	servelib.AddRc("sftp", startRc)
+}
+
+// startRc starts an SFTP server for serve/start
+func startRc(ctx context.Context, f fs.Fs, in rc.Params) (servelib.Server, error) {
+	opt := Opt
+	err := in.GetStructMissingOK("opt", &opt)
+	if err != nil {
+		return nil, err
+	}
+	if opt.Stdio {
+		return nil, errors.New("can't serve on stdin/stdout from the rc")
+	}
+	vfsOpt := vfsflags.Opt
+	err = in.GetStructMissingOK("vfsOpt", &vfsOpt)
+	if err != nil {
+		return nil, err
+	}
+	s, err := newServer(ctx, f, &opt, &vfsOpt)
+	if err != nil {
+		return nil, err
+	}
+	err = s.Serve()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Shutdown stops the server started by serve/start
+func (s *server) Shutdown() error {
+	s.Close()
+	if s.vfs != nil {
+		s.vfs.Shutdown()
+	}
+	return s.log.Close()
+}
@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/rclone/rclone/cmd/serve/accesslog"
 	"github.com/rclone/rclone/cmd/serve/proxy"
 	"github.com/rclone/rclone/cmd/serve/proxy/proxyflags"
 	"github.com/rclone/rclone/fs"
@@ -30,7 +31,7 @@ import (
 	"github.com/rclone/rclone/lib/env"
 	"github.com/rclone/rclone/lib/file"
 	"github.com/rclone/rclone/vfs"
-	"github.com/rclone/rclone/vfs/vfsflags"
+	"github.com/rclone/rclone/vfs/vfscommon"
 	"golang.org/x/crypto/ssh"
 )
 
@@ -44,21 +45,32 @@ type server struct {
 	listener net.Listener
 	waitChan chan struct{} // for waiting on the listener to close
 	proxy    *proxy.Proxy
+	log      *accesslog.Logger
 }
 
-func newServer(ctx context.Context, f fs.Fs, opt *Options) *server {
+func newServer(ctx context.Context, f fs.Fs, opt *Options, vfsOpt *vfscommon.Options) (*server, error) {
 	s := &server{
 		f:        f,
 		ctx:      ctx,
 		opt:      *opt,
 		waitChan: make(chan struct{}),
 	}
-	if proxyflags.Opt.AuthProxy != "" {
+	var err error
+	s.log, err = accesslog.New("sftp", &accesslog.Opt)
+	if err != nil {
+		return nil, err
+	}
+	if proxyflags.Opt.UsersFile != "" {
+		s.proxy, err = proxy.NewUsers(ctx, &proxyflags.Opt, f)
+		if err != nil {
+			return nil, err
+		}
+	} else if proxyflags.Opt.AuthProxy != "" {
 		s.proxy = proxy.New(ctx, &proxyflags.Opt)
 	} else {
-		s.vfs = vfs.New(f, &vfsflags.Opt)
+		s.vfs = vfs.New(f, vfsOpt)
 	}
-	return s
+	return s, nil
 }
 
 // getVFS gets the vfs from s or the proxy
@@ -109,7 +121,7 @@ func (s *server) acceptConnection(nConn net.Conn) {
 		_ = nConn.Close()
 		return
 	}
-	c.handlers = newVFSHandler(c.vfs)
+	c.handlers = newVFSHandler(c.vfs, s.log, nConn.RemoteAddr().String(), sshConn.User())
 
 	// Accept all channels
 	go c.handleChannels(chans)
@@ -151,7 +163,7 @@ func (s *server) serve() (err error) {
 	}
 
 	if !s.opt.NoAuth && len(authorizedKeysMap) == 0 && s.opt.User == "" && s.opt.Pass == "" && s.proxy == nil {
-		return errors.New("no authorization found, use --user/--pass or --authorized-keys or --no-auth or --auth-proxy")
+		return errors.New("no authorization found, use --user/--pass or --authorized-keys or --no-auth or --auth-proxy or --users-file")
 	}
 
 	// An SSH server is represented by a ServerConfig, which holds
@@ -9,6 +9,8 @@ import (
 	"context"
 
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/serve/accesslog"
+	"github.com/rclone/rclone/cmd/serve/multiremote"
 	"github.com/rclone/rclone/cmd/serve/proxy"
 	"github.com/rclone/rclone/cmd/serve/proxy/proxyflags"
 	"github.com/rclone/rclone/fs"
@@ -55,12 +57,14 @@ func AddFlags(flagSet *pflag.FlagSet, Opt *Options) {
 func init() {
 	vfsflags.AddFlags(Command.Flags())
 	proxyflags.AddFlags(Command.Flags())
+	multiremote.AddFlags(Command.Flags())
+	accesslog.AddFlags(Command.Flags())
 	AddFlags(Command.Flags(), &Opt)
 }
 
 // Command definition for cobra
 var Command = &cobra.Command{
-	Use:   "sftp remote:path",
+	Use:   "sftp remote:path [remote:path...]",
 	Short: `Serve the remote over SFTP.`,
 	Long: `rclone serve sftp implements an SFTP server to serve the remote
 over SFTP.  This can be used with an SFTP client or you can make a
@@ -111,12 +115,11 @@ used. Omitting "restrict" and using --sftp-path-override to enable
 checksumming is possible but less secure and you could use the SFTP server
 provided by OpenSSH in this case.
 
-` + vfs.Help + proxy.Help,
+` + accesslog.Help + multiremote.Help + vfs.Help + proxy.Help + proxy.UsersHelp,
 	Run: func(command *cobra.Command, args []string) {
 		var f fs.Fs
 		if proxyflags.Opt.AuthProxy == "" {
-			cmd.CheckArgs(1, 1, command, args)
-			f = cmd.NewFsSrc(args)
+			f = multiremote.NewFs(command, args)
 		} else {
 			cmd.CheckArgs(0, 0, command, args)
 		}
@@ -124,8 +127,11 @@ provided by OpenSSH in this case.
 			if Opt.Stdio {
 				return serveStdio(f)
 			}
-			s := newServer(context.Background(), f, &Opt)
-			err := s.Serve()
+			s, err := newServer(context.Background(), f, &Opt, &vfsflags.Opt)
+			if err != nil {
+				return err
+			}
+			err = s.Serve()
 			if err != nil {
 				return err
 			}
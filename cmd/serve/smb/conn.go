@@ -0,0 +1,448 @@
+package smb
+
+import (
+	"bufio"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// Transport constants
+const (
+	maxMessageSize = 8 * 1024 * 1024 // largest message we will read
+	maxCredits     = 512             // most credits granted in one response
+
+	// NetBIOS session service message types (RFC 1002)
+	nbSessionMessage   = 0x00
+	nbSessionRequest   = 0x81
+	nbPositiveResponse = 0x82
+	nbKeepAlive        = 0x85
+
+	statusPending       = 0x00000103
+	statusCancelled     = 0xC0000120
+	statusNotifyCleanup = 0x0000010B
+)
+
+// errMessageTooBig is returned if a client sends a message which is
+// larger than we are prepared to read
+var errMessageTooBig = errors.New("SMB message too big")
+
+// handler is the implementation of an SMB2 command. It returns the
+// NTSTATUS and the response body which is ignored unless the status
+// is a success or a warning.
+type handler func(req *request) (status uint32, body []byte)
+
+// command describes how to run an SMB2 command
+type command struct {
+	name        string
+	fn          handler
+	needSession bool // needs an authenticated session
+	needTree    bool // needs a connected tree
+}
+
+// commands is the table of SMB2 commands indexed by number
+var commands []command
+
+func init() {
+	commands = []command{
+		cmdNegotiate:      {name: "NEGOTIATE", fn: (*request).negotiate},
+		cmdSessionSetup:   {name: "SESSION_SETUP", fn: (*request).sessionSetup},
+		cmdLogoff:         {name: "LOGOFF", fn: (*request).logoff, needSession: true},
+		cmdTreeConnect:    {name: "TREE_CONNECT", fn: (*request).treeConnect, needSession: true},
+		cmdTreeDisconnect: {name: "TREE_DISCONNECT", fn: (*request).treeDisconnect, needSession: true, needTree: true},
+		cmdCreate:         {name: "CREATE", fn: (*request).create, needSession: true, needTree: true},
+		cmdClose:          {name: "CLOSE", fn: (*request).close, needSession: true, needTree: true},
+		cmdFlush:          {name: "FLUSH", fn: (*request).flush, needSession: true, needTree: true},
+		cmdRead:           {name: "READ", fn: (*request).read, needSession: true, needTree: true},
+		cmdWrite:          {name: "WRITE", fn: (*request).write, needSession: true, needTree: true},
+		cmdLock:           {name: "LOCK", fn: (*request).lock, needSession: true, needTree: true},
+		cmdIoctl:          {name: "IOCTL", fn: (*request).ioctl, needSession: true},
+		cmdCancel:         {name: "CANCEL"},
+		cmdEcho:           {name: "ECHO", fn: (*request).echo},
+		cmdQueryDirectory: {name: "QUERY_DIRECTORY", fn: (*request).queryDirectory, needSession: true, needTree: true},
+		cmdChangeNotify:   {name: "CHANGE_NOTIFY", fn: (*request).changeNotify, needSession: true, needTree: true},
+		cmdQueryInfo:      {name: "QUERY_INFO", fn: (*request).queryInfo, needSession: true, needTree: true},
+		cmdSetInfo:        {name: "SET_INFO", fn: (*request).setInfo, needSession: true, needTree: true},
+		cmdOplockBreak:    {name: "OPLOCK_BREAK"},
+	}
+}
+
+// conn is a connection from a client
+//
+// Requests on a connection are run one at a time so none of the
+// connection state needs locking.
+type conn struct {
+	s              *server
+	c              net.Conn
+	in             *bufio.Reader
+	dialect        uint16 // negotiated dialect or 0 if not negotiated yet
+	clientGUID     [16]byte
+	clientSecurity uint16
+	clientCaps     uint32
+	preauthHash    [sha512.Size]byte // for dialect 3.1.1
+	sessions       map[uint64]*session
+	files          map[uint64]*file
+	notifies       map[uint64]*notify // pending CHANGE_NOTIFY by async id
+}
+
+// request is a single SMB2 command being run
+type request struct {
+	c      *conn
+	hdr    header
+	msg    []byte // the whole message including the header
+	body   []byte // the message after the header
+	sess   *session
+	tree   *tree
+	fileID uint64 // the file id used by a compounded request
+	async  uint64 // set to the async id if the command went async
+
+	respBody []byte // the response body set by run
+}
+
+func newConn(s *server, c net.Conn) *conn {
+	return &conn{
+		s:        s,
+		c:        c,
+		in:       bufio.NewReader(c),
+		sessions: make(map[uint64]*session),
+		files:    make(map[uint64]*file),
+		notifies: make(map[uint64]*notify),
+	}
+}
+
+// serve reads and runs requests until the connection is closed
+func (c *conn) serve() {
+	for {
+		msg, err := c.readMessage()
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				fs.Debugf(c.c.RemoteAddr(), "SMB connection closed: %v", err)
+			}
+			return
+		}
+		var reply []byte
+		switch {
+		case len(msg) >= 4 && string(msg[:4]) == "\xffSMB":
+			reply, err = c.negotiateSMB1(msg)
+		case len(msg) >= headerSize && string(msg[:4]) == "\xfeSMB":
+			reply, err = c.handle(msg)
+		default:
+			err = errors.New("unsupported protocol")
+		}
+		if err == nil && reply != nil {
+			err = c.writeMessage(reply)
+		}
+		if err != nil {
+			fs.Debugf(c.c.RemoteAddr(), "SMB closing connection: %v", err)
+			return
+		}
+	}
+}
+
+// close releases everything held by the connection
+func (c *conn) close() {
+	_ = c.c.Close()
+	for _, f := range c.files {
+		c.closeFile(f)
+	}
+}
+
+// readMessage reads the next SMB message from the NetBIOS session
+// service framing, answering session requests and skipping keep
+// alives.
+func (c *conn) readMessage() ([]byte, error) {
+	var hdr [4]byte
+	for {
+		_, err := io.ReadFull(c.in, hdr[:])
+		if err != nil {
+			return nil, err
+		}
+		n := int(binary.BigEndian.Uint32(hdr[:]) & 0xFFFFFF)
+		if n > maxMessageSize {
+			return nil, errMessageTooBig
+		}
+		msg := make([]byte, n)
+		_, err = io.ReadFull(c.in, msg)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("failed to read SMB message: %w", err)
+		}
+		switch hdr[0] {
+		case nbSessionMessage:
+			return msg, nil
+		case nbSessionRequest:
+			_, err = c.c.Write([]byte{nbPositiveResponse, 0, 0, 0})
+			if err != nil {
+				return nil, err
+			}
+		case nbKeepAlive:
+		default:
+			return nil, fmt.Errorf("unknown NetBIOS message type 0x%02x", hdr[0])
+		}
+	}
+}
+
+// writeMessage writes msg with the NetBIOS session service framing
+func (c *conn) writeMessage(msg []byte) error {
+	buf := make([]byte, 4, 4+len(msg))
+	binary.BigEndian.PutUint32(buf, uint32(len(msg)))
+	buf = append(buf, msg...)
+	_, err := c.c.Write(buf)
+	return err
+}
+
+// negotiateSMB1 answers an SMB1 NEGOTIATE from a client which can
+// also speak SMB2 by replying with an SMB2 NEGOTIATE response.
+func (c *conn) negotiateSMB1(msg []byte) ([]byte, error) {
+	const smb1HeaderSize = 32
+	if c.dialect != 0 || len(msg) < smb1HeaderSize+3 || msg[4] != 0x72 {
+		return nil, errors.New("SMB1 is not supported")
+	}
+	dialect := uint16(0)
+	for _, d := range splitSMB1Dialects(msg[smb1HeaderSize+3:]) {
+		switch d {
+		case "SMB 2.???":
+			dialect = dialectWildcard
+		case "SMB 2.002":
+			if dialect == 0 {
+				dialect = dialect202
+			}
+		}
+	}
+	if dialect == 0 {
+		return nil, errors.New("client doesn't support SMB2")
+	}
+	req := &request{c: c, hdr: header{command: cmdNegotiate}}
+	body := req.negotiateResponse(dialect, nil)
+	if dialect != dialectWildcard {
+		c.dialect = dialect
+	}
+	resp := make([]byte, headerSize, headerSize+len(body))
+	h := header{command: cmdNegotiate, credits: 1, flags: flagServerToRedir}
+	h.encode(resp)
+	return append(resp, body...), nil
+}
+
+// splitSMB1Dialects splits the dialect strings out of an SMB1
+// NEGOTIATE request
+func splitSMB1Dialects(b []byte) (dialects []string) {
+	for len(b) > 0 && b[0] == 0x02 {
+		b = b[1:]
+		i := 0
+		for i < len(b) && b[i] != 0 {
+			i++
+		}
+		dialects = append(dialects, string(b[:i]))
+		if i < len(b) {
+			i++
+		}
+		b = b[i:]
+	}
+	return dialects
+}
+
+// handle runs the possibly compounded SMB2 requests in msg and
+// returns the responses to send or nil if there are none
+func (c *conn) handle(msg []byte) ([]byte, error) {
+	type result struct {
+		req    *request
+		status uint32
+		resp   []byte
+	}
+	var (
+		results []result
+		prev    *request
+		status  uint32
+	)
+	for len(msg) > 0 {
+		if len(msg) < headerSize || string(msg[:4]) != "\xfeSMB" {
+			return nil, errors.New("bad SMB2 header")
+		}
+		hdr := parseHeader(msg)
+		this := msg
+		if hdr.nextCommand != 0 {
+			if int(hdr.nextCommand) < headerSize || int(hdr.nextCommand) > len(msg) || hdr.nextCommand%8 != 0 {
+				return nil, errors.New("bad compound offset")
+			}
+			this = msg[:hdr.nextCommand]
+		}
+		msg = msg[len(this):]
+		req := &request{
+			c:    c,
+			hdr:  hdr,
+			msg:  this,
+			body: this[headerSize:],
+		}
+		if hdr.command == cmdCancel {
+			// CANCEL has no response
+			c.cancel(req)
+			continue
+		}
+		related := hdr.flags&flagRelatedOperations != 0
+		if related && prev == nil {
+			status = statusInvalidParameter
+		} else if related && (status&0xC0000000) == 0xC0000000 {
+			// a related request fails with the error of the one
+			// before it
+		} else {
+			if related {
+				req.hdr.sessionID = prev.hdr.sessionID
+				req.hdr.treeID = prev.hdr.treeID
+				req.fileID = prev.fileID
+			}
+			status = c.run(req)
+		}
+		results = append(results, result{req: req, status: status})
+		prev = req
+	}
+	if len(results) == 0 {
+		return nil, nil
+	}
+	var out []byte
+	for i := range results {
+		r := &results[i]
+		req := r.req
+		body := req.respBody
+		hasBody := r.status == statusSuccess || isWarning(r.status) ||
+			(req.hdr.command == cmdSessionSetup && r.status == statusMoreProcessingRequired)
+		if !hasBody || body == nil {
+			body = errorBody()
+		}
+		resp := make([]byte, headerSize, headerSize+len(body)+8)
+		resp = append(resp, body...)
+		last := i == len(results)-1
+		if !last {
+			for len(resp)%8 != 0 {
+				resp = append(resp, 0)
+			}
+		}
+		h := header{
+			creditCharge: req.hdr.creditCharge,
+			status:       r.status,
+			command:      req.hdr.command,
+			credits:      grantCredits(req.hdr.credits),
+			flags:        flagServerToRedir | req.hdr.flags&flagRelatedOperations,
+			messageID:    req.hdr.messageID,
+			treeID:       req.hdr.treeID,
+			sessionID:    req.hdr.sessionID,
+		}
+		if r.status == statusPending {
+			h.flags |= flagAsyncCommand
+			h.asyncID = req.async
+		}
+		if !last {
+			h.nextCommand = uint32(len(resp))
+		}
+		h.encode(resp)
+		c.afterResponse(req, r.status, resp)
+		if sess := req.sess; sess != nil && sess.shouldSign(req, r.status) {
+			sess.sign(resp)
+		}
+		out = append(out, resp...)
+	}
+	return out, nil
+}
+
+// run checks the session and tree of req then runs it, returning the
+// status and storing the response body in req
+func (c *conn) run(req *request) (status uint32) {
+	cmdNum := req.hdr.command
+	if int(cmdNum) >= len(commands) || commands[cmdNum].fn == nil {
+		return statusNotSupported
+	}
+	cmd := commands[cmdNum]
+	if cmdNum != cmdNegotiate && c.dialect == 0 {
+		return statusInvalidParameter
+	}
+	if cmd.needSession || req.hdr.flags&flagSigned != 0 {
+		sess := c.sessions[req.hdr.sessionID]
+		if sess == nil || (!sess.valid && cmdNum != cmdSessionSetup) {
+			return statusUserSessionDeleted
+		}
+		if req.hdr.flags&flagSigned != 0 {
+			if !sess.verify(req.msg) {
+				fs.Debugf(c.c.RemoteAddr(), "SMB %s has a bad signature", cmd.name)
+				return statusAccessDenied
+			}
+		} else if sess.signingRequired && cmdNum != cmdNegotiate && cmdNum != cmdSessionSetup {
+			// Don't let a man in the middle strip the signatures
+			fs.Debugf(c.c.RemoteAddr(), "SMB %s isn't signed but the session requires signing", cmd.name)
+			return statusAccessDenied
+		}
+		req.sess = sess
+	}
+	if cmd.needTree {
+		req.tree = req.sess.trees[req.hdr.treeID]
+		if req.tree == nil {
+			return statusNetworkNameDeleted
+		}
+	}
+	status, req.respBody = cmd.fn(req)
+	if status != statusSuccess && status != statusMoreProcessingRequired && status != statusPending {
+		fs.Debugf(c.c.RemoteAddr(), "SMB %s failed: 0x%08X", cmd.name, status)
+	}
+	return status
+}
+
+// afterResponse updates the preauth integrity hashes with the
+// response to req
+func (c *conn) afterResponse(req *request, status uint32, resp []byte) {
+	if c.dialect != dialect311 {
+		return
+	}
+	switch {
+	case req.hdr.command == cmdNegotiate && status == statusSuccess:
+		c.preauthHash = preauthHash(c.preauthHash, resp)
+	case req.hdr.command == cmdSessionSetup && status == statusMoreProcessingRequired && req.sess != nil:
+		req.sess.preauthHash = preauthHash(req.sess.preauthHash, resp)
+	}
+}
+
+// preauthHash chains msg onto the preauth integrity hash
+func preauthHash(prev [sha512.Size]byte, msg []byte) [sha512.Size]byte {
+	h := sha512.New()
+	_, _ = h.Write(prev[:])
+	_, _ = h.Write(msg)
+	var out [sha512.Size]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
+
+// isWarning returns true if status is a warning which still carries
+// a response body
+func isWarning(status uint32) bool {
+	return status == statusBufferOverflow
+}
+
+// errorBody returns an SMB2 ERROR response body
+func errorBody() []byte {
+	b := make([]byte, 9)
+	le.PutUint16(b, 9)
+	return b
+}
+
+// grantCredits returns the credits to grant for a request asking for
+// requested credits
+func grantCredits(requested uint16) uint16 {
+	if requested < 1 {
+		return 1
+	}
+	if requested > maxCredits {
+		return maxCredits
+	}
+	return requested
+}
+
+// echo runs an ECHO request
+func (req *request) echo() (uint32, []byte) {
+	body := make([]byte, 4)
+	le.PutUint16(body, 4)
+	return statusSuccess, body
+}
@@ -0,0 +1,513 @@
+package smb
+
+import (
+	"errors"
+	"hash/fnv"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// CREATE constants
+const (
+	dispositionSupersede   = 0
+	dispositionOpen        = 1
+	dispositionCreate      = 2
+	dispositionOpenIf      = 3
+	dispositionOverwrite   = 4
+	dispositionOverwriteIf = 5
+
+	optionDirectoryFile    = 0x00000001
+	optionNonDirectoryFile = 0x00000040
+	optionDeleteOnClose    = 0x00001000
+
+	actionSuperseded  = 0
+	actionOpened      = 1
+	actionCreated     = 2
+	actionOverwritten = 3
+
+	attrReadOnly  = 0x00000001
+	attrDirectory = 0x00000010
+	attrArchive   = 0x00000020
+
+	closePostQueryAttrib = 0x0001
+
+	// fileIDAny in both halves of a file id means the file opened
+	// by the previous request in a compound
+	fileIDAny = 0xFFFFFFFFFFFFFFFF
+
+	fsctlValidateNegotiateInfo = 0x00140204
+	ioctlIsFsctl               = 0x00000001
+
+	allocationUnit = 4096
+
+	accessModeMask = os.O_RDONLY | os.O_WRONLY | os.O_RDWR
+)
+
+// file is a file or directory opened by CREATE
+type file struct {
+	id            uint64
+	tree          *tree
+	path          string // path in the VFS without a leading /
+	isDir         bool
+	deleteOnClose bool
+	h             vfs.Handle // opened on first read or write
+	hWrite        bool       // h can write
+	hRead         bool       // h can read
+	entries       []dirEntry // directory listing being returned
+	entriesPos    int
+}
+
+// translateError converts an error from the VFS into an NTSTATUS
+func translateError(err error) uint32 {
+	if err == nil {
+		return statusSuccess
+	}
+	_, uErr := fserrors.Cause(err)
+	switch uErr {
+	case vfs.OK:
+		return statusSuccess
+	case vfs.ENOENT, fs.ErrorDirNotFound, fs.ErrorObjectNotFound:
+		return statusObjectNameNotFound
+	case vfs.EEXIST, fs.ErrorDirExists:
+		return statusObjectNameCollision
+	case vfs.EPERM, fs.ErrorPermissionDenied:
+		return statusAccessDenied
+	case vfs.ENOTEMPTY:
+		return statusDirectoryNotEmpty
+	case vfs.EROFS:
+		return statusMediaWriteProtected
+	case vfs.ENOSPC:
+		return statusDiskFull
+	case vfs.ENOSYS, fs.ErrorNotImplemented:
+		return statusNotSupported
+	case vfs.EINVAL:
+		return statusInvalidParameter
+	case vfs.EBADF, vfs.ECLOSED:
+		return statusFileClosed
+	}
+	if errors.Is(err, fs.ErrorDirNotFound) || errors.Is(err, fs.ErrorObjectNotFound) {
+		return statusObjectNameNotFound
+	}
+	fs.Errorf(nil, "SMB error: %v", err)
+	return statusUnexpectedIOError
+}
+
+// vfsPath converts the name of a file in the share into a VFS path
+func vfsPath(name string) (p string, ok bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	// Only the unnamed data stream is supported
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		stream := name[i:]
+		if stream != ":" && !strings.EqualFold(stream, "::$DATA") {
+			return "", false
+		}
+		name = name[:i]
+	}
+	p = path.Clean("/" + name)[1:]
+	return p, true
+}
+
+// getFile looks up the file with the id at off in b
+func (req *request) getFile(b []byte, off int) (*file, uint32) {
+	id := field(b, off, 16)
+	if id == nil {
+		return nil, statusInvalidParameter
+	}
+	volatile := le.Uint64(id[8:])
+	if le.Uint64(id) == fileIDAny && volatile == fileIDAny {
+		volatile = req.fileID
+	}
+	f := req.c.files[volatile]
+	if f == nil || f.tree != req.tree {
+		return nil, statusFileClosed
+	}
+	req.fileID = f.id
+	return f, statusSuccess
+}
+
+// putFileID writes the id of f at off in w
+func putFileID(w *buffer, off int, f *file) {
+	w.u64(off, f.id)
+	w.u64(off+8, f.id)
+}
+
+// fileIndex returns a number identifying the file at p
+func fileIndex(p string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(p))
+	return h.Sum64()
+}
+
+// fileAttributes returns the Windows attributes for node
+func fileAttributes(node vfs.Node) uint32 {
+	if node.IsDir() {
+		return attrDirectory
+	}
+	if node.Mode()&0200 == 0 {
+		return attrArchive | attrReadOnly
+	}
+	return attrArchive
+}
+
+// allocationSize returns the size rounded up to allocationUnit
+func allocationSize(node vfs.Node) uint64 {
+	size := node.Size()
+	if node.IsDir() || size <= 0 {
+		return 0
+	}
+	return uint64((size + allocationUnit - 1) &^ (allocationUnit - 1))
+}
+
+// putNetworkOpenInfo writes CreationTime, LastAccessTime,
+// LastWriteTime, ChangeTime, AllocationSize, EndOfFile and
+// FileAttributes for node at off in w
+func putNetworkOpenInfo(w *buffer, off int, node vfs.Node) {
+	t := fileTime(node.ModTime())
+	w.u64(off, t)
+	w.u64(off+8, t)
+	w.u64(off+16, t)
+	w.u64(off+24, t)
+	w.u64(off+32, allocationSize(node))
+	if !node.IsDir() && node.Size() > 0 {
+		w.u64(off+40, uint64(node.Size()))
+	}
+	w.u32(off+48, fileAttributes(node))
+}
+
+// create runs a CREATE request
+func (req *request) create() (uint32, []byte) {
+	c := req.c
+	b := req.body
+	if len(b) < 56 {
+		return statusInvalidParameter, nil
+	}
+	disposition := le.Uint32(b[36:])
+	options := le.Uint32(b[40:])
+	nameBytes := field(req.msg, int(le.Uint16(b[44:])), int(le.Uint16(b[46:])))
+	if nameBytes == nil && le.Uint16(b[46:]) != 0 {
+		return statusInvalidParameter, nil
+	}
+	name, ok := vfsPath(decodeString(nameBytes))
+	if !ok {
+		return statusObjectNameNotFound, nil
+	}
+	if disposition > dispositionOverwriteIf {
+		return statusInvalidParameter, nil
+	}
+	VFS := c.s.vfs
+	node, err := VFS.Stat(name)
+	if err != nil && err != vfs.ENOENT {
+		return translateError(err), nil
+	}
+	exists := err == nil
+	if !exists && name != "" {
+		_, _, err := VFS.StatParent(name)
+		if err != nil {
+			return statusObjectPathNotFound, nil
+		}
+	}
+	wantDir := options&optionDirectoryFile != 0
+	if exists {
+		switch {
+		case wantDir && !node.IsDir():
+			return statusNotADirectory, nil
+		case options&optionNonDirectoryFile != 0 && node.IsDir():
+			return statusFileIsADirectory, nil
+		}
+	}
+	isDir := wantDir || (exists && node.IsDir())
+	f := &file{
+		id:            c.s.newID(),
+		tree:          req.tree,
+		path:          name,
+		isDir:         isDir,
+		deleteOnClose: options&optionDeleteOnClose != 0,
+	}
+	action := uint32(actionOpened)
+	switch disposition {
+	case dispositionOpen:
+		if !exists {
+			return statusObjectNameNotFound, nil
+		}
+	case dispositionCreate:
+		if exists {
+			return statusObjectNameCollision, nil
+		}
+	case dispositionOverwrite:
+		if !exists {
+			return statusObjectNameNotFound, nil
+		}
+	}
+	truncate := exists && (disposition == dispositionSupersede || disposition == dispositionOverwrite || disposition == dispositionOverwriteIf)
+	if isDir && truncate {
+		return statusInvalidParameter, nil
+	}
+	switch {
+	case !exists && isDir:
+		dir, leaf, err := VFS.StatParent(name)
+		if err != nil {
+			return translateError(err), nil
+		}
+		_, err = dir.Mkdir(leaf)
+		if err != nil {
+			return translateError(err), nil
+		}
+		action = actionCreated
+	case !exists:
+		err = f.open(VFS, os.O_CREATE|os.O_TRUNC|os.O_WRONLY)
+		if err != nil {
+			return translateError(err), nil
+		}
+		action = actionCreated
+	case truncate:
+		err = f.open(VFS, os.O_TRUNC|os.O_WRONLY)
+		if err != nil {
+			return translateError(err), nil
+		}
+		action = actionOverwritten
+		if disposition == dispositionSupersede {
+			action = actionSuperseded
+		}
+	}
+	node, err = VFS.Stat(name)
+	if err != nil {
+		_ = f.closeHandle()
+		return translateError(err), nil
+	}
+	c.files[f.id] = f
+	req.fileID = f.id
+	w := newBuffer(88)
+	w.u16(0, 89) // StructureSize
+	w.u32(4, action)
+	putNetworkOpenInfo(w, 8, node)
+	putFileID(w, 64, f)
+	w.append([]byte{0})
+	return statusSuccess, w.b
+}
+
+// open opens the VFS handle for f with flags closing any existing
+// handle first
+func (f *file) open(VFS *vfs.VFS, flags int) error {
+	if err := f.closeHandle(); err != nil {
+		return err
+	}
+	if VFS.Opt.CacheMode >= vfscommon.CacheModeWrites && flags&accessModeMask != os.O_RDONLY {
+		flags = flags&^accessModeMask | os.O_RDWR
+	}
+	h, err := VFS.OpenFile(f.path, flags, VFS.Opt.FilePerms)
+	if err != nil {
+		return err
+	}
+	f.h = h
+	f.hRead = flags&accessModeMask != os.O_WRONLY
+	f.hWrite = flags&accessModeMask != os.O_RDONLY
+	return nil
+}
+
+// handle returns a VFS handle for f which can write if write is set
+// or read otherwise
+func (f *file) handle(VFS *vfs.VFS, write bool) (vfs.Handle, error) {
+	if f.h != nil && ((write && f.hWrite) || (!write && f.hRead)) {
+		return f.h, nil
+	}
+	flags := os.O_RDONLY
+	if write {
+		flags = os.O_WRONLY
+	}
+	err := f.open(VFS, flags)
+	return f.h, err
+}
+
+// closeHandle closes the VFS handle of f if open
+func (f *file) closeHandle() error {
+	if f.h == nil {
+		return nil
+	}
+	h := f.h
+	f.h = nil
+	return h.Close()
+}
+
+// closeFile closes f deleting it if required and forgets it
+func (c *conn) closeFile(f *file) error {
+	delete(c.files, f.id)
+	for id, n := range c.notifies {
+		if n.file == f {
+			c.completeNotify(id, statusNotifyCleanup)
+		}
+	}
+	err := f.closeHandle()
+	if err != nil {
+		fs.Errorf(f.path, "SMB failed to close file: %v", err)
+	}
+	if f.deleteOnClose {
+		node, statErr := c.s.vfs.Stat(f.path)
+		if statErr == nil {
+			err = node.Remove()
+		} else if statErr != vfs.ENOENT {
+			err = statErr
+		}
+		if err != nil {
+			fs.Errorf(f.path, "SMB failed to delete on close: %v", err)
+		}
+	}
+	return err
+}
+
+// close runs a CLOSE request
+func (req *request) close() (uint32, []byte) {
+	if len(req.body) < 24 {
+		return statusInvalidParameter, nil
+	}
+	f, status := req.getFile(req.body, 8)
+	if f == nil {
+		return status, nil
+	}
+	err := req.c.closeFile(f)
+	w := newBuffer(60)
+	w.u16(0, 60) // StructureSize
+	if le.Uint16(req.body[2:])&closePostQueryAttrib != 0 && !f.deleteOnClose {
+		if node, statErr := req.c.s.vfs.Stat(f.path); statErr == nil {
+			w.u16(2, closePostQueryAttrib)
+			putNetworkOpenInfo(w, 8, node)
+		}
+	}
+	if err != nil {
+		return translateError(err), nil
+	}
+	return statusSuccess, w.b
+}
+
+// flush runs a FLUSH request
+func (req *request) flush() (uint32, []byte) {
+	f, status := req.getFile(req.body, 8)
+	if f == nil {
+		return status, nil
+	}
+	if f.h != nil && f.hWrite {
+		if err := f.h.Sync(); err != nil {
+			return translateError(err), nil
+		}
+	}
+	body := make([]byte, 4)
+	le.PutUint16(body, 4)
+	return statusSuccess, body
+}
+
+// read runs a READ request
+func (req *request) read() (uint32, []byte) {
+	const dataOffset = headerSize + 16
+	b := req.body
+	if len(b) < 48 {
+		return statusInvalidParameter, nil
+	}
+	f, status := req.getFile(b, 16)
+	if f == nil {
+		return status, nil
+	}
+	if f.isDir {
+		return statusInvalidDeviceRequest, nil
+	}
+	length := le.Uint32(b[4:])
+	if length > maxTransactSize {
+		return statusInvalidParameter, nil
+	}
+	offset := int64(le.Uint64(b[8:]))
+	minCount := le.Uint32(b[32:])
+	h, err := f.handle(req.c.s.vfs, false)
+	if err != nil {
+		return translateError(err), nil
+	}
+	w := newBuffer(16)
+	data := make([]byte, length)
+	n, err := h.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		return translateError(err), nil
+	}
+	if (n == 0 && length > 0) || uint32(n) < minCount {
+		return statusEndOfFile, nil
+	}
+	w.u16(0, 17) // StructureSize
+	w.u8(2, dataOffset)
+	w.u32(4, uint32(n))
+	w.append(data[:n])
+	return statusSuccess, w.b
+}
+
+// write runs a WRITE request
+func (req *request) write() (uint32, []byte) {
+	b := req.body
+	if len(b) < 48 {
+		return statusInvalidParameter, nil
+	}
+	f, status := req.getFile(b, 16)
+	if f == nil {
+		return status, nil
+	}
+	if f.isDir {
+		return statusInvalidDeviceRequest, nil
+	}
+	data := field(req.msg, int(le.Uint16(b[2:])), int(le.Uint32(b[4:])))
+	if data == nil {
+		return statusInvalidParameter, nil
+	}
+	offset := int64(le.Uint64(b[8:]))
+	h, err := f.handle(req.c.s.vfs, true)
+	if err != nil {
+		return translateError(err), nil
+	}
+	n, err := h.WriteAt(data, offset)
+	if err != nil {
+		return translateError(err), nil
+	}
+	w := newBuffer(16)
+	w.u16(0, 17) // StructureSize
+	w.u32(4, uint32(n))
+	w.append([]byte{0})
+	return statusSuccess, w.b
+}
+
+// lock runs a LOCK request. Locks aren't supported but always succeed
+// so clients which lock files can use them.
+func (req *request) lock() (uint32, []byte) {
+	f, status := req.getFile(req.body, 8)
+	if f == nil {
+		return status, nil
+	}
+	body := make([]byte, 4)
+	le.PutUint16(body, 4)
+	return statusSuccess, body
+}
+
+// ioctl runs an IOCTL request
+func (req *request) ioctl() (uint32, []byte) {
+	b := req.body
+	if len(b) < 56 {
+		return statusInvalidParameter, nil
+	}
+	ctlCode := le.Uint32(b[4:])
+	if ctlCode != fsctlValidateNegotiateInfo || le.Uint32(b[48:])&ioctlIsFsctl == 0 {
+		return statusNotSupported, nil
+	}
+	input := field(req.msg, int(le.Uint32(b[24:])), int(le.Uint32(b[28:])))
+	status, output := req.validateNegotiate(input)
+	if status != statusSuccess {
+		return status, nil
+	}
+	w := newBuffer(48)
+	w.u16(0, 49) // StructureSize
+	w.u32(4, ctlCode)
+	copy(w.b[8:24], b[8:24]) // FileId
+	w.u32(24, headerSize+48) // InputOffset
+	w.u32(32, headerSize+48) // OutputOffset
+	w.u32(36, uint32(len(output)))
+	w.append(output)
+	return statusSuccess, w.b
+}
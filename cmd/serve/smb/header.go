@@ -0,0 +1,226 @@
+package smb
+
+import (
+	"encoding/binary"
+	"time"
+	"unicode/utf16"
+)
+
+// SMB2 protocol constants (MS-SMB2)
+const (
+	headerSize = 64
+
+	// Commands
+	cmdNegotiate      = 0x00
+	cmdSessionSetup   = 0x01
+	cmdLogoff         = 0x02
+	cmdTreeConnect    = 0x03
+	cmdTreeDisconnect = 0x04
+	cmdCreate         = 0x05
+	cmdClose          = 0x06
+	cmdFlush          = 0x07
+	cmdRead           = 0x08
+	cmdWrite          = 0x09
+	cmdLock           = 0x0A
+	cmdIoctl          = 0x0B
+	cmdCancel         = 0x0C
+	cmdEcho           = 0x0D
+	cmdQueryDirectory = 0x0E
+	cmdChangeNotify   = 0x0F
+	cmdQueryInfo      = 0x10
+	cmdSetInfo        = 0x11
+	cmdOplockBreak    = 0x12
+
+	// Header flags
+	flagServerToRedir     = 0x00000001
+	flagAsyncCommand      = 0x00000002
+	flagRelatedOperations = 0x00000004
+	flagSigned            = 0x00000008
+
+	// Dialects
+	dialect202      = 0x0202
+	dialect210      = 0x0210
+	dialect300      = 0x0300
+	dialect302      = 0x0302
+	dialect311      = 0x0311
+	dialectWildcard = 0x02FF
+
+	// Security modes
+	signingEnabled  = 0x0001
+	signingRequired = 0x0002
+
+	// Capabilities
+	capLargeMTU = 0x00000004
+
+	// Session flags
+	sessionFlagIsGuest = 0x0001
+	sessionFlagIsNull  = 0x0002
+)
+
+// NTSTATUS values used in responses
+const (
+	statusSuccess                = 0x00000000
+	statusNoMoreFiles            = 0x80000006
+	statusBufferOverflow         = 0x80000005
+	statusInvalidInfoClass       = 0xC0000003
+	statusInfoLengthMismatch     = 0xC0000004
+	statusInvalidParameter       = 0xC000000D
+	statusNoSuchFile             = 0xC000000F
+	statusInvalidDeviceRequest   = 0xC0000010
+	statusEndOfFile              = 0xC0000011
+	statusMoreProcessingRequired = 0xC0000016
+	statusAccessDenied           = 0xC0000022
+	statusObjectNameInvalid      = 0xC0000033
+	statusObjectNameNotFound     = 0xC0000034
+	statusObjectNameCollision    = 0xC0000035
+	statusObjectPathNotFound     = 0xC000003A
+	statusLogonFailure           = 0xC000006D
+	statusFileIsADirectory       = 0xC00000BA
+	statusNotSupported           = 0xC00000BB
+	statusNetworkNameDeleted     = 0xC00000C9
+	statusBadNetworkName         = 0xC00000CC
+	statusUnexpectedIOError      = 0xC00000E9
+	statusDirectoryNotEmpty      = 0xC0000101
+	statusNotADirectory          = 0xC0000103
+	statusFileClosed             = 0xC0000128
+	statusUserSessionDeleted     = 0xC0000203
+	statusMediaWriteProtected    = 0xC00000A2
+	statusRequestNotAccepted     = 0xC00000D0
+	statusDiskFull               = 0xC000007F
+)
+
+// le is the byte order of SMB2
+var le = binary.LittleEndian
+
+// header is a decoded SMB2 header
+type header struct {
+	creditCharge uint16
+	status       uint32
+	command      uint16
+	credits      uint16
+	flags        uint32
+	nextCommand  uint32
+	messageID    uint64
+	asyncID      uint64
+	treeID       uint32
+	sessionID    uint64
+	signature    [16]byte
+}
+
+// parseHeader decodes the header at the start of b which must be at
+// least headerSize long
+func parseHeader(b []byte) (h header) {
+	h.creditCharge = le.Uint16(b[6:])
+	h.status = le.Uint32(b[8:])
+	h.command = le.Uint16(b[12:])
+	h.credits = le.Uint16(b[14:])
+	h.flags = le.Uint32(b[16:])
+	h.nextCommand = le.Uint32(b[20:])
+	h.messageID = le.Uint64(b[24:])
+	if h.flags&flagAsyncCommand != 0 {
+		h.asyncID = le.Uint64(b[32:])
+	} else {
+		h.treeID = le.Uint32(b[36:])
+	}
+	h.sessionID = le.Uint64(b[40:])
+	copy(h.signature[:], b[48:64])
+	return h
+}
+
+// encode writes the header to the start of b
+func (h *header) encode(b []byte) {
+	copy(b, "\xfeSMB")
+	le.PutUint16(b[4:], headerSize)
+	le.PutUint16(b[6:], h.creditCharge)
+	le.PutUint32(b[8:], h.status)
+	le.PutUint16(b[12:], h.command)
+	le.PutUint16(b[14:], h.credits)
+	le.PutUint32(b[16:], h.flags)
+	le.PutUint32(b[20:], h.nextCommand)
+	le.PutUint64(b[24:], h.messageID)
+	if h.flags&flagAsyncCommand != 0 {
+		le.PutUint64(b[32:], h.asyncID)
+	} else {
+		le.PutUint32(b[32:], 0)
+		le.PutUint32(b[36:], h.treeID)
+	}
+	le.PutUint64(b[40:], h.sessionID)
+	copy(b[48:64], h.signature[:])
+}
+
+// buffer is used to build response bodies
+type buffer struct {
+	b []byte
+}
+
+// newBuffer makes a buffer with n zero bytes to fill in
+func newBuffer(n int) *buffer {
+	return &buffer{b: make([]byte, n)}
+}
+
+func (w *buffer) u8(off int, v uint8)   { w.b[off] = v }
+func (w *buffer) u16(off int, v uint16) { le.PutUint16(w.b[off:], v) }
+func (w *buffer) u32(off int, v uint32) { le.PutUint32(w.b[off:], v) }
+func (w *buffer) u64(off int, v uint64) { le.PutUint64(w.b[off:], v) }
+
+// append adds data to the end of the buffer returning its offset
+func (w *buffer) append(data []byte) int {
+	off := len(w.b)
+	w.b = append(w.b, data...)
+	return off
+}
+
+// align pads the buffer with zeros to a multiple of n bytes
+func (w *buffer) align(n int) {
+	for len(w.b)%n != 0 {
+		w.b = append(w.b, 0)
+	}
+}
+
+// field returns the n bytes at off in b or nil if they are out of
+// range
+func field(b []byte, off, n int) []byte {
+	if off < 0 || n < 0 || off+n > len(b) {
+		return nil
+	}
+	return b[off : off+n]
+}
+
+// encodeString encodes s as UTF-16LE
+func encodeString(s string) []byte {
+	u := utf16.Encode([]rune(s))
+	b := make([]byte, 2*len(u))
+	for i, c := range u {
+		le.PutUint16(b[2*i:], c)
+	}
+	return b
+}
+
+// decodeString decodes UTF-16LE
+func decodeString(b []byte) string {
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = le.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u))
+}
+
+// fileTimeEpoch is the number of 100ns intervals between 1601 and 1970
+const fileTimeEpoch = 116444736000000000
+
+// fileTime converts t into a Windows FILETIME
+func fileTime(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	ft := t.UnixNano()/100 + fileTimeEpoch
+	if ft < 0 {
+		return 0
+	}
+	return uint64(ft)
+}
+
+// fromFileTime converts a Windows FILETIME to a time
+func fromFileTime(ft uint64) time.Time {
+	return time.Unix(0, (int64(ft)-fileTimeEpoch)*100)
+}
@@ -0,0 +1,705 @@
+package smb
+
+import (
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+)
+
+// QUERY_INFO and SET_INFO types
+const (
+	infoFile       = 0x01
+	infoFilesystem = 0x02
+	infoSecurity   = 0x03
+)
+
+// File information classes
+const (
+	fileDirectoryInformation         = 1
+	fileFullDirectoryInformation     = 2
+	fileBothDirectoryInformation     = 3
+	fileBasicInformation             = 4
+	fileStandardInformation          = 5
+	fileInternalInformation          = 6
+	fileEaInformation                = 7
+	fileAccessInformation            = 8
+	fileRenameInformation            = 10
+	fileNamesInformation             = 12
+	fileDispositionInformation       = 13
+	filePositionInformation          = 14
+	fileModeInformation              = 16
+	fileAlignmentInformation         = 17
+	fileAllInformation               = 18
+	fileAllocationInformation        = 19
+	fileEndOfFileInformation         = 20
+	fileStreamInformation            = 22
+	fileCompressionInformation       = 28
+	fileNetworkOpenInformation       = 34
+	fileAttributeTagInformation      = 35
+	fileIDBothDirectoryInformation   = 37
+	fileIDFullDirectoryInformation   = 38
+	fileDispositionInformationEx     = 64
+	fileDispositionDelete            = 0x1
+	fileSystemVolumeInformation      = 1
+	fileSystemSizeInformation        = 3
+	fileSystemDeviceInformation      = 4
+	fileSystemAttributeInformation   = 5
+	fileSystemFullSizeInformation    = 7
+	fileSystemSectorSizeInformation  = 11
+	fileDeviceDisk                   = 0x07
+	fileCasePreservedNames           = 0x02
+	fileUnicodeOnDisk                = 0x04
+	maxComponentLength               = 255
+	bytesPerSector                   = 512
+	sectorsPerUnit                   = allocationUnit / bytesPerSector
+	unknownTotalSize                 = 1 << 50
+	queryDirectoryRestartScans       = 0x01
+	queryDirectoryReturnSingleEntry  = 0x02
+	queryDirectoryReopen             = 0x10
+	securityOwner                    = 0x01
+	securityGroup                    = 0x02
+	securityDACL                     = 0x04
+	securityDescriptorSelfRelative   = 0x8000
+	securityDescriptorDACLPresent    = 0x0004
+	aceObjectInheritContainerInherit = 0x03
+)
+
+// sidEveryone is the SID S-1-1-0
+var sidEveryone = []byte{1, 1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0}
+
+// dirEntry is an entry in a directory listing
+type dirEntry struct {
+	name string
+	node vfs.Node
+}
+
+// notify is a CHANGE_NOTIFY request which has gone async
+type notify struct {
+	file      *file
+	sess      *session
+	messageID uint64
+	signed    bool
+}
+
+// matchPattern returns whether name matches the Windows wildcard
+// pattern case insensitively
+func matchPattern(pattern, name string) bool {
+	if pattern == "" || pattern == "*" {
+		return true
+	}
+	return match([]rune(strings.ToUpper(pattern)), []rune(strings.ToUpper(name)))
+}
+
+// match does the work for matchPattern
+func match(p, n []rune) bool {
+	for len(p) > 0 {
+		switch p[0] {
+		case '*', '<':
+			for i := 0; i <= len(n); i++ {
+				if match(p[1:], n[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?', '>':
+			if len(n) > 0 {
+				n = n[1:]
+			} else if p[0] == '?' {
+				return false
+			}
+		case '"':
+			if len(n) > 0 && n[0] == '.' {
+				n = n[1:]
+			} else if len(n) > 0 {
+				return false
+			}
+		default:
+			if len(n) == 0 || n[0] != p[0] {
+				return false
+			}
+			n = n[1:]
+		}
+		p = p[1:]
+	}
+	return len(n) == 0
+}
+
+// listDir reads the entries of the directory f which match pattern
+func (req *request) listDir(f *file, pattern string) ([]dirEntry, error) {
+	VFS := req.c.s.vfs
+	node, err := VFS.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+	dir, ok := node.(*vfs.Dir)
+	if !ok {
+		return nil, vfs.ENOENT
+	}
+	var entries []dirEntry
+	if matchPattern(pattern, ".") {
+		entries = append(entries, dirEntry{name: ".", node: dir})
+	}
+	if matchPattern(pattern, "..") {
+		parent := vfs.Node(dir)
+		if f.path != "" {
+			if p, err := VFS.Stat(path.Dir(f.path)); err == nil {
+				parent = p
+			}
+		}
+		entries = append(entries, dirEntry{name: "..", node: parent})
+	}
+	items, err := dir.ReadDirAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		if matchPattern(pattern, item.Name()) {
+			entries = append(entries, dirEntry{name: item.Name(), node: item})
+		}
+	}
+	return entries, nil
+}
+
+// dirEntryInfo encodes entry for the information class which must
+// be one of the directory information classes
+func dirEntryInfo(class byte, entry dirEntry) []byte {
+	node := entry.node
+	name := encodeString(entry.name)
+	var w *buffer
+	var nameOff int
+	switch class {
+	case fileNamesInformation:
+		w = newBuffer(12)
+		w.u32(8, uint32(len(name)))
+		w.append(name)
+		return w.b
+	case fileDirectoryInformation:
+		nameOff = 64
+	case fileFullDirectoryInformation:
+		nameOff = 68
+	case fileBothDirectoryInformation:
+		nameOff = 94
+	case fileIDBothDirectoryInformation:
+		nameOff = 104
+	default: // fileIDFullDirectoryInformation
+		nameOff = 80
+	}
+	w = newBuffer(nameOff)
+	t := fileTime(node.ModTime())
+	w.u64(8, t)
+	w.u64(16, t)
+	w.u64(24, t)
+	w.u64(32, t)
+	if !node.IsDir() && node.Size() > 0 {
+		w.u64(40, uint64(node.Size()))
+	}
+	w.u64(48, allocationSize(node))
+	w.u32(56, fileAttributes(node))
+	w.u32(60, uint32(len(name)))
+	switch class {
+	case fileIDBothDirectoryInformation:
+		w.u64(96, fileIndex(node.Path()))
+	case fileIDFullDirectoryInformation:
+		w.u64(72, fileIndex(node.Path()))
+	}
+	w.append(name)
+	return w.b
+}
+
+// queryDirectory runs a QUERY_DIRECTORY request
+func (req *request) queryDirectory() (uint32, []byte) {
+	const outputOffset = headerSize + 8
+	b := req.body
+	if len(b) < 32 {
+		return statusInvalidParameter, nil
+	}
+	f, status := req.getFile(b, 8)
+	if f == nil {
+		return status, nil
+	}
+	if !f.isDir {
+		return statusInvalidParameter, nil
+	}
+	class := b[2]
+	flags := b[3]
+	pattern := decodeString(field(req.msg, int(le.Uint16(b[24:])), int(le.Uint16(b[26:]))))
+	maxOut := int(le.Uint32(b[28:]))
+	switch class {
+	case fileDirectoryInformation, fileFullDirectoryInformation, fileBothDirectoryInformation,
+		fileNamesInformation, fileIDBothDirectoryInformation, fileIDFullDirectoryInformation:
+	default:
+		return statusInvalidInfoClass, nil
+	}
+	if f.entries == nil || flags&(queryDirectoryRestartScans|queryDirectoryReopen) != 0 {
+		entries, err := req.listDir(f, pattern)
+		if err != nil {
+			return translateError(err), nil
+		}
+		if len(entries) == 0 {
+			return statusNoSuchFile, nil
+		}
+		f.entries = entries
+		f.entriesPos = 0
+	}
+	w := newBuffer(8)
+	w.u16(0, 9) // StructureSize
+	lastEntry := -1
+	for f.entriesPos < len(f.entries) {
+		info := dirEntryInfo(class, f.entries[f.entriesPos])
+		start := len(w.b)
+		if lastEntry >= 0 {
+			start = (start + 7) &^ 7
+		}
+		if start-8+len(info) > maxOut {
+			break
+		}
+		w.align(8)
+		if lastEntry >= 0 {
+			w.u32(lastEntry, uint32(start-lastEntry))
+		}
+		lastEntry = w.append(info)
+		f.entriesPos++
+		if flags&queryDirectoryReturnSingleEntry != 0 {
+			break
+		}
+	}
+	if lastEntry < 0 {
+		if f.entriesPos >= len(f.entries) {
+			return statusNoMoreFiles, nil
+		}
+		return statusInfoLengthMismatch, nil
+	}
+	w.u16(2, outputOffset)
+	w.u32(4, uint32(len(w.b)-8))
+	return statusSuccess, w.b
+}
+
+// queryInfo runs a QUERY_INFO request
+func (req *request) queryInfo() (uint32, []byte) {
+	const outputOffset = headerSize + 8
+	b := req.body
+	if len(b) < 40 {
+		return statusInvalidParameter, nil
+	}
+	f, status := req.getFile(b, 24)
+	if f == nil {
+		return status, nil
+	}
+	infoType, class := b[2], b[3]
+	maxOut := int(le.Uint32(b[4:]))
+	var out []byte
+	switch infoType {
+	case infoFile:
+		node, err := req.c.s.vfs.Stat(f.path)
+		if err != nil {
+			return translateError(err), nil
+		}
+		status, out = req.fileInfo(f, node, class)
+	case infoFilesystem:
+		status, out = req.filesystemInfo(class)
+	case infoSecurity:
+		status, out = statusSuccess, securityDescriptor(le.Uint32(b[16:]), f.isDir)
+	default:
+		status = statusNotSupported
+	}
+	if status != statusSuccess {
+		return status, nil
+	}
+	if len(out) > maxOut {
+		out = out[:maxOut]
+		status = statusBufferOverflow
+	}
+	w := newBuffer(8)
+	w.u16(0, 9) // StructureSize
+	w.u16(2, outputOffset)
+	w.u32(4, uint32(len(out)))
+	if len(out) == 0 {
+		w.append([]byte{0})
+	}
+	w.append(out)
+	return status, w.b
+}
+
+// fileInfo returns the file information class for node
+func (req *request) fileInfo(f *file, node vfs.Node, class byte) (uint32, []byte) {
+	basic := func(w *buffer, off int) {
+		t := fileTime(node.ModTime())
+		w.u64(off, t)
+		w.u64(off+8, t)
+		w.u64(off+16, t)
+		w.u64(off+24, t)
+		w.u32(off+32, fileAttributes(node))
+	}
+	standard := func(w *buffer, off int) {
+		w.u64(off, allocationSize(node))
+		if !node.IsDir() && node.Size() > 0 {
+			w.u64(off+8, uint64(node.Size()))
+		}
+		w.u32(off+16, 1) // NumberOfLinks
+		if f.deleteOnClose {
+			w.u8(off+20, 1)
+		}
+		if node.IsDir() {
+			w.u8(off+21, 1)
+		}
+	}
+	access := uint32(accessFull)
+	if req.c.s.vfs.Opt.ReadOnly {
+		access = accessReadOnly
+	}
+	var w *buffer
+	switch class {
+	case fileBasicInformation:
+		w = newBuffer(40)
+		basic(w, 0)
+	case fileStandardInformation:
+		w = newBuffer(24)
+		standard(w, 0)
+	case fileInternalInformation:
+		w = newBuffer(8)
+		w.u64(0, fileIndex(node.Path()))
+	case fileEaInformation, fileModeInformation, fileAlignmentInformation:
+		w = newBuffer(4)
+	case fileAccessInformation:
+		w = newBuffer(4)
+		w.u32(0, access)
+	case filePositionInformation:
+		w = newBuffer(8)
+	case fileAllInformation:
+		name := encodeString("\\" + strings.ReplaceAll(f.path, "/", "\\"))
+		w = newBuffer(100)
+		basic(w, 0)
+		standard(w, 40)
+		w.u64(64, fileIndex(node.Path()))
+		w.u32(76, access)
+		w.u32(96, uint32(len(name)))
+		w.append(name)
+	case fileStreamInformation:
+		w = newBuffer(0)
+		if !node.IsDir() {
+			name := encodeString("::$DATA")
+			w = newBuffer(24)
+			w.u32(4, uint32(len(name)))
+			if node.Size() > 0 {
+				w.u64(8, uint64(node.Size()))
+			}
+			w.u64(16, allocationSize(node))
+			w.append(name)
+		}
+	case fileCompressionInformation:
+		w = newBuffer(16)
+		if !node.IsDir() && node.Size() > 0 {
+			w.u64(0, uint64(node.Size()))
+		}
+	case fileNetworkOpenInformation:
+		w = newBuffer(56)
+		putNetworkOpenInfo(w, 0, node)
+	case fileAttributeTagInformation:
+		w = newBuffer(8)
+		w.u32(0, fileAttributes(node))
+	default:
+		return statusInvalidInfoClass, nil
+	}
+	return statusSuccess, w.b
+}
+
+// filesystemInfo returns the filesystem information class
+func (req *request) filesystemInfo(class byte) (uint32, []byte) {
+	var w *buffer
+	switch class {
+	case fileSystemVolumeInformation:
+		label := encodeString(req.c.s.opt.Share)
+		w = newBuffer(18)
+		w.u32(8, uint32(fileIndex(fs.ConfigString(req.c.s.vfs.Fs()))))
+		w.u32(12, uint32(len(label)))
+		w.append(label)
+	case fileSystemSizeInformation:
+		total, free := req.usage()
+		w = newBuffer(24)
+		w.u64(0, total)
+		w.u64(8, free)
+		w.u32(16, sectorsPerUnit)
+		w.u32(20, bytesPerSector)
+	case fileSystemFullSizeInformation:
+		total, free := req.usage()
+		w = newBuffer(32)
+		w.u64(0, total)
+		w.u64(8, free)
+		w.u64(16, free)
+		w.u32(24, sectorsPerUnit)
+		w.u32(28, bytesPerSector)
+	case fileSystemDeviceInformation:
+		w = newBuffer(8)
+		w.u32(0, fileDeviceDisk)
+	case fileSystemAttributeInformation:
+		name := encodeString("NTFS")
+		w = newBuffer(12)
+		w.u32(0, fileCasePreservedNames|fileUnicodeOnDisk)
+		w.u32(4, maxComponentLength)
+		w.u32(8, uint32(len(name)))
+		w.append(name)
+	case fileSystemSectorSizeInformation:
+		w = newBuffer(28)
+		for off := 0; off < 16; off += 4 {
+			w.u32(off, bytesPerSector)
+		}
+	default:
+		return statusInvalidInfoClass, nil
+	}
+	return statusSuccess, w.b
+}
+
+// usage returns the total and free allocation units
+func (req *request) usage() (total, free uint64) {
+	t, used, f := req.c.s.vfs.Statfs()
+	if used < 0 {
+		used = 0
+	}
+	if t < 0 {
+		t = unknownTotalSize
+		if f >= 0 {
+			t = f + used
+		}
+	}
+	if f < 0 {
+		f = t - used
+	}
+	if f < 0 {
+		f = 0
+	}
+	return uint64(t) / allocationUnit, uint64(f) / allocationUnit
+}
+
+// securityDescriptor returns a self relative security descriptor
+// with the parts in additional giving everyone full access
+func securityDescriptor(additional uint32, isDir bool) []byte {
+	w := newBuffer(20)
+	w.u8(0, 1) // Revision
+	control := uint16(securityDescriptorSelfRelative)
+	if additional&securityOwner != 0 {
+		w.u32(4, uint32(w.append(sidEveryone)))
+	}
+	if additional&securityGroup != 0 {
+		w.u32(8, uint32(w.append(sidEveryone)))
+	}
+	if additional&securityDACL != 0 {
+		control |= securityDescriptorDACLPresent
+		const aceSize = 8 + 12
+		acl := newBuffer(8 + 8)
+		acl.u8(0, 2) // AclRevision
+		acl.u16(2, 8+aceSize)
+		acl.u16(4, 1) // AceCount
+		if isDir {
+			acl.u8(9, aceObjectInheritContainerInherit)
+		}
+		acl.u16(10, aceSize)
+		acl.u32(12, accessFull)
+		acl.append(sidEveryone)
+		w.u32(16, uint32(w.append(acl.b)))
+	}
+	w.u16(2, control)
+	return w.b
+}
+
+// setInfo runs a SET_INFO request
+func (req *request) setInfo() (uint32, []byte) {
+	b := req.body
+	if len(b) < 32 {
+		return statusInvalidParameter, nil
+	}
+	f, status := req.getFile(b, 16)
+	if f == nil {
+		return status, nil
+	}
+	infoType, class := b[2], b[3]
+	in := field(req.msg, int(le.Uint16(b[8:])), int(le.Uint32(b[4:])))
+	if in == nil {
+		return statusInvalidParameter, nil
+	}
+	switch infoType {
+	case infoFile:
+		status = req.setFileInfo(f, class, in)
+	case infoSecurity:
+		// security descriptors can't be stored so are ignored
+	default:
+		status = statusNotSupported
+	}
+	if status != statusSuccess {
+		return status, nil
+	}
+	body := make([]byte, 2)
+	le.PutUint16(body, 2)
+	return statusSuccess, body
+}
+
+// setFileInfo sets the file information class on f
+func (req *request) setFileInfo(f *file, class byte, in []byte) uint32 {
+	VFS := req.c.s.vfs
+	node, err := VFS.Stat(f.path)
+	if err != nil {
+		return translateError(err)
+	}
+	switch class {
+	case fileBasicInformation:
+		if len(in) < 36 {
+			return statusInfoLengthMismatch
+		}
+		// 0 means don't change and -1 means stop updating it
+		if mtime := le.Uint64(in[16:]); mtime != 0 && mtime != 0xFFFFFFFFFFFFFFFF {
+			if err := node.SetModTime(fromFileTime(mtime)); err != nil {
+				return translateError(err)
+			}
+		}
+	case fileRenameInformation:
+		if len(in) < 20 {
+			return statusInfoLengthMismatch
+		}
+		name := field(in, 20, int(le.Uint32(in[16:])))
+		if name == nil {
+			return statusInvalidParameter
+		}
+		return req.rename(f, decodeString(name), in[0] != 0)
+	case fileDispositionInformation, fileDispositionInformationEx:
+		if len(in) < 1 {
+			return statusInfoLengthMismatch
+		}
+		deletePending := in[0] != 0
+		if class == fileDispositionInformationEx {
+			if len(in) < 4 {
+				return statusInfoLengthMismatch
+			}
+			deletePending = le.Uint32(in)&fileDispositionDelete != 0
+		}
+		if deletePending && VFS.Opt.ReadOnly {
+			return statusMediaWriteProtected
+		}
+		if deletePending && node.IsDir() {
+			items, err := node.(*vfs.Dir).ReadDirAll()
+			if err != nil {
+				return translateError(err)
+			}
+			if len(items) > 0 {
+				return statusDirectoryNotEmpty
+			}
+		}
+		f.deleteOnClose = deletePending
+	case filePositionInformation, fileAllocationInformation:
+		// positions are sent with each read and write and the
+		// allocation is set before writing so ignore them
+	case fileEndOfFileInformation:
+		if len(in) < 8 {
+			return statusInfoLengthMismatch
+		}
+		if node.IsDir() {
+			return statusInvalidParameter
+		}
+		if err := node.Truncate(int64(le.Uint64(in))); err != nil {
+			return translateError(err)
+		}
+	default:
+		return statusInvalidInfoClass
+	}
+	return statusSuccess
+}
+
+// rename renames f to name which is relative to the share root
+func (req *request) rename(f *file, name string, replace bool) uint32 {
+	VFS := req.c.s.vfs
+	newPath, ok := vfsPath(name)
+	if !ok || newPath == "" || !utf8.ValidString(newPath) {
+		return statusObjectNameInvalid
+	}
+	if newPath == f.path {
+		return statusSuccess
+	}
+	if existing, err := VFS.Stat(newPath); err == nil {
+		if !replace || existing.IsDir() {
+			return statusObjectNameCollision
+		}
+	} else if err != vfs.ENOENT {
+		return translateError(err)
+	}
+	if _, _, err := VFS.StatParent(newPath); err != nil {
+		return statusObjectPathNotFound
+	}
+	err := VFS.Rename(f.path, newPath)
+	if err != nil {
+		return translateError(err)
+	}
+	// Update the paths of this file and any files opened inside it
+	oldPath := f.path
+	for _, other := range req.c.files {
+		switch {
+		case other.path == oldPath:
+			other.path = newPath
+		case strings.HasPrefix(other.path, oldPath+"/"):
+			other.path = newPath + other.path[len(oldPath):]
+		}
+	}
+	return statusSuccess
+}
+
+// changeNotify runs a CHANGE_NOTIFY request. Changes aren't reported
+// so the request stays pending until it is cancelled or the directory
+// is closed.
+func (req *request) changeNotify() (uint32, []byte) {
+	f, status := req.getFile(req.body, 8)
+	if f == nil {
+		return status, nil
+	}
+	if !f.isDir {
+		return statusInvalidParameter, nil
+	}
+	req.async = req.c.s.newID()
+	req.c.notifies[req.async] = &notify{
+		file:      f,
+		sess:      req.sess,
+		messageID: req.hdr.messageID,
+		signed:    req.hdr.flags&flagSigned != 0,
+	}
+	return statusPending, nil
+}
+
+// cancel runs a CANCEL request which has no response
+func (c *conn) cancel(req *request) {
+	if req.hdr.flags&flagAsyncCommand != 0 {
+		if c.notifies[req.hdr.asyncID] != nil {
+			c.completeNotify(req.hdr.asyncID, statusCancelled)
+		}
+		return
+	}
+	for id, n := range c.notifies {
+		if n.messageID == req.hdr.messageID {
+			c.completeNotify(id, statusCancelled)
+		}
+	}
+}
+
+// completeNotify sends the final response for a pending
+// CHANGE_NOTIFY
+func (c *conn) completeNotify(asyncID uint64, status uint32) {
+	n := c.notifies[asyncID]
+	delete(c.notifies, asyncID)
+	body := errorBody()
+	resp := make([]byte, headerSize, headerSize+len(body))
+	resp = append(resp, body...)
+	h := header{
+		status:    status,
+		command:   cmdChangeNotify,
+		flags:     flagServerToRedir | flagAsyncCommand,
+		messageID: n.messageID,
+		asyncID:   asyncID,
+		sessionID: n.sess.id,
+	}
+	h.encode(resp)
+	if n.sess.signingKey != nil && (n.signed || n.sess.signingRequired) {
+		n.sess.sign(resp)
+	}
+	err := c.writeMessage(resp)
+	if err != nil {
+		fs.Debugf(c.c.RemoteAddr(), "SMB failed to complete CHANGE_NOTIFY: %v", err)
+	}
+}
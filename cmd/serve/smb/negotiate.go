@@ -0,0 +1,148 @@
+package smb
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"time"
+)
+
+// Negotiate constants
+const (
+	maxTransactSize     = 1024 * 1024 // max sizes for SMB 2.1 and later
+	maxTransactSize202  = 64 * 1024   // max sizes for SMB 2.0.2
+	preauthIntegrityCtx = 0x0001      // SMB2_PREAUTH_INTEGRITY_CAPABILITIES
+	hashSHA512          = 0x0001
+	saltSize            = 32
+)
+
+// supportedDialects is the dialects we support in order of preference
+var supportedDialects = []uint16{dialect311, dialect302, dialect300, dialect210, dialect202}
+
+// negotiate runs a NEGOTIATE request
+func (req *request) negotiate() (uint32, []byte) {
+	c := req.c
+	b := req.body
+	if c.dialect != 0 || len(b) < 36 {
+		return statusInvalidParameter, nil
+	}
+	dialectCount := int(le.Uint16(b[2:]))
+	dialects := field(b, 36, 2*dialectCount)
+	if dialectCount == 0 || dialects == nil {
+		return statusInvalidParameter, nil
+	}
+	dialect := uint16(0)
+	for _, d := range supportedDialects {
+		for i := 0; i < dialectCount; i++ {
+			if le.Uint16(dialects[2*i:]) == d {
+				dialect = d
+				break
+			}
+		}
+		if dialect != 0 {
+			break
+		}
+	}
+	if dialect == 0 {
+		return statusNotSupported, nil
+	}
+	c.clientSecurity = le.Uint16(b[4:])
+	c.clientCaps = le.Uint32(b[8:])
+	copy(c.clientGUID[:], b[12:28])
+	var contexts []byte
+	if dialect == dialect311 {
+		ok := hasPreauthSHA512(req.msg, int(le.Uint32(b[28:])), int(le.Uint16(b[32:])))
+		if !ok {
+			return statusInvalidParameter, nil
+		}
+		var salt [saltSize]byte
+		_, _ = rand.Read(salt[:])
+		ctx := newBuffer(8 + 6)
+		ctx.u16(0, preauthIntegrityCtx)
+		ctx.u16(2, 6+saltSize)
+		ctx.u16(8, 1)         // HashAlgorithmCount
+		ctx.u16(10, saltSize) // SaltLength
+		ctx.u16(12, hashSHA512)
+		ctx.append(salt[:])
+		contexts = ctx.b
+		c.preauthHash = preauthHash([sha512.Size]byte{}, req.msg)
+	}
+	c.dialect = dialect
+	return statusSuccess, req.negotiateResponse(dialect, contexts)
+}
+
+// hasPreauthSHA512 returns whether the negotiate contexts in msg
+// include preauth integrity with SHA-512
+func hasPreauthSHA512(msg []byte, off, count int) bool {
+	for i := 0; i < count; i++ {
+		ctx := field(msg, off, 8)
+		if ctx == nil {
+			return false
+		}
+		ctxType := le.Uint16(ctx)
+		n := int(le.Uint16(ctx[2:]))
+		data := field(msg, off+8, n)
+		if data == nil {
+			return false
+		}
+		if ctxType == preauthIntegrityCtx && len(data) >= 4 {
+			algs := field(data, 4, 2*int(le.Uint16(data)))
+			for j := 0; j+1 < len(algs); j += 2 {
+				if le.Uint16(algs[j:]) == hashSHA512 {
+					return true
+				}
+			}
+		}
+		off += (8 + n + 7) &^ 7
+	}
+	return false
+}
+
+// negotiateResponse makes the body of a NEGOTIATE response
+func (req *request) negotiateResponse(dialect uint16, contexts []byte) []byte {
+	const securityBufferOffset = headerSize + 64
+	c := req.c
+	w := newBuffer(64)
+	w.u16(0, 65) // StructureSize
+	w.u16(2, signingEnabled)
+	w.u16(4, dialect)
+	copy(w.b[8:24], c.s.guid[:])
+	size := uint32(maxTransactSize)
+	if dialect == dialect202 {
+		size = maxTransactSize202
+	} else {
+		w.u32(24, capLargeMTU)
+	}
+	w.u32(28, size) // MaxTransactSize
+	w.u32(32, size) // MaxReadSize
+	w.u32(36, size) // MaxWriteSize
+	w.u64(40, fileTime(time.Now()))
+	token := negTokenInitHint()
+	w.u16(56, securityBufferOffset)
+	w.u16(58, uint16(len(token)))
+	w.append(token)
+	if contexts != nil {
+		w.align(8)
+		w.u16(6, 1) // NegotiateContextCount
+		w.u32(60, uint32(headerSize+len(w.b)))
+		w.append(contexts)
+	}
+	return w.b
+}
+
+// validateNegotiate runs FSCTL_VALIDATE_NEGOTIATE_INFO which lets
+// the client check the negotiation wasn't tampered with
+func (req *request) validateNegotiate(input []byte) (uint32, []byte) {
+	c := req.c
+	if len(input) < 24 || c.dialect == dialect311 {
+		return statusInvalidParameter, nil
+	}
+	out := newBuffer(24)
+	out.u32(0, 0) // Capabilities
+	if c.dialect != dialect202 {
+		out.u32(0, capLargeMTU)
+	}
+	copy(out.b[4:20], c.s.guid[:])
+	out.u16(20, signingEnabled)
+	out.u16(22, c.dialect)
+	return statusSuccess, out.b
+}
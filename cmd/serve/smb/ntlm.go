@@ -0,0 +1,321 @@
+package smb
+
+// NTLMSSP (MS-NLMP) server side authentication with NTLMv2
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/rc4"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/md4" //nolint:staticcheck // needed for NTLM
+)
+
+// NTLMSSP message types
+const (
+	ntlmNegotiate    = 1
+	ntlmChallenge    = 2
+	ntlmAuthenticate = 3
+)
+
+// NTLMSSP negotiate flags
+const (
+	ntlmFlagUnicode                 = 0x00000001
+	ntlmFlagRequestTarget           = 0x00000004
+	ntlmFlagSign                    = 0x00000010
+	ntlmFlagSeal                    = 0x00000020
+	ntlmFlagNTLM                    = 0x00000200
+	ntlmFlagAlwaysSign              = 0x00008000
+	ntlmFlagTargetTypeServer        = 0x00020000
+	ntlmFlagExtendedSessionSecurity = 0x00080000
+	ntlmFlagTargetInfo              = 0x00800000
+	ntlmFlagVersion                 = 0x02000000
+	ntlmFlag128                     = 0x20000000
+	ntlmFlagKeyExch                 = 0x40000000
+	ntlmFlag56                      = 0x80000000
+
+	// flags we agree to if the client asks for them
+	ntlmFlagsSupported = ntlmFlagUnicode | ntlmFlagRequestTarget | ntlmFlagSign | ntlmFlagSeal |
+		ntlmFlagAlwaysSign | ntlmFlagExtendedSessionSecurity | ntlmFlagVersion |
+		ntlmFlag128 | ntlmFlagKeyExch | ntlmFlag56
+)
+
+// AV pair ids used in the CHALLENGE target info and the NTLMv2
+// response
+const (
+	avEOL             = 0
+	avNbComputerName  = 1
+	avNbDomainName    = 2
+	avDNSComputerName = 3
+	avDNSDomainName   = 4
+	avFlags           = 6
+	avTimestamp       = 7
+
+	avFlagMICPresent = 0x2
+)
+
+// GSS MIC magic constants
+var (
+	clientSigningMagic = []byte("session key to client-to-server signing key magic constant\x00")
+	serverSigningMagic = []byte("session key to server-to-client signing key magic constant\x00")
+	clientSealingMagic = []byte("session key to client-to-server sealing key magic constant\x00")
+	serverSealingMagic = []byte("session key to server-to-client sealing key magic constant\x00")
+)
+
+var (
+	ntlmSignature = []byte("NTLMSSP\x00")
+
+	errBadNTLM      = errors.New("bad NTLMSSP message")
+	errLogonFailure = errors.New("logon failure")
+)
+
+// ntlmServer is the state of one NTLMSSP authentication
+type ntlmServer struct {
+	negotiate []byte // the NEGOTIATE message from the client
+	challenge []byte // the CHALLENGE message we sent
+	server    [8]byte
+}
+
+// ntlmResult is the outcome of a successful authentication
+type ntlmResult struct {
+	user       string
+	anonymous  bool   // the client logged on anonymously
+	known      bool   // the user and password were verified
+	flags      uint32 // negotiated flags
+	sessionKey []byte // the exported session key if known
+}
+
+// ntlmMessageType returns the type of the NTLMSSP message in b or 0
+// if it isn't one
+func ntlmMessageType(b []byte) uint32 {
+	if len(b) < 12 || !bytes.HasPrefix(b, ntlmSignature) {
+		return 0
+	}
+	return le.Uint32(b[8:])
+}
+
+// serverName returns the NetBIOS name of this machine
+func serverName() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		name = "rclone"
+	}
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i]
+	}
+	if len(name) > 15 {
+		name = name[:15]
+	}
+	return strings.ToUpper(name)
+}
+
+// ntlmField reads the payload of the field descriptor at off
+func ntlmField(msg []byte, off int) []byte {
+	d := field(msg, off, 8)
+	if d == nil {
+		return nil
+	}
+	return field(msg, int(le.Uint32(d[4:])), int(le.Uint16(d)))
+}
+
+// challengeMessage makes the CHALLENGE message answering the
+// NEGOTIATE message in negotiate
+func (n *ntlmServer) challengeMessage(negotiate []byte) ([]byte, error) {
+	if ntlmMessageType(negotiate) != ntlmNegotiate || len(negotiate) < 16 {
+		return nil, errBadNTLM
+	}
+	n.negotiate = append([]byte(nil), negotiate...)
+	_, err := rand.Read(n.server[:])
+	if err != nil {
+		return nil, err
+	}
+	flags := le.Uint32(negotiate[12:])&ntlmFlagsSupported |
+		ntlmFlagNTLM | ntlmFlagTargetTypeServer | ntlmFlagTargetInfo | ntlmFlagUnicode
+	name := serverName()
+	target := encodeString(name)
+	info := newBuffer(0)
+	addAV := func(id uint16, value []byte) {
+		var h [4]byte
+		le.PutUint16(h[:], id)
+		le.PutUint16(h[2:], uint16(len(value)))
+		info.append(h[:])
+		info.append(value)
+	}
+	var timestamp [8]byte
+	le.PutUint64(timestamp[:], fileTime(time.Now()))
+	addAV(avNbDomainName, target)
+	addAV(avNbComputerName, target)
+	addAV(avDNSDomainName, encodeString(strings.ToLower(name)))
+	addAV(avDNSComputerName, encodeString(strings.ToLower(name)))
+	addAV(avTimestamp, timestamp[:])
+	addAV(avEOL, nil)
+
+	const payload = 56
+	w := newBuffer(payload)
+	copy(w.b, ntlmSignature)
+	w.u32(8, ntlmChallenge)
+	off := w.append(target)
+	w.u16(12, uint16(len(target)))
+	w.u16(14, uint16(len(target)))
+	w.u32(16, uint32(off))
+	w.u32(20, flags)
+	copy(w.b[24:32], n.server[:])
+	off = w.append(info.b)
+	w.u16(40, uint16(len(info.b)))
+	w.u16(42, uint16(len(info.b)))
+	w.u32(44, uint32(off))
+	// Version 6.1 build 7601, NTLMSSP revision 15
+	copy(w.b[48:56], []byte{6, 1, 0xb1, 0x1d, 0, 0, 0, 15})
+	n.challenge = w.b
+	return n.challenge, nil
+}
+
+// authenticate checks the AUTHENTICATE message in msg against user
+// and pass.
+//
+// It returns errLogonFailure if the user is known but the password
+// is wrong.
+func (n *ntlmServer) authenticate(msg []byte, user, pass string) (res ntlmResult, err error) {
+	if n.challenge == nil || ntlmMessageType(msg) != ntlmAuthenticate || len(msg) < 64 {
+		return res, errBadNTLM
+	}
+	res.flags = le.Uint32(msg[60:])
+	decode := decodeString
+	if res.flags&ntlmFlagUnicode == 0 {
+		decode = func(b []byte) string { return string(b) }
+	}
+	lmResp := ntlmField(msg, 12)
+	ntResp := ntlmField(msg, 20)
+	domain := decode(ntlmField(msg, 28))
+	res.user = decode(ntlmField(msg, 36))
+	if res.user == "" && len(ntResp) == 0 && (len(lmResp) == 0 || bytes.Equal(lmResp, []byte{0})) {
+		res.anonymous = true
+		return res, nil
+	}
+	if user == "" || !strings.EqualFold(res.user, user) {
+		return res, nil
+	}
+	// NTLMv2 responses are the 16 byte NTProofStr followed by at
+	// least 28 bytes of client data
+	if len(ntResp) < 16+28 {
+		return res, errLogonFailure
+	}
+	ntProof, temp := ntResp[:16], ntResp[16:]
+	var sessionBaseKey []byte
+	for _, d := range []string{domain, ""} {
+		key := ntowfv2(pass, res.user, d)
+		mac := hmac.New(md5.New, key)
+		_, _ = mac.Write(n.server[:])
+		_, _ = mac.Write(temp)
+		if hmac.Equal(mac.Sum(nil), ntProof) {
+			sessionBaseKey = hmacMD5(key, ntProof)
+			break
+		}
+		if d == "" {
+			break
+		}
+	}
+	if sessionBaseKey == nil {
+		return res, errLogonFailure
+	}
+	res.known = true
+	res.sessionKey = sessionBaseKey
+	if res.flags&ntlmFlagKeyExch != 0 {
+		encrypted := ntlmField(msg, 52)
+		if len(encrypted) != 16 {
+			return res, errBadNTLM
+		}
+		cipher, err := rc4.NewCipher(sessionBaseKey)
+		if err != nil {
+			return res, err
+		}
+		res.sessionKey = make([]byte, 16)
+		cipher.XORKeyStream(res.sessionKey, encrypted)
+	}
+	if hasMIC(temp) {
+		if len(msg) < 88 {
+			return res, errBadNTLM
+		}
+		auth := append([]byte(nil), msg...)
+		mic := append([]byte(nil), auth[72:88]...)
+		copy(auth[72:88], make([]byte, 16))
+		mac := hmac.New(md5.New, res.sessionKey)
+		_, _ = mac.Write(n.negotiate)
+		_, _ = mac.Write(n.challenge)
+		_, _ = mac.Write(auth)
+		if !hmac.Equal(mac.Sum(nil), mic) {
+			return res, errLogonFailure
+		}
+	}
+	return res, nil
+}
+
+// hasMIC returns whether the AV pairs in the NTLMv2 client data say
+// that the AUTHENTICATE message has a MIC
+func hasMIC(temp []byte) bool {
+	av := temp[28:]
+	for len(av) >= 4 {
+		id := le.Uint16(av)
+		n := int(le.Uint16(av[2:]))
+		if id == avEOL || len(av) < 4+n {
+			break
+		}
+		if id == avFlags && n >= 4 {
+			return le.Uint32(av[4:])&avFlagMICPresent != 0
+		}
+		av = av[4+n:]
+	}
+	return false
+}
+
+// hmacMD5 returns HMAC-MD5 of the data with key
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+	for _, d := range data {
+		_, _ = mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// ntowfv2 returns the NTLMv2 response key
+func ntowfv2(pass, user, domain string) []byte {
+	h := md4.New()
+	_, _ = h.Write(encodeString(pass))
+	return hmacMD5(h.Sum(nil), encodeString(strings.ToUpper(user)+domain))
+}
+
+// ntlmMIC computes the GSS MIC of data with the session key as sent
+// by the client if client is set or by the server otherwise. Only
+// extended session security is supported.
+func ntlmMIC(res *ntlmResult, client bool, data []byte) []byte {
+	signMagic, sealMagic := serverSigningMagic, serverSealingMagic
+	if client {
+		signMagic, sealMagic = clientSigningMagic, clientSealingMagic
+	}
+	signKey := md5.Sum(append(append([]byte(nil), res.sessionKey...), signMagic...))
+	var seq [4]byte // the MIC is the first message so the sequence number is 0
+	checksum := hmacMD5(signKey[:], seq[:], data)[:8]
+	if res.flags&ntlmFlagKeyExch != 0 {
+		key := res.sessionKey
+		switch {
+		case res.flags&ntlmFlag128 != 0:
+		case res.flags&ntlmFlag56 != 0:
+			key = key[:7]
+		default:
+			key = key[:5]
+		}
+		sealKey := md5.Sum(append(append([]byte(nil), key...), sealMagic...))
+		cipher, _ := rc4.NewCipher(sealKey[:])
+		cipher.XORKeyStream(checksum, checksum)
+	}
+	out := make([]byte, 16)
+	le.PutUint32(out, 1)
+	copy(out[4:12], checksum)
+	copy(out[12:], seq[:])
+	return out
+}
@@ -0,0 +1,116 @@
+package smb
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+)
+
+// server contains everything to run the server
+type server struct {
+	ctx      context.Context
+	opt      Options
+	vfs      *vfs.VFS
+	guid     [16]byte // server GUID sent in NEGOTIATE
+	nextID   uint64   // source of session, tree and file ids
+	listener net.Listener
+	waitChan chan struct{} // for waiting on the listener to close
+	mu       sync.Mutex
+	conns    map[*conn]struct{}
+}
+
+func newServer(ctx context.Context, VFS *vfs.VFS, opt *Options) (*server, error) {
+	if opt.User == "" && !opt.Guest {
+		return nil, errors.New("need --user and --pass or --guest to serve SMB")
+	}
+	s := &server{
+		ctx:      ctx,
+		opt:      *opt,
+		vfs:      VFS,
+		waitChan: make(chan struct{}),
+		conns:    make(map[*conn]struct{}),
+	}
+	if s.opt.Share == "" {
+		s.opt.Share = DefaultOpt.Share
+	}
+	_, err := rand.Read(s.guid[:])
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newID returns a new non zero id
+func (s *server) newID() uint64 {
+	return atomic.AddUint64(&s.nextID, 1)
+}
+
+// Serve starts the server listening
+func (s *server) Serve() (err error) {
+	s.listener, err = net.Listen("tcp", s.opt.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for SMB connections: %w", err)
+	}
+	fs.Logf(nil, "SMB Server listening on %v serving share %q\n", s.listener.Addr(), s.opt.Share)
+	go s.acceptConnections()
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (s *server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// acceptConnections accepts connections until the listener is closed
+func (s *server) acceptConnections() {
+	defer close(s.waitChan)
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				fs.Errorf(nil, "Failed to accept SMB connection: %v", err)
+			}
+			return
+		}
+		fs.Debugf(c.RemoteAddr(), "SMB connection accepted")
+		sc := newConn(s, c)
+		s.mu.Lock()
+		s.conns[sc] = struct{}{}
+		s.mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sc.serve()
+			sc.close()
+			s.mu.Lock()
+			delete(s.conns, sc)
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Wait blocks while the listener is open
+func (s *server) Wait() {
+	<-s.waitChan
+}
+
+// Close the listener and the connections
+func (s *server) Close() error {
+	err := s.listener.Close()
+	s.mu.Lock()
+	for sc := range s.conns {
+		_ = sc.c.Close()
+	}
+	s.mu.Unlock()
+	s.Wait()
+	return err
+}
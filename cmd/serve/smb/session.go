@@ -0,0 +1,202 @@
+package smb
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"errors"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// session is an SMB2 session set up by SESSION_SETUP
+type session struct {
+	id              uint64
+	valid           bool // set once authentication has completed
+	guest           bool // set for guest and anonymous sessions
+	anonymous       bool
+	user            string
+	ntlm            *ntlmServer // authentication in progress
+	mechTypes       []byte      // SPNEGO mechTypes for the mechListMIC
+	sessionKey      []byte
+	signingKey      []byte
+	cmac            bool // sign with AES-CMAC rather than HMAC-SHA256
+	signingRequired bool
+	preauthHash     [sha512.Size]byte // for dialect 3.1.1
+	trees           map[uint32]*tree
+}
+
+// shouldSign returns whether the response to req with status should
+// be signed
+func (sess *session) shouldSign(req *request, status uint32) bool {
+	if sess.signingKey == nil || status == statusPending {
+		return false
+	}
+	signed := req.hdr.flags&flagSigned != 0
+	if req.hdr.command == cmdSessionSetup {
+		// The final response is signed so the client can check
+		// the server knows the session key
+		return status == statusSuccess && (signed || sess.signingRequired || req.c.dialect == dialect311)
+	}
+	return signed || sess.signingRequired
+}
+
+// sessionSetup runs a SESSION_SETUP request
+func (req *request) sessionSetup() (uint32, []byte) {
+	const securityBufferOffset = headerSize + 8
+	c := req.c
+	b := req.body
+	if len(b) < 24 {
+		return statusInvalidParameter, nil
+	}
+	if b[2]&0x01 != 0 {
+		// binding to another connection for multichannel
+		return statusRequestNotAccepted, nil
+	}
+	var sess *session
+	if req.hdr.sessionID == 0 {
+		sess = &session{
+			id:          c.s.newID(),
+			preauthHash: c.preauthHash,
+			trees:       make(map[uint32]*tree),
+		}
+		c.sessions[sess.id] = sess
+		req.hdr.sessionID = sess.id
+	} else {
+		sess = c.sessions[req.hdr.sessionID]
+		if sess == nil {
+			return statusUserSessionDeleted, nil
+		}
+		if sess.valid {
+			// re-authentication isn't supported
+			return statusRequestNotAccepted, nil
+		}
+	}
+	req.sess = sess
+	if c.dialect == dialect311 {
+		sess.preauthHash = preauthHash(sess.preauthHash, req.msg)
+	}
+	status, token := req.authenticate(sess, field(req.msg, int(le.Uint16(b[12:])), int(le.Uint16(b[14:]))), b[3])
+	if status != statusSuccess && status != statusMoreProcessingRequired {
+		delete(c.sessions, sess.id)
+		return status, nil
+	}
+	w := newBuffer(8)
+	w.u16(0, 9) // StructureSize
+	if status == statusSuccess {
+		switch {
+		case sess.anonymous:
+			w.u16(2, sessionFlagIsNull)
+		case sess.guest:
+			w.u16(2, sessionFlagIsGuest)
+		}
+	}
+	if len(token) > 0 {
+		w.u16(4, securityBufferOffset)
+		w.u16(6, uint16(len(token)))
+		w.append(token)
+	} else {
+		w.append([]byte{0})
+	}
+	return status, w.b
+}
+
+// authenticate runs one leg of the authentication of sess with the
+// security buffer in token returning the status and the token to
+// send back
+func (req *request) authenticate(sess *session, token []byte, securityMode byte) (uint32, []byte) {
+	c := req.c
+	opt := &c.s.opt
+	remote := c.c.RemoteAddr()
+	neg, err := parseNegToken(token)
+	if err != nil || !neg.offersNTLM {
+		fs.Debugf(remote, "SMB session setup: unsupported security token")
+		return statusLogonFailure, nil
+	}
+	if neg.mechTypesRaw != nil {
+		sess.mechTypes = neg.mechTypesRaw
+	}
+	wrap := func(state byte, first bool, token, mic []byte) []byte {
+		if !neg.spnego {
+			return token
+		}
+		return negTokenResp(state, first, token, mic)
+	}
+	if sess.ntlm == nil {
+		sess.ntlm = &ntlmServer{}
+	}
+	if !neg.ntlm || neg.mechToken == nil {
+		// The client sent a token for a mechanism we don't support
+		// so tell it to use NTLMSSP
+		return statusMoreProcessingRequired, wrap(negAcceptIncomplete, true, nil, nil)
+	}
+	switch ntlmMessageType(neg.mechToken) {
+	case ntlmNegotiate:
+		challenge, err := sess.ntlm.challengeMessage(neg.mechToken)
+		if err != nil {
+			fs.Debugf(remote, "SMB session setup: %v", err)
+			return statusInvalidParameter, nil
+		}
+		return statusMoreProcessingRequired, wrap(negAcceptIncomplete, true, challenge, nil)
+	case ntlmAuthenticate:
+	default:
+		fs.Debugf(remote, "SMB session setup: unexpected security token")
+		return statusInvalidParameter, nil
+	}
+	res, err := sess.ntlm.authenticate(neg.mechToken, opt.User, opt.Pass)
+	sess.ntlm.negotiate, sess.ntlm.challenge = nil, nil
+	if errors.Is(err, errLogonFailure) {
+		fs.Infof(remote, "SMB logon failure for user %q", res.user)
+		return statusLogonFailure, nil
+	} else if err != nil {
+		fs.Debugf(remote, "SMB session setup: %v", err)
+		return statusInvalidParameter, nil
+	}
+	switch {
+	case res.known:
+		sess.user = res.user
+		sess.sessionKey = res.sessionKey
+		sess.deriveSigningKey(c.dialect)
+		sess.signingRequired = securityMode&signingRequired != 0 || c.clientSecurity&signingRequired != 0
+	case opt.Guest:
+		sess.guest = true
+		sess.anonymous = res.anonymous
+	default:
+		fs.Infof(remote, "SMB logon failure for unknown user %q", res.user)
+		return statusLogonFailure, nil
+	}
+	var mic []byte
+	if neg.spnego && res.known && res.flags&ntlmFlagExtendedSessionSecurity != 0 && neg.mechListMIC != nil {
+		if !hmac.Equal(ntlmMIC(&res, true, sess.mechTypes), neg.mechListMIC) {
+			fs.Infof(remote, "SMB logon failure for user %q: bad mechListMIC", res.user)
+			return statusLogonFailure, nil
+		}
+		mic = ntlmMIC(&res, false, sess.mechTypes)
+	}
+	sess.ntlm = nil
+	sess.valid = true
+	if sess.guest {
+		fs.Infof(remote, "SMB guest session started")
+	} else {
+		fs.Infof(remote, "SMB session started for user %q", sess.user)
+	}
+	if !neg.spnego {
+		return statusSuccess, nil
+	}
+	return statusSuccess, negTokenResp(negAcceptCompleted, false, nil, mic)
+}
+
+// logoff runs a LOGOFF request
+func (req *request) logoff() (uint32, []byte) {
+	req.c.closeSession(req.sess)
+	body := make([]byte, 4)
+	le.PutUint16(body, 4)
+	return statusSuccess, body
+}
+
+// closeSession disconnects the trees of sess and forgets it
+func (c *conn) closeSession(sess *session) {
+	for _, t := range sess.trees {
+		c.closeTree(t)
+	}
+	delete(c.sessions, sess.id)
+}
@@ -0,0 +1,122 @@
+package smb
+
+// Message signing for SMB 2.x with HMAC-SHA256 and for SMB 3.x with
+// AES-CMAC (RFC 4493) using keys from the SP800-108 KDF
+
+import (
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+)
+
+// kdf is the SP800-108 counter mode KDF with HMAC-SHA256 producing a
+// 128 bit key
+func kdf(key, label, context []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte{0, 0, 0, 1})
+	_, _ = mac.Write(label)
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write(context)
+	_, _ = mac.Write([]byte{0, 0, 0, 128})
+	return mac.Sum(nil)[:16]
+}
+
+// deriveSigningKey sets the key used to sign messages in sess from
+// the session key
+func (sess *session) deriveSigningKey(dialect uint16) {
+	switch dialect {
+	case dialect202, dialect210:
+		sess.signingKey = sess.sessionKey
+	case dialect300, dialect302:
+		sess.signingKey = kdf(sess.sessionKey, []byte("SMB2AESCMAC\x00"), []byte("SmbSign\x00"))
+	default:
+		sess.signingKey = kdf(sess.sessionKey, []byte("SMBSigningKey\x00"), sess.preauthHash[:])
+	}
+	sess.cmac = dialect >= dialect300
+}
+
+// signature computes the signature of msg which must have its
+// signature field zeroed
+func (sess *session) signature(msg []byte) []byte {
+	if sess.cmac {
+		return aesCMAC(sess.signingKey, msg)
+	}
+	mac := hmac.New(sha256.New, sess.signingKey)
+	_, _ = mac.Write(msg)
+	return mac.Sum(nil)[:16]
+}
+
+// sign sets the signed flag and the signature of msg
+func (sess *session) sign(msg []byte) {
+	le.PutUint32(msg[16:], le.Uint32(msg[16:])|flagSigned)
+	copy(msg[48:64], make([]byte, 16))
+	copy(msg[48:64], sess.signature(msg))
+}
+
+// verify checks the signature of msg
+func (sess *session) verify(msg []byte) bool {
+	if sess.signingKey == nil {
+		return false
+	}
+	buf := append([]byte(nil), msg...)
+	copy(buf[48:64], make([]byte, 16))
+	return subtle.ConstantTimeCompare(sess.signature(buf), msg[48:64]) == 1
+}
+
+// aesCMAC computes the AES-CMAC of msg with a 128 bit key
+func aesCMAC(key, msg []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	const bs = aes.BlockSize
+	// Generate the subkeys
+	var l, k1, k2 [bs]byte
+	block.Encrypt(l[:], l[:])
+	shiftLeft(k1[:], l[:])
+	if l[0]&0x80 != 0 {
+		k1[bs-1] ^= 0x87
+	}
+	shiftLeft(k2[:], k1[:])
+	if k1[0]&0x80 != 0 {
+		k2[bs-1] ^= 0x87
+	}
+	n := (len(msg) + bs - 1) / bs
+	complete := n > 0 && len(msg)%bs == 0
+	if n == 0 {
+		n = 1
+	}
+	var last [bs]byte
+	if complete {
+		xorBytes(last[:], msg[(n-1)*bs:], k1[:])
+	} else {
+		rem := msg[(n-1)*bs:]
+		copy(last[:], rem)
+		last[len(rem)] = 0x80
+		xorBytes(last[:], last[:], k2[:])
+	}
+	var x [bs]byte
+	for i := 0; i < n-1; i++ {
+		xorBytes(x[:], x[:], msg[i*bs:(i+1)*bs])
+		block.Encrypt(x[:], x[:])
+	}
+	xorBytes(x[:], x[:], last[:])
+	block.Encrypt(x[:], x[:])
+	return x[:]
+}
+
+// xorBytes sets dst[i] = a[i] ^ b[i] for each byte of dst
+func xorBytes(dst, a, b []byte) {
+	for i := range dst {
+		dst[i] = a[i] ^ b[i]
+	}
+}
+
+// shiftLeft sets dst to src shifted left by one bit
+func shiftLeft(dst, src []byte) {
+	n := binary.BigEndian.Uint64(src[8:])
+	binary.BigEndian.PutUint64(dst[:8], binary.BigEndian.Uint64(src)<<1|n>>63)
+	binary.BigEndian.PutUint64(dst[8:], n<<1)
+}
@@ -0,0 +1,129 @@
+// Package smb implements a server to serve a VFS remote over SMB2/3
+package smb
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfsflags"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Options contains options for the SMB Server
+type Options struct {
+	ListenAddr string // Port to listen on
+	User       string // single username for authentication
+	Pass       string // password for authentication
+	Guest      bool   // allow guest access
+	Share      string // name of the share
+}
+
+// DefaultOpt is the default values used for Options
+var DefaultOpt = Options{
+	ListenAddr: "localhost:445",
+	Share:      "rclone",
+}
+
+// Opt is options set by command line flags
+var Opt = DefaultOpt
+
+// AddFlags adds flags for the smb server
+func AddFlags(flagSet *pflag.FlagSet, Opt *Options) {
+	rc.AddOption("smb", &Opt)
+	flags.StringVarP(flagSet, &Opt.ListenAddr, "addr", "", Opt.ListenAddr, "IPaddress:Port or :Port to bind server to")
+	flags.StringVarP(flagSet, &Opt.User, "user", "", Opt.User, "User name for authentication")
+	flags.StringVarP(flagSet, &Opt.Pass, "pass", "", Opt.Pass, "Password for authentication")
+	flags.BoolVarP(flagSet, &Opt.Guest, "guest", "", Opt.Guest, "Allow guest access without a password")
+	flags.StringVarP(flagSet, &Opt.Share, "share", "", Opt.Share, "Name of the share to serve the remote as")
+}
+
+func init() {
+	vfsflags.AddFlags(Command.Flags())
+	AddFlags(Command.Flags(), &Opt)
+}
+
+const longHelp = `rclone serve smb implements an SMB2/3 server to serve the remote as
+a Windows network share. This lets Windows machines, smart TVs,
+scanners and anything else which can use a network share access the
+remote without installing any software.
+
+Clients connect to the share with a UNC path like
+
+    \\server\rclone
+
+where |rclone| is the name of the share which can be changed with
+--share. Browsing the list of shares on the server isn't supported so
+clients must be given the share name.
+
+On Linux the share can be mounted with
+
+    mount -t cifs -o vers=3.0,username=user //server/rclone /mnt/remote
+
+### Server options
+
+Use --addr to specify which IP address and port the server should
+listen on, e.g. --addr 1.2.3.4:445 or --addr :445 to listen to all
+IPs. By default it only listens on localhost.
+
+Windows clients always connect to port 445, which needs root
+privileges to listen on under Unix and must not be in use by the
+Windows file sharing service if rclone is running on Windows. Other
+clients can usually be told to use a different port.
+
+### Authentication
+
+Use --user and --pass to set the user name and password clients must
+log in with. These are checked with NTLMv2 so the password is never
+sent over the network.
+
+Use --guest to let clients connect without a password. If --user is
+set as well, clients which don't supply the user name are given guest
+access. Note that recent versions of Windows refuse to connect to
+shares with guest access by default so --user and --pass are
+recommended for Windows clients.
+
+One of --user or --guest must be given.
+
+Messages are signed with the session key when the client asks for it,
+but they are not encrypted, so the server should only be used on a
+trusted network.
+
+### Limitations
+
+Files can't be locked, change notifications aren't sent, and security
+descriptors are reported as full access for everyone. Alternate data
+streams, hard links and symbolic links aren't supported.
+
+Windows clients write the parts of a file out of order and read files
+they are writing, so |--vfs-cache-mode writes| or |full| should be
+used if clients will be writing.
+
+`
+
+// Command definition for cobra
+var Command = &cobra.Command{
+	Use:   "smb remote:path",
+	Short: `Serve the remote as an SMB network share.`,
+	Long:  strings.ReplaceAll(longHelp, "|", "`") + vfs.Help,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		f := cmd.NewFsSrc(args)
+		cmd.Run(false, true, command, func() error {
+			s, err := newServer(context.Background(), vfs.New(f, &vfsflags.Opt), &Opt)
+			if err != nil {
+				return err
+			}
+			err = s.Serve()
+			if err != nil {
+				return err
+			}
+			s.Wait()
+			return nil
+		})
+	},
+}
@@ -0,0 +1,522 @@
+package smb
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ntlmssp "github.com/Azure/go-ntlmssp"
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPattern(t *testing.T) {
+	for _, test := range []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"*", "anything", true},
+		{"", "anything", true},
+		{"*.txt", "file.TXT", true},
+		{"*.txt", "file.txt.bak", false},
+		{"file?.txt", "file1.txt", true},
+		{"file?.txt", "file.txt", false},
+		{"FILE.TXT", "file.txt", true},
+		{"<.txt", "a.txt", true},
+		{"a\"", "a.", true},
+		{"a\"", "a", true},
+		{"a>", "a", true},
+	} {
+		assert.Equal(t, test.want, matchPattern(test.pattern, test.name), "%q %q", test.pattern, test.name)
+	}
+}
+
+func TestAESCMAC(t *testing.T) {
+	// Test vectors from RFC 4493
+	key, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	msg, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710")
+	for _, test := range []struct {
+		n    int
+		want string
+	}{
+		{0, "bb1d6929e95937287fa37d129b756746"},
+		{16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{40, "dfa66747de9ae63030ca32611497c827"},
+		{64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	} {
+		assert.Equal(t, test.want, hex.EncodeToString(aesCMAC(key, msg[:test.n])), test.n)
+	}
+}
+
+func TestNTLM(t *testing.T) {
+	negotiate, err := ntlmssp.NewNegotiateMessage("", "")
+	require.NoError(t, err)
+	for _, test := range []struct {
+		user, pass string
+		known      bool
+		err        error
+	}{
+		{"user", "secret", true, nil},
+		{"USER", "secret", true, nil},
+		{"user", "wrong", false, errLogonFailure},
+		{"other", "secret", false, nil},
+	} {
+		n := &ntlmServer{}
+		challenge, err := n.challengeMessage(negotiate)
+		require.NoError(t, err)
+		auth, err := ntlmssp.ProcessChallenge(challenge, test.user, test.pass)
+		require.NoError(t, err)
+		res, err := n.authenticate(auth, "user", "secret")
+		assert.Equal(t, test.err, err, test.user)
+		assert.Equal(t, test.known, res.known, test.user)
+		if test.known {
+			assert.Len(t, res.sessionKey, 16)
+		}
+	}
+}
+
+func TestParseNegToken(t *testing.T) {
+	negotiate, err := ntlmssp.NewNegotiateMessage("", "")
+	require.NoError(t, err)
+	mechTypes := der(0xa0, der(0x30, oidNTLMSSP))
+	token := der(0x60, oidSPNEGO, der(0xa0, der(0x30, mechTypes, der(0xa2, der(0x04, negotiate)))))
+	neg, err := parseNegToken(token)
+	require.NoError(t, err)
+	assert.True(t, neg.spnego)
+	assert.True(t, neg.ntlm)
+	assert.Equal(t, negotiate, neg.mechToken)
+	assert.Equal(t, der(0x30, oidNTLMSSP), neg.mechTypesRaw)
+
+	neg, err = parseNegToken(negotiate)
+	require.NoError(t, err)
+	assert.False(t, neg.spnego)
+	assert.Equal(t, negotiate, neg.mechToken)
+
+	_, err = parseNegToken([]byte{0x60, 0x10, 0x00})
+	assert.Error(t, err)
+}
+
+// testClient speaks just enough SMB2 to test the server
+type testClient struct {
+	t         *testing.T
+	c         net.Conn
+	messageID uint64
+	sessionID uint64
+	treeID    uint32
+}
+
+func newTestClient(t *testing.T, s *server) *testClient {
+	c, err := net.Dial("tcp", s.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = c.Close() })
+	return &testClient{t: t, c: c}
+}
+
+// message builds a request with the body
+func (tc *testClient) message(cmd uint16, flags uint32, body []byte) []byte {
+	msg := make([]byte, headerSize, headerSize+len(body))
+	h := header{
+		command:   cmd,
+		credits:   1,
+		flags:     flags,
+		messageID: tc.messageID,
+		treeID:    tc.treeID,
+		sessionID: tc.sessionID,
+	}
+	tc.messageID++
+	h.encode(msg)
+	return append(msg, body...)
+}
+
+// roundTrip sends the messages as a compound and returns the
+// responses
+func (tc *testClient) roundTrip(msgs ...[]byte) (resps []header, bodies [][]byte) {
+	var out []byte
+	for i, msg := range msgs {
+		if i < len(msgs)-1 {
+			for len(msg)%8 != 0 {
+				msg = append(msg, 0)
+			}
+			le.PutUint32(msg[20:], uint32(len(msg)))
+		}
+		out = append(out, msg...)
+	}
+	frame := make([]byte, 4, 4+len(out))
+	binary.BigEndian.PutUint32(frame, uint32(len(out)))
+	_, err := tc.c.Write(append(frame, out...))
+	require.NoError(tc.t, err)
+	_, err = io.ReadFull(tc.c, frame[:4])
+	require.NoError(tc.t, err)
+	in := make([]byte, binary.BigEndian.Uint32(frame))
+	_, err = io.ReadFull(tc.c, in)
+	require.NoError(tc.t, err)
+	for len(in) > 0 {
+		h := parseHeader(in)
+		this := in
+		if h.nextCommand != 0 {
+			this = in[:h.nextCommand]
+		}
+		in = in[len(this):]
+		resps = append(resps, h)
+		bodies = append(bodies, this)
+	}
+	return resps, bodies
+}
+
+// call sends a single request returning the status and the whole
+// response message
+func (tc *testClient) call(cmd uint16, body []byte) (uint32, []byte) {
+	resps, msgs := tc.roundTrip(tc.message(cmd, 0, body))
+	require.Len(tc.t, resps, 1)
+	return resps[0].status, msgs[0]
+}
+
+// withBuffer makes a request body of size fixed bytes followed by
+// data, putting the offset and length of data at off
+func withBuffer(fixed []byte, off int, data []byte, wideLen bool) []byte {
+	le.PutUint16(fixed[off:], uint16(headerSize+len(fixed)))
+	if wideLen {
+		le.PutUint32(fixed[off+2:], uint32(len(data)))
+	} else {
+		le.PutUint16(fixed[off+2:], uint16(len(data)))
+	}
+	return append(fixed, data...)
+}
+
+func (tc *testClient) negotiate() {
+	tc.negotiateSecurity(signingEnabled)
+}
+
+// negotiateSecurity negotiates with the client SecurityMode set to mode
+func (tc *testClient) negotiateSecurity(mode uint16) {
+	body := make([]byte, 36)
+	le.PutUint16(body, 36)
+	le.PutUint16(body[2:], 2) // DialectCount
+	le.PutUint16(body[4:], mode)
+	body = append(body, 0x02, 0x02, 0x10, 0x02)
+	status, resp := tc.call(cmdNegotiate, body)
+	require.Equal(tc.t, uint32(statusSuccess), status)
+	assert.Equal(tc.t, uint16(dialect210), le.Uint16(resp[headerSize+4:]))
+}
+
+func (tc *testClient) sessionSetupLeg(token []byte) (uint32, []byte, []byte) {
+	body := make([]byte, 24)
+	le.PutUint16(body, 25)
+	body = withBuffer(body, 12, token, false)
+	status, resp := tc.call(cmdSessionSetup, body)
+	if tc.sessionID == 0 {
+		tc.sessionID = le.Uint64(resp[40:])
+	}
+	b := resp[headerSize:]
+	return status, b, field(resp, int(le.Uint16(b[4:])), int(le.Uint16(b[6:])))
+}
+
+// login authenticates with NTLMSSP wrapped in SPNEGO returning the
+// status and session flags
+func (tc *testClient) login(user, pass string) (uint32, uint16) {
+	negotiate, err := ntlmssp.NewNegotiateMessage("", "")
+	require.NoError(tc.t, err)
+	mechTypes := der(0xa0, der(0x30, oidNTLMSSP))
+	token := der(0x60, oidSPNEGO, der(0xa0, der(0x30, mechTypes, der(0xa2, der(0x04, negotiate)))))
+	status, _, respToken := tc.sessionSetupLeg(token)
+	require.Equal(tc.t, uint32(statusMoreProcessingRequired), status)
+	neg, err := parseNegToken(respToken)
+	require.NoError(tc.t, err)
+	auth, err := ntlmssp.ProcessChallenge(neg.mechToken, user, pass)
+	require.NoError(tc.t, err)
+	status, b, _ := tc.sessionSetupLeg(negTokenResp(negAcceptIncomplete, false, auth, nil))
+	return status, le.Uint16(b[2:])
+}
+
+func (tc *testClient) treeConnect(share string) uint32 {
+	body := make([]byte, 8)
+	le.PutUint16(body, 9)
+	body = withBuffer(body, 4, encodeString(`\\localhost\`+share), false)
+	status, resp := tc.call(cmdTreeConnect, body)
+	if status == statusSuccess {
+		tc.treeID = le.Uint32(resp[36:])
+	}
+	return status
+}
+
+func createBody(name string, disposition, options uint32) []byte {
+	body := make([]byte, 56)
+	le.PutUint16(body, 57)
+	le.PutUint32(body[24:], accessFull)
+	le.PutUint32(body[36:], disposition)
+	le.PutUint32(body[40:], options)
+	le.PutUint16(body[44:], headerSize+56)
+	name16 := encodeString(name)
+	le.PutUint16(body[46:], uint16(len(name16)))
+	if len(name16) == 0 {
+		// the buffer must have at least one byte
+		name16 = []byte{0}
+	}
+	return append(body, name16...)
+}
+
+// create opens name returning the status and file id
+func (tc *testClient) create(name string, disposition, options uint32) (uint32, []byte) {
+	status, resp := tc.call(cmdCreate, createBody(name, disposition, options))
+	if status != statusSuccess {
+		return status, nil
+	}
+	return status, resp[headerSize+64 : headerSize+80]
+}
+
+// fileIDBody makes a body with the file id at off
+func fileIDBody(size, structureSize, off int, fileID []byte) []byte {
+	body := make([]byte, size)
+	le.PutUint16(body, uint16(structureSize))
+	copy(body[off:], fileID)
+	return body
+}
+
+func (tc *testClient) close(fileID []byte) uint32 {
+	status, _ := tc.call(cmdClose, fileIDBody(24, 24, 8, fileID))
+	return status
+}
+
+func (tc *testClient) write(fileID []byte, offset uint64, data []byte) uint32 {
+	body := fileIDBody(48, 49, 16, fileID)
+	le.PutUint64(body[8:], offset)
+	status, _ := tc.call(cmdWrite, withBuffer(body, 2, data, true))
+	return status
+}
+
+func (tc *testClient) read(fileID []byte, offset uint64, n uint32) (uint32, []byte) {
+	body := fileIDBody(49, 49, 16, fileID)
+	le.PutUint32(body[4:], n)
+	le.PutUint64(body[8:], offset)
+	status, resp := tc.call(cmdRead, body)
+	if status != statusSuccess {
+		return status, nil
+	}
+	b := resp[headerSize:]
+	return status, field(resp, int(b[2]), int(le.Uint32(b[4:])))
+}
+
+func (tc *testClient) setInfo(fileID []byte, class byte, data []byte) uint32 {
+	body := fileIDBody(32, 33, 16, fileID)
+	body[2] = infoFile
+	body[3] = class
+	le.PutUint16(body[8:], headerSize+32)
+	le.PutUint32(body[4:], uint32(len(data)))
+	status, _ := tc.call(cmdSetInfo, append(body, data...))
+	return status
+}
+
+// list returns the names in the directory
+func (tc *testClient) list(fileID []byte, pattern string) (names []string) {
+	for {
+		body := fileIDBody(32, 33, 8, fileID)
+		body[2] = fileIDBothDirectoryInformation
+		le.PutUint32(body[28:], 200) // small to test continuing
+		status, resp := tc.call(cmdQueryDirectory, withBuffer(body, 24, encodeString(pattern), false))
+		if status == statusNoMoreFiles || status == statusNoSuchFile {
+			return names
+		}
+		require.Equal(tc.t, uint32(statusSuccess), status)
+		b := resp[headerSize:]
+		out := field(resp, int(le.Uint16(b[2:])), int(le.Uint32(b[4:])))
+		for {
+			names = append(names, decodeString(out[104:104+le.Uint32(out[60:])]))
+			next := le.Uint32(out)
+			if next == 0 {
+				break
+			}
+			out = out[next:]
+		}
+	}
+}
+
+func newTestServer(t *testing.T, opt *Options) (*server, string) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	f, err := fs.NewFs(ctx, dir)
+	require.NoError(t, err)
+	vfsOpt := vfscommon.DefaultOpt
+	vfsOpt.CacheMode = vfscommon.CacheModeWrites
+	vfsOpt.WriteBack = 0
+	VFS := vfs.New(f, &vfsOpt)
+	t.Cleanup(VFS.Shutdown)
+	opt.ListenAddr = "127.0.0.1:0"
+	s, err := newServer(ctx, VFS, opt)
+	require.NoError(t, err)
+	require.NoError(t, s.Serve())
+	t.Cleanup(func() { _ = s.Close() })
+	return s, dir
+}
+
+func TestServerLogin(t *testing.T) {
+	s, _ := newTestServer(t, &Options{User: "user", Pass: "secret"})
+
+	tc := newTestClient(t, s)
+	tc.negotiate()
+	status, flags := tc.login("user", "secret")
+	assert.Equal(t, uint32(statusSuccess), status)
+	assert.Equal(t, uint16(0), flags)
+	assert.Equal(t, uint32(statusBadNetworkName), tc.treeConnect("nope"))
+	assert.Equal(t, uint32(statusSuccess), tc.treeConnect("RCLONE"))
+
+	for _, user := range []string{"user", "someone"} {
+		tc = newTestClient(t, s)
+		tc.negotiate()
+		status, _ = tc.login(user, "wrong")
+		assert.Equal(t, uint32(statusLogonFailure), status, user)
+		// the session is gone
+		assert.Equal(t, uint32(statusUserSessionDeleted), tc.treeConnect("rclone"))
+	}
+
+	// Requests before negotiating fail
+	tc = newTestClient(t, s)
+	assert.Equal(t, uint32(statusInvalidParameter), tc.treeConnect("rclone"))
+}
+
+func TestServerSigningRequired(t *testing.T) {
+	s, _ := newTestServer(t, &Options{User: "user", Pass: "secret"})
+	tc := newTestClient(t, s)
+	tc.negotiateSecurity(signingEnabled | signingRequired)
+	status, _ := tc.login("user", "secret")
+	require.Equal(t, uint32(statusSuccess), status)
+
+	// Unsigned requests on the session are refused
+	assert.Equal(t, uint32(statusAccessDenied), tc.treeConnect("rclone"))
+
+	// As are ones with a bad signature
+	msg := tc.message(cmdTreeConnect, flagSigned, make([]byte, 8))
+	resps, _ := tc.roundTrip(msg)
+	require.Len(t, resps, 1)
+	assert.Equal(t, uint32(statusAccessDenied), resps[0].status)
+}
+
+func TestServerGuest(t *testing.T) {
+	s, _ := newTestServer(t, &Options{User: "user", Pass: "secret", Guest: true})
+	tc := newTestClient(t, s)
+	tc.negotiate()
+	status, flags := tc.login("someone", "whatever")
+	assert.Equal(t, uint32(statusSuccess), status)
+	assert.Equal(t, uint16(sessionFlagIsGuest), flags)
+	assert.Equal(t, uint32(statusSuccess), tc.treeConnect("rclone"))
+}
+
+func TestServerFiles(t *testing.T) {
+	s, dir := newTestServer(t, &Options{Guest: true, Share: "share"})
+	tc := newTestClient(t, s)
+	tc.negotiate()
+	status, _ := tc.login("guest", "guest")
+	require.Equal(t, uint32(statusSuccess), status)
+	require.Equal(t, uint32(statusSuccess), tc.treeConnect("share"))
+
+	// Make a directory and a file in it
+	status, dirID := tc.create("sub", dispositionCreate, optionDirectoryFile)
+	require.Equal(t, uint32(statusSuccess), status)
+	require.Equal(t, uint32(statusSuccess), tc.close(dirID))
+	status, _ = tc.create(`missing\file.txt`, dispositionCreate, 0)
+	assert.Equal(t, uint32(statusObjectPathNotFound), status)
+	status, fileID := tc.create(`sub\file.txt`, dispositionOverwriteIf, optionNonDirectoryFile)
+	require.Equal(t, uint32(statusSuccess), status)
+	assert.Equal(t, uint32(statusSuccess), tc.write(fileID, 0, []byte("hello world")))
+	status, data := tc.read(fileID, 6, 100)
+	assert.Equal(t, uint32(statusSuccess), status)
+	assert.Equal(t, "world", string(data))
+	status, _ = tc.read(fileID, 11, 100)
+	assert.Equal(t, uint32(statusEndOfFile), status)
+	var eof [8]byte
+	le.PutUint64(eof[:], 5)
+	assert.Equal(t, uint32(statusSuccess), tc.setInfo(fileID, fileEndOfFileInformation, eof[:]))
+	require.Equal(t, uint32(statusSuccess), tc.close(fileID))
+	assert.Equal(t, uint32(statusFileClosed), tc.close(fileID))
+	got, err := os.ReadFile(filepath.Join(dir, "sub", "file.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(got))
+
+	status, _ = tc.create(`sub\file.txt`, dispositionCreate, 0)
+	assert.Equal(t, uint32(statusObjectNameCollision), status)
+	status, _ = tc.create(`sub\file.txt`, dispositionOpen, optionDirectoryFile)
+	assert.Equal(t, uint32(statusNotADirectory), status)
+	status, _ = tc.create(`sub\file.txt:stream`, dispositionOpen, 0)
+	assert.Equal(t, uint32(statusObjectNameNotFound), status)
+
+	// Open, query and close as a compound like Windows does
+	createMsg := tc.message(cmdCreate, 0, createBody(`sub\file.txt`, dispositionOpen, 0))
+	anyID := make([]byte, 16)
+	for i := range anyID {
+		anyID[i] = 0xff
+	}
+	query := fileIDBody(40, 41, 24, anyID)
+	query[2] = infoFile
+	query[3] = fileStandardInformation
+	le.PutUint32(query[4:], 1024)
+	queryMsg := tc.message(cmdQueryInfo, flagRelatedOperations, query)
+	closeMsg := tc.message(cmdClose, flagRelatedOperations, fileIDBody(24, 24, 8, anyID))
+	resps, msgs := tc.roundTrip(createMsg, queryMsg, closeMsg)
+	require.Len(t, resps, 3)
+	for _, h := range resps {
+		assert.Equal(t, uint32(statusSuccess), h.status)
+	}
+	info := msgs[1][headerSize+8:]
+	assert.Equal(t, uint64(5), le.Uint64(info[8:]), "EndOfFile")
+
+	// A failed create fails the related requests
+	createMsg = tc.message(cmdCreate, 0, createBody(`sub\missing`, dispositionOpen, 0))
+	closeMsg = tc.message(cmdClose, flagRelatedOperations, fileIDBody(24, 24, 8, anyID))
+	resps, _ = tc.roundTrip(createMsg, closeMsg)
+	require.Len(t, resps, 2)
+	assert.Equal(t, uint32(statusObjectNameNotFound), resps[0].status)
+	assert.Equal(t, uint32(statusObjectNameNotFound), resps[1].status)
+
+	// Rename the file
+	status, fileID = tc.create(`sub\file.txt`, dispositionOpen, 0)
+	require.Equal(t, uint32(statusSuccess), status)
+	newName := encodeString(`sub\renamed.txt`)
+	rename := make([]byte, 20)
+	le.PutUint32(rename[16:], uint32(len(newName)))
+	assert.Equal(t, uint32(statusSuccess), tc.setInfo(fileID, fileRenameInformation, append(rename, newName...)))
+	require.Equal(t, uint32(statusSuccess), tc.close(fileID))
+	_, err = os.Stat(filepath.Join(dir, "sub", "renamed.txt"))
+	assert.NoError(t, err)
+
+	// List the directory
+	for i := 0; i < 5; i++ {
+		status, fileID = tc.create(`sub\file`+string(rune('a'+i)), dispositionCreate, 0)
+		require.Equal(t, uint32(statusSuccess), status)
+		require.Equal(t, uint32(statusSuccess), tc.close(fileID))
+	}
+	status, dirID = tc.create("sub", dispositionOpen, optionDirectoryFile)
+	require.Equal(t, uint32(statusSuccess), status)
+	assert.Equal(t, []string{".", "..", "filea", "fileb", "filec", "filed", "filee", "renamed.txt"}, tc.list(dirID, "*"))
+	require.Equal(t, uint32(statusSuccess), tc.close(dirID))
+	status, dirID = tc.create("sub", dispositionOpen, optionDirectoryFile)
+	require.Equal(t, uint32(statusSuccess), status)
+	assert.Equal(t, []string{"renamed.txt"}, tc.list(dirID, "*.TXT"))
+
+	// Directories which aren't empty can't be deleted
+	assert.Equal(t, uint32(statusDirectoryNotEmpty), tc.setInfo(dirID, fileDispositionInformation, []byte{1}))
+	require.Equal(t, uint32(statusSuccess), tc.close(dirID))
+
+	// Delete the file on close
+	status, fileID = tc.create(`sub\renamed.txt`, dispositionOpen, optionDeleteOnClose)
+	require.Equal(t, uint32(statusSuccess), status)
+	require.Equal(t, uint32(statusSuccess), tc.close(fileID))
+	_, err = os.Stat(filepath.Join(dir, "sub", "renamed.txt"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Delete a file by setting its disposition
+	status, fileID = tc.create(`sub\filea`, dispositionOpen, 0)
+	require.Equal(t, uint32(statusSuccess), status)
+	assert.Equal(t, uint32(statusSuccess), tc.setInfo(fileID, fileDispositionInformation, []byte{1}))
+	require.Equal(t, uint32(statusSuccess), tc.close(fileID))
+	_, err = os.Stat(filepath.Join(dir, "sub", "filea"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,188 @@
+package smb
+
+// SPNEGO (RFC 4178) wrapping of NTLMSSP
+//
+// The tokens are simple enough that they are parsed and built by hand
+// rather than with encoding/asn1 which can't produce the explicitly
+// tagged optional fields with the values the clients expect.
+
+import (
+	"bytes"
+	"errors"
+)
+
+// DER encoded OIDs
+var (
+	oidSPNEGO  = []byte{0x06, 0x06, 0x2b, 0x06, 0x01, 0x05, 0x05, 0x02}
+	oidNTLMSSP = []byte{0x06, 0x0a, 0x2b, 0x06, 0x01, 0x04, 0x01, 0x82, 0x37, 0x02, 0x02, 0x0a}
+)
+
+// NegTokenResp negState values
+const (
+	negAcceptCompleted  = 0
+	negAcceptIncomplete = 1
+)
+
+var errBadToken = errors.New("bad SPNEGO token")
+
+// negToken is a decoded NegTokenInit or NegTokenResp
+type negToken struct {
+	spnego       bool   // set if the token was wrapped in SPNEGO
+	ntlm         bool   // set if the mechanism chosen is NTLMSSP
+	offersNTLM   bool   // set if NTLMSSP is one of the mechanisms offered
+	mechTypesRaw []byte // DER encoded mechTypes for the mechListMIC
+	mechToken    []byte // the NTLMSSP message if any
+	mechListMIC  []byte
+}
+
+// parseTLV splits a DER tag, length and value off the front of b
+func parseTLV(b []byte) (tag byte, value, rest []byte, err error) {
+	if len(b) < 2 {
+		return 0, nil, nil, errBadToken
+	}
+	tag = b[0]
+	n := int(b[1])
+	b = b[2:]
+	if n&0x80 != 0 {
+		bytesLen := n & 0x7f
+		if bytesLen == 0 || bytesLen > 4 || len(b) < bytesLen {
+			return 0, nil, nil, errBadToken
+		}
+		n = 0
+		for _, c := range b[:bytesLen] {
+			n = n<<8 | int(c)
+		}
+		b = b[bytesLen:]
+	}
+	if n < 0 || n > len(b) {
+		return 0, nil, nil, errBadToken
+	}
+	return tag, b[:n], b[n:], nil
+}
+
+// parseNegToken decodes the security buffer of a SESSION_SETUP
+// request which can be a SPNEGO token or a raw NTLMSSP message
+func parseNegToken(b []byte) (t negToken, err error) {
+	if bytes.HasPrefix(b, ntlmSignature) {
+		return negToken{ntlm: true, offersNTLM: true, mechToken: b}, nil
+	}
+	t.spnego = true
+	tag, value, _, err := parseTLV(b)
+	if err != nil {
+		return t, err
+	}
+	switch tag {
+	case 0x60: // [APPLICATION 0] InitialContextToken
+		var oid []byte
+		_, oid, value, err = parseTLV(value)
+		if err != nil || !bytes.Equal(oid, oidSPNEGO[2:]) {
+			return t, errBadToken
+		}
+		tag, value, _, err = parseTLV(value)
+		if err != nil || tag != 0xa0 {
+			return t, errBadToken
+		}
+	case 0xa1: // NegTokenResp
+		// The mechanism has already been chosen
+		t.ntlm, t.offersNTLM = true, true
+	default:
+		return t, errBadToken
+	}
+	tag, value, _, err = parseTLV(value)
+	if err != nil || tag != 0x30 {
+		return t, errBadToken
+	}
+	for len(value) > 0 {
+		var inner, rest []byte
+		tag, inner, rest, err = parseTLV(value)
+		if err != nil {
+			return t, err
+		}
+		value = rest
+		switch tag {
+		case 0xa0: // mechTypes in NegTokenInit, negState in NegTokenResp
+			if t.ntlm {
+				continue
+			}
+			t.mechTypesRaw = inner
+			var mechs []byte
+			_, mechs, _, err = parseTLV(inner)
+			if err != nil {
+				return t, err
+			}
+			// Only the first mechanism can have an optimistic token
+			t.ntlm = bytes.HasPrefix(mechs, oidNTLMSSP)
+			for len(mechs) > 0 {
+				var oid []byte
+				_, oid, mechs, err = parseTLV(mechs)
+				if err != nil {
+					return t, err
+				}
+				if bytes.Equal(oid, oidNTLMSSP[2:]) {
+					t.offersNTLM = true
+				}
+			}
+		case 0xa2: // mechToken or responseToken
+			_, t.mechToken, _, err = parseTLV(inner)
+			if err != nil {
+				return t, err
+			}
+		case 0xa3: // mechListMIC
+			_, t.mechListMIC, _, err = parseTLV(inner)
+			if err != nil {
+				return t, err
+			}
+		}
+	}
+	return t, nil
+}
+
+// derLength encodes a DER length
+func derLength(n int) []byte {
+	switch {
+	case n < 0x80:
+		return []byte{byte(n)}
+	case n < 0x100:
+		return []byte{0x81, byte(n)}
+	case n < 0x10000:
+		return []byte{0x82, byte(n >> 8), byte(n)}
+	default:
+		return []byte{0x83, byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+}
+
+// der encodes a DER tag, length and the concatenated values
+func der(tag byte, values ...[]byte) []byte {
+	n := 0
+	for _, v := range values {
+		n += len(v)
+	}
+	out := append([]byte{tag}, derLength(n)...)
+	for _, v := range values {
+		out = append(out, v...)
+	}
+	return out
+}
+
+// negTokenInitHint returns the NegTokenInit sent in the NEGOTIATE
+// response to say that NTLMSSP is the only mechanism
+func negTokenInitHint() []byte {
+	mechTypes := der(0xa0, der(0x30, oidNTLMSSP))
+	return der(0x60, oidSPNEGO, der(0xa0, der(0x30, mechTypes)))
+}
+
+// negTokenResp builds a NegTokenResp. supportedMech should be set
+// in the first reply, and token and mic are left out if nil.
+func negTokenResp(state byte, supportedMech bool, token, mic []byte) []byte {
+	fields := [][]byte{der(0xa0, []byte{0x0a, 0x01, state})}
+	if supportedMech {
+		fields = append(fields, der(0xa1, oidNTLMSSP))
+	}
+	if token != nil {
+		fields = append(fields, der(0xa2, der(0x04, token)))
+	}
+	if mic != nil {
+		fields = append(fields, der(0xa3, der(0x04, mic)))
+	}
+	return der(0xa1, der(0x30, fields...))
+}
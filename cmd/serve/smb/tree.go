@@ -0,0 +1,79 @@
+package smb
+
+import (
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// Tree connect constants
+const (
+	shareTypeDisk = 0x01
+
+	shareFlagNoCaching = 0x00000030
+
+	accessFull     = 0x001F01FF // FILE_ALL_ACCESS
+	accessReadOnly = 0x001200A9 // FILE_GENERIC_READ | FILE_GENERIC_EXECUTE
+)
+
+// tree is a connection to the share made by TREE_CONNECT
+type tree struct {
+	id   uint32
+	sess *session
+}
+
+// treeConnect runs a TREE_CONNECT request
+func (req *request) treeConnect() (uint32, []byte) {
+	c := req.c
+	b := req.body
+	if len(b) < 8 {
+		return statusInvalidParameter, nil
+	}
+	path := field(req.msg, int(le.Uint16(b[4:])), int(le.Uint16(b[6:])))
+	if path == nil {
+		return statusInvalidParameter, nil
+	}
+	// The path is \\server\share
+	name := decodeString(path)
+	if i := strings.LastIndexByte(name, '\\'); i >= 0 {
+		name = name[i+1:]
+	}
+	if !strings.EqualFold(name, c.s.opt.Share) {
+		fs.Debugf(c.c.RemoteAddr(), "SMB tree connect to unknown share %q", name)
+		return statusBadNetworkName, nil
+	}
+	t := &tree{
+		id:   uint32(c.s.newID()),
+		sess: req.sess,
+	}
+	req.sess.trees[t.id] = t
+	req.hdr.treeID = t.id
+	access := uint32(accessFull)
+	if c.s.vfs.Opt.ReadOnly {
+		access = accessReadOnly
+	}
+	w := newBuffer(16)
+	w.u16(0, 16) // StructureSize
+	w.u8(2, shareTypeDisk)
+	w.u32(4, shareFlagNoCaching)
+	w.u32(12, access)
+	return statusSuccess, w.b
+}
+
+// treeDisconnect runs a TREE_DISCONNECT request
+func (req *request) treeDisconnect() (uint32, []byte) {
+	req.c.closeTree(req.tree)
+	body := make([]byte, 4)
+	le.PutUint16(body, 4)
+	return statusSuccess, body
+}
+
+// closeTree closes the files opened on t and forgets it
+func (c *conn) closeTree(t *tree) {
+	for _, f := range c.files {
+		if f.tree == t {
+			c.closeFile(f)
+		}
+	}
+	delete(t.sess.trees, t.id)
+}
@@ -0,0 +1,288 @@
+package webdav
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/net/webdav"
+)
+
+// lockSystem is a webdav.LockSystem which keeps the locks in memory
+// and optionally persists them to a file so they survive a restart.
+//
+// Only exclusive write locks are supported, which is all the webdav
+// library asks for.
+type lockSystem struct {
+	mu    sync.Mutex
+	path  string           // file to persist the locks in or "" for memory only
+	locks map[string]*lock // locks indexed by token
+}
+
+// lock is a single WebDAV lock
+type lock struct {
+	Token     string
+	Root      string
+	OwnerXML  string        `json:",omitempty"`
+	ZeroDepth bool          `json:",omitempty"`
+	Duration  time.Duration // negative for an infinite lock
+	Expiry    time.Time     // when the lock expires if Duration >= 0
+	held      bool          // set while a request is using the lock
+}
+
+// check interface
+var _ webdav.LockSystem = (*lockSystem)(nil)
+
+// newLockSystem makes a lockSystem, loading any locks persisted in
+// lockPath if it is set.
+func newLockSystem(lockPath string) (*lockSystem, error) {
+	ls := &lockSystem{
+		path:  lockPath,
+		locks: make(map[string]*lock),
+	}
+	if lockPath == "" {
+		return ls, nil
+	}
+	data, err := ioutil.ReadFile(lockPath)
+	if os.IsNotExist(err) {
+		return ls, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read lock file: %w", err)
+	}
+	var locks []*lock
+	err = json.Unmarshal(data, &locks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse lock file %q: %w", lockPath, err)
+	}
+	for _, l := range locks {
+		ls.locks[l.Token] = l
+	}
+	ls.expire(time.Now())
+	fs.Debugf(nil, "Loaded %d WebDAV locks from %q", len(ls.locks), lockPath)
+	return ls, nil
+}
+
+// persistent returns whether the lock should be saved to the lock
+// file.
+//
+// The webdav handler takes a lock without an owner or timeout for
+// the duration of every request which modifies a resource without
+// an If header. There is no point saving those.
+func (l *lock) persistent() bool {
+	return l.OwnerXML != "" || l.Duration >= 0
+}
+
+// covers returns whether the lock applies to the resource name
+func (l *lock) covers(name string) bool {
+	return name == l.Root || (!l.ZeroDepth && isAncestor(l.Root, name))
+}
+
+// details returns the webdav.LockDetails for the lock
+func (l *lock) details() webdav.LockDetails {
+	return webdav.LockDetails{
+		Root:      l.Root,
+		Duration:  l.Duration,
+		OwnerXML:  l.OwnerXML,
+		ZeroDepth: l.ZeroDepth,
+	}
+}
+
+// setDuration sets the duration and expiry time of the lock
+func (l *lock) setDuration(now time.Time, duration time.Duration) {
+	l.Duration = duration
+	l.Expiry = time.Time{}
+	if duration >= 0 {
+		l.Expiry = now.Add(duration)
+	}
+}
+
+// isAncestor returns whether dir is a strict ancestor of name
+func isAncestor(dir, name string) bool {
+	if dir == "/" {
+		return name != "/"
+	}
+	return strings.HasPrefix(name, dir+"/")
+}
+
+// cleanName cleans a resource name in the same way as the webdav
+// library
+func cleanName(name string) string {
+	if name == "" || name[0] != '/' {
+		name = "/" + name
+	}
+	return path.Clean(name)
+}
+
+// expire removes any locks which have timed out. Locks which are
+// held by a request don't expire until they are released.
+//
+// Call with the mutex held.
+func (ls *lockSystem) expire(now time.Time) {
+	changed := false
+	for token, l := range ls.locks {
+		if !l.held && l.Duration >= 0 && !now.Before(l.Expiry) {
+			fs.Debugf(l.Root, "WebDAV lock %q expired", token)
+			delete(ls.locks, token)
+			changed = changed || l.persistent()
+		}
+	}
+	if changed {
+		ls.save()
+	}
+}
+
+// save writes the persistent locks to the lock file if set.
+//
+// Call with the mutex held.
+func (ls *lockSystem) save() {
+	if ls.path == "" {
+		return
+	}
+	locks := make([]*lock, 0, len(ls.locks))
+	for _, l := range ls.locks {
+		if l.persistent() {
+			locks = append(locks, l)
+		}
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Token < locks[j].Token
+	})
+	data, err := json.MarshalIndent(locks, "", "\t")
+	if err != nil {
+		fs.Errorf(nil, "Failed to encode WebDAV locks: %v", err)
+		return
+	}
+	tmpPath := ls.path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0600)
+	if err == nil {
+		err = os.Rename(tmpPath, ls.path)
+	}
+	if err != nil {
+		fs.Errorf(nil, "Failed to save WebDAV locks: %v", err)
+	}
+}
+
+// lookup returns the lock named by one of the conditions which
+// covers name and isn't held, or nil if there isn't one.
+//
+// Call with the mutex held.
+func (ls *lockSystem) lookup(name string, conditions []webdav.Condition) *lock {
+	for _, c := range conditions {
+		l := ls.locks[c.Token]
+		if l != nil && !l.held && l.covers(name) {
+			return l
+		}
+	}
+	return nil
+}
+
+// Confirm confirms that the caller can claim all of the locks
+// specified by the conditions for name0 and name1.
+func (ls *lockSystem) Confirm(now time.Time, name0, name1 string, conditions ...webdav.Condition) (release func(), err error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.expire(now)
+	var l0, l1 *lock
+	if name0 != "" {
+		if l0 = ls.lookup(cleanName(name0), conditions); l0 == nil {
+			return nil, webdav.ErrConfirmationFailed
+		}
+	}
+	if name1 != "" {
+		if l1 = ls.lookup(cleanName(name1), conditions); l1 == nil {
+			return nil, webdav.ErrConfirmationFailed
+		}
+	}
+	// Don't hold the same lock twice
+	if l1 == l0 {
+		l1 = nil
+	}
+	held := []*lock{}
+	for _, l := range []*lock{l0, l1} {
+		if l != nil {
+			l.held = true
+			held = append(held, l)
+		}
+	}
+	return func() {
+		ls.mu.Lock()
+		defer ls.mu.Unlock()
+		for _, l := range held {
+			l.held = false
+		}
+	}, nil
+}
+
+// Create creates a lock with the given details returning its token.
+func (ls *lockSystem) Create(now time.Time, details webdav.LockDetails) (token string, err error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.expire(now)
+	root := cleanName(details.Root)
+	for _, l := range ls.locks {
+		// Fail if root or one of its ancestors is already locked,
+		// or if a descendant is locked and the new lock is infinite
+		// depth
+		if l.covers(root) || (!details.ZeroDepth && isAncestor(root, l.Root)) {
+			return "", webdav.ErrLocked
+		}
+	}
+	l := &lock{
+		Token:     "opaquelocktoken:" + uuid.New().String(),
+		Root:      root,
+		OwnerXML:  details.OwnerXML,
+		ZeroDepth: details.ZeroDepth,
+	}
+	l.setDuration(now, details.Duration)
+	ls.locks[l.Token] = l
+	if l.persistent() {
+		ls.save()
+	}
+	return l.Token, nil
+}
+
+// Refresh refreshes the lock with the given token.
+func (ls *lockSystem) Refresh(now time.Time, token string, duration time.Duration) (webdav.LockDetails, error) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.expire(now)
+	l := ls.locks[token]
+	if l == nil {
+		return webdav.LockDetails{}, webdav.ErrNoSuchLock
+	}
+	if l.held {
+		return webdav.LockDetails{}, webdav.ErrLocked
+	}
+	l.setDuration(now, duration)
+	if l.persistent() {
+		ls.save()
+	}
+	return l.details(), nil
+}
+
+// Unlock unlocks the lock with the given token.
+func (ls *lockSystem) Unlock(now time.Time, token string) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	ls.expire(now)
+	l := ls.locks[token]
+	if l == nil {
+		return webdav.ErrNoSuchLock
+	}
+	if l.held {
+		return webdav.ErrLocked
+	}
+	delete(ls.locks, token)
+	if l.persistent() {
+		ls.save()
+	}
+	return nil
+}
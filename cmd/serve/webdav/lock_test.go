@@ -0,0 +1,181 @@
+package webdav
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+func TestLockSystemCreate(t *testing.T) {
+	ls, err := newLockSystem("")
+	require.NoError(t, err)
+	now := time.Now()
+
+	token, err := ls.Create(now, webdav.LockDetails{Root: "/dir", Duration: time.Minute})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "opaquelocktoken:"), token)
+
+	for _, test := range []struct {
+		root      string
+		zeroDepth bool
+		wantErr   error
+	}{
+		{"/dir", true, webdav.ErrLocked},
+		{"/dir/file", true, webdav.ErrLocked},
+		{"/", true, nil},
+		{"/", false, webdav.ErrLocked},
+		{"/dirx", false, nil},
+	} {
+		_, err := ls.Create(now, webdav.LockDetails{Root: test.root, ZeroDepth: test.zeroDepth, Duration: time.Minute})
+		assert.Equal(t, test.wantErr, err, test.root)
+	}
+
+	// Zero depth locks don't cover the contents
+	_, err = ls.Create(now, webdav.LockDetails{Root: "/zero", ZeroDepth: true, Duration: time.Minute})
+	require.NoError(t, err)
+	_, err = ls.Create(now, webdav.LockDetails{Root: "/zero/file", ZeroDepth: true, Duration: time.Minute})
+	require.NoError(t, err)
+}
+
+func TestLockSystemConfirm(t *testing.T) {
+	ls, err := newLockSystem("")
+	require.NoError(t, err)
+	now := time.Now()
+
+	token, err := ls.Create(now, webdav.LockDetails{Root: "/dir", Duration: time.Minute})
+	require.NoError(t, err)
+
+	_, err = ls.Confirm(now, "/dir/file", "", webdav.Condition{Token: "opaquelocktoken:wrong"})
+	assert.Equal(t, webdav.ErrConfirmationFailed, err)
+	_, err = ls.Confirm(now, "/other", "", webdav.Condition{Token: token})
+	assert.Equal(t, webdav.ErrConfirmationFailed, err)
+
+	release, err := ls.Confirm(now, "/dir/file", "/dir/file2", webdav.Condition{Token: token})
+	require.NoError(t, err)
+
+	// Can't use, refresh or unlock a lock which is held
+	_, err = ls.Confirm(now, "/dir", "", webdav.Condition{Token: token})
+	assert.Equal(t, webdav.ErrConfirmationFailed, err)
+	_, err = ls.Refresh(now, token, time.Minute)
+	assert.Equal(t, webdav.ErrLocked, err)
+	assert.Equal(t, webdav.ErrLocked, ls.Unlock(now, token))
+
+	// Held locks don't expire
+	later := now.Add(time.Hour)
+	_, err = ls.Create(later, webdav.LockDetails{Root: "/dir"})
+	assert.Equal(t, webdav.ErrLocked, err)
+
+	release()
+	details, err := ls.Refresh(now, token, 2*time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, webdav.LockDetails{Root: "/dir", Duration: 2 * time.Minute}, details)
+	assert.NoError(t, ls.Unlock(now, token))
+	assert.Equal(t, webdav.ErrNoSuchLock, ls.Unlock(now, token))
+}
+
+func TestLockSystemExpire(t *testing.T) {
+	ls, err := newLockSystem("")
+	require.NoError(t, err)
+	now := time.Now()
+
+	token, err := ls.Create(now, webdav.LockDetails{Root: "/file", ZeroDepth: true, Duration: time.Minute})
+	require.NoError(t, err)
+	infinite, err := ls.Create(now, webdav.LockDetails{Root: "/forever", ZeroDepth: true, Duration: -1})
+	require.NoError(t, err)
+
+	later := now.Add(2 * time.Minute)
+	_, err = ls.Refresh(later, token, time.Minute)
+	assert.Equal(t, webdav.ErrNoSuchLock, err)
+	_, err = ls.Create(later, webdav.LockDetails{Root: "/file", ZeroDepth: true, Duration: time.Minute})
+	assert.NoError(t, err)
+	_, err = ls.Refresh(later, infinite, -1)
+	assert.NoError(t, err)
+}
+
+func TestLockSystemPersist(t *testing.T) {
+	lockPath := filepath.Join(t.TempDir(), "locks.json")
+	ls, err := newLockSystem(lockPath)
+	require.NoError(t, err)
+	now := time.Now()
+
+	owner := "<D:href>user</D:href>"
+	token, err := ls.Create(now, webdav.LockDetails{Root: "/file", OwnerXML: owner, ZeroDepth: true, Duration: time.Hour})
+	require.NoError(t, err)
+	expired, err := ls.Create(now, webdav.LockDetails{Root: "/expired", ZeroDepth: true, Duration: time.Nanosecond})
+	require.NoError(t, err)
+	// Locks with no owner or timeout are the ones the handler
+	// takes while running a request so aren't saved
+	temporary, err := ls.Create(now, webdav.LockDetails{Root: "/temporary", ZeroDepth: true, Duration: -1})
+	require.NoError(t, err)
+
+	ls, err = newLockSystem(lockPath)
+	require.NoError(t, err)
+	assert.Len(t, ls.locks, 1)
+	details, err := ls.Refresh(time.Now(), token, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, owner, details.OwnerXML)
+	assert.Equal(t, webdav.ErrNoSuchLock, ls.Unlock(now, expired))
+	assert.Equal(t, webdav.ErrNoSuchLock, ls.Unlock(now, temporary))
+
+	require.NoError(t, ls.Unlock(time.Now(), token))
+	ls, err = newLockSystem(lockPath)
+	require.NoError(t, err)
+	assert.Len(t, ls.locks, 0)
+}
+
+// TestLockHTTP checks the lock system works with the webdav handler
+// in the way Windows and Office clients use it
+func TestLockHTTP(t *testing.T) {
+	ls, err := newLockSystem("")
+	require.NoError(t, err)
+	handler := &webdav.Handler{
+		FileSystem: webdav.NewMemFS(),
+		LockSystem: ls,
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	do := func(method, path, body string, headers ...string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp
+	}
+
+	resp := do("OPTIONS", "/", "")
+	assert.Equal(t, "1, 2", resp.Header.Get("DAV"))
+
+	// Locking a file which doesn't exist creates it
+	resp = do("LOCK", "/doc.docx", `<?xml version="1.0" encoding="utf-8"?>
+<D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope><D:locktype><D:write/></D:locktype><D:owner><D:href>user</D:href></D:owner></D:lockinfo>`,
+		"Timeout", "Second-3600", "Depth", "0")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	lockToken := resp.Header.Get("Lock-Token")
+	assert.True(t, strings.HasPrefix(lockToken, "<opaquelocktoken:"), lockToken)
+
+	resp = do("PUT", "/doc.docx", "hello")
+	assert.Equal(t, http.StatusLocked, resp.StatusCode)
+	resp = do("PUT", "/doc.docx", "hello", "If", "("+lockToken+")")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// Refresh the lock
+	resp = do("LOCK", "/doc.docx", "", "If", "("+lockToken+")", "Timeout", "Second-3600")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = do("UNLOCK", "/doc.docx", "", "Lock-Token", lockToken)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp = do("PUT", "/doc.docx", "hello again")
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+}
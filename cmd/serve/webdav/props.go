@@ -0,0 +1,218 @@
+package webdav
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/net/webdav"
+)
+
+// propSystem keeps the dead properties which clients set on
+// resources with PROPPATCH in memory and optionally persists them to
+// a file so they survive a restart.
+//
+// The properties are indexed by the remote being served as well as
+// the resource name, so users of --auth-proxy with different remotes
+// don't see each other's properties.
+type propSystem struct {
+	mu    sync.Mutex
+	path  string                                             // file to persist the properties in or "" for memory only
+	props map[string]map[string]map[xml.Name]webdav.Property // properties indexed by remote then resource name
+}
+
+// resourceProps is the dead properties of a resource as saved in the
+// properties file
+type resourceProps struct {
+	Remote string
+	Name   string
+	Props  []webdav.Property
+}
+
+// newPropSystem makes a propSystem, loading any properties persisted
+// in propsPath if it is set.
+func newPropSystem(propsPath string) (*propSystem, error) {
+	ps := &propSystem{
+		path:  propsPath,
+		props: make(map[string]map[string]map[xml.Name]webdav.Property),
+	}
+	if propsPath == "" {
+		return ps, nil
+	}
+	data, err := ioutil.ReadFile(propsPath)
+	if os.IsNotExist(err) {
+		return ps, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read properties file: %w", err)
+	}
+	var resources []resourceProps
+	err = json.Unmarshal(data, &resources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse properties file %q: %w", propsPath, err)
+	}
+	for _, r := range resources {
+		props := ps.resource(r.Remote, r.Name, true)
+		for _, p := range r.Props {
+			props[p.XMLName] = p
+		}
+	}
+	fs.Debugf(nil, "Loaded WebDAV properties of %d resources from %q", len(resources), propsPath)
+	return ps, nil
+}
+
+// resource returns the properties of name in remote, creating them
+// if create is set. It returns nil if they don't exist.
+//
+// Call with the mutex held.
+func (ps *propSystem) resource(remote, name string, create bool) map[xml.Name]webdav.Property {
+	resources := ps.props[remote]
+	if resources == nil {
+		if !create {
+			return nil
+		}
+		resources = make(map[string]map[xml.Name]webdav.Property)
+		ps.props[remote] = resources
+	}
+	name = cleanName(name)
+	props := resources[name]
+	if props == nil && create {
+		props = make(map[xml.Name]webdav.Property)
+		resources[name] = props
+	}
+	return props
+}
+
+// save writes the properties to the properties file if set.
+//
+// Call with the mutex held.
+func (ps *propSystem) save() {
+	if ps.path == "" {
+		return
+	}
+	var resources []resourceProps
+	for remote, names := range ps.props {
+		for name, props := range names {
+			r := resourceProps{
+				Remote: remote,
+				Name:   name,
+				Props:  make([]webdav.Property, 0, len(props)),
+			}
+			for _, p := range props {
+				r.Props = append(r.Props, p)
+			}
+			sort.Slice(r.Props, func(i, j int) bool {
+				a, b := r.Props[i].XMLName, r.Props[j].XMLName
+				if a.Space != b.Space {
+					return a.Space < b.Space
+				}
+				return a.Local < b.Local
+			})
+			resources = append(resources, r)
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Remote != resources[j].Remote {
+			return resources[i].Remote < resources[j].Remote
+		}
+		return resources[i].Name < resources[j].Name
+	})
+	data, err := json.MarshalIndent(resources, "", "\t")
+	if err != nil {
+		fs.Errorf(nil, "Failed to encode WebDAV properties: %v", err)
+		return
+	}
+	tmpPath := ps.path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, data, 0600)
+	if err == nil {
+		err = os.Rename(tmpPath, ps.path)
+	}
+	if err != nil {
+		fs.Errorf(nil, "Failed to save WebDAV properties: %v", err)
+	}
+}
+
+// deadProps returns a copy of the dead properties of name in remote
+func (ps *propSystem) deadProps(remote, name string) map[xml.Name]webdav.Property {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	props := ps.resource(remote, name, false)
+	if len(props) == 0 {
+		return nil
+	}
+	out := make(map[xml.Name]webdav.Property, len(props))
+	for k, v := range props {
+		out[k] = v
+	}
+	return out
+}
+
+// patch sets and removes the dead properties of name in remote
+func (ps *propSystem) patch(remote, name string, patches []webdav.Proppatch) []webdav.Propstat {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	props := ps.resource(remote, name, true)
+	pstat := webdav.Propstat{Status: http.StatusOK}
+	for _, patch := range patches {
+		for _, p := range patch.Props {
+			pstat.Props = append(pstat.Props, webdav.Property{XMLName: p.XMLName})
+			if patch.Remove {
+				delete(props, p.XMLName)
+			} else {
+				props[p.XMLName] = p
+			}
+		}
+	}
+	if len(props) == 0 {
+		delete(ps.props[remote], cleanName(name))
+	}
+	ps.save()
+	return []webdav.Propstat{pstat}
+}
+
+// rename moves the properties of oldName in remote and anything
+// inside it to newName
+func (ps *propSystem) rename(remote, oldName, newName string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	resources := ps.props[remote]
+	oldName, newName = cleanName(oldName), cleanName(newName)
+	moved := make(map[string]map[xml.Name]webdav.Property)
+	for name, props := range resources {
+		if name == oldName || isAncestor(oldName, name) {
+			delete(resources, name)
+			moved[newName+name[len(oldName):]] = props
+		}
+	}
+	if len(moved) == 0 {
+		return
+	}
+	for name, props := range moved {
+		resources[name] = props
+	}
+	ps.save()
+}
+
+// remove removes the properties of name in remote and anything
+// inside it
+func (ps *propSystem) remove(remote, name string) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	resources := ps.props[remote]
+	name = cleanName(name)
+	changed := false
+	for resource := range resources {
+		if resource == name || isAncestor(name, resource) {
+			delete(resources, resource)
+			changed = true
+		}
+	}
+	if changed {
+		ps.save()
+	}
+}
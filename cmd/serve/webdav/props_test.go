@@ -0,0 +1,181 @@
+package webdav
+
+import (
+	"context"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/webdav"
+)
+
+var (
+	testPropA = xml.Name{Space: "http://example.com/ns", Local: "a"}
+	testPropB = xml.Name{Space: "http://example.com/ns", Local: "b"}
+)
+
+// testPatch returns a patch setting or removing the names
+func testPatch(remove bool, names ...xml.Name) []webdav.Proppatch {
+	patch := webdav.Proppatch{Remove: remove}
+	for _, name := range names {
+		patch.Props = append(patch.Props, webdav.Property{
+			XMLName:  name,
+			InnerXML: []byte(name.Local + " value"),
+		})
+	}
+	return []webdav.Proppatch{patch}
+}
+
+func TestPropSystemPatch(t *testing.T) {
+	ps, err := newPropSystem("")
+	require.NoError(t, err)
+
+	assert.Nil(t, ps.deadProps("remote:", "/file"))
+
+	pstats := ps.patch("remote:", "/file", testPatch(false, testPropA, testPropB))
+	require.Len(t, pstats, 1)
+	assert.Equal(t, http.StatusOK, pstats[0].Status)
+	assert.Equal(t, []webdav.Property{{XMLName: testPropA}, {XMLName: testPropB}}, pstats[0].Props)
+
+	props := ps.deadProps("remote:", "file")
+	assert.Len(t, props, 2)
+	assert.Equal(t, "a value", string(props[testPropA].InnerXML))
+
+	// Changing the copy doesn't change the properties
+	delete(props, testPropA)
+	assert.Len(t, ps.deadProps("remote:", "/file"), 2)
+
+	// Properties are per remote
+	assert.Nil(t, ps.deadProps("other:", "/file"))
+
+	ps.patch("remote:", "/file", testPatch(true, testPropA))
+	assert.Len(t, ps.deadProps("remote:", "/file"), 1)
+	ps.patch("remote:", "/file", testPatch(true, testPropB))
+	assert.Nil(t, ps.deadProps("remote:", "/file"))
+	assert.Len(t, ps.props["remote:"], 0)
+}
+
+func TestPropSystemRenameRemove(t *testing.T) {
+	ps, err := newPropSystem("")
+	require.NoError(t, err)
+	for _, name := range []string{"/dir", "/dir/file", "/dir2/file", "/file"} {
+		ps.patch("remote:", name, testPatch(false, testPropA))
+	}
+
+	ps.rename("remote:", "/dir", "/new")
+	assert.Nil(t, ps.deadProps("remote:", "/dir"))
+	assert.Nil(t, ps.deadProps("remote:", "/dir/file"))
+	assert.NotNil(t, ps.deadProps("remote:", "/new"))
+	assert.NotNil(t, ps.deadProps("remote:", "/new/file"))
+	assert.NotNil(t, ps.deadProps("remote:", "/dir2/file"))
+
+	// Renaming other remotes does nothing
+	ps.rename("other:", "/new", "/dir")
+	assert.NotNil(t, ps.deadProps("remote:", "/new/file"))
+
+	ps.remove("remote:", "/new")
+	assert.Nil(t, ps.deadProps("remote:", "/new"))
+	assert.Nil(t, ps.deadProps("remote:", "/new/file"))
+	assert.NotNil(t, ps.deadProps("remote:", "/dir2/file"))
+	assert.NotNil(t, ps.deadProps("remote:", "/file"))
+}
+
+func TestPropSystemPersist(t *testing.T) {
+	propsPath := filepath.Join(t.TempDir(), "props.json")
+	ps, err := newPropSystem(propsPath)
+	require.NoError(t, err)
+
+	ps.patch("remote:", "/file", testPatch(false, testPropA, testPropB))
+	ps.patch("other:", "/dir", testPatch(false, testPropA))
+
+	ps, err = newPropSystem(propsPath)
+	require.NoError(t, err)
+	props := ps.deadProps("remote:", "/file")
+	assert.Len(t, props, 2)
+	assert.Equal(t, webdav.Property{XMLName: testPropB, InnerXML: []byte("b value")}, props[testPropB])
+	assert.Len(t, ps.deadProps("other:", "/dir"), 1)
+
+	ps.remove("remote:", "/file")
+	ps, err = newPropSystem(propsPath)
+	require.NoError(t, err)
+	assert.Nil(t, ps.deadProps("remote:", "/file"))
+	assert.Len(t, ps.deadProps("other:", "/dir"), 1)
+
+	require.NoError(t, ioutil.WriteFile(propsPath, []byte("potato"), 0600))
+	_, err = newPropSystem(propsPath)
+	assert.Error(t, err)
+}
+
+// TestPropsHTTP checks the dead properties work with the webdav
+// handler and follow their files when they are moved, copied and
+// deleted
+func TestPropsHTTP(t *testing.T) {
+	f, err := fs.NewFs(context.Background(), t.TempDir())
+	require.NoError(t, err)
+	ps, err := newPropSystem("")
+	require.NoError(t, err)
+	w := &WebDAV{
+		f:     f,
+		_vfs:  vfs.New(f, nil),
+		props: ps,
+		ctx:   context.Background(),
+	}
+	server := httptest.NewServer(&webdav.Handler{
+		FileSystem: w,
+		LockSystem: webdav.NewMemLS(),
+	})
+	defer server.Close()
+
+	do := func(method, path, body string, headers ...string) (*http.Response, string) {
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		for i := 0; i < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		return resp, string(data)
+	}
+	propfind := func(path string) string {
+		resp, body := do("PROPFIND", path, `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:" xmlns:E="http://example.com/ns"><D:prop><E:colour/></D:prop></D:propfind>`, "Depth", "0")
+		require.Equal(t, http.StatusMultiStatus, resp.StatusCode, body)
+		return body
+	}
+
+	resp, _ := do("PUT", "/file.txt", "hello")
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.NotContains(t, propfind("/file.txt"), "green")
+
+	resp, body := do("PROPPATCH", "/file.txt", `<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:E="http://example.com/ns"><D:set><D:prop><E:colour>green</E:colour></D:prop></D:set></D:propertyupdate>`)
+	require.Equal(t, http.StatusMultiStatus, resp.StatusCode)
+	assert.Contains(t, body, "200 OK")
+	assert.Contains(t, propfind("/file.txt"), "green")
+
+	resp, _ = do("MOVE", "/file.txt", "", "Destination", server.URL+"/moved.txt")
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Contains(t, propfind("/moved.txt"), "green")
+	assert.Nil(t, ps.deadProps(propsRemote(w._vfs), "/file.txt"))
+
+	resp, _ = do("COPY", "/moved.txt", "", "Destination", server.URL+"/copy.txt")
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Contains(t, propfind("/copy.txt"), "green")
+
+	resp, _ = do("DELETE", "/moved.txt", "")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Nil(t, ps.deadProps(propsRemote(w._vfs), "/moved.txt"))
+	assert.NotNil(t, ps.deadProps(propsRemote(w._vfs), "/copy.txt"))
+}
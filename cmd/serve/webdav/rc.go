@@ -0,0 +1,47 @@
+package webdav
+
+import (
+	"context"
+
+	"github.com/rclone/rclone/cmd/serve/httplib/httpflags"
+	"github.com/rclone/rclone/cmd/serve/servelib"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/vfs/vfsflags"
+)
+
+func init() {
+	servelib.AddRc("webdav", startRc)
+}
+
+// startRc starts a WebDAV server for serve/start
+func startRc(ctx context.Context, f fs.Fs, in rc.Params) (servelib.Server, error) {
+	opt := httpflags.Opt
+	err := in.GetStructMissingOK("opt", &opt)
+	if err != nil {
+		return nil, err
+	}
+	vfsOpt := vfsflags.Opt
+	err = in.GetStructMissingOK("vfsOpt", &vfsOpt)
+	if err != nil {
+		return nil, err
+	}
+	w, err := newWebDAV(ctx, f, &opt, &vfsOpt)
+	if err != nil {
+		return nil, err
+	}
+	err = w.serve()
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Shutdown stops the server started by serve/start
+func (w *WebDAV) Shutdown() error {
+	w.Close()
+	if w._vfs != nil {
+		w._vfs.Shutdown()
+	}
+	return w.accessLog.Close()
+}
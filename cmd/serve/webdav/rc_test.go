@@ -0,0 +1,60 @@
+package webdav
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/rclone/rclone/backend/local"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRc(t *testing.T) {
+	ctx := context.Background()
+	start := rc.Calls.Get("serve/start")
+	require.NotNil(t, start)
+	stop := rc.Calls.Get("serve/stop")
+	require.NotNil(t, stop)
+
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("hello"), 0600))
+
+	out, err := start.Fn(ctx, rc.Params{
+		"type":   "webdav",
+		"fs":     dir,
+		"opt":    rc.Params{"ListenAddr": "localhost:0"},
+		"vfsOpt": rc.Params{"ReadOnly": true},
+	})
+	require.NoError(t, err)
+	id, err := out.GetString("id")
+	require.NoError(t, err)
+	addr, err := out.GetString("addr")
+	require.NoError(t, err)
+	url := "http://" + addr + "/file.txt"
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", string(body))
+
+	// The VFS options are used
+	req, err := http.NewRequest("PUT", "http://"+addr+"/new.txt", strings.NewReader("hello"))
+	require.NoError(t, err)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.NotEqual(t, http.StatusCreated, resp.StatusCode)
+
+	_, err = stop.Fn(ctx, rc.Params{"id": id})
+	require.NoError(t, err)
+	_, err = http.Get(url)
+	assert.Error(t, err)
+}
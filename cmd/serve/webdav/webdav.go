@@ -3,6 +3,7 @@ package webdav
 
 import (
 	"context"
+	"encoding/xml"
 	"errors"
 	"fmt"
 	"net/http"
@@ -11,15 +12,19 @@ import (
 	"time"
 
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/serve/accesslog"
 	"github.com/rclone/rclone/cmd/serve/httplib"
 	"github.com/rclone/rclone/cmd/serve/httplib/httpflags"
+	"github.com/rclone/rclone/cmd/serve/multiremote"
 	"github.com/rclone/rclone/cmd/serve/proxy"
 	"github.com/rclone/rclone/cmd/serve/proxy/proxyflags"
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/lib/http/oidc"
 	"github.com/rclone/rclone/lib/http/serve"
 	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
 	"github.com/rclone/rclone/vfs/vfsflags"
 	"github.com/spf13/cobra"
 	"golang.org/x/net/webdav"
@@ -29,20 +34,27 @@ var (
 	hashName      string
 	hashType      = hash.None
 	disableGETDir = false
+	lockFile      string
+	propsFile     string
 )
 
 func init() {
 	flagSet := Command.Flags()
 	httpflags.AddFlags(flagSet)
+	oidc.AddFlagsPrefix(flagSet, "", &httpflags.Opt.OIDC)
 	vfsflags.AddFlags(flagSet)
 	proxyflags.AddFlags(flagSet)
+	multiremote.AddFlags(flagSet)
+	accesslog.AddFlags(flagSet)
 	flags.StringVarP(flagSet, &hashName, "etag-hash", "", "", "Which hash to use for the ETag, or auto or blank for off")
 	flags.BoolVarP(flagSet, &disableGETDir, "disable-dir-list", "", false, "Disable HTML directory list on GET request for a directory")
+	flags.StringVarP(flagSet, &lockFile, "lock-file", "", "", "File to keep WebDAV locks in so they survive a restart")
+	flags.StringVarP(flagSet, &propsFile, "props-file", "", "", "File to keep WebDAV properties in so they survive a restart")
 }
 
 // Command definition for cobra
 var Command = &cobra.Command{
-	Use:   "webdav remote:path",
+	Use:   "webdav remote:path [remote:path...]",
 	Short: `Serve remote:path over webdav.`,
 	Long: `
 rclone serve webdav implements a basic webdav server to serve the
@@ -63,12 +75,37 @@ supported hash on the backend or you can use a named hash such as
 
 Use "rclone hashsum" to see the full list.
 
-` + httplib.Help + vfs.Help + proxy.Help,
+#### --lock-file
+
+The server supports WebDAV class 2 locking so clients can lock files
+while they are editing them. Microsoft Office and the Windows WebDAV
+client need this to edit documents directly on the server.
+
+Locks are normally kept in memory and are lost when the server is
+restarted. Set this flag to the path of a file to store the locks in
+so clients which are still editing files keep their locks after a
+restart.
+
+Locks are only enforced for clients of this server, so changes made
+directly to the remote by something else will not be stopped.
+
+#### --props-file
+
+Clients can store their own properties on files and directories
+with PROPPATCH, which some sync clients and the macOS Finder rely
+on. These are kept in memory and are lost when the server is
+restarted. Set this flag to the path of a file to store them in so
+they are kept.
+
+The properties are moved, copied and deleted along with their files
+when this is done through the server, but not when the files are
+changed directly on the remote.
+
+` + httplib.Help + oidc.Help + accesslog.Help + multiremote.Help + vfs.Help + proxy.Help + proxy.UsersHelp,
 	RunE: func(command *cobra.Command, args []string) error {
 		var f fs.Fs
 		if proxyflags.Opt.AuthProxy == "" {
-			cmd.CheckArgs(1, 1, command, args)
-			f = cmd.NewFsSrc(args)
+			f = multiremote.NewFs(command, args)
 		} else {
 			cmd.CheckArgs(0, 0, command, args)
 		}
@@ -85,8 +122,11 @@ Use "rclone hashsum" to see the full list.
 			fs.Debugf(f, "Using hash %v for ETag", hashType)
 		}
 		cmd.Run(false, false, command, func() error {
-			s := newWebDAV(context.Background(), f, &httpflags.Opt)
-			err := s.serve()
+			s, err := newWebDAV(context.Background(), f, &httpflags.Opt, &vfsflags.Opt)
+			if err != nil {
+				return err
+			}
+			err = s.serve()
 			if err != nil {
 				return err
 			}
@@ -114,7 +154,9 @@ type WebDAV struct {
 	f             fs.Fs
 	_vfs          *vfs.VFS // don't use directly, use getVFS
 	webdavhandler *webdav.Handler
+	props         *propSystem
 	proxy         *proxy.Proxy
+	accessLog     *accesslog.Logger
 	ctx           context.Context // for global config
 }
 
@@ -122,29 +164,50 @@ type WebDAV struct {
 var _ webdav.FileSystem = (*WebDAV)(nil)
 
 // Make a new WebDAV to serve the remote
-func newWebDAV(ctx context.Context, f fs.Fs, opt *httplib.Options) *WebDAV {
-	w := &WebDAV{
-		f:   f,
-		ctx: ctx,
+func newWebDAV(ctx context.Context, f fs.Fs, opt *httplib.Options, vfsOpt *vfscommon.Options) (*WebDAV, error) {
+	ls, err := newLockSystem(lockFile)
+	if err != nil {
+		return nil, err
 	}
-	if proxyflags.Opt.AuthProxy != "" {
+	props, err := newPropSystem(propsFile)
+	if err != nil {
+		return nil, err
+	}
+	w := &WebDAV{
+		f:     f,
+		props: props,
+		ctx:   ctx,
+	}
+	if proxyflags.Opt.UsersFile != "" {
+		w.proxy, err = proxy.NewUsers(ctx, &proxyflags.Opt, f)
+		if err != nil {
+			return nil, err
+		}
+	} else if proxyflags.Opt.AuthProxy != "" {
 		w.proxy = proxy.New(ctx, &proxyflags.Opt)
+	}
+	if w.proxy != nil {
 		// override auth
 		copyOpt := *opt
 		copyOpt.Auth = w.auth
 		opt = &copyOpt
 	} else {
-		w._vfs = vfs.New(f, &vfsflags.Opt)
+		w._vfs = vfs.New(f, vfsOpt)
 	}
 	w.Server = httplib.NewServer(http.HandlerFunc(w.handler), opt)
+	w.accessLog, err = accesslog.New("webdav", &accesslog.Opt)
+	if err != nil {
+		return nil, err
+	}
+	w.Server.Wrap(w.accessLog.Handler)
 	webdavHandler := &webdav.Handler{
 		Prefix:     w.Server.Opt.BaseURL,
 		FileSystem: w,
-		LockSystem: webdav.NewMemLS(),
+		LockSystem: ls,
 		Logger:     w.logRequest, // FIXME
 	}
 	w.webdavhandler = webdavHandler
-	return w
+	return w, nil
 }
 
 // Gets the VFS in use for this request
@@ -219,7 +282,7 @@ func (w *WebDAV) serveDir(rw http.ResponseWriter, r *http.Request, dirRemote str
 	// Make the entries for display
 	directory := serve.NewDirectory(dirRemote, w.HTMLTemplate)
 	for _, node := range dirEntries {
-		if vfsflags.Opt.NoModTime {
+		if VFS.Opt.NoModTime {
 			directory.AddHTMLEntry(node.Path(), node.IsDir(), node.Size(), time.Time{})
 		} else {
 			directory.AddHTMLEntry(node.Path(), node.IsDir(), node.Size(), node.ModTime().UTC())
@@ -276,7 +339,7 @@ func (w *WebDAV) OpenFile(ctx context.Context, name string, flags int, perm os.F
 	if err != nil {
 		return nil, err
 	}
-	return Handle{f}, nil
+	return Handle{Handle: f, props: w.props, remote: propsRemote(VFS), name: name}, nil
 }
 
 // RemoveAll removes a file or a directory and its contents
@@ -294,6 +357,7 @@ func (w *WebDAV) RemoveAll(ctx context.Context, name string) (err error) {
 	if err != nil {
 		return err
 	}
+	w.props.remove(propsRemote(VFS), name)
 	return nil
 }
 
@@ -304,7 +368,12 @@ func (w *WebDAV) Rename(ctx context.Context, oldName, newName string) (err error
 	if err != nil {
 		return err
 	}
-	return VFS.Rename(oldName, newName)
+	err = VFS.Rename(oldName, newName)
+	if err != nil {
+		return err
+	}
+	w.props.rename(propsRemote(VFS), oldName, newName)
+	return nil
 }
 
 // Stat returns info about the file or directory
@@ -321,9 +390,31 @@ func (w *WebDAV) Stat(ctx context.Context, name string) (fi os.FileInfo, err err
 	return FileInfo{fi}, nil
 }
 
+// propsRemote returns the name the dead properties of VFS are kept
+// under
+func propsRemote(VFS *vfs.VFS) string {
+	return fs.ConfigString(VFS.Fs())
+}
+
 // Handle represents an open file
 type Handle struct {
 	vfs.Handle
+	props  *propSystem // dead properties of all the files
+	remote string      // remote the file is on as used by props
+	name   string      // name the file was opened as
+}
+
+// check interface
+var _ webdav.DeadPropsHolder = Handle{}
+
+// DeadProps returns a copy of the dead properties of the file
+func (h Handle) DeadProps() (map[xml.Name]webdav.Property, error) {
+	return h.props.deadProps(h.remote, h.name), nil
+}
+
+// Patch sets and removes the dead properties of the file
+func (h Handle) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	return h.props.patch(h.remote, h.name, patches), nil
 }
 
 // Readdir reads directory entries from the handle
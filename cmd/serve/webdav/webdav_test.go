@@ -26,6 +26,7 @@ import (
 	"github.com/rclone/rclone/fs/config/obscure"
 	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/vfs/vfsflags"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/net/webdav"
@@ -58,7 +59,8 @@ func TestWebDav(t *testing.T) {
 		hashType = hash.MD5
 
 		// Start the server
-		w := newWebDAV(context.Background(), f, &opt)
+		w, err := newWebDAV(context.Background(), f, &opt, &vfsflags.Opt)
+		require.NoError(t, err)
 		assert.NoError(t, w.serve())
 
 		// Config for the backend we'll use to connect to the server
@@ -103,7 +105,8 @@ func TestHTTPFunction(t *testing.T) {
 	opt.Template = testTemplate
 
 	// Start the server
-	w := newWebDAV(context.Background(), f, &opt)
+	w, err := newWebDAV(context.Background(), f, &opt, &vfsflags.Opt)
+	require.NoError(t, err)
 	assert.NoError(t, w.serve())
 	defer func() {
 		w.Close()
@@ -2,8 +2,15 @@ package sync
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fs/sync"
@@ -12,12 +19,46 @@ import (
 
 var (
 	createEmptySrcDirs = false
+	outputFormat       = "text"
+	watch              = false
+	watchDelay         = 5 * time.Second
+	watchPollInterval  = time.Minute
 )
 
 func init() {
 	cmd.Root.AddCommand(commandDefinition)
 	cmdFlags := commandDefinition.Flags()
 	flags.BoolVarP(cmdFlags, &createEmptySrcDirs, "create-empty-src-dirs", "", createEmptySrcDirs, "Create empty source dirs on destination after sync")
+	flags.StringVarP(cmdFlags, &outputFormat, "output-format", "", outputFormat, "Format to report what --dry-run would do text|json")
+	flags.BoolVarP(cmdFlags, &watch, "watch", "", watch, "Keep running and sync the changes to the source as they happen")
+	flags.DurationVarP(cmdFlags, &watchDelay, "watch-delay", "", watchDelay, "Wait for no changes for this long before syncing them with --watch")
+	flags.DurationVarP(cmdFlags, &watchPollInterval, "watch-poll-interval", "", watchPollInterval, "Time to wait between checking the source for changes with --watch")
+}
+
+// withDryRunReport runs fn, then if --output-format json is set
+// writes the actions skipped by --dry-run to stdout, one JSON object
+// per line
+func withDryRunReport(fn func(ctx context.Context) error) error {
+	ctx := context.Background()
+	switch strings.ToLower(outputFormat) {
+	case "", "text":
+		return fn(ctx)
+	case "json":
+	default:
+		return fmt.Errorf("unknown --output-format %q: must be text or json", outputFormat)
+	}
+	if !fs.GetConfig(ctx).DryRun {
+		return errors.New("--output-format json can only be used with --dry-run")
+	}
+	ctx, recorder := fs.WithDryRunRecorder(ctx)
+	err := fn(ctx)
+	enc := json.NewEncoder(os.Stdout)
+	for _, action := range recorder.Actions() {
+		if encErr := enc.Encode(action); encErr != nil && err == nil {
+			err = fmt.Errorf("failed to write --dry-run report: %w", encErr)
+		}
+	}
+	return err
 }
 
 var commandDefinition = &cobra.Command{
@@ -49,6 +90,26 @@ go there.
 
 **Note**: Use the ` + "`-P`" + `/` + "`--progress`" + ` flag to view real-time transfer statistics
 
+Use ` + "`--dry-run --output-format json`" + ` to get what the sync would do
+as one JSON object per line on stdout, e.g.
+
+    {"action":"copy","name":"file.txt","size":1234}
+
+which is easier for scripts and CI pipelines to read than the log.
+
+Use ` + "`--watch`" + ` to keep running after the sync and sync the changes to
+the source as they happen. Only the directories with changes in are
+synced, so this is much quicker than running the sync again. The
+changes are synced once there have been none for ` + "`--watch-delay`" + `.
+
+Sources which support change notifications (e.g. Google Drive,
+Dropbox and OneDrive) are asked for their changes every
+` + "`--watch-poll-interval`" + `. Other sources, including the local disk,
+are listed every ` + "`--watch-poll-interval`" + ` to find the changes.
+
+Errors syncing the changes are logged and the watch carries on. Use
+Ctrl-C to stop it. ` + "`--watch`" + ` can't be used with ` + "`--max-depth`" + `.
+
 **Note**: Use the ` + "`rclone dedupe`" + ` command to deal with "Duplicate object/directory found in source/destination - ignoring" errors.
 See [this forum post](https://forum.rclone.org/t/sync-not-clearing-duplicates/14372) for more info.
 `,
@@ -56,10 +117,18 @@ See [this forum post](https://forum.rclone.org/t/sync-not-clearing-duplicates/14
 		cmd.CheckArgs(2, 2, command, args)
 		fsrc, srcFileName, fdst := cmd.NewFsSrcFileDst(args)
 		cmd.Run(true, true, command, func() error {
-			if srcFileName == "" {
-				return sync.Sync(context.Background(), fdst, fsrc, createEmptySrcDirs)
-			}
-			return operations.CopyFile(context.Background(), fdst, fsrc, srcFileName, srcFileName)
+			return withDryRunReport(func(ctx context.Context) error {
+				if srcFileName == "" {
+					if watch {
+						return sync.Watch(ctx, fdst, fsrc, createEmptySrcDirs, watchDelay, watchPollInterval)
+					}
+					return sync.Sync(ctx, fdst, fsrc, createEmptySrcDirs)
+				}
+				if watch {
+					return errors.New("can't use --watch when the source is a file")
+				}
+				return operations.CopyFile(ctx, fdst, fsrc, srcFileName, srcFileName)
+			})
 		})
 	},
 }
@@ -0,0 +1,70 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rclone/rclone/cmd"
+	"github.com/rclone/rclone/cmd/vfscache"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/rclone/rclone/vfs/vfsflags"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	vfscache.Command.AddCommand(commandDefinition)
+	vfsflags.AddFlags(commandDefinition.Flags())
+}
+
+var commandDefinition = &cobra.Command{
+	Use:   "check remote:path",
+	Short: `Check the VFS cache for remote:path for corruption.`,
+	Long: `
+Check the on disk VFS cache for remote:path.
+
+This checks the metadata of each item in the cache against the cached
+data and, for completely downloaded files, the hash of the cached data
+against the remote where the two have a hash in common. Corrupted
+items and files in the cache which don't belong to any item are
+removed and the space reclaimed is reported.
+
+Use the same remote:path and VFS flags, in particular
+--vfs-cache-mode and --cache-dir, as the mount or serve command which
+uses the cache. Use --dry-run to report problems without removing
+anything.
+
+**NB** Don't run this while a mount or serve of the same remote:path
+is running as they will both be using the cache. Use the
+vfs/checkcache remote control call to check the cache of a running
+rclone instead.
+`,
+	Run: func(command *cobra.Command, args []string) {
+		cmd.CheckArgs(1, 1, command, args)
+		fsrc := cmd.NewFsSrc(args)
+		cmd.Run(false, false, command, func() error {
+			return checkCache(context.Background(), fsrc)
+		})
+	},
+}
+
+// checkCache checks the VFS cache for f
+func checkCache(ctx context.Context, f fs.Fs) error {
+	if vfsflags.Opt.CacheMode == vfscommon.CacheModeOff {
+		return errors.New("need --vfs-cache-mode to find the cache to check")
+	}
+	VFS := vfs.New(f, &vfsflags.Opt)
+	defer VFS.Shutdown()
+	res, err := VFS.CheckCache(ctx)
+	if err != nil {
+		return err
+	}
+	for _, problem := range res.Problems {
+		fmt.Println(problem)
+	}
+	fmt.Printf("Checked %d items, found %d problems, removed %d entries, %s reclaimable\n",
+		res.Checked, len(res.Problems), res.Removed, fs.SizeSuffix(res.Reclaimable).ByteUnit())
+	return nil
+}
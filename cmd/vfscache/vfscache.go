@@ -0,0 +1,25 @@
+package vfscache
+
+import (
+	"github.com/rclone/rclone/cmd"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	cmd.Root.AddCommand(Command)
+}
+
+// Command definition for cobra
+var Command = &cobra.Command{
+	Use:   "vfs-cache <subcommand>",
+	Short: `Manage the VFS cache used by mount and serve.`,
+	Long: `Rclone vfs-cache is used to manage the on disk VFS cache used by
+rclone mount and rclone serve with --vfs-cache-mode.
+
+Select which command you want with the subcommand, eg
+
+    rclone vfs-cache check remote:
+
+Each subcommand has its own options which you can see in their help.
+`,
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/asyncreader"
 	"github.com/rclone/rclone/fs/fserrors"
+	"golang.org/x/time/rate"
 )
 
 // ErrorMaxTransferLimitReached defines error when transfer limit is reached.
@@ -61,7 +62,8 @@ type Account struct {
 	exit    chan struct{} // channel that will be closed when transfer is finished
 	withBuf bool          // is using a buffered in
 
-	tokenBucket buckets // per file bandwidth limiter (may be nil)
+	tokenBucket    buckets         // per file bandwidth limiter (may be nil)
+	remoteLimiters []*rate.Limiter // per remote bandwidth limiters
 
 	values accountValues
 }
@@ -326,6 +328,7 @@ func (acc *Account) accountRead(n int) {
 
 	TokenBucket.LimitBandwidth(TokenBucketSlotAccounting, n)
 	acc.limitPerFileBandwidth(n)
+	acc.limitRemoteBandwidth(n)
 }
 
 // read bytes from the io.Reader passed in and account them
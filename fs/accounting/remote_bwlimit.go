@@ -0,0 +1,120 @@
+package accounting
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"golang.org/x/time/rate"
+)
+
+// remoteTokenBuckets holds the bandwidth limiters for each remote
+// which are shared by all the transfers to and from it
+var remoteTokenBuckets = struct {
+	mu      sync.Mutex
+	buckets map[string]*buckets // by remote name - nil if not limited
+}{
+	buckets: make(map[string]*buckets),
+}
+
+// RemoteBwLimit returns the bandwidth limit for the remote called
+// name set with --bwlimit-remote, or if not set there by bwlimit in
+// its config section.
+//
+// The limit is unset if the remote isn't limited.
+func RemoteBwLimit(ctx context.Context, name string) (limit fs.BwPair, err error) {
+	ci := fs.GetConfig(ctx)
+	// Remove the suffix added for overridden config
+	if i := strings.IndexRune(name, '{'); i >= 0 {
+		name = name[:i]
+	}
+	value, found := "", false
+	for _, item := range ci.BwLimitRemote {
+		i := strings.IndexRune(item, '=')
+		if i < 0 {
+			return limit, fmt.Errorf("bad --bwlimit-remote %q: expecting remote:=BANDWIDTH", item)
+		}
+		if strings.TrimSuffix(item[:i], ":") == name {
+			value, found = item[i+1:], true
+		}
+	}
+	if !found {
+		value, found = fs.ConfigFileGet(name, "bwlimit")
+	}
+	if !found || value == "" {
+		return limit, nil
+	}
+	err = limit.Set(value)
+	if err != nil {
+		return limit, fmt.Errorf("bad bandwidth limit for remote %q: %w", name, err)
+	}
+	return limit, nil
+}
+
+// getRemoteTokenBuckets returns the bandwidth limiters for the remote
+// called name or nil if it isn't limited
+func getRemoteTokenBuckets(ctx context.Context, name string) *buckets {
+	remoteTokenBuckets.mu.Lock()
+	defer remoteTokenBuckets.mu.Unlock()
+	tbs, found := remoteTokenBuckets.buckets[name]
+	if found {
+		return tbs
+	}
+	limit, err := RemoteBwLimit(ctx, name)
+	if err != nil {
+		fs.Errorf(nil, "Ignoring bandwidth limit: %v", err)
+	} else if limit.IsSet() {
+		newTbs := newTokenBucket(limit)
+		tbs = &newTbs
+		fs.Infof(name, "Limiting bandwidth of remote to %v", &limit)
+	}
+	remoteTokenBuckets.buckets[name] = tbs
+	return tbs
+}
+
+// LimitRemotes limits the bandwidth of the transfer to the limits of
+// the remote src it is read from and the remote dst it is written to.
+//
+// Either may be nil.
+func (acc *Account) LimitRemotes(src, dst fs.Info) *Account {
+	var limiters []*rate.Limiter
+	if src != nil {
+		if tbs := getRemoteTokenBuckets(acc.ctx, src.Name()); tbs != nil && tbs[TokenBucketSlotTransportRx] != nil {
+			limiters = append(limiters, tbs[TokenBucketSlotTransportRx])
+		}
+	}
+	if dst != nil {
+		if tbs := getRemoteTokenBuckets(acc.ctx, dst.Name()); tbs != nil && tbs[TokenBucketSlotTransportTx] != nil {
+			limiters = append(limiters, tbs[TokenBucketSlotTransportTx])
+		}
+	}
+	acc.values.mu.Lock()
+	acc.remoteLimiters = limiters
+	acc.values.mu.Unlock()
+	return acc
+}
+
+// Account for n bytes from the bandwidth limits of the remotes (if any)
+func (acc *Account) limitRemoteBandwidth(n int) {
+	acc.values.mu.Lock()
+	limiters := acc.remoteLimiters
+	acc.values.mu.Unlock()
+
+	for _, limiter := range limiters {
+		// Wait for at most a burst at a time as n may be a whole chunk
+		for left := n; left > 0; {
+			i := left
+			if burst := limiter.Burst(); i > burst {
+				i = burst
+			}
+			err := limiter.WaitN(context.Background(), i)
+			if err != nil {
+				fs.Errorf(nil, "Token bucket error: %v", err)
+				break
+			}
+			left -= i
+		}
+	}
+}
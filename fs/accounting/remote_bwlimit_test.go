@@ -0,0 +1,91 @@
+package accounting
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func TestRemoteBwLimit(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	ci.BwLimitRemote = []string{"a:=1M", "b=2M:3M", "bad:=potato"}
+
+	oldConfigFileGet := fs.ConfigFileGet
+	fs.ConfigFileGet = func(section, key string) (string, bool) {
+		if key == "bwlimit" && (section == "a" || section == "c") {
+			return "4M", true
+		}
+		return "", false
+	}
+	defer func() {
+		fs.ConfigFileGet = oldConfigFileGet
+	}()
+
+	for _, test := range []struct {
+		name string
+		want fs.BwPair
+		err  bool
+	}{
+		{name: "a", want: fs.BwPair{Tx: 1 << 20, Rx: 1 << 20}},
+		{name: "a{12345}", want: fs.BwPair{Tx: 1 << 20, Rx: 1 << 20}},
+		{name: "b", want: fs.BwPair{Tx: 2 << 20, Rx: 3 << 20}},
+		{name: "c", want: fs.BwPair{Tx: 4 << 20, Rx: 4 << 20}},
+		{name: "d", want: fs.BwPair{}},
+		{name: "bad", err: true},
+	} {
+		got, err := RemoteBwLimit(ctx, test.name)
+		if test.err {
+			assert.Error(t, err, test.name)
+			continue
+		}
+		require.NoError(t, err, test.name)
+		assert.Equal(t, test.want, got, test.name)
+	}
+
+	ci.BwLimitRemote = []string{"no equals"}
+	_, err := RemoteBwLimit(ctx, "a")
+	assert.Error(t, err)
+}
+
+func TestAccountLimitRemotes(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	ci.BwLimitRemote = []string{"limitsrc:=1G:2G", "limitdst:=3G:4G"}
+
+	in := ioutil.NopCloser(bytes.NewBuffer([]byte{1}))
+	stats := NewStats(ctx)
+	acc := newAccountSizeName(ctx, stats, in, 1, "test")
+	defer acc.Done()
+
+	acc.LimitRemotes(nil, nil)
+	assert.Equal(t, 0, len(acc.remoteLimiters))
+
+	// Unlimited remotes don't add limiters
+	acc.LimitRemotes(mockfs.NewFs(ctx, "unlimited", ""), nil)
+	assert.Equal(t, 0, len(acc.remoteLimiters))
+
+	// Reading from src uses its download limit and writing to dst
+	// uses its upload limit
+	acc.LimitRemotes(mockfs.NewFs(ctx, "limitsrc", ""), mockfs.NewFs(ctx, "limitdst", ""))
+	require.Equal(t, 2, len(acc.remoteLimiters))
+	assert.Equal(t, rate.Limit(2<<30), acc.remoteLimiters[0].Limit())
+	assert.Equal(t, rate.Limit(3<<30), acc.remoteLimiters[1].Limit())
+
+	// The limiters are shared between transfers
+	acc2 := newAccountSizeName(ctx, stats, in, 1, "test2")
+	defer acc2.Done()
+	acc2.LimitRemotes(mockfs.NewFs(ctx, "limitsrc", ""), nil)
+	require.Equal(t, 1, len(acc2.remoteLimiters))
+	assert.True(t, acc.remoteLimiters[0] == acc2.remoteLimiters[0])
+
+	// Check reading more than a burst doesn't fail
+	acc.limitRemoteBandwidth(3 * acc.remoteLimiters[0].Burst())
+}
@@ -58,11 +58,20 @@ func rcRemoteStats(ctx context.Context, in rc.Params) (rc.Params, error) {
 	if rc.NotErrParamNotFound(err) {
 		return rc.Params{}, err
 	}
-	if group != "" {
-		return StatsGroup(ctx, group).RemoteStats()
+	wait, err := in.GetDuration("wait")
+	if rc.NotErrParamNotFound(err) {
+		return rc.Params{}, err
 	}
-
-	return groups.sum(ctx).RemoteStats()
+	since, err := in.GetInt64("since")
+	if rc.NotErrParamNotFound(err) {
+		return rc.Params{}, err
+	}
+	return waitStats(ctx, group, since, wait, func() (rc.Params, error) {
+		if group != "" {
+			return StatsGroup(ctx, group).RemoteStats()
+		}
+		return groups.sum(ctx).RemoteStats()
+	})
 }
 
 func init() {
@@ -81,6 +90,15 @@ returned.
 Parameters
 
 - group - name of the stats group (string)
+- since - seq returned by a previous call (integer)
+- wait - how long to wait for the stats to change from since, e.g. "30s" (duration)
+
+If wait is set and the stats haven't changed since the call which
+returned seq=since then the call waits until they change or wait
+passes before returning. This can be used to follow the stats without
+polling, by passing the seq from each call as since for the next.
+
+	rclone rc core/stats wait=30s since=42
 
 Returns the following values:
 
@@ -96,6 +114,7 @@ Returns the following values:
 	"lastError": last error string,
 	"renames" : number of files renamed,
 	"retryError": boolean showing whether there has been at least one non-NoRetryError,
+	"seq": sequence number which goes up when the stats change,
 	"speed": average speed in bytes per second since start of the group,
 	"totalBytes": total number of bytes in the group,
 	"totalChecks": total number of checks in the group,
@@ -418,6 +437,7 @@ func (sg *statsGroups) delete(group string) {
 	stats.ResetErrors()
 	stats.ResetCounters()
 	delete(sg.m, group)
+	watcher.forget(group)
 
 	// Remove group reference from the ordering slice.
 	tmp := sg.order[:0]
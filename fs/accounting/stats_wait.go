@@ -0,0 +1,121 @@
+package accounting
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// statsPollInterval is how often core/stats checks the stats for
+// changes while waiting
+var statsPollInterval = 250 * time.Millisecond
+
+// statsSeq is the sequence number of the stats of a group
+type statsSeq struct {
+	seq int64  // goes up by one each time the stats are seen to change
+	key string // statsChangeKey of the stats when last seen
+}
+
+// statsWatcher keeps the sequence numbers of the stats of each group
+// for core/stats to wait for changes
+type statsWatcher struct {
+	mu   sync.Mutex
+	seqs map[string]*statsSeq // by group - "" for the sum of all groups
+}
+
+var watcher = &statsWatcher{
+	seqs: make(map[string]*statsSeq),
+}
+
+// statsChangeKey returns a string which changes when the stats in out
+// change ignoring the values which change with time alone
+func statsChangeKey(out rc.Params) string {
+	key := make(rc.Params, len(out))
+	for k, v := range out {
+		switch k {
+		case "elapsedTime", "transferTime", "speed", "eta":
+			continue
+		case "transferring":
+			if transferring, ok := v.([]rc.Params); ok {
+				var ts []rc.Params
+				for _, t := range transferring {
+					ts = append(ts, rc.Params{
+						"name":  t["name"],
+						"bytes": t["bytes"],
+						"size":  t["size"],
+					})
+				}
+				v = ts
+			}
+		}
+		key[k] = v
+	}
+	buf, err := json.Marshal(key)
+	if err != nil {
+		return ""
+	}
+	return string(buf)
+}
+
+// observe records out as the current stats of group returning their
+// sequence number
+func (w *statsWatcher) observe(group string, out rc.Params) int64 {
+	key := statsChangeKey(out)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s := w.seqs[group]
+	if s == nil {
+		s = &statsSeq{seq: 1, key: key}
+		w.seqs[group] = s
+	} else if s.key != key {
+		s.seq++
+		s.key = key
+	}
+	return s.seq
+}
+
+// forget removes the sequence number of group
+func (w *statsWatcher) forget(group string) {
+	w.mu.Lock()
+	delete(w.seqs, group)
+	w.mu.Unlock()
+}
+
+// waitStats calls getStats to read the stats of group until their
+// sequence number is different to since or wait has passed, returning
+// the last stats read with "seq" set to their sequence number.
+//
+// If wait is 0 it returns straight away.
+func waitStats(ctx context.Context, group string, since int64, wait time.Duration, getStats func() (rc.Params, error)) (out rc.Params, err error) {
+	out, err = getStats()
+	if err != nil {
+		return nil, err
+	}
+	seq := watcher.observe(group, out)
+	if wait > 0 && seq == since {
+		timeout := time.NewTimer(wait)
+		defer timeout.Stop()
+		ticker := time.NewTicker(statsPollInterval)
+		defer ticker.Stop()
+		timedOut := false
+		for seq == since && !timedOut {
+			select {
+			case <-ticker.C:
+			case <-timeout.C:
+				timedOut = true
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			out, err = getStats()
+			if err != nil {
+				return nil, err
+			}
+			seq = watcher.observe(group, out)
+		}
+	}
+	out["seq"] = seq
+	return out, nil
+}
@@ -0,0 +1,67 @@
+package accounting
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsChangeKey(t *testing.T) {
+	a := rc.Params{"bytes": 1, "elapsedTime": 1.0, "speed": 2.0, "transferring": []rc.Params{{"name": "a", "bytes": 1, "speed": 3.0}}}
+	b := rc.Params{"bytes": 1, "elapsedTime": 2.0, "speed": 4.0, "transferring": []rc.Params{{"name": "a", "bytes": 1, "speed": 5.0}}}
+	c := rc.Params{"bytes": 2, "elapsedTime": 2.0, "speed": 4.0, "transferring": []rc.Params{{"name": "a", "bytes": 1, "speed": 5.0}}}
+	d := rc.Params{"bytes": 1, "elapsedTime": 2.0, "speed": 4.0, "transferring": []rc.Params{{"name": "a", "bytes": 2, "speed": 5.0}}}
+	assert.Equal(t, statsChangeKey(a), statsChangeKey(b))
+	assert.NotEqual(t, statsChangeKey(a), statsChangeKey(c))
+	assert.NotEqual(t, statsChangeKey(a), statsChangeKey(d))
+}
+
+func TestRcStatsWait(t *testing.T) {
+	ctx := context.Background()
+	oldInterval := statsPollInterval
+	statsPollInterval = time.Millisecond
+	defer func() { statsPollInterval = oldInterval }()
+	const group = "test-stats-wait"
+	defer groups.delete(group)
+	call := rc.Calls.Get("core/stats")
+	require.NotNil(t, call)
+
+	// Get the current sequence number
+	out, err := call.Fn(ctx, rc.Params{"group": group})
+	require.NoError(t, err)
+	seq := out["seq"].(int64)
+
+	// Unchanged stats return the same seq after the wait
+	start := time.Now()
+	out, err = call.Fn(ctx, rc.Params{"group": group, "since": seq, "wait": "50ms"})
+	require.NoError(t, err)
+	assert.Equal(t, seq, out["seq"])
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	// A different since returns straight away
+	start = time.Now()
+	out, err = call.Fn(ctx, rc.Params{"group": group, "since": seq - 1, "wait": "1h"})
+	require.NoError(t, err)
+	assert.Equal(t, seq, out["seq"])
+	assert.True(t, time.Since(start) < time.Minute)
+
+	// A change in the stats ends the wait
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		StatsGroup(ctx, group).Bytes(42)
+	}()
+	out, err = call.Fn(ctx, rc.Params{"group": group, "since": seq, "wait": "1m"})
+	require.NoError(t, err)
+	assert.Equal(t, seq+1, out["seq"])
+	assert.Equal(t, int64(42), out["bytes"])
+
+	// Bad parameters
+	_, err = call.Fn(ctx, rc.Params{"group": group, "wait": "potato"})
+	assert.Error(t, err)
+	_, err = call.Fn(ctx, rc.Params{"group": group, "since": "potato"})
+	assert.Error(t, err)
+}
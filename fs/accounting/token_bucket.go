@@ -36,6 +36,8 @@ type tokenBucket struct {
 	toggledOff  bool
 	currLimitMu sync.Mutex // protects changes to the timeslot
 	currLimit   fs.BwTimeSlot
+	timetable   fs.BwTimetable // schedule of bandwidth limits
+	ticking     bool           // set if the ticker is running
 }
 
 // Return true if limit is disabled
@@ -116,53 +118,88 @@ func (tb *tokenBucket) StartTokenBucket(ctx context.Context) {
 // StartTokenTicker creates a ticker to update the bandwidth limiter every minute.
 func (tb *tokenBucket) StartTokenTicker(ctx context.Context) {
 	ci := fs.GetConfig(ctx)
+	tb.currLimitMu.Lock()
+	defer tb.currLimitMu.Unlock()
+	tb.timetable = ci.BwLimit
+	tb._startTicker()
+}
+
+// _startTicker starts the ticker which updates the bandwidth limiter
+// from the timetable if it isn't running and is needed.
+//
+// Call with currLimitMu held
+func (tb *tokenBucket) _startTicker() {
 	// If the timetable has a single entry or was not specified, we don't need
 	// a ticker to update the bandwidth.
-	if len(ci.BwLimit) <= 1 {
+	if tb.ticking || len(tb.timetable) <= 1 {
 		return
 	}
+	tb.ticking = true
 
 	ticker := time.NewTicker(time.Minute)
 	go func() {
 		for range ticker.C {
-			limitNow := ci.BwLimit.LimitAt(time.Now())
 			tb.currLimitMu.Lock()
-
+			limitNow := tb.timetable.LimitAt(time.Now())
 			if tb.currLimit.Bandwidth != limitNow.Bandwidth {
-				tb.mu.Lock()
-
-				// If bwlimit is toggled off, the change should only
-				// become active on the next toggle, which causes
-				// an exchange of tb.curr <-> tb.prev
-				var targetBucket *buckets
-				if tb.toggledOff {
-					targetBucket = &tb.prev
-				} else {
-					targetBucket = &tb.curr
-				}
-
-				// Set new bandwidth. If unlimited, set tokenbucket to nil.
-				if limitNow.Bandwidth.IsSet() {
-					*targetBucket = newTokenBucket(limitNow.Bandwidth)
-					if tb.toggledOff {
-						fs.Logf(nil, "Scheduled bandwidth change. "+
-							"Limit will be set to %v Byte/s when toggled on again.", &limitNow.Bandwidth)
-					} else {
-						fs.Logf(nil, "Scheduled bandwidth change. Limit set to %v Byte/s", &limitNow.Bandwidth)
-					}
-				} else {
-					targetBucket._setOff()
-					fs.Logf(nil, "Scheduled bandwidth change. Bandwidth limits disabled")
-				}
-
-				tb.currLimit = limitNow
-				tb.mu.Unlock()
+				tb._setLimit(limitNow)
 			}
 			tb.currLimitMu.Unlock()
 		}
 	}()
 }
 
+// _setLimit sets the bandwidth limit to the time slot given
+//
+// Call with currLimitMu held
+func (tb *tokenBucket) _setLimit(limitNow fs.BwTimeSlot) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	// If bwlimit is toggled off, the change should only
+	// become active on the next toggle, which causes
+	// an exchange of tb.curr <-> tb.prev
+	var targetBucket *buckets
+	if tb.toggledOff {
+		targetBucket = &tb.prev
+	} else {
+		targetBucket = &tb.curr
+	}
+
+	// Set new bandwidth. If unlimited, set tokenbucket to nil.
+	if limitNow.Bandwidth.IsSet() {
+		*targetBucket = newTokenBucket(limitNow.Bandwidth)
+		if tb.toggledOff {
+			fs.Logf(nil, "Scheduled bandwidth change. "+
+				"Limit will be set to %v Byte/s when toggled on again.", &limitNow.Bandwidth)
+		} else {
+			fs.Logf(nil, "Scheduled bandwidth change. Limit set to %v Byte/s", &limitNow.Bandwidth)
+		}
+	} else {
+		targetBucket._setOff()
+		fs.Logf(nil, "Scheduled bandwidth change. Bandwidth limits disabled")
+	}
+
+	tb.currLimit = limitNow
+}
+
+// SetBwTimetable replaces the bandwidth timetable and sets the
+// bandwidth limit to the entry for the current time.
+func (tb *tokenBucket) SetBwTimetable(timetable fs.BwTimetable) {
+	tb.currLimitMu.Lock()
+	defer tb.currLimitMu.Unlock()
+	tb.timetable = timetable
+	tb._setLimit(timetable.LimitAt(time.Now()))
+	tb._startTicker()
+}
+
+// GetBwTimetable returns the bandwidth timetable in use
+func (tb *tokenBucket) GetBwTimetable() fs.BwTimetable {
+	tb.currLimitMu.Lock()
+	defer tb.currLimitMu.Unlock()
+	return tb.timetable
+}
+
 // LimitBandwidth sleeps for the correct amount of time for the passage
 // of n bytes according to the current bandwidth limit
 func (tb *tokenBucket) LimitBandwidth(i TokenBucketSlot, n int) {
@@ -284,6 +321,89 @@ except only one bandwidth may be specified.
 
 In either case "rate" is returned as a human-readable string, and
 "bytesPerSecond" is returned as a number.
+
+If a bandwidth schedule is in use the limit set here lasts until the
+next change in the schedule. Use core/bwschedule to change the
+schedule.
+`,
+	})
+}
+
+// read and set the bandwidth timetable
+func (tb *tokenBucket) rcBwschedule(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	schedule, err := in.GetString("schedule")
+	if err == nil {
+		var timetable fs.BwTimetable
+		err = timetable.Set(schedule)
+		if err != nil {
+			return out, fmt.Errorf("bad bwlimit schedule: %w", err)
+		}
+		tb.SetBwTimetable(timetable)
+	} else if rc.NotErrParamNotFound(err) {
+		return out, err
+	}
+	timetable := tb.GetBwTimetable()
+	slots := []rc.Params{}
+	for _, slot := range timetable {
+		slots = append(slots, rc.Params{
+			"day":  time.Weekday(slot.DayOfTheWeek).String(),
+			"time": fmt.Sprintf("%02d:%02d", slot.HHMM/100, slot.HHMM%100),
+			"rate": slot.Bandwidth.String(),
+		})
+	}
+	limitNow := timetable.LimitAt(time.Now())
+	out = rc.Params{
+		"schedule": timetable.String(),
+		"slots":    slots,
+		"rate":     limitNow.Bandwidth.String(),
+	}
+	return out, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path: "core/bwschedule",
+		Fn: func(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+			return TokenBucket.rcBwschedule(ctx, in)
+		},
+		Title: "Read or set the bandwidth limit schedule.",
+		Parameters: []rc.Parameter{
+			{Name: "schedule", Type: "string", Help: "the new bandwidth schedule, e.g. \"08:00,512k 19:00,off\""},
+		},
+		Help: `
+This replaces the bandwidth limit schedule with the one passed in and
+sets the bandwidth limit to the one scheduled for the current time.
+
+The format of the schedule is exactly the same as passed to --bwlimit
+so it can be a single bandwidth limit or a timetable, e.g.
+
+    rclone rc core/bwschedule schedule="08:00,512k 19:00,off"
+
+If the schedule parameter is not supplied then the schedule is
+queried
+
+    rclone rc core/bwschedule
+    {
+        "rate": "512Ki",
+        "schedule": "Sun-08:00,512Ki Sun-19:00,off Mon-08:00,512Ki ...",
+        "slots": [
+            {
+                "day": "Sunday",
+                "rate": "512Ki",
+                "time": "08:00"
+            },
+            ...
+        ]
+    }
+
+In either case the following are returned
+
+- schedule - the whole schedule as a string
+- slots - array of the entries in the schedule with day, time and rate
+- rate - the bandwidth limit scheduled for the current time
+
+This doesn't change the per file bandwidth limit set with
+--bwlimit-file.
 `,
 	})
 }
@@ -3,6 +3,7 @@ package accounting
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/rclone/rclone/fs/rc"
 	"github.com/stretchr/testify/assert"
@@ -90,3 +91,49 @@ func TestRcBwLimit(t *testing.T) {
 	}, out)
 
 }
+
+func TestRcBwSchedule(t *testing.T) {
+	call := rc.Calls.Get("core/bwschedule")
+	assert.NotNil(t, call)
+	defer TokenBucket.SetBwTimetable(nil)
+
+	// Set a single limit
+	out, err := call.Fn(context.Background(), rc.Params{
+		"schedule": "1M",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "1Mi", out["schedule"])
+	assert.Equal(t, "1Mi", out["rate"])
+	assert.Equal(t, []rc.Params{{"day": "Sunday", "time": "00:00", "rate": "1Mi"}}, out["slots"])
+	assert.Equal(t, rate.Limit(1048576), TokenBucket.curr[0].Limit())
+
+	// Set a timetable with the same limit all day
+	out, err = call.Fn(context.Background(), rc.Params{
+		"schedule": "Mon-00:00,2M:1M Tue-00:00,off",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Mon-00:00,2Mi:1Mi Tue-00:00,off", out["schedule"])
+	slots := out["slots"].([]rc.Params)
+	require.Equal(t, 2, len(slots))
+	assert.Equal(t, rc.Params{"day": "Monday", "time": "00:00", "rate": "2Mi:1Mi"}, slots[0])
+	assert.Equal(t, rc.Params{"day": "Tuesday", "time": "00:00", "rate": "off"}, slots[1])
+	if time.Now().Weekday() == time.Monday {
+		assert.Equal(t, "2Mi:1Mi", out["rate"])
+		assert.Equal(t, rate.Limit(2097152), TokenBucket.curr[TokenBucketSlotTransportTx].Limit())
+	} else {
+		assert.Equal(t, "off", out["rate"])
+		assert.Nil(t, TokenBucket.curr[0])
+	}
+
+	// Query
+	out2, err := call.Fn(context.Background(), rc.Params{})
+	require.NoError(t, err)
+	assert.Equal(t, out, out2)
+
+	// Bad schedule
+	_, err = call.Fn(context.Background(), rc.Params{
+		"schedule": "potato",
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "Mon-00:00,2Mi:1Mi Tue-00:00,off", TokenBucket.GetBwTimetable().String())
+}
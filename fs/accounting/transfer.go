@@ -9,6 +9,7 @@ import (
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/rc/events"
 )
 
 // TransferSnapshot represents state of an account at point in time.
@@ -119,10 +120,26 @@ func (tr *Transfer) Done(ctx context.Context, err error) {
 		tr.stats.DoneChecking(tr.remote)
 	} else {
 		tr.stats.DoneTransferring(tr.remote, err == nil)
+		tr.publishDone(err)
 	}
 	tr.stats.PruneTransfers()
 }
 
+// publishDone publishes an event saying the transfer has completed
+func (tr *Transfer) publishDone(err error) {
+	snapshot := tr.Snapshot()
+	data := rc.Params{
+		"name":  snapshot.Name,
+		"size":  snapshot.Size,
+		"bytes": snapshot.Bytes,
+		"group": snapshot.Group,
+	}
+	if err != nil {
+		data["error"] = err.Error()
+	}
+	events.Publish(events.TypeTransfer, data)
+}
+
 // Reset allows to switch the Account to another transfer method.
 func (tr *Transfer) Reset(ctx context.Context) {
 	tr.mu.RLock()
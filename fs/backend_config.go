@@ -74,12 +74,14 @@ type ConfigIn struct {
 // OAuth is a special value set by oauthutil.ConfigOAuth
 // Error is displayed to the user before asking a question
 // Result is passed to the next call to Config if Option/OAuth isn't set
+// OAuthURL is set by oauthutil.ConfigOAuth when using ConfigOAuthURL
 type ConfigOut struct {
-	State  string      // State to jump to after this
-	Option *Option     // Option to query user about
-	OAuth  interface{} `json:"-"` // Do OAuth if set
-	Error  string      // error to be displayed to the user
-	Result string      // if Option/OAuth not set then this is passed to the next state
+	State    string      // State to jump to after this
+	Option   *Option     // Option to query user about
+	OAuth    interface{} `json:"-"` // Do OAuth if set
+	Error    string      // error to be displayed to the user
+	Result   string      // if Option/OAuth not set then this is passed to the next state
+	OAuthURL string      `json:",omitempty"` // URL to authorize at if Option is asking for the OAuth code
 }
 
 // ConfigInputOptional asks the user for a string which may be empty
@@ -254,6 +256,24 @@ func isConfigOAuthOnly(ctx context.Context) bool {
 	return ctx.Value(configOAuthKey) != nil
 }
 
+type configOAuthURLKeyType struct{}
+
+// OAuth URL key for config
+var configOAuthURLKey = configOAuthURLKeyType{}
+
+// ConfigOAuthURL marks the ctx so that the OAuth returns the URL for
+// the user to authorize at in ConfigOut.OAuthURL and asks for the
+// redirect it returns to, rather than running a local webserver to
+// receive it.
+func ConfigOAuthURL(ctx context.Context) context.Context {
+	return context.WithValue(ctx, configOAuthURLKey, struct{}{})
+}
+
+// IsConfigOAuthURL returns true if ctx is marked as ConfigOAuthURL
+func IsConfigOAuthURL(ctx context.Context) bool {
+	return ctx.Value(configOAuthURLKey) != nil
+}
+
 // StatePop pops a state from the front of the config string
 // It returns the new state and the value popped
 func StatePop(state string) (newState string, value string) {
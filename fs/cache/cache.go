@@ -156,6 +156,33 @@ func ClearConfig(name string) (deleted int) {
 	return c.DeletePrefix(name + ":")
 }
 
+// Evict removes the Fs for fsString from the cache
+//
+// Returns true if it was found
+func Evict(fsString string) bool {
+	createOnFirstUse()
+	canonicalName := Canonicalize(fsString)
+	found := c.Delete(canonicalName)
+	if canonicalName != fsString && c.Delete(fsString) {
+		found = true
+	}
+	mu.Lock()
+	for name, canonical := range remap {
+		if canonical == canonicalName {
+			delete(remap, name)
+		}
+	}
+	mu.Unlock()
+	return found
+}
+
+// List returns information about the entries in the cache sorted by
+// their name
+func List() []cache.EntryInfo {
+	createOnFirstUse()
+	return c.List()
+}
+
 // Clear removes everything from the cache
 func Clear() {
 	createOnFirstUse()
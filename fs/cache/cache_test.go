@@ -209,3 +209,31 @@ func TestEntries(t *testing.T) {
 
 	assert.Equal(t, 1, Entries())
 }
+
+func TestEvict(t *testing.T) {
+	cleanup, create := mockNewFs(t)
+	defer cleanup()
+
+	_, err := GetFn(context.Background(), "mock:/", create)
+	require.NoError(t, err)
+	assert.Equal(t, 1, Entries())
+
+	assert.False(t, Evict("mock:/potato"))
+	assert.Equal(t, 1, Entries())
+
+	assert.True(t, Evict("mock:/"))
+	assert.Equal(t, 0, Entries())
+}
+
+func TestList(t *testing.T) {
+	cleanup, create := mockNewFs(t)
+	defer cleanup()
+
+	_, err := GetFn(context.Background(), "mock:/", create)
+	require.NoError(t, err)
+
+	list := List()
+	require.Equal(t, 1, len(list))
+	assert.Equal(t, "mock:/", list[0].Key)
+	assert.NoError(t, list[0].Err)
+}
@@ -57,6 +57,7 @@ type ConfigInfo struct {
 	IgnoreErrors           bool
 	ModifyWindow           time.Duration
 	Checkers               int
+	AdaptiveConcurrency    bool // tune the checkers and transfers working at once while running
 	Transfers              int
 	ConnectTimeout         time.Duration // Connect timeout
 	Timeout                time.Duration // Data channel timeout
@@ -64,7 +65,9 @@ type ConfigInfo struct {
 	Dump                   DumpFlags
 	InsecureSkipVerify     bool // Skip server certificate verification
 	DeleteMode             DeleteMode
+	DeleteJournal          string // file to record the deletions sync intends to make before making them
 	MaxDelete              int64
+	MaxDeletePercent       int    // max percentage of destination files to delete or -1 for no limit
 	TrackRenames           bool   // Track file renames.
 	TrackRenamesStrategy   string // Comma separated list of strategies used to track renames
 	LowLevelRetries        int
@@ -75,6 +78,7 @@ type ConfigInfo struct {
 	IgnoreChecksum         bool
 	IgnoreCaseSync         bool
 	NoTraverse             bool
+	TopUp                  bool // only transfer files newer than --max-age without listing the destination
 	CheckFirst             bool
 	NoCheckDest            bool
 	NoUnicodeNormalization bool
@@ -83,12 +87,18 @@ type ConfigInfo struct {
 	CompareDest            []string
 	CopyDest               []string
 	BackupDir              string
+	AppendOnly             bool // refuse to overwrite or delete existing files on the destination
+	StagingDir             string
+	QuarantineDir          string // move deleted files here instead of deleting them
+	QuarantineRuns         int    // delete files from QuarantineDir after they are missing for this many runs
 	Suffix                 string
 	SuffixKeepExtension    bool
 	UseListR               bool
+	ListCutoff             int // max entries of a listing to hold in memory for sync
 	BufferSize             SizeSuffix
 	BwLimit                BwTimetable
 	BwLimitFile            BwTimetable
+	BwLimitRemote          []string // per remote bandwidth limits as remote:=BANDWIDTH
 	TPSLimit               float64
 	TPSLimitBurst          int
 	BindAddr               net.IP
@@ -104,6 +114,8 @@ type ConfigInfo struct {
 	MaxTransfer            SizeSuffix
 	MaxDuration            time.Duration
 	CutoffMode             CutoffMode
+	ConflictResolve        ConflictResolve
+	ServerSideFallback     ServerSideFallback
 	MaxBacklog             int
 	MaxStatsGroups         int
 	StatsOneLine           bool
@@ -112,6 +124,8 @@ type ConfigInfo struct {
 	ErrorOnNoTransfer      bool   // Set appropriate exit code if no files transferred
 	Progress               bool
 	ProgressTerminalTitle  bool
+	ProgressJSON           bool
+	ProgressJSONAddr       string // socket to write --progress-json to instead of stdout
 	Cookie                 bool
 	UseMmap                bool
 	CaCert                 string // Client Side CA
@@ -119,8 +133,15 @@ type ConfigInfo struct {
 	ClientKey              string // Client Side Key
 	MultiThreadCutoff      SizeSuffix
 	MultiThreadStreams     int
-	MultiThreadSet         bool   // whether MultiThreadStreams was set (set in fs/config/configflags)
-	OrderBy                string // instructions on how to order the transfer
+	MultiThreadSet         bool       // whether MultiThreadStreams was set (set in fs/config/configflags)
+	Delta                  bool       // send only the changed blocks of updated files if possible
+	DeltaBlockSize         SizeSuffix // block size for delta transfers or 0 for automatic
+	Resume                 string     // journal of finished files to resume an interrupted sync with
+	ResumeUploads          bool       // keep the state of multi-thread uploads so they can be resumed
+	Metadata               bool       // preserve the metadata of objects when copying them
+	VerifyAfterTransfer    bool       // read back transferred files which can't be checked by hash
+	VerifySampleSize       SizeSuffix // only read back this much of each file in ranges if > 0
+	OrderBy                string     // instructions on how to order the transfer
 	UploadHeaders          []*HTTPOption
 	DownloadHeaders        []*HTTPOption
 	Headers                []*HTTPOption
@@ -151,6 +172,8 @@ func NewConfig() *ConfigInfo {
 	c.ExpectContinueTimeout = 1 * time.Second
 	c.DeleteMode = DeleteModeDefault
 	c.MaxDelete = -1
+	c.MaxDeletePercent = -1
+	c.QuarantineRuns = 3
 	c.LowLevelRetries = 10
 	c.MaxDepth = -1
 	c.DataRateUnit = "bytes"
@@ -163,10 +186,12 @@ func NewConfig() *ConfigInfo {
 	c.TPSLimitBurst = 1
 	c.MaxTransfer = -1
 	c.MaxBacklog = 10000
+	c.ListCutoff = 1000000
 	// We do not want to set the default here. We use this variable being empty as part of the fall-through of options.
 	//	c.StatsOneLineDateFormat = "2006/01/02 15:04:05 - "
 	c.MultiThreadCutoff = SizeSuffix(250 * 1024 * 1024)
 	c.MultiThreadStreams = 4
+	c.VerifySampleSize = -1
 
 	c.TrackRenamesStrategy = "hash"
 	c.FsCacheExpireDuration = 300 * time.Second
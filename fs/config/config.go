@@ -362,6 +362,7 @@ func LoadedData() Storage {
 		} else {
 			log.Fatalf("Failed to load config file %q: %v", configPath, err)
 		}
+		rememberRemotes(data)
 	}
 	return data
 }
@@ -424,6 +425,9 @@ type UpdateRemoteOpt struct {
 	Result string `json:"result"`
 	// If set then edit existing values
 	Edit bool `json:"edit"`
+	// If set then return the URL to do OAuth at and ask for the
+	// redirect rather than waiting for a browser on this machine
+	OAuthURL bool `json:"oauthURL"`
 }
 
 func updateRemote(ctx context.Context, name string, keyValues rc.Params, opt UpdateRemoteOpt) (out *fs.ConfigOut, err error) {
@@ -439,6 +443,9 @@ func updateRemote(ctx context.Context, name string, keyValues rc.Params, opt Upd
 	if interactive && !opt.All {
 		ctx = suppressConfirm(ctx)
 	}
+	if opt.OAuthURL {
+		ctx = fs.ConfigOAuthURL(ctx)
+	}
 
 	fsType := FileGet(name, "type")
 	if fsType == "" {
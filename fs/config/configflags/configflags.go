@@ -4,6 +4,7 @@ package configflags
 
 // Options set by command line flags
 import (
+	"fmt"
 	"log"
 	"net"
 	"os"
@@ -37,6 +38,7 @@ var (
 	uploadHeaders   []string
 	downloadHeaders []string
 	headers         []string
+	maxDelete       string
 )
 
 // AddFlags adds the non filing system specific flags to the command
@@ -48,6 +50,7 @@ func AddFlags(ci *fs.ConfigInfo, flagSet *pflag.FlagSet) {
 	flags.DurationVarP(flagSet, &ci.ModifyWindow, "modify-window", "", ci.ModifyWindow, "Max time diff to be considered the same")
 	flags.IntVarP(flagSet, &ci.Checkers, "checkers", "", ci.Checkers, "Number of checkers to run in parallel")
 	flags.IntVarP(flagSet, &ci.Transfers, "transfers", "", ci.Transfers, "Number of file transfers to run in parallel")
+	flags.BoolVarP(flagSet, &ci.AdaptiveConcurrency, "adaptive-concurrency", "", ci.AdaptiveConcurrency, "Tune the number of checkers and transfers working at once while running")
 	flags.StringVarP(flagSet, &configPath, "config", "", config.GetConfigPath(), "Config file")
 	flags.StringVarP(flagSet, &cacheDir, "cache-dir", "", config.GetCacheDir(), "Directory rclone will use for caching")
 	flags.StringVarP(flagSet, &tempDir, "temp-dir", "", os.TempDir(), "Directory rclone will use for temporary files")
@@ -69,7 +72,8 @@ func AddFlags(ci *fs.ConfigInfo, flagSet *pflag.FlagSet) {
 	flags.BoolVarP(flagSet, &deleteBefore, "delete-before", "", false, "When synchronizing, delete files on destination before transferring")
 	flags.BoolVarP(flagSet, &deleteDuring, "delete-during", "", false, "When synchronizing, delete files during transfer")
 	flags.BoolVarP(flagSet, &deleteAfter, "delete-after", "", false, "When synchronizing, delete files on destination after transferring (default)")
-	flags.Int64VarP(flagSet, &ci.MaxDelete, "max-delete", "", -1, "When synchronizing, limit the number of deletes")
+	flags.StringVarP(flagSet, &ci.DeleteJournal, "delete-journal", "", ci.DeleteJournal, "When synchronizing, record the deletions in this file before making them and only make them if the listings had no errors")
+	flags.StringVarP(flagSet, &maxDelete, "max-delete", "", "", "When synchronizing, limit the number of deletes, or the percentage of destination files deleted if it ends in %")
 	flags.BoolVarP(flagSet, &ci.TrackRenames, "track-renames", "", ci.TrackRenames, "When synchronizing, track file renames and do a server-side move if possible")
 	flags.StringVarP(flagSet, &ci.TrackRenamesStrategy, "track-renames-strategy", "", ci.TrackRenamesStrategy, "Strategies to use when synchronizing using track-renames hash|modtime|leaf")
 	flags.IntVarP(flagSet, &ci.LowLevelRetries, "low-level-retries", "", ci.LowLevelRetries, "Number of low level retries to do")
@@ -81,16 +85,22 @@ func AddFlags(ci *fs.ConfigInfo, flagSet *pflag.FlagSet) {
 	flags.BoolVarP(flagSet, &ci.IgnoreChecksum, "ignore-checksum", "", ci.IgnoreChecksum, "Skip post copy check of checksums")
 	flags.BoolVarP(flagSet, &ci.IgnoreCaseSync, "ignore-case-sync", "", ci.IgnoreCaseSync, "Ignore case when synchronizing")
 	flags.BoolVarP(flagSet, &ci.NoTraverse, "no-traverse", "", ci.NoTraverse, "Don't traverse destination file system on copy")
+	flags.BoolVarP(flagSet, &ci.TopUp, "top-up", "", ci.TopUp, "Quickly copy only the files changed within --max-age, implies --no-traverse")
 	flags.BoolVarP(flagSet, &ci.CheckFirst, "check-first", "", ci.CheckFirst, "Do all the checks before starting transfers")
 	flags.BoolVarP(flagSet, &ci.NoCheckDest, "no-check-dest", "", ci.NoCheckDest, "Don't check the destination, copy regardless")
 	flags.BoolVarP(flagSet, &ci.NoUnicodeNormalization, "no-unicode-normalization", "", ci.NoUnicodeNormalization, "Don't normalize unicode characters in filenames")
 	flags.BoolVarP(flagSet, &ci.NoUpdateModTime, "no-update-modtime", "", ci.NoUpdateModTime, "Don't update destination mod-time if files identical")
-	flags.StringArrayVarP(flagSet, &ci.CompareDest, "compare-dest", "", nil, "Include additional comma separated server-side paths during comparison")
-	flags.StringArrayVarP(flagSet, &ci.CopyDest, "copy-dest", "", nil, "Implies --compare-dest but also copies files from paths into destination")
+	flags.StringArrayVarP(flagSet, &ci.CompareDest, "compare-dest", "", nil, "Include additional server-side paths during comparison (can be repeated)")
+	flags.StringArrayVarP(flagSet, &ci.CopyDest, "copy-dest", "", nil, "Implies --compare-dest but also copies files from paths into destination (can be repeated)")
 	flags.StringVarP(flagSet, &ci.BackupDir, "backup-dir", "", ci.BackupDir, "Make backups into hierarchy based in DIR")
+	flags.BoolVarP(flagSet, &ci.AppendOnly, "append-only", "", ci.AppendOnly, "Refuse to overwrite or delete existing files on the destination")
+	flags.StringVarP(flagSet, &ci.QuarantineDir, "quarantine-dir", "", ci.QuarantineDir, "Move files deleted by sync into DIR and only delete them when missing from the source for --quarantine-runs runs")
+	flags.IntVarP(flagSet, &ci.QuarantineRuns, "quarantine-runs", "", ci.QuarantineRuns, "Number of runs a file must be missing from the source before it is deleted from --quarantine-dir")
+	flags.StringVarP(flagSet, &ci.StagingDir, "staging-dir", "", ci.StagingDir, "Transfer files into DIR then move them into place once all transfers succeed")
 	flags.StringVarP(flagSet, &ci.Suffix, "suffix", "", ci.Suffix, "Suffix to add to changed files")
 	flags.BoolVarP(flagSet, &ci.SuffixKeepExtension, "suffix-keep-extension", "", ci.SuffixKeepExtension, "Preserve the extension when using --suffix")
 	flags.BoolVarP(flagSet, &ci.UseListR, "fast-list", "", ci.UseListR, "Use recursive list if available; uses more memory but fewer transactions")
+	flags.IntVarP(flagSet, &ci.ListCutoff, "list-cutoff", "", ci.ListCutoff, "Sort listings with more than this many entries on disk rather than in memory for sync, using an extra transaction per object")
 	flags.Float64VarP(flagSet, &ci.TPSLimit, "tpslimit", "", ci.TPSLimit, "Limit HTTP transactions per second to this")
 	flags.IntVarP(flagSet, &ci.TPSLimitBurst, "tpslimit-burst", "", ci.TPSLimitBurst, "Max burst of transactions for --tpslimit")
 	flags.StringVarP(flagSet, &bindAddr, "bind", "", "", "Local address to bind to for outgoing connections, IPv4, IPv6 or name")
@@ -103,12 +113,15 @@ func AddFlags(ci *fs.ConfigInfo, flagSet *pflag.FlagSet) {
 	flags.FVarP(flagSet, &ci.StatsLogLevel, "stats-log-level", "", "Log level to show --stats output DEBUG|INFO|NOTICE|ERROR")
 	flags.FVarP(flagSet, &ci.BwLimit, "bwlimit", "", "Bandwidth limit in KiB/s, or use suffix B|K|M|G|T|P or a full timetable")
 	flags.FVarP(flagSet, &ci.BwLimitFile, "bwlimit-file", "", "Bandwidth limit per file in KiB/s, or use suffix B|K|M|G|T|P or a full timetable")
+	flags.StringArrayVarP(flagSet, &ci.BwLimitRemote, "bwlimit-remote", "", nil, "Bandwidth limit for transfers to or from a remote as remote:=BANDWIDTH (can repeat)")
 	flags.FVarP(flagSet, &ci.BufferSize, "buffer-size", "", "In memory buffer size when reading files for each --transfer")
 	flags.FVarP(flagSet, &ci.StreamingUploadCutoff, "streaming-upload-cutoff", "", "Cutoff for switching to chunked upload if file size is unknown, upload starts after reaching cutoff or when file ends")
 	flags.FVarP(flagSet, &ci.Dump, "dump", "", "List of items to dump from: "+fs.DumpFlagsList)
 	flags.FVarP(flagSet, &ci.MaxTransfer, "max-transfer", "", "Maximum size of data to transfer")
 	flags.DurationVarP(flagSet, &ci.MaxDuration, "max-duration", "", 0, "Maximum duration rclone will transfer data for")
 	flags.FVarP(flagSet, &ci.CutoffMode, "cutoff-mode", "", "Mode to stop transfers when reaching the max transfer limit HARD|SOFT|CAUTIOUS")
+	flags.FVarP(flagSet, &ci.ConflictResolve, "conflict-resolve", "", "What to do when a file differs on the destination source|dest|newer|larger|rename-both|prompt")
+	flags.FVarP(flagSet, &ci.ServerSideFallback, "server-side-fallback", "", "What to do when a move can't be done server-side allow|warn|error")
 	flags.IntVarP(flagSet, &ci.MaxBacklog, "max-backlog", "", ci.MaxBacklog, "Maximum number of objects in sync or check backlog")
 	flags.IntVarP(flagSet, &ci.MaxStatsGroups, "max-stats-groups", "", ci.MaxStatsGroups, "Maximum number of stats groups to keep in memory, on max oldest is discarded")
 	flags.BoolVarP(flagSet, &ci.StatsOneLine, "stats-one-line", "", ci.StatsOneLine, "Make the stats fit on one line")
@@ -117,6 +130,8 @@ func AddFlags(ci *fs.ConfigInfo, flagSet *pflag.FlagSet) {
 	flags.BoolVarP(flagSet, &ci.ErrorOnNoTransfer, "error-on-no-transfer", "", ci.ErrorOnNoTransfer, "Sets exit code 9 if no files are transferred, useful in scripts")
 	flags.BoolVarP(flagSet, &ci.Progress, "progress", "P", ci.Progress, "Show progress during transfer")
 	flags.BoolVarP(flagSet, &ci.ProgressTerminalTitle, "progress-terminal-title", "", ci.ProgressTerminalTitle, "Show progress on the terminal title (requires -P/--progress)")
+	flags.BoolVarP(flagSet, &ci.ProgressJSON, "progress-json", "", ci.ProgressJSON, "Show progress as a JSON record per line for machines to read")
+	flags.StringVarP(flagSet, &ci.ProgressJSONAddr, "progress-json-addr", "", ci.ProgressJSONAddr, "Write --progress-json to this socket (unix:/path or host:port) instead of stdout")
 	flags.BoolVarP(flagSet, &ci.Cookie, "use-cookies", "", ci.Cookie, "Enable session cookiejar")
 	flags.BoolVarP(flagSet, &ci.UseMmap, "use-mmap", "", ci.UseMmap, "Use mmap allocator (see docs)")
 	flags.StringVarP(flagSet, &ci.CaCert, "ca-cert", "", ci.CaCert, "CA certificate used to verify servers")
@@ -124,6 +139,13 @@ func AddFlags(ci *fs.ConfigInfo, flagSet *pflag.FlagSet) {
 	flags.StringVarP(flagSet, &ci.ClientKey, "client-key", "", ci.ClientKey, "Client SSL private key (PEM) for mutual TLS auth")
 	flags.FVarP(flagSet, &ci.MultiThreadCutoff, "multi-thread-cutoff", "", "Use multi-thread downloads for files above this size")
 	flags.IntVarP(flagSet, &ci.MultiThreadStreams, "multi-thread-streams", "", ci.MultiThreadStreams, "Max number of streams to use for multi-thread downloads")
+	flags.BoolVarP(flagSet, &ci.Delta, "delta", "", ci.Delta, "Only send the changed blocks when updating files on the local backend")
+	flags.FVarP(flagSet, &ci.DeltaBlockSize, "delta-block-size", "", "Block size for --delta, 0 to pick one from the file size")
+	flags.StringVarP(flagSet, &ci.Resume, "resume", "", ci.Resume, "Keep a journal of finished files in this file so an interrupted sync can skip them when run again")
+	flags.BoolVarP(flagSet, &ci.ResumeUploads, "resume-uploads", "", ci.ResumeUploads, "Resume interrupted multi-thread uploads from the last uploaded chunk")
+	flags.BoolVarP(flagSet, &ci.Metadata, "metadata", "", ci.Metadata, "Preserve the metadata of files when copying them where both remotes support it")
+	flags.BoolVarP(flagSet, &ci.VerifyAfterTransfer, "verify-after-transfer", "", ci.VerifyAfterTransfer, "Read back transferred files which can't be checked by hash and compare them with the source")
+	flags.FVarP(flagSet, &ci.VerifySampleSize, "verify-sample-size", "", "Only read back this much of each file in ranges spread through it for --verify-after-transfer")
 	flags.BoolVarP(flagSet, &ci.UseJSONLog, "use-json-log", "", ci.UseJSONLog, "Use json log format")
 	flags.StringVarP(flagSet, &ci.OrderBy, "order-by", "", ci.OrderBy, "Instructions on how to order the transfers, e.g. 'size,descending'")
 	flags.StringArrayVarP(flagSet, &uploadHeaders, "header-upload", "", nil, "Set HTTP header for upload transactions")
@@ -230,6 +252,14 @@ func SetFlags(ci *fs.ConfigInfo) {
 		ci.DeleteMode = fs.DeleteModeDefault
 	}
 
+	if maxDelete != "" {
+		var err error
+		ci.MaxDelete, ci.MaxDeletePercent, err = ParseMaxDelete(maxDelete)
+		if err != nil {
+			log.Fatalf("--max-delete: %v", err)
+		}
+	}
+
 	if len(ci.CompareDest) > 0 && len(ci.CopyDest) > 0 {
 		log.Fatalf(`Can't use --compare-dest with --copy-dest.`)
 	}
@@ -365,3 +395,23 @@ func parseDSCP(dscp string) (uint8, bool) {
 		return 0, false
 	}
 }
+
+// ParseMaxDelete parses the value of --max-delete which is either a
+// number of files or a percentage like "10%".
+//
+// It returns -1 for whichever of maxDelete and maxDeletePercent isn't
+// set.
+func ParseMaxDelete(s string) (maxDelete int64, maxDeletePercent int, err error) {
+	if strings.HasSuffix(s, "%") {
+		maxDeletePercent, err = strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(s, "%")))
+		if err != nil || maxDeletePercent < 0 || maxDeletePercent > 100 {
+			return -1, -1, fmt.Errorf("invalid percentage %q", s)
+		}
+		return -1, maxDeletePercent, nil
+	}
+	maxDelete, err = strconv.ParseInt(s, 10, 64)
+	if err != nil || maxDelete < -1 {
+		return -1, -1, fmt.Errorf("invalid number of files %q", s)
+	}
+	return maxDelete, -1, nil
+}
@@ -0,0 +1,37 @@
+package configflags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMaxDelete(t *testing.T) {
+	for _, test := range []struct {
+		in          string
+		wantMax     int64
+		wantPercent int
+		wantErr     bool
+	}{
+		{"-1", -1, -1, false},
+		{"0", 0, -1, false},
+		{"100", 100, -1, false},
+		{"10%", -1, 10, false},
+		{"0%", -1, 0, false},
+		{"100%", -1, 100, false},
+		{"101%", -1, -1, true},
+		{"-1%", -1, -1, true},
+		{"-2", -1, -1, true},
+		{"potato", -1, -1, true},
+		{"%", -1, -1, true},
+	} {
+		gotMax, gotPercent, err := ParseMaxDelete(test.in)
+		if test.wantErr {
+			assert.Error(t, err, test.in)
+		} else {
+			assert.NoError(t, err, test.in)
+		}
+		assert.Equal(t, test.wantMax, gotMax, test.in)
+		assert.Equal(t, test.wantPercent, gotPercent, test.in)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/rc"
@@ -27,7 +28,14 @@ See the [config dump command](/commands/rclone_config_dump/) command for more in
 
 // Return the config file dump
 func rcDump(ctx context.Context, in rc.Params) (out rc.Params, err error) {
-	return DumpRcBlob(), nil
+	dump := DumpRcBlob()
+	tenant := rc.GetTenant(ctx)
+	for name := range dump {
+		if !rc.TenantOwns(tenant, name) {
+			delete(dump, name)
+		}
+	}
+	return dump, nil
 }
 
 func init() {
@@ -52,6 +60,9 @@ func rcGet(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	if err != nil {
 		return nil, err
 	}
+	if !rc.TenantOwns(rc.GetTenant(ctx), name) {
+		return nil, fmt.Errorf("remote %q: %w", name, rc.ErrTenantForbidden)
+	}
 	return DumpRcRemote(name), nil
 }
 
@@ -73,8 +84,11 @@ See the [listremotes command](/commands/rclone_listremotes/) command for more in
 // Return the a list of remotes in the config file
 func rcListRemotes(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	var remotes = []string{}
+	tenant := rc.GetTenant(ctx)
 	for _, remote := range LoadedData().GetSectionList() {
-		remotes = append(remotes, remote)
+		if rc.TenantOwns(tenant, remote) {
+			remotes = append(remotes, remote)
+		}
 	}
 	out = rc.Params{
 		"remotes": remotes,
@@ -121,6 +135,7 @@ func init() {
     - all - ask all the config questions not just the post config ones
     - state - state to restart with - used with continue
     - result - result to restart with - used with continue
+    - oauthURL - return the URL to do OAuth at in OAuthURL and ask for the redirect instead of waiting for a browser
 `
 		}
 		rc.Add(rc.Call{
@@ -2,10 +2,14 @@ package config_test
 
 import (
 	"context"
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
+	_ "github.com/rclone/rclone/backend/alias"
 	_ "github.com/rclone/rclone/backend/local"
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
 	"github.com/rclone/rclone/fs/config"
 	"github.com/rclone/rclone/fs/config/configfile"
 	"github.com/rclone/rclone/fs/config/obscure"
@@ -153,3 +157,87 @@ func TestRcProviders(t *testing.T) {
 	}
 	assert.True(t, foundLocal, "didn't find local provider")
 }
+
+func TestRcReload(t *testing.T) {
+	ctx := context.Background()
+	oldPath := config.GetConfigPath()
+	defer func() {
+		require.NoError(t, config.SetConfigPath(oldPath))
+		configfile.Install()
+		cache.Clear()
+	}()
+	path := filepath.Join(t.TempDir(), "rclone.conf")
+	write := func(contents string) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0600))
+	}
+	write(`[gone]
+type = local
+
+[edited]
+type = local
+case_insensitive = false
+
+[wrapper]
+type = alias
+remote = edited:dir
+
+[same]
+type = local
+`)
+	require.NoError(t, config.SetConfigPath(path))
+	configfile.Install()
+	assert.Equal(t, []string{"gone", "edited", "wrapper", "same"}, config.LoadedData().GetSectionList())
+
+	// Put the remotes in the cache
+	cache.Clear()
+	for _, remote := range []string{"gone:", "edited:", "wrapper:", "same:"} {
+		_, err := cache.Get(ctx, remote)
+		require.NoError(t, err, remote)
+	}
+	entries := cache.Entries()
+
+	write(`[edited]
+type = local
+case_insensitive = true
+
+[wrapper]
+type = alias
+remote = edited:dir
+
+[same]
+type = local
+
+[new]
+type = local
+`)
+	call := rc.Calls.Get("config/reload")
+	require.NotNil(t, call)
+	out, err := call.Fn(ctx, rc.Params{})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"added":   []interface{}{"new"},
+		"changed": []interface{}{"edited"},
+		"removed": []interface{}{"gone"},
+	}, out)
+	assert.Equal(t, "true", config.FileGet("edited", "case_insensitive"))
+
+	// Only the unchanged remote is left in the cache
+	assert.Equal(t, entries-3, cache.Entries())
+	assert.Equal(t, 0, cache.ClearConfig("gone")+cache.ClearConfig("edited")+cache.ClearConfig("wrapper"))
+	assert.Equal(t, 1, cache.ClearConfig("same"))
+
+	// Reloading again finds no changes
+	out, err = call.Fn(ctx, rc.Params{})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"added":   []interface{}{},
+		"changed": []interface{}{},
+		"removed": []interface{}{},
+	}, out)
+
+	// A broken config file is an error and the config is kept
+	write("[broken\n")
+	_, err = call.Fn(ctx, rc.Params{})
+	assert.Error(t, err)
+	assert.Equal(t, "true", config.FileGet("edited", "case_insensitive"))
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// remotesSnapshot is the keys and values of each remote in the config
+type remotesSnapshot map[string]map[string]string
+
+// loaded is the remotes as they were when the config file was last
+// loaded so Reload can see what changed
+var loaded struct {
+	mu      sync.Mutex
+	remotes remotesSnapshot
+}
+
+// snapshotRemotes reads the keys and values of every remote in d
+func snapshotRemotes(d Storage) remotesSnapshot {
+	remotes := make(remotesSnapshot)
+	for _, name := range d.GetSectionList() {
+		section := make(map[string]string)
+		for _, key := range d.GetKeyList(name) {
+			section[key], _ = d.GetValue(name, key)
+		}
+		remotes[name] = section
+	}
+	return remotes
+}
+
+// rememberRemotes records the remotes in d as the ones loaded
+func rememberRemotes(d Storage) {
+	remotes := snapshotRemotes(d)
+	loaded.mu.Lock()
+	loaded.remotes = remotes
+	loaded.mu.Unlock()
+}
+
+// sameSection returns true if a and b have the same keys and values
+func sameSection(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, found := b[key]; !found || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+// ReloadChanges lists the remotes which changed when the config file
+// was reloaded
+type ReloadChanges struct {
+	Added   []string `json:"added"`   // remotes which are new
+	Changed []string `json:"changed"` // remotes whose config changed
+	Removed []string `json:"removed"` // remotes which were removed
+}
+
+// Reload reads the config file again and removes remotes which were
+// changed or removed from the Fs cache, along with remotes which use
+// them, e.g. a crypt wrapping a changed remote.
+//
+// Fs already in use carry on with the old config. If the config file
+// can't be read the current config is kept.
+func Reload(ctx context.Context) (changes *ReloadChanges, err error) {
+	d := LoadedData()
+	loaded.mu.Lock()
+	defer loaded.mu.Unlock()
+	before := loaded.remotes
+	err = d.Load()
+	if err == ErrorConfigFileNotFound {
+		return nil, fmt.Errorf("no config file to reload at %q", GetConfigPath())
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to reload config file %q: %w", GetConfigPath(), err)
+	}
+	after := snapshotRemotes(d)
+	loaded.remotes = after
+
+	changes = &ReloadChanges{
+		Added:   []string{},
+		Changed: []string{},
+		Removed: []string{},
+	}
+	invalid := map[string]bool{}
+	for name, section := range after {
+		if old, found := before[name]; !found {
+			changes.Added = append(changes.Added, name)
+		} else if !sameSection(old, section) {
+			changes.Changed = append(changes.Changed, name)
+			invalid[name] = true
+		}
+	}
+	for name := range before {
+		if _, found := after[name]; !found {
+			changes.Removed = append(changes.Removed, name)
+			invalid[name] = true
+		}
+	}
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Changed)
+	sort.Strings(changes.Removed)
+
+	// Remotes which refer to an invalid remote are invalid too
+	for found := true; found; {
+		found = false
+		for name, section := range after {
+			if invalid[name] {
+				continue
+			}
+			for _, value := range section {
+				if i := strings.IndexRune(value, ':'); i > 0 && invalid[value[:i]] {
+					invalid[name] = true
+					found = true
+					break
+				}
+			}
+		}
+	}
+	for name := range invalid {
+		if n := cache.ClearConfig(name); n > 0 {
+			fs.Debugf(nil, "Removed %d cached Fs for remote %q after reloading config", n, name)
+		}
+	}
+	fs.Infof(nil, "Reloaded config file %q: %d added, %d changed, %d removed", GetConfigPath(), len(changes.Added), len(changes.Changed), len(changes.Removed))
+	return changes, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "config/reload",
+		Fn:           rcReload,
+		Title:        "Reload the config file.",
+		AuthRequired: true,
+		Help: `
+This reads the config file again so changes made to it since rclone
+started are used without restarting it.
+
+Remotes which were changed or removed are removed from the cache of
+remotes in use, as are remotes which refer to them, so they are made
+again with the new config next time they are used. Operations already
+running carry on with the old config.
+
+If the config file can't be read an error is returned and the
+current config is kept.
+
+Returns
+
+- added - array of names of remotes which were added
+- changed - array of names of remotes whose config changed
+- removed - array of names of remotes which were removed
+`,
+	})
+}
+
+// Reload the config file
+func rcReload(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	changes, err := Reload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out = rc.Params{}
+	err = rc.Reshape(&out, changes)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
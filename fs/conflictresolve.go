@@ -0,0 +1,65 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConflictResolve describes what to do when a file needs transferring
+// but a different file already exists at the destination
+type ConflictResolve byte
+
+// ConflictResolve constants
+const (
+	ConflictResolveSource     ConflictResolve = iota // overwrite the destination with the source
+	ConflictResolveDest                              // keep the destination
+	ConflictResolveNewer                             // keep whichever is newer
+	ConflictResolveLarger                            // keep whichever is larger
+	ConflictResolveRenameBoth                        // keep both under new names
+	ConflictResolvePrompt                            // ask the user
+	ConflictResolveDefault    = ConflictResolveSource
+)
+
+var conflictResolveToString = []string{
+	ConflictResolveSource:     "source",
+	ConflictResolveDest:       "dest",
+	ConflictResolveNewer:      "newer",
+	ConflictResolveLarger:     "larger",
+	ConflictResolveRenameBoth: "rename-both",
+	ConflictResolvePrompt:     "prompt",
+}
+
+// String turns a ConflictResolve into a string
+func (m ConflictResolve) String() string {
+	if m >= ConflictResolve(len(conflictResolveToString)) {
+		return fmt.Sprintf("ConflictResolve(%d)", m)
+	}
+	return conflictResolveToString[m]
+}
+
+// Set a ConflictResolve
+func (m *ConflictResolve) Set(s string) error {
+	for n, name := range conflictResolveToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = ConflictResolve(n)
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown conflict resolution %q", s)
+}
+
+// Type of the value
+func (m *ConflictResolve) Type() string {
+	return "string"
+}
+
+// UnmarshalJSON makes sure the value can be parsed as a string or integer in JSON
+func (m *ConflictResolve) UnmarshalJSON(in []byte) error {
+	return UnmarshalJSONFlag(in, m, func(i int64) error {
+		if i < 0 || i >= int64(len(conflictResolveToString)) {
+			return fmt.Errorf("Out of range conflict resolution %d", i)
+		}
+		*m = (ConflictResolve)(i)
+		return nil
+	})
+}
@@ -0,0 +1,78 @@
+package fs
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Check it satisfies the interface
+var _ flagger = (*ConflictResolve)(nil)
+
+func TestConflictResolveString(t *testing.T) {
+	for _, test := range []struct {
+		in   ConflictResolve
+		want string
+	}{
+		{ConflictResolveSource, "source"},
+		{ConflictResolveRenameBoth, "rename-both"},
+		{99, "ConflictResolve(99)"},
+	} {
+		cr := test.in
+		got := cr.String()
+		assert.Equal(t, test.want, got, test.in)
+	}
+}
+
+func TestConflictResolveSet(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want ConflictResolve
+		err  bool
+	}{
+		{"source", ConflictResolveSource, false},
+		{"DEST", ConflictResolveDest, false},
+		{"Newer", ConflictResolveNewer, false},
+		{"larger", ConflictResolveLarger, false},
+		{"rename-both", ConflictResolveRenameBoth, false},
+		{"prompt", ConflictResolvePrompt, false},
+		{"Potato", 0, true},
+		{"", 0, true},
+	} {
+		cr := ConflictResolve(0)
+		err := cr.Set(test.in)
+		if test.err {
+			require.Error(t, err, test.in)
+		} else {
+			require.NoError(t, err, test.in)
+		}
+		assert.Equal(t, test.want, cr, test.in)
+	}
+}
+
+func TestConflictResolveUnmarshalJSON(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want ConflictResolve
+		err  bool
+	}{
+		{`"newer"`, ConflictResolveNewer, false},
+		{`"Rename-Both"`, ConflictResolveRenameBoth, false},
+		{`"Potato"`, 0, true},
+		{strconv.Itoa(int(ConflictResolveDest)), ConflictResolveDest, false},
+		{`99`, 0, true},
+		{`-99`, 0, true},
+	} {
+		var cr ConflictResolve
+		err := json.Unmarshal([]byte(test.in), &cr)
+		if test.err {
+			require.Error(t, err, test.in)
+		} else {
+			require.NoError(t, err, test.in)
+		}
+		assert.Equal(t, test.want, cr, test.in)
+	}
+}
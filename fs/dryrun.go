@@ -0,0 +1,71 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// DryRunAction is an action which was skipped because --dry-run was set
+type DryRunAction struct {
+	Action string `json:"action"` // what would have been done, e.g. "copy"
+	Name   string `json:"name"`   // the object or directory it would have been done to
+	Size   int64  `json:"size"`   // size of the object or -1 if not known
+}
+
+// DryRunRecorder records the actions skipped because of --dry-run so
+// they can be reported
+type DryRunRecorder struct {
+	mu      sync.Mutex
+	actions []DryRunAction
+}
+
+// Type of the context key for the DryRunRecorder
+type dryRunContextKeyType struct{}
+
+// Context key for the DryRunRecorder
+var dryRunContextKey = dryRunContextKeyType{}
+
+// WithDryRunRecorder returns a copy of ctx with a new DryRunRecorder
+// attached which records the actions skipped in it
+func WithDryRunRecorder(ctx context.Context) (context.Context, *DryRunRecorder) {
+	r := &DryRunRecorder{
+		actions: []DryRunAction{},
+	}
+	return context.WithValue(ctx, dryRunContextKey, r), r
+}
+
+// GetDryRunRecorder returns the DryRunRecorder attached to ctx or nil
+// if there isn't one
+func GetDryRunRecorder(ctx context.Context) *DryRunRecorder {
+	r, _ := ctx.Value(dryRunContextKey).(*DryRunRecorder)
+	return r
+}
+
+// Add records that action on subject of size was skipped
+func (r *DryRunRecorder) Add(subject interface{}, action string, size int64) {
+	name := ""
+	switch x := subject.(type) {
+	case nil:
+	case DirEntry:
+		name = x.Remote()
+	case Fs:
+		name = ConfigString(x)
+	default:
+		name = fmt.Sprint(x)
+	}
+	r.mu.Lock()
+	r.actions = append(r.actions, DryRunAction{
+		Action: action,
+		Name:   name,
+		Size:   size,
+	})
+	r.mu.Unlock()
+}
+
+// Actions returns a copy of the actions recorded so far
+func (r *DryRunRecorder) Actions() []DryRunAction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]DryRunAction{}, r.actions...)
+}
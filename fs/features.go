@@ -26,6 +26,11 @@ type Features struct {
 	IsLocal                 bool // is the local backend
 	SlowModTime             bool // if calling ModTime() generally takes an extra transaction
 	SlowHash                bool // if calling Hash() generally takes an extra transaction
+	ReadMetadata            bool // can read metadata from objects
+	WriteMetadata           bool // can write metadata to objects
+	UserMetadata            bool // can read/write general purpose metadata
+	Trash                   bool // deleted files go into a trash of the remote's own
+	CompressUploads         bool // compresses uploads as asked by a Content-Encoding upload header
 
 	// Purge all files in the directory specified
 	//
@@ -143,6 +148,19 @@ type Features struct {
 	// It truncates any existing object
 	OpenWriterAt func(ctx context.Context, remote string, size int64) (WriterAtCloser, error)
 
+	// OpenChunkWriter returns the chunk size and a ChunkWriter
+	//
+	// Pass in the remote and the src object being uploaded. All
+	// the chunks written must be chunkSize bytes except the last.
+	OpenChunkWriter func(ctx context.Context, remote string, src ObjectInfo, options ...OpenOption) (chunkSize int64, writer ChunkWriter, err error)
+
+	// ResumeChunkWriter reopens an upload started by
+	// OpenChunkWriter using the ResumeID of its ChunkWriter
+	//
+	// It returns the chunk size, a ChunkWriter and the chunk
+	// numbers which have already been written.
+	ResumeChunkWriter func(ctx context.Context, remote string, src ObjectInfo, resumeID string, options ...OpenOption) (chunkSize int64, writer ChunkWriter, written []int, err error)
+
 	// UserInfo returns info about the connected user
 	UserInfo func(ctx context.Context) (map[string]string, error)
 
@@ -163,6 +181,13 @@ type Features struct {
 	// Shutdown the backend, closing any background tasks and any
 	// cached connections.
 	Shutdown func(ctx context.Context) error
+
+	// AccountID returns an identifier for the provider account the
+	// Fs is using or "" if it isn't known.
+	//
+	// Remotes of the same type with the same AccountID can
+	// server-side copy and move between each other.
+	AccountID func() string
 }
 
 // Disable nil's out the named feature.  If it isn't found then it
@@ -278,6 +303,12 @@ func (ft *Features) Fill(ctx context.Context, f Fs) *Features {
 	if do, ok := f.(OpenWriterAter); ok {
 		ft.OpenWriterAt = do.OpenWriterAt
 	}
+	if do, ok := f.(OpenChunkWriter); ok {
+		ft.OpenChunkWriter = do.OpenChunkWriter
+	}
+	if do, ok := f.(ChunkWriterResumer); ok {
+		ft.ResumeChunkWriter = do.ResumeChunkWriter
+	}
 	if do, ok := f.(UserInfoer); ok {
 		ft.UserInfo = do.UserInfo
 	}
@@ -290,6 +321,9 @@ func (ft *Features) Fill(ctx context.Context, f Fs) *Features {
 	if do, ok := f.(Shutdowner); ok {
 		ft.Shutdown = do.Shutdown
 	}
+	if do, ok := f.(AccountIDer); ok {
+		ft.AccountID = do.AccountID
+	}
 	return ft.DisableList(GetConfig(ctx).DisableFeatures)
 }
 
@@ -314,6 +348,11 @@ func (ft *Features) Mask(ctx context.Context, f Fs) *Features {
 	// ft.IsLocal = ft.IsLocal && mask.IsLocal Don't propagate IsLocal
 	ft.SlowModTime = ft.SlowModTime && mask.SlowModTime
 	ft.SlowHash = ft.SlowHash && mask.SlowHash
+	ft.ReadMetadata = ft.ReadMetadata && mask.ReadMetadata
+	ft.WriteMetadata = ft.WriteMetadata && mask.WriteMetadata
+	ft.UserMetadata = ft.UserMetadata && mask.UserMetadata
+	ft.Trash = ft.Trash && mask.Trash
+	ft.CompressUploads = ft.CompressUploads && mask.CompressUploads
 
 	if mask.Purge == nil {
 		ft.Purge = nil
@@ -363,6 +402,12 @@ func (ft *Features) Mask(ctx context.Context, f Fs) *Features {
 	if mask.OpenWriterAt == nil {
 		ft.OpenWriterAt = nil
 	}
+	if mask.OpenChunkWriter == nil {
+		ft.OpenChunkWriter = nil
+	}
+	if mask.ResumeChunkWriter == nil {
+		ft.ResumeChunkWriter = nil
+	}
 	if mask.UserInfo == nil {
 		ft.UserInfo = nil
 	}
@@ -373,6 +418,9 @@ func (ft *Features) Mask(ctx context.Context, f Fs) *Features {
 	if mask.Shutdown == nil {
 		ft.Shutdown = nil
 	}
+	if mask.AccountID == nil {
+		ft.AccountID = nil
+	}
 	return ft.DisableList(GetConfig(ctx).DisableFeatures)
 }
 
@@ -593,6 +641,54 @@ type OpenWriterAter interface {
 	OpenWriterAt(ctx context.Context, remote string, size int64) (WriterAtCloser, error)
 }
 
+// OpenChunkWriter is an option interface for Fs to implement chunked writing
+type OpenChunkWriter interface {
+	// OpenChunkWriter returns the chunk size and a ChunkWriter
+	//
+	// Pass in the remote and the src object being uploaded. All
+	// the chunks written must be chunkSize bytes except the last.
+	OpenChunkWriter(ctx context.Context, remote string, src ObjectInfo, options ...OpenOption) (chunkSize int64, writer ChunkWriter, err error)
+}
+
+// ChunkWriter is returned by OpenChunkWriter to implement chunked writing
+//
+// WriteChunk may be called concurrently for different chunks. Once
+// all the chunks have been written Close must be called to finish
+// the object, or Abort to cancel it.
+type ChunkWriter interface {
+	// WriteChunk will write chunk number with reader bytes, where chunk number >= 0
+	WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (bytesWritten int64, err error)
+
+	// Close complete chunked writer finalising the file
+	Close(ctx context.Context) error
+
+	// Abort chunk write
+	//
+	// You can and should call Abort without calling Close.
+	Abort(ctx context.Context) error
+}
+
+// ResumableChunkWriter is an optional interface for a ChunkWriter
+// whose upload can be continued later with ResumeChunkWriter
+type ResumableChunkWriter interface {
+	ChunkWriter
+
+	// ResumeID returns an opaque string identifying the upload
+	// to pass to ResumeChunkWriter
+	ResumeID() string
+}
+
+// ChunkWriterResumer is an optional interface for Fs to resume
+// chunked uploads
+type ChunkWriterResumer interface {
+	// ResumeChunkWriter reopens an upload started by
+	// OpenChunkWriter using the ResumeID of its ChunkWriter
+	//
+	// It returns the chunk size, a ChunkWriter and the chunk
+	// numbers which have already been written.
+	ResumeChunkWriter(ctx context.Context, remote string, src ObjectInfo, resumeID string, options ...OpenOption) (chunkSize int64, writer ChunkWriter, written []int, err error)
+}
+
 // UserInfoer is an optional interface for Fs
 type UserInfoer interface {
 	// UserInfo returns info about the connected user
@@ -636,6 +732,16 @@ type Shutdowner interface {
 	Shutdown(ctx context.Context) error
 }
 
+// AccountIDer is an optional interface for Fs
+type AccountIDer interface {
+	// AccountID returns an identifier for the provider account the
+	// Fs is using or "" if it isn't known.
+	//
+	// Remotes of the same type with the same AccountID can
+	// server-side copy and move between each other.
+	AccountID() string
+}
+
 // ObjectsChan is a channel of Objects
 type ObjectsChan chan Object
 
@@ -6,7 +6,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path"
 	"regexp"
@@ -91,6 +90,7 @@ type Opt struct {
 	IncludeFrom    []string
 	FilesFrom      []string
 	FilesFromRaw   []string
+	FilesFromList  []string // list of file names as if read with --files-from-raw
 	MinAge         fs.Duration
 	MaxAge         fs.Duration
 	MinSize        fs.SizeSuffix
@@ -137,7 +137,7 @@ func NewFilter(opt *Opt) (f *Filter, err error) {
 	if f.Opt.MaxAge.IsSet() {
 		f.ModTimeFrom = time.Now().Add(-time.Duration(f.Opt.MaxAge))
 		if !f.ModTimeTo.IsZero() && f.ModTimeTo.Before(f.ModTimeFrom) {
-			log.Fatal("filter: --min-age can't be larger than --max-age")
+			return nil, errors.New("filter: --min-age can't be larger than --max-age")
 		}
 		fs.Debugf(nil, "--max-age %v to %v", f.Opt.MaxAge, f.ModTimeFrom)
 	}
@@ -225,6 +225,19 @@ func NewFilter(opt *Opt) (f *Filter, err error) {
 		}
 	}
 
+	if len(f.Opt.FilesFromList) > 0 {
+		if !inActive {
+			return nil, fmt.Errorf("The usage of a list of files overrides all other filters, it should be used alone or with --files-from")
+		}
+		f.initAddFile()
+		for _, name := range f.Opt.FilesFromList {
+			err := f.AddFile(name)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	if addImplicitExclude {
 		err = f.Add(false, "/**")
 		if err != nil {
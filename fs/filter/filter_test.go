@@ -386,6 +386,32 @@ func TestNewFilterMaxSize(t *testing.T) {
 	assert.False(t, f.InActive())
 }
 
+func TestNewFilterFilesFromList(t *testing.T) {
+	Opt := DefaultOpt
+	Opt.FilesFromList = []string{"file1.jpg", " potato/file 2.jpg"}
+	f, err := NewFilter(&Opt)
+	require.NoError(t, err)
+	testInclude(t, f, []includeTest{
+		{"file1.jpg", 100, 0, true},
+		{" potato/file 2.jpg", 100, 0, true},
+		{"potato/file 2.jpg", 100, 0, false},
+		{"file3.jpg", 100, 0, false},
+	})
+
+	Opt.FilterRule = []string{"- *.jpg"}
+	_, err = NewFilter(&Opt)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "overrides all other filters")
+}
+
+func TestNewFilterMinAgeLargerThanMaxAge(t *testing.T) {
+	Opt := DefaultOpt
+	Opt.MinAge = fs.Duration(2 * time.Hour)
+	Opt.MaxAge = fs.Duration(time.Hour)
+	_, err := NewFilter(&Opt)
+	assert.Error(t, err)
+}
+
 func TestNewFilterMinAndMaxAge(t *testing.T) {
 	f, err := NewFilter(nil)
 	require.NoError(t, err)
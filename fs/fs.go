@@ -48,6 +48,8 @@ var (
 	ErrorNotImplemented              = errors.New("optional feature not implemented")
 	ErrorCommandNotFound             = errors.New("command not found")
 	ErrorFileNameTooLong             = errors.New("file name too long")
+	ErrorMaxDeleteReached            = errors.New("--max-delete threshold reached")
+	ErrorServerSideFallback          = errors.New("can't move server-side and --server-side-fallback is error")
 )
 
 // CheckClose is a utility function used to check the return from
@@ -0,0 +1,154 @@
+package list
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// DiskTree holds a recursive listing on disk for listings which are
+// too big to hold in memory.
+//
+// Entries are added in any order with Add. When Finish has been
+// called the entries of each directory can be read back sorted by
+// key with Dir.
+type DiskTree struct {
+	ctx    context.Context
+	f      fs.Fs
+	sorter *Sorter             // sorts the entries by directory then key
+	file   *os.File            // the sorted entries when finished
+	index  map[string]*section // where each directory is in file
+}
+
+// section is where the entries of a directory are in the file
+type section struct {
+	offset int64
+	size   int64
+}
+
+// NewDiskTree makes a DiskTree for entries of f sorting the
+// entries in each directory by keyFn.
+//
+// CleanUp must be called when it is finished with.
+func NewDiskTree(ctx context.Context, f fs.Fs, keyFn KeyFn) *DiskTree {
+	treeKey := func(entry fs.DirEntry) string {
+		dir := path.Dir(entry.Remote())
+		if dir == "." {
+			dir = ""
+		}
+		return dir + "\x00" + keyFn(entry)
+	}
+	return &DiskTree{
+		ctx:    ctx,
+		f:      f,
+		sorter: NewSorter(ctx, f, treeKey),
+	}
+}
+
+// Add adds entries to the tree
+func (t *DiskTree) Add(entries fs.DirEntries) error {
+	return t.sorter.Add(entries)
+}
+
+// Finish sorts the entries and writes them to disk.
+//
+// If skip is not nil then entries for which it returns true are left
+// out.
+func (t *DiskTree) Finish(skip func(remote string, isDir bool) bool) (err error) {
+	ls := t.sorter
+	defer ls.CleanUp()
+	// Make sure everything is on disk so it doesn't have to be
+	// found again with NewObject to write it out
+	if len(ls.entries) > 0 || len(ls.runs) == 0 {
+		err = ls.spill()
+		if err != nil {
+			return err
+		}
+	}
+	err = ls.start()
+	if err != nil {
+		return err
+	}
+	t.file, err = os.CreateTemp("", "rclone-list-")
+	if err != nil {
+		return fmt.Errorf("failed to make temporary file for listing: %w", err)
+	}
+	t.index = make(map[string]*section)
+	counter := &countWriter{w: t.file}
+	out := bufio.NewWriter(counter)
+	pos := func() int64 {
+		return counter.n + int64(out.Buffered())
+	}
+	var (
+		cur    *section
+		curDir string
+	)
+	for {
+		rec, err := ls.nextRecord()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read listing from disk: %w", err)
+		}
+		if skip != nil && skip(rec.remote, rec.isDir) {
+			continue
+		}
+		dir := rec.parent()
+		if cur == nil || dir != curDir {
+			cur = &section{offset: pos()}
+			curDir = dir
+			t.index[dir] = cur
+		}
+		err = rec.write(out)
+		if err != nil {
+			return fmt.Errorf("failed to write listing to disk: %w", err)
+		}
+		cur.size = pos() - cur.offset
+	}
+	return out.Flush()
+}
+
+// countWriter counts the bytes written through it
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write is part of io.Writer
+func (c *countWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Dir returns a Sorter which returns the entries of dir in key order.
+//
+// CleanUp must be called on the Sorter when it is finished with.
+func (t *DiskTree) Dir(dir string) *Sorter {
+	ls := NewSorter(t.ctx, t.f, nil)
+	if s := t.index[dir]; s != nil {
+		ls.runs = append(ls.runs, &run{
+			in: bufio.NewReader(io.NewSectionReader(t.file, s.offset, s.size)),
+		})
+	}
+	return ls
+}
+
+// CleanUp removes the temporary files
+func (t *DiskTree) CleanUp() {
+	t.sorter.CleanUp()
+	if t.file != nil {
+		_ = t.file.Close()
+		err := os.Remove(t.file.Name())
+		if err != nil {
+			fs.Errorf(nil, "Failed to remove temporary listing file: %v", err)
+		}
+		t.file = nil
+	}
+	t.index = nil
+}
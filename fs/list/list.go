@@ -36,8 +36,59 @@ func DirSorted(ctx context.Context, f fs.Fs, includeAll bool, dir string) (entri
 	return filterAndSortDir(ctx, entries, includeAll, dir, fi.IncludeObject, fi.IncludeDirectory(ctx, f))
 }
 
+// DirSorter reads Object and *Dir for the given Fs into a Sorter
+// which returns them sorted by keyFn.
+//
+// It filters the entries like DirSorted, but if there are more than
+// --list-cutoff of them the Sorter sorts them on disk rather than in
+// memory.
+//
+// CleanUp must be called on the Sorter when it is finished with.
+func DirSorter(ctx context.Context, f fs.Fs, includeAll bool, dir string, keyFn KeyFn) (ls *Sorter, err error) {
+	// Get unfiltered entries from the fs
+	entries, err := f.List(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	ls = NewSorter(ctx, f, keyFn)
+	fi := filter.GetConfig(ctx)
+	if !includeAll && fi.ListContainsExcludeFile(entries) {
+		fs.Debugf(dir, "Excluded")
+		return ls, nil
+	}
+	entries, err = filterDir(ctx, entries, includeAll, dir, fi.IncludeObject, fi.IncludeDirectory(ctx, f))
+	if err == nil {
+		err = ls.Add(entries)
+	}
+	if err != nil {
+		ls.CleanUp()
+		return nil, err
+	}
+	return ls, nil
+}
+
 // filter (if required) and check the entries, then sort them
 func filterAndSortDir(ctx context.Context, entries fs.DirEntries, includeAll bool, dir string,
+	IncludeObject func(ctx context.Context, o fs.Object) bool,
+	IncludeDirectory func(remote string) (bool, error)) (newEntries fs.DirEntries, err error) {
+	entries, err = filterDir(ctx, entries, includeAll, dir, IncludeObject, IncludeDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort the directory entries by Remote
+	//
+	// We use a stable sort here just in case there are
+	// duplicates. Assuming the remote delivers the entries in a
+	// consistent order, this will give the best user experience
+	// in syncing as it will use the first entry for the sync
+	// comparison.
+	sort.Stable(entries)
+	return entries, nil
+}
+
+// filter (if required) and check the entries
+func filterDir(ctx context.Context, entries fs.DirEntries, includeAll bool, dir string,
 	IncludeObject func(ctx context.Context, o fs.Object) bool,
 	IncludeDirectory func(remote string) (bool, error)) (newEntries fs.DirEntries, err error) {
 	newEntries = entries[:0] // in place filter
@@ -90,15 +141,5 @@ func filterAndSortDir(ctx context.Context, entries fs.DirEntries, includeAll boo
 			newEntries = append(newEntries, entry)
 		}
 	}
-	entries = newEntries
-
-	// Sort the directory entries by Remote
-	//
-	// We use a stable sort here just in case there are
-	// duplicates. Assuming the remote delivers the entries in a
-	// consistent order, this will give the best user experience
-	// in syncing as it will use the first entry for the sync
-	// comparison.
-	sort.Stable(entries)
-	return entries, nil
+	return newEntries, nil
 }
@@ -0,0 +1,458 @@
+package list
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// KeyFn returns the key a Sorter sorts an entry by.
+//
+// Entries with the same key are returned in the order they were
+// added.
+type KeyFn func(entry fs.DirEntry) string
+
+// sorterBatch is the number of entries read back from disk at once
+const sorterBatch = 1024
+
+// Sorter sorts directory entries by key.
+//
+// It keeps up to --list-cutoff entries in memory. Above that it sorts
+// them in chunks which it writes to temporary files and merges them
+// as they are read back with Next, so the memory used is bounded no
+// matter how many entries there are.
+//
+// Directories are stored on disk in full, but objects are stored by
+// name only and read again with NewObject, so sorting on disk uses
+// an extra transaction per object. If an object can't be found again
+// Next returns an error rather than leaving it out.
+type Sorter struct {
+	ctx     context.Context
+	f       fs.Fs
+	keyFn   KeyFn
+	cutoff  int
+	entries []keyedEntry  // entries held in memory
+	runs    []*run        // sorted runs of records on disk
+	started bool          // set when Next has been called
+	merge   runHeap       // runs being merged
+	batch   fs.DirEntries // entries read back but not returned yet
+	err     error         // sticky error from reading back
+}
+
+// keyedEntry is an entry with its sort key
+type keyedEntry struct {
+	key   string
+	entry fs.DirEntry
+}
+
+// NewSorter makes a Sorter to sort entries of f by keyFn.
+//
+// CleanUp must be called when it is finished with.
+func NewSorter(ctx context.Context, f fs.Fs, keyFn KeyFn) *Sorter {
+	return &Sorter{
+		ctx:    ctx,
+		f:      f,
+		keyFn:  keyFn,
+		cutoff: fs.GetConfig(ctx).ListCutoff,
+	}
+}
+
+// Add adds entries to the Sorter.
+//
+// It must not be called after Next.
+func (ls *Sorter) Add(entries fs.DirEntries) error {
+	if ls.started {
+		return errors.New("can't add entries to a Sorter after reading from it")
+	}
+	for _, entry := range entries {
+		ls.entries = append(ls.entries, keyedEntry{key: ls.keyFn(entry), entry: entry})
+		if ls.cutoff > 0 && len(ls.entries) >= ls.cutoff {
+			err := ls.spill()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sort the entries in memory
+func (ls *Sorter) sort() {
+	sort.SliceStable(ls.entries, func(i, j int) bool {
+		return ls.entries[i].key < ls.entries[j].key
+	})
+}
+
+// spill sorts the entries in memory and writes them to a new run on
+// disk
+func (ls *Sorter) spill() (err error) {
+	if len(ls.runs) == 0 {
+		fs.Debugf(ls.f, "Sorting more than %d directory entries on disk", ls.cutoff)
+	}
+	ls.sort()
+	file, err := os.CreateTemp("", "rclone-list-")
+	if err != nil {
+		return fmt.Errorf("failed to make temporary file for listing: %w", err)
+	}
+	r := &run{file: file, n: len(ls.runs)}
+	ls.runs = append(ls.runs, r)
+	out := bufio.NewWriter(file)
+	for i := range ls.entries {
+		rec := newRecord(ls.ctx, ls.entries[i].key, ls.entries[i].entry)
+		err = rec.write(out)
+		if err != nil {
+			return fmt.Errorf("failed to write listing to disk: %w", err)
+		}
+		ls.entries[i] = keyedEntry{}
+	}
+	ls.entries = ls.entries[:0]
+	err = out.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to write listing to disk: %w", err)
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	r.in = bufio.NewReader(file)
+	return nil
+}
+
+// start reading entries back
+func (ls *Sorter) start() error {
+	ls.started = true
+	if len(ls.runs) == 0 {
+		ls.sort()
+		return nil
+	}
+	if len(ls.entries) > 0 {
+		err := ls.spill()
+		if err != nil {
+			return err
+		}
+	}
+	for _, r := range ls.runs {
+		err := r.next()
+		if err == io.EOF {
+			continue
+		} else if err != nil {
+			return err
+		}
+		ls.merge = append(ls.merge, r)
+	}
+	heap.Init(&ls.merge)
+	return nil
+}
+
+// nextRecord returns the next record in key order from the runs on
+// disk or io.EOF if there are no more.
+func (ls *Sorter) nextRecord() (rec record, err error) {
+	if len(ls.merge) == 0 {
+		return rec, io.EOF
+	}
+	r := ls.merge[0]
+	rec = r.rec
+	err = r.next()
+	if err == io.EOF {
+		heap.Pop(&ls.merge)
+	} else if err != nil {
+		return rec, err
+	} else {
+		heap.Fix(&ls.merge, 0)
+	}
+	return rec, nil
+}
+
+// readBatch reads the next batch of records from disk and turns them
+// back into entries.
+func (ls *Sorter) readBatch() error {
+	var recs []record
+	for len(recs) < sorterBatch {
+		rec, err := ls.nextRecord()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read listing from disk: %w", err)
+		}
+		recs = append(recs, rec)
+	}
+	if len(recs) == 0 {
+		return io.EOF
+	}
+
+	// Find the objects again in parallel
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		findErr error
+		entries = make(fs.DirEntries, len(recs))
+		limiter = make(chan struct{}, fs.GetConfig(ls.ctx).Checkers)
+	)
+	for i := range recs {
+		wg.Add(1)
+		limiter <- struct{}{}
+		go func(i int) {
+			defer func() {
+				<-limiter
+				wg.Done()
+			}()
+			entry, err := recs[i].entry(ls.ctx, ls.f)
+			if errors.Is(err, fs.ErrorObjectNotFound) || errors.Is(err, fs.ErrorIsDir) {
+				// Leaving the object out could make a sync
+				// delete its match in the destination
+				err = fmt.Errorf("%q was listed but couldn't be found again when sorting the listing on disk: %w", recs[i].remote, err)
+			}
+			if err != nil {
+				mu.Lock()
+				if findErr == nil {
+					findErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			entries[i] = entry
+		}(i)
+	}
+	wg.Wait()
+	if findErr != nil {
+		return findErr
+	}
+	for _, entry := range entries {
+		if entry != nil {
+			ls.batch = append(ls.batch, entry)
+		}
+	}
+	return nil
+}
+
+// Next returns the next entry in key order or io.EOF if there are no
+// more.
+func (ls *Sorter) Next() (entry fs.DirEntry, err error) {
+	if !ls.started {
+		err = ls.start()
+		if err != nil {
+			ls.err = err
+		}
+	}
+	if ls.err != nil {
+		return nil, ls.err
+	}
+	if len(ls.runs) == 0 {
+		if len(ls.entries) == 0 {
+			return nil, io.EOF
+		}
+		entry = ls.entries[0].entry
+		ls.entries[0] = keyedEntry{}
+		ls.entries = ls.entries[1:]
+		return entry, nil
+	}
+	for len(ls.batch) == 0 {
+		err = ls.readBatch()
+		if err != nil {
+			ls.err = err
+			return nil, err
+		}
+	}
+	entry = ls.batch[0]
+	ls.batch[0] = nil
+	ls.batch = ls.batch[1:]
+	return entry, nil
+}
+
+// CleanUp removes any temporary files
+func (ls *Sorter) CleanUp() {
+	for _, r := range ls.runs {
+		r.close()
+	}
+	ls.runs = nil
+	ls.merge = nil
+	ls.entries = nil
+	ls.batch = nil
+}
+
+// run is a sorted run of records on disk
+type run struct {
+	file *os.File      // temporary file to remove when done or nil
+	in   *bufio.Reader // where to read the records from
+	rec  record        // the current record
+	n    int           // number of the run to keep the merge stable
+}
+
+// next reads the next record into r.rec
+func (r *run) next() error {
+	return r.rec.read(r.in)
+}
+
+// close the run removing its file if it has one
+func (r *run) close() {
+	if r.file == nil {
+		return
+	}
+	_ = r.file.Close()
+	err := os.Remove(r.file.Name())
+	if err != nil {
+		fs.Errorf(nil, "Failed to remove temporary listing file: %v", err)
+	}
+	r.file = nil
+}
+
+// runHeap merges runs by the key of their current record
+type runHeap []*run
+
+// Len is part of heap.Interface
+func (h runHeap) Len() int { return len(h) }
+
+// Less is part of heap.Interface
+func (h runHeap) Less(i, j int) bool {
+	if h[i].rec.key == h[j].rec.key {
+		return h[i].n < h[j].n
+	}
+	return h[i].rec.key < h[j].rec.key
+}
+
+// Swap is part of heap.Interface
+func (h runHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+// Push is part of heap.Interface
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*run)) }
+
+// Pop is part of heap.Interface
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return x
+}
+
+// record is a directory entry as stored on disk
+type record struct {
+	key    string
+	remote string
+	isDir  bool
+	// for directories only
+	modTime int64
+	size    int64
+	items   int64
+	id      string
+}
+
+// newRecord makes a record from an entry
+func newRecord(ctx context.Context, key string, entry fs.DirEntry) record {
+	rec := record{
+		key:    key,
+		remote: entry.Remote(),
+	}
+	if dir, ok := entry.(fs.Directory); ok {
+		rec.isDir = true
+		rec.modTime = dir.ModTime(ctx).UnixNano()
+		rec.size = dir.Size()
+		rec.items = dir.Items()
+		rec.id = dir.ID()
+	}
+	return rec
+}
+
+// entry makes the record back into an entry
+func (rec *record) entry(ctx context.Context, f fs.Fs) (fs.DirEntry, error) {
+	if rec.isDir {
+		return fs.NewDir(rec.remote, time.Unix(0, rec.modTime)).SetSize(rec.size).SetItems(rec.items).SetID(rec.id), nil
+	}
+	return f.NewObject(ctx, rec.remote)
+}
+
+// parent returns the directory the record is in
+func (rec *record) parent() string {
+	dir := path.Dir(rec.remote)
+	if dir == "." {
+		dir = ""
+	}
+	return dir
+}
+
+// write the record to out
+func (rec *record) write(out *bufio.Writer) error {
+	var buf [binary.MaxVarintLen64]byte
+	putInt := func(x int64) {
+		n := binary.PutVarint(buf[:], x)
+		_, _ = out.Write(buf[:n])
+	}
+	putString := func(s string) {
+		putInt(int64(len(s)))
+		_, _ = out.WriteString(s)
+	}
+	putString(rec.key)
+	putString(rec.remote)
+	if rec.isDir {
+		_ = out.WriteByte('D')
+		putInt(rec.modTime)
+		putInt(rec.size)
+		putInt(rec.items)
+		putString(rec.id)
+	} else {
+		_ = out.WriteByte('O')
+	}
+	// bufio.Writer errors are sticky so just check the last one
+	_, err := out.Write(nil)
+	return err
+}
+
+// read the record from in returning io.EOF if there are no more
+func (rec *record) read(in *bufio.Reader) (err error) {
+	getInt := func() int64 {
+		if err != nil {
+			return 0
+		}
+		var x int64
+		x, err = binary.ReadVarint(in)
+		return x
+	}
+	getString := func() string {
+		n := getInt()
+		if err != nil {
+			return ""
+		}
+		if n < 0 {
+			err = errors.New("bad string length")
+			return ""
+		}
+		buf := make([]byte, n)
+		_, err = io.ReadFull(in, buf)
+		return string(buf)
+	}
+	*rec = record{}
+	rec.key = getString()
+	if err == io.EOF {
+		return io.EOF
+	}
+	rec.remote = getString()
+	var kind byte
+	if err == nil {
+		kind, err = in.ReadByte()
+	}
+	if err == nil && kind == 'D' {
+		rec.isDir = true
+		rec.modTime = getInt()
+		rec.size = getInt()
+		rec.items = getInt()
+		rec.id = getString()
+	} else if err == nil && kind != 'O' {
+		err = fmt.Errorf("unknown record type %q", kind)
+	}
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
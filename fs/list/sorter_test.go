@@ -0,0 +1,162 @@
+package list
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// objectsFs is a mock Fs which can find objects in any directory
+type objectsFs struct {
+	*mockfs.Fs
+	objects map[string]fs.Object
+}
+
+func newObjectsFs(ctx context.Context, entries fs.DirEntries) *objectsFs {
+	f := &objectsFs{
+		Fs:      mockfs.NewFs(ctx, "mock", "/"),
+		objects: map[string]fs.Object{},
+	}
+	for _, entry := range entries {
+		if o, ok := entry.(fs.Object); ok {
+			f.objects[o.Remote()] = o
+		}
+	}
+	return f
+}
+
+// NewObject finds the Object at remote
+func (f *objectsFs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	o, ok := f.objects[remote]
+	if !ok {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return o, nil
+}
+
+// read all the entries from the Sorter
+func readSorter(t *testing.T, ls *Sorter) (entries fs.DirEntries) {
+	for {
+		entry, err := ls.Next()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+}
+
+func remoteKey(entry fs.DirEntry) string {
+	return entry.Remote()
+}
+
+func TestSorter(t *testing.T) {
+	t1 := time.Date(2001, 2, 3, 4, 5, 6, 7, time.UTC)
+	var (
+		a     = mockobject.Object("a")
+		b     = mockobject.Object("b")
+		b2    = mockobject.Object("b")
+		c     = mockobject.Object("c")
+		d     = mockobject.Object("d")
+		dirC  = fs.NewDir("c", t1).SetSize(1).SetItems(2).SetID("id")
+		input = fs.DirEntries{d, b, dirC, a, b2, c}
+	)
+	for _, cutoff := range []int{0, 1, 2, 4, 100} {
+		ctx, ci := fs.AddConfig(context.Background())
+		ci.ListCutoff = cutoff
+		f := newObjectsFs(ctx, input)
+		ls := NewSorter(ctx, f, remoteKey)
+		require.NoError(t, ls.Add(input[:3]))
+		require.NoError(t, ls.Add(input[3:]))
+		got := readSorter(t, ls)
+		_, err := ls.Next()
+		assert.Equal(t, io.EOF, err)
+		ls.CleanUp()
+
+		require.Len(t, got, 6, "cutoff %d", cutoff)
+		assert.Equal(t, a, got[0])
+		// entries with the same key stay in the order they were added
+		// though the duplicate is found again with NewObject on disk
+		assert.Equal(t, b, got[1])
+		assert.Equal(t, "b", got[2].Remote())
+		if cutoff == 0 || cutoff >= len(input) {
+			assert.Equal(t, dirC, got[3])
+			assert.Equal(t, b2, got[2])
+		} else {
+			dir, ok := got[3].(fs.Directory)
+			require.True(t, ok)
+			assert.Equal(t, "c", dir.Remote())
+			assert.True(t, t1.Equal(dir.ModTime(ctx)))
+			assert.Equal(t, int64(1), dir.Size())
+			assert.Equal(t, int64(2), dir.Items())
+			assert.Equal(t, "id", dir.ID())
+		}
+		assert.Equal(t, c, got[4])
+		assert.Equal(t, d, got[5])
+	}
+}
+
+func TestSorterGone(t *testing.T) {
+	ctx, ci := fs.AddConfig(context.Background())
+	ci.ListCutoff = 1
+	a, b := mockobject.Object("a"), mockobject.Object("b")
+	f := newObjectsFs(ctx, fs.DirEntries{a})
+	ls := NewSorter(ctx, f, remoteKey)
+	defer ls.CleanUp()
+	require.NoError(t, ls.Add(fs.DirEntries{b, a}))
+
+	// b has gone since it was listed which is an error
+	_, err := ls.Next()
+	assert.True(t, errors.Is(err, fs.ErrorObjectNotFound))
+	_, err = ls.Next()
+	assert.Error(t, err)
+	assert.Error(t, ls.Add(fs.DirEntries{b}))
+}
+
+func TestDiskTree(t *testing.T) {
+	t1 := time.Date(2001, 2, 3, 4, 5, 6, 7, time.UTC)
+	var (
+		a     = mockobject.Object("a")
+		dirB  = fs.NewDir("b", t1)
+		bc    = mockobject.Object("b/c")
+		ba    = mockobject.Object("b/a")
+		dirBD = fs.NewDir("b/d", t1)
+		bdx   = mockobject.Object("b/d/x")
+		bdy   = mockobject.Object("b/d/y")
+		ab    = mockobject.Object("a.b/z")
+		input = fs.DirEntries{bdy, a, bc, ab, dirB, bdx, ba, dirBD}
+	)
+	ctx, ci := fs.AddConfig(context.Background())
+	ci.ListCutoff = 3
+	f := newObjectsFs(ctx, input)
+	tree := NewDiskTree(ctx, f, remoteKey)
+	defer tree.CleanUp()
+	require.NoError(t, tree.Add(input[:5]))
+	require.NoError(t, tree.Add(input[5:]))
+	require.NoError(t, tree.Finish(func(remote string, isDir bool) bool {
+		return remote == "b/d/y"
+	}))
+
+	remotes := func(dir string) (names []string) {
+		ls := tree.Dir(dir)
+		defer ls.CleanUp()
+		for _, entry := range readSorter(t, ls) {
+			names = append(names, entry.Remote())
+		}
+		return names
+	}
+	// Read the directories in a different order to the file
+	assert.Equal(t, []string{"b/d/x"}, remotes("b/d"))
+	assert.Equal(t, []string{"a", "b"}, remotes(""))
+	assert.Equal(t, []string{"b/a", "b/c", "b/d"}, remotes("b"))
+	assert.Equal(t, []string{"a.b/z"}, remotes("a.b"))
+	assert.Equal(t, []string(nil), remotes("missing"))
+}
@@ -92,6 +92,9 @@ var LogPrint = func(level LogLevel, text string) {
 	_ = log.Output(4, text)
 }
 
+// LogHook is called, if set, with each message which is logged
+var LogHook func(level LogLevel, text string)
+
 // LogValueItem describes keyed item for a JSON log entry
 type LogValueItem struct {
 	key    string
@@ -134,6 +137,13 @@ func (j LogValueItem) String() string {
 // LogPrintf produces a log string from the arguments passed in
 func LogPrintf(level LogLevel, o interface{}, text string, args ...interface{}) {
 	out := fmt.Sprintf(text, args...)
+	if LogHook != nil {
+		if o != nil {
+			LogHook(level, fmt.Sprintf("%v: %s", o, out))
+		} else {
+			LogHook(level, out)
+		}
+	}
 
 	if GetConfig(context.TODO()).UseJSONLog {
 		fields := logrus.Fields{}
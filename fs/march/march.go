@@ -4,13 +4,12 @@ package march
 import (
 	"context"
 	"fmt"
+	"io"
 	"path"
-	"sort"
 	"strings"
 	"sync"
 
 	"github.com/rclone/rclone/fs"
-	"github.com/rclone/rclone/fs/dirtree"
 	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/list"
 	"github.com/rclone/rclone/fs/walk"
@@ -35,6 +34,7 @@ type March struct {
 	srcListDir listDirFn // function to call to list a directory in the src
 	dstListDir listDirFn // function to call to list a directory in the dst
 	transforms []matchTransformFn
+	cleanUps   []func() // functions to call when finished
 }
 
 // Marcher is called on each match
@@ -51,11 +51,7 @@ type Marcher interface {
 // Note: this will flag filter-aware backends on the source side
 func (m *March) init(ctx context.Context) {
 	ci := fs.GetConfig(ctx)
-	m.srcListDir = m.makeListDir(ctx, m.Fsrc, m.SrcIncludeAll)
-	if !m.NoTraverse {
-		m.dstListDir = m.makeListDir(ctx, m.Fdst, m.DstIncludeAll)
-	}
-	// Now create the matching transform
+	// First create the matching transform
 	// ..normalise the UTF8 first
 	if !m.NoUnicodeNormalization {
 		m.transforms = append(m.transforms, norm.NFC.String)
@@ -69,10 +65,24 @@ func (m *March) init(ctx context.Context) {
 	if m.Fdst.Features().CaseInsensitive || ci.IgnoreCaseSync {
 		m.transforms = append(m.transforms, strings.ToLower)
 	}
+	// Now make the listers which sort using it
+	m.srcListDir = m.makeListDir(ctx, m.Fsrc, m.SrcIncludeAll)
+	if !m.NoTraverse {
+		m.dstListDir = m.makeListDir(ctx, m.Fdst, m.DstIncludeAll)
+	}
+}
+
+// cleanUp calls the clean up functions
+func (m *March) cleanUp() {
+	for _, fn := range m.cleanUps {
+		fn()
+	}
+	m.cleanUps = nil
 }
 
-// list a directory into entries, err
-type listDirFn func(dir string) (entries fs.DirEntries, err error)
+// list a directory into a Sorter which returns the entries sorted by
+// matchKey
+type listDirFn func(dir string) (ls *list.Sorter, err error)
 
 // makeListDir makes constructs a listing function for the given fs
 // and includeAll flags for marching through the file system.
@@ -80,40 +90,45 @@ type listDirFn func(dir string) (entries fs.DirEntries, err error)
 func (m *March) makeListDir(ctx context.Context, f fs.Fs, includeAll bool) listDirFn {
 	ci := fs.GetConfig(ctx)
 	fi := filter.GetConfig(ctx)
+	keyFn := matchKey(m.transforms)
 	if !(ci.UseListR && f.Features().ListR != nil) && // !--fast-list active and
 		!(ci.NoTraverse && fi.HaveFilesFrom()) { // !(--files-from and --no-traverse)
-		return func(dir string) (entries fs.DirEntries, err error) {
+		return func(dir string) (*list.Sorter, error) {
 			dirCtx := filter.SetUseFilter(m.Ctx, !includeAll) // make filter-aware backends constrain List
-			return list.DirSorted(dirCtx, f, includeAll, dir)
+			return list.DirSorter(dirCtx, f, includeAll, dir, keyFn)
 		}
 	}
 
 	// This returns a closure for use when --fast-list is active or for when
 	// --files-from and --no-traverse is set
+	//
+	// If the listing has more than --list-cutoff entries it is
+	// kept on disk rather than in memory.
 	var (
 		mu      sync.Mutex
 		started bool
-		dirs    dirtree.DirTree
-		dirsErr error
+		tree    *walk.Tree
+		treeErr error
 	)
-	return func(dir string) (entries fs.DirEntries, err error) {
+	m.cleanUps = append(m.cleanUps, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if tree != nil {
+			tree.CleanUp()
+		}
+	})
+	return func(dir string) (*list.Sorter, error) {
 		mu.Lock()
 		defer mu.Unlock()
 		if !started {
 			dirCtx := filter.SetUseFilter(m.Ctx, !includeAll) // make filter-aware backends constrain List
-			dirs, dirsErr = walk.NewDirTree(dirCtx, f, m.Dir, includeAll, ci.MaxDepth)
+			tree, treeErr = walk.NewTree(dirCtx, f, m.Dir, includeAll, ci.MaxDepth, ci.ListCutoff, keyFn)
 			started = true
 		}
-		if dirsErr != nil {
-			return nil, dirsErr
-		}
-		entries, ok := dirs[dir]
-		if !ok {
-			err = fs.ErrorDirNotFound
-		} else {
-			delete(dirs, dir)
+		if treeErr != nil {
+			return nil, treeErr
 		}
-		return entries, err
+		return tree.ListDir(dir)
 	}
 }
 
@@ -132,6 +147,7 @@ func (m *March) Run(ctx context.Context) error {
 	ci := fs.GetConfig(ctx)
 	fi := filter.GetConfig(ctx)
 	m.init(ctx)
+	defer m.cleanUp()
 
 	srcDepth := ci.MaxDepth
 	if srcDepth < 0 {
@@ -230,148 +246,212 @@ func (m *March) aborting() bool {
 	return false
 }
 
-// matchEntry is an entry plus transformed name
-type matchEntry struct {
-	entry fs.DirEntry
-	leaf  string
-	name  string
-}
-
-// matchEntries contains many matchEntry~s
-type matchEntries []matchEntry
-
-// Len is part of sort.Interface.
-func (es matchEntries) Len() int { return len(es) }
-
-// Swap is part of sort.Interface.
-func (es matchEntries) Swap(i, j int) { es[i], es[j] = es[j], es[i] }
+// matchTransformFn converts a name into a form which is used for
+// comparison in matchListings.
+type matchTransformFn func(name string) string
 
-// Less is part of sort.Interface.
+// matchKey returns a function which makes the key the listings are
+// sorted by for matchListings.
 //
-// Compare in order (name, leaf, remote)
-func (es matchEntries) Less(i, j int) bool {
-	ei, ej := &es[i], &es[j]
-	if ei.name == ej.name {
-		if ei.leaf == ej.leaf {
-			return fs.CompareDirEntries(ei.entry, ej.entry) < 0
-		}
-		return ei.leaf < ej.leaf
+// This sorts in order (name, leaf, remote, type) where name is the
+// leaf transformed by transforms. The parts are separated by a 0
+// byte, which can't be in a name, so the keys sort in the same order
+// as the parts.
+func matchKey(transforms []matchTransformFn) list.KeyFn {
+	return func(entry fs.DirEntry) string {
+		leaf := path.Base(entry.Remote())
+		return transformName(leaf, transforms) + "\x00" + leaf + "\x00" + entry.Remote() + "\x00" + fs.DirEntryType(entry)
 	}
-	return ei.name < ej.name
 }
 
-// Sort the directory entries by (name, leaf, remote)
-//
-// We use a stable sort here just in case there are
-// duplicates. Assuming the remote delivers the entries in a
-// consistent order, this will give the best user experience
-// in syncing as it will use the first entry for the sync
-// comparison.
-func (es matchEntries) sort() {
-	sort.Stable(es)
-}
-
-// make a matchEntries from a newMatch entries
-func newMatchEntries(entries fs.DirEntries, transforms []matchTransformFn) matchEntries {
-	es := make(matchEntries, len(entries))
-	for i := range es {
-		es[i].entry = entries[i]
-		name := path.Base(entries[i].Remote())
-		es[i].leaf = name
-		for _, transform := range transforms {
-			name = transform(name)
-		}
-		es[i].name = name
+// transformName applies the transforms to the name
+func transformName(name string, transforms []matchTransformFn) string {
+	for _, transform := range transforms {
+		name = transform(name)
 	}
-	es.sort()
-	return es
+	return name
 }
 
-// matchPair is a matched pair of direntries returned by matchListings
-type matchPair struct {
-	src, dst fs.DirEntry
+// matchEntry is an entry plus transformed name
+type matchEntry struct {
+	entry fs.DirEntry
+	name  string
 }
 
-// matchTransformFn converts a name into a form which is used for
-// comparison in matchListings.
-type matchTransformFn func(name string) string
+// matchCursor reads the entries of one side of matchListings
+type matchCursor struct {
+	next       func() (fs.DirEntry, error)
+	transforms []matchTransformFn
+	side       string     // "source" or "destination"
+	cur        matchEntry // current entry - entry is nil at the end
+	prev       matchEntry // previous entry read
+}
 
-// Process the two listings, matching up the items in the two slices
-// using the transform function on each name first.
-//
-// Into srcOnly go Entries which only exist in the srcList
-// Into dstOnly go Entries which only exist in the dstList
-// Into matches go matchPair's of src and dst which have the same name
-//
-// This checks for duplicates and checks the list is sorted.
-func matchListings(srcListEntries, dstListEntries fs.DirEntries, transforms []matchTransformFn) (srcOnly fs.DirEntries, dstOnly fs.DirEntries, matches []matchPair) {
-	srcList := newMatchEntries(srcListEntries, transforms)
-	dstList := newMatchEntries(dstListEntries, transforms)
-
-	for iSrc, iDst := 0, 0; ; iSrc, iDst = iSrc+1, iDst+1 {
-		var src, dst fs.DirEntry
-		var srcName, dstName string
-		if iSrc < len(srcList) {
-			src = srcList[iSrc].entry
-			srcName = srcList[iSrc].name
+// advance reads the next entry into cur, skipping duplicates and
+// checking the listing is sorted.
+func (c *matchCursor) advance() error {
+	for {
+		entry, err := c.next()
+		if err == io.EOF {
+			c.cur = matchEntry{}
+			return nil
+		} else if err != nil {
+			return err
 		}
-		if iDst < len(dstList) {
-			dst = dstList[iDst].entry
-			dstName = dstList[iDst].name
+		e := matchEntry{
+			entry: entry,
+			name:  transformName(path.Base(entry.Remote()), c.transforms),
 		}
-		if src == nil && dst == nil {
-			break
-		}
-		if src != nil && iSrc > 0 {
-			prev := srcList[iSrc-1].entry
-			prevName := srcList[iSrc-1].name
-			if srcName == prevName && fs.DirEntryType(prev) == fs.DirEntryType(src) {
-				fs.Logf(src, "Duplicate %s found in source - ignoring", fs.DirEntryType(src))
-				iDst-- // ignore the src and retry the dst
+		prev := c.prev
+		c.prev = e
+		if prev.entry != nil {
+			if e.name == prev.name && fs.DirEntryType(prev.entry) == fs.DirEntryType(entry) {
+				fs.Logf(entry, "Duplicate %s found in %s - ignoring", fs.DirEntryType(entry), c.side)
 				continue
-			} else if srcName < prevName {
+			} else if e.name < prev.name {
 				// this should never happen since we sort the listings
-				panic("Out of order listing in source")
-			}
-		}
-		if dst != nil && iDst > 0 {
-			prev := dstList[iDst-1].entry
-			prevName := dstList[iDst-1].name
-			if dstName == prevName && fs.DirEntryType(dst) == fs.DirEntryType(prev) {
-				fs.Logf(dst, "Duplicate %s found in destination - ignoring", fs.DirEntryType(dst))
-				iSrc-- // ignore the dst and retry the src
-				continue
-			} else if dstName < prevName {
-				// this should never happen since we sort the listings
-				panic("Out of order listing in destination")
+				panic("Out of order listing in " + c.side)
 			}
 		}
+		c.cur = e
+		return nil
+	}
+}
+
+// Process the two listings, matching up the items using the
+// transform function on each name first.
+//
+// The listings are read with srcNext and dstNext which must return
+// the entries sorted by matchKey and then io.EOF.
+//
+// srcOnly is called with entries which only exist in the source,
+// dstOnly with entries which only exist in the destination and match
+// with the dst and src which have the same name. They are called in
+// name order as the listings are read.
+//
+// This checks for duplicates and checks the listings are sorted.
+func matchListings(srcNext, dstNext func() (fs.DirEntry, error), transforms []matchTransformFn,
+	srcOnly, dstOnly func(entry fs.DirEntry) error, match func(dst, src fs.DirEntry) error) error {
+	srcList := &matchCursor{next: srcNext, transforms: transforms, side: "source"}
+	dstList := &matchCursor{next: dstNext, transforms: transforms, side: "destination"}
+	err := srcList.advance()
+	if err != nil {
+		return err
+	}
+	err = dstList.advance()
+	if err != nil {
+		return err
+	}
+	for {
+		src, dst := srcList.cur.entry, dstList.cur.entry
 		if src != nil && dst != nil {
 			// we can't use CompareDirEntries because srcName, dstName could
 			// be different then src.Remote() or dst.Remote()
+			srcName, dstName := srcList.cur.name, dstList.cur.name
 			srcType := fs.DirEntryType(src)
 			dstType := fs.DirEntryType(dst)
 			if srcName > dstName || (srcName == dstName && srcType > dstType) {
 				src = nil
-				iSrc--
 			} else if srcName < dstName || (srcName == dstName && srcType < dstType) {
 				dst = nil
-				iDst--
 			}
 		}
 		// Debugf(nil, "src = %v, dst = %v", src, dst)
 		switch {
 		case src == nil && dst == nil:
-			// do nothing
+			return nil
 		case src == nil:
-			dstOnly = append(dstOnly, dst)
+			err = dstOnly(dst)
+			if err == nil {
+				err = dstList.advance()
+			}
 		case dst == nil:
-			srcOnly = append(srcOnly, src)
+			err = srcOnly(src)
+			if err == nil {
+				err = srcList.advance()
+			}
 		default:
-			matches = append(matches, matchPair{src: src, dst: dst})
+			err = match(dst, src)
+			if err == nil {
+				err = srcList.advance()
+			}
+			if err == nil {
+				err = dstList.advance()
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// noTraverseBatch is the number of source entries to look up in the
+// destination at once with --no-traverse
+const noTraverseBatch = 1024
+
+// matchNoTraverse reads the source entries with srcNext and looks
+// for an object with the same name in dstRemote for each one, for
+// use when the destination isn't listed.
+//
+// It calls srcOnly and match like matchListings.
+func (m *March) matchNoTraverse(dstRemote string, srcNext func() (fs.DirEntry, error),
+	srcOnly func(entry fs.DirEntry) error, match func(dst, src fs.DirEntry) error) error {
+	ci := fs.GetConfig(m.Ctx)
+	srcList := &matchCursor{next: srcNext, transforms: m.transforms, side: "source"}
+	for {
+		var batch fs.DirEntries
+		for len(batch) < noTraverseBatch {
+			err := srcList.advance()
+			if err != nil {
+				return err
+			}
+			if srcList.cur.entry == nil {
+				break
+			}
+			batch = append(batch, srcList.cur.entry)
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+
+		// Try to find a matching object for each item in the
+		// batch to head dst object
+		dstList := make([]fs.Object, len(batch))
+		if !m.NoCheckDest {
+			var wg sync.WaitGroup
+			limiter := make(chan struct{}, ci.Checkers)
+			for i, src := range batch {
+				srcObj, ok := src.(fs.Object)
+				if !ok {
+					continue
+				}
+				wg.Add(1)
+				limiter <- struct{}{}
+				go func(i int, srcObj fs.Object) {
+					defer wg.Done()
+					leaf := path.Base(srcObj.Remote())
+					dstObj, err := m.Fdst.NewObject(m.Ctx, path.Join(dstRemote, leaf))
+					if err == nil {
+						dstList[i] = dstObj
+					}
+					<-limiter
+				}(i, srcObj)
+			}
+			wg.Wait()
+		}
+
+		for i, src := range batch {
+			var err error
+			if dstList[i] != nil {
+				err = match(dstList[i], src)
+			} else {
+				err = srcOnly(src)
+			}
+			if err != nil {
+				return err
+			}
 		}
 	}
-	return
 }
 
 // processJob processes a listDirJob listing the source and
@@ -382,10 +462,9 @@ func matchListings(srcListEntries, dstListEntries fs.DirEntries, transforms []ma
 func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 	var (
 		jobs                   []listDirJob
-		srcList, dstList       fs.DirEntries
+		srcList, dstList       *list.Sorter
 		srcListErr, dstListErr error
 		wg                     sync.WaitGroup
-		mu                     sync.Mutex
 	)
 
 	// List the src and dst directories
@@ -406,6 +485,12 @@ func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 
 	// Wait for listings to complete and report errors
 	wg.Wait()
+	if srcList != nil {
+		defer srcList.CleanUp()
+	}
+	if dstList != nil {
+		defer dstList.CleanUp()
+	}
 	if srcListErr != nil {
 		if job.srcRemote != "" {
 			fs.Errorf(job.srcRemote, "error reading source directory: %v", srcListErr)
@@ -427,36 +512,21 @@ func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 		return nil, dstListErr
 	}
 
-	// If NoTraverse is set, then try to find a matching object
-	// for each item in the srcList to head dst object
-	ci := fs.GetConfig(m.Ctx)
-	limiter := make(chan struct{}, ci.Checkers)
-	if m.NoTraverse && !m.NoCheckDest {
-		for _, src := range srcList {
-			wg.Add(1)
-			limiter <- struct{}{}
-			go func(limiter chan struct{}, src fs.DirEntry) {
-				defer wg.Done()
-				if srcObj, ok := src.(fs.Object); ok {
-					leaf := path.Base(srcObj.Remote())
-					dstObj, err := m.Fdst.NewObject(m.Ctx, path.Join(job.dstRemote, leaf))
-					if err == nil {
-						mu.Lock()
-						dstList = append(dstList, dstObj)
-						mu.Unlock()
-					}
-				}
-				<-limiter
-			}(limiter, src)
+	// The entries are read back from the listings as they are
+	// matched so they don't all have to be in memory at once
+	next := func(ls *list.Sorter) func() (fs.DirEntry, error) {
+		if ls == nil {
+			return func() (fs.DirEntry, error) {
+				return nil, io.EOF
+			}
 		}
-		wg.Wait()
+		return ls.Next
 	}
 
 	// Work out what to do and do it
-	srcOnly, dstOnly, matches := matchListings(srcList, dstList, m.transforms)
-	for _, src := range srcOnly {
+	srcOnly := func(src fs.DirEntry) error {
 		if m.aborting() {
-			return nil, m.Ctx.Err()
+			return m.Ctx.Err()
 		}
 		recurse := m.Callback.SrcOnly(src)
 		if recurse && job.srcDepth > 0 {
@@ -467,11 +537,11 @@ func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 				noDst:     true,
 			})
 		}
-
+		return nil
 	}
-	for _, dst := range dstOnly {
+	dstOnly := func(dst fs.DirEntry) error {
 		if m.aborting() {
-			return nil, m.Ctx.Err()
+			return m.Ctx.Err()
 		}
 		recurse := m.Callback.DstOnly(dst)
 		if recurse && job.dstDepth > 0 {
@@ -482,20 +552,35 @@ func (m *March) processJob(job listDirJob) ([]listDirJob, error) {
 				noSrc:     true,
 			})
 		}
+		return nil
 	}
-	for _, match := range matches {
+	match := func(dst, src fs.DirEntry) error {
 		if m.aborting() {
-			return nil, m.Ctx.Err()
+			return m.Ctx.Err()
 		}
-		recurse := m.Callback.Match(m.Ctx, match.dst, match.src)
+		recurse := m.Callback.Match(m.Ctx, dst, src)
 		if recurse && job.srcDepth > 0 && job.dstDepth > 0 {
 			jobs = append(jobs, listDirJob{
-				srcRemote: match.src.Remote(),
-				dstRemote: match.dst.Remote(),
+				srcRemote: src.Remote(),
+				dstRemote: dst.Remote(),
 				srcDepth:  job.srcDepth - 1,
 				dstDepth:  job.dstDepth - 1,
 			})
 		}
+		return nil
+	}
+	var err error
+	if m.NoTraverse {
+		err = m.matchNoTraverse(job.dstRemote, next(srcList), srcOnly, match)
+	} else {
+		err = matchListings(next(srcList), next(dstList), m.transforms, srcOnly, dstOnly, match)
+	}
+	if err != nil {
+		if m.aborting() {
+			return nil, m.Ctx.Err()
+		}
+		fs.Errorf(job.srcRemote, "error reading directory listings: %v", err)
+		return nil, fs.CountError(err)
 	}
 	return jobs, nil
 }
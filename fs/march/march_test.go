@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"sync"
 	"testing"
@@ -14,6 +15,7 @@ import (
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/list"
 	"github.com/rclone/rclone/fstest"
 	"github.com/rclone/rclone/fstest/mockdir"
 	"github.com/rclone/rclone/fstest/mockobject"
@@ -139,6 +141,13 @@ func (mt *marchTester) aborting() bool {
 }
 
 func TestMarch(t *testing.T) {
+	// Run with the listings sorted in memory and on disk
+	for _, listCutoff := range []int{0, 1} {
+		testMarch(t, listCutoff)
+	}
+}
+
+func testMarch(t *testing.T, listCutoff int) {
 	for _, test := range []struct {
 		what        string
 		fileSrcOnly []string
@@ -168,7 +177,7 @@ func TestMarch(t *testing.T) {
 			dirDstOnly:  []string{"dstOnlyDir"},
 		},
 	} {
-		t.Run(fmt.Sprintf("TestMarch-%s", test.what), func(t *testing.T) {
+		t.Run(fmt.Sprintf("TestMarch-%s-cutoff-%d", test.what, listCutoff), func(t *testing.T) {
 			r := fstest.NewRun(t)
 			defer r.Finalise()
 
@@ -176,7 +185,9 @@ func TestMarch(t *testing.T) {
 			var dstOnly []fstest.Item
 			var match []fstest.Item
 
-			ctx, cancel := context.WithCancel(context.Background())
+			ctx, ci := fs.AddConfig(context.Background())
+			ci.ListCutoff = listCutoff
+			ctx, cancel := context.WithCancel(ctx)
 
 			for _, f := range test.fileSrcOnly {
 				srcOnly = append(srcOnly, r.WriteFile(f, "hello world", t1))
@@ -284,7 +295,26 @@ func TestMarchNoTraverse(t *testing.T) {
 	}
 }
 
-func TestNewMatchEntries(t *testing.T) {
+// sortEntries sorts the entries with matchKey
+func sortEntries(t *testing.T, entries fs.DirEntries, transforms []matchTransformFn) *list.Sorter {
+	ls := list.NewSorter(context.Background(), nil, matchKey(transforms))
+	require.NoError(t, ls.Add(entries))
+	return ls
+}
+
+// readEntries reads all the entries from ls
+func readEntries(t *testing.T, ls *list.Sorter) (entries fs.DirEntries) {
+	for {
+		entry, err := ls.Next()
+		if err == io.EOF {
+			return entries
+		}
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+}
+
+func TestMatchKey(t *testing.T) {
 	var (
 		a = mockobject.Object("path/a")
 		A = mockobject.Object("path/A")
@@ -292,21 +322,16 @@ func TestNewMatchEntries(t *testing.T) {
 		c = mockobject.Object("path/c")
 	)
 
-	es := newMatchEntries(fs.DirEntries{a, A, B, c}, nil)
-	assert.Equal(t, es, matchEntries{
-		{name: "A", leaf: "A", entry: A},
-		{name: "B", leaf: "B", entry: B},
-		{name: "a", leaf: "a", entry: a},
-		{name: "c", leaf: "c", entry: c},
-	})
-
-	es = newMatchEntries(fs.DirEntries{a, A, B, c}, []matchTransformFn{strings.ToLower})
-	assert.Equal(t, es, matchEntries{
-		{name: "a", leaf: "A", entry: A},
-		{name: "a", leaf: "a", entry: a},
-		{name: "b", leaf: "B", entry: B},
-		{name: "c", leaf: "c", entry: c},
-	})
+	es := readEntries(t, sortEntries(t, fs.DirEntries{a, A, B, c}, nil))
+	assert.Equal(t, fs.DirEntries{A, B, a, c}, es)
+
+	es = readEntries(t, sortEntries(t, fs.DirEntries{a, A, B, c}, []matchTransformFn{strings.ToLower}))
+	assert.Equal(t, fs.DirEntries{A, a, B, c}, es)
+}
+
+// matchPair is a matched pair of direntries found by matchListings
+type matchPair struct {
+	src, dst fs.DirEntry
 }
 
 func TestMatchListings(t *testing.T) {
@@ -519,12 +544,27 @@ func TestMatchListings(t *testing.T) {
 					dstList = append(dstList, dst)
 				}
 			}
-			srcOnly, dstOnly, matches := matchListings(srcList, dstList, test.transforms)
+			doMatch := func(srcList, dstList fs.DirEntries) (srcOnly fs.DirEntries, dstOnly fs.DirEntries, matches []matchPair) {
+				err := matchListings(sortEntries(t, srcList, test.transforms).Next, sortEntries(t, dstList, test.transforms).Next, test.transforms,
+					func(src fs.DirEntry) error {
+						srcOnly = append(srcOnly, src)
+						return nil
+					}, func(dst fs.DirEntry) error {
+						dstOnly = append(dstOnly, dst)
+						return nil
+					}, func(dst, src fs.DirEntry) error {
+						matches = append(matches, matchPair{src: src, dst: dst})
+						return nil
+					})
+				require.NoError(t, err)
+				return srcOnly, dstOnly, matches
+			}
+			srcOnly, dstOnly, matches := doMatch(srcList, dstList)
 			assert.Equal(t, test.srcOnly, srcOnly, test.what, "srcOnly differ")
 			assert.Equal(t, test.dstOnly, dstOnly, test.what, "dstOnly differ")
 			assert.Equal(t, test.matches, matches, test.what, "matches differ")
 			// now swap src and dst
-			dstOnly, srcOnly, matches = matchListings(dstList, srcList, test.transforms)
+			dstOnly, srcOnly, matches = doMatch(dstList, srcList)
 			assert.Equal(t, test.srcOnly, srcOnly, test.what, "srcOnly differ")
 			assert.Equal(t, test.dstOnly, dstOnly, test.what, "dstOnly differ")
 			assert.Equal(t, test.matches, matches, test.what, "matches differ")
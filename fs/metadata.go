@@ -0,0 +1,120 @@
+package fs
+
+import (
+	"context"
+	"sort"
+)
+
+// Metadata represents Object metadata in a standardised form
+//
+// The keys are lower case and the values are strings. The standard
+// keys which backends should use where they can are
+//
+//	mtime        - time of last modification in RFC 3339 format
+//	atime        - time of last access in RFC 3339 format
+//	btime        - time of file birth (creation) in RFC 3339 format
+//	mode         - file type and permissions in octal, e.g. 0100664
+//	uid          - user ID of the owner
+//	gid          - group ID of the owner
+//	content-type - the MIME type of the object
+//
+// Extended attributes and custom key/values are stored under other
+// keys as supported by the backend.
+type Metadata map[string]string
+
+// Set k to v on m
+//
+// If m is nil, then it will get made
+func (m *Metadata) Set(k, v string) {
+	if *m == nil {
+		*m = make(Metadata, 1)
+	}
+	(*m)[k] = v
+}
+
+// Merge other into m
+//
+// If m is nil, then it will get made
+func (m *Metadata) Merge(other Metadata) {
+	for k, v := range other {
+		if *m == nil {
+			*m = make(Metadata, len(other))
+		}
+		(*m)[k] = v
+	}
+}
+
+// Keys returns the keys of m sorted
+func (m Metadata) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// MetadataHelp represents help for a bit of system metadata
+type MetadataHelp struct {
+	Help     string
+	Type     string
+	Example  string
+	ReadOnly bool
+}
+
+// MetadataInfo is help for the metadata a backend supports
+type MetadataInfo struct {
+	System map[string]MetadataHelp // the system metadata keys
+	Help   string                  // extra help on user metadata
+}
+
+// Metadataer is an optional interface for Object
+type Metadataer interface {
+	// Metadata returns metadata for an object
+	//
+	// It should return nil if there is no Metadata
+	Metadata(ctx context.Context) (Metadata, error)
+}
+
+// GetMetadata from an ObjectInfo
+//
+// If the object has no metadata then metadata will be nil
+func GetMetadata(ctx context.Context, o ObjectInfo) (metadata Metadata, err error) {
+	do, ok := o.(Metadataer)
+	if !ok {
+		return nil, nil
+	}
+	return do.Metadata(ctx)
+}
+
+// SetMetadataer is an optional interface for Object
+type SetMetadataer interface {
+	// SetMetadata sets metadata on an existing object
+	//
+	// Only the keys in metadata are changed, the others are left
+	// alone. It should return ErrorNotImplemented if it can't set
+	// metadata.
+	SetMetadata(ctx context.Context, metadata Metadata) error
+}
+
+// SetMetadata sets the metadata on o if it supports it
+//
+// It returns ErrorNotImplemented if it doesn't.
+func SetMetadata(ctx context.Context, o Object, metadata Metadata) error {
+	do, ok := o.(SetMetadataer)
+	if !ok {
+		return ErrorNotImplemented
+	}
+	return do.SetMetadata(ctx, metadata)
+}
+
+// GetMetadataOptions returns the Metadata passed in with a
+// MetadataOption in options or nil if there isn't one
+func GetMetadataOptions(options []OpenOption) (metadata Metadata) {
+	for _, option := range options {
+		if x, ok := option.(MetadataOption); ok {
+			metadata.Merge(Metadata(x))
+		}
+	}
+	return metadata
+}
@@ -0,0 +1,88 @@
+package fs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataSet(t *testing.T) {
+	var m Metadata
+	assert.Nil(t, m)
+	m.Set("key", "value")
+	assert.NotNil(t, m)
+	assert.Equal(t, "value", m["key"])
+	m.Set("key", "value2")
+	assert.Equal(t, "value2", m["key"])
+}
+
+func TestMetadataMerge(t *testing.T) {
+	var m Metadata
+	m.Merge(nil)
+	assert.Nil(t, m)
+	m.Merge(Metadata{"a": "1", "b": "2"})
+	m.Merge(Metadata{"b": "3", "c": "4"})
+	assert.Equal(t, Metadata{"a": "1", "b": "3", "c": "4"}, m)
+	assert.Equal(t, []string{"a", "b", "c"}, m.Keys())
+}
+
+type metadataObject struct {
+	ObjectInfo
+	metadata Metadata
+}
+
+func (o metadataObject) Metadata(ctx context.Context) (Metadata, error) {
+	return o.metadata, nil
+}
+
+func TestGetMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	metadata, err := GetMetadata(ctx, metadataObject{})
+	require.NoError(t, err)
+	assert.Nil(t, metadata)
+
+	want := Metadata{"mtime": "2022-05-10T11:03:01Z"}
+	metadata, err = GetMetadata(ctx, metadataObject{metadata: want})
+	require.NoError(t, err)
+	assert.Equal(t, want, metadata)
+
+	// Objects without the Metadataer interface have no metadata
+	metadata, err = GetMetadata(ctx, struct{ ObjectInfo }{})
+	require.NoError(t, err)
+	assert.Nil(t, metadata)
+}
+
+type setMetadataObject struct {
+	Object
+	metadata Metadata
+}
+
+func (o *setMetadataObject) SetMetadata(ctx context.Context, metadata Metadata) error {
+	o.metadata.Merge(metadata)
+	return nil
+}
+
+func TestSetMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	o := &setMetadataObject{metadata: Metadata{"a": "1"}}
+	require.NoError(t, SetMetadata(ctx, o, Metadata{"b": "2"}))
+	assert.Equal(t, Metadata{"a": "1", "b": "2"}, o.metadata)
+
+	// Objects without the SetMetadataer interface can't set metadata
+	err := SetMetadata(ctx, struct{ Object }{}, Metadata{"b": "2"})
+	assert.Equal(t, ErrorNotImplemented, err)
+}
+
+func TestGetMetadataOptions(t *testing.T) {
+	assert.Nil(t, GetMetadataOptions(nil))
+	assert.Nil(t, GetMetadataOptions([]OpenOption{&HashesOption{}}))
+	assert.Equal(t, Metadata{"a": "1", "b": "2"}, GetMetadataOptions([]OpenOption{
+		MetadataOption{"a": "1"},
+		NullOption{},
+		MetadataOption{"b": "2"},
+	}))
+}
@@ -283,3 +283,22 @@ func OpenOptionAddHTTPHeaders(headers http.Header, options []OpenOption) {
 		}
 	}
 }
+
+// MetadataOption defines an Option used to pass the Metadata to set
+// on the Object to Put and Update
+type MetadataOption Metadata
+
+// Header formats the option as an http header
+func (o MetadataOption) Header() (key string, value string) {
+	return "", ""
+}
+
+// String formats the option into human-readable form
+func (o MetadataOption) String() string {
+	return fmt.Sprintf("MetadataOption(%v)", Metadata(o))
+}
+
+// Mandatory returns whether the option must be parsed or can be ignored
+func (o MetadataOption) Mandatory() bool {
+	return false
+}
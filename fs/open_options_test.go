@@ -132,6 +132,16 @@ func TestNullOption(t *testing.T) {
 	assert.Equal(t, false, opt.Mandatory())
 }
 
+func TestMetadataOption(t *testing.T) {
+	opt := MetadataOption{"mtime": "2022-05-10T11:03:01Z"}
+	var _ OpenOption = opt // check interface
+	assert.Equal(t, "MetadataOption(map[mtime:2022-05-10T11:03:01Z])", opt.String())
+	key, value := opt.Header()
+	assert.Equal(t, "", key)
+	assert.Equal(t, "", value)
+	assert.Equal(t, false, opt.Mandatory())
+}
+
 func TestFixRangeOptions(t *testing.T) {
 	for _, test := range []struct {
 		name string
@@ -0,0 +1,50 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// AppendOnlyError is returned when --append-only refuses an operation
+// which would overwrite or delete existing data
+type AppendOnlyError struct {
+	Op     string // the operation refused, e.g. "overwrite" or "delete"
+	Remote string // the file or directory it was refused for
+}
+
+// Error satisfies the error interface
+func (e *AppendOnlyError) Error() string {
+	return fmt.Sprintf("--append-only: refusing to %s %q", e.Op, e.Remote)
+}
+
+// NoRetry returns true as retrying won't change the outcome
+func (e *AppendOnlyError) NoRetry() bool {
+	return true
+}
+
+// checkAppendOnly returns an *AppendOnlyError if --append-only is set
+func checkAppendOnly(ctx context.Context, op string, remote string) error {
+	if !fs.GetConfig(ctx).AppendOnly {
+		return nil
+	}
+	return &AppendOnlyError{Op: op, Remote: remote}
+}
+
+// checkAppendOnlyNew returns an *AppendOnlyError if --append-only is
+// set and remote already exists in f so writing it would overwrite it
+func checkAppendOnlyNew(ctx context.Context, f fs.Fs, remote string) error {
+	if !fs.GetConfig(ctx).AppendOnly {
+		return nil
+	}
+	_, err := f.NewObject(ctx, remote)
+	switch err {
+	case nil:
+	case fs.ErrorObjectNotFound, fs.ErrorDirNotFound, fs.ErrorIsDir, fs.ErrorNotAFile:
+		return nil
+	default:
+		return fmt.Errorf("--append-only: failed to check %q doesn't exist: %w", remote, err)
+	}
+	return &AppendOnlyError{Op: "overwrite", Remote: remote}
+}
@@ -0,0 +1,82 @@
+package operations_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// checkAppendOnlyError checks err is an *operations.AppendOnlyError
+// refusing op
+func checkAppendOnlyError(t *testing.T, err error, op string) {
+	var appendOnlyErr *operations.AppendOnlyError
+	require.True(t, errors.As(err, &appendOnlyErr), "expecting AppendOnlyError, got %v", err)
+	assert.Equal(t, op, appendOnlyErr.Op)
+	assert.True(t, fserrors.IsNoRetryError(err))
+}
+
+func TestAppendOnly(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ci.AppendOnly = true
+
+	file1 := r.WriteObject(ctx, "file1", "file1 contents", t1)
+	file2 := r.WriteFile("file2", "file2 contents", t1)
+	file3 := r.WriteFile("file3", "file3 contents", t1)
+	r.CheckRemoteItems(t, file1)
+
+	remoteFile1, err := r.Fremote.NewObject(ctx, "file1")
+	require.NoError(t, err)
+	localFile2, err := r.Flocal.NewObject(ctx, "file2")
+	require.NoError(t, err)
+	localFile3, err := r.Flocal.NewObject(ctx, "file3")
+	require.NoError(t, err)
+
+	// New files can be copied
+	_, err = operations.Copy(ctx, r.Fremote, nil, "file2", localFile2)
+	require.NoError(t, err)
+	r.CheckRemoteItems(t, file1, file2)
+
+	// Existing files can't be overwritten
+	_, err = operations.Copy(ctx, r.Fremote, remoteFile1, "file1", localFile2)
+	checkAppendOnlyError(t, err, "overwrite")
+	_, err = operations.Rcat(ctx, r.Fremote, "file1", ioutil.NopCloser(bytes.NewBufferString("new")), t2)
+	checkAppendOnlyError(t, err, "overwrite")
+	_, err = operations.RcatSize(ctx, r.Fremote, "file1", ioutil.NopCloser(bytes.NewBufferString("new")), 3, t2)
+	checkAppendOnlyError(t, err, "overwrite")
+
+	// Files can't be deleted or moved within the destination
+	err = operations.DeleteFile(ctx, remoteFile1)
+	checkAppendOnlyError(t, err, "delete")
+	_, err = operations.Move(ctx, r.Fremote, nil, "file1-moved", remoteFile1)
+	checkAppendOnlyError(t, err, "move")
+	err = operations.Purge(ctx, r.Fremote, "")
+	checkAppendOnlyError(t, err, "purge")
+	r.CheckRemoteItems(t, file1, file2)
+
+	// Files can be moved from a different remote
+	_, err = operations.Move(ctx, r.Fremote, nil, "file3", localFile3)
+	if operations.SameConfig(r.Flocal, r.Fremote) {
+		checkAppendOnlyError(t, err, "move")
+	} else {
+		require.NoError(t, err)
+		r.CheckRemoteItems(t, file1, file2, file3)
+		r.CheckLocalItems(t, file2)
+	}
+
+	// --backup-dir can't be used
+	ci.BackupDir = r.FremoteName + "/backup"
+	_, err = operations.BackupDir(ctx, r.Fremote, r.Flocal, "")
+	assert.True(t, fserrors.IsFatalError(err))
+}
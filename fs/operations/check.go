@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -38,6 +39,7 @@ type CheckOpt struct {
 	Check        checkFn   // function to use for checking
 	OneWay       bool      // one way only?
 	Combined     io.Writer // a file with file names with leading sigils
+	CombinedJSON bool      // write Combined as JSON records instead
 	MissingOnSrc io.Writer // files only in the destination
 	MissingOnDst io.Writer // files only in the source
 	Match        io.Writer // matching files
@@ -45,6 +47,21 @@ type CheckOpt struct {
 	Error        io.Writer // files with errors of some kind
 }
 
+// CheckRecord is a line of the combined report when written as JSON
+type CheckRecord struct {
+	Path   string `json:"path"`
+	Status string `json:"status"` // match, differ, missingOnSrc, missingOnDst or error
+}
+
+// checkStatus is the status in the JSON report for each sigil
+var checkStatus = map[rune]string{
+	'=': "match",
+	'*': "differ",
+	'-': "missingOnSrc",
+	'+': "missingOnDst",
+	'!': "error",
+}
+
 // checkMarch is used to march over two Fses in the same way as
 // sync/copy
 type checkMarch struct {
@@ -69,7 +86,15 @@ func (c *checkMarch) reportFilename(filename string, out io.Writer, sigil rune)
 		syncFprintf(out, "%s\n", filename)
 	}
 	if c.opt.Combined != nil {
-		syncFprintf(c.opt.Combined, "%c %s\n", sigil, filename)
+		if c.opt.CombinedJSON {
+			buf, _ := json.Marshal(CheckRecord{
+				Path:   filename,
+				Status: checkStatus[sigil],
+			})
+			syncFprintf(c.opt.Combined, "%s\n", buf)
+		} else {
+			syncFprintf(c.opt.Combined, "%c %s\n", sigil, filename)
+		}
 	}
 }
 
@@ -3,6 +3,7 @@ package operations_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -184,6 +185,45 @@ func TestCheck(t *testing.T) {
 	testCheck(t, operations.Check)
 }
 
+func TestCheckCombinedJSON(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ctx := context.Background()
+
+	t1 := fstest.Time("2001-02-03T04:05:06.499999999Z")
+	file1 := r.WriteBoth(ctx, "both", "same", t1)
+	file2 := r.WriteFile("src only", "src", t1)
+	file3 := r.WriteObject(ctx, "dst only", "dst", t1)
+	r.CheckLocalItems(t, file1, file2)
+	r.CheckRemoteItems(t, file1, file3)
+
+	var buf bytes.Buffer
+	opt := operations.CheckOpt{
+		Fdst:         r.Fremote,
+		Fsrc:         r.Flocal,
+		Combined:     &buf,
+		CombinedJSON: true,
+	}
+	err := operations.Check(ctx, &opt)
+	require.Error(t, err)
+
+	var got []operations.CheckRecord
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var record operations.CheckRecord
+		require.NoError(t, dec.Decode(&record))
+		got = append(got, record)
+	}
+	sort.Slice(got, func(i, j int) bool {
+		return got[i].Path < got[j].Path
+	})
+	assert.Equal(t, []operations.CheckRecord{
+		{Path: "both", Status: "match"},
+		{Path: "dst only", Status: "missingOnSrc"},
+		{Path: "src only", Status: "missingOnDst"},
+	}, got)
+}
+
 func TestCheckFsError(t *testing.T) {
 	ctx := context.Background()
 	dstFs, err := fs.NewFs(ctx, "non-existent")
@@ -0,0 +1,106 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/lib/atexit"
+)
+
+// Suffixes added to the names of the source and destination files
+// when a conflict is resolved with --conflict-resolve rename-both
+const (
+	ConflictSuffixSource = ".conflict-source"
+	ConflictSuffixDest   = ".conflict-dest"
+)
+
+// ResolveConflict decides what to do when src needs transferring but
+// a different dst already exists, according to --conflict-resolve.
+//
+// It returns skip true if dst should be kept. Otherwise src should be
+// transferred to remote over newDst. If rename-both moved dst out of
+// the way then remote is the new name for src and newDst is the
+// object already at that name, if any.
+func ResolveConflict(ctx context.Context, fdst fs.Fs, dst, src fs.Object) (remote string, newDst fs.Object, skip bool, err error) {
+	ci := fs.GetConfig(ctx)
+	remote = src.Remote()
+	resolve := ci.ConflictResolve
+	if resolve == fs.ConflictResolvePrompt {
+		resolve = conflictChoose(ctx, dst, src)
+	}
+	switch resolve {
+	case fs.ConflictResolveSource:
+		return remote, dst, false, nil
+	case fs.ConflictResolveDest:
+		fs.Logf(src, "Keeping destination as it differs and --conflict-resolve is %v", resolve)
+		return remote, dst, true, nil
+	case fs.ConflictResolveNewer:
+		modifyWindow := fs.GetModifyWindow(ctx, dst.Fs(), src.Fs())
+		if modifyWindow == fs.ModTimeNotSupported {
+			modifyWindow = time.Second
+		}
+		if dst.ModTime(ctx).Sub(src.ModTime(ctx)) >= modifyWindow {
+			fs.Logf(src, "Keeping destination as it is newer than the source")
+			return remote, dst, true, nil
+		}
+		return remote, dst, false, nil
+	case fs.ConflictResolveLarger:
+		if dst.Size() > src.Size() {
+			fs.Logf(src, "Keeping destination as it is larger than the source")
+			return remote, dst, true, nil
+		}
+		return remote, dst, false, nil
+	case fs.ConflictResolveRenameBoth:
+		dstRemote := dst.Remote() + ConflictSuffixDest
+		existing, err := fdst.NewObject(ctx, dstRemote)
+		if err != nil {
+			existing = nil
+		}
+		_, err = Move(ctx, fdst, existing, dstRemote, dst)
+		if err != nil {
+			return remote, dst, true, fmt.Errorf("failed to rename destination to resolve conflict: %w", err)
+		}
+		remote += ConflictSuffixSource
+		newDst, err = fdst.NewObject(ctx, remote)
+		if err != nil {
+			newDst = nil
+		}
+		fs.Infof(src, "Keeping both versions as %q and %q", remote, dstRemote)
+		return remote, newDst, false, nil
+	}
+	return remote, dst, true, fmt.Errorf("unknown --conflict-resolve %v", resolve)
+}
+
+// conflictChoose asks the user how to resolve the conflict between
+// dst and src
+func conflictChoose(ctx context.Context, dst, src fs.Object) fs.ConflictResolve {
+	interactiveMu.Lock()
+	defer interactiveMu.Unlock()
+	fmt.Printf("rclone: %q differs on the destination\n", src.Remote())
+	fmt.Printf("  source:      size %v, modified %v\n", fs.SizeSuffix(src.Size()), src.ModTime(ctx).Local().Format(time.RFC3339))
+	fmt.Printf("  destination: size %v, modified %v\n", fs.SizeSuffix(dst.Size()), dst.ModTime(ctx).Local().Format(time.RFC3339))
+	switch i := config.CommandDefault([]string{
+		"sUse the source",
+		"dKeep the destination",
+		"rRename both and keep them",
+		"qExit rclone now.",
+	}, 0); i {
+	case 's':
+		return fs.ConflictResolveSource
+	case 'd':
+		return fs.ConflictResolveDest
+	case 'r':
+		return fs.ConflictResolveRenameBoth
+	case 'q':
+		fs.Logf(nil, "Quitting rclone now")
+		atexit.Run()
+		os.Exit(0)
+	default:
+		fs.Errorf(nil, "Bad choice %c", i)
+	}
+	return fs.ConflictResolveDest
+}
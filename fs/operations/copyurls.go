@@ -0,0 +1,140 @@
+package operations
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+)
+
+// CopyURLItem is a URL to download with CopyURLs
+type CopyURLItem struct {
+	URL    string // the URL to download
+	Remote string // where to put it - if empty the name is taken from the URL
+}
+
+// CopyURLResult is the outcome of downloading a CopyURLItem
+type CopyURLResult struct {
+	URL     string `json:"url"`
+	Remote  string `json:"remote"`          // the destination, empty if it couldn't be found from the URL
+	Size    int64  `json:"size"`            // size of the object written or -1 if it failed
+	Tries   int    `json:"tries"`           // number of attempts made
+	Success bool   `json:"success"`         // set if the download succeeded
+	Error   string `json:"error,omitempty"` // the last error if it failed
+}
+
+// copyURLsRetrySleep is the time to wait before the first retry of a
+// URL - it doubles for each retry after that
+var copyURLsRetrySleep = time.Second
+
+// ReadCopyURLItems reads a list of URLs to download from in
+//
+// There is one URL per line, optionally followed by white space and
+// the destination path. Blank lines and lines starting with '#' or
+// ';' are ignored.
+func ReadCopyURLItems(in io.Reader) (items []CopyURLItem, err error) {
+	scanner := bufio.NewScanner(in)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		var item CopyURLItem
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			item.URL, item.Remote = line[:i], strings.TrimSpace(line[i:])
+		} else {
+			item.URL = line
+		}
+		if !strings.Contains(item.URL, "://") {
+			return nil, fmt.Errorf("line %d: %q is not a URL", lineNumber, item.URL)
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// copyURLWithRetries downloads item into fdst trying up to retries
+// times
+func copyURLWithRetries(ctx context.Context, fdst fs.Fs, item CopyURLItem, noClobber bool, retries int) (result CopyURLResult) {
+	result = CopyURLResult{
+		URL:    item.URL,
+		Remote: item.Remote,
+		Size:   -1,
+	}
+	sleep := copyURLsRetrySleep
+	var err error
+	for result.Tries < retries {
+		result.Tries++
+		var dst fs.Object
+		dst, err = CopyURL(ctx, fdst, item.Remote, item.URL, item.Remote == "", noClobber)
+		if err == nil {
+			result.Remote = dst.Remote()
+			result.Size = dst.Size()
+			result.Success = true
+			return result
+		}
+		if ctx.Err() != nil || fserrors.IsNoRetryError(err) || fserrors.IsFatalError(err) || result.Tries >= retries {
+			break
+		}
+		fs.Debugf(item.URL, "Attempt %d/%d failed - retrying in %v: %v", result.Tries, retries, sleep, err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(sleep):
+		}
+		sleep *= 2
+	}
+	err = fs.CountError(err)
+	fs.Errorf(item.URL, "Failed to copy URL after %d attempts: %v", result.Tries, err)
+	result.Error = err.Error()
+	return result
+}
+
+// CopyURLs downloads items into fdst, --transfers at once, trying each
+// one up to retries times.
+//
+// It returns a result for each item in the same order as items and
+// an error if any of them failed.
+func CopyURLs(ctx context.Context, fdst fs.Fs, items []CopyURLItem, noClobber bool, retries int) (results []CopyURLResult, err error) {
+	ci := fs.GetConfig(ctx)
+	if retries < 1 {
+		retries = 1
+	}
+	results = make([]CopyURLResult, len(items))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < ci.Transfers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = copyURLWithRetries(ctx, fdst, items[i], noClobber, retries)
+			}
+		}()
+	}
+	for i := range items {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	failed := 0
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("failed to copy %d of %d URLs", failed, len(items))
+	}
+	return results, nil
+}
@@ -0,0 +1,108 @@
+package operations_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCopyURLItems(t *testing.T) {
+	items, err := operations.ReadCopyURLItems(strings.NewReader(`
+# comment
+; comment
+https://example.com/file1
+	https://example.com/file2  dir/file 2
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []operations.CopyURLItem{
+		{URL: "https://example.com/file1"},
+		{URL: "https://example.com/file2", Remote: "dir/file 2"},
+	}, items)
+
+	_, err = operations.ReadCopyURLItems(strings.NewReader("https://example.com/file1\nfile2\n"))
+	assert.EqualError(t, err, `line 2: "file2" is not a URL`)
+}
+
+func TestCopyURLs(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	r.Mkdir(ctx, r.Fremote)
+
+	contents := "file contents\n"
+	var mu sync.Mutex
+	requests := map[string]int{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests[req.URL.Path]++
+		n := requests[req.URL.Path]
+		mu.Unlock()
+		switch req.URL.Path {
+		case "/missing":
+			http.Error(w, "not here", http.StatusNotFound)
+			return
+		case "/flaky":
+			if n == 1 {
+				http.Error(w, "try again", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		_, err := w.Write([]byte(contents))
+		assert.NoError(t, err)
+	})
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	items := []operations.CopyURLItem{
+		{URL: ts.URL + "/file1.txt"},
+		{URL: ts.URL + "/flaky", Remote: "dir/flaky.txt"},
+		{URL: ts.URL + "/missing", Remote: "missing.txt"},
+	}
+	results, err := operations.CopyURLs(ctx, r.Fremote, items, false, 3)
+	assert.EqualError(t, err, "failed to copy 1 of 3 URLs")
+	require.Len(t, results, 3)
+
+	assert.Equal(t, operations.CopyURLResult{
+		URL:     ts.URL + "/file1.txt",
+		Remote:  "file1.txt",
+		Size:    int64(len(contents)),
+		Tries:   1,
+		Success: true,
+	}, results[0])
+	assert.Equal(t, operations.CopyURLResult{
+		URL:     ts.URL + "/flaky",
+		Remote:  "dir/flaky.txt",
+		Size:    int64(len(contents)),
+		Tries:   2,
+		Success: true,
+	}, results[1])
+
+	// Not Found isn't retried
+	assert.Equal(t, ts.URL+"/missing", results[2].URL)
+	assert.Equal(t, "missing.txt", results[2].Remote)
+	assert.Equal(t, int64(-1), results[2].Size)
+	assert.Equal(t, 1, results[2].Tries)
+	assert.False(t, results[2].Success)
+	assert.Contains(t, results[2].Error, "Not Found")
+
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{
+		fstest.NewItem("file1.txt", contents, t1),
+		fstest.NewItem("dir/flaky.txt", contents, t1),
+	}, []string{"dir"}, fs.ModTimeNotSupported)
+
+	// Existing files are left alone with noClobber
+	results, err = operations.CopyURLs(ctx, r.Fremote, items[:1], true, 3)
+	assert.Error(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, 1, results[0].Tries)
+	assert.Contains(t, results[0].Error, "already exist")
+}
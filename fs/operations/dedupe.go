@@ -4,6 +4,7 @@ package operations
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"path"
@@ -72,6 +73,40 @@ func dedupeDeleteAllButOne(ctx context.Context, keep int, remote string, objs []
 	}
 }
 
+// dedupeServerSideCopy replaces all but the one in keep with
+// server-side copies of it
+func dedupeServerSideCopy(ctx context.Context, f fs.Fs, keep int, remote string, objs []fs.Object) {
+	doCopy := f.Features().Copy
+	src := objs[keep]
+	count := 0
+	for i, o := range objs {
+		if i == keep || SkipDestructive(ctx, o, "replace with server-side copy") {
+			continue
+		}
+		if f.Features().DuplicateFiles {
+			// Copying would make another duplicate so remove the
+			// old one first
+			err := o.Remove(ctx)
+			if err != nil {
+				err = fs.CountError(err)
+				fs.Errorf(o, "Failed to remove before replacing with server-side copy: %v", err)
+				continue
+			}
+		}
+		newObj, err := doCopy(ctx, src, o.Remote())
+		if err != nil {
+			err = fs.CountError(err)
+			fs.Errorf(o, "Failed to replace with server-side copy of %q: %v", src.Remote(), err)
+			continue
+		}
+		fs.Debugf(newObj, "Replaced with server-side copy of %q", src.Remote())
+		count++
+	}
+	if count > 0 {
+		fs.Logf(remote, "Replaced %d duplicates with server-side copies of %q", count, src.Remote())
+	}
+}
+
 // dedupeDeleteIdentical deletes all but one of identical (by hash) copies
 func dedupeDeleteIdentical(ctx context.Context, ht hash.Type, remote string, objs []fs.Object) (remainingObjs []fs.Object) {
 	ci := fs.GetConfig(ctx)
@@ -192,6 +227,7 @@ const (
 	DeduplicateLargest                            // choose the largest object
 	DeduplicateSmallest                           // choose the smallest object
 	DeduplicateList                               // list duplicates only
+	DeduplicateCopy                               // replace with server-side copies of the oldest object
 )
 
 func (x DeduplicateMode) String() string {
@@ -214,6 +250,8 @@ func (x DeduplicateMode) String() string {
 		return "smallest"
 	case DeduplicateList:
 		return "list"
+	case DeduplicateCopy:
+		return "copy"
 	}
 	return "unknown"
 }
@@ -239,6 +277,8 @@ func (x *DeduplicateMode) Set(s string) error {
 		*x = DeduplicateSmallest
 	case "list":
 		*x = DeduplicateList
+	case "copy":
+		*x = DeduplicateCopy
 	default:
 		return fmt.Errorf("unknown mode for dedupe %q", s)
 	}
@@ -407,6 +447,14 @@ func Deduplicate(ctx context.Context, f fs.Fs, mode DeduplicateMode, byHash bool
 		}
 		what = ht.String() + " hashes"
 	}
+	if mode == DeduplicateCopy {
+		if !byHash {
+			return errors.New("dedupe mode copy needs --by-hash")
+		}
+		if f.Features().Copy == nil {
+			return fmt.Errorf("dedupe mode copy needs server-side copy which %v doesn't support", f)
+		}
+	}
 	fs.Infof(f, "Looking for duplicate %s using %v mode.", what, mode)
 
 	// Find duplicate directories first and fix them
@@ -492,6 +540,9 @@ func Deduplicate(ctx context.Context, f fs.Fs, mode DeduplicateMode, byHash bool
 			fs.Logf(remote, "Skipping %d files with duplicate %s", len(objs), what)
 		case DeduplicateList:
 			dedupeList(ctx, f, ht, remote, objs, byHash)
+		case DeduplicateCopy:
+			sortOldestFirst(objs)
+			dedupeServerSideCopy(ctx, f, 0, remote, objs)
 		default:
 			//skip
 		}
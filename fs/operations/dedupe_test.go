@@ -170,6 +170,34 @@ func TestDeduplicateNewestByHash(t *testing.T) {
 	r.CheckRemoteItems(t, file3, file4)
 }
 
+func TestDeduplicateCopyByHash(t *testing.T) {
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	skipIfNoHash(t, r.Fremote)
+	skipIfNoModTime(t, r.Fremote)
+	contents := random.String(100)
+
+	file1 := r.WriteObject(context.Background(), "one", contents, t1)
+	file2 := r.WriteObject(context.Background(), "also/one", contents, t2)
+	file3 := r.WriteObject(context.Background(), "not-one", "stuff", t3)
+	r.CheckRemoteItems(t, file1, file2, file3)
+
+	// Only by hash
+	err := operations.Deduplicate(context.Background(), r.Fremote, operations.DeduplicateCopy, false)
+	require.Error(t, err)
+
+	err = operations.Deduplicate(context.Background(), r.Fremote, operations.DeduplicateCopy, true)
+	if r.Fremote.Features().Copy == nil {
+		require.Error(t, err)
+		return
+	}
+	require.NoError(t, err)
+
+	// also/one is now a copy of the oldest
+	file2.ModTime = t1
+	r.CheckRemoteItems(t, file1, file2, file3)
+}
+
 func TestDeduplicateOldest(t *testing.T) {
 	r := fstest.NewRun(t)
 	defer r.Finalise()
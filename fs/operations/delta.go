@@ -0,0 +1,105 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/lib/delta"
+)
+
+// Return a boolean as to whether we should use a delta copy to update
+// dst with src
+func doDeltaCopy(ctx context.Context, dst fs.Object, src fs.Object) bool {
+	ci := fs.GetConfig(ctx)
+
+	// Disable delta copy if...
+
+	// ...it isn't configured
+	if !ci.Delta {
+		return false
+	}
+	// ...there is nothing to update
+	if dst == nil {
+		return false
+	}
+	// ...size of the source isn't known
+	if src.Size() < 0 {
+		return false
+	}
+	// ...destination doesn't support it
+	if _, ok := dst.(fs.Deltaer); !ok {
+		fs.Debugf(dst, "Not using --delta as the destination backend doesn't support it")
+		return false
+	}
+	return true
+}
+
+// deltaCopy updates dst with src sending only the blocks of src which
+// dst doesn't already have.
+//
+// It returns fs.ErrorCantCopy if dst can't do a delta copy after all.
+func deltaCopy(ctx context.Context, dst fs.Object, remote string, src fs.Object, tr *accounting.Transfer, hashOption fs.OpenOption) (err error) {
+	ci := fs.GetConfig(ctx)
+	deltaer := dst.(fs.Deltaer)
+	blockSize := int(ci.DeltaBlockSize)
+	if blockSize <= 0 {
+		blockSize = delta.BlockSize(src.Size())
+	}
+	sig, err := deltaer.Signature(ctx, blockSize)
+	if errors.Is(err, fs.ErrorNotImplemented) {
+		return fs.ErrorCantCopy
+	} else if err != nil {
+		return fmt.Errorf("failed to read signature of destination: %w", err)
+	}
+
+	openOptions := []fs.OpenOption{}
+	for _, option := range ci.DownloadHeaders {
+		openOptions = append(openOptions, option)
+	}
+	in0, err := NewReOpen(ctx, src, ci.LowLevelRetries, openOptions...)
+	if err != nil {
+		return fmt.Errorf("failed to open source object: %w", err)
+	}
+	in := tr.Account(ctx, in0).LimitRemotes(src.Fs(), dst.Fs()).WithBuffer() // account and buffer the transfer
+	defer fs.CheckClose(in, &err)
+
+	// Make the delta in the background while dst reads it
+	pr, pw := io.Pipe()
+	var literal int64
+	diffDone := make(chan struct{})
+	go func() {
+		defer close(diffDone)
+		var diffErr error
+		literal, diffErr = delta.Diff(pw, sig, in)
+		_ = pw.CloseWithError(diffErr)
+	}()
+
+	var wrappedSrc fs.ObjectInfo = src
+	// We try to pass the original object if possible
+	if src.Remote() != remote {
+		wrappedSrc = NewOverrideRemote(src, remote)
+	}
+	options := []fs.OpenOption{hashOption}
+	for _, option := range ci.UploadHeaders {
+		options = append(options, option)
+	}
+	err = deltaer.Patch(ctx, pr, wrappedSrc, options...)
+	if err == nil {
+		// Make sure the delta was read to the end
+		_, err = io.Copy(ioutil.Discard, pr)
+	}
+	if err != nil {
+		_ = pr.CloseWithError(err)
+	}
+	<-diffDone
+	if err != nil {
+		return err
+	}
+	fs.Debugf(src, "Delta copy sent %d of %d bytes as new data", literal, src.Size())
+	return nil
+}
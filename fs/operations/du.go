@@ -0,0 +1,89 @@
+package operations
+
+import (
+	"context"
+	"path"
+	"sort"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// DuDir is the disk usage of a directory and everything in it
+type DuDir struct {
+	Path  string   `json:"path"`            // path of the directory
+	Size  int64    `json:"size"`            // total size of the files in it and its subdirectories
+	Count int64    `json:"count"`           // total number of files in it and its subdirectories
+	Error string   `json:"error,omitempty"` // error reading the directory if any
+	Dirs  []*DuDir `json:"dirs,omitempty"`  // subdirectories sorted by path
+}
+
+// Du works out the disk usage of remote in f and all the directories
+// under it, like the scan done by ncdu.
+//
+// The whole tree is scanned so the sizes are complete, but only
+// depth levels of subdirectories are returned. If depth is < 0 they
+// are all returned.
+func Du(ctx context.Context, f fs.Fs, remote string, depth int) (*DuDir, error) {
+	ci := fs.GetConfig(ctx)
+	root := &DuDir{Path: remote}
+	dirs := map[string]*DuDir{remote: root}
+	// get the DuDir for dirPath making it and its parents if needed
+	var getDir func(dirPath string) *DuDir
+	getDir = func(dirPath string) *DuDir {
+		if d, ok := dirs[dirPath]; ok {
+			return d
+		}
+		d := &DuDir{Path: dirPath}
+		dirs[dirPath] = d
+		parentPath := path.Dir(dirPath)
+		if parentPath == "." {
+			parentPath = ""
+		}
+		parent := getDir(parentPath)
+		parent.Dirs = append(parent.Dirs, d)
+		return d
+	}
+	err := walk.Walk(ctx, f, remote, false, ci.MaxDepth, func(dirPath string, entries fs.DirEntries, err error) error {
+		d := getDir(dirPath)
+		if err != nil {
+			fs.Errorf(dirPath, "Failed to read directory: %v", err)
+			d.Error = err.Error()
+			return nil
+		}
+		for _, entry := range entries {
+			switch x := entry.(type) {
+			case fs.Object:
+				d.Count++
+				if size := x.Size(); size > 0 {
+					d.Size += size
+				}
+			case fs.Directory:
+				getDir(x.Remote())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	root.total(depth)
+	return root, nil
+}
+
+// total adds the sizes and counts of the subdirectories of d to it,
+// sorts them and removes those more than depth levels down
+func (d *DuDir) total(depth int) {
+	for _, sub := range d.Dirs {
+		sub.total(depth - 1)
+		d.Size += sub.Size
+		d.Count += sub.Count
+	}
+	if depth == 0 {
+		d.Dirs = nil
+		return
+	}
+	sort.Slice(d.Dirs, func(i, j int) bool {
+		return d.Dirs[i].Path < d.Dirs[j].Path
+	})
+}
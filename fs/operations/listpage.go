@@ -0,0 +1,157 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/lib/random"
+)
+
+// listCursorExpire is how long a paged listing is kept waiting for
+// the next page to be read
+var listCursorExpire = 5 * time.Minute
+
+// listCursorBuffer is the most items read ahead by a paged listing
+const listCursorBuffer = 1024
+
+// listCursor is a listing running in the background whose items are
+// read a page at a time
+type listCursor struct {
+	mu      sync.Mutex         // held while a page is being read
+	key     string             // the fs and remote being listed
+	items   chan *ListJSONItem // items as they are listed
+	err     error              // error from the listing - read after items is closed
+	pending *ListJSONItem      // item read from items but not returned yet
+	cancel  func()             // cancel the listing
+	timer   *time.Timer        // removes the cursor if it isn't used
+}
+
+// listCursors are the listings which have more pages to read by token
+var listCursors = struct {
+	mu      sync.Mutex
+	cursors map[string]*listCursor
+}{
+	cursors: make(map[string]*listCursor),
+}
+
+// removeListCursor cancels the listing with token and removes it
+func removeListCursor(token string) {
+	listCursors.mu.Lock()
+	cursor := listCursors.cursors[token]
+	delete(listCursors.cursors, token)
+	listCursors.mu.Unlock()
+	if cursor != nil {
+		cursor.timer.Stop()
+		cursor.cancel()
+	}
+}
+
+// newListCursor starts listing remote on fsrc in the background
+func newListCursor(ctx context.Context, fsrc fs.Fs, remote string, opt *ListJSONOpt, key string, maxResults int) (token string, cursor *listCursor, err error) {
+	token, err = random.Password(128)
+	if err != nil {
+		return "", nil, err
+	}
+	// The listing outlives this call so use a new context with
+	// the config and filters from ctx
+	listCtx := fs.CopyConfig(context.Background(), ctx)
+	listCtx = filter.CopyConfig(listCtx, ctx)
+	listCtx, cancel := context.WithCancel(listCtx)
+	if maxResults > listCursorBuffer {
+		maxResults = listCursorBuffer
+	}
+	cursor = &listCursor{
+		key:    key,
+		items:  make(chan *ListJSONItem, maxResults),
+		cancel: cancel,
+	}
+	go func() {
+		cursor.err = ListJSON(listCtx, fsrc, remote, opt, func(item *ListJSONItem) error {
+			select {
+			case cursor.items <- item:
+				return nil
+			case <-listCtx.Done():
+				return listCtx.Err()
+			}
+		})
+		close(cursor.items)
+	}()
+	cursor.timer = time.AfterFunc(listCursorExpire, func() {
+		fs.Debugf(fsrc, "Removing unused paged listing of %q", remote)
+		removeListCursor(token)
+	})
+	listCursors.mu.Lock()
+	listCursors.cursors[token] = cursor
+	listCursors.mu.Unlock()
+	return token, cursor, nil
+}
+
+// ListJSONPage lists up to maxResults items of remote on fsrc using
+// the options in opt.
+//
+// If there are more items to read then it returns a token which
+// should be passed in as token to read the next page with the same
+// fsrc, remote and opt. If token is empty a new listing is started.
+//
+// The listing carries on in the background between pages so the
+// items aren't all held in memory. It is abandoned if the next page
+// isn't read within 5 minutes.
+func ListJSONPage(ctx context.Context, fsrc fs.Fs, remote string, opt *ListJSONOpt, maxResults int, token string) (list []*ListJSONItem, nextToken string, err error) {
+	if maxResults <= 0 {
+		return nil, "", errors.New("maxResults must be > 0")
+	}
+	key := fs.ConfigString(fsrc) + "\x00" + remote
+	var cursor *listCursor
+	if token == "" {
+		token, cursor, err = newListCursor(ctx, fsrc, remote, opt, key, maxResults)
+		if err != nil {
+			return nil, "", err
+		}
+	} else {
+		listCursors.mu.Lock()
+		cursor = listCursors.cursors[token]
+		listCursors.mu.Unlock()
+		if cursor == nil {
+			return nil, "", errors.New("continuation token not found or expired")
+		}
+		if cursor.key != key {
+			return nil, "", errors.New("continuation token is for a different listing")
+		}
+	}
+	cursor.mu.Lock()
+	defer cursor.mu.Unlock()
+	cursor.timer.Stop()
+	list = []*ListJSONItem{}
+	if cursor.pending != nil {
+		list = append(list, cursor.pending)
+		cursor.pending = nil
+	}
+	// Read one more than asked for to see if there is another page
+	for len(list) <= maxResults {
+		var item *ListJSONItem
+		select {
+		case item = <-cursor.items:
+		case <-ctx.Done():
+			// the items read so far are lost so the listing can't continue
+			removeListCursor(token)
+			return nil, "", ctx.Err()
+		}
+		if item == nil {
+			// listing finished
+			removeListCursor(token)
+			if cursor.err != nil {
+				return nil, "", cursor.err
+			}
+			return list, "", nil
+		}
+		list = append(list, item)
+	}
+	cursor.pending = list[maxResults]
+	list = list[:maxResults]
+	cursor.timer.Reset(listCursorExpire)
+	return list, token, nil
+}
@@ -0,0 +1,261 @@
+package operations
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+)
+
+// manifestVersion is the version of the manifest format
+const manifestVersion = 1
+
+// manifestSignaturePrefix is put before the hex HMAC in the signature
+const manifestSignaturePrefix = "hmac-sha256:"
+
+// Manifest is a record of the paths, sizes and hashes of the files in
+// a remote for checking their integrity later
+type Manifest struct {
+	Version   int            `json:"version"`             // version of the manifest format
+	Hash      string         `json:"hash"`                // name of the hash type used
+	Created   time.Time      `json:"created"`             // when the manifest was made
+	Files     []ManifestFile `json:"files"`               // the files sorted by path
+	Signature string         `json:"signature,omitempty"` // signature of the above if signed
+}
+
+// ManifestFile is a file in a Manifest
+type ManifestFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	Hash string `json:"hash"`
+}
+
+// manifestHashSum returns the hash of o, downloading it to work it out
+// if download is set or the remote can't supply the hash
+func manifestHashSum(ctx context.Context, ht hash.Type, download bool, o fs.Object) (string, error) {
+	if !download && o.Fs().Hashes().Contains(ht) {
+		sum, err := hashSum(ctx, ht, false, false, o)
+		if err != nil {
+			return "", err
+		}
+		if sum != "" {
+			return strings.ToLower(sum), nil
+		}
+		fs.Debugf(o, "Downloading to hash as the remote has no %v", ht)
+	}
+	sum, err := hashSum(ctx, ht, false, true, o)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(sum), nil
+}
+
+// manifestListFn calls fn for each object in f running --transfers
+// at once
+func manifestListFn(ctx context.Context, f fs.Fs, fn func(o fs.Object)) error {
+	concurrencyControl := make(chan struct{}, fs.GetConfig(ctx).Transfers)
+	var wg sync.WaitGroup
+	err := ListFn(ctx, f, func(o fs.Object) {
+		wg.Add(1)
+		concurrencyControl <- struct{}{}
+		go func() {
+			defer func() {
+				<-concurrencyControl
+				wg.Done()
+			}()
+			fn(o)
+		}()
+	})
+	wg.Wait()
+	return err
+}
+
+// MakeManifest makes a Manifest of the files in f using the hash ht
+//
+// The hashes are read from the remote if it supports ht, otherwise
+// the files are downloaded to hash them, as they are if download is
+// set.
+func MakeManifest(ctx context.Context, f fs.Fs, ht hash.Type, download bool) (*Manifest, error) {
+	if ht == hash.None {
+		return nil, errors.New("need a hash type to make a manifest")
+	}
+	m := &Manifest{
+		Version: manifestVersion,
+		Hash:    ht.String(),
+		Created: time.Now().UTC(),
+		Files:   []ManifestFile{},
+	}
+	var (
+		mu     sync.Mutex
+		failed int
+	)
+	err := manifestListFn(ctx, f, func(o fs.Object) {
+		sum, err := manifestHashSum(ctx, ht, download, o)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			fs.Errorf(o, "Failed to hash for manifest: %v", fs.CountError(err))
+			failed++
+			return
+		}
+		m.Files = append(m.Files, ManifestFile{Path: o.Remote(), Size: o.Size(), Hash: sum})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list for manifest: %w", err)
+	}
+	if failed > 0 {
+		return nil, fmt.Errorf("failed to hash %d files for manifest", failed)
+	}
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Path < m.Files[j].Path
+	})
+	return m, nil
+}
+
+// signature returns the signature of m made with key
+func (m *Manifest) signature(key []byte) (string, error) {
+	unsigned := *m
+	unsigned.Signature = ""
+	data, err := json.Marshal(&unsigned)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(data)
+	return manifestSignaturePrefix + hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// Sign signs m with key
+func (m *Manifest) Sign(key []byte) (err error) {
+	if len(key) == 0 {
+		return errors.New("need a key to sign the manifest")
+	}
+	m.Signature, err = m.signature(key)
+	return err
+}
+
+// CheckSignature checks m was signed with key and hasn't been
+// changed since
+func (m *Manifest) CheckSignature(key []byte) error {
+	if m.Signature == "" {
+		return errors.New("manifest isn't signed")
+	}
+	if !strings.HasPrefix(m.Signature, manifestSignaturePrefix) {
+		return fmt.Errorf("unknown manifest signature type %q", m.Signature)
+	}
+	want, err := m.signature(key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(want), []byte(m.Signature)) {
+		return errors.New("manifest signature doesn't match - it has been changed or the key is wrong")
+	}
+	return nil
+}
+
+// Write writes m to out as JSON
+func (m *Manifest) Write(out io.Writer) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "\t")
+	return enc.Encode(m)
+}
+
+// ReadManifest reads a Manifest written by Write from in
+func ReadManifest(in io.Reader) (*Manifest, error) {
+	var m Manifest
+	err := json.NewDecoder(in).Decode(&m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if m.Version != manifestVersion {
+		return nil, fmt.Errorf("unsupported manifest version %d", m.Version)
+	}
+	return &m, nil
+}
+
+// VerifyManifest checks the files in f against m, logging an error
+// for each file which is missing, extra, or has a different size or
+// hash.
+//
+// The hashes are read from the remote if it supports the manifest
+// hash, otherwise the files are downloaded to hash them, as they are
+// if download is set. This means the files can be verified on a
+// different remote to the one the manifest was made on.
+func VerifyManifest(ctx context.Context, f fs.Fs, m *Manifest, download bool) error {
+	var ht hash.Type
+	err := ht.Set(m.Hash)
+	if err != nil {
+		return fmt.Errorf("bad hash in manifest: %w", err)
+	}
+	want := make(map[string]ManifestFile, len(m.Files))
+	for _, file := range m.Files {
+		want[file.Path] = file
+	}
+	var (
+		mu          sync.Mutex
+		differences int
+		matches     int
+	)
+	differ := func(o fs.Object, format string, args ...interface{}) {
+		fs.Errorf(o, format, args...)
+		mu.Lock()
+		differences++
+		mu.Unlock()
+	}
+	err = manifestListFn(ctx, f, func(o fs.Object) {
+		remote := o.Remote()
+		mu.Lock()
+		file, found := want[remote]
+		delete(want, remote)
+		mu.Unlock()
+		if !found {
+			differ(o, "%v", fs.CountError(errors.New("file not in manifest")))
+			return
+		}
+		if o.Size() != file.Size {
+			differ(o, "%v", fs.CountError(fmt.Errorf("sizes differ: manifest %d, remote %d", file.Size, o.Size())))
+			return
+		}
+		sum, err := manifestHashSum(ctx, ht, download, o)
+		if err != nil {
+			differ(o, "Failed to hash: %v", fs.CountError(err))
+			return
+		}
+		if sum != strings.ToLower(file.Hash) {
+			differ(o, "%v", fs.CountError(fmt.Errorf("%v differ: manifest %s, remote %s", ht, file.Hash, sum)))
+			return
+		}
+		fs.Debugf(o, "OK")
+		mu.Lock()
+		matches++
+		mu.Unlock()
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list for manifest: %w", err)
+	}
+	missing := make([]string, 0, len(want))
+	for remote := range want {
+		missing = append(missing, remote)
+	}
+	sort.Strings(missing)
+	for _, remote := range missing {
+		fs.Errorf(remote, "%v", fs.CountError(errors.New("file in manifest not found")))
+		differences++
+	}
+	fs.Logf(f, "%d matching files", matches)
+	if differences > 0 {
+		return fmt.Errorf("%d differences found", differences)
+	}
+	return nil
+}
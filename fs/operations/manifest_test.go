@@ -0,0 +1,71 @@
+package operations_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	r.WriteObject(ctx, "file1", "file1 contents", t1)
+	r.WriteObject(ctx, "sub/file2", "file2 contents", t2)
+
+	m, err := operations.MakeManifest(ctx, r.Fremote, hash.SHA256, false)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256", m.Hash)
+	assert.Equal(t, []operations.ManifestFile{
+		{Path: "file1", Size: 14, Hash: "226e7cfa701fb8ba542d42e0f8bd3090cbbcc9f54d834f361c0ab8c3f4846b72"},
+		{Path: "sub/file2", Size: 14, Hash: "0140c0c66a644ab2dd27ac5536f20cc373d6fd1896f9838ecb4595675dda01fa"},
+	}, m.Files)
+
+	// Round trip the manifest
+	var buf bytes.Buffer
+	require.NoError(t, m.Sign([]byte("key")))
+	require.NoError(t, m.Write(&buf))
+	m, err = operations.ReadManifest(&buf)
+	require.NoError(t, err)
+	assert.NoError(t, m.CheckSignature([]byte("key")))
+	assert.Error(t, m.CheckSignature([]byte("wrong key")))
+
+	assert.NoError(t, operations.VerifyManifest(ctx, r.Fremote, m, false))
+	assert.NoError(t, operations.VerifyManifest(ctx, r.Fremote, m, true))
+
+	// Changes to the manifest are detected
+	sum := m.Files[0].Hash
+	m.Files[0].Hash = "potato"
+	assert.Error(t, m.CheckSignature([]byte("key")))
+	m.Files[0].Hash = sum
+	assert.NoError(t, m.CheckSignature([]byte("key")))
+
+	// Changed, extra and missing files are found
+	r.WriteObject(ctx, "file1", "file1 CONTENTS", t1)
+	err = operations.VerifyManifest(ctx, r.Fremote, m, false)
+	assert.EqualError(t, err, "1 differences found")
+
+	r.WriteObject(ctx, "file3", "file3 contents", t1)
+	err = operations.VerifyManifest(ctx, r.Fremote, m, false)
+	assert.EqualError(t, err, "2 differences found")
+
+	obj, err := r.Fremote.NewObject(ctx, "sub/file2")
+	require.NoError(t, err)
+	require.NoError(t, obj.Remove(ctx))
+	err = operations.VerifyManifest(ctx, r.Fremote, m, false)
+	assert.EqualError(t, err, "3 differences found")
+}
+
+func TestReadManifestErrors(t *testing.T) {
+	_, err := operations.ReadManifest(bytes.NewBufferString("not json"))
+	assert.Error(t, err)
+	_, err = operations.ReadManifest(bytes.NewBufferString(`{"version":99}`))
+	assert.EqualError(t, err, "unsupported manifest version 99")
+}
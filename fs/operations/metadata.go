@@ -0,0 +1,50 @@
+package operations
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// getMetadata returns the metadata of src to preserve on the
+// destination if --metadata is set, or nil if it isn't or src has
+// none
+func getMetadata(ctx context.Context, src fs.ObjectInfo) fs.Metadata {
+	if !fs.GetConfig(ctx).Metadata {
+		return nil
+	}
+	metadata, err := fs.GetMetadata(ctx, src)
+	if err != nil {
+		fs.Errorf(src, "Failed to read metadata: %v", err)
+		return nil
+	}
+	return metadata
+}
+
+// metadataDropped returns the sorted keys of want which are missing
+// from got
+func metadataDropped(want, got fs.Metadata) (dropped []string) {
+	for _, k := range want.Keys() {
+		if _, found := got[k]; !found {
+			dropped = append(dropped, k)
+		}
+	}
+	return dropped
+}
+
+// checkMetadata reports the items of the source metadata want which
+// weren't preserved on dst because its backend doesn't support them
+func checkMetadata(ctx context.Context, dst fs.Object, want fs.Metadata) {
+	if dst == nil || len(want) == 0 {
+		return
+	}
+	got, err := fs.GetMetadata(ctx, dst)
+	if err != nil {
+		fs.Debugf(dst, "Failed to read metadata to check it: %v", err)
+		return
+	}
+	if dropped := metadataDropped(want, got); len(dropped) > 0 {
+		fs.Infof(dst, "Metadata not supported by destination was dropped: %s", strings.Join(dropped, ", "))
+	}
+}
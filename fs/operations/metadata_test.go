@@ -0,0 +1,51 @@
+package operations
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest/mockobject"
+	"github.com/stretchr/testify/assert"
+)
+
+// metadataObject is a mock object with metadata
+type metadataObject struct {
+	mockobject.Object
+	metadata fs.Metadata
+}
+
+func (o metadataObject) Metadata(ctx context.Context) (fs.Metadata, error) {
+	return o.metadata, nil
+}
+
+func TestGetMetadata(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	src := metadataObject{
+		Object:   mockobject.New("potato"),
+		metadata: fs.Metadata{"mtime": "2022-05-10T11:03:01Z"},
+	}
+
+	// Not read unless --metadata is set
+	assert.Nil(t, getMetadata(ctx, src))
+
+	ci.Metadata = true
+	assert.Equal(t, src.metadata, getMetadata(ctx, src))
+	assert.Nil(t, getMetadata(ctx, mockobject.New("potato")))
+}
+
+func TestMetadataDropped(t *testing.T) {
+	for _, test := range []struct {
+		want fs.Metadata
+		got  fs.Metadata
+		drop []string
+	}{
+		{want: nil, got: nil, drop: nil},
+		{want: fs.Metadata{"mtime": "1"}, got: fs.Metadata{"mtime": "2"}, drop: nil},
+		{want: fs.Metadata{"mtime": "1", "uid": "0", "gid": "0"}, got: fs.Metadata{"mtime": "1"}, drop: []string{"gid", "uid"}},
+		{want: fs.Metadata{"mode": "0100644"}, got: nil, drop: []string{"mode"}},
+	} {
+		assert.Equal(t, test.drop, metadataDropped(test.want, test.got), test.want)
+	}
+}
@@ -1,6 +1,7 @@
 package operations
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -32,9 +33,9 @@ func doMultiThreadCopy(ctx context.Context, f fs.Fs, src fs.Object) bool {
 	if src.Size() < int64(ci.MultiThreadCutoff) {
 		return false
 	}
-	// ...source doesn't support it
+	// ...destination doesn't support it
 	dstFeatures := f.Features()
-	if dstFeatures.OpenWriterAt == nil {
+	if dstFeatures.OpenWriterAt == nil && dstFeatures.OpenChunkWriter == nil {
 		return false
 	}
 	// ...if --multi-thread-streams not in use and source and
@@ -139,10 +140,15 @@ func (mc *multiThreadCopyState) calculateChunks() {
 	}
 }
 
-// Copy src to (f, remote) using streams download threads and the OpenWriterAt feature
+// Copy src to (f, remote) using streams download threads and the
+// OpenWriterAt feature, or streams upload threads and the
+// OpenChunkWriter feature if the destination doesn't have OpenWriterAt
 func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object, streams int, tr *accounting.Transfer) (newDst fs.Object, err error) {
 	openWriterAt := f.Features().OpenWriterAt
 	if openWriterAt == nil {
+		if f.Features().OpenChunkWriter != nil {
+			return multiThreadUpload(ctx, f, remote, src, streams, tr)
+		}
 		return nil, errors.New("multi-thread copy: OpenWriterAt not supported")
 	}
 	if src.Size() < 0 {
@@ -162,7 +168,7 @@ func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object,
 	mc.calculateChunks()
 
 	// Make accounting
-	mc.acc = tr.Account(ctx, nil)
+	mc.acc = tr.Account(ctx, nil).LimitRemotes(src.Fs(), f)
 
 	// create write file handle
 	mc.wc, err = openWriterAt(gCtx, remote, mc.size)
@@ -201,3 +207,180 @@ func multiThreadCopy(ctx context.Context, f fs.Fs, remote string, src fs.Object,
 	fs.Debugf(src, "Finished multi-thread copy with %d parts of size %v", mc.streams, fs.SizeSuffix(mc.partSize))
 	return obj, nil
 }
+
+// state for a multi-thread upload
+type multiThreadUploadState struct {
+	size      int64
+	chunkSize int64
+	w         fs.ChunkWriter
+	src       fs.Object
+	acc       *accounting.Account
+}
+
+// Upload a single chunk
+func (mu *multiThreadUploadState) uploadChunk(ctx context.Context, chunk int) (err error) {
+	ci := fs.GetConfig(ctx)
+	start := int64(chunk) * mu.chunkSize
+	end := start + mu.chunkSize
+	if end > mu.size {
+		end = mu.size
+	}
+	fs.Debugf(mu.src, "multi-thread upload: chunk %d (%d-%d) size %v starting", chunk+1, start, end, fs.SizeSuffix(end-start))
+
+	rc, err := NewReOpen(ctx, mu.src, ci.LowLevelRetries, &fs.RangeOption{Start: start, End: end - 1})
+	if err != nil {
+		return fmt.Errorf("multi-thread upload: failed to open source: %w", err)
+	}
+	defer fs.CheckClose(rc, &err)
+
+	// Read the chunk into memory so it can be retried
+	buf := make([]byte, end-start)
+	n, err := io.ReadFull(rc, buf)
+	if err != nil {
+		return fmt.Errorf("multi-thread upload: read failed: %w", err)
+	}
+	err = mu.acc.AccountRead(n)
+	if err != nil {
+		return fmt.Errorf("multi-thread upload: accounting failed: %w", err)
+	}
+
+	written, err := mu.w.WriteChunk(ctx, chunk, bytes.NewReader(buf))
+	if err != nil {
+		return fmt.Errorf("multi-thread upload: chunk %d failed: %w", chunk+1, err)
+	}
+	if written != int64(n) {
+		return fmt.Errorf("multi-thread upload: chunk %d: %w", chunk+1, io.ErrShortWrite)
+	}
+	fs.Debugf(mu.src, "multi-thread upload: chunk %d (%d-%d) size %v finished", chunk+1, start, end, fs.SizeSuffix(end-start))
+	return nil
+}
+
+// openChunkWriter opens a ChunkWriter to upload src to remote on f
+//
+// If --resume-uploads is set it resumes the previous upload of src if
+// there is one, returning the chunks it already wrote, and returns
+// the resumeUpload to save the state of the upload in, or nil if the
+// upload can't be resumed.
+func openChunkWriter(ctx context.Context, f fs.Fs, remote string, src fs.Object, options []fs.OpenOption) (chunkSize int64, w fs.ChunkWriter, written []int, resume *resumeUpload, err error) {
+	ci := fs.GetConfig(ctx)
+	features := f.Features()
+	var wrappedSrc fs.ObjectInfo = src
+	// We try to pass the original object if possible
+	if src.Remote() != remote {
+		wrappedSrc = NewOverrideRemote(src, remote)
+	}
+	if ci.ResumeUploads && features.ResumeChunkWriter != nil {
+		resume, err = newResumeUpload(f, remote, src, src.ModTime(ctx))
+		if err != nil {
+			fs.Errorf(src, "multi-thread upload: can't resume uploads: %v", err)
+			resume = nil
+		} else if resumeID := resume.load(); resumeID != "" {
+			chunkSize, w, written, err = features.ResumeChunkWriter(ctx, remote, wrappedSrc, resumeID, options...)
+			if err == nil {
+				fs.Infof(src, "multi-thread upload: resuming upload with %d chunks already uploaded", len(written))
+				return chunkSize, w, written, resume, nil
+			}
+			fs.Logf(src, "multi-thread upload: failed to resume upload so starting again: %v", err)
+		}
+	}
+	chunkSize, w, err = features.OpenChunkWriter(ctx, remote, wrappedSrc, options...)
+	if err != nil {
+		return -1, nil, nil, nil, fmt.Errorf("multi-thread upload: failed to open destination: %w", err)
+	}
+	if resume != nil {
+		if rw, ok := w.(fs.ResumableChunkWriter); ok {
+			err = resume.save(rw.ResumeID())
+			if err != nil {
+				fs.Errorf(src, "multi-thread upload: failed to save state so upload can't be resumed: %v", err)
+				resume = nil
+			}
+		} else {
+			resume = nil
+		}
+	}
+	return chunkSize, w, nil, resume, nil
+}
+
+// Upload src to (f, remote) using streams upload threads and the
+// OpenChunkWriter feature
+//
+// Each thread reads a chunk of the source into memory and uploads it,
+// so this uses up to streams chunks of memory.
+func multiThreadUpload(ctx context.Context, f fs.Fs, remote string, src fs.Object, streams int, tr *accounting.Transfer) (newDst fs.Object, err error) {
+	ci := fs.GetConfig(ctx)
+	if src.Size() <= 0 {
+		return nil, errors.New("multi-thread upload: can't upload unknown or zero sized file")
+	}
+	var options []fs.OpenOption
+	for _, option := range ci.UploadHeaders {
+		options = append(options, option)
+	}
+	if metadata := getMetadata(ctx, src); metadata != nil {
+		options = append(options, fs.MetadataOption(metadata))
+	}
+	chunkSize, w, written, resume, err := openChunkWriter(ctx, f, remote, src, options)
+	if err != nil {
+		return nil, err
+	}
+	if chunkSize <= 0 {
+		_ = w.Abort(ctx)
+		return nil, fmt.Errorf("multi-thread upload: invalid chunk size %d", chunkSize)
+	}
+	mu := &multiThreadUploadState{
+		size:      src.Size(),
+		chunkSize: chunkSize,
+		w:         w,
+		src:       src,
+		acc:       tr.Account(ctx, nil).LimitRemotes(src.Fs(), f),
+	}
+	chunks := int((mu.size + chunkSize - 1) / chunkSize)
+	done := make(map[int]bool, len(written))
+	for _, chunk := range written {
+		done[chunk] = true
+	}
+
+	fs.Debugf(src, "Starting multi-thread upload of %d chunks of size %v with %d streams", chunks, fs.SizeSuffix(chunkSize), streams)
+	g, gCtx := errgroup.WithContext(ctx)
+	tokens := make(chan struct{}, streams)
+	for chunk := 0; chunk < chunks; chunk++ {
+		if done[chunk] {
+			continue
+		}
+		tokens <- struct{}{}
+		// Stop starting chunks once one has failed
+		if gCtx.Err() != nil {
+			<-tokens
+			break
+		}
+		chunk := chunk
+		g.Go(func() error {
+			defer func() { <-tokens }()
+			return mu.uploadChunk(gCtx, chunk)
+		})
+	}
+	err = g.Wait()
+	if err != nil {
+		if resume != nil {
+			fs.Infof(src, "multi-thread upload: keeping the uploaded chunks so the upload can be resumed")
+			return nil, err
+		}
+		if abortErr := w.Abort(ctx); abortErr != nil {
+			fs.Debugf(src, "multi-thread upload: failed to abort: %v", abortErr)
+		}
+		return nil, err
+	}
+	err = w.Close(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("multi-thread upload: failed to finalise: %w", err)
+	}
+	if resume != nil {
+		resume.remove()
+	}
+
+	obj, err := f.NewObject(ctx, remote)
+	if err != nil {
+		return nil, fmt.Errorf("multi-thread upload: failed to find object after upload: %w", err)
+	}
+	fs.Debugf(src, "Finished multi-thread upload of %d chunks of size %v", chunks, fs.SizeSuffix(chunkSize))
+	return obj, nil
+}
@@ -1,11 +1,17 @@
 package operations
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
 	"testing"
 
 	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/config"
 	"github.com/rclone/rclone/fstest/mockfs"
 	"github.com/rclone/rclone/fstest/mockobject"
 	"github.com/rclone/rclone/lib/random"
@@ -59,6 +65,11 @@ func TestDoMultiThreadCopy(t *testing.T) {
 
 	f.Features().OpenWriterAt = nil
 	assert.False(t, doMultiThreadCopy(ctx, f, src))
+	f.Features().OpenChunkWriter = func(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (int64, fs.ChunkWriter, error) {
+		panic("don't call me")
+	}
+	assert.True(t, doMultiThreadCopy(ctx, f, src))
+	f.Features().OpenChunkWriter = nil
 	f.Features().OpenWriterAt = nullWriterAt
 	assert.True(t, doMultiThreadCopy(ctx, f, src))
 
@@ -147,3 +158,169 @@ func TestMultithreadCopy(t *testing.T) {
 	}
 
 }
+
+// testChunkWriter assembles the chunks written to it into an object
+// in f when closed
+type testChunkWriter struct {
+	f         *mockfs.Fs
+	remote    string
+	chunkSize int64
+	mu        sync.Mutex
+	chunks    map[int][]byte
+	wrote     []int // chunk numbers in the order written
+	failFrom  int   // if > 0 fail writing chunks from this number
+	closed    bool
+	aborted   bool
+}
+
+func (w *testChunkWriter) WriteChunk(ctx context.Context, chunkNumber int, reader io.ReadSeeker) (int64, error) {
+	if w.failFrom > 0 && chunkNumber >= w.failFrom {
+		return -1, errors.New("chunk write failed")
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return -1, err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.chunks[chunkNumber] = data
+	w.wrote = append(w.wrote, chunkNumber)
+	return int64(len(data)), nil
+}
+
+func (w *testChunkWriter) ResumeID() string {
+	return "resume-id"
+}
+
+func (w *testChunkWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var buf bytes.Buffer
+	for i := 0; i < len(w.chunks); i++ {
+		chunk, ok := w.chunks[i]
+		if !ok {
+			return fmt.Errorf("missing chunk %d", i)
+		}
+		if i < len(w.chunks)-1 && int64(len(chunk)) != w.chunkSize {
+			return fmt.Errorf("chunk %d is the wrong size %d", i, len(chunk))
+		}
+		buf.Write(chunk)
+	}
+	w.closed = true
+	w.f.AddObject(mockobject.New(w.remote).WithContent(buf.Bytes(), mockobject.SeekModeNone))
+	return nil
+}
+
+func (w *testChunkWriter) Abort(ctx context.Context) error {
+	w.aborted = true
+	return nil
+}
+
+func TestMultithreadUpload(t *testing.T) {
+	ctx := context.Background()
+	for _, test := range []struct {
+		size      int
+		chunkSize int64
+		streams   int
+	}{
+		{size: 1, chunkSize: 10, streams: 4},
+		{size: 99, chunkSize: 10, streams: 4},
+		{size: 100, chunkSize: 10, streams: 4},
+		{size: 101, chunkSize: 10, streams: 1},
+		{size: 1000, chunkSize: 7, streams: 16},
+	} {
+		t.Run(fmt.Sprintf("%+v", test), func(t *testing.T) {
+			contents := []byte(random.String(test.size))
+			src := mockobject.New("file1").WithContent(contents, mockobject.SeekModeNone)
+			f := mockfs.NewFs(ctx, "potato", "")
+			w := &testChunkWriter{
+				f:         f,
+				chunkSize: test.chunkSize,
+				chunks:    make(map[int][]byte),
+			}
+			f.Features().OpenChunkWriter = func(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (int64, fs.ChunkWriter, error) {
+				w.remote = remote
+				return test.chunkSize, w, nil
+			}
+
+			tr := accounting.GlobalStats().NewTransfer(src)
+			dst, err := multiThreadCopy(ctx, f, "file1", src, test.streams, tr)
+			tr.Done(ctx, err)
+			require.NoError(t, err)
+			assert.True(t, w.closed)
+			assert.False(t, w.aborted)
+			assert.Equal(t, "file1", dst.Remote())
+
+			in, err := dst.Open(ctx)
+			require.NoError(t, err)
+			got, err := ioutil.ReadAll(in)
+			require.NoError(t, err)
+			require.NoError(t, in.Close())
+			assert.Equal(t, contents, got)
+		})
+	}
+}
+
+func TestMultithreadUploadResume(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	ci.ResumeUploads = true
+	oldCacheDir := config.GetCacheDir()
+	require.NoError(t, config.SetCacheDir(t.TempDir()))
+	defer func() {
+		_ = config.SetCacheDir(oldCacheDir)
+	}()
+
+	contents := []byte(random.String(100))
+	src := mockobject.New("file1").WithContent(contents, mockobject.SeekModeNone)
+	f := mockfs.NewFs(ctx, "potato", "")
+	w := &testChunkWriter{
+		f:         f,
+		remote:    "file1",
+		chunkSize: 10,
+		chunks:    make(map[int][]byte),
+		failFrom:  5,
+	}
+	f.Features().OpenChunkWriter = func(ctx context.Context, remote string, src fs.ObjectInfo, options ...fs.OpenOption) (int64, fs.ChunkWriter, error) {
+		return w.chunkSize, w, nil
+	}
+	f.Features().ResumeChunkWriter = func(ctx context.Context, remote string, src fs.ObjectInfo, resumeID string, options ...fs.OpenOption) (int64, fs.ChunkWriter, []int, error) {
+		assert.Equal(t, "resume-id", resumeID)
+		var written []int
+		for chunk := range w.chunks {
+			written = append(written, chunk)
+		}
+		return w.chunkSize, w, written, nil
+	}
+
+	// Interrupted upload keeps the chunks written
+	tr := accounting.GlobalStats().NewTransfer(src)
+	_, err := multiThreadCopy(ctx, f, "file1", src, 1, tr)
+	tr.Done(ctx, err)
+	require.Error(t, err)
+	assert.False(t, w.aborted)
+	assert.False(t, w.closed)
+	assert.Equal(t, []int{0, 1, 2, 3, 4}, w.wrote)
+
+	// Next upload only writes the missing chunks
+	w.failFrom = 0
+	w.wrote = nil
+	tr = accounting.GlobalStats().NewTransfer(src)
+	dst, err := multiThreadCopy(ctx, f, "file1", src, 1, tr)
+	tr.Done(ctx, err)
+	require.NoError(t, err)
+	assert.True(t, w.closed)
+	assert.Equal(t, []int{5, 6, 7, 8, 9}, w.wrote)
+
+	in, err := dst.Open(ctx)
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(in)
+	require.NoError(t, err)
+	require.NoError(t, in.Close())
+	assert.Equal(t, contents, got)
+
+	// The state is removed once the upload has finished
+	resume, err := newResumeUpload(f, "file1", src, src.ModTime(ctx))
+	require.NoError(t, err)
+	assert.Equal(t, "", resume.load())
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/rclone/rclone/fs/config"
 	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/fspath"
 	"github.com/rclone/rclone/fs/fshttp"
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/object"
@@ -102,6 +103,19 @@ func checkHashes(ctx context.Context, src fs.ObjectInfo, dst fs.Object, ht hash.
 	return srcHash == dstHash, ht, srcHash, dstHash, nil
 }
 
+// Reasons returned by NeedTransferReason for whether a file needs
+// transferring
+const (
+	ReasonMissingOnDst   = "missing on dst"
+	ReasonIgnoreExisting = "exists on dst"
+	ReasonIgnoreTimes    = "ignoring times"
+	ReasonDstNewer       = "dst newer"
+	ReasonSizeDiffers    = "size differs"
+	ReasonHashDiffers    = "hash differs"
+	ReasonModTimeDiffers = "modtime differs"
+	ReasonUnchanged      = "unchanged"
+)
+
 // Equal checks to see if the src and dst objects are equal by looking at
 // size, mtime and hash
 //
@@ -165,14 +179,21 @@ func logModTimeUpload(dst fs.Object) {
 }
 
 func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt) bool {
+	same, _ := equalReason(ctx, src, dst, opt)
+	return same
+}
+
+// equalReason is like equal but also returns the reason for the
+// result, one of the Reason constants
+func equalReason(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt) (same bool, reason string) {
 	ci := fs.GetConfig(ctx)
 	if sizeDiffers(ctx, src, dst) {
 		fs.Debugf(src, "Sizes differ (src %d vs dst %d)", src.Size(), dst.Size())
-		return false
+		return false, ReasonSizeDiffers
 	}
 	if opt.sizeOnly {
 		fs.Debugf(src, "Sizes identical")
-		return true
+		return true, ReasonUnchanged
 	}
 
 	// Assert: Size is equal or being ignored
@@ -183,7 +204,7 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt)
 		same, ht, _ := CheckHashes(ctx, src, dst)
 		if !same {
 			fs.Debugf(src, "%v differ", ht)
-			return false
+			return false, ReasonHashDiffers
 		}
 		if ht == hash.None {
 			common := src.Fs().Hashes().Overlap(dst.Fs().Hashes())
@@ -196,7 +217,7 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt)
 		} else {
 			fs.Debugf(src, "Size and %v of src and dst objects identical", ht)
 		}
-		return true
+		return true, ReasonUnchanged
 	}
 
 	srcModTime := src.ModTime(ctx)
@@ -205,13 +226,13 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt)
 		modifyWindow := fs.GetModifyWindow(ctx, src.Fs(), dst.Fs())
 		if modifyWindow == fs.ModTimeNotSupported {
 			fs.Debugf(src, "Sizes identical")
-			return true
+			return true, ReasonUnchanged
 		}
 		dstModTime := dst.ModTime(ctx)
 		dt := dstModTime.Sub(srcModTime)
 		if dt < modifyWindow && dt > -modifyWindow {
 			fs.Debugf(src, "Size and modification time the same (differ by %s, within tolerance %s)", dt, modifyWindow)
-			return true
+			return true, ReasonUnchanged
 		}
 
 		fs.Debugf(src, "Modification times differ by %s: %v, %v", dt, srcModTime, dstModTime)
@@ -221,11 +242,11 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt)
 	same, ht, _ := CheckHashes(ctx, src, dst)
 	if !same {
 		fs.Debugf(src, "%v differ", ht)
-		return false
+		return false, ReasonHashDiffers
 	}
 	if ht == hash.None && !ci.RefreshTimes {
 		// if couldn't check hash, return that they differ
-		return false
+		return false, ReasonModTimeDiffers
 	}
 
 	// mod time differs but hash is the same to reset mod time if required
@@ -235,14 +256,14 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt)
 			// Error if objects are treated as immutable
 			if ci.Immutable {
 				fs.Errorf(dst, "Timestamp mismatch between immutable objects")
-				return false
+				return false, ReasonModTimeDiffers
 			}
 			// Update the mtime of the dst object here
 			err := dst.SetModTime(ctx, srcModTime)
 			if err == fs.ErrorCantSetModTime {
 				logModTimeUpload(dst)
 				fs.Infof(dst, "src and dst identical but can't set mod time without re-uploading")
-				return false
+				return false, ReasonModTimeDiffers
 			} else if err == fs.ErrorCantSetModTimeWithoutDelete {
 				logModTimeUpload(dst)
 				fs.Infof(dst, "src and dst identical but can't set mod time without deleting and re-uploading")
@@ -254,7 +275,7 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt)
 						fs.Errorf(dst, "failed to delete before re-upload: %v", err)
 					}
 				}
-				return false
+				return false, ReasonModTimeDiffers
 			} else if err != nil {
 				err = fs.CountError(err)
 				fs.Errorf(dst, "Failed to set modification time: %v", err)
@@ -263,7 +284,7 @@ func equal(ctx context.Context, src fs.ObjectInfo, dst fs.Object, opt equalOpt)
 			}
 		}
 	}
-	return true
+	return true, ReasonUnchanged
 }
 
 // Used to remove a failed copy
@@ -369,6 +390,12 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 		tr.Done(ctx, err)
 	}()
 	newDst = dst
+	if dst != nil {
+		err = checkAppendOnly(ctx, "overwrite", dst.Remote())
+		if err != nil {
+			return newDst, fs.CountError(err)
+		}
+	}
 	if SkipDestructive(ctx, src, "copy") {
 		in := tr.Account(ctx, nil)
 		in.DryRun(src.Size())
@@ -378,6 +405,7 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 	tries := 0
 	doUpdate := dst != nil
 	hashType, hashOption := CommonHash(ctx, f, src.Fs())
+	metadata := getMetadata(ctx, src)
 
 	var actionTaken string
 	for {
@@ -398,7 +426,7 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 				return nil, accounting.ErrorMaxTransferLimitReachedGraceful
 			}
 		}
-		if doCopy := f.Features().Copy; doCopy != nil && (SameConfig(src.Fs(), f) || (SameRemoteType(src.Fs(), f) && f.Features().ServerSideAcrossConfigs)) {
+		if doCopy := f.Features().Copy; doCopy != nil && canServerSide(f, src.Fs()) {
 			in := tr.Account(ctx, nil) // account the transfer
 			in.ServerSideCopyStart()
 			newDst, err = doCopy(ctx, src, remote)
@@ -415,7 +443,12 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 		} else {
 			err = fs.ErrorCantCopy
 		}
-		// If can't server-side copy, do it manually
+		// If can't server-side copy, try sending only the changes
+		if err == fs.ErrorCantCopy && doDeltaCopy(ctx, dst, src) {
+			actionTaken = "Delta copied (replaced existing)"
+			err = deltaCopy(ctx, dst, remote, src, tr, hashOption)
+		}
+		// Otherwise do it manually
 		if err == fs.ErrorCantCopy {
 			if doMultiThreadCopy(ctx, f, src) {
 				// Number of streams proportional to size
@@ -454,7 +487,7 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 						dst, err = Rcat(ctx, f, remote, in0, src.ModTime(ctx))
 						newDst = dst
 					} else {
-						in := tr.Account(ctx, in0).WithBuffer() // account and buffer the transfer
+						in := tr.Account(ctx, in0).LimitRemotes(src.Fs(), f).WithBuffer() // account and buffer the transfer
 						var wrappedSrc fs.ObjectInfo = src
 						// We try to pass the original object if possible
 						if src.Remote() != remote {
@@ -464,6 +497,9 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 						for _, option := range ci.UploadHeaders {
 							options = append(options, option)
 						}
+						if metadata != nil {
+							options = append(options, fs.MetadataOption(metadata))
+						}
 						if doUpdate {
 							actionTaken = "Copied (replaced existing)"
 							err = dst.Update(ctx, in, wrappedSrc, options...)
@@ -520,9 +556,10 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 	}
 
 	// Verify hashes are the same after transfer - ignoring blank hashes
+	hashChecked := false
 	if hashType != hash.None {
 		// checkHashes has logged and counted errors
-		equal, _, srcSum, dstSum, _ := checkHashes(ctx, src, dst, hashType)
+		equal, checkedType, srcSum, dstSum, _ := checkHashes(ctx, src, dst, hashType)
 		if !equal {
 			err = fmt.Errorf("corrupted on transfer: %v hash differ %q vs %q", hashType, srcSum, dstSum)
 			fs.Errorf(dst, "%v", err)
@@ -530,7 +567,21 @@ func Copy(ctx context.Context, f fs.Fs, dst fs.Object, remote string, src fs.Obj
 			removeFailedCopy(ctx, dst)
 			return newDst, err
 		}
+		hashChecked = checkedType != hash.None
 	}
+
+	// Read back the destination if it couldn't be checked by hash
+	if ci.VerifyAfterTransfer && !hashChecked {
+		err = verifyAfterTransfer(ctx, dst, src)
+		if err != nil {
+			err = fmt.Errorf("corrupted on transfer: %w", err)
+			fs.Errorf(dst, "%v", err)
+			err = fs.CountError(err)
+			removeFailedCopy(ctx, dst)
+			return newDst, err
+		}
+	}
+	checkMetadata(ctx, dst, metadata)
 	if newDst != nil && src.String() != newDst.String() {
 		fs.Infof(src, "%s to: %s", actionTaken, newDst.String())
 	} else {
@@ -583,13 +634,20 @@ func Move(ctx context.Context, fdst fs.Fs, dst fs.Object, remote string, src fs.
 		tr.Done(ctx, err)
 	}()
 	newDst = dst
+	// Moving a file within the destination deletes it from its old path
+	if SameConfig(src.Fs(), fdst) {
+		err = checkAppendOnly(ctx, "move", src.Remote())
+		if err != nil {
+			return newDst, fs.CountError(err)
+		}
+	}
 	if SkipDestructive(ctx, src, "move") {
 		in := tr.Account(ctx, nil)
 		in.DryRun(src.Size())
 		return newDst, nil
 	}
 	// See if we have Move available
-	if doMove := fdst.Features().Move; doMove != nil && (SameConfig(src.Fs(), fdst) || (SameRemoteType(src.Fs(), fdst) && fdst.Features().ServerSideAcrossConfigs)) {
+	if doMove := fdst.Features().Move; doMove != nil && canServerSide(fdst, src.Fs()) {
 		// Delete destination if it exists and is not the same file as src (could be same file while seemingly different if the remote is case insensitive)
 		if dst != nil && !SameObject(src, dst) {
 			err = DeleteFile(ctx, dst)
@@ -622,15 +680,41 @@ func Move(ctx context.Context, fdst fs.Fs, dst fs.Object, remote string, src fs.
 		}
 	}
 	// Move not found or didn't work so copy dst <- src
+	err = checkServerSideFallback(ctx, fdst, src)
+	if err != nil {
+		return newDst, err
+	}
 	newDst, err = Copy(ctx, fdst, dst, remote, src)
 	if err != nil {
 		fs.Errorf(src, "Not deleting source as copy failed: %v", err)
 		return newDst, err
 	}
-	// Delete src if no error on copy
+	// Delete src if no error on copy - this is allowed with
+	// --append-only as src isn't on the destination
+	ctx, ci := fs.AddConfig(ctx)
+	ci.AppendOnly = false
 	return newDst, DeleteFile(ctx, src)
 }
 
+// checkServerSideFallback applies the --server-side-fallback policy
+// when src can't be moved server-side to fdst.
+//
+// Falling back to a server-side copy and delete is always allowed.
+func checkServerSideFallback(ctx context.Context, fdst fs.Fs, src fs.Object) error {
+	if fdst.Features().Copy != nil && canServerSide(fdst, src.Fs()) {
+		return nil
+	}
+	switch fs.GetConfig(ctx).ServerSideFallback {
+	case fs.ServerSideFallbackWarn:
+		fs.Logf(src, "Can't move server-side so downloading and uploading instead")
+	case fs.ServerSideFallbackError:
+		err := fs.CountError(fserrors.NoRetryError(fs.ErrorServerSideFallback))
+		fs.Errorf(src, "Not moving: %v", err)
+		return err
+	}
+	return nil
+}
+
 // CanServerSideMove returns true if fdst support server-side moves or
 // server-side copies
 //
@@ -668,14 +752,19 @@ func DeleteFileWithBackupDir(ctx context.Context, dst fs.Object, backupDir fs.Fs
 	defer func() {
 		tr.Done(ctx, err)
 	}()
-	numDeletes := accounting.Stats(ctx).Deletes(1)
-	if ci.MaxDelete != -1 && numDeletes > ci.MaxDelete {
-		return fserrors.FatalError(errors.New("--max-delete threshold reached"))
-	}
 	action, actioned := "delete", "Deleted"
 	if backupDir != nil {
 		action, actioned = "move into backup dir", "Moved into backup dir"
 	}
+	err = checkAppendOnly(ctx, action, dst.Remote())
+	if err != nil {
+		fs.Errorf(dst, "Couldn't %s: %v", action, err)
+		return fs.CountError(err)
+	}
+	numDeletes := accounting.Stats(ctx).Deletes(1)
+	if ci.MaxDelete != -1 && numDeletes > ci.MaxDelete {
+		return fserrors.FatalError(fs.ErrorMaxDeleteReached)
+	}
 	skip := SkipDestructive(ctx, dst, action)
 	if skip {
 		// do nothing
@@ -757,6 +846,33 @@ func SameConfig(fdst, fsrc fs.Info) bool {
 	return fdst.Name() == fsrc.Name()
 }
 
+// SameAccount returns true if fdst and fsrc are remotes of the same
+// type using the same provider account, even if they are different
+// config file entries.
+func SameAccount(fdst, fsrc fs.Info) bool {
+	if !SameRemoteType(fdst, fsrc) {
+		return false
+	}
+	dstAccountID, srcAccountID := fdst.Features().AccountID, fsrc.Features().AccountID
+	if dstAccountID == nil || srcAccountID == nil {
+		return false
+	}
+	accountID := dstAccountID()
+	return accountID != "" && accountID == srcAccountID()
+}
+
+// canServerSide returns true if fdst can try a server-side copy or
+// move of objects from fsrc
+func canServerSide(fdst, fsrc fs.Info) bool {
+	if SameConfig(fsrc, fdst) {
+		return true
+	}
+	if !SameRemoteType(fsrc, fdst) {
+		return false
+	}
+	return fdst.Features().ServerSideAcrossConfigs || SameAccount(fdst, fsrc)
+}
+
 // SameConfigArr returns true if any of []fsrcs has same config file entry with fdst
 func SameConfigArr(fdst fs.Info, fsrcs []fs.Fs) bool {
 	for _, fsrc := range fsrcs {
@@ -977,7 +1093,7 @@ func hashSum(ctx context.Context, ht hash.Type, base64Encoded bool, downloadFlag
 		}
 
 		// Account and buffer the transfer
-		in = tr.Account(ctx, in).WithBuffer()
+		in = tr.Account(ctx, in).LimitRemotes(o.Fs(), nil).WithBuffer()
 
 		// Setup hasher
 		hasher, err := hash.NewMultiHasherTypes(hash.NewHashSet(ht))
@@ -1023,6 +1139,20 @@ func hashSum(ctx context.Context, ht hash.Type, base64Encoded bool, downloadFlag
 // Updated to perform multiple hashes concurrently
 func HashLister(ctx context.Context, ht hash.Type, outputBase64 bool, downloadFlag bool, f fs.Fs, w io.Writer) error {
 	width := hash.Width(ht, outputBase64)
+	return HashListerFn(ctx, ht, outputBase64, downloadFlag, f, func(o fs.Object, sum string, err error) {
+		if err != nil {
+			fs.Errorf(o, "%v", fs.CountError(err))
+			return
+		}
+		syncFprintf(w, "%*s  %s\n", width, sum, o.Remote())
+	})
+}
+
+// HashListerFn calls fn with the hash of each object in f as soon as
+// it is known, hashing --transfers objects at once.
+//
+// fn may be called concurrently.
+func HashListerFn(ctx context.Context, ht hash.Type, outputBase64 bool, downloadFlag bool, f fs.Fs, fn func(o fs.Object, sum string, err error)) error {
 	concurrencyControl := make(chan struct{}, fs.GetConfig(ctx).Transfers)
 	var wg sync.WaitGroup
 	err := ListFn(ctx, f, func(o fs.Object) {
@@ -1034,11 +1164,7 @@ func HashLister(ctx context.Context, ht hash.Type, outputBase64 bool, downloadFl
 				wg.Done()
 			}()
 			sum, err := hashSum(ctx, ht, outputBase64, downloadFlag, o)
-			if err != nil {
-				fs.Errorf(o, "%v", fs.CountError(err))
-				return
-			}
-			syncFprintf(w, "%*s  %s\n", width, sum, o.Remote())
+			fn(o, sum, err)
 		}()
 	})
 	wg.Wait()
@@ -1141,6 +1267,10 @@ func Rmdir(ctx context.Context, f fs.Fs, dir string) error {
 
 // Purge removes a directory and all of its contents
 func Purge(ctx context.Context, f fs.Fs, dir string) (err error) {
+	err = checkAppendOnly(ctx, "purge", fspath.JoinRootPath(fs.ConfigString(f), dir))
+	if err != nil {
+		return fs.CountError(err)
+	}
 	doFallbackPurge := true
 	if doPurge := f.Features().Purge; doPurge != nil {
 		doFallbackPurge = false
@@ -1220,6 +1350,10 @@ func CleanUp(ctx context.Context, f fs.Fs) error {
 	if doCleanUp == nil {
 		return fmt.Errorf("%v doesn't support cleanup", f)
 	}
+	err := checkAppendOnly(ctx, "clean up old files in", fs.ConfigString(f))
+	if err != nil {
+		return fs.CountError(err)
+	}
 	if SkipDestructive(ctx, f, "clean up old files") {
 		return nil
 	}
@@ -1273,7 +1407,7 @@ func Cat(ctx context.Context, f fs.Fs, w io.Writer, offset, count int64) error {
 		if count >= 0 {
 			in = &readCloser{Reader: &io.LimitedReader{R: in, N: count}, Closer: in}
 		}
-		in = tr.Account(ctx, in).WithBuffer() // account and buffer the transfer
+		in = tr.Account(ctx, in).LimitRemotes(o.Fs(), nil).WithBuffer() // account and buffer the transfer
 		// take the lock just before we output stuff, so at the last possible moment
 		mu.Lock()
 		defer mu.Unlock()
@@ -1288,11 +1422,15 @@ func Cat(ctx context.Context, f fs.Fs, w io.Writer, offset, count int64) error {
 // Rcat reads data from the Reader until EOF and uploads it to a file on remote
 func Rcat(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadCloser, modTime time.Time) (dst fs.Object, err error) {
 	ci := fs.GetConfig(ctx)
+	err = checkAppendOnlyNew(ctx, fdst, dstFileName)
+	if err != nil {
+		return nil, fs.CountError(err)
+	}
 	tr := accounting.Stats(ctx).NewTransferRemoteSize(dstFileName, -1)
 	defer func() {
 		tr.Done(ctx, err)
 	}()
-	in = tr.Account(ctx, in).WithBuffer()
+	in = tr.Account(ctx, in).LimitRemotes(nil, fdst).WithBuffer()
 
 	readCounter := readers.NewCountingReader(in)
 	var trackingIn io.Reader
@@ -1595,20 +1733,27 @@ func CompareOrCopyDest(ctx context.Context, fdst fs.Fs, dst, src fs.Object, Comp
 // Returns a flag which indicates whether the file needs to be
 // transferred or not.
 func NeedTransfer(ctx context.Context, dst, src fs.Object) bool {
+	need, _ := NeedTransferReason(ctx, dst, src)
+	return need
+}
+
+// NeedTransferReason is like NeedTransfer but also returns the reason
+// for the result, one of the Reason constants
+func NeedTransferReason(ctx context.Context, dst, src fs.Object) (need bool, reason string) {
 	ci := fs.GetConfig(ctx)
 	if dst == nil {
 		fs.Debugf(src, "Need to transfer - File not found at Destination")
-		return true
+		return true, ReasonMissingOnDst
 	}
 	// If we should ignore existing files, don't transfer
 	if ci.IgnoreExisting {
 		fs.Debugf(src, "Destination exists, skipping")
-		return false
+		return false, ReasonIgnoreExisting
 	}
 	// If we should upload unconditionally
 	if ci.IgnoreTimes {
 		fs.Debugf(src, "Transferring unconditionally as --ignore-times is in use")
-		return true
+		return true, ReasonIgnoreTimes
 	}
 	// If UpdateOlder is in effect, skip if dst is newer than src
 	if ci.UpdateOlder {
@@ -1626,33 +1771,37 @@ func NeedTransfer(ctx context.Context, dst, src fs.Object) bool {
 		switch {
 		case dt >= modifyWindow:
 			fs.Debugf(src, "Destination is newer than source, skipping")
-			return false
+			return false, ReasonDstNewer
 		case dt <= -modifyWindow:
 			// force --checksum on for the check and do update modtimes by default
 			opt := defaultEqualOpt(ctx)
 			opt.forceModTimeMatch = true
-			if equal(ctx, src, dst, opt) {
+			same, reason := equalReason(ctx, src, dst, opt)
+			if same {
 				fs.Debugf(src, "Unchanged skipping")
-				return false
+				return false, reason
 			}
+			return true, reason
 		default:
 			// Do a size only compare unless --checksum is set
 			opt := defaultEqualOpt(ctx)
 			opt.sizeOnly = !ci.CheckSum
-			if equal(ctx, src, dst, opt) {
+			same, reason := equalReason(ctx, src, dst, opt)
+			if same {
 				fs.Debugf(src, "Destination mod time is within %v of source and files identical, skipping", modifyWindow)
-				return false
+				return false, reason
 			}
 			fs.Debugf(src, "Destination mod time is within %v of source but files differ, transferring", modifyWindow)
-		}
-	} else {
-		// Check to see if changed or not
-		if Equal(ctx, src, dst) {
-			fs.Debugf(src, "Unchanged skipping")
-			return false
+			return true, reason
 		}
 	}
-	return true
+	// Check to see if changed or not
+	same, reason := equalReason(ctx, src, dst, defaultEqualOpt(ctx))
+	if same {
+		fs.Debugf(src, "Unchanged skipping")
+		return false, reason
+	}
+	return true, reason
 }
 
 // RcatSize reads data from the Reader until EOF and uploads it to a file on remote.
@@ -1662,6 +1811,10 @@ func RcatSize(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadClo
 
 	if size >= 0 {
 		var err error
+		err = checkAppendOnlyNew(ctx, fdst, dstFileName)
+		if err != nil {
+			return nil, fs.CountError(err)
+		}
 		// Size known use Put
 		tr := accounting.Stats(ctx).NewTransferRemoteSize(dstFileName, size)
 		defer func() {
@@ -1696,6 +1849,17 @@ func RcatSize(ctx context.Context, fdst fs.Fs, dstFileName string, in io.ReadClo
 	return obj, nil
 }
 
+// copyURLRetryErrorCodes are the HTTP status codes which are worth
+// retrying when downloading a URL
+var copyURLRetryErrorCodes = []int{
+	408, // Request Timeout
+	429, // Rate exceeded.
+	500, // Get occasional 500 Internal Server Error
+	502, // Bad Gateway
+	503, // Service Unavailable
+	504, // Gateway Time-out
+}
+
 // copyURLFunc is called from CopyURLFn
 type copyURLFunc func(ctx context.Context, dstFileName string, in io.ReadCloser, size int64, modTime time.Time) (err error)
 
@@ -1708,7 +1872,11 @@ func copyURLFn(ctx context.Context, dstFileName string, url string, dstFileNameF
 	}
 	defer fs.CheckClose(resp.Body, &err)
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("CopyURL failed: %s", resp.Status)
+		err = fmt.Errorf("CopyURL failed: %s", resp.Status)
+		if !fserrors.ShouldRetryHTTP(resp, copyURLRetryErrorCodes) {
+			err = fserrors.NoRetryError(err)
+		}
+		return err
 	}
 	modTime, err := http.ParseTime(resp.Header.Get("Last-Modified"))
 	if err != nil {
@@ -1717,7 +1885,7 @@ func copyURLFn(ctx context.Context, dstFileName string, url string, dstFileNameF
 	if dstFileNameFromURL {
 		dstFileName = path.Base(resp.Request.URL.Path)
 		if dstFileName == "." || dstFileName == "/" {
-			return fmt.Errorf("CopyURL failed: file name wasn't found in url")
+			return fserrors.NoRetryError(errors.New("CopyURL failed: file name wasn't found in url"))
 		}
 		fs.Debugf(dstFileName, "File name found in url")
 	}
@@ -1731,7 +1899,7 @@ func CopyURL(ctx context.Context, fdst fs.Fs, dstFileName string, url string, ds
 		if noClobber {
 			_, err = fdst.NewObject(ctx, dstFileName)
 			if err == nil {
-				return errors.New("CopyURL failed: file already exist")
+				return fserrors.NoRetryError(errors.New("CopyURL failed: file already exist"))
 			}
 		}
 		dst, err = RcatSize(ctx, fdst, dstFileName, in, size, modTime)
@@ -1751,6 +1919,9 @@ func CopyURLToWriter(ctx context.Context, url string, out io.Writer) (err error)
 // BackupDir returns the correctly configured --backup-dir
 func BackupDir(ctx context.Context, fdst fs.Fs, fsrc fs.Fs, srcFileName string) (backupDir fs.Fs, err error) {
 	ci := fs.GetConfig(ctx)
+	if ci.AppendOnly {
+		return nil, fserrors.FatalError(errors.New("can't use --backup-dir or --suffix with --append-only as they move files on the destination"))
+	}
 	if ci.BackupDir != "" {
 		backupDir, err = cache.Get(ctx, ci.BackupDir)
 		if err != nil {
@@ -2297,6 +2468,9 @@ func SkipDestructive(ctx context.Context, subject interface{}, action string) (s
 		} else {
 			fs.Logf(subject, "Skipped %s as %s is set", fs.LogValue("skipped", action), flag)
 		}
+		if r := fs.GetDryRunRecorder(ctx); r != nil && ci.DryRun {
+			r.Add(subject, action, size)
+		}
 	}
 	return skip
 }
@@ -10,6 +10,8 @@ import (
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/object"
+	"github.com/rclone/rclone/fstest/mockfs"
+	"github.com/rclone/rclone/fstest/mockobject"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -41,3 +43,66 @@ func TestSizeDiffers(t *testing.T) {
 		assert.Equal(t, test.want, got, fmt.Sprintf("ignoreSize=%v, srcSize=%v, dstSize=%v", test.ignoreSize, test.srcSize, test.dstSize))
 	}
 }
+
+func TestVerifyAfterTransfer(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	content := make([]byte, 1000)
+	for i := range content {
+		content[i] = byte(i)
+	}
+	src := mockobject.New("a").WithContent(content, mockobject.SeekModeNone)
+	for _, test := range []struct {
+		sample  fs.SizeSuffix
+		changed int // byte changed in dst or -1
+		wantErr bool
+	}{
+		{-1, -1, false},
+		{-1, 500, true},
+		{80, -1, false},
+		{80, 0, true},
+		{80, 999, true},
+		{80, 500, false}, // between the sampled ranges
+		{1000, 500, true},
+	} {
+		what := fmt.Sprintf("sample=%v, changed=%d", test.sample, test.changed)
+		dstContent := append([]byte(nil), content...)
+		if test.changed >= 0 {
+			dstContent[test.changed]++
+		}
+		dst := mockobject.New("a").WithContent(dstContent, mockobject.SeekModeNone)
+		ci.VerifySampleSize = test.sample
+		err := verifyAfterTransfer(ctx, dst, src)
+		if test.wantErr {
+			assert.Error(t, err, what)
+		} else {
+			assert.NoError(t, err, what)
+		}
+	}
+}
+
+func TestCheckServerSideFallback(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	fdst := mockfs.NewFs(ctx, "dst", "")
+	src := mockobject.New("file.txt").WithContent([]byte("hello"), mockobject.SeekModeNone)
+	src.SetFs(mockfs.NewFs(ctx, "src", ""))
+
+	ci.ServerSideFallback = fs.ServerSideFallbackAllow
+	assert.NoError(t, checkServerSideFallback(ctx, fdst, src))
+	ci.ServerSideFallback = fs.ServerSideFallbackWarn
+	assert.NoError(t, checkServerSideFallback(ctx, fdst, src))
+	ci.ServerSideFallback = fs.ServerSideFallbackError
+	assert.ErrorIs(t, checkServerSideFallback(ctx, fdst, src), fs.ErrorServerSideFallback)
+
+	// Move doesn't try to copy the file
+	_, err := Move(ctx, fdst, nil, "file.txt", src)
+	assert.ErrorIs(t, err, fs.ErrorServerSideFallback)
+
+	// Falling back to a server-side copy is allowed
+	fdst.Features().Copy = func(ctx context.Context, src fs.Object, remote string) (fs.Object, error) {
+		return nil, fs.ErrorCantCopy
+	}
+	src.SetFs(fdst)
+	assert.NoError(t, checkServerSideFallback(ctx, fdst, src))
+}
@@ -425,6 +425,24 @@ func TestDelete(t *testing.T) {
 	r.CheckRemoteItems(t, file3)
 }
 
+func TestDeleteDryRun(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	ci.DryRun = true
+	ctx, recorder := fs.WithDryRunRecorder(ctx)
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	file1 := r.WriteObject(ctx, "small", "1234567890", t2)
+	r.CheckRemoteItems(t, file1)
+
+	err := operations.Delete(ctx, r.Fremote)
+	require.NoError(t, err)
+	r.CheckRemoteItems(t, file1)
+	assert.Equal(t, []fs.DryRunAction{
+		{Action: "delete", Name: "small", Size: 10},
+	}, recorder.Actions())
+}
+
 func TestRetry(t *testing.T) {
 	ctx := context.Background()
 
@@ -956,6 +974,28 @@ func TestCopyFile(t *testing.T) {
 	r.CheckRemoteItems(t, file2)
 }
 
+func TestCopyFileDelta(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	ci.Delta = true
+	ci.DeltaBlockSize = 16
+
+	contents := strings.Repeat("0123456789abcdef", 64)
+	file1 := r.WriteObject(ctx, "file1", contents, t1)
+	r.CheckRemoteItems(t, file1)
+
+	newContents := contents[:100] + "potato" + contents[100:900] + "sausage"
+	file2 := r.WriteFile("file1", newContents, t2)
+	r.CheckLocalItems(t, file2)
+
+	err := operations.CopyFile(ctx, r.Fremote, r.Flocal, file2.Path, file2.Path)
+	require.NoError(t, err)
+	r.CheckRemoteItems(t, file2)
+}
+
 func TestCopyFileBackupDir(t *testing.T) {
 	ctx := context.Background()
 	ctx, ci := fs.AddConfig(ctx)
@@ -1203,6 +1243,31 @@ func TestSameConfig(t *testing.T) {
 	}
 }
 
+func TestSameAccount(t *testing.T) {
+	accountID := func(id string) func() string {
+		return func() string { return id }
+	}
+	a := &testFsInfo{name: "a", features: fs.Features{AccountID: accountID("account")}}
+	for _, test := range []struct {
+		what      string
+		accountID func() string
+		expected  bool
+	}{
+		{"same account", accountID("account"), true},
+		{"different account", accountID("other"), false},
+		{"unknown account", accountID(""), false},
+		{"no AccountID", nil, false},
+	} {
+		b := &testFsInfo{name: "b", features: fs.Features{AccountID: test.accountID}}
+		assert.Equal(t, test.expected, operations.SameAccount(a, b), test.what)
+		assert.Equal(t, test.expected, operations.SameAccount(b, a), test.what)
+	}
+
+	// Different types are never the same account
+	b := &testFsInfo{name: "b", features: fs.Features{AccountID: accountID("account")}}
+	assert.False(t, operations.SameAccount(a, struct{ *testFsInfo }{b}))
+}
+
 func TestSame(t *testing.T) {
 	a := &testFsInfo{name: "name", root: "root"}
 	for _, test := range []struct {
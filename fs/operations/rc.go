@@ -2,17 +2,18 @@ package operations
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
-	"mime"
-	"mime/multipart"
-	"net/http"
-	"path"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/rc/events"
 )
 
 func init() {
@@ -20,11 +21,21 @@ func init() {
 		Path:         "operations/list",
 		AuthRequired: true,
 		Fn:           rcList,
+		CanStream:    true,
 		Title:        "List the given remote and path in JSON format",
+		Parameters: []rc.Parameter{
+			{Name: "fs", Type: "string", Help: "a remote name string e.g. \"drive:\"", Required: true},
+			{Name: "remote", Type: "string", Help: "a path within that remote e.g. \"dir\"", Required: true},
+			{Name: "opt", Type: "object", Help: "a dictionary of options to control the listing"},
+			{Name: "maxResults", Type: "integer", Help: "the most items to return in one page of the listing"},
+			{Name: "continuationToken", Type: "string", Help: "token from the previous page to read the next page"},
+		},
 		Help: `This takes the following parameters:
 
 - fs - a remote name string e.g. "drive:"
 - remote - a path within that remote e.g. "dir"
+- maxResults - the most items to return in one page (optional)
+- continuationToken - continuationToken from the previous page (optional)
 - opt - a dictionary of options to control the listing (optional)
     - recurse - If set recurse directories
     - noModTime - If set return modification time
@@ -40,6 +51,18 @@ Returns:
 
 - list
     - This is an array of objects as described in the lsjson command
+- continuationToken - set if maxResults was passed and there are more items
+
+If maxResults is set then the listing is returned a page at a time.
+Pass the continuationToken returned along with the same fs, remote,
+maxResults and opt to read the next page. When there are no more
+items continuationToken isn't returned. The listing carries on in the
+background between pages and is abandoned if the next page isn't
+read within 5 minutes.
+
+If _stream is set then each item is written as a line of JSON as it
+is listed instead of returning list. It can't be used with
+maxResults.
 
 See the [lsjson command](/commands/rclone_lsjson/) for more information on the above and examples.
 `,
@@ -57,6 +80,44 @@ func rcList(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	if rc.NotErrParamNotFound(err) {
 		return nil, err
 	}
+	maxResults, err := in.GetInt64("maxResults")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	paged := err == nil
+	token, err := in.GetString("continuationToken")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	stream := rc.GetStream(ctx)
+	if stream != nil {
+		if paged || token != "" {
+			return nil, rc.NewErrParamInvalid(errors.New("can't page the listing with maxResults when streaming it"))
+		}
+		err = ListJSON(ctx, f, remote, &opt, func(item *ListJSONItem) error {
+			return stream(item)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	if paged || token != "" {
+		if maxResults <= 0 {
+			return nil, rc.NewErrParamInvalid(errors.New("maxResults must be set to a number > 0 to page the listing"))
+		}
+		list, nextToken, err := ListJSONPage(ctx, f, remote, &opt, int(maxResults), token)
+		if err != nil {
+			return nil, err
+		}
+		out = rc.Params{
+			"list": list,
+		}
+		if nextToken != "" {
+			out["continuationToken"] = nextToken
+		}
+		return out, nil
+	}
 	var list = []*ListJSONItem{}
 	err = ListJSON(ctx, f, remote, &opt, func(item *ListJSONItem) error {
 		list = append(list, item)
@@ -167,6 +228,12 @@ func init() {
 				return rcMoveOrCopyFile(ctx, in, copy)
 			},
 			Title: name + " a file from source remote to destination remote",
+			Parameters: []rc.Parameter{
+				{Name: "srcFs", Type: "string", Help: "a remote name string e.g. \"drive:\" for the source", Required: true},
+				{Name: "srcRemote", Type: "string", Help: "a path within that remote e.g. \"file.txt\" for the source", Required: true},
+				{Name: "dstFs", Type: "string", Help: "a remote name string e.g. \"drive2:\" for the destination", Required: true},
+				{Name: "dstRemote", Type: "string", Help: "a path within that remote e.g. \"file2.txt\" for the destination", Required: true},
+			},
 			Help: `This takes the following parameters:
 
 - srcFs - a remote name string e.g. "drive:" for the source
@@ -178,6 +245,77 @@ func init() {
 	}
 }
 
+func init() {
+	rc.Add(rc.Call{
+		Path:         "operations/streamcopy",
+		AuthRequired: true,
+		Fn:           rcStreamCopy,
+		Title:        "Copy a large file between remotes streaming it in chunks",
+		Parameters: []rc.Parameter{
+			{Name: "srcFs", Type: "string", Help: "a remote name string e.g. \"drive:\" for the source", Required: true},
+			{Name: "srcRemote", Type: "string", Help: "a path within that remote e.g. \"file.txt\" for the source", Required: true},
+			{Name: "dstFs", Type: "string", Help: "a remote name string e.g. \"drive2:\" for the destination", Required: true},
+			{Name: "dstRemote", Type: "string", Help: "a path within that remote e.g. \"file2.txt\" for the destination", Required: true},
+			{Name: "chunkSize", Type: "string", Help: "size of the chunks to read the source in e.g. \"64M\""},
+		},
+		Help: `This takes the following parameters:
+
+- srcFs - a remote name string e.g. "drive:" for the source
+- srcRemote - a path within that remote e.g. "file.txt" for the source
+- dstFs - a remote name string e.g. "drive2:" for the destination
+- dstRemote - a path within that remote e.g. "file2.txt" for the destination
+- chunkSize - size of the chunks to read the source in (default 64M, 0 for one request)
+
+Unlike operations/copyfile this never tries a server-side copy. The
+source is read in chunks and streamed to the destination as it is
+read, so it is suitable for very large files. The copy is checked
+against the size and hash of the source.
+
+Run it with _async=true then use job/status, whose "progress" has the
+bytes copied, speed and ETA, or job/progress to follow it.
+
+Returns:
+
+- size - the size of the copy
+`,
+	})
+}
+
+// Stream copy a file
+func rcStreamCopy(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	srcFs, srcRemote, err := rc.GetFsAndRemoteNamed(ctx, in, "srcFs", "srcRemote")
+	if err != nil {
+		return nil, err
+	}
+	dstFs, dstRemote, err := rc.GetFsAndRemoteNamed(ctx, in, "dstFs", "dstRemote")
+	if err != nil {
+		return nil, err
+	}
+	chunkSize := fs.SizeSuffix(64 * fs.Mebi)
+	chunkSizeString, err := in.GetString("chunkSize")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	} else if err == nil {
+		err = chunkSize.Set(chunkSizeString)
+		if err != nil {
+			return nil, rc.NewErrParamInvalid(fmt.Errorf("bad chunkSize: %w", err))
+		}
+	}
+	src, err := srcFs.NewObject(ctx, srcRemote)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := StreamCopy(ctx, dstFs, dstRemote, src, int64(chunkSize))
+	if err != nil {
+		return nil, err
+	}
+	out = rc.Params{}
+	if dst != nil {
+		out["size"] = dst.Size()
+	}
+	return out, nil
+}
+
 // Copy a file
 func rcMoveOrCopyFile(ctx context.Context, in rc.Params, cp bool) (out rc.Params, err error) {
 	srcFs, srcRemote, err := rc.GetFsAndRemoteNamed(ctx, in, "srcFs", "srcRemote")
@@ -196,23 +334,34 @@ func init() {
 		name         string
 		title        string
 		help         string
+		params       []rc.Parameter
 		noRemote     bool
 		needsRequest bool
 	}{
 		{name: "mkdir", title: "Make a destination directory or container"},
 		{name: "rmdir", title: "Remove an empty directory or container"},
 		{name: "purge", title: "Remove a directory or container and all of its contents"},
-		{name: "rmdirs", title: "Remove all the empty directories in the path", help: "- leaveRoot - boolean, set to true not to delete the root"},
+		{name: "rmdirs", title: "Remove all the empty directories in the path", help: "- leaveRoot - boolean, set to true not to delete the root", params: []rc.Parameter{
+			{Name: "leaveRoot", Type: "boolean", Help: "set to true not to delete the root"},
+		}},
 		{name: "delete", title: "Remove files in the path", noRemote: true},
 		{name: "deletefile", title: "Remove the single file pointed to"},
-		{name: "copyurl", title: "Copy the URL to the object", help: "- url - string, URL to read from\n - autoFilename - boolean, set to true to retrieve destination file name from url"},
+		{name: "copyurl", title: "Copy the URL to the object", help: "- url - string, URL to read from\n - autoFilename - boolean, set to true to retrieve destination file name from url", params: []rc.Parameter{
+			{Name: "url", Type: "string", Help: "URL to read from", Required: true},
+			{Name: "autoFilename", Type: "boolean", Help: "set to true to retrieve destination file name from url"},
+		}},
 		{name: "uploadfile", title: "Upload file using multiform/form-data", help: "- each part in body represents a file to be uploaded", needsRequest: true},
 		{name: "cleanup", title: "Remove trashed files in the remote or path", noRemote: true},
 	} {
 		op := op
 		remote := "- remote - a path within that remote e.g. \"dir\"\n"
+		params := []rc.Parameter{
+			{Name: "fs", Type: "string", Help: "a remote name string e.g. \"drive:\"", Required: true},
+		}
 		if op.noRemote {
 			remote = ""
+		} else {
+			params = append(params, rc.Parameter{Name: "remote", Type: "string", Help: "a path within that remote e.g. \"dir\"", Required: true})
 		}
 		rc.Add(rc.Call{
 			Path:         "operations/" + op.name,
@@ -221,7 +370,8 @@ func init() {
 			Fn: func(ctx context.Context, in rc.Params) (rc.Params, error) {
 				return rcSingleCommand(ctx, in, op.name, op.noRemote)
 			},
-			Title: op.title,
+			Title:      op.title,
+			Parameters: append(params, op.params...),
 			Help: `This takes the following parameters:
 
 - fs - a remote name string e.g. "drive:"
@@ -278,44 +428,116 @@ func rcSingleCommand(ctx context.Context, in rc.Params, name string, noRemote bo
 		_, err = CopyURL(ctx, f, remote, url, autoFilename, noClobber)
 		return nil, err
 	case "uploadfile":
-
-		var request *http.Request
 		request, err := in.GetHTTPRequest()
-
 		if err != nil {
 			return nil, err
 		}
+		_, err = UploadMultipart(ctx, f, remote, request)
+		return nil, err
+	case "cleanup":
+		return nil, CleanUp(ctx, f)
+	}
+	panic("unknown rcSingleCommand type")
+}
 
-		contentType := request.Header.Get("Content-Type")
-		mediaType, params, err := mime.ParseMediaType(contentType)
+func init() {
+	rc.Add(rc.Call{
+		Path:         "operations/upload",
+		AuthRequired: true,
+		NeedsRequest: true,
+		Fn:           rcUpload,
+		Title:        "Upload files streaming them to the remote, optionally in resumable chunks",
+		Parameters: []rc.Parameter{
+			{Name: "fs", Type: "string", Help: "a remote name string e.g. \"drive:\"", Required: true},
+			{Name: "remote", Type: "string", Help: "the directory to upload to, or the file if size is set", Required: true},
+			{Name: "size", Type: "integer", Help: "total size of the file to upload it in chunks"},
+			{Name: "offset", Type: "integer", Help: "offset of this chunk in the file"},
+			{Name: "uploadId", Type: "string", Help: "id of the chunked upload returned with the first chunk"},
+		},
+		Help: `This takes the following parameters:
+
+- fs - a remote name string e.g. "drive:"
+- remote - a path within that remote e.g. "dir"
+- size - total size of the file to upload it in chunks (optional)
+- offset - offset of this chunk in the file (optional)
+- uploadId - id of the chunked upload from the first chunk (optional)
+
+The parameters should be passed in the URL as the body of the request
+is the data to upload.
+
+If size isn't set then the body should be multipart/form-data and
+each file in it is uploaded to the directory remote as it is read,
+without being stored on the machine running rclone. This returns
+
+- files - the paths of the files uploaded
+
+If size is set then remote is the file to upload and the body is a
+chunk of it, either the whole body or the first file in a
+multipart/form-data body. The chunks are stored in the cache directory
+until size bytes have been received, then the file is uploaded to the
+remote. Leave out uploadId for the first chunk and pass the one
+returned with each of the following chunks. Pass offset to check each
+chunk goes where it is expected. If a chunk fails, send an empty
+chunk without offset to find out where to resume from. Uploads which
+don't receive a chunk for 24 hours are removed. This returns
+
+- uploadId - pass with the next chunk
+- offset - the number of bytes received so far
+- size - as passed in
+- finished - true once the file has been uploaded to the remote
+
+Eg
+
+    curl -X POST --data-binary @chunk1 'http://localhost:5572/operations/upload?fs=remote:&remote=dir/file.bin&size=20000000'
+    curl -X POST --data-binary @chunk2 'http://localhost:5572/operations/upload?fs=remote:&remote=dir/file.bin&size=20000000&offset=10000000&uploadId=ID'
+`,
+	})
+}
+
+// Upload the files in the request
+func rcUpload(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	f, remote, err := rc.GetFsAndRemote(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	request, err := in.GetHTTPRequest()
+	if err != nil {
+		return nil, err
+	}
+	size, err := in.GetInt64("size")
+	if rc.IsErrParamNotFound(err) {
+		names, err := UploadMultipart(ctx, f, remote, request)
 		if err != nil {
 			return nil, err
 		}
-
-		if strings.HasPrefix(mediaType, "multipart/") {
-			mr := multipart.NewReader(request.Body, params["boundary"])
-			for {
-				p, err := mr.NextPart()
-				if err == io.EOF {
-					return nil, nil
-				}
-				if err != nil {
-					return nil, err
-				}
-				if p.FileName() != "" {
-					obj, err := Rcat(ctx, f, path.Join(remote, p.FileName()), p, time.Now())
-					if err != nil {
-						return nil, err
-					}
-					fs.Debugf(obj, "Upload Succeeded")
-				}
-			}
-		}
-		return nil, nil
-	case "cleanup":
-		return nil, CleanUp(ctx, f)
+		return rc.Params{"files": names}, nil
+	} else if err != nil {
+		return nil, err
 	}
-	panic("unknown rcSingleCommand type")
+	offset, err := in.GetInt64("offset")
+	if rc.IsErrParamNotFound(err) {
+		offset = -1
+	} else if err != nil {
+		return nil, err
+	}
+	uploadID, err := in.GetString("uploadId")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	chunk, err := uploadChunkReader(request)
+	if err != nil {
+		return nil, err
+	}
+	upload, err := UploadChunk(ctx, f, remote, uploadID, offset, size, chunk)
+	if err != nil {
+		return nil, err
+	}
+	out = rc.Params{}
+	err = rc.Reshape(&out, upload)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
 }
 
 func init() {
@@ -354,6 +576,248 @@ func rcSize(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	return out, nil
 }
 
+func init() {
+	rc.Add(rc.Call{
+		Path:         "operations/rename",
+		AuthRequired: true,
+		Fn:           rcRename,
+		Title:        "Rename the files in remote whose paths match a regular expression",
+		Parameters: []rc.Parameter{
+			{Name: "fs", Type: "string", Help: "a remote name string e.g. \"drive:path/to/dir\"", Required: true},
+			{Name: "fromRegex", Type: "string", Help: "regular expression to match the paths of the files", Required: true},
+			{Name: "to", Type: "string", Help: "replacement for the matches", Required: true},
+		},
+		Help: `This takes the following parameters:
+
+- fs - a remote name string e.g. "drive:path/to/dir"
+- fromRegex - regular expression to match the paths of the files
+- to - replacement for the matches, which may use $1 or ${name} for submatches
+
+Returns:
+
+- renames - array of the files renamed, each with from and to paths
+
+Set "DryRun": true in _config to see what would be renamed without
+renaming anything.
+
+See the [rename](/commands/rclone_rename/) command for more information on the above.
+`,
+	})
+}
+
+// Rename files matching a regexp
+func rcRename(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	f, err := rc.GetFs(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	fromRegex, err := in.GetString("fromRegex")
+	if err != nil {
+		return nil, err
+	}
+	from, err := regexp.Compile(fromRegex)
+	if err != nil {
+		return nil, rc.NewErrParamInvalid(fmt.Errorf("bad fromRegex: %w", err))
+	}
+	to, err := in.GetString("to")
+	if err != nil {
+		return nil, err
+	}
+	renames, err := Rename(ctx, f, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if renames == nil {
+		renames = []RenamePair{}
+	}
+	return rc.Params{
+		"renames": renames,
+	}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "operations/du",
+		AuthRequired: true,
+		Fn:           rcDu,
+		Title:        "Return the disk usage of each directory in the remote",
+		Parameters: []rc.Parameter{
+			{Name: "fs", Type: "string", Help: "a remote name string e.g. \"drive:\"", Required: true},
+			{Name: "remote", Type: "string", Help: "a path within that remote e.g. \"dir\"", Required: true},
+			{Name: "depth", Type: "integer", Help: "levels of subdirectories to return, -1 for all"},
+		},
+		Help: `This scans the remote like the [ncdu command](/commands/rclone_ncdu/)
+and returns the size of each directory including everything in it.
+
+This takes the following parameters:
+
+- fs - a remote name string e.g. "drive:"
+- remote - a path within that remote e.g. "dir"
+- depth - levels of subdirectories to return (optional, default -1 for all)
+
+The whole tree is always scanned so the sizes include everything
+below each directory however deep it is. Use --max-depth in _config
+to limit the scan instead.
+
+Returns a tree of directories starting at remote, each with:
+
+- path - path of the directory
+- size - number of bytes in the files in it and its subdirectories
+- count - number of files in it and its subdirectories
+- error - error reading the directory, if any
+- dirs - array of the subdirectories, sorted by path
+
+Scanning big remotes can take a long time so consider using _async.
+`,
+	})
+}
+
+// Return the disk usage tree
+func rcDu(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	f, remote, err := rc.GetFsAndRemote(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	depth, err := in.GetInt64("depth")
+	if rc.IsErrParamNotFound(err) {
+		depth = -1
+	} else if err != nil {
+		return nil, err
+	}
+	du, err := Du(ctx, f, remote, int(depth))
+	if err != nil {
+		return nil, err
+	}
+	out = make(rc.Params)
+	err = rc.Reshape(&out, du)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "operations/hashsum",
+		AuthRequired: true,
+		Fn:           rcHashsum,
+		CanStream:    true,
+		Title:        "Produce a hashsum file for all the objects in the path.",
+		Parameters: []rc.Parameter{
+			{Name: "fs", Type: "string", Help: "a remote name string e.g. \"drive:path/to/dir\"", Required: true},
+			{Name: "hashType", Type: "string", Help: "type of hash to generate, e.g. \"md5\"", Required: true},
+			{Name: "download", Type: "boolean", Help: "download the files and hash them locally"},
+			{Name: "base64", Type: "boolean", Help: "output the hashes in base64 rather than hex"},
+		},
+		Help: `This produces a hash for each file in the path like the [hashsum
+command](/commands/rclone_hashsum/).
+
+This takes the following parameters:
+
+- fs - a remote name string e.g. "drive:path/to/dir"
+- hashType - type of hash to generate, e.g. "md5", "sha1"
+- download - download the files and hash them locally (optional, default false)
+- base64 - output the hashes in base64 rather than hex (optional, default false)
+
+If the remote doesn't support hashType then the files are downloaded
+and hashed locally as if download was set.
+
+Returns:
+
+- hashType - the type of hash used
+- hashsum - array of lines in the format "hash  path" in the order they were hashed
+
+Results can be read as each file is hashed rather than when they are
+all done in two ways:
+
+- with _stream set each file is written as a line of JSON with "name"
+and "hash" (or "error") instead of returning hashsum.
+- with _async set a "hash" event is published for each file which can
+be read with [core/subscribe](#core-subscribe).
+
+Files which fail to hash are logged and counted as errors and left
+out of hashsum.
+`,
+	})
+}
+
+// Hash the objects in a remote
+func rcHashsum(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	f, err := rc.GetFs(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	hashType, err := in.GetString("hashType")
+	if err != nil {
+		return nil, err
+	}
+	var ht hash.Type
+	err = ht.Set(hashType)
+	if err != nil {
+		return nil, err
+	}
+	download, err := in.GetBool("download")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	base64, err := in.GetBool("base64")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if !download && !f.Hashes().Contains(ht) {
+		fs.Debugf(f, "Hash %v not supported - downloading files to hash them", ht)
+		download = true
+	}
+	group, _ := accounting.StatsGroupFromContext(ctx)
+	stream := rc.GetStream(ctx)
+	var (
+		mu        sync.Mutex
+		lines     = []string{}
+		streamErr error
+	)
+	err = HashListerFn(ctx, ht, base64, download, f, func(o fs.Object, sum string, err error) {
+		item := rc.Params{
+			"name": o.Remote(),
+		}
+		if err != nil {
+			fs.Errorf(o, "%v", fs.CountError(err))
+			item["error"] = err.Error()
+		} else {
+			item["hash"] = sum
+		}
+		mu.Lock()
+		if stream != nil {
+			if streamErr == nil {
+				streamErr = stream(item)
+			}
+		} else if err == nil {
+			lines = append(lines, sum+"  "+o.Remote())
+		}
+		mu.Unlock()
+		data := rc.Params{
+			"hashType": ht.String(),
+			"group":    group,
+		}
+		for k, v := range item {
+			data[k] = v
+		}
+		events.Publish(events.TypeHash, data)
+	})
+	if err == nil {
+		err = streamErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stream != nil {
+		return nil, nil
+	}
+	return rc.Params{
+		"hashType": ht.String(),
+		"hashsum":  lines,
+	}, nil
+}
+
 func init() {
 	rc.Add(rc.Call{
 		Path:         "operations/publiclink",
@@ -2,16 +2,20 @@ package operations_test
 
 import (
 	"context"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"os"
 	"path"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/config"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fs/rc"
 	"github.com/rclone/rclone/fstest"
@@ -93,6 +97,35 @@ func TestRcCopyfile(t *testing.T) {
 	r.CheckRemoteItems(t, file1)
 }
 
+// operations/streamcopy: Copy a file streaming it in chunks
+func TestRcStreamCopy(t *testing.T) {
+	r, call := rcNewRun(t, "operations/streamcopy")
+	defer r.Finalise()
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+	file2 := r.WriteObject(context.Background(), "file1-copy", "old", t2)
+	r.CheckLocalItems(t, file1)
+	r.CheckRemoteItems(t, file2)
+
+	in := rc.Params{
+		"srcFs":     r.LocalName,
+		"srcRemote": "file1",
+		"dstFs":     r.FremoteName,
+		"dstRemote": "file1-copy",
+		"chunkSize": "4B",
+	}
+	out, err := call.Fn(context.Background(), in)
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"size": int64(14)}, out)
+
+	r.CheckLocalItems(t, file1)
+	file1.Path = "file1-copy"
+	r.CheckRemoteItems(t, file1)
+
+	in["chunkSize"] = "potato"
+	_, err = call.Fn(context.Background(), in)
+	assert.True(t, rc.IsErrParamInvalid(err))
+}
+
 // operations/copyurl: Copy the URL to the object
 func TestRcCopyurl(t *testing.T) {
 	r, call := rcNewRun(t, "operations/copyurl")
@@ -261,6 +294,87 @@ func TestRcList(t *testing.T) {
 	checkFile2(list[2])
 }
 
+// operations/list: List a page at a time
+func TestRcListPaged(t *testing.T) {
+	r, call := rcNewRun(t, "operations/list")
+	defer r.Finalise()
+
+	ctx := context.Background()
+	file1 := r.WriteObject(ctx, "a", "a", t1)
+	file2 := r.WriteObject(ctx, "b", "b", t1)
+	file3 := r.WriteObject(ctx, "subdir/c", "c", t1)
+	file4 := r.WriteObject(ctx, "subdir/d", "d", t1)
+	r.CheckRemoteItems(t, file1, file2, file3, file4)
+
+	var (
+		paths []string
+		token string
+		pages int
+	)
+	for {
+		in := rc.Params{
+			"fs":         r.FremoteName,
+			"remote":     "",
+			"maxResults": 2,
+			"opt": rc.Params{
+				"recurse": true,
+			},
+		}
+		if token != "" {
+			in["continuationToken"] = token
+		}
+		out, err := call.Fn(ctx, in)
+		require.NoError(t, err)
+		pages++
+		list := out["list"].([]*operations.ListJSONItem)
+		assert.LessOrEqual(t, len(list), 2)
+		for _, item := range list {
+			paths = append(paths, item.Path)
+		}
+		next, found := out["continuationToken"]
+		if !found {
+			break
+		}
+		token = next.(string)
+		require.NotEqual(t, "", token)
+	}
+	assert.Equal(t, 3, pages)
+	sort.Strings(paths)
+	assert.Equal(t, []string{"a", "b", "subdir", "subdir/c", "subdir/d"}, paths)
+
+	// The finished listing's token can't be used again
+	_, err := call.Fn(ctx, rc.Params{
+		"fs":                r.FremoteName,
+		"remote":            "",
+		"maxResults":        2,
+		"continuationToken": token,
+	})
+	assert.Error(t, err)
+
+	// A page size is needed
+	_, err = call.Fn(ctx, rc.Params{
+		"fs":                r.FremoteName,
+		"remote":            "",
+		"continuationToken": "potato",
+	})
+	assert.True(t, rc.IsErrParamInvalid(err))
+
+	// A token must be for the same listing
+	out, err := call.Fn(ctx, rc.Params{
+		"fs":         r.FremoteName,
+		"remote":     "",
+		"maxResults": 1,
+	})
+	require.NoError(t, err)
+	_, err = call.Fn(ctx, rc.Params{
+		"fs":                r.FremoteName,
+		"remote":            "subdir",
+		"maxResults":        1,
+		"continuationToken": out["continuationToken"],
+	})
+	assert.Error(t, err)
+}
+
 // operations/stat: Stat the given remote and path in JSON format.
 func TestRcStat(t *testing.T) {
 	r, call := rcNewRun(t, "operations/stat")
@@ -456,6 +570,112 @@ func TestRcSize(t *testing.T) {
 	}, out)
 }
 
+// operations/hashsum: Produce a hashsum file for all the objects in the path
+func TestRcHashsum(t *testing.T) {
+	ctx := context.Background()
+	r, call := rcNewRun(t, "operations/hashsum")
+	defer r.Finalise()
+	file1 := r.WriteObject(ctx, "a", "hello", t1)
+	file2 := r.WriteObject(ctx, "dir/b", "world", t1)
+	r.CheckRemoteItems(t, file1, file2)
+
+	in := rc.Params{
+		"fs":       r.FremoteName,
+		"hashType": "md5",
+		"download": true,
+	}
+	out, err := call.Fn(ctx, in)
+	require.NoError(t, err)
+	assert.Equal(t, "md5", out["hashType"])
+	lines := out["hashsum"].([]string)
+	sort.Strings(lines)
+	assert.Equal(t, []string{
+		"5d41402abc4b2a76b9719d911017c592  a",
+		"7d793037a0760186574b0282f2f435e7  dir/b",
+	}, lines)
+
+	// Streamed
+	var items []rc.Params
+	streamCtx := rc.WithStream(ctx, func(item interface{}) error {
+		items = append(items, item.(rc.Params))
+		return nil
+	})
+	out, err = call.Fn(streamCtx, rc.Params{
+		"fs":       r.FremoteName,
+		"hashType": "md5",
+		"download": true,
+	})
+	require.NoError(t, err)
+	assert.Nil(t, out)
+	sort.Slice(items, func(i, j int) bool { return items[i]["name"].(string) < items[j]["name"].(string) })
+	assert.Equal(t, []rc.Params{
+		{"name": "a", "hash": "5d41402abc4b2a76b9719d911017c592"},
+		{"name": "dir/b", "hash": "7d793037a0760186574b0282f2f435e7"},
+	}, items)
+
+	// Bad hash type
+	_, err = call.Fn(ctx, rc.Params{
+		"fs":       r.FremoteName,
+		"hashType": "potato",
+	})
+	assert.Error(t, err)
+}
+
+// operations/du: Return the disk usage of each directory in the remote
+func TestRcDu(t *testing.T) {
+	r, call := rcNewRun(t, "operations/du")
+	defer r.Finalise()
+	file1 := r.WriteObject(context.Background(), "small", "1234567890", t2)                                                           // 10 bytes
+	file2 := r.WriteObject(context.Background(), "subdir/medium", "------------------------------------------------------------", t1) // 60 bytes
+	file3 := r.WriteObject(context.Background(), "subdir/subsubdir/large", "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", t1)  // 50 bytes
+	file4 := r.WriteObject(context.Background(), "another/tiny", "1", t1)                                                             // 1 byte
+	r.CheckRemoteItems(t, file1, file2, file3, file4)
+
+	in := rc.Params{
+		"fs":     r.FremoteName,
+		"remote": "",
+	}
+	out, err := call.Fn(context.Background(), in)
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"path":  "",
+		"size":  float64(121),
+		"count": float64(4),
+		"dirs": []interface{}{
+			map[string]interface{}{
+				"path":  "another",
+				"size":  float64(1),
+				"count": float64(1),
+			},
+			map[string]interface{}{
+				"path":  "subdir",
+				"size":  float64(110),
+				"count": float64(2),
+				"dirs": []interface{}{
+					map[string]interface{}{
+						"path":  "subdir/subsubdir",
+						"size":  float64(50),
+						"count": float64(1),
+					},
+				},
+			},
+		},
+	}, out)
+
+	in = rc.Params{
+		"fs":     r.FremoteName,
+		"remote": "subdir",
+		"depth":  0,
+	}
+	out, err = call.Fn(context.Background(), in)
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"path":  "subdir",
+		"size":  float64(110),
+		"count": float64(2),
+	}, out)
+}
+
 // operations/publiclink: Create or retrieve a public link to the given file or folder.
 func TestRcPublicLink(t *testing.T) {
 	r, call := rcNewRun(t, "operations/publiclink")
@@ -555,6 +775,77 @@ func TestUploadFile(t *testing.T) {
 
 }
 
+// operations/upload: Upload files in one go or in chunks
+func TestRcUpload(t *testing.T) {
+	r, call := rcNewRun(t, "operations/upload")
+	defer r.Finalise()
+	ctx := context.Background()
+	oldCacheDir := config.GetCacheDir()
+	require.NoError(t, config.SetCacheDir(t.TempDir()))
+	defer func() {
+		_ = config.SetCacheDir(oldCacheDir)
+	}()
+
+	// A multipart upload is streamed into the directory
+	formReader, contentType, _, err := rest.MultipartUpload(ctx, ioutil.NopCloser(strings.NewReader("Hello World")), url.Values{}, "file", "test.txt")
+	require.NoError(t, err)
+	httpReq := httptest.NewRequest("POST", "/", formReader)
+	httpReq.Header.Add("Content-Type", contentType)
+	out, err := call.Fn(ctx, rc.Params{
+		"_request": httpReq,
+		"fs":       r.FremoteName,
+		"remote":   "dir",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"files": []string{"dir/test.txt"}}, out)
+
+	// upload a chunk returning the output
+	upload := func(in rc.Params, chunk string) (rc.Params, error) {
+		httpReq := httptest.NewRequest("POST", "/", strings.NewReader(chunk))
+		httpReq.Header.Add("Content-Type", "application/octet-stream")
+		in["_request"] = httpReq
+		in["fs"] = r.FremoteName
+		in["remote"] = "chunked.txt"
+		in["size"] = 11
+		return call.Fn(ctx, in)
+	}
+	out, err = upload(rc.Params{"offset": 0}, "Hello")
+	require.NoError(t, err)
+	uploadID, ok := out["uploadId"].(string)
+	require.True(t, ok)
+	assert.Equal(t, float64(5), out["offset"])
+	assert.Equal(t, false, out["finished"])
+
+	// A chunk in the wrong place is refused
+	_, err = upload(rc.Params{"uploadId": uploadID, "offset": 2}, " World")
+	assert.Error(t, err)
+
+	// An empty chunk reads the offset to resume from
+	out, err = upload(rc.Params{"uploadId": uploadID}, "")
+	require.NoError(t, err)
+	assert.Equal(t, float64(5), out["offset"])
+
+	// Unknown and bad upload IDs are refused
+	_, err = upload(rc.Params{"uploadId": "potato"}, "")
+	assert.Error(t, err)
+	_, err = upload(rc.Params{"uploadId": "../potato"}, "")
+	assert.Error(t, err)
+
+	out, err = upload(rc.Params{"uploadId": uploadID, "offset": 5}, " World")
+	require.NoError(t, err)
+	assert.Equal(t, float64(11), out["offset"])
+	assert.Equal(t, true, out["finished"])
+
+	// Too much data is an error
+	_, err = upload(rc.Params{}, "Hello World!")
+	assert.Error(t, err)
+
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{
+		fstest.NewItem("dir/test.txt", "Hello World", t1),
+		fstest.NewItem("chunked.txt", "Hello World", t1),
+	}, nil, fs.ModTimeNotSupported)
+}
+
 // operations/command: Runs a backend command
 func TestRcCommand(t *testing.T) {
 	r, call := rcNewRun(t, "backend/command")
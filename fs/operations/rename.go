@@ -0,0 +1,122 @@
+package operations
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// RenamePair is a file renamed by Rename
+type RenamePair struct {
+	From string `json:"from"` // the old path of the file
+	To   string `json:"to"`   // the new path of the file
+}
+
+// renameTarget works out the new name of remote, returning "" if it
+// isn't to be renamed
+func renameTarget(from *regexp.Regexp, to string, remote string) (string, error) {
+	if !from.MatchString(remote) {
+		return "", nil
+	}
+	newRemote := from.ReplaceAllString(remote, to)
+	if newRemote == remote {
+		return "", nil
+	}
+	cleanRemote := path.Clean(newRemote)
+	if newRemote == "" || cleanRemote == "." || cleanRemote == ".." || strings.HasPrefix(cleanRemote, "../") || strings.HasPrefix(cleanRemote, "/") {
+		return "", fmt.Errorf("new name %q is outside the remote", newRemote)
+	}
+	return cleanRemote, nil
+}
+
+// Rename renames all the files in f whose path matches the regexp
+// from. The new path is made by replacing the matches with to, which
+// may contain $1 style references to the submatches as in
+// regexp.ReplaceAllString.
+//
+// Files aren't renamed over existing files or each other. The renames
+// are done with server-side moves where possible.
+//
+// It returns the renames which were done, or which would have been
+// done with --dry-run.
+func Rename(ctx context.Context, f fs.Fs, from *regexp.Regexp, to string) (renames []RenamePair, err error) {
+	ci := fs.GetConfig(ctx)
+	objs := map[string]fs.Object{}
+	err = walk.ListR(ctx, f, "", false, ci.MaxDepth, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			objs[o.Remote()] = o
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files to rename: %w", err)
+	}
+
+	// Work out the renames checking for clashes before doing any
+	var errorCount int32
+	targets := map[string][]string{}
+	for remote, o := range objs {
+		newRemote, err := renameTarget(from, to, remote)
+		if err != nil {
+			fs.Errorf(o, "Not renaming: %v", err)
+			errorCount++
+		} else if newRemote != "" {
+			targets[newRemote] = append(targets[newRemote], remote)
+		}
+	}
+	for newRemote, remotes := range targets {
+		sort.Strings(remotes)
+		if _, exists := objs[newRemote]; exists {
+			for _, remote := range remotes {
+				fs.Errorf(objs[remote], "Not renaming as %q already exists", newRemote)
+			}
+			errorCount += int32(len(remotes))
+		} else if len(remotes) > 1 {
+			fs.Errorf(newRemote, "Not renaming %q as they would all have this name", remotes)
+			errorCount += int32(len(remotes))
+		} else {
+			renames = append(renames, RenamePair{From: remotes[0], To: newRemote})
+		}
+	}
+	sort.Slice(renames, func(i, j int) bool {
+		return renames[i].From < renames[j].From
+	})
+
+	in := make(chan RenamePair, ci.Transfers)
+	var wg sync.WaitGroup
+	wg.Add(ci.Transfers)
+	for i := 0; i < ci.Transfers; i++ {
+		go func() {
+			defer wg.Done()
+			for rename := range in {
+				_, err := Move(ctx, f, nil, rename.To, objs[rename.From])
+				if err != nil {
+					fs.Errorf(objs[rename.From], "Failed to rename to %q: %v", rename.To, err)
+					atomic.AddInt32(&errorCount, 1)
+				}
+			}
+		}()
+	}
+outer:
+	for _, rename := range renames {
+		select {
+		case <-ctx.Done():
+			break outer
+		case in <- rename:
+		}
+	}
+	close(in)
+	wg.Wait()
+	if errorCount > 0 {
+		return renames, fmt.Errorf("failed to rename %d files", errorCount)
+	}
+	return renames, ctx.Err()
+}
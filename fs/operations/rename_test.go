@@ -0,0 +1,117 @@
+package operations_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRename(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	file1 := r.WriteObject(ctx, "a.jpeg", "a", t1)
+	file2 := r.WriteObject(ctx, "sub/b.jpeg", "b", t1)
+	file3 := r.WriteObject(ctx, "c.txt", "c", t1)
+	r.CheckRemoteItems(t, file1, file2, file3)
+
+	renames, err := operations.Rename(ctx, r.Fremote, regexp.MustCompile(`\.jpeg$`), ".jpg")
+	require.NoError(t, err)
+	assert.Equal(t, []operations.RenamePair{
+		{From: "a.jpeg", To: "a.jpg"},
+		{From: "sub/b.jpeg", To: "sub/b.jpg"},
+	}, renames)
+
+	file1.Path = "a.jpg"
+	file2.Path = "sub/b.jpg"
+	r.CheckRemoteItems(t, file1, file2, file3)
+
+	// Submatches can move files into other directories
+	_, err = operations.Rename(ctx, r.Fremote, regexp.MustCompile(`^(\w)\.(\w+)$`), "$2/$1")
+	require.NoError(t, err)
+	file1.Path = "jpg/a"
+	file3.Path = "txt/c"
+	r.CheckRemoteItems(t, file1, file2, file3)
+
+	// Can't rename outside the remote
+	_, err = operations.Rename(ctx, r.Fremote, regexp.MustCompile(`^txt/c$`), "../c")
+	assert.Error(t, err)
+	r.CheckRemoteItems(t, file1, file2, file3)
+}
+
+func TestRenameClash(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	file1 := r.WriteObject(ctx, "file1.txt", "1", t1)
+	file2 := r.WriteObject(ctx, "file2.txt", "2", t1)
+	file3 := r.WriteObject(ctx, "file3.dat", "3", t1)
+	file4 := r.WriteObject(ctx, "file3.txt", "4", t1)
+	file5 := r.WriteObject(ctx, "file4.dat", "5", t1)
+	r.CheckRemoteItems(t, file1, file2, file3, file4, file5)
+
+	// file1 and file2 would get the same name
+	renames, err := operations.Rename(ctx, r.Fremote, regexp.MustCompile(`^file[12]\.txt$`), "X")
+	assert.Error(t, err)
+	assert.Nil(t, renames)
+	r.CheckRemoteItems(t, file1, file2, file3, file4, file5)
+
+	// file3.dat would overwrite file3.txt so only file4.dat is renamed
+	renames, err = operations.Rename(ctx, r.Fremote, regexp.MustCompile(`\.dat$`), ".txt")
+	assert.Error(t, err)
+	assert.Equal(t, []operations.RenamePair{{From: "file4.dat", To: "file4.txt"}}, renames)
+	file5.Path = "file4.txt"
+	r.CheckRemoteItems(t, file1, file2, file3, file4, file5)
+}
+
+func TestRenameDryRun(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	file1 := r.WriteObject(ctx, "file1", "1", t1)
+	r.CheckRemoteItems(t, file1)
+
+	ci.DryRun = true
+	renames, err := operations.Rename(ctx, r.Fremote, regexp.MustCompile(`1`), "2")
+	require.NoError(t, err)
+	assert.Equal(t, []operations.RenamePair{{From: "file1", To: "file2"}}, renames)
+	r.CheckRemoteItems(t, file1)
+}
+
+// operations/rename: Rename the files in remote whose paths match a regular expression
+func TestRcRename(t *testing.T) {
+	r, call := rcNewRun(t, "operations/rename")
+	defer r.Finalise()
+	ctx := context.Background()
+
+	file1 := r.WriteObject(ctx, "IMG_20220510.jpg", "1", t1)
+	r.CheckRemoteItems(t, file1)
+
+	in := rc.Params{
+		"fs":        r.FremoteName,
+		"fromRegex": `^IMG_(\d{4})(\d{2})`,
+		"to":        "$1/$2/IMG_$1$2",
+	}
+	out, err := call.Fn(ctx, in)
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"renames": []operations.RenamePair{{From: "IMG_20220510.jpg", To: "2022/05/IMG_20220510.jpg"}},
+	}, out)
+	file1.Path = "2022/05/IMG_20220510.jpg"
+	r.CheckRemoteItems(t, file1)
+
+	in["fromRegex"] = "("
+	_, err = call.Fn(ctx, in)
+	assert.Error(t, err)
+}
@@ -0,0 +1,91 @@
+package operations
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+)
+
+// resumeUploadState is saved in the cache directory while a
+// multi-thread upload is in progress with --resume-uploads so that if
+// it is interrupted it can be resumed by the next run.
+type resumeUploadState struct {
+	Dst      string    `json:"dst"`      // where the upload is going to
+	Size     int64     `json:"size"`     // size of the source
+	ModTime  time.Time `json:"modTime"`  // modification time of the source
+	ResumeID string    `json:"resumeId"` // passed to ResumeChunkWriter
+}
+
+// resumeUpload saves and loads the resumeUploadState for an upload
+type resumeUpload struct {
+	path  string
+	state resumeUploadState
+}
+
+// newResumeUpload makes a resumeUpload for uploading src to remote
+// on f
+func newResumeUpload(f fs.Fs, remote string, src fs.ObjectInfo, modTime time.Time) (*resumeUpload, error) {
+	dir := filepath.Join(config.GetCacheDir(), "resume-upload")
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make resume directory: %w", err)
+	}
+	dst := fs.ConfigString(f)
+	if remote != "" {
+		dst += "/" + remote
+	}
+	sum := sha1.Sum([]byte(dst))
+	return &resumeUpload{
+		path: filepath.Join(dir, hex.EncodeToString(sum[:])+".json"),
+		state: resumeUploadState{
+			Dst:     dst,
+			Size:    src.Size(),
+			ModTime: modTime,
+		},
+	}, nil
+}
+
+// load returns the ResumeID saved by a previous upload of the same
+// source to the same place or "" if there isn't one
+func (r *resumeUpload) load() string {
+	data, err := ioutil.ReadFile(r.path)
+	if os.IsNotExist(err) {
+		return ""
+	} else if err != nil {
+		fs.Debugf(nil, "Failed to read resume state: %v", err)
+		return ""
+	}
+	var old resumeUploadState
+	err = json.Unmarshal(data, &old)
+	if err != nil || old.Dst != r.state.Dst || old.Size != r.state.Size || !old.ModTime.Equal(r.state.ModTime) {
+		fs.Debugf(nil, "Not resuming upload to %q as the source has changed", r.state.Dst)
+		return ""
+	}
+	return old.ResumeID
+}
+
+// save the state with resumeID
+func (r *resumeUpload) save(resumeID string) error {
+	r.state.ResumeID = resumeID
+	data, err := json.Marshal(r.state)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, data, 0600)
+}
+
+// remove the saved state
+func (r *resumeUpload) remove() {
+	err := os.Remove(r.path)
+	if err != nil && !os.IsNotExist(err) {
+		fs.Debugf(nil, "Failed to remove resume state: %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/chunkedreader"
+)
+
+// StreamCopy copies src to dstRemote on fdst by reading it in chunks
+// of chunkSize with range requests and streaming them to the
+// destination as they are read.
+//
+// Unlike Copy it never tries a server-side copy, so the transfer
+// shows its progress in the stats as it goes. If chunkSize is <= 0
+// the source is read in one request.
+func StreamCopy(ctx context.Context, fdst fs.Fs, dstRemote string, src fs.Object, chunkSize int64) (dst fs.Object, err error) {
+	tr := accounting.Stats(ctx).NewTransfer(src)
+	defer func() {
+		tr.Done(ctx, err)
+	}()
+	if SkipDestructive(ctx, src, "stream copy") {
+		in := tr.Account(ctx, nil)
+		in.DryRun(src.Size())
+		return nil, nil
+	}
+	existing, err := fdst.NewObject(ctx, dstRemote)
+	if errors.Is(err, fs.ErrorObjectNotFound) {
+		existing = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	cr, err := chunkedreader.New(ctx, src, chunkSize, chunkSize).Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source object: %w", err)
+	}
+	in := tr.Account(ctx, cr).LimitRemotes(src.Fs(), fdst).WithBuffer() // account and buffer the transfer
+	srcInfo := NewOverrideRemote(src, dstRemote)
+	if existing != nil {
+		err = existing.Update(ctx, in, srcInfo)
+		dst = existing
+	} else {
+		dst, err = fdst.Put(ctx, in, srcInfo)
+	}
+	closeErr := in.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stream copy: %w", err)
+	}
+
+	// Check the copy is the same as the source
+	if srcSize, dstSize := src.Size(), dst.Size(); srcSize >= 0 && dstSize >= 0 && srcSize != dstSize {
+		err = fmt.Errorf("corrupted on transfer: sizes differ %d vs %d", srcSize, dstSize)
+	} else if equal, hashType, hashErr := CheckHashes(ctx, src, dst); hashErr == nil && !equal {
+		err = fmt.Errorf("corrupted on transfer: %v hashes differ", hashType)
+	}
+	if err != nil {
+		fs.Errorf(dst, "%v", err)
+		if removeErr := dst.Remove(ctx); removeErr != nil {
+			fs.Errorf(dst, "Failed to remove corrupted copy: %v", removeErr)
+		}
+		return nil, err
+	}
+	fs.Infof(src, "Copied (stream copy) to: %s", dstRemote)
+	return dst, nil
+}
@@ -0,0 +1,238 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config"
+	"github.com/rclone/rclone/lib/random"
+)
+
+// maxUploadAge is how long a chunked upload which hasn't received a
+// chunk is kept before it is removed
+const maxUploadAge = 24 * time.Hour
+
+// uploadIDRe matches valid upload IDs
+var uploadIDRe = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// chunkedUploads are the uploads receiving a chunk or uploading to
+// the remote - the chunks are kept in files in the cache directory
+// until the whole file has been received
+var chunkedUploads = struct {
+	mu      sync.Mutex
+	writing map[string]bool // by upload ID
+}{
+	writing: make(map[string]bool),
+}
+
+// uploadDir returns the directory the partial uploads are kept in,
+// making it if necessary
+func uploadDir() (string, error) {
+	dir := filepath.Join(config.GetCacheDir(), "rc-upload")
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return "", fmt.Errorf("failed to make upload directory: %w", err)
+	}
+	return dir, nil
+}
+
+// removeStaleUploads removes partial uploads in dir which haven't
+// been written to for maxUploadAge
+func removeStaleUploads(dir string) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		fs.Errorf(nil, "Failed to read upload directory: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		if time.Since(entry.ModTime()) < maxUploadAge {
+			continue
+		}
+		fs.Debugf(nil, "Removing stale upload %q", entry.Name())
+		err = os.Remove(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			fs.Errorf(nil, "Failed to remove stale upload: %v", err)
+		}
+	}
+}
+
+// ChunkedUpload is the state of a chunked upload returned after each
+// chunk
+type ChunkedUpload struct {
+	UploadID string `json:"uploadId"` // pass this with the next chunk
+	Offset   int64  `json:"offset"`   // number of bytes received so far
+	Size     int64  `json:"size"`     // total size of the file
+	Finished bool   `json:"finished"` // set when the file has been uploaded to the remote
+}
+
+// UploadChunk adds the chunk read from in at offset to the upload
+// with uploadID, making a new upload if uploadID is empty. Once size
+// bytes have been received they are uploaded to remote on f.
+//
+// If offset is < 0 the chunk is added to the end of the upload. An
+// empty chunk can be used to read the offset to resume from.
+func UploadChunk(ctx context.Context, f fs.Fs, remote string, uploadID string, offset, size int64, in io.Reader) (upload *ChunkedUpload, err error) {
+	if size < 0 {
+		return nil, errors.New("size must be >= 0")
+	}
+	dir, err := uploadDir()
+	if err != nil {
+		return nil, err
+	}
+	newUpload := uploadID == ""
+	if newUpload {
+		removeStaleUploads(dir)
+		uploadID, err = random.Password(128)
+		if err != nil {
+			return nil, err
+		}
+	} else if !uploadIDRe.MatchString(uploadID) {
+		return nil, fmt.Errorf("invalid uploadId %q", uploadID)
+	}
+	chunkedUploads.mu.Lock()
+	if chunkedUploads.writing[uploadID] {
+		chunkedUploads.mu.Unlock()
+		return nil, fmt.Errorf("upload %q is already receiving a chunk", uploadID)
+	}
+	chunkedUploads.writing[uploadID] = true
+	chunkedUploads.mu.Unlock()
+	defer func() {
+		chunkedUploads.mu.Lock()
+		delete(chunkedUploads.writing, uploadID)
+		chunkedUploads.mu.Unlock()
+	}()
+
+	partialPath := filepath.Join(dir, uploadID)
+	flags := os.O_WRONLY | os.O_APPEND
+	if newUpload {
+		flags |= os.O_CREATE | os.O_EXCL
+	}
+	fd, err := os.OpenFile(partialPath, flags, 0600)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("upload %q not found", uploadID)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to open upload: %w", err)
+	}
+	upload = &ChunkedUpload{
+		UploadID: uploadID,
+		Size:     size,
+	}
+	fi, err := fd.Stat()
+	if err == nil {
+		upload.Offset = fi.Size()
+		if offset >= 0 && offset != upload.Offset {
+			err = fmt.Errorf("chunk offset %d doesn't match %d bytes received so far", offset, upload.Offset)
+		}
+	}
+	if err == nil {
+		var n int64
+		n, err = io.Copy(fd, io.LimitReader(in, size-upload.Offset+1))
+		upload.Offset += n
+		if err == nil && upload.Offset > size {
+			err = fmt.Errorf("received more than %d bytes", size)
+		}
+	}
+	closeErr := fd.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if upload.Offset > size {
+			_ = os.Remove(partialPath)
+		}
+		return nil, err
+	}
+	if upload.Offset < size {
+		fs.Debugf(remote, "Received %d/%d bytes of upload %q", upload.Offset, size, uploadID)
+		return upload, nil
+	}
+
+	// All received so upload it
+	fd, err = os.Open(partialPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload: %w", err)
+	}
+	obj, err := RcatSize(ctx, f, remote, fd, size, time.Now())
+	_ = fd.Close()
+	if err != nil {
+		// leave the upload in place so it can be retried with an empty chunk
+		return nil, err
+	}
+	fs.Debugf(obj, "Upload Succeeded")
+	err = os.Remove(partialPath)
+	if err != nil {
+		fs.Errorf(nil, "Failed to remove finished upload: %v", err)
+	}
+	upload.Finished = true
+	return upload, nil
+}
+
+// UploadMultipart uploads each file in the multipart/form-data body
+// of request into dir on f as it is read, returning their names.
+func UploadMultipart(ctx context.Context, f fs.Fs, dir string, request *http.Request) (names []string, err error) {
+	contentType := request.Header.Get("Content-Type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("expecting multipart/form-data not %q", mediaType)
+	}
+	names = []string{}
+	mr := multipart.NewReader(request.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return names, err
+		}
+		if p.FileName() == "" {
+			continue
+		}
+		name := path.Join(dir, p.FileName())
+		obj, err := Rcat(ctx, f, name, p, time.Now())
+		if err != nil {
+			return names, err
+		}
+		fs.Debugf(obj, "Upload Succeeded")
+		names = append(names, name)
+	}
+}
+
+// uploadChunkReader returns the chunk in request - the first file in
+// a multipart/form-data body or otherwise the whole body
+func uploadChunkReader(request *http.Request) (io.Reader, error) {
+	mediaType, params, err := mime.ParseMediaType(request.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return request.Body, nil
+	}
+	mr := multipart.NewReader(request.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return strings.NewReader(""), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.FileName() != "" {
+			return p, nil
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// verifyRanges is the number of ranges read back when
+// --verify-sample-size is in use
+const verifyRanges = 8
+
+// verifyAfterTransfer reads dst back and checks it is the same as src
+// for --verify-after-transfer.
+//
+// If --verify-sample-size is set and the file is bigger than it then
+// only that much of the file is read, in verifyRanges ranges spread
+// evenly from the start to the end of the file.
+func verifyAfterTransfer(ctx context.Context, dst, src fs.Object) error {
+	ci := fs.GetConfig(ctx)
+	size := src.Size()
+	sample := int64(ci.VerifySampleSize)
+	if sample < 0 || size < 0 || size <= sample {
+		fs.Debugf(dst, "Reading back to verify")
+		return verifyRange(ctx, dst, src, nil)
+	}
+	rangeSize := sample / verifyRanges
+	if rangeSize <= 0 {
+		rangeSize = 1
+	}
+	fs.Debugf(dst, "Reading back %d ranges of %v to verify", verifyRanges, fs.SizeSuffix(rangeSize))
+	for i := int64(0); i < verifyRanges; i++ {
+		start := (size - rangeSize) * i / (verifyRanges - 1)
+		err := verifyRange(ctx, dst, src, &fs.RangeOption{Start: start, End: start + rangeSize - 1})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyRange compares the part of dst and src given by option, or
+// all of them if it is nil
+func verifyRange(ctx context.Context, dst, src fs.Object, option *fs.RangeOption) (err error) {
+	ci := fs.GetConfig(ctx)
+	var options []fs.OpenOption
+	if option != nil {
+		options = append(options, option)
+	}
+	var differ bool
+	err = Retry(ctx, src, ci.LowLevelRetries, func() (err error) {
+		in1, err := dst.Open(ctx, options...)
+		if err != nil {
+			return fmt.Errorf("failed to open %q to read back: %w", dst, err)
+		}
+		defer fs.CheckClose(in1, &err)
+		in2, err := src.Open(ctx, options...)
+		if err != nil {
+			return fmt.Errorf("failed to open %q to read back: %w", src, err)
+		}
+		defer fs.CheckClose(in2, &err)
+		differ, err = CheckEqualReaders(in1, in2)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if differ {
+		if option != nil {
+			return fmt.Errorf("contents differ in bytes %d-%d when read back", option.Start, option.End)
+		}
+		return errors.New("contents differ when read back")
+	}
+	return nil
+}
@@ -5,6 +5,8 @@ package rc
 import (
 	"context"
 	"errors"
+	"strings"
+	"time"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/cache"
@@ -23,6 +25,10 @@ func GetFsNamed(ctx context.Context, in Params, fsName string) (f fs.Fs, err err
 			return nil, err
 		}
 	}
+	err = CheckTenantFs(ctx, fsString)
+	if err != nil {
+		return nil, err
+	}
 	return cache.Get(ctx, fsString)
 }
 
@@ -114,20 +120,96 @@ func init() {
 	Add(Call{
 		Path:         "fscache/entries",
 		Fn:           rcCacheEntries,
-		Title:        "Returns the number of entries in the fs cache.",
+		Title:        "Returns the entries in the fs cache.",
 		AuthRequired: true,
 		Help: `
-This returns the number of entries in the fs cache.
+This returns the number of entries in the fs cache and a list of them.
 
 Returns
 - entries - number of items in the cache
+- list - array of the items in the cache sorted by fs, each with
+    - fs - the remote string the item is cached under
+    - created - time the item was put in the cache
+    - lastUsed - time the item was last used
+    - age - time in seconds since the item was put in the cache
+    - references - number of users which have pinned the item in the cache
+    - error - error creating the item, e.g. the remote points to a file
 `,
 	})
 }
 
 // Return the Entries the fs cache
 func rcCacheEntries(ctx context.Context, in Params) (out Params, err error) {
+	list := []Params{}
+	for _, entry := range cache.List() {
+		item := Params{
+			"fs":         entry.Key,
+			"created":    entry.Created,
+			"lastUsed":   entry.LastUsed,
+			"age":        time.Since(entry.Created).Seconds(),
+			"references": entry.PinCount,
+		}
+		if entry.Err != nil {
+			item["error"] = entry.Err.Error()
+		}
+		list = append(list, item)
+	}
+	return Params{
+		"entries": len(list),
+		"list":    list,
+	}, nil
+}
+
+func init() {
+	Add(Call{
+		Path:         "fscache/evict",
+		Fn:           rcCacheEvict,
+		Title:        "Remove remotes from the fs cache.",
+		AuthRequired: true,
+		Parameters: []Parameter{
+			{Name: "fs", Type: "string", Help: "the remote string to remove e.g. \"drive:dir\""},
+			{Name: "remote", Type: "string", Help: "the name of a remote to remove all items for e.g. \"drive\""},
+		},
+		Help: `
+This removes items from the fs cache so they are made afresh with the
+current config the next time they are used, e.g. after a token or
+option has changed. Operations using them carry on unaffected.
+
+Parameters - one of
+
+- fs - the remote string to remove as passed to other calls, e.g. "drive:dir"
+- remote - the name of a remote in the config file to remove all the items for, e.g. "drive"
+
+Returns
+- evicted - number of items removed from the cache
+`,
+	})
+}
+
+// Evict entries from the fs cache
+func rcCacheEvict(ctx context.Context, in Params) (out Params, err error) {
+	fsString, err := in.GetString("fs")
+	if NotErrParamNotFound(err) {
+		return nil, err
+	}
+	remote, err := in.GetString("remote")
+	if NotErrParamNotFound(err) {
+		return nil, err
+	}
+	evicted := 0
+	switch {
+	case fsString != "" && remote != "":
+		return nil, NewErrParamInvalid(errors.New("only one of fs and remote can be set"))
+	case fsString != "":
+		if cache.Evict(fsString) {
+			evicted = 1
+		}
+	case remote != "":
+		evicted = cache.ClearConfig(strings.TrimSuffix(remote, ":"))
+	default:
+		return nil, NewErrParamInvalid(errors.New("fs or remote must be set"))
+	}
 	return Params{
-		"entries": cache.Entries(),
+		"evicted": evicted,
 	}, nil
 }
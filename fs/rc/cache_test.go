@@ -192,6 +192,38 @@ func TestGetFsAndRemote(t *testing.T) {
 			assert.NotEqual(t, 0, getEntries())
 		})
 
+		t.Run("List", func(t *testing.T) {
+			call := Calls.Get("fscache/entries")
+			require.NotNil(t, call)
+
+			out, err := call.Fn(context.Background(), Params{})
+			require.NoError(t, err)
+			list := out["list"].([]Params)
+			require.Equal(t, out["entries"], len(list))
+			assert.NotEqual(t, "", list[0]["fs"])
+			assert.Equal(t, 0, list[0]["references"])
+		})
+
+		t.Run("Evict", func(t *testing.T) {
+			call := Calls.Get("fscache/evict")
+			require.NotNil(t, call)
+
+			_, err := call.Fn(context.Background(), Params{})
+			assert.True(t, IsErrParamInvalid(err))
+			_, err = call.Fn(context.Background(), Params{"fs": "/", "remote": "mock"})
+			assert.True(t, IsErrParamInvalid(err))
+
+			before := getEntries()
+			out, err := call.Fn(context.Background(), Params{"fs": "/"})
+			require.NoError(t, err)
+			assert.Equal(t, Params{"evicted": 1}, out)
+			assert.Equal(t, before-1, getEntries())
+
+			out, err = call.Fn(context.Background(), Params{"fs": "/"})
+			require.NoError(t, err)
+			assert.Equal(t, Params{"evicted": 0}, out)
+		})
+
 		t.Run("Clear", func(t *testing.T) {
 			call := Calls.Get("fscache/clear")
 			require.NotNil(t, call)
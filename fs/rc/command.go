@@ -0,0 +1,118 @@
+// Restrictions on the commands core/command may run
+
+package rc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// errCommandNotAllowed is returned when core/command is asked to run
+// something the command policy doesn't allow
+var errCommandNotAllowed = errors.New("not allowed by the rc command policy")
+
+// commandPolicy is what core/command is allowed to run
+type commandPolicy struct {
+	disable    bool            // set if core/command is disabled
+	allow      map[string]bool // commands allowed if not empty
+	deny       map[string]bool // commands denied
+	allowFlags map[string]bool // flags allowed if not empty
+	denyFlags  map[string]bool // flags denied
+}
+
+// commandPolicyMu protects currentCommandPolicy
+var (
+	commandPolicyMu      sync.Mutex
+	currentCommandPolicy = &commandPolicy{}
+)
+
+// parseNameList parses a comma separated list of names into a set
+// ignoring any leading "-" on each name
+func parseNameList(s string) map[string]bool {
+	names := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimLeft(strings.TrimSpace(name), "-")
+		if name != "" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// SetCommandOpt sets the restrictions on the commands core/command
+// may run from opt.
+//
+// The options are copied so changing them later with options/set has
+// no effect.
+func SetCommandOpt(opt *Options) {
+	policy := &commandPolicy{
+		disable:    opt.CommandDisable,
+		allow:      parseNameList(opt.CommandAllow),
+		deny:       parseNameList(opt.CommandDeny),
+		allowFlags: parseNameList(opt.CommandAllowFlags),
+		denyFlags:  parseNameList(opt.CommandDenyFlags),
+	}
+	commandPolicyMu.Lock()
+	currentCommandPolicy = policy
+	commandPolicyMu.Unlock()
+}
+
+// getCommandPolicy returns the command policy in use
+func getCommandPolicy() *commandPolicy {
+	commandPolicyMu.Lock()
+	defer commandPolicyMu.Unlock()
+	return currentCommandPolicy
+}
+
+// restrictsCommands returns true if the policy restricts which
+// commands may be run
+func (p *commandPolicy) restrictsCommands() bool {
+	return len(p.allow) > 0 || len(p.deny) > 0
+}
+
+// check returns an error if args, the command followed by its
+// arguments and flags, may not be run
+func (p *commandPolicy) check(args []string) error {
+	if p.disable {
+		return fmt.Errorf("core/command is disabled: %w", errCommandNotAllowed)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	if p.restrictsCommands() {
+		// The command must come first otherwise the value of a
+		// flag could be mistaken for it
+		command := args[0]
+		if strings.HasPrefix(command, "-") {
+			return fmt.Errorf("command must be before any flags, found %q: %w", command, errCommandNotAllowed)
+		}
+		if len(p.allow) > 0 && !p.allow[command] {
+			return fmt.Errorf("command %q: %w", command, errCommandNotAllowed)
+		}
+		if p.deny[command] {
+			return fmt.Errorf("command %q: %w", command, errCommandNotAllowed)
+		}
+	}
+	for _, arg := range args {
+		if arg == "--" {
+			// the rest are not flags
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			continue
+		}
+		flag := strings.TrimLeft(arg, "-")
+		if i := strings.IndexRune(flag, '='); i >= 0 {
+			flag = flag[:i]
+		}
+		if len(p.allowFlags) > 0 && !p.allowFlags[flag] {
+			return fmt.Errorf("flag %q: %w", arg, errCommandNotAllowed)
+		}
+		if p.denyFlags[flag] {
+			return fmt.Errorf("flag %q: %w", arg, errCommandNotAllowed)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package rc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandPolicyCheck(t *testing.T) {
+	for _, test := range []struct {
+		opt   Options
+		args  []string
+		allow bool
+	}{
+		{opt: Options{}, args: []string{"delete", "remote:", "--max-depth", "1"}, allow: true},
+		{opt: Options{}, args: []string{}, allow: true},
+		{opt: Options{CommandDisable: true}, args: []string{"version"}, allow: false},
+		{opt: Options{CommandAllow: "ls, lsjson"}, args: []string{"ls", "remote:"}, allow: true},
+		{opt: Options{CommandAllow: "ls,lsjson"}, args: []string{"delete", "remote:"}, allow: false},
+		{opt: Options{CommandAllow: "ls,lsjson"}, args: []string{"--max-depth", "1", "ls"}, allow: false},
+		{opt: Options{CommandDeny: "delete,purge"}, args: []string{"ls", "remote:"}, allow: true},
+		{opt: Options{CommandDeny: "delete,purge"}, args: []string{"purge", "remote:"}, allow: false},
+		{opt: Options{CommandDeny: "delete,purge"}, args: []string{"--max-depth", "1", "delete", "remote:"}, allow: false},
+		{opt: Options{CommandAllowFlags: "max-depth,a"}, args: []string{"ls", "-a", "--max-depth", "1"}, allow: true},
+		{opt: Options{CommandAllowFlags: "max-depth"}, args: []string{"ls", "--max-depth=1"}, allow: true},
+		{opt: Options{CommandAllowFlags: "max-depth"}, args: []string{"ls", "--config", "x"}, allow: false},
+		{opt: Options{CommandDenyFlags: "--config"}, args: []string{"ls", "--config=x"}, allow: false},
+		{opt: Options{CommandDenyFlags: "config"}, args: []string{"ls", "--", "--config"}, allow: true},
+		{opt: Options{CommandDenyFlags: "config"}, args: []string{"ls", "-"}, allow: true},
+	} {
+		SetCommandOpt(&test.opt)
+		err := getCommandPolicy().check(test.args)
+		if test.allow {
+			assert.NoError(t, err, test.args)
+		} else {
+			assert.True(t, errors.Is(err, errCommandNotAllowed), test.args)
+		}
+	}
+	SetCommandOpt(&Options{})
+}
+
+func TestSetCommandOptCopies(t *testing.T) {
+	defer SetCommandOpt(&Options{})
+	opt := Options{CommandDisable: true}
+	SetCommandOpt(&opt)
+	opt.CommandDisable = false
+	assert.Error(t, getCommandPolicy().check([]string{"version"}))
+}
+
+func TestCoreCommandPolicy(t *testing.T) {
+	defer SetCommandOpt(&Options{})
+	SetCommandOpt(&Options{CommandDeny: "version"})
+	call := Calls.Get("core/command")
+	require.NotNil(t, call)
+	in := Params{
+		"command":   "version",
+		"_response": http.ResponseWriter(httptest.NewRecorder()),
+	}
+	_, err := call.Fn(context.Background(), in)
+	require.Error(t, err)
+	_, status := Error("core/command", in, err, http.StatusInternalServerError)
+	assert.Equal(t, http.StatusForbidden, status)
+}
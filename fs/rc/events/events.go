@@ -0,0 +1,339 @@
+// Package events keeps a stream of events for remote control clients
+// so they don't have to poll for changes.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// maxEvents is the number of events kept in memory. Clients which
+// fall further behind than this will miss events.
+const maxEvents = 4096
+
+// maxTimeout is the longest a caller may wait for new events
+const maxTimeout = 5 * time.Minute
+
+// pollExpiry is how long events are recorded for after the last
+// core/subscribe call if there are no other listeners
+const pollExpiry = time.Minute
+
+// Types of event
+const (
+	TypeJob      = "job"      // a job started or finished
+	TypeTransfer = "transfer" // a transfer completed
+	TypeLog      = "log"      // a log message
+	TypeMount    = "mount"    // a mount was mounted or unmounted
+	TypeHash     = "hash"     // operations/hashsum hashed a file
+)
+
+// Event describes something which happened
+type Event struct {
+	Seq   uint64      `json:"seq"`  // sequence number of this event
+	Time  time.Time   `json:"time"` // when the event happened
+	Type  string      `json:"type"` // type of the event, e.g. "job"
+	Data  rc.Params   `json:"data"` // details which depend on the Type
+	level fs.LogLevel // level of log events
+}
+
+// eventLog keeps a bounded list of recent events
+type eventLog struct {
+	mu     sync.Mutex
+	seq    uint64        // sequence number of the last event
+	events []Event       // ring buffer of events - allocated on first use
+	head   int           // index of the oldest event in events
+	n      int           // number of events in events
+	wake   chan struct{} // closed when new events arrive
+}
+
+var (
+	recent    = &eventLog{wake: make(chan struct{})}
+	listeners int32 // number of long running listeners, accessed with atomic
+	pollUntil int64 // record events until this UnixNano for pollers, accessed with atomic
+)
+
+func init() {
+	fs.LogHook = func(level fs.LogLevel, text string) {
+		if !enabled() {
+			return
+		}
+		recent.add(Event{
+			Type: TypeLog,
+			Data: rc.Params{
+				"level": level.String(),
+				"msg":   text,
+			},
+			level: level,
+		})
+	}
+}
+
+// enabled returns true if anything is listening for events
+func enabled() bool {
+	return atomic.LoadInt32(&listeners) > 0 || time.Now().UnixNano() < atomic.LoadInt64(&pollUntil)
+}
+
+// Listen records events until the stop function returned is called.
+//
+// Events are only recorded while something is listening for them.
+func Listen() (stop func()) {
+	atomic.AddInt32(&listeners, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			atomic.AddInt32(&listeners, -1)
+		})
+	}
+}
+
+// Publish records an event of typ with data if anything is
+// listening for events
+func Publish(typ string, data rc.Params) {
+	if !enabled() {
+		return
+	}
+	recent.add(Event{
+		Type: typ,
+		Data: data,
+	})
+}
+
+// add records an event, filling in Seq and Time
+func (el *eventLog) add(ev Event) {
+	el.mu.Lock()
+	defer el.mu.Unlock()
+	if el.events == nil {
+		el.events = make([]Event, maxEvents)
+	}
+	el.seq++
+	ev.Seq = el.seq
+	ev.Time = time.Now()
+	if el.n < len(el.events) {
+		el.events[(el.head+el.n)%len(el.events)] = ev
+		el.n++
+	} else {
+		// overwrite the oldest event
+		el.events[el.head] = ev
+		el.head = (el.head + 1) % len(el.events)
+	}
+	close(el.wake)
+	el.wake = make(chan struct{})
+}
+
+// _since returns the events after seq which match filter and
+// whether any events were lost because seq is older than the oldest
+// event kept.
+//
+// Call with el.mu held
+func (el *eventLog) _since(seq uint64, filter *Filter) (events []Event, lost bool) {
+	events = []Event{}
+	if el.n == 0 || seq >= el.seq {
+		return events, false
+	}
+	oldest := el.seq - uint64(el.n) + 1
+	start := 0
+	if seq+1 < oldest {
+		lost = true
+	} else {
+		start = int(seq + 1 - oldest)
+	}
+	for i := start; i < el.n; i++ {
+		ev := el.events[(el.head+i)%len(el.events)]
+		if filter.Match(&ev) {
+			events = append(events, ev)
+		}
+	}
+	return events, lost
+}
+
+// Seq returns the sequence number of the latest event
+func Seq() uint64 {
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+	return recent.seq
+}
+
+// Wait returns the events after seq which match filter, waiting up
+// to timeout (at most 5 minutes) for new events if there aren't any.
+//
+// It also returns the sequence number of the latest event, which
+// should be passed in as seq on the next call, and whether some
+// events after seq were discarded before they could be read.
+func Wait(ctx context.Context, seq uint64, timeout time.Duration, filter *Filter) (events []Event, last uint64, lost bool) {
+	if timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+	var timeoutChan <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+	for {
+		var (
+			wake    chan struct{}
+			lostNow bool
+		)
+		recent.mu.Lock()
+		events, lostNow = recent._since(seq, filter)
+		last, wake = recent.seq, recent.wake
+		recent.mu.Unlock()
+		lost = lost || lostNow
+		if len(events) > 0 || timeout <= 0 {
+			return events, last, lost
+		}
+		// Nothing matched so carry on from the latest event
+		seq = last
+		select {
+		case <-wake:
+		case <-timeoutChan:
+			return events, last, lost
+		case <-ctx.Done():
+			return events, last, lost
+		}
+	}
+}
+
+// Filter selects which events a client wants
+type Filter struct {
+	types map[string]bool // types wanted, all if empty
+	level fs.LogLevel     // log messages at this level or more severe are wanted
+}
+
+// NewFilter makes a Filter from a comma separated list of event
+// types, all if empty, and the name of the least severe log level
+// wanted, NOTICE if empty.
+func NewFilter(types string, level string) (*Filter, error) {
+	filter := &Filter{
+		types: map[string]bool{},
+		level: fs.LogLevelNotice,
+	}
+	for _, typ := range strings.Split(types, ",") {
+		typ = strings.TrimSpace(typ)
+		switch typ {
+		case "":
+		case TypeJob, TypeTransfer, TypeLog, TypeMount, TypeHash:
+			filter.types[typ] = true
+		default:
+			return nil, fmt.Errorf("unknown event type %q", typ)
+		}
+	}
+	if level != "" {
+		err := filter.level.Set(strings.ToUpper(level))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return filter, nil
+}
+
+// Match returns true if ev is wanted by the filter. A nil filter
+// matches everything.
+func (filter *Filter) Match(ev *Event) bool {
+	if filter == nil {
+		return true
+	}
+	if len(filter.types) > 0 && !filter.types[ev.Type] {
+		return false
+	}
+	return ev.Type != TypeLog || ev.level <= filter.level
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "core/subscribe",
+		AuthRequired: true,
+		Title:        "Wait for and return events such as job and transfer completions.",
+		Help: `
+This returns events as they happen so applications don't have to poll
+core/stats and job/status. It is a long poll - the same events are
+available as a stream from the /events websocket.
+
+Parameters:
+
+- since - sequence number of the last event seen
+- timeout - time to wait for new events if there are none, e.g. 30s (default 0 - don't wait, max 5m)
+- types - comma separated list of event types wanted (default all)
+- level - least severe log messages wanted (default NOTICE)
+
+Events are only recorded while a client is subscribed, so call first
+without "since" to subscribe, then pass the "last" value returned as
+"since" in the following calls. Events stop being recorded a minute
+after the last call finishes if nothing else is subscribed.
+
+    rclone rc core/subscribe
+    rclone rc core/subscribe since=42 timeout=1m types=job,transfer
+
+This returns
+
+- events - list of events with seq > since
+- last - sequence number of the latest event - pass this as since in the next call
+- lost - true if some events after since were discarded before being read
+
+Each event has a "seq" number, the "time" it happened, its "type"
+and some "data" which depends on the type:
+
+- job - a job changed state: "id", "group", "status" ("started" or "finished"), plus "success", "error" and "duration" when finished
+- transfer - a file transfer completed: "name", "size", "bytes", "group" and "error" if it failed
+- log - a log message: "level" and "msg". Only messages logged at the current --log-level or more severe are available.
+- mount - a mount changed: "mountPoint", "fs" and "status" ("mounted" or "unmounted")
+- hash - operations/hashsum hashed a file: "name", "hash", "hashType", "group" and "error" if it failed
+`,
+		Fn: rcSubscribe,
+	})
+}
+
+// rcSubscribe is the core/subscribe call
+func rcSubscribe(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	since, err := in.GetInt64("since")
+	subscribing := rc.IsErrParamNotFound(err)
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if since < 0 {
+		return nil, errors.New("since must be >= 0")
+	}
+	timeout, err := in.GetDuration("timeout")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if timeout > maxTimeout {
+		timeout = maxTimeout
+	}
+	types, err := in.GetString("types")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	level, err := in.GetString("level")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	filter, err := NewFilter(types, level)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep recording events until a while after this call returns
+	atomic.StoreInt64(&pollUntil, time.Now().Add(timeout+pollExpiry).UnixNano())
+	if subscribing {
+		return rc.Params{
+			"events": []Event{},
+			"last":   Seq(),
+			"lost":   false,
+		}, nil
+	}
+	events, last, lost := Wait(ctx, uint64(since), timeout, filter)
+	return rc.Params{
+		"events": events,
+		"last":   last,
+		"lost":   lost,
+	}, nil
+}
@@ -0,0 +1,120 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublish(t *testing.T) {
+	ctx := context.Background()
+
+	// Nothing is recorded without listeners
+	seq := Seq()
+	Publish(TypeJob, rc.Params{"id": 1})
+	assert.Equal(t, seq, Seq())
+
+	stop := Listen()
+	defer stop()
+	Publish(TypeJob, rc.Params{"id": 2})
+	Publish(TypeMount, rc.Params{"status": "mounted"})
+	events, last, lost := Wait(ctx, seq, 0, nil)
+	assert.False(t, lost)
+	assert.Equal(t, seq+2, last)
+	require.Len(t, events, 2)
+	assert.Equal(t, seq+1, events[0].Seq)
+	assert.Equal(t, TypeJob, events[0].Type)
+	assert.Equal(t, rc.Params{"id": 2}, events[0].Data)
+	assert.Equal(t, TypeMount, events[1].Type)
+
+	// Filtered
+	filter, err := NewFilter("mount", "")
+	require.NoError(t, err)
+	events, _, _ = Wait(ctx, seq, 0, filter)
+	require.Len(t, events, 1)
+	assert.Equal(t, TypeMount, events[0].Type)
+
+	// Wait for a new event
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		Publish(TypeTransfer, rc.Params{"name": "potato"})
+	}()
+	events, last, _ = Wait(ctx, seq+2, time.Minute, nil)
+	require.Len(t, events, 1)
+	assert.Equal(t, TypeTransfer, events[0].Type)
+	assert.Equal(t, seq+3, last)
+
+	// Waiting for filtered events times out
+	events, _, _ = Wait(ctx, last, 50*time.Millisecond, filter)
+	assert.Len(t, events, 0)
+
+	// Old events are lost
+	for i := 0; i < maxEvents; i++ {
+		Publish(TypeJob, rc.Params{"id": i})
+	}
+	events, _, lost = Wait(ctx, seq, 0, nil)
+	assert.True(t, lost)
+	assert.Len(t, events, maxEvents)
+}
+
+func TestPublishLog(t *testing.T) {
+	stop := Listen()
+	defer stop()
+	seq := Seq()
+	fs.Logf("potato", "hello %d", 42)
+	fs.Debugf("potato", "debug")
+
+	filter, err := NewFilter("log", "info")
+	require.NoError(t, err)
+	events, _, _ := Wait(context.Background(), seq, 0, filter)
+	require.Len(t, events, 1)
+	assert.Equal(t, rc.Params{"level": "NOTICE", "msg": "potato: hello 42"}, events[0].Data)
+
+	filter, err = NewFilter("log", "error")
+	require.NoError(t, err)
+	events, _, _ = Wait(context.Background(), seq, 0, filter)
+	assert.Len(t, events, 0)
+}
+
+func TestNewFilter(t *testing.T) {
+	_, err := NewFilter("potato", "")
+	assert.Error(t, err)
+	_, err = NewFilter("", "potato")
+	assert.Error(t, err)
+	filter, err := NewFilter(" job, transfer ", "DEBUG")
+	require.NoError(t, err)
+	assert.True(t, filter.Match(&Event{Type: TypeJob}))
+	assert.True(t, filter.Match(&Event{Type: TypeTransfer}))
+	assert.False(t, filter.Match(&Event{Type: TypeLog}))
+}
+
+func TestRcSubscribe(t *testing.T) {
+	call := rc.Calls.Get("core/subscribe")
+	require.NotNil(t, call)
+	ctx := context.Background()
+
+	// Subscribe
+	out, err := call.Fn(ctx, rc.Params{})
+	require.NoError(t, err)
+	last := out["last"].(uint64)
+	assert.Equal(t, []Event{}, out["events"])
+
+	Publish(TypeJob, rc.Params{"id": 1})
+	out, err = call.Fn(ctx, rc.Params{"since": int64(last), "timeout": "1s", "types": "job"})
+	require.NoError(t, err)
+	events := out["events"].([]Event)
+	require.Len(t, events, 1)
+	assert.Equal(t, rc.Params{"id": 1}, events[0].Data)
+	assert.Equal(t, last+1, out["last"])
+	assert.Equal(t, false, out["lost"])
+
+	_, err = call.Fn(ctx, rc.Params{"since": -1})
+	assert.Error(t, err)
+	_, err = call.Fn(ctx, rc.Params{"since": 0, "types": "potato"})
+	assert.Error(t, err)
+}
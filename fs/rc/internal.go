@@ -371,6 +371,11 @@ Returns:
 - error	 - set if rclone exits with an error code.
 - returnType - one of ("COMBINED_OUTPUT", "STREAM", "STREAM_ONLY_STDOUT", "STREAM_ONLY_STDERR").
 
+The commands and flags which can be run may be restricted with the
+--rc-command-* flags, or this call disabled entirely with
+--rc-command-disable, in which case an error with status 403 is
+returned.
+
 Example:
 
     rclone rc core/command command=ls -a mydrive:/ -o max-depth=1
@@ -444,6 +449,11 @@ func rcRunCommand(ctx context.Context, in Params) (out Params, err error) {
 		allArgs = append(allArgs, value)
 	}
 
+	err = getCommandPolicy().check(allArgs)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get the path for the current executable which was used to run rclone.
 	ex, err := os.Executable()
 	if err != nil {
@@ -0,0 +1,286 @@
+// Apply declarative job specs
+
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// Spec is a list of jobs to run as read from a job spec file by
+// rclone apply or passed to job/apply
+type Spec struct {
+	Jobs []JobSpec `json:"jobs"`
+}
+
+// JobSpec describes a single job in a Spec
+type JobSpec struct {
+	Name        string    `json:"name"`        // unique name for the job
+	Command     string    `json:"command"`     // one of sync, copy or move
+	Source      string    `json:"source"`      // remote to read from
+	Destination string    `json:"destination"` // remote to write to
+	Filter      rc.Params `json:"filter"`      // filter options as for _filter
+	Config      rc.Params `json:"config"`      // config options as for _config
+	Params      rc.Params `json:"params"`      // extra parameters for the rc call
+	Schedule    string    `json:"schedule"`    // cron expression to run on, run at once if empty
+}
+
+// applyCommands maps the commands which can be used in a JobSpec to
+// the rc call they run
+var applyCommands = map[string]string{
+	"sync": "sync/sync",
+	"copy": "sync/copy",
+	"move": "sync/move",
+}
+
+// configKeys is the lower case names of the options which can be set
+// in _config
+var configKeys = func() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(fs.ConfigInfo{})
+	for i := 0; i < t.NumField(); i++ {
+		keys[strings.ToLower(t.Field(i).Name)] = true
+	}
+	return keys
+}()
+
+// ParseSpec reads a Spec from in, which should have a "jobs" key,
+// and validates it
+func ParseSpec(in rc.Params) (*Spec, error) {
+	buf, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	spec := new(Spec)
+	err = dec.Decode(spec)
+	if err != nil {
+		return nil, rc.NewErrParamInvalid(fmt.Errorf("invalid job spec: %w", err))
+	}
+	err = spec.Validate()
+	if err != nil {
+		return nil, rc.NewErrParamInvalid(err)
+	}
+	return spec, nil
+}
+
+// Validate checks the jobs in the spec without running them
+func (spec *Spec) Validate() error {
+	if len(spec.Jobs) == 0 {
+		return errors.New("job spec has no jobs")
+	}
+	names := map[string]bool{}
+	for i := range spec.Jobs {
+		job := &spec.Jobs[i]
+		if job.Name == "" {
+			return fmt.Errorf("job %d: name must be set", i+1)
+		}
+		if names[job.Name] {
+			return fmt.Errorf("job %q: duplicate name", job.Name)
+		}
+		names[job.Name] = true
+		err := job.validate()
+		if err != nil {
+			return fmt.Errorf("job %q: %w", job.Name, err)
+		}
+	}
+	return nil
+}
+
+// validate checks a single job
+func (job *JobSpec) validate() error {
+	path, ok := applyCommands[job.Command]
+	if !ok {
+		return fmt.Errorf("unknown command %q", job.Command)
+	}
+	if rc.Calls.Get(path) == nil {
+		return fmt.Errorf("couldn't find method %q", path)
+	}
+	if job.Source == "" {
+		return errors.New("source must be set")
+	}
+	if job.Destination == "" {
+		return errors.New("destination must be set")
+	}
+	for key := range job.Params {
+		switch key {
+		case "srcFs", "dstFs", "_config", "_filter", "_async", "_queue", "_webhook":
+			return fmt.Errorf("%q can't be set in params", key)
+		}
+	}
+	if job.Schedule != "" {
+		if _, err := parseCron(job.Schedule); err != nil {
+			return err
+		}
+	}
+	for key := range job.Config {
+		if !configKeys[strings.ToLower(key)] {
+			return fmt.Errorf("unknown config option %q", key)
+		}
+	}
+	return checkParams(job.params())
+}
+
+// params returns the parameters for the rc call for job
+func (job *JobSpec) params() rc.Params {
+	in := job.Params.Copy()
+	in["srcFs"] = job.Source
+	in["dstFs"] = job.Destination
+	if len(job.Config) > 0 {
+		in["_config"] = job.Config
+	}
+	if len(job.Filter) > 0 {
+		in["_filter"] = job.Filter
+	}
+	return in
+}
+
+// Apply runs the jobs in spec which don't have a schedule, one after
+// the other in order, stopping at the first one which fails. The
+// jobs with a schedule are added to the job schedule, replacing any
+// with the same name added by a previous Apply.
+//
+// It returns a result for each job and an error if any failed.
+func Apply(ctx context.Context, spec *Spec) (results []rc.Params, err error) {
+	err = spec.Validate()
+	if err != nil {
+		return nil, err
+	}
+	failed := 0
+	for i := range spec.Jobs {
+		job := &spec.Jobs[i]
+		path := applyCommands[job.Command]
+		result := rc.Params{
+			"name": job.Name,
+			"path": path,
+		}
+		results = append(results, result)
+		if job.Schedule != "" {
+			item, err := scheduler.addNamed(job.Name, job.Schedule, path, job.params())
+			if err != nil {
+				failed++
+				result["status"] = "failed"
+				result["error"] = err.Error()
+				fs.Errorf(nil, "apply: failed to schedule job %q: %v", job.Name, err)
+				continue
+			}
+			scheduler.mu.Lock()
+			result["scheduleId"] = item.ID
+			result["next"] = item.next
+			scheduler.mu.Unlock()
+			result["status"] = "scheduled"
+			fs.Infof(nil, "apply: scheduled job %q to run at %q", job.Name, job.Schedule)
+			continue
+		}
+		if failed > 0 {
+			result["status"] = "skipped"
+			continue
+		}
+		fs.Infof(nil, "apply: running job %q", job.Name)
+		out, err := runBatchCall(ctx, path, job.params())
+		if err != nil {
+			failed++
+			result["status"] = "failed"
+			result["error"] = err.Error()
+			fs.Errorf(nil, "apply: job %q failed: %v", job.Name, err)
+			continue
+		}
+		result["status"] = "done"
+		if out != nil {
+			result["output"] = out
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%d of %d jobs failed", failed, len(spec.Jobs))
+	}
+	return results, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "job/apply",
+		AuthRequired: true,
+		Fn:           rcJobApply,
+		Title:        "Validate and run a job spec",
+		Parameters: []rc.Parameter{
+			{Name: "jobs", Type: "array", Help: "the jobs to run, as in a job spec file for rclone apply", Required: true},
+			{Name: "check", Type: "boolean", Help: "only validate the jobs, don't run them"},
+		},
+		Help: `This runs the jobs in a job spec as the [apply command](/commands/rclone_apply/)
+does.
+
+Parameters:
+
+- jobs - an array of jobs, each an object with
+    - name - unique name for the job
+    - command - one of sync, copy or move
+    - source - the remote to read from, e.g. "/home/user"
+    - destination - the remote to write to, e.g. "remote:backup"
+    - filter - filter options as for _filter (optional)
+    - config - config options as for _config (optional)
+    - params - extra parameters for the call, e.g. createEmptySrcDirs (optional)
+    - schedule - cron expression to run the job on as for job/schedule-create (optional)
+- check - boolean - only validate the jobs (default false)
+
+The jobs without a schedule are run one after the other in order,
+stopping at the first one which fails. The jobs with a schedule are
+added to the job schedule, replacing any with the same name added by
+a previous job/apply.
+
+Results:
+
+- results - an array with one object for each job with
+    - name - the name of the job
+    - path - the rc call it runs
+    - status - one of done, failed, skipped or scheduled
+    - error - the error if it failed
+    - output - the output of the call if it had any
+    - scheduleId - id of the scheduled job if it has a schedule
+    - next - when a scheduled job will next run
+- errors - the number of jobs which failed
+
+Use _async=true to run the jobs in the background.
+`,
+	})
+}
+
+// Validates and runs a job spec
+func rcJobApply(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	check, err := in.GetBool("check")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if _, err = in.Get("jobs"); err != nil {
+		return nil, err
+	}
+	spec, err := ParseSpec(rc.Params{"jobs": in["jobs"]})
+	if err != nil {
+		return nil, err
+	}
+	if check {
+		return rc.Params{}, nil
+	}
+	results, err := Apply(ctx, spec)
+	if err != nil && results == nil {
+		return nil, err
+	}
+	errorCount := 0
+	for _, result := range results {
+		if result["status"] == "failed" {
+			errorCount++
+		}
+	}
+	return rc.Params{
+		"results": results,
+		"errors":  errorCount,
+	}, nil
+}
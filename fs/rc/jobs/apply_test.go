@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testApplyCommand makes the "test" command run test/queue
+func testApplyCommand(t *testing.T) {
+	applyCommands["test"] = "test/queue"
+	t.Cleanup(func() { delete(applyCommands, "test") })
+}
+
+func TestParseSpec(t *testing.T) {
+	testApplyCommand(t)
+	job := func(changes rc.Params) rc.Params {
+		in := rc.Params{
+			"name":        "job",
+			"command":     "test",
+			"source":      "/src",
+			"destination": "remote:dst",
+		}
+		for k, v := range changes {
+			in[k] = v
+		}
+		return in
+	}
+
+	spec, err := ParseSpec(rc.Params{"jobs": []rc.Params{job(rc.Params{
+		"filter":   rc.Params{"ExcludeRule": []string{"*.tmp"}},
+		"config":   rc.Params{"transfers": 17},
+		"params":   rc.Params{"n": 1},
+		"schedule": "@daily",
+	})}})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(spec.Jobs))
+	assert.Equal(t, "@daily", spec.Jobs[0].Schedule)
+	assert.Equal(t, rc.Params{
+		"srcFs":   "/src",
+		"dstFs":   "remote:dst",
+		"n":       float64(1),
+		"_config": rc.Params{"transfers": float64(17)},
+		"_filter": rc.Params{"ExcludeRule": []interface{}{"*.tmp"}},
+	}, spec.Jobs[0].params())
+
+	for _, test := range []struct {
+		in   rc.Params
+		want string
+	}{
+		{rc.Params{}, "no jobs"},
+		{rc.Params{"jobs": []rc.Params{job(rc.Params{"potato": 1})}}, "unknown field"},
+		{rc.Params{"jobs": []rc.Params{job(rc.Params{"name": ""})}}, "name must be set"},
+		{rc.Params{"jobs": []rc.Params{job(nil), job(nil)}}, "duplicate name"},
+		{rc.Params{"jobs": []rc.Params{job(rc.Params{"command": "potato"})}}, "unknown command"},
+		{rc.Params{"jobs": []rc.Params{job(rc.Params{"source": ""})}}, "source must be set"},
+		{rc.Params{"jobs": []rc.Params{job(rc.Params{"destination": ""})}}, "destination must be set"},
+		{rc.Params{"jobs": []rc.Params{job(rc.Params{"params": rc.Params{"_async": true}})}}, "can't be set in params"},
+		{rc.Params{"jobs": []rc.Params{job(rc.Params{"schedule": "potato"})}}, "cron"},
+		{rc.Params{"jobs": []rc.Params{job(rc.Params{"config": rc.Params{"potato": 1}})}}, "unknown config option"},
+		{rc.Params{"jobs": []rc.Params{job(rc.Params{"config": rc.Params{"transfers": "potato"}})}}, "_config"},
+		{rc.Params{"jobs": []rc.Params{job(rc.Params{"filter": rc.Params{"potato": 1}})}}, "unknown filter option"},
+	} {
+		_, err := ParseSpec(test.in)
+		require.Error(t, err, test.in)
+		assert.Contains(t, err.Error(), test.want, test.in)
+		assert.True(t, rc.IsErrParamInvalid(err), test.in)
+	}
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	testApplyCommand(t)
+	defer func() { require.NoError(t, stopSchedule()) }()
+	resetQueueRuns()
+
+	in := rc.Params{"jobs": []rc.Params{
+		{"name": "one", "command": "test", "source": "a", "destination": "b", "params": rc.Params{"n": 1}},
+		{"name": "nightly", "command": "test", "source": "a", "destination": "b", "params": rc.Params{"n": 2}, "schedule": "0 3 * * *"},
+		{"name": "two", "command": "test", "source": "a", "destination": "b", "params": rc.Params{"n": 3}},
+	}}
+
+	// Check only validates
+	out, err := rcJobApply(ctx, rc.Params{"jobs": in["jobs"], "check": true})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{}, out)
+	assert.Nil(t, getQueueRuns())
+	assert.Nil(t, scheduleIDs(t))
+
+	out, err = rcJobApply(ctx, in)
+	require.NoError(t, err)
+	assert.Equal(t, 0, out["errors"])
+	results := out["results"].([]rc.Params)
+	require.Equal(t, 3, len(results))
+	assert.Equal(t, "done", results[0]["status"])
+	assert.Equal(t, rc.Params{"n": int64(1)}, results[0]["output"])
+	assert.Equal(t, "scheduled", results[1]["status"])
+	assert.Equal(t, "done", results[2]["status"])
+	assert.Equal(t, []int64{1, 3}, getQueueRuns())
+	ids := scheduleIDs(t)
+	require.Equal(t, 1, len(ids))
+	assert.Equal(t, results[1]["scheduleId"], ids[0])
+
+	// Applying again replaces the scheduled job
+	out, err = rcJobApply(ctx, in)
+	require.NoError(t, err)
+	results = out["results"].([]rc.Params)
+	newIDs := scheduleIDs(t)
+	require.Equal(t, 1, len(newIDs))
+	assert.NotEqual(t, ids[0], newIDs[0])
+	assert.Equal(t, results[1]["scheduleId"], newIDs[0])
+
+	// A failed job stops the ones after it
+	resetQueueRuns()
+	in["jobs"].([]rc.Params)[0]["params"] = rc.Params{}
+	out, err = rcJobApply(ctx, in)
+	require.NoError(t, err)
+	assert.Equal(t, 1, out["errors"])
+	results = out["results"].([]rc.Params)
+	assert.Equal(t, "failed", results[0]["status"])
+	assert.Contains(t, results[0]["error"], "n")
+	assert.Equal(t, "scheduled", results[1]["status"])
+	assert.Equal(t, "skipped", results[2]["status"])
+	assert.Nil(t, getQueueRuns())
+
+	_, err = rcJobApply(ctx, rc.Params{})
+	assert.Error(t, err)
+}
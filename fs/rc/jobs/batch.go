@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "core/batch",
+		AuthRequired: true,
+		Fn:           rcBatch,
+		Title:        "Run a batch of rc calls in one request",
+		Parameters: []rc.Parameter{
+			{Name: "inputs", Type: "array", Help: "the rc calls to run, each an object with _path and its parameters", Required: true},
+			{Name: "stopOnError", Type: "boolean", Help: "stop at the first call which fails"},
+		},
+		Help: `This runs a list of rc calls one after the other, saving a round
+trip for each one.
+
+Parameters:
+
+- inputs - an array of the rc calls to run, each an object with
+    - _path - the rc call to run, eg "operations/mkdir"
+    - the parameters for that call
+- stopOnError - boolean - stop at the first call which fails (default false)
+
+The calls may use _config, _filter, _dryrun, _timeout and _group like
+any other rc call but not _async, _queue or _webhook - pass those to
+core/batch itself to run the whole batch in the background. Calls which need the HTTP
+request, like the ones which serve files, can't be batched, nor can
+core/batch itself.
+
+Results:
+
+- results - an array with the output of each call run in the same
+  order as inputs. A call which failed has the same error output it
+  would have had on its own, with "error", "status", "input" and "path".
+- errors - the number of calls which failed
+
+If stopOnError is set, results will be shorter than inputs if a call
+failed.
+
+Eg
+
+    rclone rc core/batch --json '{
+        "inputs": [
+            {"_path": "operations/mkdir", "fs": "remote:", "remote": "dir1"},
+            {"_path": "operations/mkdir", "fs": "remote:", "remote": "dir2"}
+        ]
+    }'
+`,
+	})
+}
+
+// Runs the calls in inputs in order
+func rcBatch(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	var inputs []rc.Params
+	err = in.GetStruct("inputs", &inputs)
+	if err != nil {
+		return nil, err
+	}
+	stopOnError, err := in.GetBool("stopOnError")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	results := make([]rc.Params, 0, len(inputs))
+	errorCount := 0
+	for _, input := range inputs {
+		path, _ := input.GetString("_path")
+		result, err := runBatchCall(ctx, path, input)
+		if err != nil {
+			errorCount++
+			result, _ = rc.Error(path, input, err, http.StatusInternalServerError)
+		} else if result == nil {
+			result = rc.Params{}
+		}
+		results = append(results, result)
+		if err != nil && stopOnError {
+			break
+		}
+	}
+	return rc.Params{
+		"results": results,
+		"errors":  errorCount,
+	}, nil
+}
+
+// runBatchCall runs the call at path with in from a core/batch
+func runBatchCall(ctx context.Context, path string, in rc.Params) (out rc.Params, err error) {
+	if path == "" {
+		return nil, rc.NewErrParamInvalid(errors.New("_path must be set for each input"))
+	}
+	call := rc.Calls.Get(path)
+	if call == nil {
+		return nil, fmt.Errorf("couldn't find method %q", path)
+	}
+	if call.NeedsRequest || call.NeedsResponse || path == "core/batch" {
+		return nil, fmt.Errorf("%q can't be used in a batch", path)
+	}
+	in = in.Copy() // copy input so we can change it
+	delete(in, "_path")
+	for _, key := range []string{"_async", "_queue", "_webhook"} {
+		if _, found := in[key]; found {
+			return nil, rc.NewErrParamInvalid(fmt.Errorf("%s can't be used in a batch", key))
+		}
+	}
+	ctx, err = getConfig(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err = getFilter(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	ctx, recorder, err := getDryRun(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	fn := call.Fn
+	if recorder != nil {
+		fn = withDryRunActions(fn, recorder)
+	}
+	timeout, err := getTimeout(in)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		fn = withTimeout(fn, timeout)
+	}
+	group, err := in.GetString("_group")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	delete(in, "_group")
+	if group != "" {
+		ctx = accounting.WithStatsGroup(ctx, group)
+	}
+	return fn(ctx, in)
+}
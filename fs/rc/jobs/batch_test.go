@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatch(t *testing.T) {
+	ctx := context.Background()
+	inputs := []rc.Params{
+		{"_path": "rc/noop", "a": "1"},
+		{"_path": "rc/error", "b": "2"},
+		{"_path": "rc/noop", "c": "3", "_config": rc.Params{"Transfers": 17}},
+		{"_path": "rc/potato"},
+		{"_path": "rc/noop", "_async": true},
+		{"_path": "core/batch"},
+		{"d": "4"},
+	}
+
+	out, err := rcBatch(ctx, rc.Params{"inputs": inputs})
+	require.NoError(t, err)
+	results := out["results"].([]rc.Params)
+	require.Equal(t, len(inputs), len(results))
+	assert.Equal(t, 5, out["errors"])
+	assert.Equal(t, rc.Params{"a": "1"}, results[0])
+	assert.Equal(t, "rc/error", results[1]["path"])
+	assert.Equal(t, http.StatusInternalServerError, results[1]["status"])
+	assert.Contains(t, results[1]["error"], "arbitrary error")
+	assert.Equal(t, rc.Params{"c": "3"}, results[2])
+	assert.Contains(t, results[3]["error"], "couldn't find method")
+	assert.Equal(t, http.StatusBadRequest, results[4]["status"])
+	assert.Contains(t, results[5]["error"], "can't be used in a batch")
+	assert.Equal(t, http.StatusBadRequest, results[6]["status"])
+
+	// Check the inputs weren't modified
+	assert.Equal(t, "rc/noop", inputs[0]["_path"])
+
+	out, err = rcBatch(ctx, rc.Params{"inputs": inputs, "stopOnError": true})
+	require.NoError(t, err)
+	results = out["results"].([]rc.Params)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, 1, out["errors"])
+
+	_, err = rcBatch(ctx, rc.Params{})
+	assert.Error(t, err)
+	_, err = rcBatch(ctx, rc.Params{"inputs": inputs, "stopOnError": "potato"})
+	assert.Error(t, err)
+}
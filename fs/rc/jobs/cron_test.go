@@ -0,0 +1,70 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, test := range []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"* * * * *", false},
+		{"0 3 * * *", false},
+		{"*/15 9-17 * * mon-fri", false},
+		{"0 0 1,15 jan,jul *", false},
+		{"0 0 * * 7", false},
+		{"@daily", false},
+		{"@HOURLY", false},
+		{"", true},
+		{"* * * *", true},
+		{"* * * * * *", true},
+		{"60 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 0 * *", true},
+		{"* * * 13 *", true},
+		{"* * * * 8", true},
+		{"*/0 * * * *", true},
+		{"5-1 * * * *", true},
+		{"potato * * * *", true},
+		{"@sometimes", true},
+	} {
+		_, err := parseCron(test.expr)
+		if test.wantErr {
+			assert.Error(t, err, test.expr)
+		} else {
+			assert.NoError(t, err, test.expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// Wednesday
+	start := time.Date(2022, 3, 2, 10, 30, 20, 0, time.UTC)
+	for _, test := range []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2022, 3, 2, 10, 31, 0, 0, time.UTC)},
+		{"30 * * * *", time.Date(2022, 3, 2, 11, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2022, 3, 3, 3, 0, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2022, 3, 2, 10, 40, 0, 0, time.UTC)},
+		{"0 9-17 * * *", time.Date(2022, 3, 2, 11, 0, 0, 0, time.UTC)},
+		{"0 0 * * sun", time.Date(2022, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2022, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2022, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"@yearly", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// day of month or day of week if both restricted
+		{"0 0 10 * fri", time.Date(2022, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 apr *", time.Time{}},
+	} {
+		c, err := parseCron(test.expr)
+		require.NoError(t, err, test.expr)
+		assert.Equal(t, test.want, c.next(start), test.expr)
+	}
+}
@@ -0,0 +1,347 @@
+// History of finished jobs which can be kept in a file
+
+package jobs
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/rc"
+	"go.etcd.io/bbolt"
+)
+
+// historyBucket is the name of the bucket in the history file
+var historyBucket = []byte("history") // finished jobs by ID
+
+// historyJob is the record of a finished job - it is stored as JSON in
+// the history file
+type historyJob struct {
+	ID        int64     `json:"id"`
+	Path      string    `json:"path,omitempty"` // rc call run if known
+	Group     string    `json:"group"`
+	Params    rc.Params `json:"params,omitempty"` // parameters with secrets redacted
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Duration  float64   `json:"duration"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error"`
+	Stats     rc.Params `json:"stats,omitempty"`  // core/stats for the group when the job finished
+	Tenant    string    `json:"tenant,omitempty"` // tenant which started the job
+}
+
+// jobHistory keeps the records of finished jobs
+type jobHistory struct {
+	mu      sync.Mutex
+	db      *bbolt.DB     // file the history is kept in if set
+	maxAge  time.Duration // forget jobs which finished longer ago than this if > 0
+	maxJobs int           // max number of jobs to keep if > 0
+}
+
+var history = &jobHistory{}
+
+// pathKey is the context key for the rc call a job runs
+type pathKey struct{}
+
+// WithPath returns a new context which records path as the rc call
+// run by the jobs started with it in the job history.
+func WithPath(ctx context.Context, path string) context.Context {
+	return context.WithValue(ctx, pathKey{}, path)
+}
+
+// getPath returns the rc call set with WithPath or ""
+func getPath(ctx context.Context) string {
+	path, _ := ctx.Value(pathKey{}).(string)
+	return path
+}
+
+// noteJobID makes sure new jobs don't reuse id
+func noteJobID(id int64) {
+	for {
+		old := atomic.LoadInt64(&jobID)
+		if id <= old || atomic.CompareAndSwapInt64(&jobID, old, id) {
+			return
+		}
+	}
+}
+
+// StartHistory keeps the records of finished jobs in the file at path
+// for job/history, forgetting those which finished more than maxAge
+// ago and the oldest when there are more than maxJobs. Either limit
+// is ignored if it is 0.
+func StartHistory(path string, maxAge time.Duration, maxJobs int) error {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("failed to open job history %q: %w", path, err)
+	}
+	var lastID int64
+	err = db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(historyBucket)
+		if err != nil {
+			return err
+		}
+		if k, _ := b.Cursor().Last(); k != nil {
+			lastID = int64(binary.BigEndian.Uint64(k))
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return fmt.Errorf("failed to read job history %q: %w", path, err)
+	}
+
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	if history.db != nil {
+		_ = db.Close()
+		return errors.New("job history already started")
+	}
+	history.db = db
+	history.maxAge = maxAge
+	history.maxJobs = maxJobs
+	history._prune()
+
+	// Make sure new jobs don't reuse the IDs of the old ones
+	noteJobID(lastID)
+	return nil
+}
+
+// stopHistory stops keeping the history of jobs
+func stopHistory() error {
+	history.mu.Lock()
+	defer history.mu.Unlock()
+	if history.db == nil {
+		return nil
+	}
+	err := history.db.Close()
+	history.db = nil
+	return err
+}
+
+// enabled returns true if the history is being kept
+func (h *jobHistory) enabled() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.db != nil
+}
+
+// add the finished job to the history if it is being kept
+func (h *jobHistory) add(job *Job) {
+	if !h.enabled() {
+		return
+	}
+	job.mu.Lock()
+	item := &historyJob{
+		ID:        job.ID,
+		Path:      job.path,
+		Group:     job.Group,
+		Params:    job.params,
+		StartTime: job.StartTime,
+		EndTime:   job.EndTime,
+		Duration:  job.Duration,
+		Success:   job.Success,
+		Error:     job.Error,
+		Tenant:    job.tenant,
+	}
+	job.mu.Unlock()
+	stats, err := accounting.StatsGroup(context.Background(), item.Group).RemoteStats()
+	if err == nil {
+		item.Stats = stats
+	}
+	buf, err := json.Marshal(item)
+	if err != nil {
+		fs.Errorf(nil, "rc: failed to marshal job %d for history: %v", item.ID, err)
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.db == nil {
+		return
+	}
+	err = h.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(historyBucket).Put(idKey(item.ID), buf)
+	})
+	if err != nil {
+		fs.Errorf(nil, "rc: failed to save job %d to history: %v", item.ID, err)
+	}
+	h._prune()
+}
+
+// _prune removes the jobs over the limits from the history
+//
+// Call with h.mu held
+func (h *jobHistory) _prune() {
+	if h.maxAge <= 0 && h.maxJobs <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-h.maxAge)
+	err := h.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(historyBucket)
+		excess := b.Stats().KeyN - h.maxJobs
+		var old [][]byte
+		// IDs increase with time so the oldest jobs are first
+		c := b.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			if h.maxJobs <= 0 || excess <= 0 {
+				if h.maxAge <= 0 {
+					break
+				}
+				var item historyJob
+				if json.Unmarshal(v, &item) == nil && item.EndTime.After(cutoff) {
+					break
+				}
+			}
+			old = append(old, append([]byte(nil), k...))
+			excess--
+		}
+		for _, k := range old {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fs.Errorf(nil, "rc: failed to prune job history: %v", err)
+	}
+}
+
+// query returns the jobs in the history which finished between from
+// and to, either of which may be zero, with status "success",
+// "error" or "" for both, which tenant may see, oldest first.
+func (h *jobHistory) query(from, to time.Time, status string, tenant string) (items []*historyJob, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.db == nil {
+		return nil, errors.New("job history not enabled - use --rc-job-history-file")
+	}
+	items = []*historyJob{}
+	err = h.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(historyBucket).ForEach(func(k, v []byte) error {
+			item := new(historyJob)
+			if err := json.Unmarshal(v, item); err != nil {
+				fs.Errorf(nil, "rc: skipping unreadable job in history: %v", err)
+				return nil
+			}
+			switch {
+			case !from.IsZero() && item.EndTime.Before(from):
+			case !to.IsZero() && item.EndTime.After(to):
+			case status == "success" && !item.Success:
+			case status == "error" && item.Success:
+			case tenant != "" && item.Tenant != tenant:
+			default:
+				items = append(items, item)
+			}
+			return nil
+		})
+	})
+	return items, err
+}
+
+// getTimeParam gets the time in the key parameter of in which is
+// either an RFC 3339 time or a duration before now like "24h", or
+// returns the zero time if it isn't set
+func getTimeParam(in rc.Params, key string) (t time.Time, err error) {
+	value, err := in.GetString(key)
+	if rc.IsErrParamNotFound(err) {
+		return t, nil
+	} else if err != nil {
+		return t, err
+	}
+	t, err = time.Parse(time.RFC3339, value)
+	if err == nil {
+		return t, nil
+	}
+	ago, err := fs.ParseDuration(value)
+	if err != nil {
+		return t, rc.NewErrParamInvalid(fmt.Errorf("%s must be an RFC 3339 time or a duration: %q", key, value))
+	}
+	return time.Now().Add(-ago), nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/history",
+		Fn:    rcJobHistory,
+		Title: "Reads the records of finished jobs",
+		Parameters: []rc.Parameter{
+			{Name: "from", Type: "string", Help: "only jobs which finished at or after this time"},
+			{Name: "to", Type: "string", Help: "only jobs which finished at or before this time"},
+			{Name: "status", Type: "string", Help: "only jobs with this status - \"success\" or \"error\""},
+		},
+		Help: `This needs --rc-job-history-file to be set. The history is
+kept across restarts of rclone, unlike job/list and job/status which
+forget finished jobs after --rc-job-expire-duration.
+
+Parameters:
+
+- from - only jobs which finished at or after this time (optional)
+- to - only jobs which finished at or before this time (optional)
+- status - "success" or "error" for only the jobs which succeeded or failed (optional)
+
+Times are either RFC 3339, e.g. "2022-05-10T11:03:01Z", or a duration
+before now, e.g. "24h".
+
+Results:
+
+- jobs - array of the finished jobs, oldest first, each with
+    - id - id of the job
+    - path - the rc call the job ran, if known
+    - group - the stats group of the job
+    - params - the parameters of the call with secrets redacted
+    - startTime - time the job started
+    - endTime - time the job finished
+    - duration - time in seconds that the job ran for
+    - success - boolean - true for success false otherwise
+    - error - error from the job or empty string for no error
+    - stats - the stats of the group when the job finished as in core/stats
+
+    rclone rc job/history from=24h status=error
+`,
+	})
+}
+
+// Returns the history of finished jobs
+func rcJobHistory(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	from, err := getTimeParam(in, "from")
+	if err != nil {
+		return nil, err
+	}
+	to, err := getTimeParam(in, "to")
+	if err != nil {
+		return nil, err
+	}
+	status, err := in.GetString("status")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	switch status {
+	case "", "success", "error":
+	default:
+		return nil, rc.NewErrParamInvalid(fmt.Errorf("status must be \"success\" or \"error\": %q", status))
+	}
+	items, err := history.query(from, to, status, rc.GetTenant(ctx))
+	if err != nil {
+		return nil, err
+	}
+	jobs := []rc.Params{}
+	for _, item := range items {
+		var job rc.Params
+		err = rc.Reshape(&job, item)
+		if err != nil {
+			return nil, err
+		}
+		delete(job, "tenant")
+		jobs = append(jobs, job)
+	}
+	return rc.Params{
+		"jobs": jobs,
+	}, nil
+}
@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// historyIDs returns the IDs of the jobs in the history matching in
+func historyIDs(t *testing.T, ctx context.Context, in rc.Params) (ids []int64) {
+	out, err := rcJobHistory(ctx, in)
+	require.NoError(t, err)
+	for _, job := range out["jobs"].([]rc.Params) {
+		ids = append(ids, int64(job["id"].(float64)))
+	}
+	return ids
+}
+
+func TestJobHistory(t *testing.T) {
+	_, err := rcJobHistory(context.Background(), rc.Params{})
+	assert.Error(t, err, "history not enabled")
+
+	path := filepath.Join(t.TempDir(), "history.db")
+	defer func() {
+		require.NoError(t, stopHistory())
+		// Remove the jobs so they don't show up in other tests
+		running.mu.Lock()
+		running.jobs = map[int64]*Job{}
+		running.mu.Unlock()
+	}()
+	require.NoError(t, StartHistory(path, 0, 0))
+	assert.Error(t, StartHistory(path, 0, 0))
+
+	jobID = 0
+	ctx := WithPath(context.Background(), "test/history")
+	_, _, err = NewJob(ctx, shortFn, rc.Params{"pass": "secret"})
+	require.NoError(t, err)
+	_, _, err = NewJob(ctx, func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		return nil, errors.New("potato")
+	}, rc.Params{})
+	require.Error(t, err)
+	_, _, err = NewJob(rc.WithTenant(ctx, "alice"), shortFn, rc.Params{})
+	require.NoError(t, err)
+
+	out, err := rcJobHistory(context.Background(), rc.Params{})
+	require.NoError(t, err)
+	jobs := out["jobs"].([]rc.Params)
+	require.Len(t, jobs, 3)
+	assert.Equal(t, "test/history", jobs[0]["path"])
+	assert.Equal(t, map[string]interface{}{"pass": rc.Redacted}, jobs[0]["params"])
+	assert.Equal(t, true, jobs[0]["success"])
+	assert.Equal(t, "potato", jobs[1]["error"])
+	assert.Nil(t, jobs[2]["tenant"])
+
+	assert.Equal(t, []int64{1, 3}, historyIDs(t, context.Background(), rc.Params{"status": "success"}))
+	assert.Equal(t, []int64{2}, historyIDs(t, context.Background(), rc.Params{"status": "error"}))
+	assert.Equal(t, []int64{3}, historyIDs(t, rc.WithTenant(ctx, "alice"), rc.Params{}))
+	assert.Equal(t, []int64{1, 2, 3}, historyIDs(t, context.Background(), rc.Params{"from": "1h"}))
+	assert.Nil(t, historyIDs(t, context.Background(), rc.Params{"to": time.Now().Add(-time.Hour).Format(time.RFC3339)}))
+	_, err = rcJobHistory(context.Background(), rc.Params{"status": "potato"})
+	assert.True(t, rc.IsErrParamInvalid(err))
+	_, err = rcJobHistory(context.Background(), rc.Params{"from": "potato"})
+	assert.True(t, rc.IsErrParamInvalid(err))
+
+	// The history survives a restart and new jobs don't reuse its IDs
+	require.NoError(t, stopHistory())
+	jobID = 0
+	require.NoError(t, StartHistory(path, 0, 2))
+	assert.Equal(t, int64(3), jobID)
+	assert.Equal(t, []int64{2, 3}, historyIDs(t, context.Background(), rc.Params{}))
+	job, _, err := NewJob(ctx, shortFn, rc.Params{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), job.ID)
+	assert.Equal(t, []int64{3, 4}, historyIDs(t, context.Background(), rc.Params{}))
+}
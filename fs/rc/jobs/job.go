@@ -6,7 +6,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"runtime/debug"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,6 +18,7 @@ import (
 	"github.com/rclone/rclone/fs/accounting"
 	"github.com/rclone/rclone/fs/filter"
 	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/rc/events"
 )
 
 // Job describes an asynchronous task started via the rc package
@@ -29,8 +33,13 @@ type Job struct {
 	Success   bool      `json:"success"`
 	Duration  float64   `json:"duration"`
 	Output    rc.Params `json:"output"`
+	Queued    bool      `json:"queued"`
 	Stop      func()    `json:"-"`
 	listeners []*func()
+	webhook   string    // URL to notify when the job finishes if set
+	tenant    string    // tenant which started the job if set
+	path      string    // rc call the job runs if known
+	params    rc.Params // parameters of the job with secrets redacted
 
 	// realErr is the Error before printing it as a string, it's used to return
 	// the real error to the upper application layers while still printing the
@@ -38,6 +47,13 @@ type Job struct {
 	realErr error
 }
 
+// visibleTo returns true if the tenant in ctx may see and control the
+// job
+func (job *Job) visibleTo(ctx context.Context) bool {
+	tenant := rc.GetTenant(ctx)
+	return tenant == "" || job.tenant == tenant
+}
+
 // mark the job as finished
 func (job *Job) finish(out rc.Params, err error) {
 	job.mu.Lock()
@@ -65,6 +81,30 @@ func (job *Job) finish(out rc.Params, err error) {
 
 	job.mu.Unlock()
 	running.kickExpire() // make sure this job gets expired
+	history.add(job)
+}
+
+// publish an event saying the job has finished
+func (job *Job) publishFinished() {
+	job.mu.Lock()
+	data := rc.Params{
+		"id":       job.ID,
+		"group":    job.Group,
+		"status":   "finished",
+		"success":  job.Success,
+		"error":    job.Error,
+		"duration": job.Duration,
+	}
+	job.mu.Unlock()
+	events.Publish(events.TypeJob, data)
+	job.notifyWebhook(context.Background())
+}
+
+// isQueued returns true if the job is waiting in the queue
+func (job *Job) isQueued() bool {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.Queued
 }
 
 func (job *Job) addListener(fn *func()) {
@@ -168,6 +208,25 @@ func (jobs *Jobs) IDs() (IDs []int64) {
 	return IDs
 }
 
+// Group returns the jobs in group sorted by ID
+func (jobs *Jobs) Group(group string) (groupJobs []*Job) {
+	jobs.mu.RLock()
+	defer jobs.mu.RUnlock()
+	groupJobs = []*Job{}
+	for _, job := range jobs.jobs {
+		job.mu.Lock()
+		inGroup := job.Group == group
+		job.mu.Unlock()
+		if inGroup {
+			groupJobs = append(groupJobs, job)
+		}
+	}
+	sort.Slice(groupJobs, func(i, j int) bool {
+		return groupJobs[i].ID < groupJobs[j].ID
+	})
+	return groupJobs
+}
+
 // Get a job with a given ID or nil if it doesn't exist
 func (jobs *Jobs) Get(ID int64) *Job {
 	jobs.mu.RLock()
@@ -184,6 +243,9 @@ func getGroup(ctx context.Context, in rc.Params, id int64) (context.Context, str
 	delete(in, "_group")
 	if group == "" {
 		group = fmt.Sprintf("job/%d", id)
+	} else if tenant := rc.GetTenant(ctx); tenant != "" {
+		// keep the groups of different tenants apart
+		group = tenant + "/" + group
 	}
 	ctx = accounting.WithStatsGroup(ctx, group)
 	return ctx, group, nil
@@ -198,16 +260,26 @@ func getAsync(ctx context.Context, in rc.Params) (context.Context, bool, error)
 	delete(in, "_async") // remove the async parameter after parsing
 	if isAsync {
 		// unlink this job from the current context
+		tenant := rc.GetTenant(ctx)
 		ctx = context.Background()
+		if tenant != "" {
+			ctx = rc.WithTenant(ctx, tenant)
+		}
 	}
 	return ctx, isAsync, nil
 }
 
 // See if _config is set and if so adjust ctx to include it
+//
+// Tenants can't use _config as it sets paths, like BackupDir and
+// CompareDest, which would reach outside the tenant's remotes.
 func getConfig(ctx context.Context, in rc.Params) (context.Context, error) {
 	if _, ok := in["_config"]; !ok {
 		return ctx, nil
 	}
+	if rc.GetTenant(ctx) != "" {
+		return ctx, fmt.Errorf("_config: %w", rc.ErrTenantForbidden)
+	}
 	ctx, ci := fs.AddConfig(ctx)
 	err := in.GetStruct("_config", ci)
 	if err != nil {
@@ -217,21 +289,109 @@ func getConfig(ctx context.Context, in rc.Params) (context.Context, error) {
 	return ctx, nil
 }
 
+// See if _dryrun is set and if so adjust ctx to skip destructive
+// actions, returning a recorder for the actions skipped
+func getDryRun(ctx context.Context, in rc.Params) (context.Context, *fs.DryRunRecorder, error) {
+	dryRun, err := in.GetBool("_dryrun")
+	if rc.NotErrParamNotFound(err) {
+		return ctx, nil, err
+	}
+	delete(in, "_dryrun") // remove the parameter
+	if !dryRun {
+		return ctx, nil, nil
+	}
+	ctx, ci := fs.AddConfig(ctx)
+	ci.DryRun = true
+	ctx, recorder := fs.WithDryRunRecorder(ctx)
+	return ctx, recorder, nil
+}
+
+// withDryRunActions wraps fn so its output has the actions recorded
+// by recorder added as "dryRun"
+func withDryRunActions(fn rc.Func, recorder *fs.DryRunRecorder) rc.Func {
+	return func(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+		out, err = fn(ctx, in)
+		if err == nil {
+			if out == nil {
+				out = rc.Params{}
+			}
+			out["dryRun"] = recorder.Actions()
+		}
+		return out, err
+	}
+}
+
+// See if _timeout is set returning how long the call may run for or
+// 0 for no limit
+func getTimeout(in rc.Params) (time.Duration, error) {
+	timeout, err := in.GetDuration("_timeout")
+	if rc.NotErrParamNotFound(err) {
+		return 0, err
+	}
+	delete(in, "_timeout") // remove the parameter
+	if timeout < 0 {
+		return 0, rc.NewErrParamInvalid(errors.New("_timeout must be >= 0"))
+	}
+	return timeout, nil
+}
+
+// withTimeout wraps fn so it is cancelled if it runs for longer
+// than timeout
+func withTimeout(fn rc.Func, timeout time.Duration) rc.Func {
+	return func(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		out, err = fn(ctx, in)
+		if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("call timed out after %v: %w", timeout, err)
+		}
+		return out, err
+	}
+}
+
+// filterKeys is the lower case names of the options which can be set
+// in _filter
+var filterKeys = func() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(filter.Opt{})
+	for i := 0; i < t.NumField(); i++ {
+		keys[strings.ToLower(t.Field(i).Name)] = true
+	}
+	return keys
+}()
+
 // See if _filter is set and if so adjust ctx to include it
+//
+// Tenants can't use _filter as options like FilesFrom read local
+// files.
 func getFilter(ctx context.Context, in rc.Params) (context.Context, error) {
 	if _, ok := in["_filter"]; !ok {
 		return ctx, nil
 	}
+	if rc.GetTenant(ctx) != "" {
+		return ctx, fmt.Errorf("_filter: %w", rc.ErrTenantForbidden)
+	}
+	// Check there aren't any misspelt options which would be ignored
+	var keys map[string]interface{}
+	err := in.GetStruct("_filter", &keys)
+	if err != nil {
+		return ctx, err
+	}
+	for key := range keys {
+		if !filterKeys[strings.ToLower(key)] {
+			return ctx, rc.NewErrParamInvalid(fmt.Errorf("unknown filter option %q in _filter", key))
+		}
+	}
 	// Copy of the current filter options
 	opt := filter.GetConfig(ctx).Opt
 	// Update the options from the parameter
-	err := in.GetStruct("_filter", &opt)
+	err = in.GetStruct("_filter", &opt)
 	if err != nil {
 		return ctx, err
 	}
 	fi, err := filter.NewFilter(&opt)
 	if err != nil {
-		return ctx, err
+		return ctx, rc.NewErrParamInvalid(fmt.Errorf("invalid _filter: %w", err))
 	}
 	ctx = filter.ReplaceConfig(ctx, fi)
 	delete(in, "_filter") // remove the parameter
@@ -240,8 +400,15 @@ func getFilter(ctx context.Context, in rc.Params) (context.Context, error) {
 
 // NewJob creates a Job and executes it, possibly in the background if _async is set
 func (jobs *Jobs) NewJob(ctx context.Context, fn rc.Func, in rc.Params) (job *Job, out rc.Params, err error) {
-	id := atomic.AddInt64(&jobID, 1)
+	return jobs.startJob(ctx, atomic.AddInt64(&jobID, 1), fn, in)
+}
+
+// startJob runs the job with id which may already exist if it was
+// queued, possibly in the background if _async is set
+func (jobs *Jobs) startJob(ctx context.Context, id int64, fn rc.Func, in rc.Params) (job *Job, out rc.Params, err error) {
 	in = in.Copy() // copy input so we can change it
+	tenant := rc.GetTenant(ctx)
+	path, params := getPath(ctx), rc.Redact(in)
 
 	ctx, isAsync, err := getAsync(ctx, in)
 	if err != nil {
@@ -258,28 +425,64 @@ func (jobs *Jobs) NewJob(ctx context.Context, fn rc.Func, in rc.Params) (job *Jo
 		return nil, nil, err
 	}
 
+	ctx, recorder, err := getDryRun(ctx, in)
+	if err != nil {
+		return nil, nil, err
+	}
+	if recorder != nil {
+		fn = withDryRunActions(fn, recorder)
+	}
+
+	timeout, err := getTimeout(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	if timeout > 0 {
+		fn = withTimeout(fn, timeout)
+	}
+
 	ctx, group, err := getGroup(ctx, in, id)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	webhook, err := getWebhook(in)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	ctx, cancel := context.WithCancel(ctx)
 	stop := func() {
 		cancel()
 		// Wait for cancel to propagate before returning.
 		<-ctx.Done()
 	}
-	job = &Job{
-		ID:        id,
-		Group:     group,
-		StartTime: time.Now(),
-		Stop:      stop,
-	}
 	jobs.mu.Lock()
-	jobs.jobs[job.ID] = job
+	job = jobs.jobs[id]
+	if job == nil || !job.isQueued() {
+		job = &Job{ID: id, tenant: tenant}
+		jobs.jobs[id] = job
+	}
 	jobs.mu.Unlock()
+	job.mu.Lock()
+	job.Group = group
+	job.StartTime = time.Now()
+	job.Queued = false
+	job.Stop = stop
+	job.webhook = webhook
+	job.path = path
+	job.params = params
+	job.mu.Unlock()
 	if isAsync {
-		go job.run(ctx, fn, in)
+		events.Publish(events.TypeJob, rc.Params{
+			"id":     job.ID,
+			"group":  job.Group,
+			"status": "started",
+		})
+		go func() {
+			job.run(ctx, fn, in)
+			job.publishFinished()
+		}()
 		out = make(rc.Params)
 		out["jobid"] = job.ID
 		err = nil
@@ -297,6 +500,34 @@ func NewJob(ctx context.Context, fn rc.Func, in rc.Params) (job *Job, out rc.Par
 	return running.NewJob(ctx, fn, in)
 }
 
+// Progress returns the stats of the job with jobID as returned by
+// core/stats for its group, plus its "jobid" and whether it has
+// "finished". Once finished the "success", "error" and "duration" of
+// the job are added too.
+func Progress(ctx context.Context, jobID int64) (out rc.Params, finished bool, err error) {
+	job := running.Get(jobID)
+	if job == nil {
+		return nil, false, errors.New("job not found")
+	}
+	job.mu.Lock()
+	group := job.Group
+	finished = job.Finished
+	success, jobErr, duration := job.Success, job.Error, job.Duration
+	job.mu.Unlock()
+	out, err = accounting.StatsGroup(ctx, group).RemoteStats()
+	if err != nil {
+		return nil, false, err
+	}
+	out["jobid"] = jobID
+	out["finished"] = finished
+	if finished {
+		out["success"] = success
+		out["error"] = jobErr
+		out["duration"] = duration
+	}
+	return out, finished, nil
+}
+
 // OnFinish adds listener to jobid that will be triggered when job is finished.
 // It returns a function to cancel listening.
 func OnFinish(jobID int64, fn func()) (func(), error) {
@@ -317,6 +548,9 @@ func init() {
 		Path:  "job/status",
 		Fn:    rcJobStatus,
 		Title: "Reads the status of the job ID",
+		Parameters: []rc.Parameter{
+			{Name: "jobid", Type: "integer", Help: "id of the job", Required: true},
+		},
 		Help: `Parameters:
 
 - jobid - id of the job (integer).
@@ -332,7 +566,12 @@ Results:
 - startTime - time the job started (e.g. "2018-10-26T18:50:20.528336039+01:00")
 - success - boolean - true for success false otherwise
 - output - output of the job as would have been returned if called synchronously
-- progress - output of the progress related to the underlying job
+- progress - the progress of the transfers made by the job
+    - bytes - number of bytes transferred so far
+    - totalBytes - total number of bytes to transfer
+    - speed - average speed in bytes per second
+    - eta - estimated seconds to go or null if not known
+    - transferring - the transfers in progress as in core/stats
 `,
 	})
 }
@@ -344,19 +583,40 @@ func rcJobStatus(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 		return nil, err
 	}
 	job := running.Get(jobID)
-	if job == nil {
+	if job == nil || !job.visibleTo(ctx) {
 		return nil, errors.New("job not found")
 	}
 	job.mu.Lock()
-	defer job.mu.Unlock()
 	out = make(rc.Params)
 	err = rc.Reshape(&out, job)
+	group := job.Group
+	job.mu.Unlock()
 	if err != nil {
 		return nil, fmt.Errorf("reshape failed in job status: %w", err)
 	}
+	out["progress"], err = groupProgress(ctx, group)
+	if err != nil {
+		return nil, err
+	}
 	return out, nil
 }
 
+// groupProgress returns a summary of the transfers in the stats for
+// group
+func groupProgress(ctx context.Context, group string) (rc.Params, error) {
+	stats, err := accounting.StatsGroup(ctx, group).RemoteStats()
+	if err != nil {
+		return nil, err
+	}
+	progress := rc.Params{}
+	for _, key := range []string{"bytes", "totalBytes", "speed", "eta", "transferring"} {
+		if value, found := stats[key]; found {
+			progress[key] = value
+		}
+	}
+	return progress, nil
+}
+
 func init() {
 	rc.Add(rc.Call{
 		Path:  "job/list",
@@ -373,8 +633,18 @@ Results:
 
 // Returns list of job ids.
 func rcJobList(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	IDs := running.IDs()
+	if rc.GetTenant(ctx) != "" {
+		visible := []int64{}
+		for _, ID := range IDs {
+			if job := running.Get(ID); job != nil && job.visibleTo(ctx) {
+				visible = append(visible, ID)
+			}
+		}
+		IDs = visible
+	}
 	out = make(rc.Params)
-	out["jobids"] = running.IDs()
+	out["jobids"] = IDs
 	return out, nil
 }
 
@@ -383,6 +653,9 @@ func init() {
 		Path:  "job/stop",
 		Fn:    rcJobStop,
 		Title: "Stop the running job",
+		Parameters: []rc.Parameter{
+			{Name: "jobid", Type: "integer", Help: "id of the job", Required: true},
+		},
 		Help: `Parameters:
 
 - jobid - id of the job (integer).
@@ -397,7 +670,7 @@ func rcJobStop(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 		return nil, err
 	}
 	job := running.Get(jobID)
-	if job == nil {
+	if job == nil || !job.visibleTo(ctx) {
 		return nil, errors.New("job not found")
 	}
 	job.mu.Lock()
@@ -406,3 +679,126 @@ func rcJobStop(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	job.Stop()
 	return out, nil
 }
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/stopgroup",
+		Fn:    rcJobStopGroup,
+		Title: "Stop all running jobs in a group",
+		Parameters: []rc.Parameter{
+			{Name: "group", Type: "string", Help: "name of the group", Required: true},
+		},
+		Help: `Parameters:
+
+- group - name of the group (string) as set with _group.
+
+This stops all the jobs in the group which haven't finished,
+including any waiting in the queue.
+
+Results:
+
+- jobids - array of integer ids of the jobs stopped.
+`,
+	})
+}
+
+// Stops the running jobs in a group.
+func rcJobStopGroup(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	group, err := in.GetString("group")
+	if err != nil {
+		return nil, err
+	}
+	stopped := []int64{}
+	for _, job := range running.Group(group) {
+		if !job.visibleTo(ctx) {
+			continue
+		}
+		job.mu.Lock()
+		if !job.Finished {
+			job.Stop()
+			stopped = append(stopped, job.ID)
+		}
+		job.mu.Unlock()
+	}
+	return rc.Params{
+		"jobids": stopped,
+	}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/statusgroup",
+		Fn:    rcJobStatusGroup,
+		Title: "Reads the status of all the jobs in a group",
+		Parameters: []rc.Parameter{
+			{Name: "group", Type: "string", Help: "name of the group", Required: true},
+		},
+		Help: `Parameters:
+
+- group - name of the group (string) as set with _group.
+
+Results:
+
+- jobs - array of the status of each job in the group as returned by job/status
+- running - number of jobs which haven't finished, including queued ones
+- finished - number of jobs which have finished
+- success - number of jobs which finished successfully
+- errors - number of jobs which finished with an error
+- stats - the stats for the group as returned by core/stats
+
+The jobs in a group share its stats so these are the totals for all
+of them.
+`,
+	})
+}
+
+// Returns the status of the jobs in a group.
+func rcJobStatusGroup(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	group, err := in.GetString("group")
+	if err != nil {
+		return nil, err
+	}
+	groupJobs := []*Job{}
+	for _, job := range running.Group(group) {
+		if job.visibleTo(ctx) {
+			groupJobs = append(groupJobs, job)
+		}
+	}
+	if len(groupJobs) == 0 {
+		return nil, errors.New("no jobs found in group")
+	}
+	var nRunning, nFinished, nSuccess, nErrors int
+	statuses := []rc.Params{}
+	for _, job := range groupJobs {
+		status := make(rc.Params)
+		job.mu.Lock()
+		err = rc.Reshape(&status, job)
+		switch {
+		case !job.Finished:
+			nRunning++
+		case job.Success:
+			nFinished++
+			nSuccess++
+		default:
+			nFinished++
+			nErrors++
+		}
+		job.mu.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("reshape failed in job status: %w", err)
+		}
+		statuses = append(statuses, status)
+	}
+	stats, err := accounting.StatsGroup(ctx, group).RemoteStats()
+	if err != nil {
+		return nil, err
+	}
+	return rc.Params{
+		"jobs":     statuses,
+		"running":  nRunning,
+		"finished": nFinished,
+		"success":  nSuccess,
+		"errors":   nErrors,
+		"stats":    stats,
+	}, nil
+}
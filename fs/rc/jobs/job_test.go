@@ -302,6 +302,86 @@ func TestExecuteJobWithFilter(t *testing.T) {
 	assert.Equal(t, true, called)
 }
 
+func TestExecuteJobWithBadFilter(t *testing.T) {
+	ctx := context.Background()
+	jobID = 0
+	jobFn := func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		t.Fatal("job shouldn't be run")
+		return nil, nil
+	}
+	for _, f := range []rc.Params{
+		{"MaxSiz": "1k"},
+		{"FilterRule": []string{"potato"}},
+		{"MinAge": "2h", "MaxAge": "1h"},
+		{"FilesFromList": []string{"a"}, "IncludeRule": []string{"b"}},
+	} {
+		_, _, err := NewJob(ctx, jobFn, rc.Params{"_filter": f})
+		assert.True(t, rc.IsErrParamInvalid(err), f)
+	}
+}
+
+func TestExecuteJobWithFilesFromList(t *testing.T) {
+	ctx := context.Background()
+	jobID = 0
+	called := false
+	jobFn := func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		fi := filter.GetConfig(ctx)
+		assert.True(t, fi.HaveFilesFrom())
+		assert.True(t, fi.Include("dir/a", 1, time.Now()))
+		assert.False(t, fi.Include("dir/b", 1, time.Now()))
+		called = true
+		return nil, nil
+	}
+	_, _, err := NewJob(ctx, jobFn, rc.Params{
+		"_filter": `{"filesFromList": ["dir/a", "c"]}`,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, true, called)
+	assert.False(t, filter.GetConfig(ctx).HaveFilesFrom())
+}
+
+func TestExecuteJobWithDryRun(t *testing.T) {
+	ctx := context.Background()
+	jobID = 0
+	jobFn := func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		assert.True(t, fs.GetConfig(ctx).DryRun)
+		_, found := in["_dryrun"]
+		assert.False(t, found)
+		fs.GetDryRunRecorder(ctx).Add(nil, "delete", 42)
+		return rc.Params{"hello": "world"}, nil
+	}
+	_, out, err := NewJob(ctx, jobFn, rc.Params{
+		"_dryrun": true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"hello": "world",
+		"dryRun": []fs.DryRunAction{
+			{Action: "delete", Name: "", Size: 42},
+		},
+	}, out)
+	assert.False(t, fs.GetConfig(ctx).DryRun)
+
+	// Without _dryrun the output is unchanged
+	jobID = 0
+	_, out, err = NewJob(ctx, func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		assert.False(t, fs.GetConfig(ctx).DryRun)
+		assert.Nil(t, fs.GetDryRunRecorder(ctx))
+		return nil, nil
+	}, rc.Params{
+		"_dryrun": false,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{}, out)
+
+	// Bad parameter is an error
+	jobID = 0
+	_, _, err = NewJob(ctx, jobFn, rc.Params{
+		"_dryrun": "potato",
+	})
+	assert.Error(t, err)
+}
+
 func TestExecuteJobWithGroup(t *testing.T) {
 	ctx := context.Background()
 	jobID = 0
@@ -348,6 +428,9 @@ func TestRcJobStatus(t *testing.T) {
 	assert.Equal(t, "", out["error"])
 	assert.Equal(t, false, out["finished"])
 	assert.Equal(t, false, out["success"])
+	progress, ok := out["progress"].(rc.Params)
+	require.True(t, ok)
+	assert.Equal(t, int64(0), progress["bytes"])
 
 	in = rc.Params{"jobid": 123123123}
 	_, err = call.Fn(context.Background(), in)
@@ -494,3 +577,139 @@ func TestOnFinishAlreadyFinished(t *testing.T) {
 		t.Fatal("Timeout waiting for OnFinish to fire")
 	}
 }
+
+func TestRcJobGroup(t *testing.T) {
+	ctx := context.Background()
+	jobID = 0
+	for i := 0; i < 2; i++ {
+		_, _, err := NewJob(ctx, ctxFn, rc.Params{"_async": true, "_group": "test-job-group"})
+		require.NoError(t, err)
+	}
+	_, _, err := NewJob(ctx, shortFn, rc.Params{"_group": "test-job-group"})
+	require.NoError(t, err)
+	_, _, err = NewJob(ctx, ctxFn, rc.Params{"_async": true, "_group": "test-job-other"})
+	require.NoError(t, err)
+
+	groupJobs := running.Group("test-job-group")
+	require.Equal(t, 3, len(groupJobs))
+	assert.Equal(t, []int64{1, 2, 3}, []int64{groupJobs[0].ID, groupJobs[1].ID, groupJobs[2].ID})
+
+	status := rc.Calls.Get("job/statusgroup")
+	require.NotNil(t, status)
+	out, err := status.Fn(ctx, rc.Params{"group": "test-job-group"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, out["running"])
+	assert.Equal(t, 1, out["finished"])
+	assert.Equal(t, 1, out["success"])
+	assert.Equal(t, 0, out["errors"])
+	assert.Equal(t, 3, len(out["jobs"].([]rc.Params)))
+	assert.NotNil(t, out["stats"])
+
+	_, err = status.Fn(ctx, rc.Params{"group": "test-job-missing"})
+	assert.Error(t, err)
+
+	stop := rc.Calls.Get("job/stopgroup")
+	require.NotNil(t, stop)
+	out, err = stop.Fn(ctx, rc.Params{"group": "test-job-group"})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"jobids": []int64{1, 2}}, out)
+
+	time.Sleep(10 * time.Millisecond)
+
+	out, err = status.Fn(ctx, rc.Params{"group": "test-job-group"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, out["running"])
+	assert.Equal(t, 3, out["finished"])
+	assert.Equal(t, 2, out["errors"])
+
+	// Jobs in other groups are left running
+	other := running.Get(4)
+	other.mu.Lock()
+	assert.False(t, other.Finished)
+	other.Stop()
+	other.mu.Unlock()
+}
+
+func TestExecuteJobWithTimeout(t *testing.T) {
+	ctx := context.Background()
+	jobID = 0
+	_, _, err := NewJob(ctx, ctxFn, rc.Params{
+		"_timeout": "10ms",
+	})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "timed out after 10ms")
+
+	// Calls which finish in time aren't affected
+	jobID = 0
+	_, out, err := NewJob(ctx, func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		_, found := in["_timeout"]
+		assert.False(t, found)
+		_, hasDeadline := ctx.Deadline()
+		assert.True(t, hasDeadline)
+		return rc.Params{"ok": true}, nil
+	}, rc.Params{
+		"_timeout": "1h",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"ok": true}, out)
+
+	// Bad timeouts are errors
+	for _, timeout := range []string{"potato", "-1s"} {
+		jobID = 0
+		_, _, err = NewJob(ctx, ctxFn, rc.Params{
+			"_timeout": timeout,
+		})
+		assert.True(t, rc.IsErrParamInvalid(err), timeout)
+	}
+}
+
+func TestRcJobTenant(t *testing.T) {
+	jobID = 0
+	alice := rc.WithTenant(context.Background(), "alice")
+	bob := rc.WithTenant(context.Background(), "bob")
+	job, _, err := NewJob(alice, func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		assert.Equal(t, "alice", rc.GetTenant(ctx))
+		group, _ := accounting.StatsGroupFromContext(ctx)
+		assert.Equal(t, "alice/party", group)
+		return nil, nil
+	}, rc.Params{"_async": true, "_group": "party"})
+	require.NoError(t, err)
+	done := make(chan struct{})
+	_, err = OnFinish(job.ID, func() { close(done) })
+	require.NoError(t, err)
+	<-done
+
+	status := rc.Calls.Get("job/status")
+	_, err = status.Fn(alice, rc.Params{"jobid": job.ID})
+	assert.NoError(t, err)
+	_, err = status.Fn(bob, rc.Params{"jobid": job.ID})
+	assert.Error(t, err)
+	_, err = status.Fn(context.Background(), rc.Params{"jobid": job.ID})
+	assert.NoError(t, err)
+
+	list := rc.Calls.Get("job/list")
+	out, err := list.Fn(bob, rc.Params{})
+	require.NoError(t, err)
+	assert.NotContains(t, out["jobids"], job.ID)
+	out, err = list.Fn(alice, rc.Params{})
+	require.NoError(t, err)
+	assert.Contains(t, out["jobids"], job.ID)
+
+	_, err = rc.Calls.Get("job/stop").Fn(bob, rc.Params{"jobid": job.ID})
+	assert.Error(t, err)
+	_, err = rc.Calls.Get("job/statusgroup").Fn(bob, rc.Params{"group": "alice/party"})
+	assert.Error(t, err)
+
+	// Tenants can't set the config or the filters
+	for _, in := range []rc.Params{
+		{"_config": rc.Params{"BackupDir": "/tmp/backup"}},
+		{"_filter": rc.Params{"FilesFrom": []string{"/etc/passwd"}}},
+	} {
+		_, _, err = NewJob(alice, func(ctx context.Context, in rc.Params) (rc.Params, error) {
+			t.Error("job shouldn't run")
+			return nil, nil
+		}, in)
+		assert.True(t, errors.Is(err, rc.ErrTenantForbidden), in)
+	}
+}
@@ -0,0 +1,516 @@
+// Queue of jobs waiting to run which can be kept in a file
+
+package jobs
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/rc/events"
+	"go.etcd.io/bbolt"
+)
+
+// Names of the buckets in the queue file
+var (
+	queueBucket = []byte("queue") // queued jobs by ID
+	stateBucket = []byte("state") // state of the queue
+	pausedKey   = []byte("paused")
+)
+
+// queuedJob is a job waiting in the queue - it is stored as JSON in
+// the queue file
+type queuedJob struct {
+	ID       int64     `json:"id"`
+	Path     string    `json:"path"`     // rc call to run
+	Params   rc.Params `json:"params"`   // parameters for the call
+	Priority int64     `json:"priority"` // jobs with higher priorities run first
+	Queued   time.Time `json:"queued"`   // when the job was queued
+}
+
+// jobQueue holds the jobs waiting to be run
+type jobQueue struct {
+	mu      sync.Mutex
+	items   []*queuedJob // jobs in the order they will be run
+	paused  bool         // set if no more jobs should be started
+	running int          // number of jobs from the queue running
+	db      *bbolt.DB    // file the queue is kept in if set
+}
+
+var queue = &jobQueue{}
+
+// idKey makes the key for a job ID in the queue file
+func idKey(id int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+// StartQueue keeps the job queue in the file at path, restarting any
+// jobs left in it by a previous run.
+func StartQueue(path string) error {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("failed to open job queue %q: %w", path, err)
+	}
+	var (
+		items  []*queuedJob
+		paused bool
+	)
+	err = db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(queueBucket)
+		if err != nil {
+			return err
+		}
+		err = b.ForEach(func(k, v []byte) error {
+			item := new(queuedJob)
+			if err := json.Unmarshal(v, item); err != nil {
+				fs.Errorf(nil, "rc: dropping unreadable job from queue: %v", err)
+				return b.Delete(k)
+			}
+			items = append(items, item)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		state, err := tx.CreateBucketIfNotExists(stateBucket)
+		if err != nil {
+			return err
+		}
+		value := state.Get(pausedKey)
+		paused = len(value) > 0 && value[0] != 0
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return fmt.Errorf("failed to read job queue %q: %w", path, err)
+	}
+
+	queue.mu.Lock()
+	if queue.db != nil {
+		queue.mu.Unlock()
+		_ = db.Close()
+		return errors.New("job queue already started")
+	}
+	queue.db = db
+	queue.paused = paused
+	queue.mu.Unlock()
+
+	// Make sure new jobs don't reuse the IDs of the queued ones
+	for _, item := range items {
+		noteJobID(item.ID)
+		running.addQueued(item)
+	}
+	queue.mu.Lock()
+	queue.items = append(queue.items, items...)
+	queue._sort()
+	queue.mu.Unlock()
+	if len(items) > 0 {
+		fs.Logf(nil, "rc: restored %d queued jobs from %q", len(items), path)
+	}
+	queue.kick()
+	return nil
+}
+
+// stopQueue stops keeping the queue in a file and empties it
+func stopQueue() error {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	queue.items = nil
+	queue.paused = false
+	if queue.db == nil {
+		return nil
+	}
+	err := queue.db.Close()
+	queue.db = nil
+	return err
+}
+
+// Queued returns true if the _queue parameter is set in in
+func Queued(in rc.Params) (bool, error) {
+	isQueued, err := in.GetBool("_queue")
+	if rc.NotErrParamNotFound(err) {
+		return false, err
+	}
+	return isQueued, nil
+}
+
+// Enqueue adds a job to run the call at path with in to the queue
+// returning the job and its jobid in out.
+//
+// The job runs in the background when its turn comes. The queue is
+// kept in the file passed to StartQueue if it was called so the job
+// will be run even if rclone restarts before it starts.
+func Enqueue(path string, in rc.Params) (job *Job, out rc.Params, err error) {
+	call := rc.Calls.Get(path)
+	if call == nil {
+		return nil, nil, fmt.Errorf("couldn't find method %q", path)
+	}
+	if call.NeedsRequest || call.NeedsResponse {
+		return nil, nil, fmt.Errorf("%q can't be queued", path)
+	}
+	in = in.Copy() // copy input so we can change it
+	delete(in, "_queue")
+	delete(in, "_async") // queued jobs always run in the background
+	priority, err := in.GetInt64("_priority")
+	if rc.NotErrParamNotFound(err) {
+		return nil, nil, err
+	}
+	delete(in, "_priority")
+
+	// Check the parameters used to start the job now rather than
+	// when it runs
+	if err = checkParams(in); err != nil {
+		return nil, nil, err
+	}
+
+	item := &queuedJob{
+		ID:       atomic.AddInt64(&jobID, 1),
+		Path:     path,
+		Params:   in,
+		Priority: priority,
+		Queued:   time.Now(),
+	}
+	job = running.addQueued(item)
+	err = queue.add(item)
+	if err != nil {
+		running.mu.Lock()
+		delete(running.jobs, item.ID)
+		running.mu.Unlock()
+		return nil, nil, err
+	}
+	queue.kick()
+	return job, rc.Params{"jobid": item.ID}, nil
+}
+
+// checkParams checks the special parameters in in which are used
+// when a job is started so a job which will be started later can be
+// checked now
+func checkParams(in rc.Params) (err error) {
+	ctx := context.Background()
+	if _, err = getConfig(ctx, in.Copy()); err != nil {
+		return err
+	}
+	if _, err = getFilter(ctx, in.Copy()); err != nil {
+		return err
+	}
+	if _, _, err = getDryRun(ctx, in.Copy()); err != nil {
+		return err
+	}
+	if _, _, err = getGroup(ctx, in.Copy(), 0); err != nil {
+		return err
+	}
+	if _, err = getWebhook(in.Copy()); err != nil {
+		return err
+	}
+	if _, err = getTimeout(in.Copy()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// addQueued adds a placeholder Job for item which is replaced when
+// it starts running
+func (jobs *Jobs) addQueued(item *queuedJob) *Job {
+	group, _ := item.Params.GetString("_group")
+	if group == "" {
+		group = fmt.Sprintf("job/%d", item.ID)
+	}
+	job := &Job{
+		ID:     item.ID,
+		Group:  group,
+		Queued: true,
+	}
+	job.Stop = func() {
+		if queue.remove(item.ID) {
+			// finish the job when the caller releases job.mu
+			go job.finish(nil, errors.New("job removed from queue"))
+		}
+	}
+	jobs.mu.Lock()
+	jobs.jobs[item.ID] = job
+	jobs.mu.Unlock()
+	return job
+}
+
+// _sort sorts the queue into the order the jobs will be run
+//
+// Call with q.mu held
+func (q *jobQueue) _sort() {
+	sort.SliceStable(q.items, func(i, j int) bool {
+		a, b := q.items[i], q.items[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return a.ID < b.ID
+	})
+}
+
+// _put writes the key and value to bucket in the queue file if in use
+//
+// Call with q.mu held
+func (q *jobQueue) _put(bucket, key, value []byte) error {
+	if q.db == nil {
+		return nil
+	}
+	return q.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucket).Put(key, value)
+	})
+}
+
+// _save writes item to the queue file if in use
+//
+// Call with q.mu held
+func (q *jobQueue) _save(item *queuedJob) error {
+	buf, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	err = q._put(queueBucket, idKey(item.ID), buf)
+	if err != nil {
+		return fmt.Errorf("failed to save job to queue: %w", err)
+	}
+	return nil
+}
+
+// _delete removes the job with id from the queue file if in use
+//
+// Call with q.mu held
+func (q *jobQueue) _delete(id int64) {
+	if q.db == nil {
+		return
+	}
+	err := q.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(queueBucket).Delete(idKey(id))
+	})
+	if err != nil {
+		fs.Errorf(nil, "rc: failed to remove job %d from queue: %v", id, err)
+	}
+}
+
+// add item to the queue
+func (q *jobQueue) add(item *queuedJob) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	err := q._save(item)
+	if err != nil {
+		return err
+	}
+	q.items = append(q.items, item)
+	q._sort()
+	return nil
+}
+
+// _find returns the index of the job with id in the queue or -1
+//
+// Call with q.mu held
+func (q *jobQueue) _find(id int64) int {
+	for i, item := range q.items {
+		if item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// remove the job with id from the queue returning true if it was there
+func (q *jobQueue) remove(id int64) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := q._find(id)
+	if i < 0 {
+		return false
+	}
+	q.items = append(q.items[:i], q.items[i+1:]...)
+	q._delete(id)
+	return true
+}
+
+// setPriority changes the priority of the job with id
+func (q *jobQueue) setPriority(id int64, priority int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := q._find(id)
+	if i < 0 {
+		return errors.New("job not found in queue")
+	}
+	item := q.items[i]
+	item.Priority = priority
+	q._sort()
+	return q._save(item)
+}
+
+// setPaused pauses or resumes starting jobs from the queue
+func (q *jobQueue) setPaused(paused bool) error {
+	q.mu.Lock()
+	q.paused = paused
+	value := []byte{0}
+	if paused {
+		value[0] = 1
+	}
+	err := q._put(stateBucket, pausedKey, value)
+	q.mu.Unlock()
+	if !paused {
+		q.kick()
+	}
+	return err
+}
+
+// concurrency returns the number of queued jobs which can run at once
+func (q *jobQueue) concurrency() int {
+	if n := running.opt.JobQueueConcurrency; n > 0 {
+		return n
+	}
+	return 1
+}
+
+// kick starts as many jobs from the queue as are allowed
+func (q *jobQueue) kick() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for !q.paused && len(q.items) > 0 && q.running < q.concurrency() {
+		item := q.items[0]
+		q.items = q.items[1:]
+		q._delete(item.ID)
+		q.running++
+		go q.run(item)
+	}
+}
+
+// run the queued job item then start the next one
+func (q *jobQueue) run(item *queuedJob) {
+	defer func() {
+		q.mu.Lock()
+		q.running--
+		q.mu.Unlock()
+		q.kick()
+	}()
+	placeholder := running.Get(item.ID)
+	call := rc.Calls.Get(item.Path)
+	if call == nil {
+		if placeholder != nil {
+			placeholder.finish(nil, fmt.Errorf("couldn't find method %q", item.Path))
+		}
+		return
+	}
+	events.Publish(events.TypeJob, rc.Params{
+		"id":     item.ID,
+		"status": "started",
+	})
+	job, _, err := running.startJob(WithPath(context.Background(), item.Path), item.ID, call.Fn, item.Params)
+	if job == nil {
+		// The job couldn't be started
+		job = placeholder
+		if job == nil {
+			return
+		}
+		job.finish(nil, err)
+	}
+	job.publishFinished()
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/queue",
+		Fn:    rcJobQueue,
+		Title: "Lists the jobs waiting in the queue",
+		Help: `Parameters: None.
+
+Jobs are added to the queue by passing _queue=true to any rc call.
+
+Results:
+
+- paused - boolean whether starting jobs from the queue is paused
+- queue - array of jobs in the order they will run, each with
+    - id - id of the job
+    - path - the rc call it will run
+    - priority - jobs with a higher priority run first
+    - queued - time the job was queued
+`,
+	})
+}
+
+// Returns the jobs in the queue
+func rcJobQueue(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	list := []rc.Params{}
+	for _, item := range queue.items {
+		list = append(list, rc.Params{
+			"id":       item.ID,
+			"path":     item.Path,
+			"priority": item.Priority,
+			"queued":   item.Queued,
+		})
+	}
+	return rc.Params{
+		"paused": queue.paused,
+		"queue":  list,
+	}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/queue-pause",
+		Fn:    rcJobQueuePause,
+		Title: "Stop starting jobs from the queue",
+		Help: `Parameters: None.
+
+Jobs already running carry on. Jobs can still be added to the queue.
+`,
+	})
+}
+
+// Pauses the queue
+func rcJobQueuePause(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	return nil, queue.setPaused(true)
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/queue-resume",
+		Fn:    rcJobQueueResume,
+		Title: "Start running jobs from the queue again",
+		Help:  `Parameters: None.`,
+	})
+}
+
+// Resumes the queue
+func rcJobQueueResume(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	return nil, queue.setPaused(false)
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/queue-priority",
+		Fn:    rcJobQueuePriority,
+		Title: "Change the priority of a job in the queue",
+		Help: `Parameters:
+
+- jobid - id of the job (integer)
+- priority - new priority of the job (integer) - jobs with a higher priority run first
+`,
+	})
+}
+
+// Sets the priority of a queued job
+func rcJobQueuePriority(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	jobID, err := in.GetInt64("jobid")
+	if err != nil {
+		return nil, err
+	}
+	priority, err := in.GetInt64("priority")
+	if err != nil {
+		return nil, err
+	}
+	return nil, queue.setPriority(jobID, priority)
+}
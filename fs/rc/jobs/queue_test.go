@@ -0,0 +1,187 @@
+package jobs
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queueRuns records the "n" parameter of each test/queue call run
+var queueRuns struct {
+	mu sync.Mutex
+	ns []int64
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "test/queue",
+		Title: "Records the n parameter for the queue tests",
+		Fn: func(ctx context.Context, in rc.Params) (rc.Params, error) {
+			n, err := in.GetInt64("n")
+			if err != nil {
+				return nil, err
+			}
+			queueRuns.mu.Lock()
+			queueRuns.ns = append(queueRuns.ns, n)
+			queueRuns.mu.Unlock()
+			return rc.Params{"n": n}, nil
+		},
+	})
+}
+
+// resetQueueRuns clears the record of queue runs
+func resetQueueRuns() {
+	queueRuns.mu.Lock()
+	queueRuns.ns = nil
+	queueRuns.mu.Unlock()
+}
+
+// getQueueRuns returns the n parameters of the calls run
+func getQueueRuns() []int64 {
+	queueRuns.mu.Lock()
+	defer queueRuns.mu.Unlock()
+	return append([]int64(nil), queueRuns.ns...)
+}
+
+// queueIDs returns the IDs in the queue in order
+func queueIDs(t *testing.T) (ids []int64) {
+	out, err := rcJobQueue(context.Background(), nil)
+	require.NoError(t, err)
+	for _, item := range out["queue"].([]rc.Params) {
+		ids = append(ids, item["id"].(int64))
+	}
+	return ids
+}
+
+// waitFinished waits for job to finish
+func waitFinished(t *testing.T, job *Job) {
+	for i := 0; i < 100; i++ {
+		job.mu.Lock()
+		finished := job.Finished
+		job.mu.Unlock()
+		if finished {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %d didn't finish", job.ID)
+}
+
+func TestQueued(t *testing.T) {
+	for _, test := range []struct {
+		in      rc.Params
+		want    bool
+		wantErr bool
+	}{
+		{rc.Params{}, false, false},
+		{rc.Params{"_queue": true}, true, false},
+		{rc.Params{"_queue": "false"}, false, false},
+		{rc.Params{"_queue": "potato"}, false, true},
+	} {
+		got, err := Queued(test.in)
+		assert.Equal(t, test.want, got, test.in)
+		assert.Equal(t, test.wantErr, err != nil, test.in)
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	defer func() {
+		require.NoError(t, stopQueue())
+	}()
+	resetQueueRuns()
+	require.NoError(t, queue.setPaused(true))
+
+	_, _, err := Enqueue("test/potato", rc.Params{})
+	assert.Error(t, err)
+	_, _, err = Enqueue("test/queue", rc.Params{"_priority": "high"})
+	assert.Error(t, err)
+	_, _, err = Enqueue("test/queue", rc.Params{"_config": "potato"})
+	assert.Error(t, err)
+
+	job1, out, err := Enqueue("test/queue", rc.Params{"n": 1, "_queue": true})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"jobid": job1.ID}, out)
+	assert.True(t, job1.Queued)
+	job2, _, err := Enqueue("test/queue", rc.Params{"n": 2, "_priority": 5})
+	require.NoError(t, err)
+	job3, _, err := Enqueue("test/queue", rc.Params{"n": 3})
+	require.NoError(t, err)
+	job4, _, err := Enqueue("test/queue", rc.Params{"n": 4})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{job2.ID, job1.ID, job3.ID, job4.ID}, queueIDs(t))
+
+	// Reorder the queue
+	_, err = rcJobQueuePriority(context.Background(), rc.Params{"jobid": job3.ID, "priority": 10})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{job3.ID, job2.ID, job1.ID, job4.ID}, queueIDs(t))
+	_, err = rcJobQueuePriority(context.Background(), rc.Params{"jobid": 123456789, "priority": 10})
+	assert.Error(t, err)
+
+	// Stop a queued job
+	_, err = rcJobStop(context.Background(), rc.Params{"jobid": job1.ID})
+	require.NoError(t, err)
+	waitFinished(t, job1)
+	assert.Equal(t, "job removed from queue", job1.Error)
+	assert.Equal(t, []int64{job3.ID, job2.ID, job4.ID}, queueIDs(t))
+
+	// Nothing runs until resumed
+	assert.Equal(t, []int64(nil), getQueueRuns())
+	_, err = rcJobQueueResume(context.Background(), nil)
+	require.NoError(t, err)
+	for _, job := range []*Job{job2, job3, job4} {
+		waitFinished(t, running.Get(job.ID))
+	}
+	assert.Equal(t, []int64{3, 2, 4}, getQueueRuns())
+	status, err := rcJobStatus(context.Background(), rc.Params{"jobid": job3.ID})
+	require.NoError(t, err)
+	assert.Equal(t, true, status["success"])
+	assert.Equal(t, false, status["queued"])
+	assert.Equal(t, map[string]interface{}{"n": float64(3)}, status["output"])
+	assert.Equal(t, []int64(nil), queueIDs(t))
+}
+
+func TestQueueFile(t *testing.T) {
+	defer func() {
+		require.NoError(t, stopQueue())
+	}()
+	resetQueueRuns()
+	path := filepath.Join(t.TempDir(), "queue.db")
+	require.NoError(t, StartQueue(path))
+	assert.Error(t, StartQueue(path))
+
+	_, err := rcJobQueuePause(context.Background(), nil)
+	require.NoError(t, err)
+	job1, _, err := Enqueue("test/queue", rc.Params{"n": 1})
+	require.NoError(t, err)
+	job2, _, err := Enqueue("test/queue", rc.Params{"n": 2, "_priority": 1, "_group": "queued"})
+	require.NoError(t, err)
+
+	// Start again as if rclone had restarted
+	require.NoError(t, stopQueue())
+	jobID = 0
+	running = newJobs()
+	require.NoError(t, StartQueue(path))
+	assert.Equal(t, job2.ID, jobID)
+	out, err := rcJobQueue(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, true, out["paused"])
+	assert.Equal(t, []int64{job2.ID, job1.ID}, queueIDs(t))
+	assert.True(t, running.Get(job1.ID).Queued)
+
+	require.NoError(t, queue.setPaused(false))
+	waitFinished(t, running.Get(job1.ID))
+	waitFinished(t, running.Get(job2.ID))
+	assert.Equal(t, []int64{2, 1}, getQueueRuns())
+	assert.Equal(t, "queued", running.Get(job2.ID).Group)
+
+	// The finished jobs aren't restored
+	require.NoError(t, stopQueue())
+	require.NoError(t, StartQueue(path))
+	assert.Equal(t, []int64(nil), queueIDs(t))
+}
@@ -0,0 +1,445 @@
+// Schedule rc calls to run periodically
+
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	"go.etcd.io/bbolt"
+)
+
+// Name of the bucket in the schedule file
+var scheduleBucket = []byte("schedule") // scheduled jobs by ID
+
+// scheduledJob is an rc call which is run whenever its cron
+// expression matches - it is stored as JSON in the schedule file
+type scheduledJob struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name,omitempty"` // set if made by job/apply
+	Cron      string    `json:"cron"`           // cron expression for when to run
+	Path      string    `json:"path"`           // rc call to run
+	Params    rc.Params `json:"params"`         // parameters for the call
+	Created   time.Time `json:"created"`        // when the schedule was created
+	LastRun   time.Time `json:"lastRun"`        // when the call was last started
+	LastJobID int64     `json:"lastJobId"`      // id of the job last started
+	schedule  *cronSchedule
+	next      time.Time   // when the call will next run
+	timer     *time.Timer // fires at next
+}
+
+// jobScheduler holds the scheduled jobs
+type jobScheduler struct {
+	mu     sync.Mutex
+	items  map[int64]*scheduledJob // by ID
+	lastID int64                   // last ID given out
+	db     *bbolt.DB               // file the schedule is kept in if set
+}
+
+var scheduler = &jobScheduler{
+	items: make(map[int64]*scheduledJob),
+}
+
+// StartSchedule keeps the scheduled jobs in the file at path, loading
+// any created by a previous run.
+func StartSchedule(path string) error {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("failed to open job schedule %q: %w", path, err)
+	}
+	var items []*scheduledJob
+	err = db.Update(func(tx *bbolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(scheduleBucket)
+		if err != nil {
+			return err
+		}
+		return b.ForEach(func(k, v []byte) error {
+			item := new(scheduledJob)
+			if err := json.Unmarshal(v, item); err != nil {
+				fs.Errorf(nil, "rc: dropping unreadable job from schedule: %v", err)
+				return b.Delete(k)
+			}
+			item.schedule, err = parseCron(item.Cron)
+			if err != nil {
+				fs.Errorf(nil, "rc: dropping job %d from schedule: %v", item.ID, err)
+				return b.Delete(k)
+			}
+			items = append(items, item)
+			return nil
+		})
+	})
+	if err != nil {
+		_ = db.Close()
+		return fmt.Errorf("failed to read job schedule %q: %w", path, err)
+	}
+
+	s := scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db != nil {
+		_ = db.Close()
+		return errors.New("job schedule already started")
+	}
+	s.db = db
+	for _, item := range items {
+		s.items[item.ID] = item
+		if item.ID > s.lastID {
+			s.lastID = item.ID
+		}
+		s._arm(item)
+	}
+	if len(items) > 0 {
+		fs.Logf(nil, "rc: loaded %d scheduled jobs from %q", len(items), path)
+	}
+	return nil
+}
+
+// stopSchedule stops keeping the schedule in a file and removes all
+// the scheduled jobs
+func stopSchedule() error {
+	s := scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, item := range s.items {
+		if item.timer != nil {
+			item.timer.Stop()
+		}
+		delete(s.items, id)
+	}
+	s.lastID = 0
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Close()
+	s.db = nil
+	return err
+}
+
+// _save writes item to the schedule file if in use
+//
+// Call with s.mu held
+func (s *jobScheduler) _save(item *scheduledJob) error {
+	if s.db == nil {
+		return nil
+	}
+	buf, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(scheduleBucket).Put(idKey(item.ID), buf)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save job to schedule: %w", err)
+	}
+	return nil
+}
+
+// _arm sets the timer to run item when its schedule next matches
+//
+// Call with s.mu held
+func (s *jobScheduler) _arm(item *scheduledJob) {
+	item.next = item.schedule.next(time.Now())
+	if item.next.IsZero() {
+		fs.Errorf(nil, "rc: scheduled job %d will never run", item.ID)
+		return
+	}
+	item.timer = time.AfterFunc(time.Until(item.next), func() {
+		s.run(item)
+	})
+}
+
+// add makes a new scheduled job to run the call at path with in
+// whenever the cron expression matches
+func (s *jobScheduler) add(cron string, path string, in rc.Params) (*scheduledJob, error) {
+	return s.addNamed("", cron, path, in)
+}
+
+// addNamed is like add but if name is set it replaces any scheduled
+// job with the same name
+func (s *jobScheduler) addNamed(name string, cron string, path string, in rc.Params) (*scheduledJob, error) {
+	schedule, err := parseCron(cron)
+	if err != nil {
+		return nil, rc.NewErrParamInvalid(err)
+	}
+	call := rc.Calls.Get(path)
+	if call == nil {
+		return nil, rc.NewErrParamInvalid(fmt.Errorf("couldn't find method %q", path))
+	}
+	if call.NeedsRequest || call.NeedsResponse {
+		return nil, rc.NewErrParamInvalid(fmt.Errorf("%q can't be scheduled", path))
+	}
+	in = in.Copy()
+	delete(in, "_async") // scheduled jobs always run in the background
+	if _, err = Queued(in); err != nil {
+		return nil, err
+	}
+	if err = checkParams(in); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if name != "" {
+		for id, old := range s.items {
+			if old.Name != name {
+				continue
+			}
+			err = s._remove(id)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	s.lastID++
+	item := &scheduledJob{
+		ID:       s.lastID,
+		Name:     name,
+		Cron:     cron,
+		Path:     path,
+		Params:   in,
+		Created:  time.Now(),
+		schedule: schedule,
+	}
+	err = s._save(item)
+	if err != nil {
+		return nil, err
+	}
+	s.items[item.ID] = item
+	s._arm(item)
+	return item, nil
+}
+
+// remove the scheduled job with id returning true if it was there
+func (s *jobScheduler) remove(id int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.items[id] == nil {
+		return false, nil
+	}
+	return true, s._remove(id)
+}
+
+// _remove the scheduled job with id which must exist
+//
+// Call with s.mu held
+func (s *jobScheduler) _remove(id int64) error {
+	item := s.items[id]
+	if item.timer != nil {
+		item.timer.Stop()
+	}
+	delete(s.items, id)
+	if s.db == nil {
+		return nil
+	}
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(scheduleBucket).Delete(idKey(id))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove job %d from schedule: %w", id, err)
+	}
+	return nil
+}
+
+// list returns the scheduled jobs sorted by ID
+func (s *jobScheduler) list() []rc.Params {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []rc.Params{}
+	for _, item := range s.items {
+		list = append(list, rc.Params{
+			"id":        item.ID,
+			"name":      item.Name,
+			"cron":      item.Cron,
+			"path":      item.Path,
+			"params":    item.Params,
+			"created":   item.Created,
+			"lastRun":   item.LastRun,
+			"lastJobId": item.LastJobID,
+			"next":      item.next,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i]["id"].(int64) < list[j]["id"].(int64)
+	})
+	return list
+}
+
+// run starts the job for item unless the last one it started is
+// still running then sets the timer for the next run
+func (s *jobScheduler) run(item *scheduledJob) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.items[item.ID] != item {
+		// removed while the timer was firing
+		return
+	}
+	defer s._arm(item)
+	if last := running.Get(item.LastJobID); last != nil {
+		last.mu.Lock()
+		finished := last.Finished
+		last.mu.Unlock()
+		if !finished {
+			fs.Logf(nil, "rc: skipping scheduled job %d as job %d it started is still running", item.ID, last.ID)
+			return
+		}
+	}
+	jobID, err := item.start()
+	if err != nil {
+		fs.Errorf(nil, "rc: failed to start scheduled job %d: %v", item.ID, err)
+		return
+	}
+	fs.Infof(nil, "rc: scheduled job %d started %q as job %d", item.ID, item.Path, jobID)
+	item.LastRun = time.Now()
+	item.LastJobID = jobID
+	err = s._save(item)
+	if err != nil {
+		fs.Errorf(nil, "rc: %v", err)
+	}
+}
+
+// start the call for item in the background or on the queue if
+// _queue is set returning the job ID
+func (item *scheduledJob) start() (jobID int64, err error) {
+	isQueued, _ := Queued(item.Params)
+	if isQueued {
+		job, _, err := Enqueue(item.Path, item.Params)
+		if err != nil {
+			return 0, err
+		}
+		return job.ID, nil
+	}
+	call := rc.Calls.Get(item.Path)
+	if call == nil {
+		return 0, fmt.Errorf("couldn't find method %q", item.Path)
+	}
+	in := item.Params.Copy()
+	in["_async"] = true
+	job, _, err := NewJob(WithPath(context.Background(), item.Path), call.Fn, in)
+	if err != nil {
+		return 0, err
+	}
+	return job.ID, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/schedule-create",
+		Fn:    rcJobScheduleCreate,
+		Title: "Run an rc call periodically",
+		Help: `Parameters:
+
+- cron - when to run the call as a cron expression, e.g. "30 2 * * *"
+- path - the rc call to run, e.g. "sync/sync"
+- params - the parameters for the call as a JSON object (optional)
+
+The cron expression has the five fields "minute hour day-of-month
+month day-of-week" as used by cron, or can be one of @hourly, @daily,
+@weekly, @monthly or @yearly. Times are in the local time zone.
+
+The call is run in the background like a call with _async=true, or
+put on the queue if _queue=true is in params. If the job it started
+last time is still running it isn't started again.
+
+Results:
+
+- id - id of the scheduled job (integer)
+- next - when the call will next run
+
+    rclone rc job/schedule-create cron="0 3 * * *" path=sync/sync params='{"srcFs":"/home","dstFs":"remote:backup"}'
+`,
+	})
+}
+
+// Creates a scheduled job
+func rcJobScheduleCreate(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	cron, err := in.GetString("cron")
+	if err != nil {
+		return nil, err
+	}
+	path, err := in.GetString("path")
+	if err != nil {
+		return nil, err
+	}
+	params := rc.Params{}
+	err = in.GetStruct("params", &params)
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	item, err := scheduler.add(cron, path, params)
+	if err != nil {
+		return nil, err
+	}
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+	return rc.Params{
+		"id":   item.ID,
+		"next": item.next,
+	}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/schedule-list",
+		Fn:    rcJobScheduleList,
+		Title: "Lists the scheduled jobs",
+		Help: `Parameters: None.
+
+Results:
+
+- schedule - array of scheduled jobs, each with
+    - id - id of the scheduled job
+    - name - name of the job if it was made by job/apply
+    - cron - the cron expression for when it runs
+    - path - the rc call it runs
+    - params - the parameters for the call
+    - created - time the scheduled job was created
+    - lastRun - time the call was last started
+    - lastJobId - id of the job last started, 0 if it hasn't run
+    - next - time the call will next run
+`,
+	})
+}
+
+// Returns the scheduled jobs
+func rcJobScheduleList(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	return rc.Params{
+		"schedule": scheduler.list(),
+	}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "job/schedule-delete",
+		Fn:    rcJobScheduleDelete,
+		Title: "Delete a scheduled job",
+		Help: `Parameters:
+
+- id - id of the scheduled job (integer)
+
+Jobs it has already started carry on running.
+`,
+	})
+}
+
+// Deletes a scheduled job
+func rcJobScheduleDelete(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	id, err := in.GetInt64("id")
+	if err != nil {
+		return nil, err
+	}
+	found, err := scheduler.remove(id)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("scheduled job not found")
+	}
+	return nil, nil
+}
@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scheduleIDs returns the IDs of the scheduled jobs in order
+func scheduleIDs(t *testing.T) (ids []int64) {
+	out, err := rcJobScheduleList(context.Background(), nil)
+	require.NoError(t, err)
+	for _, item := range out["schedule"].([]rc.Params) {
+		ids = append(ids, item["id"].(int64))
+	}
+	return ids
+}
+
+func TestScheduleCreateDelete(t *testing.T) {
+	ctx := context.Background()
+	defer func() { require.NoError(t, stopSchedule()) }()
+
+	out, err := rcJobScheduleCreate(ctx, rc.Params{
+		"cron":   "0 3 * * *",
+		"path":   "test/queue",
+		"params": `{"n": 1}`,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), out["id"])
+	assert.NotNil(t, out["next"])
+
+	_, err = rcJobScheduleCreate(ctx, rc.Params{
+		"cron": "@hourly",
+		"path": "test/queue",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 2}, scheduleIDs(t))
+
+	// Check bad parameters
+	for _, in := range []rc.Params{
+		{"cron": "potato", "path": "test/queue"},
+		{"cron": "@daily", "path": "not/found"},
+		{"cron": "@daily", "path": "test/queue", "params": rc.Params{"_config": "potato"}},
+		{"path": "test/queue"},
+		{"cron": "@daily"},
+	} {
+		_, err = rcJobScheduleCreate(ctx, in)
+		assert.Error(t, err, in)
+	}
+
+	_, err = rcJobScheduleDelete(ctx, rc.Params{"id": 1})
+	require.NoError(t, err)
+	assert.Equal(t, []int64{2}, scheduleIDs(t))
+	_, err = rcJobScheduleDelete(ctx, rc.Params{"id": 1})
+	assert.Error(t, err)
+}
+
+func TestScheduleRun(t *testing.T) {
+	ctx := context.Background()
+	defer func() { require.NoError(t, stopSchedule()) }()
+	resetQueueRuns()
+
+	item, err := scheduler.add("0 0 1 1 *", "test/queue", rc.Params{"n": 42})
+	require.NoError(t, err)
+
+	// Run it as if the timer had fired
+	scheduler.run(item)
+	scheduler.mu.Lock()
+	jobID := item.LastJobID
+	lastRun := item.LastRun
+	scheduler.mu.Unlock()
+	require.NotEqual(t, int64(0), jobID)
+	assert.False(t, lastRun.IsZero())
+	job := running.Get(jobID)
+	require.NotNil(t, job)
+	waitFinished(t, job)
+	assert.Equal(t, []int64{42}, getQueueRuns())
+
+	// A deleted scheduled job doesn't run
+	_, err = rcJobScheduleDelete(ctx, rc.Params{"id": item.ID})
+	require.NoError(t, err)
+	scheduler.run(item)
+	assert.Equal(t, []int64{42}, getQueueRuns())
+}
+
+func TestScheduleFile(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "schedule.db")
+
+	require.NoError(t, StartSchedule(path))
+	_, err := rcJobScheduleCreate(ctx, rc.Params{
+		"cron":   "@daily",
+		"path":   "test/queue",
+		"params": rc.Params{"n": 1},
+	})
+	require.NoError(t, err)
+	_, err = rcJobScheduleCreate(ctx, rc.Params{
+		"cron": "@weekly",
+		"path": "test/queue",
+	})
+	require.NoError(t, err)
+	_, err = rcJobScheduleDelete(ctx, rc.Params{"id": 1})
+	require.NoError(t, err)
+	assert.Error(t, StartSchedule(path))
+	require.NoError(t, stopSchedule())
+	assert.Nil(t, scheduleIDs(t))
+
+	// Restart and check the scheduled job is still there
+	require.NoError(t, StartSchedule(path))
+	defer func() { require.NoError(t, stopSchedule()) }()
+	out, err := rcJobScheduleList(ctx, nil)
+	require.NoError(t, err)
+	list := out["schedule"].([]rc.Params)
+	require.Equal(t, 1, len(list))
+	assert.Equal(t, int64(2), list[0]["id"])
+	assert.Equal(t, "@weekly", list[0]["cron"])
+	assert.Equal(t, "test/queue", list[0]["path"])
+
+	// New scheduled jobs don't reuse its ID
+	out, err = rcJobScheduleCreate(ctx, rc.Params{
+		"cron": "@daily",
+		"path": "test/queue",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), out["id"])
+}
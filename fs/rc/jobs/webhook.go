@@ -0,0 +1,106 @@
+// Notify a URL when a job finishes
+
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fs/fshttp"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// checkWebhook checks webhook is an http or https URL
+func checkWebhook(webhook string) error {
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return fmt.Errorf("bad webhook url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("webhook url must be http or https not %q", webhook)
+	}
+	return nil
+}
+
+// See if _webhook is set returning the URL to notify when the job
+// finishes which defaults to --rc-job-webhook
+func getWebhook(in rc.Params) (string, error) {
+	webhook, err := in.GetString("_webhook")
+	if rc.NotErrParamNotFound(err) {
+		return "", err
+	}
+	delete(in, "_webhook") // remove the parameter
+	if err != nil {
+		return running.opt.JobWebhook, nil
+	}
+	if webhook == "" {
+		// _webhook="" turns off the default
+		return "", nil
+	}
+	if err = checkWebhook(webhook); err != nil {
+		return "", rc.NewErrParamInvalid(err)
+	}
+	return webhook, nil
+}
+
+// notifyWebhook POSTs a JSON summary of the finished job to its
+// webhook if it has one
+func (job *Job) notifyWebhook(ctx context.Context) {
+	job.mu.Lock()
+	webhook := job.webhook
+	summary := rc.Params{
+		"id":        job.ID,
+		"group":     job.Group,
+		"status":    "finished",
+		"success":   job.Success,
+		"error":     job.Error,
+		"startTime": job.StartTime,
+		"endTime":   job.EndTime,
+		"duration":  job.Duration,
+	}
+	job.mu.Unlock()
+	if webhook == "" {
+		return
+	}
+	stats, err := accounting.StatsGroup(ctx, job.Group).RemoteStats()
+	if err == nil {
+		summary["stats"] = stats
+	}
+	err = postWebhook(ctx, webhook, summary)
+	if err != nil {
+		fs.Errorf(nil, "rc: failed to notify webhook for job %d: %v", job.ID, err)
+		return
+	}
+	fs.Debugf(nil, "rc: notified webhook for job %d", job.ID)
+}
+
+// postWebhook POSTs summary as JSON to webhook
+func postWebhook(ctx context.Context, webhook string, summary rc.Params) (err error) {
+	buf, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := fshttp.NewClient(ctx).Do(req)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(resp.Body, &err)
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("HTTP error %s", resp.Status)
+	}
+	return nil
+}
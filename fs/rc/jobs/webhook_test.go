@@ -0,0 +1,114 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookServer starts a server which sends the JSON POSTed to it
+// to the channel returned
+func webhookServer(t *testing.T) (*httptest.Server, chan rc.Params) {
+	received := make(chan rc.Params, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var summary rc.Params
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&summary))
+		received <- summary
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+// waitWebhook waits for a summary to be received
+func waitWebhook(t *testing.T, received chan rc.Params) rc.Params {
+	select {
+	case summary := <-received:
+		return summary
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not notified")
+	}
+	return nil
+}
+
+func TestGetWebhook(t *testing.T) {
+	defer func() { running.opt.JobWebhook = "" }()
+	for _, test := range []struct {
+		in         rc.Params
+		defaultURL string
+		want       string
+		wantErr    bool
+	}{
+		{in: rc.Params{}, want: ""},
+		{in: rc.Params{}, defaultURL: "http://example.com/default", want: "http://example.com/default"},
+		{in: rc.Params{"_webhook": "https://example.com/hook"}, want: "https://example.com/hook"},
+		{in: rc.Params{"_webhook": "https://example.com/hook"}, defaultURL: "http://example.com/default", want: "https://example.com/hook"},
+		{in: rc.Params{"_webhook": ""}, defaultURL: "http://example.com/default", want: ""},
+		{in: rc.Params{"_webhook": "ftp://example.com/hook"}, wantErr: true},
+		{in: rc.Params{"_webhook": ":potato"}, wantErr: true},
+		{in: rc.Params{"_webhook": 1}, wantErr: true},
+	} {
+		running.opt.JobWebhook = test.defaultURL
+		got, err := getWebhook(test.in)
+		if test.wantErr {
+			assert.Error(t, err, test.in)
+			continue
+		}
+		require.NoError(t, err, test.in)
+		assert.Equal(t, test.want, got, test.in)
+		_, found := test.in["_webhook"]
+		assert.False(t, found)
+	}
+}
+
+func TestJobWebhook(t *testing.T) {
+	ctx := context.Background()
+	server, received := webhookServer(t)
+	jobFn := func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		_, found := in["_webhook"]
+		assert.False(t, found)
+		return nil, nil
+	}
+	job, _, err := NewJob(ctx, jobFn, rc.Params{
+		"_async":   true,
+		"_group":   "webhookgroup",
+		"_webhook": server.URL,
+	})
+	require.NoError(t, err)
+	summary := waitWebhook(t, received)
+	assert.Equal(t, float64(job.ID), summary["id"])
+	assert.Equal(t, "webhookgroup", summary["group"])
+	assert.Equal(t, "finished", summary["status"])
+	assert.Equal(t, true, summary["success"])
+	assert.Equal(t, "", summary["error"])
+	assert.NotNil(t, summary["stats"])
+
+	// Errors are reported
+	failFn := func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		return nil, errors.New("job failed")
+	}
+	_, _, err = NewJob(ctx, failFn, rc.Params{
+		"_async":   true,
+		"_webhook": server.URL,
+	})
+	require.NoError(t, err)
+	summary = waitWebhook(t, received)
+	assert.Equal(t, false, summary["success"])
+	assert.Equal(t, "job failed", summary["error"])
+
+	// Bad URL is an error
+	_, _, err = NewJob(ctx, jobFn, rc.Params{
+		"_async":   true,
+		"_webhook": "potato",
+	})
+	assert.True(t, rc.IsErrParamInvalid(err))
+}
@@ -0,0 +1,141 @@
+// Generate an OpenAPI document from the registry
+
+package rc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+)
+
+func init() {
+	Add(Call{
+		Path:  "core/openapi",
+		Fn:    rcOpenAPI,
+		Title: "Returns an OpenAPI 3 description of the rc API",
+		Help: `
+This returns an OpenAPI 3 document describing all the registered
+remote control commands which can be used to generate clients for the
+API automatically.
+
+Each command is a POST to its path taking a JSON object. Commands
+which declare their parameters have them in the request schema, all
+accept extra parameters such as _async, _config and _filter.
+
+This can be fetched with "rclone rc --openapi".`,
+	})
+}
+
+// Return the OpenAPI document
+func rcOpenAPI(ctx context.Context, in Params) (out Params, err error) {
+	return OpenAPI(Calls.List()), nil
+}
+
+// OpenAPI returns an OpenAPI 3 document describing calls
+func OpenAPI(calls []*Call) Params {
+	paths := Params{}
+	for _, call := range calls {
+		paths["/"+call.Path] = Params{
+			"post": openAPIOperation(call),
+		}
+	}
+	return Params{
+		"openapi": "3.0.3",
+		"info": Params{
+			"title":       "rclone remote control",
+			"description": "The API of the rclone remote control. See https://rclone.org/rc/ for more info.",
+			"version":     fs.Version,
+		},
+		"paths": paths,
+		"components": Params{
+			"securitySchemes": Params{
+				"basicAuth": Params{
+					"type":   "http",
+					"scheme": "basic",
+				},
+				"bearerAuth": Params{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+			},
+			"schemas": Params{
+				"Error": Params{
+					"type": "object",
+					"properties": Params{
+						"error":  Params{"type": "string", "description": "the error message"},
+						"input":  Params{"type": "object", "description": "the input parameters of the call"},
+						"path":   Params{"type": "string", "description": "the path of the call"},
+						"status": Params{"type": "integer", "description": "the HTTP status code"},
+					},
+				},
+			},
+		},
+	}
+}
+
+// openAPIOperation returns the OpenAPI operation for call
+func openAPIOperation(call *Call) Params {
+	properties := Params{}
+	var required []string
+	for _, param := range call.Parameters {
+		property := Params{}
+		if param.Type != "" {
+			property["type"] = param.Type
+		}
+		if param.Help != "" {
+			property["description"] = param.Help
+		}
+		properties[param.Name] = property
+		if param.Required {
+			required = append(required, param.Name)
+		}
+	}
+	schema := Params{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": true,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	operation := Params{
+		"operationId": strings.Replace(call.Path, "/", "_", -1),
+		"summary":     call.Title,
+		"description": call.Help,
+		"tags":        []string{strings.SplitN(call.Path, "/", 2)[0]},
+		"requestBody": Params{
+			"required": len(required) > 0,
+			"content": Params{
+				"application/json": Params{
+					"schema": schema,
+				},
+			},
+		},
+		"responses": Params{
+			"200": Params{
+				"description": "the output of the call",
+				"content": Params{
+					"application/json": Params{
+						"schema": Params{"type": "object"},
+					},
+				},
+			},
+			"default": Params{
+				"description": "an error",
+				"content": Params{
+					"application/json": Params{
+						"schema": Params{"$ref": "#/components/schemas/Error"},
+					},
+				},
+			},
+		},
+	}
+	if call.AuthRequired {
+		operation["security"] = []Params{
+			{"basicAuth": []string{}},
+			{"bearerAuth": []string{}},
+		}
+	}
+	return operation
+}
@@ -0,0 +1,84 @@
+package rc
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPI(t *testing.T) {
+	calls := []*Call{{
+		Path:  "test/one",
+		Title: "Test one",
+		Help:  "Help for test one",
+	}, {
+		Path:         "test/two",
+		Title:        "Test two",
+		AuthRequired: true,
+		Parameters: []Parameter{
+			{Name: "fs", Type: "string", Help: "a remote", Required: true},
+			{Name: "opt", Type: "object"},
+		},
+	}}
+	doc := OpenAPI(calls)
+	assert.Equal(t, "3.0.3", doc["openapi"])
+
+	// Check it is valid JSON and reshape it for easy testing
+	var out struct {
+		Paths map[string]struct {
+			Post struct {
+				OperationID string                `json:"operationId"`
+				Summary     string                `json:"summary"`
+				Description string                `json:"description"`
+				Tags        []string              `json:"tags"`
+				Security    []map[string][]string `json:"security"`
+				RequestBody struct {
+					Required bool `json:"required"`
+					Content  map[string]struct {
+						Schema struct {
+							Properties map[string]map[string]string `json:"properties"`
+							Required   []string                     `json:"required"`
+						} `json:"schema"`
+					} `json:"content"`
+				} `json:"requestBody"`
+			} `json:"post"`
+		} `json:"paths"`
+	}
+	buf, err := json.Marshal(doc)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(buf, &out))
+	require.Equal(t, 2, len(out.Paths))
+
+	one := out.Paths["/test/one"].Post
+	assert.Equal(t, "test_one", one.OperationID)
+	assert.Equal(t, "Test one", one.Summary)
+	assert.Equal(t, "Help for test one", one.Description)
+	assert.Equal(t, []string{"test"}, one.Tags)
+	assert.Nil(t, one.Security)
+	assert.False(t, one.RequestBody.Required)
+	assert.Equal(t, 0, len(one.RequestBody.Content["application/json"].Schema.Properties))
+
+	two := out.Paths["/test/two"].Post
+	assert.Equal(t, 2, len(two.Security))
+	assert.True(t, two.RequestBody.Required)
+	schema := two.RequestBody.Content["application/json"].Schema
+	assert.Equal(t, map[string]map[string]string{
+		"fs":  {"type": "string", "description": "a remote"},
+		"opt": {"type": "object"},
+	}, schema.Properties)
+	assert.Equal(t, []string{"fs"}, schema.Required)
+}
+
+func TestCoreOpenAPI(t *testing.T) {
+	call := Calls.Get("core/openapi")
+	assert.NotNil(t, call)
+	out, err := call.Fn(context.Background(), nil)
+	require.NoError(t, err)
+	paths, ok := out["paths"].(Params)
+	require.True(t, ok)
+	assert.NotNil(t, paths["/core/openapi"])
+	assert.NotNil(t, paths["/rc/noop"])
+}
@@ -14,32 +14,65 @@ import (
 	"time"
 
 	"github.com/rclone/rclone/cmd/serve/httplib"
+	"github.com/rclone/rclone/fs"
 )
 
 // Options contains options for the remote control server
 type Options struct {
-	HTTPOptions              httplib.Options
-	Enabled                  bool   // set to enable the server
-	Serve                    bool   // set to serve files from remotes
-	Files                    string // set to enable serving files locally
-	NoAuth                   bool   // set to disable auth checks on AuthRequired methods
-	WebUI                    bool   // set to launch the web ui
-	WebGUIUpdate             bool   // set to check new update
-	WebGUIForceUpdate        bool   // set to force download new update
-	WebGUINoOpenBrowser      bool   // set to disable auto opening browser
-	WebGUIFetchURL           string // set the default url for fetching webgui
-	AccessControlAllowOrigin string // set the access control for CORS configuration
-	EnableMetrics            bool   // set to disable prometheus metrics on /metrics
-	JobExpireDuration        time.Duration
-	JobExpireInterval        time.Duration
+	HTTPOptions                   httplib.Options
+	Enabled                       bool    // set to enable the server
+	Serve                         bool    // set to serve files from remotes
+	Files                         string  // set to enable serving files locally
+	NoAuth                        bool    // set to disable auth checks on AuthRequired methods
+	WebUI                         bool    // set to launch the web ui
+	WebGUIUpdate                  bool    // set to check new update
+	WebGUIForceUpdate             bool    // set to force download new update
+	WebGUINoOpenBrowser           bool    // set to disable auto opening browser
+	WebGUIFetchURL                string  // set the default url for fetching webgui
+	AccessControlAllowOrigin      string  // set the access control for CORS configuration
+	AccessControlAllowMethods     string  // methods allowed for CORS requests
+	AccessControlAllowHeaders     string  // headers allowed for CORS requests
+	AccessControlAllowCredentials bool    // set to allow CORS requests with credentials
+	EnableMetrics                 bool    // set to disable prometheus metrics on /metrics
+	RateLimit                     float64 // max requests per second from each client, 0 for unlimited
+	RateBurst                     int     // max burst of requests from each client, 0 for ceil(RateLimit)
+	MaxConcurrent                 int     // max calls running at once, 0 for unlimited
+	JobExpireDuration             time.Duration
+	JobExpireInterval             time.Duration
+	JobQueueFile                  string        // file to keep the job queue in if set
+	JobQueueConcurrency           int           // number of queued jobs to run at once
+	JobScheduleFile               string        // file to keep the scheduled jobs in if set
+	JobHistoryFile                string        // file to keep the records of finished jobs in if set
+	JobHistoryMaxAge              time.Duration // forget finished jobs older than this, 0 to keep them
+	JobHistoryMaxJobs             int           // max number of finished jobs to keep, 0 for unlimited
+	JobWebhook                    string        // URL to notify when async jobs finish if set
+	GRPCAddr                      string        // address to serve the rc over gRPC on if set
+	AuditLog                      string        // file to log the rc calls to if set
+	AuditLogMaxSize               fs.SizeSuffix // rotate the audit log when it gets bigger than this
+	AuditLogMaxBackups            int           // number of old audit logs to keep
+	CommandDisable                bool          // set to disable core/command
+	CommandAllow                  string        // comma separated commands core/command may run if set
+	CommandDeny                   string        // comma separated commands core/command may not run
+	CommandAllowFlags             string        // comma separated flags core/command may use if set
+	CommandDenyFlags              string        // comma separated flags core/command may not use
+	NoCompression                 bool          // set to disable compressing the replies to rc calls
+	Tenants                       bool          // set to restrict each user to the remotes, jobs and mounts it owns
+	TenantAdmins                  string        // comma separated users which aren't restricted by Tenants
 }
 
 // DefaultOpt is the default values used for Options
 var DefaultOpt = Options{
-	HTTPOptions:       httplib.DefaultOpt,
-	Enabled:           false,
-	JobExpireDuration: 60 * time.Second,
-	JobExpireInterval: 10 * time.Second,
+	HTTPOptions:               httplib.DefaultOpt,
+	Enabled:                   false,
+	AccessControlAllowMethods: "POST, OPTIONS, GET, HEAD",
+	AccessControlAllowHeaders: "authorization, Content-Type",
+	JobExpireDuration:         60 * time.Second,
+	JobExpireInterval:         10 * time.Second,
+	JobQueueConcurrency:       1,
+	JobHistoryMaxAge:          30 * 24 * time.Hour,
+	JobHistoryMaxJobs:         10000,
+	AuditLogMaxSize:           100 * fs.Mebi,
+	AuditLogMaxBackups:        5,
 }
 
 func init() {
@@ -5,6 +5,7 @@ import (
 	"github.com/rclone/rclone/cmd/serve/httplib/httpflags"
 	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/lib/http/oidc"
 	"github.com/spf13/pflag"
 )
 
@@ -25,9 +26,35 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.BoolVarP(flagSet, &Opt.WebGUIForceUpdate, "rc-web-gui-force-update", "", false, "Force update to latest version of web gui")
 	flags.BoolVarP(flagSet, &Opt.WebGUINoOpenBrowser, "rc-web-gui-no-open-browser", "", false, "Don't open the browser automatically")
 	flags.StringVarP(flagSet, &Opt.WebGUIFetchURL, "rc-web-fetch-url", "", "https://api.github.com/repos/rclone/rclone-webui-react/releases/latest", "URL to fetch the releases for webgui")
-	flags.StringVarP(flagSet, &Opt.AccessControlAllowOrigin, "rc-allow-origin", "", "", "Set the allowed origin for CORS")
+	flags.StringVarP(flagSet, &Opt.AccessControlAllowOrigin, "rc-allow-origin", "", "", "Comma separated list of origins allowed for CORS")
+	flags.StringVarP(flagSet, &Opt.AccessControlAllowMethods, "rc-allow-methods", "", Opt.AccessControlAllowMethods, "Comma separated list of methods allowed for CORS")
+	flags.StringVarP(flagSet, &Opt.AccessControlAllowHeaders, "rc-allow-headers", "", Opt.AccessControlAllowHeaders, "Comma separated list of headers allowed for CORS")
+	flags.BoolVarP(flagSet, &Opt.AccessControlAllowCredentials, "rc-allow-credentials", "", false, "Allow CORS requests with credentials from allowed origins")
 	flags.BoolVarP(flagSet, &Opt.EnableMetrics, "rc-enable-metrics", "", false, "Enable prometheus metrics on /metrics")
+	flags.Float64VarP(flagSet, &Opt.RateLimit, "rc-rate-limit", "", 0, "Max requests per second from each client address (0 for unlimited)")
+	flags.IntVarP(flagSet, &Opt.RateBurst, "rc-rate-burst", "", 0, "Max burst of requests from each client address (0 for the rate limit rounded up)")
+	flags.IntVarP(flagSet, &Opt.MaxConcurrent, "rc-max-concurrent", "", 0, "Max number of rc calls and async jobs running at once (0 for unlimited)")
 	flags.DurationVarP(flagSet, &Opt.JobExpireDuration, "rc-job-expire-duration", "", Opt.JobExpireDuration, "Expire finished async jobs older than this value")
 	flags.DurationVarP(flagSet, &Opt.JobExpireInterval, "rc-job-expire-interval", "", Opt.JobExpireInterval, "Interval to check for expired async jobs")
+	flags.StringVarP(flagSet, &Opt.JobQueueFile, "rc-job-queue-file", "", "", "File to keep the queue of jobs in so they survive a restart")
+	flags.IntVarP(flagSet, &Opt.JobQueueConcurrency, "rc-job-queue-concurrency", "", Opt.JobQueueConcurrency, "Number of jobs from the queue to run at once")
+	flags.StringVarP(flagSet, &Opt.JobScheduleFile, "rc-job-schedule-file", "", "", "File to keep the scheduled jobs in so they survive a restart")
+	flags.StringVarP(flagSet, &Opt.JobHistoryFile, "rc-job-history-file", "", "", "File to keep the records of finished jobs in for job/history")
+	flags.DurationVarP(flagSet, &Opt.JobHistoryMaxAge, "rc-job-history-max-age", "", Opt.JobHistoryMaxAge, "Forget finished jobs older than this in the job history (0 to keep them)")
+	flags.IntVarP(flagSet, &Opt.JobHistoryMaxJobs, "rc-job-history-max-jobs", "", Opt.JobHistoryMaxJobs, "Max number of finished jobs to keep in the job history (0 for unlimited)")
+	flags.StringVarP(flagSet, &Opt.JobWebhook, "rc-job-webhook", "", "", "URL to POST a summary of each async job to when it finishes")
+	flags.StringVarP(flagSet, &Opt.GRPCAddr, "rc-grpc-addr", "", "", "IPaddress:Port or :Port to serve the remote control over gRPC on")
+	flags.StringVarP(flagSet, &Opt.AuditLog, "rc-audit-log", "", "", "File to log all rc calls to as JSON")
+	flags.FVarP(flagSet, &Opt.AuditLogMaxSize, "rc-audit-log-max-size", "", "Rotate the rc audit log when it is bigger than this")
+	flags.IntVarP(flagSet, &Opt.AuditLogMaxBackups, "rc-audit-log-max-backups", "", Opt.AuditLogMaxBackups, "Number of rotated rc audit logs to keep")
+	flags.BoolVarP(flagSet, &Opt.CommandDisable, "rc-command-disable", "", false, "Disable running commands with core/command")
+	flags.StringVarP(flagSet, &Opt.CommandAllow, "rc-command-allow", "", "", "Comma separated list of the only commands core/command may run")
+	flags.StringVarP(flagSet, &Opt.CommandDeny, "rc-command-deny", "", "", "Comma separated list of commands core/command may not run")
+	flags.StringVarP(flagSet, &Opt.CommandAllowFlags, "rc-command-allow-flags", "", "", "Comma separated list of the only flags core/command may use")
+	flags.StringVarP(flagSet, &Opt.CommandDenyFlags, "rc-command-deny-flags", "", "", "Comma separated list of flags core/command may not use")
+	flags.BoolVarP(flagSet, &Opt.NoCompression, "rc-no-compression", "", false, "Don't compress the replies to rc calls")
+	flags.BoolVarP(flagSet, &Opt.Tenants, "rc-tenants", "", false, "Restrict each rc user to its own remotes, jobs and mounts")
+	flags.StringVarP(flagSet, &Opt.TenantAdmins, "rc-tenant-admins", "", "", "Comma separated list of rc users not restricted by --rc-tenants")
 	httpflags.AddFlagsPrefix(flagSet, "rc-", &Opt.HTTPOptions)
+	oidc.AddFlagsPrefix(flagSet, "rc-", &Opt.HTTPOptions.OIDC)
 }
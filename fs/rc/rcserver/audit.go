@@ -0,0 +1,256 @@
+package rcserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/cmd/serve/httplib"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// maxAuditEntries is the number of recent audit entries kept in memory
+// for core/audit
+const maxAuditEntries = 1000
+
+// auditEntry is the record of one rc call in the audit log
+type auditEntry struct {
+	Time     time.Time `json:"time"`
+	User     string    `json:"user,omitempty"`  // user from the password or client certificate
+	Token    string    `json:"token,omitempty"` // name of the API token used
+	Remote   string    `json:"remote"`          // address of the client
+	Path     string    `json:"path"`
+	Params   rc.Params `json:"params,omitempty"` // input with secrets redacted
+	Status   int       `json:"status"`           // HTTP status of the reply
+	JobID    int64     `json:"jobid,omitempty"`
+	Duration float64   `json:"duration"` // seconds taken to reply
+}
+
+// auditLog records the rc calls to a file, rotating it when it gets
+// too big, and keeps the most recent in memory
+type auditLog struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64 // rotate the file when it gets bigger than this if > 0
+	maxBackups int   // number of old files to keep
+	file       *os.File
+	size       int64
+	recent     []auditEntry // most recent entries, oldest first
+}
+
+// currentAudit is the audit log of the running server for core/audit
+var currentAudit struct {
+	mu  sync.Mutex
+	log *auditLog
+}
+
+// newAuditLog opens the audit log at path appending to it if it exists
+func newAuditLog(path string, maxSize int64, maxBackups int) (*auditLog, error) {
+	a := &auditLog{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	err := a._open()
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// _open opens the log file for appending
+//
+// Call with a.mu held
+func (a *auditLog) _open() error {
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	a.file = f
+	a.size = fi.Size()
+	return nil
+}
+
+// _rotate renames the log file to path.1, path.1 to path.2 and so on
+// up to maxBackups, then opens a new one
+//
+// Call with a.mu held
+func (a *auditLog) _rotate() error {
+	err := a.file.Close()
+	a.file = nil
+	if err != nil {
+		return err
+	}
+	if a.maxBackups <= 0 {
+		err = os.Remove(a.path)
+	} else {
+		for i := a.maxBackups - 1; i >= 1; i-- {
+			oldPath := fmt.Sprintf("%s.%d", a.path, i)
+			if _, err := os.Stat(oldPath); err == nil {
+				err = os.Rename(oldPath, fmt.Sprintf("%s.%d", a.path, i+1))
+				if err != nil {
+					return err
+				}
+			}
+		}
+		err = os.Rename(a.path, a.path+".1")
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return a._open()
+}
+
+// add writes entry to the log and keeps it in memory
+func (a *auditLog) add(entry auditEntry) {
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		fs.Errorf(nil, "rc: failed to marshal audit entry: %v", err)
+		return
+	}
+	buf = append(buf, '\n')
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.recent) >= maxAuditEntries {
+		a.recent = append(a.recent[:0], a.recent[1:]...)
+	}
+	a.recent = append(a.recent, entry)
+	if a.file != nil && a.maxSize > 0 && a.size > 0 && a.size+int64(len(buf)) > a.maxSize {
+		err = a._rotate()
+		if err != nil {
+			fs.Errorf(nil, "rc: failed to rotate audit log: %v", err)
+		}
+	}
+	if a.file == nil {
+		err = a._open()
+		if err != nil {
+			fs.Errorf(nil, "rc: %v", err)
+			return
+		}
+	}
+	n, err := a.file.Write(buf)
+	a.size += int64(n)
+	if err != nil {
+		fs.Errorf(nil, "rc: failed to write audit log: %v", err)
+	}
+}
+
+// list returns up to n of the most recent entries, oldest first
+func (a *auditLog) list(n int) []auditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if n <= 0 || n > len(a.recent) {
+		n = len(a.recent)
+	}
+	return append([]auditEntry(nil), a.recent[len(a.recent)-n:]...)
+}
+
+// close closes the log file
+func (a *auditLog) close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Close()
+	a.file = nil
+	return err
+}
+
+// newAuditEntry makes an entry for the call to path with in from
+// the client described by ctx and remoteAddr
+func newAuditEntry(ctx context.Context, remoteAddr string, path string, in rc.Params) auditEntry {
+	entry := auditEntry{
+		Time:   time.Now(),
+		Remote: remoteAddr,
+		Path:   path,
+		Params: rc.Redact(in),
+	}
+	if user, ok := ctx.Value(httplib.ContextUserKey).(string); ok {
+		entry.User = user
+	}
+	if t, ok := ctx.Value(httplib.ContextAuthKey).(*apiToken); ok {
+		entry.Token = t.Name
+	}
+	return entry
+}
+
+// statusWriter records the status written to an http.ResponseWriter
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status and passes it on
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Flush passes on the Flush if the ResponseWriter supports it
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "core/audit",
+		AuthRequired: true,
+		Fn:           rcAudit,
+		Title:        "Returns the most recent entries in the audit log",
+		Parameters: []rc.Parameter{
+			{Name: "count", Type: "integer", Help: "number of entries to return"},
+		},
+		Help: `This needs --rc-audit-log to be set.
+
+Parameters:
+
+- count - number of entries to return (optional, default 100, max 1000)
+
+Returns:
+
+- entries - array of the most recent rc calls, oldest first, each with
+    - time - when the call was made
+    - user - user from the password or client certificate, if any
+    - token - name of the API token used, if any
+    - remote - address of the client
+    - path - the rc call
+    - params - the input parameters with secrets redacted
+    - status - HTTP status code of the reply
+    - jobid - id of the job, if one was started
+    - duration - seconds taken to reply
+`,
+	})
+}
+
+// Return the recent audit log entries
+func rcAudit(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	count, err := in.GetInt64("count")
+	if rc.IsErrParamNotFound(err) {
+		count = 100
+	} else if err != nil {
+		return nil, err
+	}
+	currentAudit.mu.Lock()
+	a := currentAudit.log
+	currentAudit.mu.Unlock()
+	if a == nil {
+		return nil, errors.New("audit log not enabled - use --rc-audit-log")
+	}
+	return rc.Params{
+		"entries": a.list(int(count)),
+	}, nil
+}
@@ -0,0 +1,111 @@
+package rcserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLogRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	a, err := newAuditLog(path, 200, 2)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, a.close())
+	}()
+
+	for i := 0; i < 10; i++ {
+		a.add(auditEntry{Path: "rc/noop", Status: http.StatusOK})
+	}
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		fi, err := os.Stat(name)
+		require.NoError(t, err, name)
+		assert.True(t, fi.Size() <= 200, name)
+	}
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, 10, len(a.list(0)))
+	assert.Equal(t, 3, len(a.list(3)))
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "audit.log")
+	opt := newTestOpt()
+	opt.Serve = false
+	opt.Files = ""
+	opt.HTTPOptions.ListenAddr = "localhost:0"
+	opt.HTTPOptions.BasicUser = "user"
+	opt.HTTPOptions.BasicPass = "pass"
+	opt.AuditLog = path
+	s := newServer(ctx, &opt, http.NewServeMux())
+	require.NoError(t, s.Serve())
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	// call path with params returning the status and output
+	call := func(path string, in rc.Params) (int, rc.Params) {
+		buf, err := json.Marshal(in)
+		require.NoError(t, err)
+		req, err := http.NewRequest("POST", s.URL()+path, strings.NewReader(string(buf)))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		req.SetBasicAuth("user", "pass")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		var out rc.Params
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	status, _ := call("rc/noop", rc.Params{"a": "potato", "password": "secret"})
+	require.Equal(t, http.StatusOK, status)
+	status, _ = call("rc/error", nil)
+	require.Equal(t, http.StatusInternalServerError, status)
+
+	status, out := call("core/audit", rc.Params{"count": 2})
+	require.Equal(t, http.StatusOK, status)
+	var entries []auditEntry
+	require.NoError(t, out.GetStruct("entries", &entries))
+	require.Equal(t, 2, len(entries))
+
+	noop := entries[0]
+	assert.Equal(t, "rc/noop", noop.Path)
+	assert.Equal(t, "user", noop.User)
+	assert.Equal(t, http.StatusOK, noop.Status)
+	assert.NotEqual(t, int64(0), noop.JobID)
+	assert.NotEqual(t, "", noop.Remote)
+	assert.Equal(t, rc.Params{"a": "potato", "password": rc.Redacted}, noop.Params)
+
+	assert.Equal(t, "rc/error", entries[1].Path)
+	assert.Equal(t, http.StatusInternalServerError, entries[1].Status)
+
+	// The calls are written to the file one JSON object per line
+	buf, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(buf), "secret")
+	scanner := bufio.NewScanner(strings.NewReader(string(buf)))
+	var paths []string
+	for scanner.Scan() {
+		var entry auditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		paths = append(paths, entry.Path)
+	}
+	assert.Equal(t, []string{"rc/noop", "rc/error", "core/audit"}, paths)
+}
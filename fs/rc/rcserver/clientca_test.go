@@ -0,0 +1,151 @@
+package rcserver
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCert is a certificate and its key
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// makeTestCert makes a certificate from template signed by parent or
+// self signed if parent is nil
+func makeTestCert(t *testing.T, template *x509.Certificate, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+// tlsCert returns c as a tls.Certificate
+func (c *testCert) tlsCert() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+// write writes the certificate and key as PEM files in dir
+func (c *testCert) write(t *testing.T, dir, name string) (certFile, keyFile string) {
+	certFile = filepath.Join(dir, name+".crt")
+	keyFile = filepath.Join(dir, name+".key")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0600))
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestClientCA(t *testing.T) {
+	dir := t.TempDir()
+	newCA := func(name string) *testCert {
+		return makeTestCert(t, &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: name},
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign,
+		}, nil)
+	}
+	newClient := func(ca *testCert, subject pkix.Name, dnsNames []string) *testCert {
+		return makeTestCert(t, &x509.Certificate{
+			SerialNumber: big.NewInt(3),
+			Subject:      subject,
+			DNSNames:     dnsNames,
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}, ca)
+	}
+	ca := newCA("test CA")
+	otherCA := newCA("other CA")
+	server := makeTestCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	caFile, _ := ca.write(t, dir, "ca")
+	certFile, keyFile := server.write(t, dir, "server")
+
+	opt := newTestOpt()
+	opt.Serve = false
+	opt.Files = ""
+	opt.HTTPOptions.ListenAddr = "127.0.0.1:0"
+	opt.HTTPOptions.SslCert = certFile
+	opt.HTTPOptions.SslKey = keyFile
+	opt.HTTPOptions.ClientCA = caFile
+	s := newServer(context.Background(), &opt, http.NewServeMux())
+	require.NoError(t, s.Serve())
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+	assert.True(t, s.UsingAuth())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	// call rc/noopauth presenting the client certificates given
+	call := func(certs ...*testCert) (int, error) {
+		tlsConfig := &tls.Config{RootCAs: roots}
+		for _, cert := range certs {
+			tlsConfig.Certificates = append(tlsConfig.Certificates, cert.tlsCert())
+		}
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Post(s.URL()+"rc/noopauth", "application/json", strings.NewReader("{}"))
+		if err != nil {
+			return 0, err
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	// A certificate with a CN counts as authentication
+	status, err := call(newClient(ca, pkix.Name{CommonName: "alice"}, nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	// So does one identified by its SAN
+	status, err = call(newClient(ca, pkix.Name{}, []string{"bob.example.com"}))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	// But not one without any names
+	status, err = call(newClient(ca, pkix.Name{}, nil))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// No certificate or one signed by another CA is refused
+	_, err = call()
+	assert.Error(t, err)
+	_, err = call(newClient(otherCA, pkix.Name{CommonName: "mallory"}, nil))
+	assert.Error(t, err)
+}
@@ -0,0 +1,198 @@
+package rcserver
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/rclone/rclone/fs"
+)
+
+// compressMinSize is the size of the smallest response which is
+// compressed - smaller ones aren't worth it
+const compressMinSize = 1024
+
+// compressEncodings are the supported Content-Encodings in order of
+// preference
+var compressEncodings = []string{"zstd", "gzip"}
+
+// negotiateEncoding returns the best Content-Encoding which can be
+// used with the Accept-Encoding header passed in or "" if none
+func negotiateEncoding(acceptEncoding string) string {
+	best, bestQ := "", 0.0
+	q := map[string]float64{}
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(item, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if name == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				var err error
+				quality, err = strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					quality = 0
+				}
+			}
+		}
+		q[name] = quality
+	}
+	for _, encoding := range compressEncodings {
+		quality, found := q[encoding]
+		if !found {
+			quality, found = q["*"]
+		}
+		if found && quality > bestQ {
+			best, bestQ = encoding, quality
+		}
+	}
+	return best
+}
+
+// compressWriter compresses the body written to it with encoding if
+// it is big enough
+type compressWriter struct {
+	http.ResponseWriter
+	encoding    string
+	status      int            // status to send with the header
+	buf         []byte         // start of the body held back until it is big enough to compress
+	enc         io.WriteCloser // compressor once compression has started
+	wroteHeader bool           // set once the header has been sent
+	passThrough bool           // set if the body isn't being compressed
+}
+
+// newCompressWriter returns w wrapped to compress the response to r
+// if the client accepts a supported encoding and a function which
+// must be called when the response is complete.
+func newCompressWriter(w http.ResponseWriter, r *http.Request) (http.ResponseWriter, func()) {
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return w, func() {}
+	}
+	w.Header().Add("Vary", "Accept-Encoding")
+	cw := &compressWriter{
+		ResponseWriter: w,
+		encoding:       encoding,
+		status:         http.StatusOK,
+	}
+	return cw, cw.close
+}
+
+// WriteHeader saves the status until it is known whether the body
+// is going to be compressed
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.status = status
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		cw.passThrough = true
+		cw.writeHeader()
+	}
+}
+
+// writeHeader sends the header
+func (cw *compressWriter) writeHeader() {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		cw.ResponseWriter.WriteHeader(cw.status)
+	}
+}
+
+// Write the body compressing it if required
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	switch {
+	case cw.passThrough:
+		cw.writeHeader()
+		return cw.ResponseWriter.Write(p)
+	case cw.enc != nil:
+		return cw.enc.Write(p)
+	}
+	cw.buf = append(cw.buf, p...)
+	if len(cw.buf) >= compressMinSize {
+		err := cw.startCompression()
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// startCompression sends the header and starts compressing the body
+func (cw *compressWriter) startCompression() (err error) {
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		// already encoded
+		cw.passThrough = true
+	} else {
+		var enc io.WriteCloser
+		switch cw.encoding {
+		case "zstd":
+			enc, err = zstd.NewWriter(cw.ResponseWriter, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+		case "gzip":
+			enc, err = gzip.NewWriterLevel(cw.ResponseWriter, gzip.BestSpeed)
+		}
+		if err != nil {
+			return err
+		}
+		cw.enc = enc
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+	}
+	cw.writeHeader()
+	buf := cw.buf
+	cw.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if cw.passThrough {
+		_, err = cw.ResponseWriter.Write(buf)
+	} else {
+		_, err = cw.enc.Write(buf)
+	}
+	return err
+}
+
+// Flush sends what has been written so far to the client compressing
+// it if required
+func (cw *compressWriter) Flush() {
+	if !cw.passThrough && cw.enc == nil {
+		err := cw.startCompression()
+		if err != nil {
+			fs.Errorf(nil, "rc: failed to compress response: %v", err)
+			return
+		}
+	}
+	if flusher, ok := cw.enc.(interface{ Flush() error }); ok {
+		err := flusher.Flush()
+		if err != nil {
+			fs.Errorf(nil, "rc: failed to flush compressed response: %v", err)
+		}
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// close finishes the response sending small ones uncompressed
+func (cw *compressWriter) close() {
+	var err error
+	if cw.enc != nil {
+		err = cw.enc.Close()
+	} else {
+		cw.passThrough = true
+		cw.writeHeader()
+		if len(cw.buf) > 0 {
+			_, err = cw.ResponseWriter.Write(cw.buf)
+		}
+	}
+	if err != nil {
+		fs.Errorf(nil, "rc: failed to finish compressed response: %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package rcserver
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"gzip, deflate, br", "gzip"},
+		{"zstd", "zstd"},
+		{"gzip, zstd", "zstd"},
+		{"GZIP", "gzip"},
+		{"gzip;q=1.0, zstd;q=0.5", "gzip"},
+		{"gzip;q=0, zstd;q=0", ""},
+		{"zstd;q=potato, gzip", "gzip"},
+		{"*", "zstd"},
+		{"*;q=0.5, gzip", "gzip"},
+		{"*, zstd;q=0", "gzip"},
+	} {
+		assert.Equal(t, test.want, negotiateEncoding(test.in), test.in)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	big := strings.Repeat("potato", 1000)
+	call := func(t *testing.T, opt *rc.Options, acceptEncoding, value string) (*http.Response, rc.Params) {
+		s := newServer(context.Background(), opt, http.NewServeMux())
+		body := `{"value": "` + value + `"}`
+		req := httptest.NewRequest("POST", "http://1.2.3.4/rc/noop", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", acceptEncoding)
+		}
+		w := httptest.NewRecorder()
+		s.handler(w, req)
+		resp := w.Result()
+		var in io.Reader = resp.Body
+		switch resp.Header.Get("Content-Encoding") {
+		case "gzip":
+			gz, err := gzip.NewReader(in)
+			require.NoError(t, err)
+			in = gz
+		case "zstd":
+			zr, err := zstd.NewReader(in)
+			require.NoError(t, err)
+			defer zr.Close()
+			in = zr
+		}
+		data, err := ioutil.ReadAll(in)
+		require.NoError(t, err)
+		var out rc.Params
+		require.NoError(t, json.Unmarshal(data, &out))
+		return resp, out
+	}
+	opt := newTestOpt()
+	for _, test := range []struct {
+		name           string
+		acceptEncoding string
+		value          string
+		noCompression  bool
+		want           string
+	}{
+		{name: "none", value: big, want: ""},
+		{name: "gzip", acceptEncoding: "gzip", value: big, want: "gzip"},
+		{name: "zstd", acceptEncoding: "gzip, zstd", value: big, want: "zstd"},
+		{name: "small", acceptEncoding: "gzip", value: "small", want: ""},
+		{name: "disabled", acceptEncoding: "gzip", value: big, noCompression: true, want: ""},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opt.NoCompression = test.noCompression
+			resp, out := call(t, &opt, test.acceptEncoding, test.value)
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, test.want, resp.Header.Get("Content-Encoding"))
+			assert.Equal(t, test.value, out["value"])
+		})
+	}
+}
+
+func TestH2C(t *testing.T) {
+	opt := newTestOpt()
+	opt.HTTPOptions.ListenAddr = testBindAddress
+	s := newServer(context.Background(), &opt, http.NewServeMux())
+	require.NoError(t, s.Serve())
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	// Talk HTTP/2 without TLS
+	client := &http.Client{
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+				return net.Dial(network, addr)
+			},
+		},
+	}
+	resp, err := client.Post(s.URL()+"rc/noop", "application/json", strings.NewReader(`{"a": "b"}`))
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 2, resp.ProtoMajor)
+	var out rc.Params
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.Equal(t, rc.Params{"a": "b"}, out)
+
+	// HTTP/1.1 still works
+	resp2, err := http.Post(s.URL()+"rc/noop", "application/json", strings.NewReader(`{}`))
+	require.NoError(t, err)
+	assert.Equal(t, 1, resp2.ProtoMajor)
+	require.NoError(t, resp2.Body.Close())
+}
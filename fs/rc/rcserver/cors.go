@@ -0,0 +1,81 @@
+package rcserver
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// splitList splits a comma separated list, trimming space and
+// removing empty items
+func splitList(list string) (items []string) {
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// corsOrigins returns the origins allowed by --rc-allow-origin
+func (s *Server) corsOrigins() []string {
+	s.corsOnce.Do(func() {
+		s.allowOrigins = splitList(s.opt.AccessControlAllowOrigin)
+		for _, origin := range s.allowOrigins {
+			if origin == "*" {
+				fs.Logf(nil, "Warning: Allow origin set to *. This can cause serious security problems.")
+			}
+		}
+	})
+	return s.allowOrigins
+}
+
+// originAllowed returns true if origin is in the --rc-allow-origin
+// list or that is "*"
+func (s *Server) originAllowed(origin string) bool {
+	for _, allowed := range s.corsOrigins() {
+		if allowed == "*" || strings.EqualFold(strings.TrimRight(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// setCORSHeaders sets the CORS headers for the response to r. It
+// returns false if r is a preflight request from an origin which
+// isn't allowed in which case it has already been answered.
+func (s *Server) setCORSHeaders(w http.ResponseWriter, r *http.Request) bool {
+	origins := s.corsOrigins()
+	header := w.Header()
+	origin := r.Header.Get("Origin")
+	switch {
+	case len(origins) == 0:
+		// Only allow pages served by the rc itself
+		header.Add("Access-Control-Allow-Origin", s.URL())
+	case len(origins) == 1 && origins[0] == "*" && !s.opt.AccessControlAllowCredentials:
+		header.Add("Access-Control-Allow-Origin", "*")
+	case origin != "" && s.originAllowed(origin):
+		// Echo the origin back as the header can only hold one
+		header.Add("Access-Control-Allow-Origin", origin)
+		header.Add("Vary", "Origin")
+	default:
+		header.Add("Vary", "Origin")
+		if r.Method == "OPTIONS" && origin != "" && r.Header.Get("Access-Control-Request-Method") != "" {
+			fs.Debugf(nil, "rc: CORS preflight from disallowed origin %q", origin)
+			w.WriteHeader(http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+
+	// echo back access control headers client needs
+	header.Add("Access-Control-Request-Method", s.opt.AccessControlAllowMethods)
+	header.Add("Access-Control-Allow-Methods", s.opt.AccessControlAllowMethods)
+	header.Add("Access-Control-Allow-Headers", s.opt.AccessControlAllowHeaders)
+	if s.opt.AccessControlAllowCredentials {
+		header.Add("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package rcserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	for _, test := range []struct {
+		name        string
+		allow       string
+		credentials bool
+		method      string
+		origin      string
+		status      int
+		allowOrigin string
+	}{
+		{
+			name:        "default",
+			method:      "OPTIONS",
+			origin:      "http://example.com",
+			status:      http.StatusOK,
+			allowOrigin: "http://localhost:5572/",
+		}, {
+			name:        "star",
+			allow:       "*",
+			method:      "POST",
+			origin:      "http://example.com",
+			status:      http.StatusOK,
+			allowOrigin: "*",
+		}, {
+			name:        "star-credentials",
+			allow:       "*",
+			credentials: true,
+			method:      "POST",
+			origin:      "http://example.com",
+			status:      http.StatusOK,
+			allowOrigin: "http://example.com",
+		}, {
+			name:        "allowed",
+			allow:       "http://one.example.com, http://two.example.com/",
+			method:      "OPTIONS",
+			origin:      "http://two.example.com",
+			status:      http.StatusOK,
+			allowOrigin: "http://two.example.com",
+		}, {
+			name:        "not-allowed-preflight",
+			allow:       "http://one.example.com,http://two.example.com",
+			method:      "OPTIONS",
+			origin:      "http://evil.example.com",
+			status:      http.StatusForbidden,
+			allowOrigin: "",
+		}, {
+			name:        "not-allowed",
+			allow:       "http://one.example.com",
+			method:      "POST",
+			origin:      "http://evil.example.com",
+			status:      http.StatusOK,
+			allowOrigin: "",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opt := newTestOpt()
+			opt.Serve = false
+			opt.Files = ""
+			opt.AccessControlAllowOrigin = test.allow
+			opt.AccessControlAllowCredentials = test.credentials
+			s := newServer(context.Background(), &opt, http.NewServeMux())
+
+			req := httptest.NewRequest(test.method, "http://localhost:5572/rc/noop", nil)
+			req.Header.Set("Origin", test.origin)
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			w := httptest.NewRecorder()
+			s.handler(w, req)
+			resp := w.Result()
+
+			assert.Equal(t, test.status, resp.StatusCode)
+			assert.Equal(t, test.allowOrigin, resp.Header.Get("Access-Control-Allow-Origin"))
+			if test.allowOrigin == "" {
+				assert.Equal(t, "", resp.Header.Get("Access-Control-Allow-Methods"))
+				return
+			}
+			assert.Equal(t, "POST, OPTIONS, GET, HEAD", resp.Header.Get("Access-Control-Allow-Methods"))
+			assert.Equal(t, "authorization, Content-Type", resp.Header.Get("Access-Control-Allow-Headers"))
+			if test.credentials {
+				assert.Equal(t, "true", resp.Header.Get("Access-Control-Allow-Credentials"))
+			} else {
+				assert.Equal(t, "", resp.Header.Get("Access-Control-Allow-Credentials"))
+			}
+		})
+	}
+}
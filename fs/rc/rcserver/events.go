@@ -0,0 +1,103 @@
+package rcserver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc/events"
+	"golang.org/x/net/websocket"
+)
+
+// eventsWaitTime is how long to wait for events before checking the
+// websocket is still alive
+const eventsWaitTime = time.Minute
+
+// serveEvents streams events to a websocket at /events
+//
+// The events can be filtered with the "types" and "level" query
+// parameters as used by core/subscribe.
+func (s *Server) serveEvents(w http.ResponseWriter, r *http.Request) {
+	if !s.opt.NoAuth && !s.UsingAuth() {
+		writeError("events", nil, w, errors.New("authentication must be set up on the rc server to use /events or the --rc-no-auth flag must be in use"), http.StatusForbidden)
+		return
+	}
+	query := r.URL.Query()
+	filter, err := events.NewFilter(query.Get("types"), query.Get("level"))
+	if err != nil {
+		writeError("events", nil, w, err, http.StatusBadRequest)
+		return
+	}
+	server := websocket.Server{
+		Handshake: s.checkEventsOrigin,
+		Handler: func(ws *websocket.Conn) {
+			s.streamEvents(ws, filter)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// checkEventsOrigin stops web pages from other sites reading the
+// events unless allowed by --rc-allow-origin. Websockets aren't
+// subject to the browser's cross origin checks.
+func (s *Server) checkEventsOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Not from a browser
+		return nil
+	}
+	if s.originAllowed(origin) {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err == nil && u.Host == r.Host {
+		return nil
+	}
+	return errors.New("origin not allowed")
+}
+
+// streamEvents sends events matching filter to ws until it is closed
+func (s *Server) streamEvents(ws *websocket.Conn, filter *events.Filter) {
+	defer func() {
+		_ = ws.Close()
+	}()
+	stop := events.Listen()
+	defer stop()
+	seq := events.Seq()
+
+	// Read from the websocket to notice when it is closed
+	ctx, cancel := context.WithCancel(ws.Request().Context())
+	defer cancel()
+	go func() {
+		var msg []byte
+		for {
+			err := websocket.Message.Receive(ws, &msg)
+			if err != nil {
+				break
+			}
+		}
+		cancel()
+	}()
+
+	for ctx.Err() == nil {
+		evs, last, lost := events.Wait(ctx, seq, eventsWaitTime, filter)
+		seq = last
+		if lost {
+			err := websocket.JSON.Send(ws, events.Event{Type: "lost"})
+			if err != nil {
+				fs.Debugf(nil, "rc: /events: failed to send: %v", err)
+				return
+			}
+		}
+		for _, ev := range evs {
+			err := websocket.JSON.Send(ws, ev)
+			if err != nil {
+				fs.Debugf(nil, "rc: /events: failed to send: %v", err)
+				return
+			}
+		}
+	}
+}
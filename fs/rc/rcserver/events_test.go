@@ -0,0 +1,79 @@
+package rcserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/rc/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func TestEventsAuthRequired(t *testing.T) {
+	tests := []testRun{{
+		Name:     "events",
+		URL:      "events",
+		Status:   http.StatusForbidden,
+		Contains: regexp.MustCompile(`authentication must be set up`),
+	}}
+	opt := newTestOpt()
+	opt.Serve = false
+	opt.Files = ""
+	opt.NoAuth = false
+	testServer(t, tests, &opt)
+}
+
+func TestEvents(t *testing.T) {
+	opt := newTestOpt()
+	opt.Serve = false
+	opt.Files = ""
+	opt.NoAuth = true
+	rcServer := newServer(context.Background(), &opt, http.NewServeMux())
+	server := httptest.NewServer(http.HandlerFunc(rcServer.handler))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/events?types=mount"
+
+	// Other origins aren't allowed
+	_, err := websocket.Dial(wsURL, "", "http://example.com/")
+	assert.Error(t, err)
+
+	// Bad parameters
+	resp, err := http.Get(server.URL + "/events?types=potato")
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	ws, err := websocket.Dial(wsURL, "", server.URL+"/")
+	require.NoError(t, err)
+	defer func() {
+		_ = ws.Close()
+	}()
+
+	// Keep publishing until the stream has started
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			events.Publish(events.TypeJob, rc.Params{"id": 1})
+			events.Publish(events.TypeMount, rc.Params{"status": "mounted"})
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}()
+
+	var ev events.Event
+	require.NoError(t, ws.SetDeadline(time.Now().Add(10*time.Second)))
+	require.NoError(t, websocket.JSON.Receive(ws, &ev))
+	assert.Equal(t, events.TypeMount, ev.Type)
+	assert.Equal(t, rc.Params{"status": "mounted"}, ev.Data)
+}
@@ -0,0 +1,349 @@
+package rcserver
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/rclone/rclone/cmd/serve/httplib"
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/rc/jobs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// grpcService is implemented by the Server to serve the rc over gRPC.
+//
+// The service is described in rc.proto. All the messages are
+// google.protobuf.Struct so the input and output of any rc call can
+// be passed through as they would be as JSON.
+type grpcService interface {
+	grpcCall(ctx context.Context, req *structpb.Struct) (*structpb.Struct, error)
+	grpcJobProgress(req *structpb.Struct, stream grpc.ServerStream) error
+}
+
+// grpcServiceDesc describes the rclone.rc.RC service in rc.proto
+var grpcServiceDesc = grpc.ServiceDesc{
+	ServiceName: "rclone.rc.RC",
+	HandlerType: (*grpcService)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Call",
+		Handler:    grpcCallHandler,
+	}},
+	Streams: []grpc.StreamDesc{{
+		StreamName:    "JobProgress",
+		Handler:       grpcJobProgressHandler,
+		ServerStreams: true,
+	}},
+	Metadata: "rc.proto",
+}
+
+func grpcCallHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := new(structpb.Struct)
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	service := srv.(grpcService)
+	if interceptor == nil {
+		return service.grpcCall(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rclone.rc.RC/Call",
+	}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return service.grpcCall(ctx, req.(*structpb.Struct))
+	})
+}
+
+func grpcJobProgressHandler(srv interface{}, stream grpc.ServerStream) error {
+	req := new(structpb.Struct)
+	if err := stream.RecvMsg(req); err != nil {
+		return err
+	}
+	return srv.(grpcService).grpcJobProgress(req, stream)
+}
+
+// grpcTLSConfig makes the TLS config for the gRPC server from the
+// --rc-cert, --rc-key and --rc-client-ca flags or returns nil if they
+// aren't set
+func (s *Server) grpcTLSConfig() (*tls.Config, error) {
+	opt := &s.opt.HTTPOptions
+	if opt.SslCert == "" && opt.SslKey == "" {
+		if opt.ClientCA != "" {
+			return nil, errors.New("can't use --rc-client-ca without --rc-cert and --rc-key")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(opt.SslCert, opt.SslKey)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+	if opt.ClientCA != "" {
+		pem, err := ioutil.ReadFile(opt.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client certificate authority: %w", err)
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("can't parse client certificate authority")
+		}
+		tlsConfig.ClientCAs = certPool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// serveGRPC starts serving the rc over gRPC on --rc-grpc-addr in the
+// background
+func (s *Server) serveGRPC() error {
+	tlsConfig, err := s.grpcTLSConfig()
+	if err != nil {
+		return fmt.Errorf("gRPC server: %w", err)
+	}
+	var options []grpc.ServerOption
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	s.grpcListener, err = net.Listen("tcp", s.opt.GRPCAddr)
+	if err != nil {
+		return fmt.Errorf("gRPC server: %w", err)
+	}
+	s.grpcServer = grpc.NewServer(options...)
+	s.grpcServer.RegisterService(&grpcServiceDesc, s)
+	go func() {
+		err := s.grpcServer.Serve(s.grpcListener)
+		if err != nil {
+			fs.Errorf(nil, "Error serving remote control over gRPC: %v", err)
+		}
+	}()
+	fs.Logf(nil, "Serving remote control over gRPC on %s", s.grpcListener.Addr())
+	return nil
+}
+
+// grpcStatus converts the rc HTTP status of an error into a gRPC status
+func grpcStatus(httpStatus int, err error) error {
+	code := codes.Internal
+	switch httpStatus {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusUnauthorized:
+		code = codes.Unauthenticated
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusTooManyRequests:
+		code = codes.ResourceExhausted
+	}
+	return status.Error(code, err.Error())
+}
+
+// grpcHTTPStatus converts the gRPC status of err back into the HTTP
+// status the rc would have replied with
+func grpcHTTPStatus(err error) int {
+	switch status.Code(err) {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+// grpcError logs err from the call at path and returns it as a gRPC
+// status with the code from the HTTP status rc.Error would use
+func grpcError(path string, in rc.Params, err error, httpStatus int) error {
+	fs.Errorf(nil, "rc: %q: gRPC error: %v", path, err)
+	_, httpStatus = rc.Error(path, in, err, httpStatus)
+	return grpcStatus(httpStatus, err)
+}
+
+// grpcAuth checks the credentials in the "authorization" metadata of
+// ctx the same way as the Authorization header of HTTP requests, and
+// the scope and rate limits of any API token used, for a call to path
+func (s *Server) grpcAuth(ctx context.Context, path string) (context.Context, error) {
+	r, err := http.NewRequestWithContext(ctx, "POST", "/"+path, nil)
+	if err != nil {
+		return nil, grpcError(path, nil, err, http.StatusInternalServerError)
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		r.RemoteAddr = p.Addr.String()
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, value := range md.Get("authorization") {
+			r.Header.Set("Authorization", value)
+		}
+	}
+	r, ok := s.CheckAuth(r)
+	if !ok {
+		return nil, grpcError(path, nil, errors.New("unauthorized"), http.StatusUnauthorized)
+	}
+	t, _ := r.Context().Value(httplib.ContextAuthKey).(*apiToken)
+	if t != nil && !scopeAllows(t.Scope, path) {
+		return nil, grpcError(path, nil, fmt.Errorf("token %q with scope %q may not use %q", t.Name, t.Scope, path), http.StatusForbidden)
+	}
+	if _, err := s.rateLimitDelay(r.RemoteAddr, t); err != nil {
+		return nil, grpcError(path, nil, err, http.StatusTooManyRequests)
+	}
+	ctx, err = s.tenantContext(r.Context(), "POST", path)
+	if err != nil {
+		return nil, grpcError(path, nil, err, http.StatusForbidden)
+	}
+	return ctx, nil
+}
+
+// toStruct converts rc.Params into a protobuf Struct via JSON so it
+// is the same as the output of the HTTP rc
+func toStruct(out rc.Params) (*structpb.Struct, error) {
+	buf, err := json.Marshal(out)
+	if err != nil {
+		return nil, err
+	}
+	result := new(structpb.Struct)
+	err = result.UnmarshalJSON(buf)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// grpcCall runs the rc call in the "_path" field of req with the
+// other fields as its parameters
+func (s *Server) grpcCall(ctx context.Context, req *structpb.Struct) (result *structpb.Struct, err error) {
+	in := rc.Params(req.AsMap())
+	path, _ := in.GetString("_path")
+	delete(in, "_path")
+	call := rc.Calls.Get(path)
+	if call == nil {
+		return nil, grpcError(path, in, fmt.Errorf("couldn't find method %q", path), http.StatusNotFound)
+	}
+	if call.NeedsRequest || call.NeedsResponse {
+		return nil, status.Errorf(codes.Unimplemented, "%q can't be called over gRPC", path)
+	}
+	ctx, err = s.grpcAuth(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	var jobID int64
+	start := time.Now()
+	defer func() {
+		rcMetrics.observe(path, grpcHTTPStatus(err), time.Since(start))
+	}()
+	if s.audit != nil {
+		remoteAddr := ""
+		if p, ok := peer.FromContext(ctx); ok {
+			remoteAddr = p.Addr.String()
+		}
+		entry := newAuditEntry(ctx, remoteAddr, path, in)
+		defer func() {
+			entry.Status = grpcHTTPStatus(err)
+			entry.JobID = jobID
+			entry.Duration = time.Since(entry.Time).Seconds()
+			s.audit.add(entry)
+		}()
+	}
+	if !s.opt.NoAuth && call.AuthRequired && !s.UsingAuth() {
+		return nil, grpcError(path, in, fmt.Errorf("authentication must be set up on the rc server to use %q or the --rc-no-auth flag must be in use", path), http.StatusForbidden)
+	}
+
+	inOrig := in.Copy()
+	job, out, err := s.runCall(ctx, path, call, in)
+	if job != nil {
+		_ = grpc.SetHeader(ctx, metadata.Pairs("x-rclone-jobid", fmt.Sprint(job.ID)))
+		jobID = job.ID
+	}
+	if errors.Is(err, errTooManyCalls) {
+		return nil, grpcError(path, inOrig, err, http.StatusTooManyRequests)
+	}
+	if err != nil {
+		return nil, grpcError(path, inOrig, err, http.StatusInternalServerError)
+	}
+	fs.Debugf(nil, "rc: %q: gRPC reply %+v", path, out)
+	result, err = toStruct(out)
+	if err != nil {
+		return nil, grpcError(path, inOrig, err, http.StatusInternalServerError)
+	}
+	return result, nil
+}
+
+// grpcJobProgress streams the progress of the job in the "jobid"
+// field of req every "interval" until it finishes, like
+// serveJobProgress
+func (s *Server) grpcJobProgress(req *structpb.Struct, stream grpc.ServerStream) error {
+	const path = "job/progress"
+	ctx, err := s.grpcAuth(stream.Context(), path)
+	if err != nil {
+		return err
+	}
+	in := rc.Params(req.AsMap())
+	jobID, err := in.GetInt64("jobid")
+	if err != nil {
+		return grpcError(path, in, err, http.StatusBadRequest)
+	}
+	interval, err := in.GetDuration("interval")
+	if rc.NotErrParamNotFound(err) {
+		return grpcError(path, in, err, http.StatusBadRequest)
+	}
+	if rc.IsErrParamNotFound(err) {
+		interval = time.Second
+	} else if interval < minProgressInterval {
+		interval = minProgressInterval
+	}
+	out, finished, err := jobs.Progress(ctx, jobID)
+	if err != nil {
+		return grpcError(path, in, err, http.StatusNotFound)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		result, err := toStruct(out)
+		if err != nil {
+			return grpcError(path, in, err, http.StatusInternalServerError)
+		}
+		err = stream.SendMsg(result)
+		if err != nil {
+			fs.Debugf(nil, "rc: %q: failed to send progress: %v", path, err)
+			return err
+		}
+		if finished {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		out, finished, err = jobs.Progress(ctx, jobID)
+		if err != nil {
+			// The job has expired
+			fs.Debugf(nil, "rc: %q: %v", path, err)
+			return nil
+		}
+	}
+}
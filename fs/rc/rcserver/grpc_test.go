@@ -0,0 +1,102 @@
+package rcserver
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestGRPC(t *testing.T) {
+	ctx := context.Background()
+	opt := newTestOpt()
+	opt.Serve = false
+	opt.Files = ""
+	opt.HTTPOptions.ListenAddr = "localhost:0"
+	opt.HTTPOptions.BasicUser = "user"
+	opt.HTTPOptions.BasicPass = "pass"
+	opt.GRPCAddr = "localhost:0"
+	s := newServer(ctx, &opt, http.NewServeMux())
+	require.NoError(t, s.Serve())
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	conn, err := grpc.Dial(s.grpcListener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte("user:pass")))
+
+	// call the rc with in returning the output and the gRPC code
+	call := func(ctx context.Context, in map[string]interface{}, opts ...grpc.CallOption) (map[string]interface{}, codes.Code) {
+		req, err := structpb.NewStruct(in)
+		require.NoError(t, err)
+		out := new(structpb.Struct)
+		err = conn.Invoke(ctx, "/rclone.rc.RC/Call", req, out, opts...)
+		return out.AsMap(), status.Code(err)
+	}
+
+	_, code := call(ctx, map[string]interface{}{"_path": "rc/noop"})
+	assert.Equal(t, codes.Unauthenticated, code)
+
+	var header metadata.MD
+	out, code := call(authCtx, map[string]interface{}{"_path": "rc/noop", "a": "potato", "b": 1.5}, grpc.Header(&header))
+	require.Equal(t, codes.OK, code)
+	assert.Equal(t, map[string]interface{}{"a": "potato", "b": 1.5}, out)
+	assert.Equal(t, 1, len(header.Get("x-rclone-jobid")))
+
+	_, code = call(authCtx, map[string]interface{}{"_path": "rc/potato"})
+	assert.Equal(t, codes.NotFound, code)
+	_, code = call(authCtx, map[string]interface{}{"_path": "rc/error"})
+	assert.Equal(t, codes.Internal, code)
+	_, code = call(authCtx, map[string]interface{}{"_path": "rc/noop", "_async": "potato"})
+	assert.Equal(t, codes.InvalidArgument, code)
+
+	// Stream the progress of a job until it finishes
+	out, code = call(authCtx, map[string]interface{}{"_path": "rc/noop", "_async": true})
+	require.Equal(t, codes.OK, code)
+	jobID := out["jobid"]
+	stream, err := conn.NewStream(authCtx, &grpc.StreamDesc{ServerStreams: true}, "/rclone.rc.RC/JobProgress")
+	require.NoError(t, err)
+	req, err := structpb.NewStruct(map[string]interface{}{"jobid": jobID, "interval": "100ms"})
+	require.NoError(t, err)
+	require.NoError(t, stream.SendMsg(req))
+	require.NoError(t, stream.CloseSend())
+	var last map[string]interface{}
+	for {
+		progress := new(structpb.Struct)
+		err = stream.RecvMsg(progress)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		last = progress.AsMap()
+	}
+	require.NotNil(t, last)
+	assert.Equal(t, jobID, last["jobid"])
+	assert.Equal(t, true, last["finished"])
+	assert.Equal(t, true, last["success"])
+
+	// Unknown jobs aren't found
+	stream, err = conn.NewStream(authCtx, &grpc.StreamDesc{ServerStreams: true}, "/rclone.rc.RC/JobProgress")
+	require.NoError(t, err)
+	req, err = structpb.NewStruct(map[string]interface{}{"jobid": 123456789})
+	require.NoError(t, err)
+	require.NoError(t, stream.SendMsg(req))
+	require.NoError(t, stream.CloseSend())
+	err = stream.RecvMsg(new(structpb.Struct))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,275 @@
+package rcserver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fshttp"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// maxHandlerReply is the largest reply read from an external handler
+const maxHandlerReply = 64 * 1024 * 1024
+
+// externalHandler is an rc call registered by rc/handler/register
+// which is run by POSTing its input to a URL
+type externalHandler struct {
+	Path         string `json:"path"`         // rc path the handler serves
+	URL          string `json:"url"`          // URL the input is POSTed to
+	Title        string `json:"title"`        // one line help for rc/list
+	AuthRequired bool   `json:"authRequired"` // whether the call needs the rc to have authentication
+}
+
+// handlerStore holds the external handlers which have been registered
+type handlerStore struct {
+	mu       sync.Mutex
+	handlers map[string]*externalHandler // by path
+}
+
+// handlers are the external handlers registered with this rclone
+var handlers = &handlerStore{
+	handlers: make(map[string]*externalHandler),
+}
+
+// register adds h to the rc replacing any external handler already
+// at its path
+func (hs *handlerStore) register(h *externalHandler, help string) error {
+	h.Path = strings.Trim(h.Path, "/")
+	if h.Path == "" {
+		return errors.New("handler needs a path")
+	}
+	u, err := url.Parse(h.URL)
+	if err != nil {
+		return fmt.Errorf("bad url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("url must be http or https not %q", h.URL)
+	}
+	if h.Title == "" {
+		h.Title = "External handler at " + h.URL
+	}
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if _, found := hs.handlers[h.Path]; !found && rc.Calls.Get(h.Path) != nil {
+		return fmt.Errorf("%q is already an rc call", h.Path)
+	}
+	hs.handlers[h.Path] = h
+	rc.Add(rc.Call{
+		Path:         h.Path,
+		AuthRequired: h.AuthRequired,
+		Fn:           h.call,
+		Title:        h.Title,
+		Help:         help,
+	})
+	return nil
+}
+
+// unregister removes the external handler at path
+func (hs *handlerStore) unregister(path string) error {
+	path = strings.Trim(path, "/")
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if _, found := hs.handlers[path]; !found {
+		return fmt.Errorf("handler %q not found", path)
+	}
+	delete(hs.handlers, path)
+	rc.Calls.Remove(path)
+	return nil
+}
+
+// list returns the external handlers sorted by path
+func (hs *handlerStore) list() []*externalHandler {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	list := make([]*externalHandler, 0, len(hs.handlers))
+	for _, h := range hs.handlers {
+		list = append(list, h)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Path < list[j].Path
+	})
+	return list
+}
+
+// call runs the handler by POSTing in as JSON to its URL and
+// returning the JSON object in the reply
+func (h *externalHandler) call(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	buf, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", h.URL, bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Rclone-Path", h.Path)
+	resp, err := fshttp.NewClient(ctx).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("handler %q: %w", h.Path, err)
+	}
+	defer fs.CheckClose(resp.Body, &err)
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, maxHandlerReply))
+	if err != nil {
+		return nil, fmt.Errorf("handler %q: failed to read reply: %w", h.Path, err)
+	}
+	if len(bytes.TrimSpace(body)) > 0 {
+		err = json.Unmarshal(body, &out)
+		if err != nil && resp.StatusCode == http.StatusOK {
+			return nil, fmt.Errorf("handler %q: failed to decode reply: %w", h.Path, err)
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		if message, ok := out["error"].(string); ok && message != "" {
+			return nil, fmt.Errorf("handler %q: %s", h.Path, message)
+		}
+		return nil, fmt.Errorf("handler %q: HTTP error %s", h.Path, resp.Status)
+	}
+	return out, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "rc/handler/register",
+		AuthRequired: true,
+		Fn:           rcHandlerRegister,
+		Title:        "Register an external handler for an rc path.",
+		Parameters: []rc.Parameter{
+			{Name: "path", Type: "string", Help: "the rc path to serve", Required: true},
+			{Name: "url", Type: "string", Help: "the http or https URL to POST the input to", Required: true},
+			{Name: "title", Type: "string", Help: "one line help for the call"},
+			{Name: "help", Type: "string", Help: "markdown help for the call"},
+			{Name: "authRequired", Type: "boolean", Help: "whether the call needs the rc to have authentication"},
+		},
+		Help: `
+This lets other programs add their own calls to the rc. When the call
+at path is made its input is POSTed as JSON to url and the JSON object
+in the reply is returned as its output.
+
+Parameters:
+
+- path - the rc path to serve, e.g. "myapp/refresh"
+- url - the http or https URL to POST the input to
+- title - one line help for the call shown by rc/list (optional)
+- help - markdown help for the call (optional)
+- authRequired - whether the rc must have authentication set up to use the call (default true)
+
+The handler should reply with status 200 and a JSON object, or another
+status and a JSON object with an "error" field to make the call fail.
+The header X-Rclone-Path is set to the path called.
+
+Paths which are already rc calls can't be registered, except those
+registered by this call which are replaced.
+
+Handlers are kept in memory so they need to be registered again if
+rclone is restarted.
+
+    rclone rc rc/handler/register path=myapp/refresh url=http://localhost:8080/refresh
+`,
+	})
+}
+
+// rcHandlerRegister is the rc/handler/register call
+func rcHandlerRegister(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	h := &externalHandler{AuthRequired: true}
+	h.Path, err = in.GetString("path")
+	if err != nil {
+		return nil, err
+	}
+	h.URL, err = in.GetString("url")
+	if err != nil {
+		return nil, err
+	}
+	h.Title, err = in.GetString("title")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	help, err := in.GetString("help")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	authRequired, err := in.GetBool("authRequired")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		h.AuthRequired = authRequired
+	}
+	err = handlers.register(h, help)
+	if err != nil {
+		return nil, rc.NewErrParamInvalid(err)
+	}
+	fs.Infof(nil, "rc: registered handler %q at %q", h.Path, h.URL)
+	out = rc.Params{}
+	err = rc.Reshape(&out, h)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "rc/handler/unregister",
+		AuthRequired: true,
+		Fn:           rcHandlerUnregister,
+		Title:        "Unregister an external handler.",
+		Parameters: []rc.Parameter{
+			{Name: "path", Type: "string", Help: "the rc path of the handler", Required: true},
+		},
+		Help: `
+This removes a handler registered with rc/handler/register.
+
+Parameters:
+
+- path - the rc path of the handler
+`,
+	})
+}
+
+// rcHandlerUnregister is the rc/handler/unregister call
+func rcHandlerUnregister(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	path, err := in.GetString("path")
+	if err != nil {
+		return nil, err
+	}
+	err = handlers.unregister(path)
+	if err != nil {
+		return nil, err
+	}
+	fs.Infof(nil, "rc: unregistered handler %q", path)
+	return nil, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "rc/handler/list",
+		AuthRequired: true,
+		Fn:           rcHandlerList,
+		Title:        "List the external handlers.",
+		Help: `
+This lists the handlers registered with rc/handler/register.
+
+This returns
+
+- handlers - array of handlers, each with path, url, title and authRequired
+`,
+	})
+}
+
+// rcHandlerList is the rc/handler/list call
+func rcHandlerList(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	return rc.Params{
+		"handlers": handlers.list(),
+	}, nil
+}
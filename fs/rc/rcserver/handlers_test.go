@@ -0,0 +1,86 @@
+package rcserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternalHandlers(t *testing.T) {
+	ctx := context.Background()
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "POST", r.Method)
+		var in rc.Params
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		if in["fail"] == true {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"failed on purpose"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(rc.Params{
+			"path": r.Header.Get("X-Rclone-Path"),
+			"in":   in,
+		})
+	}))
+	defer backend.Close()
+
+	register := rc.Calls.Get("rc/handler/register")
+	require.NotNil(t, register)
+	unregister := rc.Calls.Get("rc/handler/unregister")
+	require.NotNil(t, unregister)
+	list := rc.Calls.Get("rc/handler/list")
+	require.NotNil(t, list)
+
+	// Can't replace built in calls or use bad URLs
+	_, err := register.Fn(ctx, rc.Params{"path": "rc/noop", "url": backend.URL})
+	assert.Error(t, err)
+	_, err = register.Fn(ctx, rc.Params{"path": "test/handler", "url": "file:///etc/passwd"})
+	assert.Error(t, err)
+
+	out, err := register.Fn(ctx, rc.Params{"path": "/test/handler/", "url": backend.URL, "title": "Test handler", "authRequired": false})
+	require.NoError(t, err)
+	assert.Equal(t, "test/handler", out["path"])
+	defer func() {
+		_ = handlers.unregister("test/handler")
+	}()
+
+	call := rc.Calls.Get("test/handler")
+	require.NotNil(t, call)
+	assert.Equal(t, "Test handler", call.Title)
+	assert.False(t, call.AuthRequired)
+
+	out, err = call.Fn(ctx, rc.Params{"a": "potato"})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"path": "test/handler",
+		"in":   map[string]interface{}{"a": "potato"},
+	}, out)
+
+	_, err = call.Fn(ctx, rc.Params{"fail": true})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed on purpose")
+
+	// Registering again replaces the handler
+	_, err = register.Fn(ctx, rc.Params{"path": "test/handler", "url": backend.URL})
+	require.NoError(t, err)
+	assert.True(t, rc.Calls.Get("test/handler").AuthRequired)
+
+	out, err = list.Fn(ctx, nil)
+	require.NoError(t, err)
+	listed, ok := out["handlers"].([]*externalHandler)
+	require.True(t, ok)
+	require.Equal(t, 1, len(listed))
+	assert.Equal(t, backend.URL, listed[0].URL)
+
+	_, err = unregister.Fn(ctx, rc.Params{"path": "test/handler"})
+	require.NoError(t, err)
+	assert.Nil(t, rc.Calls.Get("test/handler"))
+	_, err = unregister.Fn(ctx, rc.Params{"path": "test/handler"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package rcserver
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	"golang.org/x/time/rate"
+)
+
+// maxIPLimiters is the number of client addresses the rate limiter
+// remembers
+const maxIPLimiters = 1024
+
+// limitBurst returns burst or ceil(limit) if burst is 0
+func limitBurst(limit float64, burst int) int {
+	if burst <= 0 {
+		burst = int(math.Ceil(limit))
+	}
+	return burst
+}
+
+// limitDelay takes a request from lim returning 0 if it is allowed
+// now or how long to wait before retrying if not
+func limitDelay(lim *rate.Limiter) time.Duration {
+	r := lim.Reserve()
+	if !r.OK() {
+		return time.Second
+	}
+	delay := r.Delay()
+	if delay > 0 {
+		r.Cancel()
+	}
+	return delay
+}
+
+// writeTooMany writes a 429 error with a Retry-After header saying
+// when to try again
+func writeTooMany(path string, w http.ResponseWriter, err error, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	writeError(path, nil, w, err, http.StatusTooManyRequests)
+}
+
+// ipLimiter is the rate limiter for one client address
+type ipLimiter struct {
+	lim  *rate.Limiter
+	last time.Time // when it was last used
+}
+
+// ipLimiters rate limits requests from each client address
+type ipLimiters struct {
+	mu       sync.Mutex
+	limit    float64
+	burst    int
+	limiters map[string]*ipLimiter
+}
+
+// newIPLimiters makes an ipLimiters allowing limit requests per second
+// with a burst of burst from each address
+func newIPLimiters(limit float64, burst int) *ipLimiters {
+	return &ipLimiters{
+		limit:    limit,
+		burst:    limitBurst(limit, burst),
+		limiters: make(map[string]*ipLimiter),
+	}
+}
+
+// delay takes a request from the address in remoteAddr returning 0
+// if it is allowed now or how long to wait before retrying if not
+func (ls *ipLimiters) delay(remoteAddr string) time.Duration {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	now := time.Now()
+	ls.mu.Lock()
+	l, found := ls.limiters[host]
+	if !found {
+		if len(ls.limiters) >= maxIPLimiters {
+			ls._prune(now)
+		}
+		l = &ipLimiter{lim: rate.NewLimiter(rate.Limit(ls.limit), ls.burst)}
+		ls.limiters[host] = l
+	}
+	l.last = now
+	ls.mu.Unlock()
+	return limitDelay(l.lim)
+}
+
+// _prune removes the limiters which have been idle long enough to
+// refill, or all of them if that doesn't free any.
+//
+// Call with ls.mu held
+func (ls *ipLimiters) _prune(now time.Time) {
+	refill := time.Duration(float64(ls.burst) / ls.limit * float64(time.Second))
+	for host, l := range ls.limiters {
+		if now.Sub(l.last) > refill {
+			delete(ls.limiters, host)
+		}
+	}
+	if len(ls.limiters) >= maxIPLimiters {
+		ls.limiters = make(map[string]*ipLimiter)
+	}
+}
+
+// rateLimitDelay checks the per address and per token rate limits
+// for a request from remoteAddr returning an error and how long to
+// wait before retrying if either is exceeded
+func (s *Server) rateLimitDelay(remoteAddr string, t *apiToken) (time.Duration, error) {
+	if s.ipLimiters != nil {
+		if delay := s.ipLimiters.delay(remoteAddr); delay > 0 {
+			return delay, fmt.Errorf("rate limit exceeded for %s", remoteAddr)
+		}
+	}
+	if t != nil && t.limiter != nil {
+		if delay := limitDelay(t.limiter); delay > 0 {
+			return delay, fmt.Errorf("token %q rate limit exceeded", t.Name)
+		}
+	}
+	return 0, nil
+}
+
+// checkRateLimits checks the per address and per token rate limits
+// for r returning false if it has already been answered with an error
+func (s *Server) checkRateLimits(w http.ResponseWriter, r *http.Request, path string, t *apiToken) bool {
+	if delay, err := s.rateLimitDelay(r.RemoteAddr, t); err != nil {
+		writeTooMany(path, w, err, delay)
+		return false
+	}
+	return true
+}
+
+// limitConcurrency returns fn wrapped so it holds one of the
+// --rc-max-concurrent slots while it runs, and a function to release
+// the slot if fn is never called. It returns a nil fn if all the
+// slots are in use.
+//
+// Calls which only read stats and job/stop don't need a slot so they
+// can always be used to monitor the server and stop jobs.
+func (s *Server) limitConcurrency(path string, fn rc.Func) (limitedFn rc.Func, release func()) {
+	if s.running == nil || statsPaths[path] || path == "job/stop" {
+		return fn, func() {}
+	}
+	select {
+	case s.running <- struct{}{}:
+	default:
+		return nil, nil
+	}
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			<-s.running
+		})
+	}
+	limitedFn = func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		defer release()
+		return fn(ctx, in)
+	}
+	return limitedFn, release
+}
@@ -0,0 +1,83 @@
+package rcserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPLimiters(t *testing.T) {
+	ls := newIPLimiters(1, 2)
+	assert.Equal(t, 2, ls.burst)
+	assert.Zero(t, ls.delay("192.0.2.1:1234"))
+	assert.Zero(t, ls.delay("192.0.2.1:5678"))
+	assert.NotZero(t, ls.delay("192.0.2.1:1234"))
+	assert.Zero(t, ls.delay("192.0.2.2:1234"))
+	assert.Equal(t, 2, len(ls.limiters))
+
+	assert.Equal(t, 3, newIPLimiters(2.5, 0).burst)
+}
+
+func TestRateLimit(t *testing.T) {
+	opt := newTestOpt()
+	opt.Serve = false
+	opt.Files = ""
+	opt.RateLimit = 0.01
+	opt.RateBurst = 2
+	s := newServer(context.Background(), &opt, http.NewServeMux())
+
+	call := func(remoteAddr string) *http.Response {
+		req := httptest.NewRequest("POST", "http://localhost:5572/rc/noop", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		s.handler(w, req)
+		return w.Result()
+	}
+	assert.Equal(t, http.StatusOK, call("192.0.2.1:1234").StatusCode)
+	assert.Equal(t, http.StatusOK, call("192.0.2.1:1234").StatusCode)
+	resp := call("192.0.2.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "100", resp.Header.Get("Retry-After"))
+	assert.Equal(t, http.StatusOK, call("192.0.2.2:1234").StatusCode)
+}
+
+func TestLimitConcurrency(t *testing.T) {
+	s := &Server{running: make(chan struct{}, 1)}
+	calls := 0
+	fn := func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		calls++
+		return nil, nil
+	}
+
+	fn1, release1 := s.limitConcurrency("sync/copy", fn)
+	require.NotNil(t, fn1)
+
+	// No more slots
+	fn2, _ := s.limitConcurrency("sync/copy", fn)
+	assert.Nil(t, fn2)
+
+	// Except for calls reading stats and stopping jobs
+	fnStats, _ := s.limitConcurrency("core/stats", fn)
+	assert.NotNil(t, fnStats)
+	fnStop, _ := s.limitConcurrency("job/stop", fn)
+	assert.NotNil(t, fnStop)
+
+	// Running the call frees the slot
+	_, err := fn1(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, calls)
+	release1() // doesn't free it twice
+	assert.Equal(t, 0, len(s.running))
+
+	// As does releasing it if the call wasn't run
+	fn3, release3 := s.limitConcurrency("sync/copy", fn)
+	require.NotNil(t, fn3)
+	assert.Equal(t, 1, len(s.running))
+	release3()
+	assert.Equal(t, 0, len(s.running))
+}
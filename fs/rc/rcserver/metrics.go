@@ -0,0 +1,66 @@
+package rcserver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// callMetrics are the Prometheus metrics for the rc calls
+type callMetrics struct {
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// newCallMetrics makes the metrics for the rc calls
+func newCallMetrics(namespace string) *callMetrics {
+	return &callMetrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rc",
+			Name:      "requests_total",
+			Help:      "Number of rc calls by path and HTTP status code",
+		}, []string{"path", "code"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "rc",
+			Name:      "errors_total",
+			Help:      "Number of rc calls which returned an error by path",
+		}, []string{"path"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "rc",
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to reply to rc calls by path",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		}, []string{"path"}),
+	}
+}
+
+// Collectors returns the metrics as collectors for registration
+func (m *callMetrics) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		m.requests,
+		m.errors,
+		m.duration,
+	}
+}
+
+// observe records a call to path which replied with status after
+// taking duration
+//
+// Paths which aren't rc calls are recorded as "unknown" so clients
+// can't make an unlimited number of labels.
+func (m *callMetrics) observe(path string, status int, duration time.Duration) {
+	if rc.Calls.Get(path) == nil {
+		path = "unknown"
+	}
+	m.requests.WithLabelValues(path, fmt.Sprint(status)).Inc()
+	if status >= 400 {
+		m.errors.WithLabelValues(path).Inc()
+	}
+	m.duration.WithLabelValues(path).Observe(duration.Seconds())
+}
@@ -0,0 +1,26 @@
+package rcserver
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallMetrics(t *testing.T) {
+	m := newCallMetrics("test")
+	m.observe("rc/noop", http.StatusOK, time.Millisecond)
+	m.observe("rc/noop", http.StatusOK, time.Second)
+	m.observe("rc/error", http.StatusInternalServerError, time.Millisecond)
+	m.observe("potato/potato", http.StatusNotFound, time.Millisecond)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues("rc/noop", "200")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("rc/error", "500")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("unknown", "404")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.errors.WithLabelValues("rc/noop")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.errors.WithLabelValues("rc/error")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.errors.WithLabelValues("unknown")))
+	assert.Equal(t, 3, testutil.CollectAndCount(m.duration))
+}
@@ -0,0 +1,97 @@
+package rcserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc/jobs"
+)
+
+// minProgressInterval is the shortest interval between progress
+// updates a client may ask for
+const minProgressInterval = 100 * time.Millisecond
+
+// serveJobProgress streams the progress of the job given by the
+// "jobid" query parameter every "interval" until it finishes.
+//
+// The progress is sent as server-sent events if the client accepts
+// text/event-stream, otherwise as lines of JSON.
+func (s *Server) serveJobProgress(w http.ResponseWriter, r *http.Request, path string) {
+	ctx := r.Context()
+	query := r.URL.Query()
+	jobID, err := strconv.ParseInt(query.Get("jobid"), 10, 64)
+	if err != nil {
+		writeError(path, nil, w, fmt.Errorf("bad or missing jobid: %w", err), http.StatusBadRequest)
+		return
+	}
+	interval := time.Second
+	if value := query.Get("interval"); value != "" {
+		interval, err = fs.ParseDuration(value)
+		if err != nil {
+			writeError(path, nil, w, fmt.Errorf("bad interval: %w", err), http.StatusBadRequest)
+			return
+		}
+		if interval < minProgressInterval {
+			interval = minProgressInterval
+		}
+	}
+	out, finished, err := jobs.Progress(ctx, jobID)
+	if err != nil {
+		writeError(path, nil, w, err, http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(path, nil, w, errors.New("streaming not supported"), http.StatusInternalServerError)
+		return
+	}
+
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		buf, err := json.Marshal(out)
+		if err != nil {
+			fs.Errorf(nil, "rc: %q: failed to marshal progress: %v", path, err)
+			return
+		}
+		if sse {
+			_, err = fmt.Fprintf(w, "data: %s\n\n", buf)
+		} else {
+			_, err = fmt.Fprintf(w, "%s\n", buf)
+		}
+		if err != nil {
+			fs.Debugf(nil, "rc: %q: failed to write progress: %v", path, err)
+			return
+		}
+		flusher.Flush()
+		if finished {
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		out, finished, err = jobs.Progress(ctx, jobID)
+		if err != nil {
+			// The job has expired
+			fs.Debugf(nil, "rc: %q: %v", path, err)
+			return
+		}
+	}
+}
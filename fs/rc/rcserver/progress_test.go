@@ -0,0 +1,91 @@
+package rcserver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/rc/jobs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobProgress(t *testing.T) {
+	opt := newTestOpt()
+	opt.Serve = false
+	opt.Files = ""
+	rcServer := newServer(context.Background(), &opt, http.NewServeMux())
+	server := httptest.NewServer(http.HandlerFunc(rcServer.handler))
+	defer server.Close()
+
+	sleep := func(ctx context.Context, in rc.Params) (rc.Params, error) {
+		time.Sleep(300 * time.Millisecond)
+		return rc.Params{}, nil
+	}
+
+	get := func(url string, accept string) *http.Response {
+		req, err := http.NewRequest("GET", server.URL+"/"+url, nil)
+		require.NoError(t, err)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Read the progress of a new job returning the messages
+	progress := func(accept string) (msgs []rc.Params) {
+		job, _, err := jobs.NewJob(context.Background(), sleep, rc.Params{"_async": true})
+		require.NoError(t, err)
+		resp := get(fmt.Sprintf("job/progress?jobid=%d&interval=100ms", job.ID), accept)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if accept != "" {
+				if line == "" {
+					continue
+				}
+				require.True(t, strings.HasPrefix(line, "data: "), line)
+				line = line[6:]
+			}
+			var msg rc.Params
+			require.NoError(t, json.Unmarshal([]byte(line), &msg))
+			assert.Equal(t, float64(job.ID), msg["jobid"])
+			msgs = append(msgs, msg)
+		}
+		require.NoError(t, scanner.Err())
+		return msgs
+	}
+
+	for _, accept := range []string{"", "text/event-stream"} {
+		t.Run(fmt.Sprintf("Accept=%q", accept), func(t *testing.T) {
+			msgs := progress(accept)
+			require.True(t, len(msgs) >= 2, msgs)
+			assert.Equal(t, false, msgs[0]["finished"])
+			assert.Contains(t, msgs[0], "bytes")
+			last := msgs[len(msgs)-1]
+			assert.Equal(t, true, last["finished"])
+			assert.Equal(t, true, last["success"])
+		})
+	}
+
+	resp := get("job/progress?jobid=potato", "")
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	resp = get("job/progress?jobid=123456789", "")
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
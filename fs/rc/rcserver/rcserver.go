@@ -5,15 +5,20 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"path/filepath"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -34,10 +39,13 @@ import (
 	"github.com/rclone/rclone/lib/http/serve"
 	"github.com/rclone/rclone/lib/random"
 	"github.com/skratchdot/open-golang/open"
+	"google.golang.org/grpc"
 )
 
-var promHandler http.Handler
-var onlyOnceWarningAllowOrigin sync.Once
+var (
+	promHandler http.Handler
+	rcMetrics   *callMetrics
+)
 
 func init() {
 	rcloneCollector := accounting.NewRcloneCollector(context.Background())
@@ -49,6 +57,11 @@ func init() {
 	}
 	fshttp.DefaultMetrics = m
 
+	rcMetrics = newCallMetrics("rclone")
+	for _, c := range rcMetrics.Collectors() {
+		prometheus.MustRegister(c)
+	}
+
 	promHandler = promhttp.Handler()
 }
 
@@ -57,7 +70,26 @@ func init() {
 // If the server wasn't configured the *Server returned may be nil
 func Start(ctx context.Context, opt *rc.Options) (*Server, error) {
 	jobs.SetOpt(opt) // set the defaults for jobs
+	rc.SetCommandOpt(opt)
 	if opt.Enabled {
+		if opt.JobQueueFile != "" {
+			err := jobs.StartQueue(opt.JobQueueFile)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if opt.JobHistoryFile != "" {
+			err := jobs.StartHistory(opt.JobHistoryFile, opt.JobHistoryMaxAge, opt.JobHistoryMaxJobs)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if opt.JobScheduleFile != "" {
+			err := jobs.StartSchedule(opt.JobScheduleFile)
+			if err != nil {
+				return nil, err
+			}
+		}
 		// Serve on the DefaultServeMux so can have global registrations appear
 		s := newServer(ctx, opt, http.DefaultServeMux)
 		return s, s.Serve()
@@ -72,6 +104,13 @@ type Server struct {
 	files          http.Handler
 	pluginsHandler http.Handler
 	opt            *rc.Options
+	corsOnce       sync.Once     // for parsing allowOrigins
+	allowOrigins   []string      // origins allowed by --rc-allow-origin
+	ipLimiters     *ipLimiters   // rate limits for each client if set
+	running        chan struct{} // slots for --rc-max-concurrent calls if set
+	grpcServer     *grpc.Server  // serving the rc over gRPC if set
+	grpcListener   net.Listener  // listener for grpcServer
+	audit          *auditLog     // log of the rc calls if set
 }
 
 func newServer(ctx context.Context, opt *rc.Options, mux *http.ServeMux) *Server {
@@ -96,7 +135,7 @@ func newServer(ctx context.Context, opt *rc.Options, mux *http.ServeMux) *Server
 		}
 		if opt.NoAuth {
 			fs.Logf(nil, "It is recommended to use web gui with auth.")
-		} else {
+		} else if opt.HTTPOptions.OIDC.Issuer == "" {
 			if opt.HTTPOptions.BasicUser == "" {
 				opt.HTTPOptions.BasicUser = "gui"
 				fs.Infof(nil, "No username specified. Using default username: %s \n", rcflags.Opt.HTTPOptions.BasicUser)
@@ -118,13 +157,24 @@ func newServer(ctx context.Context, opt *rc.Options, mux *http.ServeMux) *Server
 		pluginsHandler = http.FileServer(http.Dir(webgui.PluginsPath))
 	}
 
+	// Allow API tokens as well as the user and password
+	httpOpt := opt.HTTPOptions
+	httpOpt.TokenAuth = tokens.check
+
 	s := &Server{
-		Server:         httplib.NewServer(mux, &opt.HTTPOptions),
+		Server:         httplib.NewServer(mux, &httpOpt),
 		ctx:            ctx,
 		opt:            opt,
 		files:          fileHandler,
 		pluginsHandler: pluginsHandler,
 	}
+	s.Server.ServeH2C()
+	if opt.RateLimit > 0 {
+		s.ipLimiters = newIPLimiters(opt.RateLimit, opt.RateBurst)
+	}
+	if opt.MaxConcurrent > 0 {
+		s.running = make(chan struct{}, opt.MaxConcurrent)
+	}
 	mux.HandleFunc("/", s.handler)
 
 	return s
@@ -134,11 +184,36 @@ func newServer(ctx context.Context, opt *rc.Options, mux *http.ServeMux) *Server
 //
 // Use s.Close() and s.Wait() to shutdown server
 func (s *Server) Serve() error {
+	if s.opt.Tenants && !s.UsingAuth() {
+		return errors.New("--rc-tenants needs authentication set up on the rc server")
+	}
+	if user := s.opt.HTTPOptions.BasicUser; s.opt.Tenants && user != "" && !s.isTenantAdmin(user) {
+		if err := rc.CheckTenantName(user); err != nil {
+			return fmt.Errorf("--rc-tenants: %w", err)
+		}
+	}
+	if s.opt.AuditLog != "" {
+		audit, err := newAuditLog(s.opt.AuditLog, int64(s.opt.AuditLogMaxSize), s.opt.AuditLogMaxBackups)
+		if err != nil {
+			return err
+		}
+		s.audit = audit
+		currentAudit.mu.Lock()
+		currentAudit.log = audit
+		currentAudit.mu.Unlock()
+	}
 	err := s.Server.Serve()
 	if err != nil {
 		return err
 	}
 	fs.Logf(nil, "Serving remote control on %s", s.URL())
+	if s.opt.GRPCAddr != "" {
+		err = s.serveGRPC()
+		if err != nil {
+			s.Server.Close()
+			return err
+		}
+	}
 	// Open the files in the browser if set
 	if s.files != nil {
 		openURL, err := url.Parse(s.URL())
@@ -171,6 +246,25 @@ func (s *Server) Serve() error {
 	return nil
 }
 
+// Close shuts the running server down
+func (s *Server) Close() {
+	if s.grpcServer != nil {
+		s.grpcServer.Stop()
+	}
+	s.Server.Close()
+	if s.audit != nil {
+		currentAudit.mu.Lock()
+		if currentAudit.log == s.audit {
+			currentAudit.log = nil
+		}
+		currentAudit.mu.Unlock()
+		err := s.audit.close()
+		if err != nil {
+			fs.Errorf(nil, "rc: failed to close audit log: %v", err)
+		}
+	}
+}
+
 // writeError writes a formatted error to the output
 func writeError(path string, in rc.Params, w http.ResponseWriter, err error, status int) {
 	fs.Errorf(nil, "rc: %q: error: %v", path, err)
@@ -191,25 +285,35 @@ func (s *Server) handler(w http.ResponseWriter, r *http.Request) {
 	}
 	path := strings.TrimLeft(urlPath, "/")
 
-	allowOrigin := rcflags.Opt.AccessControlAllowOrigin
-	if allowOrigin != "" {
-		onlyOnceWarningAllowOrigin.Do(func() {
-			if allowOrigin == "*" {
-				fs.Logf(nil, "Warning: Allow origin set to *. This can cause serious security problems.")
-			}
-		})
-		w.Header().Add("Access-Control-Allow-Origin", allowOrigin)
-	} else {
-		w.Header().Add("Access-Control-Allow-Origin", s.URL())
+	if !s.setCORSHeaders(w, r) {
+		return
 	}
 
-	// echo back access control headers client needs
-	//reqAccessHeaders := r.Header.Get("Access-Control-Request-Headers")
-	w.Header().Add("Access-Control-Request-Method", "POST, OPTIONS, GET, HEAD")
-	w.Header().Add("Access-Control-Allow-Headers", "authorization, Content-Type")
+	// Check the scope of API tokens and the rate limits
+	if r.Method != "OPTIONS" {
+		t, _ := r.Context().Value(httplib.ContextAuthKey).(*apiToken)
+		if t != nil && !scopeAllows(t.Scope, path) {
+			writeError(path, nil, w, fmt.Errorf("token %q with scope %q may not use %q", t.Name, t.Scope, path), http.StatusForbidden)
+			return
+		}
+		if !s.checkRateLimits(w, r, path, t) {
+			return
+		}
+		ctx, err := s.tenantContext(r.Context(), r.Method, path)
+		if err != nil {
+			writeError(path, nil, w, err, http.StatusForbidden)
+			return
+		}
+		r = r.WithContext(ctx)
+	}
 
 	switch r.Method {
 	case "POST":
+		if !s.opt.NoCompression {
+			var finish func()
+			w, finish = newCompressWriter(w, r)
+			defer finish()
+		}
 		s.handlePost(w, r, path)
 	case "OPTIONS":
 		s.handleOptions(w, r, path)
@@ -225,6 +329,26 @@ func (s *Server) handlePost(w http.ResponseWriter, r *http.Request, path string)
 	ctx := r.Context()
 	contentType := r.Header.Get("Content-Type")
 
+	// Record the call in the metrics and the audit log when it has
+	// been answered
+	var in rc.Params
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	w = sw
+	start := time.Now()
+	defer func() {
+		rcMetrics.observe(path, sw.status, time.Since(start))
+	}()
+	if s.audit != nil {
+		entry := newAuditEntry(ctx, r.RemoteAddr, path, nil)
+		defer func() {
+			entry.Params = rc.Redact(in)
+			entry.Status = sw.status
+			entry.JobID, _ = strconv.ParseInt(sw.Header().Get("x-rclone-jobid"), 10, 64)
+			entry.Duration = time.Since(entry.Time).Seconds()
+			s.audit.add(entry)
+		}()
+	}
+
 	values := r.URL.Query()
 	if contentType == "application/x-www-form-urlencoded" {
 		// Parse the POST and URL parameters into r.Form, for others r.Form will be empty value
@@ -237,7 +361,7 @@ func (s *Server) handlePost(w http.ResponseWriter, r *http.Request, path string)
 	}
 
 	// Read the POST and URL parameters into in
-	in := make(rc.Params)
+	in = make(rc.Params)
 	for k, vs := range values {
 		if len(vs) > 0 {
 			in[k] = vs[len(vs)-1]
@@ -267,24 +391,68 @@ func (s *Server) handlePost(w http.ResponseWriter, r *http.Request, path string)
 
 	inOrig := in.Copy()
 
+	// Stream the result as JSON lines if required
+	stream, err := getStream(call, in)
+	if err != nil {
+		writeError(path, inOrig, w, err, http.StatusBadRequest)
+		return
+	}
+	var streamer *streamWriter
+	if stream {
+		streamer = newStreamWriter(w)
+		ctx = rc.WithStream(ctx, streamer.write)
+	}
+
 	if call.NeedsRequest {
 		// Add the request to RC
 		in["_request"] = r
+	} else if r.Body != nil {
+		// Read the rest of the body so the server notices if the
+		// client disconnects and cancels ctx
+		_, _ = io.Copy(ioutil.Discard, r.Body)
 	}
 
 	if call.NeedsResponse {
 		in["_response"] = w
 	}
 
-	fs.Debugf(nil, "rc: %q: with parameters %+v", path, in)
-	job, out, err := jobs.NewJob(ctx, call.Fn, in)
+	if user, ok := ctx.Value(httplib.ContextUserKey).(string); ok && user != "" {
+		fs.Debugf(nil, "rc: %q: called by user %q", path, user)
+	}
+	job, out, err := s.runCall(ctx, path, call, in)
+	if errors.Is(err, errTooManyCalls) {
+		writeTooMany(path, w, err, time.Second)
+		return
+	}
+	if ctx.Err() != nil {
+		fs.Logf(nil, "rc: %q: client disconnected - call cancelled", path)
+	}
 	if job != nil {
 		w.Header().Add("x-rclone-jobid", fmt.Sprintf("%d", job.ID))
 	}
+	if streamer != nil && streamer.isStarted() {
+		// The status has been sent so errors go in the last line
+		if err != nil {
+			fs.Errorf(nil, "rc: %q: error: %v", path, err)
+			out, _ = rc.Error(path, inOrig, err, http.StatusInternalServerError)
+		}
+		err = streamer.finish(out)
+		if err != nil {
+			fs.Errorf(nil, "rc: handlePost: failed to write JSON output: %v", err)
+		}
+		return
+	}
 	if err != nil {
 		writeError(path, inOrig, w, err, http.StatusInternalServerError)
 		return
 	}
+	if streamer != nil {
+		err = streamer.finish(out)
+		if err != nil {
+			fs.Errorf(nil, "rc: handlePost: failed to write JSON output: %v", err)
+		}
+		return
+	}
 	if out == nil {
 		out = make(rc.Params)
 	}
@@ -298,6 +466,38 @@ func (s *Server) handlePost(w http.ResponseWriter, r *http.Request, path string)
 	}
 }
 
+// errTooManyCalls is returned by runCall if --rc-max-concurrent calls
+// are already running
+var errTooManyCalls = errors.New("too many concurrent calls")
+
+// runCall runs call with in as a job, or adds it to the job queue if
+// _queue is set
+func (s *Server) runCall(ctx context.Context, path string, call *rc.Call, in rc.Params) (job *jobs.Job, out rc.Params, err error) {
+	queued, err := jobs.Queued(in)
+	if err != nil {
+		return nil, nil, err
+	}
+	if queued {
+		// Queued jobs run without the tenant
+		if rc.GetTenant(ctx) != "" {
+			return nil, nil, fmt.Errorf("_queue: %w", rc.ErrTenantForbidden)
+		}
+		fs.Debugf(nil, "rc: %q: queued with parameters %+v", path, in)
+		return jobs.Enqueue(path, in)
+	}
+	fn, release := s.limitConcurrency(path, call.Fn)
+	if fn == nil {
+		return nil, nil, fmt.Errorf("%w - limit is %d", errTooManyCalls, s.opt.MaxConcurrent)
+	}
+	fs.Debugf(nil, "rc: %q: with parameters %+v", path, in)
+	job, out, err = jobs.NewJob(jobs.WithPath(ctx, path), fn, in)
+	if job == nil {
+		// fn wasn't run
+		release()
+	}
+	return job, out, err
+}
+
 func (s *Server) handleOptions(w http.ResponseWriter, r *http.Request, path string) {
 	w.WriteHeader(http.StatusOK)
 }
@@ -308,7 +508,11 @@ func (s *Server) serveRoot(w http.ResponseWriter, r *http.Request) {
 	directory := serve.NewDirectory("", s.HTMLTemplate)
 	directory.Name = "List of all rclone remotes."
 	q := url.Values{}
+	tenant := rc.GetTenant(r.Context())
 	for _, remote := range remotes {
+		if !rc.TenantOwns(tenant, remote) {
+			continue
+		}
 		q.Set("fs", remote)
 		directory.AddHTMLEntry("["+remote+":]", true, -1, time.Time{})
 	}
@@ -320,6 +524,11 @@ func (s *Server) serveRoot(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) serveRemote(w http.ResponseWriter, r *http.Request, path string, fsName string) {
+	err := rc.CheckTenantFs(r.Context(), fsName)
+	if err != nil {
+		writeError(path, nil, w, err, http.StatusForbidden)
+		return
+	}
 	f, err := cache.Get(s.ctx, fsName)
 	if err != nil {
 		writeError(path, nil, w, fmt.Errorf("failed to make Fs: %w", err), http.StatusInternalServerError)
@@ -367,6 +576,12 @@ func (s *Server) handleGet(w http.ResponseWriter, r *http.Request, path string)
 		// Serve /[fs]/remote files
 		s.serveRemote(w, r, fsMatchResult[2], fsMatchResult[1])
 		return
+	case path == "job/progress":
+		s.serveJobProgress(w, r, path)
+		return
+	case path == "events":
+		s.serveEvents(w, r)
+		return
 	case path == "metrics" && s.opt.EnableMetrics:
 		promHandler.ServeHTTP(w, r)
 		return
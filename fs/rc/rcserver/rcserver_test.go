@@ -593,6 +593,12 @@ func TestMetrics(t *testing.T) {
 
 func makeMetricsTestCases(stats *accounting.StatsInfo) (tests []testRun) {
 	tests = []testRun{{
+		Name:     "RC Call",
+		URL:      "rc/noop",
+		Method:   "POST",
+		Status:   http.StatusOK,
+		Expected: "{}\n",
+	}, {
 		Name:     "Bytes Transferred Metric",
 		URL:      "/metrics",
 		Method:   "GET",
@@ -622,6 +628,12 @@ func makeMetricsTestCases(stats *accounting.StatsInfo) (tests []testRun) {
 		Method:   "GET",
 		Status:   http.StatusOK,
 		Contains: regexp.MustCompile(fmt.Sprintf("rclone_files_transferred_total %d", stats.GetTransfers())),
+	}, {
+		Name:     "RC Calls Metric",
+		URL:      "/metrics",
+		Method:   "GET",
+		Status:   http.StatusOK,
+		Contains: regexp.MustCompile(`rclone_rc_requests_total{code="200",path="rc/noop"} \d+`),
 	},
 	}
 	return
@@ -781,3 +793,53 @@ func TestRCAsync(t *testing.T) {
 	opt.Files = ""
 	testServer(t, tests, &opt)
 }
+
+func TestDisconnectCancelsCall(t *testing.T) {
+	ctx := context.Background()
+	started := make(chan struct{})
+	cancelled := make(chan error, 1)
+	rc.Add(rc.Call{
+		Path:  "test/wait-cancel",
+		Title: "Waits to be cancelled",
+		Fn: func(ctx context.Context, in rc.Params) (rc.Params, error) {
+			close(started)
+			select {
+			case <-ctx.Done():
+				cancelled <- ctx.Err()
+			case <-time.After(time.Minute):
+				cancelled <- nil
+			}
+			return nil, ctx.Err()
+		},
+	})
+	defer rc.Calls.Remove("test/wait-cancel")
+
+	opt := newTestOpt()
+	opt.HTTPOptions.ListenAddr = testBindAddress
+	opt.NoAuth = true
+	s := newServer(ctx, &opt, http.NewServeMux())
+	require.NoError(t, s.Serve())
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	// JSON with trailing data which isn't read by the decoder
+	reqCtx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequestWithContext(reqCtx, "POST", s.URL()+"test/wait-cancel", strings.NewReader("{}\n\n"))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err = http.DefaultClient.Do(req)
+	require.Error(t, err)
+
+	select {
+	case err := <-cancelled:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(10 * time.Second):
+		t.Fatal("call wasn't cancelled when the client disconnected")
+	}
+}
@@ -0,0 +1,59 @@
+package rcserver
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/rclone/rclone/cmd/serve/httplib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnixSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets not tested on Windows")
+	}
+	path := filepath.Join(t.TempDir(), "rc.sock")
+	addr := "unix://" + path
+	opt := newTestOpt()
+	opt.Serve = false
+	opt.Files = ""
+	opt.HTTPOptions.ListenAddr = addr
+	s := newServer(context.Background(), &opt, http.NewServeMux())
+	require.NoError(t, s.Serve())
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+	assert.Equal(t, addr+"/", s.URL())
+
+	// Only the user can use the socket
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	// Can't listen on a socket in use
+	s2 := newServer(context.Background(), &opt, http.NewServeMux())
+	assert.Error(t, s2.Serve())
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: httplib.SocketDialer(addr),
+		},
+	}
+	resp, err := client.Post("http://localhost/rc/noop?potato=1", "application/json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), `"potato": "1"`)
+}
@@ -0,0 +1,105 @@
+package rcserver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/fs/rc/jobs"
+)
+
+// streamContentType is the Content-Type of a streamed result
+const streamContentType = "application/x-ndjson"
+
+// streamWriter writes the items of a streamed result to the client
+// as JSON lines
+type streamWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	enc     *json.Encoder
+	started bool // set if the response has been started
+}
+
+// newStreamWriter makes a streamWriter writing to w
+func newStreamWriter(w http.ResponseWriter) *streamWriter {
+	return &streamWriter{
+		w:   w,
+		enc: json.NewEncoder(w),
+	}
+}
+
+// getStream returns true if _stream is set in which case call must
+// be able to stream its result and not be run in the background
+func getStream(call *rc.Call, in rc.Params) (bool, error) {
+	stream, err := in.GetBool("_stream")
+	if rc.NotErrParamNotFound(err) {
+		return false, err
+	}
+	delete(in, "_stream")
+	if !stream {
+		return false, nil
+	}
+	if !call.CanStream {
+		return false, rc.NewErrParamInvalid(errors.New("_stream isn't supported by this call"))
+	}
+	isAsync, err := in.GetBool("_async")
+	if rc.NotErrParamNotFound(err) {
+		return false, err
+	}
+	queued, err := jobs.Queued(in)
+	if err != nil {
+		return false, err
+	}
+	if isAsync || queued {
+		return false, rc.NewErrParamInvalid(errors.New("_stream can't be used with _async or _queue"))
+	}
+	return true, nil
+}
+
+// start the response if it hasn't been started
+//
+// Call with mu held
+func (s *streamWriter) _start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.w.Header().Set("Content-Type", streamContentType)
+	s.w.WriteHeader(http.StatusOK)
+}
+
+// write item as a line of JSON sending it to the client straight away
+func (s *streamWriter) write(item interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s._start()
+	err := s.enc.Encode(item)
+	if err != nil {
+		return err
+	}
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// finish the response writing out as the last line if it has anything
+// in it.
+func (s *streamWriter) finish(out rc.Params) error {
+	if len(out) > 0 {
+		return s.write(out)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s._start()
+	return nil
+}
+
+// isStarted returns true if any of the response has been written
+func (s *streamWriter) isStarted() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.started
+}
@@ -0,0 +1,93 @@
+package rcserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	_ "github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	s := newStreamWriter(w)
+	assert.False(t, s.isStarted())
+	require.NoError(t, s.write(rc.Params{"a": 1}))
+	assert.True(t, s.isStarted())
+	require.NoError(t, s.write(rc.Params{"b": 2}))
+	require.NoError(t, s.finish(nil))
+	assert.Equal(t, "{\"a\":1}\n{\"b\":2}\n", w.Body.String())
+	assert.Equal(t, streamContentType, w.Header().Get("Content-Type"))
+	assert.True(t, w.Flushed)
+
+	// The output is written as the last line if set
+	w = httptest.NewRecorder()
+	s = newStreamWriter(w)
+	require.NoError(t, s.finish(rc.Params{"error": "potato"}))
+	assert.Equal(t, "{\"error\":\"potato\"}\n", w.Body.String())
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestStream(t *testing.T) {
+	tests := []testRun{{
+		Name:        "list",
+		URL:         "operations/list",
+		Method:      "POST",
+		Body:        `{"fs": "testdata/files", "remote": "", "opt": {"noModTime": true, "noMimeType": true}, "_stream": true}`,
+		ContentType: "application/json",
+		Status:      http.StatusOK,
+		Expected: `{"Path":"dir","Name":"dir","Size":-1,"ModTime":"","IsDir":true}
+{"Path":"file.txt","Name":"file.txt","Size":18,"ModTime":"","IsDir":false}
+`,
+		Headers: map[string]string{
+			"Content-Type": "application/x-ndjson",
+		},
+	}, {
+		Name:        "list-error",
+		URL:         "operations/list",
+		Method:      "POST",
+		Body:        `{"fs": "testdata/files", "remote": "notfound", "_stream": true}`,
+		ContentType: "application/json",
+		Status:      http.StatusNotFound,
+		Contains:    regexp.MustCompile(`"error": ".*directory not found"`),
+	}, {
+		Name:        "list-not-stream",
+		URL:         "operations/list",
+		Method:      "POST",
+		Body:        `{"fs": "testdata/files", "remote": "", "_stream": false}`,
+		ContentType: "application/json",
+		Status:      http.StatusOK,
+		Contains:    regexp.MustCompile(`(?s)^\{\n\t"list": \[`),
+	}, {
+		Name:        "paged",
+		URL:         "operations/list",
+		Method:      "POST",
+		Body:        `{"fs": "testdata/files", "remote": "", "maxResults": 1, "_stream": true}`,
+		ContentType: "application/json",
+		Status:      http.StatusBadRequest,
+		Contains:    regexp.MustCompile(`can't page the listing`),
+	}, {
+		Name:        "async",
+		URL:         "operations/list",
+		Method:      "POST",
+		Body:        `{"fs": "testdata/files", "remote": "", "_async": true, "_stream": true}`,
+		ContentType: "application/json",
+		Status:      http.StatusBadRequest,
+		Contains:    regexp.MustCompile(`_stream can't be used with _async or _queue`),
+	}, {
+		Name:        "unsupported",
+		URL:         "rc/noop",
+		Method:      "POST",
+		Body:        `{"_stream": true}`,
+		ContentType: "application/json",
+		Status:      http.StatusBadRequest,
+		Contains:    regexp.MustCompile(`_stream isn't supported by this call`),
+	}}
+	opt := newTestOpt()
+	opt.NoAuth = true
+	testServer(t, tests, &opt)
+}
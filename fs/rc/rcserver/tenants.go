@@ -0,0 +1,114 @@
+package rcserver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rclone/rclone/cmd/serve/httplib"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// tenantPaths are the paths a tenant may use with --rc-tenants
+//
+// These either don't touch remotes, jobs or mounts or only let the
+// tenant see and use its own.
+var tenantPaths = map[string]bool{
+	"backup/prune":          true,
+	"config/dump":           true,
+	"config/get":            true,
+	"config/listremotes":    true,
+	"config/providers":      true,
+	"core/version":          true,
+	"job/history":           true,
+	"job/list":              true,
+	"job/status":            true,
+	"job/statusgroup":       true,
+	"job/stop":              true,
+	"job/stopgroup":         true,
+	"mount/listmounts":      true,
+	"mount/types":           true,
+	"mount/unmount":         true,
+	"operations/about":      true,
+	"operations/cleanup":    true,
+	"operations/copyfile":   true,
+	"operations/delete":     true,
+	"operations/deletefile": true,
+	"operations/du":         true,
+	"operations/fsinfo":     true,
+	"operations/list":       true,
+	"operations/mkdir":      true,
+	"operations/movefile":   true,
+	"operations/publiclink": true,
+	"operations/purge":      true,
+	"operations/rename":     true,
+	"operations/rmdir":      true,
+	"operations/rmdirs":     true,
+	"operations/size":       true,
+	"operations/stat":       true,
+	"operations/streamcopy": true,
+	"operations/upload":     true,
+	"operations/uploadfile": true,
+	"rc/list":               true,
+	"rc/noop":               true,
+	"sync/copy":             true,
+	"sync/explain":          true,
+	"sync/move":             true,
+	"sync/sync":             true,
+}
+
+// tenantAllows returns true if a tenant may use path with method
+func tenantAllows(method, path string) bool {
+	if method == "GET" || method == "HEAD" {
+		switch path {
+		case "events", "job/progress", "metrics":
+			return false
+		}
+		// Remotes served with --rc-serve are checked as they are
+		// served
+		return true
+	}
+	return tenantPaths[path]
+}
+
+// tenant returns the tenant the user authenticated in ctx belongs to
+// or "" if it isn't restricted.
+//
+// Requests authenticated with an API token have no user and aren't
+// restricted. Tenants can't make tokens.
+func (s *Server) tenant(ctx context.Context) string {
+	if !s.opt.Tenants {
+		return ""
+	}
+	user, _ := ctx.Value(httplib.ContextUserKey).(string)
+	if user == "" || s.isTenantAdmin(user) {
+		return ""
+	}
+	return user
+}
+
+// isTenantAdmin returns true if user is in --rc-tenant-admins
+func (s *Server) isTenantAdmin(user string) bool {
+	for _, admin := range strings.Split(s.opt.TenantAdmins, ",") {
+		if strings.TrimSpace(admin) == user {
+			return true
+		}
+	}
+	return false
+}
+
+// tenantContext checks the tenant of the user authenticated in ctx
+// may use path with method and returns a context restricting the call to it.
+func (s *Server) tenantContext(ctx context.Context, method, path string) (context.Context, error) {
+	tenant := s.tenant(ctx)
+	if tenant == "" {
+		return ctx, nil
+	}
+	if err := rc.CheckTenantName(tenant); err != nil {
+		return nil, fmt.Errorf("%v: %w", err, rc.ErrTenantForbidden)
+	}
+	if !tenantAllows(method, path) {
+		return nil, fmt.Errorf("%q: %w", path, rc.ErrTenantForbidden)
+	}
+	return rc.WithTenant(ctx, tenant), nil
+}
@@ -0,0 +1,69 @@
+package rcserver
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rclone/rclone/cmd/serve/httplib"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantAllows(t *testing.T) {
+	for _, test := range []struct {
+		method string
+		path   string
+		want   bool
+	}{
+		{"POST", "operations/list", true},
+		{"POST", "sync/copy", true},
+		{"POST", "job/status", true},
+		{"POST", "sync/bisync", false},
+		{"POST", "core/command", false},
+		{"POST", "config/create", false},
+		{"POST", "mount/mount", false},
+		{"POST", "rc/token/create", false},
+		{"GET", "[alice.drive:]/file.txt", true},
+		{"GET", "events", false},
+		{"GET", "job/progress", false},
+		{"GET", "metrics", false},
+	} {
+		assert.Equal(t, test.want, tenantAllows(test.method, test.path), test)
+	}
+}
+
+func TestTenantContext(t *testing.T) {
+	opt := rc.DefaultOpt
+	s := &Server{opt: &opt}
+	userCtx := func(user string) context.Context {
+		return context.WithValue(context.Background(), httplib.ContextUserKey, user)
+	}
+
+	// Not restricted without --rc-tenants
+	ctx, err := s.tenantContext(userCtx("alice"), "POST", "core/command")
+	require.NoError(t, err)
+	assert.Equal(t, "", rc.GetTenant(ctx))
+
+	opt.Tenants = true
+	opt.TenantAdmins = "root, admin"
+
+	ctx, err = s.tenantContext(userCtx("alice"), "POST", "operations/list")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", rc.GetTenant(ctx))
+
+	_, err = s.tenantContext(userCtx("alice"), "POST", "core/command")
+	assert.True(t, errors.Is(err, rc.ErrTenantForbidden))
+
+	// Tenant names can't contain the separator
+	_, err = s.tenantContext(userCtx("alice.x"), "POST", "operations/list")
+	assert.True(t, errors.Is(err, rc.ErrTenantForbidden))
+
+	// Admins and API tokens aren't restricted
+	for _, ctx := range []context.Context{userCtx("admin"), context.Background()} {
+		ctx, err = s.tenantContext(ctx, "POST", "core/command")
+		require.NoError(t, err)
+		assert.Equal(t, "", rc.GetTenant(ctx))
+	}
+}
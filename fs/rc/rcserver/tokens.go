@@ -0,0 +1,324 @@
+package rcserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/rclone/rclone/lib/random"
+	"golang.org/x/time/rate"
+)
+
+// Scopes an API token can have
+const (
+	ScopeStats = "stats" // read only access to stats, jobs and events
+	ScopeMount = "mount" // as ScopeStats plus managing mounts and the VFS
+	ScopeFull  = "full"  // access to everything
+)
+
+// tokenPrefix starts every API token to make them easy to recognise
+const tokenPrefix = "rclone_"
+
+// statsPaths are the paths a ScopeStats token may use
+var statsPaths = map[string]bool{
+	"core/group-list":  true,
+	"core/memstats":    true,
+	"core/openapi":     true,
+	"core/pid":         true,
+	"core/stats":       true,
+	"core/subscribe":   true,
+	"core/transferred": true,
+	"core/version":     true,
+	"events":           true,
+	"job/list":         true,
+	"job/progress":     true,
+	"job/status":       true,
+	"job/statusgroup":  true,
+	"metrics":          true,
+	"mount/listmounts": true,
+	"mount/types":      true,
+	"rc/list":          true,
+	"rc/noop":          true,
+	"vfs/list":         true,
+	"vfs/stats":        true,
+}
+
+// mountPaths are the paths a ScopeMount token may use as well as
+// statsPaths. The vfs/ calls which can delete data or change the
+// cache limits, like vfs/emptytrash, vfs/checkcache, vfs/setopts and
+// vfs/closehandle, need ScopeFull.
+var mountPaths = map[string]bool{
+	"mount/mount":       true,
+	"mount/unmount":     true,
+	"mount/unmountall":  true,
+	"vfs/changes":       true,
+	"vfs/forget":        true,
+	"vfs/handles":       true,
+	"vfs/poll-interval": true,
+	"vfs/refresh":       true,
+	"vfs/slow":          true,
+	"vfs/snapshot":      true,
+}
+
+// scopeAllows returns true if a token with scope may use path
+func scopeAllows(scope, path string) bool {
+	switch scope {
+	case ScopeFull:
+		return true
+	case ScopeMount:
+		return mountPaths[path] || statsPaths[path]
+	case ScopeStats:
+		return statsPaths[path]
+	}
+	return false
+}
+
+// apiToken is an API token issued by rc/token/create
+type apiToken struct {
+	Name    string    `json:"name"`    // name to refer to the token by
+	Scope   string    `json:"scope"`   // what the token may be used for
+	Rate    float64   `json:"rate"`    // max requests per second, 0 for unlimited
+	Created time.Time `json:"created"` // when the token was made
+	Expires time.Time `json:"expires"` // when the token stops working, zero for never
+	hash    string    // hex SHA-256 of the token
+	limiter *rate.Limiter
+}
+
+// tokenStore holds the API tokens which have been issued
+type tokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]*apiToken // by name
+}
+
+// tokens are the API tokens issued by this rclone
+var tokens = &tokenStore{
+	tokens: make(map[string]*apiToken),
+}
+
+// hashToken returns the hex SHA-256 of token so the tokens themselves
+// needn't be kept
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// create makes a new token, returning it and its secret value
+func (ts *tokenStore) create(name, scope string, limit float64, expire time.Duration) (*apiToken, string, error) {
+	if name == "" {
+		return nil, "", errors.New("token needs a name")
+	}
+	switch scope {
+	case ScopeStats, ScopeMount, ScopeFull:
+	default:
+		return nil, "", fmt.Errorf("unknown scope %q - must be %q, %q or %q", scope, ScopeStats, ScopeMount, ScopeFull)
+	}
+	if limit < 0 {
+		return nil, "", errors.New("rate must be >= 0")
+	}
+	if expire < 0 {
+		return nil, "", errors.New("expire must be >= 0")
+	}
+	secret, err := random.Password(256)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to make token: %w", err)
+	}
+	secret = tokenPrefix + secret
+	t := &apiToken{
+		Name:    name,
+		Scope:   scope,
+		Rate:    limit,
+		Created: time.Now(),
+		hash:    hashToken(secret),
+	}
+	if expire > 0 {
+		t.Expires = t.Created.Add(expire)
+	}
+	if limit > 0 {
+		t.limiter = rate.NewLimiter(rate.Limit(limit), limitBurst(limit, 0))
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if _, found := ts.tokens[name]; found {
+		return nil, "", fmt.Errorf("token %q already exists", name)
+	}
+	ts.tokens[name] = t
+	return t, secret, nil
+}
+
+// revoke removes the token called name
+func (ts *tokenStore) revoke(name string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if _, found := ts.tokens[name]; !found {
+		return fmt.Errorf("token %q not found", name)
+	}
+	delete(ts.tokens, name)
+	return nil
+}
+
+// list returns the tokens sorted by name
+func (ts *tokenStore) list() []*apiToken {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	list := make([]*apiToken, 0, len(ts.tokens))
+	for _, t := range ts.tokens {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// check returns the token for secret - it satisfies httplib.TokenAuthFn
+func (ts *tokenStore) check(secret string) (value interface{}, err error) {
+	hash := hashToken(secret)
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for name, t := range ts.tokens {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(t.hash)) != 1 {
+			continue
+		}
+		if !t.Expires.IsZero() && time.Now().After(t.Expires) {
+			delete(ts.tokens, name)
+			return nil, fmt.Errorf("token %q has expired", name)
+		}
+		return t, nil
+	}
+	return nil, errors.New("unknown token")
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "rc/token/create",
+		AuthRequired: true,
+		Fn:           rcTokenCreate,
+		Title:        "Create an API token for the rc server.",
+		Help: `
+This makes a token which can be used to authenticate to the rc server
+instead of the user and password by sending the header
+
+    Authorization: Bearer TOKEN
+
+Parameters:
+
+- name - name of the token, used to revoke it
+- scope - what the token may do, one of "stats", "mount" or "full"
+- rate - maximum number of requests per second (default 0 - unlimited)
+- expire - how long the token is valid for, e.g. 24h (default 0 - forever)
+
+The scopes are
+
+- stats - read only access to stats, jobs, events and the list of mounts
+- mount - as stats plus mounting, unmounting and the vfs/ calls
+  which don't delete data or change the cache limits, so not
+  vfs/emptytrash, vfs/checkcache, vfs/setopts or vfs/closehandle
+- full - everything the user and password can do
+
+This returns
+
+- token - the token - this is the only time it is shown
+- name, scope, rate, created and expires as passed in
+
+Tokens are kept in memory so they stop working when rclone exits.
+They can only be used if authentication is set up with --rc-user and
+--rc-pass or --rc-htpasswd.
+
+    rclone rc rc/token/create name=monitor scope=stats rate=5
+`,
+	})
+}
+
+// rcTokenCreate is the rc/token/create call
+func rcTokenCreate(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	name, err := in.GetString("name")
+	if err != nil {
+		return nil, err
+	}
+	scope, err := in.GetString("scope")
+	if err != nil {
+		return nil, err
+	}
+	limit, err := in.GetFloat64("rate")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	expire, err := in.GetDuration("expire")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	t, secret, err := tokens.create(name, scope, limit, expire)
+	if err != nil {
+		return nil, err
+	}
+	fs.Infof(nil, "rc: created API token %q with scope %q", name, scope)
+	out = rc.Params{}
+	err = rc.Reshape(&out, t)
+	if err != nil {
+		return nil, err
+	}
+	out["token"] = secret
+	return out, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "rc/token/revoke",
+		AuthRequired: true,
+		Fn:           rcTokenRevoke,
+		Title:        "Revoke an API token for the rc server.",
+		Help: `
+This stops the token with the name given working immediately.
+
+Parameters:
+
+- name - name of the token
+
+    rclone rc rc/token/revoke name=monitor
+`,
+	})
+}
+
+// rcTokenRevoke is the rc/token/revoke call
+func rcTokenRevoke(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	name, err := in.GetString("name")
+	if err != nil {
+		return nil, err
+	}
+	err = tokens.revoke(name)
+	if err != nil {
+		return nil, err
+	}
+	fs.Infof(nil, "rc: revoked API token %q", name)
+	return nil, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "rc/token/list",
+		AuthRequired: true,
+		Fn:           rcTokenList,
+		Title:        "List the API tokens for the rc server.",
+		Help: `
+This returns
+
+- tokens - a list of the tokens with their name, scope, rate, created
+and expires. The tokens themselves aren't shown.
+`,
+	})
+}
+
+// rcTokenList is the rc/token/list call
+func rcTokenList(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	return rc.Params{
+		"tokens": tokens.list(),
+	}, nil
+}
@@ -0,0 +1,164 @@
+package rcserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/rc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopeAllows(t *testing.T) {
+	for _, test := range []struct {
+		scope string
+		path  string
+		want  bool
+	}{
+		{ScopeStats, "core/stats", true},
+		{ScopeStats, "job/progress", true},
+		{ScopeStats, "mount/mount", false},
+		{ScopeStats, "operations/list", false},
+		{ScopeMount, "core/stats", true},
+		{ScopeMount, "mount/mount", true},
+		{ScopeMount, "vfs/refresh", true},
+		{ScopeMount, "vfs/stats", true},
+		{ScopeMount, "mount/unmount", true},
+		{ScopeMount, "vfs/emptytrash", false},
+		{ScopeMount, "vfs/setopts", false},
+		{ScopeMount, "vfs/checkcache", false},
+		{ScopeMount, "vfs/closehandle", false},
+		{ScopeMount, "vfs/potato", false},
+		{ScopeMount, "mount/potato", false},
+		{ScopeMount, "operations/list", false},
+		{ScopeMount, "rc/token/create", false},
+		{ScopeFull, "operations/list", true},
+		{ScopeFull, "rc/token/create", true},
+		{"potato", "core/stats", false},
+	} {
+		assert.Equal(t, test.want, scopeAllows(test.scope, test.path), test)
+	}
+}
+
+func TestTokenStore(t *testing.T) {
+	ts := &tokenStore{tokens: make(map[string]*apiToken)}
+
+	_, _, err := ts.create("", ScopeStats, 0, 0)
+	assert.Error(t, err)
+	_, _, err = ts.create("a", "potato", 0, 0)
+	assert.Error(t, err)
+	_, _, err = ts.create("a", ScopeStats, -1, 0)
+	assert.Error(t, err)
+
+	tok, secret, err := ts.create("a", ScopeStats, 0, 0)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, tokenPrefix))
+	assert.NotContains(t, tok.hash, secret)
+	_, _, err = ts.create("a", ScopeFull, 0, 0)
+	assert.Error(t, err)
+
+	value, err := ts.check(secret)
+	require.NoError(t, err)
+	assert.Equal(t, tok, value)
+	_, err = ts.check(secret + "x")
+	assert.Error(t, err)
+
+	_, expiredSecret, err := ts.create("b", ScopeFull, 0, time.Nanosecond)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	_, err = ts.check(expiredSecret)
+	assert.Error(t, err)
+
+	list := ts.list()
+	require.Equal(t, 1, len(list))
+	assert.Equal(t, "a", list[0].Name)
+
+	require.NoError(t, ts.revoke("a"))
+	assert.Error(t, ts.revoke("a"))
+	_, err = ts.check(secret)
+	assert.Error(t, err)
+}
+
+func TestTokens(t *testing.T) {
+	ctx := context.Background()
+	opt := newTestOpt()
+	opt.Serve = false
+	opt.Files = ""
+	opt.HTTPOptions.ListenAddr = "localhost:0"
+	opt.HTTPOptions.BasicUser = "user"
+	opt.HTTPOptions.BasicPass = "pass"
+	s := newServer(ctx, &opt, http.NewServeMux())
+	require.NoError(t, s.Serve())
+	defer func() {
+		s.Close()
+		s.Wait()
+	}()
+
+	// call path with params authenticating with token or the user
+	// and password if token is empty, returning the status and output
+	call := func(path string, in rc.Params, token string) (int, rc.Params) {
+		buf, err := json.Marshal(in)
+		require.NoError(t, err)
+		req, err := http.NewRequest("POST", s.URL()+path, strings.NewReader(string(buf)))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if token == "" {
+			req.SetBasicAuth("user", "pass")
+		} else {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer func() {
+			_ = resp.Body.Close()
+		}()
+		var out rc.Params
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	status, out := call("rc/token/create", rc.Params{"name": "test-tokens", "scope": "stats", "rate": 2}, "")
+	require.Equal(t, http.StatusOK, status, out)
+	assert.Equal(t, "test-tokens", out["name"])
+	assert.Equal(t, "stats", out["scope"])
+	token, ok := out["token"].(string)
+	require.True(t, ok)
+	defer func() {
+		_ = tokens.revoke("test-tokens")
+	}()
+
+	// Not allowed to make more tokens or run operations
+	status, _ = call("rc/token/create", rc.Params{"name": "another", "scope": "full"}, token)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	// Allowed to use read only calls until the rate limit is hit
+	time.Sleep(time.Second)
+	status, _ = call("core/version", nil, token)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = call("rc/noop", nil, token)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = call("rc/noop", nil, token)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+
+	// A bad token isn't allowed
+	status, _ = call("core/stats", nil, token+"x")
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// The token is listed without its value
+	status, out = call("rc/token/list", nil, "")
+	require.Equal(t, http.StatusOK, status)
+	buf, err := json.Marshal(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(buf), `"test-tokens"`)
+	assert.NotContains(t, string(buf), token)
+
+	// Once revoked it can't be used
+	status, _ = call("rc/token/revoke", rc.Params{"name": "test-tokens"}, "")
+	require.Equal(t, http.StatusOK, status)
+	status, _ = call("core/version", nil, token)
+	assert.Equal(t, http.StatusUnauthorized, status)
+}
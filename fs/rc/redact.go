@@ -0,0 +1,64 @@
+package rc
+
+import "strings"
+
+// Redacted replaces the values of secret parameters in logs and
+// records of calls
+const Redacted = "XXXXXXXX"
+
+// isSecret returns true if the parameter key may hold a secret
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range []string{"pass", "secret", "token", "key", "auth", "credential"} {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns a copy of in with the values of secret parameters
+// replaced and the HTTP request and response removed
+func Redact(in Params) Params {
+	if in == nil {
+		return nil
+	}
+	out := make(Params, len(in))
+	for key, value := range in {
+		switch {
+		case key == "_request" || key == "_response":
+			continue
+		case isSecret(key):
+			out[key] = Redacted
+		default:
+			out[key] = redactValue(value)
+		}
+	}
+	return out
+}
+
+// redactValue redacts secrets in any parameters within value
+func redactValue(value interface{}) interface{} {
+	switch x := value.(type) {
+	case Params:
+		return Redact(x)
+	case map[string]interface{}:
+		return map[string]interface{}(Redact(x))
+	case map[string]string:
+		out := make(map[string]string, len(x))
+		for key, value := range x {
+			if isSecret(key) {
+				value = Redacted
+			}
+			out[key] = value
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i := range x {
+			out[i] = redactValue(x[i])
+		}
+		return out
+	}
+	return value
+}
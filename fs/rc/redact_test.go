@@ -0,0 +1,50 @@
+package rc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	in := Params{
+		"fs":        "remote:",
+		"password":  "secret",
+		"_request":  "request",
+		"_response": "response",
+		"parameters": map[string]interface{}{
+			"client_secret": "secret",
+			"region":        "eu",
+		},
+		"opt": Params{
+			"token": "secret",
+		},
+		"list": []interface{}{
+			map[string]interface{}{"api_key": "secret"},
+		},
+		"config": map[string]string{
+			"pass": "secret",
+			"type": "drive",
+		},
+	}
+	assert.Equal(t, Params{
+		"fs":       "remote:",
+		"password": Redacted,
+		"parameters": map[string]interface{}{
+			"client_secret": Redacted,
+			"region":        "eu",
+		},
+		"opt": Params{
+			"token": Redacted,
+		},
+		"list": []interface{}{
+			map[string]interface{}{"api_key": Redacted},
+		},
+		"config": map[string]string{
+			"pass": Redacted,
+			"type": "drive",
+		},
+	}, Redact(in))
+	assert.Equal(t, "secret", in["password"], "input must not be changed")
+	assert.Nil(t, Redact(nil))
+}
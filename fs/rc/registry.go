@@ -12,16 +12,26 @@ import (
 // Func defines a type for a remote control function
 type Func func(ctx context.Context, in Params) (out Params, err error)
 
+// Parameter describes one of the input parameters of a Call
+type Parameter struct {
+	Name     string // name of the parameter
+	Type     string // JSON type - "string", "integer", "number", "boolean", "object" or "array"
+	Help     string // one line description of the parameter
+	Required bool   // if set then the call fails without this parameter
+}
+
 // Call defines info about a remote control function and is used in
 // the Add function to create new entry points.
 type Call struct {
-	Path          string // path to activate this RC
-	Fn            Func   `json:"-"` // function to call
-	Title         string // help for the function
-	AuthRequired  bool   // if set then this call requires authorisation to be set
-	Help          string // multi-line markdown formatted help
-	NeedsRequest  bool   // if set then this call will be passed the original request object as _request
-	NeedsResponse bool   // if set then this call will be passed the original response object as _response
+	Path          string      // path to activate this RC
+	Fn            Func        `json:"-"` // function to call
+	Title         string      // help for the function
+	AuthRequired  bool        // if set then this call requires authorisation to be set
+	Help          string      // multi-line markdown formatted help
+	NeedsRequest  bool        // if set then this call will be passed the original request object as _request
+	NeedsResponse bool        // if set then this call will be passed the original response object as _response
+	CanStream     bool        // if set then this call passes its items to the StreamFn in the context if there is one
+	Parameters    []Parameter `json:",omitempty"` // the input parameters if declared
 }
 
 // Registry holds the list of all the registered remote control functions
@@ -54,6 +64,17 @@ func (r *Registry) Get(path string) *Call {
 	return r.call[path]
 }
 
+// Remove the call at path from the registry returning true if it
+// was found
+func (r *Registry) Remove(path string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	path = strings.Trim(path, "/")
+	_, found := r.call[path]
+	delete(r.call, path)
+	return found
+}
+
 // List of all calls in alphabetical order
 func (r *Registry) List() (out []*Call) {
 	r.mu.RLock()
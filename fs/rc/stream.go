@@ -0,0 +1,23 @@
+package rc
+
+import "context"
+
+// StreamFn is called with each item of the result of a call which is
+// being streamed
+type StreamFn func(item interface{}) error
+
+// streamKey is the context key for the StreamFn
+type streamKey struct{}
+
+// WithStream returns a new context which asks calls which can stream
+// their results to pass each item to fn instead of returning them.
+func WithStream(ctx context.Context, fn StreamFn) context.Context {
+	return context.WithValue(ctx, streamKey{}, fn)
+}
+
+// GetStream returns the StreamFn to pass the items of the result to
+// or nil if the result isn't being streamed.
+func GetStream(ctx context.Context) StreamFn {
+	fn, _ := ctx.Value(streamKey{}).(StreamFn)
+	return fn
+}
@@ -0,0 +1,81 @@
+package rc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rclone/rclone/fs/fspath"
+)
+
+// TenantSeparator separates the name of a tenant from the rest of the
+// name of the remotes it owns, so the tenant "alice" owns the remotes
+// "alice.drive" and "alice.s3".
+const TenantSeparator = "."
+
+// ErrTenantForbidden is returned when a tenant tries to use something
+// which doesn't belong to it
+var ErrTenantForbidden = errors.New("not allowed for this tenant")
+
+// tenantKey is the context key for the tenant
+type tenantKey struct{}
+
+// WithTenant returns a new context which restricts the calls made
+// with it to the remotes, jobs and mounts belonging to tenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// GetTenant returns the tenant set with WithTenant or "" if the calls
+// made with ctx aren't restricted.
+func GetTenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// CheckTenantName returns an error if tenant can't be used as the
+// name of a tenant.
+//
+// Tenant names can't contain TenantSeparator, otherwise the tenant
+// "alice" would own the remotes of the tenant "alice.x".
+func CheckTenantName(tenant string) error {
+	if strings.Contains(tenant, TenantSeparator) {
+		return fmt.Errorf("tenant name %q can't contain %q", tenant, TenantSeparator)
+	}
+	return nil
+}
+
+// TenantOwns returns true if the remote called name belongs to
+// tenant, which is when the part of name before the first
+// TenantSeparator is tenant. Every remote belongs to the empty tenant.
+func TenantOwns(tenant, name string) bool {
+	if tenant == "" {
+		return true
+	}
+	if CheckTenantName(tenant) != nil {
+		return false
+	}
+	return strings.HasPrefix(name, tenant+TenantSeparator)
+}
+
+// CheckTenantFs returns an error if the tenant set in ctx may not use
+// the remote fsString.
+//
+// A tenant may only use remotes from the config file which it owns.
+// Local paths, on the fly backends and connection string parameters
+// are refused as they could reach outside of the tenant's remotes.
+func CheckTenantFs(ctx context.Context, fsString string) error {
+	tenant := GetTenant(ctx)
+	if tenant == "" {
+		return nil
+	}
+	parsed, err := fspath.Parse(fsString)
+	if err != nil {
+		return err
+	}
+	if parsed.Name == "" || parsed.Name[0] == ':' || parsed.ConfigString != parsed.Name || !TenantOwns(tenant, parsed.Name) {
+		return fmt.Errorf("remote %q: %w", fsString, ErrTenantForbidden)
+	}
+	return nil
+}
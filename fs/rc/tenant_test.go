@@ -0,0 +1,65 @@
+package rc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantOwns(t *testing.T) {
+	for _, test := range []struct {
+		tenant string
+		name   string
+		want   bool
+	}{
+		{"", "drive", true},
+		{"alice", "alice.drive", true},
+		{"alice", "alice.drive{AbCdE}", true},
+		{"alice", "drive", false},
+		{"alice", "bob.drive", false},
+		{"alice", "alicedrive", false},
+		{"alice", "alice", false},
+		{"alice", "alice.x.drive", true},
+		{"alice.x", "alice.x.drive", false},
+		{"alice.", "alice..drive", false},
+	} {
+		assert.Equal(t, test.want, TenantOwns(test.tenant, test.name), test)
+	}
+}
+
+func TestCheckTenantName(t *testing.T) {
+	assert.NoError(t, CheckTenantName("alice"))
+	assert.NoError(t, CheckTenantName(""))
+	assert.Error(t, CheckTenantName("alice.x"))
+	assert.Error(t, CheckTenantName("."))
+}
+
+func TestCheckTenantFs(t *testing.T) {
+	ctx := context.Background()
+	assert.NoError(t, CheckTenantFs(ctx, "/tmp"))
+	assert.Equal(t, "", GetTenant(ctx))
+
+	ctx = WithTenant(ctx, "alice")
+	assert.Equal(t, "alice", GetTenant(ctx))
+	for _, test := range []struct {
+		fsString string
+		ok       bool
+	}{
+		{"alice.drive:", true},
+		{"alice.drive:path/to/dir", true},
+		{"bob.drive:", false},
+		{"drive:", false},
+		{"/tmp", false},
+		{":local:/tmp", false},
+		{"alice.drive,root_folder_id=1:", false},
+	} {
+		err := CheckTenantFs(ctx, test.fsString)
+		if test.ok {
+			assert.NoError(t, err, test.fsString)
+		} else {
+			assert.True(t, errors.Is(err, ErrTenantForbidden), test.fsString)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ServerSideFallback describes what to do when a server-side move
+// isn't possible and the file would have to be downloaded and
+// uploaded instead
+type ServerSideFallback byte
+
+// ServerSideFallback constants
+const (
+	ServerSideFallbackAllow   ServerSideFallback = iota // fall back silently
+	ServerSideFallbackWarn                              // fall back with a warning
+	ServerSideFallbackError                             // don't fall back - return an error
+	ServerSideFallbackDefault = ServerSideFallbackAllow
+)
+
+var serverSideFallbackToString = []string{
+	ServerSideFallbackAllow: "allow",
+	ServerSideFallbackWarn:  "warn",
+	ServerSideFallbackError: "error",
+}
+
+// String turns a ServerSideFallback into a string
+func (m ServerSideFallback) String() string {
+	if m >= ServerSideFallback(len(serverSideFallbackToString)) {
+		return fmt.Sprintf("ServerSideFallback(%d)", m)
+	}
+	return serverSideFallbackToString[m]
+}
+
+// Set a ServerSideFallback
+func (m *ServerSideFallback) Set(s string) error {
+	for n, name := range serverSideFallbackToString {
+		if s != "" && name == strings.ToLower(s) {
+			*m = ServerSideFallback(n)
+			return nil
+		}
+	}
+	return fmt.Errorf("Unknown server-side fallback policy %q", s)
+}
+
+// Type of the value
+func (m *ServerSideFallback) Type() string {
+	return "string"
+}
+
+// UnmarshalJSON makes sure the value can be parsed as a string or integer in JSON
+func (m *ServerSideFallback) UnmarshalJSON(in []byte) error {
+	return UnmarshalJSONFlag(in, m, func(i int64) error {
+		if i < 0 || i >= int64(len(serverSideFallbackToString)) {
+			return fmt.Errorf("Out of range server-side fallback policy %d", i)
+		}
+		*m = (ServerSideFallback)(i)
+		return nil
+	})
+}
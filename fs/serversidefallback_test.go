@@ -0,0 +1,75 @@
+package fs
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Check it satisfies the interface
+var _ flagger = (*ServerSideFallback)(nil)
+
+func TestServerSideFallbackString(t *testing.T) {
+	for _, test := range []struct {
+		in   ServerSideFallback
+		want string
+	}{
+		{ServerSideFallbackAllow, "allow"},
+		{ServerSideFallbackError, "error"},
+		{99, "ServerSideFallback(99)"},
+	} {
+		sf := test.in
+		got := sf.String()
+		assert.Equal(t, test.want, got, test.in)
+	}
+}
+
+func TestServerSideFallbackSet(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want ServerSideFallback
+		err  bool
+	}{
+		{"allow", ServerSideFallbackAllow, false},
+		{"WARN", ServerSideFallbackWarn, false},
+		{"Error", ServerSideFallbackError, false},
+		{"Potato", 0, true},
+		{"", 0, true},
+	} {
+		sf := ServerSideFallback(0)
+		err := sf.Set(test.in)
+		if test.err {
+			require.Error(t, err, test.in)
+		} else {
+			require.NoError(t, err, test.in)
+		}
+		assert.Equal(t, test.want, sf, test.in)
+	}
+}
+
+func TestServerSideFallbackUnmarshalJSON(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want ServerSideFallback
+		err  bool
+	}{
+		{`"warn"`, ServerSideFallbackWarn, false},
+		{`"ERROR"`, ServerSideFallbackError, false},
+		{`"Potato"`, 0, true},
+		{strconv.Itoa(int(ServerSideFallbackWarn)), ServerSideFallbackWarn, false},
+		{`99`, 0, true},
+		{`-99`, 0, true},
+	} {
+		var sf ServerSideFallback
+		err := json.Unmarshal([]byte(test.in), &sf)
+		if test.err {
+			require.Error(t, err, test.in)
+		} else {
+			require.NoError(t, err, test.in)
+		}
+		assert.Equal(t, test.want, sf, test.in)
+	}
+}
@@ -0,0 +1,226 @@
+package sync
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/lib/pacer"
+)
+
+const (
+	// adaptiveMaxFactor is how many times --transfers or --checkers
+	// the adaptive limits can grow to
+	adaptiveMaxFactor = 4
+	// adaptiveInterval is how often the adaptive limits are tuned
+	adaptiveInterval = 5 * time.Second
+	// adaptiveGain is the minimum fractional increase in the rate
+	// for an increase in the limit to be worth keeping
+	adaptiveGain = 0.05
+	// adaptiveLatencyFactor is how many times slower than the best
+	// seen the operations need to be to count as congested
+	adaptiveLatencyFactor = 2
+	// adaptiveHold is how many intervals to wait after reducing the
+	// limit before increasing it again
+	adaptiveHold = 3
+)
+
+// adaptiveLimit limits the number of workers which are working at
+// once to a limit which can be changed while they are running
+type adaptiveLimit struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	limit     int           // number of workers allowed to work at once
+	active    int           // number of workers working
+	saturated bool          // set if a worker had to wait since the last sample
+	done      int64         // operations finished since the last sample
+	elapsed   time.Duration // total time they took
+}
+
+// newAdaptiveLimit makes a new limit
+func newAdaptiveLimit(limit int) *adaptiveLimit {
+	l := &adaptiveLimit{limit: limit}
+	l.cond = sync.NewCond(&l.mu)
+	return l
+}
+
+// acquire waits until the worker is allowed to work returning the
+// time it started
+//
+// It does nothing if l is nil.
+func (l *adaptiveLimit) acquire() time.Time {
+	if l == nil {
+		return time.Time{}
+	}
+	l.mu.Lock()
+	for l.active >= l.limit {
+		l.saturated = true
+		l.cond.Wait()
+	}
+	l.active++
+	l.mu.Unlock()
+	return time.Now()
+}
+
+// release is called when the worker which called acquire has finished
+// an operation
+//
+// It does nothing if l is nil.
+func (l *adaptiveLimit) release(start time.Time) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.active--
+	l.done++
+	l.elapsed += time.Since(start)
+	l.mu.Unlock()
+	l.cond.Signal()
+}
+
+// setLimit changes the limit
+func (l *adaptiveLimit) setLimit(limit int) {
+	l.mu.Lock()
+	l.limit = limit
+	l.mu.Unlock()
+	l.cond.Broadcast()
+}
+
+// sample returns the state since the last sample and resets it
+func (l *adaptiveLimit) sample() (limit int, saturated bool, done int64, elapsed time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	limit, saturated, done, elapsed = l.limit, l.saturated || l.active >= l.limit, l.done, l.elapsed
+	l.saturated, l.done, l.elapsed = false, 0, 0
+	return limit, saturated, done, elapsed
+}
+
+// adaptiveTuner tunes an adaptiveLimit
+//
+// It increases the limit one at a time while that increases the rate
+// the work is done and the workers are all busy. It reduces the limit
+// by a quarter if there are retries or errors or the operations slow
+// down a lot, which is a sign that the remote or network is
+// congested, and it takes back an increase which didn't help.
+type adaptiveTuner struct {
+	name        string         // name of the limit for logging
+	limit       *adaptiveLimit // the limit being tuned
+	max         int            // largest the limit can be
+	count       func() int64   // returns the amount of work done so far
+	lastCount   int64          // count at the last sample
+	lastRate    float64        // rate of work at the last sample
+	bestLatency time.Duration  // shortest average latency seen
+	grew        bool           // set if the limit was increased at the last sample
+	hold        int            // number of samples before increasing again
+}
+
+// tune adjusts the limit from a sample taken interval after the last
+// one. congested should be set if there were retries or errors.
+func (t *adaptiveTuner) tune(interval time.Duration, congested bool) {
+	limit, saturated, done, elapsed := t.limit.sample()
+	count := t.count()
+	rate := float64(count-t.lastCount) / interval.Seconds()
+	t.lastCount = count
+	reason := ""
+	if done > 0 {
+		latency := elapsed / time.Duration(done)
+		if t.bestLatency == 0 || latency < t.bestLatency {
+			t.bestLatency = latency
+		} else if latency > adaptiveLatencyFactor*t.bestLatency && rate <= t.lastRate {
+			congested = true
+			reason = "operations slowed down"
+		}
+	}
+	newLimit := limit
+	switch {
+	case congested:
+		newLimit = limit * 3 / 4
+		if newLimit >= limit {
+			newLimit = limit - 1
+		}
+		if reason == "" {
+			reason = "retries or errors"
+		}
+		t.hold = adaptiveHold
+	case t.grew && rate < t.lastRate*(1+adaptiveGain):
+		newLimit = limit - 1
+		reason = "no increase in rate"
+		t.hold = adaptiveHold
+	case t.hold > 0:
+		t.hold--
+	case saturated && rate > 0:
+		newLimit = limit + 1
+		reason = "all busy"
+	}
+	if newLimit < 1 {
+		newLimit = 1
+	} else if newLimit > t.max {
+		newLimit = t.max
+	}
+	t.grew = newLimit > limit
+	t.lastRate = rate
+	if newLimit != limit {
+		fs.Infof(nil, "Adaptive concurrency: %s %d -> %d (%s)", t.name, limit, newLimit, reason)
+		t.limit.setLimit(newLimit)
+	}
+}
+
+// adaptive tunes the number of checkers and transfers working at
+// once in a sync for --adaptive-concurrency
+type adaptive struct {
+	checkers    *adaptiveLimit
+	transfers   *adaptiveLimit
+	tuners      []*adaptiveTuner
+	lastRetries int64
+	lastErrors  int64
+	stats       *accounting.StatsInfo
+}
+
+// newAdaptive makes an adaptive starting at --checkers and
+// --transfers
+func newAdaptive(ctx context.Context) *adaptive {
+	ci := fs.GetConfig(ctx)
+	stats := accounting.Stats(ctx)
+	a := &adaptive{
+		checkers:    newAdaptiveLimit(ci.Checkers),
+		transfers:   newAdaptiveLimit(ci.Transfers),
+		lastRetries: pacer.Retries(),
+		lastErrors:  stats.GetErrors(),
+		stats:       stats,
+	}
+	a.tuners = []*adaptiveTuner{{
+		name:      "checkers",
+		limit:     a.checkers,
+		max:       adaptiveMaxFactor * ci.Checkers,
+		count:     stats.GetChecks,
+		lastCount: stats.GetChecks(),
+	}, {
+		name:      "transfers",
+		limit:     a.transfers,
+		max:       adaptiveMaxFactor * ci.Transfers,
+		count:     stats.GetBytes,
+		lastCount: stats.GetBytes(),
+	}}
+	return a
+}
+
+// run tunes the limits every adaptiveInterval until ctx is cancelled
+func (a *adaptive) run(ctx context.Context) {
+	ticker := time.NewTicker(adaptiveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		retries, errors := pacer.Retries(), a.stats.GetErrors()
+		congested := retries > a.lastRetries || errors > a.lastErrors
+		a.lastRetries, a.lastErrors = retries, errors
+		for _, t := range a.tuners {
+			t.tune(adaptiveInterval, congested)
+		}
+	}
+}
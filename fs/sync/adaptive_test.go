@@ -0,0 +1,126 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveLimit(t *testing.T) {
+	l := newAdaptiveLimit(1)
+	start := l.acquire()
+
+	// A second worker has to wait
+	acquired := make(chan struct{})
+	go func() {
+		l.release(l.acquire())
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired more than the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Raising the limit lets it go
+	l.setLimit(2)
+	<-acquired
+	l.release(start)
+
+	limit, saturated, done, _ := l.sample()
+	assert.Equal(t, 2, limit)
+	assert.True(t, saturated)
+	assert.Equal(t, int64(2), done)
+
+	limit, saturated, done, elapsed := l.sample()
+	assert.Equal(t, 2, limit)
+	assert.False(t, saturated)
+	assert.Equal(t, int64(0), done)
+	assert.Equal(t, time.Duration(0), elapsed)
+
+	// A nil limit does nothing
+	var nilLimit *adaptiveLimit
+	nilLimit.release(nilLimit.acquire())
+}
+
+func TestAdaptiveTuner(t *testing.T) {
+	var count int64
+	l := newAdaptiveLimit(2)
+	tuner := &adaptiveTuner{
+		name:  "test",
+		limit: l,
+		max:   4,
+		count: func() int64 { return count },
+	}
+	// work does n operations each taking latency and adds work
+	// to the count with all the workers busy if busy is set
+	work := func(n int, latency time.Duration, amount int64, busy bool) {
+		l.mu.Lock()
+		l.done += int64(n)
+		l.elapsed += time.Duration(n) * latency
+		l.saturated = busy
+		l.mu.Unlock()
+		count += amount
+	}
+	getLimit := func() int {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.limit
+	}
+
+	// Busy so increase
+	work(10, time.Second, 100, true)
+	tuner.tune(time.Second, false)
+	assert.Equal(t, 3, getLimit())
+
+	// Rate went up so increase again
+	work(10, time.Second, 150, true)
+	tuner.tune(time.Second, false)
+	assert.Equal(t, 4, getLimit())
+
+	// Can't go above the max
+	work(10, time.Second, 200, true)
+	tuner.tune(time.Second, false)
+	assert.Equal(t, 4, getLimit())
+
+	// Congestion reduces by a quarter then holds
+	work(10, time.Second, 200, true)
+	tuner.tune(time.Second, true)
+	assert.Equal(t, 3, getLimit())
+	for i := 0; i < adaptiveHold; i++ {
+		work(10, time.Second, 200, true)
+		tuner.tune(time.Second, false)
+		assert.Equal(t, 3, getLimit())
+	}
+
+	// Increase which doesn't increase the rate is taken back
+	work(10, time.Second, 200, true)
+	tuner.tune(time.Second, false)
+	assert.Equal(t, 4, getLimit())
+	work(10, time.Second, 201, true)
+	tuner.tune(time.Second, false)
+	assert.Equal(t, 3, getLimit())
+	for i := 0; i < adaptiveHold; i++ {
+		work(10, time.Second, 200, true)
+		tuner.tune(time.Second, false)
+	}
+
+	// Not busy so no change
+	work(10, time.Second, 200, false)
+	tuner.tune(time.Second, false)
+	assert.Equal(t, 3, getLimit())
+
+	// Operations slowing down without going faster counts as
+	// congestion
+	work(10, 3*time.Second, 200, true)
+	tuner.tune(time.Second, false)
+	assert.Equal(t, 2, getLimit())
+
+	// Never goes below 1
+	for i := 0; i < 5; i++ {
+		work(10, time.Second, 200, true)
+		tuner.tune(time.Second, true)
+	}
+	assert.Equal(t, 1, getLimit())
+}
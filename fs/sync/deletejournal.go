@@ -0,0 +1,261 @@
+package sync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// States of the entries in the --delete-journal
+const (
+	deleteIntended = "intended" // going to be deleted once committed
+	deleteCommit   = "commit"   // all the intended deletions are recorded
+	deleteDone     = "deleted"  // deleted
+	deleteFailed   = "failed"   // failed to delete
+	deleteWithheld = "withheld" // not deleted as it wasn't safe
+)
+
+// deleteJournal records the deletions a sync is going to make in a
+// file before making any of them for --delete-journal.
+//
+// The deletions are made in two phases. First every file which is
+// going to be deleted is written to the journal as "intended"
+// followed by a "commit" line, then each file is deleted and written
+// to the journal again as "deleted" or "failed". If the listings
+// weren't complete then none of the files are deleted and they are
+// written to the journal as "withheld" instead.
+//
+// The journal is a file of JSON lines, the first of which says which
+// sync it is for. It is replaced each time the sync runs.
+type deleteJournal struct {
+	path string
+	mu   sync.Mutex
+	out  *os.File // file to write the journal to
+	err  error    // first error writing the journal
+}
+
+// deleteJournalHeader is the first line of the journal
+type deleteJournalHeader struct {
+	Src     string    `json:"src"`
+	Dst     string    `json:"dst"`
+	Started time.Time `json:"started"`
+}
+
+// deleteJournalEntry is a line of the journal for a file
+type deleteJournalEntry struct {
+	Remote string `json:"remote,omitempty"`
+	State  string `json:"state"`
+	Error  string `json:"error,omitempty"` // why it failed or was withheld
+}
+
+// newDeleteJournal creates the journal at path for syncing fsrc to
+// fdst, replacing any journal from a previous run.
+func newDeleteJournal(path string, fdst, fsrc fs.Fs) (j *deleteJournal, err error) {
+	j = &deleteJournal{path: path}
+	j.out, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create --delete-journal: %w", err)
+	}
+	err = j.write(deleteJournalHeader{
+		Src:     fs.ConfigString(fsrc),
+		Dst:     fs.ConfigString(fdst),
+		Started: time.Now(),
+	})
+	if err != nil {
+		_ = j.out.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+// write a line to the journal returning the first error writing it
+func (j *deleteJournal) write(v interface{}) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.err != nil {
+		return j.err
+	}
+	buf, err := json.Marshal(v)
+	if err == nil {
+		_, err = j.out.Write(append(buf, '\n'))
+	}
+	if err != nil {
+		j.err = fmt.Errorf("failed to write --delete-journal: %w", err)
+	}
+	return j.err
+}
+
+// record writes an entry for each of objs with state
+func (j *deleteJournal) record(objs []fs.Object, state string, reason error) error {
+	entry := deleteJournalEntry{State: state}
+	if reason != nil {
+		entry.Error = reason.Error()
+	}
+	for _, o := range objs {
+		entry.Remote = o.Remote()
+		if err := j.write(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commit writes the commit line and makes sure the journal is on
+// disk so the deletions can be started.
+func (j *deleteJournal) commit() error {
+	err := j.write(deleteJournalEntry{State: deleteCommit})
+	if err != nil {
+		return err
+	}
+	err = j.out.Sync()
+	if err != nil {
+		return fmt.Errorf("failed to sync --delete-journal: %w", err)
+	}
+	return nil
+}
+
+// close the journal
+func (j *deleteJournal) close() error {
+	err := j.out.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close --delete-journal: %w", err)
+	}
+	return nil
+}
+
+// sortedObjects returns the objects in files sorted by remote
+func sortedObjects(files map[string]fs.Object) []fs.Object {
+	objs := make([]fs.Object, 0, len(files))
+	for _, o := range files {
+		objs = append(objs, o)
+	}
+	sort.Slice(objs, func(i, k int) bool {
+		return objs[i].Remote() < objs[k].Remote()
+	})
+	return objs
+}
+
+// withhold records that none of objs will be deleted because of
+// reason and logs each one.
+func (j *deleteJournal) withhold(objs []fs.Object, reason error) error {
+	for _, o := range objs {
+		fs.Errorf(o, "Not deleting: %v", reason)
+	}
+	err := j.record(objs, deleteWithheld, reason)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: withheld %d deletions recorded in --delete-journal %q", fs.ErrorNotDeleting, len(objs), j.path)
+}
+
+// journaledDeleteFiles deletes the files left in dstFiles for
+// --delete-journal. listErr is the error from listing the source and
+// destination - if it is set, or the sync was interrupted, nothing is
+// deleted as the listings may not have been complete.
+func (s *syncCopyMove) journaledDeleteFiles(listErr error) (err error) {
+	j := s.deleteJournal
+	defer func() {
+		closeErr := j.close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+	objs := sortedObjects(s.dstFiles)
+	if len(objs) == 0 {
+		return nil
+	}
+
+	// Phase 0: check it is safe to delete anything
+	switch {
+	case listErr != nil:
+		return j.withhold(objs, fmt.Errorf("listing was incomplete: %v", listErr))
+	case s.ctx.Err() != nil:
+		return j.withhold(objs, fmt.Errorf("sync was interrupted: %v", s.ctx.Err()))
+	case s.currentError() != nil && !s.ci.IgnoreErrors:
+		return j.withhold(objs, fmt.Errorf("there were errors: %v", s.currentError()))
+	}
+	if err := s.checkMaxDelete(len(objs)); err != nil {
+		_ = j.withhold(objs, err)
+		return err
+	}
+
+	// Phase 1: record the deletions and commit them
+	err = j.record(objs, deleteIntended, nil)
+	if err != nil {
+		return fserrors.FatalError(err)
+	}
+	if s.ci.DryRun {
+		// Don't commit as nothing will be deleted
+		for _, o := range objs {
+			_ = operations.DeleteFileWithBackupDir(s.ctx, o, s.deleteBackupDir())
+		}
+		return nil
+	}
+	err = j.commit()
+	if err != nil {
+		return fserrors.FatalError(err)
+	}
+
+	// Phase 2: make the deletions recording each one
+	toDelete := make(chan fs.Object)
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex
+		errorCount  int
+		fatalErrors int
+	)
+	wg.Add(s.ci.Transfers)
+	for i := 0; i < s.ci.Transfers; i++ {
+		go func() {
+			defer wg.Done()
+			for o := range toDelete {
+				err := operations.DeleteFileWithBackupDir(s.ctx, o, s.deleteBackupDir())
+				if err != nil {
+					_ = j.record([]fs.Object{o}, deleteFailed, err)
+					mu.Lock()
+					errorCount++
+					if fserrors.IsFatalError(err) {
+						fatalErrors++
+					}
+					mu.Unlock()
+				} else {
+					_ = j.record([]fs.Object{o}, deleteDone, nil)
+				}
+			}
+		}()
+	}
+outer:
+	for _, o := range objs {
+		mu.Lock()
+		stop := fatalErrors > 0
+		mu.Unlock()
+		if stop {
+			break
+		}
+		select {
+		case <-s.ctx.Done():
+			break outer
+		case toDelete <- o:
+		}
+	}
+	close(toDelete)
+	wg.Wait()
+	if errorCount > 0 {
+		err = fmt.Errorf("failed to delete %d files", errorCount)
+		if fatalErrors > 0 {
+			return fserrors.FatalError(err)
+		}
+		return err
+	}
+	j.mu.Lock()
+	err = j.err
+	j.mu.Unlock()
+	return err
+}
@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/accounting"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readDeleteJournal reads the entries in the --delete-journal at path
+func readDeleteJournal(t *testing.T, path string) (header deleteJournalHeader, entries []deleteJournalEntry) {
+	in, err := os.Open(path)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, in.Close()) }()
+	scanner := bufio.NewScanner(in)
+	require.True(t, scanner.Scan())
+	require.NoError(t, json.Unmarshal(scanner.Bytes(), &header))
+	for scanner.Scan() {
+		var entry deleteJournalEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return header, entries
+}
+
+func TestSyncDeleteJournal(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ci.DeleteJournal = filepath.Join(t.TempDir(), "deletes.json")
+	ci.DeleteMode = fs.DeleteModeDuring
+
+	file1 := r.WriteFile("file1", "file1 contents", t1)
+	file2 := r.WriteObject(ctx, "file2", "file2 contents", t1)
+	file3 := r.WriteObject(ctx, "sub/file3", "file3 contents", t1)
+	r.CheckLocalItems(t, file1)
+	r.CheckRemoteItems(t, file2, file3)
+
+	accounting.GlobalStats().ResetCounters()
+	err := Sync(ctx, r.Fremote, r.Flocal, false)
+	require.NoError(t, err)
+	r.CheckRemoteItems(t, file1)
+
+	header, entries := readDeleteJournal(t, ci.DeleteJournal)
+	assert.Equal(t, fs.ConfigString(r.Flocal), header.Src)
+	assert.Equal(t, fs.ConfigString(r.Fremote), header.Dst)
+	require.Equal(t, 5, len(entries))
+	assert.Equal(t, []deleteJournalEntry{
+		{Remote: "file2", State: deleteIntended},
+		{Remote: "sub/file3", State: deleteIntended},
+		{State: deleteCommit},
+	}, entries[:3])
+	assert.ElementsMatch(t, []deleteJournalEntry{
+		{Remote: "file2", State: deleteDone},
+		{Remote: "sub/file3", State: deleteDone},
+	}, entries[3:])
+}
+
+func TestSyncDeleteJournalWithheld(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	ci.DeleteJournal = filepath.Join(t.TempDir(), "deletes.json")
+	ci.IgnoreErrors = true // listing errors withhold deletions regardless
+
+	r.WriteFile("file1", "file1 contents", t1)
+	file2 := r.WriteObject(ctx, "file2", "file2 contents", t1)
+
+	s, err := newSyncCopyMove(ctx, r.Fremote, r.Flocal, "", fs.DeleteModeAfter, false, false, false)
+	require.NoError(t, err)
+	defer s.cancel()
+	obj, err := r.Fremote.NewObject(ctx, "file2")
+	require.NoError(t, err)
+	s.dstFiles = map[string]fs.Object{"file2": obj}
+
+	err = s.journaledDeleteFiles(errors.New("list failed"))
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, fs.ErrorNotDeleting))
+	assert.Contains(t, err.Error(), "withheld 1 deletions")
+	r.CheckRemoteItems(t, file2)
+
+	_, entries := readDeleteJournal(t, ci.DeleteJournal)
+	assert.Equal(t, []deleteJournalEntry{
+		{Remote: "file2", State: deleteWithheld, Error: "listing was incomplete: list failed"},
+	}, entries)
+}
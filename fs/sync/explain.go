@@ -0,0 +1,229 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/march"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/rc"
+)
+
+// Actions returned by Explain
+const (
+	ActionCopy   = "copy"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionSkip   = "skip"
+)
+
+// ReasonMissingOnSrc is the reason given for deleting a file
+const ReasonMissingOnSrc = "missing on src"
+
+// Explanation is what a sync or copy would do to a single file
+type Explanation struct {
+	Path   string `json:"path"`
+	Action string `json:"action"` // one of the Action constants
+	Reason string `json:"reason"` // why the action would be taken
+}
+
+// explainer is a march.Marcher which records what a sync would do
+// to each file rather than doing it
+type explainer struct {
+	ctx       context.Context
+	deleteDst bool // if set, files only in the destination are deleted
+	mu        sync.Mutex
+	fn        func(*Explanation) error
+	err       error // first error returned by fn
+}
+
+// explain calls fn with the action, serializing the calls
+func (e *explainer) explain(remote, action, reason string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return
+	}
+	e.err = e.fn(&Explanation{
+		Path:   remote,
+		Action: action,
+		Reason: reason,
+	})
+}
+
+// DstOnly is called for a DirEntry found only in the destination
+func (e *explainer) DstOnly(dst fs.DirEntry) (recurse bool) {
+	if !e.deleteDst {
+		return false
+	}
+	switch dst.(type) {
+	case fs.Object:
+		e.explain(dst.Remote(), ActionDelete, ReasonMissingOnSrc)
+	case fs.Directory:
+		return true
+	}
+	return false
+}
+
+// SrcOnly is called for a DirEntry found only in the source
+func (e *explainer) SrcOnly(src fs.DirEntry) (recurse bool) {
+	switch src.(type) {
+	case fs.Object:
+		e.explain(src.Remote(), ActionCopy, operations.ReasonMissingOnDst)
+	case fs.Directory:
+		return true
+	}
+	return false
+}
+
+// Match is called for a DirEntry found both in the source and destination
+func (e *explainer) Match(ctx context.Context, dst, src fs.DirEntry) (recurse bool) {
+	switch srcX := src.(type) {
+	case fs.Object:
+		dstX, ok := dst.(fs.Object)
+		if !ok {
+			e.explain(src.Remote(), ActionSkip, "can't overwrite directory with file")
+			return false
+		}
+		need, reason := operations.NeedTransferReason(e.ctx, dstX, srcX)
+		if need {
+			e.explain(src.Remote(), ActionUpdate, reason)
+		} else {
+			e.explain(src.Remote(), ActionSkip, reason)
+		}
+	case fs.Directory:
+		if _, ok := dst.(fs.Directory); ok {
+			return true
+		}
+		e.explain(src.Remote(), ActionSkip, "can't overwrite file with directory")
+	}
+	return false
+}
+
+// Explain works out what syncing fsrc to fdst would do to each file
+// without transferring or deleting anything and calls fn with the
+// result for each file. If deleteDst is false it explains a copy
+// instead, so files only in fdst are left alone.
+//
+// fn is not called concurrently. If it returns an error Explain stops
+// and returns it.
+func Explain(ctx context.Context, fdst, fsrc fs.Fs, deleteDst bool, fn func(*Explanation) error) error {
+	// Make sure nothing is changed while comparing, e.g. modification
+	// times being fixed up by equal
+	ctx, ci := fs.AddConfig(ctx)
+	ci.DryRun = true
+	fi := filter.GetConfig(ctx)
+	e := &explainer{
+		ctx:       ctx,
+		deleteDst: deleteDst,
+		fn:        fn,
+	}
+	m := &march.March{
+		Ctx:                    ctx,
+		Fdst:                   fdst,
+		Fsrc:                   fsrc,
+		NoTraverse:             ci.NoTraverse,
+		Callback:               e,
+		DstIncludeAll:          fi.Opt.DeleteExcluded,
+		NoCheckDest:            ci.NoCheckDest,
+		NoUnicodeNormalization: ci.NoUnicodeNormalization,
+	}
+	err := m.Run(ctx)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.err != nil {
+		return e.err
+	}
+	return err
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:         "sync/explain",
+		AuthRequired: true,
+		Fn:           rcExplain,
+		CanStream:    true,
+		Title:        "Show what a sync or copy would do to each file without doing it",
+		Parameters: []rc.Parameter{
+			{Name: "srcFs", Type: "string", Help: "a remote name string e.g. \"drive:src\" for the source", Required: true},
+			{Name: "dstFs", Type: "string", Help: "a remote name string e.g. \"drive:dst\" for the destination", Required: true},
+			{Name: "mode", Type: "string", Help: "\"sync\" (the default) or \"copy\""},
+		},
+		Help: `This compares the source with the destination in the same way as
+sync/sync or sync/copy but doesn't transfer or delete anything.
+
+This takes the following parameters:
+
+- srcFs - a remote name string e.g. "drive:src" for the source
+- dstFs - a remote name string e.g. "drive:dst" for the destination
+- mode - "sync" (the default) or "copy" - files only on the destination are only deleted by "sync"
+
+Returns:
+
+- actions - array of the actions for each file, sorted by path, with
+    - path - path of the file
+    - action - one of "copy", "update", "delete" or "skip"
+    - reason - why, e.g. "missing on dst", "size differs" or "unchanged"
+
+The flags which affect the comparison such as --size-only, --checksum
+and --ignore-existing are obeyed. Renames which --track-renames would
+find are shown as a copy and a delete.
+
+This can be streamed with _stream=true to get the actions unsorted as
+they are found.
+
+    rclone rc sync/explain srcFs=drive:src dstFs=drive:dst
+`,
+	})
+}
+
+// Explain what a sync or copy would do
+func rcExplain(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	srcFs, err := rc.GetFsNamed(ctx, in, "srcFs")
+	if err != nil {
+		return nil, err
+	}
+	dstFs, err := rc.GetFsNamed(ctx, in, "dstFs")
+	if err != nil {
+		return nil, err
+	}
+	mode, err := in.GetString("mode")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	var deleteDst bool
+	switch mode {
+	case "", "sync":
+		deleteDst = true
+	case "copy":
+	default:
+		return nil, rc.NewErrParamInvalid(fmt.Errorf("mode must be \"sync\" or \"copy\": %q", mode))
+	}
+	if operations.Same(dstFs, srcFs) {
+		return nil, rc.NewErrParamInvalid(errors.New("source and destination are the same"))
+	}
+	if stream := rc.GetStream(ctx); stream != nil {
+		return nil, Explain(ctx, dstFs, srcFs, deleteDst, func(item *Explanation) error {
+			return stream(item)
+		})
+	}
+	actions := []*Explanation{}
+	err = Explain(ctx, dstFs, srcFs, deleteDst, func(item *Explanation) error {
+		actions = append(actions, item)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].Path < actions[j].Path
+	})
+	return rc.Params{
+		"actions": actions,
+	}, nil
+}
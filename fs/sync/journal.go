@@ -0,0 +1,174 @@
+package sync
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// journal records the source files a sync has finished with for
+// --resume so that if the sync is interrupted, running it again skips
+// checking them.
+//
+// The journal is a file of JSON lines, the first of which says which
+// sync it is for, and is removed when the sync finishes successfully.
+type journal struct {
+	path   string
+	mu     sync.Mutex
+	out    *os.File                // file to append the finished files to
+	err    error                   // first error writing the journal
+	done   map[string]journalEntry // files finished by previous runs
+	header journalHeader
+}
+
+// journalHeader is the first line of the journal
+type journalHeader struct {
+	Src string `json:"src"`
+	Dst string `json:"dst"`
+}
+
+// journalEntry is a line of the journal for each finished file
+type journalEntry struct {
+	Remote  string    `json:"remote"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// openJournal opens the journal at path for syncing fsrc to fdst,
+// reading the files finished by previous runs if it exists.
+func openJournal(path string, fdst, fsrc fs.Fs) (j *journal, err error) {
+	j = &journal{
+		path: path,
+		done: make(map[string]journalEntry),
+		header: journalHeader{
+			Src: fs.ConfigString(fsrc),
+			Dst: fs.ConfigString(fdst),
+		},
+	}
+	haveHeader, needNewline, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+	j.out, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open --resume journal: %w", err)
+	}
+	if needNewline {
+		// Finish off a line cut short by an interruption
+		_, err = j.out.Write([]byte("\n"))
+	} else if !haveHeader {
+		err = j.write(j.header)
+	}
+	if err != nil {
+		_ = j.out.Close()
+		return nil, fmt.Errorf("failed to write --resume journal: %w", err)
+	}
+	if len(j.done) > 0 {
+		fs.Infof(nil, "Resuming: skipping checks of %d files finished by a previous run", len(j.done))
+	}
+	return j, nil
+}
+
+// load reads the journal if it exists
+func (j *journal) load() (haveHeader, needNewline bool, err error) {
+	in, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return false, false, nil
+	} else if err != nil {
+		return false, false, fmt.Errorf("failed to read --resume journal: %w", err)
+	}
+	defer fs.CheckClose(in, &err)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !haveHeader {
+			var header journalHeader
+			if json.Unmarshal(line, &header) != nil {
+				return false, false, fmt.Errorf("--resume journal %q is corrupted", j.path)
+			}
+			if header != j.header {
+				return false, false, fmt.Errorf("--resume journal %q is for syncing %q to %q not %q to %q", j.path, header.Src, header.Dst, j.header.Src, j.header.Dst)
+			}
+			haveHeader = true
+			continue
+		}
+		var entry journalEntry
+		if json.Unmarshal(line, &entry) != nil {
+			// Probably cut short by an interruption
+			continue
+		}
+		j.done[entry.Remote] = entry
+	}
+	if err = scanner.Err(); err != nil {
+		return false, false, fmt.Errorf("failed to read --resume journal: %w", err)
+	}
+	// Check the file ends with a newline
+	size, err := in.Seek(0, io.SeekEnd)
+	if err == nil && size > 0 {
+		last := []byte{0}
+		_, err = in.ReadAt(last, size-1)
+		needNewline = last[0] != '\n'
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("failed to read --resume journal: %w", err)
+	}
+	return haveHeader, needNewline, nil
+}
+
+// write a line to the journal
+func (j *journal) write(v interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = j.out.Write(append(buf, '\n'))
+	return err
+}
+
+// finished returns true if src was finished with by a previous run
+// and hasn't changed since
+func (j *journal) finished(ctx context.Context, src fs.Object) bool {
+	entry, ok := j.done[src.Remote()]
+	return ok && entry.Size == src.Size() && entry.ModTime.Equal(src.ModTime(ctx))
+}
+
+// add records that src has been finished with
+func (j *journal) add(ctx context.Context, src fs.Object) {
+	entry := journalEntry{
+		Remote:  src.Remote(),
+		Size:    src.Size(),
+		ModTime: src.ModTime(ctx),
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.err != nil {
+		return
+	}
+	j.err = j.write(entry)
+	if j.err != nil {
+		fs.Errorf(nil, "Failed to write to --resume journal - sync can't be resumed: %v", j.err)
+	}
+}
+
+// close the journal, removing it if the sync was successful
+func (j *journal) close(success bool) error {
+	err := j.out.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close --resume journal: %w", err)
+	}
+	if success {
+		err = os.Remove(j.path)
+		if err != nil {
+			return fmt.Errorf("failed to remove --resume journal: %w", err)
+		}
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math/bits"
+	"path"
 	"strconv"
 	"strings"
 	"sync"
@@ -186,7 +187,14 @@ func newLess(orderBy string) (less lessFn, fraction int, err error) {
 		return nil, fraction, nil
 	}
 	parts := strings.Split(strings.ToLower(orderBy), ",")
-	switch parts[0] {
+	// A "dir" prefix orders the files within each directory with
+	// the directories taken in name order
+	comparison := parts[0]
+	perDir := strings.HasPrefix(comparison, "dir")
+	if perDir {
+		comparison = comparison[3:]
+	}
+	switch comparison {
 	case "name":
 		less = func(a, b fs.ObjectPair) bool {
 			return a.Src.Remote() < b.Src.Remote()
@@ -200,6 +208,15 @@ func newLess(orderBy string) (less lessFn, fraction int, err error) {
 			ctx := context.Background()
 			return a.Src.ModTime(ctx).Before(b.Src.ModTime(ctx))
 		}
+	case "depth":
+		less = func(a, b fs.ObjectPair) bool {
+			aRemote, bRemote := a.Src.Remote(), b.Src.Remote()
+			aDepth, bDepth := strings.Count(aRemote, "/"), strings.Count(bRemote, "/")
+			if aDepth != bDepth {
+				return aDepth < bDepth
+			}
+			return aRemote < bRemote
+		}
 	default:
 		return nil, fraction, fmt.Errorf("unknown --order-by comparison %q", parts[0])
 	}
@@ -231,5 +248,18 @@ func newLess(orderBy string) (less lessFn, fraction int, err error) {
 			return !oldLess(a, b)
 		}
 	}
+	if perDir {
+		if fraction >= 0 {
+			return nil, fraction, fmt.Errorf("can't use mixed with per directory --order-by %q", parts[0])
+		}
+		dirLess := less
+		less = func(a, b fs.ObjectPair) bool {
+			aDir, bDir := path.Dir(a.Src.Remote()), path.Dir(b.Src.Remote())
+			if aDir != bDir {
+				return aDir < bDir
+			}
+			return dirLess(a, b)
+		}
+	}
 	return less, fraction, nil
 }
@@ -232,6 +232,18 @@ func TestNewLess(t *testing.T) {
 		assert.Contains(t, err.Error(), "unknown --order-by comparison")
 	})
 
+	t.Run("unknownPerDirComparison", func(t *testing.T) {
+		_, _, err := newLess("dirpotato")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown --order-by comparison")
+	})
+
+	t.Run("perDirMixed", func(t *testing.T) {
+		_, _, err := newLess("dirsize,mixed,75")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "can't use mixed")
+	})
+
 	t.Run("unknownSortDirection", func(t *testing.T) {
 		_, _, err := newLess("name,sideways")
 		require.Error(t, err)
@@ -262,6 +274,11 @@ func TestNewLess(t *testing.T) {
 		{"modtime,descending", true, true, -1},
 		{"modtime,mixed", false, false, 50},
 		{"modtime,mixed,30", false, false, 30},
+		{"depth", false, true, -1},
+		{"depth,desc", true, false, -1},
+		{"dirsize", true, false, -1},
+		{"dirsize,desc", false, true, -1},
+		{"dirname", false, true, -1},
 	} {
 		t.Run(test.orderBy, func(t *testing.T) {
 			less, gotFraction, err := newLess(test.orderBy)
@@ -275,4 +292,42 @@ func TestNewLess(t *testing.T) {
 		})
 	}
 
+	// Check ordering by depth and within directories
+	var (
+		ctx     = context.Background()
+		big     = fs.ObjectPair{Src: mockobject.New("a/big").WithContent([]byte("1234"), mockobject.SeekModeNone)}
+		small   = fs.ObjectPair{Src: mockobject.New("a/small").WithContent([]byte("1"), mockobject.SeekModeNone)}
+		deep    = fs.ObjectPair{Src: mockobject.New("a/b/deep").WithContent([]byte("12"), mockobject.SeekModeNone)}
+		top     = fs.ObjectPair{Src: mockobject.New("top").WithContent([]byte("123"), mockobject.SeekModeNone)}
+		remotes = func(pairs []fs.ObjectPair) (out []string) {
+			for _, pair := range pairs {
+				out = append(out, pair.Src.Remote())
+			}
+			return out
+		}
+	)
+	for _, test := range []struct {
+		orderBy string
+		want    []string
+	}{
+		{"depth", []string{"top", "a/big", "a/small", "a/b/deep"}},
+		{"depth,desc", []string{"a/b/deep", "a/small", "a/big", "top"}},
+		{"dirsize", []string{"top", "a/small", "a/big", "a/b/deep"}},
+		{"dirsize,desc", []string{"top", "a/big", "a/small", "a/b/deep"}},
+	} {
+		t.Run(test.orderBy, func(t *testing.T) {
+			p, err := newPipe(test.orderBy, func(n int, size int64) {}, 10)
+			require.NoError(t, err)
+			for _, pair := range []fs.ObjectPair{deep, small, top, big} {
+				require.True(t, p.Put(ctx, pair))
+			}
+			var got []fs.ObjectPair
+			for range test.want {
+				pair, ok := p.Get(ctx)
+				require.True(t, ok)
+				got = append(got, pair)
+			}
+			assert.Equal(t, test.want, remotes(got))
+		})
+	}
 }
@@ -0,0 +1,173 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// quarantineStateName is the name of the file in the root of
+// --quarantine-dir which records how long the files have been there
+const quarantineStateName = ".rclone-quarantine.json"
+
+// quarantine moves the files the sync deletes into --quarantine-dir
+// and removes them for good once they have been missing from the
+// source for --quarantine-runs runs.
+type quarantine struct {
+	f    fs.Fs // the quarantine dir
+	runs int   // the number of runs to keep files for
+}
+
+// quarantineState is saved in the quarantine dir between runs
+type quarantineState struct {
+	Runs map[string]int `json:"runs"` // number of runs each file has been missing from the source
+}
+
+// newQuarantine makes the Fs for --quarantine-dir checking it can be
+// used for syncing fsrc to fdst.
+func newQuarantine(ctx context.Context, fdst, fsrc fs.Fs) (*quarantine, error) {
+	ci := fs.GetConfig(ctx)
+	if ci.AppendOnly {
+		return nil, fserrors.FatalError(errors.New("can't use --quarantine-dir with --append-only as it moves files on the destination"))
+	}
+	if ci.QuarantineRuns < 1 {
+		return nil, fserrors.FatalError(fmt.Errorf("--quarantine-runs must be at least 1, not %d", ci.QuarantineRuns))
+	}
+	f, err := cache.Get(ctx, ci.QuarantineDir)
+	if err != nil {
+		return nil, fserrors.FatalError(fmt.Errorf("failed to make fs for --quarantine-dir %q: %w", ci.QuarantineDir, err))
+	}
+	if !operations.SameConfig(fdst, f) {
+		return nil, fserrors.FatalError(errors.New("parameter to --quarantine-dir has to be on the same remote as destination"))
+	}
+	if operations.Overlapping(fdst, f) {
+		return nil, fserrors.FatalError(errors.New("destination and parameter to --quarantine-dir mustn't overlap"))
+	}
+	if operations.Overlapping(fsrc, f) {
+		return nil, fserrors.FatalError(errors.New("source and parameter to --quarantine-dir mustn't overlap"))
+	}
+	if !operations.CanServerSideMove(f) {
+		return nil, fserrors.FatalError(errors.New("can't use --quarantine-dir on a remote which doesn't support server-side move or copy"))
+	}
+	return &quarantine{f: f, runs: ci.QuarantineRuns}, nil
+}
+
+// load reads the state saved by the last run, if any
+func (q *quarantine) load(ctx context.Context) (state quarantineState, err error) {
+	state.Runs = map[string]int{}
+	o, err := q.f.NewObject(ctx, quarantineStateName)
+	if err == fs.ErrorObjectNotFound || err == fs.ErrorDirNotFound {
+		return state, nil
+	} else if err != nil {
+		return state, err
+	}
+	in, err := o.Open(ctx)
+	if err != nil {
+		return state, err
+	}
+	defer fs.CheckClose(in, &err)
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return state, err
+	}
+	if state.Runs == nil {
+		state.Runs = map[string]int{}
+	}
+	return state, nil
+}
+
+// save the state for the next run
+func (q *quarantine) save(ctx context.Context, state quarantineState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	_, err = operations.Rcat(ctx, q.f, quarantineStateName, ioutil.NopCloser(bytes.NewReader(data)), time.Now())
+	return err
+}
+
+// finish is called at the end of a successful sync from fsrc.
+//
+// It counts another run for each file in the quarantine which is
+// still missing from the source and deletes those which have been
+// missing for long enough. Files which are back in the source have
+// been synced again so their quarantined copies are removed.
+func (q *quarantine) finish(ctx context.Context, fsrc fs.Fs) error {
+	state, err := q.load(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read --quarantine-dir state: %w", err)
+	}
+	var files []fs.Object
+	err = walk.ListR(ctx, q.f, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			if o.Remote() != quarantineStateName {
+				files = append(files, o)
+			}
+		})
+		return nil
+	})
+	if err != nil && err != fs.ErrorDirNotFound {
+		return fmt.Errorf("failed to list --quarantine-dir: %w", err)
+	}
+	fi := filter.GetConfig(ctx)
+	newState := quarantineState{Runs: make(map[string]int, len(files))}
+	deleted, restored := 0, 0
+	for _, o := range files {
+		remote := o.Remote()
+		if fi.IncludeRemote(remote) {
+			_, err = fsrc.NewObject(ctx, remote)
+			switch err {
+			case nil:
+				fs.Debugf(o, "Removing from --quarantine-dir as it is back in the source")
+				restored++
+				err = operations.DeleteFile(ctx, o)
+				if err != nil {
+					return err
+				}
+				continue
+			case fs.ErrorObjectNotFound, fs.ErrorIsDir, fs.ErrorNotAFile:
+			default:
+				// Don't count the run if the source couldn't be checked
+				return fmt.Errorf("failed to check source for file in --quarantine-dir: %w", err)
+			}
+		}
+		runs := state.Runs[remote] + 1
+		if runs >= q.runs {
+			fs.Debugf(o, "Deleting from --quarantine-dir as it has been missing from the source for %d runs", runs)
+			deleted++
+			err = operations.DeleteFile(ctx, o)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		newState.Runs[remote] = runs
+	}
+	if len(files) > 0 {
+		fs.Infof(q.f, "Quarantine: kept %d files, deleted %d missing from the source for %d runs, removed %d back in the source", len(newState.Runs), deleted, q.runs, restored)
+	}
+	err = q.save(ctx, newState)
+	if err != nil {
+		return fmt.Errorf("failed to save --quarantine-dir state: %w", err)
+	}
+	err = operations.Rmdirs(ctx, q.f, "", true)
+	if err != nil {
+		fs.Debugf(q.f, "Failed to remove empty directories from --quarantine-dir: %v", err)
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package sync
 
 import (
 	"context"
+	"errors"
 
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/rc"
 )
 
@@ -10,8 +12,15 @@ func init() {
 	for _, name := range []string{"sync", "copy", "move"} {
 		name := name
 		moveHelp := ""
+		params := []rc.Parameter{
+			{Name: "srcFs", Type: "string", Help: "a remote name string e.g. \"drive:src\" for the source", Required: true},
+			{Name: "dstFs", Type: "string", Help: "a remote name string e.g. \"drive:dst\" for the destination", Required: true},
+			{Name: "createEmptySrcDirs", Type: "boolean", Help: "create empty src directories on destination if set"},
+			{Name: "conflictResolve", Type: "string", Help: "what to do when a file differs on the destination, as --conflict-resolve"},
+		}
 		if name == "move" {
 			moveHelp = "- deleteEmptySrcDirs - delete empty src directories if set\n"
+			params = append(params, rc.Parameter{Name: "deleteEmptySrcDirs", Type: "boolean", Help: "delete empty src directories if set"})
 		}
 		rc.Add(rc.Call{
 			Path:         "sync/" + name,
@@ -19,12 +28,14 @@ func init() {
 			Fn: func(ctx context.Context, in rc.Params) (rc.Params, error) {
 				return rcSyncCopyMove(ctx, in, name)
 			},
-			Title: name + " a directory from source remote to destination remote",
+			Title:      name + " a directory from source remote to destination remote",
+			Parameters: params,
 			Help: `This takes the following parameters:
 
 - srcFs - a remote name string e.g. "drive:src" for the source
 - dstFs - a remote name string e.g. "drive:dst" for the destination
 - createEmptySrcDirs - create empty src directories on destination if set
+- conflictResolve - what to do when a file differs on the destination, one of source, dest, newer, larger or rename-both as for --conflict-resolve
 ` + moveHelp + `
 
 See the [` + name + ` command](/commands/rclone_` + name + `/) command for more information on the above.`,
@@ -46,6 +57,20 @@ func rcSyncCopyMove(ctx context.Context, in rc.Params, name string) (out rc.Para
 	if rc.NotErrParamNotFound(err) {
 		return nil, err
 	}
+	conflictResolve, err := in.GetString("conflictResolve")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	} else if err == nil {
+		var ci *fs.ConfigInfo
+		ctx, ci = fs.AddConfig(ctx)
+		err = ci.ConflictResolve.Set(conflictResolve)
+		if err != nil {
+			return nil, rc.NewErrParamInvalid(err)
+		}
+		if ci.ConflictResolve == fs.ConflictResolvePrompt {
+			return nil, rc.NewErrParamInvalid(errors.New("conflictResolve can't be prompt over rc"))
+		}
+	}
 	switch name {
 	case "sync":
 		return nil, Sync(ctx, dstFs, srcFs, createEmptySrcDirs)
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fs/rc"
 	"github.com/rclone/rclone/fstest"
 	"github.com/stretchr/testify/assert"
@@ -97,3 +98,47 @@ func TestRcSync(t *testing.T) {
 	r.CheckLocalItems(t, file1, file2)
 	r.CheckRemoteItems(t, file1, file2)
 }
+
+// sync/explain: show what a sync would do without doing it
+func TestRcExplain(t *testing.T) {
+	r, call := rcNewRun(t, "sync/explain")
+	defer r.Finalise()
+	r.Mkdir(context.Background(), r.Fremote)
+
+	file1 := r.WriteBoth(context.Background(), "file1", "file1 contents", t1)
+	file2 := r.WriteFile("subdir/file2", "file2 contents", t2)
+	file3 := r.WriteObject(context.Background(), "subdir/subsubdir/file3", "file3 contents", t3)
+	file4 := r.WriteFile("file4", "file4 contents", t1)
+	file4dst := r.WriteObject(context.Background(), "file4", "file4 new contents", t1)
+
+	r.CheckLocalItems(t, file1, file2, file4)
+	r.CheckRemoteItems(t, file1, file3, file4dst)
+
+	in := rc.Params{
+		"srcFs": r.LocalName,
+		"dstFs": r.FremoteName,
+	}
+	out, err := call.Fn(context.Background(), in)
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{
+		"actions": []*Explanation{
+			{Path: "file1", Action: ActionSkip, Reason: operations.ReasonUnchanged},
+			{Path: "file4", Action: ActionUpdate, Reason: operations.ReasonSizeDiffers},
+			{Path: "subdir/file2", Action: ActionCopy, Reason: operations.ReasonMissingOnDst},
+			{Path: "subdir/subsubdir/file3", Action: ActionDelete, Reason: ReasonMissingOnSrc},
+		},
+	}, out)
+
+	in["mode"] = "copy"
+	out, err = call.Fn(context.Background(), in)
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(out["actions"].([]*Explanation)))
+
+	in["mode"] = "potato"
+	_, err = call.Fn(context.Background(), in)
+	assert.Error(t, err)
+
+	// Nothing should have changed
+	r.CheckLocalItems(t, file1, file2, file4)
+	r.CheckRemoteItems(t, file1, file3, file4dst)
+}
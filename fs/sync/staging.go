@@ -0,0 +1,159 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/cache"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// staging transfers files into --staging-dir so they can be moved
+// into place in the destination with server-side moves once all the
+// transfers have succeeded.
+type staging struct {
+	f     fs.Fs // the staging dir
+	mu    sync.Mutex
+	files []stagedFile // files waiting to be moved into place
+}
+
+// stagedFile is a file in the staging dir
+type stagedFile struct {
+	staged fs.Object // the file in the staging dir
+	dst    fs.Object // the file it replaces in the destination, if any
+	src    fs.Object // the source it was transferred from
+	remote string    // its name in the destination
+}
+
+// newStaging makes the Fs for --staging-dir checking it can be used
+// for syncing fsrc to fdst.
+func newStaging(ctx context.Context, fdst, fsrc fs.Fs) (*staging, error) {
+	ci := fs.GetConfig(ctx)
+	f, err := cache.Get(ctx, ci.StagingDir)
+	if err != nil {
+		return nil, fserrors.FatalError(fmt.Errorf("failed to make fs for --staging-dir %q: %w", ci.StagingDir, err))
+	}
+	if !operations.SameConfig(fdst, f) {
+		return nil, fserrors.FatalError(errors.New("parameter to --staging-dir has to be on the same remote as destination"))
+	}
+	if operations.Overlapping(fdst, f) {
+		return nil, fserrors.FatalError(errors.New("destination and parameter to --staging-dir mustn't overlap"))
+	}
+	if operations.Overlapping(fsrc, f) {
+		return nil, fserrors.FatalError(errors.New("source and parameter to --staging-dir mustn't overlap"))
+	}
+	if fdst.Features().Move == nil {
+		return nil, fserrors.FatalError(errors.New("can't use --staging-dir on a remote which doesn't support server-side move"))
+	}
+	return &staging{f: f}, nil
+}
+
+// transfer copies src into the staging dir to be moved to remote in
+// the destination over dst later.
+func (st *staging) transfer(ctx context.Context, dst fs.Object, remote string, src fs.Object) error {
+	// Overwrite anything left over from a previous run
+	existing, err := st.f.NewObject(ctx, remote)
+	if err != nil {
+		existing = nil
+	}
+	staged, err := operations.Copy(ctx, st.f, existing, remote, src)
+	if err != nil {
+		return err
+	}
+	if staged == nil {
+		staged, err = st.f.NewObject(ctx, remote)
+		if err != nil {
+			return fmt.Errorf("failed to find file in --staging-dir: %w", err)
+		}
+	}
+	st.mu.Lock()
+	st.files = append(st.files, stagedFile{
+		staged: staged,
+		dst:    dst,
+		src:    src,
+		remote: remote,
+	})
+	st.mu.Unlock()
+	return nil
+}
+
+// commitStaged moves the files in the staging dir into place in the
+// destination now all the transfers have succeeded.
+func (s *syncCopyMove) commitStaged() {
+	files := s.staging.files
+	if len(files) == 0 {
+		return
+	}
+	fs.Infof(s.fdst, "Moving %d files from --staging-dir into place", len(files))
+	in := make(chan stagedFile, s.ci.Transfers)
+	var wg sync.WaitGroup
+	wg.Add(s.ci.Transfers)
+	for i := 0; i < s.ci.Transfers; i++ {
+		go func() {
+			defer wg.Done()
+			for file := range in {
+				s.processError(s.commitStagedFile(file))
+			}
+		}()
+	}
+outer:
+	for _, file := range files {
+		select {
+		case <-s.ctx.Done():
+			break outer
+		case in <- file:
+		}
+	}
+	close(in)
+	wg.Wait()
+	s.removeStagingDirs()
+}
+
+// commitStagedFile moves a single staged file into place
+func (s *syncCopyMove) commitStagedFile(file stagedFile) error {
+	dst := file.dst
+	if dst != nil && s.backupDir != nil {
+		err := operations.MoveBackupDir(s.ctx, s.backupDir, dst)
+		if err != nil {
+			return err
+		}
+		dst = nil
+	}
+	_, err := operations.Move(s.ctx, s.fdst, dst, file.remote, file.staged)
+	if err != nil {
+		return err
+	}
+	if s.journal != nil {
+		s.journal.add(s.ctx, file.src)
+	}
+	return nil
+}
+
+// discardStaged removes the files in the staging dir as the
+// transfers didn't all succeed, leaving the destination untouched.
+func (s *syncCopyMove) discardStaged() {
+	files := s.staging.files
+	if len(files) == 0 {
+		return
+	}
+	fs.Errorf(s.fdst, "Not moving %d files from --staging-dir into place as there were errors", len(files))
+	for _, file := range files {
+		err := file.staged.Remove(s.ctx)
+		if err != nil {
+			fs.Errorf(file.staged, "Failed to remove from --staging-dir: %v", err)
+		}
+	}
+	s.removeStagingDirs()
+}
+
+// removeStagingDirs removes the now empty directories of the staging dir
+func (s *syncCopyMove) removeStagingDirs() {
+	err := operations.Rmdirs(s.ctx, s.staging.f, "", false)
+	if err != nil {
+		fs.Debugf(s.staging.f, "Failed to remove --staging-dir: %v", err)
+	}
+}
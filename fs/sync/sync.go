@@ -45,6 +45,7 @@ type syncCopyMove struct {
 	trackRenamesStrategy   trackRenamesStrategy   // strategies used for tracking renames
 	dstFilesMu             sync.Mutex             // protect dstFiles
 	dstFiles               map[string]fs.Object   // dst files, always filled
+	dstObjects             int                    // number of objects found in dst, protected by dstFilesMu
 	srcFiles               map[string]fs.Object   // src files, only used if deleteBefore
 	srcFilesChan           chan fs.Object         // passes src objects
 	srcFilesResult         chan error             // error result of src listing
@@ -72,8 +73,15 @@ type syncCopyMove struct {
 	renameCheck            []fs.Object            // accumulate files to check for rename here
 	compareCopyDest        []fs.Fs                // place to check for files to server side copy
 	backupDir              fs.Fs                  // place to store overwrites/deletes
+	staging                *staging               // if set transfer files via --staging-dir
+	quarantine             *quarantine            // if set move deleted files to --quarantine-dir
+	deleteJournal          *deleteJournal         // if set record deletions here before making them
 	checkFirst             bool                   // if set run all the checkers before starting transfers
 	maxDurationEndTime     time.Time              // end time if --max-duration is set
+	journal                *journal               // finished files for --resume if set
+	adaptive               *adaptive              // tunes the checkers and transfers if set
+	checkersLimit          *adaptiveLimit         // limits the checkers working if set
+	transfersLimit         *adaptiveLimit         // limits the transfers working if set
 }
 
 type trackRenamesStrategy byte
@@ -96,7 +104,7 @@ func (strategy trackRenamesStrategy) leaf() bool {
 	return (strategy & trackRenamesStrategyLeaf) != 0
 }
 
-func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.DeleteMode, DoMove bool, deleteEmptySrcDirs bool, copyEmptySrcDirs bool) (*syncCopyMove, error) {
+func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, dir string, deleteMode fs.DeleteMode, DoMove bool, deleteEmptySrcDirs bool, copyEmptySrcDirs bool) (*syncCopyMove, error) {
 	if (deleteMode != fs.DeleteModeOff || DoMove) && operations.Overlapping(fdst, fsrc) {
 		return nil, fserrors.FatalError(fs.ErrorOverlapping)
 	}
@@ -111,7 +119,7 @@ func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 		DoMove:                 DoMove,
 		copyEmptySrcDirs:       copyEmptySrcDirs,
 		deleteEmptySrcDirs:     deleteEmptySrcDirs,
-		dir:                    "",
+		dir:                    dir,
 		srcFilesChan:           make(chan fs.Object, ci.Checkers+ci.Transfers),
 		srcFilesResult:         make(chan error, 1),
 		dstFilesResult:         make(chan error, 1),
@@ -155,12 +163,28 @@ func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 	}
 	// Input context - cancel this for graceful stop
 	s.inCtx, s.inCancel = context.WithCancel(s.ctx)
+	if ci.TopUp {
+		if s.deleteMode != fs.DeleteModeOff {
+			return nil, errors.New("can't use --top-up with sync: use copy instead")
+		}
+		if fi.ModTimeFrom.IsZero() {
+			return nil, errors.New("can't use --top-up without --max-age")
+		}
+		// Look up the recently changed files in the destination
+		// rather than listing it all
+		s.noTraverse = true
+	}
 	if s.noTraverse && s.deleteMode != fs.DeleteModeOff {
 		if !fi.HaveFilesFrom() {
 			fs.Errorf(nil, "Ignoring --no-traverse with sync")
 		}
 		s.noTraverse = false
 	}
+	if ci.AdaptiveConcurrency {
+		s.adaptive = newAdaptive(ctx)
+		s.checkersLimit = s.adaptive.checkers
+		s.transfersLimit = s.adaptive.transfers
+	}
 	s.trackRenamesStrategy, err = parseTrackRenamesStrategy(ci.TrackRenamesStrategy)
 	if err != nil {
 		return nil, err
@@ -215,6 +239,16 @@ func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 			return nil, err
 		}
 	}
+	// Make Fs for --quarantine-dir if required
+	if ci.QuarantineDir != "" && s.deleteMode != fs.DeleteModeOff {
+		if s.backupDir != nil {
+			return nil, fserrors.FatalError(errors.New("can't use --quarantine-dir with --backup-dir or --suffix"))
+		}
+		s.quarantine, err = newQuarantine(ctx, fdst, fsrc)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if len(ci.CompareDest) > 0 {
 		var err error
 		s.compareCopyDest, err = operations.GetCompareDest(ctx)
@@ -228,6 +262,34 @@ func newSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 			return nil, err
 		}
 	}
+	// Make Fs for --staging-dir if required
+	if ci.StagingDir != "" && s.deleteMode != fs.DeleteModeOnly && !ci.DryRun {
+		if s.DoMove {
+			return nil, fserrors.FatalError(errors.New("can't use --staging-dir with move"))
+		}
+		s.staging, err = newStaging(ctx, fdst, fsrc)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Open the --delete-journal if required - runSyncCopyMove has
+	// made sure the deletions are done after the transfers
+	if ci.DeleteJournal != "" && s.deleteMode == fs.DeleteModeAfter {
+		s.deleteJournal, err = newDeleteJournal(ci.DeleteJournal, fdst, fsrc)
+		if err != nil {
+			return nil, fserrors.FatalError(err)
+		}
+	}
+	if ci.Resume != "" && s.deleteMode != fs.DeleteModeOnly {
+		if s.DoMove {
+			fs.Errorf(fdst, "Ignoring --resume as it doesn't work with move, only sync or copy")
+		} else {
+			s.journal, err = openJournal(ci.Resume, fdst, fsrc)
+			if err != nil {
+				return nil, fserrors.FatalError(err)
+			}
+		}
+	}
 	return s, nil
 }
 
@@ -315,22 +377,46 @@ func (s *syncCopyMove) pairChecker(in *pipe, out *pipe, fraction int, wg *sync.W
 		}
 		src := pair.Src
 		var err error
+		// Skip files finished by a previous run with --resume
+		if s.journal != nil && pair.Dst != nil && s.journal.finished(s.ctx, src) {
+			fs.Debugf(src, "Skipping check as finished by a previous run")
+			continue
+		}
 		tr := accounting.Stats(s.ctx).NewCheckingTransfer(src)
 		// Check to see if can store this
 		if src.Storable() {
+			start := s.checkersLimit.acquire()
 			NoNeedTransfer, err := operations.CompareOrCopyDest(s.ctx, s.fdst, pair.Dst, pair.Src, s.compareCopyDest, s.backupDir)
 			if err != nil {
 				s.processError(err)
 			}
-			if !NoNeedTransfer && operations.NeedTransfer(s.ctx, pair.Dst, pair.Src) {
+			needTransfer := !NoNeedTransfer && operations.NeedTransfer(s.ctx, pair.Dst, pair.Src)
+			s.checkersLimit.release(start)
+			if needTransfer {
 				// If files are treated as immutable, fail if destination exists and does not match
 				if s.ci.Immutable && pair.Dst != nil {
 					err := fs.CountError(fserrors.NoRetryError(fs.ErrorImmutableModified))
 					fs.Errorf(pair.Dst, "Source and destination exist but do not match: %v", err)
 					s.processError(err)
 				} else {
-					// If destination already exists, then we must move it into --backup-dir if required
-					if pair.Dst != nil && s.backupDir != nil {
+					// If destination already exists, resolve the conflict according to --conflict-resolve
+					skip := false
+					if pair.Dst != nil {
+						var remote string
+						remote, pair.Dst, skip, err = operations.ResolveConflict(s.ctx, s.fdst, pair.Dst, src)
+						if err != nil {
+							s.processError(err)
+						} else if remote != src.Remote() {
+							pair.Src = &renamedObject{Object: src, remote: remote}
+						}
+					}
+					if skip {
+						if err == nil && s.journal != nil {
+							s.journal.add(s.ctx, src)
+						}
+					} else if pair.Dst != nil && s.backupDir != nil && s.staging == nil {
+						// If destination already exists, then we must move it into --backup-dir if required
+						// now, or when the staged file is moved into place if using --staging-dir
 						err := operations.MoveBackupDir(s.ctx, s.backupDir, pair.Dst)
 						if err != nil {
 							s.processError(err)
@@ -350,6 +436,9 @@ func (s *syncCopyMove) pairChecker(in *pipe, out *pipe, fraction int, wg *sync.W
 					}
 				}
 			} else {
+				if s.journal != nil {
+					s.journal.add(s.ctx, src)
+				}
 				// If moving need to delete the files we don't need to copy
 				if s.DoMove {
 					// Delete src if no error on copy
@@ -396,21 +485,43 @@ func (s *syncCopyMove) pairCopyOrMove(ctx context.Context, in *pipe, fdst fs.Fs,
 		if !ok {
 			return
 		}
-		src := pair.Src
-		if s.DoMove {
-			_, err = operations.Move(ctx, fdst, pair.Dst, src.Remote(), src)
+		src, remote := pair.Src, pair.Src.Remote()
+		if renamed, ok := src.(*renamedObject); ok {
+			src, remote = renamed.Object, renamed.remote
+		}
+		start := s.transfersLimit.acquire()
+		if s.staging != nil {
+			err = s.staging.transfer(ctx, pair.Dst, remote, src)
+		} else if s.DoMove {
+			_, err = operations.Move(ctx, fdst, pair.Dst, remote, src)
 		} else {
-			_, err = operations.Copy(ctx, fdst, pair.Dst, src.Remote(), src)
+			_, err = operations.Copy(ctx, fdst, pair.Dst, remote, src)
+		}
+		s.transfersLimit.release(start)
+		// Staged files are journaled when they are moved into place
+		if err == nil && s.journal != nil && s.staging == nil {
+			s.journal.add(s.ctx, src)
 		}
 		s.processError(err)
 	}
 }
 
+// renamedObject is a source object which is to be transferred to a
+// different name on the destination
+type renamedObject struct {
+	fs.Object
+	remote string
+}
+
 // This starts the background checkers.
 func (s *syncCopyMove) startCheckers() {
-	s.checkerWg.Add(s.ci.Checkers)
-	for i := 0; i < s.ci.Checkers; i++ {
-		fraction := (100 * i) / s.ci.Checkers
+	checkers := s.ci.Checkers
+	if s.adaptive != nil {
+		checkers *= adaptiveMaxFactor
+	}
+	s.checkerWg.Add(checkers)
+	for i := 0; i < checkers; i++ {
+		fraction := (100 * i) / checkers
 		go s.pairChecker(s.toBeChecked, s.toBeUploaded, fraction, &s.checkerWg)
 	}
 }
@@ -424,9 +535,13 @@ func (s *syncCopyMove) stopCheckers() {
 
 // This starts the background transfers
 func (s *syncCopyMove) startTransfers() {
-	s.transfersWg.Add(s.ci.Transfers)
-	for i := 0; i < s.ci.Transfers; i++ {
-		fraction := (100 * i) / s.ci.Transfers
+	transfers := s.ci.Transfers
+	if s.adaptive != nil {
+		transfers *= adaptiveMaxFactor
+	}
+	s.transfersWg.Add(transfers)
+	for i := 0; i < transfers; i++ {
+		fraction := (100 * i) / transfers
 		go s.pairCopyOrMove(s.ctx, s.toBeUploaded, s.fdst, fraction, &s.transfersWg)
 	}
 }
@@ -491,7 +606,7 @@ func (s *syncCopyMove) startDeleters() {
 	s.deletersWg.Add(1)
 	go func() {
 		defer s.deletersWg.Done()
-		err := operations.DeleteFilesWithBackupDir(s.ctx, s.deleteFilesCh, s.backupDir)
+		err := operations.DeleteFilesWithBackupDir(s.ctx, s.deleteFilesCh, s.deleteBackupDir())
 		s.processError(err)
 	}()
 }
@@ -516,6 +631,20 @@ func (s *syncCopyMove) deleteFiles(checkSrcMap bool) error {
 		return fs.ErrorNotDeleting
 	}
 
+	// Check the deletes are within --max-delete before starting
+	toDeleteCount := len(s.dstFiles)
+	if checkSrcMap {
+		toDeleteCount = 0
+		for remote := range s.dstFiles {
+			if _, exists := s.srcFiles[remote]; !exists {
+				toDeleteCount++
+			}
+		}
+	}
+	if err := s.checkMaxDelete(toDeleteCount); err != nil {
+		return err
+	}
+
 	// Delete the spare files
 	toDelete := make(fs.ObjectsChan, s.ci.Transfers)
 	go func() {
@@ -538,7 +667,33 @@ func (s *syncCopyMove) deleteFiles(checkSrcMap bool) error {
 		}
 		close(toDelete)
 	}()
-	return operations.DeleteFilesWithBackupDir(s.ctx, toDelete, s.backupDir)
+	return operations.DeleteFilesWithBackupDir(s.ctx, toDelete, s.deleteBackupDir())
+}
+
+// deleteBackupDir returns where deleted files should be moved to or
+// nil if they should be deleted
+func (s *syncCopyMove) deleteBackupDir() fs.Fs {
+	if s.quarantine != nil {
+		return s.quarantine.f
+	}
+	return s.backupDir
+}
+
+// checkMaxDelete checks that deleting n files is within --max-delete
+// before any are deleted, so a sync from an accidentally empty source
+// can't wipe the destination.
+func (s *syncCopyMove) checkMaxDelete(n int) error {
+	var err error
+	if s.ci.MaxDelete >= 0 && int64(n) > s.ci.MaxDelete {
+		err = fmt.Errorf("%w: would delete %d files which is more than %d", fs.ErrorMaxDeleteReached, n, s.ci.MaxDelete)
+	} else if s.ci.MaxDeletePercent >= 0 && n > 0 && n*100 > s.ci.MaxDeletePercent*s.dstObjects {
+		err = fmt.Errorf("%w: would delete %d of %d files (%d%%) which is more than %d%%", fs.ErrorMaxDeleteReached, n, s.dstObjects, n*100/s.dstObjects, s.ci.MaxDeletePercent)
+	}
+	if err != nil {
+		fs.Errorf(s.fdst, "%v", err)
+		return fserrors.FatalError(err)
+	}
+	return nil
 }
 
 // This deletes the empty directories in the slice passed in.  It
@@ -702,25 +857,37 @@ func (s *syncCopyMove) popRenameMap(hash string, src fs.Object) (dst fs.Object)
 	dsts, ok := s.renameMap[hash]
 	if ok && len(dsts) > 0 {
 		// Element to remove
-		i := 0
+		i := -1
 
-		// If using track renames strategy modtime then we need to check the modtimes here
+		var srcModTime time.Time
 		if s.trackRenamesStrategy.modTime() {
-			i = -1
-			srcModTime := src.ModTime(s.ctx)
-			for j, dst := range dsts {
+			srcModTime = src.ModTime(s.ctx)
+		}
+		srcLeaf := path.Base(src.Remote())
+		for j, dst := range dsts {
+			// If using track renames strategy modtime then we need to check the modtimes here
+			if s.trackRenamesStrategy.modTime() {
 				dstModTime := dst.ModTime(s.ctx)
 				dt := dstModTime.Sub(srcModTime)
-				if dt < s.modifyWindow && dt > -s.modifyWindow {
-					i = j
-					break
+				if dt >= s.modifyWindow || dt <= -s.modifyWindow {
+					continue
 				}
 			}
-			// If nothing matched then return nil
 			if i < 0 {
-				return nil
+				i = j
+			}
+			// If there are several files with the same contents
+			// prefer the one with the same name as it is most
+			// likely to have been moved from another directory
+			if path.Base(dst.Remote()) == srcLeaf {
+				i = j
+				break
 			}
 		}
+		// If nothing matched then return nil
+		if i < 0 {
+			return nil
+		}
 
 		// Remove the entry and return it
 		dst = dsts[i]
@@ -806,7 +973,11 @@ func (s *syncCopyMove) tryRename(src fs.Object) bool {
 	delete(s.dstFiles, dst.Remote())
 	s.dstFilesMu.Unlock()
 
-	fs.Infof(src, "Renamed from %q", dst.Remote())
+	if path.Base(dst.Remote()) == path.Base(src.Remote()) {
+		fs.Infof(src, "Moved from %q", dst.Remote())
+	} else {
+		fs.Infof(src, "Renamed from %q", dst.Remote())
+	}
 	return true
 }
 
@@ -824,9 +995,20 @@ var errorMaxDurationReached = fserrors.FatalError(errors.New("Max transfer durat
 func (s *syncCopyMove) run() error {
 	if operations.Same(s.fdst, s.fsrc) {
 		fs.Errorf(s.fdst, "Nothing to do as source and destination are the same")
+		if s.deleteJournal != nil {
+			_ = s.deleteJournal.close()
+		}
+		if s.journal != nil {
+			return s.journal.close(true)
+		}
 		return nil
 	}
 
+	// Start tuning the checkers and transfers if required
+	if s.adaptive != nil {
+		go s.adaptive.run(s.ctx)
+	}
+
 	// Start background checking and transferring pipeline
 	s.startCheckers()
 	s.startRenamers()
@@ -850,7 +1032,8 @@ func (s *syncCopyMove) run() error {
 		NoCheckDest:            s.noCheckDest,
 		NoUnicodeNormalization: s.noUnicodeNormalization,
 	}
-	s.processError(m.Run(s.ctx))
+	listErr := m.Run(s.ctx)
+	s.processError(listErr)
 
 	s.stopTrackRenames()
 	if s.trackRenames {
@@ -873,6 +1056,16 @@ func (s *syncCopyMove) run() error {
 	}
 	s.stopRenamers()
 	s.stopTransfers()
+
+	// Move the staged files into place if all the transfers succeeded
+	if s.staging != nil {
+		if s.currentError() != nil {
+			s.discardStaged()
+		} else {
+			s.commitStaged()
+		}
+	}
+
 	s.stopDeleters()
 
 	if s.copyEmptySrcDirs {
@@ -880,7 +1073,9 @@ func (s *syncCopyMove) run() error {
 	}
 
 	// Delete files after
-	if s.deleteMode == fs.DeleteModeAfter {
+	if s.deleteJournal != nil {
+		s.processError(s.journaledDeleteFiles(listErr))
+	} else if s.deleteMode == fs.DeleteModeAfter {
 		if s.currentError() != nil && !s.ci.IgnoreErrors {
 			fs.Errorf(s.fdst, "%v", fs.ErrorNotDeleting)
 		} else {
@@ -890,13 +1085,23 @@ func (s *syncCopyMove) run() error {
 
 	// Prune empty directories
 	if s.deleteMode != fs.DeleteModeOff {
-		if s.currentError() != nil && !s.ci.IgnoreErrors {
+		if s.currentError() != nil && (!s.ci.IgnoreErrors || (s.deleteJournal != nil && listErr != nil)) {
 			fs.Errorf(s.fdst, "%v", fs.ErrorNotDeletingDirs)
 		} else {
 			s.processError(s.deleteEmptyDirectories(s.ctx, s.fdst, s.dstEmptyDirs))
 		}
 	}
 
+	// Delete the files which have been in --quarantine-dir long
+	// enough - only a sync of the whole source counts as a run
+	if s.quarantine != nil && s.dir == "" && !s.ci.DryRun {
+		if s.currentError() != nil {
+			fs.Errorf(s.fdst, "Not updating --quarantine-dir as there were errors")
+		} else {
+			s.processError(s.quarantine.finish(s.ctx, s.fsrc))
+		}
+	}
+
 	// Delete empty fsrc subdirectories
 	// if DoMove and --delete-empty-src-dirs flag is set
 	if s.DoMove && s.deleteEmptySrcDirs {
@@ -918,6 +1123,11 @@ func (s *syncCopyMove) run() error {
 		fs.Infof(nil, "There was nothing to transfer")
 	}
 
+	// Remove the --resume journal if everything was finished
+	if s.journal != nil {
+		s.processError(s.journal.close(s.currentError() == nil))
+	}
+
 	// cancel the context to free resources
 	s.cancel()
 	return s.currentError()
@@ -935,6 +1145,7 @@ func (s *syncCopyMove) DstOnly(dst fs.DirEntry) (recurse bool) {
 			// record object as needs deleting
 			s.dstFilesMu.Lock()
 			s.dstFiles[x.Remote()] = x
+			s.dstObjects++
 			s.dstFilesMu.Unlock()
 		case fs.DeleteModeDuring, fs.DeleteModeOnly:
 			select {
@@ -1023,6 +1234,11 @@ func (s *syncCopyMove) Match(ctx context.Context, dst, src fs.DirEntry) (recurse
 		}
 		dstX, ok := dst.(fs.Object)
 		if ok {
+			if s.deleteMode == fs.DeleteModeAfter {
+				s.dstFilesMu.Lock()
+				s.dstObjects++
+				s.dstFilesMu.Unlock()
+			}
 			ok = s.toBeChecked.Put(s.ctx, fs.ObjectPair{Src: srcX, Dst: dstX})
 			if !ok {
 				return false
@@ -1065,18 +1281,26 @@ func (s *syncCopyMove) Match(ctx context.Context, dst, src fs.DirEntry) (recurse
 // If DoMove is true then files will be moved instead of copied
 //
 // dir is the start directory, "" for root
-func runSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.DeleteMode, DoMove bool, deleteEmptySrcDirs bool, copyEmptySrcDirs bool) error {
+func runSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, dir string, deleteMode fs.DeleteMode, DoMove bool, deleteEmptySrcDirs bool, copyEmptySrcDirs bool) error {
 	ci := fs.GetConfig(ctx)
 	if deleteMode != fs.DeleteModeOff && DoMove {
 		return fserrors.FatalError(errors.New("can't delete and move at the same time"))
 	}
+	if ci.MaxDeletePercent >= 0 && (deleteMode == fs.DeleteModeBefore || deleteMode == fs.DeleteModeDuring) {
+		return fserrors.FatalError(errors.New("can't use --max-delete with a percentage with --delete-before or --delete-during"))
+	}
+	// --delete-journal needs the listings to finish before deleting
+	if ci.DeleteJournal != "" && (deleteMode == fs.DeleteModeBefore || deleteMode == fs.DeleteModeDuring) {
+		fs.Logf(fdst, "Deleting files after transferring as --delete-journal needs the listings to finish first")
+		deleteMode = fs.DeleteModeAfter
+	}
 	// Run an extra pass to delete only
 	if deleteMode == fs.DeleteModeBefore {
 		if ci.TrackRenames {
 			return fserrors.FatalError(errors.New("can't use --delete-before with --track-renames"))
 		}
 		// only delete stuff during in this pass
-		do, err := newSyncCopyMove(ctx, fdst, fsrc, fs.DeleteModeOnly, false, deleteEmptySrcDirs, copyEmptySrcDirs)
+		do, err := newSyncCopyMove(ctx, fdst, fsrc, dir, fs.DeleteModeOnly, false, deleteEmptySrcDirs, copyEmptySrcDirs)
 		if err != nil {
 			return err
 		}
@@ -1087,7 +1311,7 @@ func runSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 		// Next pass does a copy only
 		deleteMode = fs.DeleteModeOff
 	}
-	do, err := newSyncCopyMove(ctx, fdst, fsrc, deleteMode, DoMove, deleteEmptySrcDirs, copyEmptySrcDirs)
+	do, err := newSyncCopyMove(ctx, fdst, fsrc, dir, deleteMode, DoMove, deleteEmptySrcDirs, copyEmptySrcDirs)
 	if err != nil {
 		return err
 	}
@@ -1097,17 +1321,17 @@ func runSyncCopyMove(ctx context.Context, fdst, fsrc fs.Fs, deleteMode fs.Delete
 // Sync fsrc into fdst
 func Sync(ctx context.Context, fdst, fsrc fs.Fs, copyEmptySrcDirs bool) error {
 	ci := fs.GetConfig(ctx)
-	return runSyncCopyMove(ctx, fdst, fsrc, ci.DeleteMode, false, false, copyEmptySrcDirs)
+	return runSyncCopyMove(ctx, fdst, fsrc, "", ci.DeleteMode, false, false, copyEmptySrcDirs)
 }
 
 // CopyDir copies fsrc into fdst
 func CopyDir(ctx context.Context, fdst, fsrc fs.Fs, copyEmptySrcDirs bool) error {
-	return runSyncCopyMove(ctx, fdst, fsrc, fs.DeleteModeOff, false, false, copyEmptySrcDirs)
+	return runSyncCopyMove(ctx, fdst, fsrc, "", fs.DeleteModeOff, false, false, copyEmptySrcDirs)
 }
 
 // moveDir moves fsrc into fdst
 func moveDir(ctx context.Context, fdst, fsrc fs.Fs, deleteEmptySrcDirs bool, copyEmptySrcDirs bool) error {
-	return runSyncCopyMove(ctx, fdst, fsrc, fs.DeleteModeOff, true, deleteEmptySrcDirs, copyEmptySrcDirs)
+	return runSyncCopyMove(ctx, fdst, fsrc, "", fs.DeleteModeOff, true, deleteEmptySrcDirs, copyEmptySrcDirs)
 }
 
 // MoveDir moves fsrc into fdst
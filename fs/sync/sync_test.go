@@ -6,6 +6,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
@@ -19,6 +21,7 @@ import (
 	"github.com/rclone/rclone/fs/hash"
 	"github.com/rclone/rclone/fs/operations"
 	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/fstest/mockobject"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/text/unicode/norm"
@@ -1186,6 +1189,277 @@ func TestSyncWithTrackRenamesStrategyLeaf(t *testing.T) {
 	}
 }
 
+func TestPopRenameMapPrefersLeaf(t *testing.T) {
+	s := &syncCopyMove{
+		ctx:       context.Background(),
+		renameMap: map[string][]fs.Object{},
+	}
+	a := mockobject.New("a/one.txt")
+	b := mockobject.New("b/two.txt")
+	s.pushRenameMap("3,hash", a)
+	s.pushRenameMap("3,hash", b)
+
+	assert.Nil(t, s.popRenameMap("4,hash", mockobject.New("c/two.txt")))
+	assert.Equal(t, b, s.popRenameMap("3,hash", mockobject.New("c/two.txt")))
+	assert.Equal(t, a, s.popRenameMap("3,hash", mockobject.New("c/three.txt")))
+	assert.Nil(t, s.popRenameMap("3,hash", mockobject.New("c/one.txt")))
+	assert.Equal(t, 0, len(s.renameMap))
+}
+
+func TestSyncResume(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	ci.Resume = filepath.Join(t.TempDir(), "journal")
+
+	file1 := r.WriteFile("file1", "potato", t1)
+	file2 := r.WriteFile("sub/file2", "sausage", t2)
+	file1dst := r.WriteObject(ctx, "file1", "tomato", t2)
+	r.CheckLocalItems(t, file1, file2)
+	r.CheckRemoteItems(t, file1dst)
+
+	// Pretend a previous run finished with file1
+	src1, err := r.Flocal.NewObject(ctx, "file1")
+	require.NoError(t, err)
+	j, err := openJournal(ci.Resume, r.Fremote, r.Flocal)
+	require.NoError(t, err)
+	j.add(ctx, src1)
+	require.NoError(t, j.close(false))
+
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, Sync(ctx, r.Fremote, r.Flocal, false))
+
+	// file1 should not have been checked so not updated
+	r.CheckRemoteItems(t, file1dst, file2)
+	_, err = os.Stat(ci.Resume)
+	assert.True(t, os.IsNotExist(err), "journal should be removed")
+
+	// A journal for a different sync is refused
+	j, err = openJournal(ci.Resume, r.Flocal, r.Fremote)
+	require.NoError(t, err)
+	require.NoError(t, j.close(false))
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is for syncing")
+}
+
+func TestCopyConflictResolve(t *testing.T) {
+	src1 := fstest.NewItem("file1", "potatoes", t1) // larger but older
+	src2 := fstest.NewItem("file2", "egg", t2)      // newer but smaller
+	dst1 := fstest.NewItem("file1", "tomato", t2)
+	dst2 := fstest.NewItem("file2", "sausage", t1)
+	renamed := func(item fstest.Item, suffix string) fstest.Item {
+		item.Path += suffix
+		return item
+	}
+	for _, test := range []struct {
+		resolve fs.ConflictResolve
+		want    []fstest.Item
+	}{
+		{fs.ConflictResolveSource, []fstest.Item{src1, src2}},
+		{fs.ConflictResolveDest, []fstest.Item{dst1, dst2}},
+		{fs.ConflictResolveNewer, []fstest.Item{dst1, src2}},
+		{fs.ConflictResolveLarger, []fstest.Item{src1, dst2}},
+		{fs.ConflictResolveRenameBoth, []fstest.Item{
+			renamed(src1, operations.ConflictSuffixSource),
+			renamed(src2, operations.ConflictSuffixSource),
+			renamed(dst1, operations.ConflictSuffixDest),
+			renamed(dst2, operations.ConflictSuffixDest),
+		}},
+	} {
+		t.Run(test.resolve.String(), func(t *testing.T) {
+			ctx := context.Background()
+			ctx, ci := fs.AddConfig(ctx)
+			r := fstest.NewRun(t)
+			defer r.Finalise()
+			if test.resolve == fs.ConflictResolveRenameBoth && !operations.CanServerSideMove(r.Fremote) {
+				t.Skip("Skipping test as remote does not support server-side move or copy")
+			}
+
+			r.WriteFile(src1.Path, "potatoes", src1.ModTime)
+			r.WriteFile(src2.Path, "egg", src2.ModTime)
+			r.WriteObject(ctx, dst1.Path, "tomato", dst1.ModTime)
+			r.WriteObject(ctx, dst2.Path, "sausage", dst2.ModTime)
+
+			ci.ConflictResolve = test.resolve
+			require.NoError(t, CopyDir(ctx, r.Fremote, r.Flocal, false))
+
+			r.CheckLocalItems(t, src1, src2)
+			r.CheckRemoteItems(t, test.want...)
+		})
+	}
+}
+
+func TestSyncMaxDeletePercent(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	file1 := r.WriteFile("a", "a", t1)
+	r.CheckLocalItems(t, file1)
+	dst1 := r.WriteObject(ctx, "a", "a", t1)
+	dst2 := r.WriteObject(ctx, "b", "b", t1)
+	dst3 := r.WriteObject(ctx, "c", "c", t1)
+	dst4 := r.WriteObject(ctx, "d", "d", t1)
+	r.CheckRemoteItems(t, dst1, dst2, dst3, dst4)
+
+	// Deleting 3 of 4 files is more than 50% so nothing is deleted
+	ci.MaxDeletePercent = 50
+	accounting.GlobalStats().ResetCounters()
+	err := Sync(ctx, r.Fremote, r.Flocal, false)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, fs.ErrorMaxDeleteReached))
+	assert.True(t, fserrors.IsFatalError(err))
+	r.CheckRemoteItems(t, dst1, dst2, dst3, dst4)
+
+	// The absolute limit is checked before deleting too
+	ci.MaxDeletePercent = -1
+	ci.MaxDelete = 2
+	accounting.GlobalStats().ResetCounters()
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	assert.True(t, errors.Is(err, fs.ErrorMaxDeleteReached))
+	r.CheckRemoteItems(t, dst1, dst2, dst3, dst4)
+
+	// 75% is within the limit
+	ci.MaxDelete = -1
+	ci.MaxDeletePercent = 75
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, Sync(ctx, r.Fremote, r.Flocal, false))
+	r.CheckRemoteItems(t, file1)
+
+	// A percentage can't be used with --delete-during
+	ci.DeleteMode = fs.DeleteModeDuring
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--delete-during")
+}
+
+func TestSyncStagingDir(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	if r.Fremote.Features().Move == nil {
+		t.Skip("Skipping test as remote does not support server-side move")
+	}
+
+	ci.StagingDir = r.FremoteName + "/staging"
+	fdst, err := fs.NewFs(ctx, r.FremoteName+"/dst")
+	require.NoError(t, err)
+
+	file1 := r.WriteFile("one", "one new", t2)
+	file2 := r.WriteFile("sub/two", "two", t1)
+	r.CheckLocalItems(t, file1, file2)
+	r.WriteObject(ctx, "dst/one", "one", t1)
+	r.WriteObject(ctx, "dst/three", "three", t1)
+
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, Sync(ctx, fdst, r.Flocal, false))
+
+	file1dst, file2dst := file1, file2
+	file1dst.Path, file2dst.Path = "dst/one", "dst/sub/two"
+	r.CheckRemoteItems(t, file1dst, file2dst)
+	_, err = r.Fremote.List(ctx, "staging")
+	assert.Equal(t, fs.ErrorDirNotFound, err)
+
+	// Can't use with move
+	err = MoveDir(ctx, fdst, r.Flocal, false, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--staging-dir")
+}
+
+func TestSyncQuarantineDir(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	if !operations.CanServerSideMove(r.Fremote) {
+		t.Skip("Skipping test as remote does not support server-side move")
+	}
+
+	ci.QuarantineDir = r.FremoteName + "/quarantine"
+	ci.QuarantineRuns = 2
+	fdst, err := fs.NewFs(ctx, r.FremoteName+"/dst")
+	require.NoError(t, err)
+
+	file1 := r.WriteFile("one", "one", t1)
+	r.WriteObject(ctx, "dst/one", "one", t1)
+	r.WriteObject(ctx, "dst/two", "two", t1)
+	r.WriteObject(ctx, "dst/sub/three", "three", t1)
+
+	runSync := func() {
+		accounting.GlobalStats().ResetCounters()
+		require.NoError(t, Sync(ctx, fdst, r.Flocal, false))
+	}
+	quarantined := func(remote string) bool {
+		_, err := r.Fremote.NewObject(ctx, "quarantine/"+remote)
+		return err == nil
+	}
+
+	// First run moves the deleted files into the quarantine
+	runSync()
+	fstest.CheckListingWithPrecision(t, fdst, []fstest.Item{file1}, nil, fs.GetModifyWindow(ctx, fdst, r.Flocal))
+	assert.True(t, quarantined("two"))
+	assert.True(t, quarantined("sub/three"))
+
+	// three comes back in the source so is removed from the quarantine
+	r.WriteFile("sub/three", "three", t1)
+	runSync()
+	assert.True(t, quarantined("two"))
+	assert.False(t, quarantined("sub/three"))
+
+	// two has now been missing for 2 runs so is deleted
+	runSync()
+	assert.False(t, quarantined("two"))
+	_, err = fdst.NewObject(ctx, "sub/three")
+	require.NoError(t, err)
+
+	// Can't use with --backup-dir
+	ci.BackupDir = r.FremoteName + "/backup"
+	err = Sync(ctx, fdst, r.Flocal, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--quarantine-dir")
+}
+
+func TestCopyTopUp(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	now := fstest.Time(time.Now().Format(time.RFC3339))
+	file1 := r.WriteFile("old", "old", t1)
+	file2 := r.WriteFile("sub/new", "new", now)
+	file3 := r.WriteObject(ctx, "dst-only", "dst-only", t1)
+	r.CheckLocalItems(t, file1, file2)
+	r.CheckRemoteItems(t, file3)
+
+	ci.TopUp = true
+
+	// Needs --max-age
+	err := CopyDir(ctx, r.Fremote, r.Flocal, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--max-age")
+
+	opt := filter.DefaultOpt
+	opt.MaxAge = fs.Duration(time.Hour)
+	fi, err := filter.NewFilter(&opt)
+	require.NoError(t, err)
+	ctx = filter.ReplaceConfig(ctx, fi)
+
+	// Can't be used with sync
+	err = Sync(ctx, r.Fremote, r.Flocal, false)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--top-up")
+
+	accounting.GlobalStats().ResetCounters()
+	require.NoError(t, CopyDir(ctx, r.Fremote, r.Flocal, false))
+	r.CheckRemoteItems(t, file2, file3)
+}
+
 func toyFileTransfers(r *fstest.Run) int64 {
 	remote := r.Fremote.Name()
 	transfers := 1
@@ -1590,6 +1864,41 @@ func TestSyncMultipleCompareDest(t *testing.T) {
 	r.CheckRemoteItems(t, fdest1, fdest2, fdest3)
 }
 
+// Test with multiple CopyDest checked in order
+func TestSyncMultipleCopyDest(t *testing.T) {
+	ctx := context.Background()
+	ctx, ci := fs.AddConfig(ctx)
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+
+	if r.Fremote.Features().Copy == nil {
+		t.Skip("Skipping test as remote does not support server-side copy")
+	}
+
+	ci.CopyDest = []string{r.FremoteName + "/pre-dest2", r.FremoteName + "/pre-dest1"}
+
+	fsrc1 := r.WriteFile("1", "1", t1)
+	fsrc2 := r.WriteFile("2", "2", t2)
+	fsrc3 := r.WriteFile("3", "3", t1)
+	r.CheckLocalItems(t, fsrc1, fsrc2, fsrc3)
+
+	// 1 is only in the older generation and 2 is in both but
+	// only matches in the older one
+	fdest1 := r.WriteObject(ctx, "pre-dest1/1", "1", t1)
+	fdest2old := r.WriteObject(ctx, "pre-dest1/2", "2", t2)
+	fdest2new := r.WriteObject(ctx, "pre-dest2/2", "two", t3)
+	r.CheckRemoteItems(t, fdest1, fdest2old, fdest2new)
+
+	accounting.GlobalStats().ResetCounters()
+	fdst, err := fs.NewFs(ctx, r.FremoteName+"/dest")
+	require.NoError(t, err)
+	require.NoError(t, Sync(ctx, fdst, r.Flocal, false))
+
+	fdst1, fdst2, fdst3 := fsrc1, fsrc2, fsrc3
+	fdst1.Path, fdst2.Path, fdst3.Path = "dest/1", "dest/2", "dest/3"
+	r.CheckRemoteItems(t, fdest1, fdest2old, fdest2new, fdst1, fdst2, fdst3)
+}
+
 // Test with CopyDest set
 func TestSyncCopyDest(t *testing.T) {
 	ctx := context.Background()
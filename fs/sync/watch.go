@@ -0,0 +1,261 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/filter"
+	"github.com/rclone/rclone/fs/fserrors"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// watcher collects the changes to the source of a Watch
+type watcher struct {
+	mu      sync.Mutex
+	changes map[string]fs.EntryType // paths changed since the last sync
+	changed chan struct{}           // signalled when a change is added
+}
+
+// notify records a change to remote - it is used as the ChangeNotify
+// callback
+func (w *watcher) notify(remote string, entryType fs.EntryType) {
+	remote = strings.Trim(remote, "/")
+	w.mu.Lock()
+	if w.changes[remote] != fs.EntryDirectory {
+		w.changes[remote] = entryType
+	}
+	w.mu.Unlock()
+	select {
+	case w.changed <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the changes so far and resets them
+func (w *watcher) take() (changes map[string]fs.EntryType) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	changes, w.changes = w.changes, map[string]fs.EntryType{}
+	return changes
+}
+
+// pollEntry is what is remembered about an entry when polling
+type pollEntry struct {
+	entryType fs.EntryType
+	size      int64
+	modTime   time.Time
+}
+
+// pollSnapshot lists f returning the entries in it
+func pollSnapshot(ctx context.Context, f fs.Fs) (snapshot map[string]pollEntry, err error) {
+	snapshot = map[string]pollEntry{}
+	err = walk.ListR(ctx, f, "", false, -1, walk.ListAll, func(entries fs.DirEntries) error {
+		for _, entry := range entries {
+			switch x := entry.(type) {
+			case fs.Object:
+				snapshot[x.Remote()] = pollEntry{
+					entryType: fs.EntryObject,
+					size:      x.Size(),
+					modTime:   x.ModTime(ctx),
+				}
+			case fs.Directory:
+				snapshot[x.Remote()] = pollEntry{entryType: fs.EntryDirectory}
+			}
+		}
+		return nil
+	})
+	if err == fs.ErrorDirNotFound {
+		err = nil
+	}
+	return snapshot, err
+}
+
+// pollDiff calls notify for each entry which is new, changed or
+// removed between the snapshots before and after
+func pollDiff(before, after map[string]pollEntry, notify func(string, fs.EntryType)) {
+	for remote, newEntry := range after {
+		oldEntry, ok := before[remote]
+		if !ok || oldEntry.entryType != newEntry.entryType || oldEntry.size != newEntry.size || !oldEntry.modTime.Equal(newEntry.modTime) {
+			notify(remote, newEntry.entryType)
+		}
+	}
+	for remote, oldEntry := range before {
+		if _, ok := after[remote]; !ok {
+			notify(remote, oldEntry.entryType)
+		}
+	}
+}
+
+// poll finds the changes to f by listing it every pollInterval for
+// sources which don't support ChangeNotify
+//
+// The first listing is done before returning.
+func (w *watcher) poll(ctx context.Context, f fs.Fs, pollInterval time.Duration) {
+	fs.Infof(f, "Change notifications not supported - polling for changes every %v", pollInterval)
+	old, err := pollSnapshot(ctx, f)
+	if err != nil {
+		fs.Errorf(f, "Failed to list for changes: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			snapshot, err := pollSnapshot(ctx, f)
+			if err != nil {
+				fs.Errorf(f, "Failed to list for changes: %v", err)
+				continue
+			}
+			pollDiff(old, snapshot, w.notify)
+			old = snapshot
+		}
+	}()
+}
+
+// watchJob is a directory of the source to sync after changes in it
+type watchJob struct {
+	dir     string // directory relative to the root
+	recurse bool   // set to sync the subdirectories too
+}
+
+// parentDir returns the parent of dir with "" for the root
+func parentDir(dir string) string {
+	dir = path.Dir(dir)
+	if dir == "." || dir == "/" {
+		return ""
+	}
+	return dir
+}
+
+// watchJobs works out the directories to sync for the changes
+//
+// A changed file causes its directory to be synced and a changed
+// directory is synced with its subdirectories. Directories already
+// covered by a recursive sync of one of their parents are dropped.
+func watchJobs(changes map[string]fs.EntryType) (jobs []watchJob) {
+	recurse := map[string]bool{}
+	for remote, entryType := range changes {
+		if entryType == fs.EntryDirectory {
+			recurse[remote] = true
+		} else if dir := parentDir(remote); !recurse[dir] {
+			recurse[dir] = false
+		}
+	}
+	dirs := make([]string, 0, len(recurse))
+	for dir := range recurse {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+outer:
+	for _, dir := range dirs {
+		for parent := dir; parent != ""; {
+			parent = parentDir(parent)
+			if recurse[parent] {
+				continue outer
+			}
+		}
+		jobs = append(jobs, watchJob{dir: dir, recurse: recurse[dir]})
+	}
+	return jobs
+}
+
+// syncJob syncs the directory in job
+//
+// If the directory has gone from the source then its nearest parent
+// which still exists is synced with its subdirectories so the
+// deletions are done.
+func syncJob(ctx context.Context, fdst, fsrc fs.Fs, copyEmptySrcDirs bool, job watchJob) error {
+	for job.dir != "" {
+		_, err := fsrc.List(ctx, job.dir)
+		if err != fs.ErrorDirNotFound {
+			break
+		}
+		job.dir = parentDir(job.dir)
+		job.recurse = true
+	}
+	ctx, ci := fs.AddConfig(ctx)
+	if !job.recurse {
+		ci.MaxDepth = 1
+	}
+	fs.Debugf(fs.LogDirName(fsrc, job.dir), "Syncing changes (recursive %v)", job.recurse)
+	return runSyncCopyMove(ctx, fdst, fsrc, job.dir, ci.DeleteMode, false, false, copyEmptySrcDirs)
+}
+
+// Watch syncs fsrc into fdst then keeps running until ctx is
+// cancelled, syncing only the directories of fsrc which change.
+//
+// Changes are read with ChangeNotify if fsrc supports it, otherwise
+// fsrc is listed every pollInterval to find them. The changes are
+// synced once there have been none for delay.
+//
+// Errors syncing the changes are logged and the watch carries on.
+// Only fatal errors are returned.
+func Watch(ctx context.Context, fdst, fsrc fs.Fs, copyEmptySrcDirs bool, delay, pollInterval time.Duration) error {
+	ci := fs.GetConfig(ctx)
+	if ci.MaxDepth >= 0 {
+		return fserrors.FatalError(errors.New("can't use --watch with --max-depth"))
+	}
+	if pollInterval <= 0 {
+		return fserrors.FatalError(errors.New("--watch-poll-interval must be greater than 0"))
+	}
+	fi := filter.GetConfig(ctx)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Start watching before the first sync so no changes are missed
+	w := &watcher{
+		changes: map[string]fs.EntryType{},
+		changed: make(chan struct{}, 1),
+	}
+	if do := fsrc.Features().ChangeNotify; do != nil {
+		pollChan := make(chan time.Duration)
+		do(ctx, func(remote string, entryType fs.EntryType) {
+			if entryType == fs.EntryObject && !fi.IncludeRemote(strings.Trim(remote, "/")) {
+				return
+			}
+			w.notify(remote, entryType)
+		}, pollChan)
+		pollChan <- pollInterval
+		defer close(pollChan)
+	} else {
+		w.poll(ctx, fsrc, pollInterval)
+	}
+
+	err := Sync(ctx, fdst, fsrc, copyEmptySrcDirs)
+	if fserrors.IsFatalError(err) {
+		return err
+	} else if err != nil {
+		fs.Errorf(fdst, "Sync failed, carrying on watching for changes: %v", err)
+	}
+	fs.Infof(fsrc, "Watching for changes to sync")
+
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-w.changed:
+			timer = time.After(delay)
+		case <-timer:
+			timer = nil
+			for _, job := range watchJobs(w.take()) {
+				err := syncJob(ctx, fdst, fsrc, copyEmptySrcDirs, job)
+				if fserrors.IsFatalError(err) {
+					return err
+				} else if err != nil {
+					fs.Errorf(fs.LogDirName(fdst, job.dir), "Failed to sync changes: %v", err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchJobs(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		changes map[string]fs.EntryType
+		want    []watchJob
+	}{
+		{
+			name:    "empty",
+			changes: map[string]fs.EntryType{},
+			want:    nil,
+		},
+		{
+			name:    "file in root",
+			changes: map[string]fs.EntryType{"file": fs.EntryObject},
+			want:    []watchJob{{dir: "", recurse: false}},
+		},
+		{
+			name: "files in dirs",
+			changes: map[string]fs.EntryType{
+				"a/file1":   fs.EntryObject,
+				"a/file2":   fs.EntryObject,
+				"a/b/file3": fs.EntryObject,
+				"c/file4":   fs.EntryObject,
+			},
+			want: []watchJob{{dir: "a"}, {dir: "a/b"}, {dir: "c"}},
+		},
+		{
+			name: "dirs cover their contents",
+			changes: map[string]fs.EntryType{
+				"a":         fs.EntryDirectory,
+				"a/file1":   fs.EntryObject,
+				"a/b/file2": fs.EntryObject,
+				"a/b":       fs.EntryDirectory,
+				"file3":     fs.EntryObject,
+				"ab/file4":  fs.EntryObject,
+			},
+			want: []watchJob{{dir: ""}, {dir: "a", recurse: true}, {dir: "ab"}},
+		},
+		{
+			name: "root dir covers everything",
+			changes: map[string]fs.EntryType{
+				"":        fs.EntryDirectory,
+				"a/file1": fs.EntryObject,
+				"b":       fs.EntryDirectory,
+			},
+			want: []watchJob{{dir: "", recurse: true}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, watchJobs(test.changes))
+		})
+	}
+}
+
+func TestWatcherNotify(t *testing.T) {
+	w := &watcher{
+		changes: map[string]fs.EntryType{},
+		changed: make(chan struct{}, 1),
+	}
+	w.notify("/dir/", fs.EntryDirectory)
+	w.notify("dir", fs.EntryObject)
+	w.notify("file", fs.EntryObject)
+	assert.Equal(t, 1, len(w.changed))
+	assert.Equal(t, map[string]fs.EntryType{
+		"dir":  fs.EntryDirectory,
+		"file": fs.EntryObject,
+	}, w.take())
+	assert.Equal(t, map[string]fs.EntryType{}, w.take())
+}
+
+func TestPollDiff(t *testing.T) {
+	t1 := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	t2 := t1.Add(time.Second)
+	before := map[string]pollEntry{
+		"dir":       {entryType: fs.EntryDirectory},
+		"same":      {entryType: fs.EntryObject, size: 1, modTime: t1},
+		"size":      {entryType: fs.EntryObject, size: 1, modTime: t1},
+		"modtime":   {entryType: fs.EntryObject, size: 1, modTime: t1},
+		"removed":   {entryType: fs.EntryObject, size: 1, modTime: t1},
+		"removeDir": {entryType: fs.EntryDirectory},
+	}
+	after := map[string]pollEntry{
+		"dir":     {entryType: fs.EntryDirectory},
+		"same":    {entryType: fs.EntryObject, size: 1, modTime: t1},
+		"size":    {entryType: fs.EntryObject, size: 2, modTime: t1},
+		"modtime": {entryType: fs.EntryObject, size: 1, modTime: t2},
+		"new":     {entryType: fs.EntryObject, size: 1, modTime: t1},
+		"newDir":  {entryType: fs.EntryDirectory},
+	}
+	got := map[string]fs.EntryType{}
+	pollDiff(before, after, func(remote string, entryType fs.EntryType) {
+		got[remote] = entryType
+	})
+	assert.Equal(t, map[string]fs.EntryType{
+		"size":      fs.EntryObject,
+		"modtime":   fs.EntryObject,
+		"removed":   fs.EntryObject,
+		"removeDir": fs.EntryDirectory,
+		"new":       fs.EntryObject,
+		"newDir":    fs.EntryDirectory,
+	}, got)
+}
+
+func TestWatch(t *testing.T) {
+	ctx := context.Background()
+	r := fstest.NewRun(t)
+	defer r.Finalise()
+	r.WriteFile("dir/file1", "file1 contents", t1)
+
+	exists := func(remote string) bool {
+		_, err := r.Fremote.NewObject(ctx, remote)
+		return err == nil
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- Watch(watchCtx, r.Fremote, r.Flocal, false, 10*time.Millisecond, 50*time.Millisecond)
+	}()
+
+	// The initial sync copies the existing files
+	assert.Eventually(t, func() bool { return exists("dir/file1") }, 10*time.Second, 10*time.Millisecond)
+
+	// New files are copied
+	file2 := r.WriteFile("dir/sub/file2", "file2 contents", t2)
+	assert.Eventually(t, func() bool { return exists("dir/sub/file2") }, 10*time.Second, 10*time.Millisecond)
+
+	// Removed files are deleted
+	require.NoError(t, os.Remove(filepath.Join(r.LocalName, "dir", "file1")))
+	assert.Eventually(t, func() bool { return !exists("dir/file1") }, 10*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.NoError(t, <-errs)
+	r.CheckRemoteItems(t, file2)
+}
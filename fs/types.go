@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/lib/delta"
 )
 
 // Fs is the interface a cloud storage system must provide
@@ -183,6 +184,22 @@ type GetTierer interface {
 	GetTier() string
 }
 
+// Deltaer is an optional interface for Object
+//
+// Objects which implement it can be updated by sending only the parts
+// of the new contents which have changed.
+type Deltaer interface {
+	// Signature returns the checksums of the blocks of blockSize
+	// bytes of the current contents of the Object
+	Signature(ctx context.Context, blockSize int) (*delta.Signature, error)
+
+	// Patch updates the Object with new contents and metadata
+	// from src. The new contents are made by applying the delta
+	// read from in, as made by delta.Diff from the Signature, to
+	// the current contents.
+	Patch(ctx context.Context, in io.Reader, src ObjectInfo, options ...OpenOption) error
+}
+
 // FullObjectInfo contains all the read-only optional interfaces
 //
 // Use for checking making wrapping ObjectInfos implement everything
@@ -232,6 +249,9 @@ func ObjectOptionalInterfaces(o Object) (supported, unsupported []string) {
 	_, ok = o.(GetTierer)
 	store(ok, "GetTier")
 
+	_, ok = o.(Metadataer)
+	store(ok, "Metadata")
+
 	return supported, unsupported
 }
 
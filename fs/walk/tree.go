@@ -0,0 +1,189 @@
+package walk
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/dirtree"
+	"github.com/rclone/rclone/fs/list"
+)
+
+// Tree is a recursive listing made by NewTree.
+//
+// It is held in memory unless it has too many entries in which case
+// it is held on disk.
+type Tree struct {
+	ctx   context.Context
+	f     fs.Fs
+	keyFn list.KeyFn
+	mu    sync.Mutex
+	dirs  dirtree.DirTree // the listing if it is in memory
+	disk  *diskTree       // the listing if it is on disk
+}
+
+// ListDir returns a Sorter which returns the entries of dir sorted by
+// the keyFn passed to NewTree.
+//
+// It returns fs.ErrorDirNotFound if dir isn't in the Tree. Each
+// directory may only be listed once.
+//
+// CleanUp must be called on the Sorter when it is finished with.
+func (t *Tree) ListDir(dir string) (*list.Sorter, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.disk != nil {
+		return t.disk.listDir(dir)
+	}
+	entries, ok := t.dirs[dir]
+	if !ok {
+		return nil, fs.ErrorDirNotFound
+	}
+	delete(t.dirs, dir)
+	ls := list.NewSorter(t.ctx, t.f, t.keyFn)
+	err := ls.Add(entries)
+	if err != nil {
+		ls.CleanUp()
+		return nil, err
+	}
+	return ls, nil
+}
+
+// CleanUp removes any temporary files used by the Tree
+func (t *Tree) CleanUp() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.disk != nil {
+		t.disk.CleanUp()
+	}
+	t.dirs = nil
+}
+
+// treeAdder is the part of dirtree.DirTree which walkRTree uses to
+// add entries
+type treeAdder interface {
+	Add(entry fs.DirEntry)
+	AddDir(entry fs.DirEntry)
+	CheckParent(root, dirPath string)
+}
+
+// diskTreeBatch is how many entries diskTree adds to disk at once
+const diskTreeBatch = 1024
+
+// diskTree is a dirtree.DirTree which keeps the entries on disk.
+//
+// Only the names of the directories are kept in memory.
+type diskTree struct {
+	tree    *list.DiskTree
+	dirs    map[string]bool // directories which have a listing
+	hasDir  map[string]bool // directories which have an entry in their parent
+	pruned  map[string]bool // directories which have been pruned
+	pending fs.DirEntries   // entries not written to tree yet
+	err     error           // first error writing to tree
+}
+
+func newDiskTree(ctx context.Context, f fs.Fs, keyFn list.KeyFn) *diskTree {
+	return &diskTree{
+		tree:   list.NewDiskTree(ctx, f, keyFn),
+		dirs:   make(map[string]bool),
+		hasDir: make(map[string]bool),
+		pruned: make(map[string]bool),
+	}
+}
+
+// addDirTree moves the entries of dt to disk
+func (d *diskTree) addDirTree(dt dirtree.DirTree) {
+	for dirPath, entries := range dt {
+		d.dirs[dirPath] = true
+		for _, entry := range entries {
+			d.Add(entry)
+		}
+		delete(dt, dirPath)
+	}
+}
+
+// flush the pending entries to disk
+func (d *diskTree) flush() {
+	if d.err == nil && len(d.pending) > 0 {
+		d.err = d.tree.Add(d.pending)
+	}
+	d.pending = nil
+}
+
+// Add an entry to the tree
+func (d *diskTree) Add(entry fs.DirEntry) {
+	d.dirs[parentDir(entry.Remote())] = true
+	if _, ok := entry.(fs.Directory); ok {
+		d.hasDir[entry.Remote()] = true
+	}
+	d.pending = append(d.pending, entry)
+	if len(d.pending) >= diskTreeBatch {
+		d.flush()
+	}
+}
+
+// AddDir adds a directory entry to the tree and creates the
+// directory itself
+func (d *diskTree) AddDir(entry fs.DirEntry) {
+	d.Add(entry)
+	d.dirs[entry.Remote()] = true
+}
+
+// CheckParent checks that dirPath and its parents up to root have a
+// directory entry in their parent
+func (d *diskTree) CheckParent(root, dirPath string) {
+	for dirPath != root && dirPath != "" && !d.hasDir[dirPath] {
+		d.Add(fs.NewDir(dirPath, time.Now()))
+		dirPath = parentDir(dirPath)
+	}
+}
+
+// finish checks the parents, prunes the directories in toPrune and
+// their children and writes the tree to disk.
+func (d *diskTree) finish(root string, toPrune map[string]bool) error {
+	for dirPath := range d.dirs {
+		d.CheckParent(root, dirPath)
+	}
+	if len(d.dirs) == 0 {
+		d.dirs[root] = true
+	}
+	isPruned := func(dirPath string) bool {
+		for {
+			if toPrune[dirPath] {
+				return true
+			}
+			if dirPath == "" {
+				return false
+			}
+			dirPath = parentDir(dirPath)
+		}
+	}
+	for _, dirs := range []map[string]bool{d.dirs, d.hasDir} {
+		for dirPath := range dirs {
+			if isPruned(dirPath) {
+				d.pruned[dirPath] = true
+			}
+		}
+	}
+	d.flush()
+	if d.err != nil {
+		return d.err
+	}
+	return d.tree.Finish(func(remote string, isDir bool) bool {
+		return d.pruned[parentDir(remote)] || (isDir && d.pruned[remote])
+	})
+}
+
+// listDir returns a Sorter for the entries of dirPath
+func (d *diskTree) listDir(dirPath string) (*list.Sorter, error) {
+	if !d.dirs[dirPath] || d.pruned[dirPath] {
+		return nil, fs.ErrorDirNotFound
+	}
+	return d.tree.Dir(dirPath), nil
+}
+
+// CleanUp removes the temporary files
+func (d *diskTree) CleanUp() {
+	d.tree.CleanUp()
+}
@@ -458,17 +458,44 @@ func walk(ctx context.Context, f fs.Fs, path string, includeAll bool, maxLevel i
 	return <-errs
 }
 
+// walkRDirTree makes a DirTree using listR
 func walkRDirTree(ctx context.Context, f fs.Fs, startPath string, includeAll bool, maxLevel int, listR fs.ListRFn) (dirtree.DirTree, error) {
+	tree, err := walkRTree(ctx, f, startPath, includeAll, maxLevel, 0, nil, listR)
+	if err != nil {
+		return nil, err
+	}
+	return tree.dirs, nil
+}
+
+// walkRTree makes a Tree using listR
+//
+// If maxEntries > 0 and listR returns more than that many entries
+// then the Tree is moved to disk with the entries sorted by keyFn.
+func walkRTree(ctx context.Context, f fs.Fs, startPath string, includeAll bool, maxLevel int, maxEntries int, keyFn list.KeyFn, listR fs.ListRFn) (*Tree, error) {
 	fi := filter.GetConfig(ctx)
-	dirs := dirtree.New()
+	memDirs := dirtree.New()
+	var (
+		disk *diskTree           // set if the tree has been moved to disk
+		dirs treeAdder = memDirs // where to add the entries
+	)
 	// Entries can come in arbitrary order. We use toPrune to keep
 	// all directories to exclude later.
 	toPrune := make(map[string]bool)
 	includeDirectory := fi.IncludeDirectory(ctx, f)
-	var mu sync.Mutex
+	var (
+		mu       sync.Mutex
+		nEntries int
+	)
 	err := listR(ctx, startPath, func(entries fs.DirEntries) error {
 		mu.Lock()
 		defer mu.Unlock()
+		nEntries += len(entries)
+		if disk == nil && maxEntries > 0 && nEntries > maxEntries {
+			fs.Infof(f, "Listing has more than --list-cutoff %d entries - sorting it on disk", maxEntries)
+			disk = newDiskTree(ctx, f, keyFn)
+			disk.addDirTree(memDirs)
+			memDirs, dirs = nil, disk
+		}
 		for _, entry := range entries {
 			slashes := strings.Count(entry.Remote(), "/")
 			switch x := entry.(type) {
@@ -518,21 +545,35 @@ func walkRDirTree(ctx context.Context, f fs.Fs, startPath string, includeAll boo
 				return fmt.Errorf("unknown object type %T", entry)
 			}
 		}
+		if disk != nil {
+			return disk.err
+		}
 		return nil
 	})
 	if err != nil {
+		if disk != nil {
+			disk.CleanUp()
+		}
 		return nil, err
 	}
-	dirs.CheckParents(startPath)
-	if len(dirs) == 0 {
-		dirs[startPath] = nil
+	if disk != nil {
+		err = disk.finish(startPath, toPrune)
+		if err != nil {
+			disk.CleanUp()
+			return nil, err
+		}
+		return &Tree{ctx: ctx, f: f, keyFn: keyFn, disk: disk}, nil
 	}
-	err = dirs.Prune(toPrune)
+	memDirs.CheckParents(startPath)
+	if len(memDirs) == 0 {
+		memDirs[startPath] = nil
+	}
+	err = memDirs.Prune(toPrune)
 	if err != nil {
 		return nil, err
 	}
-	dirs.Sort()
-	return dirs, nil
+	memDirs.Sort()
+	return &Tree{ctx: ctx, f: f, keyFn: keyFn, dirs: memDirs}, nil
 }
 
 // Create a DirTree using List
@@ -567,18 +608,36 @@ func walkNDirTree(ctx context.Context, f fs.Fs, path string, includeAll bool, ma
 //
 // NB (f, path) to be replaced by fs.Dir at some point
 func NewDirTree(ctx context.Context, f fs.Fs, path string, includeAll bool, maxLevel int) (dirtree.DirTree, error) {
+	tree, err := NewTree(ctx, f, path, includeAll, maxLevel, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return tree.dirs, nil
+}
+
+// NewTree is like NewDirTree but if maxEntries > 0 and the listing is
+// done with ListR and has more than maxEntries entries then the Tree
+// is kept on disk rather than in memory with the entries of each
+// directory sorted by keyFn.
+//
+// CleanUp must be called on the Tree when it is finished with.
+func NewTree(ctx context.Context, f fs.Fs, path string, includeAll bool, maxLevel int, maxEntries int, keyFn list.KeyFn) (*Tree, error) {
 	ci := fs.GetConfig(ctx)
 	fi := filter.GetConfig(ctx)
 	// if --no-traverse and --files-from build DirTree just from files
 	if ci.NoTraverse && fi.HaveFilesFrom() {
-		return walkRDirTree(ctx, f, path, includeAll, maxLevel, fi.MakeListR(ctx, f.NewObject))
+		return walkRTree(ctx, f, path, includeAll, maxLevel, maxEntries, keyFn, fi.MakeListR(ctx, f.NewObject))
 	}
 	// if have ListR; and recursing; and not using --files-from; then build a DirTree with ListR
 	if ListR := f.Features().ListR; (maxLevel < 0 || maxLevel > 1) && ListR != nil && !fi.HaveFilesFrom() {
-		return walkRDirTree(ctx, f, path, includeAll, maxLevel, ListR)
+		return walkRTree(ctx, f, path, includeAll, maxLevel, maxEntries, keyFn, ListR)
 	}
 	// otherwise just use List
-	return walkNDirTree(ctx, f, path, includeAll, maxLevel, list.DirSorted)
+	dirs, err := walkNDirTree(ctx, f, path, includeAll, maxLevel, list.DirSorted)
+	if err != nil {
+		return nil, err
+	}
+	return &Tree{ctx: ctx, f: f, keyFn: keyFn, dirs: dirs}, nil
 }
 
 func walkR(ctx context.Context, f fs.Fs, path string, includeAll bool, maxLevel int, fn Func, listR fs.ListRFn) error {
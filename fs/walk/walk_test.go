@@ -584,6 +584,108 @@ a/
 	}
 }
 
+// objectsFs is a mock Fs which can find the objects in entries
+type objectsFs struct {
+	*mockfs.Fs
+	objects map[string]fs.Object
+}
+
+// NewObject finds the Object at remote
+func (f *objectsFs) NewObject(ctx context.Context, remote string) (fs.Object, error) {
+	o, ok := f.objects[remote]
+	if !ok {
+		return nil, fs.ErrorObjectNotFound
+	}
+	return o, nil
+}
+
+func TestWalkRTreeOnDisk(t *testing.T) {
+	ctx, ci := fs.AddConfig(context.Background())
+	ci.ListCutoff = 2
+	ctx, fi := filter.AddConfig(ctx)
+	fi.Opt.ExcludeFile = ".ignore"
+	entries := fs.DirEntries{
+		mockobject.Object("z"),
+		mockobject.Object("a/b/c/d"),
+		mockobject.Object("a/x"),
+		mockdir.New("a/b"),
+		mockobject.Object("a/b/e"),
+		mockobject.Object("a/b/a"),
+		mockobject.Object("ignored/.ignore"),
+		mockobject.Object("ignored/y"),
+		mockobject.Object("ignored/sub/y"),
+		mockobject.Object("a.b/y"),
+	}
+	f := &objectsFs{
+		Fs:      mockfs.NewFs(ctx, "mock", "/"),
+		objects: map[string]fs.Object{},
+	}
+	for _, entry := range entries {
+		if o, ok := entry.(fs.Object); ok {
+			f.objects[o.Remote()] = o
+		}
+	}
+	// send the entries one at a time so the tree moves to disk part way
+	listR := func(ctx context.Context, dir string, callback fs.ListRCallback) error {
+		for _, entry := range entries {
+			err := callback(fs.DirEntries{entry})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	keyFn := func(entry fs.DirEntry) string {
+		return entry.Remote()
+	}
+
+	want, err := walkRDirTree(ctx, f, "", false, -1, listR)
+	require.NoError(t, err)
+	assert.Equal(t, `/
+  a/
+  a.b/
+  z
+a/
+  b/
+  x
+a.b/
+  y
+a/b/
+  a
+  c/
+  e
+a/b/c/
+  d
+`, want.String())
+
+	tree, err := walkRTree(ctx, f, "", false, -1, 3, keyFn, listR)
+	require.NoError(t, err)
+	defer tree.CleanUp()
+	require.NotNil(t, tree.disk)
+	for _, dir := range want.Dirs() {
+		ls, err := tree.ListDir(dir)
+		require.NoError(t, err, dir)
+		var got []string
+		for {
+			entry, err := ls.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			got = append(got, entry.Remote())
+			wantEntry := want[dir][len(got)-1]
+			assert.Equal(t, wantEntry.Remote(), entry.Remote())
+			assert.Equal(t, fs.DirEntryType(wantEntry), fs.DirEntryType(entry))
+		}
+		ls.CleanUp()
+		assert.Equal(t, len(want[dir]), len(got), dir)
+	}
+	for _, dir := range []string{"ignored", "ignored/sub", "missing"} {
+		_, err = tree.ListDir(dir)
+		assert.Equal(t, fs.ErrorDirNotFound, err, dir)
+	}
+}
+
 func TestWalkRDirTreeExclude(t *testing.T) {
 	ctx := context.Background()
 	fi := filter.GetConfig(ctx)
@@ -3,6 +3,7 @@
 package cache
 
 import (
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -57,6 +58,7 @@ type cacheEntry struct {
 	value    interface{} // cached item
 	err      error       // creation error
 	key      string      // key
+	created  time.Time   // time the entry was added
 	lastUsed time.Time   // time used for expiry
 	pinCount int         // non zero if the entry should not be removed
 }
@@ -88,9 +90,10 @@ func (c *Cache) Get(key string, create CreateFunc) (value interface{}, err error
 			return value, err
 		}
 		entry = &cacheEntry{
-			value: value,
-			key:   key,
-			err:   err,
+			value:   value,
+			key:     key,
+			err:     err,
+			created: time.Now(),
 		}
 		c.mu.Lock()
 		if !c.noCache() {
@@ -130,8 +133,9 @@ func (c *Cache) Put(key string, value interface{}) {
 		return
 	}
 	entry := &cacheEntry{
-		value: value,
-		key:   key,
+		value:   value,
+		key:     key,
+		created: time.Now(),
 	}
 	c.used(entry)
 	c.cache[key] = entry
@@ -231,3 +235,35 @@ func (c *Cache) Entries() int {
 	c.mu.Unlock()
 	return entries
 }
+
+// EntryInfo describes an entry in the cache
+type EntryInfo struct {
+	Key      string      // key of the entry
+	Value    interface{} // cached item
+	Err      error       // creation error
+	Created  time.Time   // time the entry was added
+	LastUsed time.Time   // time the entry was last used
+	PinCount int         // number of times the entry is pinned
+}
+
+// List returns information about each entry in the cache sorted by
+// key
+func (c *Cache) List() []EntryInfo {
+	c.mu.Lock()
+	list := make([]EntryInfo, 0, len(c.cache))
+	for key, entry := range c.cache {
+		list = append(list, EntryInfo{
+			Key:      key,
+			Value:    entry.value,
+			Err:      entry.err,
+			Created:  entry.created,
+			LastUsed: entry.lastUsed,
+			PinCount: entry.pinCount,
+		})
+	}
+	c.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Key < list[j].Key
+	})
+	return list
+}
@@ -231,6 +231,31 @@ func TestEntries(t *testing.T) {
 	assert.Equal(t, 0, c.Entries())
 }
 
+func TestList(t *testing.T) {
+	c, create := setup(t)
+
+	assert.Equal(t, []EntryInfo{}, c.List())
+
+	_, err := c.Get("/file.txt", create)
+	require.Equal(t, errCached, err)
+	called = 0
+	_, err = c.Get("/", create)
+	require.NoError(t, err)
+	c.Pin("/")
+
+	list := c.List()
+	require.Equal(t, 2, len(list))
+	assert.Equal(t, "/", list[0].Key)
+	assert.Equal(t, "/", list[0].Value)
+	assert.NoError(t, list[0].Err)
+	assert.Equal(t, 1, list[0].PinCount)
+	assert.False(t, list[0].Created.IsZero())
+	assert.False(t, list[0].LastUsed.Before(list[0].Created))
+	assert.Equal(t, "/file.txt", list[1].Key)
+	assert.Equal(t, errCached, list[1].Err)
+	assert.Equal(t, 0, list[1].PinCount)
+}
+
 func TestGetMaybe(t *testing.T) {
 	c, create := setup(t)
 
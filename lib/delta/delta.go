@@ -0,0 +1,393 @@
+// Package delta implements rsync style delta transfers.
+//
+// The receiver makes a Signature of the old contents of a file with
+// Sign. The sender uses it with Diff to write a delta of the new
+// contents which references the blocks of the old contents it
+// already has using a rolling checksum and only includes the data
+// which has changed. The receiver then recreates the new contents
+// from the old contents and the delta with Apply.
+package delta
+
+import (
+	"bufio"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Limits for the block size chosen by BlockSize
+const (
+	MinBlockSize = 4 * 1024
+	MaxBlockSize = 1024 * 1024
+)
+
+// maxLiteral is the largest amount of changed data which Diff keeps
+// in memory before writing it
+const maxLiteral = 1024 * 1024
+
+// Operations in the delta
+const (
+	opCopy = 'C' // followed by uvarint offset and length in the old contents
+	opData = 'D' // followed by uvarint length and that much new data
+	opEnd  = 'E' // end of the delta
+)
+
+// ErrCorrupt is returned by Apply if the delta is invalid
+var ErrCorrupt = errors.New("corrupted delta")
+
+// Block is the checksums of a single block of a file
+type Block struct {
+	Weak   uint32         // rolling checksum
+	Strong [md5.Size]byte // MD5 of the block
+}
+
+// Signature is the checksums of the blocks of a file
+type Signature struct {
+	BlockSize int     // size of each block except maybe the last
+	Size      int64   // size of the file
+	Blocks    []Block // the blocks in order
+}
+
+// BlockSize returns a block size suitable for a file of size bytes
+//
+// This is the square root of the size, as rsync uses, clipped to
+// MinBlockSize and MaxBlockSize.
+func BlockSize(size int64) int {
+	blockSize := int(math.Sqrt(float64(size)))
+	blockSize = (blockSize + 1023) &^ 1023
+	if blockSize < MinBlockSize {
+		blockSize = MinBlockSize
+	} else if blockSize > MaxBlockSize {
+		blockSize = MaxBlockSize
+	}
+	return blockSize
+}
+
+// rolling is the rsync rolling checksum of a window of data
+type rolling struct {
+	a, b uint32
+	n    uint32 // size of the window
+}
+
+// init sets the checksum to that of p
+func (r *rolling) init(p []byte) {
+	r.a, r.b, r.n = 0, 0, uint32(len(p))
+	for i, c := range p {
+		r.a += uint32(c)
+		r.b += uint32(len(p)-i) * uint32(c)
+	}
+}
+
+// roll moves the window on one byte, removing out and adding in
+func (r *rolling) roll(out, in byte) {
+	r.a += uint32(in) - uint32(out)
+	r.b += r.a - r.n*uint32(out)
+}
+
+// sum returns the checksum
+func (r *rolling) sum() uint32 {
+	return r.b<<16 | r.a&0xffff
+}
+
+// weakSum returns the rolling checksum of p
+func weakSum(p []byte) uint32 {
+	var r rolling
+	r.init(p)
+	return r.sum()
+}
+
+// Sign reads in and returns the Signature of its contents using
+// blocks of blockSize bytes.
+func Sign(in io.Reader, blockSize int) (*Signature, error) {
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("invalid block size %d", blockSize)
+	}
+	sig := &Signature{BlockSize: blockSize}
+	buf := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(in, buf)
+		if n > 0 {
+			sig.Size += int64(n)
+			sig.Blocks = append(sig.Blocks, Block{
+				Weak:   weakSum(buf[:n]),
+				Strong: md5.Sum(buf[:n]),
+			})
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return sig, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// encoder writes the operations of a delta, joining up adjacent
+// copies
+type encoder struct {
+	w       *bufio.Writer
+	copyOff int64 // pending copy
+	copyLen int64
+	buf     [binary.MaxVarintLen64]byte
+	written int64 // bytes of new data written
+}
+
+// writeUvarint writes x to the delta
+func (e *encoder) writeUvarint(x uint64) error {
+	n := binary.PutUvarint(e.buf[:], x)
+	_, err := e.w.Write(e.buf[:n])
+	return err
+}
+
+// flushCopy writes the pending copy if any
+func (e *encoder) flushCopy() error {
+	if e.copyLen == 0 {
+		return nil
+	}
+	if err := e.w.WriteByte(opCopy); err != nil {
+		return err
+	}
+	if err := e.writeUvarint(uint64(e.copyOff)); err != nil {
+		return err
+	}
+	if err := e.writeUvarint(uint64(e.copyLen)); err != nil {
+		return err
+	}
+	e.copyLen = 0
+	return nil
+}
+
+// copy adds a copy of length bytes at off in the old contents
+func (e *encoder) copy(off, length int64) error {
+	if e.copyLen > 0 && e.copyOff+e.copyLen == off {
+		e.copyLen += length
+		return nil
+	}
+	if err := e.flushCopy(); err != nil {
+		return err
+	}
+	e.copyOff, e.copyLen = off, length
+	return nil
+}
+
+// data adds the new data in p
+func (e *encoder) data(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	if err := e.flushCopy(); err != nil {
+		return err
+	}
+	if err := e.w.WriteByte(opData); err != nil {
+		return err
+	}
+	if err := e.writeUvarint(uint64(len(p))); err != nil {
+		return err
+	}
+	_, err := e.w.Write(p)
+	e.written += int64(len(p))
+	return err
+}
+
+// close finishes the delta
+func (e *encoder) close() error {
+	if err := e.flushCopy(); err != nil {
+		return err
+	}
+	if err := e.w.WriteByte(opEnd); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+// Diff reads the new contents from in and writes a delta to w which
+// turns the old contents described by sig into them.
+//
+// It returns the number of bytes of new data in the delta, which is
+// all that needs to be sent apart from the small overhead of the
+// operations.
+func Diff(w io.Writer, sig *Signature, in io.Reader) (literal int64, err error) {
+	bs := sig.BlockSize
+	if bs <= 0 {
+		return 0, fmt.Errorf("invalid block size %d", bs)
+	}
+	e := &encoder{w: bufio.NewWriter(w)}
+
+	// Index the full sized blocks by their weak checksum - only
+	// the last block can be short
+	index := make(map[uint32][]int, len(sig.Blocks))
+	for i, block := range sig.Blocks {
+		if int64(i+1)*int64(bs) <= sig.Size {
+			index[block.Weak] = append(index[block.Weak], i)
+		}
+	}
+	find := func(weak uint32, window []byte) (int, bool) {
+		candidates := index[weak]
+		if len(candidates) == 0 {
+			return 0, false
+		}
+		strong := md5.Sum(window)
+		for _, i := range candidates {
+			if sig.Blocks[i].Strong == strong {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+
+	// data[start:pos] is new data not yet written and
+	// data[pos:pos+bs] is the window being checked
+	var (
+		data     = make([]byte, 0, 2*bs+maxLiteral)
+		start    int
+		pos      int
+		eof      bool
+		r        rolling
+		haveSum  bool
+		writeErr error
+	)
+	flushLiteral := func() {
+		if writeErr == nil {
+			writeErr = e.data(data[start:pos])
+		}
+		start = pos
+	}
+	fill := func() error {
+		for !eof && len(data)-pos < bs {
+			if len(data) == cap(data) {
+				// Move the unwritten data to the start
+				n := copy(data, data[start:])
+				data = data[:n]
+				pos -= start
+				start = 0
+			}
+			n, err := in.Read(data[len(data):cap(data)])
+			data = data[:len(data)+n]
+			if err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err = fill(); err != nil {
+		return 0, err
+	}
+	for writeErr == nil {
+		n := len(data) - pos
+		if n == 0 {
+			break
+		}
+		if n < bs {
+			// Only the short last block could match the tail
+			last := len(sig.Blocks) - 1
+			tail := data[pos:]
+			if last >= 0 && int64(last)*int64(bs)+int64(n) == sig.Size && sig.Blocks[last].Weak == weakSum(tail) && sig.Blocks[last].Strong == md5.Sum(tail) {
+				flushLiteral()
+				if writeErr == nil {
+					writeErr = e.copy(int64(last)*int64(bs), int64(n))
+				}
+				pos = len(data)
+				start = pos
+			} else {
+				pos = len(data)
+			}
+			break
+		}
+		window := data[pos : pos+bs]
+		if !haveSum {
+			r.init(window)
+			haveSum = true
+		}
+		if i, ok := find(r.sum(), window); ok {
+			flushLiteral()
+			if writeErr == nil {
+				writeErr = e.copy(int64(i)*int64(bs), int64(bs))
+			}
+			pos += bs
+			start = pos
+			haveSum = false
+		} else {
+			if pos-start >= maxLiteral {
+				flushLiteral()
+			}
+			out := data[pos]
+			pos++
+			if err = fill(); err != nil {
+				return 0, err
+			}
+			if len(data)-pos >= bs {
+				r.roll(out, data[pos+bs-1])
+			} else {
+				haveSum = false
+			}
+			continue
+		}
+		if err = fill(); err != nil {
+			return 0, err
+		}
+	}
+	flushLiteral()
+	if writeErr != nil {
+		return 0, writeErr
+	}
+	if err = e.close(); err != nil {
+		return 0, err
+	}
+	return e.written, nil
+}
+
+// Apply reads the delta from in and writes the new contents it
+// describes to w using the old contents in base.
+//
+// It returns the number of bytes written.
+func Apply(w io.Writer, base io.ReaderAt, in io.Reader) (written int64, err error) {
+	br := bufio.NewReader(in)
+	for {
+		op, err := br.ReadByte()
+		if err == io.EOF {
+			return written, fmt.Errorf("unexpected end of delta: %w", ErrCorrupt)
+		} else if err != nil {
+			return written, err
+		}
+		switch op {
+		case opCopy:
+			off, err := binary.ReadUvarint(br)
+			if err != nil {
+				return written, fmt.Errorf("bad copy offset: %w", ErrCorrupt)
+			}
+			length, err := binary.ReadUvarint(br)
+			if err != nil {
+				return written, fmt.Errorf("bad copy length: %w", ErrCorrupt)
+			}
+			n, err := io.Copy(w, io.NewSectionReader(base, int64(off), int64(length)))
+			written += n
+			if err != nil {
+				return written, err
+			}
+			if n != int64(length) {
+				return written, fmt.Errorf("copy of %d bytes at %d past end of old contents: %w", length, off, ErrCorrupt)
+			}
+		case opData:
+			length, err := binary.ReadUvarint(br)
+			if err != nil {
+				return written, fmt.Errorf("bad data length: %w", ErrCorrupt)
+			}
+			n, err := io.CopyN(w, br, int64(length))
+			written += n
+			if err == io.EOF {
+				return written, fmt.Errorf("short data: %w", ErrCorrupt)
+			} else if err != nil {
+				return written, err
+			}
+		case opEnd:
+			return written, nil
+		default:
+			return written, fmt.Errorf("unknown operation %q: %w", op, ErrCorrupt)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package delta
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRolling(t *testing.T) {
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(1)).Read(data)
+	const n = 100
+	var r rolling
+	r.init(data[:n])
+	for i := 1; i+n <= len(data); i++ {
+		r.roll(data[i-1], data[i+n-1])
+		assert.Equal(t, weakSum(data[i:i+n]), r.sum(), i)
+	}
+}
+
+func TestBlockSize(t *testing.T) {
+	assert.Equal(t, MinBlockSize, BlockSize(0))
+	assert.Equal(t, MinBlockSize, BlockSize(1000))
+	assert.Equal(t, 10240, BlockSize(100*1000*1000))
+	assert.Equal(t, MaxBlockSize, BlockSize(1<<50))
+}
+
+func TestSign(t *testing.T) {
+	sig, err := Sign(bytes.NewReader([]byte("0123456789")), 4)
+	require.NoError(t, err)
+	assert.Equal(t, 4, sig.BlockSize)
+	assert.Equal(t, int64(10), sig.Size)
+	require.Equal(t, 3, len(sig.Blocks))
+	assert.Equal(t, weakSum([]byte("89")), sig.Blocks[2].Weak)
+
+	sig, err = Sign(bytes.NewReader(nil), 4)
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(sig.Blocks))
+
+	_, err = Sign(bytes.NewReader(nil), 0)
+	assert.Error(t, err)
+}
+
+// roundTrip makes a delta from old to new, checks it applies and
+// returns the amount of literal data in it
+func roundTrip(t *testing.T, old, new []byte, blockSize int) int64 {
+	sig, err := Sign(bytes.NewReader(old), blockSize)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	literal, err := Diff(&buf, sig, bytes.NewReader(new))
+	require.NoError(t, err)
+	var out bytes.Buffer
+	n, err := Apply(&out, bytes.NewReader(old), &buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(new)), n)
+	assert.True(t, bytes.Equal(new, out.Bytes()), "contents differ")
+	return literal
+}
+
+func TestDiff(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	random := func(n int) []byte {
+		p := make([]byte, n)
+		rng.Read(p)
+		return p
+	}
+	const bs = 1024
+	old := random(100*bs + 123)
+	join := func(ps ...[]byte) []byte {
+		return bytes.Join(ps, nil)
+	}
+
+	for _, test := range []struct {
+		name    string
+		old     []byte
+		new     []byte
+		literal int64
+	}{
+		{"Empty", nil, nil, 0},
+		{"FromEmpty", nil, old, int64(len(old))},
+		{"ToEmpty", old, nil, 0},
+		{"Same", old, old, 0},
+		{"Appended", old, join(old, []byte("hello")), 123 + 5}, // short last block doesn't match
+		{"Prepended", old, join([]byte("hello"), old), 5},
+		{"Truncated", old, old[:50*bs+7], 7},
+		{"Inserted", old, join(old[:10*bs+5], random(77), old[10*bs+5:]), bs + 77},
+		{"Changed", old, join(old[:20*bs], []byte("x"), old[20*bs+1:]), bs},
+		{"Reordered", old, join(old[50*bs:100*bs], old[:50*bs], old[100*bs:]), 0},
+		{"Different", old, random(5 * maxLiteral / 2), 5 * maxLiteral / 2},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			literal := roundTrip(t, test.old, test.new, bs)
+			assert.Equal(t, test.literal, literal)
+		})
+	}
+}
+
+func TestApplyCorrupt(t *testing.T) {
+	old := []byte("hello world")
+	for _, delta := range []string{
+		"",
+		"D\x05hel",
+		"C\x00\x20E",
+		"X",
+		"D\x02hi",
+	} {
+		var out bytes.Buffer
+		_, err := Apply(&out, bytes.NewReader(old), bytes.NewReader([]byte(delta)))
+		assert.ErrorIs(t, err, ErrCorrupt, delta)
+	}
+	var out bytes.Buffer
+	_, err := Apply(&out, bytes.NewReader(old), bytes.NewReader([]byte("C\x06\x05D\x01!E")))
+	require.NoError(t, err)
+	assert.Equal(t, "world!", out.String())
+}
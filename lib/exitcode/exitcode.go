@@ -22,4 +22,6 @@ const (
 	TransferExceeded
 	// NoFilesTransferred everything succeeded, but no transfer was made.
 	NoFilesTransferred
+	// MaxDeleteReached is returned when deleting files was refused by --max-delete.
+	MaxDeleteReached
 )
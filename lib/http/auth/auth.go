@@ -1,8 +1,11 @@
 package auth
 
 import (
+	"log"
+
 	"github.com/rclone/rclone/fs/config/flags"
 	"github.com/rclone/rclone/lib/http"
+	"github.com/rclone/rclone/lib/http/oidc"
 	"github.com/spf13/pflag"
 )
 
@@ -47,11 +50,17 @@ type Options struct {
 	BasicPass string       // password for BasicUser
 	Salt      string       // password hashing salt
 	Auth      CustomAuthFn `json:"-"` // custom Auth (not set by command line flags)
+	OIDC      oidc.Options // OpenID Connect authentication used instead of the above if Issuer is set
 }
 
 // Auth instantiates middleware that authenticates users based on the configuration
 func Auth(opt Options) http.Middleware {
-	if opt.Auth != nil {
+	if opt.OIDC.Issuer != "" {
+		if opt.Auth != nil || opt.HtPasswd != "" || opt.BasicUser != "" {
+			log.Fatalf("Can't use --oidc-issuer with --user or --htpasswd")
+		}
+		return OIDCAuth(opt.OIDC)
+	} else if opt.Auth != nil {
 		return CustomAuth(opt.Auth, opt.Realm)
 	} else if opt.HtPasswd != "" {
 		return HtPasswdAuth(opt.HtPasswd, opt.Realm)
@@ -65,6 +74,7 @@ func Auth(opt Options) http.Middleware {
 var (
 	Opt = Options{
 		Salt: "dlPL2MqE",
+		OIDC: oidc.DefaultOpt,
 	}
 )
 
@@ -75,6 +85,7 @@ func AddFlagsPrefix(flagSet *pflag.FlagSet, prefix string, Opt *Options) {
 	flags.StringVarP(flagSet, &Opt.BasicUser, prefix+"user", "", Opt.BasicUser, "User name for authentication")
 	flags.StringVarP(flagSet, &Opt.BasicPass, prefix+"pass", "", Opt.BasicPass, "Password for authentication")
 	flags.StringVarP(flagSet, &Opt.Salt, prefix+"salt", "", Opt.Salt, "Password hashing salt")
+	oidc.AddFlagsPrefix(flagSet, prefix, &Opt.OIDC)
 }
 
 // AddFlags adds flags for the http/auth
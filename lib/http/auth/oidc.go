@@ -0,0 +1,19 @@
+package auth
+
+import (
+	"log"
+
+	httplib "github.com/rclone/rclone/lib/http"
+	"github.com/rclone/rclone/lib/http/oidc"
+)
+
+// OIDCAuth instantiates middleware that authenticates users with an
+// OpenID Connect provider
+func OIDCAuth(opt oidc.Options) httplib.Middleware {
+	authenticator, err := oidc.New(&opt)
+	if err != nil {
+		log.Fatalf("Failed to set up OpenID Connect: %v", err)
+	}
+	// The base URL has already been stripped from the request
+	return authenticator.Middleware("", ContextUserKey)
+}
@@ -0,0 +1,297 @@
+// Package certs supplies the TLS certificates for the http servers,
+// reloading them when their files change or getting them with ACME
+package certs
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/spf13/pflag"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// Help contains text describing certificate reloading and ACME to
+// add to the command help.
+var Help = strings.Replace(`
+#### Certificate reloading and ACME
+
+The |--cert| and |--key| files are checked for changes every
+|--cert-reload-interval|. When they change the new certificate is
+used for new connections without restarting the server or dropping
+the connections which are already open. This means certificates
+renewed by an ACME client such as certbot are picked up
+automatically, even when it replaces links to the files as it does
+in its |live| directory. If the new files can't be loaded the old
+certificate is kept and they are tried again at the next check. Set
+|--cert-reload-interval 0| to only load them when the server starts.
+
+Instead of |--cert| and |--key| rclone can get certificates from
+Let's Encrypt, or another certificate authority using ACME, itself.
+Use |--acme-domain| to give the domain name of the server, repeated
+for each name it should have a certificate for, and |--acme-email|
+to give a contact address for the account. Setting |--acme-domain|
+means you accept the terms of service of the certificate authority.
+
+The certificate authority checks the server owns the domain by
+connecting to it on port 443, so use |--addr :443|. If that isn't
+possible use |--acme-http-addr :80| to answer its checks over http
+on port 80 instead. This also redirects anyone using http on that
+port to https.
+
+Certificates are kept in |--acme-cache-dir| and renewed
+automatically before they expire. Use |--acme-directory-url| to use
+a different certificate authority, for example the Let's Encrypt
+staging server |https://acme-staging-v02.api.letsencrypt.org/directory|
+for testing.
+`, "|", "`", -1)
+
+// Options contains options for reloading and getting certificates
+type Options struct {
+	ReloadInterval   time.Duration // how often to check the certificate files for changes - 0 to never
+	ACMEDomains      []string      // domains to get certificates for with ACME - ACME is off if not set
+	ACMEEmail        string        // contact email address for the ACME account
+	ACMEDirectoryURL string        // directory URL of the ACME certificate authority
+	ACMECacheDir     string        // directory to keep the ACME account and certificates in
+	ACMEHTTPAddr     string        // address to answer ACME HTTP-01 challenges on if set
+}
+
+// DefaultOpt is the default values used for Options
+var DefaultOpt = Options{
+	ReloadInterval:   time.Minute,
+	ACMEDirectoryURL: acme.LetsEncryptURL,
+}
+
+// AddFlagsPrefix adds flags for reloading and getting certificates
+func AddFlagsPrefix(flagSet *pflag.FlagSet, prefix string, Opt *Options) {
+	flags.DurationVarP(flagSet, &Opt.ReloadInterval, prefix+"cert-reload-interval", "", Opt.ReloadInterval, "How often to check the cert and key files for changes (0 to never)")
+	flags.StringArrayVarP(flagSet, &Opt.ACMEDomains, prefix+"acme-domain", "", Opt.ACMEDomains, "Domain to get a certificate for with ACME (can be repeated)")
+	flags.StringVarP(flagSet, &Opt.ACMEEmail, prefix+"acme-email", "", Opt.ACMEEmail, "Contact email address for the ACME account")
+	flags.StringVarP(flagSet, &Opt.ACMEDirectoryURL, prefix+"acme-directory-url", "", Opt.ACMEDirectoryURL, "Directory URL of the ACME certificate authority")
+	flags.StringVarP(flagSet, &Opt.ACMECacheDir, prefix+"acme-cache-dir", "", Opt.ACMECacheDir, "Directory to keep ACME certificates in (default rclone/acme in the user cache directory)")
+	flags.StringVarP(flagSet, &Opt.ACMEHTTPAddr, prefix+"acme-http-addr", "", Opt.ACMEHTTPAddr, "IPaddress:Port or :Port to answer ACME challenges over http on")
+}
+
+// Certs supplies the certificates for a TLS server
+type Certs struct {
+	reloader   *Reloader         // reloads the certificate files if set
+	manager    *autocert.Manager // gets certificates with ACME if set
+	httpServer *http.Server      // answers ACME HTTP-01 challenges if set
+}
+
+// New returns the Certs to serve the certificate in certFile and
+// keyFile, or to get with ACME if opt has any ACME domains.
+//
+// It returns nil if neither is set.
+func New(opt *Options, certFile, keyFile string) (*Certs, error) {
+	if len(opt.ACMEDomains) == 0 {
+		if opt.ACMEHTTPAddr != "" {
+			return nil, errors.New("can't use --acme-http-addr without --acme-domain")
+		}
+		if certFile == "" && keyFile == "" {
+			return nil, nil
+		}
+		reloader, err := NewReloader(certFile, keyFile, opt.ReloadInterval)
+		if err != nil {
+			return nil, err
+		}
+		return &Certs{reloader: reloader}, nil
+	}
+	if certFile != "" || keyFile != "" {
+		return nil, errors.New("can't use --acme-domain with --cert and --key")
+	}
+	cacheDir := opt.ACMECacheDir
+	if cacheDir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("can't find a directory for ACME certificates - use --acme-cache-dir: %w", err)
+		}
+		cacheDir = filepath.Join(userCacheDir, "rclone", "acme")
+	}
+	c := &Certs{
+		manager: &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			Cache:      autocert.DirCache(cacheDir),
+			HostPolicy: autocert.HostWhitelist(opt.ACMEDomains...),
+			Email:      opt.ACMEEmail,
+			Client:     &acme.Client{DirectoryURL: opt.ACMEDirectoryURL},
+		},
+	}
+	if opt.ACMEHTTPAddr != "" {
+		ln, err := net.Listen("tcp", opt.ACMEHTTPAddr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen for ACME challenges: %w", err)
+		}
+		c.httpServer = &http.Server{
+			Handler:           c.manager.HTTPHandler(nil),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			err := c.httpServer.Serve(ln)
+			if err != nil && err != http.ErrServerClosed {
+				fs.Errorf(nil, "Error serving ACME challenges: %v", err)
+			}
+		}()
+	}
+	fs.Infof(nil, "Getting TLS certificates for %s with ACME from %s", strings.Join(opt.ACMEDomains, ", "), opt.ACMEDirectoryURL)
+	return c, nil
+}
+
+// Configure sets config to get its certificates from c
+func (c *Certs) Configure(config *tls.Config) {
+	config.Certificates = nil
+	if c.manager != nil {
+		config.GetCertificate = c.manager.GetCertificate
+		// Let the certificate authority use the TLS-ALPN-01 challenge
+		config.NextProtos = []string{"h2", "http/1.1", acme.ALPNProto}
+	} else {
+		config.GetCertificate = c.reloader.GetCertificate
+	}
+}
+
+// Close stops reloading the certificates and answering ACME
+// challenges. It does nothing if c is nil.
+func (c *Certs) Close() error {
+	if c == nil {
+		return nil
+	}
+	if c.reloader != nil {
+		c.reloader.Close()
+	}
+	if c.httpServer != nil {
+		return c.httpServer.Close()
+	}
+	return nil
+}
+
+// fileStamp identifies a version of a file
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// stat returns the fileStamp of the file at path, following links
+func stat(path string) (fileStamp, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: fi.ModTime(), size: fi.Size()}, nil
+}
+
+// Reloader keeps a certificate loaded from a certificate and key file
+// up to date with the files
+type Reloader struct {
+	certFile string
+	keyFile  string
+	stop     chan struct{} // closed to stop checking the files
+	done     chan struct{} // closed when checking has stopped
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	certStamp fileStamp
+	keyStamp  fileStamp
+}
+
+// NewReloader loads the certificate in certFile and keyFile and
+// checks them for changes every interval, or never if it is 0.
+//
+// Call Close to stop checking.
+func NewReloader(certFile, keyFile string, interval time.Duration) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		close(r.done)
+		return r, nil
+	}
+	go r.run(interval)
+	return r, nil
+}
+
+// run reloads the certificate every interval until stopped
+func (r *Reloader) run(interval time.Duration) {
+	defer close(r.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := r.Reload(); err != nil {
+				fs.Errorf(nil, "Keeping the old TLS certificate: %v", err)
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// Reload loads the certificate again if either of its files have
+// changed since it was loaded.
+//
+// If they can't be loaded an error is returned and the old
+// certificate is kept.
+func (r *Reloader) Reload() error {
+	certStamp, err := stat(r.certFile)
+	if err != nil {
+		return fmt.Errorf("failed to read TLS certificate: %w", err)
+	}
+	keyStamp, err := stat(r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to read TLS key: %w", err)
+	}
+	r.mu.Lock()
+	changed := r.cert == nil || certStamp != r.certStamp || keyStamp != r.keyStamp
+	r.mu.Unlock()
+	if !changed {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	r.mu.Lock()
+	reloaded := r.cert != nil
+	r.cert = &cert
+	r.certStamp = certStamp
+	r.keyStamp = keyStamp
+	r.mu.Unlock()
+	if reloaded {
+		fs.Infof(nil, "Reloaded TLS certificate from %q", r.certFile)
+	}
+	return nil
+}
+
+// GetCertificate returns the current certificate. It can be used as
+// tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cert, nil
+}
+
+// Close stops checking the files for changes
+func (r *Reloader) Close() {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+	<-r.done
+}
@@ -0,0 +1,158 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme"
+)
+
+// writeCert writes a new self signed certificate for name to
+// certFile and keyFile with the modification time modTime
+func writeCert(t *testing.T, certFile, keyFile, name string, modTime time.Time) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	require.NoError(t, os.Chtimes(certFile, modTime, modTime))
+	require.NoError(t, os.Chtimes(keyFile, modTime, modTime))
+}
+
+// commonName returns the common name of the certificate r is serving
+func commonName(t *testing.T, r *Reloader) string {
+	cert, err := r.GetCertificate(nil)
+	require.NoError(t, err)
+	require.NotNil(t, cert)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	return leaf.Subject.CommonName
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	start := time.Now().Add(-time.Hour)
+
+	_, err := NewReloader(certFile, keyFile, 0)
+	assert.Error(t, err)
+
+	writeCert(t, certFile, keyFile, "one", start)
+	r, err := NewReloader(certFile, keyFile, 0)
+	require.NoError(t, err)
+	defer r.Close()
+	assert.Equal(t, "one", commonName(t, r))
+
+	// Unchanged files aren't loaded again
+	require.NoError(t, r.Reload())
+	assert.Equal(t, "one", commonName(t, r))
+
+	// Changed files are
+	writeCert(t, certFile, keyFile, "two", start.Add(time.Minute))
+	require.NoError(t, r.Reload())
+	assert.Equal(t, "two", commonName(t, r))
+
+	// A broken key keeps the old certificate until it is fixed
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte("potato"), 0600))
+	assert.Error(t, r.Reload())
+	assert.Equal(t, "two", commonName(t, r))
+	writeCert(t, certFile, keyFile, "three", start.Add(2*time.Minute))
+	require.NoError(t, r.Reload())
+	assert.Equal(t, "three", commonName(t, r))
+
+	// Missing files keep the old certificate
+	require.NoError(t, os.Remove(keyFile))
+	assert.Error(t, r.Reload())
+	assert.Equal(t, "three", commonName(t, r))
+}
+
+func TestReloaderInterval(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	start := time.Now().Add(-time.Hour)
+
+	writeCert(t, certFile, keyFile, "one", start)
+	r, err := NewReloader(certFile, keyFile, 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, "one", commonName(t, r))
+
+	writeCert(t, certFile, keyFile, "two", start.Add(time.Minute))
+	assert.Eventually(t, func() bool {
+		return commonName(t, r) == "two"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	r.Close()
+	r.Close() // closing twice is OK
+}
+
+func TestNew(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writeCert(t, certFile, keyFile, "one", time.Now())
+
+	// Nothing configured
+	opt := DefaultOpt
+	c, err := New(&opt, "", "")
+	require.NoError(t, err)
+	assert.Nil(t, c)
+	assert.NoError(t, c.Close())
+
+	// Certificate files
+	c, err = New(&opt, certFile, keyFile)
+	require.NoError(t, err)
+	config := &tls.Config{Certificates: []tls.Certificate{{}}}
+	c.Configure(config)
+	assert.Empty(t, config.Certificates)
+	cert, err := config.GetCertificate(&tls.ClientHelloInfo{})
+	require.NoError(t, err)
+	assert.NotNil(t, cert)
+	assert.NoError(t, c.Close())
+
+	// ACME
+	opt.ACMEDomains = []string{"rclone.example.com"}
+	opt.ACMECacheDir = dir
+	_, err = New(&opt, certFile, keyFile)
+	assert.EqualError(t, err, "can't use --acme-domain with --cert and --key")
+	c, err = New(&opt, "", "")
+	require.NoError(t, err)
+	config = &tls.Config{}
+	c.Configure(config)
+	assert.NotNil(t, config.GetCertificate)
+	assert.Contains(t, config.NextProtos, acme.ALPNProto)
+
+	// Other domains are refused without asking the certificate authority
+	_, err = config.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.example.com"})
+	assert.Error(t, err)
+	assert.NoError(t, c.Close())
+
+	// The ACME http server needs ACME
+	opt = DefaultOpt
+	opt.ACMEHTTPAddr = "localhost:0"
+	_, err = New(&opt, certFile, keyFile)
+	assert.EqualError(t, err, "can't use --acme-http-addr without --acme-domain")
+}
@@ -17,6 +17,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/lib/http/certs"
 	"github.com/spf13/pflag"
 )
 
@@ -59,7 +60,7 @@ supply --client-ca also.
 of that with the CA certificate.  --key should be the PEM encoded
 private key and --client-ca should be the PEM encoded client
 certificate authority certificate.
-`
+` + certs.Help
 
 // Middleware function signature required by chi.Router.Use()
 type Middleware func(http.Handler) http.Handler
@@ -76,6 +77,7 @@ type Options struct {
 	SslCertBody        []byte        // SSL PEM key (concatenation of certificate and CA certificate) body, ignores SslCert
 	SslKeyBody         []byte        // SSL PEM Private key body, ignores SslKey
 	ClientCA           string        // Client certificate authority to verify clients with
+	Certs              certs.Options // reloading of SslCert and SslKey or getting certificates with ACME
 }
 
 // DefaultOpt is the default values used for Options
@@ -84,6 +86,7 @@ var DefaultOpt = Options{
 	ServerReadTimeout:  1 * time.Hour,
 	ServerWriteTimeout: 1 * time.Hour,
 	MaxHeaderBytes:     4096,
+	Certs:              certs.DefaultOpt,
 }
 
 // Server interface of http server
@@ -91,6 +94,8 @@ type Server interface {
 	Router() chi.Router
 	Route(pattern string, fn func(r chi.Router)) chi.Router
 	Mount(pattern string, h http.Handler)
+	Serve()
+	Addr() net.Addr
 	Shutdown() error
 }
 
@@ -103,6 +108,7 @@ type server struct {
 	baseRouter   chi.Router
 	closing      *sync.WaitGroup
 	useSSL       bool
+	certs        *certs.Certs // supplies the certificates if not using SslCertBody
 }
 
 var (
@@ -112,7 +118,7 @@ var (
 )
 
 func useSSL(opt Options) bool {
-	return opt.SslKey != "" || len(opt.SslKeyBody) > 0
+	return opt.SslKey != "" || len(opt.SslKeyBody) > 0 || len(opt.Certs.ACMEDomains) > 0
 }
 
 // NewServer instantiates a new http server using provided listeners and options
@@ -140,20 +146,24 @@ func NewServer(listeners, tlsListeners []net.Listener, opt Options) (Server, err
 		return nil, err
 	}
 
+	var tlsCerts *certs.Certs
 	if useSSL {
-		var cert tls.Certificate
-		var err error
+		tlsConfig = &tls.Config{
+			MinVersion: tls.VersionTLS10, // disable SSL v3.0 and earlier
+		}
 		if len(opt.SslCertBody) > 0 {
-			cert, err = tls.X509KeyPair(opt.SslCertBody, opt.SslKeyBody)
+			cert, err := tls.X509KeyPair(opt.SslCertBody, opt.SslKeyBody)
+			if err != nil {
+				log.Fatal(err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
 		} else {
-			cert, err = tls.LoadX509KeyPair(opt.SslCert, opt.SslKey)
-		}
-		if err != nil {
-			log.Fatal(err)
-		}
-		tlsConfig = &tls.Config{
-			MinVersion:   tls.VersionTLS10, // disable SSL v3.0 and earlier
-			Certificates: []tls.Certificate{cert},
+			var err error
+			tlsCerts, err = certs.New(&opt.Certs, opt.SslCert, opt.SslKey)
+			if err != nil {
+				log.Fatal(err)
+			}
+			tlsCerts.Configure(tlsConfig)
 		}
 	} else if len(listeners) == 0 && len(tlsListeners) != 0 {
 		return nil, errors.New("No SslKey or non-tlsListeners")
@@ -225,7 +235,7 @@ func NewServer(listeners, tlsListeners []net.Listener, opt Options) (Server, err
 		}
 	}
 
-	return &server{addrs, tlsAddrs, listeners, tlsListeners, httpServer, router, wg, useSSL}, nil
+	return &server{addrs, tlsAddrs, listeners, tlsListeners, httpServer, router, wg, useSSL, tlsCerts}, nil
 }
 
 func (s *server) Serve() {
@@ -255,6 +265,14 @@ func (s *server) Serve() {
 	}
 }
 
+// Addr returns the first address the server is listening on
+func (s *server) Addr() net.Addr {
+	if len(s.addrs) > 0 {
+		return s.addrs[0]
+	}
+	return s.tlsAddrs[0]
+}
+
 // Wait blocks while the server is serving requests
 func (s *server) Wait() {
 	s.closing.Wait()
@@ -281,7 +299,7 @@ func (s *server) Shutdown() error {
 		return err
 	}
 	s.closing.Wait()
-	return nil
+	return s.certs.Close()
 }
 
 //---- Default HTTP server convenience functions ----
@@ -335,25 +353,34 @@ func start() error {
 		return nil
 	}
 
-	var err error
-	var l net.Listener
-	l, err = net.Listen("tcp", defaultServerOptions.ListenAddr)
+	s, err := Listen(defaultServerOptions)
 	if err != nil {
 		return err
 	}
+	defaultServer = s.(*server)
+	defaultServer.Serve()
+	return nil
+}
 
+// Listen instantiates a new http server listening on opt.ListenAddr,
+// using SSL if it is configured in opt. Call Serve to start serving
+// requests.
+func Listen(opt Options) (Server, error) {
+	l, err := net.Listen("tcp", opt.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
 	var s Server
-	if useSSL(defaultServerOptions) {
-		s, err = NewServer([]net.Listener{}, []net.Listener{l}, defaultServerOptions)
+	if useSSL(opt) {
+		s, err = NewServer([]net.Listener{}, []net.Listener{l}, opt)
 	} else {
-		s, err = NewServer([]net.Listener{l}, []net.Listener{}, defaultServerOptions)
+		s, err = NewServer([]net.Listener{l}, []net.Listener{}, opt)
 	}
 	if err != nil {
-		return err
+		_ = l.Close()
+		return nil, err
 	}
-	defaultServer = s.(*server)
-	defaultServer.Serve()
-	return nil
+	return s, nil
 }
 
 // Shutdown gracefully shuts down the default http server
@@ -410,6 +437,7 @@ func AddFlagsPrefix(flagSet *pflag.FlagSet, prefix string, Opt *Options) {
 	flags.StringVarP(flagSet, &Opt.SslKey, prefix+"key", "", Opt.SslKey, "SSL PEM Private key")
 	flags.StringVarP(flagSet, &Opt.ClientCA, prefix+"client-ca", "", Opt.ClientCA, "Client certificate authority to verify clients with")
 	flags.StringVarP(flagSet, &Opt.BaseURL, prefix+"baseurl", "", Opt.BaseURL, "Prefix for URLs - leave blank for root")
+	certs.AddFlagsPrefix(flagSet, prefix, &Opt.Certs)
 
 }
 
@@ -0,0 +1,272 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Allowance for clock skew between us and the provider when checking
+// the times in tokens
+const clockSkew = time.Minute
+
+// jwtHeader is the header of a JSON Web Token
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// claims are the claims of a JSON Web Token
+type claims map[string]interface{}
+
+// jwt is a JSON Web Token split into its parts
+type jwt struct {
+	header    jwtHeader
+	claims    claims
+	signed    []byte // the part of the token which is signed
+	signature []byte
+}
+
+// parseJWT splits a compact serialized JWT into its parts without
+// verifying it
+func parseJWT(token string) (*jwt, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token: need 3 parts")
+	}
+	t := &jwt{
+		signed: []byte(parts[0] + "." + parts[1]),
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	if err = json.Unmarshal(header, &t.header); err != nil {
+		return nil, fmt.Errorf("malformed token header: %w", err)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	if err = json.Unmarshal(payload, &t.claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims: %w", err)
+	}
+	t.signature, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature: %w", err)
+	}
+	return t, nil
+}
+
+// hashes used by each of the JWS algorithms we support
+var algHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// algCurves are the curves the keys for the ES algorithms must use
+var algCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+// minRSAKeyBits is the size of the smallest RSA key we accept
+// signatures from
+const minRSAKeyBits = 2048
+
+// verify checks the signature of the token with key.
+//
+// The key must be of the type the token's algorithm is for, EC keys
+// must use the curve of the algorithm and RSA keys must be at least
+// minRSAKeyBits long.
+func (t *jwt) verify(key crypto.PublicKey) error {
+	hash, ok := algHashes[t.header.Alg]
+	if !ok {
+		return fmt.Errorf("unsupported token signing algorithm %q", t.header.Alg)
+	}
+	h := hash.New()
+	_, _ = h.Write(t.signed)
+	digest := h.Sum(nil)
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		if t.header.Alg[0] != 'R' && t.header.Alg[0] != 'P' {
+			break
+		}
+		if bits := key.N.BitLen(); bits < minRSAKeyBits {
+			return fmt.Errorf("RSA key of %d bits is too small - need at least %d", bits, minRSAKeyBits)
+		}
+		switch t.header.Alg[0] {
+		case 'R':
+			return rsa.VerifyPKCS1v15(key, hash, digest, t.signature)
+		case 'P':
+			return rsa.VerifyPSS(key, hash, digest, t.signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+	case *ecdsa.PublicKey:
+		if t.header.Alg[0] != 'E' {
+			break
+		}
+		if key.Curve != algCurves[t.header.Alg] {
+			return fmt.Errorf("EC key on curve %s doesn't match token signing algorithm %q", key.Curve.Params().Name, t.header.Alg)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(t.signature) != 2*size {
+			return errors.New("bad signature length")
+		}
+		r := new(big.Int).SetBytes(t.signature[:size])
+		s := new(big.Int).SetBytes(t.signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errors.New("bad signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("key doesn't match token signing algorithm %q", t.header.Alg)
+}
+
+// String returns the claim called name or "" if it isn't a string
+func (c claims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns the claim called name as a list of strings. A
+// single string is returned as a list with one item.
+func (c claims) Strings(name string) (out []string) {
+	switch v := c[name].(type) {
+	case string:
+		out = append(out, v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+// Time returns the claim called name as a time and whether it was
+// present
+func (c claims) Time(name string) (time.Time, bool) {
+	v, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(v), 0), true
+}
+
+// check checks the issuer, audience and times of the claims
+func (c claims) check(issuer, audience string, now time.Time) error {
+	if iss := c.String("iss"); iss != issuer {
+		return fmt.Errorf("token issued by %q not %q", iss, issuer)
+	}
+	found := false
+	for _, aud := range c.Strings("aud") {
+		if aud == audience {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("token not issued for audience %q", audience)
+	}
+	exp, ok := c.Time("exp")
+	if !ok {
+		return errors.New("token has no expiry")
+	}
+	if now.After(exp.Add(clockSkew)) {
+		return errors.New("token has expired")
+	}
+	if nbf, ok := c.Time("nbf"); ok && now.Before(nbf.Add(-clockSkew)) {
+		return errors.New("token not valid yet")
+	}
+	return nil
+}
+
+// jwk is a JSON Web Key as found in the provider's key set
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwks is a JSON Web Key Set
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+// decodeBigInt decodes a base64url encoded big endian number
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty number")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// curves supported for EC keys
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// publicKey returns the public key described by k
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("bad RSA modulus: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("bad RSA exponent: %w", err)
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("RSA exponent too large")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		curve, ok := curves[k.Crv]
+		if !ok {
+			return nil, fmt.Errorf("unsupported EC curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("bad EC x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("bad EC y: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("EC point not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
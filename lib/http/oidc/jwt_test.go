@@ -0,0 +1,118 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTES256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	// Round trip the public key through a JWK
+	k := jwk{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+	}
+	pub, err := k.publicKey()
+	require.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"me"}`))
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+
+	token, err := parseJWT(signed + "." + base64.RawURLEncoding.EncodeToString(sig))
+	require.NoError(t, err)
+	assert.Equal(t, "ES256", token.header.Alg)
+	assert.Equal(t, "me", token.claims.String("iss"))
+	assert.NoError(t, token.verify(pub))
+
+	sig[0] ^= 1
+	token.signature = sig
+	assert.EqualError(t, token.verify(pub), "bad signature")
+
+	token.header.Alg = "RS256"
+	assert.EqualError(t, token.verify(pub), `key doesn't match token signing algorithm "RS256"`)
+
+	// The curve of the key must match the algorithm
+	token.header.Alg = "ES384"
+	assert.EqualError(t, token.verify(pub), `EC key on curve P-256 doesn't match token signing algorithm "ES384"`)
+	key384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	token.header.Alg = "ES256"
+	assert.EqualError(t, token.verify(&key384.PublicKey), `EC key on curve P-384 doesn't match token signing algorithm "ES256"`)
+
+	token.header.Alg = "none"
+	assert.EqualError(t, token.verify(pub), `unsupported token signing algorithm "none"`)
+}
+
+func TestJWTRSAKeySize(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"iss":"me"}`))
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+
+	token, err := parseJWT(signed + "." + base64.RawURLEncoding.EncodeToString(sig))
+	require.NoError(t, err)
+	assert.EqualError(t, token.verify(&key.PublicKey), "RSA key of 1024 bits is too small - need at least 2048")
+
+	token.header.Alg = "PS256"
+	assert.EqualError(t, token.verify(&key.PublicKey), "RSA key of 1024 bits is too small - need at least 2048")
+
+	token.header.Alg = "ES256"
+	assert.EqualError(t, token.verify(&key.PublicKey), `key doesn't match token signing algorithm "ES256"`)
+}
+
+func TestJWKPublicKey(t *testing.T) {
+	for _, k := range []jwk{
+		{Kty: "oct"},
+		{Kty: "EC", Crv: "P-192"},
+		{Kty: "EC", Crv: "P-256", X: "AQ", Y: "AQ"},
+		{Kty: "RSA", N: "", E: "AQAB"},
+	} {
+		_, err := k.publicKey()
+		assert.Error(t, err, k.Kty)
+	}
+}
+
+func TestClaimsCheck(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	c := claims{
+		"iss": "https://issuer",
+		"aud": "rclone",
+		"exp": float64(now.Unix() + 60),
+		"nbf": float64(now.Unix() - 60),
+	}
+	assert.NoError(t, c.check("https://issuer", "rclone", now))
+	assert.Error(t, c.check("https://other", "rclone", now))
+	assert.Error(t, c.check("https://issuer", "other", now))
+
+	// Clock skew is allowed for
+	assert.NoError(t, c.check("https://issuer", "rclone", now.Add(90*time.Second)))
+	assert.EqualError(t, c.check("https://issuer", "rclone", now.Add(3*time.Minute)), "token has expired")
+	assert.NoError(t, c.check("https://issuer", "rclone", now.Add(-90*time.Second)))
+	assert.EqualError(t, c.check("https://issuer", "rclone", now.Add(-3*time.Minute)), "token not valid yet")
+
+	delete(c, "exp")
+	assert.EqualError(t, c.check("https://issuer", "rclone", now), "token has no expiry")
+}
@@ -0,0 +1,444 @@
+// Package oidc authenticates users of the http servers with an
+// OpenID Connect provider
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/flags"
+	"github.com/rclone/rclone/lib/random"
+	"github.com/spf13/pflag"
+	"golang.org/x/oauth2"
+)
+
+// Help contains text describing OpenID Connect authentication to add
+// to the command help.
+var Help = strings.Replace(`
+#### OpenID Connect
+
+Instead of a user and password, users can be authenticated with an
+OpenID Connect (OIDC) provider such as Keycloak, Dex, Okta, Azure AD
+or Google. Set |--oidc-issuer| to the issuer URL of the provider to
+turn this on, for example
+|--oidc-issuer https://keycloak.example.com/realms/example|. It can't
+be used with |--user|, |--htpasswd|, |--auth-proxy| or |--users-file|.
+
+Programs should send a JSON Web Token (JWT) signed by the provider in
+an |Authorization: Bearer| header. This can be an ID token or, if the
+provider issues them as JWTs, an access token. The token must have
+been issued for the audience |--oidc-audience| which defaults to
+|--oidc-client-id|.
+
+To let people log in with a web browser, register rclone as a client
+with the provider and set |--oidc-client-id|, |--oidc-client-secret|
+and |--oidc-redirect-url|. The redirect URL is the URL of the server
+as seen by the browser followed by |/oidc/callback|, for example
+|https://rclone.example.com/oidc/callback|. Browsers without a valid
+token are then sent to the provider to log in, and come back with a
+session cookie which lasts for |--oidc-session-time|. Sessions are
+lost if rclone is restarted.
+
+The user name is taken from the |--oidc-user-claim| claim of the
+token, or the |sub| claim if that isn't present. Groups are taken from
+the |--oidc-groups-claim| claim. Some providers need an extra scope
+adding with |--oidc-scopes| to include the groups.
+
+By default any user the provider authenticates may use the whole
+server. Use |--oidc-rule group=path| one or more times to only allow
+members of the group to use URLs under the path, for example
+
+    --oidc-rule admins=/ --oidc-rule staff=/shared --oidc-rule '*=/public'
+
+allows the admins group to use everything, the staff group to use
+|/shared| and anyone who can log in to use |/public|. The paths are
+relative to |--baseurl|.
+`, "|", "`", -1)
+
+// Timeout for requests to the provider
+const providerTimeout = time.Minute
+
+// CallbackPath is the path relative to the base URL of the server
+// which the provider sends the user back to after logging in
+const CallbackPath = "/oidc/callback"
+
+// Options contains options for OpenID Connect authentication
+type Options struct {
+	Issuer       string          // issuer URL of the provider - OIDC is off if not set
+	ClientID     string          // client ID registered with the provider
+	ClientSecret string          // client secret registered with the provider
+	RedirectURL  string          // URL of CallbackPath for the browser login flow
+	Audience     string          // audience tokens must be issued for if not ClientID
+	Scopes       fs.CommaSepList // scopes to ask for when logging in
+	UserClaim    string          // claim to read the user name from
+	GroupsClaim  string          // claim to read the groups from
+	Rules        []string        // group=path rules allowing access
+	SessionTime  time.Duration   // how long a browser login lasts
+}
+
+// DefaultOpt is the default values used for Options
+var DefaultOpt = Options{
+	Scopes:      fs.CommaSepList{"openid", "profile", "email"},
+	UserClaim:   "preferred_username",
+	GroupsClaim: "groups",
+	SessionTime: time.Hour,
+}
+
+// AddFlagsPrefix adds flags for OpenID Connect authentication
+func AddFlagsPrefix(flagSet *pflag.FlagSet, prefix string, Opt *Options) {
+	flags.StringVarP(flagSet, &Opt.Issuer, prefix+"oidc-issuer", "", Opt.Issuer, "OpenID Connect issuer URL to authenticate users with")
+	flags.StringVarP(flagSet, &Opt.ClientID, prefix+"oidc-client-id", "", Opt.ClientID, "OpenID Connect client ID")
+	flags.StringVarP(flagSet, &Opt.ClientSecret, prefix+"oidc-client-secret", "", Opt.ClientSecret, "OpenID Connect client secret")
+	flags.StringVarP(flagSet, &Opt.RedirectURL, prefix+"oidc-redirect-url", "", Opt.RedirectURL, "URL of "+CallbackPath+" on this server to log in with a browser")
+	flags.StringVarP(flagSet, &Opt.Audience, prefix+"oidc-audience", "", Opt.Audience, "Audience tokens must be issued for (default --oidc-client-id)")
+	flags.FVarP(flagSet, &Opt.Scopes, prefix+"oidc-scopes", "", "Comma separated list of scopes to ask for when logging in")
+	flags.StringVarP(flagSet, &Opt.UserClaim, prefix+"oidc-user-claim", "", Opt.UserClaim, "Token claim containing the user name")
+	flags.StringVarP(flagSet, &Opt.GroupsClaim, prefix+"oidc-groups-claim", "", Opt.GroupsClaim, "Token claim containing the groups of the user")
+	flags.StringArrayVarP(flagSet, &Opt.Rules, prefix+"oidc-rule", "", Opt.Rules, "Allow members of a group to use a path as group=path (can be repeated)")
+	flags.DurationVarP(flagSet, &Opt.SessionTime, prefix+"oidc-session-time", "", Opt.SessionTime, "How long a browser login lasts")
+}
+
+// User is a user authenticated by the provider
+type User struct {
+	Name   string
+	Groups []string
+}
+
+// errNoCredentials is returned when the request has no token or
+// session cookie
+var errNoCredentials = errors.New("no bearer token or session cookie")
+
+// Authenticator checks users with an OpenID Connect provider
+type Authenticator struct {
+	opt      Options
+	audience string
+	redirect *url.URL // where the provider sends users back to - nil if browser login is off
+	rules    []rule
+	secret   []byte // for signing cookies
+	provider *provider
+}
+
+// New makes an Authenticator from opt
+//
+// The provider isn't contacted until it is needed.
+func New(opt *Options) (*Authenticator, error) {
+	a := &Authenticator{
+		opt:      *opt,
+		audience: opt.Audience,
+	}
+	if opt.Issuer == "" {
+		return nil, errors.New("need --oidc-issuer")
+	}
+	if _, err := url.Parse(opt.Issuer); err != nil {
+		return nil, fmt.Errorf("bad --oidc-issuer: %w", err)
+	}
+	if a.audience == "" {
+		a.audience = opt.ClientID
+	}
+	if a.audience == "" {
+		return nil, errors.New("need --oidc-client-id or --oidc-audience")
+	}
+	if opt.RedirectURL != "" {
+		if opt.ClientID == "" {
+			return nil, errors.New("need --oidc-client-id to use --oidc-redirect-url")
+		}
+		u, err := url.Parse(opt.RedirectURL)
+		if err != nil {
+			return nil, fmt.Errorf("bad --oidc-redirect-url: %w", err)
+		}
+		if !strings.HasSuffix(u.Path, CallbackPath) {
+			return nil, fmt.Errorf("--oidc-redirect-url must end in %q", CallbackPath)
+		}
+		a.redirect = u
+	}
+	if opt.SessionTime <= 0 {
+		a.opt.SessionTime = DefaultOpt.SessionTime
+	}
+	var err error
+	a.rules, err = parseRules(opt.Rules)
+	if err != nil {
+		return nil, err
+	}
+	a.secret = make([]byte, 32)
+	if _, err = rand.Read(a.secret); err != nil {
+		return nil, fmt.Errorf("failed to make cookie secret: %w", err)
+	}
+	// fshttp can't be used here as the rc server imports this package
+	a.provider = newProvider(opt.Issuer, &http.Client{Timeout: providerTimeout})
+	fs.Infof(nil, "Using OpenID Connect provider %q", opt.Issuer)
+	return a, nil
+}
+
+// verifyToken checks the token was signed by the provider for us and
+// returns its claims. If nonce is set then the token must contain it.
+func (a *Authenticator) verifyToken(ctx context.Context, token, nonce string) (claims, error) {
+	t, err := parseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	config, err := a.provider.Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	key, err := a.provider.Key(ctx, t.header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.verify(key); err != nil {
+		return nil, fmt.Errorf("token signature invalid: %w", err)
+	}
+	if err = t.claims.check(config.Issuer, a.audience, time.Now()); err != nil {
+		return nil, err
+	}
+	if nonce != "" && t.claims.String("nonce") != nonce {
+		return nil, errors.New("token has the wrong nonce")
+	}
+	return t.claims, nil
+}
+
+// user makes the User from the claims of a token
+func (a *Authenticator) user(c claims) (*User, error) {
+	user := &User{
+		Name:   c.String(a.opt.UserClaim),
+		Groups: c.Strings(a.opt.GroupsClaim),
+	}
+	if user.Name == "" {
+		user.Name = c.String("sub")
+	}
+	if user.Name == "" {
+		return nil, errors.New("token has no user name")
+	}
+	return user, nil
+}
+
+// parseBearer returns the bearer token from the Authorization header
+// if there is one
+func parseBearer(r *http.Request) (token string, ok bool) {
+	s := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(s) == 2 && strings.EqualFold(s[0], "Bearer") && s[1] != "" {
+		return s[1], true
+	}
+	return "", false
+}
+
+// Authenticate returns the user of the bearer token or session cookie
+// of r.
+func (a *Authenticator) Authenticate(r *http.Request) (*User, error) {
+	if token, ok := parseBearer(r); ok {
+		c, err := a.verifyToken(r.Context(), token, "")
+		if err != nil {
+			return nil, err
+		}
+		return a.user(c)
+	}
+	return a.sessionUser(r)
+}
+
+// Check authenticates the user of r and checks the rules allow them
+// to make the request to the server at baseURL.
+func (a *Authenticator) Check(r *http.Request, baseURL string) (*User, error) {
+	user, err := a.Authenticate(r)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.Authorize(user, r, baseURL); err != nil {
+		return user, err
+	}
+	return user, nil
+}
+
+// wantsLogin returns whether r looks like it came from a web browser
+// which can be sent to the provider to log in
+func (a *Authenticator) wantsLogin(r *http.Request) bool {
+	if a.redirect == nil || (r.Method != "GET" && r.Method != "HEAD") {
+		return false
+	}
+	if _, ok := parseBearer(r); ok {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// RequireAuth replies to a request which failed Check for the server
+// at baseURL.
+//
+// Users who authenticated but aren't allowed by the rules get 403
+// Forbidden, web browsers are sent to the provider to log in if
+// possible and everything else gets 401 Unauthorized.
+func (a *Authenticator) RequireAuth(w http.ResponseWriter, r *http.Request, baseURL string) {
+	user, err := a.Authenticate(r)
+	if err == nil {
+		fs.Infof(r.URL.Path, "%s: Forbidden request from %s", r.RemoteAddr, user.Name)
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if err != errNoCredentials {
+		fs.Infof(r.URL.Path, "%s: Unauthorized request: %v", r.RemoteAddr, err)
+	}
+	if a.wantsLogin(r) {
+		a.login(w, r, baseURL)
+		return
+	}
+	w.Header().Set("WWW-Authenticate", `Bearer realm="rclone"`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+// oauthConfig returns the OAuth2 config for the authorization code flow
+func (a *Authenticator) oauthConfig(ctx context.Context) (*oauth2.Config, error) {
+	config, err := a.provider.Config(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Config{
+		ClientID:     a.opt.ClientID,
+		ClientSecret: a.opt.ClientSecret,
+		RedirectURL:  a.redirect.String(),
+		Scopes:       []string(a.opt.Scopes),
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  config.AuthURL,
+			TokenURL: config.TokenURL,
+		},
+	}, nil
+}
+
+// login sends the browser to the provider to log in
+func (a *Authenticator) login(w http.ResponseWriter, r *http.Request, baseURL string) {
+	config, err := a.oauthConfig(r.Context())
+	if err != nil {
+		fs.Errorf(nil, "OpenID Connect login failed: %v", err)
+		http.Error(w, "OpenID Connect provider unavailable", http.StatusBadGateway)
+		return
+	}
+	state, err := random.Password(128)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	nonce, err := random.Password(128)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	// Use the URI as sent in case a prefix has been stripped from r.URL
+	to := r.RequestURI
+	if to == "" {
+		to = r.URL.RequestURI()
+	}
+	expiry := time.Now().Add(loginTime)
+	cookie, err := a.seal(&loginState{
+		State:  state,
+		Nonce:  nonce,
+		Return: to,
+		Expiry: expiry.Unix(),
+	})
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	a.setCookie(w, baseURL, stateCookie, cookie, expiry)
+	http.Redirect(w, r, config.AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce)), http.StatusFound)
+}
+
+// HandleCallback handles the provider sending the browser back to
+// the server at baseURL after logging in. It returns false if r
+// isn't for the callback.
+func (a *Authenticator) HandleCallback(w http.ResponseWriter, r *http.Request, baseURL string) bool {
+	if a.redirect == nil || r.URL.Path != baseURL+CallbackPath {
+		return false
+	}
+	err := a.callback(w, r, baseURL)
+	if err != nil {
+		fs.Infof(r.URL.Path, "%s: OpenID Connect login failed: %v", r.RemoteAddr, err)
+		http.Error(w, "Login failed: "+err.Error(), http.StatusUnauthorized)
+	}
+	return true
+}
+
+// callback checks the login and sets the session cookie
+func (a *Authenticator) callback(w http.ResponseWriter, r *http.Request, baseURL string) error {
+	ctx := r.Context()
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		return fmt.Errorf("%s: %s", e, q.Get("error_description"))
+	}
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil {
+		return errors.New("no login in progress")
+	}
+	a.clearCookie(w, baseURL, stateCookie)
+	var state loginState
+	if err = a.unseal(cookie.Value, &state); err != nil {
+		return err
+	}
+	if time.Now().Unix() > state.Expiry {
+		return errors.New("login took too long")
+	}
+	if q.Get("state") != state.State {
+		return errors.New("state doesn't match")
+	}
+	config, err := a.oauthConfig(ctx)
+	if err != nil {
+		return err
+	}
+	token, err := config.Exchange(context.WithValue(ctx, oauth2.HTTPClient, a.provider.client), q.Get("code"))
+	if err != nil {
+		return fmt.Errorf("failed to get token: %w", err)
+	}
+	idToken, _ := token.Extra("id_token").(string)
+	if idToken == "" {
+		return errors.New("provider didn't return an ID token")
+	}
+	c, err := a.verifyToken(ctx, idToken, state.Nonce)
+	if err != nil {
+		return err
+	}
+	user, err := a.user(c)
+	if err != nil {
+		return err
+	}
+	expiry := time.Now().Add(a.opt.SessionTime)
+	value, err := a.seal(&session{
+		User:   user.Name,
+		Groups: user.Groups,
+		Expiry: expiry.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	a.setCookie(w, baseURL, sessionCookie, value, expiry)
+	fs.Infof(nil, "%s: OpenID Connect login for %s", r.RemoteAddr, user.Name)
+
+	// Only send the user back to this server
+	to := state.Return
+	if !strings.HasPrefix(to, "/") || strings.HasPrefix(to, "//") {
+		to = baseURL + "/"
+	}
+	http.Redirect(w, r, to, http.StatusFound)
+	return nil
+}
+
+// Middleware returns middleware which checks requests to the server at
+// baseURL and adds the name of the user to the context under userKey.
+func (a *Authenticator) Middleware(baseURL string, userKey interface{}) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if a.HandleCallback(w, r, baseURL) {
+				return
+			}
+			user, err := a.Check(r, baseURL)
+			if err != nil {
+				a.RequireAuth(w, r, baseURL)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), userKey, user.Name))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package oidc
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testProvider is a fake OpenID Connect provider
+type testProvider struct {
+	*httptest.Server
+	key   *rsa.PrivateKey
+	nonce string // nonce to put in the ID token
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p := &testProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(providerConfig{
+			Issuer:   p.URL,
+			AuthURL:  p.URL + "/auth",
+			TokenURL: p.URL + "/token",
+			JWKSURL:  p.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jwks{Keys: []jwk{{
+			Kty: "RSA",
+			Kid: "key1",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "good-code" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "opaque",
+			"token_type":   "Bearer",
+			"id_token":     p.token(t, "key1", p.claims("alice", []string{"staff"}, p.nonce)),
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// claims returns valid claims for user
+func (p *testProvider) claims(user string, groups []string, nonce string) claims {
+	c := claims{
+		"iss":                p.URL,
+		"aud":                []interface{}{"rclone"},
+		"sub":                "id-" + user,
+		"preferred_username": user,
+		"exp":                float64(time.Now().Add(time.Hour).Unix()),
+	}
+	if groups != nil {
+		c["groups"] = groups
+	}
+	if nonce != "" {
+		c["nonce"] = nonce
+	}
+	return c
+}
+
+// token makes an RS256 token with the claims signed by the provider
+func (p *testProvider) token(t *testing.T, kid string, c claims) string {
+	header, err := json.Marshal(jwtHeader{Alg: "RS256", Kid: kid})
+	require.NoError(t, err)
+	payload, err := json.Marshal(c)
+	require.NoError(t, err)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// context key the tests store the user under
+type testUserKey struct{}
+
+func newTestAuthenticator(t *testing.T, p *testProvider, rules ...string) *Authenticator {
+	opt := DefaultOpt
+	opt.Issuer = p.URL
+	opt.ClientID = "rclone"
+	opt.RedirectURL = "http://rclone.example.com/base" + CallbackPath
+	opt.Rules = rules
+	a, err := New(&opt)
+	require.NoError(t, err)
+	return a
+}
+
+func TestNew(t *testing.T) {
+	for _, test := range []struct {
+		opt  Options
+		want string
+	}{
+		{Options{}, "need --oidc-issuer"},
+		{Options{Issuer: "https://example.com"}, "need --oidc-client-id or --oidc-audience"},
+		{Options{Issuer: "https://example.com", Audience: "api", RedirectURL: "https://rclone/oidc/callback"}, "need --oidc-client-id to use --oidc-redirect-url"},
+		{Options{Issuer: "https://example.com", ClientID: "rclone", RedirectURL: "https://rclone/callback"}, `--oidc-redirect-url must end in "/oidc/callback"`},
+		{Options{Issuer: "https://example.com", ClientID: "rclone", Rules: []string{"admins"}}, `bad --oidc-rule "admins": need group=path`},
+		{Options{Issuer: "https://example.com", ClientID: "rclone", RedirectURL: "https://rclone/oidc/callback"}, ""},
+	} {
+		_, err := New(&test.opt)
+		if test.want == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, test.want)
+		}
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	a := &Authenticator{}
+	var err error
+	a.rules, err = parseRules([]string{"admins=/", "staff=shared/", "*=/public", "a=b=/x"})
+	require.NoError(t, err)
+	assert.Equal(t, []rule{{"admins", "/"}, {"staff", "/shared"}, {"*", "/public"}, {"a=b", "/x"}}, a.rules)
+
+	alice := &User{Name: "alice", Groups: []string{"staff"}}
+	bob := &User{Name: "bob"}
+	root := &User{Name: "root", Groups: []string{"users", "admins"}}
+	for _, test := range []struct {
+		user *User
+		path string
+		dst  string
+		want bool
+	}{
+		{alice, "/base/shared", "", true},
+		{alice, "/base/shared/file.txt", "", true},
+		{alice, "/base/shared/../private", "", false},
+		{alice, "/base/sharedx", "", false},
+		{alice, "/base/", "", false},
+		{alice, "/base/public/file.txt", "", true},
+		{alice, "/base/shared/file.txt", "http://host/base/shared/copy.txt", true},
+		{alice, "/base/shared/file.txt", "http://host/base/private/file.txt", false},
+		{bob, "/base/public", "", true},
+		{bob, "/base/shared", "", false},
+		{root, "/base/anything", "", true},
+	} {
+		r := httptest.NewRequest("GET", test.path, nil)
+		if test.dst != "" {
+			r.Header.Set("Destination", test.dst)
+		}
+		err := a.Authorize(test.user, r, "/base")
+		if test.want {
+			assert.NoError(t, err, test.path)
+		} else {
+			assert.ErrorIs(t, err, errForbidden, test.path)
+		}
+	}
+
+	// No rules lets everyone in
+	assert.NoError(t, (&Authenticator{}).Authorize(bob, httptest.NewRequest("GET", "/", nil), ""))
+}
+
+func TestAuthenticateBearer(t *testing.T) {
+	p := newTestProvider(t)
+	a := newTestAuthenticator(t, p)
+
+	authenticate := func(token string) (*User, error) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		return a.Authenticate(r)
+	}
+
+	user, err := authenticate(p.token(t, "key1", p.claims("alice", []string{"staff", "users"}, "")))
+	require.NoError(t, err)
+	assert.Equal(t, &User{Name: "alice", Groups: []string{"staff", "users"}}, user)
+
+	// Falls back to sub for the user name
+	c := p.claims("bob", nil, "")
+	delete(c, "preferred_username")
+	c["aud"] = "rclone"
+	user, err = authenticate(p.token(t, "key1", c))
+	require.NoError(t, err)
+	assert.Equal(t, &User{Name: "id-bob"}, user)
+
+	c = p.claims("bob", nil, "")
+	c["aud"] = "someone-else"
+	_, err = authenticate(p.token(t, "key1", c))
+	assert.EqualError(t, err, `token not issued for audience "rclone"`)
+
+	c = p.claims("bob", nil, "")
+	c["iss"] = "https://evil.example.com"
+	_, err = authenticate(p.token(t, "key1", c))
+	assert.Contains(t, err.Error(), "token issued by")
+
+	c = p.claims("bob", nil, "")
+	c["exp"] = float64(time.Now().Add(-time.Hour).Unix())
+	_, err = authenticate(p.token(t, "key1", c))
+	assert.EqualError(t, err, "token has expired")
+
+	_, err = authenticate(p.token(t, "key2", p.claims("bob", nil, "")))
+	assert.EqualError(t, err, `token signed with unknown key "key2"`)
+
+	token := p.token(t, "key1", p.claims("bob", nil, ""))
+	_, err = authenticate(token[:len(token)-4] + "AAAA")
+	assert.Contains(t, err.Error(), "token signature invalid")
+
+	_, err = authenticate("not-a-jwt")
+	assert.EqualError(t, err, "malformed token: need 3 parts")
+
+	_, err = a.Authenticate(httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, errNoCredentials, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	p := newTestProvider(t)
+	a := newTestAuthenticator(t, p, "staff=/shared")
+	handler := a.Middleware("/base", testUserKey{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Context().Value(testUserKey{}).(string)))
+	}))
+
+	do := func(path, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := do("/base/shared/file.txt", "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer realm="rclone"`, w.Header().Get("WWW-Authenticate"))
+
+	token := p.token(t, "key1", p.claims("alice", []string{"staff"}, ""))
+	w = do("/base/shared/file.txt", token)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice", w.Body.String())
+
+	w = do("/base/private/file.txt", token)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestLogin(t *testing.T) {
+	p := newTestProvider(t)
+	a := newTestAuthenticator(t, p)
+	handler := a.Middleware("/base", testUserKey{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Context().Value(testUserKey{}).(string)))
+	}))
+	var cookies []*http.Cookie
+	do := func(target string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", target, nil)
+		r.Header.Set("Accept", "text/html")
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		for _, c := range w.Result().Cookies() {
+			if c.MaxAge >= 0 {
+				cookies = append(cookies, c)
+			}
+		}
+		return w
+	}
+
+	// The browser is sent to the provider to log in
+	w := do("/base/dir/?sort=size")
+	require.Equal(t, http.StatusFound, w.Code)
+	login, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, p.URL+"/auth", login.Scheme+"://"+login.Host+login.Path)
+	q := login.Query()
+	assert.Equal(t, "rclone", q.Get("client_id"))
+	assert.Equal(t, "http://rclone.example.com/base/oidc/callback", q.Get("redirect_uri"))
+	assert.Equal(t, "openid profile email", q.Get("scope"))
+	require.NotEqual(t, "", q.Get("state"))
+	require.NotEqual(t, "", q.Get("nonce"))
+
+	// A callback with the wrong state fails
+	saved := cookies
+	w = do("/base/oidc/callback?code=good-code&state=wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "state doesn't match")
+
+	// A token with the wrong nonce fails
+	cookies = saved
+	p.nonce = "wrong"
+	w = do("/base/oidc/callback?code=good-code&state=" + url.QueryEscape(q.Get("state")))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "wrong nonce")
+
+	// The provider sends the browser back with a code
+	cookies = saved
+	p.nonce = q.Get("nonce")
+	w = do("/base/oidc/callback?code=good-code&state=" + url.QueryEscape(q.Get("state")))
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	assert.Equal(t, "/base/dir/?sort=size", w.Header().Get("Location"))
+
+	// Now the session cookie logs the user in
+	w = do("/base/dir/")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice", w.Body.String())
+
+	// A tampered session cookie doesn't
+	for _, c := range cookies {
+		if c.Name == sessionCookie {
+			c.Value = "x" + c.Value
+		}
+	}
+	w = do("/base/dir/")
+	assert.Equal(t, http.StatusFound, w.Code)
+}
@@ -0,0 +1,164 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// Don't fetch the keys of the provider more often than this when
+// looking for a key we haven't seen
+const minKeyRefresh = time.Minute
+
+// providerConfig is the part of the provider's discovery document
+// which we use
+type providerConfig struct {
+	Issuer   string `json:"issuer"`
+	AuthURL  string `json:"authorization_endpoint"`
+	TokenURL string `json:"token_endpoint"`
+	JWKSURL  string `json:"jwks_uri"`
+}
+
+// provider is an OpenID Connect provider
+//
+// Its configuration and keys are fetched when first needed so rclone
+// can start even if the provider isn't reachable.
+type provider struct {
+	issuer   string
+	client   *http.Client
+	mu       sync.Mutex // protects the following
+	config   *providerConfig
+	keys     map[string]crypto.PublicKey // keys by ID
+	keysTime time.Time                   // when keys were fetched
+}
+
+// newProvider makes a provider for issuer
+func newProvider(issuer string, client *http.Client) *provider {
+	return &provider{
+		issuer: issuer,
+		client: client,
+	}
+}
+
+// getJSON fetches url and decodes the JSON reply into out
+func (p *provider) getJSON(ctx context.Context, url string, out interface{}) (err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer fs.CheckClose(resp.Body, &err)
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s: %s", url, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// discover returns the provider's configuration, fetching it if
+// necessary
+//
+// Call with mu held
+func (p *provider) discover(ctx context.Context) (*providerConfig, error) {
+	if p.config != nil {
+		return p.config, nil
+	}
+	var config providerConfig
+	err := p.getJSON(ctx, strings.TrimSuffix(p.issuer, "/")+"/.well-known/openid-configuration", &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpenID configuration: %w", err)
+	}
+	if strings.TrimSuffix(config.Issuer, "/") != strings.TrimSuffix(p.issuer, "/") {
+		return nil, fmt.Errorf("OpenID configuration is for issuer %q not %q", config.Issuer, p.issuer)
+	}
+	if config.JWKSURL == "" {
+		return nil, errors.New("OpenID configuration has no jwks_uri")
+	}
+	fs.Debugf(nil, "Read OpenID configuration for %q", config.Issuer)
+	p.config = &config
+	return p.config, nil
+}
+
+// Config returns the provider's configuration, fetching it if
+// necessary
+func (p *provider) Config(ctx context.Context) (*providerConfig, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.discover(ctx)
+}
+
+// refreshKeys fetches the provider's keys
+//
+// Call with mu held
+func (p *provider) refreshKeys(ctx context.Context) error {
+	config, err := p.discover(ctx)
+	if err != nil {
+		return err
+	}
+	p.keysTime = time.Now()
+	var set jwks
+	err = p.getJSON(ctx, config.JWKSURL, &set)
+	if err != nil {
+		return fmt.Errorf("failed to read provider keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for i := range set.Keys {
+		k := &set.Keys[i]
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			fs.Debugf(nil, "Ignoring provider key %q: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	fs.Debugf(nil, "Read %d keys from OpenID provider", len(keys))
+	p.keys = keys
+	return nil
+}
+
+// Key returns the provider's key with ID kid, fetching the keys again
+// if it isn't known.
+//
+// If kid is "" then the provider must have exactly one key.
+func (p *provider) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	find := func() crypto.PublicKey {
+		if kid == "" && len(p.keys) == 1 {
+			for _, key := range p.keys {
+				return key
+			}
+		}
+		return p.keys[kid]
+	}
+	if key := find(); key != nil {
+		return key, nil
+	}
+	// The provider may have rotated its keys
+	if p.keys == nil || time.Since(p.keysTime) >= minKeyRefresh {
+		if err := p.refreshKeys(ctx); err != nil {
+			return nil, err
+		}
+		if key := find(); key != nil {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("token signed with unknown key %q", kid)
+}
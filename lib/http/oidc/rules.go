@@ -0,0 +1,86 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// errForbidden is returned when an authenticated user isn't allowed
+// to use a path
+var errForbidden = errors.New("forbidden by --oidc-rule")
+
+// rule allows members of group to use the URLs under path
+type rule struct {
+	group string // "*" for any user
+	path  string // clean absolute path
+}
+
+// parseRules parses rules in the form "group=path"
+func parseRules(in []string) (rules []rule, err error) {
+	for _, s := range in {
+		i := strings.LastIndex(s, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("bad --oidc-rule %q: need group=path", s)
+		}
+		rules = append(rules, rule{
+			group: s[:i],
+			path:  path.Clean("/" + s[i+1:]),
+		})
+	}
+	return rules, nil
+}
+
+// pathUnder returns whether p is dir or inside it
+func pathUnder(p, dir string) bool {
+	return dir == "/" || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// allows returns whether the rule lets user use the URL path p
+func (r *rule) allows(user *User, p string) bool {
+	if !pathUnder(p, r.path) {
+		return false
+	}
+	if r.group == "*" {
+		return true
+	}
+	for _, group := range user.Groups {
+		if group == r.group {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize checks the rules allow user to make the request r to the
+// server at baseURL.
+//
+// As well as the URL, the Destination header used by WebDAV to copy
+// and move files is checked.
+func (a *Authenticator) Authorize(user *User, r *http.Request, baseURL string) error {
+	if len(a.rules) == 0 {
+		return nil
+	}
+	paths := []string{r.URL.Path}
+	if dst := r.Header.Get("Destination"); dst != "" {
+		u, err := url.Parse(dst)
+		if err != nil {
+			return fmt.Errorf("bad Destination: %w", err)
+		}
+		paths = append(paths, u.Path)
+	}
+outer:
+	for _, p := range paths {
+		p = path.Clean("/" + strings.TrimPrefix(p, baseURL))
+		for i := range a.rules {
+			if a.rules[i].allows(user, p) {
+				continue outer
+			}
+		}
+		return fmt.Errorf("%w: %q may not use %q", errForbidden, user.Name, p)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Names of the cookies used in the login flow
+const (
+	sessionCookie = "rclone_oidc_session"
+	stateCookie   = "rclone_oidc_state"
+)
+
+// How long the user has to log in with the provider
+const loginTime = 10 * time.Minute
+
+// session is stored in a cookie once the user has logged in
+type session struct {
+	User   string   `json:"u"`
+	Groups []string `json:"g,omitempty"`
+	Expiry int64    `json:"e"`
+}
+
+// loginState is stored in a cookie while the user logs in with the
+// provider
+type loginState struct {
+	State  string `json:"s"`
+	Nonce  string `json:"n"`
+	Return string `json:"r"` // URL to send the user back to
+	Expiry int64  `json:"e"`
+}
+
+// errBadCookie is returned if a cookie hasn't been signed by us
+var errBadCookie = errors.New("bad cookie")
+
+// mac returns the signature of value
+func (a *Authenticator) mac(value string) []byte {
+	h := hmac.New(sha256.New, a.secret)
+	_, _ = h.Write([]byte(value))
+	return h.Sum(nil)
+}
+
+// seal encodes v as JSON and signs it so it can be stored in a cookie
+func (a *Authenticator) seal(v interface{}) (string, error) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	value := base64.RawURLEncoding.EncodeToString(buf)
+	return value + "." + base64.RawURLEncoding.EncodeToString(a.mac(value)), nil
+}
+
+// unseal checks the signature of a cookie made by seal and decodes
+// it into v
+func (a *Authenticator) unseal(cookie string, v interface{}) error {
+	i := strings.LastIndex(cookie, ".")
+	if i < 0 {
+		return errBadCookie
+	}
+	value := cookie[:i]
+	sig, err := base64.RawURLEncoding.DecodeString(cookie[i+1:])
+	if err != nil || !hmac.Equal(sig, a.mac(value)) {
+		return errBadCookie
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return errBadCookie
+	}
+	return json.Unmarshal(buf, v)
+}
+
+// setCookie sets a cookie called name for the server at baseURL which
+// expires at expiry
+func (a *Authenticator) setCookie(w http.ResponseWriter, baseURL, name, value string, expiry time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     baseURL + "/",
+		Expires:  expiry,
+		HttpOnly: true,
+		Secure:   a.redirect != nil && a.redirect.Scheme == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// clearCookie removes the cookie called name
+func (a *Authenticator) clearCookie(w http.ResponseWriter, baseURL, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Path:     baseURL + "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+}
+
+// sessionUser returns the user from the session cookie of r
+func (a *Authenticator) sessionUser(r *http.Request) (*User, error) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return nil, errNoCredentials
+	}
+	var s session
+	if err = a.unseal(cookie.Value, &s); err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() > s.Expiry {
+		return nil, errors.New("session has expired")
+	}
+	return &User{Name: s.User, Groups: s.Groups}, nil
+}
@@ -0,0 +1,113 @@
+package serve
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETag makes a strong entity tag for an object from its size and
+// modification time.
+//
+// This is cheap to compute unlike a hash, but changes whenever the
+// object is modified.
+func ETag(size int64, modTime time.Time) string {
+	return fmt.Sprintf(`"%x-%x"`, modTime.UnixNano(), size)
+}
+
+// CheckPreconditions evaluates the If-Match, If-Unmodified-Since,
+// If-None-Match and If-Modified-Since headers of r against the etag
+// and modTime of the resource as described in RFC 7232.
+//
+// If the request shouldn't go ahead it writes a 304 Not Modified or
+// 412 Precondition Failed response and returns true. This should be
+// called before any headers describing the body are set.
+//
+// etag or modTime may be empty if they aren't known.
+func CheckPreconditions(w http.ResponseWriter, r *http.Request, etag string, modTime time.Time) (done bool) {
+	modTime = modTime.Truncate(time.Second)
+	hasModTime := !modTime.IsZero() && !modTime.Equal(time.Unix(0, 0))
+
+	// If-Match takes precedence over If-Unmodified-Since
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if !etagListMatch(ifMatch, etag, false) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return true
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && hasModTime {
+		if modTime.After(since) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return true
+		}
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since
+	notModified := false
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if etagListMatch(ifNoneMatch, etag, true) {
+			if r.Method != "GET" && r.Method != "HEAD" {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return true
+			}
+			notModified = true
+		}
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && hasModTime {
+		if (r.Method == "GET" || r.Method == "HEAD") && !modTime.After(since) {
+			notModified = true
+		}
+	}
+	if notModified {
+		h := w.Header()
+		delete(h, "Content-Type")
+		delete(h, "Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// RangeApplies returns whether the Range header of r should be
+// honoured given the If-Range header, the etag and the modTime of the
+// resource. If it returns false then the whole resource should be
+// sent instead.
+func RangeApplies(r *http.Request, etag string, modTime time.Time) bool {
+	ifRange := r.Header.Get("If-Range")
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return etagListMatch(ifRange, etag, false)
+	}
+	since, err := http.ParseTime(ifRange)
+	if err != nil || modTime.IsZero() {
+		return false
+	}
+	return modTime.Truncate(time.Second).Equal(since)
+}
+
+// etagListMatch returns whether etag matches one of the entity tags
+// in the comma separated list, or the list is "*" which matches any
+// resource which exists.
+//
+// If weak is set then the weak comparison is used, otherwise weak
+// entity tags never match.
+func etagListMatch(list, etag string, weak bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	if etag == "" {
+		return false
+	}
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if !strings.HasPrefix(tag, "W/") && !strings.HasPrefix(etag, "W/") && tag == etag {
+			return true
+		}
+	}
+	return false
+}
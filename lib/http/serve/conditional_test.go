@@ -0,0 +1,94 @@
+package serve
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestETag(t *testing.T) {
+	modTime := time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Equal(t, `"d23a56f80df3200-40"`, ETag(64, modTime))
+	assert.NotEqual(t, ETag(64, modTime), ETag(65, modTime))
+	assert.NotEqual(t, ETag(64, modTime), ETag(64, modTime.Add(time.Nanosecond)))
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	const etag = `"abc"`
+	modTime := time.Date(2000, 1, 2, 3, 4, 5, 600, time.UTC)
+	before := modTime.Add(-time.Hour).Format(http.TimeFormat)
+	at := modTime.Format(http.TimeFormat)
+	for _, test := range []struct {
+		method  string
+		headers []string
+		want    int
+	}{
+		{"GET", nil, 0},
+		{"GET", []string{"If-None-Match", `"abc"`}, http.StatusNotModified},
+		{"HEAD", []string{"If-None-Match", `"xyz", W/"abc"`}, http.StatusNotModified},
+		{"GET", []string{"If-None-Match", `"xyz"`}, 0},
+		{"GET", []string{"If-None-Match", `*`}, http.StatusNotModified},
+		{"PUT", []string{"If-None-Match", `"abc"`}, http.StatusPreconditionFailed},
+		{"GET", []string{"If-Modified-Since", at}, http.StatusNotModified},
+		{"GET", []string{"If-Modified-Since", before}, 0},
+		{"GET", []string{"If-Modified-Since", "potato"}, 0},
+		// If-None-Match takes precedence over If-Modified-Since
+		{"GET", []string{"If-None-Match", `"xyz"`, "If-Modified-Since", at}, 0},
+		{"GET", []string{"If-Match", `"abc"`}, 0},
+		{"GET", []string{"If-Match", `"xyz"`}, http.StatusPreconditionFailed},
+		{"GET", []string{"If-Match", `W/"abc"`}, http.StatusPreconditionFailed},
+		{"GET", []string{"If-Match", `*`}, 0},
+		{"GET", []string{"If-Unmodified-Since", at}, 0},
+		{"GET", []string{"If-Unmodified-Since", before}, http.StatusPreconditionFailed},
+		// If-Match takes precedence over If-Unmodified-Since
+		{"GET", []string{"If-Match", `"abc"`, "If-Unmodified-Since", before}, 0},
+		{"GET", []string{"If-Match", `"abc"`, "If-None-Match", `"abc"`}, http.StatusNotModified},
+	} {
+		r := httptest.NewRequest(test.method, "http://example.com/file", nil)
+		for i := 0; i < len(test.headers); i += 2 {
+			r.Header.Set(test.headers[i], test.headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		w.Header().Set("Content-Length", "64")
+		done := CheckPreconditions(w, r, etag, modTime)
+		assert.Equal(t, test.want != 0, done, "%s %v", test.method, test.headers)
+		if done {
+			assert.Equal(t, test.want, w.Code, "%s %v", test.method, test.headers)
+			if test.want == http.StatusNotModified {
+				assert.Equal(t, "", w.Header().Get("Content-Length"))
+			}
+		}
+	}
+
+	// Dates are ignored if the modification time isn't known
+	r := httptest.NewRequest("GET", "http://example.com/file", nil)
+	r.Header.Set("If-Modified-Since", at)
+	assert.False(t, CheckPreconditions(httptest.NewRecorder(), r, etag, time.Time{}))
+}
+
+func TestRangeApplies(t *testing.T) {
+	const etag = `"abc"`
+	modTime := time.Date(2000, 1, 2, 3, 4, 5, 600, time.UTC)
+	for _, test := range []struct {
+		ifRange string
+		want    bool
+	}{
+		{"", true},
+		{`"abc"`, true},
+		{`"xyz"`, false},
+		{`W/"abc"`, false},
+		{modTime.Format(http.TimeFormat), true},
+		{modTime.Add(-time.Hour).Format(http.TimeFormat), false},
+		{"potato", false},
+	} {
+		r := httptest.NewRequest("GET", "http://example.com/file", nil)
+		r.Header.Set("Range", "bytes=0-1")
+		if test.ifRange != "" {
+			r.Header.Set("If-Range", test.ifRange)
+		}
+		assert.Equal(t, test.want, RangeApplies(r, etag, modTime), test.ifRange)
+	}
+}
@@ -2,6 +2,7 @@ package serve
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"net/http"
@@ -221,7 +222,64 @@ const (
 	sortByTime         = "time"
 )
 
-// Serve serves a directory
+// jsonEntry is an entry in the JSON directory listing
+type jsonEntry struct {
+	Name    string
+	URL     string
+	IsDir   bool
+	Size    int64
+	ModTime string `json:",omitempty"`
+}
+
+// jsonDirectory is the JSON directory listing
+type jsonDirectory struct {
+	Path    string
+	Entries []jsonEntry
+}
+
+// wantsJSON returns whether the request asked for a JSON directory
+// listing, either with the format=json query parameter or by
+// accepting application/json but not text/html.
+func wantsJSON(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "json"
+	}
+	accept := r.Header.Get("Accept")
+	return strings.Contains(accept, "application/json") && !strings.Contains(accept, "text/html")
+}
+
+// serveJSON serves the directory as JSON
+func (d *Directory) serveJSON(w http.ResponseWriter) {
+	out := jsonDirectory{
+		Path:    d.Name,
+		Entries: make([]jsonEntry, 0, len(d.Entries)),
+	}
+	for _, entry := range d.Entries {
+		item := jsonEntry{
+			Name:  strings.TrimSuffix(entry.Leaf, "/"),
+			URL:   entry.URL,
+			IsDir: entry.IsDir,
+			Size:  entry.Size,
+		}
+		if !entry.ModTime.IsZero() {
+			item.ModTime = entry.ModTime.Format(time.RFC3339Nano)
+		}
+		out.Entries = append(out.Entries, item)
+	}
+	buf, err := json.MarshalIndent(out, "", "\t")
+	if err != nil {
+		Error(d.DirRemote, w, "Failed to encode JSON", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, err = w.Write(append(buf, '\n'))
+	if err != nil {
+		Error(d.DirRemote, nil, "Failed to write JSON", err)
+	}
+}
+
+// Serve serves a directory as HTML or as JSON if the request asks
+// for it with wantsJSON
 func (d *Directory) Serve(w http.ResponseWriter, r *http.Request) {
 	// Account the transfer
 	tr := accounting.Stats(r.Context()).NewTransferRemoteSize(d.DirRemote, -1)
@@ -229,6 +287,12 @@ func (d *Directory) Serve(w http.ResponseWriter, r *http.Request) {
 
 	fs.Infof(d.DirRemote, "%s: Serving directory", r.RemoteAddr)
 
+	w.Header().Add("Vary", "Accept")
+	if wantsJSON(r) {
+		d.serveJSON(w)
+		return
+	}
+
 	buf := &bytes.Buffer{}
 	err := d.HTMLTemplate.Execute(buf, d)
 	if err != nil {
@@ -123,3 +123,57 @@ func TestServe(t *testing.T) {
 </html>
 `, string(body))
 }
+
+func TestServeJSON(t *testing.T) {
+	modTime := time.Date(2000, 1, 2, 3, 4, 5, 0, time.UTC)
+	d := NewDirectory("aDirectory", GetTemplate(t))
+	d.AddHTMLEntry("aDirectory/file name.txt", false, 64, modTime)
+	d.AddHTMLEntry("aDirectory/dir", true, 0, time.Time{})
+
+	for _, test := range []struct {
+		url    string
+		accept string
+		json   bool
+	}{
+		{"http://example.com/aDirectory/", "", false},
+		{"http://example.com/aDirectory/", "text/html,application/xhtml+xml,application/json;q=0.9", false},
+		{"http://example.com/aDirectory/", "application/json", true},
+		{"http://example.com/aDirectory/?format=json", "", true},
+		{"http://example.com/aDirectory/?format=html", "application/json", false},
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", test.url, nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		d.Serve(w, r)
+		resp := w.Result()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "Accept", resp.Header.Get("Vary"))
+		body, _ := ioutil.ReadAll(resp.Body)
+		if !test.json {
+			assert.Contains(t, string(body), "<html", test.url)
+			continue
+		}
+		assert.Equal(t, "application/json; charset=utf-8", resp.Header.Get("Content-Type"))
+		assert.Equal(t, `{
+	"Path": "/aDirectory",
+	"Entries": [
+		{
+			"Name": "file name.txt",
+			"URL": "file%20name.txt",
+			"IsDir": false,
+			"Size": 64,
+			"ModTime": "2000-01-02T03:04:05Z"
+		},
+		{
+			"Name": "dir",
+			"URL": "dir/",
+			"IsDir": true,
+			"Size": 0
+		}
+	]
+}
+`, string(body), test.url)
+	}
+}
@@ -462,6 +462,9 @@ func ConfigOAuth(ctx context.Context, name string, m configmap.Mapper, ri *fs.Re
 		if in.Result == "false" {
 			return fs.ConfigGoto(newState("*oauth-done"))
 		}
+		if fs.IsConfigOAuthURL(ctx) {
+			return fs.ConfigGoto(newState("*oauth-url"))
+		}
 		return fs.ConfigConfirm(newState("*oauth-islocal"), true, "config_is_local", "Use auto config?\n * Say Y if not sure\n * Say N if you are working on a remote or headless machine\n")
 	case "*oauth-islocal":
 		if in.Result == "true" {
@@ -533,6 +536,47 @@ version recommended):
 			m.Set(fs.ConfigToken, code)
 		}
 		return fs.ConfigGoto(newState("*oauth-done"))
+	case "*oauth-url":
+		opt, err := getOAuth()
+		if err != nil {
+			return nil, err
+		}
+		oauthConfig, _ := overrideCredentials(name, m, opt.OAuth2Config)
+		authURL, oauthState, err := getAuthURL(name, m, fixRedirect(oauthConfig), opt)
+		if err != nil {
+			return nil, err
+		}
+		// Push the OAuth state under the next state to check the redirect with
+		out, err := fs.ConfigInput(fs.StatePush(stateParams, "*oauth-code", oauthState), "config_oauth_redirect", fmt.Sprintf("OAuth redirect\n\nGo to this URL, authenticate then paste the URL it redirects to here.\n\n%s\n", authURL))
+		if err != nil {
+			return nil, err
+		}
+		out.OAuthURL = authURL
+		return out, nil
+	case "*oauth-code":
+		var oauthState string
+		stateParams, oauthState = fs.StatePop(stateParams)
+		opt, err := getOAuth()
+		if err != nil {
+			return nil, err
+		}
+		auth, err := parseRedirect(in.Result, oauthState, opt)
+		if err != nil {
+			return fs.ConfigError(newState("*oauth-url"), err.Error())
+		}
+		oauthConfig, _ := overrideCredentials(name, m, opt.OAuth2Config)
+		oauthConfig = fixRedirect(oauthConfig)
+		if opt.CheckAuth != nil {
+			err = opt.CheckAuth(oauthConfig, auth)
+			if err != nil {
+				return nil, err
+			}
+		}
+		err = configExchange(ctx, name, m, oauthConfig, auth.Code)
+		if err != nil {
+			return nil, err
+		}
+		return fs.ConfigGoto(newState("*oauth-done"))
 	case "*oauth-do":
 		code := in.Result
 		opt, err := getOAuth()
@@ -592,6 +636,40 @@ func getAuthURL(name string, m configmap.Mapper, oauthConfig *oauth2.Config, opt
 	return authURL, state, nil
 }
 
+// parseRedirect reads the code from the URL the user was redirected
+// to after authorizing, checking its state is oauthState. The code
+// on its own is accepted too.
+func parseRedirect(redirect string, oauthState string, opt *Options) (*AuthResult, error) {
+	redirect = strings.TrimSpace(redirect)
+	if redirect == "" {
+		return nil, errors.New("no redirect URL or code entered")
+	}
+	if !strings.Contains(redirect, "?") {
+		return &AuthResult{OK: true, Code: redirect}, nil
+	}
+	u, err := url.Parse(redirect)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse redirect URL: %w", err)
+	}
+	form := u.Query()
+	if authErr := form.Get("error"); authErr != "" {
+		return nil, &AuthResult{
+			Name:        authErr,
+			Description: form.Get("error_description"),
+			HelpURL:     form.Get("error_uri"),
+		}
+	}
+	code := form.Get("code")
+	if code == "" {
+		return nil, errors.New("no code in redirect URL")
+	}
+	state := form.Get("state")
+	if state != oauthState && !(state == "" && opt.StateBlankOK) {
+		return nil, errors.New("auth state in redirect URL doesn't match")
+	}
+	return &AuthResult{OK: true, Code: code, Form: form}, nil
+}
+
 // If TitleBarRedirect is set but we are doing a real oauth, then
 // override our redirect URL
 func fixRedirect(oauthConfig *oauth2.Config) *oauth2.Config {
@@ -0,0 +1,119 @@
+package oauthutil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/config/configmap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+func TestParseRedirect(t *testing.T) {
+	opt := &Options{}
+	for _, test := range []struct {
+		redirect string
+		code     string
+		err      bool
+	}{
+		{"", "", true},
+		{"  abc  ", "abc", false},
+		{"http://127.0.0.1:53682/?code=abc&state=STATE", "abc", false},
+		{"http://127.0.0.1:53682/?code=abc&state=WRONG", "", true},
+		{"http://127.0.0.1:53682/?code=abc", "", true},
+		{"http://127.0.0.1:53682/?state=STATE", "", true},
+		{"http://127.0.0.1:53682/?error=access_denied&state=STATE", "", true},
+	} {
+		auth, err := parseRedirect(test.redirect, "STATE", opt)
+		if test.err {
+			assert.Error(t, err, test.redirect)
+			continue
+		}
+		require.NoError(t, err, test.redirect)
+		assert.True(t, auth.OK)
+		assert.Equal(t, test.code, auth.Code, test.redirect)
+	}
+
+	// Blank state allowed if StateBlankOK
+	auth, err := parseRedirect("http://127.0.0.1:53682/?code=abc&hostname=api.example.com", "STATE", &Options{StateBlankOK: true})
+	require.NoError(t, err)
+	assert.Equal(t, "abc", auth.Code)
+	assert.Equal(t, "api.example.com", auth.Form.Get("hostname"))
+}
+
+func TestConfigOAuthURL(t *testing.T) {
+	ctx := fs.ConfigOAuthURL(context.Background())
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.Form.Get("code") != "good-code" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		assert.Equal(t, RedirectURL, r.Form.Get("redirect_uri"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"ACCESS","token_type":"Bearer","refresh_token":"REFRESH"}`))
+	}))
+	defer tokenServer.Close()
+
+	ri := &fs.RegInfo{
+		Name: "oauthtest",
+		Config: func(ctx context.Context, name string, m configmap.Mapper, in fs.ConfigIn) (*fs.ConfigOut, error) {
+			return ConfigOut("", &Options{
+				OAuth2Config: &oauth2.Config{
+					ClientID: "client",
+					Endpoint: oauth2.Endpoint{
+						AuthURL:  "https://auth.example.com/auth",
+						TokenURL: tokenServer.URL,
+					},
+					RedirectURL: TitleBarRedirectURL,
+				},
+			})
+		},
+	}
+	m := configmap.Simple{}
+
+	// Start the config and check we are asked for the redirect
+	// with the URL to authorize at
+	start := func() *fs.ConfigOut {
+		out, err := fs.BackendConfig(ctx, "test", m, ri, configmap.Simple{}, fs.ConfigIn{})
+		require.NoError(t, err)
+		require.NotNil(t, out.Option)
+		assert.Equal(t, "config_oauth_redirect", out.Option.Name)
+		require.NotEqual(t, "", out.OAuthURL)
+		return out
+	}
+	out := start()
+	authURL, err := url.Parse(out.OAuthURL)
+	require.NoError(t, err)
+	assert.Equal(t, "auth.example.com", authURL.Host)
+	assert.Equal(t, RedirectURL, authURL.Query().Get("redirect_uri"))
+	state := authURL.Query().Get("state")
+	require.NotEqual(t, "", state)
+
+	// A redirect with the wrong state is an error
+	bad, err := fs.BackendConfig(ctx, "test", m, ri, configmap.Simple{}, fs.ConfigIn{
+		State:  out.State,
+		Result: RedirectURL + "?code=good-code&state=wrong",
+	})
+	require.NoError(t, err)
+	assert.NotEqual(t, "", bad.Error)
+	_, found := m.Get("token")
+	assert.False(t, found)
+
+	// The code in the redirect is exchanged for a token
+	done, err := fs.BackendConfig(ctx, "test", m, ri, configmap.Simple{}, fs.ConfigIn{
+		State:  out.State,
+		Result: RedirectURL + "?code=good-code&state=" + url.QueryEscape(state),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "", done.State)
+	token, err := GetToken("test", m)
+	require.NoError(t, err)
+	assert.Equal(t, "ACCESS", token.AccessToken)
+	assert.Equal(t, "REFRESH", token.RefreshToken)
+}
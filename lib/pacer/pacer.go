@@ -3,6 +3,7 @@ package pacer
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 
 	liberrors "github.com/rclone/rclone/lib/errors"
@@ -170,6 +171,17 @@ func (p *Pacer) beginCall() {
 	p.mu.Unlock()
 }
 
+// retries counts the calls retried by all the pacers
+var retries int64
+
+// Retries returns the number of calls which have been retried by all
+// the pacers so far. Retries are usually caused by rate limiting or
+// other transient errors so this can be used to see how congested the
+// remotes are.
+func Retries() int64 {
+	return atomic.LoadInt64(&retries)
+}
+
 // endCall implements the pacing algorithm
 //
 // This should calculate a new sleepTime.  It takes a boolean as to
@@ -181,6 +193,7 @@ func (p *Pacer) endCall(retry bool, err error) {
 	p.mu.Lock()
 	if retry {
 		p.state.ConsecutiveRetries++
+		atomic.AddInt64(&retries, 1)
 	} else {
 		p.state.ConsecutiveRetries = 0
 	}
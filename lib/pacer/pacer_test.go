@@ -287,18 +287,22 @@ func TestEndCall(t *testing.T) {
 	p := New(MaxConnectionsOption(5))
 	emptyTokens(p)
 	p.state.ConsecutiveRetries = 1
+	before := Retries()
 	p.endCall(true, nil)
 	assert.Equal(t, 1, len(p.connTokens))
 	assert.Equal(t, 2, p.state.ConsecutiveRetries)
+	assert.Equal(t, before+1, Retries())
 }
 
 func TestEndCallZeroConnections(t *testing.T) {
 	p := New(MaxConnectionsOption(0))
 	emptyTokens(p)
 	p.state.ConsecutiveRetries = 1
+	before := Retries()
 	p.endCall(false, nil)
 	assert.Equal(t, 0, len(p.connTokens))
 	assert.Equal(t, 0, p.state.ConsecutiveRetries)
+	assert.Equal(t, before, Retries())
 }
 
 var errFoo = errors.New("foo")
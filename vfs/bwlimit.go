@@ -0,0 +1,82 @@
+package vfs
+
+import (
+	"context"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"golang.org/x/time/rate"
+)
+
+// newHandleLimiter returns a bandwidth limiter for a single file
+// handle as set by --vfs-handle-bwlimit or nil if there is no limit.
+//
+// The bucket starts full so small reads and writes aren't delayed.
+func newHandleLimiter(opt *vfscommon.Options) *rate.Limiter {
+	if opt.HandleBwLimit <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(opt.HandleBwLimit), int(opt.HandleBwLimit))
+}
+
+// newVFSLimiter returns a bandwidth limiter shared by all the file
+// handles of the VFS as set by --vfs-bwlimit or nil if there is no
+// limit.
+func newVFSLimiter(opt *vfscommon.Options) *rate.Limiter {
+	if opt.BwLimit <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(opt.BwLimit), int(opt.BwLimit))
+}
+
+// limitWaits is embedded in the file handles so their bandwidth limit
+// waits can be cancelled without taking the handle's lock, which a
+// handle being throttled holds while it waits. This lets a throttled
+// handle be force closed.
+type limitWaits struct {
+	waitCtx    context.Context // cancelled to stop the waits
+	cancelWait context.CancelFunc
+}
+
+// newLimitWaits makes a limitWaits ready to wait
+func newLimitWaits() limitWaits {
+	ctx, cancel := context.WithCancel(context.Background())
+	return limitWaits{waitCtx: ctx, cancelWait: cancel}
+}
+
+// cancelWaits stops any current and future bandwidth limit waits
+func (w *limitWaits) cancelWaits() {
+	w.cancelWait()
+}
+
+// waitCanceller is satisfied by file handles whose bandwidth limit
+// waits can be cancelled
+type waitCanceller interface {
+	cancelWaits()
+}
+
+// limitHandle sleeps for the correct amount of time for the passage
+// of n bytes through tb which may be nil, recording the time spent
+// in t. It returns early if ctx is cancelled.
+func limitHandle(ctx context.Context, tb *rate.Limiter, n int, t *slowTrace) {
+	if tb == nil || ctx.Err() != nil {
+		return
+	}
+	defer t.time(slowLimit)()
+	// WaitN fails if asked for more than the burst size so wait
+	// in chunks
+	for n > 0 {
+		chunk := n
+		if burst := tb.Burst(); chunk > burst {
+			chunk = burst
+		}
+		err := tb.WaitN(ctx, chunk)
+		if err != nil {
+			if ctx.Err() == nil {
+				fs.Errorf(nil, "VFS handle bandwidth limit error: %v", err)
+			}
+			return
+		}
+		n -= chunk
+	}
+}
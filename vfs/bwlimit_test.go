@@ -0,0 +1,96 @@
+package vfs
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHandleLimiter(t *testing.T) {
+	opt := vfscommon.DefaultOpt
+	assert.Nil(t, newHandleLimiter(&opt))
+
+	opt.HandleBwLimit = 4 * fs.Mebi
+	tb := newHandleLimiter(&opt)
+	require.NotNil(t, tb)
+	assert.Equal(t, 4*1024*1024, tb.Burst())
+}
+
+func TestLimitHandle(t *testing.T) {
+	// nil limiter does nothing
+	ctx := context.Background()
+	limitHandle(ctx, nil, 1<<30, nil)
+
+	opt := vfscommon.DefaultOpt
+	opt.HandleBwLimit = 1000
+	tb := newHandleLimiter(&opt)
+	require.NotNil(t, tb)
+
+	// The first burst is free, the next 500 bytes should take 0.5s
+	start := time.Now()
+	limitHandle(ctx, tb, 1500, nil)
+	assert.True(t, time.Since(start) >= 400*time.Millisecond)
+
+	// Cancelling the context stops the wait
+	w := newLimitWaits()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		w.cancelWaits()
+	}()
+	start = time.Now()
+	limitHandle(w.waitCtx, tb, 10000, nil)
+	assert.True(t, time.Since(start) < 5*time.Second)
+
+	// and any later waits
+	start = time.Now()
+	limitHandle(w.waitCtx, tb, 10000, nil)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestNewVFSLimiter(t *testing.T) {
+	opt := vfscommon.DefaultOpt
+	assert.Nil(t, newVFSLimiter(&opt))
+
+	opt.BwLimit = 10 * fs.Mebi
+	tb := newVFSLimiter(&opt)
+	require.NotNil(t, tb)
+	assert.Equal(t, 10*1024*1024, tb.Burst())
+}
+
+func TestCloseHandleWhileLimited(t *testing.T) {
+	opt := vfscommon.DefaultOpt
+	opt.HandleBwLimit = 1000
+	r, vfs, cleanup := newTestVFSOpt(t, &opt)
+	defer cleanup()
+
+	file1 := r.WriteObject(context.Background(), "file1", strings.Repeat("x", 20000), t1)
+	r.CheckRemoteItems(t, file1)
+
+	fd, err := vfs.OpenFile("file1", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	handles := vfs.Handles()
+	require.Len(t, handles, 1)
+
+	// The second read waits for the limit with the handle locked
+	done := make(chan struct{})
+	go func() {
+		buf := make([]byte, 10000)
+		_, _ = fd.Read(buf[:1000])
+		_, _ = fd.Read(buf)
+		close(done)
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	start := time.Now()
+	require.NoError(t, vfs.CloseHandle(handles[0].ID))
+	assert.True(t, time.Since(start) < 5*time.Second)
+	<-done
+	assert.Len(t, vfs.Handles(), 0)
+}
@@ -0,0 +1,160 @@
+package vfs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// maxChangeEvents is the number of change events kept in memory
+// per VFS. Clients which fall further behind than this will miss
+// events and should do a full rescan.
+const maxChangeEvents = 4096
+
+// maxChangesTimeout is the longest a caller may wait for new events
+const maxChangesTimeout = 5 * time.Minute
+
+// ChangeEvent describes a single change observed by the VFS
+type ChangeEvent struct {
+	Seq     uint64    `json:"seq"`               // sequence number of this event
+	Time    time.Time `json:"time"`              // when the change was observed
+	Op      string    `json:"op"`                // what happened, e.g. "write", "remove", "rename", "mkdir", "change"
+	Source  string    `json:"source"`            // "local" for changes through the VFS, "remote" for ChangeNotify
+	Path    string    `json:"path"`              // path of the item relative to the VFS root
+	OldPath string    `json:"oldPath,omitempty"` // old path of the item for renames
+	IsDir   bool      `json:"isDir"`             // set if the item is a directory
+}
+
+// Change operations
+const (
+	ChangeWrite  = "write"  // file was written and uploaded
+	ChangeRemove = "remove" // file or directory was removed
+	ChangeRename = "rename" // file or directory was renamed
+	ChangeMkdir  = "mkdir"  // directory was created
+	ChangeRemote = "change" // remote reported a change
+)
+
+// Change sources
+const (
+	ChangeSourceLocal  = "local"
+	ChangeSourceRemote = "remote"
+)
+
+// changeLog keeps a bounded list of recent ChangeEvents
+type changeLog struct {
+	mu     sync.Mutex
+	seq    uint64        // sequence number of the last event
+	events []ChangeEvent // ring buffer of events - allocated on first use
+	head   int           // index of the oldest event in events
+	n      int           // number of events in events
+	wake   chan struct{} // closed when new events arrive
+}
+
+// newChangeLog makes a new empty changeLog
+func newChangeLog() *changeLog {
+	return &changeLog{
+		wake: make(chan struct{}),
+	}
+}
+
+// add records an event, filling in Seq and Time
+func (cl *changeLog) add(ev ChangeEvent) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+	if cl.events == nil {
+		cl.events = make([]ChangeEvent, maxChangeEvents)
+	}
+	cl.seq++
+	ev.Seq = cl.seq
+	ev.Time = time.Now()
+	if cl.n < len(cl.events) {
+		cl.events[(cl.head+cl.n)%len(cl.events)] = ev
+		cl.n++
+	} else {
+		// overwrite the oldest event
+		cl.events[cl.head] = ev
+		cl.head = (cl.head + 1) % len(cl.events)
+	}
+	close(cl.wake)
+	cl.wake = make(chan struct{})
+}
+
+// _since returns the events after seq and whether any events were
+// lost because seq is older than the oldest event kept.
+//
+// Call with cl.mu held
+func (cl *changeLog) _since(seq uint64) (events []ChangeEvent, lost bool) {
+	events = []ChangeEvent{}
+	if cl.n == 0 || seq >= cl.seq {
+		return events, false
+	}
+	oldest := cl.seq - uint64(cl.n) + 1
+	start := 0
+	if seq+1 < oldest {
+		lost = true
+	} else {
+		start = int(seq + 1 - oldest)
+	}
+	for i := start; i < cl.n; i++ {
+		events = append(events, cl.events[(cl.head+i)%len(cl.events)])
+	}
+	return events, lost
+}
+
+// wait returns the events after seq, waiting up to timeout for new
+// events if there aren't any. It also returns the latest sequence
+// number and whether events were lost.
+//
+// timeout is limited to maxChangesTimeout.
+func (cl *changeLog) wait(ctx context.Context, seq uint64, timeout time.Duration) (events []ChangeEvent, last uint64, lost bool) {
+	if timeout > maxChangesTimeout {
+		timeout = maxChangesTimeout
+	}
+	var timeoutChan <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+	for {
+		var wake chan struct{}
+		cl.mu.Lock()
+		events, lost = cl._since(seq)
+		last, wake = cl.seq, cl.wake
+		cl.mu.Unlock()
+		if len(events) > 0 || timeout <= 0 {
+			return events, last, lost
+		}
+		select {
+		case <-wake:
+		case <-timeoutChan:
+			return events, last, lost
+		case <-ctx.Done():
+			return events, last, lost
+		}
+	}
+}
+
+// notifyChange records a change to the item at path
+func (vfs *VFS) notifyChange(op, source, path, oldPath string, isDir bool) {
+	fs.Debugf(path, "VFS change: %s from %s", op, source)
+	vfs.changes.add(ChangeEvent{
+		Op:      op,
+		Source:  source,
+		Path:    path,
+		OldPath: oldPath,
+		IsDir:   isDir,
+	})
+}
+
+// Changes returns the change events observed after seq, waiting up
+// to timeout (at most 5 minutes) for new events if there are none.
+//
+// It also returns the sequence number of the latest event, which
+// should be passed in as seq on the next call, and whether some
+// events after seq were discarded before they could be read.
+func (vfs *VFS) Changes(ctx context.Context, seq uint64, timeout time.Duration) (events []ChangeEvent, last uint64, lost bool) {
+	return vfs.changes.wait(ctx, seq, timeout)
+}
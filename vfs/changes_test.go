@@ -0,0 +1,103 @@
+package vfs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeLog(t *testing.T) {
+	ctx := context.Background()
+	cl := newChangeLog()
+
+	events, last, lost := cl.wait(ctx, 0, 0)
+	assert.Equal(t, []ChangeEvent{}, events)
+	assert.Equal(t, uint64(0), last)
+	assert.False(t, lost)
+
+	cl.add(ChangeEvent{Op: ChangeWrite, Path: "a"})
+	cl.add(ChangeEvent{Op: ChangeRemove, Path: "b"})
+
+	events, last, lost = cl.wait(ctx, 0, 0)
+	require.Len(t, events, 2)
+	assert.Equal(t, uint64(1), events[0].Seq)
+	assert.Equal(t, "a", events[0].Path)
+	assert.Equal(t, uint64(2), events[1].Seq)
+	assert.Equal(t, "b", events[1].Path)
+	assert.Equal(t, uint64(2), last)
+	assert.False(t, lost)
+
+	events, _, _ = cl.wait(ctx, 1, 0)
+	require.Len(t, events, 1)
+	assert.Equal(t, "b", events[0].Path)
+
+	// Check waiting for an event
+	type result struct {
+		events []ChangeEvent
+		last   uint64
+	}
+	results := make(chan result)
+	go func() {
+		events, last, _ := cl.wait(ctx, 2, time.Minute)
+		results <- result{events, last}
+	}()
+	cl.add(ChangeEvent{Op: ChangeMkdir, Path: "c"})
+	res := <-results
+	events, last = res.events, res.last
+	require.Len(t, events, 1)
+	assert.Equal(t, "c", events[0].Path)
+	assert.Equal(t, uint64(3), last)
+
+	// Check timeout and cancellation return no events
+	events, _, _ = cl.wait(ctx, 3, time.Millisecond)
+	assert.Len(t, events, 0)
+	cancelCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	events, _, _ = cl.wait(cancelCtx, 3, time.Minute)
+	assert.Len(t, events, 0)
+
+	// Check overflow
+	for i := 0; i < maxChangeEvents; i++ {
+		cl.add(ChangeEvent{Op: ChangeWrite, Path: "x"})
+	}
+	events, _, lost = cl.wait(ctx, 0, 0)
+	assert.Len(t, events, maxChangeEvents)
+	assert.True(t, lost)
+	events, _, lost = cl.wait(ctx, 3, 0)
+	assert.Len(t, events, maxChangeEvents)
+	assert.False(t, lost)
+	assert.Equal(t, uint64(4), events[0].Seq)
+	assert.Equal(t, uint64(maxChangeEvents+3), events[len(events)-1].Seq)
+	events, _, lost = cl.wait(ctx, maxChangeEvents+2, 0)
+	require.Len(t, events, 1)
+	assert.False(t, lost)
+}
+
+func TestVFSChanges(t *testing.T) {
+	_, vfs, cleanup := newTestVFS(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	require.NoError(t, vfs.Mkdir("dir", 0777))
+	require.NoError(t, vfs.Rename("dir", "dir2"))
+	require.NoError(t, vfs.Remove("dir2"))
+
+	events, last, lost := vfs.Changes(ctx, 0, 0)
+	assert.False(t, lost)
+	assert.Equal(t, uint64(3), last)
+	require.Len(t, events, 3)
+	assert.Equal(t, ChangeMkdir, events[0].Op)
+	assert.Equal(t, "dir", events[0].Path)
+	assert.True(t, events[0].IsDir)
+	assert.Equal(t, ChangeRename, events[1].Op)
+	assert.Equal(t, "dir2", events[1].Path)
+	assert.Equal(t, "dir", events[1].OldPath)
+	assert.Equal(t, ChangeRemove, events[2].Op)
+	assert.Equal(t, "dir2", events[2].Path)
+	for _, ev := range events {
+		assert.Equal(t, ChangeSourceLocal, ev.Source)
+	}
+}
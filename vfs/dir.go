@@ -241,6 +241,7 @@ func (d *Dir) changeNotify(relativePath string, entryType fs.EntryType) {
 	if entryType == fs.EntryDirectory {
 		d.invalidateDir(absPath)
 	}
+	d.vfs.notifyChange(ChangeRemote, ChangeSourceRemote, absPath, "", entryType == fs.EntryDirectory)
 }
 
 // ForgetPath clears the cache for itself and all subdirectories if
@@ -441,7 +442,7 @@ func (d *Dir) DelVirtual(leaf string) {
 }
 
 // read the directory and sets d.items - must be called with the lock held
-func (d *Dir) _readDir() error {
+func (d *Dir) _readDir(t *slowTrace) error {
 	when := time.Now()
 	if age, stale := d._age(when); stale {
 		if age != 0 {
@@ -450,7 +451,9 @@ func (d *Dir) _readDir() error {
 	} else {
 		return nil
 	}
+	stop := t.time(slowBackend)
 	entries, err := list.DirSorted(context.TODO(), d.f, false, d.path)
+	stop()
 	if err == fs.ErrorDirNotFound {
 		// We treat directory not found as empty because we
 		// create directories on the fly
@@ -611,6 +614,7 @@ func (mv manageVirtuals) end(d *Dir) {
 // set the last read time - must be called with the lock held
 func (d *Dir) _readDirFromEntries(entries fs.DirEntries, dirTree dirtree.DirTree, when time.Time) error {
 	var err error
+	var files []*File
 	mv := d._newManageVirtuals()
 	for _, entry := range entries {
 		name := path.Base(entry.Remote())
@@ -630,6 +634,9 @@ func (d *Dir) _readDirFromEntries(entries fs.DirEntries, dirTree dirtree.DirTree
 			} else {
 				node = newFile(d, d.path, obj, name)
 			}
+			if d.vfs.Opt.Metadata {
+				files = append(files, node.(*File))
+			}
 		case fs.Directory:
 			// Reuse old dir value if it exists
 			if node == nil || !node.IsDir() {
@@ -657,6 +664,7 @@ func (d *Dir) _readDirFromEntries(entries fs.DirEntries, dirTree dirtree.DirTree
 		d.items[name] = node
 	}
 	mv.end(d)
+	readFilesMetadata(d.vfs, files)
 	return nil
 }
 
@@ -688,7 +696,13 @@ func (d *Dir) readDir() error {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 	d.read = time.Time{}
-	return d._readDir()
+	return d._readDir(nil)
+}
+
+// lock takes d.mu recording the time spent waiting for it in t
+func (d *Dir) lock(t *slowTrace) {
+	defer t.time(slowLock)()
+	d.mu.Lock()
 }
 
 // stat a single item in the directory
@@ -696,13 +710,19 @@ func (d *Dir) readDir() error {
 // returns ENOENT if not found.
 // returns a custom error if directory on a case-insensitive file system
 // contains files with names that differ only by case.
-func (d *Dir) stat(leaf string) (Node, error) {
-	d.mu.Lock()
+//
+// The time spent is recorded in t which may be nil.
+func (d *Dir) stat(leaf string, t *slowTrace) (Node, error) {
+	if snapRoot := d.snapshotRoot(leaf); snapRoot != nil {
+		return snapRoot, nil
+	}
+	d.lock(t)
 	defer d.mu.Unlock()
-	err := d._readDir()
+	err := d._readDir(t)
 	if err != nil {
 		return nil, err
 	}
+	defer t.time(slowCache)()
 	item, ok := d.items[leaf]
 
 	if !ok && d.vfs.Opt.CaseInsensitive {
@@ -727,10 +747,10 @@ func (d *Dir) stat(leaf string) (Node, error) {
 }
 
 // Check to see if a directory is empty
-func (d *Dir) isEmpty() (bool, error) {
-	d.mu.Lock()
+func (d *Dir) isEmpty(t *slowTrace) (bool, error) {
+	d.lock(t)
 	defer d.mu.Unlock()
-	err := d._readDir()
+	err := d._readDir(t)
 	if err != nil {
 		return false, err
 	}
@@ -796,7 +816,9 @@ func (d *Dir) cachedNode(relativePath string) Node {
 // Stat need not to handle the names "." and "..".
 func (d *Dir) Stat(name string) (node Node, err error) {
 	// fs.Debugf(path, "Dir.Stat")
-	node, err = d.stat(name)
+	t := d.vfs.traceSlow("stat", d, name)
+	defer t.done()
+	node, err = d.stat(name, t)
 	if err != nil {
 		if err != ENOENT {
 			fs.Errorf(d, "Dir.Stat error: %v", err)
@@ -810,8 +832,10 @@ func (d *Dir) Stat(name string) (node Node, err error) {
 // ReadDirAll reads the contents of the directory sorted
 func (d *Dir) ReadDirAll() (items Nodes, err error) {
 	// fs.Debugf(d.path, "Dir.ReadDirAll")
-	d.mu.Lock()
-	err = d._readDir()
+	t := d.vfs.traceSlow("readdir", d, "")
+	defer t.done()
+	d.lock(t)
+	err = d._readDir(t)
 	if err != nil {
 		fs.Debugf(d.path, "Dir.ReadDirAll error: %v", err)
 		d.mu.Unlock()
@@ -843,7 +867,7 @@ func (d *Dir) Open(flags int) (fd Handle, err error) {
 func (d *Dir) Create(name string, flags int) (*File, error) {
 	// fs.Debugf(path, "Dir.Create")
 	// Return existing node if one exists
-	node, err := d.stat(name)
+	node, err := d.stat(name, nil)
 	switch err {
 	case ENOENT:
 		// not found, carry on
@@ -872,7 +896,9 @@ func (d *Dir) Mkdir(name string) (*Dir, error) {
 		return nil, EROFS
 	}
 	path := path.Join(d.path, name)
-	node, err := d.stat(name)
+	t := d.vfs.traceSlow("mkdir", d, name)
+	defer t.done()
+	node, err := d.stat(name, t)
 	switch err {
 	case ENOENT:
 		// not found, carry on
@@ -888,7 +914,9 @@ func (d *Dir) Mkdir(name string) (*Dir, error) {
 		return nil, err
 	}
 	// fs.Debugf(path, "Dir.Mkdir")
+	stop := t.time(slowBackend)
 	err = d.f.Mkdir(context.TODO(), path)
+	stop()
 	if err != nil {
 		fs.Errorf(d, "Dir.Mkdir failed to create directory: %v", err)
 		return nil, err
@@ -896,6 +924,7 @@ func (d *Dir) Mkdir(name string) (*Dir, error) {
 	fsDir := fs.NewDir(path, time.Now())
 	dir := newDir(d.vfs, d.f, d, fsDir)
 	d.addObject(dir)
+	d.vfs.notifyChange(ChangeMkdir, ChangeSourceLocal, path, "", true)
 	// fs.Debugf(path, "Dir.Mkdir OK")
 	return dir, nil
 }
@@ -905,8 +934,10 @@ func (d *Dir) Remove() error {
 	if d.vfs.Opt.ReadOnly {
 		return EROFS
 	}
+	t := d.vfs.traceSlow("rmdir", d, "")
+	defer t.done()
 	// Check directory is empty first
-	empty, err := d.isEmpty()
+	empty, err := d.isEmpty(t)
 	if err != nil {
 		fs.Errorf(d, "Dir.Remove dir error: %v", err)
 		return err
@@ -916,7 +947,9 @@ func (d *Dir) Remove() error {
 		return ENOTEMPTY
 	}
 	// remove directory
+	stop := t.time(slowBackend)
 	err = d.f.Rmdir(context.TODO(), d.path)
+	stop()
 	if err != nil {
 		fs.Errorf(d, "Dir.Remove failed to remove directory: %v", err)
 		return err
@@ -925,6 +958,7 @@ func (d *Dir) Remove() error {
 	if d.parent != nil {
 		d.parent.delObject(d.Name())
 	}
+	d.vfs.notifyChange(ChangeRemove, ChangeSourceLocal, d.Path(), "", true)
 	return nil
 }
 
@@ -962,7 +996,7 @@ func (d *Dir) RemoveName(name string) error {
 		return EROFS
 	}
 	// fs.Debugf(path, "Dir.Remove")
-	node, err := d.stat(name)
+	node, err := d.stat(name, nil)
 	if err != nil {
 		fs.Errorf(d, "Dir.Remove error: %v", err)
 		return err
@@ -973,17 +1007,21 @@ func (d *Dir) RemoveName(name string) error {
 // Rename the file
 func (d *Dir) Rename(oldName, newName string, destDir *Dir) error {
 	// fs.Debugf(d, "BEFORE\n%s", d.dump())
-	if d.vfs.Opt.ReadOnly {
+	if d.vfs.Opt.ReadOnly || destDir.vfs.Opt.ReadOnly {
 		return EROFS
 	}
 	oldPath := path.Join(d.path, oldName)
 	newPath := path.Join(destDir.path, newName)
 	// fs.Debugf(oldPath, "Dir.Rename to %q", newPath)
-	oldNode, err := d.stat(oldName)
+	t := d.vfs.traceSlow("rename", d, oldName)
+	defer t.done()
+	oldNode, err := d.stat(oldName, t)
 	if err != nil {
 		fs.Errorf(oldPath, "Dir.Rename error: %v", err)
 		return err
 	}
+	stop := t.time(slowBackend)
+	defer stop()
 	switch x := oldNode.DirEntry().(type) {
 	case nil:
 		if oldFile, ok := oldNode.(*File); ok {
@@ -1033,10 +1071,12 @@ func (d *Dir) Rename(oldName, newName string, destDir *Dir) error {
 		fs.Errorf(d.path, "Dir.Rename error: %v", err)
 		return err
 	}
+	stop()
 
 	// Show moved - delete from old dir and add to new
 	d.delObject(oldName)
 	destDir.addObject(oldNode)
+	d.vfs.notifyChange(ChangeRename, ChangeSourceLocal, newPath, oldPath, oldNode.IsDir())
 
 	// fs.Debugf(newPath, "Dir.Rename renamed from %q", oldPath)
 	// fs.Debugf(d, "AFTER\n%s", d.dump())
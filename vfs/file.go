@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -46,12 +47,16 @@ type File struct {
 	leaf             string                          // leaf name of the object
 	writers          []Handle                        // writers for this file
 	nwriters         int32                           // len(writers) which is read/updated with atomic
+	nreaders         int32                           // number of open ReadFileHandles which is read/updated with atomic
 	pendingModTime   time.Time                       // will be applied once o becomes available, i.e. after file was written
+	meta             fs.Metadata                     // metadata of o with setMeta applied, nil if not read yet - replace, don't modify
+	setMeta          fs.Metadata                     // metadata set with Chmod, Chown and SetAccessTime to store on o
+	pendingMeta      bool                            // set if setMeta needs to be applied to o
 	pendingRenameFun func(ctx context.Context) error // will be run/renamed after all writers close
 	appendMode       bool                            // file was opened with O_APPEND
 	sys              atomic.Value                    // user defined info to be attached here
 
-	muRW sync.Mutex // synchronize RWFileHandle.openPending(), RWFileHandle.close() and File.Remove
+	muRW sync.Mutex // synchronize RWFileHandle.openPending(), RWFileHandle.close(), File.Remove and prefetching
 }
 
 // newFile creates a new File
@@ -90,16 +95,63 @@ func (f *File) IsDir() bool {
 }
 
 // Mode bits of the file or directory - satisfies Node interface
+//
+// If --vfs-metadata is set then the permissions come from the cached
+// metadata of the object if it has them.
 func (f *File) Mode() (mode os.FileMode) {
+	meta := f.metadata()
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 	mode = f.d.vfs.Opt.FilePerms
+	if v, ok := meta["mode"]; ok {
+		perm, err := strconv.ParseUint(v, 8, 32)
+		if err == nil {
+			mode = os.FileMode(perm) & os.ModePerm
+		}
+	}
 	if f.appendMode {
 		mode |= os.ModeAppend
 	}
 	return mode
 }
 
+// Owner returns the uid and gid of the file
+//
+// These are --uid and --gid unless --vfs-metadata is set and the
+// cached metadata of the object has them.
+func (f *File) Owner() (uid, gid uint32) {
+	meta := f.metadata()
+	opt := &f.VFS().Opt
+	return parseMetadataID(meta["uid"], opt.UID), parseMetadataID(meta["gid"], opt.GID)
+}
+
+// parseMetadataID parses a uid or gid from the metadata returning def
+// if it isn't set or isn't valid
+func parseMetadataID(v string, def uint32) uint32 {
+	if v == "" {
+		return def
+	}
+	id, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return def
+	}
+	return uint32(id)
+}
+
+// AccessTime returns the access time of the file
+//
+// This is the modification time unless --vfs-metadata is set and the
+// cached metadata of the object has an access time.
+func (f *File) AccessTime() time.Time {
+	if v, ok := f.metadata()["atime"]; ok {
+		atime, err := time.Parse(time.RFC3339Nano, v)
+		if err == nil {
+			return atime
+		}
+	}
+	return f.ModTime()
+}
+
 // Name (base) of the directory - satisfies Node interface
 func (f *File) Name() (name string) {
 	f.mu.RLock()
@@ -425,6 +477,161 @@ func (f *File) _applyPendingModTime() error {
 	return nil
 }
 
+// metadata returns the cached metadata of the file or nil if it
+// hasn't been read yet or --vfs-metadata isn't set.
+//
+// It never reads the metadata from the object so it is cheap to call
+// when finding the attributes of the file. The result must not be
+// modified.
+func (f *File) metadata() fs.Metadata {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.meta
+}
+
+// readMetadata reads the metadata from the object into the cache
+func (f *File) readMetadata(ctx context.Context) {
+	f.mu.RLock()
+	o := f.o
+	f.mu.RUnlock()
+	if o == nil {
+		return
+	}
+	meta, err := fs.GetMetadata(ctx, o)
+	if err != nil {
+		fs.Debugf(o, "Failed to read metadata: %v", err)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.o != o {
+		// changed while reading so leave it for the next read
+		return
+	}
+	newMeta := fs.Metadata{}
+	newMeta.Merge(meta)
+	newMeta.Merge(f.setMeta)
+	f.meta = newMeta
+}
+
+// readFilesMetadata reads the metadata of files into their caches in
+// the background if --vfs-metadata is set.
+//
+// It reads up to --checkers at once.
+func readFilesMetadata(vfs *VFS, files []*File) {
+	if !vfs.Opt.Metadata || len(files) == 0 {
+		return
+	}
+	ctx := context.Background()
+	checkers := fs.GetConfig(ctx).Checkers
+	go func() {
+		limiter := make(chan struct{}, checkers)
+		for _, file := range files {
+			limiter <- struct{}{}
+			go func(file *File) {
+				defer func() { <-limiter }()
+				file.readMetadata(ctx)
+			}(file)
+		}
+	}()
+}
+
+// Chmod changes the permissions of the file
+//
+// They are stored in the metadata of the object if --vfs-metadata is
+// set and the remote can set metadata, otherwise this does nothing.
+func (f *File) Chmod(mode os.FileMode) error {
+	const modeRegular = 0100000 // S_IFREG
+	return f.setMetadata(fs.Metadata{
+		"mode": fmt.Sprintf("0%o", modeRegular|uint32(mode.Perm())),
+	})
+}
+
+// Chown changes the uid and gid of the file
+//
+// A uid or gid of -1 is left unchanged. They are stored in the same
+// way as Chmod.
+func (f *File) Chown(uid, gid int) error {
+	metadata := fs.Metadata{}
+	if uid >= 0 {
+		metadata["uid"] = strconv.Itoa(uid)
+	}
+	if gid >= 0 {
+		metadata["gid"] = strconv.Itoa(gid)
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+	return f.setMetadata(metadata)
+}
+
+// SetAccessTime sets the access time of the file
+//
+// It is stored in the same way as Chmod.
+func (f *File) SetAccessTime(atime time.Time) error {
+	return f.setMetadata(fs.Metadata{
+		"atime": atime.Format(time.RFC3339Nano),
+	})
+}
+
+// setMetadata stores metadata in the object, or queues it up until
+// the object is written if there are writers.
+func (f *File) setMetadata(metadata fs.Metadata) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.d.vfs.Opt.Metadata {
+		return nil
+	}
+	if f.d.vfs.Opt.ReadOnly {
+		return EROFS
+	}
+	f.setMeta.Merge(metadata)
+	f.pendingMeta = true
+	newMeta := fs.Metadata{}
+	newMeta.Merge(f.meta)
+	newMeta.Merge(metadata)
+	f.meta = newMeta
+
+	// Only update the metadata when there are no writers, setObject will do it
+	if !f._writingInProgress() {
+		return f._applyPendingMetadata()
+	}
+
+	// queue up for later, hoping f.o becomes available
+	return nil
+}
+
+// Apply the pending metadata
+// Call with the mutex held
+func (f *File) _applyPendingMetadata() error {
+	if !f.pendingMeta {
+		return nil
+	}
+	if f.o == nil {
+		return errors.New("Cannot apply metadata, file object is not available")
+	}
+	f.pendingMeta = false
+
+	err := fs.SetMetadata(context.TODO(), f.o, f.setMeta)
+	switch {
+	case err == nil:
+		fs.Debugf(f.o, "Applied pending metadata %v OK", f.setMeta)
+	case errors.Is(err, fs.ErrorNotImplemented):
+		fs.Debugf(f.o, "Not storing metadata %v as the remote can't set it", f.setMeta)
+	default:
+		fs.Errorf(f.o, "Failed to apply pending metadata %v: %v", f.setMeta, err)
+		return err
+	}
+	return nil
+}
+
+// Apply the pending metadata
+func (f *File) applyPendingMetadata() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f._applyPendingMetadata()
+}
+
 // Apply a pending mod time
 func (f *File) applyPendingModTime() error {
 	f.mu.Lock()
@@ -455,11 +662,17 @@ func (f *File) setObject(o fs.Object) {
 	f.mu.Lock()
 	f.o = o
 	_ = f._applyPendingModTime()
+	// The upload may have replaced the metadata so store it again
+	if len(f.setMeta) > 0 {
+		f.pendingMeta = true
+	}
+	_ = f._applyPendingMetadata()
 	d := f.d
 	f.mu.Unlock()
 
 	// Release File.mu before calling Dir method
 	d.addObject(f)
+	d.vfs.notifyChange(ChangeWrite, ChangeSourceLocal, f.Path(), "", false)
 }
 
 // Update the object but don't update the directory cache - for use by
@@ -522,6 +735,17 @@ func (f *File) openRead() (fh *ReadFileHandle, err error) {
 	return fh, nil
 }
 
+// dedupeRead returns true if a read only open should go through the
+// VFS cache because --vfs-dedupe-reads is set and the file is already
+// being read directly from the remote.
+//
+// Once one reader is using the cache, later ones will find the item
+// in use and share its download too.
+func (f *File) dedupeRead() bool {
+	opt := &f.VFS().Opt
+	return opt.DedupeReads && opt.CacheMode >= vfscommon.CacheModeMinimal && atomic.LoadInt32(&f.nreaders) > 0
+}
+
 // openWrite open the file for write
 func (f *File) openWrite(flags int) (fh *WriteFileHandle, err error) {
 	f.mu.RLock()
@@ -580,24 +804,44 @@ func (f *File) Remove() (err error) {
 	if d.vfs.Opt.ReadOnly {
 		return EROFS
 	}
+	t := d.vfs.traceSlow("remove", f, "")
+	defer t.done()
 
 	// Remove the object from the cache
 	wasWriting := false
+	stop := t.time(slowCache)
 	if d.vfs.cache != nil && d.vfs.cache.Exists(f.Path()) {
 		wasWriting = d.vfs.cache.Remove(f.Path())
 	}
+	stop()
 
 	// Remove the item from the directory listing
 	// called with File.mu released
 	d.delObject(f.Name())
 
+	stop = t.time(slowLock)
 	f.muRW.Lock() // muRW must be locked before mu to avoid
 	f.mu.Lock()   // deadlock in RWFileHandle.openPending and .close
+	stop()
+	var trashObj fs.Object
 	if f.o != nil {
-		err = f.o.Remove(context.TODO())
+		if d.vfs.useTrash(f.o.Remote()) {
+			// moving into the trash may copy the file so do
+			// it once the locks are released
+			trashObj = f.o
+		} else {
+			stop = t.time(slowBackend)
+			err = f.o.Remove(context.TODO())
+			stop()
+		}
 	}
 	f.mu.Unlock()
 	f.muRW.Unlock()
+	if trashObj != nil {
+		stop = t.time(slowBackend)
+		err = d.vfs.moveToTrash(context.TODO(), trashObj)
+		stop()
+	}
 	if err != nil {
 		if wasWriting {
 			// Ignore error deleting file if was writing it as it may not be uploaded yet
@@ -607,6 +851,10 @@ func (f *File) Remove() (err error) {
 			fs.Debugf(f._path(), "File.Remove file error: %v", err)
 		}
 	}
+	if err == nil {
+		d.vfs.forgetTrash()
+		d.vfs.notifyChange(ChangeRemove, ChangeSourceLocal, f.Path(), "", false)
+	}
 	return err
 }
 
@@ -702,7 +950,14 @@ func (f *File) Open(flags int) (fd Handle, err error) {
 	f.mu.RLock()
 	d := f.d
 	f.mu.RUnlock()
+	t := d.vfs.traceSlow("open", f, "")
+	defer t.done()
 	CacheMode := d.vfs.Opt.CacheMode
+	if CacheMode >= vfscommon.CacheModeMinimal {
+		// Without the cache the backend is opened on the first
+		// read or write so is timed there
+		defer t.time(slowCache)()
+	}
 	if CacheMode >= vfscommon.CacheModeMinimal && (d.vfs.cache.InUse(f.Path()) || d.vfs.cache.Exists(f.Path())) {
 		fd, err = f.openRW(flags)
 	} else if read && write {
@@ -721,7 +976,7 @@ func (f *File) Open(flags int) (fd Handle, err error) {
 			fd, err = f.openWrite(flags)
 		}
 	} else if read {
-		if CacheMode >= vfscommon.CacheModeFull {
+		if CacheMode >= vfscommon.CacheModeFull || f.dedupeRead() {
 			fd, err = f.openRW(flags)
 		} else {
 			fd, err = f.openRead()
@@ -735,6 +990,12 @@ func (f *File) Open(flags int) (fd Handle, err error) {
 		// called without File.mu held
 		d.addObject(f)
 	}
+	if err == nil {
+		d.vfs.handles.add(fd, f, flags)
+		if read && !write {
+			d.vfs.prefetchOpened(d, f)
+		}
+	}
 	return fd, err
 }
 
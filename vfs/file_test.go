@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"runtime"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/operations"
@@ -164,6 +167,121 @@ func TestFileSetModTime(t *testing.T) {
 	}
 }
 
+func testFileSetMetadata(t *testing.T, cacheMode vfscommon.CacheMode, open bool, write bool) {
+	opt := vfscommon.DefaultOpt
+	opt.CacheMode = cacheMode
+	opt.WriteBack = writeBackDelay
+	opt.Metadata = true
+	opt.UID, opt.GID = 1234, 1234
+	r, vfs, cleanup := newTestVFSOpt(t, &opt)
+	defer cleanup()
+	if !r.Fremote.Features().WriteMetadata {
+		t.Skip("can't write metadata")
+	}
+	if runtime.GOOS != "linux" {
+		t.Skip("can't read back owner and permissions")
+	}
+	ctx := context.Background()
+	file1 := r.WriteObject(ctx, "dir/file1", "file1 contents", t1)
+	obj, err := r.Fremote.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+	if _, ok := obj.(fs.SetMetadataer); !ok {
+		t.Skip("can't set metadata")
+	}
+	node, err := vfs.Stat(file1.Path)
+	require.NoError(t, err)
+	file := node.(*File)
+
+	var fd Handle
+	if open {
+		if cacheMode != vfscommon.CacheModeOff {
+			fd, err = file.Open(os.O_WRONLY)
+		} else {
+			fd, err = file.Open(os.O_WRONLY | os.O_TRUNC)
+		}
+		require.NoError(t, err)
+		if write {
+			_, err = fd.WriteString("hello")
+			require.NoError(t, err)
+		}
+	}
+
+	uid, gid := os.Getuid(), os.Getgid()
+	require.NoError(t, file.Chmod(0640))
+	require.NoError(t, file.Chown(uid, gid))
+	require.NoError(t, file.SetAccessTime(t2))
+
+	// The new values are seen straight away
+	assert.Equal(t, os.FileMode(0640), file.Mode().Perm())
+	gotUID, gotGID := file.Owner()
+	assert.Equal(t, uint32(uid), gotUID)
+	assert.Equal(t, uint32(gid), gotGID)
+	assert.True(t, t2.Equal(file.AccessTime()))
+
+	if open {
+		require.NoError(t, fd.Close())
+		vfs.WaitForWriters(waitForWritersDelay)
+	}
+
+	// Check they were stored in the object
+	obj, err = r.Fremote.NewObject(ctx, file1.Path)
+	require.NoError(t, err)
+	metadata, err := fs.GetMetadata(ctx, obj)
+	require.NoError(t, err)
+	assert.Equal(t, "0100640", metadata["mode"])
+	assert.Equal(t, strconv.Itoa(uid), metadata["uid"])
+	assert.Equal(t, strconv.Itoa(gid), metadata["gid"])
+
+	// Check a new VFS reads them back
+	vfs2 := New(r.Fremote, &opt)
+	defer cleanupVFS(t, vfs2)
+	node, err = vfs2.Stat(file1.Path)
+	require.NoError(t, err)
+	file2 := node.(*File)
+	// The metadata is read in the background after the listing
+	assert.Eventually(t, func() bool {
+		return file2.Mode().Perm() == 0640
+	}, 10*time.Second, 10*time.Millisecond)
+	gotUID, gotGID = file2.Owner()
+	assert.Equal(t, uint32(uid), gotUID)
+	assert.Equal(t, uint32(gid), gotGID)
+	assert.True(t, t2.Equal(file2.AccessTime()))
+
+	vfs.Opt.ReadOnly = true
+	assert.Equal(t, EROFS, file.Chmod(0600))
+}
+
+// Test setting metadata with and without the cache and with and
+// without opening or writing to the file.
+func TestFileSetMetadata(t *testing.T) {
+	for _, cacheMode := range []vfscommon.CacheMode{vfscommon.CacheModeOff, vfscommon.CacheModeFull} {
+		for _, open := range []bool{false, true} {
+			for _, write := range []bool{false, true} {
+				if write && !open {
+					continue
+				}
+				t.Run(fmt.Sprintf("cache=%v,open=%v,write=%v", cacheMode, open, write), func(t *testing.T) {
+					testFileSetMetadata(t, cacheMode, open, write)
+				})
+			}
+		}
+	}
+}
+
+// Without --vfs-metadata Chmod and Chown are accepted but do nothing
+func TestFileSetMetadataOff(t *testing.T) {
+	_, vfs, file, _, cleanup := fileCreate(t, vfscommon.CacheModeOff)
+	defer cleanup()
+
+	require.NoError(t, file.Chmod(0600))
+	require.NoError(t, file.Chown(1, 2))
+	assert.Equal(t, vfs.Opt.FilePerms, file.Mode())
+	uid, gid := file.Owner()
+	assert.Equal(t, vfs.Opt.UID, uid)
+	assert.Equal(t, vfs.Opt.GID, gid)
+	assert.Equal(t, file.ModTime(), file.AccessTime())
+}
+
 func fileCheckContents(t *testing.T, file *File) {
 	fd, err := file.Open(os.O_RDONLY)
 	require.NoError(t, err)
@@ -401,6 +519,56 @@ func testFileRename(t *testing.T, mode vfscommon.CacheMode, inCache bool, forceC
 	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{newItem}, nil, fs.ModTimeNotSupported)
 }
 
+func TestFileOpenDedupeReads(t *testing.T) {
+	for _, dedupe := range []bool{false, true} {
+		t.Run(fmt.Sprintf("dedupe=%v", dedupe), func(t *testing.T) {
+			opt := vfscommon.DefaultOpt
+			opt.CacheMode = vfscommon.CacheModeWrites
+			opt.DedupeReads = dedupe
+			r, vfs, cleanup := newTestVFSOpt(t, &opt)
+			defer cleanup()
+
+			file1 := r.WriteObject(context.Background(), "file1", "file1 contents", t1)
+			r.CheckRemoteItems(t, file1)
+			node, err := vfs.Stat("file1")
+			require.NoError(t, err)
+			file := node.(*File)
+
+			// The first reader always reads directly
+			fd1, err := file.Open(os.O_RDONLY)
+			require.NoError(t, err)
+			_, ok := fd1.(*ReadFileHandle)
+			assert.True(t, ok)
+
+			// Further readers go through the cache if deduping
+			var fds []Handle
+			for i := 0; i < 2; i++ {
+				fd, err := file.Open(os.O_RDONLY)
+				require.NoError(t, err)
+				_, ok = fd.(*RWFileHandle)
+				assert.Equal(t, dedupe, ok)
+				contents, err := ioutil.ReadAll(fd)
+				require.NoError(t, err)
+				assert.Equal(t, "file1 contents", string(contents))
+				fds = append(fds, fd)
+			}
+			for _, fd := range fds {
+				require.NoError(t, fd.Close())
+			}
+			require.NoError(t, fd1.Close())
+			assert.Equal(t, int32(0), file.nreaders)
+
+			// With no other readers a reader reads directly
+			// unless the file is still in the cache
+			fd, err := file.Open(os.O_RDONLY)
+			require.NoError(t, err)
+			_, ok = fd.(*RWFileHandle)
+			assert.Equal(t, dedupe, ok)
+			require.NoError(t, fd.Close())
+		})
+	}
+}
+
 func TestFileRename(t *testing.T) {
 	for _, test := range []struct {
 		mode       vfscommon.CacheMode
@@ -0,0 +1,166 @@
+package vfs
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// transferrer is satisfied by file handles which count the bytes
+// read or written through them
+type transferrer interface {
+	bytesTransferred() int64
+}
+
+// openHandle is the bookkeeping for an open file handle
+type openHandle struct {
+	id     uint64
+	h      Handle
+	file   *File
+	flags  int
+	opened time.Time
+	pid    int // 0 if not known
+}
+
+// HandleInfo describes an open file handle
+type HandleInfo struct {
+	ID          uint64        `json:"id"`          // unique id of the handle - pass to vfs/closehandle
+	Path        string        `json:"path"`        // path of the file
+	Mode        string        `json:"mode"`        // open flags, e.g. "O_RDONLY"
+	Opened      time.Time     `json:"opened"`      // when the handle was opened
+	Age         time.Duration `json:"age"`         // how long the handle has been open
+	Transferred int64         `json:"transferred"` // bytes read or written through the handle
+	PID         int           `json:"pid"`         // process which opened the handle, 0 if not known
+}
+
+// handles keeps track of the open file handles of a VFS
+type handles struct {
+	mu     sync.Mutex
+	lastID uint64
+	open   map[Handle]*openHandle
+}
+
+// newHandles makes a new empty handles
+func newHandles() *handles {
+	return &handles{
+		open: make(map[Handle]*openHandle),
+	}
+}
+
+// add registers h as open on file
+func (hs *handles) add(h Handle, file *File, flags int) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	hs.lastID++
+	hs.open[h] = &openHandle{
+		id:     hs.lastID,
+		h:      h,
+		file:   file,
+		flags:  flags,
+		opened: time.Now(),
+	}
+}
+
+// remove deregisters h - it is OK to call this more than once
+func (hs *handles) remove(h Handle) {
+	hs.mu.Lock()
+	delete(hs.open, h)
+	hs.mu.Unlock()
+}
+
+// setPID records the process which opened h
+func (hs *handles) setPID(h Handle, pid int) {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	if oh, ok := hs.open[h]; ok {
+		oh.pid = pid
+	}
+}
+
+// find returns the handle with id or nil if not found
+func (hs *handles) find(id uint64) Handle {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+	for h, oh := range hs.open {
+		if oh.id == id {
+			return h
+		}
+	}
+	return nil
+}
+
+// list returns info on all the open handles sorted by id
+func (hs *handles) list() []HandleInfo {
+	hs.mu.Lock()
+	ohs := make([]openHandle, 0, len(hs.open))
+	for _, oh := range hs.open {
+		ohs = append(ohs, *oh)
+	}
+	hs.mu.Unlock()
+
+	// Read the details without hs.mu held. Don't call methods on
+	// the handles which take their locks as a stuck handle may be
+	// holding it.
+	now := time.Now()
+	infos := make([]HandleInfo, 0, len(ohs))
+	for _, oh := range ohs {
+		info := HandleInfo{
+			ID:     oh.id,
+			Path:   oh.file.Path(),
+			Mode:   decodeOpenFlags(oh.flags),
+			Opened: oh.opened,
+			Age:    now.Sub(oh.opened),
+			PID:    oh.pid,
+		}
+		if t, ok := oh.h.(transferrer); ok {
+			info.Transferred = t.bytesTransferred()
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+	return infos
+}
+
+// addTransferred adds n to the counter pointed to by p if n is positive
+func addTransferred(p *int64, n int) {
+	if n > 0 {
+		atomic.AddInt64(p, int64(n))
+	}
+}
+
+// Handles returns info about all the file handles open on the VFS
+func (vfs *VFS) Handles() []HandleInfo {
+	return vfs.handles.list()
+}
+
+// SetHandlePID records the process id which opened the handle h.
+//
+// This is for use by mount implementations which know which process
+// opened the file.
+func (vfs *VFS) SetHandlePID(h Handle, pid int) {
+	vfs.handles.setPID(h, pid)
+}
+
+// CloseHandle force closes the open file handle with the id
+// returned in HandleInfo.
+//
+// It returns ENOENT if the handle isn't found.
+func (vfs *VFS) CloseHandle(id uint64) error {
+	h := vfs.handles.find(id)
+	if h == nil {
+		return ENOENT
+	}
+	// Stop any bandwidth limit wait first as the handle holds its
+	// lock while waiting which Close needs
+	if wc, ok := h.(waitCanceller); ok {
+		wc.cancelWaits()
+	}
+	err := h.Close()
+	// make sure it is forgotten even if the close failed
+	vfs.handles.remove(h)
+	if err == ECLOSED {
+		err = nil
+	}
+	return err
+}
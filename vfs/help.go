@@ -105,6 +105,17 @@ seconds. If rclone is quit or dies with files that haven't been
 uploaded, these will be uploaded next time rclone is run with the same
 flags.
 
+If you mount a [compress](/compress/) remote to save bandwidth on a
+slow uplink you can choose the compression used for files written
+back from the cache with !--vfs-write-back-compress zstd!, trading CPU
+for bandwidth for this mount only. zstd is usually faster than gzip
+for a similar ratio, but reading from the middle of a zstd file means
+decompressing it from the start. This has no effect on other remotes
+as the VFS relies on the remote file matching the size and hash of
+the cached file.
+
+    --vfs-write-back-compress string     Compress files written back to a compress remote with this (zstd)
+
 If using !--vfs-cache-max-size! note that the cache may exceed this size
 for two reasons.  Firstly because it is only checked every
 !--vfs-cache-poll-interval!.  Secondly because open files cannot be
@@ -117,6 +128,11 @@ around this by giving each rclone its own cache hierarchy with
 !--cache-dir!. You don't need to worry about this if the remotes in
 use don't overlap.
 
+The cache can be checked for corrupted entries and files which don't
+belong to any item with !rclone vfs-cache check! when rclone isn't
+running, or with the !vfs/checkcache! remote control call when it is.
+Both remove the bad entries and report the space reclaimed.
+
 #### --vfs-cache-mode off
 
 In this mode (the default) the cache will read directly from the remote and write
@@ -184,6 +200,36 @@ FAT/exFAT do not. Rclone will perform very badly if the cache
 directory is on a filesystem which doesn't support sparse files and it
 will log an ERROR message if one is detected.
 
+#### Prefetching
+
+In !--vfs-cache-mode full! rclone can download files into the cache
+before they are opened if it can predict they will be. If
+!--vfs-prefetch! is set then once !--vfs-prefetch-after! files in a
+directory have been opened for reading one after the other in name
+order, the next !--vfs-prefetch! files in the directory are downloaded
+into the cache in the background. This suits workloads such as
+browsing photo galleries or playing media split into segments.
+
+    --vfs-prefetch int         Prefetch this many following files into the cache when files in a directory are read in sequence (0 is off)
+    --vfs-prefetch-after int   Number of files read in sequence before --vfs-prefetch starts (default 3)
+
+#### Concurrent readers
+
+In !--vfs-cache-mode full! all the readers of a file share the
+downloads into the cache so a file being read by several processes at
+once is only downloaded once.
+
+In the other cache modes each read only open of a file reads directly
+from the remote. If !--vfs-dedupe-reads! is set with
+!--vfs-cache-mode minimal! or !writes! then, when a file is opened for
+reading while it is already being read, the new reader is sent through
+the cache instead, as in !--vfs-cache-mode full!. Any further readers
+share the same download, reading from the partially downloaded cache
+file, so at most two streams are open on the remote however many
+processes are reading.
+
+    --vfs-dedupe-reads   Share one download through the cache between concurrent readers of a file
+
 #### Fingerprinting
 
 Various parts of the VFS use fingerprinting to see if a local file
@@ -272,6 +318,91 @@ modified files from the cache (the related global flag !--checkers! has no effec
 
     --transfers int  Number of file transfers to run in parallel (default 4)
 
+To stop one process streaming a large file from starving other users
+of a shared mount, the bandwidth of each open file can be limited
+with !--vfs-handle-bwlimit!. This limits the reads and writes made
+through each file handle separately, whether they come from the
+remote or from the VFS cache. It is off by default.
+
+    --vfs-handle-bwlimit SizeSuffix  Bandwidth limit for each open file in bytes/s, e.g. 4M (0 is off)
+
+To limit the bandwidth used by all the open files together, for
+example to stop one mount using all of a shared connection, use
+!--vfs-bwlimit!. This is applied as well as !--vfs-handle-bwlimit!
+and, unlike the global !--bwlimit!, only affects this VFS.
+
+    --vfs-bwlimit SizeSuffix  Bandwidth limit for all open files together in bytes/s, e.g. 10M (0 is off)
+
+Programs such as media scanners may open thousands of files at once
+and read a little of each, which can use up all the connections a
+remote allows. Use !--vfs-read-max-open! to limit the number of files
+which are open for reading directly from the remote. When the limit is
+exceeded the least recently used file which isn't being read from is
+closed on the remote. It is reopened at the same place when it is next
+read from, so this is invisible to the program reading it, apart from
+the time taken to reopen it. Files read through the VFS cache aren't
+affected.
+
+    --vfs-read-max-open int  Max number of files open for reading on the remote at once, idle ones are closed and reopened as needed (0 is unlimited)
+
+### VFS Slow Operations
+
+If !--vfs-trace-slow! is set then any VFS operation which takes longer
+than it is logged at NOTICE level, with a breakdown of how long was
+spent in calls to the backend, in the VFS cache and directory cache
+(including any downloads into the cache), waiting for
+!--vfs-handle-bwlimit!, waiting for locks held by other operations and
+elsewhere. The operations traced are stat, readdir, mkdir, rmdir,
+rename, open, read, write, close and remove.
+
+The most recent slow operations can also be read with the
+[vfs/slow](/rc/#vfs-slow) remote control command.
+
+    --vfs-trace-slow duration   Log VFS operations which take longer than this (0 is off)
+
+### VFS Trash
+
+Normally files deleted through the VFS are deleted from the remote.
+If !--vfs-trash! is set to a directory (relative to the root of the
+VFS) then deleted files are moved into it instead, as
+!<trash>/<time of deletion>/<path of file>!. Files deleted from
+inside the trash directory are deleted as normal.
+
+If !--vfs-trash-max-age! is set then files are removed from the trash
+permanently once they have been there that long. The trash may also be
+emptied with the [vfs/emptytrash](/rc/#vfs-emptytrash) remote control
+command.
+
+If the remote has a trash of its own which deleted files go into then
+that is used instead and the directory isn't. This is the case for
+Box, Google Drive (unless !use_trash! is off), Jottacloud, Mega,
+OneDrive, SugarSync and Yandex Disk (unless !hard_delete! is set), and
+for remotes like crypt which wrap them. The remote's trash can be emptied with !rclone cleanup! where supported,
+and !--vfs-trash-max-age! and !vfs/emptytrash! don't apply to it.
+
+Otherwise files are moved into the trash with server-side moves. On
+remotes which can't move files on the server each deletion copies the
+file into the trash then deletes it, which is slow for big files, so
+a notice is logged when the VFS starts.
+
+    --vfs-trash string             Move deleted files into this directory on the remote instead of deleting them
+    --vfs-trash-max-age duration   Remove files from the --vfs-trash after this long (0 is keep forever)
+
+### VFS Snapshots
+
+Backup tools reading a large tree through the VFS may complain that
+files changed as they were read. The
+[vfs/snapshot](/rc/#vfs-snapshot) remote control command freezes the
+directory cache into a read only snapshot which appears as
+!.snapshot/<name>! in the root of the VFS while the live view carries
+on as normal. The !.snapshot! directory isn't shown in listings of the
+root so it won't be walked by accident.
+
+The listings, sizes and modification times in a snapshot never
+change, but file data is still read from the remote, so reading a file
+which has been changed since the snapshot will fail or give the new
+data.
+
 ### VFS Case Sensitivity
 
 Linux file systems are case-sensitive: two files can differ only
@@ -319,4 +450,45 @@ and compute the total used space itself.
 _WARNING._ Contrary to !rclone size!, this flag ignores filters so that the
 result is accurate. However, this is very inefficient and may cost lots of API
 calls resulting in extra charges. Use it as a last resort and only with caching.
+
+### VFS Quota
+
+Use !--vfs-quota! to limit the total size of the files in the VFS,
+for example !--vfs-quota 10G!. Writes which would take the total over
+the limit fail with "no space left on device", and !df! on the
+filesystem shows the quota as its size.
+
+The space used is measured by scanning the remote like
+!--vfs-used-is-size!, at most once every !--dir-cache-time!. In
+between, rclone adds on the size of data written and assumes files
+being written through the VFS cache which haven't been uploaded yet
+take up extra space, so the limit may be enforced a little early but
+shouldn't be overshot by writes through this VFS.
+
+    --vfs-quota SizeSuffix  Max total size of the files in the VFS, writes which would exceed it fail (default off)
+
+### VFS Metadata
+
+Normally all files show the permissions and owner set with
+!--file-perms!, !--uid! and !--gid!, and changes made with !chmod!
+and !chown! are accepted but not stored.
+
+If !--vfs-metadata! is set and the remote can write metadata (see the
+[metadata](/docs/#metadata) docs) then the permissions, owner and
+access time of a file set through the VFS are stored in the metadata
+of the object as !mode!, !uid!, !gid! and !atime!. They are read back
+from the metadata, so they are kept when the remote is mounted again
+and files copied with !--metadata! from a remote which stores them
+show their original permissions and owner. Files without them use the
+values from the flags as before.
+
+The metadata is read in the background each time a directory is
+listed, which may need an extra transaction for each file in it, so
+until it has been read files show the values from the flags. Setting
+it on remotes like S3 copies the object to itself, so this is off by
+default. Directories have no
+metadata so they always use !--dir-perms!, !--uid! and !--gid!.
+Modification times are stored as normal whether or not this is set.
+
+    --vfs-metadata   Store the permissions, owners and access times of files in their metadata on the remote
 `, "!", "`")
@@ -0,0 +1,175 @@
+package vfs
+
+import (
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// maxPrefetchDirs is the number of directories the sequential
+// prefetcher remembers
+const maxPrefetchDirs = 100
+
+// Prefetcher decides which files to prefetch into the VFS cache
+//
+// Opened is called each time a file is opened for reading with the
+// directory it is in, its name and the sorted names of the files in
+// the directory. It returns the names of any files to prefetch.
+//
+// It may be called concurrently.
+type Prefetcher interface {
+	Opened(dir string, leaf string, names []string) (prefetch []string)
+}
+
+// sequentialPrefetcher prefetches the files following the one
+// opened once enough files in a directory have been opened in name
+// order.
+type sequentialPrefetcher struct {
+	mu    sync.Mutex
+	after int                 // number of files read in order before prefetching
+	n     int                 // number of files to prefetch
+	dirs  map[string]seqState // state of each directory
+}
+
+// seqState is the state of a directory for sequentialPrefetcher
+type seqState struct {
+	last int // index of the last file opened
+	run  int // number of files opened in order
+}
+
+// newSequentialPrefetcher makes the default Prefetcher which
+// prefetches n files after after files have been read in sequence.
+func newSequentialPrefetcher(after, n int) *sequentialPrefetcher {
+	return &sequentialPrefetcher{
+		after: after,
+		n:     n,
+		dirs:  make(map[string]seqState),
+	}
+}
+
+// Opened satisfies the Prefetcher interface
+func (p *sequentialPrefetcher) Opened(dir string, leaf string, names []string) (prefetch []string) {
+	i := -1
+	for j, name := range names {
+		if name == leaf {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	state, found := p.dirs[dir]
+	switch {
+	case found && i == state.last:
+		// Opening the same file again doesn't change anything
+		return nil
+	case found && i == state.last+1:
+		state.run++
+	default:
+		state.run = 1
+	}
+	state.last = i
+	if !found && len(p.dirs) >= maxPrefetchDirs {
+		p.dirs = make(map[string]seqState)
+	}
+	p.dirs[dir] = state
+	if state.run < p.after {
+		return nil
+	}
+	end := i + 1 + p.n
+	if end > len(names) {
+		end = len(names)
+	}
+	return names[i+1 : end]
+}
+
+// SetPrefetcher sets the Prefetcher used to decide which files to
+// prefetch into the cache, replacing the one set by --vfs-prefetch.
+// Passing nil turns prefetching off.
+//
+// Prefetching only happens with --vfs-cache-mode full.
+func (vfs *VFS) SetPrefetcher(p Prefetcher) {
+	vfs.prefetchMu.Lock()
+	vfs.prefetcher = p
+	vfs.prefetchMu.Unlock()
+}
+
+// prefetchOpened is called when f in d has been opened for reading
+// and starts prefetching any files the Prefetcher wants.
+//
+// Call with no locks held.
+func (vfs *VFS) prefetchOpened(d *Dir, f *File) {
+	vfs.prefetchMu.Lock()
+	p := vfs.prefetcher
+	vfs.prefetchMu.Unlock()
+	if p == nil || vfs.Opt.CacheMode < vfscommon.CacheModeFull {
+		return
+	}
+	go func() {
+		nodes, err := d.ReadDirAll()
+		if err != nil {
+			return
+		}
+		var names []string
+		files := make(map[string]*File, len(nodes))
+		for _, node := range nodes {
+			if file, ok := node.(*File); ok {
+				names = append(names, file.Name())
+				files[file.Name()] = file
+			}
+		}
+		for _, name := range p.Opened(d.Path(), f.Name(), names) {
+			if file := files[name]; file != nil {
+				vfs.prefetch(file)
+			}
+		}
+	}()
+}
+
+// prefetch downloads the whole of f into the cache if it isn't
+// already there or being prefetched.
+func (vfs *VFS) prefetch(f *File) {
+	cache := vfs.cache
+	o := f.getObject()
+	if cache == nil || o == nil || o.Size() < 0 {
+		return
+	}
+	name := f.Path()
+	vfs.prefetchMu.Lock()
+	if vfs.prefetching[name] {
+		vfs.prefetchMu.Unlock()
+		return
+	}
+	vfs.prefetching[name] = true
+	vfs.prefetchMu.Unlock()
+	defer func() {
+		vfs.prefetchMu.Lock()
+		delete(vfs.prefetching, name)
+		vfs.prefetchMu.Unlock()
+	}()
+
+	// Open and close the item under muRW like RWFileHandle does
+	item := cache.Item(name)
+	f.muRW.Lock()
+	err := item.Open(o)
+	f.muRW.Unlock()
+	if err != nil {
+		fs.Debugf(name, "vfs prefetch: failed to open cache item: %v", err)
+		return
+	}
+	fs.Debugf(name, "vfs prefetch: fetching %d bytes", o.Size())
+	err = item.Fetch(0, o.Size())
+	if err != nil {
+		fs.Debugf(name, "vfs prefetch: failed: %v", err)
+	}
+	f.muRW.Lock()
+	err = item.Close(nil)
+	f.muRW.Unlock()
+	if err != nil {
+		fs.Debugf(name, "vfs prefetch: failed to close cache item: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package vfs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/lib/ranges"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequentialPrefetcher(t *testing.T) {
+	p := newSequentialPrefetcher(2, 2)
+	names := []string{"a", "b", "c", "d", "e"}
+	assert.Nil(t, p.Opened("dir", "a", names))
+	assert.Nil(t, p.Opened("dir", "a", names))
+	assert.Equal(t, []string{"c", "d"}, p.Opened("dir", "b", names))
+	assert.Equal(t, []string{"d", "e"}, p.Opened("dir", "c", names))
+	assert.Equal(t, []string{"e"}, p.Opened("dir", "d", names))
+	assert.Equal(t, []string{}, p.Opened("dir", "e", names))
+
+	// Out of order resets the sequence
+	assert.Nil(t, p.Opened("dir", "b", names))
+	assert.Equal(t, []string{"d", "e"}, p.Opened("dir", "c", names))
+
+	// Directories are independent
+	assert.Nil(t, p.Opened("other", "d", names))
+
+	// Unknown names are ignored
+	assert.Nil(t, p.Opened("dir", "potato", names))
+}
+
+// testPrefetcher prefetches a fixed file
+type testPrefetcher struct {
+	name string
+}
+
+func (p testPrefetcher) Opened(dir string, leaf string, names []string) (prefetch []string) {
+	return []string{p.name}
+}
+
+func TestVFSPrefetch(t *testing.T) {
+	opt := vfscommon.DefaultOpt
+	opt.CacheMode = vfscommon.CacheModeFull
+	opt.Prefetch = 2
+	opt.PrefetchAfter = 2
+	r, vfs, cleanup := newTestVFSOpt(t, &opt)
+	defer cleanup()
+	ctx := context.Background()
+
+	const n = 6
+	for i := 0; i < n; i++ {
+		r.WriteObject(ctx, fmt.Sprintf("dir/file%d", i), fmt.Sprintf("file%d contents", i), t1)
+	}
+	inCache := func(i int) bool {
+		item := vfs.cache.Item(fmt.Sprintf("dir/file%d", i))
+		return item.HasRange(ranges.Range{Pos: 0, Size: 14})
+	}
+	read := func(i int) {
+		contents, err := vfs.ReadFile(fmt.Sprintf("dir/file%d", i))
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("file%d contents", i), string(contents))
+	}
+
+	read(0)
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, inCache(1))
+
+	read(1)
+	assert.Eventually(t, func() bool { return inCache(2) && inCache(3) }, 10*time.Second, 10*time.Millisecond)
+	assert.False(t, inCache(4))
+	read(2)
+	read(3)
+	assert.Eventually(t, func() bool { return inCache(4) && inCache(5) }, 10*time.Second, 10*time.Millisecond)
+
+	// A custom Prefetcher replaces the default one
+	vfs.SetPrefetcher(testPrefetcher{name: "extra"})
+	r.WriteObject(ctx, "dir/extra", "extra contents", t1)
+	vfs.FlushDirCache()
+	read(0)
+	assert.Eventually(t, func() bool {
+		return vfs.cache.Item("dir/extra").HasRange(ranges.Range{Pos: 0, Size: 14})
+	}, 10*time.Second, 10*time.Millisecond)
+}
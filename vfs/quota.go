@@ -0,0 +1,74 @@
+package vfs
+
+import (
+	"context"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/walk"
+)
+
+// quotaMeasure updates the space used for --vfs-quota if it is
+// older than --dir-cache-time.
+//
+// Files which are dirty in the cache are counted in full as well as
+// whatever is on the remote, so we err on the side of being full.
+//
+// Call with quotaMu held
+func (vfs *VFS) quotaMeasure() {
+	if !vfs.quotaTime.IsZero() && time.Since(vfs.quotaTime) < vfs.Opt.DirCacheTime {
+		return
+	}
+	var used int64
+	err := walk.ListR(context.TODO(), vfs.f, "", true, -1, walk.ListObjects, func(entries fs.DirEntries) error {
+		entries.ForObject(func(o fs.Object) {
+			if size := o.Size(); size > 0 {
+				used += size
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		// carry on with the old figure
+		fs.Errorf(vfs.f, "Failed to measure size for --vfs-quota: %v", err)
+		return
+	}
+	if vfs.cache != nil {
+		_, dirty := vfs.cache.Dirty()
+		used += dirty
+	}
+	vfs.quotaUsed = used
+	vfs.quotaTime = time.Now()
+}
+
+// quotaGrow should be called before writing to a file which will
+// grow by n bytes. It returns ENOSPC if that would take the total
+// size of the files over --vfs-quota, otherwise it adds n to the
+// space used.
+func (vfs *VFS) quotaGrow(n int64) error {
+	if vfs.Opt.Quota < 0 || n <= 0 {
+		return nil
+	}
+	vfs.quotaMu.Lock()
+	defer vfs.quotaMu.Unlock()
+	vfs.quotaMeasure()
+	if vfs.quotaUsed+n > int64(vfs.Opt.Quota) {
+		return ENOSPC
+	}
+	vfs.quotaUsed += n
+	return nil
+}
+
+// quotaStatfs returns the total, used and free space as limited by
+// --vfs-quota
+func (vfs *VFS) quotaStatfs() (total, used, free int64) {
+	vfs.quotaMu.Lock()
+	defer vfs.quotaMu.Unlock()
+	vfs.quotaMeasure()
+	total, used = int64(vfs.Opt.Quota), vfs.quotaUsed
+	free = total - used
+	if free < 0 {
+		free = 0
+	}
+	return total, used, free
+}
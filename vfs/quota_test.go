@@ -0,0 +1,59 @@
+package vfs
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVFSQuota(t *testing.T) {
+	opt := vfscommon.DefaultOpt
+	opt.Quota = 100
+	opt.DirCacheTime = time.Hour
+	r, vfs, cleanup := newTestVFSOpt(t, &opt)
+	defer cleanup()
+
+	file1 := r.WriteObject(context.Background(), "file1", "0123456789", t1)
+	r.CheckRemoteItems(t, file1)
+
+	// the quota is the size of the filesystem
+	total, used, free := vfs.Statfs()
+	assert.Equal(t, int64(100), total)
+	assert.Equal(t, int64(10), used)
+	assert.Equal(t, int64(90), free)
+
+	// writes are allowed up to the quota
+	fh, err := vfs.OpenFile("file2", os.O_WRONLY|os.O_CREATE, 0777)
+	require.NoError(t, err)
+	n, err := fh.Write(make([]byte, 80))
+	require.NoError(t, err)
+	assert.Equal(t, 80, n)
+
+	// but not past it
+	_, err = fh.Write(make([]byte, 20))
+	assert.Equal(t, ENOSPC, err)
+	require.NoError(t, fh.Close())
+
+	_, used, free = vfs.Statfs()
+	assert.Equal(t, int64(90), used)
+	assert.Equal(t, int64(10), free)
+
+	// overwriting existing data doesn't use more space
+	assert.NoError(t, vfs.quotaGrow(0))
+	assert.NoError(t, vfs.quotaGrow(-10))
+	assert.Equal(t, ENOSPC, vfs.quotaGrow(11))
+}
+
+func TestVFSQuotaOff(t *testing.T) {
+	_, vfs, cleanup := newTestVFS(t)
+	defer cleanup()
+
+	assert.Equal(t, int64(-1), int64(vfs.Opt.Quota))
+	assert.NoError(t, vfs.quotaGrow(1<<50))
+	assert.True(t, vfs.quotaTime.IsZero())
+}
@@ -92,7 +92,7 @@ func rcRefresh(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 		var node Node = root
 		for _, s := range segments {
 			if dir, ok := node.(*Dir); ok {
-				node, err = dir.stat(s)
+				node, err = dir.stat(s, nil)
 				if err != nil {
 					return nil, err
 				}
@@ -400,7 +400,9 @@ This returns stats for the selected VFS.
     {
         // Status of the disk cache - only present if --vfs-cache-mode > off
         "diskCache": {
+            "bytesDirty": 0,
             "bytesUsed": 0,
+            "dirtyFiles": 0,
             "erroredFiles": 0,
             "files": 0,
             "hashType": 1,
@@ -437,3 +439,468 @@ func rcStats(ctx context.Context, in rc.Params) (out rc.Params, err error) {
 	}
 	return vfs.Stats(), nil
 }
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "vfs/changes",
+		Title: "Wait for and return changes seen by the VFS.",
+		Help: `
+This returns the file change events observed by the VFS, both changes
+made through the VFS (e.g. via a mount) and changes reported by the
+remote if it supports polling for changes (see --poll-interval).
+
+It can be used as a long poll by applications which want to keep an
+index up to date without rescanning the whole mount.
+
+Parameters:
+
+- since - sequence number of the last event seen (default 0)
+- timeout - time to wait for new events if there are none, e.g. 30s (default 0 - don't wait, max 5m)
+
+    rclone rc vfs/changes since=42 timeout=1m
+
+This returns
+
+- events - list of events with seq > since
+- last - sequence number of the latest event - pass this as since in the next call
+- lost - true if some events after since were discarded before being read
+
+Each event looks like this
+
+    {
+        "seq": 43,
+        "time": "2022-04-12T10:23:45.123456789+01:00",
+        "op": "rename",
+        "source": "local",
+        "path": "dir/new.txt",
+        "oldPath": "dir/old.txt",
+        "isDir": false
+    }
+
+The "op" is one of "write", "remove", "rename", "mkdir" for changes
+made through the VFS or "change" for changes reported by the remote.
+
+Only the most recent events are kept, so if "lost" is set the
+application should rescan.
+
+A timeout longer than 5m is reduced to 5m, so clients wanting to wait
+longer should call again.
+` + getVFSHelp,
+		Fn: rcChanges,
+	})
+}
+
+func rcChanges(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	vfs, err := getVFS(in)
+	if err != nil {
+		return nil, err
+	}
+	since, err := in.GetInt64("since")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if since < 0 {
+		return nil, errors.New("since must be >= 0")
+	}
+	timeout, err := in.GetDuration("timeout")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	events, last, lost := vfs.Changes(ctx, uint64(since), timeout)
+	return rc.Params{
+		"events": events,
+		"last":   last,
+		"lost":   lost,
+	}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "vfs/handles",
+		Title: "List the open file handles.",
+		Help: `
+This lists the file handles currently open on the VFS, which can be
+useful to find out what is stopping an unmount.
+
+It returns a list under the key "handles" of
+
+    {
+        "id": 3,
+        "path": "dir/file.txt",
+        "mode": "O_RDONLY",
+        "opened": "2022-04-12T10:23:45.123456789+01:00",
+        "age": 12500000000,
+        "transferred": 1048576,
+        "pid": 1234
+    }
+
+where "age" is the time in nanoseconds since the handle was opened,
+"transferred" is the number of bytes read or written through the
+handle and "pid" is the id of the process which opened the file, or 0
+if the mount can't tell.
+
+Pass the "id" to vfs/closehandle to force close a handle.
+` + getVFSHelp,
+		Fn: rcHandles,
+	})
+}
+
+func rcHandles(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	vfs, err := getVFS(in)
+	if err != nil {
+		return nil, err
+	}
+	return rc.Params{
+		"handles": vfs.Handles(),
+	}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "vfs/closehandle",
+		Title: "Force close an open file handle.",
+		Help: `
+This closes the file handle with the "id" returned from vfs/handles.
+
+    rclone rc vfs/closehandle id=3
+
+Any further use of the handle by the process which opened it will
+return an error. If the handle was open for writing then the data
+written so far will be uploaded as if the file had been closed.
+` + getVFSHelp,
+		Fn: rcCloseHandle,
+	})
+}
+
+func rcCloseHandle(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	vfs, err := getVFS(in)
+	if err != nil {
+		return nil, err
+	}
+	id, err := in.GetInt64("id")
+	if err != nil {
+		return nil, err
+	}
+	err = vfs.CloseHandle(uint64(id))
+	if err == ENOENT {
+		return nil, fmt.Errorf("no open handle with id %d", id)
+	}
+	return nil, err
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "vfs/emptytrash",
+		Title: "Remove files from the --vfs-trash.",
+		Help: `
+This permanently removes files which were moved into the trash
+directory by --vfs-trash.
+
+    rclone rc vfs/emptytrash
+    rclone rc vfs/emptytrash maxAge=24h
+
+If "maxAge" is passed in then only files deleted longer ago than that
+are removed, otherwise the trash is emptied completely.
+
+It returns a list of the trash directories removed in "removed".
+` + getVFSHelp,
+		Fn: rcEmptyTrash,
+	})
+}
+
+func rcEmptyTrash(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	vfs, err := getVFS(in)
+	if err != nil {
+		return nil, err
+	}
+	maxAge, err := in.GetDuration("maxAge")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	removed, err := vfs.EmptyTrash(ctx, maxAge)
+	if err != nil {
+		return nil, err
+	}
+	return rc.Params{
+		"removed": removed,
+	}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "vfs/slow",
+		Title: "List recent slow VFS operations.",
+		Help: `
+This lists the most recent operations which took longer than
+--vfs-trace-slow, oldest first. It returns an empty list if
+--vfs-trace-slow isn't set.
+
+    rclone rc vfs/slow
+
+Each operation has the "op" and "path" it was on, the "time" it
+started and the "total" time it took in nanoseconds, broken down into
+the time spent in the "backend", the VFS "cache" and directory cache,
+waiting for the "limit" set by --vfs-handle-bwlimit, waiting for a
+"lock" held by another operation and "other".
+` + getVFSHelp,
+		Fn: rcSlow,
+	})
+}
+
+func rcSlow(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	vfs, err := getVFS(in)
+	if err != nil {
+		return nil, err
+	}
+	return rc.Params{
+		"slow": vfs.SlowOps(),
+	}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "vfs/setopts",
+		Title: "Change options of a running VFS.",
+		Help: `
+This changes some of the options of a running VFS without remounting
+it and returns their new values. Use vfs/list to find the names of the
+active VFSes. With no options it just returns the current values.
+
+    rclone rc vfs/setopts fs=remote: readAhead=256M cacheMaxAge=24h
+
+The options which can be changed are
+
+- readAhead - as set by --vfs-read-ahead, e.g. "128M"
+- cacheMaxAge - as set by --vfs-cache-max-age, e.g. "1h"
+- cacheMaxSize - as set by --vfs-cache-max-size, e.g. "10G" or "off"
+- pollInterval - as set by --poll-interval, e.g. "1m", see vfs/poll-interval
+
+The new cache limits are applied the next time the cache is cleaned,
+which happens every --vfs-cache-poll-interval. The new read ahead
+applies to new reads.
+
+Note that the VFS will no longer be shared with new users asking for
+it with its original options.
+` + getVFSHelp,
+		Fn: rcSetOpts,
+	})
+}
+
+// getSizeSuffix reads the SizeSuffix k from in, deleting it if found
+func getSizeSuffix(in rc.Params, k string) (size fs.SizeSuffix, found bool, err error) {
+	v, ok := in[k]
+	if !ok {
+		return 0, false, nil
+	}
+	if s, ok := v.(string); ok {
+		err = size.Set(s)
+	} else {
+		var i int64
+		i, err = in.GetInt64(k)
+		size = fs.SizeSuffix(i)
+	}
+	if err != nil {
+		return 0, true, fmt.Errorf("parse size %q=%v: %w", k, v, err)
+	}
+	delete(in, k)
+	return size, true, nil
+}
+
+// getOptDuration reads the duration k from in, deleting it if found
+func getOptDuration(in rc.Params, k string) (duration time.Duration, found bool, err error) {
+	v, ok := in[k]
+	if !ok {
+		return 0, false, nil
+	}
+	duration, err = getDuration(k, v)
+	if err != nil {
+		return 0, true, err
+	}
+	if duration < 0 {
+		return 0, true, fmt.Errorf("%s must be >= 0", k)
+	}
+	delete(in, k)
+	return duration, true, nil
+}
+
+func rcSetOpts(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	vfs, err := getVFS(in)
+	if err != nil {
+		return nil, err
+	}
+	readAhead, setReadAhead, err := getSizeSuffix(in, "readAhead")
+	if err != nil {
+		return nil, err
+	}
+	cacheMaxSize, setCacheMaxSize, err := getSizeSuffix(in, "cacheMaxSize")
+	if err != nil {
+		return nil, err
+	}
+	cacheMaxAge, setCacheMaxAge, err := getOptDuration(in, "cacheMaxAge")
+	if err != nil {
+		return nil, err
+	}
+	pollInterval, setPollInterval, err := getOptDuration(in, "pollInterval")
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range in {
+		return nil, fmt.Errorf("invalid parameter: %s=%v", k, v)
+	}
+	if setPollInterval && vfs.pollChan == nil {
+		return nil, errors.New("poll-interval is not supported by this remote")
+	}
+
+	// Take activeMu as New compares the options of active VFSes
+	activeMu.Lock()
+	if setReadAhead {
+		vfs.Opt.SetReadAhead(readAhead)
+	}
+	if setCacheMaxSize {
+		vfs.Opt.SetCacheMaxSize(cacheMaxSize)
+	}
+	if setCacheMaxAge {
+		vfs.Opt.SetCacheMaxAge(cacheMaxAge)
+	}
+	activeMu.Unlock()
+
+	if setPollInterval {
+		timer := time.NewTimer(10 * time.Second)
+		defer timer.Stop()
+		select {
+		case vfs.pollChan <- pollInterval:
+			activeMu.Lock()
+			vfs.Opt.PollInterval = pollInterval
+			activeMu.Unlock()
+		case <-timer.C:
+			return nil, errors.New("timed out waiting for the poll function to accept the new poll-interval")
+		}
+	}
+
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	return rc.Params{
+		"readAhead":    vfs.Opt.GetReadAhead(),
+		"cacheMaxAge":  vfs.Opt.GetCacheMaxAge(),
+		"cacheMaxSize": vfs.Opt.GetCacheMaxSize(),
+		"pollInterval": vfs.Opt.PollInterval,
+	}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "vfs/checkcache",
+		Title: "Check the VFS cache for corrupted and orphaned entries.",
+		Help: `
+This scans the on disk VFS cache checking the metadata of each item
+against the cached data and, for completely downloaded files, the
+hash of the cached data against the remote where they share a hash.
+
+Corrupted items and files in the cache which don't belong to any item
+are removed, unless the --dry-run flag is set, e.g. with
+_config={"DryRun":true}. Items which are open or waiting to be
+uploaded are not checked.
+
+    rclone rc vfs/checkcache
+
+It returns the number of items "checked", a list of the "problems"
+found, the number of entries "removed" and the number of bytes
+"reclaimable" by removing the bad entries.
+` + getVFSHelp,
+		Fn: rcCheckCache,
+	})
+}
+
+func rcCheckCache(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	vfs, err := getVFS(in)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range in {
+		return nil, fmt.Errorf("invalid parameter: %s=%v", k, v)
+	}
+	res, err := vfs.CheckCache(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return rc.Params{
+		"checked":     res.Checked,
+		"problems":    res.Problems,
+		"removed":     res.Removed,
+		"reclaimable": res.Reclaimable,
+	}, nil
+}
+
+func init() {
+	rc.Add(rc.Call{
+		Path:  "vfs/snapshot",
+		Title: "Make or remove a read only snapshot of the VFS.",
+		Help: `
+This freezes the current directory cache into a read only snapshot
+which appears in the hidden directory ".snapshot" in the root of the
+VFS. Backup tools can walk the snapshot while the live view carries
+on changing, so they won't see files change as they read them.
+
+    rclone rc vfs/snapshot
+    rclone rc vfs/snapshot name=daily refresh=true
+    rclone rc vfs/snapshot name=daily remove=true
+
+If "name" isn't passed in then the snapshot is named after the current
+time. An existing snapshot with the same name is replaced. The
+snapshot appears as ".snapshot/name" - note that ".snapshot" isn't
+shown in directory listings of the root.
+
+Only directories in the directory cache are included in the snapshot
+so pass "refresh=true" to read the whole directory tree first, as
+with vfs/refresh recursive=true.
+
+The listings, sizes and modification times in the snapshot don't
+change, but reading a file reads the data from the remote, so it
+will fail or return the new data if the file has been changed since
+the snapshot was taken.
+
+Pass "remove=true" to remove the snapshot "name".
+
+It returns the "name" of the snapshot made or removed and the names
+of all the "snapshots".
+` + getVFSHelp,
+		Fn: rcSnapshot,
+	})
+}
+
+func rcSnapshot(ctx context.Context, in rc.Params) (out rc.Params, err error) {
+	vfs, err := getVFS(in)
+	if err != nil {
+		return nil, err
+	}
+	name, err := in.GetString("name")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	remove, err := in.GetBool("remove")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	refresh, err := in.GetBool("refresh")
+	if rc.NotErrParamNotFound(err) {
+		return nil, err
+	}
+	if remove {
+		err = vfs.RemoveSnapshot(name)
+	} else {
+		if refresh {
+			err = vfs.root.readDirTree()
+			if err != nil {
+				return nil, fmt.Errorf("failed to refresh directory cache: %w", err)
+			}
+		}
+		name, err = vfs.Snapshot(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rc.Params{
+		"name":      name,
+		"snapshots": vfs.Snapshots(),
+	}, nil
+}
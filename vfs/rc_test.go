@@ -2,7 +2,9 @@ package vfs
 
 import (
 	"context"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/rc"
@@ -131,3 +133,147 @@ func TestRcStats(t *testing.T) {
 	assert.Equal(t, 1, out["metadataCache"].(rc.Params)["dirs"])
 	assert.Equal(t, vfs.Opt, out["opt"].(vfscommon.Options))
 }
+
+func TestRcChanges(t *testing.T) {
+	_, vfs, cleanup, call := rcNewRun(t, "vfs/changes")
+	defer cleanup()
+	require.NoError(t, vfs.Mkdir("dir", 0777))
+
+	out, err := call.Fn(context.Background(), rc.Params{"since": int64(0)})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), out["last"])
+	assert.Equal(t, false, out["lost"])
+	events := out["events"].([]ChangeEvent)
+	require.Len(t, events, 1)
+	assert.Equal(t, "dir", events[0].Path)
+
+	out, err = call.Fn(context.Background(), rc.Params{"since": int64(1), "timeout": "1ms"})
+	require.NoError(t, err)
+	assert.Len(t, out["events"], 0)
+
+	_, err = call.Fn(context.Background(), rc.Params{"since": int64(-1)})
+	assert.Error(t, err)
+}
+
+func TestRcHandles(t *testing.T) {
+	r, vfs, cleanup, call := rcNewRun(t, "vfs/handles")
+	defer cleanup()
+	closeCall := rc.Calls.Get("vfs/closehandle")
+	require.NotNil(t, closeCall)
+
+	file1 := r.WriteObject(context.Background(), "file1", "file1 contents", t1)
+	r.CheckRemoteItems(t, file1)
+
+	out, err := call.Fn(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []HandleInfo{}, out["handles"])
+
+	fd, err := vfs.OpenFile("file1", os.O_RDONLY, 0)
+	require.NoError(t, err)
+	buf := make([]byte, 5)
+	_, err = fd.Read(buf)
+	require.NoError(t, err)
+
+	out, err = call.Fn(context.Background(), nil)
+	require.NoError(t, err)
+	handles := out["handles"].([]HandleInfo)
+	require.Len(t, handles, 1)
+	assert.Equal(t, "file1", handles[0].Path)
+	assert.Equal(t, "O_RDONLY", handles[0].Mode)
+	assert.Equal(t, int64(5), handles[0].Transferred)
+
+	_, err = closeCall.Fn(context.Background(), rc.Params{"id": int64(handles[0].ID)})
+	require.NoError(t, err)
+	assert.Equal(t, ECLOSED, fd.Close())
+
+	out, err = call.Fn(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Len(t, out["handles"], 0)
+
+	_, err = closeCall.Fn(context.Background(), rc.Params{"id": int64(handles[0].ID)})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no open handle")
+}
+
+func TestRcEmptyTrash(t *testing.T) {
+	_, _, cleanup, call := rcNewRun(t, "vfs/emptytrash")
+	defer cleanup()
+
+	// --vfs-trash isn't set on the test VFS
+	_, err := call.Fn(context.Background(), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--vfs-trash")
+
+	_, err = call.Fn(context.Background(), rc.Params{"maxAge": "potato"})
+	assert.Error(t, err)
+}
+
+func TestRcSlow(t *testing.T) {
+	_, _, cleanup, call := rcNewRun(t, "vfs/slow")
+	defer cleanup()
+
+	out, err := call.Fn(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, []SlowOp{}, out["slow"])
+}
+
+func TestRcSetOpts(t *testing.T) {
+	_, vfs, cleanup, call := rcNewRun(t, "vfs/setopts")
+	defer cleanup()
+
+	out, err := call.Fn(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, vfscommon.DefaultOpt.ReadAhead, out["readAhead"])
+	assert.Equal(t, vfscommon.DefaultOpt.CacheMaxAge, out["cacheMaxAge"])
+
+	out, err = call.Fn(context.Background(), rc.Params{
+		"readAhead":    "16M",
+		"cacheMaxAge":  "2h",
+		"cacheMaxSize": int64(1024),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 16*fs.Mebi, out["readAhead"])
+	assert.Equal(t, 2*time.Hour, out["cacheMaxAge"])
+	assert.Equal(t, fs.SizeSuffix(1024), out["cacheMaxSize"])
+	assert.Equal(t, 16*fs.Mebi, vfs.Opt.GetReadAhead())
+
+	_, err = call.Fn(context.Background(), rc.Params{"readAhead": "potato"})
+	assert.Error(t, err)
+	_, err = call.Fn(context.Background(), rc.Params{"cacheMaxAge": "-1h"})
+	assert.Error(t, err)
+	_, err = call.Fn(context.Background(), rc.Params{"potato": "1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid parameter")
+}
+
+func TestRcCheckCache(t *testing.T) {
+	_, _, cleanup, call := rcNewRun(t, "vfs/checkcache")
+	defer cleanup()
+
+	// The test VFS has no cache
+	_, err := call.Fn(context.Background(), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "--vfs-cache-mode")
+
+	_, err = call.Fn(context.Background(), rc.Params{"potato": "sausage"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid parameter")
+}
+
+func TestRcSnapshot(t *testing.T) {
+	r, vfs, cleanup, call := rcNewRun(t, "vfs/snapshot")
+	defer cleanup()
+	r.WriteObject(context.Background(), "dir/file1", "file1 contents", t1)
+
+	out, err := call.Fn(context.Background(), rc.Params{"name": "snap", "refresh": true})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"name": "snap", "snapshots": []string{"snap"}}, out)
+	assert.Equal(t, []string{"file1"}, readDirNames(t, vfs, ".snapshot/snap/dir"))
+
+	out, err = call.Fn(context.Background(), rc.Params{"name": "snap", "remove": true})
+	require.NoError(t, err)
+	assert.Equal(t, rc.Params{"name": "snap", "snapshots": []string{}}, out)
+
+	_, err = call.Fn(context.Background(), rc.Params{"name": "snap", "remove": true})
+	assert.Error(t, err)
+}
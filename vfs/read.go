@@ -7,17 +7,22 @@ import (
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/accounting"
 	"github.com/rclone/rclone/fs/chunkedreader"
 	"github.com/rclone/rclone/fs/hash"
+	"golang.org/x/time/rate"
 )
 
 // ReadFileHandle is an open for read file handle on a File
 type ReadFileHandle struct {
 	baseHandle
+	transferred int64         // bytes read - accessed with atomic - must be 64 bit aligned
+	limiter     *rate.Limiter // bandwidth limiter for this handle - may be nil
+	limitWaits
 	done        func(ctx context.Context, err error)
 	mu          sync.Mutex
 	cond        *sync.Cond // cond lock for out of sequence reads
@@ -32,6 +37,8 @@ type ReadFileHandle struct {
 	file        *File
 	hash        *hash.MultiHasher
 	opened      bool
+	parked      bool // set if the stream was closed by --vfs-read-max-open
+	busy        bool // set while reading or waiting for an in-sequence read
 	remote      string
 }
 
@@ -56,6 +63,8 @@ func newReadFileHandle(f *File) (*ReadFileHandle, error) {
 	}
 
 	fh := &ReadFileHandle{
+		limiter:     newHandleLimiter(&f.VFS().Opt),
+		limitWaits:  newLimitWaits(),
 		remote:      o.Remote(),
 		noSeek:      f.VFS().Opt.NoSeek,
 		file:        f,
@@ -64,6 +73,7 @@ func newReadFileHandle(f *File) (*ReadFileHandle, error) {
 		sizeUnknown: o.Size() < 0,
 	}
 	fh.cond = sync.NewCond(&fh.mu)
+	atomic.AddInt32(&f.nreaders, 1)
 	return fh, nil
 }
 
@@ -71,6 +81,9 @@ func newReadFileHandle(f *File) (*ReadFileHandle, error) {
 // call with the lock held
 func (fh *ReadFileHandle) openPending() (err error) {
 	if fh.opened {
+		if fh.parked {
+			return fh.unpark()
+		}
 		return nil
 	}
 	o := fh.file.getObject()
@@ -86,6 +99,47 @@ func (fh *ReadFileHandle) openPending() (err error) {
 	return nil
 }
 
+// park closes the stream on the backend to save resources. It will
+// be reopened at the same offset by openPending when next needed.
+//
+// call with the lock held
+func (fh *ReadFileHandle) park() {
+	if !fh.opened || fh.parked || fh.closed {
+		return
+	}
+	fs.Debugf(fh.remote, "ReadFileHandle.park closing idle stream at offset %d", fh.offset)
+	err := fh.r.Close()
+	if err != nil {
+		fs.Debugf(fh.remote, "ReadFileHandle.park close failed: %v", err)
+	}
+	fh.parked = true
+}
+
+// unpark reopens the stream closed by park at the current offset
+//
+// call with the lock held
+func (fh *ReadFileHandle) unpark() (err error) {
+	fs.Debugf(fh.remote, "ReadFileHandle.unpark reopening stream at offset %d", fh.offset)
+	// close the current stream in case it was reopened since parking
+	err = fh.r.Close()
+	if err != nil {
+		fs.Debugf(fh.remote, "ReadFileHandle.unpark close old failed: %v", err)
+	}
+	o := fh.file.getObject()
+	r := chunkedreader.New(context.TODO(), o, int64(fh.file.VFS().Opt.ChunkSize), int64(fh.file.VFS().Opt.ChunkSizeLimit))
+	_, err = r.Seek(fh.offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	in, err := r.Open()
+	if err != nil {
+		return err
+	}
+	fh.r.UpdateReader(context.TODO(), in)
+	fh.parked = false
+	return nil
+}
+
 // String converts it to printable
 func (fh *ReadFileHandle) String() string {
 	if fh == nil {
@@ -161,6 +215,7 @@ func (fh *ReadFileHandle) seek(offset int64, reopen bool) (err error) {
 	}
 	fh.r.UpdateReader(context.TODO(), r)
 	fh.offset = offset
+	fh.parked = false
 	return nil
 }
 
@@ -253,10 +308,21 @@ func waitSequential(what string, remote string, cond *sync.Cond, maxWait time.Du
 // Implementation of ReadAt - call with lock held
 func (fh *ReadFileHandle) readAt(p []byte, off int64) (n int, err error) {
 	// defer log.Trace(fh.remote, "p[%d], off=%d", len(p), off)("n=%d, err=%v", &n, &err)
+	t := fh.file.VFS().traceSlow("read", fh.file, "")
+	defer t.done()
+	stop := t.time(slowBackend)
+	defer stop()
+	// mark the handle as in use so it isn't parked while it waits
+	// for an in-sequence read with the lock released
+	fh.busy = true
+	defer func() {
+		fh.busy = false
+	}()
 	err = fh.openPending() // FIXME pending open could be more efficient in the presence of seek (and retries)
 	if err != nil {
 		return 0, err
 	}
+	fh.file.VFS().readers.used(fh)
 	// fs.Debugf(fh.remote, "ReadFileHandle.Read size %d offset %d", reqSize, off)
 	if fh.closed {
 		fs.Errorf(fh.remote, "ReadFileHandle.Read error: %v", EBADF)
@@ -322,6 +388,10 @@ func (fh *ReadFileHandle) readAt(p []byte, off int64) (n int, err error) {
 		doSeek = true
 		doReopen = true
 	}
+	addTransferred(&fh.transferred, n)
+	stop()
+	limitHandle(fh.waitCtx, fh.limiter, n, t)
+	limitHandle(fh.waitCtx, fh.file.VFS().limiter, n, t)
 	if err != nil {
 		fs.Errorf(fh.remote, "ReadFileHandle.Read error: %v", err)
 	} else {
@@ -412,6 +482,9 @@ func (fh *ReadFileHandle) close() error {
 		return ECLOSED
 	}
 	fh.closed = true
+	fh.file.VFS().handles.remove(fh)
+	fh.file.VFS().readers.remove(fh)
+	atomic.AddInt32(&fh.file.nreaders, -1)
 
 	if fh.opened {
 		var err error
@@ -483,6 +556,11 @@ func (fh *ReadFileHandle) Release() error {
 	return err
 }
 
+// bytesTransferred returns the number of bytes read so far
+func (fh *ReadFileHandle) bytesTransferred() int64 {
+	return atomic.LoadInt64(&fh.transferred)
+}
+
 // Size returns the size of the underlying file
 func (fh *ReadFileHandle) Size() int64 {
 	fh.mu.Lock()
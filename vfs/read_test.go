@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/vfs/vfscommon"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -232,3 +233,58 @@ func TestReadFileHandleRelease(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, fh.closed)
 }
+
+func TestReadFileHandleMaxOpen(t *testing.T) {
+	opt := vfscommon.DefaultOpt
+	opt.ReadMaxOpen = 1
+	r, vfs, cleanup := newTestVFSOpt(t, &opt)
+	defer cleanup()
+
+	file1 := r.WriteObject(context.Background(), "file1", "0123456789abcdef", t1)
+	file2 := r.WriteObject(context.Background(), "file2", "ABCDEFGHIJKLMNOP", t2)
+	r.CheckRemoteItems(t, file1, file2)
+
+	open := func(name string) *ReadFileHandle {
+		h, err := vfs.OpenFile(name, os.O_RDONLY, 0)
+		require.NoError(t, err)
+		fh, ok := h.(*ReadFileHandle)
+		require.True(t, ok)
+		return fh
+	}
+	fh1 := open("file1")
+	fh2 := open("file2")
+	assert.Equal(t, 0, vfs.readers.open())
+
+	assert.Equal(t, "0123", readString(t, fh1, 4))
+	assert.Equal(t, 1, vfs.readers.open())
+	assert.False(t, fh1.parked)
+
+	// Reading the second file parks the first
+	assert.Equal(t, "ABCD", readString(t, fh2, 4))
+	assert.Equal(t, 1, vfs.readers.open())
+	assert.True(t, fh1.parked)
+
+	// Reading the first again carries on where it left off
+	assert.Equal(t, "4567", readString(t, fh1, 4))
+	assert.False(t, fh1.parked)
+	assert.True(t, fh2.parked)
+
+	// A handle waiting for an in-sequence read isn't parked
+	fh1.mu.Lock()
+	fh1.busy = true
+	fh1.mu.Unlock()
+	assert.Equal(t, "EFGH", readString(t, fh2, 4))
+	assert.Equal(t, 2, vfs.readers.open())
+	assert.False(t, fh1.parked)
+	fh1.mu.Lock()
+	fh1.busy = false
+	fh1.mu.Unlock()
+
+	assert.Equal(t, "IJKLMNOP", readString(t, fh2, 100))
+	assert.Equal(t, "89abcdef", readString(t, fh1, 100))
+
+	// Closing parked and unparked handles works and the hashes check out
+	require.NoError(t, fh1.Close())
+	require.NoError(t, fh2.Close())
+	assert.Equal(t, 0, vfs.readers.open())
+}
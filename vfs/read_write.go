@@ -5,10 +5,12 @@ import (
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/log"
 	"github.com/rclone/rclone/vfs/vfscache"
+	"golang.org/x/time/rate"
 )
 
 // RWFileHandle is a handle that can be open for read and write.
@@ -16,11 +18,15 @@ import (
 // It will be open to a temporary file which, when closed, will be
 // transferred to the remote.
 type RWFileHandle struct {
+	transferred int64 // bytes read or written - accessed with atomic - must be 64 bit aligned
+
 	// read only variables
-	file  *File
-	d     *Dir
-	flags int            // open flags
-	item  *vfscache.Item // cached file item
+	file    *File
+	d       *Dir
+	flags   int            // open flags
+	item    *vfscache.Item // cached file item
+	limiter *rate.Limiter  // bandwidth limiter for this handle - may be nil
+	limitWaits
 
 	// read write variables protected by mutex
 	mu          sync.Mutex
@@ -43,10 +49,12 @@ func newRWFileHandle(d *Dir, f *File, flags int) (fh *RWFileHandle, err error) {
 	}
 
 	fh = &RWFileHandle{
-		file:  f,
-		d:     d,
-		flags: flags,
-		item:  item,
+		file:       f,
+		d:          d,
+		flags:      flags,
+		item:       item,
+		limiter:    newHandleLimiter(&d.vfs.Opt),
+		limitWaits: newLimitWaits(),
 	}
 
 	// truncate immediately if O_TRUNC is set or O_CREATE is set and file doesn't exist
@@ -153,13 +161,18 @@ func (fh *RWFileHandle) close() (err error) {
 	}
 
 	fh.closed = true
+	fh.d.vfs.handles.remove(fh)
+	t := fh.d.vfs.traceSlow("close", fh.file, "")
+	defer t.done()
+	defer t.time(slowCache)()
 	fh.updateSize()
 	if fh.opened {
 		err = fh.item.Close(fh.file.setObject)
 		fh.opened = false
 	} else {
-		// apply any pending mod times if any
+		// apply any pending mod times and metadata if any
 		_ = fh.file.applyPendingModTime()
+		_ = fh.file.applyPendingMetadata()
 	}
 
 	if !fh.readOnly() {
@@ -169,6 +182,11 @@ func (fh *RWFileHandle) close() (err error) {
 	return err
 }
 
+// bytesTransferred returns the number of bytes read or written so far
+func (fh *RWFileHandle) bytesTransferred() int64 {
+	return atomic.LoadInt64(&fh.transferred)
+}
+
 // Close closes the file
 func (fh *RWFileHandle) Close() error {
 	fh.mu.Lock()
@@ -263,7 +281,14 @@ func (fh *RWFileHandle) _readAt(b []byte, off int64, release bool) (n int, err e
 		fh.mu.Unlock()
 	}
 
+	t := fh.d.vfs.traceSlow("read", fh.file, "")
+	stop := t.time(slowCache)
 	n, err = fh.item.ReadAt(b, off)
+	addTransferred(&fh.transferred, n)
+	stop()
+	limitHandle(fh.waitCtx, fh.limiter, n, t)
+	limitHandle(fh.waitCtx, fh.d.vfs.limiter, n, t)
+	t.done()
 
 	if release {
 		fh.mu.Lock()
@@ -334,12 +359,22 @@ func (fh *RWFileHandle) _writeAt(b []byte, off int64, release bool) (n int, err
 		fh.offset = size
 		off = fh.offset
 	}
+	if err = fh.d.vfs.quotaGrow(off + int64(len(b)) - fh._size()); err != nil {
+		return n, err
+	}
 	fh.writeCalled = true
 	if release {
 		// Do the writing with fh.mu unlocked
 		fh.mu.Unlock()
 	}
+	t := fh.d.vfs.traceSlow("write", fh.file, "")
+	stop := t.time(slowCache)
 	n, err = fh.item.WriteAt(b, off)
+	addTransferred(&fh.transferred, n)
+	stop()
+	limitHandle(fh.waitCtx, fh.limiter, n, t)
+	limitHandle(fh.waitCtx, fh.d.vfs.limiter, n, t)
+	t.done()
 	if release {
 		fh.mu.Lock()
 	}
@@ -430,12 +465,12 @@ func (fh *RWFileHandle) Chdir() error {
 
 // Chmod changes the mode of the file to mode.
 func (fh *RWFileHandle) Chmod(mode os.FileMode) error {
-	return ENOSYS
+	return fh.file.Chmod(mode)
 }
 
 // Chown changes the numeric uid and gid of the named file.
 func (fh *RWFileHandle) Chown(uid, gid int) error {
-	return ENOSYS
+	return fh.file.Chown(uid, gid)
 }
 
 // Fd returns the integer Unix file descriptor referencing the open file.
@@ -0,0 +1,87 @@
+package vfs
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// readerLRU limits the number of ReadFileHandles which have a stream
+// open on the backend as set by --vfs-read-max-open.
+//
+// When there are too many, the streams of the least recently used
+// handles which aren't reading or waiting to read are closed. They are
+// reopened at the same offset when next read.
+type readerLRU struct {
+	mu    sync.Mutex
+	max   int                               // maximum number of open streams, 0 for unlimited
+	lru   *list.List                        // of *ReadFileHandle, most recently used at the front
+	elems map[*ReadFileHandle]*list.Element // where each handle is in lru
+}
+
+// newReaderLRU makes a new readerLRU allowing max open streams
+func newReaderLRU(max int) *readerLRU {
+	return &readerLRU{
+		max:   max,
+		lru:   list.New(),
+		elems: make(map[*ReadFileHandle]*list.Element),
+	}
+}
+
+// used marks fh as having an open stream which has just been used,
+// closing the streams of other handles if there are now too many.
+//
+// Call with fh.mu held.
+func (l *readerLRU) used(fh *ReadFileHandle) {
+	if l.max <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.elems[fh]; ok {
+		l.lru.MoveToFront(elem)
+		return
+	}
+	l.elems[fh] = l.lru.PushFront(fh)
+	for elem := l.lru.Back(); elem != nil && l.lru.Len() > l.max; {
+		victim := elem.Value.(*ReadFileHandle)
+		prev := elem.Prev()
+		// Only park handles which are idle. Don't wait for the
+		// lock as the handle may be waiting for l.mu. The lock
+		// is released while waiting for an in-sequence read so
+		// check busy too.
+		if victim != fh && victim.mu.TryLock() {
+			if !victim.busy {
+				victim.park()
+				l.lru.Remove(elem)
+				delete(l.elems, victim)
+			}
+			victim.mu.Unlock()
+		}
+		elem = prev
+	}
+	if l.lru.Len() > l.max {
+		fs.Debugf(fh.remote, "VFS has %d open readers which is more than --vfs-read-max-open %d as the others are busy", l.lru.Len(), l.max)
+	}
+}
+
+// remove forgets fh - it is OK to call this if fh isn't present
+func (l *readerLRU) remove(fh *ReadFileHandle) {
+	if l.max <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if elem, ok := l.elems[fh]; ok {
+		l.lru.Remove(elem)
+		delete(l.elems, fh)
+	}
+}
+
+// open returns the number of handles with open streams
+func (l *readerLRU) open() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lru.Len()
+}
@@ -0,0 +1,141 @@
+package vfs
+
+import (
+	"path"
+	"sync"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// maxSlowOps is the number of slow operations kept for vfs/slow
+const maxSlowOps = 100
+
+// Phases of a VFS operation which are timed separately
+const (
+	slowBackend = iota // calls to the backend
+	slowCache          // the VFS cache and directory cache, including downloads into the cache
+	slowLimit          // waiting for --vfs-handle-bwlimit
+	slowLock           // waiting for locks held by other operations
+	numSlowPhases
+)
+
+// SlowOp describes a VFS operation which took longer than
+// --vfs-trace-slow
+type SlowOp struct {
+	Time    time.Time     `json:"time"`    // when the operation started
+	Op      string        `json:"op"`      // name of the operation, e.g. "read", "readdir"
+	Path    string        `json:"path"`    // path the operation was on
+	Total   time.Duration `json:"total"`   // total time taken
+	Backend time.Duration `json:"backend"` // time spent in calls to the backend
+	Cache   time.Duration `json:"cache"`   // time spent in the VFS cache
+	Limit   time.Duration `json:"limit"`   // time spent waiting for the bandwidth limit
+	Lock    time.Duration `json:"lock"`    // time spent waiting for locks
+	Other   time.Duration `json:"other"`   // time not accounted for above
+}
+
+// slowTrace times a single VFS operation
+//
+// A nil *slowTrace is valid and does nothing, which is what
+// traceSlow returns if --vfs-trace-slow isn't in use.
+type slowTrace struct {
+	vfs    *VFS
+	op     string
+	node   Node   // node the operation is on
+	leaf   string // name within node if node is the parent directory
+	start  time.Time
+	phases [numSlowPhases]time.Duration
+}
+
+// traceSlow starts timing op on node, or on leaf within node if
+// set, returning nil if tracing is off.
+func (vfs *VFS) traceSlow(op string, node Node, leaf string) *slowTrace {
+	if vfs.Opt.TraceSlow <= 0 {
+		return nil
+	}
+	return &slowTrace{
+		vfs:   vfs,
+		op:    op,
+		node:  node,
+		leaf:  leaf,
+		start: time.Now(),
+	}
+}
+
+// time starts timing phase, returning a function to call when it
+// is finished. It is safe to call the function more than once.
+func (t *slowTrace) time(phase int) (stop func()) {
+	if t == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		if !start.IsZero() {
+			t.phases[phase] += time.Since(start)
+			start = time.Time{}
+		}
+	}
+}
+
+// done finishes the trace, logging and recording it if it was slow
+func (t *slowTrace) done() {
+	if t == nil {
+		return
+	}
+	total := time.Since(t.start)
+	if total < t.vfs.Opt.TraceSlow {
+		return
+	}
+	op := SlowOp{
+		Time:    t.start,
+		Op:      t.op,
+		Path:    path.Join(t.node.Path(), t.leaf),
+		Total:   total,
+		Backend: t.phases[slowBackend],
+		Cache:   t.phases[slowCache],
+		Limit:   t.phases[slowLimit],
+		Lock:    t.phases[slowLock],
+	}
+	op.Other = total - op.Backend - op.Cache - op.Limit - op.Lock
+	fs.Logf(op.Path, "VFS slow %s took %v: backend %v, cache %v, bwlimit %v, lock %v, other %v", op.Op, op.Total, op.Backend, op.Cache, op.Limit, op.Lock, op.Other)
+	t.vfs.slowOps.add(op)
+}
+
+// slowOps keeps the most recent slow operations in a ring buffer
+type slowOps struct {
+	mu   sync.Mutex
+	ops  []SlowOp // allocated on first use
+	next int      // index to write the next op to
+	n    int      // number of ops in ops
+}
+
+// add records op, overwriting the oldest if full
+func (s *slowOps) add(op SlowOp) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ops == nil {
+		s.ops = make([]SlowOp, maxSlowOps)
+	}
+	s.ops[s.next] = op
+	s.next = (s.next + 1) % len(s.ops)
+	if s.n < len(s.ops) {
+		s.n++
+	}
+}
+
+// list returns a copy of the slow operations, oldest first
+func (s *slowOps) list() []SlowOp {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ops := make([]SlowOp, 0, s.n)
+	for i := s.n; i > 0; i-- {
+		ops = append(ops, s.ops[(s.next-i+len(s.ops))%len(s.ops)])
+	}
+	return ops
+}
+
+// SlowOps returns the most recent operations which took longer
+// than --vfs-trace-slow, oldest first.
+func (vfs *VFS) SlowOps() []SlowOp {
+	return vfs.slowOps.list()
+}
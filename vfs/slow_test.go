@@ -0,0 +1,104 @@
+package vfs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowTraceOff(t *testing.T) {
+	_, vfs, cleanup := newTestVFS(t)
+	defer cleanup()
+
+	tr := vfs.traceSlow("stat", vfs.root, "potato")
+	assert.Nil(t, tr)
+	tr.time(slowBackend)()
+	tr.done()
+	assert.Equal(t, []SlowOp{}, vfs.SlowOps())
+}
+
+func TestSlowTrace(t *testing.T) {
+	opt := vfscommon.DefaultOpt
+	opt.TraceSlow = time.Nanosecond
+	_, vfs, cleanup := newTestVFSOpt(t, &opt)
+	defer cleanup()
+
+	tr := vfs.traceSlow("stat", vfs.root, "potato")
+	require.NotNil(t, tr)
+	stop := tr.time(slowBackend)
+	stop()
+	backend := tr.phases[slowBackend]
+	stop() // stopping twice is a no-op
+	assert.Equal(t, backend, tr.phases[slowBackend])
+	tr.done()
+
+	ops := vfs.SlowOps()
+	require.Len(t, ops, 1)
+	op := ops[0]
+	assert.Equal(t, "stat", op.Op)
+	assert.Equal(t, "potato", op.Path)
+	assert.Equal(t, backend, op.Backend)
+	assert.Equal(t, op.Total, op.Backend+op.Cache+op.Limit+op.Lock+op.Other)
+
+	// Check real operations are traced
+	require.NoError(t, vfs.Mkdir("dir", 0777))
+	ops = vfs.SlowOps()
+	require.True(t, len(ops) > 1)
+	assert.Equal(t, "dir", ops[len(ops)-1].Path)
+}
+
+func TestSlowTraceStatPhases(t *testing.T) {
+	opt := vfscommon.DefaultOpt
+	opt.TraceSlow = time.Nanosecond
+	r, vfs, cleanup := newTestVFSOpt(t, &opt)
+	defer cleanup()
+	r.WriteObject(context.Background(), "file1", "file1 contents", t1)
+
+	lastOp := func() SlowOp {
+		ops := vfs.SlowOps()
+		require.True(t, len(ops) > 0)
+		return ops[len(ops)-1]
+	}
+
+	// The first stat lists the directory from the backend
+	_, err := vfs.root.Stat("file1")
+	require.NoError(t, err)
+	op := lastOp()
+	assert.Equal(t, "stat", op.Op)
+	assert.True(t, op.Backend > 0)
+
+	// The second is answered from the directory cache
+	_, err = vfs.root.Stat("file1")
+	require.NoError(t, err)
+	op = lastOp()
+	assert.Equal(t, time.Duration(0), op.Backend)
+	assert.True(t, op.Lock < 10*time.Millisecond, op.Lock)
+
+	// Waiting for the directory lock is counted separately
+	vfs.root.mu.Lock()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		vfs.root.mu.Unlock()
+	}()
+	_, err = vfs.root.Stat("file1")
+	require.NoError(t, err)
+	op = lastOp()
+	assert.Equal(t, time.Duration(0), op.Backend)
+	assert.True(t, op.Lock >= 40*time.Millisecond, op.Lock)
+	assert.Equal(t, op.Total, op.Backend+op.Cache+op.Limit+op.Lock+op.Other)
+}
+
+func TestSlowOpsRing(t *testing.T) {
+	var s slowOps
+	for i := 0; i < maxSlowOps+10; i++ {
+		s.add(SlowOp{Total: time.Duration(i)})
+	}
+	ops := s.list()
+	require.Len(t, ops, maxSlowOps)
+	assert.Equal(t, time.Duration(10), ops[0].Total)
+	assert.Equal(t, time.Duration(maxSlowOps+9), ops[len(ops)-1].Total)
+}
@@ -0,0 +1,168 @@
+package vfs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/vfs/vfscommon"
+)
+
+// snapshotDir is the hidden directory in the root which the
+// snapshots appear in
+const snapshotDir = ".snapshot"
+
+// snapshotTimeFormat is used to name snapshots if no name is given
+const snapshotTimeFormat = "2006-01-02T150405Z"
+
+// newSnapshotVFS makes the read only VFS which holds the snapshots
+// of vfs.
+//
+// The snapshots are trees of Dir and File copied from the directory
+// cache of vfs which are marked as read so they are never re-read
+// from the remote.
+func newSnapshotVFS(vfs *VFS) *VFS {
+	snap := &VFS{
+		f:     vfs.f,
+		Opt:   vfs.Opt.Copy(),
+		inUse: int32(1),
+	}
+	snap.Opt.ReadOnly = true
+	snap.Opt.CacheMode = vfscommon.CacheModeOff
+	snap.Opt.DirCacheTime = math.MaxInt64
+	snap.Opt.PollInterval = 0
+	snap.initState()
+	// Share the open streams and bandwidth limits with vfs
+	snap.readers = vfs.readers
+	snap.limiter = vfs.limiter
+	snap.root = newDir(snap, snap.f, nil, fs.NewDir(snapshotDir, time.Now()))
+	snap.root.read = time.Now()
+	return snap
+}
+
+// snapshotRoot returns the directory holding the snapshots if leaf
+// is its name in d and any snapshots exist, or nil otherwise.
+func (d *Dir) snapshotRoot(leaf string) *Dir {
+	if leaf != snapshotDir || d != d.vfs.root {
+		return nil
+	}
+	d.vfs.snapshotMu.Lock()
+	defer d.vfs.snapshotMu.Unlock()
+	if d.vfs.snapshots == nil {
+		return nil
+	}
+	return d.vfs.snapshots.root
+}
+
+// snapshot makes a frozen copy of the cached contents of d as
+// dirPath in the snapshot VFS.
+//
+// Directories which haven't been read yet are empty in the copy and
+// files which haven't been uploaded yet are left out.
+func (d *Dir) snapshot(snap *VFS, parent *Dir, dirPath string, when time.Time) *Dir {
+	d.mu.RLock()
+	items := make(map[string]Node, len(d.items))
+	for leaf, node := range d.items {
+		items[leaf] = node
+	}
+	d.mu.RUnlock()
+
+	newD := newDir(snap, snap.f, parent, fs.NewDir(dirPath, d.ModTime()))
+	newD.read = when
+	for leaf, node := range items {
+		switch x := node.(type) {
+		case *Dir:
+			newD.items[leaf] = x.snapshot(snap, newD, path.Join(dirPath, leaf), when)
+		case *File:
+			o := x.getObject()
+			if o == nil {
+				continue
+			}
+			newD.items[leaf] = newFile(newD, dirPath, o, leaf)
+		}
+	}
+	return newD
+}
+
+// checkSnapshotName checks name is suitable for a snapshot
+func checkSnapshotName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid snapshot name %q", name)
+	}
+	return nil
+}
+
+// Snapshot freezes the current directory cache as a read only
+// snapshot which appears as /.snapshot/name. If name is empty then
+// the current time is used. It returns the name of the snapshot.
+//
+// An existing snapshot with the same name is replaced.
+//
+// The directories and files in the snapshot keep the listings, sizes
+// and modification times they had when the snapshot was taken
+// regardless of changes to the live view. Reading a file gives the
+// data on the remote so will fail or give the new data if the file
+// has been changed since.
+func (vfs *VFS) Snapshot(name string) (string, error) {
+	if name == "" {
+		name = time.Now().UTC().Format(snapshotTimeFormat)
+	}
+	if err := checkSnapshotName(name); err != nil {
+		return "", err
+	}
+	vfs.snapshotMu.Lock()
+	defer vfs.snapshotMu.Unlock()
+	if vfs.snapshots == nil {
+		vfs.snapshots = newSnapshotVFS(vfs)
+	}
+	snapRoot := vfs.snapshots.root
+	when := time.Now()
+	newRoot := vfs.root.snapshot(vfs.snapshots, snapRoot, path.Join(snapshotDir, name), when)
+	snapRoot.mu.Lock()
+	snapRoot.items[name] = newRoot
+	snapRoot.read = when
+	snapRoot.mu.Unlock()
+	fs.Infof(vfs.f, "Made VFS snapshot %q", name)
+	return name, nil
+}
+
+// RemoveSnapshot removes the snapshot called name.
+//
+// Files open in the snapshot can carry on being read.
+func (vfs *VFS) RemoveSnapshot(name string) error {
+	vfs.snapshotMu.Lock()
+	defer vfs.snapshotMu.Unlock()
+	if vfs.snapshots != nil {
+		snapRoot := vfs.snapshots.root
+		snapRoot.mu.Lock()
+		defer snapRoot.mu.Unlock()
+		if _, found := snapRoot.items[name]; found {
+			delete(snapRoot.items, name)
+			fs.Infof(vfs.f, "Removed VFS snapshot %q", name)
+			return nil
+		}
+	}
+	return errors.New("snapshot not found")
+}
+
+// Snapshots returns the names of the snapshots in sorted order
+func (vfs *VFS) Snapshots() []string {
+	vfs.snapshotMu.Lock()
+	defer vfs.snapshotMu.Unlock()
+	names := []string{}
+	if vfs.snapshots != nil {
+		snapRoot := vfs.snapshots.root
+		snapRoot.mu.RLock()
+		for name := range snapRoot.items {
+			names = append(names, name)
+		}
+		snapRoot.mu.RUnlock()
+	}
+	sort.Strings(names)
+	return names
+}
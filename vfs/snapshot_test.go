@@ -0,0 +1,84 @@
+package vfs
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readDirNames(t *testing.T, vfs *VFS, dir string) (names []string) {
+	fis, err := vfs.ReadDir(dir)
+	require.NoError(t, err)
+	for _, fi := range fis {
+		names = append(names, fi.Name())
+	}
+	return names
+}
+
+func TestVFSSnapshot(t *testing.T) {
+	r, vfs, cleanup := newTestVFS(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	r.WriteObject(ctx, "dir/file1", "file1 contents", t1)
+	r.WriteObject(ctx, "dir/file2", "file2 contents", t2)
+	assert.Equal(t, []string{"file1", "file2"}, readDirNames(t, vfs, "dir"))
+	assert.Equal(t, []string{}, vfs.Snapshots())
+
+	// The snapshot directory isn't there until a snapshot is made
+	_, err := vfs.Stat(".snapshot")
+	assert.Equal(t, os.ErrNotExist, err)
+
+	name, err := vfs.Snapshot("snap")
+	require.NoError(t, err)
+	assert.Equal(t, "snap", name)
+	assert.Equal(t, []string{"snap"}, vfs.Snapshots())
+
+	// The snapshot VFS is set up like the live one
+	snap := vfs.snapshots
+	assert.NotNil(t, snap.prefetching)
+	assert.True(t, snap.readers == vfs.readers)
+	assert.True(t, snap.limiter == vfs.limiter)
+
+	// Change the live view
+	require.NoError(t, vfs.Remove("dir/file2"))
+	r.WriteObject(ctx, "dir/file3", "file3 contents", t3)
+	vfs.FlushDirCache()
+	assert.Equal(t, []string{"file1", "file3"}, readDirNames(t, vfs, "dir"))
+
+	// The snapshot doesn't change and isn't in the root listing
+	assert.Equal(t, []string{"dir"}, readDirNames(t, vfs, ""))
+	assert.Equal(t, []string{"snap"}, readDirNames(t, vfs, ".snapshot"))
+	assert.Equal(t, []string{"dir"}, readDirNames(t, vfs, ".snapshot/snap"))
+	assert.Equal(t, []string{"file1", "file2"}, readDirNames(t, vfs, ".snapshot/snap/dir"))
+	node, err := vfs.Stat(".snapshot/snap/dir/file2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(14), node.Size())
+	assert.Equal(t, ".snapshot/snap/dir/file2", node.Path())
+	contents, err := vfs.ReadFile(".snapshot/snap/dir/file1")
+	require.NoError(t, err)
+	assert.Equal(t, "file1 contents", string(contents))
+
+	// The snapshot is read only
+	_, err = vfs.Create(".snapshot/snap/dir/potato")
+	assert.Equal(t, EROFS, err)
+	assert.Equal(t, EROFS, vfs.Remove(".snapshot/snap/dir/file1"))
+	assert.Equal(t, EROFS, vfs.Rename("dir/file1", ".snapshot/snap/dir/file4"))
+
+	// Remove the snapshot
+	assert.Error(t, vfs.RemoveSnapshot("potato"))
+	require.NoError(t, vfs.RemoveSnapshot("snap"))
+	assert.Equal(t, []string{}, vfs.Snapshots())
+	_, err = vfs.Stat(".snapshot/snap")
+	assert.Equal(t, os.ErrNotExist, err)
+
+	// Snapshots are named after the time by default
+	name, err = vfs.Snapshot("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{name}, vfs.Snapshots())
+	_, err = vfs.Snapshot("bad/name")
+	assert.Error(t, err)
+}
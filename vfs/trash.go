@@ -0,0 +1,161 @@
+package vfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/list"
+	"github.com/rclone/rclone/fs/operations"
+)
+
+// trashTimeFormat is the format of the directories in the trash
+// which record when the files in them were deleted
+const trashTimeFormat = "2006-01-02T150405Z"
+
+// maxTrashInterval is the longest time between trash clean ups
+const maxTrashInterval = time.Hour
+
+// trashPath returns the path of the trash directory or "" if not in use
+func (vfs *VFS) trashPath() string {
+	if vfs.nativeTrash {
+		return ""
+	}
+	return strings.Trim(vfs.Opt.Trash, "/")
+}
+
+// startTrash works out how --vfs-trash should be done for the remote
+// and starts the trash cleaner if needed
+func (vfs *VFS) startTrash() {
+	if vfs.Opt.Trash == "" {
+		return
+	}
+	if vfs.f.Features().Trash {
+		vfs.nativeTrash = true
+		fs.Infof(vfs.f, "--vfs-trash: deleted files go into the remote's own trash so %q isn't used", vfs.Opt.Trash)
+		if vfs.Opt.TrashMaxAge > 0 {
+			fs.Logf(vfs.f, "--vfs-trash-max-age is ignored as the remote's own trash is used")
+		}
+		return
+	}
+	if vfs.f.Features().Move == nil {
+		fs.Logf(vfs.f, "--vfs-trash: this remote can't move files on the server so deleting a file will copy it into the trash then delete it")
+	}
+	if vfs.Opt.TrashMaxAge > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		vfs.cancelTrash = cancel
+		go vfs.trashCleaner(ctx)
+	}
+}
+
+// inTrash returns true if remote is the trash directory or inside it
+func (vfs *VFS) inTrash(remote string) bool {
+	trash := vfs.trashPath()
+	return trash != "" && (remote == trash || strings.HasPrefix(remote, trash+"/"))
+}
+
+// useTrash returns true if remote should be moved into the trash
+// rather than deleted. This is when --vfs-trash is in use, the remote
+// doesn't have a trash of its own and remote isn't in the trash
+// already.
+func (vfs *VFS) useTrash(remote string) bool {
+	return vfs.trashPath() != "" && !vfs.inTrash(remote)
+}
+
+// moveToTrash moves o into the trash as
+// trash/<time of deletion>/<path>
+//
+// If the remote can't move files on the server this copies o then
+// deletes it, so don't call it with any File locks held.
+func (vfs *VFS) moveToTrash(ctx context.Context, o fs.Object) error {
+	remote := o.Remote()
+	dstRemote := path.Join(vfs.trashPath(), time.Now().UTC().Format(trashTimeFormat), remote)
+	_, err := operations.Move(ctx, vfs.f, nil, dstRemote, o)
+	if err != nil {
+		return fmt.Errorf("failed to move %q to trash: %w", remote, err)
+	}
+	fs.Debugf(remote, "Moved to trash as %q", dstRemote)
+	return nil
+}
+
+// forgetTrash makes sure the directory cache of the trash is re-read
+// so new deletions show up in it.
+//
+// Don't call this with any File locks held.
+func (vfs *VFS) forgetTrash() {
+	if trash := vfs.trashPath(); trash != "" {
+		vfs.root.ForgetPath(trash, fs.EntryDirectory)
+	}
+}
+
+// EmptyTrash removes the deletions in the trash which are older than
+// maxAge. If maxAge is 0 then it removes everything.
+//
+// It returns the names of the directories removed.
+func (vfs *VFS) EmptyTrash(ctx context.Context, maxAge time.Duration) (removed []string, err error) {
+	if vfs.nativeTrash {
+		return nil, errors.New("--vfs-trash is using the remote's own trash which rclone can't empty selectively - use \"rclone cleanup\" instead")
+	}
+	trash := vfs.trashPath()
+	if trash == "" {
+		return nil, errors.New("--vfs-trash is not in use")
+	}
+	removed = []string{}
+	entries, err := list.DirSorted(ctx, vfs.f, true, trash)
+	if err == fs.ErrorDirNotFound {
+		return removed, nil
+	} else if err != nil {
+		return removed, fmt.Errorf("failed to list trash: %w", err)
+	}
+	now := time.Now()
+	for _, entry := range entries {
+		dir, ok := entry.(fs.Directory)
+		if !ok {
+			continue
+		}
+		deleted, err := time.Parse(trashTimeFormat, path.Base(dir.Remote()))
+		if err != nil {
+			fs.Debugf(dir, "Ignoring unknown directory in trash")
+			continue
+		}
+		if maxAge > 0 && now.Sub(deleted) < maxAge {
+			continue
+		}
+		err = operations.Purge(ctx, vfs.f, dir.Remote())
+		if err != nil {
+			return removed, fmt.Errorf("failed to remove %q from trash: %w", dir.Remote(), err)
+		}
+		removed = append(removed, dir.Remote())
+	}
+	if len(removed) > 0 {
+		fs.Infof(vfs.f, "Removed %d deletions from the trash", len(removed))
+		vfs.forgetTrash()
+	}
+	return removed, nil
+}
+
+// trashCleaner removes old files from the trash every so often
+// until the context is cancelled
+func (vfs *VFS) trashCleaner(ctx context.Context) {
+	interval := vfs.Opt.TrashMaxAge
+	if interval > maxTrashInterval {
+		interval = maxTrashInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		_, err := vfs.EmptyTrash(ctx, vfs.Opt.TrashMaxAge)
+		if err != nil {
+			fs.Errorf(vfs.f, "Failed to clean trash: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
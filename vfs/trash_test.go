@@ -0,0 +1,85 @@
+package vfs
+
+import (
+	"context"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/fstest"
+	"github.com/rclone/rclone/vfs/vfscommon"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVFSTrash(t *testing.T) {
+	opt := vfscommon.DefaultOpt
+	opt.Trash = ".trash"
+	r, vfs, cleanup := newTestVFSOpt(t, &opt)
+	defer cleanup()
+	ctx := context.Background()
+	features := r.Fremote.Features()
+	if features.Move == nil && features.Copy == nil {
+		t.Skip("skip as can't move or copy files server side")
+	}
+
+	file1 := r.WriteObject(ctx, "dir/file1", "file1 contents", t1)
+	r.CheckRemoteItems(t, file1)
+
+	require.NoError(t, vfs.Remove("dir/file1"))
+
+	// The file should now be in the trash
+	trash, err := vfs.ReadDir(".trash")
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	deleted := trash[0].Name()
+	_, err = time.Parse(trashTimeFormat, deleted)
+	require.NoError(t, err)
+	file1.Path = path.Join(".trash", deleted, "dir/file1")
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{file1}, []string{"dir", ".trash", ".trash/" + deleted, ".trash/" + deleted + "/dir"}, r.Fremote.Precision())
+
+	// Files deleted from the trash are deleted permanently
+	require.NoError(t, vfs.Remove(file1.Path))
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{}, []string{"dir", ".trash", ".trash/" + deleted, ".trash/" + deleted + "/dir"}, r.Fremote.Precision())
+
+	// Nothing is old enough to go
+	removed, err := vfs.EmptyTrash(ctx, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []string{}, removed)
+
+	// Everything goes with maxAge 0
+	removed, err = vfs.EmptyTrash(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{path.Join(".trash", deleted)}, removed)
+	require.NoError(t, operations.Rmdirs(ctx, r.Fremote, "", false))
+	fstest.CheckListingWithPrecision(t, r.Fremote, []fstest.Item{}, []string{}, r.Fremote.Precision())
+}
+
+func TestVFSTrashOff(t *testing.T) {
+	_, vfs, cleanup := newTestVFS(t)
+	defer cleanup()
+	_, err := vfs.EmptyTrash(context.Background(), 0)
+	assert.Error(t, err)
+}
+
+func TestVFSNativeTrash(t *testing.T) {
+	// The local backend has no trash so the trash directory is used
+	opt := vfscommon.DefaultOpt
+	opt.Trash = ".trash"
+	r, vfs, cleanup := newTestVFSOpt(t, &opt)
+	defer cleanup()
+	assert.False(t, r.Fremote.Features().Trash)
+	assert.False(t, vfs.nativeTrash)
+	assert.Equal(t, ".trash", vfs.trashPath())
+
+	// With a native trash files are just deleted
+	vfs.nativeTrash = true
+	assert.Equal(t, "", vfs.trashPath())
+	file1 := r.WriteObject(context.Background(), "file1", "file1 contents", t1)
+	r.CheckRemoteItems(t, file1)
+	require.NoError(t, vfs.Remove("file1"))
+	r.CheckRemoteItems(t)
+	_, err := vfs.EmptyTrash(context.Background(), 0)
+	assert.Error(t, err)
+}
@@ -23,6 +23,7 @@ package vfs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -40,6 +41,7 @@ import (
 	"github.com/rclone/rclone/fs/walk"
 	"github.com/rclone/rclone/vfs/vfscache"
 	"github.com/rclone/rclone/vfs/vfscommon"
+	"golang.org/x/time/rate"
 )
 
 // Node represents either a directory (*Dir) or a file (*File)
@@ -65,6 +67,21 @@ var (
 	_ Node = (*Dir)(nil)
 )
 
+// MetadataNode is an optional interface for a Node which can have
+// permissions, an owner and an access time of its own which are
+// stored in metadata with --vfs-metadata. Only *File implements it.
+type MetadataNode interface {
+	Node
+	AccessTime() time.Time
+	SetAccessTime(atime time.Time) error
+	Owner() (uid, gid uint32)
+	Chmod(mode os.FileMode) error
+	Chown(uid, gid int) error
+}
+
+// Check interfaces
+var _ MetadataNode = (*File)(nil)
+
 // Nodes is a slice of Node
 type Nodes []Node
 
@@ -164,11 +181,26 @@ type VFS struct {
 	Opt         vfscommon.Options
 	cache       *vfscache.Cache
 	cancelCache context.CancelFunc
+	cancelTrash context.CancelFunc // stops the trash cleaner if running
+	nativeTrash bool               // set if --vfs-trash uses the remote's own trash
 	usageMu     sync.Mutex
 	usageTime   time.Time
 	usage       *fs.Usage
 	pollChan    chan time.Duration
-	inUse       int32 // count of number of opens accessed with atomic
+	inUse       int32           // count of number of opens accessed with atomic
+	changes     *changeLog      // recent changes seen by the VFS
+	handles     *handles        // open file handles
+	slowOps     slowOps         // operations slower than --vfs-trace-slow
+	readers     *readerLRU      // read handles with streams open on the backend
+	snapshotMu  sync.Mutex      // protects snapshots
+	snapshots   *VFS            // read only VFS holding the snapshots - may be nil
+	prefetchMu  sync.Mutex      // protects the following
+	prefetcher  Prefetcher      // decides which files to prefetch - may be nil
+	prefetching map[string]bool // files being prefetched
+	limiter     *rate.Limiter   // bandwidth limiter for all handles - may be nil
+	quotaMu     sync.Mutex      // protects the following
+	quotaTime   time.Time       // when quotaUsed was last measured
+	quotaUsed   int64           // bytes used for --vfs-quota
 }
 
 // Keep track of active VFS keyed on fs.ConfigString(f)
@@ -205,12 +237,14 @@ func New(f fs.Fs, opt *vfscommon.Options) *VFS {
 	defer activeMu.Unlock()
 	configName := fs.ConfigString(f)
 	for _, activeVFS := range active[configName] {
-		if vfs.Opt == activeVFS.Opt {
+		if vfs.Opt == activeVFS.Opt.Copy() {
 			fs.Debugf(f, "Re-using VFS from active cache")
 			atomic.AddInt32(&activeVFS.inUse, 1)
 			return activeVFS
 		}
 	}
+	vfs.initState()
+
 	// Put the VFS into the active cache
 	active[configName] = append(active[configName], vfs)
 
@@ -234,6 +268,9 @@ func New(f fs.Fs, opt *vfscommon.Options) *VFS {
 
 	vfs.SetCacheMode(vfs.Opt.CacheMode)
 
+	// Set up the trash and start clearing old files out of it
+	vfs.startTrash()
+
 	// Pin the Fs into the cache so that when we use cache.NewFs
 	// with the same remote string we get this one. The Pin is
 	// removed when the vfs is finalized
@@ -242,11 +279,26 @@ func New(f fs.Fs, opt *vfscommon.Options) *VFS {
 	return vfs
 }
 
+// initState starts recording changes and open handles and sets up
+// the readers, bandwidth limiter and prefetcher from vfs.Opt.
+//
+// It is used by New and newSnapshotVFS.
+func (vfs *VFS) initState() {
+	vfs.changes = newChangeLog()
+	vfs.handles = newHandles()
+	vfs.readers = newReaderLRU(vfs.Opt.ReadMaxOpen)
+	vfs.limiter = newVFSLimiter(&vfs.Opt)
+	vfs.prefetching = make(map[string]bool)
+	if vfs.Opt.Prefetch > 0 {
+		vfs.prefetcher = newSequentialPrefetcher(vfs.Opt.PrefetchAfter, vfs.Opt.Prefetch)
+	}
+}
+
 // Stats returns info about the VFS
 func (vfs *VFS) Stats() (out rc.Params) {
 	out = make(rc.Params)
 	out["fs"] = fs.ConfigString(vfs.f)
-	out["opt"] = vfs.Opt
+	out["opt"] = vfs.Opt.Copy()
 	out["inUse"] = atomic.LoadInt32(&vfs.inUse)
 
 	var (
@@ -335,6 +387,10 @@ func (vfs *VFS) Shutdown() {
 	activeMu.Unlock()
 
 	vfs.shutdownCache()
+	if vfs.cancelTrash != nil {
+		vfs.cancelTrash()
+		vfs.cancelTrash = nil
+	}
 }
 
 // CleanUp deletes the contents of the on disk cache
@@ -345,6 +401,15 @@ func (vfs *VFS) CleanUp() error {
 	return vfs.cache.CleanUp()
 }
 
+// CheckCache verifies the on disk cache, removing corrupted items
+// and orphan files. See vfscache.Cache.Check for details.
+func (vfs *VFS) CheckCache(ctx context.Context) (vfscache.CheckResult, error) {
+	if vfs.cache == nil {
+		return vfscache.CheckResult{}, errors.New("vfs cache is not in use - set --vfs-cache-mode")
+	}
+	return vfs.cache.Check(ctx)
+}
+
 // FlushDirCache empties the directory cache
 func (vfs *VFS) FlushDirCache() {
 	vfs.root.ForgetAll()
@@ -580,6 +645,9 @@ const unknownFreeBytes = 1 << 50
 // This information is cached for the DirCacheTime interval
 func (vfs *VFS) Statfs() (total, used, free int64) {
 	// defer log.Trace("/", "")("total=%d, used=%d, free=%d", &total, &used, &free)
+	if vfs.Opt.Quota >= 0 {
+		return vfs.quotaStatfs()
+	}
 	vfs.usageMu.Lock()
 	defer vfs.usageMu.Unlock()
 	total, used, free = -1, -1, -1
@@ -50,6 +50,7 @@ type Cache struct {
 	hashOption *fs.HashesOption     // corresponding OpenOption
 	writeback  *writeback.WriteBack // holds Items for writeback
 	avFn       AddVirtualFn         // if set, can be called to add dir entries
+	compress   *fs.HTTPOption       // if set, added to write back uploads to compress them
 
 	mu            sync.Mutex       // protects the following variables
 	cond          *sync.Cond       // cond lock for synchronous cache cleaning
@@ -126,6 +127,7 @@ func New(ctx context.Context, fremote fs.Fs, opt *vfscommon.Options, avFn AddVir
 		hashOption: hashOption,
 		writeback:  writeback.New(ctx, opt),
 		avFn:       avFn,
+		compress:   writeBackCompression(fremote, opt.WriteBackCompress),
 	}
 
 	// load in the cache and metadata off disk
@@ -165,10 +167,32 @@ func (c *Cache) Stats() (out rc.Params) {
 	out["erroredFiles"] = len(c.errItems)
 	out["bytesUsed"] = c.used
 	out["outOfSpace"] = c.outOfSpace
+	out["dirtyFiles"], out["bytesDirty"] = c._dirty()
 
 	return out
 }
 
+// Dirty returns the number of items in the cache which haven't been
+// uploaded yet and their total size
+func (c *Cache) Dirty() (files int, bytes int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c._dirty()
+}
+
+// _dirty returns the number and size of the dirty items
+//
+// call with c.mu held
+func (c *Cache) _dirty() (files int, bytes int64) {
+	for _, item := range c.item {
+		if dirty, size := item.getDirtySize(); dirty {
+			files++
+			bytes += size
+		}
+	}
+	return files, bytes
+}
+
 // createDir creates a directory path, along with any necessary parents
 func createDir(dir string) error {
 	return file.MkdirAll(dir, 0700)
@@ -661,7 +685,7 @@ func (c *Cache) purgeOld(maxAge time.Duration) {
 	for _, item := range c.item {
 		c.removeNotInUse(item, maxAge, false)
 	}
-	if c.used < int64(c.opt.CacheMaxSize) {
+	if c.used < int64(c.opt.GetCacheMaxSize()) {
 		c.outOfSpace = false
 		c.cond.Broadcast()
 	}
@@ -741,23 +765,26 @@ func (c *Cache) clean(kicked bool) {
 
 	// loop cleaning the cache until we reach below cache quota
 	for {
+		// Read the limits each time round as they may be changed
+		maxAge, maxSize := c.opt.GetCacheMaxAge(), int64(c.opt.GetCacheMaxSize())
+
 		// Remove any files that are over age
-		c.purgeOld(c.opt.CacheMaxAge)
+		c.purgeOld(maxAge)
 
-		if int64(c.opt.CacheMaxSize) <= 0 {
+		if maxSize <= 0 {
 			break
 		}
 
 		// Now remove files not in use until cache size is below quota starting from the
 		// oldest first
-		c.purgeOverQuota(int64(c.opt.CacheMaxSize))
+		c.purgeOverQuota(maxSize)
 
 		// Remove cache files that are not dirty if we are still above the max cache size
-		c.purgeClean(int64(c.opt.CacheMaxSize))
+		c.purgeClean(maxSize)
 		c.retryFailedResets()
 
 		used := c.updateUsed()
-		if used <= int64(c.opt.CacheMaxSize) && len(c.errItems) == 0 {
+		if used <= maxSize && len(c.errItems) == 0 {
 			break
 		}
 	}
@@ -3,6 +3,7 @@ package vfscache
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	_ "github.com/rclone/rclone/backend/local" // import the local backend
+	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fstest"
 	"github.com/rclone/rclone/vfs/vfscommon"
 	"github.com/stretchr/testify/assert"
@@ -712,4 +714,98 @@ func TestCacheStats(t *testing.T) {
 	assert.Equal(t, 0, out["files"])
 	assert.Equal(t, 0, out["uploadsInProgress"])
 	assert.Equal(t, 0, out["uploadsQueued"])
+	assert.Equal(t, 0, out["dirtyFiles"])
+	assert.Equal(t, int64(0), out["bytesDirty"])
+}
+
+func TestCacheCheck(t *testing.T) {
+	r, c, cleanup := newItemTestCache(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// download a file into the cache
+	download := func(remote string) (item *Item) {
+		contents, obj, item := newFile(t, r, c, remote)
+		require.NoError(t, item.Open(obj))
+		buf := make([]byte, len(contents))
+		n, err := item.ReadAt(buf, 0)
+		require.NoError(t, err)
+		require.Equal(t, contents, string(buf[:n]))
+		require.NoError(t, item.Close(nil))
+		return item
+	}
+	download("good")
+	bad := download("bad")
+	osPathBad := c.toOSPath(bad.name)
+
+	// A good cache has no problems
+	res, err := c.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, CheckResult{Checked: 2, Problems: []string{}}, res)
+
+	// Corrupt an item without changing its size and add an orphan
+	require.NoError(t, ioutil.WriteFile(osPathBad, make([]byte, 100), 0600))
+	osPathOrphan := c.toOSPath("orphan")
+	require.NoError(t, ioutil.WriteFile(osPathOrphan, []byte("orphan"), 0600))
+
+	// Check with --dry-run doesn't remove anything
+	ctxDryRun, ci := fs.AddConfig(ctx)
+	ci.DryRun = true
+	res, err = c.Check(ctxDryRun)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Checked)
+	assert.Equal(t, 2, len(res.Problems))
+	assert.Equal(t, 0, res.Removed)
+	assert.Equal(t, int64(106), res.Reclaimable)
+	assert.Equal(t, []string{
+		`name="bad" opens=0 size=100`,
+		`name="good" opens=0 size=100`,
+	}, itemAsString(c))
+	assertPathExist(t, osPathBad)
+	assertPathExist(t, osPathOrphan)
+
+	// Check removes the bad item and the orphan
+	res, err = c.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.Checked)
+	require.Equal(t, 2, len(res.Problems))
+	assert.Contains(t, res.Problems[0], "corrupted")
+	assert.Contains(t, res.Problems[1], "orphan file")
+	assert.Equal(t, 2, res.Removed)
+	assert.Equal(t, int64(106), res.Reclaimable)
+	assert.Equal(t, []string{
+		`name="good" opens=0 size=100`,
+	}, itemAsString(c))
+	assertPathNotExist(t, osPathBad)
+	assertPathNotExist(t, osPathOrphan)
+
+	// and the cache is good again
+	res, err = c.Check(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, CheckResult{Checked: 1, Problems: []string{}}, res)
+}
+
+func TestCacheWriteBackCompress(t *testing.T) {
+	opt := vfscommon.DefaultOpt
+	opt.CachePollInterval = 0
+	opt.WriteBackCompress = "zstd"
+	_, c, cleanup := newTestCacheOpt(t, opt)
+	defer cleanup()
+
+	// The local remote can't compress so the option is ignored
+	assert.Nil(t, c.compress)
+	assert.Nil(t, writeBackCompression(c.fremote, ""))
+	assert.Nil(t, writeBackCompression(c.fremote, "brotli"))
+	ctx := context.Background()
+	assert.Equal(t, ctx, c.uploadContext(ctx))
+
+	// With a compress remote the header is added to uploads
+	// without changing the parent config
+	c.compress = &fs.HTTPOption{Key: "Content-Encoding", Value: "zstd"}
+	ciParent := fs.GetConfig(ctx)
+	nHeaders := len(ciParent.UploadHeaders)
+	ci := fs.GetConfig(c.uploadContext(ctx))
+	assert.Equal(t, c.compress, ci.UploadHeaders[len(ci.UploadHeaders)-1])
+	assert.Equal(t, nHeaders+1, len(ci.UploadHeaders))
+	assert.Equal(t, nHeaders, len(ciParent.UploadHeaders))
 }
@@ -0,0 +1,173 @@
+package vfscache
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/rclone/rclone/fs/hash"
+	"github.com/rclone/rclone/fs/operations"
+	"github.com/rclone/rclone/lib/ranges"
+)
+
+// CheckResult is the result of checking the cache with Check
+type CheckResult struct {
+	Checked     int      `json:"checked"`     // number of items checked
+	Problems    []string `json:"problems"`    // description of each problem found
+	Removed     int      `json:"removed"`     // number of bad items and orphan files removed
+	Reclaimable int64    `json:"reclaimable"` // bytes used by the bad items and orphan files
+}
+
+// problem records a problem with name using size bytes
+func (res *CheckResult) problem(name string, size int64, format string, args ...interface{}) {
+	res.Problems = append(res.Problems, name+": "+fmt.Sprintf(format, args...))
+	res.Reclaimable += size
+	fs.Errorf(name, "vfs cache: check: "+format, args...)
+}
+
+// Check verifies the cache, removing any corrupted items and any
+// files on disk which don't belong to an item.
+//
+// Items which are open or dirty are not checked. Items which are
+// completely downloaded have their hash checked against the remote
+// object if the cache and the remote have a hash in common.
+//
+// Nothing is removed if --dry-run is set.
+func (c *Cache) Check(ctx context.Context) (res CheckResult, err error) {
+	res.Problems = []string{}
+
+	// Check the items
+	c.mu.Lock()
+	items := make([]*Item, 0, len(c.item))
+	for _, item := range c.item {
+		items = append(items, item)
+	}
+	c.mu.Unlock()
+	for _, item := range items {
+		checked, size, problem := item.check(ctx)
+		if checked {
+			res.Checked++
+		}
+		if problem == "" {
+			continue
+		}
+		res.problem(item.name, size, "%s", problem)
+		if operations.SkipDestructive(ctx, item.name, "remove corrupted vfs cache item") {
+			continue
+		}
+		if c.removeChecked(item, problem) {
+			res.Removed++
+		}
+	}
+
+	// Look for files on disk without items
+	for _, dir := range []string{c.root, c.metaRoot} {
+		err = c.walk(dir, func(osPath string, fi os.FileInfo, name string) error {
+			if fi.IsDir() {
+				return nil
+			}
+			c.mu.Lock()
+			_, found := c.item[name]
+			c.mu.Unlock()
+			if found {
+				return nil
+			}
+			res.problem(name, fi.Size(), "orphan file %q", osPath)
+			if operations.SkipDestructive(ctx, osPath, "remove orphan vfs cache file") {
+				return nil
+			}
+			err := os.Remove(osPath)
+			if err != nil && !os.IsNotExist(err) {
+				fs.Errorf(name, "vfs cache: check: failed to remove orphan file: %v", err)
+				return nil
+			}
+			res.Removed++
+			return nil
+		})
+		if err != nil {
+			return res, fmt.Errorf("failed to walk cache %q: %w", dir, err)
+		}
+	}
+	return res, nil
+}
+
+// removeChecked removes an item found to be bad by check returning
+// true if it was removed. It isn't removed if it has come into use
+// since it was checked.
+func (c *Cache) removeChecked(item *Item, reason string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.item[item.name] != item || item.inUse() {
+		return false
+	}
+	delete(c.item, item.name)
+	item.remove(reason)
+	return true
+}
+
+// check the item is consistent, returning a description of the
+// problem if it isn't and the disk space it is using.
+//
+// checked is false if the item was in use so wasn't checked.
+func (item *Item) check(ctx context.Context) (checked bool, size int64, problem string) {
+	item.mu.Lock()
+	if item.opens != 0 || item.info.Dirty {
+		item.mu.Unlock()
+		return false, 0, ""
+	}
+	info := item.info
+	info.Rs = append(ranges.Ranges(nil), item.info.Rs...)
+	item.mu.Unlock()
+	size = info.Rs.Size()
+
+	// Check the cache file against the metadata
+	osPath := item.c.toOSPath(item.name) // No locking in Cache
+	fi, err := os.Stat(osPath)
+	if os.IsNotExist(err) {
+		if size > 0 {
+			return true, size, "cache file is missing"
+		}
+		return true, 0, ""
+	} else if err != nil {
+		return true, size, fmt.Sprintf("failed to stat cache file: %v", err)
+	}
+	if info.Size >= 0 && fi.Size() != info.Size {
+		return true, size, fmt.Sprintf("cache file is %d bytes but metadata says %d", fi.Size(), info.Size)
+	}
+	if info.Size >= 0 && size > info.Size {
+		return true, size, fmt.Sprintf("metadata says %d bytes are present but file is only %d", size, info.Size)
+	}
+
+	// Check the hash if the file is complete and there is a
+	// common hash
+	hashType := item.c.hashType
+	if hashType == hash.None || info.Size <= 0 || !info.Rs.Present(ranges.Range{Pos: 0, Size: info.Size}) {
+		return true, size, ""
+	}
+	o, err := item.c.fremote.NewObject(ctx, item.name)
+	if err != nil {
+		// Stale items are dealt with when they are opened
+		return true, size, ""
+	}
+	if fs.Fingerprint(ctx, o, item.c.opt.FastFingerprint) != info.Fingerprint {
+		return true, size, ""
+	}
+	remoteHash, err := o.Hash(ctx, hashType)
+	if err != nil || remoteHash == "" {
+		return true, size, ""
+	}
+	in, err := os.Open(osPath)
+	if err != nil {
+		return true, size, fmt.Sprintf("failed to open cache file: %v", err)
+	}
+	hashes, err := hash.StreamTypes(in, hash.NewHashSet(hashType))
+	_ = in.Close()
+	if err != nil {
+		return true, size, fmt.Sprintf("failed to read cache file: %v", err)
+	}
+	if localHash := hashes[hashType]; localHash != remoteHash {
+		return true, size, fmt.Sprintf("corrupted: %v hash is %q but remote is %q", hashType, localHash, remoteHash)
+	}
+	return true, size, ""
+}
@@ -0,0 +1,47 @@
+package vfscache
+
+import (
+	"context"
+
+	"github.com/rclone/rclone/fs"
+)
+
+// writeBackCompression returns the upload header which asks the
+// remote to compress files written back with encoding as set by
+// --vfs-write-back-compress, or nil if not in use.
+//
+// Only remotes with the CompressUploads feature, like the compress
+// backend, understand the header. They compress the data themselves
+// and record the original size and hash so the remote object still
+// matches the cache file. Any other remote would store the data
+// uncompressed under a wrong Content-Encoding so the option is ignored
+// for those.
+func writeBackCompression(f fs.Fs, encoding string) *fs.HTTPOption {
+	if encoding == "" {
+		return nil
+	}
+	if encoding != "zstd" {
+		fs.Errorf(f, "vfs cache: ignoring --vfs-write-back-compress %q as only zstd is supported", encoding)
+		return nil
+	}
+	if !f.Features().CompressUploads {
+		fs.Errorf(f, "vfs cache: ignoring --vfs-write-back-compress as the remote can't compress uploads")
+		return nil
+	}
+	fs.Infof(f, "vfs cache: compressing files written back with %s", encoding)
+	return &fs.HTTPOption{Key: "Content-Encoding", Value: encoding}
+}
+
+// uploadContext returns ctx with the upload headers for writing back
+// files to the remote added
+func (c *Cache) uploadContext(ctx context.Context) context.Context {
+	if c.compress == nil {
+		return ctx
+	}
+	ctx, ci := fs.AddConfig(ctx)
+	// copy the headers so the ones in the parent config aren't changed
+	headers := make([]*fs.HTTPOption, 0, len(ci.UploadHeaders)+1)
+	headers = append(headers, ci.UploadHeaders...)
+	ci.UploadHeaders = append(headers, c.compress)
+	return ctx
+}
@@ -290,8 +290,8 @@ func (dls *Downloaders) _ensureDownloader(r ranges.Range) (err error) {
 	window := int64(fs.GetConfig(context.TODO()).BufferSize)
 
 	// Increase the read range by the read ahead if set
-	if dls.opt.ReadAhead > 0 {
-		r.Size += int64(dls.opt.ReadAhead)
+	if readAhead := dls.opt.GetReadAhead(); readAhead > 0 {
+		r.Size += int64(readAhead)
 	}
 
 	// We may be reopening a downloader after a failure here or
@@ -453,6 +453,13 @@ func (item *Item) IsDirty() bool {
 	return item.info.Dirty
 }
 
+// getDirtySize returns whether the item is dirty and its size
+func (item *Item) getDirtySize() (dirty bool, size int64) {
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	return item.info.Dirty, item.info.Size
+}
+
 // Create the cache file and store the metadata on disk
 // Called with item.mu locked
 func (item *Item) _createFile(osPath string) (err error) {
@@ -581,7 +588,7 @@ func (item *Item) _store(ctx context.Context, storeFn StoreFn) (err error) {
 	if cacheObj != nil {
 		o, name := item.o, item.name
 		item.mu.Unlock()
-		o, err := operations.Copy(ctx, item.c.fremote, o, name, cacheObj)
+		o, err := operations.Copy(item.c.uploadContext(ctx), item.c.fremote, o, name, cacheObj)
 		item.mu.Lock()
 		if err != nil {
 			return fmt.Errorf("vfs cache: failed to transfer file from cache to remote: %w", err)
@@ -1255,6 +1262,22 @@ func (item *Item) readAt(b []byte, off int64) (n int, err error) {
 	return n, err
 }
 
+// Fetch makes sure the range from offset, size is downloaded into
+// the cache without reading it
+func (item *Item) Fetch(offset, size int64) (err error) {
+	item.preAccess()
+	defer item.postAccess()
+	item.mu.Lock()
+	defer item.mu.Unlock()
+	if item.fd == nil {
+		return errors.New("vfs cache item Fetch: internal error: didn't Open file")
+	}
+	if item.info.Rs.Present(ranges.Range{Pos: offset, Size: size}) {
+		return nil
+	}
+	return item._ensure(offset, size)
+}
+
 // WriteAt bytes to the file at off
 func (item *Item) WriteAt(b []byte, off int64) (n int, err error) {
 	item.preAccess()
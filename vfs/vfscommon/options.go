@@ -3,6 +3,7 @@ package vfscommon
 import (
 	"os"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/rclone/rclone/fs"
@@ -34,6 +35,18 @@ type Options struct {
 	ReadAhead         fs.SizeSuffix // bytes to read ahead in cache mode "full"
 	UsedIsSize        bool          // if true, use the `rclone size` algorithm for Used size
 	FastFingerprint   bool          // if set use fast fingerprints
+	HandleBwLimit     fs.SizeSuffix // if > 0 limit each open file handle to this many bytes/s
+	BwLimit           fs.SizeSuffix // if > 0 limit all the open file handles together to this many bytes/s
+	Quota             fs.SizeSuffix // if >= 0 the maximum total size of the files in the VFS
+	Trash             string        // if set move deleted files into this directory instead
+	TrashMaxAge       time.Duration // remove files from the trash after this long, 0 to keep forever
+	TraceSlow         time.Duration // if > 0 log operations which take longer than this
+	DedupeReads       bool          // if set concurrent readers of a file share a download through the cache
+	ReadMaxOpen       int           // if > 0 the maximum number of read streams to keep open on the backend
+	Prefetch          int           // if > 0 the number of following files to prefetch into the cache
+	PrefetchAfter     int           // number of files to read in sequence before prefetching
+	WriteBackCompress string        // if set compress files written back to a compress remote with this, e.g. "zstd"
+	Metadata          bool          // if set store permissions, owners and access times of files in their metadata
 }
 
 // DefaultOpt is the default values uses for Opt
@@ -61,4 +74,74 @@ var DefaultOpt = Options{
 	WriteBack:         5 * time.Second,
 	ReadAhead:         0 * fs.Mebi,
 	UsedIsSize:        false,
+	HandleBwLimit:     0,
+	BwLimit:           0,
+	Quota:             -1,
+	Trash:             "",
+	TrashMaxAge:       0,
+	TraceSlow:         0,
+	DedupeReads:       false,
+	ReadMaxOpen:       0,
+	Prefetch:          0,
+	PrefetchAfter:     3,
+	Metadata:          false,
+}
+
+// CacheMaxAge, CacheMaxSize and ReadAhead may be changed by
+// vfs/setopts while the VFS is running so must be accessed with the
+// methods below once the VFS has started.
+//
+// They are guarded by a mutex rather than using atomics as 64 bit
+// atomic operations need 64 bit alignment which the fields of
+// Options don't have on 32 bit platforms.
+var liveMu sync.RWMutex
+
+// GetCacheMaxAge returns CacheMaxAge
+func (opt *Options) GetCacheMaxAge() time.Duration {
+	liveMu.RLock()
+	defer liveMu.RUnlock()
+	return opt.CacheMaxAge
+}
+
+// SetCacheMaxAge sets CacheMaxAge
+func (opt *Options) SetCacheMaxAge(maxAge time.Duration) {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	opt.CacheMaxAge = maxAge
+}
+
+// GetCacheMaxSize returns CacheMaxSize
+func (opt *Options) GetCacheMaxSize() fs.SizeSuffix {
+	liveMu.RLock()
+	defer liveMu.RUnlock()
+	return opt.CacheMaxSize
+}
+
+// SetCacheMaxSize sets CacheMaxSize
+func (opt *Options) SetCacheMaxSize(maxSize fs.SizeSuffix) {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	opt.CacheMaxSize = maxSize
+}
+
+// GetReadAhead returns ReadAhead
+func (opt *Options) GetReadAhead() fs.SizeSuffix {
+	liveMu.RLock()
+	defer liveMu.RUnlock()
+	return opt.ReadAhead
+}
+
+// SetReadAhead sets ReadAhead
+func (opt *Options) SetReadAhead(readAhead fs.SizeSuffix) {
+	liveMu.Lock()
+	defer liveMu.Unlock()
+	opt.ReadAhead = readAhead
+}
+
+// Copy returns a copy of the options, reading the live options with
+// the lock held so they can't change while the copy is made
+func (opt *Options) Copy() Options {
+	liveMu.RLock()
+	defer liveMu.RUnlock()
+	return *opt
 }
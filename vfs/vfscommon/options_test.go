@@ -0,0 +1,46 @@
+package vfscommon
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rclone/rclone/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptionsLive(t *testing.T) {
+	// Options embedded after a 32 bit field so the live
+	// options aren't 64 bit aligned on 32 bit platforms
+	var s struct {
+		x   int32
+		opt Options
+	}
+	opt := &s.opt
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				opt.SetCacheMaxAge(time.Duration(i))
+				opt.SetCacheMaxSize(fs.SizeSuffix(i))
+				opt.SetReadAhead(fs.SizeSuffix(i))
+				_ = opt.GetCacheMaxAge()
+				_ = opt.GetCacheMaxSize()
+				_ = opt.GetReadAhead()
+				_ = opt.Copy()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	opt.SetCacheMaxAge(time.Hour)
+	opt.SetCacheMaxSize(fs.Gibi)
+	opt.SetReadAhead(16 * fs.Mebi)
+	assert.Equal(t, time.Hour, opt.GetCacheMaxAge())
+	assert.Equal(t, fs.Gibi, opt.GetCacheMaxSize())
+	assert.Equal(t, 16*fs.Mebi, opt.GetReadAhead())
+	assert.Equal(t, *opt, opt.Copy())
+}
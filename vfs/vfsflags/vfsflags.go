@@ -39,5 +39,17 @@ func AddFlags(flagSet *pflag.FlagSet) {
 	flags.FVarP(flagSet, &Opt.ReadAhead, "vfs-read-ahead", "", "Extra read ahead over --buffer-size when using cache-mode full")
 	flags.BoolVarP(flagSet, &Opt.UsedIsSize, "vfs-used-is-size", "", Opt.UsedIsSize, "Use the `rclone size` algorithm for Used size")
 	flags.BoolVarP(flagSet, &Opt.FastFingerprint, "vfs-fast-fingerprint", "", Opt.FastFingerprint, "Use fast (less accurate) fingerprints for change detection")
+	flags.FVarP(flagSet, &Opt.HandleBwLimit, "vfs-handle-bwlimit", "", "Bandwidth limit for each open file in bytes/s, e.g. 4M (0 is off)")
+	flags.FVarP(flagSet, &Opt.BwLimit, "vfs-bwlimit", "", "Bandwidth limit for all open files together in bytes/s, e.g. 10M (0 is off)")
+	flags.FVarP(flagSet, &Opt.Quota, "vfs-quota", "", "Max total size of the files in the VFS, writes which would exceed it fail")
+	flags.StringVarP(flagSet, &Opt.Trash, "vfs-trash", "", Opt.Trash, "Move deleted files into this directory on the remote instead of deleting them")
+	flags.DurationVarP(flagSet, &Opt.TrashMaxAge, "vfs-trash-max-age", "", Opt.TrashMaxAge, "Remove files from the --vfs-trash after this long (0 is keep forever)")
+	flags.DurationVarP(flagSet, &Opt.TraceSlow, "vfs-trace-slow", "", Opt.TraceSlow, "Log VFS operations which take longer than this (0 is off)")
+	flags.BoolVarP(flagSet, &Opt.DedupeReads, "vfs-dedupe-reads", "", Opt.DedupeReads, "Share one download through the cache between concurrent readers of a file")
+	flags.IntVarP(flagSet, &Opt.ReadMaxOpen, "vfs-read-max-open", "", Opt.ReadMaxOpen, "Max number of files open for reading on the remote at once, idle ones are closed and reopened as needed (0 is unlimited)")
+	flags.IntVarP(flagSet, &Opt.Prefetch, "vfs-prefetch", "", Opt.Prefetch, "Prefetch this many following files into the cache when files in a directory are read in sequence (0 is off)")
+	flags.IntVarP(flagSet, &Opt.PrefetchAfter, "vfs-prefetch-after", "", Opt.PrefetchAfter, "Number of files read in sequence before --vfs-prefetch starts")
+	flags.StringVarP(flagSet, &Opt.WriteBackCompress, "vfs-write-back-compress", "", Opt.WriteBackCompress, "Compress files written back to a compress remote with this (zstd)")
+	flags.BoolVarP(flagSet, &Opt.Metadata, "vfs-metadata", "", Opt.Metadata, "Store the permissions, owners and access times of files in their metadata on the remote")
 	platformFlags(flagSet)
 }
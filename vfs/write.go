@@ -5,15 +5,20 @@ import (
 	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/rclone/rclone/fs"
 	"github.com/rclone/rclone/fs/operations"
+	"golang.org/x/time/rate"
 )
 
 // WriteFileHandle is an open for write handle on a File
 type WriteFileHandle struct {
 	baseHandle
+	transferred int64         // bytes written - accessed with atomic - must be 64 bit aligned
+	limiter     *rate.Limiter // bandwidth limiter for this handle - may be nil
+	limitWaits
 	mu          sync.Mutex
 	cond        *sync.Cond // cond lock for out of sequence writes
 	closed      bool       // set if handle has been closed
@@ -38,10 +43,12 @@ var (
 
 func newWriteFileHandle(d *Dir, f *File, remote string, flags int) (*WriteFileHandle, error) {
 	fh := &WriteFileHandle{
-		remote: remote,
-		flags:  flags,
-		result: make(chan error, 1),
-		file:   f,
+		remote:     remote,
+		flags:      flags,
+		result:     make(chan error, 1),
+		file:       f,
+		limiter:    newHandleLimiter(&d.vfs.Opt),
+		limitWaits: newLimitWaits(),
 	}
 	fh.cond = sync.NewCond(&fh.mu)
 	fh.file.addWriter(fh)
@@ -136,11 +143,22 @@ func (fh *WriteFileHandle) writeAt(p []byte, off int64) (n int, err error) {
 		fs.Errorf(fh.remote, "WriteFileHandle.Write: can't seek in file without --vfs-cache-mode >= writes")
 		return 0, ESPIPE
 	}
+	if err = fh.file.VFS().quotaGrow(off + int64(len(p)) - fh.file.Size()); err != nil {
+		return 0, err
+	}
+	t := fh.file.VFS().traceSlow("write", fh.file, "")
+	defer t.done()
+	stop := t.time(slowBackend)
+	defer stop()
 	if err = fh.openPending(); err != nil {
 		return 0, err
 	}
 	fh.writeCalled = true
 	n, err = fh.pipeWriter.Write(p)
+	addTransferred(&fh.transferred, n)
+	stop()
+	limitHandle(fh.waitCtx, fh.limiter, n, t)
+	limitHandle(fh.waitCtx, fh.file.VFS().limiter, n, t)
 	fh.offset += int64(n)
 	fh.file.setSize(fh.offset)
 	if err != nil {
@@ -187,6 +205,10 @@ func (fh *WriteFileHandle) close() (err error) {
 		return ECLOSED
 	}
 	fh.closed = true
+	fh.file.VFS().handles.remove(fh)
+	t := fh.file.VFS().traceSlow("close", fh.file, "")
+	defer t.done()
+	defer t.time(slowBackend)()
 	// leave writer open until file is transferred
 	defer func() {
 		fh.file.delWriter(fh)
@@ -207,6 +229,11 @@ func (fh *WriteFileHandle) close() (err error) {
 	return err
 }
 
+// bytesTransferred returns the number of bytes written so far
+func (fh *WriteFileHandle) bytesTransferred() int64 {
+	return atomic.LoadInt64(&fh.transferred)
+}
+
 // Close closes the file
 func (fh *WriteFileHandle) Close() error {
 	fh.mu.Lock()